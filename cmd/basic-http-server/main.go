@@ -49,7 +49,12 @@ func NewBasicHTTPServer(cfg *config.Config) *BasicHTTPServer {
 
 	// Configure HTTP/2 support for TLS connections
 	if cfg.Server.TLS.Enabled {
-		if err := http2.ConfigureServer(httpServer, &http2.Server{}); err != nil {
+		h2 := &http2.Server{
+			MaxConcurrentStreams: cfg.Server.HTTP2.MaxConcurrentStreams,
+			MaxReadFrameSize:     cfg.Server.HTTP2.MaxReadFrameSize,
+			IdleTimeout:          cfg.Server.HTTP2.IdleTimeout,
+		}
+		if err := http2.ConfigureServer(httpServer, h2); err != nil {
 			log.Printf("Failed to configure HTTP/2: %v", err)
 		} else {
 			log.Println("HTTP/2 support enabled for TLS connections")
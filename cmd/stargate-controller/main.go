@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
@@ -43,6 +44,11 @@ func main() {
 		log.Fatalf("Failed to load configuration: %v", err)
 	}
 
+	// Resolve secret references again to detect rotations
+	go cfg.SecretResolver().Watch(context.Background(), cfg.Secrets.RefreshInterval, func(paths []string) {
+		log.Printf("Secrets rotated for %s, restart to apply the new values", strings.Join(paths, ", "))
+	})
+
 	// Create controller server
 	server, err := controller.NewServer(cfg)
 	if err != nil {
@@ -70,6 +76,11 @@ func main() {
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 
+	// Keep serving while reporting not ready; a second signal skips the wait
+	lameDuckCtx, stopLameDuck := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	server.LameDuck(lameDuckCtx)
+	stopLameDuck()
+
 	log.Println("Shutting down Stargate Controller...")
 
 	// Create a deadline to wait for
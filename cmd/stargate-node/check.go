@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/songzhibin97/stargate/internal/config"
+	"github.com/songzhibin97/stargate/internal/proxy"
+)
+
+// runCheck validates the configuration file without starting the server and
+// returns the process exit code. The pipeline is constructed to surface
+// errors only caught at initialization, but nothing is started and no ports
+// are bound.
+func runCheck(configFile string, out io.Writer) int {
+	fmt.Fprintf(out, "Checking configuration %s\n", configFile)
+
+	var failures int
+	report := func(step string, err error) {
+		if err != nil {
+			failures++
+			fmt.Fprintf(out, "  [FAIL] %s: %v\n", step, err)
+			return
+		}
+		fmt.Fprintf(out, "  [OK]   %s\n", step)
+	}
+
+	// Loading runs config validation, nothing else can be checked if it fails
+	cfg, err := config.Load(configFile)
+	report("load and validate configuration", err)
+	if err != nil {
+		fmt.Fprintln(out, "Configuration check failed: 1 error")
+		return 1
+	}
+
+	report("validate configuration source", config.ValidateSourceConfig(cfg))
+
+	// Construct the pipeline to catch middleware and component errors
+	pipeline, err := proxy.NewPipeline(cfg, nil)
+	report("initialize pipeline", err)
+	if pipeline != nil {
+		if err := pipeline.Stop(); err != nil {
+			report("release pipeline", err)
+		}
+	}
+
+	if failures > 0 {
+		fmt.Fprintf(out, "Configuration check failed: %d error(s)\n", failures)
+		return 1
+	}
+
+	fmt.Fprintln(out, "Configuration OK")
+	return 0
+}
@@ -0,0 +1,547 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/songzhibin97/stargate/internal/config"
+)
+
+func TestRunCheck(t *testing.T) {
+	var out bytes.Buffer
+	if code := runCheck("../../configs/stargate-node.defaults.yaml", &out); code != 0 {
+		t.Errorf("Expected exit code 0 for default config, got %d: %s", code, out.String())
+	}
+	if !strings.Contains(out.String(), "Configuration OK") {
+		t.Errorf("Expected OK summary, got %s", out.String())
+	}
+
+	invalid := writeConfig(t, "logging:\n  level: verbose\n")
+
+	out.Reset()
+	if code := runCheck(invalid, &out); code != 1 {
+		t.Errorf("Expected exit code 1 for invalid config, got %d", code)
+	}
+	if !strings.Contains(out.String(), "invalid log level") {
+		t.Errorf("Expected validation error in summary, got %s", out.String())
+	}
+}
+
+func TestLoad_SecretReferences(t *testing.T) {
+	dir := t.TempDir()
+	secretFile := filepath.Join(dir, "jwt")
+	credentialsFile := filepath.Join(dir, "credentials.json")
+	os.WriteFile(secretFile, []byte("jwt-from-file\n"), 0600)
+	os.WriteFile(credentialsFile, []byte(`{"client_secret": "oauth-from-json"}`), 0600)
+	t.Setenv("TEST_STARGATE_REDIS_PASSWORD", "redis-from-env")
+
+	path := writeConfig(t, fmt.Sprintf(`
+auth:
+  jwt:
+    secret: "file://%s"
+  oauth2:
+    client_secret: "file://%s#client_secret"
+rate_limit:
+  redis:
+    password: "env://TEST_STARGATE_REDIS_PASSWORD"
+portal:
+  repository:
+    postgres:
+      dsn: "postgres://app:literal@db/portal"
+`, secretFile, credentialsFile))
+
+	cfg, err := config.Load(path)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if cfg.Auth.JWT.Secret != "jwt-from-file" || cfg.Auth.OAuth2.ClientSecret != "oauth-from-json" || cfg.RateLimit.Redis.Password != "redis-from-env" {
+		t.Errorf("Secret references not resolved: %q %q %q", cfg.Auth.JWT.Secret, cfg.Auth.OAuth2.ClientSecret, cfg.RateLimit.Redis.Password)
+	}
+	if cfg.Portal.Repository.Postgres.DSN != "postgres://app:literal@db/portal" {
+		t.Errorf("Expected the postgres DSN to be used as is, got %s", cfg.Portal.Repository.Postgres.DSN)
+	}
+
+	// Rotation is picked up by a refresh
+	os.WriteFile(secretFile, []byte("rotated-jwt"), 0600)
+	changed, err := cfg.SecretResolver().Refresh()
+	if err != nil {
+		t.Fatalf("Failed to refresh secrets: %v", err)
+	}
+	if strings.Join(changed, ",") != "auth.jwt.secret" {
+		t.Errorf("Expected auth.jwt.secret to rotate, got %v", changed)
+	}
+	if value, _ := cfg.SecretResolver().Value("auth.jwt.secret"); value != "rotated-jwt" {
+		t.Errorf("Expected the rotated value, got %q", value)
+	}
+
+	// Failures name the field and reference, not the value
+	t.Setenv("TEST_STARGATE_REDIS_PASSWORD", "")
+	os.Unsetenv("TEST_STARGATE_REDIS_PASSWORD")
+	var out bytes.Buffer
+	if code := runCheck(path, &out); code != 1 {
+		t.Fatalf("Expected exit code 1 for an unresolvable reference, got %d", code)
+	}
+	if !strings.Contains(out.String(), "rate_limit.redis.password: failed to resolve secret reference env://TEST_STARGATE_REDIS_PASSWORD") {
+		t.Errorf("Expected the reference in the error, got %s", out.String())
+	}
+	if strings.Contains(out.String(), "rotated-jwt") || strings.Contains(out.String(), "oauth-from-json") {
+		t.Errorf("Secret value leaked in the error: %s", out.String())
+	}
+
+	unknown := writeConfig(t, "auth:\n  jwt:\n    secret: \"vault://secret/gateway#jwt\"\n")
+	if _, err := config.Load(unknown); err == nil || !strings.Contains(err.Error(), "no secret provider registered for scheme vault") {
+		t.Errorf("Expected an error for an unregistered scheme, got %v", err)
+	}
+}
+
+func TestLoad_NoRouteAction(t *testing.T) {
+	for action, valid := range map[string]bool{
+		"error":                         true,
+		"static":                        true,
+		"upstream:catch-all":            true,
+		"redirect:https://example.com/": true,
+		"redirect:/home":                true,
+		"redirect:home":                 false,
+		"upstream:":                     false,
+		"static:page":                   false,
+		"forward:catch-all":             false,
+	} {
+		_, err := config.Load(writeConfig(t, fmt.Sprintf("proxy:\n  no_route:\n    action: %q\n", action)))
+		if valid && err != nil {
+			t.Errorf("Expected no-route action %q to be valid, got %v", action, err)
+		}
+		if !valid && err == nil {
+			t.Errorf("Expected no-route action %q to be rejected", action)
+		}
+	}
+}
+
+func TestLoad_RootPage(t *testing.T) {
+	pageFile := filepath.Join(t.TempDir(), "index.html")
+	if err := os.WriteFile(pageFile, []byte("<h1>Stargate</h1>"), 0644); err != nil {
+		t.Fatalf("Failed to write page: %v", err)
+	}
+
+	for settings, valid := range map[string]bool{
+		"body: ok\npaths: [/status]\n":                          true,
+		"file: " + pageFile + "\n":                              true,
+		"redirect: https://docs.example.com\n":                  true,
+		"redirect: /docs\nstatus_code: 301\n":                   true,
+		"redirect: docs\n":                                      false,
+		"redirect: /docs\nbody: ok\n":                           false,
+		"redirect: /docs\nstatus_code: 200\n":                   false,
+		"body: ok\nfile: " + pageFile + "\n":                    false,
+		"file: " + filepath.Join(t.TempDir(), "missing") + "\n": false,
+		"body: ok\npaths: [status]\n":                           false,
+		"body: ok\nstatus_code: 99\n":                           false,
+	} {
+		_, err := config.Load(writeConfig(t, "proxy:\n  root:\n    enabled: true\n    "+strings.ReplaceAll(strings.TrimSuffix(settings, "\n"), "\n", "\n    ")+"\n"))
+		if valid && err != nil {
+			t.Errorf("Expected %q to be valid, got %v", settings, err)
+		}
+		if !valid && err == nil {
+			t.Errorf("Expected %q to be rejected", settings)
+		}
+	}
+}
+
+func TestLoad_UpstreamDebugLog(t *testing.T) {
+	for settings, valid := range map[string]bool{
+		"default_duration: 15m\nmax_duration: 2h\n": true,
+		"default_duration: 30m\n":                   true,
+		"max_body_size: 1024\n":                     true,
+		"default_duration: 2h\n":                    false,
+		"default_duration: 2h\nmax_duration: 1h\n":  false,
+		"max_duration: -1m\n":                       false,
+		"max_body_size: -1\n":                       false,
+	} {
+		_, err := config.Load(writeConfig(t, "upstreams:\n  debug_log:\n    "+strings.ReplaceAll(strings.TrimSuffix(settings, "\n"), "\n", "\n    ")+"\n"))
+		if valid && err != nil {
+			t.Errorf("Expected %q to be valid, got %v", settings, err)
+		}
+		if !valid && err == nil {
+			t.Errorf("Expected %q to be rejected", settings)
+		}
+	}
+}
+
+func TestLoad_UpstreamPriority(t *testing.T) {
+	for settings, valid := range map[string]bool{
+		"tiers: [premium, free]\n":                        true,
+		"tiers: [premium, free]\ndefault_tier: premium\n": true,
+		"tiers: [premium, premium]\n":                     false,
+		"tiers: [premium, \"\"]\n":                        false,
+		"tiers: [premium, free]\ndefault_tier: gold\n":    false,
+	} {
+		_, err := config.Load(writeConfig(t, "upstreams:\n  priority:\n    "+strings.ReplaceAll(strings.TrimSuffix(settings, "\n"), "\n", "\n    ")+"\n"))
+		if valid && err != nil {
+			t.Errorf("Expected %q to be valid, got %v", settings, err)
+		}
+		if !valid && err == nil {
+			t.Errorf("Expected %q to be rejected", settings)
+		}
+	}
+}
+
+func TestLoad_SessionAffinity(t *testing.T) {
+	for settings, valid := range map[string]bool{
+		"ttl: 30m\n": true,
+		"cookie_name: orders_pin\npath: /orders\nhttp_only: true\n": true,
+		"same_site: Strict\n":             true,
+		"same_site: none\nsecure: true\n": true,
+		"same_site: none\n":               false,
+		"same_site: relaxed\n":            false,
+		"ttl: -1s\n":                      false,
+	} {
+		_, err := config.Load(writeConfig(t, "upstreams:\n  affinity:\n    orders:\n      "+strings.ReplaceAll(strings.TrimSuffix(settings, "\n"), "\n", "\n      ")+"\n"))
+		if valid && err != nil {
+			t.Errorf("Expected %q to be valid, got %v", settings, err)
+		}
+		if !valid && err == nil {
+			t.Errorf("Expected %q to be rejected", settings)
+		}
+	}
+}
+
+func TestLoad_PortalSecretHashAlgorithm(t *testing.T) {
+	for algorithm, valid := range map[string]bool{
+		"":         true,
+		"bcrypt":   true,
+		"argon2id": true,
+		"md5":      false,
+	} {
+		_, err := config.Load(writeConfig(t, "portal:\n  repository:\n    postgres:\n      secret_hash_algorithm: \""+algorithm+"\"\n"))
+		if valid && err != nil {
+			t.Errorf("Expected %q to be valid, got %v", algorithm, err)
+		}
+		if !valid && err == nil {
+			t.Errorf("Expected %q to be rejected", algorithm)
+		}
+	}
+}
+
+func TestLoad_EtcdSource(t *testing.T) {
+	for settings, valid := range map[string]bool{
+		"dial_keepalive_time: 30s\ndial_keepalive_timeout: 10s\n":    true,
+		"auto_sync_interval: 5m\n":                                   true,
+		"max_retries: 5\nretry_backoff: 1s\nmax_retry_backoff: 1m\n": true,
+		"dial_keepalive_time: -1s\n":                                 false,
+		"auto_sync_interval: -1s\n":                                  false,
+		"max_retries: -1\n":                                          false,
+		"retry_backoff: -1s\n":                                       false,
+		"retry_backoff: 1m\nmax_retry_backoff: 1s\n":                 false,
+	} {
+		cfg, err := config.Load(writeConfig(t, "config:\n  source:\n    driver: etcd\n    etcd:\n      "+strings.ReplaceAll(strings.TrimSuffix(settings, "\n"), "\n", "\n      ")+"\n"))
+		if err != nil {
+			t.Fatalf("Failed to load %q: %v", settings, err)
+		}
+		err = config.ValidateSourceConfig(cfg)
+		if valid && err != nil {
+			t.Errorf("Expected %q to be valid, got %v", settings, err)
+		}
+		if !valid && err == nil {
+			t.Errorf("Expected %q to be rejected", settings)
+		}
+	}
+}
+
+func TestLoad_LeaderElection(t *testing.T) {
+	for settings, valid := range map[string]bool{
+		"backend: etcd\n": true,
+		"backend: redis\nredis:\n  address: localhost:6379\n":                                 true,
+		"backend: etcd\nstandby_writes: proxy\nadvertise_address: http://controller-1:9090\n": true,
+		"backend: etcd\nlease_ttl: 30s\nrenew_interval: 10s\n":                                true,
+		"backend: consul\n":                                     false,
+		"backend: redis\n":                                      false,
+		"backend: etcd\nlease_ttl: 500ms\n":                     false,
+		"backend: etcd\nrenew_interval: 15s\n":                  false,
+		"backend: etcd\nstandby_writes: proxy\n":                false,
+		"backend: etcd\nstandby_writes: drop\n":                 false,
+		"backend: etcd\nadvertise_address: controller-1:9090\n": false,
+	} {
+		_, err := config.Load(writeConfig(t, "store:\n  type: etcd\ncontroller:\n  leader_election:\n    enabled: true\n    "+strings.ReplaceAll(strings.TrimSuffix(settings, "\n"), "\n", "\n    ")+"\n"))
+		if valid && err != nil {
+			t.Errorf("Expected %q to be valid, got %v", settings, err)
+		}
+		if !valid && err == nil {
+			t.Errorf("Expected %q to be rejected", settings)
+		}
+	}
+
+	// The etcd backend runs on the store's cluster
+	if _, err := config.Load(writeConfig(t, "store:\n  type: memory\ncontroller:\n  leader_election:\n    enabled: true\n    backend: etcd\n")); err == nil {
+		t.Error("Expected the etcd backend to require the etcd store")
+	}
+}
+
+func TestLoad_SchemaValidation(t *testing.T) {
+	schemaFile := filepath.Join(t.TempDir(), "order.json")
+	if err := os.WriteFile(schemaFile, []byte(`{"type": "object", "required": ["id"]}`), 0644); err != nil {
+		t.Fatalf("Failed to write schema: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		route string
+		valid bool
+	}{
+		{"inline schema", "request:\n          type: object\n          properties:\n            id: {type: string}", true},
+		{"schema file", "response_file: " + schemaFile, true},
+		{"invalid inline schema", "request:\n          type: decimal", false},
+		{"invalid pattern", "request:\n          pattern: \"(\"", false},
+		{"missing schema file", "request_file: " + schemaFile + ".missing", false},
+		{"inline and file", "request: {type: object}\n        request_file: " + schemaFile, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content := "schema_validation:\n  enabled: true\n  routes:\n    orders:\n        " + tt.route + "\n"
+			_, err := config.Load(writeConfig(t, content))
+			if tt.valid && err != nil {
+				t.Errorf("Expected schema to be valid, got %v", err)
+			}
+			if !tt.valid && (err == nil || !strings.Contains(err.Error(), "invalid schema of route orders")) {
+				t.Errorf("Expected the schema to fail loading, got %v", err)
+			}
+		})
+	}
+}
+
+func TestLoad_HTTP2(t *testing.T) {
+	cfg, err := config.Load(writeConfig(t, "server:\n  address: \":8080\"\n"))
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if cfg.Server.HTTP2.MaxConcurrentStreams != 100 || cfg.Server.HTTP2.MaxReadFrameSize != 1<<20 || cfg.Controller.HTTP2.MaxConcurrentStreams != 100 {
+		t.Errorf("Expected HTTP/2 defaults, got %+v and %+v", cfg.Server.HTTP2, cfg.Controller.HTTP2)
+	}
+
+	for settings, valid := range map[string]bool{
+		"server:\n  http2:\n    max_concurrent_streams: 50\n    idle_timeout: 30s\n": true,
+		"server:\n  http2:\n    max_read_frame_size: 16384\n":                        true,
+		"server:\n  http2:\n    max_read_frame_size: 16777215\n":                     true,
+		"server:\n  http2:\n    max_concurrent_streams: 0\n":                         false,
+		"server:\n  http2:\n    max_read_frame_size: 1024\n":                         false,
+		"server:\n  http2:\n    max_read_frame_size: 16777216\n":                     false,
+		"server:\n  http2:\n    idle_timeout: -1s\n":                                 false,
+		"controller:\n  http2:\n    max_concurrent_streams: 0\n":                     false,
+	} {
+		_, err := config.Load(writeConfig(t, settings))
+		if valid && err != nil {
+			t.Errorf("Expected %q to be valid, got %v", settings, err)
+		}
+		if !valid && err == nil {
+			t.Errorf("Expected %q to be rejected", settings)
+		}
+	}
+}
+
+func TestLoad_RequestDeadline(t *testing.T) {
+	cfg, err := config.Load(writeConfig(t, "proxy:\n  deadline:\n    enabled: true\n    timeout: 5s\n"))
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if cfg.Proxy.Deadline.Header != "X-Request-Timeout" || cfg.Proxy.Deadline.Timeout != 5*time.Second {
+		t.Errorf("Expected the deadline header default and timeout, got %+v", cfg.Proxy.Deadline)
+	}
+
+	for settings, valid := range map[string]bool{
+		"per_route:\n      reports: 1m\n":              true,
+		"trusted_sources: [\"10.0.0.0/8\", \"::1\"]\n": true,
+		"timeout: -1s\n":                               false,
+		"per_route:\n      reports: 0s\n":              false,
+		"trusted_sources: [\"10.0.0.0/33\"]\n":         false,
+	} {
+		_, err := config.Load(writeConfig(t, "proxy:\n  deadline:\n    enabled: true\n    "+settings))
+		if valid && err != nil {
+			t.Errorf("Expected %q to be valid, got %v", settings, err)
+		}
+		if !valid && err == nil {
+			t.Errorf("Expected %q to be rejected", settings)
+		}
+	}
+}
+
+func TestLoad_Retry(t *testing.T) {
+	cfg, err := config.Load(writeConfig(t, "proxy:\n  retry:\n    enabled: true\n    per_route:\n      orders:\n        methods: [POST]\n        conditions: [connect-error, 503]\n"))
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	retry := cfg.Proxy.Retry
+	if retry.Attempts != 2 || retry.MaxBodySize != 1024*1024 {
+		t.Errorf("Expected the retry defaults, got %+v", retry)
+	}
+	if orders := retry.PerRoute["orders"]; len(orders.Methods) != 1 || len(orders.Conditions) != 2 || orders.Conditions[1] != "503" {
+		t.Errorf("Expected the route retry policy, got %+v", orders)
+	}
+
+	for settings, valid := range map[string]bool{
+		"attempts: 5\n":  true,
+		"attempts: 6\n":  false,
+		"attempts: -1\n": false,
+		"backoff: -1s\n": false,
+		"per_route:\n      orders:\n        methods: [\"*\"]\n":       true,
+		"per_route:\n      orders:\n        methods: [\"GET /\"]\n":   false,
+		"per_route:\n      orders:\n        conditions: [429, 504]\n": true,
+		"per_route:\n      orders:\n        conditions: [timeout]\n":  false,
+		"per_route:\n      orders:\n        conditions: [200]\n":      false,
+	} {
+		_, err := config.Load(writeConfig(t, "proxy:\n  retry:\n    enabled: true\n    "+settings))
+		if valid && err != nil {
+			t.Errorf("Expected %q to be valid, got %v", settings, err)
+		}
+		if !valid && err == nil {
+			t.Errorf("Expected %q to be rejected", settings)
+		}
+	}
+}
+
+func TestLoad_CacheWarm(t *testing.T) {
+	cfg, err := config.Load(writeConfig(t, "proxy:\n  cache_warm:\n    enabled: true\n    per_route:\n      catalog:\n        urls: [\"https://api.example.com/catalog\"]\n"))
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	warm := cfg.Proxy.CacheWarm
+	if warm.Concurrency != 4 || warm.Timeout != 10*time.Second || warm.Deadline != 30*time.Second {
+		t.Errorf("Expected the cache warm defaults, got %+v", warm)
+	}
+	if urls := warm.PerRoute["catalog"].URLs; len(urls) != 1 {
+		t.Errorf("Expected the route's warm URLs, got %v", urls)
+	}
+
+	for settings, valid := range map[string]bool{
+		"concurrency: 16\n": true,
+		"concurrency: 0\n":  false,
+		"timeout: 0s\n":     false,
+		"deadline: -1s\n":   false,
+		"per_route:\n      catalog:\n        urls: [\"http://localhost:8080/items?page=1\"]\n": true,
+		"per_route:\n      catalog:\n        urls: [\"/catalog\"]\n":                           false,
+		"per_route:\n      catalog:\n        urls: [\"ftp://example.com/catalog\"]\n":          false,
+	} {
+		_, err := config.Load(writeConfig(t, "proxy:\n  cache_warm:\n    enabled: true\n    "+settings))
+		if valid && err != nil {
+			t.Errorf("Expected %q to be valid, got %v", settings, err)
+		}
+		if !valid && err == nil {
+			t.Errorf("Expected %q to be rejected", settings)
+		}
+	}
+}
+
+func TestLoad_OAuth2RetryBackoff(t *testing.T) {
+	for settings, valid := range map[string]bool{
+		"retry_multiplier: 1.5\nretry_jitter: 0.2\nmax_retry_delay: 2s\nmax_elapsed_time: 5s\n": true,
+		"retry_multiplier: 0.5\n": false,
+		"retry_jitter: 1.5\n":     false,
+		"retry_jitter: -0.1\n":    false,
+		"max_retry_delay: -1s\n":  false,
+		"max_elapsed_time: -1s\n": false,
+	} {
+		_, err := config.Load(writeConfig(t, "auth:\n  oauth2:\n    "+strings.ReplaceAll(settings, "\n", "\n    ")))
+		if valid && err != nil {
+			t.Errorf("Expected %q to be valid, got %v", settings, err)
+		}
+		if !valid && err == nil {
+			t.Errorf("Expected %q to be rejected", settings)
+		}
+	}
+}
+
+func TestLoad_MetricsRouteGroups(t *testing.T) {
+	for groups, valid := range map[string]bool{
+		"public:\n  routes: [orders]\n  subsystem: public\n  labels:\n    audience: public\n": true,
+		"public:\n  routes: [orders]\n":                                                                            false,
+		"public:\n  routes: [orders]\n  subsystem: public-api\n":                                                   false,
+		"public:\n  subsystem: public\n":                                                                           false,
+		"public:\n  routes: [orders]\n  subsystem: public\n  labels:\n    route: orders\n":                         false,
+		"public:\n  routes: [orders]\n  subsystem: public\n  labels:\n    audience: \"\"\n":                        false,
+		"public:\n  routes: [orders]\n  subsystem: api\ninternal:\n  routes: [reports]\n  subsystem: api\n":        false,
+		"public:\n  routes: [orders]\n  subsystem: public\ninternal:\n  routes: [orders]\n  subsystem: internal\n": false,
+	} {
+		_, err := config.Load(writeConfig(t, "metrics:\n  route_groups:\n    "+strings.ReplaceAll(strings.TrimSuffix(groups, "\n"), "\n", "\n    ")+"\n"))
+		if valid && err != nil {
+			t.Errorf("Expected %q to be valid, got %v", groups, err)
+		}
+		if !valid && err == nil {
+			t.Errorf("Expected %q to be rejected", groups)
+		}
+	}
+}
+
+func TestLoad_AuthShadow(t *testing.T) {
+	for settings, valid := range map[string]bool{
+		"jwt:\n  secret: new-secret\nroutes: [orders]\n":   true,
+		"api_key:\n  header: X-API-Key\n":                  true,
+		"routes: [orders]\n":                               false,
+		"jwt:\n  secret: new-secret\ntimeout: 0s\n":        false,
+		"jwt:\n  secret: new-secret\nmax_concurrent: -1\n": false,
+	} {
+		_, err := config.Load(writeConfig(t, "auth:\n  shadow:\n    enabled: true\n    "+strings.ReplaceAll(strings.TrimSuffix(settings, "\n"), "\n", "\n    ")+"\n"))
+		if valid && err != nil {
+			t.Errorf("Expected %q to be valid, got %v", settings, err)
+		}
+		if !valid && err == nil {
+			t.Errorf("Expected %q to be rejected", settings)
+		}
+	}
+}
+
+func TestLoad_Tenancy(t *testing.T) {
+	cfg, err := config.Load(writeConfig(t, "tenancy:\n  enabled: true\n  tenants:\n    acme:\n      hosts: [api.acme.com]\n      api_keys: [acme-key]\n"))
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if cfg.Tenancy.Header != "X-Stargate-Tenant" || cfg.Tenancy.JWTClaim != "tenant" {
+		t.Errorf("Expected the tenancy defaults, got %+v", cfg.Tenancy)
+	}
+	if tenant := cfg.Tenancy.TenantForHost("API.acme.com:8080"); tenant != "acme" {
+		t.Errorf("Expected host to select tenant acme, got %q", tenant)
+	}
+	if tenant, ok := cfg.Tenancy.TenantForAPIKey("acme-key"); !ok || tenant != "acme" {
+		t.Errorf("Expected API key to be bound to tenant acme, got %q", tenant)
+	}
+
+	for settings, valid := range map[string]bool{
+		"tenants:\n    acme-eu_1: {}\n": true,
+		"tenants:\n    Acme: {}\n":      false,
+		"tenants:\n    acme/eu: {}\n":   false,
+		"header: \"\"\n":                false,
+		"tenants:\n    acme:\n      hosts: [a.example.com]\n    globex:\n      hosts: [A.example.com]\n": false,
+		"tenants:\n    acme:\n      api_keys: [k]\n    globex:\n      api_keys: [k]\n":                   false,
+		"tenants:\n    acme:\n      api_keys: [\"\"]\n":                                                  false,
+	} {
+		_, err := config.Load(writeConfig(t, "tenancy:\n  enabled: true\n  "+settings))
+		if valid && err != nil {
+			t.Errorf("Expected %q to be valid, got %v", settings, err)
+		}
+		if !valid && err == nil {
+			t.Errorf("Expected %q to be rejected", settings)
+		}
+	}
+}
+
+func TestLoad_RateLimitStorage(t *testing.T) {
+	for settings, valid := range map[string]bool{
+		"storage: memory\n":    true,
+		"storage: redis\n":     true,
+		"storage: memcached\n": false,
+		"storage: dynamodb\n  dynamodb:\n    table: counters\n":                                          true,
+		"storage: dynamodb\n  dynamodb:\n    table: counters\n    endpoint: \"http://localhost:8000\"\n": true,
+		"storage: dynamodb\n  dynamodb:\n    region: us-east-1\n":                                        false,
+		"storage: dynamodb\n  dynamodb:\n    table: counters\n    endpoint: \"localhost:8000\"\n":        false,
+		"storage: dynamodb\n  dynamodb:\n    table: counters\n    access_key_id: AKID\n":                 false,
+	} {
+		_, err := config.Load(writeConfig(t, "rate_limit:\n  "+settings))
+		if valid && err != nil {
+			t.Errorf("Expected %q to be valid, got %v", settings, err)
+		}
+		if !valid && err == nil {
+			t.Errorf("Expected %q to be rejected", settings)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/songzhibin97/stargate/internal/config"
+)
+
+// runLint reports risky and deprecated settings in the configuration file and
+// returns the process exit code. Warnings don't fail the run, errors do.
+func runLint(configFile string, out io.Writer) int {
+	cfg, err := config.Load(configFile)
+	if err != nil {
+		fmt.Fprintf(out, "[error] %v\n", err)
+		return 1
+	}
+
+	findings := config.Lint(cfg)
+	for _, finding := range findings {
+		fmt.Fprintln(out, finding)
+	}
+
+	if config.HasLintErrors(findings) {
+		fmt.Fprintf(out, "Lint failed: %d finding(s)\n", len(findings))
+		return 1
+	}
+	if len(findings) > 0 {
+		fmt.Fprintf(out, "Lint passed with %d warning(s)\n", len(findings))
+		return 0
+	}
+
+	fmt.Fprintln(out, "Lint passed: no findings")
+	return 0
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeConfig writes a configuration file for the test
+func writeConfig(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	return path
+}
+
+func TestRunLint(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   string
+		code     int
+		expected []string
+	}{
+		{
+			name: "cors credentials with all origins",
+			config: `
+server:
+  address: "127.0.0.1:8080"
+rate_limit:
+  enabled: true
+cors:
+  enabled: true
+  allow_all_origins: true
+  allow_credentials: true
+`,
+			code:     1,
+			expected: []string{"[error] cors.allow_credentials"},
+		},
+		{
+			name: "warnings only",
+			config: `
+server:
+  address: ":8080"
+proxy:
+  upstreams:
+    legacy:
+      tls:
+        enabled: true
+        insecure_skip_verify: true
+metrics:
+  enabled: true
+  prometheus:
+    enabled: true
+    namespace: "legacy"
+`,
+			code: 0,
+			expected: []string{
+				"[warning] proxy.upstreams.legacy.tls.insecure_skip_verify",
+				"[warning] rate_limit.enabled",
+				"[warning] metrics.prometheus",
+			},
+		},
+		{
+			name: "clean",
+			config: `
+server:
+  address: "127.0.0.1:8080"
+`,
+			code:     0,
+			expected: []string{"no findings"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			if code := runLint(writeConfig(t, tt.config), &out); code != tt.code {
+				t.Errorf("Expected exit code %d, got %d: %s", tt.code, code, out.String())
+			}
+			for _, expected := range tt.expected {
+				if !strings.Contains(out.String(), expected) {
+					t.Errorf("Expected output to contain %q, got %s", expected, out.String())
+				}
+			}
+		})
+	}
+}
@@ -8,17 +8,21 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/songzhibin97/stargate/internal/config"
 	"github.com/songzhibin97/stargate/internal/proxy"
 	"github.com/songzhibin97/stargate/internal/router"
+	pkgconfig "github.com/songzhibin97/stargate/pkg/config"
 )
 
 var (
 	configFile = flag.String("config", "config.yaml", "Configuration file path")
 	version    = flag.Bool("version", false, "Show version information")
+	check      = flag.Bool("check", false, "Validate configuration and exit without starting the server")
+	lint       = flag.Bool("lint", false, "Report risky and deprecated configuration settings and exit")
 )
 
 const (
@@ -38,6 +42,14 @@ func main() {
 		os.Exit(0)
 	}
 
+	if *check {
+		os.Exit(runCheck(*configFile, os.Stdout))
+	}
+
+	if *lint {
+		os.Exit(runLint(*configFile, os.Stdout))
+	}
+
 	// Load configuration
 	cfg, err := config.Load(*configFile)
 	if err != nil {
@@ -82,12 +94,22 @@ func main() {
 
 	log.Printf("Configuration source initialized: driver=%s", cfg.ConfigSource.Source.Driver)
 
+	// Resolve secret references again to detect rotations
+	go cfg.SecretResolver().Watch(ctx, cfg.Secrets.RefreshInterval, func(paths []string) {
+		log.Printf("Secrets rotated for %s, restart to apply the new values", strings.Join(paths, ", "))
+	})
+
 	// Create proxy server
 	server, err := proxy.NewServer(cfg)
 	if err != nil {
 		log.Fatalf("Failed to create proxy server: %v", err)
 	}
 
+	// Report a config source that can lose its connection in health
+	if reporter, ok := configSource.(pkgconfig.HealthReporter); ok {
+		server.SetConfigSource(reporter)
+	}
+
 	// Start server in a goroutine
 	go func() {
 		log.Printf("Starting Stargate Node on %s", cfg.Server.Address)
@@ -101,6 +123,11 @@ func main() {
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 
+	// Keep serving while reporting not ready; a second signal skips the wait
+	lameDuckCtx, stopLameDuck := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	server.LameDuck(lameDuckCtx)
+	stopLameDuck()
+
 	log.Println("Shutting down Stargate Node...")
 
 	// Create a deadline to wait for
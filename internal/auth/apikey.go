@@ -1,22 +1,32 @@
 package auth
 
 import (
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
 	"net/http"
-	"strings"
 	"sync"
 	"time"
 
+	"github.com/songzhibin97/stargate/internal/clientip"
 	"github.com/songzhibin97/stargate/internal/config"
+	"github.com/songzhibin97/stargate/pkg/portal"
 )
 
 // APIKeyAuthenticator handles API key authentication
 type APIKeyAuthenticator struct {
-	config    *config.APIKeyConfig
-	consumers *ConsumerManager
-	mu        sync.RWMutex
+	config       *config.APIKeyConfig
+	consumers    *ConsumerManager
+	applications ApplicationResolver
+	lastUsed     *lastUsedTracker // Records application uses when the resolver is a LastUsedRecorder
+	mu           sync.RWMutex
+}
+
+// ApplicationResolver looks up developer portal applications by API key.
+// portal.ApplicationRepository implementations satisfy it.
+type ApplicationResolver interface {
+	GetApplicationByAPIKey(ctx context.Context, apiKey string) (*portal.Application, error)
 }
 
 // Consumer represents an API key consumer
@@ -34,6 +44,7 @@ type Consumer struct {
 	// Rate limiting and access control
 	RateLimit   *RateLimitConfig  `json:"rate_limit,omitempty"`
 	IPWhitelist []string          `json:"ip_whitelist,omitempty"`
+	Scopes      []string          `json:"scopes,omitempty"` // Scopes granted to the consumer
 	
 	// Statistics
 	RequestCount int64 `json:"request_count"`
@@ -107,16 +118,25 @@ func (a *APIKeyAuthenticator) Authenticate(r *http.Request) (*AuthResult, error)
 			Authenticated: false,
 			Error:         "API key not provided",
 			StatusCode:    http.StatusUnauthorized,
+			Reason:        FailureMissingCredentials,
 		}, nil
 	}
 	
-	// Find consumer by API key
+	// Find consumer by API key, falling back to portal applications
 	consumer, err := a.consumers.GetConsumerByAPIKey(apiKey)
-	if err != nil {
+	fromApplication := err != nil
+	if fromApplication {
+		consumer, err = a.resolveApplication(r.Context(), apiKey)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if consumer == nil {
 		return &AuthResult{
 			Authenticated: false,
 			Error:         "Invalid API key",
 			StatusCode:    http.StatusUnauthorized,
+			Reason:        FailureUnknownKey,
 		}, nil
 	}
 	
@@ -126,6 +146,7 @@ func (a *APIKeyAuthenticator) Authenticate(r *http.Request) (*AuthResult, error)
 			Authenticated: false,
 			Error:         "API key is disabled",
 			StatusCode:    http.StatusForbidden,
+			Reason:        FailureKeyDisabled,
 		}, nil
 	}
 	
@@ -137,18 +158,24 @@ func (a *APIKeyAuthenticator) Authenticate(r *http.Request) (*AuthResult, error)
 				Authenticated: false,
 				Error:         "IP address not whitelisted",
 				StatusCode:    http.StatusForbidden,
+				Reason:        FailureIPNotAllowed,
 			}, nil
 		}
 	}
 	
 	// Update consumer statistics
-	a.consumers.UpdateConsumerStats(consumer.ID)
+	if fromApplication {
+		a.touchApplication(consumer.ID)
+	} else {
+		a.consumers.UpdateConsumerStats(consumer.ID)
+	}
 	
 	// Create user info
 	userInfo := &UserInfo{
-		ID:       consumer.ID,
-		Username: consumer.Name,
-		Metadata: consumer.Metadata,
+		ID:          consumer.ID,
+		Username:    consumer.Name,
+		Permissions: consumer.Scopes,
+		Metadata:    consumer.Metadata,
 	}
 	
 	return &AuthResult{
@@ -163,6 +190,81 @@ func (a *APIKeyAuthenticator) GetName() string {
 	return "api_key"
 }
 
+// SetApplicationResolver sets the lookup of portal applications for API keys
+// not found among the configured keys, or removes it when nil. When the
+// resolver is also a LastUsedRecorder, application uses are recorded with it
+// in the background.
+func (a *APIKeyAuthenticator) SetApplicationResolver(resolver ApplicationResolver) {
+	a.mu.Lock()
+	previous := a.lastUsed
+	a.applications = resolver
+	a.lastUsed = nil
+	if recorder, ok := resolver.(LastUsedRecorder); ok {
+		a.lastUsed = newLastUsedTracker(recorder, lastUsedFlushInterval)
+	}
+	a.mu.Unlock()
+
+	if previous != nil {
+		previous.close()
+	}
+}
+
+// Close records the pending application uses and stops recording them
+func (a *APIKeyAuthenticator) Close() {
+	a.mu.Lock()
+	tracker := a.lastUsed
+	a.lastUsed = nil
+	a.mu.Unlock()
+
+	if tracker != nil {
+		tracker.close()
+	}
+}
+
+// touchApplication notes a successful authentication with the application's
+// API key, to be recorded in the background
+func (a *APIKeyAuthenticator) touchApplication(appID string) {
+	a.mu.RLock()
+	tracker := a.lastUsed
+	a.mu.RUnlock()
+
+	if tracker != nil {
+		tracker.touch(appID, time.Now())
+	}
+}
+
+// resolveApplication returns the consumer of the portal application owning
+// the API key, or nil if no application owns it
+func (a *APIKeyAuthenticator) resolveApplication(ctx context.Context, apiKey string) (*Consumer, error) {
+	a.mu.RLock()
+	resolver := a.applications
+	a.mu.RUnlock()
+	if resolver == nil {
+		return nil, nil
+	}
+
+	app, err := resolver.GetApplicationByAPIKey(ctx, apiKey)
+	if err != nil {
+		if portal.IsNotFoundError(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to resolve application: %w", err)
+	}
+
+	return &Consumer{
+		ID:        app.ID,
+		Name:      app.Name,
+		Enabled:   app.Status == portal.ApplicationStatusActive,
+		CreatedAt: app.CreatedAt,
+		UpdatedAt: app.UpdatedAt,
+		Metadata: map[string]string{
+			"app_id":  app.ID,
+			"user_id": app.UserID,
+		},
+		Scopes: app.Scopes,
+	}, nil
+}
+
 // extractAPIKey extracts API key from request headers or query parameters
 func (a *APIKeyAuthenticator) extractAPIKey(r *http.Request) string {
 	// Try header first
@@ -188,26 +290,9 @@ func (a *APIKeyAuthenticator) hashAPIKey(apiKey string) string {
 	return hex.EncodeToString(hash[:])
 }
 
-// getClientIP extracts client IP from request
+// getClientIP returns the client IP resolved for the request
 func (a *APIKeyAuthenticator) getClientIP(r *http.Request) string {
-	// Check X-Forwarded-For header
-	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
-		ips := strings.Split(xff, ",")
-		return strings.TrimSpace(ips[0])
-	}
-	
-	// Check X-Real-IP header
-	if xri := r.Header.Get("X-Real-IP"); xri != "" {
-		return strings.TrimSpace(xri)
-	}
-	
-	// Use remote address
-	ip := r.RemoteAddr
-	if idx := strings.LastIndex(ip, ":"); idx != -1 {
-		ip = ip[:idx]
-	}
-	
-	return ip
+	return clientip.FromRequest(r)
 }
 
 // isIPWhitelisted checks if IP is in whitelist
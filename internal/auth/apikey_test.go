@@ -1,12 +1,15 @@
 package auth
 
 import (
+	"context"
 	"net/http"
 	"net/http/httptest"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/songzhibin97/stargate/internal/config"
+	"github.com/songzhibin97/stargate/pkg/portal"
 )
 
 func TestAPIKeyAuthenticator_Authenticate(t *testing.T) {
@@ -282,3 +285,125 @@ func TestConsumerManager_RemoveConsumer(t *testing.T) {
 		t.Error("Expected error when removing non-existent consumer")
 	}
 }
+
+// staticApplications resolves API keys from a map of applications
+type staticApplications map[string]*portal.Application
+
+func (s staticApplications) GetApplicationByAPIKey(ctx context.Context, apiKey string) (*portal.Application, error) {
+	app, ok := s[apiKey]
+	if !ok {
+		return nil, portal.NewNotFoundError("APPLICATION_NOT_FOUND", "application not found")
+	}
+	return app, nil
+}
+
+func TestMiddleware_ApplicationScopes(t *testing.T) {
+	cfg := &config.AuthConfig{
+		Enabled: true,
+		APIKey:  config.APIKeyConfig{Header: "X-API-Key", Keys: []string{"config-key"}},
+	}
+	middleware := NewMiddleware(cfg)
+	middleware.SetApplicationResolver(staticApplications{
+		"app-key":       {ID: "app1", Name: "Orders Client", UserID: "user1", Status: portal.ApplicationStatusActive, Scopes: []string{"orders:read", "orders:write"}},
+		"suspended-key": {ID: "app2", Name: "Suspended Client", UserID: "user1", Status: portal.ApplicationStatusSuspended},
+	})
+
+	var scopes []string
+	var scoped bool
+	handler := middleware.Handler()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		scopes, scoped = GetScopesFromContext(r.Context())
+		if HasScopes(r.Context(), "orders:read") {
+			w.Header().Set("X-Orders", "read")
+		}
+	}))
+
+	tests := []struct {
+		key        string
+		wantStatus int
+		wantScopes int
+	}{
+		{"app-key", http.StatusOK, 2},
+		{"config-key", http.StatusOK, 0},
+		{"suspended-key", http.StatusForbidden, 0},
+		{"unknown-key", http.StatusUnauthorized, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			scopes, scoped = nil, false
+			req := httptest.NewRequest("GET", "/orders", nil)
+			req.Header.Set("X-API-Key", tt.key)
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("Expected status %d, got %d", tt.wantStatus, rr.Code)
+			}
+			if tt.wantStatus == http.StatusOK && (!scoped || len(scopes) != tt.wantScopes) {
+				t.Errorf("Expected %d scopes in context, got %v", tt.wantScopes, scopes)
+			}
+			if read := rr.Header().Get("X-Orders") == "read"; read != (tt.wantScopes > 0) {
+				t.Errorf("Expected HasScopes to be %v", tt.wantScopes > 0)
+			}
+		})
+	}
+
+	if req := httptest.NewRequest("GET", "/orders", nil); HasScopes(req.Context(), "orders:read") || !HasScopes(req.Context()) {
+		t.Error("Expected unauthenticated requests to have no scopes")
+	}
+}
+
+// recordingApplications resolves API keys from a map of applications and
+// records the last uses reported to it
+type recordingApplications struct {
+	staticApplications
+	mu       sync.Mutex
+	batches  int
+	lastUsed map[string]time.Time
+}
+
+func (r *recordingApplications) RecordApplicationsLastUsed(ctx context.Context, lastUsed map[string]time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.batches++
+	for appID, usedAt := range lastUsed {
+		r.lastUsed[appID] = usedAt
+	}
+	return nil
+}
+
+func TestAPIKeyAuthenticator_RecordsApplicationLastUsed(t *testing.T) {
+	resolver := &recordingApplications{
+		staticApplications: staticApplications{
+			"app-key":       {ID: "app1", Status: portal.ApplicationStatusActive},
+			"suspended-key": {ID: "app2", Status: portal.ApplicationStatusSuspended},
+		},
+		lastUsed: make(map[string]time.Time),
+	}
+	auth := NewAPIKeyAuthenticator(&config.APIKeyConfig{Header: "X-API-Key", Keys: []string{"config-key"}})
+	auth.SetApplicationResolver(resolver)
+
+	before := time.Now()
+	for _, key := range []string{"app-key", "app-key", "suspended-key", "config-key"} {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("X-API-Key", key)
+		if _, err := auth.Authenticate(req); err != nil {
+			t.Fatalf("Authenticate failed: %v", err)
+		}
+	}
+
+	resolver.mu.Lock()
+	if resolver.batches != 0 {
+		t.Error("Expected uses not to be recorded on the request path")
+	}
+	resolver.mu.Unlock()
+
+	auth.Close()
+
+	if resolver.batches != 1 {
+		t.Errorf("Expected uses to be recorded in 1 batch on close, got %d", resolver.batches)
+	}
+	if len(resolver.lastUsed) != 1 || resolver.lastUsed["app1"].Before(before) {
+		t.Errorf("Expected only the use of app1 to be recorded, got %v", resolver.lastUsed)
+	}
+}
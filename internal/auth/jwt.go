@@ -6,23 +6,39 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"encoding/pem"
+	"errors"
 	"fmt"
 	"math/big"
 	"net/http"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/songzhibin97/stargate/internal/config"
+	"github.com/songzhibin97/stargate/pkg/metrics"
 )
 
+// errUnknownKey is returned when no verification key matches the token
+var errUnknownKey = errors.New("unknown signing key")
+
 // JWTAuthenticator handles JWT authentication
 type JWTAuthenticator struct {
 	config    *config.JWTConfig
 	publicKey interface{}
 	jwksCache *JWKSCache
 	mu        sync.RWMutex
+
+	tokenCache  *JWTTokenCache  // Verified tokens, nil when disabled
+	revocations *RevocationList // Revoked token IDs
+
+	cacheHits     atomic.Int64
+	cacheMisses   atomic.Int64
+	revokedTokens atomic.Int64
+
+	cacheRequests  metrics.CounterVec // Cache lookups by result
+	revokedCounter metrics.Counter    // Rejected revoked tokens
 }
 
 // JWKSCache caches JWKS (JSON Web Key Set) data
@@ -74,6 +90,16 @@ func NewJWTAuthenticator(config *config.JWTConfig) (*JWTAuthenticator, error) {
 	if err := auth.initializeKeys(); err != nil {
 		return nil, fmt.Errorf("failed to initialize JWT keys: %w", err)
 	}
+
+	revocations, err := NewRevocationList(&config.Revocation)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize JWT revocation list: %w", err)
+	}
+	auth.revocations = revocations
+
+	if config.Cache.Enabled {
+		auth.tokenCache = NewJWTTokenCache(&config.Cache)
+	}
 	
 	return auth, nil
 }
@@ -143,18 +169,45 @@ func (j *JWTAuthenticator) Authenticate(r *http.Request) (*AuthResult, error) {
 			Authenticated: false,
 			Error:         "JWT token not provided",
 			StatusCode:    http.StatusUnauthorized,
+			Reason:        FailureMissingCredentials,
 		}, nil
 	}
 	
-	// Parse and validate the token
-	claims, err := j.validateToken(token)
-	if err != nil {
+	// Use the cached claims of a verified token, or parse and validate it
+	claims, cached := j.tokenCache.Get(token)
+	if j.tokenCache != nil {
+		j.recordCacheLookup(cached)
+	}
+	if !cached {
+		var err error
+		claims, err = j.validateToken(token)
+		if err != nil {
+			return &AuthResult{
+				Authenticated: false,
+				Error:         fmt.Sprintf("Invalid JWT token: %v", err),
+				StatusCode:    http.StatusUnauthorized,
+				Reason:        jwtFailureReason(err),
+			}, nil
+		}
+	}
+
+	// Revoked tokens are rejected even when cached
+	if j.revocations.IsRevoked(claims.ID) {
+		j.revokedTokens.Add(1)
+		if j.revokedCounter != nil {
+			j.revokedCounter.Inc()
+		}
 		return &AuthResult{
 			Authenticated: false,
-			Error:         fmt.Sprintf("Invalid JWT token: %v", err),
+			Error:         "JWT token has been revoked",
 			StatusCode:    http.StatusUnauthorized,
+			Reason:        FailureRevoked,
 		}, nil
 	}
+
+	if !cached {
+		j.tokenCache.Set(token, claims)
+	}
 	
 	// Create user info from claims
 	userInfo := j.createUserInfoFromClaims(claims)
@@ -169,6 +222,70 @@ func (j *JWTAuthenticator) Authenticate(r *http.Request) (*AuthResult, error) {
 	}, nil
 }
 
+// recordCacheLookup counts a verified token cache lookup
+func (j *JWTAuthenticator) recordCacheLookup(hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+		j.cacheHits.Add(1)
+	} else {
+		j.cacheMisses.Add(1)
+	}
+
+	if j.cacheRequests != nil {
+		j.cacheRequests.WithLabelValues(result).Inc()
+	}
+}
+
+// SetMetrics sets the counters of verified token cache lookups, labelled by
+// result (hit or miss), and of rejected revoked tokens
+func (j *JWTAuthenticator) SetMetrics(cacheRequests metrics.CounterVec, revoked metrics.Counter) {
+	j.cacheRequests = cacheRequests
+	j.revokedCounter = revoked
+}
+
+// UpdateRevocations replaces the revoked token IDs from the configuration
+func (j *JWTAuthenticator) UpdateRevocations(cfg *config.JWTRevocationConfig) error {
+	return j.revocations.Update(cfg)
+}
+
+// PrepareRevocations loads the revoked token IDs of the configuration and
+// returns a function replacing them
+func (j *JWTAuthenticator) PrepareRevocations(cfg *config.JWTRevocationConfig) (func(), error) {
+	return j.revocations.Prepare(cfg)
+}
+
+// Revoke revokes a token ID until the authenticator is recreated
+func (j *JWTAuthenticator) Revoke(jti string) {
+	j.revocations.Revoke(jti)
+}
+
+// FlushCache removes all cached verified tokens and returns how many were removed
+func (j *JWTAuthenticator) FlushCache() int {
+	return j.tokenCache.Flush()
+}
+
+// Stats returns verified token cache and revocation statistics
+func (j *JWTAuthenticator) Stats() map[string]interface{} {
+	hits := j.cacheHits.Load()
+	misses := j.cacheMisses.Load()
+
+	hitRate := 0.0
+	if total := hits + misses; total > 0 {
+		hitRate = float64(hits) / float64(total)
+	}
+
+	return map[string]interface{}{
+		"cache_enabled":   j.tokenCache != nil,
+		"cache_entries":   j.tokenCache.Len(),
+		"cache_hits":      hits,
+		"cache_misses":    misses,
+		"cache_hit_rate":  hitRate,
+		"revoked_ids":     j.revocations.Len(),
+		"revoked_rejects": j.revokedTokens.Load(),
+	}
+}
+
 // extractToken extracts JWT token from Authorization header
 func (j *JWTAuthenticator) extractToken(r *http.Request) string {
 	authHeader := r.Header.Get("Authorization")
@@ -212,6 +329,21 @@ func (j *JWTAuthenticator) validateToken(tokenString string) (*JWTClaims, error)
 	return claims, nil
 }
 
+// jwtFailureReason classifies a token validation error
+func jwtFailureReason(err error) string {
+	switch {
+	case errors.Is(err, jwt.ErrTokenExpired):
+		return FailureExpired
+	case errors.Is(err, jwt.ErrTokenNotValidYet):
+		return FailureNotYetValid
+	case errors.Is(err, jwt.ErrTokenSignatureInvalid):
+		return FailureInvalidSignature
+	case errors.Is(err, errUnknownKey):
+		return FailureUnknownKey
+	}
+	return FailureInvalidToken
+}
+
 // getKeyFunc returns a function to get the verification key
 func (j *JWTAuthenticator) getKeyFunc() jwt.Keyfunc {
 	return func(token *jwt.Token) (interface{}, error) {
@@ -231,7 +363,7 @@ func (j *JWTAuthenticator) getKeyFunc() jwt.Keyfunc {
 		if j.jwksCache != nil {
 			kid, ok := token.Header["kid"].(string)
 			if !ok {
-				return nil, fmt.Errorf("token missing kid header")
+				return nil, fmt.Errorf("%w: token missing kid header", errUnknownKey)
 			}
 			
 			key, err := j.jwksCache.getKey(kid)
@@ -253,12 +385,12 @@ func (j *JWTAuthenticator) validateStandardClaims(claims *JWTClaims) error {
 	
 	// Validate expiration time
 	if claims.ExpiresAt != nil && claims.ExpiresAt.Before(now) {
-		return fmt.Errorf("token has expired")
+		return fmt.Errorf("%w: token has expired", jwt.ErrTokenExpired)
 	}
 	
 	// Validate not before time
 	if claims.NotBefore != nil && claims.NotBefore.After(now) {
-		return fmt.Errorf("token not valid yet")
+		return fmt.Errorf("%w: token not valid yet", jwt.ErrTokenNotValidYet)
 	}
 	
 	// Validate issued at time (with some leeway)
@@ -438,7 +570,7 @@ func (c *JWKSCache) getKey(kid string) (interface{}, error) {
 
 	key, exists := c.keys[kid]
 	if !exists {
-		return nil, fmt.Errorf("key with ID %s not found", kid)
+		return nil, fmt.Errorf("%w: key with ID %s not found", errUnknownKey, kid)
 	}
 
 	return key, nil
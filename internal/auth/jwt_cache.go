@@ -0,0 +1,307 @@
+package auth
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/songzhibin97/stargate/internal/config"
+)
+
+// JWT cache defaults
+const (
+	defaultJWTCacheEntries         = 10000
+	defaultJWTCacheTTL             = 5 * time.Minute
+	defaultRevocationRefreshPeriod = 30 * time.Second
+)
+
+// cachedJWT is a verified token cached until expiresAt
+type cachedJWT struct {
+	claims    *JWTClaims
+	expiresAt time.Time
+}
+
+// JWTTokenCache caches the claims of verified tokens keyed by the token hash,
+// so repeated requests with the same token skip signature verification
+type JWTTokenCache struct {
+	cache      map[string]*cachedJWT
+	maxEntries int
+	ttl        time.Duration
+	mu         sync.RWMutex
+}
+
+// NewJWTTokenCache creates a verified token cache from the configuration
+func NewJWTTokenCache(cfg *config.JWTCacheConfig) *JWTTokenCache {
+	maxEntries := cfg.MaxEntries
+	if maxEntries <= 0 {
+		maxEntries = defaultJWTCacheEntries
+	}
+	ttl := cfg.TTL
+	if ttl <= 0 {
+		ttl = defaultJWTCacheTTL
+	}
+
+	return &JWTTokenCache{
+		cache:      make(map[string]*cachedJWT),
+		maxEntries: maxEntries,
+		ttl:        ttl,
+	}
+}
+
+// Get returns the claims of a cached token, if not expired. A nil cache
+// never has a token.
+func (c *JWTTokenCache) Get(token string) (*JWTClaims, bool) {
+	if c == nil {
+		return nil, false
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	cached, exists := c.cache[tokenCacheKey(token)]
+	if !exists || time.Now().After(cached.expiresAt) {
+		return nil, false
+	}
+	return cached.claims, true
+}
+
+// Set caches the claims of a verified token for the cache TTL, capped by the
+// token's expiry. When the cache is full expired tokens are removed first,
+// and the token is not cached if there is still no room.
+func (c *JWTTokenCache) Set(token string, claims *JWTClaims) {
+	if c == nil {
+		return
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(c.ttl)
+	if claims.ExpiresAt != nil && claims.ExpiresAt.Time.Before(expiresAt) {
+		expiresAt = claims.ExpiresAt.Time
+	}
+	if !expiresAt.After(now) {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.cache) >= c.maxEntries {
+		for key, cached := range c.cache {
+			if now.After(cached.expiresAt) {
+				delete(c.cache, key)
+			}
+		}
+		if len(c.cache) >= c.maxEntries {
+			return
+		}
+	}
+
+	c.cache[tokenCacheKey(token)] = &cachedJWT{
+		claims:    claims,
+		expiresAt: expiresAt,
+	}
+}
+
+// Flush removes all cached tokens and returns how many were removed
+func (c *JWTTokenCache) Flush() int {
+	if c == nil {
+		return 0
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	flushed := len(c.cache)
+	c.cache = make(map[string]*cachedJWT)
+	return flushed
+}
+
+// Len returns the number of cached tokens
+func (c *JWTTokenCache) Len() int {
+	if c == nil {
+		return 0
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return len(c.cache)
+}
+
+// RevocationList is the denylist of revoked token IDs (jti). IDs come from
+// the configuration, from a file that is reloaded when it changes, and from
+// Revoke calls.
+type RevocationList struct {
+	configured map[string]bool // from the configuration
+	fromFile   map[string]bool // from the revocation file
+	revoked    map[string]bool // revoked at runtime
+
+	file            string
+	fileModTime     time.Time
+	refreshInterval time.Duration
+	lastCheck       time.Time
+
+	mu sync.RWMutex
+}
+
+// NewRevocationList creates a revocation list from the configuration,
+// loading the revocation file if one is configured
+func NewRevocationList(cfg *config.JWTRevocationConfig) (*RevocationList, error) {
+	l := &RevocationList{revoked: make(map[string]bool)}
+	if err := l.Update(cfg); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Update replaces the configured IDs and revocation file, keeping IDs
+// revoked at runtime
+func (l *RevocationList) Update(cfg *config.JWTRevocationConfig) error {
+	apply, err := l.Prepare(cfg)
+	if err != nil {
+		return err
+	}
+	apply()
+	return nil
+}
+
+// Prepare loads the revocation file of the configuration and returns a
+// function applying it like Update
+func (l *RevocationList) Prepare(cfg *config.JWTRevocationConfig) (func(), error) {
+	configured := make(map[string]bool, len(cfg.JTIs))
+	for _, jti := range cfg.JTIs {
+		configured[jti] = true
+	}
+
+	refreshInterval := cfg.RefreshInterval
+	if refreshInterval <= 0 {
+		refreshInterval = defaultRevocationRefreshPeriod
+	}
+
+	var fromFile map[string]bool
+	var modTime time.Time
+	if cfg.File != "" {
+		var err error
+		fromFile, modTime, err = readRevocationFile(cfg.File)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+
+		l.configured = configured
+		l.fromFile = fromFile
+		l.file = cfg.File
+		l.fileModTime = modTime
+		l.refreshInterval = refreshInterval
+		l.lastCheck = time.Now()
+	}, nil
+}
+
+// IsRevoked reports whether the token ID is revoked. Tokens without an ID
+// can't be revoked. The revocation file is reloaded first if it changed
+// since the last check, at most once per refresh interval.
+func (l *RevocationList) IsRevoked(jti string) bool {
+	if l == nil || jti == "" {
+		return false
+	}
+
+	l.refresh()
+
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.configured[jti] || l.fromFile[jti] || l.revoked[jti]
+}
+
+// Revoke adds a token ID to the list
+func (l *RevocationList) Revoke(jti string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.revoked[jti] = true
+}
+
+// Len returns the number of revoked token IDs
+func (l *RevocationList) Len() int {
+	if l == nil {
+		return 0
+	}
+
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	ids := make(map[string]bool, len(l.configured)+len(l.fromFile)+len(l.revoked))
+	for _, set := range []map[string]bool{l.configured, l.fromFile, l.revoked} {
+		for jti := range set {
+			ids[jti] = true
+		}
+	}
+	return len(ids)
+}
+
+// refresh reloads the revocation file if it was modified. If the file can't
+// be read the previously loaded IDs stay revoked.
+func (l *RevocationList) refresh() {
+	// Checks aren't due on most requests, which share the read lock
+	l.mu.RLock()
+	due := l.file != "" && time.Since(l.lastCheck) >= l.refreshInterval
+	l.mu.RUnlock()
+	if !due {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// Another request may have checked while the lock was released
+	if l.file == "" || time.Since(l.lastCheck) < l.refreshInterval {
+		return
+	}
+	l.lastCheck = time.Now()
+
+	info, err := os.Stat(l.file)
+	if err != nil || info.ModTime().Equal(l.fileModTime) {
+		return
+	}
+
+	fromFile, modTime, err := readRevocationFile(l.file)
+	if err != nil {
+		return
+	}
+	l.fromFile = fromFile
+	l.fileModTime = modTime
+}
+
+// readRevocationFile reads token IDs, one per line. Blank lines and lines
+// starting with # are ignored.
+func readRevocationFile(path string) (map[string]bool, time.Time, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("failed to open revocation file: %w", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("failed to stat revocation file: %w", err)
+	}
+
+	ids := make(map[string]bool)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		ids[line] = true
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, time.Time{}, fmt.Errorf("failed to read revocation file: %w", err)
+	}
+
+	return ids, info.ModTime(), nil
+}
@@ -0,0 +1,180 @@
+package auth
+
+import (
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/songzhibin97/stargate/internal/config"
+)
+
+func newCachedJWTAuthenticator(t *testing.T) (*JWTAuthenticator, func(claims jwt.MapClaims) string) {
+	auth, err := NewJWTAuthenticator(&config.JWTConfig{
+		Secret:    "test-secret-key",
+		Algorithm: "HS256",
+		Cache:     config.JWTCacheConfig{Enabled: true},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create JWT authenticator: %v", err)
+	}
+
+	sign := func(claims jwt.MapClaims) string {
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("test-secret-key"))
+		if err != nil {
+			t.Fatalf("Failed to sign token: %v", err)
+		}
+		return token
+	}
+	return auth, sign
+}
+
+func authenticateToken(t *testing.T, auth *JWTAuthenticator, token string) *AuthResult {
+	req := httptest.NewRequest("GET", "/test", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	result, err := auth.Authenticate(req)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return result
+}
+
+func TestJWTAuthenticator_CacheSkipsVerification(t *testing.T) {
+	auth, sign := newCachedJWTAuthenticator(t)
+	token := sign(jwt.MapClaims{"sub": "user123", "jti": "token-1", "exp": time.Now().Add(time.Hour).Unix()})
+
+	if result := authenticateToken(t, auth, token); !result.Authenticated {
+		t.Fatalf("Expected token to be authenticated, got %+v", result)
+	}
+
+	// A cached token is not verified again
+	auth.publicKey = []byte("rotated-secret")
+	result := authenticateToken(t, auth, token)
+	if !result.Authenticated || result.UserInfo.ID != "user123" {
+		t.Fatalf("Expected cached token to be authenticated, got %+v", result)
+	}
+
+	stats := auth.Stats()
+	if stats["cache_hits"] != int64(1) || stats["cache_misses"] != int64(1) || stats["cache_hit_rate"] != 0.5 {
+		t.Errorf("Expected one hit and one miss, got %v", stats)
+	}
+
+	if flushed := auth.FlushCache(); flushed != 1 {
+		t.Errorf("Expected 1 cached token flushed, got %d", flushed)
+	}
+	if result := authenticateToken(t, auth, token); result.Authenticated {
+		t.Error("Expected flushed token to be verified again")
+	}
+}
+
+func TestJWTAuthenticator_RevokedTokenRejected(t *testing.T) {
+	auth, sign := newCachedJWTAuthenticator(t)
+	token := sign(jwt.MapClaims{"sub": "user123", "jti": "token-1", "exp": time.Now().Add(time.Hour).Unix()})
+
+	if result := authenticateToken(t, auth, token); !result.Authenticated {
+		t.Fatalf("Expected token to be authenticated, got %+v", result)
+	}
+
+	// Revocation applies to cached tokens
+	auth.Revoke("token-1")
+	result := authenticateToken(t, auth, token)
+	if result.Authenticated || result.Reason != FailureRevoked {
+		t.Errorf("Expected revoked token to be rejected, got %+v", result)
+	}
+
+	// Configured revocations replace the previous ones on update
+	if err := auth.UpdateRevocations(&config.JWTRevocationConfig{JTIs: []string{"token-2"}}); err != nil {
+		t.Fatalf("Failed to update revocations: %v", err)
+	}
+	other := sign(jwt.MapClaims{"sub": "user456", "jti": "token-2", "exp": time.Now().Add(time.Hour).Unix()})
+	if result := authenticateToken(t, auth, other); result.Authenticated || result.Reason != FailureRevoked {
+		t.Errorf("Expected configured revoked token to be rejected, got %+v", result)
+	}
+
+	if stats := auth.Stats(); stats["revoked_rejects"] != int64(2) || stats["revoked_ids"] != 2 {
+		t.Errorf("Expected 2 revoked rejects of 2 revoked IDs, got %v", stats)
+	}
+}
+
+func TestJWTTokenCache_TTLCappedByExpiry(t *testing.T) {
+	cache := NewJWTTokenCache(&config.JWTCacheConfig{TTL: time.Hour})
+
+	claims := &JWTClaims{}
+	claims.ExpiresAt = &jwt.NumericDate{Time: time.Now().Add(50 * time.Millisecond)}
+	cache.Set("token", claims)
+
+	if _, ok := cache.Get("token"); !ok {
+		t.Fatal("Expected token to be cached")
+	}
+	time.Sleep(100 * time.Millisecond)
+	if _, ok := cache.Get("token"); ok {
+		t.Error("Expected cached token to expire with the token")
+	}
+
+	// Expired tokens are not cached
+	claims.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Minute))
+	cache.Set("expired", claims)
+	if cache.Len() != 1 {
+		t.Errorf("Expected expired token not to be cached, got %d entries", cache.Len())
+	}
+}
+
+func TestRevocationList_FileReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "revoked.txt")
+	if err := os.WriteFile(path, []byte("# revoked tokens\ntoken-1\n\n"), 0644); err != nil {
+		t.Fatalf("Failed to write revocation file: %v", err)
+	}
+
+	list, err := NewRevocationList(&config.JWTRevocationConfig{File: path, RefreshInterval: time.Nanosecond})
+	if err != nil {
+		t.Fatalf("Failed to create revocation list: %v", err)
+	}
+	if !list.IsRevoked("token-1") || list.IsRevoked("token-2") {
+		t.Fatal("Expected only token-1 to be revoked")
+	}
+
+	if err := os.WriteFile(path, []byte("token-2\n"), 0644); err != nil {
+		t.Fatalf("Failed to write revocation file: %v", err)
+	}
+	modTime := time.Now().Add(time.Minute)
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatalf("Failed to update revocation file time: %v", err)
+	}
+
+	if list.IsRevoked("token-1") || !list.IsRevoked("token-2") {
+		t.Error("Expected revocation file to be reloaded")
+	}
+
+	if _, err := NewRevocationList(&config.JWTRevocationConfig{File: filepath.Join(t.TempDir(), "missing")}); err == nil {
+		t.Error("Expected error for a missing revocation file")
+	}
+}
+
+func TestRevocationList_CheckSharesReadLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "revoked.txt")
+	if err := os.WriteFile(path, []byte("token-1\n"), 0644); err != nil {
+		t.Fatalf("Failed to write revocation file: %v", err)
+	}
+	list, err := NewRevocationList(&config.JWTRevocationConfig{File: path, RefreshInterval: time.Hour})
+	if err != nil {
+		t.Fatalf("Failed to create revocation list: %v", err)
+	}
+
+	// Checks before the file is due for a reload don't take the write lock,
+	// so they proceed while another reader holds the list
+	list.mu.RLock()
+	defer list.mu.RUnlock()
+	done := make(chan bool)
+	go func() { done <- list.IsRevoked("token-1") }()
+	select {
+	case revoked := <-done:
+		if !revoked {
+			t.Error("Expected token-1 to be revoked")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected the check not to wait for the write lock")
+	}
+}
@@ -203,6 +203,55 @@ func TestJWTAuthenticator_Authenticate(t *testing.T) {
 	}
 }
 
+func TestJWTAuthenticator_FailureReason(t *testing.T) {
+	auth, err := NewJWTAuthenticator(&config.JWTConfig{Secret: "test-secret-key", Algorithm: "HS256"})
+	if err != nil {
+		t.Fatalf("Failed to create JWT authenticator: %v", err)
+	}
+
+	sign := func(secret string, claims jwt.MapClaims) string {
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+		if err != nil {
+			t.Fatalf("Failed to sign token: %v", err)
+		}
+		return token
+	}
+
+	tests := []struct {
+		name     string
+		token    string
+		expected string
+	}{
+		{"expired", sign("test-secret-key", jwt.MapClaims{"sub": "user123", "exp": time.Now().Add(-time.Hour).Unix()}), FailureExpired},
+		{"not yet valid", sign("test-secret-key", jwt.MapClaims{"sub": "user123", "nbf": time.Now().Add(time.Hour).Unix()}), FailureNotYetValid},
+		{"wrong secret", sign("other-secret", jwt.MapClaims{"sub": "user123"}), FailureInvalidSignature},
+		{"malformed", "not-a-token", FailureInvalidToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/test", nil)
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+
+			result, err := auth.Authenticate(req)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if result.Authenticated || result.Reason != tt.expected {
+				t.Errorf("Expected failure reason %s, got %+v", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestJWTFailureReason_UnknownKey(t *testing.T) {
+	cache := &JWKSCache{keys: map[string]interface{}{}, lastFetch: time.Now(), ttl: time.Hour}
+	_, err := cache.getKey("rotated")
+	if reason := jwtFailureReason(err); reason != FailureUnknownKey {
+		t.Errorf("Expected %s for a missing key ID, got %s", FailureUnknownKey, reason)
+	}
+}
+
 func TestJWTAuthenticator_GetName(t *testing.T) {
 	cfg := &config.JWTConfig{
 		Secret: "test-secret",
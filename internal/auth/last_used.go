@@ -0,0 +1,132 @@
+package auth
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// Last used tracking defaults
+const (
+	lastUsedFlushInterval = 10 * time.Second
+	lastUsedResolution    = time.Minute // Uses closer than this to the last recorded one are dropped
+	lastUsedFlushTimeout  = 5 * time.Second
+)
+
+// LastUsedRecorder records when portal applications were last used.
+// portal.ApplicationRepository implementations satisfy it.
+type LastUsedRecorder interface {
+	RecordApplicationsLastUsed(ctx context.Context, lastUsed map[string]time.Time) error
+}
+
+// lastUsedTracker batches the uses of portal applications and records them
+// in the background, keeping the store off the request path
+type lastUsedTracker struct {
+	recorder LastUsedRecorder
+	interval time.Duration
+
+	mu       sync.Mutex
+	pending  map[string]time.Time // Uses not recorded yet, by application ID
+	recorded map[string]time.Time // Last use recorded, by application ID
+
+	stopCh chan struct{}
+	wg     sync.WaitGroup
+}
+
+// newLastUsedTracker creates a tracker flushing uses to the recorder every
+// interval until closed
+func newLastUsedTracker(recorder LastUsedRecorder, interval time.Duration) *lastUsedTracker {
+	t := &lastUsedTracker{
+		recorder: recorder,
+		interval: interval,
+		pending:  make(map[string]time.Time),
+		recorded: make(map[string]time.Time),
+		stopCh:   make(chan struct{}),
+	}
+
+	t.wg.Add(1)
+	go t.run()
+
+	return t
+}
+
+// touch notes a use of the application, dropping it when a use within
+// lastUsedResolution is already noted
+func (t *lastUsedTracker) touch(appID string, usedAt time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if last, ok := t.pending[appID]; ok && usedAt.Sub(last) < lastUsedResolution {
+		return
+	}
+	if last, ok := t.recorded[appID]; ok && usedAt.Sub(last) < lastUsedResolution {
+		return
+	}
+	t.pending[appID] = usedAt
+}
+
+// run flushes pending uses every interval until the tracker is closed
+func (t *lastUsedTracker) run() {
+	defer t.wg.Done()
+
+	ticker := time.NewTicker(t.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			t.flush()
+		case <-t.stopCh:
+			t.flush()
+			return
+		}
+	}
+}
+
+// flush records the pending uses, keeping them for the next flush on failure
+func (t *lastUsedTracker) flush() {
+	t.mu.Lock()
+	if len(t.pending) == 0 {
+		t.mu.Unlock()
+		return
+	}
+	batch := t.pending
+	t.pending = make(map[string]time.Time)
+	t.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), lastUsedFlushTimeout)
+	defer cancel()
+
+	err := t.recorder.RecordApplicationsLastUsed(ctx, batch)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if err != nil {
+		log.Printf("Failed to record last use of %d applications: %v", len(batch), err)
+		for appID, usedAt := range batch {
+			if newer, ok := t.pending[appID]; !ok || newer.Before(usedAt) {
+				t.pending[appID] = usedAt
+			}
+		}
+		return
+	}
+
+	for appID, usedAt := range batch {
+		t.recorded[appID] = usedAt
+	}
+	// Forget uses older than the resolution so the map doesn't grow unbounded
+	cutoff := time.Now().Add(-lastUsedResolution)
+	for appID, usedAt := range t.recorded {
+		if usedAt.Before(cutoff) {
+			delete(t.recorded, appID)
+		}
+	}
+}
+
+// close stops the tracker after recording the pending uses
+func (t *lastUsedTracker) close() {
+	close(t.stopCh)
+	t.wg.Wait()
+}
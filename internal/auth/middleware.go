@@ -1,6 +1,7 @@
 package auth
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
@@ -10,6 +11,8 @@ import (
 	"time"
 
 	"github.com/songzhibin97/stargate/internal/config"
+	"github.com/songzhibin97/stargate/internal/decision"
+	"github.com/songzhibin97/stargate/pkg/metrics"
 )
 
 // Middleware represents the authentication middleware
@@ -17,6 +20,12 @@ type Middleware struct {
 	config        *config.AuthConfig
 	authenticators map[AuthenticationMethod]Authenticator
 	mu            sync.RWMutex
+
+	// shadow evaluates the shadow auth configuration, if enabled
+	shadow *Shadow
+
+	// routeMatcher returns the ID of the route matching a request
+	routeMatcher func(r *http.Request) string
 }
 
 // NewMiddleware creates a new authentication middleware
@@ -29,6 +38,10 @@ func NewMiddleware(config *config.AuthConfig) *Middleware {
 	// Initialize authenticators based on configuration
 	m.initializeAuthenticators()
 	
+	if config.Shadow.Enabled {
+		m.shadow = NewShadow(&config.Shadow)
+	}
+	
 	return m
 }
 
@@ -67,14 +80,32 @@ func (m *Middleware) Handler() func(http.Handler) http.Handler {
 				return
 			}
 			
+			// Copy the request for the shadow configuration before
+			// upstream headers are added
+			var shadowReq *http.Request
+			var cancelShadow context.CancelFunc
+			routeID := ""
+			if m.shadow != nil {
+				if m.routeMatcher != nil {
+					routeID = m.routeMatcher(r)
+				}
+				if m.shadow.appliesTo(routeID) {
+					shadowReq, cancelShadow = m.shadow.request(r)
+				}
+			}
+			
 			// Try to authenticate the request
 			authResult, err := m.authenticate(r)
+			if shadowReq != nil {
+				m.shadow.Evaluate(shadowReq, cancelShadow, routeID, err == nil && authResult.Authenticated)
+			}
 			if err != nil {
 				log.Printf("Authentication error: %v", err)
 				m.handleAuthError(w, r, &AuthResult{
 					Authenticated: false,
 					Error:         "Internal authentication error",
 					StatusCode:    http.StatusInternalServerError,
+					Reason:        FailureInternalError,
 				})
 				return
 			}
@@ -92,6 +123,7 @@ func (m *Middleware) Handler() func(http.Handler) http.Handler {
 			}
 			if authResult.Consumer != nil {
 				ctx = SetConsumerInContext(ctx, authResult.Consumer)
+				ctx = SetScopesInContext(ctx, authResult.Consumer.Scopes)
 			}
 			if authResult.Claims != nil {
 				ctx = SetClaimsInContext(ctx, authResult.Claims)
@@ -148,7 +180,12 @@ func (m *Middleware) authenticate(r *http.Request) (*AuthResult, error) {
 		if result.Authenticated {
 			return result, nil
 		}
-		
+
+		// A revoked token is rejected without trying other authenticators
+		if result.Reason == FailureRevoked {
+			return result, nil
+		}
+
 		// Keep track of the last result for error handling
 		lastResult = result
 	}
@@ -167,6 +204,7 @@ func (m *Middleware) authenticate(r *http.Request) (*AuthResult, error) {
 		Authenticated: false,
 		Error:         "No valid credentials provided",
 		StatusCode:    http.StatusUnauthorized,
+		Reason:        FailureMissingCredentials,
 	}, nil
 }
 
@@ -258,6 +296,13 @@ func (m *Middleware) handleAuthError(w http.ResponseWriter, r *http.Request, res
 	
 	// Log authentication failure
 	log.Printf("Authentication failed for %s %s: %s", r.Method, r.URL.Path, result.Error)
+	
+	decision.Record(r.Context(), decision.Decision{
+		Middleware: "auth",
+		Outcome:    decision.OutcomeAuthFailed,
+		Reason:     result.Reason,
+		Fields:     map[string]string{"method": m.getAuthMethod(r)},
+	})
 }
 
 // setWWWAuthenticateHeader sets the WWW-Authenticate header
@@ -327,6 +372,112 @@ func (m *Middleware) RemoveAuthenticator(method AuthenticationMethod) {
 	delete(m.authenticators, method)
 }
 
+// SetApplicationResolver makes API key authentication accept the API keys of
+// portal applications, granting the applications' scopes
+func (m *Middleware) SetApplicationResolver(resolver ApplicationResolver) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if apiKeyAuth, ok := m.authenticators[AuthMethodAPIKey].(*APIKeyAuthenticator); ok {
+		apiKeyAuth.SetApplicationResolver(resolver)
+	}
+}
+
+// SetRouteMatcher sets the function returning the ID of the route matching a
+// request, selecting the routes evaluated by the shadow configuration
+func (m *Middleware) SetRouteMatcher(matcher func(r *http.Request) string) {
+	m.routeMatcher = matcher
+}
+
+// SetShadowMetrics sets the counter of the shadow configuration's decisions
+func (m *Middleware) SetShadowMetrics(decisions metrics.CounterVec) {
+	if m.shadow != nil {
+		m.shadow.SetMetrics(decisions)
+	}
+}
+
+// Close releases the background resources of the authenticators, recording
+// pending portal application uses
+func (m *Middleware) Close() {
+	if m.shadow != nil {
+		m.shadow.Close()
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if apiKeyAuth, ok := m.authenticators[AuthMethodAPIKey].(*APIKeyAuthenticator); ok {
+		apiKeyAuth.Close()
+	}
+}
+
+// FlushIntrospectionCache removes all cached OAuth 2.0 introspection results
+// and returns how many were removed
+func (m *Middleware) FlushIntrospectionCache() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if oauth2Auth, ok := m.authenticators[AuthMethodOAuth2].(*OAuth2Authenticator); ok {
+		return oauth2Auth.FlushCache()
+	}
+	return 0
+}
+
+// jwtAuthenticator returns the JWT authenticator, if configured
+func (m *Middleware) jwtAuthenticator() (*JWTAuthenticator, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	jwtAuth, ok := m.authenticators[AuthMethodJWT].(*JWTAuthenticator)
+	return jwtAuth, ok
+}
+
+// SetJWTMetrics sets the counters of the JWT authenticator's verified token
+// cache lookups and rejected revoked tokens
+func (m *Middleware) SetJWTMetrics(cacheRequests metrics.CounterVec, revoked metrics.Counter) {
+	if jwtAuth, ok := m.jwtAuthenticator(); ok {
+		jwtAuth.SetMetrics(cacheRequests, revoked)
+	}
+}
+
+// SetOAuth2Metrics sets the counter and latency histogram of the OAuth 2.0
+// authenticator's introspection requests and the counter of its failed
+// introspections
+func (m *Middleware) SetOAuth2Metrics(attempts metrics.CounterVec, duration metrics.HistogramVec, errors metrics.CounterVec) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if oauth2Auth, ok := m.authenticators[AuthMethodOAuth2].(*OAuth2Authenticator); ok {
+		oauth2Auth.SetMetrics(attempts, duration, errors)
+	}
+}
+
+// UpdateJWTRevocations replaces the JWT authenticator's revoked token IDs
+func (m *Middleware) UpdateJWTRevocations(cfg *config.JWTRevocationConfig) error {
+	if jwtAuth, ok := m.jwtAuthenticator(); ok {
+		return jwtAuth.UpdateRevocations(cfg)
+	}
+	return nil
+}
+
+// PrepareJWTRevocations loads the JWT authenticator's revoked token IDs from
+// the configuration and returns a function replacing them
+func (m *Middleware) PrepareJWTRevocations(cfg *config.JWTRevocationConfig) (func(), error) {
+	if jwtAuth, ok := m.jwtAuthenticator(); ok {
+		return jwtAuth.PrepareRevocations(cfg)
+	}
+	return func() {}, nil
+}
+
+// JWTStats returns the JWT authenticator's cache and revocation statistics,
+// or nil if JWT authentication is not configured
+func (m *Middleware) JWTStats() map[string]interface{} {
+	if jwtAuth, ok := m.jwtAuthenticator(); ok {
+		return jwtAuth.Stats()
+	}
+	return nil
+}
+
 // GetAuthenticator gets an authenticator by method
 func (m *Middleware) GetAuthenticator(method AuthenticationMethod) (Authenticator, bool) {
 	m.mu.RLock()
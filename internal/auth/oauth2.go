@@ -1,10 +1,14 @@
 package auth
 
 import (
-	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
+	"math/rand"
 	"net/http"
 	"net/url"
 	"strings"
@@ -12,13 +16,22 @@ import (
 	"time"
 
 	"github.com/songzhibin97/stargate/internal/config"
+	"github.com/songzhibin97/stargate/pkg/metrics"
 )
 
 // OAuth2Authenticator handles OAuth 2.0 token introspection authentication
 type OAuth2Authenticator struct {
 	config     *config.OAuth2Config
 	httpClient *http.Client
+	cache      *OAuth2TokenCache
 	mu         sync.RWMutex
+
+	// random returns the jitter of retry delays in [0, 1)
+	random func() float64
+
+	attemptCounter  metrics.CounterVec   // Introspection requests by result
+	attemptDuration metrics.HistogramVec // Introspection request latency by result
+	errorCounter    metrics.CounterVec   // Failed introspections by reason
 }
 
 // IntrospectionResponse represents the response from OAuth 2.0 introspection endpoint
@@ -54,12 +67,82 @@ type TokenCache struct {
 	expiresAt time.Time
 }
 
+// maxTokenCacheEntries bounds the number of cached introspection results
+const maxTokenCacheEntries = 10000
+
 // OAuth2TokenCache caches introspection results
 type OAuth2TokenCache struct {
 	cache map[string]*TokenCache
 	mu    sync.RWMutex
 }
 
+// NewOAuth2TokenCache creates an empty introspection cache
+func NewOAuth2TokenCache() *OAuth2TokenCache {
+	return &OAuth2TokenCache{
+		cache: make(map[string]*TokenCache),
+	}
+}
+
+// tokenCacheKey hashes a token so raw tokens are not kept in memory
+func tokenCacheKey(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Get returns the cached introspection result of a token, if not expired
+func (c *OAuth2TokenCache) Get(token string) (*IntrospectionResponse, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	cached, exists := c.cache[tokenCacheKey(token)]
+	if !exists || time.Now().After(cached.expiresAt) {
+		return nil, false
+	}
+	return cached.response, true
+}
+
+// Set caches the introspection result of a token until expiresAt. When the
+// cache is full expired results are removed first, and the result is not
+// cached if there is still no room.
+func (c *OAuth2TokenCache) Set(token string, response *IntrospectionResponse, expiresAt time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.cache) >= maxTokenCacheEntries {
+		now := time.Now()
+		for key, cached := range c.cache {
+			if now.After(cached.expiresAt) {
+				delete(c.cache, key)
+			}
+		}
+		if len(c.cache) >= maxTokenCacheEntries {
+			return
+		}
+	}
+
+	c.cache[tokenCacheKey(token)] = &TokenCache{
+		response:  response,
+		expiresAt: expiresAt,
+	}
+}
+
+// Flush removes all cached results and returns how many were removed
+func (c *OAuth2TokenCache) Flush() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	flushed := len(c.cache)
+	c.cache = make(map[string]*TokenCache)
+	return flushed
+}
+
+// Len returns the number of cached results
+func (c *OAuth2TokenCache) Len() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return len(c.cache)
+}
+
 // NewOAuth2Authenticator creates a new OAuth 2.0 authenticator
 func NewOAuth2Authenticator(config *config.OAuth2Config) *OAuth2Authenticator {
 	// Set defaults
@@ -75,15 +158,38 @@ func NewOAuth2Authenticator(config *config.OAuth2Config) *OAuth2Authenticator {
 	if config.CacheTTL == 0 {
 		config.CacheTTL = 5 * time.Minute
 	}
+	if config.RetryMultiplier == 0 {
+		config.RetryMultiplier = 2
+	}
+	if config.MaxRetryDelay == 0 {
+		config.MaxRetryDelay = 10 * time.Second
+	}
+	if config.RetryJitter == 0 {
+		config.RetryJitter = 0.5
+	}
+	if config.MaxElapsedTime == 0 {
+		config.MaxElapsedTime = config.Timeout
+	}
 
 	return &OAuth2Authenticator{
 		config: config,
 		httpClient: &http.Client{
 			Timeout: config.Timeout,
 		},
+		cache:  NewOAuth2TokenCache(),
+		random: rand.Float64,
 	}
 }
 
+// SetMetrics sets the counter and latency histogram of introspection
+// requests, labelled by result (success, server_error or error), and the
+// counter of failed introspections, labelled by reason
+func (o *OAuth2Authenticator) SetMetrics(attempts metrics.CounterVec, duration metrics.HistogramVec, errors metrics.CounterVec) {
+	o.attemptCounter = attempts
+	o.attemptDuration = duration
+	o.errorCounter = errors
+}
+
 // Authenticate authenticates a request using OAuth 2.0 token introspection
 func (o *OAuth2Authenticator) Authenticate(r *http.Request) (*AuthResult, error) {
 	// Extract Bearer token from Authorization header
@@ -93,16 +199,18 @@ func (o *OAuth2Authenticator) Authenticate(r *http.Request) (*AuthResult, error)
 			Authenticated: false,
 			Error:         "OAuth 2.0 token not provided",
 			StatusCode:    http.StatusUnauthorized,
+			Reason:        FailureMissingCredentials,
 		}, nil
 	}
 	
 	// Introspect the token
-	introspectionResp, err := o.introspectToken(token)
+	introspectionResp, err := o.introspectToken(r.Context(), token)
 	if err != nil {
 		return &AuthResult{
 			Authenticated: false,
 			Error:         fmt.Sprintf("Token introspection failed: %v", err),
 			StatusCode:    http.StatusInternalServerError,
+			Reason:        FailureIntrospectionFailed,
 		}, nil
 	}
 	
@@ -112,6 +220,7 @@ func (o *OAuth2Authenticator) Authenticate(r *http.Request) (*AuthResult, error)
 			Authenticated: false,
 			Error:         "Token is not active",
 			StatusCode:    http.StatusUnauthorized,
+			Reason:        FailureInactiveToken,
 		}, nil
 	}
 	
@@ -121,6 +230,7 @@ func (o *OAuth2Authenticator) Authenticate(r *http.Request) (*AuthResult, error)
 			Authenticated: false,
 			Error:         "Token has expired",
 			StatusCode:    http.StatusUnauthorized,
+			Reason:        FailureExpired,
 		}, nil
 	}
 	
@@ -130,6 +240,7 @@ func (o *OAuth2Authenticator) Authenticate(r *http.Request) (*AuthResult, error)
 			Authenticated: false,
 			Error:         "Token not valid yet",
 			StatusCode:    http.StatusUnauthorized,
+			Reason:        FailureNotYetValid,
 		}, nil
 	}
 	
@@ -162,8 +273,22 @@ func (o *OAuth2Authenticator) extractToken(r *http.Request) string {
 	return parts[1]
 }
 
-// introspectToken performs token introspection using RFC 7662
-func (o *OAuth2Authenticator) introspectToken(token string) (*IntrospectionResponse, error) {
+// Reasons of failed introspections
+const (
+	introspectionErrorDeadline = "deadline" // The request deadline or MaxElapsedTime ran out
+	introspectionErrorRequest  = "request"  // The endpoint couldn't be reached
+	introspectionErrorStatus   = "status"   // The endpoint answered with an error status
+	introspectionErrorDecode   = "decode"   // The response wasn't valid JSON
+)
+
+// introspectToken performs token introspection using RFC 7662. Failed
+// requests are retried with backoff until MaxRetries, MaxElapsedTime or the
+// deadline of ctx, the client's request, runs out.
+func (o *OAuth2Authenticator) introspectToken(ctx context.Context, token string) (*IntrospectionResponse, error) {
+	if cached, ok := o.cache.Get(token); ok {
+		return cached, nil
+	}
+
 	// Prepare introspection request
 	data := url.Values{}
 	data.Set("token", token)
@@ -172,70 +297,157 @@ func (o *OAuth2Authenticator) introspectToken(token string) (*IntrospectionRespo
 	if o.config.TokenTypeHint != "" {
 		data.Set("token_type_hint", o.config.TokenTypeHint)
 	}
-	
-	// Create HTTP request
-	req, err := http.NewRequest("POST", o.config.IntrospectionURL, bytes.NewBufferString(data.Encode()))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create introspection request: %w", err)
-	}
-	
-	// Set headers
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-	req.Header.Set("Accept", "application/json")
-	
-	// Add custom headers
-	for key, value := range o.config.Headers {
-		req.Header.Set(key, value)
-	}
-	
-	// Set client authentication
-	if o.config.ClientID != "" && o.config.ClientSecret != "" {
-		req.SetBasicAuth(o.config.ClientID, o.config.ClientSecret)
-	}
-	
-	// Add timeout context
-	ctx, cancel := context.WithTimeout(context.Background(), o.config.Timeout)
+	body := data.Encode()
+
+	ctx, cancel := context.WithTimeout(ctx, o.config.MaxElapsedTime)
 	defer cancel()
-	req = req.WithContext(ctx)
-	
+
 	// Perform request with retries
 	var resp *http.Response
 	var lastErr error
-	
+	var lastStatus int
+	attempts := 0
+	outOfTime := false
+
 	for attempt := 0; attempt <= o.config.MaxRetries; attempt++ {
-		resp, lastErr = o.httpClient.Do(req)
+		if attempt > 0 {
+			// Give up rather than retry past the deadline
+			delay := o.retryDelay(attempt)
+			if deadline, ok := ctx.Deadline(); ok && time.Now().Add(delay).After(deadline) {
+				outOfTime = true
+				break
+			}
+			timer := time.NewTimer(delay)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+			}
+			if ctx.Err() != nil {
+				outOfTime = true
+				break
+			}
+		}
+
+		attempts++
+		resp, lastErr = o.doIntrospection(ctx, body)
 		if lastErr == nil && resp.StatusCode < 500 {
 			break // Success or client error (don't retry)
 		}
-		
-		if resp != nil {
+		if lastErr == nil {
 			resp.Body.Close()
+			lastStatus = resp.StatusCode
+			lastErr = fmt.Errorf("introspection endpoint returned status %d", resp.StatusCode)
 		}
-		
-		if attempt < o.config.MaxRetries {
-			time.Sleep(o.config.RetryDelay * time.Duration(attempt+1))
-		}
+		resp = nil
 	}
-	
-	if lastErr != nil {
-		return nil, fmt.Errorf("introspection request failed after %d attempts: %w", o.config.MaxRetries+1, lastErr)
+
+	if resp == nil {
+		reason := introspectionErrorRequest
+		switch {
+		case outOfTime || errors.Is(lastErr, context.DeadlineExceeded):
+			reason = introspectionErrorDeadline
+		case lastStatus != 0:
+			reason = introspectionErrorStatus
+		}
+		o.recordError(reason)
+		return nil, fmt.Errorf("introspection request failed after %d attempts: %w", attempts, lastErr)
 	}
 	defer resp.Body.Close()
 	
 	// Check response status
 	if resp.StatusCode != http.StatusOK {
+		o.recordError(introspectionErrorStatus)
 		return nil, fmt.Errorf("introspection endpoint returned status %d", resp.StatusCode)
 	}
 	
 	// Parse response
 	var introspectionResp IntrospectionResponse
 	if err := json.NewDecoder(resp.Body).Decode(&introspectionResp); err != nil {
+		o.recordError(introspectionErrorDecode)
 		return nil, fmt.Errorf("failed to decode introspection response: %w", err)
 	}
+
+	// Cache active tokens for CacheTTL, but never past their expiry
+	if introspectionResp.Active && o.config.CacheTTL > 0 {
+		expiresAt := time.Now().Add(o.config.CacheTTL)
+		if introspectionResp.Exp > 0 {
+			if exp := time.Unix(introspectionResp.Exp, 0); exp.Before(expiresAt) {
+				expiresAt = exp
+			}
+		}
+		if time.Now().Before(expiresAt) {
+			o.cache.Set(token, &introspectionResp, expiresAt)
+		}
+	}
 	
 	return &introspectionResp, nil
 }
 
+// doIntrospection sends one introspection request and records its result
+func (o *OAuth2Authenticator) doIntrospection(ctx context.Context, body string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", o.config.IntrospectionURL, strings.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create introspection request: %w", err)
+	}
+
+	// Set headers
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	// Add custom headers
+	for key, value := range o.config.Headers {
+		req.Header.Set(key, value)
+	}
+
+	// Set client authentication
+	if o.config.ClientID != "" && o.config.ClientSecret != "" {
+		req.SetBasicAuth(o.config.ClientID, o.config.ClientSecret)
+	}
+
+	start := time.Now()
+	resp, err := o.httpClient.Do(req)
+
+	result := "success"
+	if err != nil {
+		result = "error"
+	} else if resp.StatusCode >= 500 {
+		result = "server_error"
+	}
+	if o.attemptCounter != nil {
+		o.attemptCounter.WithLabelValues(result).Inc()
+	}
+	if o.attemptDuration != nil {
+		o.attemptDuration.WithLabelValues(result).Observe(time.Since(start).Seconds())
+	}
+	return resp, err
+}
+
+// retryDelay returns the delay before a retry, growing by RetryMultiplier
+// from RetryDelay up to MaxRetryDelay, of which the RetryJitter fraction is
+// random so gateways don't retry in lockstep
+func (o *OAuth2Authenticator) retryDelay(retry int) time.Duration {
+	delay := float64(o.config.RetryDelay) * math.Pow(o.config.RetryMultiplier, float64(retry-1))
+	if max := float64(o.config.MaxRetryDelay); delay > max {
+		delay = max
+	}
+	delay -= delay * o.config.RetryJitter * o.random()
+	return time.Duration(delay)
+}
+
+// recordError counts a failed introspection
+func (o *OAuth2Authenticator) recordError(reason string) {
+	if o.errorCounter != nil {
+		o.errorCounter.WithLabelValues(reason).Inc()
+	}
+}
+
+// FlushCache removes all cached introspection results and returns how many
+// were removed
+func (o *OAuth2Authenticator) FlushCache() int {
+	return o.cache.Flush()
+}
+
 // createUserInfoFromIntrospection creates UserInfo from introspection response
 func (o *OAuth2Authenticator) createUserInfoFromIntrospection(resp *IntrospectionResponse) *UserInfo {
 	userInfo := &UserInfo{
@@ -1,13 +1,17 @@
 package auth
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/songzhibin97/stargate/internal/config"
+	memorymetrics "github.com/songzhibin97/stargate/internal/metrics/driver/memory"
+	"github.com/songzhibin97/stargate/pkg/metrics"
 )
 
 func TestOAuth2Authenticator_Authenticate(t *testing.T) {
@@ -357,3 +361,138 @@ func TestOAuth2Authenticator_IntrospectionToClaims(t *testing.T) {
 		t.Errorf("Expected iat=1234567800, got %v", claims["iat"])
 	}
 }
+
+func TestOAuth2Authenticator_IntrospectionCache(t *testing.T) {
+	var introspections int
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		introspections++
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(IntrospectionResponse{
+			Active: true,
+			Sub:    "user123",
+			Exp:    time.Now().Add(time.Hour).Unix(),
+		})
+	}))
+	defer mockServer.Close()
+
+	auth := NewOAuth2Authenticator(&config.OAuth2Config{
+		IntrospectionURL: mockServer.URL,
+		Timeout:          5 * time.Second,
+	})
+
+	authenticate := func() {
+		req := httptest.NewRequest("GET", "/test", nil)
+		req.Header.Set("Authorization", "Bearer valid-token")
+		result, err := auth.Authenticate(req)
+		if err != nil || !result.Authenticated {
+			t.Fatalf("Expected token to authenticate, got %+v, %v", result, err)
+		}
+	}
+
+	authenticate()
+	authenticate()
+	if introspections != 1 {
+		t.Errorf("Expected cached introspection result to be reused, got %d introspections", introspections)
+	}
+
+	if flushed := auth.FlushCache(); flushed != 1 {
+		t.Errorf("Expected 1 cached result flushed, got %d", flushed)
+	}
+
+	authenticate()
+	if introspections != 2 {
+		t.Errorf("Expected token to be introspected again after flush, got %d introspections", introspections)
+	}
+}
+
+func TestOAuth2Authenticator_RetryBackoff(t *testing.T) {
+	auth := NewOAuth2Authenticator(&config.OAuth2Config{
+		IntrospectionURL: "http://127.0.0.1:0",
+		RetryDelay:       100 * time.Millisecond,
+		MaxRetryDelay:    time.Second,
+	})
+
+	// Without jitter delays double up to the cap
+	auth.random = func() float64 { return 0 }
+	expected := []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond, time.Second, time.Second}
+	for i, want := range expected {
+		if got := auth.retryDelay(i + 1); got != want {
+			t.Errorf("Retry %d: expected delay %v, got %v", i+1, want, got)
+		}
+	}
+
+	// Jitter shortens delays by up to the configured fraction
+	auth.random = func() float64 { return 0.999999 }
+	if got := auth.retryDelay(3); got < 200*time.Millisecond || got >= 201*time.Millisecond {
+		t.Errorf("Expected half of the 400ms delay with maximal default jitter, got %v", got)
+	}
+	auth.config.RetryJitter = 0.25
+	auth.random = func() float64 { return 0.5 }
+	if got := auth.retryDelay(2); got != 175*time.Millisecond {
+		t.Errorf("Expected 200ms shortened by 12.5%%, got %v", got)
+	}
+}
+
+func TestOAuth2Authenticator_RetryDeadline(t *testing.T) {
+	var requests atomic.Int32
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The request body is sent again on retries
+		r.ParseForm()
+		if r.FormValue("token") != "flaky-token" {
+			t.Errorf("Expected the token in every attempt, got %q", r.FormValue("token"))
+		}
+		if requests.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		json.NewEncoder(w).Encode(IntrospectionResponse{Active: true, Sub: "user123"})
+	}))
+	defer mockServer.Close()
+
+	provider := memorymetrics.NewProvider(memorymetrics.Options{})
+	attempts, _ := provider.NewCounterVec(metrics.MetricOptions{Name: "attempts", Labels: []string{"result"}})
+	duration, _ := provider.NewHistogramVec(metrics.MetricOptions{Name: "duration", Labels: []string{"result"}})
+	failures, _ := provider.NewCounterVec(metrics.MetricOptions{Name: "errors", Labels: []string{"reason"}})
+
+	auth := NewOAuth2Authenticator(&config.OAuth2Config{
+		IntrospectionURL: mockServer.URL,
+		Timeout:          5 * time.Second,
+		MaxRetries:       3,
+		RetryDelay:       10 * time.Millisecond,
+	})
+	auth.SetMetrics(attempts, duration, failures)
+
+	response, err := auth.introspectToken(context.Background(), "flaky-token")
+	if err != nil || !response.Active {
+		t.Fatalf("Expected the third attempt to succeed, got %+v, %v", response, err)
+	}
+	if got := attempts.WithLabelValues("server_error").Get(); got != 2 {
+		t.Errorf("Expected 2 failed attempts counted, got %v", got)
+	}
+	if got := attempts.WithLabelValues("success").Get(); got != 1 {
+		t.Errorf("Expected 1 successful attempt counted, got %v", got)
+	}
+	if got := duration.WithLabelValues("success").GetCount(); got != 1 {
+		t.Errorf("Expected the latency of the successful attempt observed, got %d", got)
+	}
+
+	// Retries stop at the deadline of the client's request
+	auth.FlushCache()
+	requests.Store(-100)
+	auth.config.RetryDelay = 200 * time.Millisecond
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := auth.introspectToken(ctx, "flaky-token"); err == nil {
+		t.Fatal("Expected introspection to fail")
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("Expected introspection to give up before the deadline, took %v", elapsed)
+	}
+	if got := requests.Load(); got != -99 {
+		t.Errorf("Expected no retry past the deadline, got %d attempts", got+100)
+	}
+	if got := failures.WithLabelValues("deadline").Get(); got != 1 {
+		t.Errorf("Expected the failure counted with reason deadline, got %v", got)
+	}
+}
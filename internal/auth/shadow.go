@@ -0,0 +1,147 @@
+package auth
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/songzhibin97/stargate/internal/config"
+	"github.com/songzhibin97/stargate/pkg/metrics"
+)
+
+// Shadow evaluates a secondary auth configuration beside the active one.
+// Evaluations run in the background on a copy of the request, so they never
+// delay or change the outcome of requests; their decisions are only logged
+// when they disagree with the active configuration and counted.
+type Shadow struct {
+	auth    *Middleware
+	routes  map[string]bool
+	timeout time.Duration
+
+	// slots bounds the evaluations in flight, requests arriving when all are
+	// taken aren't evaluated
+	slots chan struct{}
+	wg    sync.WaitGroup
+
+	decisions metrics.CounterVec
+}
+
+// NewShadow creates the shadow evaluation of a secondary auth configuration
+func NewShadow(cfg *config.AuthShadowConfig) *Shadow {
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = time.Second
+	}
+	maxConcurrent := cfg.MaxConcurrent
+	if maxConcurrent <= 0 {
+		maxConcurrent = 100
+	}
+
+	s := &Shadow{
+		auth: NewMiddleware(&config.AuthConfig{
+			Enabled: true,
+			JWT:     cfg.JWT,
+			APIKey:  cfg.APIKey,
+			OAuth2:  cfg.OAuth2,
+		}),
+		timeout: timeout,
+		slots:   make(chan struct{}, maxConcurrent),
+	}
+	if len(cfg.Routes) > 0 {
+		s.routes = make(map[string]bool, len(cfg.Routes))
+		for _, routeID := range cfg.Routes {
+			s.routes[routeID] = true
+		}
+	}
+	return s
+}
+
+// SetMetrics sets the counter of shadow decisions, labeled by the active and
+// shadow outcomes and the shadow's failure reason
+func (s *Shadow) SetMetrics(decisions metrics.CounterVec) {
+	s.decisions = decisions
+}
+
+// appliesTo reports whether requests of the route are evaluated
+func (s *Shadow) appliesTo(routeID string) bool {
+	return s.routes == nil || s.routes[routeID]
+}
+
+// request returns the copy of a request the shadow configuration evaluates,
+// taken before the active configuration adds headers for the upstream. Its
+// context outlives the request, bounded by the shadow timeout.
+func (s *Shadow) request(r *http.Request) (*http.Request, context.CancelFunc) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(r.Context()), s.timeout)
+	shadowReq := r.Clone(ctx)
+	shadowReq.Body = http.NoBody
+	shadowReq.GetBody = nil
+	return shadowReq, cancel
+}
+
+// Evaluate evaluates a request copy in the background and compares the
+// decision with the active one. Requests are skipped when too many
+// evaluations are in flight.
+func (s *Shadow) Evaluate(r *http.Request, cancel context.CancelFunc, routeID string, activeAllowed bool) {
+	select {
+	case s.slots <- struct{}{}:
+	default:
+		cancel()
+		s.record(activeAllowed, "skipped", "")
+		return
+	}
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer func() { <-s.slots }()
+		defer cancel()
+
+		shadowAllowed, reason := false, FailureInternalError
+		result, err := s.auth.authenticate(r)
+		if err == nil {
+			shadowAllowed, reason = result.Authenticated, result.Reason
+		}
+		if shadowAllowed {
+			reason = ""
+		}
+
+		s.record(activeAllowed, outcome(shadowAllowed), reason)
+		if shadowAllowed != activeAllowed {
+			log.Printf("Shadow auth would %s %s %s (route %s, active auth: %s, reason: %s)",
+				outcome(shadowAllowed), r.Method, r.URL.Path, routeID, outcome(activeAllowed), reason)
+		}
+	}()
+}
+
+// record counts a shadow decision
+func (s *Shadow) record(activeAllowed bool, shadow, reason string) {
+	if s.decisions == nil {
+		return
+	}
+	if reason == "" {
+		reason = "none"
+	}
+	s.decisions.WithLabelValues(outcome(activeAllowed), shadow, reason).Inc()
+}
+
+// Wait waits for the evaluations in flight
+func (s *Shadow) Wait() {
+	s.wg.Wait()
+}
+
+// Close waits for the evaluations in flight and releases the resources of
+// the shadow authenticators
+func (s *Shadow) Close() {
+	s.wg.Wait()
+	s.auth.Close()
+}
+
+// outcome names an auth decision
+func outcome(allowed bool) string {
+	if allowed {
+		return "allow"
+	}
+	return "deny"
+}
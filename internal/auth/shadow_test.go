@@ -0,0 +1,142 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/songzhibin97/stargate/internal/config"
+	memorymetrics "github.com/songzhibin97/stargate/internal/metrics/driver/memory"
+	"github.com/songzhibin97/stargate/pkg/metrics"
+)
+
+// blockingAuthenticator allows requests once released
+type blockingAuthenticator struct {
+	release chan struct{}
+}
+
+func (a *blockingAuthenticator) Authenticate(r *http.Request) (*AuthResult, error) {
+	<-a.release
+	return &AuthResult{Authenticated: true}, nil
+}
+
+func (a *blockingAuthenticator) GetName() string {
+	return "blocking"
+}
+
+func TestMiddleware_ShadowAuth(t *testing.T) {
+	// Migrating from static API keys to JWT, on the orders route only
+	middleware := NewMiddleware(&config.AuthConfig{
+		Enabled: true,
+		APIKey:  config.APIKeyConfig{Header: "X-API-Key", Keys: []string{"legacy-key"}},
+		Shadow: config.AuthShadowConfig{
+			Enabled: true,
+			JWT:     config.JWTConfig{Secret: "test-secret-key", Algorithm: "HS256"},
+			Routes:  []string{"orders"},
+		},
+	})
+	defer middleware.Close()
+
+	provider := memorymetrics.NewProvider(memorymetrics.Options{})
+	decisions, err := provider.NewCounterVec(metrics.MetricOptions{
+		Name:   "auth_shadow_decisions_total",
+		Labels: []string{"active", "shadow", "reason"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create counter: %v", err)
+	}
+	middleware.SetShadowMetrics(decisions)
+	middleware.SetRouteMatcher(func(r *http.Request) string {
+		return strings.TrimPrefix(r.URL.Path, "/")
+	})
+
+	handler := middleware.Handler()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub": "user-1",
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}).SignedString([]byte("test-secret-key"))
+	if err != nil {
+		t.Fatalf("Failed to sign token: %v", err)
+	}
+
+	// Outcomes are those of the active configuration only
+	for _, tt := range []struct {
+		path   string
+		header string
+		value  string
+		status int
+	}{
+		{"/orders", "X-API-Key", "legacy-key", http.StatusOK},
+		{"/orders", "Authorization", "Bearer " + token, http.StatusUnauthorized},
+		{"/reports", "Authorization", "Bearer " + token, http.StatusUnauthorized},
+	} {
+		req := httptest.NewRequest("GET", tt.path, nil)
+		req.Header.Set(tt.header, tt.value)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		if rr.Code != tt.status {
+			t.Errorf("%s with %s: expected status %d, got %d", tt.path, tt.header, tt.status, rr.Code)
+		}
+	}
+	middleware.shadow.Wait()
+
+	if got := decisions.WithLabelValues("allow", "deny", FailureMissingCredentials).Get(); got != 1 {
+		t.Errorf("Expected 1 request the shadow would deny, got %v", got)
+	}
+	if got := decisions.WithLabelValues("deny", "allow", "none").Get(); got != 1 {
+		t.Errorf("Expected 1 request the shadow would allow on the shadowed route, got %v", got)
+	}
+}
+
+func TestShadow_NeverDelaysRequests(t *testing.T) {
+	middleware := NewMiddleware(&config.AuthConfig{
+		Enabled: true,
+		APIKey:  config.APIKeyConfig{Header: "X-API-Key", Keys: []string{"legacy-key"}},
+		Shadow: config.AuthShadowConfig{
+			Enabled:       true,
+			APIKey:        config.APIKeyConfig{Header: "X-API-Key"},
+			MaxConcurrent: 1,
+		},
+	})
+
+	provider := memorymetrics.NewProvider(memorymetrics.Options{})
+	decisions, _ := provider.NewCounterVec(metrics.MetricOptions{
+		Name:   "auth_shadow_decisions_total",
+		Labels: []string{"active", "shadow", "reason"},
+	})
+	middleware.SetShadowMetrics(decisions)
+
+	blocking := &blockingAuthenticator{release: make(chan struct{})}
+	middleware.shadow.auth.AddAuthenticator(AuthMethodAPIKey, blocking)
+
+	handler := middleware.Handler()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	// Requests complete while the shadow evaluation is stuck, and requests
+	// beyond the evaluations in flight are skipped
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("X-API-Key", "legacy-key")
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Request %d: expected status 200, got %d", i, rr.Code)
+		}
+	}
+	if got := decisions.WithLabelValues("allow", "skipped", "none").Get(); got != 1 {
+		t.Errorf("Expected 1 skipped evaluation, got %v", got)
+	}
+
+	close(blocking.release)
+	middleware.Close()
+	if got := decisions.WithLabelValues("allow", "allow", "none").Get(); got != 1 {
+		t.Errorf("Expected 1 evaluation after release, got %v", got)
+	}
+}
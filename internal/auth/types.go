@@ -35,6 +35,9 @@ type AuthResult struct {
 	// StatusCode is the HTTP status code to return on failure
 	StatusCode int `json:"status_code,omitempty"`
 	
+	// Reason is why authentication failed, one of the Failure constants
+	Reason string `json:"reason,omitempty"`
+	
 	// Headers contains additional headers to set
 	Headers map[string]string `json:"headers,omitempty"`
 	
@@ -42,6 +45,22 @@ type AuthResult struct {
 	Metadata map[string]interface{} `json:"metadata,omitempty"`
 }
 
+// Reasons authentication failed
+const (
+	FailureMissingCredentials  = "missing_credentials"
+	FailureUnknownKey          = "unknown_key"
+	FailureKeyDisabled         = "key_disabled"
+	FailureIPNotAllowed        = "ip_not_allowed"
+	FailureExpired             = "expired"
+	FailureNotYetValid         = "not_yet_valid"
+	FailureInvalidSignature    = "invalid_signature"
+	FailureInvalidToken        = "invalid_token"
+	FailureInactiveToken       = "inactive_token"
+	FailureIntrospectionFailed = "introspection_failed"
+	FailureInternalError       = "internal_error"
+	FailureRevoked             = "revoked"
+)
+
 // UserInfo represents authenticated user information
 type UserInfo struct {
 	// ID is the unique identifier for the user
@@ -102,6 +121,9 @@ const (
 	
 	// AuthContextKeyMethod is the key for auth method in context
 	AuthContextKeyMethod AuthContextKey = "auth_method"
+	
+	// AuthContextKeyScopes is the key for the consumer's granted scopes in context
+	AuthContextKeyScopes AuthContextKey = "auth_scopes"
 )
 
 // GetUserFromContext extracts user info from request context
@@ -128,6 +150,32 @@ func GetAuthMethodFromContext(ctx context.Context) (string, bool) {
 	return method, ok
 }
 
+// GetScopesFromContext extracts the scopes granted to the authenticated
+// consumer from request context
+func GetScopesFromContext(ctx context.Context) ([]string, bool) {
+	scopes, ok := ctx.Value(AuthContextKeyScopes).([]string)
+	return scopes, ok
+}
+
+// HasScopes reports whether the authenticated consumer was granted all the
+// required scopes
+func HasScopes(ctx context.Context, required ...string) bool {
+	scopes, _ := GetScopesFromContext(ctx)
+	for _, scope := range required {
+		granted := false
+		for _, s := range scopes {
+			if s == scope {
+				granted = true
+				break
+			}
+		}
+		if !granted {
+			return false
+		}
+	}
+	return true
+}
+
 // SetUserInContext sets user info in request context
 func SetUserInContext(ctx context.Context, user *UserInfo) context.Context {
 	return context.WithValue(ctx, AuthContextKeyUser, user)
@@ -143,6 +191,11 @@ func SetClaimsInContext(ctx context.Context, claims map[string]interface{}) cont
 	return context.WithValue(ctx, AuthContextKeyClaims, claims)
 }
 
+// SetScopesInContext sets the consumer's granted scopes in request context
+func SetScopesInContext(ctx context.Context, scopes []string) context.Context {
+	return context.WithValue(ctx, AuthContextKeyScopes, scopes)
+}
+
 // SetAuthMethodInContext sets auth method in request context
 func SetAuthMethodInContext(ctx context.Context, method string) context.Context {
 	return context.WithValue(ctx, AuthContextKeyMethod, method)
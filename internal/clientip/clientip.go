@@ -0,0 +1,141 @@
+// Package clientip resolves the IP address of the client that sent a request.
+//
+// Forwarding headers are only trusted when the request comes from a
+// configured trusted proxy. X-Forwarded-For is walked right to left, skipping
+// trusted proxies, and the first untrusted hop is the client. Without trusted
+// proxies the connection's address is used, so clients can't spoof their
+// address by sending forwarding headers themselves.
+package clientip
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// contextKey is the request context key holding the resolved client IP
+const contextKey = "client_ip"
+
+// Resolver resolves client IPs using a list of trusted proxies
+type Resolver struct {
+	trustedProxies []*net.IPNet
+}
+
+// NewResolver creates a resolver trusting forwarding headers from the given
+// proxy IPs and CIDRs
+func NewResolver(trustedProxies []string) (*Resolver, error) {
+	networks, err := ParseNetworks(trustedProxies)
+	if err != nil {
+		return nil, err
+	}
+	return &Resolver{trustedProxies: networks}, nil
+}
+
+// ParseNetworks parses a list of IPs and CIDRs. Plain IPs are treated as
+// single-address networks.
+func ParseNetworks(sources []string) ([]*net.IPNet, error) {
+	networks := make([]*net.IPNet, 0, len(sources))
+	for _, source := range sources {
+		source = strings.TrimSpace(source)
+		if _, network, err := net.ParseCIDR(source); err == nil {
+			networks = append(networks, network)
+			continue
+		}
+
+		ip := net.ParseIP(source)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid IP or CIDR: %s", source)
+		}
+		bits := 128
+		if ip.To4() != nil {
+			ip = ip.To4()
+			bits = 32
+		}
+		networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+	}
+	return networks, nil
+}
+
+// Resolve returns the client IP of the request
+func (r *Resolver) Resolve(req *http.Request) string {
+	remoteIP := RemoteIP(req)
+	if r == nil || !r.isTrusted(remoteIP) {
+		return remoteIP
+	}
+
+	// Walk X-Forwarded-For right to left to the first untrusted hop
+	hops := forwardedFor(req.Header)
+	if len(hops) > 0 {
+		clientIP := remoteIP
+		for i := len(hops) - 1; i >= 0; i-- {
+			if net.ParseIP(hops[i]) == nil {
+				// Malformed hop, the last valid address is the best we know
+				return clientIP
+			}
+			clientIP = hops[i]
+			if !r.isTrusted(clientIP) {
+				return clientIP
+			}
+		}
+		// Every hop is a trusted proxy, the leftmost is the origin
+		return clientIP
+	}
+
+	// X-Real-IP set by a trusted proxy
+	if realIP := strings.TrimSpace(req.Header.Get("X-Real-IP")); net.ParseIP(realIP) != nil {
+		return realIP
+	}
+
+	return remoteIP
+}
+
+// WithClientIP returns a copy of the request carrying its resolved client IP
+func (r *Resolver) WithClientIP(req *http.Request) *http.Request {
+	return req.WithContext(context.WithValue(req.Context(), contextKey, r.Resolve(req)))
+}
+
+// isTrusted reports whether ip belongs to a trusted proxy
+func (r *Resolver) isTrusted(ip string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, network := range r.trustedProxies {
+		if network.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// FromRequest returns the client IP resolved for the request. Requests that
+// weren't passed through a Resolver fall back to the connection's address.
+func FromRequest(req *http.Request) string {
+	if ip, ok := req.Context().Value(contextKey).(string); ok && ip != "" {
+		return ip
+	}
+	return RemoteIP(req)
+}
+
+// RemoteIP returns the IP of the connection the request was received on
+func RemoteIP(req *http.Request) string {
+	if ip, _, err := net.SplitHostPort(req.RemoteAddr); err == nil {
+		return ip
+	}
+	return req.RemoteAddr
+}
+
+// forwardedFor returns the hops of all X-Forwarded-For headers in order
+func forwardedFor(header http.Header) []string {
+	var hops []string
+	for _, value := range header.Values("X-Forwarded-For") {
+		for _, hop := range strings.Split(value, ",") {
+			if hop = strings.TrimSpace(hop); hop != "" {
+				hops = append(hops, hop)
+			}
+		}
+	}
+	return hops
+}
@@ -0,0 +1,133 @@
+package clientip
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestResolver_Resolve(t *testing.T) {
+	resolver, err := NewResolver([]string{"10.0.0.0/8", "192.168.1.1"})
+	if err != nil {
+		t.Fatalf("Failed to create resolver: %v", err)
+	}
+
+	tests := []struct {
+		name          string
+		remoteAddr    string
+		xForwardedFor []string
+		xRealIP       string
+		expected      string
+	}{
+		{
+			name:       "Direct connection",
+			remoteAddr: "203.0.113.1:12345",
+			expected:   "203.0.113.1",
+		},
+		{
+			name:          "Spoofed X-Forwarded-For from untrusted source",
+			remoteAddr:    "203.0.113.1:12345",
+			xForwardedFor: []string{"198.51.100.1"},
+			expected:      "203.0.113.1",
+		},
+		{
+			name:       "Spoofed X-Real-IP from untrusted source",
+			remoteAddr: "203.0.113.1:12345",
+			xRealIP:    "198.51.100.1",
+			expected:   "203.0.113.1",
+		},
+		{
+			name:          "Client behind trusted proxy",
+			remoteAddr:    "10.0.0.5:12345",
+			xForwardedFor: []string{"203.0.113.1"},
+			expected:      "203.0.113.1",
+		},
+		{
+			name:          "Client behind chain of trusted proxies",
+			remoteAddr:    "10.0.0.5:12345",
+			xForwardedFor: []string{"203.0.113.1, 192.168.1.1, 10.1.2.3"},
+			expected:      "203.0.113.1",
+		},
+		{
+			name:          "Spoofed hop prepended by client",
+			remoteAddr:    "10.0.0.5:12345",
+			xForwardedFor: []string{"1.2.3.4, 203.0.113.1"},
+			expected:      "203.0.113.1",
+		},
+		{
+			name:          "Multiple X-Forwarded-For headers",
+			remoteAddr:    "10.0.0.5:12345",
+			xForwardedFor: []string{"1.2.3.4", "203.0.113.1, 10.1.2.3"},
+			expected:      "203.0.113.1",
+		},
+		{
+			name:          "Malformed hop",
+			remoteAddr:    "10.0.0.5:12345",
+			xForwardedFor: []string{"203.0.113.1, not-an-ip, 10.1.2.3"},
+			expected:      "10.1.2.3",
+		},
+		{
+			name:          "Only trusted hops",
+			remoteAddr:    "10.0.0.5:12345",
+			xForwardedFor: []string{"10.1.2.3, 10.4.5.6"},
+			expected:      "10.1.2.3",
+		},
+		{
+			name:       "X-Real-IP from trusted proxy",
+			remoteAddr: "10.0.0.5:12345",
+			xRealIP:    "203.0.113.1",
+			expected:   "203.0.113.1",
+		},
+		{
+			name:       "RemoteAddr without port",
+			remoteAddr: "203.0.113.1",
+			expected:   "203.0.113.1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			for _, value := range tt.xForwardedFor {
+				req.Header.Add("X-Forwarded-For", value)
+			}
+			if tt.xRealIP != "" {
+				req.Header.Set("X-Real-IP", tt.xRealIP)
+			}
+
+			if ip := resolver.Resolve(req); ip != tt.expected {
+				t.Errorf("Expected %s, got %s", tt.expected, ip)
+			}
+			if ip := FromRequest(resolver.WithClientIP(req)); ip != tt.expected {
+				t.Errorf("Expected %s from request context, got %s", tt.expected, ip)
+			}
+		})
+	}
+}
+
+func TestResolver_NoTrustedProxies(t *testing.T) {
+	resolver, err := NewResolver(nil)
+	if err != nil {
+		t.Fatalf("Failed to create resolver: %v", err)
+	}
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "10.0.0.5:12345"
+	req.Header.Set("X-Forwarded-For", "203.0.113.1")
+	req.Header.Set("X-Real-IP", "203.0.113.1")
+
+	if ip := resolver.Resolve(req); ip != "10.0.0.5" {
+		t.Errorf("Expected RemoteAddr without trusted proxies, got %s", ip)
+	}
+
+	// Requests not passed through a resolver use the RemoteAddr
+	if ip := FromRequest(req); ip != "10.0.0.5" {
+		t.Errorf("Expected RemoteAddr without resolver, got %s", ip)
+	}
+}
+
+func TestNewResolver_InvalidProxy(t *testing.T) {
+	if _, err := NewResolver([]string{"not-an-ip"}); err == nil {
+		t.Error("Expected error for invalid trusted proxy")
+	}
+}
@@ -0,0 +1,249 @@
+package config
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// redactedValue replaces passwords in URLs and DSNs
+const redactedValue = "xxxxx"
+
+// EffectiveConfig summarizes the features a configuration enables, for
+// startup logging and the Admin API. It never carries secrets: credentials
+// are only reported as set or not, and passwords in URLs and DSNs are
+// redacted.
+type EffectiveConfig struct {
+	Listeners    []EffectiveListener   `json:"listeners"`
+	Middlewares  []string              `json:"middlewares"` // Middlewares in the request chain, in order
+	LoadBalancer EffectiveLoadBalancer `json:"load_balancer"`
+	RateLimit    EffectiveRateLimit    `json:"rate_limit"`
+	Auth         EffectiveAuth         `json:"auth"`
+	AdminAPI     EffectiveAdminAPI     `json:"admin_api"`
+	Store        EffectiveStore        `json:"store"`
+	ConfigSource EffectiveStore        `json:"config_source"`
+	Portal       EffectivePortal       `json:"portal"`
+}
+
+// EffectiveListener is an HTTP listener and its TLS settings
+type EffectiveListener struct {
+	Name    string `json:"name"`
+	Address string `json:"address"`
+	TLS     bool   `json:"tls"`
+	ACME    bool   `json:"acme"`
+	Profile string `json:"profile,omitempty"`
+}
+
+// EffectiveLoadBalancer is the load balancing and health checking in effect
+type EffectiveLoadBalancer struct {
+	Algorithm          string `json:"algorithm"`
+	ActiveHealthCheck  bool   `json:"active_health_check"`
+	PassiveHealthCheck bool   `json:"passive_health_check"`
+}
+
+// EffectiveRateLimit is the rate limiting in effect
+type EffectiveRateLimit struct {
+	Enabled            bool   `json:"enabled"`
+	Strategy           string `json:"strategy,omitempty"`
+	Storage            string `json:"storage,omitempty"`
+	IdentifierStrategy string `json:"identifier_strategy,omitempty"`
+	RedisAddress       string `json:"redis_address,omitempty"`
+	RedisPassword      bool   `json:"redis_password_set,omitempty"`
+	DynamoDBTable      string `json:"dynamodb_table,omitempty"`
+	DynamoDBRegion     string `json:"dynamodb_region,omitempty"`
+	DynamoDBEndpoint   string `json:"dynamodb_endpoint,omitempty"`
+	DynamoDBSecretKey  bool   `json:"dynamodb_secret_access_key_set,omitempty"`
+}
+
+// EffectiveAuth is the client authentication in effect
+type EffectiveAuth struct {
+	Enabled          bool     `json:"enabled"`
+	Modes            []string `json:"modes"` // jwt, api_key and oauth2, by configured credentials
+	JWTAlgorithm     string   `json:"jwt_algorithm,omitempty"`
+	JWTSecret        bool     `json:"jwt_secret_set"`
+	JWKSURL          string   `json:"jwks_url,omitempty"`
+	APIKeys          int      `json:"api_keys"` // Number of configured keys
+	IntrospectionURL string   `json:"introspection_url,omitempty"`
+	ClientSecret     bool     `json:"oauth2_client_secret_set"`
+}
+
+// EffectiveAdminAPI is the Admin API exposure
+type EffectiveAdminAPI struct {
+	REST   bool   `json:"rest"`
+	Prefix string `json:"prefix,omitempty"`
+	GRPC   bool   `json:"grpc"`
+	Auth   bool   `json:"auth"`
+}
+
+// EffectiveStore is a configuration store or source
+type EffectiveStore struct {
+	Type      string   `json:"type"`
+	Endpoints []string `json:"endpoints,omitempty"`
+	TLS       bool     `json:"tls"`
+	Password  bool     `json:"password_set"`
+}
+
+// EffectivePortal is the developer portal and its repository
+type EffectivePortal struct {
+	Enabled    bool   `json:"enabled"`
+	Repository string `json:"repository,omitempty"`
+	DSN        string `json:"dsn,omitempty"`
+}
+
+// Effective summarizes the features enabled by a configuration. The
+// middlewares are left for the pipeline, which knows the chain it built.
+func Effective(cfg *Config) EffectiveConfig {
+	effective := EffectiveConfig{
+		Listeners: []EffectiveListener{},
+		LoadBalancer: EffectiveLoadBalancer{
+			Algorithm:          cfg.LoadBalancer.DefaultAlgorithm,
+			ActiveHealthCheck:  cfg.LoadBalancer.HealthCheck.Enabled,
+			PassiveHealthCheck: cfg.Upstreams.Defaults.HealthCheck.Passive.Enabled,
+		},
+		RateLimit: EffectiveRateLimit{Enabled: cfg.RateLimit.Enabled},
+		Auth: EffectiveAuth{
+			Enabled:      cfg.Auth.Enabled,
+			Modes:        authModes(&cfg.Auth),
+			JWTSecret:    cfg.Auth.JWT.Secret != "",
+			JWKSURL:      RedactURL(cfg.Auth.JWT.JWKSURL),
+			APIKeys:      len(cfg.Auth.APIKey.Keys),
+			ClientSecret: cfg.Auth.OAuth2.ClientSecret != "",
+		},
+		AdminAPI: EffectiveAdminAPI{
+			REST: cfg.AdminAPI.REST.Enabled,
+			GRPC: cfg.AdminAPI.GRPC.Enabled,
+			Auth: cfg.AdminAPI.Auth.Enabled,
+		},
+		Store: EffectiveStore{
+			Type:      cfg.Store.Type,
+			Endpoints: redactURLs(cfg.Store.Etcd.Endpoints),
+			TLS:       cfg.Store.Etcd.TLS.Enabled,
+			Password:  cfg.Store.Etcd.Password != "",
+		},
+		ConfigSource: EffectiveStore{Type: cfg.ConfigSource.Source.Driver},
+		Portal:       EffectivePortal{Enabled: cfg.Portal.Enabled},
+	}
+
+	for _, listener := range cfg.Server.HTTPListeners() {
+		effective.Listeners = append(effective.Listeners, EffectiveListener{
+			Name:    listener.Name,
+			Address: listener.Address,
+			TLS:     listener.TLS.Enabled,
+			ACME:    listener.TLS.ACME.Enabled,
+			Profile: listener.Profile,
+		})
+	}
+
+	if cfg.RateLimit.Enabled {
+		effective.RateLimit.Strategy = cfg.RateLimit.Strategy
+		effective.RateLimit.Storage = cfg.RateLimit.Storage
+		effective.RateLimit.IdentifierStrategy = cfg.RateLimit.IdentifierStrategy
+		if cfg.RateLimit.Storage == "redis" {
+			effective.RateLimit.RedisAddress = RedactURL(cfg.RateLimit.Redis.Address)
+			effective.RateLimit.RedisPassword = cfg.RateLimit.Redis.Password != ""
+		}
+		if cfg.RateLimit.Storage == "dynamodb" {
+			effective.RateLimit.DynamoDBTable = cfg.RateLimit.DynamoDB.Table
+			effective.RateLimit.DynamoDBRegion = cfg.RateLimit.DynamoDB.Region
+			effective.RateLimit.DynamoDBEndpoint = RedactURL(cfg.RateLimit.DynamoDB.Endpoint)
+			effective.RateLimit.DynamoDBSecretKey = cfg.RateLimit.DynamoDB.SecretAccessKey != ""
+		}
+	}
+	if cfg.Auth.Enabled {
+		effective.Auth.JWTAlgorithm = cfg.Auth.JWT.Algorithm
+		effective.Auth.IntrospectionURL = RedactURL(cfg.Auth.OAuth2.IntrospectionURL)
+	}
+	if cfg.AdminAPI.REST.Enabled {
+		effective.AdminAPI.Prefix = cfg.AdminAPI.REST.Prefix
+	}
+	if cfg.ConfigSource.Source.Driver == "etcd" {
+		effective.ConfigSource.Endpoints = redactURLs(cfg.ConfigSource.Source.Etcd.Endpoints)
+	}
+	if cfg.Portal.Enabled {
+		effective.Portal.Repository = cfg.Portal.Repository.Type
+		if cfg.Portal.Repository.Type == "postgres" {
+			effective.Portal.DSN = RedactDSN(cfg.Portal.Repository.Postgres.DSN)
+		}
+	}
+
+	return effective
+}
+
+// authModes lists the authentication modes with configured credentials
+func authModes(cfg *AuthConfig) []string {
+	modes := []string{}
+	if cfg.JWT.Secret != "" || cfg.JWT.PublicKey != "" || cfg.JWT.JWKSURL != "" {
+		modes = append(modes, "jwt")
+	}
+	if len(cfg.APIKey.Keys) > 0 {
+		modes = append(modes, "api_key")
+	}
+	if cfg.OAuth2.IntrospectionURL != "" {
+		modes = append(modes, "oauth2")
+	}
+	return modes
+}
+
+// sensitiveQueryParams are query parameters redacted from URLs and DSNs
+var sensitiveQueryParams = []string{"password", "secret", "token", "key"}
+
+// dsnPasswordPattern matches passwords of key=value DSNs such as
+// "host=db user=app password=secret"
+var dsnPasswordPattern = regexp.MustCompile(`(?i)\b(password|sslpassword)\s*=\s*('(?:[^'\\]|\\.)*'|\S+)`)
+
+// RedactURL returns the URL with its password and sensitive query parameters
+// redacted. Values that are not URLs are returned unchanged unless they
+// carry user information, which is then dropped entirely.
+func RedactURL(raw string) string {
+	if raw == "" {
+		return ""
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		// Addresses such as host:port carry no credentials
+		if strings.Contains(raw, "@") {
+			return redactedValue + "@" + raw[strings.LastIndex(raw, "@")+1:]
+		}
+		return raw
+	}
+
+	if _, hasPassword := u.User.Password(); hasPassword {
+		u.User = url.UserPassword(u.User.Username(), redactedValue)
+	}
+	query := u.Query()
+	redacted := false
+	for name := range query {
+		for _, sensitive := range sensitiveQueryParams {
+			if strings.Contains(strings.ToLower(name), sensitive) {
+				query.Set(name, redactedValue)
+				redacted = true
+				break
+			}
+		}
+	}
+	if redacted {
+		u.RawQuery = query.Encode()
+	}
+	return u.String()
+}
+
+// RedactDSN returns a database DSN, in URL or key=value form, with its
+// password redacted
+func RedactDSN(dsn string) string {
+	if strings.Contains(dsn, "://") {
+		return RedactURL(dsn)
+	}
+	return dsnPasswordPattern.ReplaceAllString(dsn, "${1}="+redactedValue)
+}
+
+// redactURLs redacts each URL
+func redactURLs(raws []string) []string {
+	if len(raws) == 0 {
+		return nil
+	}
+	redacted := make([]string, len(raws))
+	for i, raw := range raws {
+		redacted[i] = RedactURL(raw)
+	}
+	return redacted
+}
@@ -0,0 +1,143 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"sort"
+)
+
+// LintSeverity represents the severity of a lint finding
+type LintSeverity string
+
+const (
+	// LintSeverityError marks settings that are unsafe and must be fixed
+	LintSeverityError LintSeverity = "error"
+	// LintSeverityWarning marks risky or deprecated settings
+	LintSeverityWarning LintSeverity = "warning"
+)
+
+// LintFinding represents a risky or deprecated configuration setting
+type LintFinding struct {
+	Severity LintSeverity `json:"severity"`
+	Path     string       `json:"path"`    // YAML path of the setting, e.g. cors.allow_credentials
+	Message  string       `json:"message"` // What is wrong and how to fix it
+}
+
+// String formats the finding for display
+func (f LintFinding) String() string {
+	return fmt.Sprintf("[%s] %s: %s", f.Severity, f.Path, f.Message)
+}
+
+// Lint reports risky or deprecated settings in a configuration that passes
+// validation. Findings are ordered by severity, errors first.
+func Lint(cfg *Config) []LintFinding {
+	var findings []LintFinding
+	add := func(severity LintSeverity, path, message string) {
+		findings = append(findings, LintFinding{Severity: severity, Path: path, Message: message})
+	}
+
+	// CORS
+	if cfg.CORS.Enabled && cfg.CORS.AllowAllOrigins && cfg.CORS.AllowCredentials {
+		add(LintSeverityError, "cors.allow_credentials",
+			"allow_credentials cannot be combined with allow_all_origins; list trusted origins in allowed_origins instead")
+	}
+	if cfg.GRPCWeb.CORS.Enabled && cfg.GRPCWeb.CORS.AllowCredentials && containsString(cfg.GRPCWeb.CORS.AllowedOrigins, "*") {
+		add(LintSeverityError, "grpc_web.cors.allow_credentials",
+			"allow_credentials with allowed_origins \"*\" lets any site make credentialed requests; list trusted origins explicitly")
+	}
+	if cfg.Portal.Enabled && cfg.Portal.CORS.Enabled && cfg.Portal.CORS.AllowCredentials && containsString(cfg.Portal.CORS.AllowedOrigins, "*") {
+		add(LintSeverityError, "portal.cors.allow_credentials",
+			"allow_credentials with allowed_origins \"*\" lets any site make credentialed requests; list trusted origins explicitly")
+	}
+
+	// TLS verification
+	for _, upstreamID := range sortedKeys(cfg.Proxy.Upstreams) {
+		if tls := cfg.Proxy.Upstreams[upstreamID].TLS; tls.Enabled && tls.InsecureSkipVerify {
+			add(LintSeverityWarning, fmt.Sprintf("proxy.upstreams.%s.tls.insecure_skip_verify", upstreamID),
+				"upstream certificates are not verified; set ca_file to the upstream's CA instead")
+		}
+	}
+	if cfg.GRPCWeb.Enabled {
+		for _, service := range sortedKeys(cfg.GRPCWeb.Services) {
+			if tls := cfg.GRPCWeb.Services[service].TLS; tls.Enabled && tls.InsecureSkipVerify {
+				add(LintSeverityWarning, fmt.Sprintf("grpc_web.services.%s.tls.insecure_skip_verify", service),
+					"backend certificates are not verified; set ca_file to the backend's CA instead")
+			}
+		}
+	}
+
+	// Rate limiting
+	if !cfg.RateLimit.Enabled {
+		for _, listener := range cfg.Server.HTTPListeners() {
+			if isPublicAddress(listener.Address) {
+				add(LintSeverityWarning, "rate_limit.enabled",
+					fmt.Sprintf("server listens on all interfaces (%s) without rate limiting; enable rate_limit to protect upstreams", listener.Address))
+				break
+			}
+		}
+	}
+	if cfg.RateLimit.Enabled {
+		for _, routeID := range sortedKeys(cfg.RateLimit.PerRoute) {
+			if !cfg.RateLimit.PerRoute[routeID].Enabled {
+				add(LintSeverityWarning, fmt.Sprintf("rate_limit.per_route.%s.enabled", routeID),
+					"route has no rate limit; remove the override or enable it with max_requests and window_size")
+			}
+		}
+	}
+
+	// Deprecated settings, the legacy block is enabled by default so only
+	// flag it when it's in effect or customized
+	if legacy := cfg.Metrics.Prometheus; cfg.Metrics.Enabled && legacy.Enabled {
+		if cfg.Metrics.Provider == "" && len(cfg.Metrics.EnabledMetrics) == 0 {
+			add(LintSeverityWarning, "metrics.prometheus",
+				"legacy Prometheus configuration is deprecated; set metrics.provider, namespace and subsystem instead")
+		} else if legacy.Namespace != cfg.Metrics.Namespace || legacy.Subsystem != cfg.Metrics.Subsystem {
+			add(LintSeverityWarning, "metrics.prometheus",
+				"legacy Prometheus configuration is deprecated and ignored when metrics.provider is set; move namespace and subsystem to metrics")
+		}
+	}
+
+	sort.SliceStable(findings, func(i, j int) bool {
+		return findings[i].Severity == LintSeverityError && findings[j].Severity != LintSeverityError
+	})
+	return findings
+}
+
+// HasLintErrors reports whether any finding is an error
+func HasLintErrors(findings []LintFinding) bool {
+	for _, finding := range findings {
+		if finding.Severity == LintSeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// isPublicAddress reports whether a listen address binds all interfaces
+func isPublicAddress(address string) bool {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return false
+	}
+	return host == "" || host == "0.0.0.0" || host == "::"
+}
+
+// containsString reports whether values contains value
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// sortedKeys returns the keys of a map in sorted order
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -2,11 +2,18 @@ package config
 
 import (
 	"fmt"
+	"net"
+	"net/url"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/songzhibin97/stargate/internal/jsonschema"
+	"github.com/songzhibin97/stargate/internal/portal/repository/secret"
+	"github.com/songzhibin97/stargate/pkg/metrics"
+	"github.com/songzhibin97/stargate/pkg/portal"
 	"gopkg.in/yaml.v3"
 )
 
@@ -21,12 +28,23 @@ func Load(configFile string) (*Config, error) {
 			WriteTimeout:   10 * time.Second,
 			IdleTimeout:    60 * time.Second,
 			MaxHeaderBytes: 1048576,
+			HealthPath:     "/health",
+			LameDuckDuration: 5 * time.Second,
+			HTTP2: HTTP2Config{
+				MaxConcurrentStreams: 100,
+				MaxReadFrameSize:     1 << 20,
+			},
 		},
 		Controller: ControllerConfig{
 			Address:      ":9090",
 			Timeout:      30 * time.Second,
 			ReadTimeout:  10 * time.Second,
 			WriteTimeout: 10 * time.Second,
+			LameDuckDuration: 5 * time.Second,
+			HTTP2: HTTP2Config{
+				MaxConcurrentStreams: 100,
+				MaxReadFrameSize:     1 << 20,
+			},
 		},
 		Portal: PortalConfig{
 			Enabled: false,
@@ -70,6 +88,33 @@ func Load(configFile string) (*Config, error) {
 			KeepAliveTimeout:        30 * time.Second,
 			MaxIdleConns:            100,
 			MaxIdleConnsPerHost:     10,
+			CertReloadInterval:      30 * time.Second,
+			DynamicRouting: DynamicRoutingConfig{
+				Enabled:     false,
+				Header:      "X-Route-To",
+				MaxHops:     1,
+				MaxBodySize: 1024 * 1024,
+			},
+			UpstreamOverride: UpstreamOverrideConfig{
+				Enabled:      false,
+				Header:       "X-Stargate-Upstream",
+				SecretHeader: "X-Stargate-Upstream-Secret",
+			},
+			Deadline: RequestDeadlineConfig{
+				Enabled: false,
+				Header:  "X-Request-Timeout",
+			},
+			Retry: RetryConfig{
+				Enabled:     false,
+				Attempts:    2,
+				MaxBodySize: 1024 * 1024,
+			},
+			CacheWarm: CacheWarmConfig{
+				Enabled:     false,
+				Concurrency: 4,
+				Timeout:     10 * time.Second,
+				Deadline:    30 * time.Second,
+			},
 			WebSocket: WebSocketConfig{
 				Enabled:          true,
 				BufferSize:       32768,
@@ -120,11 +165,22 @@ func Load(configFile string) (*Config, error) {
 			JWT: JWTConfig{
 				Algorithm: "HS256",
 				ExpiresIn: 24 * time.Hour,
+				Cache: JWTCacheConfig{
+					MaxEntries: 10000,
+					TTL:        5 * time.Minute,
+				},
+				Revocation: JWTRevocationConfig{
+					RefreshInterval: 30 * time.Second,
+				},
 			},
 			APIKey: APIKeyConfig{
 				Header: "X-API-Key",
 				Query:  "api_key",
 			},
+			Shadow: AuthShadowConfig{
+				Timeout:       time.Second,
+				MaxConcurrent: 100,
+			},
 		},
 		Logging: LoggingConfig{
 			Level:  "info",
@@ -216,6 +272,11 @@ func Load(configFile string) (*Config, error) {
 				Port:    9091,
 			},
 		},
+		Tenancy: TenancyConfig{
+			Enabled:  false,
+			Header:   "X-Stargate-Tenant",
+			JWTClaim: "tenant",
+		},
 		Routes: RoutesConfig{
 			Defaults: RouteDefaults{
 				Timeout:      30 * time.Second,
@@ -261,6 +322,41 @@ func Load(configFile string) (*Config, error) {
 			},
 			PerRoute: make(map[string]HeaderTransformRule),
 		},
+		SecurityHeaders: SecurityHeadersConfig{
+			Enabled:       false,
+			CustomHeaders: make(map[string]string),
+			PerRoute:      make(map[string]SecurityHeadersRoute),
+		},
+		Tap: TapConfig{
+			Enabled:     false,
+			SampleRate:  1.0,
+			MaxBodySize: 64 * 1024,
+			BufferSize:  100,
+			Path:        "/_stargate/tap",
+		},
+		Experiments: ExperimentsConfig{
+			Enabled:     false,
+			Experiments: []Experiment{},
+		},
+		Idempotency: IdempotencyConfig{
+			Enabled:         false,
+			Methods:         []string{"POST", "PUT", "PATCH", "DELETE"},
+			Header:          "Idempotency-Key",
+			TTL:             24 * time.Hour,
+			LockTimeout:     30 * time.Second,
+			MaxBodySize:     1024 * 1024,
+			MaxResponseSize: 1024 * 1024,
+			Storage:         "memory",
+		},
+		BodyChecksum: BodyChecksumConfig{
+			Enabled:     false,
+			MaxBodySize: 1024 * 1024,
+		},
+		SchemaValidation: SchemaValidationConfig{
+			Enabled:     false,
+			Routes:      make(map[string]RouteSchemaConfig),
+			MaxBodySize: 1024 * 1024,
+		},
 		MockResponse: MockResponseConfig{
 			Enabled:  false,
 			Rules:    []MockRule{},
@@ -300,6 +396,12 @@ func Load(configFile string) (*Config, error) {
 		return nil, fmt.Errorf("failed to load config from environment: %w", err)
 	}
 
+	// Resolve secret references in sensitive fields
+	cfg.secrets = NewSecretResolver()
+	if err := cfg.secrets.ResolveConfig(cfg); err != nil {
+		return nil, fmt.Errorf("failed to resolve secrets: %w", err)
+	}
+
 	// Validate configuration
 	if err := validate(cfg); err != nil {
 		return nil, fmt.Errorf("configuration validation failed: %w", err)
@@ -377,10 +479,83 @@ func loadFromEnv(cfg *Config) error {
 // validate validates the configuration
 func validate(cfg *Config) error {
 	// Validate server address
-	if cfg.Server.Address == "" {
+	if len(cfg.Server.Listeners) == 0 && cfg.Server.Address == "" {
 		return fmt.Errorf("server address cannot be empty")
 	}
 
+	// Validate HTTP listeners and their profiles
+	if err := validateListeners(&cfg.Server); err != nil {
+		return err
+	}
+
+	// Validate PROXY protocol trusted sources
+	if cfg.Server.ProxyProtocol.Enabled {
+		if len(cfg.Server.ProxyProtocol.TrustedSources) == 0 {
+			return fmt.Errorf("proxy protocol trusted sources cannot be empty when proxy protocol is enabled")
+		}
+		for _, source := range cfg.Server.ProxyProtocol.TrustedSources {
+			if _, _, err := net.ParseCIDR(source); err != nil && net.ParseIP(source) == nil {
+				return fmt.Errorf("invalid proxy protocol trusted source: %s", source)
+			}
+		}
+	}
+
+	// Validate lame-duck periods
+	if cfg.Server.LameDuckDuration < 0 || cfg.Controller.LameDuckDuration < 0 {
+		return fmt.Errorf("lame duck duration cannot be negative")
+	}
+
+	// Validate HTTP/2 settings
+	if err := validateHTTP2("server", &cfg.Server.HTTP2); err != nil {
+		return err
+	}
+	if err := validateHTTP2("controller", &cfg.Controller.HTTP2); err != nil {
+		return err
+	}
+
+	// Validate controller leader election
+	if cfg.Controller.LeaderElection.Enabled {
+		if err := validateLeaderElection(&cfg.Controller.LeaderElection, cfg.Store.Type); err != nil {
+			return fmt.Errorf("invalid controller leader election: %w", err)
+		}
+	}
+
+	// Validate the portal scope allowlist
+	if err := portal.ValidateScopes(cfg.Portal.Scopes, nil); err != nil {
+		return fmt.Errorf("invalid portal scopes: %w", err)
+	}
+
+	// Validate the portal API secret hashing
+	if _, err := secret.NewHasher(cfg.Portal.Repository.Postgres.SecretHashAlgorithm); err != nil {
+		return fmt.Errorf("invalid portal repository: %w", err)
+	}
+
+	// Validate secret refresh
+	if cfg.Secrets.RefreshInterval < 0 {
+		return fmt.Errorf("secret refresh interval cannot be negative")
+	}
+
+	// Validate health check startup behavior
+	if cfg.LoadBalancer.HealthCheck.StartupGracePeriod < 0 {
+		return fmt.Errorf("health check startup grace period cannot be negative")
+	}
+	if cfg.LoadBalancer.HealthCheck.StartupGracePeriod > 0 && cfg.LoadBalancer.HealthCheck.WaitForFirstCheck {
+		return fmt.Errorf("health check startup grace period and wait for first check are mutually exclusive")
+	}
+
+	// Validate the tap, whose captures of request and response bodies are
+	// served on the proxy listeners
+	if cfg.Tap.Enabled && !cfg.AdminAuthConfigured() {
+		return fmt.Errorf("tap requires admin API authentication enabled with credentials")
+	}
+
+	// Validate trusted proxies
+	for _, proxy := range cfg.Server.TrustedProxies {
+		if _, _, err := net.ParseCIDR(proxy); err != nil && net.ParseIP(proxy) == nil {
+			return fmt.Errorf("invalid trusted proxy: %s", proxy)
+		}
+	}
+
 	// Validate store configuration
 	if cfg.Store.Type == "" {
 		return fmt.Errorf("store type cannot be empty")
@@ -397,6 +572,72 @@ func validate(cfg *Config) error {
 		return fmt.Errorf("JWT secret cannot be empty when auth is enabled")
 	}
 
+	// Validate JWT cache and revocation list
+	if cfg.Auth.JWT.Cache.MaxEntries < 0 || cfg.Auth.JWT.Cache.TTL < 0 {
+		return fmt.Errorf("JWT cache max entries and ttl cannot be negative")
+	}
+	if cfg.Auth.JWT.Revocation.RefreshInterval < 0 {
+		return fmt.Errorf("JWT revocation refresh interval cannot be negative")
+	}
+
+	// Validate OAuth 2.0 introspection retry backoff
+	oauth2 := cfg.Auth.OAuth2
+	if oauth2.RetryMultiplier != 0 && oauth2.RetryMultiplier < 1 {
+		return fmt.Errorf("OAuth2 retry multiplier must be at least 1")
+	}
+	if oauth2.RetryJitter < 0 || oauth2.RetryJitter > 1 {
+		return fmt.Errorf("OAuth2 retry jitter must be between 0 and 1")
+	}
+	if oauth2.MaxRetryDelay < 0 || oauth2.MaxElapsedTime < 0 {
+		return fmt.Errorf("OAuth2 max retry delay and max elapsed time cannot be negative")
+	}
+
+	// Validate shadow auth
+	if shadow := cfg.Auth.Shadow; shadow.Enabled {
+		if shadow.JWT.Secret == "" && shadow.JWT.PublicKey == "" && shadow.JWT.JWKSURL == "" &&
+			shadow.APIKey.Header == "" && shadow.APIKey.Query == "" && shadow.OAuth2.IntrospectionURL == "" {
+			return fmt.Errorf("shadow auth must configure JWT, API key or OAuth2 authentication")
+		}
+		if shadow.Timeout <= 0 {
+			return fmt.Errorf("shadow auth timeout must be positive")
+		}
+		if shadow.MaxConcurrent <= 0 {
+			return fmt.Errorf("shadow auth max concurrent must be positive")
+		}
+	}
+
+	// Validate rate limit identifier
+	if err := validateIdentifierStrategy(cfg.RateLimit.IdentifierStrategy); err != nil {
+		return fmt.Errorf("invalid rate limit identifier strategy: %w", err)
+	}
+
+	// Validate rate limit storage
+	if err := validateRateLimitStorage(&cfg.RateLimit); err != nil {
+		return err
+	}
+
+	// Validate per-route access logging
+	for routeID, route := range cfg.Logging.AccessLog.Routes {
+		if route.SampleRate != nil && (*route.SampleRate < 0 || *route.SampleRate > 1) {
+			return fmt.Errorf("access log sample rate of route %s must be between 0 and 1", routeID)
+		}
+	}
+
+	// Validate body checksum limit
+	if cfg.BodyChecksum.MaxBodySize < 0 {
+		return fmt.Errorf("body checksum max body size cannot be negative")
+	}
+
+	// Validate JSON schemas by compiling them
+	if cfg.SchemaValidation.MaxBodySize < 0 {
+		return fmt.Errorf("schema validation max body size cannot be negative")
+	}
+	for routeID, route := range cfg.SchemaValidation.Routes {
+		if _, _, err := CompileRouteSchemas(route); err != nil {
+			return fmt.Errorf("invalid schema of route %s: %w", routeID, err)
+		}
+	}
+
 	// Validate log level
 	validLogLevels := map[string]bool{
 		"debug": true,
@@ -411,17 +652,752 @@ func validate(cfg *Config) error {
 
 	// Validate load balancer algorithm
 	validAlgorithms := map[string]bool{
-		"round_robin": true,
-		"weighted":    true,
-		"ip_hash":     true,
+		"round_robin":     true,
+		"weighted":        true,
+		"weighted_random": true,
+		"ip_hash":         true,
 	}
 	if !validAlgorithms[cfg.LoadBalancer.DefaultAlgorithm] {
 		return fmt.Errorf("invalid load balancer algorithm: %s", cfg.LoadBalancer.DefaultAlgorithm)
 	}
 
+	// Validate no-route behavior
+	if err := validateNoRoute(&cfg.Proxy.NoRoute); err != nil {
+		return err
+	}
+	if cfg.Proxy.Root.Enabled {
+		if err := validateRootPage(&cfg.Proxy.Root); err != nil {
+			return err
+		}
+	}
+
+	// Validate dynamic routing hop limit
+	if cfg.Proxy.DynamicRouting.Enabled && (cfg.Proxy.DynamicRouting.MaxHops < 0 || cfg.Proxy.DynamicRouting.MaxHops > MaxDynamicRoutingHops) {
+		return fmt.Errorf("dynamic routing max hops must be between 1 and %d", MaxDynamicRoutingHops)
+	}
+
+	// Validate upstream override trust
+	if cfg.Proxy.UpstreamOverride.Enabled {
+		if len(cfg.Proxy.UpstreamOverride.TrustedSources) == 0 && cfg.Proxy.UpstreamOverride.Secret == "" {
+			return fmt.Errorf("upstream override requires trusted sources or a secret")
+		}
+		for _, source := range cfg.Proxy.UpstreamOverride.TrustedSources {
+			if _, _, err := net.ParseCIDR(source); err != nil && net.ParseIP(source) == nil {
+				return fmt.Errorf("invalid upstream override trusted source: %s", source)
+			}
+		}
+	}
+
+	// Validate request deadlines
+	if cfg.Proxy.Deadline.Enabled {
+		if err := validateRequestDeadline(&cfg.Proxy.Deadline); err != nil {
+			return err
+		}
+	}
+
+	// Validate tenants
+	if cfg.Tenancy.Enabled {
+		if err := validateTenancy(&cfg.Tenancy); err != nil {
+			return err
+		}
+	}
+
+	// Validate upstream retries
+	if cfg.Proxy.Retry.Enabled {
+		if err := validateRetry(&cfg.Proxy.Retry); err != nil {
+			return err
+		}
+	}
+
+	// Validate cache warming
+	if cfg.Proxy.CacheWarm.Enabled {
+		if err := validateCacheWarm(&cfg.Proxy.CacheWarm); err != nil {
+			return err
+		}
+	}
+
+	// Validate per-upstream passive health thresholds
+	for upstreamID, passive := range cfg.Upstreams.Passive {
+		if passive.ConsecutiveFailures < 0 || passive.ConsecutiveSuccesses < 0 || passive.IsolationDuration < 0 {
+			return fmt.Errorf("passive health thresholds of upstream %s cannot be negative", upstreamID)
+		}
+		for _, code := range passive.FailureStatusCodes {
+			if code < 100 || code > 599 {
+				return fmt.Errorf("invalid passive health failure status code %d for upstream %s", code, upstreamID)
+			}
+		}
+	}
+
+	// Validate per-upstream concurrency limits
+	for upstreamID, concurrency := range cfg.Upstreams.Concurrency {
+		if concurrency.MaxConcurrent <= 0 {
+			return fmt.Errorf("max_concurrent of upstream %s must be positive", upstreamID)
+		}
+		if concurrency.QueueSize < 0 || concurrency.QueueTimeout < 0 {
+			return fmt.Errorf("queue settings of upstream %s cannot be negative", upstreamID)
+		}
+	}
+
+	// Validate session affinity
+	for upstreamID, affinity := range cfg.Upstreams.Affinity {
+		if affinity.TTL < 0 {
+			return fmt.Errorf("session affinity ttl of upstream %s cannot be negative", upstreamID)
+		}
+		switch strings.ToLower(affinity.SameSite) {
+		case "", "lax", "strict":
+		case "none":
+			if !affinity.Secure {
+				return fmt.Errorf("session affinity cookie of upstream %s with same_site none must be secure", upstreamID)
+			}
+		default:
+			return fmt.Errorf("invalid session affinity same_site %q of upstream %s, must be lax, strict or none", affinity.SameSite, upstreamID)
+		}
+	}
+
+	// Validate upstream priority tiers
+	priority := cfg.Upstreams.Priority
+	tiers := make(map[string]bool, len(priority.Tiers))
+	for _, tier := range priority.Tiers {
+		if tier == "" {
+			return fmt.Errorf("upstream priority tiers cannot be empty")
+		}
+		if tiers[tier] {
+			return fmt.Errorf("duplicate upstream priority tier %s", tier)
+		}
+		tiers[tier] = true
+	}
+	if priority.DefaultTier != "" && !tiers[priority.DefaultTier] {
+		return fmt.Errorf("upstream priority default tier %s is not one of the tiers", priority.DefaultTier)
+	}
+
+	// Validate upstream debug logging
+	debugLog := cfg.Upstreams.DebugLog
+	if debugLog.DefaultDuration < 0 || debugLog.MaxDuration < 0 || debugLog.MaxBodySize < 0 {
+		return fmt.Errorf("upstream debug log durations and max body size cannot be negative")
+	}
+	maxDebugDuration := debugLog.MaxDuration
+	if maxDebugDuration == 0 {
+		maxDebugDuration = time.Hour
+	}
+	if debugLog.DefaultDuration > maxDebugDuration {
+		return fmt.Errorf("upstream debug log default duration cannot exceed its max duration of %v", maxDebugDuration)
+	}
+
+	// Validate connection limits
+	if err := validateConnectionLimit("rate_limit.connections", &cfg.RateLimit.Connections); err != nil {
+		return err
+	}
+	for routeID, limit := range cfg.RateLimit.Connections.PerRoute {
+		if err := validateConnectionLimit("rate_limit.connections.per_route."+routeID, &limit); err != nil {
+			return err
+		}
+	}
+
+	// Validate stream listeners
+	if err := validateStreamListeners(&cfg.Stream); err != nil {
+		return err
+	}
+
+	// Validate plugin phases and references
+	if cfg.WASM.Enabled {
+		if err := validateWASMPlugins(&cfg.WASM); err != nil {
+			return err
+		}
+	}
+	if cfg.Serverless.Enabled {
+		if err := validateServerlessRules(&cfg.Serverless); err != nil {
+			return err
+		}
+	}
+
+	// Validate metrics route groups
+	if err := validateMetricsRouteGroups(&cfg.Metrics); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// validateMetricsRouteGroups validates the route groups recording request
+// metrics of their own, whose subsystems must be distinct and whose routes
+// belong to a single group
+func validateMetricsRouteGroups(cfg *MetricsConfig) error {
+	subsystems := make(map[string]string, len(cfg.RouteGroups))
+	groupOf := make(map[string]string)
+	for name, group := range cfg.RouteGroups {
+		if group.Subsystem == "" {
+			return fmt.Errorf("subsystem of metrics route group %s cannot be empty", name)
+		}
+		if err := metrics.ValidateMetricName(group.Subsystem); err != nil {
+			return fmt.Errorf("invalid subsystem of metrics route group %s: %w", name, err)
+		}
+		if other, exists := subsystems[group.Subsystem]; exists {
+			return fmt.Errorf("metrics route groups %s and %s have the same subsystem %s", other, name, group.Subsystem)
+		}
+		subsystems[group.Subsystem] = name
+
+		if len(group.Routes) == 0 {
+			return fmt.Errorf("metrics route group %s has no routes", name)
+		}
+		for _, routeID := range group.Routes {
+			if other, exists := groupOf[routeID]; exists && other != name {
+				return fmt.Errorf("route %s belongs to metrics route groups %s and %s", routeID, other, name)
+			}
+			groupOf[routeID] = name
+		}
+
+		for label, value := range group.Labels {
+			if err := metrics.ValidateLabelName(label); err != nil {
+				return fmt.Errorf("invalid label name %s of metrics route group %s: %w", label, name, err)
+			}
+			if value == "" {
+				return fmt.Errorf("label %s of metrics route group %s cannot have empty value", label, name)
+			}
+			if _, extracted := cfg.LabelExtractors[label]; extracted || isRequestMetricLabel(label) {
+				return fmt.Errorf("label %s of metrics route group %s is already a request metric label", label, name)
+			}
+		}
+	}
+	return nil
+}
+
+// isRequestMetricLabel reports whether a label is a variable label of the
+// HTTP request metrics
+func isRequestMetricLabel(label string) bool {
+	switch label {
+	case "method", "route", "status_code", "error_type", "consumer_id":
+		return true
+	}
+	return false
+}
+
+// validateRateLimitStorage validates the rate limit storage backend and its
+// settings
+func validateRateLimitStorage(cfg *RateLimitConfig) error {
+	switch cfg.Storage {
+	case "", "memory", "redis":
+		return nil
+	case "dynamodb":
+		if cfg.DynamoDB.Table == "" {
+			return fmt.Errorf("rate limit dynamodb table is required")
+		}
+		if cfg.DynamoDB.Endpoint != "" {
+			if u, err := url.Parse(cfg.DynamoDB.Endpoint); err != nil || u.Scheme == "" || u.Host == "" {
+				return fmt.Errorf("invalid rate limit dynamodb endpoint: %s", cfg.DynamoDB.Endpoint)
+			}
+		}
+		if (cfg.DynamoDB.AccessKeyID == "") != (cfg.DynamoDB.SecretAccessKey == "") {
+			return fmt.Errorf("rate limit dynamodb access key ID and secret access key must be set together")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported rate limit storage: %s", cfg.Storage)
+	}
+}
+
+// validateIdentifierStrategy validates a rate limit identifier strategy, one
+// of ip, user, api_key and combined, or identifier components joined with +
+func validateIdentifierStrategy(strategy string) error {
+	switch strategy {
+	case "", "ip", "user", "api_key", "combined":
+		return nil
+	}
+
+	seen := make(map[string]bool)
+	for _, component := range strings.Split(strategy, "+") {
+		component = strings.TrimSpace(component)
+		switch {
+		case component == "ip", component == "user", component == "api_key", component == "route_id":
+		case strings.HasPrefix(component, "header:") && len(component) > len("header:"):
+		default:
+			return fmt.Errorf("unknown identifier component %q", component)
+		}
+		if seen[strings.ToLower(component)] {
+			return fmt.Errorf("identifier component %q is repeated", component)
+		}
+		seen[strings.ToLower(component)] = true
+	}
+	return nil
+}
+
+// validateListeners validates HTTP listener names, addresses, TLS and
+// profile references, and the middlewares selected by profiles
+func validateListeners(cfg *ServerConfig) error {
+	for name, profile := range cfg.Profiles {
+		for _, middleware := range profile.Middlewares {
+			if !containsString(ListenerMiddlewares, middleware) {
+				return fmt.Errorf("listener profile %s: unknown middleware: %s", name, middleware)
+			}
+		}
+	}
+
+	names := make(map[string]bool, len(cfg.Listeners))
+	addresses := make(map[string]bool, len(cfg.Listeners))
+	for i, listener := range cfg.Listeners {
+		if listener.Name == "" {
+			return fmt.Errorf("listener %d: name cannot be empty", i)
+		}
+		if names[listener.Name] {
+			return fmt.Errorf("duplicate listener name: %s", listener.Name)
+		}
+		names[listener.Name] = true
+
+		if listener.Address == "" {
+			return fmt.Errorf("listener %s: address cannot be empty", listener.Name)
+		}
+		if _, _, err := net.SplitHostPort(listener.Address); err != nil {
+			return fmt.Errorf("listener %s: invalid address %s: %w", listener.Name, listener.Address, err)
+		}
+		if addresses[listener.Address] {
+			return fmt.Errorf("listener %s: address %s already in use", listener.Name, listener.Address)
+		}
+		addresses[listener.Address] = true
+
+		if listener.TLS.Enabled && !listener.TLS.ACME.Enabled && (listener.TLS.CertFile == "" || listener.TLS.KeyFile == "") {
+			return fmt.Errorf("listener %s: tls requires cert_file and key_file or acme", listener.Name)
+		}
+		if listener.Profile != "" {
+			if _, exists := cfg.Profiles[listener.Profile]; !exists {
+				return fmt.Errorf("listener %s: unknown profile: %s", listener.Name, listener.Profile)
+			}
+		}
+	}
+
+	return nil
+}
+
+// validateStreamListeners validates stream listener names, protocols and
+// addresses, defaulting the protocol to TCP
+func validateStreamListeners(cfg *StreamConfig) error {
+	names := make(map[string]bool, len(cfg.Listeners))
+	addresses := make(map[string]bool, len(cfg.Listeners))
+	for i := range cfg.Listeners {
+		listener := &cfg.Listeners[i]
+		if listener.Name == "" {
+			return fmt.Errorf("stream listener %d: name cannot be empty", i)
+		}
+		if names[listener.Name] {
+			return fmt.Errorf("duplicate stream listener name: %s", listener.Name)
+		}
+		names[listener.Name] = true
+
+		if listener.Protocol == "" {
+			listener.Protocol = StreamProtocolTCP
+		}
+		if listener.Protocol != StreamProtocolTCP && listener.Protocol != StreamProtocolUDP {
+			return fmt.Errorf("stream listener %s: invalid protocol: %s", listener.Name, listener.Protocol)
+		}
+		if listener.Address == "" {
+			return fmt.Errorf("stream listener %s: address cannot be empty", listener.Name)
+		}
+		if _, _, err := net.SplitHostPort(listener.Address); err != nil {
+			return fmt.Errorf("stream listener %s: invalid address %s: %w", listener.Name, listener.Address, err)
+		}
+		key := listener.Protocol + "/" + listener.Address
+		if addresses[key] {
+			return fmt.Errorf("stream listener %s: %s address %s already in use", listener.Name, listener.Protocol, listener.Address)
+		}
+		addresses[key] = true
+
+		if listener.UpstreamID == "" {
+			return fmt.Errorf("stream listener %s: upstream id cannot be empty", listener.Name)
+		}
+
+		if listener.ConnectionLimit != nil {
+			if err := validateConnectionLimit("stream listener "+listener.Name+" connection limit", listener.ConnectionLimit); err != nil {
+				return err
+			}
+		}
+	}
+
 	return nil
 }
 
+// validateLeaderElection validates the election of the active controller
+func validateLeaderElection(cfg *LeaderElectionConfig, storeType string) error {
+	switch cfg.Backend {
+	case "etcd":
+		if storeType != "etcd" {
+			return fmt.Errorf("the etcd backend requires store type etcd")
+		}
+	case "redis":
+		if cfg.Redis.Address == "" {
+			return fmt.Errorf("redis address is required for the redis backend")
+		}
+	default:
+		return fmt.Errorf("invalid backend %q (valid options: etcd, redis)", cfg.Backend)
+	}
+
+	if cfg.LeaseTTL < 0 || cfg.RenewInterval < 0 {
+		return fmt.Errorf("lease TTL and renew interval cannot be negative")
+	}
+	if cfg.LeaseTTL > 0 && cfg.LeaseTTL < time.Second {
+		return fmt.Errorf("lease TTL must be at least 1s")
+	}
+	leaseTTL := cfg.LeaseTTL
+	if leaseTTL == 0 {
+		leaseTTL = 15 * time.Second
+	}
+	if cfg.RenewInterval >= leaseTTL {
+		return fmt.Errorf("renew interval must be shorter than the lease TTL of %v", leaseTTL)
+	}
+
+	switch cfg.StandbyWrites {
+	case "", "reject":
+	case "proxy":
+		if cfg.AdvertiseAddress == "" {
+			return fmt.Errorf("advertise address is required to proxy standby writes to the leader")
+		}
+	default:
+		return fmt.Errorf("invalid standby writes %q (valid options: reject, proxy)", cfg.StandbyWrites)
+	}
+	if cfg.AdvertiseAddress != "" {
+		if u, err := url.Parse(cfg.AdvertiseAddress); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("advertise address must be an http or https URL")
+		}
+	}
+	return nil
+}
+
+// validateHTTP2 validates HTTP/2 server settings against the limits of RFC 9113
+func validateHTTP2(name string, cfg *HTTP2Config) error {
+	if cfg.MaxConcurrentStreams == 0 {
+		return fmt.Errorf("%s http2 max concurrent streams must be positive", name)
+	}
+	if cfg.MaxReadFrameSize < 1<<14 || cfg.MaxReadFrameSize > 1<<24-1 {
+		return fmt.Errorf("%s http2 max read frame size must be between 16384 and 16777215, got %d", name, cfg.MaxReadFrameSize)
+	}
+	if cfg.IdleTimeout < 0 {
+		return fmt.Errorf("%s http2 idle timeout cannot be negative", name)
+	}
+	return nil
+}
+
+// validateConnectionLimit validates connection limit values and exemptions
+func validateConnectionLimit(name string, cfg *ConnectionLimitConfig) error {
+	if cfg.MaxPerIP < 0 || cfg.NewPerSecond < 0 || cfg.Burst < 0 {
+		return fmt.Errorf("%s: limits cannot be negative", name)
+	}
+	for _, source := range cfg.ExemptCIDRs {
+		if _, _, err := net.ParseCIDR(source); err != nil && net.ParseIP(source) == nil {
+			return fmt.Errorf("%s: invalid exempt CIDR: %s", name, source)
+		}
+	}
+	return nil
+}
+
+// validateWASMPlugins validates plugin IDs, phases and rule references
+func validateWASMPlugins(cfg *WASMConfig) error {
+	plugins := make(map[string]bool, len(cfg.Plugins))
+	for _, plugin := range cfg.Plugins {
+		if plugin.ID == "" {
+			return fmt.Errorf("wasm plugin %s: id cannot be empty", plugin.Name)
+		}
+		if plugins[plugin.ID] {
+			return fmt.Errorf("duplicate wasm plugin id: %s", plugin.ID)
+		}
+		plugins[plugin.ID] = true
+
+		switch plugin.Phase {
+		case "", PluginPhaseAuth, PluginPhaseRequest, PluginPhaseResponse:
+		default:
+			return fmt.Errorf("wasm plugin %s: invalid phase: %s", plugin.ID, plugin.Phase)
+		}
+	}
+
+	for _, rule := range cfg.Rules {
+		referenced := make(map[string]bool, len(rule.Plugins))
+		for _, pluginID := range rule.Plugins {
+			if !plugins[pluginID] {
+				return fmt.Errorf("wasm rule %s: unknown plugin: %s", rule.ID, pluginID)
+			}
+			if referenced[pluginID] {
+				return fmt.Errorf("wasm rule %s: plugin %s listed more than once", rule.ID, pluginID)
+			}
+			referenced[pluginID] = true
+		}
+	}
+
+	return nil
+}
+
+// validateServerlessRules validates function IDs and phases of serverless rules
+func validateServerlessRules(cfg *ServerlessConfig) error {
+	for _, rule := range cfg.Rules {
+		functions := make(map[string]bool, len(rule.PreProcess)+len(rule.PostProcess))
+
+		for _, fn := range rule.PreProcess {
+			if functions[fn.ID] {
+				return fmt.Errorf("serverless rule %s: duplicate function id: %s", rule.ID, fn.ID)
+			}
+			functions[fn.ID] = true
+
+			switch fn.Phase {
+			case "", PluginPhaseAuth, PluginPhaseRequest:
+			default:
+				return fmt.Errorf("serverless rule %s: invalid pre-process phase for function %s: %s", rule.ID, fn.ID, fn.Phase)
+			}
+		}
+
+		for _, fn := range rule.PostProcess {
+			if functions[fn.ID] {
+				return fmt.Errorf("serverless rule %s: duplicate function id: %s", rule.ID, fn.ID)
+			}
+			functions[fn.ID] = true
+
+			if fn.Phase != "" && fn.Phase != PluginPhaseResponse {
+				return fmt.Errorf("serverless rule %s: invalid post-process phase for function %s: %s", rule.ID, fn.ID, fn.Phase)
+			}
+		}
+	}
+
+	return nil
+}
+
+// ParseNoRouteAction splits a no-route action into its mode, one of error,
+// redirect, upstream and static, and the redirect URL or upstream ID
+func ParseNoRouteAction(action string) (string, string, error) {
+	if action == "" {
+		return "error", "", nil
+	}
+
+	mode, arg, _ := strings.Cut(action, ":")
+	switch mode {
+	case "error", "static":
+		if arg != "" {
+			return "", "", fmt.Errorf("no-route action %s takes no argument", mode)
+		}
+	case "redirect", "upstream":
+		if arg == "" {
+			return "", "", fmt.Errorf("no-route action %s requires a target, such as %s:<target>", mode, mode)
+		}
+	default:
+		return "", "", fmt.Errorf("invalid no-route action: %s", action)
+	}
+	return mode, arg, nil
+}
+
+// validateRootPage validates the response to requests for the gateway root
+func validateRootPage(cfg *RootPageConfig) error {
+	for _, path := range cfg.Paths {
+		if !strings.HasPrefix(path, "/") {
+			return fmt.Errorf("root page path must start with /: %s", path)
+		}
+	}
+
+	if cfg.Redirect != "" {
+		if cfg.Body != "" || cfg.File != "" {
+			return fmt.Errorf("root page redirect cannot be combined with a body or file")
+		}
+		if u, err := url.Parse(cfg.Redirect); err != nil || (u.Scheme == "" && !strings.HasPrefix(u.Path, "/")) {
+			return fmt.Errorf("root page redirect target must be an absolute URL or path: %s", cfg.Redirect)
+		}
+		if cfg.StatusCode != 0 && (cfg.StatusCode < 300 || cfg.StatusCode > 399) {
+			return fmt.Errorf("root page redirect status code must be 3xx, got %d", cfg.StatusCode)
+		}
+		return nil
+	}
+
+	if cfg.Body != "" && cfg.File != "" {
+		return fmt.Errorf("root page body and file cannot both be set")
+	}
+	if cfg.File != "" {
+		if _, err := os.Stat(cfg.File); err != nil {
+			return fmt.Errorf("invalid root page file: %w", err)
+		}
+	}
+	if cfg.StatusCode != 0 && (cfg.StatusCode < 200 || cfg.StatusCode > 599) {
+		return fmt.Errorf("invalid root page status code: %d", cfg.StatusCode)
+	}
+	return nil
+}
+
+// validateRequestDeadline validates the overall deadline of requests
+func validateRequestDeadline(cfg *RequestDeadlineConfig) error {
+	if cfg.Timeout < 0 {
+		return fmt.Errorf("request deadline timeout cannot be negative")
+	}
+	for routeID, timeout := range cfg.PerRoute {
+		if timeout <= 0 {
+			return fmt.Errorf("request deadline timeout of route %s must be positive", routeID)
+		}
+	}
+	for _, source := range cfg.TrustedSources {
+		if _, _, err := net.ParseCIDR(source); err != nil && net.ParseIP(source) == nil {
+			return fmt.Errorf("invalid request deadline trusted source: %s", source)
+		}
+	}
+	return nil
+}
+
+// validateTenancy validates tenant names and that hosts and Admin API keys
+// belong to a single tenant
+func validateTenancy(cfg *TenancyConfig) error {
+	if cfg.Header == "" {
+		return fmt.Errorf("tenancy header cannot be empty")
+	}
+	hosts := make(map[string]string)
+	keys := make(map[string]string)
+	for name, tenant := range cfg.Tenants {
+		if !ValidTenantName(name) {
+			return fmt.Errorf("invalid tenant name %q, must be lowercase letters, digits, - and _", name)
+		}
+		for _, host := range tenant.Hosts {
+			host = strings.ToLower(host)
+			if other, exists := hosts[host]; exists {
+				return fmt.Errorf("host %s belongs to tenants %s and %s", host, other, name)
+			}
+			hosts[host] = name
+		}
+		for _, key := range tenant.APIKeys {
+			if key == "" {
+				return fmt.Errorf("empty API key of tenant %s", name)
+			}
+			if other, exists := keys[key]; exists {
+				return fmt.Errorf("an API key is bound to tenants %s and %s", other, name)
+			}
+			keys[key] = name
+		}
+	}
+	return nil
+}
+
+// validateRetry validates upstream retries and the retry policies of routes
+func validateRetry(cfg *RetryConfig) error {
+	if cfg.Attempts < 0 || cfg.Attempts > MaxRetryAttempts {
+		return fmt.Errorf("retry attempts must be between 0 and %d", MaxRetryAttempts)
+	}
+	if cfg.Backoff < 0 {
+		return fmt.Errorf("retry backoff cannot be negative")
+	}
+	if cfg.MaxBodySize < 0 {
+		return fmt.Errorf("retry max body size cannot be negative")
+	}
+	for routeID, retryOn := range cfg.PerRoute {
+		for _, method := range retryOn.Methods {
+			if method != "*" && !isMethodName(method) {
+				return fmt.Errorf("invalid retry method of route %s: %q", routeID, method)
+			}
+		}
+		for _, condition := range retryOn.Conditions {
+			if _, _, err := ParseRetryCondition(condition); err != nil {
+				return fmt.Errorf("route %s: %w", routeID, err)
+			}
+		}
+	}
+	return nil
+}
+
+// validateCacheWarm validates the limits and URLs of cache warming
+func validateCacheWarm(cfg *CacheWarmConfig) error {
+	if cfg.Concurrency <= 0 {
+		return fmt.Errorf("cache warm concurrency must be positive")
+	}
+	if cfg.Timeout <= 0 {
+		return fmt.Errorf("cache warm timeout must be positive")
+	}
+	if cfg.Deadline <= 0 {
+		return fmt.Errorf("cache warm deadline must be positive")
+	}
+	for routeID, route := range cfg.PerRoute {
+		for _, rawURL := range route.URLs {
+			u, err := url.Parse(rawURL)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return fmt.Errorf("invalid cache warm URL of route %s: %q, must be an absolute http or https URL", routeID, rawURL)
+			}
+		}
+	}
+	return nil
+}
+
+// ParseRetryCondition parses a retry condition into whether it's the
+// connection error condition or else the response status it names
+func ParseRetryCondition(condition string) (bool, int, error) {
+	if condition == RetryConditionConnectError {
+		return true, 0, nil
+	}
+	status, err := strconv.Atoi(condition)
+	if err != nil || status < 400 || status > 599 {
+		return false, 0, fmt.Errorf("invalid retry condition %q, must be %s or a 4xx or 5xx status code", condition, RetryConditionConnectError)
+	}
+	return false, status, nil
+}
+
+// isMethodName reports whether s looks like an HTTP method name
+func isMethodName(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if (c < 'A' || c > 'Z') && (c < 'a' || c > 'z') {
+			return false
+		}
+	}
+	return true
+}
+
+// validateNoRoute validates the response to requests matching no route
+func validateNoRoute(cfg *NoRouteConfig) error {
+	mode, target, err := ParseNoRouteAction(cfg.Action)
+	if err != nil {
+		return err
+	}
+
+	switch mode {
+	case "redirect":
+		if u, err := url.Parse(target); err != nil || (u.Scheme == "" && !strings.HasPrefix(u.Path, "/")) {
+			return fmt.Errorf("no-route redirect target must be an absolute URL or path: %s", target)
+		}
+		if cfg.StatusCode != 0 && (cfg.StatusCode < 300 || cfg.StatusCode > 399) {
+			return fmt.Errorf("no-route redirect status code must be 3xx, got %d", cfg.StatusCode)
+		}
+	case "static":
+		if cfg.StatusCode != 0 && (cfg.StatusCode < 200 || cfg.StatusCode > 599) {
+			return fmt.Errorf("invalid no-route status code: %d", cfg.StatusCode)
+		}
+	}
+	return nil
+}
+
+// CompileRouteSchemas compiles the request and response schemas of a route,
+// returning nil for a schema that isn't configured
+func CompileRouteSchemas(cfg RouteSchemaConfig) (*jsonschema.Schema, *jsonschema.Schema, error) {
+	request, err := compileSchema("request", cfg.Request, cfg.RequestFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	response, err := compileSchema("response", cfg.Response, cfg.ResponseFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	return request, response, nil
+}
+
+// compileSchema compiles a schema given inline or as a file
+func compileSchema(name string, inline map[string]interface{}, file string) (*jsonschema.Schema, error) {
+	switch {
+	case inline != nil && file != "":
+		return nil, fmt.Errorf("%s schema cannot be both inline and a file", name)
+	case inline != nil:
+		schema, err := jsonschema.Compile(inline)
+		if err != nil {
+			return nil, fmt.Errorf("%s schema: %w", name, err)
+		}
+		return schema, nil
+	case file != "":
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s schema: %w", name, err)
+		}
+		schema, err := jsonschema.Parse(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s schema %s: %w", name, file, err)
+		}
+		return schema, nil
+	}
+	return nil, nil
+}
+
 // GetConfigDir returns the configuration directory
 func GetConfigDir() string {
 	if dir := os.Getenv("STARGATE_CONFIG_DIR"); dir != "" {
@@ -0,0 +1,346 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// SecretReference is a reference to a secret held outside the configuration,
+// written as scheme://path or scheme://path#key, such as
+// "env://STARGATE_JWT_SECRET" or "vault://secret/gateway#jwt"
+type SecretReference struct {
+	Scheme string // Selects the SecretProvider
+	Path   string
+	Key    string // Field within the secret, optional
+}
+
+// String returns the reference as written in the configuration
+func (r SecretReference) String() string {
+	if r.Key != "" {
+		return r.Scheme + "://" + r.Path + "#" + r.Key
+	}
+	return r.Scheme + "://" + r.Path
+}
+
+// SecretProvider resolves secret references of a scheme. Errors must not
+// include the secret value, they are reported at startup.
+type SecretProvider interface {
+	Resolve(ref SecretReference) (string, error)
+}
+
+// SecretProviderFunc adapts a function to SecretProvider
+type SecretProviderFunc func(ref SecretReference) (string, error)
+
+// Resolve calls f(ref)
+func (f SecretProviderFunc) Resolve(ref SecretReference) (string, error) {
+	return f(ref)
+}
+
+// Global registry of secret providers by scheme
+var (
+	secretProviders   = make(map[string]SecretProvider)
+	secretProvidersMu sync.RWMutex
+)
+
+// RegisterSecretProvider registers a provider for references of a scheme.
+// Providers must be registered before the configuration is loaded.
+func RegisterSecretProvider(scheme string, provider SecretProvider) error {
+	secretProvidersMu.Lock()
+	defer secretProvidersMu.Unlock()
+
+	if !secretSchemePattern.MatchString(scheme) {
+		return fmt.Errorf("invalid secret provider scheme: %s", scheme)
+	}
+	if _, exists := secretProviders[scheme]; exists {
+		return fmt.Errorf("secret provider %s already registered", scheme)
+	}
+
+	secretProviders[scheme] = provider
+	return nil
+}
+
+// GetSecretProvider retrieves the provider registered for a scheme
+func GetSecretProvider(scheme string) (SecretProvider, error) {
+	secretProvidersMu.RLock()
+	defer secretProvidersMu.RUnlock()
+
+	provider, exists := secretProviders[scheme]
+	if !exists {
+		return nil, fmt.Errorf("no secret provider registered for scheme %s", scheme)
+	}
+	return provider, nil
+}
+
+// ListSecretProviders returns the registered schemes
+func ListSecretProviders() []string {
+	secretProvidersMu.RLock()
+	defer secretProvidersMu.RUnlock()
+
+	schemes := make([]string, 0, len(secretProviders))
+	for scheme := range secretProviders {
+		schemes = append(schemes, scheme)
+	}
+	sort.Strings(schemes)
+	return schemes
+}
+
+func init() {
+	RegisterSecretProvider("env", SecretProviderFunc(resolveEnvSecret))
+	RegisterSecretProvider("file", SecretProviderFunc(resolveFileSecret))
+}
+
+// resolveEnvSecret resolves env://NAME to the environment variable NAME
+func resolveEnvSecret(ref SecretReference) (string, error) {
+	if ref.Key != "" {
+		return "", fmt.Errorf("environment secrets have no keys")
+	}
+	value, ok := os.LookupEnv(ref.Path)
+	if !ok {
+		return "", fmt.Errorf("environment variable %s is not set", ref.Path)
+	}
+	return value, nil
+}
+
+// resolveFileSecret resolves file:///path to the file's content without its
+// trailing newline, and file:///path#key to a key of a YAML or JSON file
+func resolveFileSecret(ref SecretReference) (string, error) {
+	data, err := os.ReadFile(ref.Path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read secret file: %w", err)
+	}
+	if ref.Key == "" {
+		return strings.TrimRight(string(data), "\r\n"), nil
+	}
+
+	var values map[string]interface{}
+	if err := yaml.Unmarshal(data, &values); err != nil {
+		// The parse error may quote the content
+		return "", fmt.Errorf("secret file %s is not a YAML or JSON object", ref.Path)
+	}
+	value, ok := values[ref.Key]
+	if !ok {
+		return "", fmt.Errorf("secret file %s has no key %s", ref.Path, ref.Key)
+	}
+	switch value.(type) {
+	case map[string]interface{}, []interface{}, nil:
+		return "", fmt.Errorf("secret file %s key %s is not a scalar", ref.Path, ref.Key)
+	}
+	return fmt.Sprint(value), nil
+}
+
+// secretSchemePattern matches the scheme of a secret reference
+var secretSchemePattern = regexp.MustCompile(`^[a-z][a-z0-9+.-]*$`)
+
+// literalURLSchemes are URL schemes of values that are not secret
+// references, such as postgres DSNs
+var literalURLSchemes = map[string]bool{"postgres": true, "postgresql": true}
+
+// ParseSecretReference parses a configuration value as a secret reference.
+// It reports false for values that aren't references, which are used as is.
+func ParseSecretReference(value string) (SecretReference, bool) {
+	scheme, rest, found := strings.Cut(value, "://")
+	if !found || !secretSchemePattern.MatchString(scheme) || literalURLSchemes[scheme] {
+		return SecretReference{}, false
+	}
+
+	ref := SecretReference{Scheme: scheme, Path: rest}
+	if i := strings.LastIndex(rest, "#"); i >= 0 {
+		ref.Path, ref.Key = rest[:i], rest[i+1:]
+	}
+	return ref, true
+}
+
+// secretFields returns the sensitive fields of a configuration, which may
+// hold secret references, keyed by their YAML path
+func secretFields(cfg *Config) map[string]*string {
+	fields := map[string]*string{
+		"auth.jwt.secret":                       &cfg.Auth.JWT.Secret,
+		"auth.oauth2.client_secret":             &cfg.Auth.OAuth2.ClientSecret,
+		"admin_api.auth.jwt.secret":             &cfg.AdminAPI.Auth.JWT.Secret,
+		"admin_api.auth.oauth2.client_secret":   &cfg.AdminAPI.Auth.OAuth2.ClientSecret,
+		"portal.jwt.secret":                     &cfg.Portal.JWT.Secret,
+		"portal.repository.postgres.dsn":        &cfg.Portal.Repository.Postgres.DSN,
+		"store.etcd.password":                   &cfg.Store.Etcd.Password,
+		"config.source.etcd.password":           &cfg.ConfigSource.Source.Etcd.Password,
+		"rate_limit.redis.password":             &cfg.RateLimit.Redis.Password,
+		"rate_limit.dynamodb.secret_access_key": &cfg.RateLimit.DynamoDB.SecretAccessKey,
+		"rate_limit.dynamodb.session_token":     &cfg.RateLimit.DynamoDB.SessionToken,
+		"idempotency.redis.password":            &cfg.Idempotency.Redis.Password,
+		"wasm.kv.redis.password":                &cfg.WASM.KV.Redis.Password,
+		"webhooks.config_change.secret":         &cfg.Webhooks.ConfigChange.Secret,
+		"webhooks.health_status.secret":         &cfg.Webhooks.HealthStatus.Secret,
+		"proxy.upstream_override.secret":        &cfg.Proxy.UpstreamOverride.Secret,
+	}
+	for i := range cfg.Auth.APIKey.Keys {
+		fields[fmt.Sprintf("auth.api_key.keys[%d]", i)] = &cfg.Auth.APIKey.Keys[i]
+	}
+	for i := range cfg.AdminAPI.Auth.APIKey.Keys {
+		fields[fmt.Sprintf("admin_api.auth.api_key.keys[%d]", i)] = &cfg.AdminAPI.Auth.APIKey.Keys[i]
+	}
+	for name, tenant := range cfg.Tenancy.Tenants {
+		for i := range tenant.APIKeys {
+			fields[fmt.Sprintf("tenancy.tenants.%s.api_keys[%d]", name, i)] = &tenant.APIKeys[i]
+		}
+	}
+	return fields
+}
+
+// SecretResolver resolves the secret references of a configuration and
+// caches the values, so each reference is fetched once per refresh
+type SecretResolver struct {
+	mu    sync.RWMutex
+	refs  map[string]SecretReference // Field path to its reference
+	cache map[string]string          // Reference to its value
+}
+
+// NewSecretResolver creates a secret resolver
+func NewSecretResolver() *SecretResolver {
+	return &SecretResolver{
+		refs:  make(map[string]SecretReference),
+		cache: make(map[string]string),
+	}
+}
+
+// ResolveConfig replaces the secret references in the sensitive fields of
+// the configuration with their values. Errors name the field and the
+// reference, never the value.
+func (r *SecretResolver) ResolveConfig(cfg *Config) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	fields := secretFields(cfg)
+	paths := make([]string, 0, len(fields))
+	for path := range fields {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		ref, ok := ParseSecretReference(*fields[path])
+		if !ok {
+			continue
+		}
+		value, cached := r.cache[ref.String()]
+		if !cached {
+			var err error
+			if value, err = resolveSecret(ref); err != nil {
+				return fmt.Errorf("%s: %w", path, err)
+			}
+			r.cache[ref.String()] = value
+		}
+		r.refs[path] = ref
+		*fields[path] = value
+	}
+	return nil
+}
+
+// Value returns the current value of a field resolved from a reference,
+// including rotations picked up by Refresh
+func (r *SecretResolver) Value(path string) (string, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	ref, ok := r.refs[path]
+	if !ok {
+		return "", false
+	}
+	return r.cache[ref.String()], true
+}
+
+// Refresh resolves the references again and returns the fields whose values
+// changed. References failing to resolve keep their previous value.
+func (r *SecretResolver) Refresh() ([]string, error) {
+	r.mu.RLock()
+	refs := make(map[string]SecretReference, len(r.cache))
+	for _, ref := range r.refs {
+		refs[ref.String()] = ref
+	}
+	r.mu.RUnlock()
+
+	// Resolve without the lock, providers may be remote
+	values := make(map[string]string, len(refs))
+	var errs []string
+	for key, ref := range refs {
+		value, err := resolveSecret(ref)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		values[key] = value
+	}
+
+	r.mu.Lock()
+	changedRefs := make(map[string]bool)
+	for key, value := range values {
+		if r.cache[key] != value {
+			r.cache[key] = value
+			changedRefs[key] = true
+		}
+	}
+	var changed []string
+	for path, ref := range r.refs {
+		if changedRefs[ref.String()] {
+			changed = append(changed, path)
+		}
+	}
+	r.mu.Unlock()
+
+	sort.Strings(changed)
+	if len(errs) > 0 {
+		sort.Strings(errs)
+		return changed, fmt.Errorf("failed to refresh secrets: %s", strings.Join(errs, "; "))
+	}
+	return changed, nil
+}
+
+// Watch refreshes the secrets every interval until the context is done,
+// calling onChange with the fields whose values rotated
+func (r *SecretResolver) Watch(ctx context.Context, interval time.Duration, onChange func(paths []string)) {
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			changed, err := r.Refresh()
+			if err != nil {
+				log.Printf("Secret refresh: %v", err)
+			}
+			if len(changed) > 0 && onChange != nil {
+				onChange(changed)
+			}
+		}
+	}
+}
+
+// resolveSecret resolves a reference with the provider of its scheme
+func resolveSecret(ref SecretReference) (string, error) {
+	provider, err := GetSecretProvider(ref.Scheme)
+	if err == nil {
+		var value string
+		if value, err = provider.Resolve(ref); err == nil {
+			return value, nil
+		}
+	}
+	return "", fmt.Errorf("failed to resolve secret reference %s: %w", ref, err)
+}
+
+// SecretResolver returns the resolver of the secret references of the
+// configuration, nil for configurations not created by Load
+func (c *Config) SecretResolver() *SecretResolver {
+	return c.secrets
+}
@@ -3,7 +3,10 @@ package etcd
 import (
 	"context"
 	"crypto/tls"
+	"errors"
 	"fmt"
+	"log"
+	"math/rand"
 	"sync"
 	"time"
 
@@ -11,14 +14,48 @@ import (
 	clientv3 "go.etcd.io/etcd/client/v3"
 )
 
+const (
+	defaultTimeout         = 5 * time.Second
+	defaultRetryBackoff    = 500 * time.Millisecond
+	defaultMaxRetryBackoff = 30 * time.Second
+)
+
+// errKeyNotFound is returned when the watched key doesn't exist, which
+// unlike connection errors isn't retried
+var errKeyNotFound = errors.New("key not found in etcd")
+
+// etcdClient is the part of the etcd client used by the source
+type etcdClient interface {
+	Get(ctx context.Context, key string, opts ...clientv3.OpOption) (*clientv3.GetResponse, error)
+	Watch(ctx context.Context, key string, opts ...clientv3.OpOption) clientv3.WatchChan
+	Close() error
+}
+
 // EtcdSource implements the config.Source interface for etcd-based configuration.
 // It provides configuration loading from etcd and watches for configuration changes.
+//
+// Failed reads are retried with exponential backoff, and a broken watch is
+// re-established with the same backoff so a flaky etcd doesn't cause a
+// reconnect storm. When etcd stays unavailable the source is reported as
+// degraded by Health while consumers keep the last configuration they read.
 type EtcdSource struct {
-	client    *clientv3.Client
+	client    etcdClient
 	key       string
 	mu        sync.RWMutex
 	watchers  map[string]*watcher
 	closed    bool
+
+	timeout         time.Duration
+	maxRetries      int
+	retryBackoff    time.Duration
+	maxRetryBackoff time.Duration
+	// random returns the jitter of retry delays in [0, 1)
+	random func() float64
+
+	stateMu     sync.Mutex
+	lastSuccess time.Time
+	lastError   error
+	failures    int
 }
 
 // watcher represents an etcd watcher instance
@@ -35,6 +72,13 @@ type EtcdConfig struct {
 	Username  string        `yaml:"username"`
 	Password  string        `yaml:"password"`
 	TLS       *TLSConfig    `yaml:"tls,omitempty"`
+
+	DialKeepAliveTime    time.Duration `yaml:"dial_keepalive_time"`    // Interval of keepalive pings, 0 disables them
+	DialKeepAliveTimeout time.Duration `yaml:"dial_keepalive_timeout"` // Wait for a keepalive response before closing the connection
+	AutoSyncInterval     time.Duration `yaml:"auto_sync_interval"`     // Interval of refreshing endpoints from the cluster, 0 disables it
+	MaxRetries           int           `yaml:"max_retries"`            // Retries of a failed read, 0 disables them
+	RetryBackoff         time.Duration `yaml:"retry_backoff"`          // Delay before the first retry, doubling per retry (default: 500ms)
+	MaxRetryBackoff      time.Duration `yaml:"max_retry_backoff"`      // Cap of a retry delay (default: 30s)
 }
 
 // TLSConfig represents TLS configuration for etcd
@@ -69,13 +113,16 @@ func NewEtcdSource(cfg *EtcdConfig, key string) (config.Source, error) {
 
 	// Create etcd client configuration
 	clientConfig := clientv3.Config{
-		Endpoints:   cfg.Endpoints,
-		DialTimeout: cfg.Timeout,
+		Endpoints:            cfg.Endpoints,
+		DialTimeout:          cfg.Timeout,
+		DialKeepAliveTime:    cfg.DialKeepAliveTime,
+		DialKeepAliveTimeout: cfg.DialKeepAliveTimeout,
+		AutoSyncInterval:     cfg.AutoSyncInterval,
 	}
 
 	// Set default timeout if not specified
 	if clientConfig.DialTimeout == 0 {
-		clientConfig.DialTimeout = 5 * time.Second
+		clientConfig.DialTimeout = defaultTimeout
 	}
 
 	// Add authentication if configured
@@ -99,46 +146,63 @@ func NewEtcdSource(cfg *EtcdConfig, key string) (config.Source, error) {
 		return nil, fmt.Errorf("failed to create etcd client: %w", err)
 	}
 
-	// Test connection
-	ctx, cancel := context.WithTimeout(context.Background(), clientConfig.DialTimeout)
-	defer cancel()
+	source := newEtcdSource(client, key, cfg)
 
-	_, err = client.Status(ctx, cfg.Endpoints[0])
+	// Test connection
+	err = source.retry(context.Background(), func(ctx context.Context) error {
+		_, err := client.Status(ctx, cfg.Endpoints[0])
+		return err
+	})
 	if err != nil {
 		client.Close()
 		return nil, fmt.Errorf("failed to connect to etcd: %w", err)
 	}
+	source.recordSuccess()
+
+	return source, nil
+}
 
-	return &EtcdSource{
-		client:   client,
-		key:      key,
-		watchers: make(map[string]*watcher),
-	}, nil
+// newEtcdSource creates the source reading key with client
+func newEtcdSource(client etcdClient, key string, cfg *EtcdConfig) *EtcdSource {
+	es := &EtcdSource{
+		client:          client,
+		key:             key,
+		watchers:        make(map[string]*watcher),
+		timeout:         cfg.Timeout,
+		maxRetries:      cfg.MaxRetries,
+		retryBackoff:    cfg.RetryBackoff,
+		maxRetryBackoff: cfg.MaxRetryBackoff,
+		random:          rand.Float64,
+	}
+	if es.timeout <= 0 {
+		es.timeout = defaultTimeout
+	}
+	if es.retryBackoff <= 0 {
+		es.retryBackoff = defaultRetryBackoff
+	}
+	if es.maxRetryBackoff <= 0 {
+		es.maxRetryBackoff = defaultMaxRetryBackoff
+	}
+	return es
 }
 
 // Get retrieves the complete configuration data from etcd.
-// It reads the value of the specified key and returns it as bytes.
+// It reads the value of the specified key and returns it as bytes,
+// retrying failed reads with backoff.
 func (es *EtcdSource) Get() ([]byte, error) {
 	es.mu.RLock()
-	defer es.mu.RUnlock()
+	closed := es.closed
+	es.mu.RUnlock()
 
-	if es.closed {
+	if closed {
 		return nil, fmt.Errorf("etcd source is closed")
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-
-	resp, err := es.client.Get(ctx, es.key)
+	data, err := es.getCurrentValue(context.Background())
 	if err != nil {
 		return nil, fmt.Errorf("failed to get key %s from etcd: %w", es.key, err)
 	}
-
-	if len(resp.Kvs) == 0 {
-		return nil, fmt.Errorf("key %s not found in etcd", es.key)
-	}
-
-	return resp.Kvs[0].Value, nil
+	return data, nil
 }
 
 // Watch monitors the etcd key for changes and returns a channel that
@@ -182,7 +246,7 @@ func (es *EtcdSource) Watch(ctx context.Context) (<-chan []byte, error) {
 		}()
 
 		// Send current configuration immediately
-		if data, err := es.getCurrentValue(); err == nil {
+		if data, err := es.getCurrentValue(watcherCtx); err == nil {
 			select {
 			case ch <- data:
 			case <-watcherCtx.Done():
@@ -193,23 +257,43 @@ func (es *EtcdSource) Watch(ctx context.Context) (<-chan []byte, error) {
 		// Start watching for changes
 		watchCh := es.client.Watch(watcherCtx, es.key)
 
+		// Consecutive watch failures, backing off reconnects
+		failures := 0
 		for {
 			select {
 			case <-watcherCtx.Done():
 				return
 			case watchResp, ok := <-watchCh:
-				if !ok {
-					// Watch channel closed, try to reconnect
-					watchCh = es.client.Watch(watcherCtx, es.key)
-					continue
-				}
+				if !ok || watchResp.Err() != nil {
+					// Watch channel closed or failed, reconnect after a backoff
+					err := errors.New("watch channel closed")
+					if ok {
+						err = watchResp.Err()
+					}
+					failures++
+					es.recordFailure(err)
+					log.Printf("Etcd watch of key %s failed, reconnecting: %v", es.key, err)
+					if !sleepContext(watcherCtx, es.retryDelay(failures)) {
+						return
+					}
 
-				if watchResp.Err() != nil {
-					// Handle watch error, try to reconnect
-					time.Sleep(time.Second)
+					// Send the current configuration again as changes
+					// may have been missed while the watch was down
+					if data, err := es.getCurrentValue(watcherCtx); err == nil {
+						failures = 0
+						select {
+						case ch <- data:
+						case <-watcherCtx.Done():
+							return
+						}
+					}
 					watchCh = es.client.Watch(watcherCtx, es.key)
 					continue
 				}
+				if failures > 0 {
+					failures = 0
+					es.recordSuccess()
+				}
 
 				// Process watch events
 				for _, event := range watchResp.Events {
@@ -228,21 +312,125 @@ func (es *EtcdSource) Watch(ctx context.Context) (<-chan []byte, error) {
 	return ch, nil
 }
 
-// getCurrentValue retrieves the current value from etcd
-func (es *EtcdSource) getCurrentValue() ([]byte, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
-	resp, err := es.client.Get(ctx, es.key)
+// getCurrentValue retrieves the current value from etcd, retrying failed
+// reads with backoff
+func (es *EtcdSource) getCurrentValue(ctx context.Context) ([]byte, error) {
+	var value []byte
+	err := es.retry(ctx, func(ctx context.Context) error {
+		resp, err := es.client.Get(ctx, es.key)
+		if err != nil {
+			return err
+		}
+		if len(resp.Kvs) == 0 {
+			return errKeyNotFound
+		}
+		value = resp.Kvs[0].Value
+		return nil
+	})
 	if err != nil {
+		if !errors.Is(err, errKeyNotFound) {
+			es.recordFailure(err)
+		}
 		return nil, err
 	}
 
-	if len(resp.Kvs) == 0 {
-		return nil, fmt.Errorf("key not found")
+	es.recordSuccess()
+	return value, nil
+}
+
+// retry calls op with the request timeout until it succeeds, up to
+// maxRetries more times with backoff between attempts. A missing key isn't
+// retried.
+func (es *EtcdSource) retry(ctx context.Context, op func(ctx context.Context) error) error {
+	var err error
+	for attempt := 0; attempt <= es.maxRetries; attempt++ {
+		if attempt > 0 && !sleepContext(ctx, es.retryDelay(attempt)) {
+			return ctx.Err()
+		}
+
+		attemptCtx, cancel := context.WithTimeout(ctx, es.timeout)
+		err = op(attemptCtx)
+		cancel()
+		if err == nil || errors.Is(err, errKeyNotFound) {
+			return err
+		}
+	}
+	return err
+}
+
+// retryDelay returns the delay before a retry, doubling from retryBackoff up
+// to maxRetryBackoff, of which half is random so nodes don't reconnect in
+// lockstep
+func (es *EtcdSource) retryDelay(retry int) time.Duration {
+	delay := es.retryBackoff
+	for i := 1; i < retry && delay < es.maxRetryBackoff; i++ {
+		delay *= 2
 	}
+	if delay > es.maxRetryBackoff {
+		delay = es.maxRetryBackoff
+	}
+	return delay - time.Duration(float64(delay)*0.5*es.random())
+}
 
-	return resp.Kvs[0].Value, nil
+// recordSuccess marks etcd as reachable
+func (es *EtcdSource) recordSuccess() {
+	es.stateMu.Lock()
+	defer es.stateMu.Unlock()
+
+	if es.failures > 0 {
+		log.Printf("Etcd config source recovered after %d failures", es.failures)
+	}
+	es.lastSuccess = time.Now()
+	es.lastError = nil
+	es.failures = 0
+}
+
+// recordFailure marks etcd as unreachable after all retries failed
+func (es *EtcdSource) recordFailure(err error) {
+	es.stateMu.Lock()
+	defer es.stateMu.Unlock()
+
+	if es.failures == 0 {
+		log.Printf("Etcd config source degraded, keeping the last known good configuration: %v", err)
+	}
+	es.lastError = err
+	es.failures++
+}
+
+// Health reports whether etcd is reachable. The source is "degraded" while
+// reads or the watch fail, in which case consumers keep serving the last
+// configuration they read.
+func (es *EtcdSource) Health() map[string]interface{} {
+	es.stateMu.Lock()
+	defer es.stateMu.Unlock()
+
+	health := map[string]interface{}{
+		"driver": "etcd",
+		"key":    es.key,
+		"status": "healthy",
+	}
+	if !es.lastSuccess.IsZero() {
+		health["last_success"] = es.lastSuccess.Unix()
+	}
+	if es.lastError != nil {
+		health["status"] = "degraded"
+		health["last_error"] = es.lastError.Error()
+		health["failures"] = es.failures
+	}
+	return health
+}
+
+// sleepContext waits for d, reporting false if ctx is done first
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
 }
 
 // Close closes the etcd source and cleans up all resources
@@ -2,8 +2,13 @@ package etcd
 
 import (
 	"context"
+	"errors"
+	"sync"
 	"testing"
 	"time"
+
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	clientv3 "go.etcd.io/etcd/client/v3"
 )
 
 func TestNewEtcdSource(t *testing.T) {
@@ -326,3 +331,172 @@ func TestEtcdSource_Integration_MultipleWatchers(t *testing.T) {
 		// Timeout is acceptable
 	}
 }
+
+// stubClient is an etcd client whose reads fail while failing is set
+type stubClient struct {
+	mu      sync.Mutex
+	value   []byte
+	failing bool
+	gets    int
+	watches chan clientv3.WatchResponse
+}
+
+func (c *stubClient) setFailing(failing bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.failing = failing
+}
+
+func (c *stubClient) Get(ctx context.Context, key string, opts ...clientv3.OpOption) (*clientv3.GetResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.gets++
+	if c.failing {
+		return nil, errors.New("connection refused")
+	}
+	if c.value == nil {
+		return &clientv3.GetResponse{}, nil
+	}
+	return &clientv3.GetResponse{Kvs: []*mvccpb.KeyValue{{Key: []byte(key), Value: c.value}}}, nil
+}
+
+func (c *stubClient) Watch(ctx context.Context, key string, opts ...clientv3.OpOption) clientv3.WatchChan {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.watches
+}
+
+func (c *stubClient) Close() error {
+	return nil
+}
+
+func newStubSource(client *stubClient) *EtcdSource {
+	source := newEtcdSource(client, "/stargate/routes", &EtcdConfig{
+		Timeout:      time.Second,
+		MaxRetries:   2,
+		RetryBackoff: time.Millisecond,
+	})
+	source.random = func() float64 { return 0 }
+	return source
+}
+
+func TestEtcdSource_RetriesAndDegrades(t *testing.T) {
+	client := &stubClient{value: []byte("routes: []"), failing: true}
+	source := newStubSource(client)
+
+	if _, err := source.Get(); err == nil {
+		t.Fatal("Expected Get to fail while etcd is unavailable")
+	}
+	if client.gets != 3 {
+		t.Errorf("Expected the read to be retried twice, got %d attempts", client.gets)
+	}
+	health := source.Health()
+	if health["status"] != "degraded" || health["last_error"] == nil {
+		t.Errorf("Expected the source degraded, got %v", health)
+	}
+
+	// Reads succeed again once etcd is back
+	client.setFailing(false)
+	data, err := source.Get()
+	if err != nil || string(data) != "routes: []" {
+		t.Fatalf("Expected the configuration, got %q: %v", data, err)
+	}
+	if health := source.Health(); health["status"] != "healthy" || health["last_success"] == nil {
+		t.Errorf("Expected the source healthy again, got %v", health)
+	}
+
+	// A missing key is neither retried nor a connection failure
+	client.value = nil
+	client.gets = 0
+	if _, err := source.Get(); err == nil || client.gets != 1 {
+		t.Errorf("Expected one failed attempt for a missing key, got %d: %v", client.gets, err)
+	}
+	if health := source.Health(); health["status"] != "healthy" {
+		t.Errorf("Expected a missing key to leave the source healthy, got %v", health)
+	}
+}
+
+func TestEtcdSource_WatchReconnects(t *testing.T) {
+	client := &stubClient{value: []byte("v1"), watches: make(chan clientv3.WatchResponse)}
+	source := newStubSource(client)
+	defer source.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ch, err := source.Watch(ctx)
+	if err != nil {
+		t.Fatalf("Watch() returned error: %v", err)
+	}
+	receive := func() string {
+		select {
+		case data := <-ch:
+			return string(data)
+		case <-time.After(2 * time.Second):
+			t.Fatal("Timed out waiting for configuration")
+			return ""
+		}
+	}
+	if data := receive(); data != "v1" {
+		t.Fatalf("Expected the current configuration, got %q", data)
+	}
+
+	// The watch breaks while etcd is down and the value changes meanwhile
+	client.setFailing(true)
+	client.mu.Lock()
+	client.value = []byte("v2")
+	broken := client.watches
+	client.watches = make(chan clientv3.WatchResponse)
+	client.mu.Unlock()
+	close(broken)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for source.Health()["status"] != "degraded" && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if health := source.Health(); health["status"] != "degraded" {
+		t.Fatalf("Expected the source degraded while the watch is down, got %v", health)
+	}
+
+	// Once etcd is back the missed change is delivered
+	client.setFailing(false)
+	client.mu.Lock()
+	broken = client.watches
+	client.watches = make(chan clientv3.WatchResponse)
+	client.mu.Unlock()
+	close(broken)
+
+	if data := receive(); data != "v2" {
+		t.Errorf("Expected the change missed during the outage, got %q", data)
+	}
+	if health := source.Health(); health["status"] != "healthy" {
+		t.Errorf("Expected the source healthy again, got %v", health)
+	}
+}
+
+func TestEtcdSource_RetryDelay(t *testing.T) {
+	source := newEtcdSource(&stubClient{}, "/stargate/routes", &EtcdConfig{
+		RetryBackoff:    100 * time.Millisecond,
+		MaxRetryBackoff: time.Second,
+	})
+
+	source.random = func() float64 { return 0 }
+	for retry, want := range map[int]time.Duration{
+		1: 100 * time.Millisecond,
+		2: 200 * time.Millisecond,
+		4: 800 * time.Millisecond,
+		5: time.Second,
+		9: time.Second,
+	} {
+		if got := source.retryDelay(retry); got != want {
+			t.Errorf("Expected %v before retry %d, got %v", want, retry, got)
+		}
+	}
+
+	// Half of the delay is jitter
+	source.random = func() float64 { return 0.5 }
+	if got := source.retryDelay(2); got != 150*time.Millisecond {
+		t.Errorf("Expected 150ms with jitter, got %v", got)
+	}
+}
@@ -74,10 +74,16 @@ func createEtcdSource(sourceConfig SourceConfig) (pkgConfig.Source, error) {
 
 	// Convert internal config to etcd source config
 	etcdSourceConfig := &etcd.EtcdConfig{
-		Endpoints: etcdConfig.Endpoints,
-		Timeout:   etcdConfig.Timeout,
-		Username:  etcdConfig.Username,
-		Password:  etcdConfig.Password,
+		Endpoints:            etcdConfig.Endpoints,
+		Timeout:              etcdConfig.Timeout,
+		Username:             etcdConfig.Username,
+		Password:             etcdConfig.Password,
+		DialKeepAliveTime:    etcdConfig.DialKeepAliveTime,
+		DialKeepAliveTimeout: etcdConfig.DialKeepAliveTimeout,
+		AutoSyncInterval:     etcdConfig.AutoSyncInterval,
+		MaxRetries:           etcdConfig.MaxRetries,
+		RetryBackoff:         etcdConfig.RetryBackoff,
+		MaxRetryBackoff:      etcdConfig.MaxRetryBackoff,
 	}
 
 	// Set default timeout if not specified
@@ -85,6 +91,11 @@ func createEtcdSource(sourceConfig SourceConfig) (pkgConfig.Source, error) {
 		etcdSourceConfig.Timeout = 5 * time.Second
 	}
 
+	// Set default retries if not specified
+	if etcdSourceConfig.MaxRetries == 0 {
+		etcdSourceConfig.MaxRetries = 3
+	}
+
 	// Convert TLS config if enabled
 	if etcdConfig.TLS.Enabled {
 		etcdSourceConfig.TLS = &etcd.TLSConfig{
@@ -159,6 +170,22 @@ func validateEtcdSourceConfig(etcdConfig EtcdSourceConfig) error {
 		return fmt.Errorf("etcd timeout cannot be negative")
 	}
 
+	if etcdConfig.DialKeepAliveTime < 0 || etcdConfig.DialKeepAliveTimeout < 0 || etcdConfig.AutoSyncInterval < 0 {
+		return fmt.Errorf("etcd keepalive and auto-sync intervals cannot be negative")
+	}
+
+	if etcdConfig.MaxRetries < 0 {
+		return fmt.Errorf("etcd max retries cannot be negative")
+	}
+
+	if etcdConfig.RetryBackoff < 0 || etcdConfig.MaxRetryBackoff < 0 {
+		return fmt.Errorf("etcd retry backoff cannot be negative")
+	}
+
+	if etcdConfig.RetryBackoff > 0 && etcdConfig.MaxRetryBackoff > 0 && etcdConfig.RetryBackoff > etcdConfig.MaxRetryBackoff {
+		return fmt.Errorf("etcd retry backoff cannot exceed max retry backoff")
+	}
+
 	// Validate TLS configuration if enabled
 	if etcdConfig.TLS.Enabled {
 		if etcdConfig.TLS.CertFile != "" && etcdConfig.TLS.KeyFile == "" {
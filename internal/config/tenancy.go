@@ -0,0 +1,44 @@
+package config
+
+import (
+	"crypto/subtle"
+	"net"
+	"regexp"
+	"strings"
+)
+
+// TenantForHost returns the tenant serving a request host, which may carry
+// a port, or the default tenant
+func (t *TenancyConfig) TenantForHost(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	for name, tenant := range t.Tenants {
+		for _, tenantHost := range tenant.Hosts {
+			if strings.EqualFold(tenantHost, host) {
+				return name
+			}
+		}
+	}
+	return ""
+}
+
+// TenantForAPIKey returns the tenant an Admin API key is bound to
+func (t *TenancyConfig) TenantForAPIKey(key string) (string, bool) {
+	for name, tenant := range t.Tenants {
+		for _, tenantKey := range tenant.APIKeys {
+			if subtle.ConstantTimeCompare([]byte(tenantKey), []byte(key)) == 1 {
+				return name, true
+			}
+		}
+	}
+	return "", false
+}
+
+// tenantNamePattern matches tenant names, which are used in store keys
+var tenantNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,62}$`)
+
+// ValidTenantName reports whether name is a valid tenant name
+func ValidTenantName(name string) bool {
+	return tenantNamePattern.MatchString(name)
+}
@@ -17,6 +17,12 @@ type Config struct {
 	IPACL          IPACLConfig          `yaml:"ip_acl"`
 	CORS           CORSConfig           `yaml:"cors"`
 	HeaderTransform HeaderTransformConfig `yaml:"header_transform"`
+	SecurityHeaders SecurityHeadersConfig `yaml:"security_headers"`
+	Tap            TapConfig            `yaml:"tap"`
+	Experiments    ExperimentsConfig    `yaml:"experiments"`
+	Idempotency    IdempotencyConfig    `yaml:"idempotency"`
+	BodyChecksum   BodyChecksumConfig   `yaml:"body_checksum"`
+	SchemaValidation SchemaValidationConfig `yaml:"schema_validation"`
 	MockResponse   MockResponseConfig   `yaml:"mock_response"`
 	GRPCWeb        GRPCWebConfig        `yaml:"grpc_web"`
 	Logging        LoggingConfig        `yaml:"logging"`
@@ -28,11 +34,22 @@ type Config struct {
 	AdminAPI       AdminAPIConfig       `yaml:"admin_api"`
 	Routes         RoutesConfig         `yaml:"routes"`
 	Upstreams      UpstreamsConfig      `yaml:"upstreams"`
+	Tenancy        TenancyConfig        `yaml:"tenancy"`
 	Plugins        PluginsConfig        `yaml:"plugins"`
 	Webhooks       WebhooksConfig       `yaml:"webhooks"`
 	Aggregator     AggregatorConfig     `yaml:"aggregator"`
 	Serverless     ServerlessConfig     `yaml:"serverless"`
 	WASM           WASMConfig           `yaml:"wasm"`
+	Stream         StreamConfig         `yaml:"stream"`
+	Secrets        SecretsConfig        `yaml:"secrets"`
+
+	secrets *SecretResolver // Resolver of the secret references, set by Load
+}
+
+// SecretsConfig represents the resolution of secret references such as
+// env://NAME, file:///path or schemes of registered SecretProviders
+type SecretsConfig struct {
+	RefreshInterval time.Duration `yaml:"refresh_interval"` // How often references are resolved again to pick up rotations, 0 disables
 }
 
 // ServerConfig represents HTTP server configuration
@@ -45,6 +62,88 @@ type ServerConfig struct {
 	WriteTimeout   time.Duration `yaml:"write_timeout"`
 	IdleTimeout    time.Duration `yaml:"idle_timeout"`
 	MaxHeaderBytes int           `yaml:"max_header_bytes"`
+	ProxyProtocol  ProxyProtocolConfig `yaml:"proxy_protocol"`
+	TrustedProxies []string      `yaml:"trusted_proxies"` // IPs or CIDRs of proxies whose X-Forwarded-For and X-Real-IP headers are trusted
+	HealthPath     string        `yaml:"health_path"`     // Readiness endpoint, reports 503 once shutdown begins (default: /health, empty disables)
+	LameDuckDuration time.Duration `yaml:"lame_duck_duration"` // Time to keep serving after SIGTERM while reporting not ready (default: 5s)
+	HTTP2          HTTP2Config   `yaml:"http2"`            // HTTP/2 settings of TLS listeners
+
+	// Listeners replaces address and tls with several HTTP listeners. When
+	// empty, address and tls form a single listener named "default".
+	Listeners []ListenerConfig               `yaml:"listeners"`
+	Profiles  map[string]ListenerProfileConfig `yaml:"profiles"` // Middleware and route selections referenced by listeners
+}
+
+// DefaultListenerName is the name of the listener formed by the server
+// address and tls when no listeners are configured
+const DefaultListenerName = "default"
+
+// ListenerConfig represents an HTTP listener of the node
+type ListenerConfig struct {
+	Name    string    `yaml:"name"`
+	Address string    `yaml:"address"`
+	TLS     TLSConfig `yaml:"tls"`
+	Profile string    `yaml:"profile"` // Profile selecting the middlewares and routes served (default: all)
+}
+
+// ListenerProfileConfig selects the middlewares applied and the routes served
+// on the listeners referencing it
+type ListenerProfileConfig struct {
+	Middlewares []string `yaml:"middlewares"` // Enabled middlewares applied, by configuration section name (default: all)
+	Routes      []string `yaml:"routes"`      // Route IDs served, other requests get 404 (default: all)
+}
+
+// ListenerMiddlewares lists the middleware names listener profiles select,
+// named after their configuration sections
+var ListenerMiddlewares = []string{
+	"tracing", "access_log", "metrics", "tap", "security_headers", "cors",
+	"header_transform", "mock_response", "grpc_web", "ip_acl", "rate_limit",
+	"auth", "body_checksum", "experiments", "idempotency", "aggregator",
+	"serverless", "wasm", "circuit_breaker", "traffic_mirror",
+}
+
+// HTTPListeners returns the configured listeners, or the single listener
+// formed by the server address and tls
+func (s *ServerConfig) HTTPListeners() []ListenerConfig {
+	if len(s.Listeners) > 0 {
+		return s.Listeners
+	}
+	return []ListenerConfig{{Name: DefaultListenerName, Address: s.Address, TLS: s.TLS}}
+}
+
+// Stream listener protocols
+const (
+	StreamProtocolTCP = "tcp"
+	StreamProtocolUDP = "udp"
+)
+
+// StreamConfig represents L4 stream proxy configuration. Stream listeners are
+// opt-in, run alongside the HTTP server and share its upstreams, load
+// balancing and health checking, but none of the HTTP middleware.
+type StreamConfig struct {
+	Listeners []StreamListenerConfig `yaml:"listeners"`
+}
+
+// StreamListenerConfig represents a single TCP or UDP stream listener
+type StreamListenerConfig struct {
+	Name           string        `yaml:"name"`
+	Address        string        `yaml:"address"`
+	Protocol       string        `yaml:"protocol"`        // tcp or udp (default: tcp)
+	UpstreamID     string        `yaml:"upstream_id"`
+	ConnectTimeout time.Duration `yaml:"connect_timeout"` // Timeout dialing a target (default: 5s)
+	IdleTimeout    time.Duration `yaml:"idle_timeout"`    // Idle time before a connection or UDP session is closed (default: 10m TCP, 1m UDP)
+
+	// ConnectionLimit replaces the global connection limits of rate_limit.connections for this listener
+	ConnectionLimit *ConnectionLimitConfig `yaml:"connection_limit,omitempty"`
+}
+
+// ProxyProtocolConfig represents PROXY protocol (v1 and v2) listener configuration.
+// The header is only honoured on connections from trusted sources, so clients
+// can't spoof their address by sending one themselves.
+type ProxyProtocolConfig struct {
+	Enabled        bool          `yaml:"enabled"`
+	TrustedSources []string      `yaml:"trusted_sources"` // IPs or CIDRs of load balancers allowed to send the header
+	HeaderTimeout  time.Duration `yaml:"header_timeout"`  // Maximum time to wait for the header (default: 5s)
 }
 
 // ControllerConfig represents controller server configuration
@@ -55,6 +154,33 @@ type ControllerConfig struct {
 	Timeout      time.Duration `yaml:"timeout"`
 	ReadTimeout  time.Duration `yaml:"read_timeout"`
 	WriteTimeout time.Duration `yaml:"write_timeout"`
+	LameDuckDuration time.Duration `yaml:"lame_duck_duration"` // Time to keep serving after SIGTERM while /health reports not ready (default: 5s)
+	HTTP2        HTTP2Config   `yaml:"http2"`        // HTTP/2 settings when TLS is enabled
+	LeaderElection LeaderElectionConfig `yaml:"leader_election"` // Active-passive election among controller replicas
+}
+
+// LeaderElectionConfig represents the election of one active controller
+// among replicas sharing a store. Only the leader runs sync, GitOps and
+// webhook delivery; standbys serve reads and take over once the leader's
+// lease expires or it shuts down.
+type LeaderElectionConfig struct {
+	Enabled          bool          `yaml:"enabled"`
+	Backend          string        `yaml:"backend"`           // "etcd" (the store's cluster, requires store.type etcd) or "redis"
+	Key              string        `yaml:"key"`               // Lock key (default: stargate/controller/leader)
+	ID               string        `yaml:"id"`                // Identity of this controller (default: the hostname)
+	AdvertiseAddress string        `yaml:"advertise_address"` // URL other controllers reach this one at, e.g. http://controller-1:9090
+	LeaseTTL         time.Duration `yaml:"lease_ttl"`         // Time the lock outlives its last renewal (default: 15s)
+	RenewInterval    time.Duration `yaml:"renew_interval"`    // Interval of renewing or campaigning for the lock (default: lease_ttl / 3)
+	StandbyWrites    string        `yaml:"standby_writes"`    // Admin API writes on a standby: "reject" with 409 (default) or "proxy" to the leader
+	Redis            RedisConfig   `yaml:"redis"`             // Redis holding the lock of the redis backend
+}
+
+// HTTP2Config represents the HTTP/2 server settings limiting what a single
+// connection may consume
+type HTTP2Config struct {
+	MaxConcurrentStreams uint32        `yaml:"max_concurrent_streams"` // Streams a client may have open per connection (default: 100)
+	MaxReadFrameSize     uint32        `yaml:"max_read_frame_size"`    // Largest frame read, 16384 to 16777215 bytes (default: 1MB)
+	IdleTimeout          time.Duration `yaml:"idle_timeout"`           // Time before an idle connection is closed (default: the server idle timeout)
 }
 
 // TLSConfig represents TLS configuration
@@ -74,6 +200,7 @@ type ACMEConfig struct {
 	CacheDir    string   `yaml:"cache_dir"`
 	DirectoryURL string  `yaml:"directory_url"`
 	AcceptTOS   bool     `yaml:"accept_tos"`
+	ForceRenewInterval time.Duration `yaml:"force_renew_interval"` // Minimum time between forced renewals of a domain through the Admin API (default: 1h)
 }
 
 // ProxyConfig represents proxy configuration
@@ -86,6 +213,139 @@ type ProxyConfig struct {
 	MaxIdleConns             int           `yaml:"max_idle_conns"`
 	MaxIdleConnsPerHost      int           `yaml:"max_idle_conns_per_host"`
 	WebSocket                WebSocketConfig `yaml:"websocket"`
+	Upstreams                map[string]UpstreamProxyConfig `yaml:"upstreams"`            // Per-upstream connection settings keyed by upstream ID
+	CertReloadInterval       time.Duration `yaml:"cert_reload_interval"` // Interval for checking upstream TLS files for changes (default: 30s)
+	TLSErrorDetail           bool          `yaml:"tls_error_detail"`     // Name why an upstream TLS handshake failed, such as an expired certificate, in errors returned to clients
+	DynamicRouting           DynamicRoutingConfig `yaml:"dynamic_routing"`
+	UpstreamOverride         UpstreamOverrideConfig `yaml:"upstream_override"`
+	NoRoute                  NoRouteConfig `yaml:"no_route"`
+	Root                     RootPageConfig `yaml:"root"`
+	Deadline                 RequestDeadlineConfig `yaml:"deadline"`
+	Retry                    RetryConfig `yaml:"retry"`
+	CacheWarm                CacheWarmConfig `yaml:"cache_warm"`
+}
+
+// MaxRetryAttempts is the most retries allowed for one request
+const MaxRetryAttempts = 5
+
+// RetryConditionConnectError is the retry condition matching failures to
+// connect to the target, other conditions are status codes such as "503"
+const RetryConditionConnectError = "connect-error"
+
+// RetryConfig represents retries of failed upstream requests, sent to the
+// same target. Without a route policy only idempotent methods (GET, HEAD,
+// OPTIONS, PUT, DELETE, TRACE) are retried, after connection errors and
+// 502, 503 and 504 responses.
+type RetryConfig struct {
+	Enabled     bool                     `yaml:"enabled"`
+	Attempts    int                      `yaml:"attempts"`      // Retries after the first attempt (default: 2, at most MaxRetryAttempts)
+	Backoff     time.Duration            `yaml:"backoff"`       // Delay before each retry, 0 for none
+	MaxBodySize int64                    `yaml:"max_body_size"` // Largest request body buffered for retries, larger requests are never retried (default: 1MB)
+	PerRoute    map[string]RetryOnConfig `yaml:"per_route"`     // Retry policies keyed by route ID, replacing the default policy
+}
+
+// RetryOnConfig represents which requests of a route are retried, such as
+// POSTs to a backend that deduplicates them
+type RetryOnConfig struct {
+	Methods    []string `yaml:"methods"`    // Retryable methods, * for all; a route without methods is never retried
+	Conditions []string `yaml:"conditions"` // connect-error or status codes (default: connect-error, 502, 503, 504)
+}
+
+// CacheWarmConfig represents priming the response caches at startup: the
+// configured URLs are requested through the proxy, middlewares included,
+// and readiness waits for them until Deadline. Failed requests are logged
+// and don't affect readiness.
+type CacheWarmConfig struct {
+	Enabled     bool                      `yaml:"enabled"`
+	Concurrency int                       `yaml:"concurrency"` // Warm requests in flight at once (default: 4)
+	Timeout     time.Duration             `yaml:"timeout"`     // Timeout of each warm request (default: 10s)
+	Deadline    time.Duration             `yaml:"deadline"`    // Longest readiness waits for warming, unfinished requests are abandoned (default: 30s)
+	PerRoute    map[string]CacheWarmRoute `yaml:"per_route"`   // Requests keyed by the ID of the route they should match
+}
+
+// CacheWarmRoute represents the requests warming a route's caches
+type CacheWarmRoute struct {
+	URLs    []string          `yaml:"urls"`    // Absolute http or https URLs requested with GET, whose host selects the route
+	Headers map[string]string `yaml:"headers"` // Headers sent with each request, such as Accept
+}
+
+// RequestDeadlineConfig represents the overall deadline of requests, counted
+// from their arrival and bounding middlewares, upstream queueing, dynamic
+// re-routes and the upstream response. Requests not answered by their
+// deadline get 504. The time left is passed to upstreams in Header, and as
+// grpc-timeout to gRPC upstreams. WebSocket upgrades have no deadline.
+type RequestDeadlineConfig struct {
+	Enabled        bool                     `yaml:"enabled"`
+	Timeout        time.Duration            `yaml:"timeout"`         // Deadline of requests, 0 for none
+	PerRoute       map[string]time.Duration `yaml:"per_route"`       // Deadlines keyed by route ID, overriding Timeout
+	Header         string                   `yaml:"header"`          // Header carrying requested and remaining timeouts (default: X-Request-Timeout)
+	TrustedSources []string                 `yaml:"trusted_sources"` // Peer IPs and CIDRs allowed to shorten deadlines with Header; none when empty
+}
+
+// NoRouteConfig represents the response to requests matching no route
+type NoRouteConfig struct {
+	Action      string `yaml:"action"`       // error (default), redirect:<url>, upstream:<id> or static
+	StatusCode  int    `yaml:"status_code"`  // Status of redirects (default: 302) and static responses (default: 404)
+	Body        string `yaml:"body"`         // Body of static responses
+	ContentType string `yaml:"content_type"` // Content type of static responses (default: text/html; charset=utf-8)
+}
+
+// RootPageConfig represents the response to requests for the gateway root
+// matching no route, such as a landing page or a redirect to docs. Requests
+// matching a route are routed as usual.
+type RootPageConfig struct {
+	Enabled     bool     `yaml:"enabled"`
+	Paths       []string `yaml:"paths"`        // Paths answered besides "/"
+	Redirect    string   `yaml:"redirect"`     // URL or path requests are redirected to, instead of a page
+	Body        string   `yaml:"body"`         // Body of the page
+	File        string   `yaml:"file"`         // File the body of the page is read from, instead of Body
+	ContentType string   `yaml:"content_type"` // Content type of the page (default: text/html; charset=utf-8)
+	StatusCode  int      `yaml:"status_code"`  // Status of redirects (default: 302) and the page (default: 200)
+}
+
+// UpstreamOverrideConfig represents forcing the upstream of a request with a
+// header set by a trusted layer in front of the gateway. Overrides are only
+// honored from trusted sources; when both TrustedSources and Secret are set,
+// a request must satisfy both. Untrusted or unknown overrides are ignored.
+type UpstreamOverrideConfig struct {
+	Enabled        bool     `yaml:"enabled"`
+	Header         string   `yaml:"header"`          // Header naming the upstream ID (default: X-Stargate-Upstream)
+	TrustedSources []string `yaml:"trusted_sources"` // Peer IPs and CIDRs allowed to override
+	SecretHeader   string   `yaml:"secret_header"`   // Header carrying Secret (default: X-Stargate-Upstream-Secret)
+	Secret         string   `yaml:"secret"`          // Shared secret required to override
+}
+
+// MaxDynamicRoutingHops is the most re-routes allowed for one request
+const MaxDynamicRoutingHops = 3
+
+// DynamicRoutingConfig represents backend-driven re-routing: an upstream
+// response carrying the routing header with a known upstream ID is discarded
+// and the original request is re-proxied to that upstream
+type DynamicRoutingConfig struct {
+	Enabled     bool   `yaml:"enabled"`
+	Header      string `yaml:"header"`        // Response header naming the upstream to re-route to (default: X-Route-To)
+	MaxHops     int    `yaml:"max_hops"`      // Re-routes per request (default: 1, at most MaxDynamicRoutingHops)
+	MaxBodySize int64  `yaml:"max_body_size"` // Largest request body buffered for re-routing, larger requests are never re-routed (default: 1MB)
+}
+
+// UpstreamProxyConfig represents connection settings for a single upstream
+type UpstreamProxyConfig struct {
+	TLS            UpstreamTLSConfig `yaml:"tls"`
+	ConnectTimeout time.Duration     `yaml:"connect_timeout"` // Overrides ProxyConfig.ConnectTimeout
+	KeepAlive      time.Duration     `yaml:"keep_alive"`      // TCP keep-alive period, overrides ProxyConfig.KeepAliveTimeout (negative disables)
+	TCPNoDelay     *bool             `yaml:"tcp_no_delay"`    // Disable Nagle's algorithm (default: true)
+	DualStack      *bool             `yaml:"dual_stack"`      // Race IPv4 and IPv6 connection attempts (default: true)
+	FallbackDelay  time.Duration     `yaml:"fallback_delay"`  // Delay before the dual-stack fallback attempt (default: 300ms)
+}
+
+// UpstreamTLSConfig represents TLS configuration for connections to an upstream
+type UpstreamTLSConfig struct {
+	Enabled            bool   `yaml:"enabled"`
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify"` // Only for development
+	CertFile           string `yaml:"cert_file"`            // Client certificate for mTLS
+	KeyFile            string `yaml:"key_file"`
+	CAFile             string `yaml:"ca_file"`              // CA bundle to verify the upstream, system roots if empty
+	ServerName         string `yaml:"server_name"`          // SNI and verification name override
 }
 
 // WebSocketConfig represents WebSocket proxy configuration
@@ -116,6 +376,8 @@ type HealthCheckConfig struct {
 	UnhealthyThreshold  int                     `yaml:"unhealthy_threshold"`
 	Path                string                  `yaml:"path"`
 	Passive             PassiveHealthCheckConfig `yaml:"passive"`
+	StartupGracePeriod  time.Duration           `yaml:"startup_grace_period"` // Failed checks don't mark targets unhealthy for this long after startup
+	WaitForFirstCheck   bool                    `yaml:"wait_for_first_check"` // Readiness reports not ready until every target has been checked once, the first result applying without thresholds
 }
 
 // PassiveHealthCheckConfig represents passive health check configuration
@@ -134,10 +396,11 @@ type RateLimitConfig struct {
 	Enabled            bool                    `yaml:"enabled"`
 	DefaultRate        int                     `yaml:"default_rate"`
 	Burst              int                     `yaml:"burst"`
-	Storage            string                  `yaml:"storage"`
+	Storage            string                  `yaml:"storage"` // memory (node-local, default), redis or dynamodb (shared across nodes, falling back to memory while failing)
 	Redis              RedisConfig             `yaml:"redis"`
+	DynamoDB           DynamoDBConfig          `yaml:"dynamodb"`
 	Strategy           string                  `yaml:"strategy"`           // fixed_window, sliding_window, token_bucket, leaky_bucket
-	IdentifierStrategy string                  `yaml:"identifier_strategy"` // ip, user, api_key, combined
+	IdentifierStrategy string                  `yaml:"identifier_strategy"` // ip, user, api_key, combined, or components joined with + such as api_key+route_id (components: ip, user, api_key, route_id, header:<name>)
 	WindowSize         time.Duration           `yaml:"window_size"`
 	CleanupInterval    time.Duration           `yaml:"cleanup_interval"`
 	SkipSuccessful     bool                    `yaml:"skip_successful_requests"`
@@ -146,6 +409,21 @@ type RateLimitConfig struct {
 	ExcludedPaths      []string                `yaml:"excluded_paths"`
 	ExcludedIPs        []string                `yaml:"excluded_ips"`
 	PerRoute           map[string]RouteRateLimit `yaml:"per_route"`
+	Connections        ConnectionLimitConfig   `yaml:"connections"` // Limits for WebSocket upgrades and stream listeners
+}
+
+// ConnectionLimitConfig represents per client IP limits on long-lived
+// connections, enforced before a connection to the upstream is made.
+// Request rate limits don't cover these connections once established.
+type ConnectionLimitConfig struct {
+	Enabled      bool     `yaml:"enabled"`
+	MaxPerIP     int      `yaml:"max_per_ip"`     // Concurrent connections per client IP (0: unlimited)
+	NewPerSecond float64  `yaml:"new_per_second"` // New connections per second per client IP (0: unlimited)
+	Burst        int      `yaml:"burst"`          // New connections allowed at once (default: new_per_second, at least 1)
+	ExemptCIDRs  []string `yaml:"exempt_cidrs"`   // Client IPs or CIDRs that are never limited
+
+	// PerRoute replaces the limits of WebSocket upgrades by route ID
+	PerRoute map[string]ConnectionLimitConfig `yaml:"per_route,omitempty"`
 }
 
 // RouteRateLimit represents per-route rate limiting configuration
@@ -163,6 +441,20 @@ type RedisConfig struct {
 	DB       int    `yaml:"db"`
 }
 
+// DynamoDBConfig represents DynamoDB configuration. The table's partition
+// key must be the string attribute "key"; enable "expires_at" as its TTL
+// attribute so expired items are deleted. Credentials and region default to
+// the AWS SDK's default chain, including IRSA, ECS task roles and instance
+// profiles.
+type DynamoDBConfig struct {
+	Table           string `yaml:"table"`
+	Region          string `yaml:"region"`
+	Endpoint        string `yaml:"endpoint"` // default: the regional endpoint, set for DynamoDB Local
+	AccessKeyID     string `yaml:"access_key_id"`
+	SecretAccessKey string `yaml:"secret_access_key"`
+	SessionToken    string `yaml:"session_token"`
+}
+
 // CanaryConfig represents canary deployment configuration
 type CanaryConfig struct {
 	Enabled bool                    `yaml:"enabled"`
@@ -229,6 +521,21 @@ type AuthConfig struct {
 	JWT     JWTConfig      `yaml:"jwt"`
 	APIKey  APIKeyConfig   `yaml:"api_key"`
 	OAuth2  OAuth2Config   `yaml:"oauth2"`
+	Shadow  AuthShadowConfig `yaml:"shadow"` // Secondary auth configuration evaluated without enforcing it
+}
+
+// AuthShadowConfig represents a secondary auth configuration evaluated in the
+// background beside the active one. Its decisions are logged and counted but
+// never change the outcome of requests, to validate an auth migration before
+// cutting over.
+type AuthShadowConfig struct {
+	Enabled       bool          `yaml:"enabled"`
+	JWT           JWTConfig     `yaml:"jwt"`
+	APIKey        APIKeyConfig  `yaml:"api_key"`
+	OAuth2        OAuth2Config  `yaml:"oauth2"`
+	Routes        []string      `yaml:"routes"`         // IDs of the routes evaluated, all routes if empty
+	Timeout       time.Duration `yaml:"timeout"`        // Time an evaluation may take (default: 1s)
+	MaxConcurrent int           `yaml:"max_concurrent"` // Evaluations in flight, others are skipped (default: 100)
 }
 
 // JWTConfig represents JWT configuration
@@ -240,6 +547,25 @@ type JWTConfig struct {
 	Issuer    string        `yaml:"issuer"`
 	Audience  string        `yaml:"audience"`
 	JWKSURL   string        `yaml:"jwks_url"`
+
+	Cache      JWTCacheConfig      `yaml:"cache"`      // Verified token cache
+	Revocation JWTRevocationConfig `yaml:"revocation"` // Revoked token IDs, checked on every request
+}
+
+// JWTCacheConfig represents the cache of verified JWTs, which skips signature
+// verification for tokens seen recently
+type JWTCacheConfig struct {
+	Enabled    bool          `yaml:"enabled"`
+	MaxEntries int           `yaml:"max_entries"` // Maximum cached tokens (default: 10000)
+	TTL        time.Duration `yaml:"ttl"`         // Time a verified token is cached, capped by its exp (default: 5m)
+}
+
+// JWTRevocationConfig represents the denylist of revoked token IDs (jti).
+// Revoked tokens are rejected whether or not they are cached.
+type JWTRevocationConfig struct {
+	JTIs            []string      `yaml:"jtis"`             // Revoked token IDs
+	File            string        `yaml:"file"`             // File of revoked token IDs, one per line, reloaded when modified
+	RefreshInterval time.Duration `yaml:"refresh_interval"` // How often the file is checked for changes (default: 30s)
 }
 
 // APIKeyConfig represents API key configuration
@@ -261,6 +587,13 @@ type OAuth2Config struct {
 	CacheEnabled     bool              `yaml:"cache_enabled"`
 	CacheTTL         time.Duration     `yaml:"cache_ttl"`
 	Headers          map[string]string `yaml:"headers"`
+
+	// Retries back off exponentially from RetryDelay with random jitter,
+	// within MaxElapsedTime and the deadline of the client's request
+	RetryMultiplier float64       `yaml:"retry_multiplier"` // Growth of the delay per retry (default: 2)
+	MaxRetryDelay   time.Duration `yaml:"max_retry_delay"`  // Cap of a single retry delay (default: 10s)
+	RetryJitter     float64       `yaml:"retry_jitter"`     // Fraction of each delay randomized, 0 to 1 (default: 0.5)
+	MaxElapsedTime  time.Duration `yaml:"max_elapsed_time"` // Total time of an introspection across retries (default: timeout)
 }
 
 // IPACLConfig represents IP access control configuration
@@ -296,6 +629,17 @@ type AccessLogConfig struct {
 	Enabled bool   `yaml:"enabled"`
 	Format  string `yaml:"format"`
 	Output  string `yaml:"output"`
+
+	ExcludePaths []string                        `yaml:"exclude_paths"` // Path prefixes never logged, such as health checks
+	Routes       map[string]AccessLogRouteConfig `yaml:"routes"`        // Per-route overrides keyed by route ID
+}
+
+// AccessLogRouteConfig represents access logging of a route. Unset fields
+// default to the global access log configuration.
+type AccessLogRouteConfig struct {
+	Enabled    *bool             `yaml:"enabled"`     // Log the route's requests (default: true)
+	SampleRate *float64          `yaml:"sample_rate"` // Fraction of the route's requests logged, 0.0 - 1.0 (default: 1.0)
+	Fields     map[string]string `yaml:"fields"`      // Extra fields added to the route's entries
 }
 
 // AuditLogConfig represents audit log configuration
@@ -318,11 +662,21 @@ type MetricsConfig struct {
 	EnabledMetrics    map[string]bool         `yaml:"enabled_metrics"`             // Enable/disable specific metrics
 	CustomLabels      map[string]string       `yaml:"custom_labels"`               // Custom labels to add
 	SampleRate        float64                 `yaml:"sample_rate"`                 // Sampling rate for high traffic
-	LabelExtractors   map[string]string       `yaml:"label_extractors"`            // Custom label extraction rules
+	LabelExtractors   map[string]string       `yaml:"label_extractors"`            // Label name to registered label extractor name
 	SensitiveLabels   []string                `yaml:"sensitive_labels"`            // Labels to filter out
+	HashedLabels      []string                `yaml:"hashed_labels"`               // Labels whose values are hashed
 	MaxLabelLength    int                     `yaml:"max_label_length"`            // Maximum label value length
 	AsyncUpdates      bool                    `yaml:"async_updates"`               // Enable async metric updates
 	BufferSize        int                     `yaml:"buffer_size"`                 // Buffer size for async updates
+	RouteGroups       map[string]MetricsRouteGroup `yaml:"route_groups"`          // Route groups with request metrics of their own, by group name
+}
+
+// MetricsRouteGroup records the request metrics of a set of routes apart from
+// the others, under its own subsystem and labels
+type MetricsRouteGroup struct {
+	Routes    []string          `yaml:"routes"`    // IDs of the routes in the group
+	Subsystem string            `yaml:"subsystem"` // Prefix of the names of the group's request metrics
+	Labels    map[string]string `yaml:"labels"`    // Constant labels of the group's request metrics
 }
 
 // PrometheusConfig represents Prometheus configuration (kept for backward compatibility)
@@ -389,6 +743,17 @@ type EtcdSourceConfig struct {
 	TLS       TLSConfig     `yaml:"tls"`           // TLS configuration
 	Username  string        `yaml:"username"`      // Authentication username
 	Password  string        `yaml:"password"`      // Authentication password
+
+	// Connection tuning. Failed reads are retried and a broken watch is
+	// re-established with exponential backoff; while etcd stays unavailable
+	// the node keeps serving the last known good configuration and reports
+	// the config source as degraded in health.
+	DialKeepAliveTime    time.Duration `yaml:"dial_keepalive_time"`    // Interval of keepalive pings (default: disabled)
+	DialKeepAliveTimeout time.Duration `yaml:"dial_keepalive_timeout"` // Wait for a keepalive response before reconnecting
+	AutoSyncInterval     time.Duration `yaml:"auto_sync_interval"`     // Interval of refreshing endpoints from the cluster members (default: disabled)
+	MaxRetries           int           `yaml:"max_retries"`            // Retries of a failed read (default: 3)
+	RetryBackoff         time.Duration `yaml:"retry_backoff"`          // Delay before the first retry, doubling per retry (default: 500ms)
+	MaxRetryBackoff      time.Duration `yaml:"max_retry_backoff"`      // Cap of a retry delay (default: 30s)
 }
 
 // SyncConfig represents synchronization configuration
@@ -421,6 +786,27 @@ type AdminAPIConfig struct {
 	Auth AuthConfig `yaml:"auth"`
 }
 
+// AdminAuthConfigured reports whether the Admin API authentication is
+// enabled with credentials to authenticate with: API keys, a JWT secret or
+// API keys bound to tenants
+func (c *Config) AdminAuthConfigured() bool {
+	auth := c.AdminAPI.Auth
+	if !auth.Enabled {
+		return false
+	}
+	if len(auth.APIKey.Keys) > 0 || auth.JWT.Secret != "" {
+		return true
+	}
+	if c.Tenancy.Enabled {
+		for _, tenant := range c.Tenancy.Tenants {
+			if len(tenant.APIKeys) > 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 // RESTConfig represents REST API configuration
 type RESTConfig struct {
 	Enabled bool   `yaml:"enabled"`
@@ -433,6 +819,24 @@ type GRPCConfig struct {
 	Port    int  `yaml:"port"`
 }
 
+// TenancyConfig represents tenant scoping of routes and upstreams. Tenants'
+// routes and upstreams are stored under tenants/<tenant>/ and only seen by
+// Admin API callers of the tenant; the default tenant, named "", keeps the
+// keys of single-tenant deployments. Requests are matched against the
+// routes of the tenant serving their host.
+type TenancyConfig struct {
+	Enabled  bool                    `yaml:"enabled"`
+	Header   string                  `yaml:"header"`    // Admin API header operators select a tenant with (default: X-Stargate-Tenant)
+	JWTClaim string                  `yaml:"jwt_claim"` // Admin API JWT claim binding callers to a tenant (default: tenant)
+	Tenants  map[string]TenantConfig `yaml:"tenants"`   // Tenants by name
+}
+
+// TenantConfig represents a tenant's hosts and Admin API keys
+type TenantConfig struct {
+	Hosts   []string `yaml:"hosts"`    // Request hosts served the tenant's routes, other hosts are served the default tenant's
+	APIKeys []string `yaml:"api_keys"` // Admin API keys bound to the tenant, accepted in addition to admin_api.auth.api_key.keys
+}
+
 // RoutesConfig represents routes configuration
 type RoutesConfig struct {
 	Defaults RouteDefaults `yaml:"defaults"`
@@ -448,6 +852,73 @@ type RouteDefaults struct {
 // UpstreamsConfig represents upstreams configuration
 type UpstreamsConfig struct {
 	Defaults UpstreamDefaults `yaml:"defaults"`
+	Passive  map[string]PassiveHealthOverrideConfig `yaml:"passive"` // Per-upstream passive health thresholds keyed by upstream ID
+	Concurrency map[string]UpstreamConcurrencyConfig `yaml:"concurrency"` // Per-upstream concurrency limits keyed by upstream ID
+	Priority UpstreamPriorityConfig `yaml:"priority"`
+	Affinity map[string]SessionAffinityConfig `yaml:"affinity"` // Cookie-based session affinity keyed by upstream ID
+	AffinitySecret string `yaml:"affinity_secret"` // Signs affinity cookies, the same on every node (default: random per process)
+	DebugLog UpstreamDebugLogConfig `yaml:"debug_log"`
+}
+
+// UpstreamDebugLogConfig represents the logging of full requests and
+// responses proxied to an upstream, turned on for one upstream at a time
+// through the Admin API and turned off automatically after a duration.
+// Sensitive headers are redacted like tap captures.
+type UpstreamDebugLogConfig struct {
+	DefaultDuration time.Duration `yaml:"default_duration"` // Time debug logging stays on unless given (default: 10m)
+	MaxDuration     time.Duration `yaml:"max_duration"`     // Longest time debug logging may stay on (default: 1h)
+	MaxBodySize     int64         `yaml:"max_body_size"`    // Logged bytes per body (default: 4KB)
+	RedactHeaders   []string      `yaml:"redact_headers"`   // Headers redacted in addition to Authorization, Cookie, Set-Cookie and Proxy-Authorization
+	Output          string        `yaml:"output"`           // stdout (default), stderr or a file path
+}
+
+// UpstreamConcurrencyConfig limits the requests proxied to a single upstream
+// at once. Requests over the limit wait in a bounded queue for a free slot;
+// they are rejected with 503 when the queue is full or the wait exceeds
+// QueueTimeout.
+type UpstreamConcurrencyConfig struct {
+	MaxConcurrent int           `yaml:"max_concurrent"` // Requests proxied at once
+	QueueSize     int           `yaml:"queue_size"`     // Requests waiting for a slot (0: reject when at capacity)
+	QueueTimeout  time.Duration `yaml:"queue_timeout"`  // Longest wait for a slot (default: 1s)
+}
+
+// SessionAffinityConfig pins the clients of an upstream to one of its
+// targets with a gateway-issued cookie. The first request picks a target
+// with the upstream's load balancer and sets the cookie; later requests
+// carrying it go to the same target while it is healthy, and are pinned to a
+// new target once it isn't or the cookie expires. Cookies are signed with
+// UpstreamsConfig.AffinitySecret and ignored when the signature doesn't
+// match. Requests reaching the upstream as a route fallback are pinned too;
+// requests re-routed to it by a response follow their pin but aren't
+// pinned, and stream proxies don't use affinity.
+type SessionAffinityConfig struct {
+	CookieName string        `yaml:"cookie_name"` // default: stargate_affinity_<upstream ID>
+	Path       string        `yaml:"path"`        // default: /
+	TTL        time.Duration `yaml:"ttl"`         // How long a client stays pinned (default: 1h)
+	Secure     bool          `yaml:"secure"`
+	HTTPOnly   bool          `yaml:"http_only"`
+	SameSite   string        `yaml:"same_site"` // lax (default), strict or none
+}
+
+// UpstreamPriorityConfig orders the requests waiting for a slot of an
+// upstream concurrency limit by the tier of their consumer. Freed slots go to
+// the highest queued tier first, and a full queue sheds its lowest-tier
+// request to admit a request of a higher tier.
+type UpstreamPriorityConfig struct {
+	Tiers       []string `yaml:"tiers"`        // Tier names, highest priority first (empty: arrival order)
+	Resolver    string   `yaml:"resolver"`     // Registered tier resolver (default: auth)
+	Key         string   `yaml:"key"`          // Consumer metadata key, JWT claim or header holding the tier (default: tier)
+	DefaultTier string   `yaml:"default_tier"` // Tier of requests without a known tier (default: the lowest tier)
+}
+
+// PassiveHealthOverrideConfig overrides the default passive health thresholds
+// for a single upstream. Unset fields use Defaults.HealthCheck.Passive.
+type PassiveHealthOverrideConfig struct {
+	ConsecutiveFailures  int           `yaml:"consecutive_failures"`  // Failures isolating a target
+	IsolationDuration    time.Duration `yaml:"isolation_duration"`    // Time before an isolated target may recover
+	ConsecutiveSuccesses int           `yaml:"consecutive_successes"` // Successes recovering an isolated target
+	FailureStatusCodes   []int         `yaml:"failure_status_codes"`  // Status codes counted as failures
+	TimeoutAsFailure     *bool         `yaml:"timeout_as_failure"`
 }
 
 // UpstreamDefaults represents default upstream settings
@@ -475,6 +946,8 @@ type WebhookConfig struct {
 	URL        string        `yaml:"url"`
 	Timeout    time.Duration `yaml:"timeout"`
 	RetryCount int           `yaml:"retry_count"`
+	// Secret signs payloads with HMAC-SHA256 so receivers can verify them
+	Secret     string        `yaml:"secret"`
 }
 
 // HeaderTransformConfig represents header transformation middleware configuration
@@ -500,6 +973,150 @@ type HeaderTransformRule struct {
 	ResponseHeaders HeaderTransformRules `yaml:"response_headers"`
 }
 
+// SecurityHeadersConfig represents security response header configuration.
+// Security headers are injected into every response and leaking upstream
+// headers are stripped. Empty values fall back to the middleware defaults.
+type SecurityHeadersConfig struct {
+	Enabled               bool                           `yaml:"enabled"`
+	HSTS                  HSTSConfig                     `yaml:"hsts"`
+	ContentTypeOptions    string                         `yaml:"content_type_options"`    // X-Content-Type-Options (default: nosniff)
+	FrameOptions          string                         `yaml:"frame_options"`           // X-Frame-Options (default: DENY)
+	ReferrerPolicy        string                         `yaml:"referrer_policy"`         // Referrer-Policy (default: strict-origin-when-cross-origin)
+	ContentSecurityPolicy string                         `yaml:"content_security_policy"` // Content-Security-Policy (not set if empty)
+	PermissionsPolicy     string                         `yaml:"permissions_policy"`      // Permissions-Policy (not set if empty)
+	CustomHeaders         map[string]string              `yaml:"custom_headers"`          // Additional headers to inject
+	RemoveHeaders         []string                       `yaml:"remove_headers"`          // Headers to strip (default: Server, X-Powered-By and framework version headers)
+	KeepHeaders           []string                       `yaml:"keep_headers"`            // Headers exempt from stripping
+	PerRoute              map[string]SecurityHeadersRoute `yaml:"per_route"`
+}
+
+// HSTSConfig represents Strict-Transport-Security configuration. The header
+// is only sent on TLS connections.
+type HSTSConfig struct {
+	Enabled           bool          `yaml:"enabled"`
+	MaxAge            time.Duration `yaml:"max_age"` // default: 1 year
+	IncludeSubDomains bool          `yaml:"include_subdomains"`
+	Preload           bool          `yaml:"preload"`
+}
+
+// TapConfig represents request capture configuration. Sampled request and
+// response pairs are kept in a ring buffer served at Path and can optionally
+// be appended to a file as JSON lines. Captures hold request and response
+// bodies, so the tap requires the Admin API authentication enabled with
+// credentials.
+type TapConfig struct {
+	Enabled          bool     `yaml:"enabled"`
+	Routes           []string `yaml:"routes"`            // Route IDs to capture
+	Paths            []string `yaml:"paths"`             // Path prefixes to capture, all requests if Routes and Paths are empty
+	Methods          []string `yaml:"methods"`           // Methods to capture, all if empty
+	SampleRate       float64  `yaml:"sample_rate"`       // 0.0 - 1.0
+	MaxBodySize      int64    `yaml:"max_body_size"`     // Captured bytes per body (default: 64KB)
+	BufferSize       int      `yaml:"buffer_size"`       // Captures kept in memory (default: 100)
+	RedactHeaders    []string `yaml:"redact_headers"`    // Headers redacted in addition to Authorization, Cookie, Set-Cookie and Proxy-Authorization
+	DisableRedaction bool     `yaml:"disable_redaction"` // Keep sensitive headers in captures
+	File             string   `yaml:"file"`              // Optional JSON lines output file
+	Path             string   `yaml:"path"`              // Admin endpoint serving captures (default: /_stargate/tap)
+}
+
+// ExperimentsConfig represents A/B experiment assignment. Each request is
+// deterministically bucketed into a variant of every experiment it matches,
+// and the variant is sent upstream as X-Experiment-<name>.
+type ExperimentsConfig struct {
+	Enabled     bool         `yaml:"enabled"`
+	Experiments []Experiment `yaml:"experiments"`
+}
+
+// Experiment represents one A/B experiment
+type Experiment struct {
+	Name     string              `yaml:"name"`     // Used in the X-Experiment-<name> header
+	Routes   []string            `yaml:"routes"`   // Route IDs in the experiment
+	Paths    []string            `yaml:"paths"`    // Path prefixes in the experiment, all requests if Routes and Paths are empty
+	Key      string              `yaml:"key"`      // Assignment key: ip, user, header or cookie (default: ip)
+	KeyName  string              `yaml:"key_name"` // Header or cookie name for the header and cookie keys
+	Variants []ExperimentVariant `yaml:"variants"`
+	Cookie   ExperimentCookie    `yaml:"cookie"`
+}
+
+// ExperimentVariant represents a variant and its share of traffic
+type ExperimentVariant struct {
+	Name   string `yaml:"name"`
+	Weight int    `yaml:"weight"`
+}
+
+// ExperimentCookie represents the sticky cookie recording a variant. A
+// valid cookie takes precedence over the assignment key, so assignments
+// survive weight changes.
+type ExperimentCookie struct {
+	Enabled  bool          `yaml:"enabled"`
+	Name     string        `yaml:"name"`    // default: stargate_exp_<experiment name>
+	Path     string        `yaml:"path"`    // default: /
+	MaxAge   time.Duration `yaml:"max_age"` // default: 30 days
+	Secure   bool          `yaml:"secure"`
+	HTTPOnly bool          `yaml:"http_only"`
+}
+
+// BodyChecksumConfig represents request body checksum validation. The body
+// of a request carrying a Content-MD5 or RFC 3230 Digest header (SHA-256 or
+// MD5) is verified against it before forwarding, and the request is rejected
+// with 400 on mismatch.
+type BodyChecksumConfig struct {
+	Enabled     bool     `yaml:"enabled"`
+	Routes      []string `yaml:"routes"`        // Route IDs to validate
+	Paths       []string `yaml:"paths"`         // Path prefixes to validate, all requests if Routes and Paths are empty
+	Required    bool     `yaml:"required"`      // Reject requests without a checksum header with 400
+	MaxBodySize int64    `yaml:"max_body_size"` // Largest body validated, larger bodies are rejected with 413 (default: 1MB)
+}
+
+// SchemaValidationConfig represents validation of JSON bodies against JSON
+// Schemas, opted into per route. A request body not matching its route's
+// request schema is rejected with 400 listing the validation errors. A 2xx
+// JSON response not matching the route's response schema is replaced by a
+// 502, so clients never see responses breaking the contract. Schemas are
+// compiled when the configuration is loaded and an invalid schema fails the
+// load.
+type SchemaValidationConfig struct {
+	Enabled     bool                         `yaml:"enabled"`
+	Routes      map[string]RouteSchemaConfig `yaml:"routes"`        // Schemas by route ID
+	MaxBodySize int64                        `yaml:"max_body_size"` // Largest body validated, larger requests are rejected with 413 and larger responses pass unvalidated (default: 1MB)
+}
+
+// RouteSchemaConfig represents the JSON Schemas of a route's bodies, each
+// given inline or as the path of a JSON or YAML file. Without a request
+// schema requests aren't validated, and without a response schema responses
+// aren't.
+type RouteSchemaConfig struct {
+	Request      map[string]interface{} `yaml:"request"`
+	RequestFile  string                 `yaml:"request_file"`
+	Response     map[string]interface{} `yaml:"response"`
+	ResponseFile string                 `yaml:"response_file"`
+}
+
+// IdempotencyConfig represents request deduplication by idempotency key.
+// The response to a request carrying the key header is stored and replayed
+// for retries with the same key within TTL. Reusing a key with a different
+// request is rejected with 422, and a retry arriving while the original is
+// still in flight waits up to WaitTimeout before being rejected with 409.
+type IdempotencyConfig struct {
+	Enabled         bool          `yaml:"enabled"`
+	Routes          []string      `yaml:"routes"`            // Route IDs to deduplicate
+	Paths           []string      `yaml:"paths"`             // Path prefixes to deduplicate, all requests if Routes and Paths are empty
+	Methods         []string      `yaml:"methods"`           // default: POST, PUT, PATCH and DELETE
+	Header          string        `yaml:"header"`            // default: Idempotency-Key
+	TTL             time.Duration `yaml:"ttl"`               // How long responses are replayed (default: 24h)
+	LockTimeout     time.Duration `yaml:"lock_timeout"`      // How long an in-flight request holds its key (default: 30s)
+	WaitTimeout     time.Duration `yaml:"wait_timeout"`      // How long a concurrent retry waits for the original, 0 rejects at once
+	MaxBodySize     int64         `yaml:"max_body_size"`     // Largest request body accepted with a key (default: 1MB)
+	MaxResponseSize int64         `yaml:"max_response_size"` // Larger responses aren't stored (default: 1MB)
+	Storage         string        `yaml:"storage"`           // memory (node-local, default) or redis (shared across nodes)
+	Redis           RedisConfig   `yaml:"redis"`
+}
+
+// SecurityHeadersRoute represents per-route security header overrides
+type SecurityHeadersRoute struct {
+	Disabled bool              `yaml:"disabled"` // Skip security headers for the route
+	Headers  map[string]string `yaml:"headers"`  // Header values overriding the global ones, an empty value skips the header
+}
+
 // MockResponseConfig represents mock response middleware configuration
 type MockResponseConfig struct {
 	Enabled  bool                       `yaml:"enabled"`
@@ -591,6 +1208,16 @@ type AggregatorConfig struct {
 	Routes         []AggregateRoute `yaml:"routes"`
 	DefaultTimeout time.Duration   `yaml:"default_timeout"`
 	MaxConcurrency int             `yaml:"max_concurrency"`
+	CircuitBreaker CircuitBreakerConfig `yaml:"circuit_breaker"` // Per-upstream circuit breakers for aggregate routes
+	FallbackCache  AggregatorFallbackCacheConfig `yaml:"fallback_cache"`
+}
+
+// AggregatorFallbackCacheConfig represents the last-known-good response cache
+// used by upstream requests with a fallback cache key. A cached body is served
+// in place of a failing or breaker-open upstream until it is older than MaxStale.
+type AggregatorFallbackCacheConfig struct {
+	MaxEntries int           `yaml:"max_entries"` // Maximum cached upstream bodies (default: 1000)
+	MaxStale   time.Duration `yaml:"max_stale"`   // Maximum age of a body served as fallback (default: 1h)
 }
 
 // AggregateRoute represents a single aggregate route configuration
@@ -611,6 +1238,10 @@ type UpstreamRequest struct {
 	Headers  map[string]string `yaml:"headers,omitempty" json:"headers,omitempty"`
 	Timeout  time.Duration     `yaml:"timeout,omitempty" json:"timeout,omitempty"`
 	Required bool              `yaml:"required,omitempty" json:"required,omitempty"`
+
+	// FallbackCacheKey names the cache entry holding the last successful body
+	// of this upstream, served as a stale part when the upstream fails
+	FallbackCacheKey string `yaml:"fallback_cache_key,omitempty" json:"fallback_cache_key,omitempty"`
 }
 
 // ServerlessConfig represents serverless function integration configuration
@@ -618,6 +1249,20 @@ type ServerlessConfig struct {
 	Enabled        bool              `yaml:"enabled"`
 	Rules          []ServerlessRule  `yaml:"rules"`
 	DefaultTimeout time.Duration     `yaml:"default_timeout"`
+	Callout        CalloutConfig     `yaml:"callout"`
+	CacheSize      int               `yaml:"cache_size"` // Maximum cached function results (default: 10000)
+}
+
+// CalloutConfig represents the guarded outbound HTTP calls available to plugins and functions.
+// Results of callouts requested by a pre-process function are passed to the next one, so
+// callouts requested by the last pre-process function are rejected. Callouts are counted by
+// source and result in callouts_total, their latency in callout_duration_seconds.
+type CalloutConfig struct {
+	Enabled         bool          `yaml:"enabled"`
+	AllowedHosts    []string      `yaml:"allowed_hosts"`     // Destination allowlist, "host", "host:port" or "*.domain"
+	Timeout         time.Duration `yaml:"timeout"`           // Per-callout timeout (default: 1s)
+	MaxConcurrent   int           `yaml:"max_concurrent"`    // Maximum callouts in flight (default: 10)
+	MaxResponseSize int64         `yaml:"max_response_size"` // Maximum response body size (default: 1MB)
 }
 
 // ServerlessRule represents a rule for when to execute serverless functions
@@ -628,6 +1273,11 @@ type ServerlessRule struct {
 	Headers     map[string]string        `yaml:"headers,omitempty" json:"headers,omitempty"`
 	PreProcess  []ServerlessFunction     `yaml:"pre_process,omitempty" json:"pre_process,omitempty"`
 	PostProcess []ServerlessFunction     `yaml:"post_process,omitempty" json:"post_process,omitempty"`
+
+	// MaxBodyBytes caps the body buffered for functions. Larger requests are
+	// rejected with 413 before any function is called; larger responses are
+	// not passed to post-process functions that need the body. Zero disables the cap.
+	MaxBodyBytes int64 `yaml:"max_body_bytes,omitempty" json:"max_body_bytes,omitempty"`
 }
 
 // ServerlessFunction represents a single serverless function configuration
@@ -640,14 +1290,59 @@ type ServerlessFunction struct {
 	Timeout    time.Duration     `yaml:"timeout,omitempty" json:"timeout,omitempty"`
 	RetryCount int               `yaml:"retry_count,omitempty" json:"retry_count,omitempty"`
 	OnError    string            `yaml:"on_error,omitempty" json:"on_error,omitempty"` // continue, abort
+	Phase      string            `yaml:"phase,omitempty" json:"phase,omitempty"`       // auth or request for pre-process (default: request), response for post-process
+	Priority   int               `yaml:"priority,omitempty" json:"priority,omitempty"` // Higher priority runs first within a phase
+	Cache      *ServerlessCache  `yaml:"cache,omitempty" json:"cache,omitempty"`
+	OmitBody   bool              `yaml:"omit_body,omitempty" json:"omit_body,omitempty"` // Send only method, path, headers and query; the body is not buffered
+}
+
+// ServerlessCache configures caching of a function's successful results.
+// Results are keyed by the function, request method and path, plus the
+// configured request attributes. A function can opt out per response with
+// a "Cache-Control: no-store" or "no-cache" header.
+type ServerlessCache struct {
+	TTL         time.Duration `yaml:"ttl" json:"ttl"`
+	KeyHeaders  []string      `yaml:"key_headers,omitempty" json:"key_headers,omitempty"`     // Request headers included in the cache key
+	KeyQuery    []string      `yaml:"key_query,omitempty" json:"key_query,omitempty"`         // Query parameters included in the cache key
+	KeyClientIP bool          `yaml:"key_client_ip,omitempty" json:"key_client_ip,omitempty"` // Include the client IP in the cache key
+	KeyBody     bool          `yaml:"key_body,omitempty" json:"key_body,omitempty"`           // Include the request body in the cache key
 }
 
 // WASMConfig represents WASM plugin configuration
 type WASMConfig struct {
-	Enabled bool         `yaml:"enabled"`
-	Plugins []WASMPlugin `yaml:"plugins"`
-	Rules   []WASMRule   `yaml:"rules"`
-}
+	Enabled        bool          `yaml:"enabled"`
+	Plugins        []WASMPlugin  `yaml:"plugins"`
+	Rules          []WASMRule    `yaml:"rules"`
+	HotReload      bool          `yaml:"hot_reload"`      // Reload plugins when their .wasm file changes
+	ReloadInterval time.Duration `yaml:"reload_interval"` // Interval for checking plugin files (default: 2s)
+	KV             WASMKVConfig  `yaml:"kv"`
+	Callout        CalloutConfig `yaml:"callout"`
+}
+
+// WASMKVConfig represents the key-value store exposed to WASM plugins
+type WASMKVConfig struct {
+	Storage      string      `yaml:"storage"`        // memory (node-local, default) or redis (shared across nodes)
+	Redis        RedisConfig `yaml:"redis"`
+	MaxKeys      int         `yaml:"max_keys"`       // Maximum keys per plugin (default: 1000)
+	MaxBytes     int         `yaml:"max_bytes"`      // Maximum key and value bytes per plugin (default: 1MB)
+	MaxValueSize int         `yaml:"max_value_size"` // Maximum size of a single value (default: 64KB)
+}
+
+// Plugin phases. WASM plugins and serverless functions run in one of these
+// phases; within a phase, plugins with a higher priority run first and
+// plugins with equal priority keep their configured order.
+//
+//   - auth runs directly after the built-in auth middleware, so plugins can
+//     make access decisions before any other request processing
+//   - request runs before the request is forwarded (default)
+//   - response runs after the upstream responded and can rewrite the response
+//
+// Within the same phase, serverless functions run before WASM plugins.
+const (
+	PluginPhaseAuth     = "auth"
+	PluginPhaseRequest  = "request"
+	PluginPhaseResponse = "response"
+)
 
 // WASMPlugin represents a single WASM plugin configuration
 type WASMPlugin struct {
@@ -655,6 +1350,8 @@ type WASMPlugin struct {
 	Name     string `yaml:"name" json:"name"`
 	Path     string `yaml:"path" json:"path"`
 	Required bool   `yaml:"required,omitempty" json:"required,omitempty"`
+	Phase    string `yaml:"phase,omitempty" json:"phase,omitempty"`       // auth, request or response (default: request)
+	Priority int    `yaml:"priority,omitempty" json:"priority,omitempty"` // Higher priority runs first within a phase
 }
 
 // WASMRule represents a rule for when to execute WASM plugins
@@ -673,6 +1370,7 @@ type PortalConfig struct {
 	JWT        PortalJWTConfig      `yaml:"jwt"`
 	Repository PortalRepositoryConfig `yaml:"repository"`
 	CORS       PortalCORSConfig     `yaml:"cors"`
+	Scopes     []string             `yaml:"scopes"` // Scopes applications may be granted (default: any well-formed scope)
 }
 
 // PortalJWTConfig represents JWT configuration for portal
@@ -702,6 +1400,10 @@ type PortalPostgresConfig struct {
 	MaxIdleConns    int           `yaml:"max_idle_conns"`
 	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime"`
 	MigrationPath   string        `yaml:"migration_path"`
+	// SecretHashAlgorithm hashes API secrets at rest: bcrypt (default) or
+	// argon2id. Secrets stored in plaintext or with another algorithm are
+	// rehashed once verified.
+	SecretHashAlgorithm string `yaml:"secret_hash_algorithm"`
 }
 
 // PortalCORSConfig represents CORS configuration for portal
@@ -13,6 +13,7 @@ import (
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/songzhibin97/stargate/internal/config"
+	"github.com/songzhibin97/stargate/internal/router"
 )
 
 // AuthMiddleware provides authentication middleware for Admin API
@@ -32,12 +33,12 @@ func (am *AuthMiddleware) Middleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Skip authentication if disabled
 		if !am.config.AdminAPI.Auth.Enabled {
-			next.ServeHTTP(w, r)
+			am.serveTenant(w, r, next, "", false)
 			return
 		}
 
-		// Skip authentication for health endpoints
-		if r.URL.Path == "/health" || r.URL.Path == "/metrics" {
+		// Skip authentication for health and metrics endpoints so scrapers don't need credentials
+		if r.URL.Path == "/health" || r.URL.Path == "/metrics" || r.URL.Path == am.config.Metrics.Path {
 			next.ServeHTTP(w, r)
 			return
 		}
@@ -45,7 +46,16 @@ func (am *AuthMiddleware) Middleware(next http.Handler) http.Handler {
 		// Try API Key authentication first
 		if len(am.config.AdminAPI.Auth.APIKey.Keys) > 0 {
 			if am.authenticateAPIKey(r) {
-				next.ServeHTTP(w, r)
+				am.serveTenant(w, r, next, "", false)
+				return
+			}
+		}
+
+		// Try API keys bound to a tenant
+		if am.config.Tenancy.Enabled {
+			apiKey := r.Header.Get(am.config.AdminAPI.Auth.APIKey.Header)
+			if tenant, ok := am.config.Tenancy.TenantForAPIKey(apiKey); ok {
+				am.serveTenant(w, r, next, tenant, true)
 				return
 			}
 		}
@@ -53,7 +63,12 @@ func (am *AuthMiddleware) Middleware(next http.Handler) http.Handler {
 		// Try JWT authentication
 		if am.config.AdminAPI.Auth.JWT.Secret != "" {
 			if am.authenticateJWT(r) {
-				next.ServeHTTP(w, r)
+				tenant, bound := am.jwtTenant(r)
+				if bound && !am.knownTenant(tenant) {
+					writeErrorResponse(w, http.StatusForbidden, "Unknown tenant", nil)
+					return
+				}
+				am.serveTenant(w, r, next, tenant, bound)
 				return
 			}
 		}
@@ -63,6 +78,53 @@ func (am *AuthMiddleware) Middleware(next http.Handler) http.Handler {
 	})
 }
 
+// serveTenant serves a request on behalf of a tenant when tenancy is enabled.
+// Callers bound to a tenant may only act for it, other callers act for the
+// default tenant or select one with the tenancy header.
+func (am *AuthMiddleware) serveTenant(w http.ResponseWriter, r *http.Request, next http.Handler, tenant string, bound bool) {
+	if !am.config.Tenancy.Enabled {
+		next.ServeHTTP(w, r)
+		return
+	}
+
+	if requested := r.Header.Get(am.config.Tenancy.Header); requested != "" {
+		switch {
+		case bound && requested != tenant:
+			writeErrorResponse(w, http.StatusForbidden, "Access to tenant denied", nil)
+			return
+		case !am.knownTenant(requested):
+			writeErrorResponse(w, http.StatusBadRequest, "Unknown tenant", nil)
+			return
+		}
+		tenant = requested
+	}
+
+	next.ServeHTTP(w, r.WithContext(router.WithTenant(r.Context(), tenant)))
+}
+
+// knownTenant reports whether a tenant is configured
+func (am *AuthMiddleware) knownTenant(tenant string) bool {
+	_, exists := am.config.Tenancy.Tenants[tenant]
+	return exists
+}
+
+// jwtTenant returns the tenant the claims of an authenticated JWT bind the
+// caller to, if any
+func (am *AuthMiddleware) jwtTenant(r *http.Request) (string, bool) {
+	if !am.config.Tenancy.Enabled {
+		return "", false
+	}
+	claims, ok := r.Context().Value("jwt_claims").(jwt.MapClaims)
+	if !ok {
+		return "", false
+	}
+	tenant, ok := claims[am.config.Tenancy.JWTClaim].(string)
+	if !ok || tenant == "" {
+		return "", false
+	}
+	return tenant, true
+}
+
 // authenticateAPIKey validates API key authentication
 func (am *AuthMiddleware) authenticateAPIKey(r *http.Request) bool {
 	// Get API key from header
@@ -3,6 +3,8 @@ package api
 import (
 	"encoding/json"
 	"net/http"
+	"sort"
+	"strings"
 )
 
 // OpenAPISpec represents the OpenAPI 3.0 specification for the Admin API
@@ -154,7 +156,7 @@ var OpenAPISpec = map[string]interface{}{
 					},
 					"algorithm": map[string]interface{}{
 						"type":        "string",
-						"enum":        []string{"round_robin", "weighted", "ip_hash"},
+						"enum":        []string{"round_robin", "weighted", "weighted_random", "ip_hash"},
 						"description": "Load balancing algorithm",
 						"example":     "round_robin",
 					},
@@ -418,12 +420,168 @@ var OpenAPISpec = map[string]interface{}{
 	},
 }
 
+// openAPIMethods are the operations of an OpenAPI path item, other keys
+// such as parameters describe the path
+var openAPIMethods = []string{"get", "put", "post", "delete", "options", "head", "patch", "trace"}
+
+// LiveRoute is an Admin API route served by the controller
+type LiveRoute struct {
+	Path    string   `json:"path"`    // OpenAPI path, with {name} path parameters
+	Methods []string `json:"methods"` // HTTP methods served
+}
+
+// OpenAPIOperation is a method of a path
+type OpenAPIOperation struct {
+	Method string `json:"method"`
+	Path   string `json:"path"`
+}
+
+// OpenAPIDriftReport compares an OpenAPI document with the live routes
+type OpenAPIDriftReport struct {
+	InSync       bool               `json:"in_sync"`
+	Undocumented []OpenAPIOperation `json:"undocumented"` // Served but absent from the document
+	Stale        []OpenAPIOperation `json:"stale"`        // Documented but not served
+}
+
+// ValidateOpenAPI cross-checks the paths and methods of an OpenAPI document
+// against the live routes. Path parameters match whatever their names.
+func ValidateOpenAPI(spec map[string]interface{}, routes []LiveRoute) *OpenAPIDriftReport {
+	documented := make(map[OpenAPIOperation]OpenAPIOperation)
+	paths, _ := spec["paths"].(map[string]interface{})
+	for path, item := range paths {
+		operations, _ := item.(map[string]interface{})
+		for _, method := range openAPIMethods {
+			if _, ok := operations[method]; ok {
+				op := OpenAPIOperation{Method: strings.ToUpper(method), Path: path}
+				documented[op.normalized()] = op
+			}
+		}
+	}
+
+	report := &OpenAPIDriftReport{
+		Undocumented: []OpenAPIOperation{},
+		Stale:        []OpenAPIOperation{},
+	}
+	for _, route := range routes {
+		for _, method := range route.Methods {
+			op := OpenAPIOperation{Method: strings.ToUpper(method), Path: route.Path}
+			if _, ok := documented[op.normalized()]; ok {
+				delete(documented, op.normalized())
+				continue
+			}
+			report.Undocumented = append(report.Undocumented, op)
+		}
+	}
+	for _, op := range documented {
+		report.Stale = append(report.Stale, op)
+	}
+
+	sortOperations(report.Undocumented)
+	sortOperations(report.Stale)
+	report.InSync = len(report.Undocumented) == 0 && len(report.Stale) == 0
+	return report
+}
+
+// normalized returns the operation with its path parameters unnamed
+func (op OpenAPIOperation) normalized() OpenAPIOperation {
+	segments := strings.Split(op.Path, "/")
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			segments[i] = "{}"
+		}
+	}
+	return OpenAPIOperation{Method: op.Method, Path: strings.Join(segments, "/")}
+}
+
+// sortOperations sorts operations by path, then method
+func sortOperations(ops []OpenAPIOperation) {
+	sort.Slice(ops, func(i, j int) bool {
+		if ops[i].Path != ops[j].Path {
+			return ops[i].Path < ops[j].Path
+		}
+		return ops[i].Method < ops[j].Method
+	})
+}
+
+// GenerateOpenAPIStub returns a minimal OpenAPI document describing the live
+// routes, a starting point for documenting them
+func GenerateOpenAPIStub(routes []LiveRoute) map[string]interface{} {
+	paths := make(map[string]interface{}, len(routes))
+	for _, route := range routes {
+		var parameters []map[string]interface{}
+		for _, segment := range strings.Split(route.Path, "/") {
+			if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+				parameters = append(parameters, map[string]interface{}{
+					"name":     strings.Trim(segment, "{}"),
+					"in":       "path",
+					"required": true,
+					"schema":   map[string]interface{}{"type": "string"},
+				})
+			}
+		}
+
+		item, _ := paths[route.Path].(map[string]interface{})
+		if item == nil {
+			item = make(map[string]interface{})
+			paths[route.Path] = item
+		}
+		for _, method := range route.Methods {
+			operation := map[string]interface{}{
+				"summary": strings.ToUpper(method) + " " + route.Path,
+				"responses": map[string]interface{}{
+					"default": map[string]interface{}{"description": "Response"},
+				},
+			}
+			if len(parameters) > 0 {
+				operation["parameters"] = parameters
+			}
+			item[strings.ToLower(method)] = operation
+		}
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.0",
+		"info": map[string]interface{}{
+			"title":   "Stargate Admin API",
+			"version": "1.0.0",
+		},
+		"paths": paths,
+	}
+}
+
 // DocsHandler handles API documentation requests
-type DocsHandler struct{}
+type DocsHandler struct {
+	routes func() []LiveRoute // Live routes the served document is validated against
+}
+
+// NewDocsHandler creates a new docs handler validating the served document
+// against the live routes returned by routes
+func NewDocsHandler(routes func() []LiveRoute) *DocsHandler {
+	return &DocsHandler{routes: routes}
+}
 
-// NewDocsHandler creates a new docs handler
-func NewDocsHandler() *DocsHandler {
-	return &DocsHandler{}
+// ValidateSpec handles GET /docs/validate, reporting the drift between the
+// served OpenAPI document and the live routes
+func (dh *DocsHandler) ValidateSpec(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ValidateOpenAPI(OpenAPISpec, dh.routes()))
+}
+
+// ServeStub handles GET /docs/stub, serving a minimal OpenAPI document
+// generated from the live routes
+func (dh *DocsHandler) ServeStub(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(GenerateOpenAPIStub(dh.routes()))
 }
 
 // ServeOpenAPI handles GET /docs/openapi.json
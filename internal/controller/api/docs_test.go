@@ -0,0 +1,99 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestValidateOpenAPI(t *testing.T) {
+	spec := map[string]interface{}{
+		"paths": map[string]interface{}{
+			"/health": map[string]interface{}{
+				"get": map[string]interface{}{},
+			},
+			"/api/v1/routes": map[string]interface{}{
+				"get":  map[string]interface{}{},
+				"post": map[string]interface{}{},
+			},
+			"/api/v1/routes/{route_id}": map[string]interface{}{
+				"parameters": []interface{}{},
+				"get":        map[string]interface{}{},
+			},
+		},
+	}
+	routes := []LiveRoute{
+		{Path: "/health", Methods: []string{"GET"}},
+		{Path: "/api/v1/routes", Methods: []string{"GET"}},
+		{Path: "/api/v1/routes/{id}", Methods: []string{"GET", "PUT"}},
+		{Path: "/api/v1/upstreams", Methods: []string{"GET"}},
+	}
+
+	report := ValidateOpenAPI(spec, routes)
+	if report.InSync {
+		t.Error("Expected drift to be reported")
+	}
+	undocumented := []OpenAPIOperation{
+		{Method: "PUT", Path: "/api/v1/routes/{id}"},
+		{Method: "GET", Path: "/api/v1/upstreams"},
+	}
+	if !reflect.DeepEqual(report.Undocumented, undocumented) {
+		t.Errorf("Expected undocumented %v, got %v", undocumented, report.Undocumented)
+	}
+	stale := []OpenAPIOperation{{Method: "POST", Path: "/api/v1/routes"}}
+	if !reflect.DeepEqual(report.Stale, stale) {
+		t.Errorf("Expected stale %v, got %v", stale, report.Stale)
+	}
+
+	// A stub generated from the live routes documents them all
+	stub := GenerateOpenAPIStub(routes)
+	if report := ValidateOpenAPI(stub, routes); !report.InSync {
+		t.Errorf("Expected the stub in sync, got %+v", report)
+	}
+	operation := stub["paths"].(map[string]interface{})["/api/v1/routes/{id}"].(map[string]interface{})["put"].(map[string]interface{})
+	parameters := operation["parameters"].([]map[string]interface{})
+	if len(parameters) != 1 || parameters[0]["name"] != "id" || parameters[0]["in"] != "path" {
+		t.Errorf("Expected the path parameter in the stub, got %v", parameters)
+	}
+}
+
+func TestDocsHandler_ValidateSpec(t *testing.T) {
+	handler := NewDocsHandler(func() []LiveRoute {
+		return []LiveRoute{
+			{Path: "/health", Methods: []string{"GET"}},
+			{Path: "/auth/login", Methods: []string{"POST"}},
+			{Path: "/api/v1/routes", Methods: []string{"GET"}},
+			{Path: "/api/v1/routes/{id}", Methods: []string{"POST"}},
+		}
+	})
+
+	rr := httptest.NewRecorder()
+	handler.ValidateSpec(rr, httptest.NewRequest("GET", "/api/v1/docs/validate", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rr.Code)
+	}
+	var report OpenAPIDriftReport
+	if err := json.NewDecoder(rr.Body).Decode(&report); err != nil {
+		t.Fatalf("Failed to decode report: %v", err)
+	}
+
+	// Routes are created with POST on the route's path, not the collection
+	if report.InSync || len(report.Undocumented) != 1 || report.Undocumented[0].Path != "/api/v1/routes/{id}" {
+		t.Errorf("Expected route creation undocumented, got %+v", report)
+	}
+	if len(report.Stale) != 1 || report.Stale[0] != (OpenAPIOperation{Method: "POST", Path: "/api/v1/routes"}) {
+		t.Errorf("Expected the collection POST stale, got %+v", report.Stale)
+	}
+
+	rr = httptest.NewRecorder()
+	handler.ServeStub(rr, httptest.NewRequest("GET", "/api/v1/docs/stub", nil))
+	var stub map[string]interface{}
+	if err := json.NewDecoder(rr.Body).Decode(&stub); err != nil {
+		t.Fatalf("Failed to decode stub: %v", err)
+	}
+	if paths, _ := stub["paths"].(map[string]interface{}); len(paths) != 4 {
+		t.Errorf("Expected the stub to describe the live routes, got %v", stub["paths"])
+	}
+}
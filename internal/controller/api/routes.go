@@ -65,7 +65,7 @@ func (rh *RouteHandler) CreateRoute(w http.ResponseWriter, r *http.Request) {
 
 	// Check if route ID already exists
 	ctx := context.Background()
-	key := fmt.Sprintf("routes/%s", route.ID)
+	key := store.TenantKey(router.TenantFromContext(r.Context()), "routes", route.ID)
 	if _, err := rh.store.Get(ctx, key); err == nil {
 		writeErrorResponse(w, http.StatusConflict, "Route ID already exists", nil)
 		return
@@ -116,7 +116,7 @@ func (rh *RouteHandler) GetRoute(w http.ResponseWriter, r *http.Request) {
 	}
 
 	ctx := context.Background()
-	key := fmt.Sprintf("routes/%s", routeID)
+	key := store.TenantKey(router.TenantFromContext(r.Context()), "routes", routeID)
 	
 	data, err := rh.store.Get(ctx, key)
 	if err != nil {
@@ -167,7 +167,7 @@ func (rh *RouteHandler) UpdateRoute(w http.ResponseWriter, r *http.Request) {
 	}
 
 	ctx := context.Background()
-	key := fmt.Sprintf("routes/%s", routeID)
+	key := store.TenantKey(router.TenantFromContext(r.Context()), "routes", routeID)
 
 	// Get old route data for change notification
 	oldData, err := rh.store.Get(ctx, key)
@@ -176,6 +176,15 @@ func (rh *RouteHandler) UpdateRoute(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Keep the stored enabled flag unless the update sets one, so updating a
+	// disabled route doesn't re-enable it
+	if route.Enabled == nil {
+		var oldRoute router.RouteRule
+		if err := json.Unmarshal(oldData, &oldRoute); err == nil {
+			route.Enabled = oldRoute.Enabled
+		}
+	}
+
 	// Serialize route
 	data, err := json.Marshal(route)
 	if err != nil {
@@ -204,6 +213,70 @@ func (rh *RouteHandler) UpdateRoute(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// SetRouteEnabled handles POST /routes/{id}/enable and POST /routes/{id}/disable.
+// A disabled route keeps its definition but isn't matched, so requests fall
+// through to other routes or the no-route behavior
+func (rh *RouteHandler) SetRouteEnabled(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	routeID, action := extractRouteAction(r.URL.Path)
+	if routeID == "" {
+		writeErrorResponse(w, http.StatusBadRequest, "Route ID is required", nil)
+		return
+	}
+	if action != "enable" && action != "disable" {
+		writeErrorResponse(w, http.StatusNotFound, "Unknown route action", fmt.Errorf("action %q", action))
+		return
+	}
+
+	ctx := context.Background()
+	key := store.TenantKey(router.TenantFromContext(r.Context()), "routes", routeID)
+
+	oldData, err := rh.store.Get(ctx, key)
+	if err != nil {
+		writeErrorResponse(w, http.StatusNotFound, "Route not found", err)
+		return
+	}
+
+	var route router.RouteRule
+	if err := json.Unmarshal(oldData, &route); err != nil {
+		writeErrorResponse(w, http.StatusInternalServerError, "Failed to deserialize route", err)
+		return
+	}
+
+	enabled := action == "enable"
+	route.Enabled = &enabled
+	route.UpdatedAt = time.Now().Unix()
+
+	data, err := json.Marshal(route)
+	if err != nil {
+		writeErrorResponse(w, http.StatusInternalServerError, "Failed to serialize route", err)
+		return
+	}
+
+	if err := rh.store.Put(ctx, key, data); err != nil {
+		writeErrorResponse(w, http.StatusInternalServerError, "Failed to update route", err)
+		return
+	}
+
+	// Notify configuration change
+	if rh.configNotifier != nil {
+		if err := rh.configNotifier.PublishConfigChange("update", key, data, oldData, "admin_api"); err != nil {
+			// Log error but don't fail the request
+			log.Printf("Failed to publish config change: %v", err)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": fmt.Sprintf("Route %sd successfully", action),
+		"route":   route,
+	})
+}
+
 // DeleteRoute handles DELETE /routes/{id}
 func (rh *RouteHandler) DeleteRoute(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodDelete {
@@ -219,7 +292,7 @@ func (rh *RouteHandler) DeleteRoute(w http.ResponseWriter, r *http.Request) {
 	}
 
 	ctx := context.Background()
-	key := fmt.Sprintf("routes/%s", routeID)
+	key := store.TenantKey(router.TenantFromContext(r.Context()), "routes", routeID)
 
 	// Get route data before deletion for change notification
 	oldData, err := rh.store.Get(ctx, key)
@@ -258,24 +331,45 @@ func (rh *RouteHandler) ListRoutes(w http.ResponseWriter, r *http.Request) {
 	ctx := context.Background()
 	
 	// Get all routes
-	routesData, err := rh.store.List(ctx, "routes/")
+	routesData, err := rh.store.List(ctx, store.TenantPrefix(router.TenantFromContext(r.Context()), "routes"))
 	if err != nil {
 		writeErrorResponse(w, http.StatusInternalServerError, "Failed to list routes", err)
 		return
 	}
 
+	// Parse query parameters for filtering and pagination
+	query := r.URL.Query()
+	var enabledFilter *bool
+	if e := query.Get("enabled"); e != "" {
+		parsed, err := strconv.ParseBool(e)
+		if err != nil {
+			writeErrorResponse(w, http.StatusBadRequest, "Invalid enabled filter", err)
+			return
+		}
+		enabledFilter = &parsed
+	}
+
 	var routes []router.RouteRule
+	disabled := 0
 	for _, data := range routesData {
 		var route router.RouteRule
 		if err := json.Unmarshal(data, &route); err != nil {
 			// Log error but continue with other routes
 			continue
 		}
+
+		// Always report the flag so disabled routes stand out
+		enabled := route.IsEnabled()
+		route.Enabled = &enabled
+		if !enabled {
+			disabled++
+		}
+		if enabledFilter != nil && *enabledFilter != enabled {
+			continue
+		}
 		routes = append(routes, route)
 	}
 
-	// Parse query parameters for filtering and pagination
-	query := r.URL.Query()
 	limit := 50 // default limit
 	if l := query.Get("limit"); l != "" {
 		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 {
@@ -305,9 +399,10 @@ func (rh *RouteHandler) ListRoutes(w http.ResponseWriter, r *http.Request) {
 
 	response := map[string]interface{}{
 		"routes": paginatedRoutes,
-		"total":  total,
-		"limit":  limit,
-		"offset": offset,
+		"total":    total,
+		"disabled": disabled,
+		"limit":    limit,
+		"offset":   offset,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -323,6 +418,16 @@ func extractRouteID(path string) string {
 	return ""
 }
 
+// extractRouteAction returns the route ID and action of a path ending in
+// routes/{id}/{action}, which may carry the Admin API prefix
+func extractRouteAction(path string) (string, string) {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	if len(parts) < 3 || parts[len(parts)-3] != "routes" {
+		return "", ""
+	}
+	return parts[len(parts)-2], parts[len(parts)-1]
+}
+
 func generateRouteID() string {
 	return fmt.Sprintf("route-%d", time.Now().UnixNano())
 }
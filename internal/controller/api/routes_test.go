@@ -336,3 +336,191 @@ func TestRouteHandler_ListRoutes(t *testing.T) {
 		t.Errorf("Expected total 2, got %v", total)
 	}
 }
+
+func TestRouteHandler_SetRouteEnabled(t *testing.T) {
+	// Setup
+	cfg := &config.Config{}
+	mockStore := NewMockStore()
+	handler := NewRouteHandler(cfg, mockStore, &MockConfigNotifier{})
+
+	route := router.RouteRule{
+		ID:   "beta-route",
+		Name: "Beta Route",
+		Rules: router.Rule{
+			Hosts: []string{"beta.example.com"},
+		},
+		UpstreamID: "beta-upstream",
+	}
+	jsonData, _ := json.Marshal(route)
+	mockStore.Put(context.Background(), "routes/beta-route", jsonData)
+
+	storedRoute := func() *router.RouteRule {
+		data, err := mockStore.Get(context.Background(), "routes/beta-route")
+		if err != nil {
+			t.Fatalf("Route was not found: %v", err)
+		}
+		var stored router.RouteRule
+		if err := json.Unmarshal(data, &stored); err != nil {
+			t.Fatalf("Failed to unmarshal stored route: %v", err)
+		}
+		return &stored
+	}
+
+	// Disable through the prefixed Admin API path
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/routes/beta-route/disable", nil)
+	w := httptest.NewRecorder()
+	handler.SetRouteEnabled(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	stored := storedRoute()
+	if stored.IsEnabled() {
+		t.Error("Expected route to be disabled")
+	}
+	if stored.Name != route.Name || stored.UpstreamID != route.UpstreamID {
+		t.Errorf("Expected route definition to be kept, got %+v", stored)
+	}
+
+	// A full update that omits the flag keeps the route disabled
+	route.Name = "Beta Route v2"
+	jsonData, _ = json.Marshal(route)
+	req = httptest.NewRequest(http.MethodPut, "/routes/beta-route", bytes.NewBuffer(jsonData))
+	w = httptest.NewRecorder()
+	handler.UpdateRoute(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	if storedRoute().IsEnabled() {
+		t.Error("Expected update without enabled to keep the route disabled")
+	}
+
+	// The list endpoint reports disabled routes
+	enabledRoute := router.RouteRule{ID: "live-route", Name: "Live Route", Rules: router.Rule{Hosts: []string{"live.example.com"}}, UpstreamID: "live-upstream"}
+	jsonData, _ = json.Marshal(enabledRoute)
+	mockStore.Put(context.Background(), "routes/live-route", jsonData)
+
+	req = httptest.NewRequest(http.MethodGet, "/routes?enabled=false", nil)
+	w = httptest.NewRecorder()
+	handler.ListRoutes(w, req)
+	var list struct {
+		Routes   []map[string]interface{} `json:"routes"`
+		Disabled int                      `json:"disabled"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&list); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if list.Disabled != 1 || len(list.Routes) != 1 || list.Routes[0]["id"] != "beta-route" || list.Routes[0]["enabled"] != false {
+		t.Errorf("Expected only the disabled route, got %+v", list)
+	}
+
+	// Re-enable
+	req = httptest.NewRequest(http.MethodPost, "/routes/beta-route/enable", nil)
+	w = httptest.NewRecorder()
+	handler.SetRouteEnabled(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	if !storedRoute().IsEnabled() {
+		t.Error("Expected route to be enabled")
+	}
+
+	// Unknown actions and routes
+	for path, code := range map[string]int{
+		"/routes/beta-route/pause": http.StatusNotFound,
+		"/routes/missing/disable":  http.StatusNotFound,
+	} {
+		w = httptest.NewRecorder()
+		handler.SetRouteEnabled(w, httptest.NewRequest(http.MethodPost, path, nil))
+		if w.Code != code {
+			t.Errorf("%s: expected status %d, got %d", path, code, w.Code)
+		}
+	}
+}
+
+func TestRouteHandler_TenantScoping(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.AdminAPI.Auth.Enabled = true
+	cfg.AdminAPI.Auth.APIKey.Header = "X-API-Key"
+	cfg.AdminAPI.Auth.APIKey.Keys = []string{"admin-key"}
+	cfg.Tenancy = config.TenancyConfig{
+		Enabled: true,
+		Header:  "X-Stargate-Tenant",
+		Tenants: map[string]config.TenantConfig{
+			"acme":   {APIKeys: []string{"acme-key"}},
+			"globex": {APIKeys: []string{"globex-key"}},
+		},
+	}
+	mockStore := NewMockStore()
+	handler := NewRouteHandler(cfg, mockStore, &MockConfigNotifier{})
+	auth := NewAuthMiddleware(cfg)
+
+	do := func(h http.HandlerFunc, method, apiKey, tenant string, route *router.RouteRule) *httptest.ResponseRecorder {
+		var body bytes.Buffer
+		if route != nil {
+			json.NewEncoder(&body).Encode(route)
+		}
+		req := httptest.NewRequest(method, "/routes", &body)
+		req.Header.Set("X-API-Key", apiKey)
+		if tenant != "" {
+			req.Header.Set("X-Stargate-Tenant", tenant)
+		}
+		w := httptest.NewRecorder()
+		auth.Middleware(h).ServeHTTP(w, req)
+		return w
+	}
+	route := &router.RouteRule{
+		ID:         "api",
+		Name:       "API",
+		Rules:      router.Rule{Paths: []router.PathRule{{Type: router.MatchTypePrefix, Value: "/api"}}},
+		UpstreamID: "backend",
+	}
+
+	// The same route ID is stored separately for a tenant and the default tenant
+	if w := do(handler.CreateRoute, http.MethodPost, "acme-key", "", route); w.Code != http.StatusCreated {
+		t.Fatalf("Expected tenant key to create route, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := do(handler.CreateRoute, http.MethodPost, "admin-key", "", route); w.Code != http.StatusCreated {
+		t.Fatalf("Expected admin key to create route, got %d: %s", w.Code, w.Body.String())
+	}
+	for _, key := range []string{"tenants/acme/routes/api", "routes/api"} {
+		if _, err := mockStore.Get(context.Background(), key); err != nil {
+			t.Errorf("Route was not stored at %s: %v", key, err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		apiKey string
+		tenant string
+		status int
+		routes int
+	}{
+		{"tenant key lists its routes", "acme-key", "", http.StatusOK, 1},
+		{"tenant key naming its tenant", "acme-key", "acme", http.StatusOK, 1},
+		{"tenant key naming another tenant", "acme-key", "globex", http.StatusForbidden, 0},
+		{"tenant without routes", "globex-key", "", http.StatusOK, 0},
+		{"admin key selects a tenant", "admin-key", "acme", http.StatusOK, 1},
+		{"admin key selects an unknown tenant", "admin-key", "initech", http.StatusBadRequest, 0},
+		{"unknown key", "other-key", "", http.StatusUnauthorized, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := do(handler.ListRoutes, http.MethodGet, tt.apiKey, tt.tenant, nil)
+			if w.Code != tt.status {
+				t.Fatalf("Expected status %d, got %d: %s", tt.status, w.Code, w.Body.String())
+			}
+			if w.Code != http.StatusOK {
+				return
+			}
+			var response struct {
+				Routes []router.RouteRule `json:"routes"`
+			}
+			if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if len(response.Routes) != tt.routes {
+				t.Errorf("Expected %d routes, got %d", tt.routes, len(response.Routes))
+			}
+		})
+	}
+}
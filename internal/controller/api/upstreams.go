@@ -59,7 +59,7 @@ func (uh *UpstreamHandler) CreateUpstream(w http.ResponseWriter, r *http.Request
 
 	// Check if upstream ID already exists
 	ctx := context.Background()
-	key := fmt.Sprintf("upstreams/%s", upstream.ID)
+	key := store.TenantKey(router.TenantFromContext(r.Context()), "upstreams", upstream.ID)
 	if _, err := uh.store.Get(ctx, key); err == nil {
 		writeErrorResponse(w, http.StatusConflict, "Upstream ID already exists", nil)
 		return
@@ -102,7 +102,7 @@ func (uh *UpstreamHandler) GetUpstream(w http.ResponseWriter, r *http.Request) {
 	}
 
 	ctx := context.Background()
-	key := fmt.Sprintf("upstreams/%s", upstreamID)
+	key := store.TenantKey(router.TenantFromContext(r.Context()), "upstreams", upstreamID)
 	
 	data, err := uh.store.Get(ctx, key)
 	if err != nil {
@@ -153,7 +153,7 @@ func (uh *UpstreamHandler) UpdateUpstream(w http.ResponseWriter, r *http.Request
 	}
 
 	ctx := context.Background()
-	key := fmt.Sprintf("upstreams/%s", upstreamID)
+	key := store.TenantKey(router.TenantFromContext(r.Context()), "upstreams", upstreamID)
 
 	// Check if upstream exists
 	if _, err := uh.store.Get(ctx, key); err != nil {
@@ -196,7 +196,7 @@ func (uh *UpstreamHandler) DeleteUpstream(w http.ResponseWriter, r *http.Request
 	}
 
 	ctx := context.Background()
-	key := fmt.Sprintf("upstreams/%s", upstreamID)
+	key := store.TenantKey(router.TenantFromContext(r.Context()), "upstreams", upstreamID)
 
 	// Check if upstream exists
 	if _, err := uh.store.Get(ctx, key); err != nil {
@@ -205,7 +205,7 @@ func (uh *UpstreamHandler) DeleteUpstream(w http.ResponseWriter, r *http.Request
 	}
 
 	// Check if upstream is referenced by any routes
-	if err := uh.checkUpstreamReferences(ctx, upstreamID); err != nil {
+	if err := uh.checkUpstreamReferences(ctx, router.TenantFromContext(r.Context()), upstreamID); err != nil {
 		writeErrorResponse(w, http.StatusConflict, "Upstream is referenced by routes", err)
 		return
 	}
@@ -232,7 +232,7 @@ func (uh *UpstreamHandler) ListUpstreams(w http.ResponseWriter, r *http.Request)
 	ctx := context.Background()
 	
 	// Get all upstreams
-	upstreamsData, err := uh.store.List(ctx, "upstreams/")
+	upstreamsData, err := uh.store.List(ctx, store.TenantPrefix(router.TenantFromContext(r.Context()), "upstreams"))
 	if err != nil {
 		writeErrorResponse(w, http.StatusInternalServerError, "Failed to list upstreams", err)
 		return
@@ -288,9 +288,9 @@ func (uh *UpstreamHandler) ListUpstreams(w http.ResponseWriter, r *http.Request)
 	json.NewEncoder(w).Encode(response)
 }
 
-// checkUpstreamReferences checks if upstream is referenced by any routes
-func (uh *UpstreamHandler) checkUpstreamReferences(ctx context.Context, upstreamID string) error {
-	routesData, err := uh.store.List(ctx, "routes/")
+// checkUpstreamReferences checks if upstream is referenced by any routes of its tenant
+func (uh *UpstreamHandler) checkUpstreamReferences(ctx context.Context, tenant, upstreamID string) error {
+	routesData, err := uh.store.List(ctx, store.TenantPrefix(tenant, "routes"))
 	if err != nil {
 		return err
 	}
@@ -2,13 +2,17 @@ package controller
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/songzhibin97/stargate/internal/config"
 	"github.com/songzhibin97/stargate/internal/store"
+	"github.com/songzhibin97/stargate/internal/webhook"
 	"github.com/songzhibin97/stargate/pkg/log"
 )
 
@@ -22,6 +26,9 @@ type ConfigNotifier struct {
 	stopCh    chan struct{}
 	wg        sync.WaitGroup
 	logger    log.Logger
+	webhook   *webhook.Sender
+	metrics   *ControllerMetrics
+	elector   *LeaderElector // Webhooks are delivered by the leader only
 }
 
 // ConfigChangeEvent represents a configuration change event
@@ -47,6 +54,29 @@ const (
 // ConfigChangeListener is called when configuration changes
 type ConfigChangeListener func(event *ConfigChangeEvent)
 
+// ConfigChangeWebhookEvent is the webhook event type of configuration changes
+const ConfigChangeWebhookEvent = "config_change"
+
+// webhookResourceTypes maps watched key prefixes to resource types reported in webhooks
+var webhookResourceTypes = map[string]string{
+	"routes/":    "route",
+	"upstreams/": "upstream",
+	"plugins/":   "plugin",
+}
+
+// ConfigChangeWebhookPayload is the payload posted to the config change webhook
+type ConfigChangeWebhookPayload struct {
+	Type         ConfigChangeType `json:"type"`
+	Key          string           `json:"key"`
+	ResourceType string           `json:"resource_type"`
+	ResourceID   string           `json:"resource_id"`
+	Summary      string           `json:"summary"`
+	Version      string           `json:"version"`
+	SHA          string           `json:"sha,omitempty"` // SHA-256 of the new value, empty on delete
+	Source       string           `json:"source"`
+	Timestamp    int64            `json:"timestamp"`
+}
+
 // NewConfigNotifier creates a new configuration notifier
 func NewConfigNotifier(cfg *config.Config, store store.Store, logger log.Logger) *ConfigNotifier {
 	if logger == nil {
@@ -73,9 +103,15 @@ func (cn *ConfigNotifier) Start() error {
 
 	cn.running = true
 
+	// Start config change webhook
+	if cn.config.Webhooks.ConfigChange.Enabled {
+		cn.webhook = webhook.NewSender(&cn.config.Webhooks.ConfigChange)
+	}
+
 	// Start watching for configuration changes
 	if err := cn.startWatching(); err != nil {
 		cn.running = false
+		cn.stopWebhook()
 		return fmt.Errorf("failed to start watching: %w", err)
 	}
 
@@ -101,9 +137,18 @@ func (cn *ConfigNotifier) Stop() {
 	cn.store.Unwatch("plugins/")
 
 	cn.wg.Wait()
+	cn.stopWebhook()
 	cn.logger.Info("Configuration notifier stopped")
 }
 
+// stopWebhook stops the config change webhook, the caller must hold cn.mu
+func (cn *ConfigNotifier) stopWebhook() {
+	if cn.webhook != nil {
+		cn.webhook.Close()
+		cn.webhook = nil
+	}
+}
+
 // AddListener adds a configuration change listener
 func (cn *ConfigNotifier) AddListener(pattern string, listener ConfigChangeListener) {
 	cn.mu.Lock()
@@ -142,6 +187,62 @@ func (cn *ConfigNotifier) NotifyChange(event *ConfigChangeEvent) {
 			}
 		}
 	}
+
+	if cn.metrics != nil {
+		cn.metrics.ObserveConfigChange(event)
+	}
+
+	// Notify external systems, delivery happens in the background
+	cn.sendWebhook(event)
+}
+
+// sendWebhook posts route, upstream and plugin changes to the config change
+// webhook. With leader election only the leader delivers them, so each
+// change is posted once.
+func (cn *ConfigNotifier) sendWebhook(event *ConfigChangeEvent) {
+	if cn.webhook == nil || !cn.elector.IsLeader() {
+		return
+	}
+
+	payload := newConfigChangeWebhookPayload(event)
+	if payload == nil {
+		return
+	}
+
+	if err := cn.webhook.Send(ConfigChangeWebhookEvent, payload); err != nil {
+		cn.logger.Error("Failed to queue config change webhook",
+			log.String("key", event.Key),
+			log.Error(err),
+		)
+	}
+}
+
+// newConfigChangeWebhookPayload builds the webhook payload of a change event,
+// returning nil for keys that aren't routes, upstreams or plugins
+func newConfigChangeWebhookPayload(event *ConfigChangeEvent) *ConfigChangeWebhookPayload {
+	for prefix, resourceType := range webhookResourceTypes {
+		if !strings.HasPrefix(event.Key, prefix) {
+			continue
+		}
+
+		resourceID := strings.TrimPrefix(event.Key, prefix)
+		payload := &ConfigChangeWebhookPayload{
+			Type:         event.Type,
+			Key:          event.Key,
+			ResourceType: resourceType,
+			ResourceID:   resourceID,
+			Summary:      fmt.Sprintf("%s %s %sd", resourceType, resourceID, event.Type),
+			Version:      event.Version,
+			Source:       event.Source,
+			Timestamp:    event.Timestamp,
+		}
+		if event.Type != ConfigChangeTypeDelete && event.Value != nil {
+			sum := sha256.Sum256(event.Value)
+			payload.SHA = hex.EncodeToString(sum[:])
+		}
+		return payload
+	}
+	return nil
 }
 
 // PublishConfigChange publishes a configuration change event
@@ -306,9 +407,13 @@ func (cn *ConfigNotifier) Metrics() map[string]interface{} {
 	cn.mu.RLock()
 	defer cn.mu.RUnlock()
 
-	return map[string]interface{}{
+	metrics := map[string]interface{}{
 		"running":          cn.running,
 		"listeners_count":  len(cn.listeners),
 		"watchers_active":  cn.running,
 	}
+	if cn.webhook != nil {
+		metrics["webhook"] = cn.webhook.GetStats()
+	}
+	return metrics
 }
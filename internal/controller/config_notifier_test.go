@@ -0,0 +1,189 @@
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/songzhibin97/stargate/internal/config"
+	"github.com/songzhibin97/stargate/internal/store"
+	"github.com/songzhibin97/stargate/internal/webhook"
+)
+
+// watchStore is an in-memory store exposing its watch callbacks
+type watchStore struct {
+	mu       sync.Mutex
+	data     map[string][]byte
+	watchers map[string]store.WatchCallback
+}
+
+func newWatchStore() *watchStore {
+	return &watchStore{
+		data:     make(map[string][]byte),
+		watchers: make(map[string]store.WatchCallback),
+	}
+}
+
+func (s *watchStore) Get(ctx context.Context, key string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	value, ok := s.data[key]
+	if !ok {
+		return nil, store.ErrKeyNotFound
+	}
+	return value, nil
+}
+
+func (s *watchStore) Put(ctx context.Context, key string, value []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data[key] = value
+	return nil
+}
+
+func (s *watchStore) Delete(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.data, key)
+	return nil
+}
+
+func (s *watchStore) List(ctx context.Context, prefix string) (map[string][]byte, error) {
+	return map[string][]byte{}, nil
+}
+
+func (s *watchStore) Watch(key string, callback store.WatchCallback) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.watchers[key] = callback
+	return nil
+}
+
+func (s *watchStore) Unwatch(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.watchers, key)
+	return nil
+}
+
+func (s *watchStore) Close() error {
+	return nil
+}
+
+// emit simulates a watch event from the store
+func (s *watchStore) emit(prefix, key string, value []byte, eventType store.EventType) {
+	s.mu.Lock()
+	callback := s.watchers[prefix]
+	s.mu.Unlock()
+	callback(key, value, eventType)
+}
+
+func TestConfigNotifier_ConfigChangeWebhook(t *testing.T) {
+	type delivery struct {
+		header  http.Header
+		payload ConfigChangeWebhookPayload
+	}
+	deliveries := make(chan delivery, 10)
+
+	var mu sync.Mutex
+	attempts := 0
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if !webhook.Verify("secret", body, r.Header.Get(webhook.HeaderSignature)) {
+			t.Errorf("Invalid webhook signature %q", r.Header.Get(webhook.HeaderSignature))
+		}
+
+		// Fail the first attempt to exercise retries
+		mu.Lock()
+		attempts++
+		first := attempts == 1
+		mu.Unlock()
+		if first {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		var payload ConfigChangeWebhookPayload
+		if err := json.Unmarshal(body, &payload); err != nil {
+			t.Errorf("Failed to decode webhook payload: %v", err)
+		}
+		deliveries <- delivery{header: r.Header.Clone(), payload: payload}
+	}))
+	defer receiver.Close()
+
+	cfg := &config.Config{}
+	cfg.Webhooks.ConfigChange = config.WebhookConfig{
+		Enabled:    true,
+		URL:        receiver.URL,
+		Timeout:    time.Second,
+		RetryCount: 2,
+		Secret:     "secret",
+	}
+
+	watchStore := newWatchStore()
+	notifier := NewConfigNotifier(cfg, watchStore, nil)
+	if err := notifier.Start(); err != nil {
+		t.Fatalf("Failed to start config notifier: %v", err)
+	}
+	defer notifier.Stop()
+
+	route := []byte(`{"id":"api","upstream_id":"backend"}`)
+	watchStore.emit("routes/", "routes/api", route, store.EventTypePut)
+
+	var received delivery
+	select {
+	case received = <-deliveries:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for config change webhook")
+	}
+
+	if attempt := received.header.Get(webhook.HeaderAttempt); attempt != "2" {
+		t.Errorf("Expected delivery attempt 2, got %q", attempt)
+	}
+	if event := received.header.Get(webhook.HeaderEvent); event != ConfigChangeWebhookEvent {
+		t.Errorf("Expected event %s, got %q", ConfigChangeWebhookEvent, event)
+	}
+
+	payload := received.payload
+	if payload.ResourceType != "route" || payload.ResourceID != "api" {
+		t.Errorf("Expected route api, got %s %s", payload.ResourceType, payload.ResourceID)
+	}
+	if payload.Summary == "" {
+		t.Error("Expected change summary")
+	}
+	if payload.Version == "" {
+		t.Error("Expected config version")
+	}
+	sum := sha256.Sum256(route)
+	if payload.SHA != hex.EncodeToString(sum[:]) {
+		t.Errorf("Expected SHA of the new value, got %q", payload.SHA)
+	}
+
+	// Deletes carry no SHA
+	watchStore.emit("upstreams/", "upstreams/backend", nil, store.EventTypeDelete)
+	select {
+	case received = <-deliveries:
+		if received.payload.Type != ConfigChangeTypeDelete || received.payload.ResourceType != "upstream" {
+			t.Errorf("Expected upstream delete, got %s %s", received.payload.ResourceType, received.payload.Type)
+		}
+		if received.payload.SHA != "" {
+			t.Errorf("Expected no SHA on delete, got %q", received.payload.SHA)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for delete webhook")
+	}
+}
+
+func TestNewConfigChangeWebhookPayload_IgnoresOtherKeys(t *testing.T) {
+	event := &ConfigChangeEvent{Type: ConfigChangeTypeUpdate, Key: "events/config_changes/1_routes/api"}
+	if payload := newConfigChangeWebhookPayload(event); payload != nil {
+		t.Errorf("Expected no payload for %s, got %+v", event.Key, payload)
+	}
+}
@@ -0,0 +1,498 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/songzhibin97/stargate/internal/config"
+	"github.com/songzhibin97/stargate/internal/store"
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+const (
+	defaultLeaderElectionKey = "stargate/controller/leader"
+	defaultLeaseTTL          = 15 * time.Second
+
+	// forwardedByHeader marks an Admin API write proxied by a standby, so
+	// the receiver rejects rather than forwards it again
+	forwardedByHeader = "X-Stargate-Forwarded-By"
+)
+
+// leaderLock is a lock expiring after a TTL, held by one controller at a time
+type leaderLock interface {
+	// acquire takes the lock for record if it's free or renews it if record
+	// holds it, returning the record of the holder
+	acquire(ctx context.Context, record string, ttl time.Duration) (string, error)
+	// release frees the lock if record holds it
+	release(ctx context.Context, record string) error
+	Close() error
+}
+
+// LeaderRecord identifies the controller holding the leader lock
+type LeaderRecord struct {
+	ID      string `json:"id"`
+	Address string `json:"address,omitempty"`
+}
+
+// LeaderElector campaigns for the leader lock so only one controller
+// replica is active. The lock is renewed every renew interval; a leader
+// that can't renew steps down before its lease may expire, and a leader
+// that stops releases the lock so a standby takes over at its next
+// campaign rather than after the TTL.
+type LeaderElector struct {
+	lock          leaderLock
+	self          LeaderRecord
+	record        string
+	ttl           time.Duration
+	renewInterval time.Duration
+	onElected     func()
+	onDemoted     func()
+
+	mu          sync.RWMutex
+	leader      bool
+	current     LeaderRecord // Holder of the lock at the last campaign
+	lastRenewal time.Time
+	lastError   error
+	running     bool
+	stopCh      chan struct{}
+	wg          sync.WaitGroup
+}
+
+// newLeaderElector creates the elector campaigning with lock. onElected and
+// onDemoted are called from the campaign loop when this controller gains
+// or loses leadership.
+func newLeaderElector(cfg *config.LeaderElectionConfig, lock leaderLock, onElected, onDemoted func()) (*LeaderElector, error) {
+	self := LeaderRecord{ID: cfg.ID, Address: cfg.AdvertiseAddress}
+	if self.ID == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get hostname for the controller ID: %w", err)
+		}
+		self.ID = hostname
+	}
+	record, err := json.Marshal(self)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode leader record: %w", err)
+	}
+
+	e := &LeaderElector{
+		lock:          lock,
+		self:          self,
+		record:        string(record),
+		ttl:           cfg.LeaseTTL,
+		renewInterval: cfg.RenewInterval,
+		onElected:     onElected,
+		onDemoted:     onDemoted,
+	}
+	if e.ttl <= 0 {
+		e.ttl = defaultLeaseTTL
+	}
+	if e.renewInterval <= 0 {
+		e.renewInterval = e.ttl / 3
+	}
+	return e, nil
+}
+
+// newLeaderLock creates the leader lock of the configured backend. The etcd
+// backend uses the client of the etcd store.
+func newLeaderLock(cfg *config.Config, storeInstance store.Store) (leaderLock, error) {
+	electionConfig := cfg.Controller.LeaderElection
+	key := electionConfig.Key
+	if key == "" {
+		key = defaultLeaderElectionKey
+	}
+
+	switch electionConfig.Backend {
+	case "etcd":
+		etcdStore, ok := storeInstance.(*store.EtcdStore)
+		if !ok {
+			return nil, fmt.Errorf("the etcd backend requires store type etcd")
+		}
+		if cfg.Store.KeyPrefix != "" {
+			key = strings.TrimSuffix(cfg.Store.KeyPrefix, "/") + "/" + key
+		}
+		return &etcdLeaderLock{client: etcdStore.Client(), key: key}, nil
+	case "redis":
+		client := redis.NewClient(&redis.Options{
+			Addr:     electionConfig.Redis.Address,
+			Password: electionConfig.Redis.Password,
+			DB:       electionConfig.Redis.DB,
+		})
+		return &redisLeaderLock{client: client, key: key}, nil
+	default:
+		return nil, fmt.Errorf("unsupported leader election backend: %s", electionConfig.Backend)
+	}
+}
+
+// Start campaigns for leadership once, then keeps campaigning and renewing
+// in the background
+func (e *LeaderElector) Start() error {
+	e.mu.Lock()
+	if e.running {
+		e.mu.Unlock()
+		return fmt.Errorf("leader elector is already running")
+	}
+	e.running = true
+	e.stopCh = make(chan struct{})
+	e.mu.Unlock()
+
+	log.Printf("Campaigning for controller leadership as %s", e.self.ID)
+	e.campaign()
+
+	e.wg.Add(1)
+	go e.run()
+	return nil
+}
+
+// Stop stops campaigning and, if this controller leads, steps down and
+// releases the lock for a standby to take over
+func (e *LeaderElector) Stop() {
+	e.mu.Lock()
+	if !e.running {
+		e.mu.Unlock()
+		return
+	}
+	e.running = false
+	close(e.stopCh)
+	e.mu.Unlock()
+
+	e.wg.Wait()
+
+	e.mu.Lock()
+	wasLeader := e.leader
+	e.leader = false
+	e.mu.Unlock()
+
+	if wasLeader {
+		e.onDemoted()
+
+		ctx, cancel := context.WithTimeout(context.Background(), e.renewInterval)
+		defer cancel()
+		if err := e.lock.release(ctx, e.record); err != nil {
+			log.Printf("Failed to release controller leadership: %v", err)
+		} else {
+			log.Printf("Released controller leadership")
+		}
+	}
+	if err := e.lock.Close(); err != nil {
+		log.Printf("Failed to close leader lock: %v", err)
+	}
+}
+
+// run campaigns every renew interval until stopped
+func (e *LeaderElector) run() {
+	defer e.wg.Done()
+
+	ticker := time.NewTicker(e.renewInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-e.stopCh:
+			return
+		case <-ticker.C:
+			e.campaign()
+		}
+	}
+}
+
+// campaign takes or renews the lock once, calling onElected or onDemoted
+// when leadership changes
+func (e *LeaderElector) campaign() {
+	ctx, cancel := context.WithTimeout(context.Background(), e.renewInterval)
+	holder, err := e.lock.acquire(ctx, e.record, e.ttl)
+	cancel()
+
+	now := time.Now()
+	e.mu.Lock()
+	wasLeader := e.leader
+	if err != nil {
+		e.lastError = err
+		// Step down before the lease may expire and another controller
+		// take over, so two controllers are never active at once
+		if e.leader && now.Sub(e.lastRenewal) >= e.ttl-e.renewInterval {
+			e.leader = false
+		}
+	} else {
+		e.lastError = nil
+		e.current = LeaderRecord{}
+		if holder != "" {
+			json.Unmarshal([]byte(holder), &e.current)
+		}
+		e.leader = holder == e.record
+		if e.leader {
+			e.lastRenewal = now
+		}
+	}
+	leader := e.leader
+	e.mu.Unlock()
+
+	switch {
+	case err != nil && leader:
+		log.Printf("Failed to renew controller leadership, retrying: %v", err)
+	case err != nil && wasLeader:
+		log.Printf("Stepping down as controller leader, the lease could not be renewed: %v", err)
+	case err != nil:
+		log.Printf("Failed to campaign for controller leadership: %v", err)
+	}
+
+	if leader && !wasLeader {
+		log.Printf("Elected controller leader as %s", e.self.ID)
+		e.onElected()
+	} else if !leader && wasLeader {
+		if err == nil {
+			log.Printf("Lost controller leadership to %s", e.Leader().ID)
+		}
+		e.onDemoted()
+	}
+}
+
+// IsLeader reports whether this controller leads. Without election (a nil
+// elector) the only controller always leads.
+func (e *LeaderElector) IsLeader() bool {
+	if e == nil {
+		return true
+	}
+
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.leader
+}
+
+// Leader returns the controller holding the lock at the last campaign
+func (e *LeaderElector) Leader() LeaderRecord {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.current
+}
+
+// Role returns "leader" or "standby"
+func (e *LeaderElector) Role() string {
+	if e.IsLeader() {
+		return "leader"
+	}
+	return "standby"
+}
+
+// Health returns the leadership state of this controller
+func (e *LeaderElector) Health() map[string]interface{} {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	health := map[string]interface{}{
+		"id":             e.self.ID,
+		"role":           "standby",
+		"leader_id":      e.current.ID,
+		"leader_address": e.current.Address,
+	}
+	if e.leader {
+		health["role"] = "leader"
+		health["last_renewal"] = e.lastRenewal.Unix()
+	}
+	if e.lastError != nil {
+		health["last_error"] = e.lastError.Error()
+	}
+	return health
+}
+
+// standbyWrites passes Admin API writes to next on the leader. A standby
+// rejects them with 409 naming the leader, or with standby_writes "proxy"
+// forwards them to the leader's advertised address.
+func (ah *APIHandler) standbyWrites(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			next.ServeHTTP(w, r)
+			return
+		}
+		if ah.elector.IsLeader() {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		leader := ah.elector.Leader()
+		if ah.config.Controller.LeaderElection.StandbyWrites == "proxy" && leader.Address != "" && r.Header.Get(forwardedByHeader) == "" {
+			ah.proxyToLeader(w, r, leader)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if leader.Address != "" {
+			w.Header().Set("X-Stargate-Leader", leader.Address)
+		}
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error":          "this controller is a standby, send writes to the leader",
+			"leader_id":      leader.ID,
+			"leader_address": leader.Address,
+		})
+	})
+}
+
+// proxyToLeader forwards an Admin API write to the leader
+func (ah *APIHandler) proxyToLeader(w http.ResponseWriter, r *http.Request, leader LeaderRecord) {
+	target, err := url.Parse(leader.Address)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadGateway)
+		json.NewEncoder(w).Encode(map[string]string{"error": fmt.Sprintf("invalid leader address: %v", err)})
+		return
+	}
+
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		log.Printf("Failed to proxy Admin API write to leader %s: %v", leader.ID, err)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadGateway)
+		json.NewEncoder(w).Encode(map[string]string{"error": "failed to reach the leader", "leader_address": leader.Address})
+	}
+
+	r.Header.Set(forwardedByHeader, ah.elector.self.ID)
+	proxy.ServeHTTP(w, r)
+}
+
+// etcdLeaderLock holds the leader key attached to a lease, renewed with
+// keep-alives
+type etcdLeaderLock struct {
+	client *clientv3.Client
+	key    string
+
+	mu    sync.Mutex
+	lease clientv3.LeaseID
+}
+
+func (l *etcdLeaderLock) acquire(ctx context.Context, record string, ttl time.Duration) (string, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.lease != 0 {
+		if _, err := l.client.KeepAliveOnce(ctx, l.lease); err != nil {
+			if !errors.Is(err, rpctypes.ErrLeaseNotFound) {
+				return "", fmt.Errorf("failed to renew lease: %w", err)
+			}
+			// The lease expired, taking the key with it
+			l.lease = 0
+		}
+	}
+	if l.lease == 0 {
+		grant, err := l.client.Grant(ctx, int64(math.Ceil(ttl.Seconds())))
+		if err != nil {
+			return "", fmt.Errorf("failed to grant lease: %w", err)
+		}
+		l.lease = grant.ID
+	}
+
+	resp, err := l.client.Txn(ctx).
+		If(clientv3.Compare(clientv3.CreateRevision(l.key), "=", 0)).
+		Then(clientv3.OpPut(l.key, record, clientv3.WithLease(l.lease))).
+		Else(clientv3.OpGet(l.key)).
+		Commit()
+	if err != nil {
+		return "", fmt.Errorf("failed to acquire leader key: %w", err)
+	}
+	if resp.Succeeded {
+		return record, nil
+	}
+
+	kvs := resp.Responses[0].GetResponseRange().Kvs
+	if len(kvs) == 0 {
+		return "", nil
+	}
+	kv := kvs[0]
+	if string(kv.Value) == record && clientv3.LeaseID(kv.Lease) != l.lease {
+		// Left by an earlier lease of this controller, such as before a
+		// restart; move it to the current lease so it's renewed
+		if _, err := l.client.Txn(ctx).
+			If(clientv3.Compare(clientv3.Value(l.key), "=", record)).
+			Then(clientv3.OpPut(l.key, record, clientv3.WithLease(l.lease))).
+			Commit(); err != nil {
+			return "", fmt.Errorf("failed to renew leader key: %w", err)
+		}
+	}
+	return string(kv.Value), nil
+}
+
+func (l *etcdLeaderLock) release(ctx context.Context, record string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, err := l.client.Txn(ctx).
+		If(clientv3.Compare(clientv3.Value(l.key), "=", record)).
+		Then(clientv3.OpDelete(l.key)).
+		Commit(); err != nil {
+		return fmt.Errorf("failed to delete leader key: %w", err)
+	}
+	if l.lease != 0 {
+		if _, err := l.client.Revoke(ctx, l.lease); err != nil {
+			return fmt.Errorf("failed to revoke lease: %w", err)
+		}
+		l.lease = 0
+	}
+	return nil
+}
+
+// Close leaves the client to the etcd store
+func (l *etcdLeaderLock) Close() error {
+	return nil
+}
+
+// acquireLeaderScript sets the leader key with a TTL in milliseconds if it's
+// free, or extends it if held by the same record, returning the holder
+var acquireLeaderScript = redis.NewScript(`
+local holder = redis.call('GET', KEYS[1])
+if holder == ARGV[1] then
+	redis.call('PEXPIRE', KEYS[1], ARGV[2])
+	return holder
+end
+if not holder then
+	redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2])
+	return ARGV[1]
+end
+return holder
+`)
+
+// releaseLeaderScript deletes the leader key if held by the record
+var releaseLeaderScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+// redisLeaderLock holds the leader key with an expiration
+type redisLeaderLock struct {
+	client *redis.Client
+	key    string
+}
+
+func (l *redisLeaderLock) acquire(ctx context.Context, record string, ttl time.Duration) (string, error) {
+	holder, err := acquireLeaderScript.Run(ctx, l.client, []string{l.key}, record, ttl.Milliseconds()).Text()
+	if err != nil {
+		return "", fmt.Errorf("failed to acquire leader key: %w", err)
+	}
+	return holder, nil
+}
+
+func (l *redisLeaderLock) release(ctx context.Context, record string) error {
+	if err := releaseLeaderScript.Run(ctx, l.client, []string{l.key}, record).Err(); err != nil {
+		return fmt.Errorf("failed to delete leader key: %w", err)
+	}
+	return nil
+}
+
+func (l *redisLeaderLock) Close() error {
+	return l.client.Close()
+}
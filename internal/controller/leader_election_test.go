@@ -0,0 +1,255 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/songzhibin97/stargate/internal/config"
+	"github.com/songzhibin97/stargate/internal/store"
+)
+
+// memoryLeaderLock is a leader lock shared by electors in one process
+type memoryLeaderLock struct {
+	mu      sync.Mutex
+	holder  string
+	expires time.Time
+}
+
+func (l *memoryLeaderLock) acquire(ctx context.Context, record string, ttl time.Duration) (string, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.holder == "" || l.holder == record || time.Now().After(l.expires) {
+		l.holder = record
+		l.expires = time.Now().Add(ttl)
+	}
+	return l.holder, nil
+}
+
+func (l *memoryLeaderLock) release(ctx context.Context, record string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.holder == record {
+		l.holder = ""
+	}
+	return nil
+}
+
+func (l *memoryLeaderLock) Close() error {
+	return nil
+}
+
+// failingLeaderLock wraps a lock, failing every call while failing is set
+type failingLeaderLock struct {
+	leaderLock
+	failing atomic.Bool
+}
+
+func (l *failingLeaderLock) acquire(ctx context.Context, record string, ttl time.Duration) (string, error) {
+	if l.failing.Load() {
+		return "", errors.New("connection refused")
+	}
+	return l.leaderLock.acquire(ctx, record, ttl)
+}
+
+// testElector creates an elector counting its elections and demotions
+func testElector(t *testing.T, id string, lock leaderLock, elected, demoted *atomic.Int32) *LeaderElector {
+	elector, err := newLeaderElector(&config.LeaderElectionConfig{
+		ID:               id,
+		AdvertiseAddress: "http://" + id + ":9090",
+		LeaseTTL:         150 * time.Millisecond,
+		RenewInterval:    30 * time.Millisecond,
+	}, lock, func() { elected.Add(1) }, func() { demoted.Add(1) })
+	if err != nil {
+		t.Fatalf("Failed to create elector: %v", err)
+	}
+	return elector
+}
+
+func waitFor(t *testing.T, condition func() bool, message string) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal(message)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestLeaderElector_Failover(t *testing.T) {
+	lock := &memoryLeaderLock{}
+	var elected1, demoted1, elected2, demoted2 atomic.Int32
+	first := testElector(t, "controller-1", lock, &elected1, &demoted1)
+	second := testElector(t, "controller-2", lock, &elected2, &demoted2)
+
+	first.Start()
+	second.Start()
+	defer second.Stop()
+
+	if !first.IsLeader() || second.IsLeader() || elected1.Load() != 1 || elected2.Load() != 0 {
+		t.Fatalf("Expected the first controller to lead, got %v and %v", first.Health(), second.Health())
+	}
+	if leader := second.Leader(); leader.ID != "controller-1" || leader.Address != "http://controller-1:9090" {
+		t.Errorf("Expected the standby to know the leader, got %+v", leader)
+	}
+	if health := second.Health(); health["role"] != "standby" || health["leader_id"] != "controller-1" {
+		t.Errorf("Expected standby health, got %v", health)
+	}
+
+	// Stopping the leader releases the lock and the standby takes over at
+	// its next campaign rather than after the TTL
+	first.Stop()
+	if first.IsLeader() || demoted1.Load() != 1 {
+		t.Error("Expected the stopped controller to step down")
+	}
+	start := time.Now()
+	waitFor(t, second.IsLeader, "Expected the standby to take over")
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("Expected the standby to take over within a renew interval, took %v", elapsed)
+	}
+	if elected2.Load() != 1 || second.Leader().ID != "controller-2" {
+		t.Errorf("Expected the second controller elected, got %v", second.Health())
+	}
+}
+
+func TestLeaderElector_StepsDownWhenRenewalFails(t *testing.T) {
+	shared := &memoryLeaderLock{}
+	lock := &failingLeaderLock{leaderLock: shared}
+	var elected1, demoted1, elected2, demoted2 atomic.Int32
+	first := testElector(t, "controller-1", lock, &elected1, &demoted1)
+	second := testElector(t, "controller-2", shared, &elected2, &demoted2)
+
+	first.Start()
+	defer first.Stop()
+	second.Start()
+	defer second.Stop()
+
+	// The leader loses its connection to the lock
+	lock.failing.Store(true)
+	renewed := time.Now()
+	waitFor(t, func() bool { return !first.IsLeader() }, "Expected the leader to step down")
+	steppedDown := time.Now()
+	if demoted1.Load() != 1 {
+		t.Errorf("Expected one demotion, got %d", demoted1.Load())
+	}
+
+	// It steps down before its lease expires and the standby takes over
+	waitFor(t, second.IsLeader, "Expected the standby to take over once the lease expired")
+	if !steppedDown.Before(renewed.Add(150*time.Millisecond)) || time.Now().Before(steppedDown) {
+		t.Errorf("Expected the leader to step down before its lease expired")
+	}
+	if health := first.Health(); health["role"] != "standby" || health["last_error"] == nil {
+		t.Errorf("Expected the failed renewal in health, got %v", health)
+	}
+}
+
+func TestAPIHandler_StandbyWrites(t *testing.T) {
+	newHandler := func(t *testing.T, standbyWrites string) (*APIHandler, *memoryLeaderLock) {
+		cfg := &config.Config{}
+		cfg.AdminAPI.REST = config.RESTConfig{Enabled: true, Prefix: "/api/v1"}
+		cfg.Controller.LeaderElection = config.LeaderElectionConfig{Enabled: true, StandbyWrites: standbyWrites}
+
+		memoryStore, err := store.NewMemoryStore(cfg)
+		if err != nil {
+			t.Fatalf("Failed to create store: %v", err)
+		}
+		t.Cleanup(func() { memoryStore.Close() })
+
+		handler, err := NewAPIHandler(cfg, memoryStore, NewConfigNotifier(cfg, memoryStore, nil), nil)
+		if err != nil {
+			t.Fatalf("Failed to create API handler: %v", err)
+		}
+
+		lock := &memoryLeaderLock{}
+		var elected, demoted atomic.Int32
+		handler.elector = testElector(t, "controller-2", lock, &elected, &demoted)
+		return handler, lock
+	}
+	serve := func(handler http.Handler, method, path string, header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(`{"id":"api","name":"api","rules":{"paths":[{"type":"prefix","value":"/api"}]},"upstream_id":"backend"}`))
+		for name, values := range header {
+			req.Header[name] = values
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("reject", func(t *testing.T) {
+		handler, lock := newHandler(t, "")
+		lock.holder = `{"id":"controller-1","address":"http://controller-1:9090"}`
+		lock.expires = time.Now().Add(time.Hour)
+		handler.elector.campaign()
+
+		// Reads are served by the standby
+		if rr := serve(handler, "GET", "/api/v1/routes", nil); rr.Code != http.StatusOK {
+			t.Errorf("Expected the standby to serve reads, got %d", rr.Code)
+		}
+
+		rr := serve(handler, "POST", "/api/v1/routes/api", nil)
+		if rr.Code != http.StatusConflict || rr.Header().Get("X-Stargate-Leader") != "http://controller-1:9090" {
+			t.Fatalf("Expected 409 naming the leader, got %d %v", rr.Code, rr.Header())
+		}
+		var body map[string]string
+		json.NewDecoder(rr.Body).Decode(&body)
+		if body["leader_id"] != "controller-1" || body["leader_address"] != "http://controller-1:9090" {
+			t.Errorf("Expected the leader in the body, got %v", body)
+		}
+
+		rr = serve(handler, "GET", "/health", nil)
+		if rr.Body.String() != `{"status": "healthy", "role": "standby"}` {
+			t.Errorf("Expected the role in health, got %q", rr.Body.String())
+		}
+
+		// Once elected the controller accepts writes
+		lock.release(context.Background(), lock.holder)
+		handler.elector.campaign()
+		if rr := serve(handler, "POST", "/api/v1/routes/api", nil); strings.Contains(rr.Body.String(), "standby") {
+			t.Errorf("Expected the leader to accept writes, got %d: %s", rr.Code, rr.Body.String())
+		}
+		if rr := serve(handler, "GET", "/health", nil); rr.Body.String() != `{"status": "healthy", "role": "leader"}` {
+			t.Errorf("Expected the leader role in health, got %q", rr.Body.String())
+		}
+	})
+
+	t.Run("proxy", func(t *testing.T) {
+		var forwardedBy string
+		leader := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			forwardedBy = r.Header.Get(forwardedByHeader)
+			body, _ := io.ReadAll(r.Body)
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(r.Method + " " + r.URL.Path + " " + string(body[:8])))
+		}))
+		defer leader.Close()
+
+		handler, lock := newHandler(t, "proxy")
+		lock.holder = `{"id":"controller-1","address":"` + leader.URL + `"}`
+		lock.expires = time.Now().Add(time.Hour)
+		handler.elector.campaign()
+
+		rr := serve(handler, "POST", "/api/v1/routes/api", nil)
+		if rr.Code != http.StatusCreated || rr.Body.String() != `POST /api/v1/routes/api {"id":"a` {
+			t.Errorf("Expected the write proxied to the leader, got %d %q", rr.Code, rr.Body.String())
+		}
+		if forwardedBy != "controller-2" {
+			t.Errorf("Expected the proxied write marked, got %q", forwardedBy)
+		}
+
+		// A write already proxied once isn't forwarded again
+		rr = serve(handler, "POST", "/api/v1/routes/api", http.Header{forwardedByHeader: {"controller-3"}})
+		if rr.Code != http.StatusConflict {
+			t.Errorf("Expected 409 for a forwarded write, got %d", rr.Code)
+		}
+	})
+}
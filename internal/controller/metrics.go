@@ -0,0 +1,244 @@
+package controller
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/songzhibin97/stargate/internal/config"
+	"github.com/songzhibin97/stargate/internal/middleware"
+	"github.com/songzhibin97/stargate/internal/store"
+	"github.com/songzhibin97/stargate/pkg/metrics"
+)
+
+// ControllerMetrics instruments the controller: Admin API requests, store
+// operations, sync status and the configuration version
+type ControllerMetrics struct {
+	provider metrics.Provider
+
+	apiRequests   metrics.CounterVec
+	apiDuration   metrics.HistogramVec
+	storeDuration metrics.HistogramVec
+	syncRunning   metrics.Gauge
+	configChanges metrics.CounterVec
+	configVersion metrics.Gauge
+}
+
+// NewControllerMetrics creates the controller metrics from the metrics
+// configuration shared with the node, so both use the same provider and
+// namespace. The subsystem defaults to "controller".
+func NewControllerMetrics(cfg *config.Config) (*ControllerMetrics, error) {
+	namespace := cfg.Metrics.Namespace
+	subsystem := cfg.Metrics.Subsystem
+
+	// Follow the node: without a provider or enabled metrics the legacy
+	// Prometheus configuration is in effect
+	if cfg.Metrics.Provider == "" && len(cfg.Metrics.EnabledMetrics) == 0 && cfg.Metrics.Prometheus.Enabled {
+		namespace = cfg.Metrics.Prometheus.Namespace
+		subsystem = cfg.Metrics.Prometheus.Subsystem
+	}
+	if namespace == "" {
+		namespace = "stargate"
+	}
+	if subsystem == "" {
+		subsystem = "controller"
+	}
+
+	factory := &middleware.MetricsProviderFactory{}
+	provider, err := factory.CreateProvider(cfg.Metrics.Provider, metrics.ProviderOptions{
+		Namespace:   namespace,
+		Subsystem:   subsystem,
+		ConstLabels: cfg.Metrics.ConstLabels,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create metrics provider: %w", err)
+	}
+
+	m := &ControllerMetrics{provider: provider}
+	if err := m.initMetrics(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// initMetrics creates the controller metrics
+func (m *ControllerMetrics) initMetrics() error {
+	var err error
+
+	m.apiRequests, err = m.provider.NewCounterVec(metrics.MetricOptions{
+		Name:   "api_requests_total",
+		Help:   "Total number of Admin API requests",
+		Labels: []string{"method", "endpoint", "status_code"},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create API requests counter: %w", err)
+	}
+
+	m.apiDuration, err = m.provider.NewHistogramVec(metrics.MetricOptions{
+		Name:    "api_request_duration_seconds",
+		Help:    "Admin API request duration in seconds",
+		Labels:  []string{"method", "endpoint"},
+		Buckets: metrics.GetDefaultBuckets("duration"),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create API request duration histogram: %w", err)
+	}
+
+	m.storeDuration, err = m.provider.NewHistogramVec(metrics.MetricOptions{
+		Name:    "store_operation_duration_seconds",
+		Help:    "Configuration store operation duration in seconds",
+		Labels:  []string{"operation", "status"},
+		Buckets: metrics.GetDefaultBuckets("duration"),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create store operation duration histogram: %w", err)
+	}
+
+	m.syncRunning, err = m.provider.NewGauge(metrics.MetricOptions{
+		Name: "sync_running",
+		Help: "Whether configuration synchronization is running (1) or not (0)",
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create sync status gauge: %w", err)
+	}
+
+	m.configChanges, err = m.provider.NewCounterVec(metrics.MetricOptions{
+		Name:   "config_changes_total",
+		Help:   "Total number of configuration changes",
+		Labels: []string{"type", "resource_type"},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create config changes counter: %w", err)
+	}
+
+	m.configVersion, err = m.provider.NewGauge(metrics.MetricOptions{
+		Name: "config_version",
+		Help: "Current configuration version, as the Unix time of the last change in seconds",
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create config version gauge: %w", err)
+	}
+
+	return nil
+}
+
+// Handler returns the HTTP handler serving the metrics
+func (m *ControllerMetrics) Handler() http.Handler {
+	return m.provider.Handler()
+}
+
+// ObserveAPIRequest records an Admin API request
+func (m *ControllerMetrics) ObserveAPIRequest(method, endpoint string, statusCode int, duration time.Duration) {
+	m.apiRequests.WithLabelValues(method, endpoint, strconv.Itoa(statusCode)).Inc()
+	m.apiDuration.WithLabelValues(method, endpoint).Observe(duration.Seconds())
+}
+
+// ObserveStoreOperation records a store operation
+func (m *ControllerMetrics) ObserveStoreOperation(operation string, duration time.Duration, err error) {
+	status := "success"
+	if err != nil {
+		status = "error"
+	}
+	m.storeDuration.WithLabelValues(operation, status).Observe(duration.Seconds())
+}
+
+// SetSyncRunning records the sync status
+func (m *ControllerMetrics) SetSyncRunning(running bool) {
+	if running {
+		m.syncRunning.Set(1)
+	} else {
+		m.syncRunning.Set(0)
+	}
+}
+
+// ObserveConfigChange records a configuration change and its version
+func (m *ControllerMetrics) ObserveConfigChange(event *ConfigChangeEvent) {
+	resourceType := "other"
+	for prefix, rt := range webhookResourceTypes {
+		if strings.HasPrefix(event.Key, prefix) {
+			resourceType = rt
+			break
+		}
+	}
+	m.configChanges.WithLabelValues(string(event.Type), resourceType).Inc()
+
+	// Versions are "v" followed by the Unix time of the change in nanoseconds
+	if nanos, err := strconv.ParseInt(strings.TrimPrefix(event.Version, "v"), 10, 64); err == nil {
+		m.configVersion.Set(float64(nanos) / float64(time.Second))
+	}
+}
+
+// InstrumentStore wraps a store so its operations are recorded
+func (m *ControllerMetrics) InstrumentStore(s store.Store) store.Store {
+	return &instrumentedStore{Store: s, metrics: m}
+}
+
+// instrumentedStore records the duration of store operations
+type instrumentedStore struct {
+	store.Store
+	metrics *ControllerMetrics
+}
+
+// Get retrieves a value by key
+func (s *instrumentedStore) Get(ctx context.Context, key string) ([]byte, error) {
+	start := time.Now()
+	value, err := s.Store.Get(ctx, key)
+	s.metrics.ObserveStoreOperation("get", time.Since(start), err)
+	return value, err
+}
+
+// Put stores a value by key
+func (s *instrumentedStore) Put(ctx context.Context, key string, value []byte) error {
+	start := time.Now()
+	err := s.Store.Put(ctx, key, value)
+	s.metrics.ObserveStoreOperation("put", time.Since(start), err)
+	return err
+}
+
+// Delete deletes a value by key
+func (s *instrumentedStore) Delete(ctx context.Context, key string) error {
+	start := time.Now()
+	err := s.Store.Delete(ctx, key)
+	s.metrics.ObserveStoreOperation("delete", time.Since(start), err)
+	return err
+}
+
+// List lists all keys with the given prefix
+func (s *instrumentedStore) List(ctx context.Context, prefix string) (map[string][]byte, error) {
+	start := time.Now()
+	values, err := s.Store.List(ctx, prefix)
+	s.metrics.ObserveStoreOperation("list", time.Since(start), err)
+	return values, err
+}
+
+// statusRecorder captures the status code written by a handler
+type statusRecorder struct {
+	http.ResponseWriter
+	statusCode int
+}
+
+// WriteHeader captures the status code
+func (r *statusRecorder) WriteHeader(statusCode int) {
+	r.statusCode = statusCode
+	r.ResponseWriter.WriteHeader(statusCode)
+}
+
+// Flush implements http.Flusher
+func (r *statusRecorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack implements http.Hijacker
+func (r *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if hijacker, ok := r.ResponseWriter.(http.Hijacker); ok {
+		return hijacker.Hijack()
+	}
+	return nil, nil, fmt.Errorf("response writer does not support hijacking")
+}
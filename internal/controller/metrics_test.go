@@ -0,0 +1,112 @@
+package controller
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/songzhibin97/stargate/internal/config"
+	"github.com/songzhibin97/stargate/internal/store"
+)
+
+func TestAPIHandler_Metrics(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Metrics = config.MetricsConfig{
+		Enabled:   true,
+		Path:      "/internal/metrics",
+		Provider:  "prometheus",
+		Namespace: "stargate",
+		Subsystem: "controller",
+	}
+	cfg.AdminAPI.REST = config.RESTConfig{Enabled: true, Prefix: "/api/v1"}
+	cfg.AdminAPI.Auth = config.AuthConfig{
+		Enabled: true,
+		APIKey:  config.APIKeyConfig{Header: "X-Admin-Key", Keys: []string{"secret"}},
+	}
+
+	controllerMetrics, err := NewControllerMetrics(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create controller metrics: %v", err)
+	}
+
+	memoryStore, err := store.NewMemoryStore(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	instrumented := controllerMetrics.InstrumentStore(memoryStore)
+	defer instrumented.Close()
+
+	notifier := NewConfigNotifier(cfg, instrumented, nil)
+	notifier.metrics = controllerMetrics
+
+	handler, err := NewAPIHandler(cfg, instrumented, notifier, controllerMetrics)
+	if err != nil {
+		t.Fatalf("Failed to create API handler: %v", err)
+	}
+
+	// An authenticated Admin API request
+	req := httptest.NewRequest("GET", "/api/v1/routes", nil)
+	req.Header.Set("X-Admin-Key", "secret")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	// A rejected request to a route with an ID
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/api/v1/routes/api", nil))
+	if rr.Code != http.StatusUnauthorized {
+		t.Fatalf("Expected status 401, got %d", rr.Code)
+	}
+
+	notifier.NotifyChange(&ConfigChangeEvent{Type: ConfigChangeTypeCreate, Key: "routes/api", Version: "v1700000000000000000"})
+
+	// The metrics path is served without credentials
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/internal/metrics", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected metrics to be served without credentials, got status %d", rr.Code)
+	}
+	body, _ := io.ReadAll(rr.Body)
+	output := string(body)
+
+	expected := []string{
+		`stargate_controller_api_requests_total{endpoint="/api/v1/routes",method="GET",status_code="200"} 1`,
+		`stargate_controller_api_requests_total{endpoint="/api/v1/routes/",method="GET",status_code="401"} 1`,
+		`stargate_controller_api_request_duration_seconds_count{endpoint="/api/v1/routes",method="GET"} 1`,
+		`stargate_controller_store_operation_duration_seconds_count{operation="list",status="success"}`,
+		`stargate_controller_config_changes_total{resource_type="route",type="create"} 1`,
+		`stargate_controller_config_version 1.7e+09`,
+	}
+	for _, line := range expected {
+		if !strings.Contains(output, line) {
+			t.Errorf("Expected metrics output to contain %q", line)
+		}
+	}
+}
+
+func TestSyncManager_Metrics(t *testing.T) {
+	cfg := &config.Config{}
+	controllerMetrics, err := NewControllerMetrics(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create controller metrics: %v", err)
+	}
+
+	syncManager, _ := NewSyncManager(cfg)
+	syncManager.metrics = controllerMetrics
+
+	if err := syncManager.Start(); err != nil {
+		t.Fatalf("Failed to start sync manager: %v", err)
+	}
+	if value := controllerMetrics.syncRunning.Get(); value != 1 {
+		t.Errorf("Expected sync_running 1, got %v", value)
+	}
+
+	syncManager.Stop()
+	if value := controllerMetrics.syncRunning.Get(); value != 0 {
+		t.Errorf("Expected sync_running 0, got %v", value)
+	}
+}
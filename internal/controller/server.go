@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"golang.org/x/net/http2"
@@ -17,6 +18,7 @@ import (
 	"github.com/songzhibin97/stargate/internal/portal/middleware"
 	"github.com/songzhibin97/stargate/internal/portal/repository/memory"
 	"github.com/songzhibin97/stargate/internal/portal/repository/postgres"
+	"github.com/songzhibin97/stargate/internal/portal/repository/secret"
 	"github.com/songzhibin97/stargate/internal/store"
 	"github.com/songzhibin97/stargate/internal/tls"
 	"github.com/songzhibin97/stargate/pkg/portal"
@@ -41,6 +43,7 @@ type Server struct {
 	acmeManager    *tls.ACMEManager
 	store          store.Store
 	configNotifier *ConfigNotifier
+	elector        *LeaderElector // Set when leader election is enabled
 	mu             sync.RWMutex
 	running        bool
 }
@@ -61,14 +64,21 @@ type APIHandler struct {
 	portalHandler     *handler.PortalHandler
 	applicationHandler *handler.ApplicationHandler
 	jwtMiddleware     *middleware.JWTMiddleware
+	metrics           *ControllerMetrics
+	protectedMux      *http.ServeMux
 	userRepo          portal.UserRepository
 	appRepo           portal.ApplicationRepository
 	gatewayClient     GatewayClientInterface
+	elector           *LeaderElector // Set when leader election is enabled
+	notReady          atomic.Bool // /health reports not ready, set on lame duck
 }
 
-// SyncManager manages configuration synchronization
+// SyncManager manages configuration synchronization. With leader election
+// it runs on the leader only, started and stopped as leadership changes.
 type SyncManager struct {
 	config  *config.Config
+	metrics *ControllerMetrics
+	mu      sync.Mutex
 	running bool
 	stopCh  chan struct{}
 	wg      sync.WaitGroup
@@ -95,12 +105,32 @@ func NewServer(cfg *config.Config) (*Server, error) {
 		return nil, fmt.Errorf("unsupported store type: %s", cfg.Store.Type)
 	}
 
+	// Create the leader lock on the store's etcd cluster or Redis
+	var lock leaderLock
+	if cfg.Controller.LeaderElection.Enabled {
+		lock, err = newLeaderLock(cfg, storeInstance)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create leader lock: %w", err)
+		}
+	}
+
+	// Create metrics and instrument the store
+	var controllerMetrics *ControllerMetrics
+	if cfg.Metrics.Enabled {
+		controllerMetrics, err = NewControllerMetrics(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create controller metrics: %w", err)
+		}
+		storeInstance = controllerMetrics.InstrumentStore(storeInstance)
+	}
+
 	// Create configuration notifier
 	logger := pkglog.Component("controller.server")
 	configNotifier := NewConfigNotifier(cfg, storeInstance, logger)
+	configNotifier.metrics = controllerMetrics
 
 	// Create API handler
-	apiHandler, err := NewAPIHandler(cfg, storeInstance, configNotifier)
+	apiHandler, err := NewAPIHandler(cfg, storeInstance, configNotifier, controllerMetrics)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create API handler: %w", err)
 	}
@@ -110,6 +140,23 @@ func NewServer(cfg *config.Config) (*Server, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to create sync manager: %w", err)
 	}
+	syncManager.metrics = controllerMetrics
+
+	// Elect the active controller, which alone runs sync and delivers
+	// webhooks; standbys reject or proxy Admin API writes
+	var elector *LeaderElector
+	if lock != nil {
+		elector, err = newLeaderElector(&cfg.Controller.LeaderElection, lock, func() {
+			if err := syncManager.Start(); err != nil {
+				log.Printf("Failed to start sync: %v", err)
+			}
+		}, syncManager.Stop)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create leader elector: %w", err)
+		}
+		configNotifier.elector = elector
+		apiHandler.elector = elector
+	}
 
 	// Create ACME manager if enabled
 	var acmeManager *tls.ACMEManager
@@ -138,7 +185,12 @@ func NewServer(cfg *config.Config) (*Server, error) {
 		}
 
 		// Configure HTTP/2 support for TLS connections
-		if err := http2.ConfigureServer(httpServer, &http2.Server{}); err != nil {
+		h2 := &http2.Server{
+			MaxConcurrentStreams: cfg.Controller.HTTP2.MaxConcurrentStreams,
+			MaxReadFrameSize:     cfg.Controller.HTTP2.MaxReadFrameSize,
+			IdleTimeout:          cfg.Controller.HTTP2.IdleTimeout,
+		}
+		if err := http2.ConfigureServer(httpServer, h2); err != nil {
 			log.Printf("Failed to configure HTTP/2 for controller server: %v", err)
 		} else {
 			log.Println("HTTP/2 support enabled for controller server TLS connections")
@@ -153,15 +205,16 @@ func NewServer(cfg *config.Config) (*Server, error) {
 		acmeManager:    acmeManager,
 		store:          storeInstance,
 		configNotifier: configNotifier,
+		elector:        elector,
 	}, nil
 }
 
 // Start starts the controller server
 func (s *Server) Start() error {
 	s.mu.Lock()
-	defer s.mu.Unlock()
 
 	if s.running {
+		s.mu.Unlock()
 		return fmt.Errorf("server is already running")
 	}
 
@@ -169,17 +222,22 @@ func (s *Server) Start() error {
 
 	// Start configuration notifier
 	if err := s.configNotifier.Start(); err != nil {
+		s.mu.Unlock()
 		return fmt.Errorf("failed to start config notifier: %w", err)
 	}
 
 	// Start ACME manager if enabled
 	if s.acmeManager != nil {
 		if err := s.acmeManager.Start(); err != nil {
+			s.mu.Unlock()
 			return fmt.Errorf("failed to start ACME manager: %w", err)
 		}
 		log.Printf("ACME manager started for domains: %v", s.acmeManager.GetDomains())
 	}
 
+	// Release the lock while serving so Shutdown and Health aren't blocked
+	s.mu.Unlock()
+
 	// Start HTTP server
 	if s.config.Controller.TLS.Enabled {
 		if s.acmeManager != nil {
@@ -197,16 +255,47 @@ func (s *Server) Start() error {
 	return s.httpServer.ListenAndServe()
 }
 
-// StartSync starts the configuration synchronization
+// StartSync starts the configuration synchronization, or with leader
+// election campaigns for leadership and starts it once elected
 func (s *Server) StartSync() error {
+	if s.elector != nil {
+		return s.elector.Start()
+	}
 	return s.syncManager.Start()
 }
 
-// StopSync stops the configuration synchronization
+// StopSync stops the configuration synchronization, stepping down as
+// leader so a standby takes over
 func (s *Server) StopSync() {
+	if s.elector != nil {
+		s.elector.Stop()
+	}
 	s.syncManager.Stop()
 }
 
+// LameDuck marks the controller not ready and keeps serving for the
+// configured lame-duck duration, or until ctx is done, so load balancers
+// that remove endpoints asynchronously stop routing here before Shutdown
+func (s *Server) LameDuck(ctx context.Context) {
+	s.apiHandler.notReady.Store(true)
+
+	duration := s.config.Controller.LameDuckDuration
+	if duration <= 0 {
+		return
+	}
+
+	log.Printf("Entering lame-duck period: reporting not ready and serving for %s", duration)
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		log.Println("Lame-duck period complete")
+	case <-ctx.Done():
+		log.Println("Lame-duck period cut short")
+	}
+}
+
 // Shutdown gracefully shuts down the server
 func (s *Server) Shutdown(ctx context.Context) error {
 	s.mu.Lock()
@@ -228,7 +317,10 @@ func (s *Server) Shutdown(ctx context.Context) error {
 	// Stop configuration notifier
 	s.configNotifier.Stop()
 
-	// Stop sync manager
+	// Stop sync manager, releasing leadership before the store closes
+	if s.elector != nil {
+		s.elector.Stop()
+	}
 	s.syncManager.Stop()
 
 	// Close store
@@ -247,8 +339,13 @@ func (s *Server) Health() map[string]interface{} {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
+	status := "healthy"
+	if s.apiHandler.notReady.Load() {
+		status = "not_ready"
+	}
+
 	health := map[string]interface{}{
-		"status":    "healthy",
+		"status":    status,
 		"timestamp": time.Now().Unix(),
 		"running":   s.running,
 		"server": map[string]interface{}{
@@ -266,6 +363,11 @@ func (s *Server) Health() map[string]interface{} {
 		health["sync"] = syncHealth
 	}
 
+	// Add leadership state
+	if s.elector != nil {
+		health["leader_election"] = s.elector.Health()
+	}
+
 	return health
 }
 
@@ -294,12 +396,14 @@ func (s *Server) Metrics() map[string]interface{} {
 	return metrics
 }
 
-// NewAPIHandler creates a new API handler
-func NewAPIHandler(cfg *config.Config, store store.Store, configNotifier *ConfigNotifier) (*APIHandler, error) {
+// NewAPIHandler creates a new API handler. Requests are recorded in
+// controllerMetrics when it's not nil.
+func NewAPIHandler(cfg *config.Config, store store.Store, configNotifier *ConfigNotifier, controllerMetrics *ControllerMetrics) (*APIHandler, error) {
 	apiHandler := &APIHandler{
 		config:          cfg,
 		store:           store,
 		configNotifier:  configNotifier,
+		metrics:         controllerMetrics,
 		mux:             http.NewServeMux(),
 		routeHandler:    api.NewRouteHandler(cfg, store, configNotifier),
 		upstreamHandler: api.NewUpstreamHandler(cfg, store, configNotifier),
@@ -307,8 +411,8 @@ func NewAPIHandler(cfg *config.Config, store store.Store, configNotifier *Config
 		configHandler:   api.NewConfigHandler(cfg, store),
 		authHandler:     api.NewAuthHandler(cfg),
 		authMiddleware:  api.NewAuthMiddleware(cfg),
-		docsHandler:     api.NewDocsHandler(),
 	}
+	apiHandler.docsHandler = api.NewDocsHandler(apiHandler.adminRoutes)
 
 	// Initialize Portal components if enabled
 	if cfg.Portal.Enabled {
@@ -358,7 +462,7 @@ func NewAPIHandler(cfg *config.Config, store store.Store, configNotifier *Config
 func createUserRepository(cfg *config.Config) (portal.UserRepository, error) {
 	switch cfg.Portal.Repository.Type {
 	case "memory":
-		repo := memory.NewRepository()
+		repo := memory.NewRepository(memory.WithAllowedScopes(cfg.Portal.Scopes))
 		return memory.NewUserRepository(repo), nil
 	case "postgres":
 		pgConfig := &postgres.Config{
@@ -368,7 +472,7 @@ func createUserRepository(cfg *config.Config) (portal.UserRepository, error) {
 			ConnMaxLifetime: cfg.Portal.Repository.Postgres.ConnMaxLifetime,
 			MigrationPath:   cfg.Portal.Repository.Postgres.MigrationPath,
 		}
-		repo, err := postgres.NewRepository(pgConfig)
+		repo, err := postgres.NewRepository(pgConfig, postgres.WithAllowedScopes(cfg.Portal.Scopes))
 		if err != nil {
 			return nil, fmt.Errorf("failed to create postgres repository: %w", err)
 		}
@@ -388,7 +492,7 @@ func createUserRepository(cfg *config.Config) (portal.UserRepository, error) {
 func createApplicationRepository(cfg *config.Config) (portal.ApplicationRepository, error) {
 	switch cfg.Portal.Repository.Type {
 	case "memory":
-		repo := memory.NewRepository()
+		repo := memory.NewRepository(memory.WithAllowedScopes(cfg.Portal.Scopes))
 		return memory.NewApplicationRepository(repo), nil
 	case "postgres":
 		pgConfig := &postgres.Config{
@@ -398,7 +502,11 @@ func createApplicationRepository(cfg *config.Config) (portal.ApplicationReposito
 			ConnMaxLifetime: cfg.Portal.Repository.Postgres.ConnMaxLifetime,
 			MigrationPath:   cfg.Portal.Repository.Postgres.MigrationPath,
 		}
-		repo, err := postgres.NewRepository(pgConfig)
+		hasher, err := secret.NewHasher(cfg.Portal.Repository.Postgres.SecretHashAlgorithm)
+		if err != nil {
+			return nil, fmt.Errorf("invalid postgres repository: %w", err)
+		}
+		repo, err := postgres.NewRepository(pgConfig, postgres.WithAllowedScopes(cfg.Portal.Scopes), postgres.WithSecretHasher(hasher))
 		if err != nil {
 			return nil, fmt.Errorf("failed to create postgres repository: %w", err)
 		}
@@ -413,7 +521,7 @@ func createApplicationRepository(cfg *config.Config) (portal.ApplicationReposito
 func createRepositories(cfg *config.Config) (portal.UserRepository, portal.ApplicationRepository, error) {
 	switch cfg.Portal.Repository.Type {
 	case "memory":
-		repo := memory.NewRepository()
+		repo := memory.NewRepository(memory.WithAllowedScopes(cfg.Portal.Scopes))
 		userRepo := memory.NewUserRepository(repo)
 		appRepo := memory.NewApplicationRepository(repo)
 		return userRepo, appRepo, nil
@@ -425,7 +533,11 @@ func createRepositories(cfg *config.Config) (portal.UserRepository, portal.Appli
 			ConnMaxLifetime: cfg.Portal.Repository.Postgres.ConnMaxLifetime,
 			MigrationPath:   cfg.Portal.Repository.Postgres.MigrationPath,
 		}
-		repo, err := postgres.NewRepository(pgConfig)
+		hasher, err := secret.NewHasher(cfg.Portal.Repository.Postgres.SecretHashAlgorithm)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid postgres repository: %w", err)
+		}
+		repo, err := postgres.NewRepository(pgConfig, postgres.WithAllowedScopes(cfg.Portal.Scopes), postgres.WithSecretHasher(hasher))
 		if err != nil {
 			return nil, nil, fmt.Errorf("failed to create postgres repository: %w", err)
 		}
@@ -445,14 +557,37 @@ func createRepositories(cfg *config.Config) (portal.UserRepository, portal.Appli
 
 // ServeHTTP implements http.Handler interface
 func (ah *APIHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	ah.mux.ServeHTTP(w, r)
+	if ah.metrics == nil {
+		ah.mux.ServeHTTP(w, r)
+		return
+	}
+
+	start := time.Now()
+	recorder := &statusRecorder{ResponseWriter: w, statusCode: http.StatusOK}
+	ah.mux.ServeHTTP(recorder, r)
+	ah.metrics.ObserveAPIRequest(r.Method, ah.endpoint(r), recorder.statusCode, time.Since(start))
+}
+
+// endpoint returns the route pattern matching a request, keeping the
+// cardinality of the endpoint label bounded
+func (ah *APIHandler) endpoint(r *http.Request) string {
+	_, pattern := ah.mux.Handler(r)
+	if ah.protectedMux != nil && pattern == ah.config.AdminAPI.REST.Prefix+"/" {
+		_, pattern = ah.protectedMux.Handler(r)
+	}
+	if pattern == "" {
+		return "unmatched"
+	}
+	return pattern
 }
 
 // setupRoutes sets up API routes
 func (ah *APIHandler) setupRoutes() {
-	// Health endpoint (no auth required)
+	// Health and metrics endpoints (no auth required)
 	ah.mux.HandleFunc("/health", ah.handleHealth)
-	ah.mux.HandleFunc("/metrics", ah.handleMetrics)
+	if ah.metrics != nil {
+		ah.mux.Handle(ah.config.Metrics.Path, ah.metrics.Handler())
+	}
 
 	// Documentation endpoints (no auth required)
 	ah.mux.HandleFunc("/docs", ah.docsHandler.ServeSwaggerUI)
@@ -501,9 +636,61 @@ func (ah *APIHandler) setupRoutes() {
 		protectedMux.HandleFunc(prefix+"/config", ah.configHandler.GetConfig)
 		protectedMux.HandleFunc(prefix+"/config/validate", ah.configHandler.ValidateConfig)
 
-		// Wrap protected routes with auth middleware
-		ah.mux.Handle(prefix+"/", ah.authMiddleware.Middleware(protectedMux))
+		// Documentation drift against the live routes
+		protectedMux.HandleFunc(prefix+"/docs/validate", ah.docsHandler.ValidateSpec)
+		protectedMux.HandleFunc(prefix+"/docs/stub", ah.docsHandler.ServeStub)
+
+		// Wrap protected routes with auth middleware, writes reaching
+		// only the leader
+		ah.protectedMux = protectedMux
+		ah.mux.Handle(prefix+"/", ah.authMiddleware.Middleware(ah.standbyWrites(protectedMux)))
+	}
+}
+
+// adminRoutes returns the routes setupRoutes serves with the current
+// configuration, which the served OpenAPI document is validated against.
+// Documentation and metrics endpoints aren't part of the API.
+func (ah *APIHandler) adminRoutes() []api.LiveRoute {
+	routes := []api.LiveRoute{
+		{Path: "/health", Methods: []string{http.MethodGet}},
+		{Path: "/auth/login", Methods: []string{http.MethodPost}},
+		{Path: "/auth/api-keys", Methods: []string{http.MethodPost}},
+	}
+
+	if ah.config.Portal.Enabled && ah.portalHandler != nil {
+		routes = append(routes,
+			api.LiveRoute{Path: "/api/register", Methods: []string{http.MethodPost}},
+			api.LiveRoute{Path: "/api/login", Methods: []string{http.MethodPost}},
+		)
 	}
+	if ah.config.Portal.Enabled && ah.applicationHandler != nil && ah.jwtMiddleware != nil {
+		routes = append(routes,
+			api.LiveRoute{Path: "/api/applications", Methods: []string{http.MethodGet}},
+			api.LiveRoute{Path: "/api/applications/create", Methods: []string{http.MethodPost}},
+			api.LiveRoute{Path: "/api/applications/{id}", Methods: []string{http.MethodGet, http.MethodPut, http.MethodDelete}},
+			api.LiveRoute{Path: "/api/applications/{id}/regenerate-key", Methods: []string{http.MethodPost}},
+		)
+	}
+
+	if ah.config.AdminAPI.REST.Enabled {
+		prefix := ah.config.AdminAPI.REST.Prefix
+		byID := []string{http.MethodGet, http.MethodPut, http.MethodDelete, http.MethodPost}
+		routes = append(routes,
+			api.LiveRoute{Path: prefix + "/routes", Methods: []string{http.MethodGet}},
+			api.LiveRoute{Path: prefix + "/routes/{id}", Methods: byID},
+			api.LiveRoute{Path: prefix + "/routes/{id}/enable", Methods: []string{http.MethodPost}},
+			api.LiveRoute{Path: prefix + "/routes/{id}/disable", Methods: []string{http.MethodPost}},
+			api.LiveRoute{Path: prefix + "/upstreams", Methods: []string{http.MethodGet}},
+			api.LiveRoute{Path: prefix + "/upstreams/{id}", Methods: byID},
+			api.LiveRoute{Path: prefix + "/plugins", Methods: []string{http.MethodGet}},
+			api.LiveRoute{Path: prefix + "/plugins/{id}", Methods: byID},
+			api.LiveRoute{Path: prefix + "/config", Methods: []string{http.MethodGet}},
+			api.LiveRoute{Path: prefix + "/config/validate", Methods: []string{http.MethodPost}},
+			api.LiveRoute{Path: prefix + "/docs/validate", Methods: []string{http.MethodGet}},
+			api.LiveRoute{Path: prefix + "/docs/stub", Methods: []string{http.MethodGet}},
+		)
+	}
+	return routes
 }
 
 // Health returns API handler health
@@ -526,18 +713,28 @@ func (ah *APIHandler) Metrics() map[string]interface{} {
 // HTTP handlers
 func (ah *APIHandler) handleHealth(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
+	if ah.notReady.Load() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte(`{"status": "not_ready"}`))
+		return
+	}
 	w.WriteHeader(http.StatusOK)
+	if ah.elector != nil {
+		fmt.Fprintf(w, `{"status": "healthy", "role": %q}`, ah.elector.Role())
+		return
+	}
 	w.Write([]byte(`{"status": "healthy"}`))
 }
 
-func (ah *APIHandler) handleMetrics(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte(`{"metrics": "placeholder"}`))
-}
-
 // Route handlers with ID routing
 func (ah *APIHandler) handleRouteWithID(w http.ResponseWriter, r *http.Request) {
+	// POST /routes/{id}/enable and /routes/{id}/disable toggle the route
+	suffix := strings.TrimPrefix(r.URL.Path, ah.config.AdminAPI.REST.Prefix+"/routes/")
+	if strings.Contains(strings.Trim(suffix, "/"), "/") {
+		ah.routeHandler.SetRouteEnabled(w, r)
+		return
+	}
+
 	switch r.Method {
 	case http.MethodGet:
 		ah.routeHandler.GetRoute(w, r)
@@ -596,11 +793,18 @@ func NewSyncManager(cfg *config.Config) (*SyncManager, error) {
 
 // Start starts the sync manager
 func (sm *SyncManager) Start() error {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
 	if sm.running {
 		return fmt.Errorf("sync manager is already running")
 	}
 
 	sm.running = true
+	sm.stopCh = make(chan struct{})
+	if sm.metrics != nil {
+		sm.metrics.SetSyncRunning(true)
+	}
 
 	if sm.config.Sync.GitOps.Enabled {
 		sm.wg.Add(1)
@@ -612,6 +816,9 @@ func (sm *SyncManager) Start() error {
 
 // Stop stops the sync manager
 func (sm *SyncManager) Stop() {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
 	if !sm.running {
 		return
 	}
@@ -619,10 +826,16 @@ func (sm *SyncManager) Stop() {
 	sm.running = false
 	close(sm.stopCh)
 	sm.wg.Wait()
+	if sm.metrics != nil {
+		sm.metrics.SetSyncRunning(false)
+	}
 }
 
 // Health returns sync manager health
 func (sm *SyncManager) Health() map[string]interface{} {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
 	return map[string]interface{}{
 		"status":         "healthy",
 		"running":        sm.running,
@@ -632,6 +845,9 @@ func (sm *SyncManager) Health() map[string]interface{} {
 
 // Metrics returns sync manager metrics
 func (sm *SyncManager) Metrics() map[string]interface{} {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
 	return map[string]interface{}{
 		"running":        sm.running,
 		"gitops_enabled": sm.config.Sync.GitOps.Enabled,
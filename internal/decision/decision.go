@@ -0,0 +1,75 @@
+// Package decision carries the reasons middlewares rejected a request to the
+// access log.
+//
+// The access log installs a Recorder in the request context before calling
+// the rest of the chain. Middlewares that reject a request record their
+// decision on it, and the access log emits the recorded decision once the
+// response is written. Without a recorder in the context recording is a no-op.
+package decision
+
+import (
+	"context"
+	"sync"
+)
+
+// Outcomes of rejecting middlewares
+const (
+	OutcomeRateLimited = "rate_limited"
+	OutcomeAuthFailed  = "auth_failed"
+	OutcomeIPDenied    = "ip_acl_denied"
+	OutcomeCircuitOpen = "circuit_open"
+)
+
+// contextKey is the request context key holding the recorder
+type contextKey struct{}
+
+// Decision is the decision of a middleware about a request
+type Decision struct {
+	Middleware string            // Middleware that made the decision
+	Outcome    string            // One of the Outcome constants
+	Reason     string            // Why the middleware decided so
+	Fields     map[string]string // Middleware specific details
+}
+
+// Recorder collects the decisions made about a request
+type Recorder struct {
+	mu        sync.Mutex
+	decisions []Decision
+}
+
+// WithRecorder returns a context carrying a new recorder
+func WithRecorder(ctx context.Context) (context.Context, *Recorder) {
+	recorder := &Recorder{}
+	return context.WithValue(ctx, contextKey{}, recorder), recorder
+}
+
+// FromContext returns the recorder of the context, or nil
+func FromContext(ctx context.Context) *Recorder {
+	recorder, _ := ctx.Value(contextKey{}).(*Recorder)
+	return recorder
+}
+
+// Record records a decision on the recorder of the context, if any
+func Record(ctx context.Context, d Decision) {
+	if recorder := FromContext(ctx); recorder != nil {
+		recorder.Record(d)
+	}
+}
+
+// Record records a decision
+func (r *Recorder) Record(d Decision) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.decisions = append(r.decisions, d)
+}
+
+// Last returns the most recent decision, the one that ended the request
+func (r *Recorder) Last() (Decision, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(r.decisions) == 0 {
+		return Decision{}, false
+	}
+	return r.decisions[len(r.decisions)-1], true
+}
@@ -0,0 +1,27 @@
+package decision
+
+import (
+	"context"
+	"testing"
+)
+
+func TestRecord(t *testing.T) {
+	// Recording without a recorder is a no-op
+	Record(context.Background(), Decision{Outcome: OutcomeRateLimited})
+
+	ctx, recorder := WithRecorder(context.Background())
+	if _, ok := recorder.Last(); ok {
+		t.Fatal("Expected no decision before recording")
+	}
+
+	Record(ctx, Decision{Middleware: "ratelimit", Outcome: OutcomeRateLimited})
+	Record(ctx, Decision{Middleware: "auth", Outcome: OutcomeAuthFailed, Reason: "expired"})
+
+	if FromContext(ctx) != recorder {
+		t.Error("Expected the recorder to be carried by the context")
+	}
+	d, ok := recorder.Last()
+	if !ok || d.Middleware != "auth" || d.Reason != "expired" {
+		t.Errorf("Expected the last decision to be returned, got %+v", d)
+	}
+}
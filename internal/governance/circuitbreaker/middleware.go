@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/songzhibin97/stargate/internal/config"
+	"github.com/songzhibin97/stargate/internal/decision"
 )
 
 // Middleware represents the circuit breaker middleware
@@ -19,6 +20,9 @@ type Middleware struct {
 	
 	// Default circuit breaker for routes without specific configuration
 	defaultCircuitBreaker *CircuitBreaker
+
+	// stateChangeListener is notified of state changes of every circuit breaker
+	stateChangeListener func(name string, from, to State)
 }
 
 // NewMiddleware creates a new circuit breaker middleware
@@ -51,14 +55,14 @@ func NewMiddleware(config *config.CircuitBreakerConfig) (*Middleware, error) {
 		defaultConfig.ErrorPercentageThreshold = 50
 	}
 
-	defaultCB := New("default", defaultConfig)
-	defaultCB.SetStateChangeCallback(logStateChange)
-
-	return &Middleware{
+	m := &Middleware{
 		config:                config,
 		circuitBreakers:       make(map[string]*CircuitBreaker),
-		defaultCircuitBreaker: defaultCB,
-	}, nil
+		defaultCircuitBreaker: New("default", defaultConfig),
+	}
+	m.defaultCircuitBreaker.SetStateChangeCallback(m.onStateChange)
+
+	return m, nil
 }
 
 // Handler returns the HTTP middleware handler
@@ -136,7 +140,7 @@ func (m *Middleware) getCircuitBreaker(r *http.Request) *CircuitBreaker {
 		}
 
 		cb = New(routeID, config)
-		cb.SetStateChangeCallback(logStateChange)
+		cb.SetStateChangeCallback(m.onStateChange)
 		m.circuitBreakers[routeID] = cb
 		m.mutex.Unlock()
 
@@ -177,6 +181,20 @@ func (m *Middleware) isSuccessfulResponse(statusCode int) bool {
 // handleCircuitOpen handles requests when circuit breaker is open
 func (m *Middleware) handleCircuitOpen(w http.ResponseWriter, r *http.Request, cb *CircuitBreaker) {
 	stats := cb.GetStatistics()
+
+	reason := "breaker_open"
+	if cb.GetState() == StateHalfOpen {
+		reason = "half_open_limit"
+	}
+	decision.Record(r.Context(), decision.Decision{
+		Middleware: "circuitbreaker",
+		Outcome:    decision.OutcomeCircuitOpen,
+		Reason:     reason,
+		Fields: map[string]string{
+			"breaker":    cb.GetName(),
+			"error_rate": fmt.Sprintf("%.2f", stats.ErrorRate()),
+		},
+	})
 	
 	// Set circuit breaker headers
 	w.Header().Set("X-Circuit-Breaker-State", cb.GetState().String())
@@ -285,6 +303,27 @@ func logStateChange(name string, from, to State) {
 	log.Printf("Circuit breaker '%s' state changed from %s to %s", name, from.String(), to.String())
 }
 
+// SetStateChangeListener sets a function notified of the state changes of
+// every circuit breaker, including breakers created later. The listener is
+// called in its own goroutine and must not block for long.
+func (m *Middleware) SetStateChangeListener(listener func(name string, from, to State)) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.stateChangeListener = listener
+}
+
+// onStateChange logs a state change and notifies the listener
+func (m *Middleware) onStateChange(name string, from, to State) {
+	logStateChange(name, from, to)
+
+	m.mutex.RLock()
+	listener := m.stateChangeListener
+	m.mutex.RUnlock()
+	if listener != nil {
+		listener(name, from, to)
+	}
+}
+
 // HealthCheck returns the health status of all circuit breakers
 func (m *Middleware) HealthCheck() map[string]interface{} {
 	result := make(map[string]interface{})
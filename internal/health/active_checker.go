@@ -3,12 +3,14 @@ package health
 import (
 	"context"
 	"fmt"
+	"log"
 	"net/http"
 	"sync"
 	"time"
 
 	"github.com/songzhibin97/stargate/internal/config"
 	"github.com/songzhibin97/stargate/internal/types"
+	"github.com/songzhibin97/stargate/internal/webhook"
 )
 
 // ActiveHealthChecker 主动健康检查器
@@ -21,6 +23,24 @@ type ActiveHealthChecker struct {
 	running     bool
 	client      *http.Client
 	callbacks   []HealthChangeCallback
+	webhook     *webhook.Sender
+
+	// 启动预热：宽限期内检查失败不会将目标标记为不健康；
+	// 或等待所有目标完成首次检查后才报告就绪
+	startedAt         time.Time
+	gracePeriod       time.Duration
+	waitForFirstCheck bool
+	warmedUp          bool
+
+	// 按探测URL合并探测：多个上游服务指向同一 host:port 和路径时共享探测结果
+	probeMu sync.Mutex
+	probes  map[string]*sharedProbe
+}
+
+// sharedProbe 一次探测，进行中时其他目标等待其结果，完成后在半个检查间隔内复用
+type sharedProbe struct {
+	done   chan struct{}
+	result *HealthCheckResult
 }
 
 // upstreamHealthState 上游服务健康状态
@@ -39,6 +59,7 @@ type targetHealthState struct {
 	consecutiveFailures int
 	lastCheckTime       time.Time
 	lastError           error
+	checked             bool // 是否已完成首次检查
 }
 
 // HealthCheckResult 健康检查结果
@@ -57,7 +78,7 @@ type HealthChangeCallback func(upstreamID string, target *types.Target, healthy
 
 // NewActiveHealthChecker 创建新的主动健康检查器
 func NewActiveHealthChecker(cfg *config.Config) *ActiveHealthChecker {
-	return &ActiveHealthChecker{
+	hc := &ActiveHealthChecker{
 		upstreams: make(map[string]*upstreamHealthState),
 		config:    cfg,
 		stopCh:    make(chan struct{}),
@@ -65,7 +86,21 @@ func NewActiveHealthChecker(cfg *config.Config) *ActiveHealthChecker {
 			Timeout: 10 * time.Second, // 默认超时10秒
 		},
 		callbacks: make([]HealthChangeCallback, 0),
+		probes:    make(map[string]*sharedProbe),
+	}
+	if cfg != nil {
+		hc.gracePeriod = cfg.LoadBalancer.HealthCheck.StartupGracePeriod
+		hc.waitForFirstCheck = cfg.LoadBalancer.HealthCheck.WaitForFirstCheck
 	}
+	return hc
+}
+
+// newHealthWebhook 创建健康状态 webhook 发送器，未启用时返回 nil
+func newHealthWebhook(cfg *config.Config) *webhook.Sender {
+	if cfg == nil || !cfg.Webhooks.HealthStatus.Enabled {
+		return nil
+	}
+	return webhook.NewSender(&cfg.Webhooks.HealthStatus)
 }
 
 // AddHealthChangeCallback 添加健康状态变化回调
@@ -86,6 +121,8 @@ func (hc *ActiveHealthChecker) Start() error {
 
 	hc.running = true
 	hc.stopCh = make(chan struct{})
+	hc.startedAt = time.Now()
+	hc.webhook = newHealthWebhook(hc.config)
 
 	// 启动所有已注册的上游服务检查
 	for upstreamID, state := range hc.upstreams {
@@ -105,6 +142,8 @@ func (hc *ActiveHealthChecker) Stop() error {
 
 	hc.running = false
 	close(hc.stopCh)
+	sender := hc.webhook
+	hc.webhook = nil
 
 	// 停止所有上游服务检查
 	for _, state := range hc.upstreams {
@@ -117,6 +156,11 @@ func (hc *ActiveHealthChecker) Stop() error {
 	// 等待所有goroutine结束（在锁外等待）
 	hc.wg.Wait()
 
+	// 停止 webhook 发送
+	if sender != nil {
+		sender.Close()
+	}
+
 	return nil
 }
 
@@ -176,6 +220,16 @@ func (hc *ActiveHealthChecker) RemoveUpstream(upstreamID string) error {
 	}
 
 	delete(hc.upstreams, upstreamID)
+
+	// 丢弃已完成的探测结果，共享这些端点的其他目标下次重新探测
+	hc.probeMu.Lock()
+	for _, targetState := range state.targets {
+		url := probeURL(targetState.target, state.config)
+		if probe, ok := hc.probes[url]; ok && probe.result != nil {
+			delete(hc.probes, url)
+		}
+	}
+	hc.probeMu.Unlock()
 	return nil
 }
 
@@ -234,7 +288,7 @@ func (hc *ActiveHealthChecker) checkAllTargets(upstreamID string, state *upstrea
 		wg.Add(1)
 		go func(ts *targetHealthState) {
 			defer wg.Done()
-			result := hc.checkTarget(ts.target, state.config, upstreamID)
+			result := hc.probeTarget(ts.target, state.config, upstreamID)
 			hc.updateTargetHealth(upstreamID, ts, result)
 		}(targetState)
 	}
@@ -242,12 +296,49 @@ func (hc *ActiveHealthChecker) checkAllTargets(upstreamID string, state *upstrea
 	wg.Wait()
 }
 
+// probeURL 返回目标的健康检查URL，也是合并探测的键
+func probeURL(target *types.Target, config *types.HealthCheck) string {
+	return fmt.Sprintf("http://%s:%d%s", target.Host, target.Port, config.Path)
+}
+
+// probeTarget 检查目标实例，与共享同一探测URL的目标合并探测：
+// 已有探测进行中时等待其结果，半个检查间隔内完成的探测直接复用，
+// 因此同一端点每个间隔只探测一次。路径不同的目标各自探测
+func (hc *ActiveHealthChecker) probeTarget(target *types.Target, config *types.HealthCheck, upstreamID string) *HealthCheckResult {
+	url := probeURL(target, config)
+	reuseFor := time.Duration(config.Interval) * time.Second / 2
+
+	hc.probeMu.Lock()
+	probe, ok := hc.probes[url]
+	if ok && (probe.result == nil || time.Since(probe.result.CheckTime) < reuseFor) {
+		hc.probeMu.Unlock()
+		<-probe.done
+
+		// 复制结果，归属到当前目标
+		shared := *probe.result
+		shared.Target = target
+		shared.UpstreamID = upstreamID
+		return &shared
+	}
+	probe = &sharedProbe{done: make(chan struct{})}
+	hc.probes[url] = probe
+	hc.probeMu.Unlock()
+
+	result := hc.checkTarget(target, config, upstreamID)
+
+	hc.probeMu.Lock()
+	probe.result = result
+	hc.probeMu.Unlock()
+	close(probe.done)
+	return result
+}
+
 // checkTarget 检查单个目标实例
 func (hc *ActiveHealthChecker) checkTarget(target *types.Target, config *types.HealthCheck, upstreamID string) *HealthCheckResult {
 	startTime := time.Now()
 	
 	// 构建健康检查URL
-	url := fmt.Sprintf("http://%s:%d%s", target.Host, target.Port, config.Path)
+	url := probeURL(target, config)
 	
 	// 创建带超时的上下文
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(config.Timeout)*time.Second)
@@ -312,9 +403,19 @@ func (hc *ActiveHealthChecker) updateTargetHealth(upstreamID string, targetState
 	}
 
 	oldHealthy := targetState.healthy
+	firstCheck := !targetState.checked
+	targetState.checked = true
 
 	// 更新连续成功/失败计数
-	if result.Healthy {
+	if firstCheck && hc.waitForFirstCheck {
+		// 首次检查结果直接生效，不受阈值限制
+		targetState.healthy = result.Healthy
+		if result.Healthy {
+			targetState.consecutiveSuccess = 1
+		} else {
+			targetState.consecutiveFailures = 1
+		}
+	} else if result.Healthy {
 		targetState.consecutiveSuccess++
 		targetState.consecutiveFailures = 0
 
@@ -326,8 +427,8 @@ func (hc *ActiveHealthChecker) updateTargetHealth(upstreamID string, targetState
 		targetState.consecutiveFailures++
 		targetState.consecutiveSuccess = 0
 
-		// 检查是否达到不健康阈值
-		if targetState.healthy && targetState.consecutiveFailures >= state.config.UnhealthyThreshold {
+		// 检查是否达到不健康阈值，启动宽限期内仅计数
+		if targetState.healthy && targetState.consecutiveFailures >= state.config.UnhealthyThreshold && !hc.inStartupGrace(result.CheckTime) {
 			targetState.healthy = false
 		}
 	}
@@ -341,7 +442,42 @@ func (hc *ActiveHealthChecker) updateTargetHealth(upstreamID string, targetState
 		for _, callback := range hc.callbacks {
 			go callback(upstreamID, targetState.target, targetState.healthy)
 		}
+
+		// 发送健康状态 webhook（异步投递，不阻塞健康检查）
+		if hc.webhook != nil {
+			target := fmt.Sprintf("%s:%d", targetState.target.Host, targetState.target.Port)
+			event := NewHealthStatusEvent(upstreamID, target, targetState.healthy, "active")
+			if err := hc.webhook.Send(HealthStatusEventType, event); err != nil {
+				log.Printf("Failed to queue health status webhook for %s: %v", target, err)
+			}
+		}
+	}
+}
+
+// inStartupGrace 判断检查时间是否处于启动宽限期内
+func (hc *ActiveHealthChecker) inStartupGrace(checkTime time.Time) bool {
+	return hc.gracePeriod > 0 && checkTime.Before(hc.startedAt.Add(hc.gracePeriod))
+}
+
+// WarmedUp 报告启动预热是否完成。未启用 wait_for_first_check 时始终为 true，
+// 否则在所有目标完成首次检查前为 false；完成后不再变化，
+// 之后新增的上游服务不会影响就绪状态
+func (hc *ActiveHealthChecker) WarmedUp() bool {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+
+	if !hc.waitForFirstCheck || hc.warmedUp {
+		return true
+	}
+	for _, state := range hc.upstreams {
+		for _, targetState := range state.targets {
+			if !targetState.checked {
+				return false
+			}
+		}
 	}
+	hc.warmedUp = true
+	return true
 }
 
 // GetUpstreamHealth 获取上游服务的健康状态
@@ -433,6 +433,143 @@ func testHealthCheckConfiguration(t *testing.T) {
 	t.Log(" 健康检查配置管理测试通过")
 }
 
+// TestActiveHealthChecker_StartupGracePeriod 验证启动宽限期内检查失败不会隔离目标
+func TestActiveHealthChecker_StartupGracePeriod(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.LoadBalancer.HealthCheck.StartupGracePeriod = time.Minute
+	checker := NewActiveHealthChecker(cfg)
+	checker.startedAt = time.Now()
+
+	target := &types.Target{Host: "127.0.0.1", Port: 9001, Healthy: true}
+	checker.AddUpstream(&types.Upstream{
+		ID:          "warmup",
+		Targets:     []*types.Target{target},
+		HealthCheck: &types.HealthCheck{Interval: 1, Timeout: 1, HealthyThreshold: 1, UnhealthyThreshold: 2},
+	})
+	state := checker.upstreams["warmup"].targets["127.0.0.1:9001"]
+
+	// 宽限期内连续失败仅计数
+	for i := 0; i < 3; i++ {
+		checker.updateTargetHealth("warmup", state, &HealthCheckResult{Target: target, CheckTime: time.Now()})
+	}
+	if !state.healthy {
+		t.Fatal("Target should stay healthy during the startup grace period")
+	}
+
+	// 宽限期结束后的首次失败即生效
+	checker.updateTargetHealth("warmup", state, &HealthCheckResult{Target: target, CheckTime: time.Now().Add(2 * time.Minute)})
+	if state.healthy {
+		t.Error("Target should be unhealthy after the startup grace period")
+	}
+	if !checker.WarmedUp() {
+		t.Error("WarmedUp should be true without wait_for_first_check")
+	}
+}
+
+// TestActiveHealthChecker_WaitForFirstCheck 验证首次检查完成前未就绪，且首次结果不受阈值限制
+func TestActiveHealthChecker_WaitForFirstCheck(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.LoadBalancer.HealthCheck.WaitForFirstCheck = true
+	checker := NewActiveHealthChecker(cfg)
+
+	up := &types.Target{Host: "127.0.0.1", Port: 9001, Healthy: true}
+	down := &types.Target{Host: "127.0.0.1", Port: 9002, Healthy: true}
+	checker.AddUpstream(&types.Upstream{
+		ID:          "warmup",
+		Targets:     []*types.Target{up, down},
+		HealthCheck: &types.HealthCheck{Interval: 1, Timeout: 1, HealthyThreshold: 2, UnhealthyThreshold: 3},
+	})
+	targets := checker.upstreams["warmup"].targets
+
+	if checker.WarmedUp() {
+		t.Fatal("WarmedUp should be false before the first check")
+	}
+
+	checker.updateTargetHealth("warmup", targets["127.0.0.1:9001"], &HealthCheckResult{Target: up, Healthy: true, CheckTime: time.Now()})
+	if checker.WarmedUp() {
+		t.Fatal("WarmedUp should be false until every target is checked")
+	}
+
+	checker.updateTargetHealth("warmup", targets["127.0.0.1:9002"], &HealthCheckResult{Target: down, CheckTime: time.Now()})
+	if !checker.WarmedUp() {
+		t.Fatal("WarmedUp should be true once every target is checked")
+	}
+	if targets["127.0.0.1:9002"].healthy {
+		t.Error("The first failed check should mark the target unhealthy regardless of the threshold")
+	}
+
+	// 预热完成后新增的上游服务不影响就绪状态
+	checker.AddUpstream(&types.Upstream{
+		ID:          "later",
+		Targets:     []*types.Target{{Host: "127.0.0.1", Port: 9003, Healthy: true}},
+		HealthCheck: &types.HealthCheck{Interval: 1, Timeout: 1, HealthyThreshold: 1, UnhealthyThreshold: 1},
+	})
+	if !checker.WarmedUp() {
+		t.Error("WarmedUp should stay true for upstreams added after warm-up")
+	}
+}
+
+// TestActiveHealthChecker_CoalescesSharedProbes 验证指向同一端点的目标每个间隔只探测一次，路径不同的目标各自探测
+func TestActiveHealthChecker_CoalescesSharedProbes(t *testing.T) {
+	var mu sync.Mutex
+	hits := make(map[string]int)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		hits[r.URL.Path]++
+		mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+	host, port := parseServerAddress(server.URL)
+
+	checker := NewActiveHealthChecker(&config.Config{})
+	for _, up := range []struct{ id, path string }{
+		{"orders", "/health"},
+		{"payments", "/health"},
+		{"reports", "/ready"},
+	} {
+		checker.AddUpstream(&types.Upstream{
+			ID:          up.id,
+			Targets:     []*types.Target{{Host: host, Port: port}},
+			HealthCheck: &types.HealthCheck{Path: up.path, Interval: 60, Timeout: 1, HealthyThreshold: 1, UnhealthyThreshold: 1},
+		})
+	}
+	if err := checker.Start(); err != nil {
+		t.Fatalf("Failed to start health checker: %v", err)
+	}
+	defer checker.Stop()
+
+	// 等待所有目标完成首次检查
+	key := fmt.Sprintf("%s:%d", host, port)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		checker.mu.RLock()
+		checked := 0
+		for _, state := range checker.upstreams {
+			if ts := state.targets[key]; ts.checked && ts.healthy {
+				checked++
+			}
+		}
+		checker.mu.RUnlock()
+		if checked == 3 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Only %d of 3 targets were checked healthy", checked)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if hits["/health"] != 1 {
+		t.Errorf("Expected the shared endpoint to be probed once, got %d probes", hits["/health"])
+	}
+	if hits["/ready"] != 1 {
+		t.Errorf("Expected the target with a different path to be probed independently once, got %d probes", hits["/ready"])
+	}
+}
+
 // HealthChangeEvent 健康状态变化事件
 type HealthChangeEvent struct {
 	UpstreamID string
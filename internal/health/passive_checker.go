@@ -1,9 +1,15 @@
 package health
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"fmt"
 	"log"
+	"net"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/songzhibin97/stargate/internal/types"
@@ -44,6 +50,10 @@ type PassiveHealthConfig struct {
 	
 	// 是否将超时也视为失败
 	TimeoutAsFailure bool `yaml:"timeout_as_failure" json:"timeout_as_failure"`
+
+	// 按上游ID覆盖的阈值，未配置的上游使用以上默认值
+	// 覆盖配置中的 Enabled、RecoveryInterval 和 Upstreams 不生效
+	Upstreams map[string]*PassiveHealthConfig `yaml:"upstreams" json:"upstreams,omitempty"`
 }
 
 // passiveTargetState 被动健康检查的目标状态
@@ -57,13 +67,36 @@ type passiveTargetState struct {
 	lastFailureTime      time.Time
 	lastSuccessTime      time.Time
 	isolationStartTime   time.Time
+	lastFailureReason    string
 	totalRequests        int64
 	totalFailures        int64
 	totalSuccesses       int64
 }
 
+// 被动健康检查状态变化的原因
+const (
+	ReasonConsecutive5xx       = "consecutive_5xx"       // 连续返回5xx状态码
+	ReasonFailureStatus        = "failure_status"        // 连续返回配置为失败的非5xx状态码
+	ReasonTimeout              = "timeout"               // 请求超时
+	ReasonConnectionRefused    = "connection_refused"    // 连接被拒绝
+	ReasonTLSHandshake         = "tls_handshake"         // TLS握手失败，如证书过期或不受信任
+	ReasonConnectionError      = "connection_error"      // 其他连接或传输错误
+	ReasonConsecutiveSuccesses = "consecutive_successes" // 隔离后连续成功而恢复
+)
+
+// HealthChange 被动健康检查的状态变化详情
+type HealthChange struct {
+	Healthy bool
+	// 隔离时为触发隔离的最后一次失败的原因，恢复时为 ReasonConsecutiveSuccesses
+	Reason string
+	// 触发变化的连续失败或连续成功次数
+	Count int
+	// 隔离时为配置的隔离时长，恢复时为实际被隔离的时长
+	IsolationDuration time.Duration
+}
+
 // HealthStatusCallback 健康状态变化回调函数
-type HealthStatusCallback func(upstreamID, targetKey string, healthy bool)
+type HealthStatusCallback func(upstreamID, targetKey string, change HealthChange)
 
 // RequestResult 请求结果
 type RequestResult struct {
@@ -212,6 +245,14 @@ func (phc *PassiveHealthChecker) RecordRequest(result *RequestResult) {
 	}
 }
 
+// configFor 返回上游适用的配置，没有覆盖配置时返回默认配置
+func (phc *PassiveHealthChecker) configFor(upstreamID string) *PassiveHealthConfig {
+	if override, ok := phc.config.Upstreams[upstreamID]; ok && override != nil {
+		return override
+	}
+	return phc.config
+}
+
 // isRequestFailure 判断请求是否为失败
 func (phc *PassiveHealthChecker) isRequestFailure(result *RequestResult) bool {
 	// 检查是否有错误
@@ -219,13 +260,15 @@ func (phc *PassiveHealthChecker) isRequestFailure(result *RequestResult) bool {
 		return true
 	}
 
+	config := phc.configFor(result.UpstreamID)
+
 	// 检查是否超时
-	if result.IsTimeout && phc.config.TimeoutAsFailure {
+	if result.IsTimeout && config.TimeoutAsFailure {
 		return true
 	}
 
 	// 检查状态码是否在失败范围内
-	for _, code := range phc.config.FailureStatusCodes {
+	for _, code := range config.FailureStatusCodes {
 		if result.StatusCode == code {
 			return true
 		}
@@ -234,18 +277,57 @@ func (phc *PassiveHealthChecker) isRequestFailure(result *RequestResult) bool {
 	return false
 }
 
+// failureReason 返回失败请求的原因
+func failureReason(result *RequestResult) string {
+	var netErr net.Error
+	switch {
+	case result.IsTimeout, errors.Is(result.Error, context.DeadlineExceeded),
+		errors.As(result.Error, &netErr) && netErr.Timeout():
+		return ReasonTimeout
+	case IsTLSHandshakeError(result.Error):
+		return ReasonTLSHandshake
+	case errors.Is(result.Error, syscall.ECONNREFUSED):
+		return ReasonConnectionRefused
+	case result.Error != nil:
+		return ReasonConnectionError
+	case result.StatusCode >= 500:
+		return ReasonConsecutive5xx
+	default:
+		return ReasonFailureStatus
+	}
+}
+
+// IsTLSHandshakeError 判断错误是否为与后端的TLS握手失败：证书过期、不受信任或
+// 主机名不匹配，后端未使用TLS，或后端以TLS告警拒绝了握手
+func IsTLSHandshakeError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var verifyErr *tls.CertificateVerificationError
+	var recordErr tls.RecordHeaderError
+	var invalidErr x509.CertificateInvalidError
+	var authorityErr x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var opErr *net.OpError
+	return errors.As(err, &verifyErr) || errors.As(err, &recordErr) ||
+		errors.As(err, &invalidErr) || errors.As(err, &authorityErr) || errors.As(err, &hostnameErr) ||
+		errors.As(err, &opErr) && opErr.Op == "remote error"
+}
+
 // handleFailure 处理失败请求
 func (phc *PassiveHealthChecker) handleFailure(state *passiveTargetState, result *RequestResult) {
 	state.totalFailures++
 	state.consecutiveFailures++
 	state.consecutiveSuccesses = 0
 	state.lastFailureTime = result.Timestamp
+	state.lastFailureReason = failureReason(result)
 
-	log.Printf("Target %s:%d failure recorded, consecutive failures: %d", 
-		state.target.Host, state.target.Port, state.consecutiveFailures)
+	log.Printf("Target %s:%d failure recorded (%s), consecutive failures: %d",
+		state.target.Host, state.target.Port, state.lastFailureReason, state.consecutiveFailures)
 
 	// 检查是否需要隔离
-	if !state.isolated && state.consecutiveFailures >= phc.config.ConsecutiveFailures {
+	if !state.isolated && state.consecutiveFailures >= phc.configFor(state.upstreamID).ConsecutiveFailures {
 		phc.isolateTarget(state)
 	}
 }
@@ -258,7 +340,7 @@ func (phc *PassiveHealthChecker) handleSuccess(state *passiveTargetState, result
 	state.lastSuccessTime = result.Timestamp
 
 	// 如果目标被隔离，检查是否可以恢复
-	if state.isolated && state.consecutiveSuccesses >= phc.config.ConsecutiveSuccesses {
+	if state.isolated && state.consecutiveSuccesses >= phc.configFor(state.upstreamID).ConsecutiveSuccesses {
 		phc.recoverTarget(state)
 	}
 }
@@ -270,13 +352,19 @@ func (phc *PassiveHealthChecker) isolateTarget(state *passiveTargetState) {
 	state.isolationStartTime = time.Now()
 
 	targetKey := fmt.Sprintf("%s:%s:%d", state.upstreamID, state.target.Host, state.target.Port)
-	
-	log.Printf("Target %s isolated due to %d consecutive failures", 
-		targetKey, state.consecutiveFailures)
+	change := HealthChange{
+		Healthy:           false,
+		Reason:            state.lastFailureReason,
+		Count:             state.consecutiveFailures,
+		IsolationDuration: phc.configFor(state.upstreamID).IsolationDuration,
+	}
+
+	log.Printf("Target %s isolated for %v due to %d consecutive failures, last: %s",
+		targetKey, change.IsolationDuration, change.Count, change.Reason)
 
 	// 通知负载均衡器
 	if phc.callback != nil {
-		phc.callback(state.upstreamID, targetKey, false)
+		phc.callback(state.upstreamID, targetKey, change)
 	}
 }
 
@@ -287,13 +375,19 @@ func (phc *PassiveHealthChecker) recoverTarget(state *passiveTargetState) {
 	state.consecutiveFailures = 0
 
 	targetKey := fmt.Sprintf("%s:%s:%d", state.upstreamID, state.target.Host, state.target.Port)
-	
-	log.Printf("Target %s recovered after %d consecutive successes", 
-		targetKey, state.consecutiveSuccesses)
+	change := HealthChange{
+		Healthy:           true,
+		Reason:            ReasonConsecutiveSuccesses,
+		Count:             state.consecutiveSuccesses,
+		IsolationDuration: time.Since(state.isolationStartTime),
+	}
+
+	log.Printf("Target %s recovered after %d consecutive successes, isolated for %v",
+		targetKey, change.Count, change.IsolationDuration)
 
 	// 通知负载均衡器
 	if phc.callback != nil {
-		phc.callback(state.upstreamID, targetKey, true)
+		phc.callback(state.upstreamID, targetKey, change)
 	}
 }
 
@@ -321,7 +415,7 @@ func (phc *PassiveHealthChecker) checkIsolatedTargets() {
 
 	now := time.Now()
 	for targetKey, state := range phc.targets {
-		if state.isolated && now.Sub(state.isolationStartTime) >= phc.config.IsolationDuration {
+		if state.isolated && now.Sub(state.isolationStartTime) >= phc.configFor(state.upstreamID).IsolationDuration {
 			// 隔离时间已到，重置连续成功计数器，等待新的请求来验证
 			state.consecutiveSuccesses = 0
 			log.Printf("Target %s isolation period expired, ready for recovery attempts", targetKey)
@@ -363,6 +457,7 @@ func (phc *PassiveHealthChecker) GetTargetStats(upstreamID string, target *types
 		"total_failures":         state.totalFailures,
 		"total_successes":        state.totalSuccesses,
 		"last_failure_time":      state.lastFailureTime,
+		"last_failure_reason":    state.lastFailureReason,
 		"last_success_time":      state.lastSuccessTime,
 		"isolation_start_time":   state.isolationStartTime,
 	}
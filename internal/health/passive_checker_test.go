@@ -1,6 +1,12 @@
 package health
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"os"
+	"syscall"
 	"testing"
 	"time"
 
@@ -18,7 +24,7 @@ func TestPassiveHealthChecker_NewPassiveHealthChecker(t *testing.T) {
 		TimeoutAsFailure:     true,
 	}
 
-	callback := func(upstreamID, targetKey string, healthy bool) {
+	callback := func(upstreamID, targetKey string, change HealthChange) {
 		// Callback for testing
 	}
 
@@ -171,9 +177,9 @@ func TestPassiveHealthChecker_RecordRequest_Failure(t *testing.T) {
 	var callbackCalled bool
 	var callbackHealthy bool
 
-	callback := func(upstreamID, targetKey string, healthy bool) {
+	callback := func(upstreamID, targetKey string, change HealthChange) {
 		callbackCalled = true
-		callbackHealthy = healthy
+		callbackHealthy = change.Healthy
 	}
 
 	checker := NewPassiveHealthChecker(config, callback)
@@ -231,6 +237,80 @@ func TestPassiveHealthChecker_RecordRequest_Failure(t *testing.T) {
 	}
 }
 
+func TestPassiveHealthChecker_PerUpstreamThresholds(t *testing.T) {
+	config := &PassiveHealthConfig{
+		Enabled:              true,
+		ConsecutiveFailures:  3,
+		IsolationDuration:    30 * time.Second,
+		RecoveryInterval:     10 * time.Second,
+		ConsecutiveSuccesses: 2,
+		FailureStatusCodes:   []int{500, 502, 503},
+		TimeoutAsFailure:     true,
+		Upstreams: map[string]*PassiveHealthConfig{
+			// The flaky upstream tolerates more failures and its 503s
+			"flaky": {
+				ConsecutiveFailures:  5,
+				IsolationDuration:    10 * time.Second,
+				ConsecutiveSuccesses: 2,
+				FailureStatusCodes:   []int{500, 502},
+			},
+		},
+	}
+
+	isolated := make(map[string]bool)
+	callback := func(upstreamID, targetKey string, change HealthChange) {
+		isolated[upstreamID] = !change.Healthy
+	}
+
+	checker := NewPassiveHealthChecker(config, callback)
+
+	strict := &types.Target{Host: "strict.example.com", Port: 80, Healthy: true}
+	flaky := &types.Target{Host: "flaky.example.com", Port: 80, Healthy: true}
+
+	for i := 0; i < 4; i++ {
+		for upstreamID, target := range map[string]*types.Target{"strict": strict, "flaky": flaky} {
+			checker.RecordRequest(&RequestResult{
+				UpstreamID: upstreamID,
+				Target:     target,
+				StatusCode: 500,
+				Timestamp:  time.Now(),
+			})
+		}
+	}
+
+	if !isolated["strict"] || checker.IsTargetHealthy("strict", strict) {
+		t.Error("Strict upstream should be isolated after 3 failures")
+	}
+	if isolated["flaky"] || !checker.IsTargetHealthy("flaky", flaky) {
+		t.Error("Flaky upstream should not be isolated before 5 failures")
+	}
+
+	// 503 is not a failure of the flaky upstream
+	for i := 0; i < 5; i++ {
+		checker.RecordRequest(&RequestResult{
+			UpstreamID: "flaky",
+			Target:     flaky,
+			StatusCode: 503,
+			Timestamp:  time.Now(),
+		})
+	}
+	if !checker.IsTargetHealthy("flaky", flaky) {
+		t.Error("Flaky upstream should not count 503 as a failure")
+	}
+
+	for i := 0; i < 5; i++ {
+		checker.RecordRequest(&RequestResult{
+			UpstreamID: "flaky",
+			Target:     flaky,
+			StatusCode: 502,
+			Timestamp:  time.Now(),
+		})
+	}
+	if !isolated["flaky"] || checker.IsTargetHealthy("flaky", flaky) {
+		t.Error("Flaky upstream should be isolated after 5 failures")
+	}
+}
+
 func TestPassiveHealthChecker_IsRequestFailure(t *testing.T) {
 	config := &PassiveHealthConfig{
 		Enabled:              true,
@@ -352,6 +432,70 @@ func TestPassiveHealthChecker_IsTargetHealthy(t *testing.T) {
 	}
 }
 
+func TestPassiveHealthChecker_HealthChangeReasons(t *testing.T) {
+	config := &PassiveHealthConfig{
+		Enabled:              true,
+		ConsecutiveFailures:  3,
+		IsolationDuration:    30 * time.Second,
+		RecoveryInterval:     10 * time.Second,
+		ConsecutiveSuccesses: 2,
+		FailureStatusCodes:   []int{429, 500, 502, 503},
+		TimeoutAsFailure:     true,
+	}
+
+	refused := &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}
+	tests := []struct {
+		name   string
+		result RequestResult
+		reason string
+	}{
+		{"5xx status", RequestResult{StatusCode: 503}, ReasonConsecutive5xx},
+		{"configured 4xx status", RequestResult{StatusCode: 429}, ReasonFailureStatus},
+		{"timeout flag", RequestResult{StatusCode: 504, IsTimeout: true}, ReasonTimeout},
+		{"deadline exceeded", RequestResult{Error: context.DeadlineExceeded}, ReasonTimeout},
+		{"connection refused", RequestResult{Error: refused}, ReasonConnectionRefused},
+		{"expired certificate", RequestResult{Error: x509.CertificateInvalidError{Reason: x509.Expired}}, ReasonTLSHandshake},
+		{"plain HTTP upstream", RequestResult{Error: tls.RecordHeaderError{Msg: "first record does not look like a TLS handshake"}}, ReasonTLSHandshake},
+		{"other error", RequestResult{Error: &testError{}}, ReasonConnectionError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var changes []HealthChange
+			checker := NewPassiveHealthChecker(config, func(upstreamID, targetKey string, change HealthChange) {
+				changes = append(changes, change)
+			})
+			target := &types.Target{Host: "example.com", Port: 80, Healthy: true}
+
+			for i := 0; i < 3; i++ {
+				result := tt.result
+				result.UpstreamID = "upstream1"
+				result.Target = target
+				result.Timestamp = time.Now()
+				checker.RecordRequest(&result)
+			}
+			if len(changes) != 1 {
+				t.Fatalf("Expected one ejection, got %+v", changes)
+			}
+			want := HealthChange{Healthy: false, Reason: tt.reason, Count: 3, IsolationDuration: 30 * time.Second}
+			if changes[0] != want {
+				t.Errorf("Expected ejection %+v, got %+v", want, changes[0])
+			}
+
+			// Recoveries report the successes and how long the target was isolated
+			for i := 0; i < 2; i++ {
+				checker.RecordRequest(&RequestResult{UpstreamID: "upstream1", Target: target, StatusCode: 200, Timestamp: time.Now()})
+			}
+			if len(changes) != 2 {
+				t.Fatalf("Expected a recovery, got %+v", changes)
+			}
+			recovery := changes[1]
+			if !recovery.Healthy || recovery.Reason != ReasonConsecutiveSuccesses || recovery.Count != 2 || recovery.IsolationDuration <= 0 {
+				t.Errorf("Unexpected recovery %+v", recovery)
+			}
+		})
+	}
+}
+
 // testError is a simple error implementation for testing
 type testError struct{}
 
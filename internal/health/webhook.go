@@ -0,0 +1,59 @@
+package health
+
+import (
+	"time"
+)
+
+const (
+	// HealthStatusEventType is the webhook event type of target health transitions
+	HealthStatusEventType = "health_status"
+
+	// StatusHealthy 健康状态
+	StatusHealthy = "healthy"
+	// StatusUnhealthy 不健康状态
+	StatusUnhealthy = "unhealthy"
+)
+
+// HealthStatusEvent 目标实例健康状态变化的 webhook 负载
+type HealthStatusEvent struct {
+	UpstreamID string    `json:"upstream_id"`
+	Target     string    `json:"target"`
+	OldStatus  string    `json:"old_status"`
+	NewStatus  string    `json:"new_status"`
+	Source     string    `json:"source"` // "active" 或 "passive"
+	Timestamp  time.Time `json:"timestamp"`
+
+	// 被动健康检查的变化详情，见 HealthChange
+	Reason      string `json:"reason,omitempty"`
+	Count       int    `json:"count,omitempty"`
+	IsolationMs int64  `json:"isolation_ms,omitempty"`
+}
+
+// NewHealthStatusEvent 创建健康状态变化事件，旧状态为新状态的相反值
+func NewHealthStatusEvent(upstreamID, target string, healthy bool, source string) *HealthStatusEvent {
+	return &HealthStatusEvent{
+		UpstreamID: upstreamID,
+		Target:     target,
+		OldStatus:  statusString(!healthy),
+		NewStatus:  statusString(healthy),
+		Source:     source,
+		Timestamp:  time.Now().UTC(),
+	}
+}
+
+// NewPassiveHealthStatusEvent 创建被动健康检查的状态变化事件，携带变化的原因
+func NewPassiveHealthStatusEvent(upstreamID, target string, change HealthChange) *HealthStatusEvent {
+	event := NewHealthStatusEvent(upstreamID, target, change.Healthy, "passive")
+	event.Reason = change.Reason
+	event.Count = change.Count
+	event.IsolationMs = change.IsolationDuration.Milliseconds()
+	return event
+}
+
+// statusString 将健康标志转换为状态字符串
+func statusString(healthy bool) string {
+	if healthy {
+		return StatusHealthy
+	}
+	return StatusUnhealthy
+}
@@ -0,0 +1,86 @@
+package health
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/songzhibin97/stargate/internal/config"
+	"github.com/songzhibin97/stargate/internal/types"
+	"github.com/songzhibin97/stargate/internal/webhook"
+)
+
+// TestActiveHealthChecker_HealthStatusWebhook 测试健康状态变化时发送 webhook
+func TestActiveHealthChecker_HealthStatusWebhook(t *testing.T) {
+	deliveries := make(chan HealthStatusEvent, 10)
+
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var event HealthStatusEvent
+		if err := json.Unmarshal(body, &event); err != nil {
+			t.Errorf("Failed to decode webhook payload: %v", err)
+		}
+		if !webhook.Verify("secret", body, r.Header.Get(webhook.HeaderSignature)) {
+			t.Errorf("Invalid webhook signature %q", r.Header.Get(webhook.HeaderSignature))
+		}
+		deliveries <- event
+	}))
+	defer receiver.Close()
+
+	faultyServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer faultyServer.Close()
+
+	cfg := &config.Config{}
+	cfg.Webhooks.HealthStatus = config.WebhookConfig{
+		Enabled:    true,
+		URL:        receiver.URL,
+		Timeout:    time.Second,
+		RetryCount: 1,
+		Secret:     "secret",
+	}
+	checker := NewActiveHealthChecker(cfg)
+
+	host, port := parseServerAddress(faultyServer.URL)
+	upstream := &types.Upstream{
+		ID:      "webhook-upstream",
+		Targets: []*types.Target{{Host: host, Port: port, Healthy: true}},
+		HealthCheck: &types.HealthCheck{
+			Type:               "http",
+			Path:               "/health",
+			Interval:           1,
+			Timeout:            1,
+			HealthyThreshold:   1,
+			UnhealthyThreshold: 1,
+		},
+	}
+	if err := checker.AddUpstream(upstream); err != nil {
+		t.Fatalf("Failed to add upstream: %v", err)
+	}
+	if err := checker.Start(); err != nil {
+		t.Fatalf("Failed to start health checker: %v", err)
+	}
+	defer checker.Stop()
+
+	select {
+	case event := <-deliveries:
+		if event.UpstreamID != "webhook-upstream" {
+			t.Errorf("Expected upstream ID webhook-upstream, got %s", event.UpstreamID)
+		}
+		if event.OldStatus != StatusHealthy || event.NewStatus != StatusUnhealthy {
+			t.Errorf("Expected transition healthy -> unhealthy, got %s -> %s", event.OldStatus, event.NewStatus)
+		}
+		if event.Source != "active" {
+			t.Errorf("Expected source active, got %s", event.Source)
+		}
+		if event.Timestamp.IsZero() {
+			t.Error("Expected event timestamp")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for health status webhook")
+	}
+}
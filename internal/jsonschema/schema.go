@@ -0,0 +1,795 @@
+// Package jsonschema validates JSON values against JSON Schemas.
+//
+// It implements the validation vocabulary of JSON Schema draft 2020-12
+// most APIs use: type, enum, const, the numeric, string, array and object
+// constraints, allOf, anyOf, oneOf, not, if/then/else, and $ref to
+// definitions within the same schema ("#/$defs/name" or
+// "#/definitions/name"). References that come back to a schema without
+// descending into the value, such as {"$ref": "#"}, are rejected at compile
+// time. Annotations such as title, description, default and format are
+// accepted and ignored. Patterns use Go's RE2 syntax, which lacks
+// backreferences and lookaround.
+package jsonschema
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Schema is a compiled JSON Schema
+type Schema struct {
+	always *bool // Boolean schema: true accepts and false rejects everything
+
+	ref   string // $ref, resolved to refSchema once the schema is compiled
+	refTo *Schema
+
+	types  []string
+	enum   []interface{}
+	const_ *interface{}
+
+	multipleOf       *float64
+	minimum          *float64
+	maximum          *float64
+	exclusiveMinimum *float64
+	exclusiveMaximum *float64
+
+	minLength *int
+	maxLength *int
+	pattern   *regexp.Regexp
+
+	items       *Schema
+	prefixItems []*Schema
+	minItems    *int
+	maxItems    *int
+	uniqueItems bool
+	contains    *Schema
+
+	properties           map[string]*Schema
+	patternProperties    map[*regexp.Regexp]*Schema
+	additionalProperties *Schema
+	required             []string
+	minProperties        *int
+	maxProperties        *int
+	propertyNames        *Schema
+
+	allOf []*Schema
+	anyOf []*Schema
+	oneOf []*Schema
+	not   *Schema
+
+	if_   *Schema
+	then_ *Schema
+	else_ *Schema
+}
+
+// ValidationError describes a value not matching its schema
+type ValidationError struct {
+	Path    string `json:"path"` // JSON Pointer to the value, "" for the document
+	Message string `json:"message"`
+}
+
+// Error implements the error interface
+func (e ValidationError) Error() string {
+	if e.Path == "" {
+		return e.Message
+	}
+	return e.Path + ": " + e.Message
+}
+
+// validTypes are the type names of JSON Schema
+var validTypes = map[string]bool{
+	"null": true, "boolean": true, "object": true, "array": true,
+	"number": true, "integer": true, "string": true,
+}
+
+// Parse compiles a schema written as JSON or YAML
+func Parse(data []byte) (*Schema, error) {
+	var doc interface{}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("invalid schema document: %w", err)
+	}
+	return Compile(doc)
+}
+
+// Compile compiles a schema decoded from JSON or YAML
+func Compile(doc interface{}) (*Schema, error) {
+	doc = normalize(doc)
+	c := &compiler{root: doc, refs: make(map[string]*Schema)}
+	schema, err := c.compile(doc, "#")
+	if err != nil {
+		return nil, err
+	}
+	if err := c.resolveRefs(); err != nil {
+		return nil, err
+	}
+	if err := checkCycles(schema); err != nil {
+		return nil, err
+	}
+	return schema, nil
+}
+
+// compiler compiles a schema document, resolving references within it
+type compiler struct {
+	root    interface{}
+	refs    map[string]*Schema // Compiled targets by reference
+	pending []*Schema          // Schemas with a $ref to resolve
+}
+
+// compile compiles the schema at a location of the document
+func (c *compiler) compile(doc interface{}, location string) (*Schema, error) {
+	if b, ok := doc.(bool); ok {
+		return &Schema{always: &b}, nil
+	}
+	m, ok := doc.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s: schema must be an object or a boolean", location)
+	}
+
+	s := &Schema{}
+	var err error
+	fail := func(keyword, format string, args ...interface{}) error {
+		return fmt.Errorf("%s/%s: %s", location, keyword, fmt.Sprintf(format, args...))
+	}
+
+	if v, ok := m["$ref"]; ok {
+		ref, isString := v.(string)
+		if !isString || !strings.HasPrefix(ref, "#") {
+			return nil, fail("$ref", "only references within the schema are supported, such as #/$defs/name")
+		}
+		s.ref = ref
+		c.pending = append(c.pending, s)
+	}
+
+	if v, ok := m["type"]; ok {
+		switch t := v.(type) {
+		case string:
+			s.types = []string{t}
+		case []interface{}:
+			for _, item := range t {
+				name, isString := item.(string)
+				if !isString {
+					return nil, fail("type", "must be a string or an array of strings")
+				}
+				s.types = append(s.types, name)
+			}
+		default:
+			return nil, fail("type", "must be a string or an array of strings")
+		}
+		for _, name := range s.types {
+			if !validTypes[name] {
+				return nil, fail("type", "unknown type %q", name)
+			}
+		}
+	}
+
+	if v, ok := m["enum"]; ok {
+		values, isArray := v.([]interface{})
+		if !isArray {
+			return nil, fail("enum", "must be an array")
+		}
+		s.enum = values
+	}
+	if v, ok := m["const"]; ok {
+		s.const_ = &v
+	}
+
+	for keyword, target := range map[string]**float64{
+		"multipleOf":       &s.multipleOf,
+		"minimum":          &s.minimum,
+		"maximum":          &s.maximum,
+		"exclusiveMinimum": &s.exclusiveMinimum,
+		"exclusiveMaximum": &s.exclusiveMaximum,
+	} {
+		if v, ok := m[keyword]; ok {
+			n, isNumber := v.(float64)
+			if !isNumber {
+				return nil, fail(keyword, "must be a number")
+			}
+			*target = &n
+		}
+	}
+	if s.multipleOf != nil && *s.multipleOf <= 0 {
+		return nil, fail("multipleOf", "must be greater than 0")
+	}
+
+	for keyword, target := range map[string]**int{
+		"minLength":     &s.minLength,
+		"maxLength":     &s.maxLength,
+		"minItems":      &s.minItems,
+		"maxItems":      &s.maxItems,
+		"minProperties": &s.minProperties,
+		"maxProperties": &s.maxProperties,
+	} {
+		if v, ok := m[keyword]; ok {
+			n, isNumber := v.(float64)
+			if !isNumber || n < 0 || n != math.Trunc(n) {
+				return nil, fail(keyword, "must be a non-negative integer")
+			}
+			i := int(n)
+			*target = &i
+		}
+	}
+
+	if v, ok := m["pattern"]; ok {
+		pattern, isString := v.(string)
+		if !isString {
+			return nil, fail("pattern", "must be a string")
+		}
+		if s.pattern, err = regexp.Compile(pattern); err != nil {
+			return nil, fail("pattern", "%v", err)
+		}
+	}
+
+	if v, ok := m["uniqueItems"]; ok {
+		unique, isBool := v.(bool)
+		if !isBool {
+			return nil, fail("uniqueItems", "must be a boolean")
+		}
+		s.uniqueItems = unique
+	}
+
+	if v, ok := m["required"]; ok {
+		names, isArray := v.([]interface{})
+		if !isArray {
+			return nil, fail("required", "must be an array of strings")
+		}
+		for _, item := range names {
+			name, isString := item.(string)
+			if !isString {
+				return nil, fail("required", "must be an array of strings")
+			}
+			s.required = append(s.required, name)
+		}
+	}
+
+	// Subschemas
+	for keyword, target := range map[string]**Schema{
+		"items":                &s.items,
+		"contains":             &s.contains,
+		"additionalProperties": &s.additionalProperties,
+		"propertyNames":        &s.propertyNames,
+		"not":                  &s.not,
+		"if":                   &s.if_,
+		"then":                 &s.then_,
+		"else":                 &s.else_,
+	} {
+		if v, ok := m[keyword]; ok {
+			if *target, err = c.compile(v, location+"/"+keyword); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for keyword, target := range map[string]*[]*Schema{
+		"prefixItems": &s.prefixItems,
+		"allOf":       &s.allOf,
+		"anyOf":       &s.anyOf,
+		"oneOf":       &s.oneOf,
+	} {
+		if v, ok := m[keyword]; ok {
+			items, isArray := v.([]interface{})
+			if !isArray || len(items) == 0 {
+				return nil, fail(keyword, "must be a non-empty array of schemas")
+			}
+			for i, item := range items {
+				sub, err := c.compile(item, fmt.Sprintf("%s/%s/%d", location, keyword, i))
+				if err != nil {
+					return nil, err
+				}
+				*target = append(*target, sub)
+			}
+		}
+	}
+
+	if v, ok := m["properties"]; ok {
+		props, isObject := v.(map[string]interface{})
+		if !isObject {
+			return nil, fail("properties", "must be an object")
+		}
+		s.properties = make(map[string]*Schema, len(props))
+		for name, prop := range props {
+			if s.properties[name], err = c.compile(prop, location+"/properties/"+escapePointer(name)); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if v, ok := m["patternProperties"]; ok {
+		props, isObject := v.(map[string]interface{})
+		if !isObject {
+			return nil, fail("patternProperties", "must be an object")
+		}
+		s.patternProperties = make(map[*regexp.Regexp]*Schema, len(props))
+		for pattern, prop := range props {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return nil, fail("patternProperties", "%v", err)
+			}
+			if s.patternProperties[re], err = c.compile(prop, location+"/patternProperties/"+escapePointer(pattern)); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// Definitions are compiled when referenced
+	for _, keyword := range []string{"$defs", "definitions"} {
+		if v, ok := m[keyword]; ok {
+			if _, isObject := v.(map[string]interface{}); !isObject {
+				return nil, fail(keyword, "must be an object")
+			}
+		}
+	}
+
+	return s, nil
+}
+
+// resolveRefs resolves the references of the compiled schemas, compiling
+// their targets. Targets may reference further schemas.
+func (c *compiler) resolveRefs() error {
+	for len(c.pending) > 0 {
+		s := c.pending[0]
+		c.pending = c.pending[1:]
+
+		if target, ok := c.refs[s.ref]; ok {
+			s.refTo = target
+			continue
+		}
+
+		doc, err := resolvePointer(c.root, s.ref)
+		if err != nil {
+			return err
+		}
+		// References of the target are queued and resolved after it is
+		// registered, so recursive schemas resolve to it
+		target, err := c.compile(doc, s.ref)
+		if err != nil {
+			return err
+		}
+		c.refs[s.ref] = target
+		s.refTo = target
+	}
+	return nil
+}
+
+// checkCycles fails if a schema reaches itself through $ref and the
+// applicators validating the same value, such as {"$ref": "#"}. Validating
+// it would recurse forever without descending into the value. Cycles
+// descending into properties or items are recursive schemas and end with
+// the value.
+func checkCycles(root *Schema) error {
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	state := make(map[*Schema]int)
+	var stack []*Schema
+	// Schemas of values nested in the value, checked once the current
+	// in-place traversal is done
+	queue := []*Schema{root}
+
+	var visit func(s *Schema) error
+	visit = func(s *Schema) error {
+		switch state[s] {
+		case visiting:
+			return circularRefError(stack, s)
+		case visited:
+			return nil
+		}
+		state[s] = visiting
+		stack = append(stack, s)
+		for _, next := range s.inPlace() {
+			if err := visit(next); err != nil {
+				return err
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[s] = visited
+		queue = append(queue, s.nested()...)
+		return nil
+	}
+
+	for len(queue) > 0 {
+		s := queue[0]
+		queue = queue[1:]
+		if err := visit(s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// circularRefError returns the error of a cycle closing at s, naming the
+// references along it
+func circularRefError(stack []*Schema, s *Schema) error {
+	var refs []string
+	for i := len(stack) - 1; i >= 0; i-- {
+		if stack[i].ref != "" {
+			refs = append([]string{stack[i].ref}, refs...)
+		}
+		if stack[i] == s {
+			break
+		}
+	}
+	if len(refs) > 0 {
+		refs = append(refs, refs[0])
+	}
+	return fmt.Errorf("circular $ref %s validates the same value forever", strings.Join(refs, " -> "))
+}
+
+// inPlace returns the subschemas validating the same value as the schema
+func (s *Schema) inPlace() []*Schema {
+	var schemas []*Schema
+	for _, sub := range []*Schema{s.refTo, s.not, s.if_, s.then_, s.else_} {
+		if sub != nil {
+			schemas = append(schemas, sub)
+		}
+	}
+	schemas = append(schemas, s.allOf...)
+	schemas = append(schemas, s.anyOf...)
+	return append(schemas, s.oneOf...)
+}
+
+// nested returns the subschemas validating values nested in the value
+func (s *Schema) nested() []*Schema {
+	var schemas []*Schema
+	for _, sub := range []*Schema{s.items, s.contains, s.additionalProperties, s.propertyNames} {
+		if sub != nil {
+			schemas = append(schemas, sub)
+		}
+	}
+	schemas = append(schemas, s.prefixItems...)
+	for _, sub := range s.properties {
+		schemas = append(schemas, sub)
+	}
+	for _, sub := range s.patternProperties {
+		schemas = append(schemas, sub)
+	}
+	return schemas
+}
+
+// resolvePointer resolves a reference like "#/$defs/name" in the document
+func resolvePointer(root interface{}, ref string) (interface{}, error) {
+	pointer := strings.TrimPrefix(ref, "#")
+	if pointer == "" {
+		return root, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("unsupported reference %s", ref)
+	}
+
+	current := root
+	for _, token := range strings.Split(pointer[1:], "/") {
+		token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+		switch v := current.(type) {
+		case map[string]interface{}:
+			next, ok := v[token]
+			if !ok {
+				return nil, fmt.Errorf("unresolvable reference %s", ref)
+			}
+			current = next
+		case []interface{}:
+			i, err := strconv.Atoi(token)
+			if err != nil || i < 0 || i >= len(v) {
+				return nil, fmt.Errorf("unresolvable reference %s", ref)
+			}
+			current = v[i]
+		default:
+			return nil, fmt.Errorf("unresolvable reference %s", ref)
+		}
+	}
+	return current, nil
+}
+
+// Validate validates a value decoded from JSON, returning the errors found
+func (s *Schema) Validate(value interface{}) []ValidationError {
+	var errs []ValidationError
+	s.validate(normalize(value), "", &errs)
+	return errs
+}
+
+// ValidateJSON validates a JSON document
+func (s *Schema) ValidateJSON(data []byte) ([]ValidationError, error) {
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+	return s.Validate(value), nil
+}
+
+// valid reports whether the value matches the schema
+func (s *Schema) valid(value interface{}, path string) bool {
+	var errs []ValidationError
+	s.validate(value, path, &errs)
+	return len(errs) == 0
+}
+
+// validate appends the errors of the value to errs
+func (s *Schema) validate(value interface{}, path string, errs *[]ValidationError) {
+	report := func(format string, args ...interface{}) {
+		*errs = append(*errs, ValidationError{Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if s.always != nil {
+		if !*s.always {
+			report("no value is allowed")
+		}
+		return
+	}
+	if s.refTo != nil {
+		s.refTo.validate(value, path, errs)
+	}
+
+	if len(s.types) > 0 && !matchesType(value, s.types) {
+		report("expected %s, got %s", strings.Join(s.types, " or "), typeOf(value))
+		return
+	}
+	if s.enum != nil {
+		found := false
+		for _, allowed := range s.enum {
+			if reflect.DeepEqual(value, allowed) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			report("value is not one of the allowed values")
+		}
+	}
+	if s.const_ != nil && !reflect.DeepEqual(value, *s.const_) {
+		report("value does not equal the constant")
+	}
+
+	switch v := value.(type) {
+	case float64:
+		s.validateNumber(v, report)
+	case string:
+		s.validateString(v, report)
+	case []interface{}:
+		s.validateArray(v, path, errs, report)
+	case map[string]interface{}:
+		s.validateObject(v, path, errs, report)
+	}
+
+	for _, sub := range s.allOf {
+		sub.validate(value, path, errs)
+	}
+	if len(s.anyOf) > 0 {
+		matched := false
+		for _, sub := range s.anyOf {
+			if sub.valid(value, path) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			report("value does not match any of the allowed schemas")
+		}
+	}
+	if len(s.oneOf) > 0 {
+		matches := 0
+		for _, sub := range s.oneOf {
+			if sub.valid(value, path) {
+				matches++
+			}
+		}
+		if matches != 1 {
+			report("value must match exactly one schema, matched %d", matches)
+		}
+	}
+	if s.not != nil && s.not.valid(value, path) {
+		report("value matches a disallowed schema")
+	}
+	if s.if_ != nil {
+		if s.if_.valid(value, path) {
+			if s.then_ != nil {
+				s.then_.validate(value, path, errs)
+			}
+		} else if s.else_ != nil {
+			s.else_.validate(value, path, errs)
+		}
+	}
+}
+
+// validateNumber validates the numeric constraints
+func (s *Schema) validateNumber(v float64, report func(string, ...interface{})) {
+	if s.multipleOf != nil {
+		if q := v / *s.multipleOf; math.Abs(q-math.Round(q)) > 1e-9 {
+			report("must be a multiple of %v", *s.multipleOf)
+		}
+	}
+	if s.minimum != nil && v < *s.minimum {
+		report("must be at least %v", *s.minimum)
+	}
+	if s.maximum != nil && v > *s.maximum {
+		report("must be at most %v", *s.maximum)
+	}
+	if s.exclusiveMinimum != nil && v <= *s.exclusiveMinimum {
+		report("must be greater than %v", *s.exclusiveMinimum)
+	}
+	if s.exclusiveMaximum != nil && v >= *s.exclusiveMaximum {
+		report("must be less than %v", *s.exclusiveMaximum)
+	}
+}
+
+// validateString validates the string constraints
+func (s *Schema) validateString(v string, report func(string, ...interface{})) {
+	length := utf8.RuneCountInString(v)
+	if s.minLength != nil && length < *s.minLength {
+		report("must be at least %d characters long", *s.minLength)
+	}
+	if s.maxLength != nil && length > *s.maxLength {
+		report("must be at most %d characters long", *s.maxLength)
+	}
+	if s.pattern != nil && !s.pattern.MatchString(v) {
+		report("must match pattern %q", s.pattern.String())
+	}
+}
+
+// validateArray validates the array constraints and items
+func (s *Schema) validateArray(v []interface{}, path string, errs *[]ValidationError, report func(string, ...interface{})) {
+	if s.minItems != nil && len(v) < *s.minItems {
+		report("must have at least %d items", *s.minItems)
+	}
+	if s.maxItems != nil && len(v) > *s.maxItems {
+		report("must have at most %d items", *s.maxItems)
+	}
+	if s.uniqueItems {
+	unique:
+		for i := range v {
+			for j := i + 1; j < len(v); j++ {
+				if reflect.DeepEqual(v[i], v[j]) {
+					report("items %d and %d are equal", i, j)
+					break unique
+				}
+			}
+		}
+	}
+
+	for i, item := range v {
+		itemPath := path + "/" + strconv.Itoa(i)
+		if i < len(s.prefixItems) {
+			s.prefixItems[i].validate(item, itemPath, errs)
+		} else if s.items != nil {
+			s.items.validate(item, itemPath, errs)
+		}
+	}
+
+	if s.contains != nil {
+		found := false
+		for i, item := range v {
+			if s.contains.valid(item, path+"/"+strconv.Itoa(i)) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			report("must contain an item matching the contains schema")
+		}
+	}
+}
+
+// validateObject validates the object constraints and properties
+func (s *Schema) validateObject(v map[string]interface{}, path string, errs *[]ValidationError, report func(string, ...interface{})) {
+	if s.minProperties != nil && len(v) < *s.minProperties {
+		report("must have at least %d properties", *s.minProperties)
+	}
+	if s.maxProperties != nil && len(v) > *s.maxProperties {
+		report("must have at most %d properties", *s.maxProperties)
+	}
+	for _, name := range s.required {
+		if _, ok := v[name]; !ok {
+			report("missing required property %q", name)
+		}
+	}
+
+	// Visit properties in order for stable errors
+	names := make([]string, 0, len(v))
+	for name := range v {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		propPath := path + "/" + escapePointer(name)
+		if s.propertyNames != nil && !s.propertyNames.valid(name, propPath) {
+			report("invalid property name %q", name)
+		}
+
+		matched := false
+		if prop, ok := s.properties[name]; ok {
+			prop.validate(v[name], propPath, errs)
+			matched = true
+		}
+		for re, prop := range s.patternProperties {
+			if re.MatchString(name) {
+				prop.validate(v[name], propPath, errs)
+				matched = true
+			}
+		}
+		if !matched && s.additionalProperties != nil {
+			if s.additionalProperties.always != nil && !*s.additionalProperties.always {
+				report("unexpected property %q", name)
+			} else {
+				s.additionalProperties.validate(v[name], propPath, errs)
+			}
+		}
+	}
+}
+
+// matchesType reports whether the value is of one of the types
+func matchesType(value interface{}, types []string) bool {
+	actual := typeOf(value)
+	for _, t := range types {
+		if t == actual || (t == "number" && actual == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+// typeOf returns the JSON Schema type name of a value
+func typeOf(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		if v == math.Trunc(v) && !math.IsInf(v, 0) {
+			return "integer"
+		}
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
+
+// normalize converts the numbers of a decoded JSON or YAML value to float64
+// so values compare equal regardless of how they were decoded
+func normalize(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			out[key] = normalize(item)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			out[i] = normalize(item)
+		}
+		return out
+	case int:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case uint64:
+		return float64(v)
+	case json.Number:
+		f, _ := v.Float64()
+		return f
+	default:
+		return value
+	}
+}
+
+// escapePointer escapes a JSON Pointer reference token
+func escapePointer(token string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(token, "~", "~0"), "/", "~1")
+}
@@ -0,0 +1,181 @@
+package jsonschema
+
+import (
+	"strings"
+	"testing"
+)
+
+const orderSchema = `
+type: object
+required: [id, items]
+additionalProperties: false
+properties:
+  id:
+    type: string
+    pattern: "^ord-[0-9]+$"
+  note:
+    type: [string, "null"]
+    maxLength: 5
+  items:
+    type: array
+    minItems: 1
+    uniqueItems: true
+    items:
+      $ref: "#/$defs/item"
+$defs:
+  item:
+    type: object
+    required: [sku, quantity]
+    properties:
+      sku:
+        enum: [apple, pear]
+      quantity:
+        type: integer
+        minimum: 1
+        exclusiveMaximum: 100
+`
+
+func TestValidate(t *testing.T) {
+	schema, err := Parse([]byte(orderSchema))
+	if err != nil {
+		t.Fatalf("Failed to compile schema: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		document string
+		paths    []string // Paths of the expected errors
+	}{
+		{"valid", `{"id":"ord-1","note":null,"items":[{"sku":"apple","quantity":2}]}`, nil},
+		{"wrong type", `[]`, []string{""}},
+		{"missing required", `{"items":[{"sku":"apple","quantity":2}]}`, []string{""}},
+		{"additional property", `{"id":"ord-1","items":[{"sku":"apple","quantity":2}],"extra":1}`, []string{""}},
+		{"pattern", `{"id":"order-1","items":[{"sku":"apple","quantity":2}]}`, []string{"/id"}},
+		{"max length", `{"id":"ord-1","note":"too long","items":[{"sku":"apple","quantity":2}]}`, []string{"/note"}},
+		{"min items", `{"id":"ord-1","items":[]}`, []string{"/items"}},
+		{"unique items", `{"id":"ord-1","items":[{"sku":"apple","quantity":2},{"sku":"apple","quantity":2}]}`, []string{"/items"}},
+		{"referenced enum", `{"id":"ord-1","items":[{"sku":"plum","quantity":2}]}`, []string{"/items/0/sku"}},
+		{"integer", `{"id":"ord-1","items":[{"sku":"apple","quantity":1.5}]}`, []string{"/items/0/quantity"}},
+		{"exclusive maximum", `{"id":"ord-1","items":[{"sku":"pear","quantity":100}]}`, []string{"/items/0/quantity"}},
+		{"several errors", `{"id":"ord-1","items":[{"quantity":0}]}`, []string{"/items/0", "/items/0/quantity"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs, err := schema.ValidateJSON([]byte(tt.document))
+			if err != nil {
+				t.Fatalf("Failed to validate: %v", err)
+			}
+			if len(errs) != len(tt.paths) {
+				t.Fatalf("Expected %d errors, got %v", len(tt.paths), errs)
+			}
+			for i, path := range tt.paths {
+				if errs[i].Path != path {
+					t.Errorf("Expected error %d at %q, got %v", i, path, errs[i])
+				}
+			}
+		})
+	}
+}
+
+func TestValidate_Combinators(t *testing.T) {
+	schema, err := Parse([]byte(`{
+		"oneOf": [
+			{"type": "object", "required": ["card"], "properties": {"card": {"type": "string", "minLength": 4}}},
+			{"type": "object", "required": ["iban"]}
+		],
+		"not": {"required": ["debug"]},
+		"if": {"required": ["card"]},
+		"then": {"required": ["cvc"]}
+	}`))
+	if err != nil {
+		t.Fatalf("Failed to compile schema: %v", err)
+	}
+
+	for document, valid := range map[string]bool{
+		`{"card":"4242","cvc":"123"}`:             true,
+		`{"iban":"DE89"}`:                         true,
+		`{"card":"4242"}`:                         false, // then
+		`{"card":"42","cvc":"1"}`:                 false, // oneOf matches none
+		`{"card":"4242","cvc":"1","iban":"DE89"}`: false, // oneOf matches both
+		`{"iban":"DE89","debug":true}`:            false, // not
+	} {
+		errs, err := schema.ValidateJSON([]byte(document))
+		if err != nil {
+			t.Fatalf("Failed to validate %s: %v", document, err)
+		}
+		if valid != (len(errs) == 0) {
+			t.Errorf("Expected %s valid=%v, got errors %v", document, valid, errs)
+		}
+	}
+}
+
+func TestValidate_RecursiveRef(t *testing.T) {
+	schema, err := Parse([]byte(`{
+		"$ref": "#/definitions/node",
+		"definitions": {
+			"node": {
+				"type": "object",
+				"properties": {"children": {"type": "array", "items": {"$ref": "#/definitions/node"}}}
+			}
+		}
+	}`))
+	if err != nil {
+		t.Fatalf("Failed to compile schema: %v", err)
+	}
+
+	errs := schema.Validate(map[string]interface{}{
+		"children": []interface{}{
+			map[string]interface{}{"children": []interface{}{"leaf"}},
+		},
+	})
+	if len(errs) != 1 || errs[0].Path != "/children/0/children/0" {
+		t.Errorf("Expected an error at the nested child, got %v", errs)
+	}
+}
+
+func TestCompile_CircularRef(t *testing.T) {
+	for name, schema := range map[string]string{
+		"root":        `{"$ref": "#"}`,
+		"definitions": `{"$ref": "#/$defs/a", "$defs": {"a": {"$ref": "#/$defs/b"}, "b": {"$ref": "#/$defs/a"}}}`,
+		"applicator":  `{"$ref": "#/$defs/a", "$defs": {"a": {"anyOf": [{"type": "string"}, {"$ref": "#/$defs/a"}]}}}`,
+		"nested":      `{"properties": {"id": {"$ref": "#/$defs/a"}}, "$defs": {"a": {"not": {"$ref": "#/$defs/a"}}}}`,
+	} {
+		_, err := Parse([]byte(schema))
+		if err == nil || !strings.Contains(err.Error(), "circular $ref") {
+			t.Errorf("Expected %s cycle to be rejected, got %v", name, err)
+		}
+	}
+
+	// Cycles through nested values end with the value
+	if _, err := Parse([]byte(`{"anyOf": [{"type": "string"}, {"type": "array", "items": {"$ref": "#"}}]}`)); err != nil {
+		t.Errorf("Expected a recursive schema to compile, got %v", err)
+	}
+}
+
+func TestCompile_Invalid(t *testing.T) {
+	for name, schema := range map[string]string{
+		"unknown type":      `{"type": "float"}`,
+		"invalid pattern":   `{"pattern": "("}`,
+		"negative length":   `{"minLength": -1}`,
+		"remote reference":  `{"$ref": "https://example.com/schema.json"}`,
+		"missing reference": `{"$ref": "#/$defs/missing"}`,
+		"empty anyOf":       `{"anyOf": []}`,
+		"not a schema":      `{"properties": {"id": 1}}`,
+		"malformed":         `{"type": `,
+	} {
+		if _, err := Parse([]byte(schema)); err == nil {
+			t.Errorf("Expected %s schema to be rejected", name)
+		}
+	}
+}
+
+func TestValidateJSON_InvalidDocument(t *testing.T) {
+	schema, err := Compile(map[string]interface{}{"type": "object"})
+	if err != nil {
+		t.Fatalf("Failed to compile schema: %v", err)
+	}
+	if _, err := schema.ValidateJSON([]byte(`{"id":`)); err == nil || !strings.Contains(err.Error(), "invalid JSON") {
+		t.Errorf("Expected an invalid JSON error, got %v", err)
+	}
+}
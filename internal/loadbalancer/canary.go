@@ -421,6 +421,26 @@ func (cb *CanaryBalancer) GetUpstream(id string) (*types.Upstream, error) {
 	return nil, fmt.Errorf("no main version found for upstream group %s", id)
 }
 
+// ListUpstreams returns the upstreams of all versions of all groups
+func (cb *CanaryBalancer) ListUpstreams() []*types.Upstream {
+	cb.mu.RLock()
+	defer cb.mu.RUnlock()
+
+	seen := make(map[string]bool)
+	upstreams := make([]*types.Upstream, 0, len(cb.upstreams))
+	for _, group := range cb.upstreams {
+		for _, version := range group.versions {
+			if version.upstream == nil || seen[version.upstream.ID] {
+				continue
+			}
+			seen[version.upstream.ID] = true
+			upstreams = append(upstreams, version.upstream)
+		}
+	}
+
+	return upstreams
+}
+
 // GetCanaryGroup 获取金丝雀组配置
 func (cb *CanaryBalancer) GetCanaryGroup(groupID string) (*CanaryConfig, error) {
 	cb.mu.RLock()
@@ -497,6 +517,21 @@ func (cb *CanaryBalancer) Start() error {
 	return nil
 }
 
+// StopHealthChecks 停止主动健康检查
+func (cb *CanaryBalancer) StopHealthChecks() error {
+	if cb.healthChecker != nil {
+		return cb.healthChecker.Stop()
+	}
+	return nil
+}
+
+// HealthChecksWarmedUp 报告启动健康检查是否已完成
+func (cb *CanaryBalancer) HealthChecksWarmedUp() bool {
+	cb.mu.RLock()
+	defer cb.mu.RUnlock()
+	return cb.healthChecker == nil || cb.healthChecker.WarmedUp()
+}
+
 // Stop 停止负载均衡器
 func (cb *CanaryBalancer) Stop() error {
 	// 停止健康检查器
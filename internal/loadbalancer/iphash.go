@@ -3,13 +3,12 @@ package loadbalancer
 import (
 	"fmt"
 	"hash/fnv"
-	"net"
 	"net/http"
 	"sort"
-	"strings"
 	"sync"
 	"time"
 
+	"github.com/songzhibin97/stargate/internal/clientip"
 	"github.com/songzhibin97/stargate/internal/config"
 	"github.com/songzhibin97/stargate/internal/health"
 	"github.com/songzhibin97/stargate/internal/types"
@@ -161,26 +160,10 @@ func (ih *IPHashBalancer) getClientIPFromContext() string {
 	return ""
 }
 
-// ExtractClientIP 从HTTP请求中提取客户端IP地址
+// ExtractClientIP 返回为请求解析出的客户端IP地址
+// 仅当请求来自受信任代理时才使用转发头，见 clientip 包
 func ExtractClientIP(r *http.Request) string {
-	// 优先级：X-Real-IP > X-Forwarded-For > RemoteAddr
-	if ip := r.Header.Get("X-Real-IP"); ip != "" {
-		return strings.TrimSpace(ip)
-	}
-
-	if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
-		// X-Forwarded-For 可能包含多个IP，取第一个（原始客户端IP）
-		if ips := strings.Split(forwarded, ","); len(ips) > 0 {
-			return strings.TrimSpace(ips[0])
-		}
-	}
-
-	// 从RemoteAddr提取IP地址
-	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
-		return host
-	}
-
-	return r.RemoteAddr
+	return clientip.FromRequest(r)
 }
 
 // getHealthyTargets 获取健康的目标实例
@@ -393,3 +376,23 @@ func (ih *IPHashBalancer) validateUpstream(upstream *types.Upstream) error {
 
 	return nil
 }
+
+// StopHealthChecks 停止主动健康检查
+func (ih *IPHashBalancer) StopHealthChecks() error {
+	if ih.healthChecker != nil {
+		return ih.healthChecker.Stop()
+	}
+	return nil
+}
+
+// HealthChecksWarmedUp 报告启动健康检查是否已完成
+func (ih *IPHashBalancer) HealthChecksWarmedUp() bool {
+	return ih.healthChecker == nil || ih.healthChecker.WarmedUp()
+}
+
+// AddHealthChangeCallback 注册主动健康检查的健康状态变化回调
+func (ih *IPHashBalancer) AddHealthChangeCallback(callback health.HealthChangeCallback) {
+	if ih.healthChecker != nil {
+		ih.healthChecker.AddHealthChangeCallback(callback)
+	}
+}
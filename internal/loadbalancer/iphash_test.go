@@ -6,6 +6,7 @@ import (
 	"sync"
 	"testing"
 
+	"github.com/songzhibin97/stargate/internal/clientip"
 	"github.com/songzhibin97/stargate/internal/config"
 	"github.com/songzhibin97/stargate/internal/types"
 )
@@ -147,14 +148,26 @@ func testClientIPExtraction(t *testing.T) {
 			expectedIP: "172.16.0.200",
 		},
 		{
-			name:          "X-Real-IP优先级高于X-Forwarded-For",
+			name:          "X-Forwarded-For优先级高于X-Real-IP",
 			remoteAddr:    "127.0.0.1:12345",
 			xRealIP:       "192.168.1.100",
 			xForwardedFor: "203.0.113.10, 192.168.1.1",
-			expectedIP:    "192.168.1.100",
+			expectedIP:    "203.0.113.10",
+		},
+		{
+			name:          "忽略不受信任来源的X-Forwarded-For",
+			remoteAddr:    "172.16.0.200:54321",
+			xForwardedFor: "203.0.113.10",
+			expectedIP:    "172.16.0.200",
 		},
 	}
 
+	// 信任本机及内网代理
+	resolver, err := clientip.NewResolver([]string{"127.0.0.1", "10.0.0.0/8", "192.168.1.0/24"})
+	if err != nil {
+		t.Fatalf("Failed to create resolver: %v", err)
+	}
+
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
 			// 创建测试请求
@@ -170,7 +183,7 @@ func testClientIPExtraction(t *testing.T) {
 			}
 
 			// 提取客户端IP
-			extractedIP := ExtractClientIP(req)
+			extractedIP := ExtractClientIP(resolver.WithClientIP(req))
 
 			if extractedIP != tc.expectedIP {
 				t.Errorf("Expected IP %s, got %s", tc.expectedIP, extractedIP)
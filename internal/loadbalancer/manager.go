@@ -397,6 +397,10 @@ func (m *Manager) InitializeBalancers() error {
 	wrrBalancer := NewWeightedRoundRobinBalancer(m.config)
 	m.RegisterBalancer("weighted_round_robin", wrrBalancer)
 
+	// Initialize Weighted Random balancer
+	wrBalancer := NewWeightedRandomBalancer(m.config)
+	m.RegisterBalancer("weighted_random", wrBalancer)
+
 	// Initialize IP Hash balancer
 	ipHashBalancer := NewIPHashBalancer(m.config)
 	m.RegisterBalancer("ip_hash", ipHashBalancer)
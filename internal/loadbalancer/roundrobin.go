@@ -303,6 +303,26 @@ func (rb *RoundRobinBalancer) Start() error {
 	return nil
 }
 
+// StopHealthChecks stops active health checking
+func (rb *RoundRobinBalancer) StopHealthChecks() error {
+	if rb.healthChecker != nil {
+		return rb.healthChecker.Stop()
+	}
+	return nil
+}
+
+// HealthChecksWarmedUp reports whether startup health checks have completed
+func (rb *RoundRobinBalancer) HealthChecksWarmedUp() bool {
+	return rb.healthChecker == nil || rb.healthChecker.WarmedUp()
+}
+
+// AddHealthChangeCallback 注册主动健康检查的健康状态变化回调
+func (rb *RoundRobinBalancer) AddHealthChangeCallback(callback health.HealthChangeCallback) {
+	if rb.healthChecker != nil {
+		rb.healthChecker.AddHealthChangeCallback(callback)
+	}
+}
+
 // Stop stops the load balancer
 func (rb *RoundRobinBalancer) Stop() error {
 	rb.mu.Lock()
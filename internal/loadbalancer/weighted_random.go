@@ -0,0 +1,295 @@
+package loadbalancer
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/songzhibin97/stargate/internal/config"
+	"github.com/songzhibin97/stargate/internal/health"
+	"github.com/songzhibin97/stargate/internal/types"
+)
+
+// WeightedRandomBalancer 加权随机负载均衡器
+// 按权重比例随机选择健康的目标实例。选择过程不修改共享状态，
+// 只持有读锁，避免加权轮询在高并发下对计数器的争用
+type WeightedRandomBalancer struct {
+	mu            sync.RWMutex
+	upstreams     map[string]*weightedRandomUpstreamState
+	config        *config.Config
+	healthChecker *health.ActiveHealthChecker
+}
+
+// weightedRandomUpstreamState 维护加权随机上游服务的状态
+type weightedRandomUpstreamState struct {
+	upstream *types.Upstream
+	targets  []*types.Target
+	weights  []int // 与 targets 一一对应，未配置权重时为1
+}
+
+// NewWeightedRandomBalancer 创建新的加权随机负载均衡器
+func NewWeightedRandomBalancer(cfg *config.Config) *WeightedRandomBalancer {
+	wr := &WeightedRandomBalancer{
+		upstreams:     make(map[string]*weightedRandomUpstreamState),
+		config:        cfg,
+		healthChecker: health.NewActiveHealthChecker(cfg),
+	}
+
+	// 添加健康状态变化回调
+	wr.healthChecker.AddHealthChangeCallback(wr.onHealthChange)
+
+	// 启动健康检查器
+	wr.healthChecker.Start()
+
+	return wr
+}
+
+// onHealthChange 健康状态变化回调
+func (wr *WeightedRandomBalancer) onHealthChange(upstreamID string, target *types.Target, healthy bool) {
+	wr.mu.Lock()
+	defer wr.mu.Unlock()
+
+	// 更新目标实例的健康状态
+	if state, exists := wr.upstreams[upstreamID]; exists {
+		for _, t := range state.targets {
+			if t.Host == target.Host && t.Port == target.Port {
+				t.Healthy = healthy
+				break
+			}
+		}
+	}
+}
+
+// Select 按权重比例随机选择健康的目标实例
+func (wr *WeightedRandomBalancer) Select(upstream *types.Upstream) (*types.Target, error) {
+	wr.mu.RLock()
+	defer wr.mu.RUnlock()
+
+	state, exists := wr.upstreams[upstream.ID]
+	if !exists {
+		return nil, fmt.Errorf("upstream %s not found", upstream.ID)
+	}
+
+	// 计算健康目标的总权重
+	totalWeight := 0
+	for i, target := range state.targets {
+		if target.Healthy {
+			totalWeight += state.weights[i]
+		}
+	}
+	if totalWeight == 0 {
+		return nil, fmt.Errorf("no healthy targets available for upstream %s", upstream.ID)
+	}
+
+	// 在总权重范围内取随机数，落在哪个目标的权重区间即选中该目标。
+	// 包级随机函数并发安全且无锁
+	r := rand.Intn(totalWeight)
+	for i, target := range state.targets {
+		if !target.Healthy {
+			continue
+		}
+		if r < state.weights[i] {
+			return target, nil
+		}
+		r -= state.weights[i]
+	}
+
+	return nil, fmt.Errorf("failed to select target for upstream %s", upstream.ID)
+}
+
+// UpdateUpstream 更新或添加上游服务，新的权重立即生效
+func (wr *WeightedRandomBalancer) UpdateUpstream(upstream *types.Upstream) error {
+	wr.mu.Lock()
+	defer wr.mu.Unlock()
+
+	// 验证上游服务配置
+	if err := wr.validateUpstream(upstream); err != nil {
+		return fmt.Errorf("invalid upstream: %w", err)
+	}
+
+	state := &weightedRandomUpstreamState{
+		upstream: upstream,
+		targets:  make([]*types.Target, len(upstream.Targets)),
+		weights:  make([]int, len(upstream.Targets)),
+	}
+	copy(state.targets, upstream.Targets)
+	for i, target := range state.targets {
+		state.weights[i] = target.Weight
+		if state.weights[i] <= 0 {
+			state.weights[i] = 1 // 默认权重为1
+		}
+	}
+
+	wr.upstreams[upstream.ID] = state
+
+	// 添加到健康检查器
+	if wr.healthChecker != nil {
+		wr.healthChecker.AddUpstream(upstream)
+	}
+
+	return nil
+}
+
+// RemoveUpstream 移除上游服务
+func (wr *WeightedRandomBalancer) RemoveUpstream(id string) error {
+	wr.mu.Lock()
+	defer wr.mu.Unlock()
+
+	if _, exists := wr.upstreams[id]; !exists {
+		return fmt.Errorf("upstream %s not found", id)
+	}
+
+	delete(wr.upstreams, id)
+
+	// 从健康检查器中移除
+	if wr.healthChecker != nil {
+		wr.healthChecker.RemoveUpstream(id)
+	}
+
+	return nil
+}
+
+// GetUpstream 获取上游服务
+func (wr *WeightedRandomBalancer) GetUpstream(id string) (*types.Upstream, error) {
+	wr.mu.RLock()
+	defer wr.mu.RUnlock()
+
+	state, exists := wr.upstreams[id]
+	if !exists {
+		return nil, fmt.Errorf("upstream %s not found", id)
+	}
+
+	return state.upstream, nil
+}
+
+// ListUpstreams 列出所有上游服务
+func (wr *WeightedRandomBalancer) ListUpstreams() []*types.Upstream {
+	wr.mu.RLock()
+	defer wr.mu.RUnlock()
+
+	upstreams := make([]*types.Upstream, 0, len(wr.upstreams))
+	for _, state := range wr.upstreams {
+		upstreams = append(upstreams, state.upstream)
+	}
+
+	return upstreams
+}
+
+// UpdateTargetHealth 更新目标实例的健康状态
+func (wr *WeightedRandomBalancer) UpdateTargetHealth(upstreamID, targetHost string, targetPort int, healthy bool) error {
+	wr.mu.Lock()
+	defer wr.mu.Unlock()
+
+	state, exists := wr.upstreams[upstreamID]
+	if !exists {
+		return fmt.Errorf("upstream %s not found", upstreamID)
+	}
+
+	for _, target := range state.targets {
+		if target.Host == targetHost && target.Port == targetPort {
+			target.Healthy = healthy
+			return nil
+		}
+	}
+
+	return fmt.Errorf("target %s:%d not found in upstream %s", targetHost, targetPort, upstreamID)
+}
+
+// Health 返回负载均衡器的健康状态
+func (wr *WeightedRandomBalancer) Health() map[string]interface{} {
+	wr.mu.RLock()
+	defer wr.mu.RUnlock()
+
+	upstreams := make(map[string]interface{})
+	for id, state := range wr.upstreams {
+		healthyCount := 0
+		totalWeight := 0
+		targetDetails := make([]map[string]interface{}, len(state.targets))
+		for i, target := range state.targets {
+			if target.Healthy {
+				healthyCount++
+			}
+			totalWeight += state.weights[i]
+			targetDetails[i] = map[string]interface{}{
+				"host":    target.Host,
+				"port":    target.Port,
+				"weight":  state.weights[i],
+				"healthy": target.Healthy,
+			}
+		}
+
+		upstreams[id] = map[string]interface{}{
+			"total_targets":   len(state.targets),
+			"healthy_targets": healthyCount,
+			"total_weight":    totalWeight,
+			"targets":         targetDetails,
+		}
+	}
+
+	return map[string]interface{}{
+		"type":            "weighted_random",
+		"upstreams_count": len(wr.upstreams),
+		"timestamp":       time.Now().Unix(),
+		"upstreams":       upstreams,
+	}
+}
+
+// validateUpstream 验证上游服务配置
+func (wr *WeightedRandomBalancer) validateUpstream(upstream *types.Upstream) error {
+	if upstream == nil {
+		return fmt.Errorf("upstream cannot be nil")
+	}
+
+	if upstream.ID == "" {
+		return fmt.Errorf("upstream ID cannot be empty")
+	}
+
+	if len(upstream.Targets) == 0 {
+		return fmt.Errorf("upstream must have at least one target")
+	}
+
+	// 验证目标配置
+	for i, target := range upstream.Targets {
+		if target.Host == "" {
+			return fmt.Errorf("target %d: host cannot be empty", i)
+		}
+		if target.Port <= 0 || target.Port > 65535 {
+			return fmt.Errorf("target %d: invalid port %d", i, target.Port)
+		}
+		if target.Weight < 0 {
+			return fmt.Errorf("target %d: weight cannot be negative", i)
+		}
+	}
+
+	return nil
+}
+
+// StopHealthChecks 停止主动健康检查
+func (wr *WeightedRandomBalancer) StopHealthChecks() error {
+	if wr.healthChecker != nil {
+		return wr.healthChecker.Stop()
+	}
+	return nil
+}
+
+// HealthChecksWarmedUp 报告启动健康检查是否已完成
+func (wr *WeightedRandomBalancer) HealthChecksWarmedUp() bool {
+	return wr.healthChecker == nil || wr.healthChecker.WarmedUp()
+}
+
+// AddHealthChangeCallback 注册主动健康检查的健康状态变化回调
+func (wr *WeightedRandomBalancer) AddHealthChangeCallback(callback health.HealthChangeCallback) {
+	if wr.healthChecker != nil {
+		wr.healthChecker.AddHealthChangeCallback(callback)
+	}
+}
+
+// Stop 停止负载均衡器
+func (wr *WeightedRandomBalancer) Stop() error {
+	wr.mu.Lock()
+	defer wr.mu.Unlock()
+
+	wr.upstreams = make(map[string]*weightedRandomUpstreamState)
+	return nil
+}
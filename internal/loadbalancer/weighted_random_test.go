@@ -0,0 +1,161 @@
+package loadbalancer
+
+import (
+	"math"
+	"testing"
+
+	"github.com/songzhibin97/stargate/internal/config"
+	"github.com/songzhibin97/stargate/internal/types"
+)
+
+// selectDistribution 执行多次选择并返回各目标被选中的比例
+func selectDistribution(t *testing.T, lb *WeightedRandomBalancer, upstream *types.Upstream, iterations int) map[string]float64 {
+	t.Helper()
+
+	counts := make(map[string]int)
+	for i := 0; i < iterations; i++ {
+		target, err := lb.Select(upstream)
+		if err != nil {
+			t.Fatalf("Request %d: Failed to select target: %v", i+1, err)
+		}
+		counts[target.Host]++
+	}
+
+	ratios := make(map[string]float64, len(counts))
+	for host, count := range counts {
+		ratios[host] = float64(count) / float64(iterations)
+	}
+	return ratios
+}
+
+// assertDistribution 验证选择比例接近期望比例
+func assertDistribution(t *testing.T, actual, expected map[string]float64) {
+	t.Helper()
+
+	const tolerance = 0.02
+	for host, want := range expected {
+		if got := actual[host]; math.Abs(got-want) > tolerance {
+			t.Errorf("%s: Expected ratio %.3f±%.2f, got %.3f", host, want, tolerance, got)
+		}
+	}
+	for host := range actual {
+		if _, ok := expected[host]; !ok {
+			t.Errorf("%s: Unexpected selection", host)
+		}
+	}
+}
+
+// TestWeightedRandomDistribution 验证选择分布近似于配置的权重
+func TestWeightedRandomDistribution(t *testing.T) {
+	lb := NewWeightedRandomBalancer(&config.Config{})
+	defer lb.StopHealthChecks()
+
+	upstream := &types.Upstream{
+		ID:        "test-upstream",
+		Algorithm: "weighted_random",
+		Targets: []*types.Target{
+			{Host: "serverA.example.com", Port: 8080, Weight: 5, Healthy: true},
+			{Host: "serverB.example.com", Port: 8080, Weight: 3, Healthy: true},
+			{Host: "serverC.example.com", Port: 8080, Weight: 2, Healthy: true},
+		},
+	}
+	if err := lb.UpdateUpstream(upstream); err != nil {
+		t.Fatalf("Failed to add upstream: %v", err)
+	}
+
+	assertDistribution(t, selectDistribution(t, lb, upstream, 100000), map[string]float64{
+		"serverA.example.com": 0.5,
+		"serverB.example.com": 0.3,
+		"serverC.example.com": 0.2,
+	})
+}
+
+// TestWeightedRandomWeightUpdate 验证 UpdateUpstream 修改的权重立即生效
+func TestWeightedRandomWeightUpdate(t *testing.T) {
+	lb := NewWeightedRandomBalancer(&config.Config{})
+	defer lb.StopHealthChecks()
+
+	upstream := &types.Upstream{
+		ID: "test-upstream",
+		Targets: []*types.Target{
+			{Host: "serverA.example.com", Port: 8080, Weight: 1, Healthy: true},
+			{Host: "serverB.example.com", Port: 8080, Weight: 1, Healthy: true},
+		},
+	}
+	if err := lb.UpdateUpstream(upstream); err != nil {
+		t.Fatalf("Failed to add upstream: %v", err)
+	}
+	assertDistribution(t, selectDistribution(t, lb, upstream, 50000), map[string]float64{
+		"serverA.example.com": 0.5,
+		"serverB.example.com": 0.5,
+	})
+
+	updated := &types.Upstream{
+		ID: "test-upstream",
+		Targets: []*types.Target{
+			{Host: "serverA.example.com", Port: 8080, Weight: 9, Healthy: true},
+			{Host: "serverB.example.com", Port: 8080, Weight: 1, Healthy: true},
+		},
+	}
+	if err := lb.UpdateUpstream(updated); err != nil {
+		t.Fatalf("Failed to update upstream: %v", err)
+	}
+	assertDistribution(t, selectDistribution(t, lb, updated, 50000), map[string]float64{
+		"serverA.example.com": 0.9,
+		"serverB.example.com": 0.1,
+	})
+}
+
+// TestWeightedRandomExcludesUnhealthyTargets 验证不健康的目标不会被选中
+func TestWeightedRandomExcludesUnhealthyTargets(t *testing.T) {
+	lb := NewWeightedRandomBalancer(&config.Config{})
+	defer lb.StopHealthChecks()
+
+	upstream := &types.Upstream{
+		ID: "test-upstream",
+		Targets: []*types.Target{
+			{Host: "serverA.example.com", Port: 8080, Weight: 5, Healthy: true},
+			{Host: "serverB.example.com", Port: 8080, Weight: 3, Healthy: true},
+			{Host: "serverC.example.com", Port: 8080, Weight: 2, Healthy: true},
+		},
+	}
+	if err := lb.UpdateUpstream(upstream); err != nil {
+		t.Fatalf("Failed to add upstream: %v", err)
+	}
+
+	// 摘除权重最高的目标后，其余目标按 3:2 分配
+	if err := lb.UpdateTargetHealth("test-upstream", "serverA.example.com", 8080, false); err != nil {
+		t.Fatalf("Failed to update target health: %v", err)
+	}
+	assertDistribution(t, selectDistribution(t, lb, upstream, 50000), map[string]float64{
+		"serverB.example.com": 0.6,
+		"serverC.example.com": 0.4,
+	})
+
+	// 所有目标都不健康时返回错误
+	lb.UpdateTargetHealth("test-upstream", "serverB.example.com", 8080, false)
+	lb.UpdateTargetHealth("test-upstream", "serverC.example.com", 8080, false)
+	if _, err := lb.Select(upstream); err == nil {
+		t.Error("Expected error when no targets are healthy")
+	}
+
+	// 恢复后重新参与选择
+	lb.UpdateTargetHealth("test-upstream", "serverC.example.com", 8080, true)
+	target, err := lb.Select(upstream)
+	if err != nil {
+		t.Fatalf("Failed to select target: %v", err)
+	}
+	if target.Host != "serverC.example.com" {
+		t.Errorf("Expected serverC.example.com, got %s", target.Host)
+	}
+}
+
+// TestWeightedRandomUnknownUpstream 验证未知上游服务返回错误
+func TestWeightedRandomUnknownUpstream(t *testing.T) {
+	lb := NewWeightedRandomBalancer(&config.Config{})
+	defer lb.StopHealthChecks()
+
+	if _, err := lb.Select(&types.Upstream{ID: "missing"}); err == nil {
+		t.Error("Expected error for unknown upstream")
+	}
+}
@@ -324,3 +324,23 @@ func (wrr *WeightedRoundRobinBalancer) validateUpstream(upstream *types.Upstream
 
 	return nil
 }
+
+// StopHealthChecks 停止主动健康检查
+func (wrr *WeightedRoundRobinBalancer) StopHealthChecks() error {
+	if wrr.healthChecker != nil {
+		return wrr.healthChecker.Stop()
+	}
+	return nil
+}
+
+// HealthChecksWarmedUp 报告启动健康检查是否已完成
+func (wrr *WeightedRoundRobinBalancer) HealthChecksWarmedUp() bool {
+	return wrr.healthChecker == nil || wrr.healthChecker.WarmedUp()
+}
+
+// AddHealthChangeCallback 注册主动健康检查的健康状态变化回调
+func (wrr *WeightedRoundRobinBalancer) AddHealthChangeCallback(callback health.HealthChangeCallback) {
+	if wrr.healthChecker != nil {
+		wrr.healthChecker.AddHealthChangeCallback(callback)
+	}
+}
@@ -0,0 +1,42 @@
+package memory
+
+import (
+	"github.com/songzhibin97/stargate/pkg/metrics"
+)
+
+// Factory implements metrics.Factory for the in-memory provider
+type Factory struct{}
+
+// Create creates a new MemoryProvider with the given options
+func (f *Factory) Create(opts metrics.ProviderOptions) (metrics.Provider, error) {
+	return NewProvider(Options{
+		Namespace:   opts.Namespace,
+		Subsystem:   opts.Subsystem,
+		ConstLabels: opts.ConstLabels,
+	}), nil
+}
+
+// Name returns the name of the factory
+func (f *Factory) Name() string {
+	return "memory"
+}
+
+// Description returns a description of the factory
+func (f *Factory) Description() string {
+	return "In-memory metrics provider for tests, exposing recorded values for assertions"
+}
+
+// NewFactory creates a new memory factory
+func NewFactory() metrics.Factory {
+	return &Factory{}
+}
+
+// RegisterFactory registers the memory factory with the global registry
+func RegisterFactory() error {
+	return metrics.RegisterFactory("memory", NewFactory())
+}
+
+// init automatically registers the memory factory
+func init() {
+	_ = RegisterFactory()
+}
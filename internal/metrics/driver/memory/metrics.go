@@ -0,0 +1,561 @@
+package memory
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/songzhibin97/stargate/pkg/metrics"
+)
+
+// counter implements metrics.Counter
+type counter struct {
+	mu    sync.Mutex
+	value float64
+}
+
+func (c *counter) Inc() {
+	c.Add(1)
+}
+
+func (c *counter) Add(delta float64) {
+	if delta < 0 {
+		panic("counter cannot decrease in value")
+	}
+	c.mu.Lock()
+	c.value += delta
+	c.mu.Unlock()
+}
+
+func (c *counter) Get() float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.value
+}
+
+// gauge implements metrics.Gauge
+type gauge struct {
+	mu    sync.Mutex
+	value float64
+}
+
+func (g *gauge) Set(value float64) {
+	g.mu.Lock()
+	g.value = value
+	g.mu.Unlock()
+}
+
+func (g *gauge) Inc() {
+	g.Add(1)
+}
+
+func (g *gauge) Dec() {
+	g.Add(-1)
+}
+
+func (g *gauge) Add(delta float64) {
+	g.mu.Lock()
+	g.value += delta
+	g.mu.Unlock()
+}
+
+func (g *gauge) Sub(delta float64) {
+	g.Add(-delta)
+}
+
+func (g *gauge) Get() float64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.value
+}
+
+func (g *gauge) SetToCurrentTime() {
+	g.Set(float64(time.Now().UnixNano()) / 1e9)
+}
+
+// histogram implements metrics.Histogram
+type histogram struct {
+	upperBounds []float64
+
+	mu     sync.Mutex
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+func newHistogram(upperBounds []float64) *histogram {
+	return &histogram{
+		upperBounds: upperBounds,
+		counts:      make([]uint64, len(upperBounds)),
+	}
+}
+
+func (h *histogram) Observe(value float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if i := sort.SearchFloat64s(h.upperBounds, value); i < len(h.counts) {
+		h.counts[i]++
+	}
+	h.count++
+	h.sum += value
+}
+
+func (h *histogram) GetCount() uint64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.count
+}
+
+func (h *histogram) GetSum() float64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.sum
+}
+
+// GetBuckets returns cumulative bucket counts
+func (h *histogram) GetBuckets() []metrics.Bucket {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	buckets := make([]metrics.Bucket, len(h.upperBounds))
+	var cumulative uint64
+	for i, upperBound := range h.upperBounds {
+		cumulative += h.counts[i]
+		buckets[i] = metrics.Bucket{
+			UpperBound: upperBound,
+			Count:      cumulative,
+		}
+	}
+	return buckets
+}
+
+// summary implements metrics.Summary. It keeps every observation and
+// computes exact quantiles, which is fine for the short-lived providers
+// this driver is meant for.
+type summary struct {
+	quantiles []float64
+
+	mu           sync.Mutex
+	observations []float64
+	sum          float64
+}
+
+func newSummary(objectives map[float64]float64) *summary {
+	quantiles := make([]float64, 0, len(objectives))
+	for q := range objectives {
+		quantiles = append(quantiles, q)
+	}
+	sort.Float64s(quantiles)
+
+	return &summary{
+		quantiles: quantiles,
+	}
+}
+
+func (s *summary) Observe(value float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.observations = append(s.observations, value)
+	s.sum += value
+}
+
+func (s *summary) GetCount() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return uint64(len(s.observations))
+}
+
+func (s *summary) GetSum() float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sum
+}
+
+func (s *summary) GetQuantiles() []metrics.Quantile {
+	s.mu.Lock()
+	sorted := make([]float64, len(s.observations))
+	copy(sorted, s.observations)
+	s.mu.Unlock()
+
+	sort.Float64s(sorted)
+
+	quantiles := make([]metrics.Quantile, len(s.quantiles))
+	for i, q := range s.quantiles {
+		value := math.NaN()
+		if len(sorted) > 0 {
+			value = sorted[int(q*float64(len(sorted)-1))]
+		}
+		quantiles[i] = metrics.Quantile{
+			Quantile: q,
+			Value:    value,
+		}
+	}
+	return quantiles
+}
+
+// series is a single label combination of a vector
+type series[T any] struct {
+	labels map[string]string
+	metric T
+}
+
+// vector stores one metric per label combination
+type vector[T any] struct {
+	labelNames []string
+	newMetric  func() T
+
+	mu     sync.RWMutex
+	series map[string]*series[T]
+}
+
+func newVector[T any](labelNames []string, newMetric func() T) *vector[T] {
+	return &vector[T]{
+		labelNames: labelNames,
+		newMetric:  newMetric,
+		series:     make(map[string]*series[T]),
+	}
+}
+
+// key returns the series key for lvs
+func (v *vector[T]) key(lvs []string) string {
+	return strings.Join(lvs, "\xff")
+}
+
+// labelValues orders labels by label name, checking they match the vector's labels
+func (v *vector[T]) labelValues(labels map[string]string) ([]string, error) {
+	if len(labels) != len(v.labelNames) {
+		return nil, fmt.Errorf("%w: expected %d labels, got %d", metrics.ErrInvalidLabel, len(v.labelNames), len(labels))
+	}
+
+	lvs := make([]string, len(v.labelNames))
+	for i, name := range v.labelNames {
+		value, exists := labels[name]
+		if !exists {
+			return nil, fmt.Errorf("%w: missing label %s", metrics.ErrInvalidLabel, name)
+		}
+		lvs[i] = value
+	}
+	return lvs, nil
+}
+
+// get returns the metric for lvs, creating it on first use
+func (v *vector[T]) get(lvs []string) (T, error) {
+	if err := metrics.ValidateLabelValues(v.labelNames, lvs); err != nil {
+		var zero T
+		return zero, err
+	}
+
+	key := v.key(lvs)
+
+	v.mu.RLock()
+	s, exists := v.series[key]
+	v.mu.RUnlock()
+	if exists {
+		return s.metric, nil
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if s, exists := v.series[key]; exists {
+		return s.metric, nil
+	}
+
+	labels := make(map[string]string, len(lvs))
+	for i, name := range v.labelNames {
+		labels[name] = lvs[i]
+	}
+	s = &series[T]{labels: labels, metric: v.newMetric()}
+	v.series[key] = s
+	return s.metric, nil
+}
+
+// getWith is the map variant of get
+func (v *vector[T]) getWith(labels map[string]string) (T, error) {
+	lvs, err := v.labelValues(labels)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return v.get(lvs)
+}
+
+// lookup returns the metric for labels without creating it
+func (v *vector[T]) lookup(labels map[string]string) (T, bool) {
+	var zero T
+	lvs, err := v.labelValues(labels)
+	if err != nil {
+		return zero, false
+	}
+
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	s, exists := v.series[v.key(lvs)]
+	if !exists {
+		return zero, false
+	}
+	return s.metric, true
+}
+
+func (v *vector[T]) deleteLabelValues(lvs []string) bool {
+	if len(lvs) != len(v.labelNames) {
+		return false
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	key := v.key(lvs)
+	if _, exists := v.series[key]; !exists {
+		return false
+	}
+	delete(v.series, key)
+	return true
+}
+
+func (v *vector[T]) delete(labels map[string]string) bool {
+	lvs, err := v.labelValues(labels)
+	if err != nil {
+		return false
+	}
+	return v.deleteLabelValues(lvs)
+}
+
+func (v *vector[T]) deletePartialMatch(labels map[string]string) int {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	deleted := 0
+	for key, s := range v.series {
+		matched := true
+		for name, value := range labels {
+			if s.labels[name] != value {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			delete(v.series, key)
+			deleted++
+		}
+	}
+	return deleted
+}
+
+func (v *vector[T]) reset() {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.series = make(map[string]*series[T])
+}
+
+// snapshot returns all series sorted by key
+func (v *vector[T]) snapshot() []*series[T] {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	keys := make([]string, 0, len(v.series))
+	for key := range v.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	snapshot := make([]*series[T], len(keys))
+	for i, key := range keys {
+		snapshot[i] = v.series[key]
+	}
+	return snapshot
+}
+
+// mustGet panics on error, matching the behaviour of WithLabelValues and With
+func mustGet[T any](metric T, err error) T {
+	if err != nil {
+		panic(err)
+	}
+	return metric
+}
+
+// counterVec implements metrics.CounterVec
+type counterVec struct {
+	*vector[*counter]
+}
+
+func (cv *counterVec) WithLabelValues(lvs ...string) metrics.Counter {
+	return mustGet(cv.get(lvs))
+}
+
+func (cv *counterVec) With(labels map[string]string) metrics.Counter {
+	return mustGet(cv.getWith(labels))
+}
+
+func (cv *counterVec) GetMetricWithLabelValues(lvs ...string) (metrics.Counter, error) {
+	c, err := cv.get(lvs)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (cv *counterVec) GetMetricWith(labels map[string]string) (metrics.Counter, error) {
+	c, err := cv.getWith(labels)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (cv *counterVec) Delete(labels map[string]string) bool {
+	return cv.delete(labels)
+}
+
+func (cv *counterVec) DeleteLabelValues(lvs ...string) bool {
+	return cv.deleteLabelValues(lvs)
+}
+
+func (cv *counterVec) DeletePartialMatch(labels map[string]string) int {
+	return cv.deletePartialMatch(labels)
+}
+
+func (cv *counterVec) Reset() {
+	cv.reset()
+}
+
+// gaugeVec implements metrics.GaugeVec
+type gaugeVec struct {
+	*vector[*gauge]
+}
+
+func (gv *gaugeVec) WithLabelValues(lvs ...string) metrics.Gauge {
+	return mustGet(gv.get(lvs))
+}
+
+func (gv *gaugeVec) With(labels map[string]string) metrics.Gauge {
+	return mustGet(gv.getWith(labels))
+}
+
+func (gv *gaugeVec) GetMetricWithLabelValues(lvs ...string) (metrics.Gauge, error) {
+	g, err := gv.get(lvs)
+	if err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+func (gv *gaugeVec) GetMetricWith(labels map[string]string) (metrics.Gauge, error) {
+	g, err := gv.getWith(labels)
+	if err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+func (gv *gaugeVec) Delete(labels map[string]string) bool {
+	return gv.delete(labels)
+}
+
+func (gv *gaugeVec) DeleteLabelValues(lvs ...string) bool {
+	return gv.deleteLabelValues(lvs)
+}
+
+func (gv *gaugeVec) DeletePartialMatch(labels map[string]string) int {
+	return gv.deletePartialMatch(labels)
+}
+
+func (gv *gaugeVec) Reset() {
+	gv.reset()
+}
+
+// histogramVec implements metrics.HistogramVec
+type histogramVec struct {
+	*vector[*histogram]
+}
+
+func (hv *histogramVec) WithLabelValues(lvs ...string) metrics.Histogram {
+	return mustGet(hv.get(lvs))
+}
+
+func (hv *histogramVec) With(labels map[string]string) metrics.Histogram {
+	return mustGet(hv.getWith(labels))
+}
+
+func (hv *histogramVec) GetMetricWithLabelValues(lvs ...string) (metrics.Histogram, error) {
+	h, err := hv.get(lvs)
+	if err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+func (hv *histogramVec) GetMetricWith(labels map[string]string) (metrics.Histogram, error) {
+	h, err := hv.getWith(labels)
+	if err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+func (hv *histogramVec) Delete(labels map[string]string) bool {
+	return hv.delete(labels)
+}
+
+func (hv *histogramVec) DeleteLabelValues(lvs ...string) bool {
+	return hv.deleteLabelValues(lvs)
+}
+
+func (hv *histogramVec) DeletePartialMatch(labels map[string]string) int {
+	return hv.deletePartialMatch(labels)
+}
+
+func (hv *histogramVec) Reset() {
+	hv.reset()
+}
+
+// summaryVec implements metrics.SummaryVec
+type summaryVec struct {
+	*vector[*summary]
+}
+
+func (sv *summaryVec) WithLabelValues(lvs ...string) metrics.Summary {
+	return mustGet(sv.get(lvs))
+}
+
+func (sv *summaryVec) With(labels map[string]string) metrics.Summary {
+	return mustGet(sv.getWith(labels))
+}
+
+func (sv *summaryVec) GetMetricWithLabelValues(lvs ...string) (metrics.Summary, error) {
+	s, err := sv.get(lvs)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (sv *summaryVec) GetMetricWith(labels map[string]string) (metrics.Summary, error) {
+	s, err := sv.getWith(labels)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (sv *summaryVec) Delete(labels map[string]string) bool {
+	return sv.delete(labels)
+}
+
+func (sv *summaryVec) DeleteLabelValues(lvs ...string) bool {
+	return sv.deleteLabelValues(lvs)
+}
+
+func (sv *summaryVec) DeletePartialMatch(labels map[string]string) int {
+	return sv.deletePartialMatch(labels)
+}
+
+func (sv *summaryVec) Reset() {
+	sv.reset()
+}
@@ -0,0 +1,613 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/songzhibin97/stargate/pkg/metrics"
+)
+
+// family holds a metric created by the provider. Exactly one of the vectors
+// is set; plain metrics are stored as a vector without labels.
+type family struct {
+	name        string
+	help        string
+	metricType  metrics.MetricType
+	isVec       bool
+	constLabels map[string]string
+
+	counters   *vector[*counter]
+	gauges     *vector[*gauge]
+	histograms *vector[*histogram]
+	summaries  *vector[*summary]
+}
+
+// snapshot converts the family's series into gathered metrics
+func (f *family) snapshot(now time.Time) []metrics.Metric {
+	build := func(labels map[string]string) metrics.Metric {
+		return metrics.Metric{
+			Name:      f.name,
+			Help:      f.help,
+			Type:      f.metricType,
+			Labels:    metrics.LabelsToLabelPairs(metrics.MergeLabelMaps(f.constLabels, labels)),
+			Timestamp: now,
+		}
+	}
+
+	var result []metrics.Metric
+	switch f.metricType {
+	case metrics.CounterType:
+		for _, s := range f.counters.snapshot() {
+			m := build(s.labels)
+			m.Value = s.metric.Get()
+			result = append(result, m)
+		}
+	case metrics.GaugeType:
+		for _, s := range f.gauges.snapshot() {
+			m := build(s.labels)
+			m.Value = s.metric.Get()
+			result = append(result, m)
+		}
+	case metrics.HistogramType:
+		for _, s := range f.histograms.snapshot() {
+			m := build(s.labels)
+			m.Count = s.metric.GetCount()
+			m.Sum = s.metric.GetSum()
+			m.Buckets = s.metric.GetBuckets()
+			result = append(result, m)
+		}
+	case metrics.SummaryType:
+		for _, s := range f.summaries.snapshot() {
+			m := build(s.labels)
+			m.Count = s.metric.GetCount()
+			m.Sum = s.metric.GetSum()
+			m.Quantiles = s.metric.GetQuantiles()
+			result = append(result, m)
+		}
+	}
+	return result
+}
+
+// reset clears all series of the family
+func (f *family) reset() {
+	switch f.metricType {
+	case metrics.CounterType:
+		f.counters.reset()
+	case metrics.GaugeType:
+		f.gauges.reset()
+	case metrics.HistogramType:
+		f.histograms.reset()
+	case metrics.SummaryType:
+		f.summaries.reset()
+	}
+}
+
+// MemoryProvider implements the metrics.Provider interface by keeping all
+// values in memory. It is intended for tests that need to assert on
+// recorded values without running a real metrics backend.
+type MemoryProvider struct {
+	namespace   string
+	subsystem   string
+	constLabels map[string]string
+
+	// Metrics storage, keyed by fully qualified name
+	families   map[string]*family
+	collectors []metrics.Collector
+
+	// Synchronization
+	mu sync.RWMutex
+
+	// Lifecycle
+	started bool
+	closed  bool
+}
+
+// Options for creating a MemoryProvider
+type Options struct {
+	Namespace   string
+	Subsystem   string
+	ConstLabels map[string]string
+}
+
+// NewProvider creates a new MemoryProvider
+func NewProvider(opts Options) *MemoryProvider {
+	return &MemoryProvider{
+		namespace:   opts.Namespace,
+		subsystem:   opts.Subsystem,
+		constLabels: metrics.MergeLabelMaps(opts.ConstLabels),
+		families:    make(map[string]*family),
+	}
+}
+
+// newFamily validates opts and returns the family for it, reusing an
+// existing family of the same kind. Callers must hold p.mu.
+func (p *MemoryProvider) newFamily(opts metrics.MetricOptions, metricType metrics.MetricType, isVec bool) (*family, bool, error) {
+	if p.closed {
+		return nil, false, metrics.ErrProviderClosed
+	}
+
+	if err := metrics.ValidateMetricName(opts.Name); err != nil {
+		return nil, false, err
+	}
+
+	if err := metrics.ValidateLabelNames(opts.Labels); err != nil {
+		return nil, false, err
+	}
+
+	fqName := metrics.BuildFQName(p.namespace, p.subsystem, opts.Name)
+
+	if existing, exists := p.families[fqName]; exists {
+		if existing.metricType != metricType || existing.isVec != isVec {
+			return nil, false, fmt.Errorf("%w: %s", metrics.ErrAlreadyRegistered, fqName)
+		}
+		return existing, true, nil
+	}
+
+	f := &family{
+		name:        fqName,
+		help:        opts.Help,
+		metricType:  metricType,
+		isVec:       isVec,
+		constLabels: metrics.MergeLabelMaps(p.constLabels, opts.ConstLabels),
+	}
+	p.families[fqName] = f
+	return f, false, nil
+}
+
+// labelNames returns the variable labels for a metric
+func labelNames(opts metrics.MetricOptions, isVec bool) []string {
+	if !isVec {
+		return nil
+	}
+	return opts.Labels
+}
+
+// histogramBuckets returns validated, sorted buckets for a histogram
+func histogramBuckets(opts metrics.MetricOptions) ([]float64, error) {
+	buckets := opts.Buckets
+	if len(buckets) == 0 {
+		buckets = metrics.DefaultBuckets
+	}
+
+	if err := metrics.ValidateHistogramBuckets(buckets); err != nil {
+		return nil, err
+	}
+
+	sorted := make([]float64, len(buckets))
+	copy(sorted, buckets)
+	sort.Float64s(sorted)
+	return sorted, nil
+}
+
+// summaryObjectives returns validated objectives for a summary
+func summaryObjectives(opts metrics.MetricOptions) (map[float64]float64, error) {
+	objectives := opts.Objectives
+	if len(objectives) == 0 {
+		objectives = metrics.DefaultObjectives
+	}
+
+	if err := metrics.ValidateSummaryObjectives(objectives); err != nil {
+		return nil, err
+	}
+	return objectives, nil
+}
+
+func (p *MemoryProvider) counterFamily(opts metrics.MetricOptions, isVec bool) (*family, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	f, exists, err := p.newFamily(opts, metrics.CounterType, isVec)
+	if err != nil || exists {
+		return f, err
+	}
+
+	f.counters = newVector(labelNames(opts, isVec), func() *counter { return &counter{} })
+	return f, nil
+}
+
+func (p *MemoryProvider) gaugeFamily(opts metrics.MetricOptions, isVec bool) (*family, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	f, exists, err := p.newFamily(opts, metrics.GaugeType, isVec)
+	if err != nil || exists {
+		return f, err
+	}
+
+	f.gauges = newVector(labelNames(opts, isVec), func() *gauge { return &gauge{} })
+	return f, nil
+}
+
+func (p *MemoryProvider) histogramFamily(opts metrics.MetricOptions, isVec bool) (*family, error) {
+	buckets, err := histogramBuckets(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	f, exists, err := p.newFamily(opts, metrics.HistogramType, isVec)
+	if err != nil || exists {
+		return f, err
+	}
+
+	f.histograms = newVector(labelNames(opts, isVec), func() *histogram { return newHistogram(buckets) })
+	return f, nil
+}
+
+func (p *MemoryProvider) summaryFamily(opts metrics.MetricOptions, isVec bool) (*family, error) {
+	objectives, err := summaryObjectives(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	f, exists, err := p.newFamily(opts, metrics.SummaryType, isVec)
+	if err != nil || exists {
+		return f, err
+	}
+
+	f.summaries = newVector(labelNames(opts, isVec), func() *summary { return newSummary(objectives) })
+	return f, nil
+}
+
+// NewCounter creates a new counter metric
+func (p *MemoryProvider) NewCounter(opts metrics.MetricOptions) (metrics.Counter, error) {
+	f, err := p.counterFamily(opts, false)
+	if err != nil {
+		return nil, err
+	}
+	return f.counters.get(nil)
+}
+
+// NewCounterVec creates a new counter vector metric
+func (p *MemoryProvider) NewCounterVec(opts metrics.MetricOptions) (metrics.CounterVec, error) {
+	f, err := p.counterFamily(opts, true)
+	if err != nil {
+		return nil, err
+	}
+	return &counterVec{vector: f.counters}, nil
+}
+
+// NewGauge creates a new gauge metric
+func (p *MemoryProvider) NewGauge(opts metrics.MetricOptions) (metrics.Gauge, error) {
+	f, err := p.gaugeFamily(opts, false)
+	if err != nil {
+		return nil, err
+	}
+	return f.gauges.get(nil)
+}
+
+// NewGaugeVec creates a new gauge vector metric
+func (p *MemoryProvider) NewGaugeVec(opts metrics.MetricOptions) (metrics.GaugeVec, error) {
+	f, err := p.gaugeFamily(opts, true)
+	if err != nil {
+		return nil, err
+	}
+	return &gaugeVec{vector: f.gauges}, nil
+}
+
+// NewHistogram creates a new histogram metric
+func (p *MemoryProvider) NewHistogram(opts metrics.MetricOptions) (metrics.Histogram, error) {
+	f, err := p.histogramFamily(opts, false)
+	if err != nil {
+		return nil, err
+	}
+	return f.histograms.get(nil)
+}
+
+// NewHistogramVec creates a new histogram vector metric
+func (p *MemoryProvider) NewHistogramVec(opts metrics.MetricOptions) (metrics.HistogramVec, error) {
+	f, err := p.histogramFamily(opts, true)
+	if err != nil {
+		return nil, err
+	}
+	return &histogramVec{vector: f.histograms}, nil
+}
+
+// NewSummary creates a new summary metric
+func (p *MemoryProvider) NewSummary(opts metrics.MetricOptions) (metrics.Summary, error) {
+	f, err := p.summaryFamily(opts, false)
+	if err != nil {
+		return nil, err
+	}
+	return f.summaries.get(nil)
+}
+
+// NewSummaryVec creates a new summary vector metric
+func (p *MemoryProvider) NewSummaryVec(opts metrics.MetricOptions) (metrics.SummaryVec, error) {
+	f, err := p.summaryFamily(opts, true)
+	if err != nil {
+		return nil, err
+	}
+	return &summaryVec{vector: f.summaries}, nil
+}
+
+// Register registers a collector whose metrics are included in Gather
+func (p *MemoryProvider) Register(collector metrics.Collector) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return metrics.ErrProviderClosed
+	}
+
+	for _, existing := range p.collectors {
+		if existing == collector {
+			return metrics.ErrAlreadyRegistered
+		}
+	}
+
+	p.collectors = append(p.collectors, collector)
+	return nil
+}
+
+// Unregister unregisters a collector
+func (p *MemoryProvider) Unregister(collector metrics.Collector) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return metrics.ErrProviderClosed
+	}
+
+	for i, existing := range p.collectors {
+		if existing == collector {
+			p.collectors = append(p.collectors[:i], p.collectors[i+1:]...)
+			return nil
+		}
+	}
+
+	return metrics.ErrCollectorNotFound
+}
+
+// UnregisterMetric removes the metric created with the given name
+func (p *MemoryProvider) UnregisterMetric(name string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return metrics.ErrProviderClosed
+	}
+
+	fqName := metrics.BuildFQName(p.namespace, p.subsystem, name)
+	if _, exists := p.families[fqName]; !exists {
+		return fmt.Errorf("%w: %s", metrics.ErrMetricNotFound, fqName)
+	}
+
+	delete(p.families, fqName)
+	return nil
+}
+
+// Gather returns all metrics sorted by family name
+func (p *MemoryProvider) Gather() ([]*metrics.MetricFamily, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.closed {
+		return nil, metrics.ErrProviderClosed
+	}
+
+	now := time.Now()
+	byName := make(map[string]*metrics.MetricFamily, len(p.families))
+
+	for name, f := range p.families {
+		byName[name] = &metrics.MetricFamily{
+			Name:    f.name,
+			Help:    f.help,
+			Type:    f.metricType,
+			Metrics: f.snapshot(now),
+		}
+	}
+
+	for _, collector := range p.collectors {
+		ch := make(chan metrics.Metric)
+		go func() {
+			collector.Collect(ch)
+			close(ch)
+		}()
+
+		for m := range ch {
+			mf, exists := byName[m.Name]
+			if !exists {
+				mf = &metrics.MetricFamily{
+					Name: m.Name,
+					Help: m.Help,
+					Type: m.Type,
+				}
+				byName[m.Name] = mf
+			}
+			mf.Metrics = append(mf.Metrics, m)
+		}
+	}
+
+	families := make([]*metrics.MetricFamily, 0, len(byName))
+	for _, mf := range byName {
+		families = append(families, mf)
+	}
+	sort.Slice(families, func(i, j int) bool {
+		return families[i].Name < families[j].Name
+	})
+
+	return families, nil
+}
+
+// GatherWithOptions gathers metrics; options are ignored
+func (p *MemoryProvider) GatherWithOptions(opts *metrics.GatherOptions) ([]*metrics.MetricFamily, error) {
+	return p.Gather()
+}
+
+// Handler returns a no-op HTTP handler, the memory provider does not expose
+// an exposition format
+func (p *MemoryProvider) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// HandlerFor returns a no-op HTTP handler
+func (p *MemoryProvider) HandlerFor(gatherer metrics.Gatherer, opts metrics.HandlerOpts) http.Handler {
+	return p.Handler()
+}
+
+// Start starts the provider
+func (p *MemoryProvider) Start(ctx context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return metrics.ErrProviderClosed
+	}
+
+	p.started = true
+	return nil
+}
+
+// Stop stops the provider and discards all metrics
+func (p *MemoryProvider) Stop(ctx context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return nil
+	}
+
+	p.closed = true
+	p.started = false
+	p.families = make(map[string]*family)
+	p.collectors = nil
+
+	return nil
+}
+
+// Health checks the health of the provider
+func (p *MemoryProvider) Health() error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.closed {
+		return metrics.ErrProviderClosed
+	}
+	return nil
+}
+
+// Name returns the name of the provider
+func (p *MemoryProvider) Name() string {
+	return "memory"
+}
+
+// Version returns the version of the provider
+func (p *MemoryProvider) Version() string {
+	return "1.0.0"
+}
+
+// Reset clears the values of all metrics while keeping them registered
+func (p *MemoryProvider) Reset() {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	for _, f := range p.families {
+		f.reset()
+	}
+}
+
+// family looks up a family by fully qualified name, or by the name it was
+// created with. The returned family is nil if it does not exist or is of a
+// different type.
+func (p *MemoryProvider) family(name string, metricType metrics.MetricType) *family {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	f, exists := p.families[name]
+	if !exists {
+		f, exists = p.families[metrics.BuildFQName(p.namespace, p.subsystem, name)]
+	}
+	if !exists || f.metricType != metricType {
+		return nil
+	}
+	return f
+}
+
+// GetCounterValue returns the value of the counter series with the given
+// variable labels, or 0 if it has not been recorded. Pass nil labels for
+// metrics created without labels.
+func (p *MemoryProvider) GetCounterValue(name string, labels map[string]string) float64 {
+	if f := p.family(name, metrics.CounterType); f != nil {
+		if c, ok := f.counters.lookup(labels); ok {
+			return c.Get()
+		}
+	}
+	return 0
+}
+
+// GetGaugeValue returns the value of the gauge series with the given
+// variable labels, or 0 if it has not been recorded
+func (p *MemoryProvider) GetGaugeValue(name string, labels map[string]string) float64 {
+	if f := p.family(name, metrics.GaugeType); f != nil {
+		if g, ok := f.gauges.lookup(labels); ok {
+			return g.Get()
+		}
+	}
+	return 0
+}
+
+// GetHistogramCount returns the number of observations of the histogram
+// series with the given variable labels
+func (p *MemoryProvider) GetHistogramCount(name string, labels map[string]string) uint64 {
+	if f := p.family(name, metrics.HistogramType); f != nil {
+		if h, ok := f.histograms.lookup(labels); ok {
+			return h.GetCount()
+		}
+	}
+	return 0
+}
+
+// GetHistogramSum returns the sum of observations of the histogram series
+// with the given variable labels
+func (p *MemoryProvider) GetHistogramSum(name string, labels map[string]string) float64 {
+	if f := p.family(name, metrics.HistogramType); f != nil {
+		if h, ok := f.histograms.lookup(labels); ok {
+			return h.GetSum()
+		}
+	}
+	return 0
+}
+
+// GetSummaryCount returns the number of observations of the summary series
+// with the given variable labels
+func (p *MemoryProvider) GetSummaryCount(name string, labels map[string]string) uint64 {
+	if f := p.family(name, metrics.SummaryType); f != nil {
+		if s, ok := f.summaries.lookup(labels); ok {
+			return s.GetCount()
+		}
+	}
+	return 0
+}
+
+// GetSummarySum returns the sum of observations of the summary series with
+// the given variable labels
+func (p *MemoryProvider) GetSummarySum(name string, labels map[string]string) float64 {
+	if f := p.family(name, metrics.SummaryType); f != nil {
+		if s, ok := f.summaries.lookup(labels); ok {
+			return s.GetSum()
+		}
+	}
+	return 0
+}
+
+// SeriesCount returns the number of series recorded for a metric
+func (p *MemoryProvider) SeriesCount(name string) int {
+	for _, metricType := range []metrics.MetricType{metrics.CounterType, metrics.GaugeType, metrics.HistogramType, metrics.SummaryType} {
+		if f := p.family(name, metricType); f != nil {
+			return len(f.snapshot(time.Time{}))
+		}
+	}
+	return 0
+}
@@ -0,0 +1,192 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/songzhibin97/stargate/pkg/metrics"
+)
+
+func TestMemoryProvider_CounterVec(t *testing.T) {
+	provider := NewProvider(Options{
+		Namespace: "test",
+	})
+
+	counterVec, err := provider.NewCounterVec(metrics.MetricOptions{
+		Name:   "requests_total",
+		Help:   "Total requests",
+		Labels: []string{"method", "status"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create counter vector: %v", err)
+	}
+
+	counterVec.WithLabelValues("GET", "200").Inc()
+	counterVec.WithLabelValues("GET", "200").Add(2)
+	counterVec.With(map[string]string{"method": "POST", "status": "500"}).Inc()
+
+	if got := provider.GetCounterValue("requests_total", map[string]string{"method": "GET", "status": "200"}); got != 3 {
+		t.Errorf("Expected counter value 3, got %f", got)
+	}
+	if got := provider.GetCounterValue("test_requests_total", map[string]string{"method": "POST", "status": "500"}); got != 1 {
+		t.Errorf("Expected counter value 1 by fully qualified name, got %f", got)
+	}
+	if got := provider.GetCounterValue("requests_total", map[string]string{"method": "PUT", "status": "200"}); got != 0 {
+		t.Errorf("Expected unrecorded series to be 0, got %f", got)
+	}
+
+	if _, err := counterVec.GetMetricWithLabelValues("GET"); !errors.Is(err, metrics.ErrInvalidLabel) {
+		t.Errorf("Expected ErrInvalidLabel for wrong label count, got %v", err)
+	}
+
+	if deleted := counterVec.DeletePartialMatch(map[string]string{"method": "POST"}); deleted != 1 {
+		t.Errorf("Expected 1 series deleted, got %d", deleted)
+	}
+	if got := provider.SeriesCount("requests_total"); got != 1 {
+		t.Errorf("Expected 1 remaining series, got %d", got)
+	}
+}
+
+func TestMemoryProvider_MetricTypes(t *testing.T) {
+	provider := NewProvider(Options{})
+
+	gauge, err := provider.NewGauge(metrics.MetricOptions{Name: "connections", Help: "Connections"})
+	if err != nil {
+		t.Fatalf("Failed to create gauge: %v", err)
+	}
+	gauge.Set(5)
+	gauge.Dec()
+	if got := provider.GetGaugeValue("connections", nil); got != 4 {
+		t.Errorf("Expected gauge value 4, got %f", got)
+	}
+
+	histogram, err := provider.NewHistogram(metrics.MetricOptions{
+		Name:    "latency_seconds",
+		Help:    "Latency",
+		Buckets: []float64{0.1, 1},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create histogram: %v", err)
+	}
+	histogram.Observe(0.05)
+	histogram.Observe(0.5)
+	histogram.Observe(5)
+
+	if got := provider.GetHistogramCount("latency_seconds", nil); got != 3 {
+		t.Errorf("Expected histogram count 3, got %d", got)
+	}
+	if got := provider.GetHistogramSum("latency_seconds", nil); got != 5.55 {
+		t.Errorf("Expected histogram sum 5.55, got %f", got)
+	}
+	buckets := histogram.GetBuckets()
+	if len(buckets) != 2 || buckets[0].Count != 1 || buckets[1].Count != 2 {
+		t.Errorf("Expected cumulative bucket counts [1 2], got %+v", buckets)
+	}
+
+	summaryVec, err := provider.NewSummaryVec(metrics.MetricOptions{
+		Name:   "payload_bytes",
+		Help:   "Payload size",
+		Labels: []string{"route"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create summary vector: %v", err)
+	}
+	for i := 1; i <= 10; i++ {
+		summaryVec.WithLabelValues("/a").Observe(float64(i))
+	}
+	if got := provider.GetSummaryCount("payload_bytes", map[string]string{"route": "/a"}); got != 10 {
+		t.Errorf("Expected summary count 10, got %d", got)
+	}
+
+	// Creating a metric of another type under an existing name fails
+	if _, err := provider.NewCounter(metrics.MetricOptions{Name: "connections", Help: "Connections"}); !errors.Is(err, metrics.ErrAlreadyRegistered) {
+		t.Errorf("Expected ErrAlreadyRegistered, got %v", err)
+	}
+
+	// Creating the same metric again returns the existing one
+	again, err := provider.NewGauge(metrics.MetricOptions{Name: "connections", Help: "Connections"})
+	if err != nil {
+		t.Fatalf("Failed to get existing gauge: %v", err)
+	}
+	if again.Get() != 4 {
+		t.Errorf("Expected existing gauge value 4, got %f", again.Get())
+	}
+
+	provider.Reset()
+	if got := provider.GetGaugeValue("connections", nil); got != 0 {
+		t.Errorf("Expected gauge value 0 after reset, got %f", got)
+	}
+}
+
+func TestMemoryProvider_Gather(t *testing.T) {
+	provider := NewProvider(Options{
+		Namespace:   "test",
+		ConstLabels: map[string]string{"service": "gateway"},
+	})
+
+	counter, err := provider.NewCounter(metrics.MetricOptions{Name: "events_total", Help: "Events"})
+	if err != nil {
+		t.Fatalf("Failed to create counter: %v", err)
+	}
+	counter.Inc()
+
+	families, err := provider.Gather()
+	if err != nil {
+		t.Fatalf("Failed to gather metrics: %v", err)
+	}
+	if len(families) != 1 || families[0].Name != "test_events_total" {
+		t.Fatalf("Expected test_events_total family, got %+v", families)
+	}
+	m := families[0].Metrics[0]
+	if m.Value != 1 {
+		t.Errorf("Expected value 1, got %f", m.Value)
+	}
+	if labels := metrics.LabelPairsToLabels(m.Labels); labels["service"] != "gateway" {
+		t.Errorf("Expected const label service=gateway, got %v", labels)
+	}
+
+	if err := provider.UnregisterMetric("events_total"); err != nil {
+		t.Fatalf("Failed to unregister metric: %v", err)
+	}
+	families, err = provider.Gather()
+	if err != nil {
+		t.Fatalf("Failed to gather metrics: %v", err)
+	}
+	if len(families) != 0 {
+		t.Errorf("Expected no families after unregister, got %d", len(families))
+	}
+}
+
+func TestMemoryProvider_HandlerAndLifecycle(t *testing.T) {
+	provider := NewProvider(Options{})
+
+	w := httptest.NewRecorder()
+	provider.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	if w.Code != http.StatusNoContent {
+		t.Errorf("Expected status 204, got %d", w.Code)
+	}
+
+	ctx := context.Background()
+	if err := provider.Start(ctx); err != nil {
+		t.Fatalf("Failed to start provider: %v", err)
+	}
+	if err := provider.Stop(ctx); err != nil {
+		t.Fatalf("Failed to stop provider: %v", err)
+	}
+	if err := provider.Health(); !errors.Is(err, metrics.ErrProviderClosed) {
+		t.Errorf("Expected ErrProviderClosed after stop, got %v", err)
+	}
+}
+
+func TestMemoryFactory(t *testing.T) {
+	provider, err := metrics.NewProvider("memory", metrics.ProviderOptions{Namespace: "test"})
+	if err != nil {
+		t.Fatalf("Failed to create provider from registered factory: %v", err)
+	}
+	if provider.Name() != "memory" {
+		t.Errorf("Expected provider name 'memory', got '%s'", provider.Name())
+	}
+}
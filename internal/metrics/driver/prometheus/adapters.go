@@ -67,6 +67,10 @@ func (cv *prometheusCounterVec) DeleteLabelValues(lvs ...string) bool {
 	return cv.counterVec.DeleteLabelValues(lvs...)
 }
 
+func (cv *prometheusCounterVec) DeletePartialMatch(labels map[string]string) int {
+	return cv.counterVec.DeletePartialMatch(prometheus.Labels(labels))
+}
+
 func (cv *prometheusCounterVec) Reset() {
 	cv.counterVec.Reset()
 }
@@ -148,6 +152,10 @@ func (gv *prometheusGaugeVec) DeleteLabelValues(lvs ...string) bool {
 	return gv.gaugeVec.DeleteLabelValues(lvs...)
 }
 
+func (gv *prometheusGaugeVec) DeletePartialMatch(labels map[string]string) int {
+	return gv.gaugeVec.DeletePartialMatch(prometheus.Labels(labels))
+}
+
 func (gv *prometheusGaugeVec) Reset() {
 	gv.gaugeVec.Reset()
 }
@@ -252,6 +260,10 @@ func (hv *prometheusHistogramVec) DeleteLabelValues(lvs ...string) bool {
 	return hv.histogramVec.DeleteLabelValues(lvs...)
 }
 
+func (hv *prometheusHistogramVec) DeletePartialMatch(labels map[string]string) int {
+	return hv.histogramVec.DeletePartialMatch(prometheus.Labels(labels))
+}
+
 func (hv *prometheusHistogramVec) Reset() {
 	hv.histogramVec.Reset()
 }
@@ -356,6 +368,10 @@ func (sv *prometheusSummaryVec) DeleteLabelValues(lvs ...string) bool {
 	return sv.summaryVec.DeleteLabelValues(lvs...)
 }
 
+func (sv *prometheusSummaryVec) DeletePartialMatch(labels map[string]string) int {
+	return sv.summaryVec.DeletePartialMatch(prometheus.Labels(labels))
+}
+
 func (sv *prometheusSummaryVec) Reset() {
 	sv.summaryVec.Reset()
 }
@@ -0,0 +1,260 @@
+package prometheus
+
+import (
+	"log"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/songzhibin97/stargate/pkg/metrics"
+)
+
+// OverflowLabelValue replaces every label value of a series that would push a
+// vector past its MaxSeries cap
+const OverflowLabelValue = "__overflow__"
+
+// seriesGuard caps the number of distinct label combinations of a vector.
+// Combinations seen before the cap is reached keep their own series; any
+// further unseen combination is folded into a single overflow series.
+type seriesGuard struct {
+	name       string
+	labelNames []string
+	maxSeries  int
+	overflow   prometheus.Counter
+	// overflowKey identifies the overflow series itself, which never counts
+	// against the cap
+	overflowKey string
+
+	mu     sync.Mutex
+	seen   map[string]struct{}
+	warned bool
+}
+
+func newSeriesGuard(name string, labelNames []string, maxSeries int, overflow prometheus.Counter) *seriesGuard {
+	overflowValues := make([]string, len(labelNames))
+	for i := range overflowValues {
+		overflowValues[i] = OverflowLabelValue
+	}
+
+	return &seriesGuard{
+		name:        name,
+		labelNames:  labelNames,
+		maxSeries:   maxSeries,
+		overflow:    overflow,
+		overflowKey: strings.Join(overflowValues, "\xff"),
+		seen:        make(map[string]struct{}),
+	}
+}
+
+// admit reports whether lvs may keep its own series, recording it if the cap
+// has not been reached yet
+func (g *seriesGuard) admit(lvs []string) bool {
+	key := strings.Join(lvs, "\xff")
+	if key == g.overflowKey {
+		return true
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if _, exists := g.seen[key]; exists {
+		return true
+	}
+	if len(g.seen) < g.maxSeries {
+		g.seen[key] = struct{}{}
+		return true
+	}
+
+	g.overflow.Inc()
+	if !g.warned {
+		g.warned = true
+		log.Printf("metrics: %s exceeded %d series, folding new label combinations into %s", g.name, g.maxSeries, OverflowLabelValue)
+	}
+	return false
+}
+
+// labelValues returns the label values to use for lvs, folding them into the
+// overflow series once the cap is reached
+func (g *seriesGuard) labelValues(lvs []string) []string {
+	if g.admit(lvs) {
+		return lvs
+	}
+
+	folded := make([]string, len(lvs))
+	for i := range folded {
+		folded[i] = OverflowLabelValue
+	}
+	return folded
+}
+
+// labels is the map variant of labelValues
+func (g *seriesGuard) labels(labels map[string]string) map[string]string {
+	if g.admit(g.orderedValues(labels)) {
+		return labels
+	}
+
+	folded := make(map[string]string, len(labels))
+	for name := range labels {
+		folded[name] = OverflowLabelValue
+	}
+	return folded
+}
+
+// orderedValues returns the values of labels in label name order
+func (g *seriesGuard) orderedValues(labels map[string]string) []string {
+	lvs := make([]string, len(g.labelNames))
+	for i, name := range g.labelNames {
+		lvs[i] = labels[name]
+	}
+	return lvs
+}
+
+// forget removes a label combination so it no longer counts against the cap
+func (g *seriesGuard) forget(lvs []string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	delete(g.seen, strings.Join(lvs, "\xff"))
+}
+
+// forgetLabels is the map variant of forget
+func (g *seriesGuard) forgetLabels(labels map[string]string) {
+	g.forget(g.orderedValues(labels))
+}
+
+// forgetPartialMatch removes all label combinations that contain the given labels
+func (g *seriesGuard) forgetPartialMatch(labels map[string]string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	for key := range g.seen {
+		lvs := strings.Split(key, "\xff")
+		matched := true
+		for i, name := range g.labelNames {
+			if value, exists := labels[name]; exists && (i >= len(lvs) || lvs[i] != value) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			delete(g.seen, key)
+		}
+	}
+}
+
+// reset forgets all label combinations
+func (g *seriesGuard) reset() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.seen = make(map[string]struct{})
+}
+
+// guardedCounterVec applies a seriesGuard to a metrics.CounterVec
+type guardedCounterVec struct {
+	metrics.CounterVec
+	guard *seriesGuard
+}
+
+func (cv *guardedCounterVec) WithLabelValues(lvs ...string) metrics.Counter {
+	return cv.CounterVec.WithLabelValues(cv.guard.labelValues(lvs)...)
+}
+
+func (cv *guardedCounterVec) With(labels map[string]string) metrics.Counter {
+	return cv.CounterVec.With(cv.guard.labels(labels))
+}
+
+func (cv *guardedCounterVec) GetMetricWithLabelValues(lvs ...string) (metrics.Counter, error) {
+	return cv.CounterVec.GetMetricWithLabelValues(cv.guard.labelValues(lvs)...)
+}
+
+func (cv *guardedCounterVec) GetMetricWith(labels map[string]string) (metrics.Counter, error) {
+	return cv.CounterVec.GetMetricWith(cv.guard.labels(labels))
+}
+
+func (cv *guardedCounterVec) Delete(labels map[string]string) bool {
+	cv.guard.forgetLabels(labels)
+	return cv.CounterVec.Delete(labels)
+}
+
+func (cv *guardedCounterVec) DeleteLabelValues(lvs ...string) bool {
+	cv.guard.forget(lvs)
+	return cv.CounterVec.DeleteLabelValues(lvs...)
+}
+
+func (cv *guardedCounterVec) DeletePartialMatch(labels map[string]string) int {
+	cv.guard.forgetPartialMatch(labels)
+	return cv.CounterVec.DeletePartialMatch(labels)
+}
+
+func (cv *guardedCounterVec) Reset() {
+	cv.guard.reset()
+	cv.CounterVec.Reset()
+}
+
+// guardedGaugeVec applies a seriesGuard to a metrics.GaugeVec
+type guardedGaugeVec struct {
+	metrics.GaugeVec
+	guard *seriesGuard
+}
+
+func (gv *guardedGaugeVec) WithLabelValues(lvs ...string) metrics.Gauge {
+	return gv.GaugeVec.WithLabelValues(gv.guard.labelValues(lvs)...)
+}
+
+func (gv *guardedGaugeVec) With(labels map[string]string) metrics.Gauge {
+	return gv.GaugeVec.With(gv.guard.labels(labels))
+}
+
+func (gv *guardedGaugeVec) GetMetricWithLabelValues(lvs ...string) (metrics.Gauge, error) {
+	return gv.GaugeVec.GetMetricWithLabelValues(gv.guard.labelValues(lvs)...)
+}
+
+func (gv *guardedGaugeVec) GetMetricWith(labels map[string]string) (metrics.Gauge, error) {
+	return gv.GaugeVec.GetMetricWith(gv.guard.labels(labels))
+}
+
+func (gv *guardedGaugeVec) Delete(labels map[string]string) bool {
+	gv.guard.forgetLabels(labels)
+	return gv.GaugeVec.Delete(labels)
+}
+
+func (gv *guardedGaugeVec) DeleteLabelValues(lvs ...string) bool {
+	gv.guard.forget(lvs)
+	return gv.GaugeVec.DeleteLabelValues(lvs...)
+}
+
+func (gv *guardedGaugeVec) DeletePartialMatch(labels map[string]string) int {
+	gv.guard.forgetPartialMatch(labels)
+	return gv.GaugeVec.DeletePartialMatch(labels)
+}
+
+func (gv *guardedGaugeVec) Reset() {
+	gv.guard.reset()
+	gv.GaugeVec.Reset()
+}
+
+// seriesOverflowCounter returns the overflow counter for the given metric,
+// registering the shared overflow counter vector on first use. Callers must
+// hold p.mu.
+func (p *PrometheusProvider) seriesOverflowCounter(fqName string) (prometheus.Counter, error) {
+	if p.overflowVec == nil {
+		overflowVec := prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   p.namespace,
+			Name:        "metrics_series_overflow_total",
+			Help:        "Total number of observations folded into the overflow series because a metric exceeded its series cap",
+			ConstLabels: p.constLabels,
+		}, []string{"metric"})
+		if err := p.registry.Register(overflowVec); err != nil {
+			are, ok := err.(prometheus.AlreadyRegisteredError)
+			if !ok {
+				return nil, err
+			}
+			existing, ok := are.ExistingCollector.(*prometheus.CounterVec)
+			if !ok {
+				return nil, err
+			}
+			overflowVec = existing
+		}
+		p.overflowVec = overflowVec
+	}
+	return p.overflowVec.WithLabelValues(fqName), nil
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"time"
 
 	"github.com/songzhibin97/stargate/pkg/metrics"
 )
@@ -242,3 +243,26 @@ func ExampleProvider_Handler() {
 	// Metrics Status: 200
 	// Metrics Content-Type: text/plain; version=0.0.4; charset=utf-8; escaping=underscores
 }
+
+// Example demonstrates measuring operation latency with a timer
+func ExampleConvenientFactory_Timer() {
+	provider, err := NewProvider(Options{Namespace: "example"})
+	if err != nil {
+		panic(err)
+	}
+	factory := metrics.NewConvenientFactory(provider)
+
+	timer := factory.Timer("operation_duration_seconds", "Operation duration in seconds")
+	func() {
+		defer timer.Start()()
+		// ... do work ...
+	}()
+
+	// Durations measured elsewhere can be recorded directly
+	factory.ObserveDuration("operation_duration_seconds", 250*time.Millisecond)
+
+	fmt.Printf("Observations: %d\n", timer.Histogram().GetCount())
+
+	// Output:
+	// Observations: 2
+}
@@ -21,14 +21,17 @@ type PrometheusProvider struct {
 	
 	// Metrics storage
 	counters    map[string]*prometheusCounter
-	counterVecs map[string]*prometheusCounterVec
+	counterVecs map[string]metrics.CounterVec
 	gauges      map[string]*prometheusGauge
-	gaugeVecs   map[string]*prometheusGaugeVec
+	gaugeVecs   map[string]metrics.GaugeVec
 	histograms  map[string]*prometheusHistogram
 	histogramVecs map[string]*prometheusHistogramVec
 	summaries   map[string]*prometheusSummary
 	summaryVecs map[string]*prometheusSummaryVec
 	
+	// Shared counter of observations folded by MaxSeries caps
+	overflowVec *prometheus.CounterVec
+	
 	// Synchronization
 	mu sync.RWMutex
 	
@@ -70,9 +73,9 @@ func NewProvider(opts Options) (*PrometheusProvider, error) {
 		subsystem:     opts.Subsystem,
 		constLabels:   constLabels,
 		counters:      make(map[string]*prometheusCounter),
-		counterVecs:   make(map[string]*prometheusCounterVec),
+		counterVecs:   make(map[string]metrics.CounterVec),
 		gauges:        make(map[string]*prometheusGauge),
-		gaugeVecs:     make(map[string]*prometheusGaugeVec),
+		gaugeVecs:     make(map[string]metrics.GaugeVec),
 		histograms:    make(map[string]*prometheusHistogram),
 		histogramVecs: make(map[string]*prometheusHistogramVec),
 		summaries:     make(map[string]*prometheusSummary),
@@ -179,7 +182,17 @@ func (p *PrometheusProvider) NewCounterVec(opts metrics.MetricOptions) (metrics.
 		return nil, fmt.Errorf("failed to register counter vector %s: %w", fqName, err)
 	}
 	
-	counterVec := &prometheusCounterVec{counterVec: promCounterVec}
+	var counterVec metrics.CounterVec = &prometheusCounterVec{counterVec: promCounterVec}
+	if opts.MaxSeries > 0 {
+		overflow, err := p.seriesOverflowCounter(fqName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to register series overflow counter: %w", err)
+		}
+		counterVec = &guardedCounterVec{
+			CounterVec: counterVec,
+			guard:      newSeriesGuard(fqName, opts.Labels, opts.MaxSeries, overflow),
+		}
+	}
 	p.counterVecs[fqName] = counterVec
 	return counterVec, nil
 }
@@ -283,7 +296,17 @@ func (p *PrometheusProvider) NewGaugeVec(opts metrics.MetricOptions) (metrics.Ga
 		return nil, fmt.Errorf("failed to register gauge vector %s: %w", fqName, err)
 	}
 	
-	gaugeVec := &prometheusGaugeVec{gaugeVec: promGaugeVec}
+	var gaugeVec metrics.GaugeVec = &prometheusGaugeVec{gaugeVec: promGaugeVec}
+	if opts.MaxSeries > 0 {
+		overflow, err := p.seriesOverflowCounter(fqName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to register series overflow counter: %w", err)
+		}
+		gaugeVec = &guardedGaugeVec{
+			GaugeVec: gaugeVec,
+			guard:    newSeriesGuard(fqName, opts.Labels, opts.MaxSeries, overflow),
+		}
+	}
 	p.gaugeVecs[fqName] = gaugeVec
 	return gaugeVec, nil
 }
@@ -598,6 +621,64 @@ func (p *PrometheusProvider) Unregister(collector metrics.Collector) error {
 	return nil
 }
 
+// UnregisterMetric unregisters the metric created with the given name and
+// forgets it, so that creating it again starts from an empty series set
+func (p *PrometheusProvider) UnregisterMetric(name string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return metrics.ErrProviderClosed
+	}
+
+	fqName := metrics.BuildFQName(p.namespace, p.subsystem, name)
+
+	var collector prometheus.Collector
+	if counter, exists := p.counters[fqName]; exists {
+		collector = counter.counter
+		delete(p.counters, fqName)
+	} else if counterVec, exists := p.counterVecs[fqName]; exists {
+		if guarded, ok := counterVec.(*guardedCounterVec); ok {
+			counterVec = guarded.CounterVec
+		}
+		collector = counterVec.(*prometheusCounterVec).counterVec
+		delete(p.counterVecs, fqName)
+	} else if gauge, exists := p.gauges[fqName]; exists {
+		collector = gauge.gauge
+		delete(p.gauges, fqName)
+	} else if gaugeVec, exists := p.gaugeVecs[fqName]; exists {
+		if guarded, ok := gaugeVec.(*guardedGaugeVec); ok {
+			gaugeVec = guarded.GaugeVec
+		}
+		collector = gaugeVec.(*prometheusGaugeVec).gaugeVec
+		delete(p.gaugeVecs, fqName)
+	} else if histogram, exists := p.histograms[fqName]; exists {
+		collector = histogram.histogram
+		delete(p.histograms, fqName)
+	} else if histogramVec, exists := p.histogramVecs[fqName]; exists {
+		collector = histogramVec.histogramVec
+		delete(p.histogramVecs, fqName)
+	} else if summary, exists := p.summaries[fqName]; exists {
+		collector = summary.summary
+		delete(p.summaries, fqName)
+	} else if summaryVec, exists := p.summaryVecs[fqName]; exists {
+		collector = summaryVec.summaryVec
+		delete(p.summaryVecs, fqName)
+	} else {
+		return fmt.Errorf("%w: %s", metrics.ErrMetricNotFound, fqName)
+	}
+
+	if p.overflowVec != nil {
+		p.overflowVec.DeleteLabelValues(fqName)
+	}
+
+	if !p.registry.Unregister(collector) {
+		return fmt.Errorf("%w: %s", metrics.ErrNotRegistered, fqName)
+	}
+
+	return nil
+}
+
 // Gather collects all metrics from the registry
 func (p *PrometheusProvider) Gather() ([]*metrics.MetricFamily, error) {
 	p.mu.RLock()
@@ -701,9 +782,9 @@ func (p *PrometheusProvider) Stop(ctx context.Context) error {
 
 	// Clear all metrics
 	p.counters = make(map[string]*prometheusCounter)
-	p.counterVecs = make(map[string]*prometheusCounterVec)
+	p.counterVecs = make(map[string]metrics.CounterVec)
 	p.gauges = make(map[string]*prometheusGauge)
-	p.gaugeVecs = make(map[string]*prometheusGaugeVec)
+	p.gaugeVecs = make(map[string]metrics.GaugeVec)
 	p.histograms = make(map[string]*prometheusHistogram)
 	p.histogramVecs = make(map[string]*prometheusHistogramVec)
 	p.summaries = make(map[string]*prometheusSummary)
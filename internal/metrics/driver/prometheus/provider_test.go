@@ -2,6 +2,7 @@ package prometheus
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -285,6 +286,312 @@ func TestPrometheusProvider_ProviderInfo(t *testing.T) {
 	}
 }
 
+func TestConvenientFactory_Timer(t *testing.T) {
+	provider, err := NewProvider(Options{
+		Namespace: "test",
+		Subsystem: "timer",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+	factory := metrics.NewConvenientFactory(provider)
+
+	timer := factory.Timer("operation_duration_seconds", "Operation duration")
+	if again := factory.Timer("operation_duration_seconds", "Operation duration"); again != timer {
+		t.Error("Expected the same timer for the same name")
+	}
+
+	sleep := 50 * time.Millisecond
+	start := time.Now()
+	done := timer.Start()
+	time.Sleep(sleep)
+	done()
+	elapsed := time.Since(start).Seconds()
+
+	histogram := timer.Histogram()
+	if got := histogram.GetCount(); got != 1 {
+		t.Fatalf("Expected 1 observation, got %d", got)
+	}
+	if got := histogram.GetSum(); got < sleep.Seconds() || got > elapsed {
+		t.Errorf("Expected recorded duration between %f and %f, got %f", sleep.Seconds(), elapsed, got)
+	}
+
+	factory.ObserveDuration("operation_duration_seconds", 2*time.Second)
+	if got := histogram.GetCount(); got != 2 {
+		t.Errorf("Expected 2 observations, got %d", got)
+	}
+	if got := histogram.GetSum(); got < 2 || got > 2+elapsed {
+		t.Errorf("Expected sum to include the observed duration, got %f", got)
+	}
+
+	// ObserveDuration creates the timer on first use
+	factory.ObserveDuration("other_duration_seconds", time.Second)
+	if got := factory.Timer("other_duration_seconds", "").Histogram().GetSum(); got != 1 {
+		t.Errorf("Expected sum 1, got %f", got)
+	}
+}
+
+func TestPrometheusProvider_MaxSeries(t *testing.T) {
+	provider, err := NewProvider(Options{
+		Namespace: "test",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+
+	counterVec, err := provider.NewCounterVec(metrics.MetricOptions{
+		Name:      "requests_total",
+		Help:      "Total requests",
+		Labels:    []string{"client"},
+		MaxSeries: 2,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create counter vector: %v", err)
+	}
+
+	counterVec.WithLabelValues("a").Inc()
+	counterVec.WithLabelValues("b").Inc()
+	counterVec.WithLabelValues("a").Inc()
+	counterVec.WithLabelValues("c").Inc()
+	counterVec.With(map[string]string{"client": "d"}).Inc()
+
+	if got := counterVec.WithLabelValues("a").Get(); got != 2 {
+		t.Errorf("Expected existing series to keep counting, got %f", got)
+	}
+	if got := counterVec.WithLabelValues(OverflowLabelValue).Get(); got != 2 {
+		t.Errorf("Expected 2 observations in overflow series, got %f", got)
+	}
+
+	gaugeVec, err := provider.NewGaugeVec(metrics.MetricOptions{
+		Name:      "connections",
+		Help:      "Open connections",
+		Labels:    []string{"client", "route"},
+		MaxSeries: 1,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create gauge vector: %v", err)
+	}
+	gaugeVec.WithLabelValues("a", "/x").Set(1)
+	gaugeVec.WithLabelValues("b", "/y").Set(5)
+
+	// Deleting a series frees room for a new one
+	counterVec.DeleteLabelValues("b")
+	counterVec.WithLabelValues("e").Inc()
+	if got := counterVec.WithLabelValues("e").Get(); got != 1 {
+		t.Errorf("Expected freed slot to be used by new series, got %f", got)
+	}
+
+	families, err := provider.Gather()
+	if err != nil {
+		t.Fatalf("Failed to gather metrics: %v", err)
+	}
+
+	overflow := make(map[string]float64)
+	series := 0
+	for _, family := range families {
+		switch family.Name {
+		case "test_metrics_series_overflow_total":
+			for _, m := range family.Metrics {
+				overflow[metrics.LabelPairsToLabels(m.Labels)["metric"]] = m.Value
+			}
+		case "test_connections":
+			series = len(family.Metrics)
+		}
+	}
+
+	if overflow["test_requests_total"] != 2 {
+		t.Errorf("Expected overflow count 2 for requests_total, got %f", overflow["test_requests_total"])
+	}
+	if overflow["test_connections"] != 1 {
+		t.Errorf("Expected overflow count 1 for connections, got %f", overflow["test_connections"])
+	}
+	if series != 2 {
+		t.Errorf("Expected 2 connection series (1 regular, 1 overflow), got %d", series)
+	}
+}
+
+func TestPrometheusProvider_DeleteSeries(t *testing.T) {
+	provider, err := NewProvider(Options{
+		Namespace: "test",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+
+	counterVec, err := provider.NewCounterVec(metrics.MetricOptions{
+		Name:   "target_requests_total",
+		Help:   "Requests per target",
+		Labels: []string{"upstream", "target"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create counter vector: %v", err)
+	}
+	counterVec.WithLabelValues("api", "10.0.0.1:80").Inc()
+	counterVec.WithLabelValues("api", "10.0.0.2:80").Inc()
+	counterVec.WithLabelValues("web", "10.0.0.3:80").Inc()
+
+	gauge, err := provider.NewGauge(metrics.MetricOptions{
+		Name: "stale_gauge",
+		Help: "Gauge to unregister",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create gauge: %v", err)
+	}
+	gauge.Set(1)
+
+	if !counterVec.DeleteLabelValues("api", "10.0.0.1:80") {
+		t.Error("Expected DeleteLabelValues to delete existing series")
+	}
+	if deleted := counterVec.DeletePartialMatch(map[string]string{"upstream": "web"}); deleted != 1 {
+		t.Errorf("Expected 1 series deleted by partial match, got %d", deleted)
+	}
+	if err := provider.UnregisterMetric("stale_gauge"); err != nil {
+		t.Fatalf("Failed to unregister gauge: %v", err)
+	}
+	if err := provider.UnregisterMetric("stale_gauge"); !errors.Is(err, metrics.ErrMetricNotFound) {
+		t.Errorf("Expected ErrMetricNotFound, got %v", err)
+	}
+
+	families, err := provider.Gather()
+	if err != nil {
+		t.Fatalf("Failed to gather metrics: %v", err)
+	}
+
+	var targets []string
+	for _, family := range families {
+		switch family.Name {
+		case "test_target_requests_total":
+			for _, m := range family.Metrics {
+				targets = append(targets, metrics.LabelPairsToLabels(m.Labels)["target"])
+			}
+		case "test_stale_gauge":
+			t.Error("Expected unregistered gauge to disappear from gather output")
+		}
+	}
+
+	if len(targets) != 1 || targets[0] != "10.0.0.2:80" {
+		t.Errorf("Expected only target 10.0.0.2:80 to remain, got %v", targets)
+	}
+
+	// Recreating an unregistered metric starts from scratch
+	gauge, err = provider.NewGauge(metrics.MetricOptions{
+		Name: "stale_gauge",
+		Help: "Gauge to unregister",
+	})
+	if err != nil {
+		t.Fatalf("Failed to recreate gauge: %v", err)
+	}
+	if got := gauge.Get(); got != 0 {
+		t.Errorf("Expected recreated gauge to be 0, got %f", got)
+	}
+}
+
+func TestSanitizingProvider(t *testing.T) {
+	base, err := NewProvider(Options{
+		Namespace: "test",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+
+	provider := metrics.NewSanitizingProvider(base, metrics.SanitizeOptions{
+		SensitiveLabels: []string{"token"},
+		HashedLabels:    []string{"email"},
+		MaxLabelLength:  8,
+	})
+
+	counterVec, err := provider.NewCounterVec(metrics.MetricOptions{
+		Name:   "logins_total",
+		Help:   "Total logins",
+		Labels: []string{"token", "email", "route"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create counter vector: %v", err)
+	}
+
+	counterVec.WithLabelValues("secret-token", "dev@example.com", "/api/v1/users").Inc()
+	counterVec.With(map[string]string{
+		"token": "other-token",
+		"email": "dev@example.com",
+		"route": "/api/v1/users",
+	}).Inc()
+
+	families, err := base.Gather()
+	if err != nil {
+		t.Fatalf("Failed to gather metrics: %v", err)
+	}
+
+	var found []metrics.Metric
+	for _, family := range families {
+		if family.Name == "test_logins_total" {
+			found = family.Metrics
+		}
+	}
+
+	if len(found) != 1 {
+		t.Fatalf("Expected both observations in a single series, got %d", len(found))
+	}
+	labels := metrics.LabelPairsToLabels(found[0].Labels)
+	if labels["token"] != "" {
+		t.Errorf("Expected sensitive label to be emptied, got %s", labels["token"])
+	}
+	if labels["email"] == "" || strings.Contains(labels["email"], "@") {
+		t.Errorf("Expected hashed email label, got %s", labels["email"])
+	}
+	if labels["route"] != "/api/v1/" {
+		t.Errorf("Expected route truncated to /api/v1/, got %s", labels["route"])
+	}
+	if found[0].Value != 2 {
+		t.Errorf("Expected value 2, got %f", found[0].Value)
+	}
+
+	if metrics.NewSanitizingProvider(base, metrics.SanitizeOptions{}) != metrics.Provider(base) {
+		t.Error("Expected provider to be returned unchanged without sanitize options")
+	}
+}
+
+func TestSanitizingProvider_MultiByteTruncation(t *testing.T) {
+	base, err := NewProvider(Options{
+		Namespace: "test",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+
+	provider := metrics.NewSanitizingProvider(base, metrics.SanitizeOptions{
+		MaxLabelLength: 8,
+	})
+
+	counterVec, err := provider.NewCounterVec(metrics.MetricOptions{
+		Name:   "requests_total",
+		Help:   "Total requests",
+		Labels: []string{"route"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create counter vector: %v", err)
+	}
+
+	// The 8 byte limit falls inside the 3 bytes of 列
+	counterVec.WithLabelValues("/商品列表").Inc()
+
+	families, err := base.Gather()
+	if err != nil {
+		t.Fatalf("Failed to gather metrics: %v", err)
+	}
+
+	for _, family := range families {
+		if family.Name != "test_requests_total" {
+			continue
+		}
+		labels := metrics.LabelPairsToLabels(family.Metrics[0].Labels)
+		if labels["route"] != "/商品" {
+			t.Errorf("Expected route truncated to /商品, got %q", labels["route"])
+		}
+		return
+	}
+	t.Fatal("Expected the counter to be gathered")
+}
+
 func BenchmarkPrometheusProvider_CounterInc(b *testing.B) {
 	provider, err := NewProvider(Options{})
 	if err != nil {
@@ -1,16 +1,21 @@
 package middleware
 
 import (
+	"bufio"
 	"fmt"
 	"io"
+	"math/rand"
 	"net"
 	"net/http"
 	"os"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/songzhibin97/stargate/internal/clientip"
 	"github.com/songzhibin97/stargate/internal/config"
+	"github.com/songzhibin97/stargate/internal/decision"
 	"github.com/songzhibin97/stargate/pkg/log"
 )
 
@@ -19,6 +24,9 @@ type AccessLogMiddleware struct {
 	config *config.AccessLogConfig
 	writer io.Writer
 	mu     sync.RWMutex
+
+	// routeMatcher returns the ID of the route matching a request
+	routeMatcher func(r *http.Request) string
 }
 
 // AccessLogEntry represents a structured access log entry
@@ -38,12 +46,22 @@ type AccessLogEntry struct {
 	Referer     string  `json:"referer,omitempty"`
 	XForwardedFor string `json:"x_forwarded_for,omitempty"`
 	XRealIP     string  `json:"x_real_ip,omitempty"`
+
+	// Decision of the middleware that rejected the request, if any
+	Decision           string            `json:"decision,omitempty"`
+	DecisionMiddleware string            `json:"decision_middleware,omitempty"`
+	DecisionReason     string            `json:"decision_reason,omitempty"`
+	DecisionFields     map[string]string `json:"decision_fields,omitempty"`
+
+	// Extra fields of the route's access log configuration
+	Fields map[string]string `json:"fields,omitempty"`
 }
 
 // accessLogResponseWrapper wraps http.ResponseWriter to capture response details
 type accessLogResponseWrapper struct {
 	http.ResponseWriter
 	statusCode   int
+	requestSize  int64 // Set once the request is served
 	responseSize int64
 	wroteHeader  bool
 }
@@ -65,6 +83,25 @@ func (rw *accessLogResponseWrapper) Write(b []byte) (int, error) {
 	return n, err
 }
 
+// Flush implements http.Flusher, so streamed responses reach the client as
+// they are written
+func (rw *accessLogResponseWrapper) Flush() {
+	if !rw.wroteHeader {
+		rw.WriteHeader(http.StatusOK)
+	}
+	if flusher, ok := rw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack implements http.Hijacker. Bytes of hijacked connections aren't counted.
+func (rw *accessLogResponseWrapper) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if hijacker, ok := rw.ResponseWriter.(http.Hijacker); ok {
+		return hijacker.Hijack()
+	}
+	return nil, nil, fmt.Errorf("response writer does not support hijacking")
+}
+
 // NewAccessLogMiddleware creates a new access log middleware
 func NewAccessLogMiddleware(cfg *config.AccessLogConfig) (*AccessLogMiddleware, error) {
 	if cfg == nil {
@@ -102,13 +139,26 @@ func (m *AccessLogMiddleware) Handler() func(http.Handler) http.Handler {
 				return
 			}
 
+			// Skip excluded paths and requests of routes not logged or not sampled
+			routeID := m.routeID(r)
+			routeConfig := m.config.Routes[routeID]
+			if m.excluded(r) || !sampleAccessLog(routeConfig) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
 			start := time.Now()
 
-			// Wrap response writer to capture response details
+			// Let rejecting middlewares record their decision
+			ctx, recorder := decision.WithRecorder(r.Context())
+			r = r.WithContext(ctx)
+
+			// Wrap response writer and request body to capture their sizes
 			wrapper := &accessLogResponseWrapper{
 				ResponseWriter: w,
 				statusCode:     http.StatusOK,
 			}
+			r, requestBody := CountRequestBody(r)
 
 			// Process request
 			next.ServeHTTP(wrapper, r)
@@ -117,7 +167,18 @@ func (m *AccessLogMiddleware) Handler() func(http.Handler) http.Handler {
 			latency := time.Since(start)
 
 			// Create log entry
+			wrapper.requestSize = requestSize(r, requestBody)
 			entry := m.createLogEntry(r, wrapper, latency)
+			if routeID != "" {
+				entry.RouteID = routeID
+			}
+			entry.Fields = routeConfig.Fields
+			if d, ok := recorder.Last(); ok {
+				entry.Decision = d.Outcome
+				entry.DecisionMiddleware = d.Middleware
+				entry.DecisionReason = d.Reason
+				entry.DecisionFields = d.Fields
+			}
 
 			// Write log entry
 			m.writeLogEntry(entry)
@@ -125,6 +186,49 @@ func (m *AccessLogMiddleware) Handler() func(http.Handler) http.Handler {
 	}
 }
 
+// SetRouteMatcher sets the function returning the ID of the route matching a
+// request, which selects the route's access log configuration. Without a
+// matcher the route ID is taken from the request context.
+func (m *AccessLogMiddleware) SetRouteMatcher(matcher func(r *http.Request) string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.routeMatcher = matcher
+}
+
+// routeID returns the ID of the route matching the request, or ""
+func (m *AccessLogMiddleware) routeID(r *http.Request) string {
+	m.mu.RLock()
+	matcher := m.routeMatcher
+	m.mu.RUnlock()
+
+	if matcher != nil {
+		return matcher(r)
+	}
+	routeID, _ := r.Context().Value("route_id").(string)
+	return routeID
+}
+
+// excluded reports whether the request's path is never logged
+func (m *AccessLogMiddleware) excluded(r *http.Request) bool {
+	for _, prefix := range m.config.ExcludePaths {
+		if strings.HasPrefix(r.URL.Path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// sampleAccessLog reports whether a request of the route is logged
+func sampleAccessLog(route config.AccessLogRouteConfig) bool {
+	if route.Enabled != nil && !*route.Enabled {
+		return false
+	}
+	if route.SampleRate == nil || *route.SampleRate >= 1.0 {
+		return true
+	}
+	return rand.Float64() < *route.SampleRate
+}
+
 // createLogEntry creates a structured log entry from request and response data
 func (m *AccessLogMiddleware) createLogEntry(r *http.Request, wrapper *accessLogResponseWrapper, latency time.Duration) *AccessLogEntry {
 	entry := &AccessLogEntry{
@@ -135,7 +239,7 @@ func (m *AccessLogMiddleware) createLogEntry(r *http.Request, wrapper *accessLog
 		StatusCode:   wrapper.statusCode,
 		LatencyMs:    latency.Nanoseconds() / 1000000, // Convert to milliseconds
 		UserAgent:    r.UserAgent(),
-		RequestSize:  r.ContentLength,
+		RequestSize:  wrapper.requestSize,
 		ResponseSize: wrapper.responseSize,
 		Protocol:     r.Proto,
 		Host:         r.Host,
@@ -165,30 +269,9 @@ func (m *AccessLogMiddleware) createLogEntry(r *http.Request, wrapper *accessLog
 	return entry
 }
 
-// getClientIP extracts the real client IP from the request
+// getClientIP returns the client IP resolved for the request
 func (m *AccessLogMiddleware) getClientIP(r *http.Request) string {
-	// Check X-Forwarded-For header first
-	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
-		// X-Forwarded-For can contain multiple IPs, take the first one
-		if ips := strings.Split(xff, ","); len(ips) > 0 {
-			ip := strings.TrimSpace(ips[0])
-			if ip != "" {
-				return ip
-			}
-		}
-	}
-
-	// Check X-Real-IP header
-	if xri := r.Header.Get("X-Real-IP"); xri != "" {
-		return strings.TrimSpace(xri)
-	}
-
-	// Fall back to RemoteAddr
-	if ip, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
-		return ip
-	}
-
-	return r.RemoteAddr
+	return clientip.FromRequest(r)
 }
 
 // writeLogEntry writes the log entry to the configured output
@@ -202,17 +285,35 @@ func (m *AccessLogMiddleware) writeLogEntry(entry *AccessLogEntry) {
 	switch m.config.Format {
 	case "json", "":
 		// JSON format (default) - use structured logging
-		logger.Info("Access log entry",
+		fields := []log.Field{
 			log.String("client_ip", entry.ClientIP),
 			log.String("method", entry.Method),
 			log.String("path", entry.Path),
 			log.String("protocol", entry.Protocol),
 			log.Int("status_code", entry.StatusCode),
+			log.Int64("request_size", entry.RequestSize),
 			log.Int64("response_size", entry.ResponseSize),
 			log.String("referer", entry.Referer),
 			log.String("user_agent", entry.UserAgent),
 			log.Int64("latency_ms", entry.LatencyMs),
-		)
+		}
+		if entry.Decision != "" {
+			fields = append(fields,
+				log.String("decision", entry.Decision),
+				log.String("decision_middleware", entry.DecisionMiddleware),
+				log.String("decision_reason", entry.DecisionReason),
+			)
+			for _, key := range sortedFieldKeys(entry.DecisionFields) {
+				fields = append(fields, log.String("decision_"+key, entry.DecisionFields[key]))
+			}
+		}
+		if entry.RouteID != "" {
+			fields = append(fields, log.String("route_id", entry.RouteID))
+		}
+		for _, key := range sortedFieldKeys(entry.Fields) {
+			fields = append(fields, log.String(key, entry.Fields[key]))
+		}
+		logger.Info("Access log entry", fields...)
 	case "combined":
 		// Apache Combined Log Format
 		logLine := fmt.Sprintf("%s - - [%s] \"%s %s %s\" %d %d \"%s\" \"%s\"",
@@ -226,7 +327,7 @@ func (m *AccessLogMiddleware) writeLogEntry(entry *AccessLogEntry) {
 			entry.Referer,
 			entry.UserAgent,
 		)
-		fmt.Fprintln(m.writer, logLine)
+		fmt.Fprintln(m.writer, logLine+decisionSuffix(entry)+fieldsSuffix(entry))
 	case "common":
 		// Apache Common Log Format
 		logLine := fmt.Sprintf("%s - - [%s] \"%s %s %s\" %d %d",
@@ -238,8 +339,46 @@ func (m *AccessLogMiddleware) writeLogEntry(entry *AccessLogEntry) {
 			entry.StatusCode,
 			entry.ResponseSize,
 		)
-		fmt.Fprintln(m.writer, logLine)
+		fmt.Fprintln(m.writer, logLine+decisionSuffix(entry)+fieldsSuffix(entry))
+	}
+}
+
+// decisionSuffix formats the decision of the entry as key=value pairs
+// appended to text log lines, or "" if no middleware rejected the request
+func decisionSuffix(entry *AccessLogEntry) string {
+	if entry.Decision == "" {
+		return ""
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, " decision=%s middleware=%s reason=%q", entry.Decision, entry.DecisionMiddleware, entry.DecisionReason)
+	for _, key := range sortedFieldKeys(entry.DecisionFields) {
+		fmt.Fprintf(&b, " %s=%q", key, entry.DecisionFields[key])
+	}
+	return b.String()
+}
+
+// fieldsSuffix formats the route's extra fields of the entry as key=value
+// pairs appended to text log lines
+func fieldsSuffix(entry *AccessLogEntry) string {
+	var b strings.Builder
+	for _, key := range sortedFieldKeys(entry.Fields) {
+		fmt.Fprintf(&b, " %s=%q", key, entry.Fields[key])
+	}
+	return b.String()
+}
+
+// sortedFieldKeys returns the keys of the fields with a value, sorted for
+// stable output
+func sortedFieldKeys(fields map[string]string) []string {
+	keys := make([]string, 0, len(fields))
+	for key, value := range fields {
+		if value != "" {
+			keys = append(keys, key)
+		}
 	}
+	sort.Strings(keys)
+	return keys
 }
 
 // Close closes the middleware and any associated resources
@@ -9,7 +9,9 @@ import (
 	"testing"
 	"time"
 
+	"github.com/songzhibin97/stargate/internal/clientip"
 	"github.com/songzhibin97/stargate/internal/config"
+	"github.com/songzhibin97/stargate/internal/decision"
 )
 
 func TestAccessLogMiddleware_Handler(t *testing.T) {
@@ -138,10 +140,94 @@ func TestAccessLogMiddleware_Handler(t *testing.T) {
 	}
 }
 
+func TestAccessLogMiddleware_Decision(t *testing.T) {
+	var logBuffer bytes.Buffer
+	middleware := &AccessLogMiddleware{
+		config: &config.AccessLogConfig{Enabled: true, Format: "common"},
+		writer: &logBuffer,
+	}
+
+	handler := middleware.Handler()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		decision.Record(r.Context(), decision.Decision{
+			Middleware: "ratelimit",
+			Outcome:    decision.OutcomeRateLimited,
+			Reason:     "quota_exceeded",
+			Fields:     map[string]string{"limiter": "default", "remaining": "0", "identifier": ""},
+		})
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/orders", nil))
+
+	expected := `429 0 decision=rate_limited middleware=ratelimit reason="quota_exceeded" limiter="default" remaining="0"` + "\n"
+	if !strings.HasSuffix(logBuffer.String(), expected) {
+		t.Errorf("Expected log line to end with %q, got %q", expected, logBuffer.String())
+	}
+
+	// Requests without a decision keep the plain format
+	logBuffer.Reset()
+	middleware.Handler()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).
+		ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/orders", nil))
+	if strings.Contains(logBuffer.String(), "decision=") {
+		t.Errorf("Expected no decision in log line, got %q", logBuffer.String())
+	}
+}
+
+func TestAccessLogMiddleware_PerRoute(t *testing.T) {
+	never := 0.0
+	disabled := false
+
+	var logBuffer bytes.Buffer
+	middleware := &AccessLogMiddleware{
+		config: &config.AccessLogConfig{
+			Enabled:      true,
+			Format:       "common",
+			ExcludePaths: []string{"/healthz"},
+			Routes: map[string]config.AccessLogRouteConfig{
+				"noisy":    {SampleRate: &never},
+				"health":   {Enabled: &disabled},
+				"payments": {Fields: map[string]string{"team": "billing"}},
+			},
+		},
+		writer: &logBuffer,
+	}
+	middleware.SetRouteMatcher(func(r *http.Request) string {
+		return strings.TrimPrefix(r.URL.Path, "/")
+	})
+	handler := middleware.Handler()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	send := func(path string, times int) []string {
+		logBuffer.Reset()
+		for i := 0; i < times; i++ {
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+		}
+		return strings.Split(strings.TrimSpace(logBuffer.String()), "\n")
+	}
+
+	for _, path := range []string{"/noisy", "/health", "/healthz"} {
+		if lines := send(path, 10); lines[0] != "" {
+			t.Errorf("Expected no requests to %s to be logged, got %d lines", path, len(lines))
+		}
+	}
+
+	if lines := send("/orders", 10); len(lines) != 10 {
+		t.Errorf("Expected every request of a route without overrides to be logged, got %d lines", len(lines))
+	}
+
+	lines := send("/payments", 1)
+	if len(lines) != 1 || !strings.HasSuffix(lines[0], ` team="billing"`) {
+		t.Errorf("Expected the route's fields in the log line, got %q", lines)
+	}
+}
+
 func TestAccessLogMiddleware_GetClientIP(t *testing.T) {
 	middleware := &AccessLogMiddleware{
 		config: &config.AccessLogConfig{Enabled: true},
 	}
+	resolver, err := clientip.NewResolver([]string{"10.0.0.0/8", "172.16.0.0/12"})
+	if err != nil {
+		t.Fatalf("Failed to create resolver: %v", err)
+	}
 
 	tests := []struct {
 		name       string
@@ -167,6 +253,12 @@ func TestAccessLogMiddleware_GetClientIP(t *testing.T) {
 			remoteAddr: "10.0.0.1:12345",
 			expected:   "203.0.113.1",
 		},
+		{
+			name:       "X-Forwarded-For from untrusted source",
+			headers:    map[string]string{"X-Forwarded-For": "192.168.1.100"},
+			remoteAddr: "198.51.100.1:54321",
+			expected:   "198.51.100.1",
+		},
 		{
 			name:       "RemoteAddr fallback",
 			headers:    map[string]string{},
@@ -189,6 +281,7 @@ func TestAccessLogMiddleware_GetClientIP(t *testing.T) {
 			for key, value := range tt.headers {
 				req.Header.Set(key, value)
 			}
+			req = resolver.WithClientIP(req)
 
 			result := middleware.getClientIP(req)
 			if result != tt.expected {
@@ -251,3 +344,28 @@ func TestAccessLogMiddleware_NewAccessLogMiddleware(t *testing.T) {
 		})
 	}
 }
+
+func TestAccessLogMiddleware_StreamedResponse(t *testing.T) {
+	var logBuffer bytes.Buffer
+	middleware := &AccessLogMiddleware{
+		config: &config.AccessLogConfig{Enabled: true, Format: "common"},
+		writer: &logBuffer,
+	}
+
+	handler := middleware.Handler()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for i := 0; i < 4; i++ {
+			w.Write([]byte("chunk\n"))
+			w.(http.Flusher).Flush()
+		}
+	}))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/stream", nil))
+
+	if !rr.Flushed {
+		t.Error("Expected flushes to reach the client")
+	}
+	if !strings.HasSuffix(logBuffer.String(), `"GET /stream HTTP/1.1" 200 24`+"\n") {
+		t.Errorf("Expected the log line to count 24 response bytes, got %q", logBuffer.String())
+	}
+}
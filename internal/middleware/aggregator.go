@@ -4,26 +4,52 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"errors"
 	"io"
 	"log"
 	"net/http"
+	"net/url"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/songzhibin97/stargate/internal/config"
+	"github.com/songzhibin97/stargate/internal/governance/circuitbreaker"
 )
 
+// errUpstreamCircuitOpen is the error of an upstream request skipped because
+// the upstream's circuit breaker is open
+var errUpstreamCircuitOpen = errors.New("circuit breaker is open")
+
+// Reasons reported in the _meta block for parts served from the fallback cache
+const (
+	staleReasonCircuitOpen   = "circuit_open"
+	staleReasonUpstreamError = "upstream_error"
+)
+
+// aggregatorMetaKey is the key of the response metadata block
+const aggregatorMetaKey = "_meta"
+
 // AggregatorMiddleware represents the API aggregator middleware
 type AggregatorMiddleware struct {
 	config *config.AggregatorConfig
 	client *http.Client
 	mutex  sync.RWMutex
 
+	// Last-known-good upstream responses served in place of failing upstreams
+	cache    *ResponseCache
+	maxStale time.Duration
+
+	// Per-upstream circuit breakers, keyed by upstream host
+	breakerConfig *circuitbreaker.Config
+	breakers      map[string]*circuitbreaker.CircuitBreaker
+	breakersMutex sync.Mutex
+
 	// Statistics
 	totalRequests     int64
 	aggregatedRequests int64
 	failedRequests    int64
+	staleResponses    int64
 }
 
 // UpstreamRequest represents a single upstream request configuration
@@ -34,6 +60,10 @@ type UpstreamRequest struct {
 	Headers  map[string]string `yaml:"headers,omitempty" json:"headers,omitempty"`
 	Timeout  time.Duration     `yaml:"timeout,omitempty" json:"timeout,omitempty"`
 	Required bool              `yaml:"required,omitempty" json:"required,omitempty"`
+
+	// FallbackCacheKey names the cache entry holding the last successful body
+	// of this upstream, served as a stale part when the upstream fails
+	FallbackCacheKey string `yaml:"fallback_cache_key,omitempty" json:"fallback_cache_key,omitempty"`
 }
 
 // AggregateRoute represents a single aggregate route configuration
@@ -54,6 +84,11 @@ type UpstreamResult struct {
 	Headers    http.Header
 	Error      error
 	Duration   time.Duration
+
+	// Stale is set when the body was served from the fallback cache
+	// because the upstream failed, with the reason in StaleReason
+	Stale       *CachedResponse
+	StaleReason string
 }
 
 // AggregatedResponse represents the final aggregated response
@@ -74,10 +109,42 @@ func NewAggregatorMiddleware(cfg *config.AggregatorConfig) *AggregatorMiddleware
 		},
 	}
 
-	return &AggregatorMiddleware{
-		config: cfg,
-		client: client,
+	m := &AggregatorMiddleware{
+		config:   cfg,
+		client:   client,
+		cache:    NewResponseCache(cfg.FallbackCache.MaxEntries),
+		maxStale: cfg.FallbackCache.MaxStale,
+		breakers: make(map[string]*circuitbreaker.CircuitBreaker),
+	}
+	if m.maxStale <= 0 {
+		m.maxStale = defaultFallbackMaxStale
+	}
+
+	if cfg.CircuitBreaker.Enabled {
+		m.breakerConfig = &circuitbreaker.Config{
+			FailureThreshold:         cfg.CircuitBreaker.FailureThreshold,
+			RecoveryTimeout:          cfg.CircuitBreaker.RecoveryTimeout,
+			RequestVolumeThreshold:   cfg.CircuitBreaker.RequestVolumeThreshold,
+			ErrorPercentageThreshold: cfg.CircuitBreaker.ErrorPercentageThreshold,
+			MaxHalfOpenRequests:      1,
+			SuccessThreshold:         1,
+		}
+		defaults := circuitbreaker.DefaultConfig()
+		if m.breakerConfig.FailureThreshold <= 0 {
+			m.breakerConfig.FailureThreshold = defaults.FailureThreshold
+		}
+		if m.breakerConfig.RecoveryTimeout <= 0 {
+			m.breakerConfig.RecoveryTimeout = defaults.RecoveryTimeout
+		}
+		if m.breakerConfig.RequestVolumeThreshold <= 0 {
+			m.breakerConfig.RequestVolumeThreshold = defaults.RequestVolumeThreshold
+		}
+		if m.breakerConfig.ErrorPercentageThreshold <= 0 {
+			m.breakerConfig.ErrorPercentageThreshold = defaults.ErrorPercentageThreshold
+		}
 	}
+
+	return m
 }
 
 // Handler returns the HTTP middleware handler
@@ -134,6 +201,8 @@ func (m *AggregatorMiddleware) matchAggregateRoute(r *http.Request) *AggregateRo
 					Headers:  upstreamReq.Headers,
 					Timeout:  upstreamReq.Timeout,
 					Required: upstreamReq.Required,
+
+					FallbackCacheKey: upstreamReq.FallbackCacheKey,
 				}
 			}
 
@@ -176,6 +245,9 @@ func (m *AggregatorMiddleware) handleAggregateRequest(w http.ResponseWriter, r *
 	// Execute upstream requests in parallel
 	results := m.executeUpstreamRequests(ctx, route.UpstreamRequests)
 
+	// Remember successful parts and replace failed ones with cached bodies
+	m.applyFallbackCache(results, route.UpstreamRequests)
+
 	// Check if any required requests failed
 	if m.hasRequiredFailures(results, route.UpstreamRequests) {
 		m.updateFailedRequests()
@@ -222,11 +294,25 @@ func (m *AggregatorMiddleware) executeUpstreamRequest(ctx context.Context, upstr
 		Name: upstreamReq.Name,
 	}
 
+	breaker := m.getCircuitBreaker(upstreamReq)
+
 	defer func() {
 		result.Duration = time.Since(start)
+		if breaker != nil && result.Error != errUpstreamCircuitOpen {
+			if upstreamFailed(result) {
+				breaker.RecordFailure()
+			} else {
+				breaker.RecordSuccess()
+			}
+		}
 		results <- result
 	}()
 
+	if breaker != nil && !breaker.CanExecute() {
+		result.Error = errUpstreamCircuitOpen
+		return
+	}
+
 	// Create HTTP request
 	req, err := http.NewRequestWithContext(ctx, upstreamReq.Method, upstreamReq.URL, nil)
 	if err != nil {
@@ -259,6 +345,85 @@ func (m *AggregatorMiddleware) executeUpstreamRequest(ctx context.Context, upstr
 	result.Headers = resp.Header
 }
 
+// getCircuitBreaker returns the circuit breaker of the upstream host, or nil
+// if circuit breaking is disabled for the aggregator
+func (m *AggregatorMiddleware) getCircuitBreaker(upstreamReq UpstreamRequest) *circuitbreaker.CircuitBreaker {
+	if m.breakerConfig == nil {
+		return nil
+	}
+
+	name := upstreamReq.Name
+	if u, err := url.Parse(upstreamReq.URL); err == nil && u.Host != "" {
+		name = u.Host
+	}
+
+	m.breakersMutex.Lock()
+	defer m.breakersMutex.Unlock()
+
+	breaker, exists := m.breakers[name]
+	if !exists {
+		config := *m.breakerConfig
+		breaker = circuitbreaker.New(name, &config)
+		m.breakers[name] = breaker
+	}
+	return breaker
+}
+
+// upstreamFailed reports whether an upstream result counts as a failure for
+// circuit breaking and fallback: a transport error or a 5xx response
+func upstreamFailed(result *UpstreamResult) bool {
+	return result.Error != nil || result.StatusCode >= 500
+}
+
+// applyFallbackCache stores the body of each successful upstream request that
+// has a fallback cache key, and replaces failed, breaker-open or timed out
+// parts with the last cached body, if one exists that is no older than the
+// configured maximum staleness. Replaced parts keep their original error for
+// logging but are marked stale, so they count as successful for required
+// upstream checks and are reported in the _meta block of the response.
+func (m *AggregatorMiddleware) applyFallbackCache(results map[string]*UpstreamResult, upstreamRequests []UpstreamRequest) {
+	for _, upstreamReq := range upstreamRequests {
+		if upstreamReq.FallbackCacheKey == "" {
+			continue
+		}
+
+		result, exists := results[upstreamReq.Name]
+		if exists && !upstreamFailed(result) {
+			if result.StatusCode >= 200 && result.StatusCode < 300 {
+				m.cache.Store(upstreamReq.FallbackCacheKey, result.StatusCode, result.Body, result.Headers)
+			}
+			continue
+		}
+
+		cached, found := m.cache.Lookup(upstreamReq.FallbackCacheKey, m.maxStale)
+		if !found {
+			continue
+		}
+
+		if !exists {
+			result = &UpstreamResult{
+				Name:  upstreamReq.Name,
+				Error: errors.New("upstream request timed out"),
+			}
+			results[upstreamReq.Name] = result
+		}
+
+		result.StaleReason = staleReasonUpstreamError
+		if errors.Is(result.Error, errUpstreamCircuitOpen) {
+			result.StaleReason = staleReasonCircuitOpen
+		}
+		log.Printf("Serving cached %s response for upstream %s (%s, age %s)",
+			upstreamReq.FallbackCacheKey, upstreamReq.Name, result.StaleReason, cached.Age().Round(time.Second))
+
+		result.Stale = cached
+		result.StatusCode = cached.StatusCode
+		result.Body = cached.Body
+		result.Headers = cached.Headers
+		result.Error = nil
+		m.updateStaleResponses()
+	}
+}
+
 // hasRequiredFailures checks if any required upstream requests failed
 func (m *AggregatorMiddleware) hasRequiredFailures(results map[string]*UpstreamResult, upstreamRequests []UpstreamRequest) bool {
 	for _, upstreamReq := range upstreamRequests {
@@ -275,9 +440,19 @@ func (m *AggregatorMiddleware) hasRequiredFailures(results map[string]*UpstreamR
 // mergeResponses merges upstream responses into a single response
 func (m *AggregatorMiddleware) mergeResponses(results map[string]*UpstreamResult, template string) *AggregatedResponse {
 	responseData := make(map[string]interface{})
+	meta := make(map[string]interface{})
 
 	// Process each upstream result
 	for name, result := range results {
+		if result.Stale != nil {
+			meta[name] = map[string]interface{}{
+				"stale":       true,
+				"reason":      result.StaleReason,
+				"cached_at":   result.Stale.CachedAt.UTC().Format(time.RFC3339),
+				"age_seconds": int64(result.Stale.Age().Seconds()),
+			}
+		}
+
 		if result.Error != nil {
 			responseData[name] = map[string]interface{}{
 				"error":  result.Error.Error(),
@@ -295,6 +470,11 @@ func (m *AggregatorMiddleware) mergeResponses(results map[string]*UpstreamResult
 		}
 	}
 
+	// Report parts served from the fallback cache
+	if len(meta) > 0 {
+		responseData[aggregatorMetaKey] = meta
+	}
+
 	// Apply template if provided (simplified implementation)
 	if template != "" {
 		// For now, just return the template with data substitution
@@ -371,6 +551,17 @@ func (m *AggregatorMiddleware) updateFailedRequests() {
 	m.failedRequests++
 }
 
+func (m *AggregatorMiddleware) updateStaleResponses() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.staleResponses++
+}
+
+// FlushCache removes all cached fallback responses and returns how many were removed
+func (m *AggregatorMiddleware) FlushCache() int {
+	return m.cache.Flush()
+}
+
 // GetStats returns middleware statistics
 func (m *AggregatorMiddleware) GetStats() map[string]interface{} {
 	m.mutex.RLock()
@@ -380,6 +571,8 @@ func (m *AggregatorMiddleware) GetStats() map[string]interface{} {
 		"total_requests":      m.totalRequests,
 		"aggregated_requests": m.aggregatedRequests,
 		"failed_requests":     m.failedRequests,
+		"stale_responses":     m.staleResponses,
+		"fallback_cache":      m.cache.Stats(),
 		"success_rate":        float64(m.aggregatedRequests-m.failedRequests) / float64(m.aggregatedRequests) * 100,
 	}
 }
@@ -0,0 +1,153 @@
+package middleware
+
+import (
+	"container/list"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultFallbackCacheSize is the default maximum number of cached upstream bodies
+	defaultFallbackCacheSize = 1000
+
+	// defaultFallbackMaxStale is the default maximum age of a body served as fallback
+	defaultFallbackMaxStale = time.Hour
+)
+
+// CachedResponse is a last-known-good upstream response
+type CachedResponse struct {
+	StatusCode int
+	Body       []byte
+	Headers    http.Header
+	CachedAt   time.Time
+}
+
+// Age returns how long ago the response was cached
+func (c *CachedResponse) Age() time.Duration {
+	return time.Since(c.CachedAt)
+}
+
+// ResponseCache is a size-bounded LRU cache of successful upstream responses,
+// keyed by the fallback cache key of the upstream request that produced them.
+// Entries are not expired on write; readers decide how stale a response may be.
+type ResponseCache struct {
+	maxEntries int
+
+	mutex   sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List
+
+	// Statistics
+	hits      int64
+	misses    int64
+	evictions int64
+}
+
+// responseCacheEntry is a cached upstream response
+type responseCacheEntry struct {
+	key      string
+	response *CachedResponse
+}
+
+// NewResponseCache creates a response cache holding at most maxEntries responses
+func NewResponseCache(maxEntries int) *ResponseCache {
+	if maxEntries <= 0 {
+		maxEntries = defaultFallbackCacheSize
+	}
+	return &ResponseCache{
+		maxEntries: maxEntries,
+		entries:    make(map[string]*list.Element),
+		lru:        list.New(),
+	}
+}
+
+// Lookup returns a copy of the response cached under key if it is no older
+// than maxStale. A zero maxStale accepts responses of any age.
+func (c *ResponseCache) Lookup(key string, maxStale time.Duration) (*CachedResponse, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	element, exists := c.entries[key]
+	if !exists {
+		c.misses++
+		return nil, false
+	}
+
+	entry := element.Value.(*responseCacheEntry)
+	if maxStale > 0 && entry.response.Age() > maxStale {
+		c.misses++
+		return nil, false
+	}
+
+	c.lru.MoveToFront(element)
+	c.hits++
+	return copyCachedResponse(entry.response), true
+}
+
+// Store caches a copy of the response under key, replacing any previous
+// response and evicting the least recently used one when the cache is full
+func (c *ResponseCache) Store(key string, statusCode int, body []byte, headers http.Header) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	entry := &responseCacheEntry{
+		key: key,
+		response: copyCachedResponse(&CachedResponse{
+			StatusCode: statusCode,
+			Body:       body,
+			Headers:    headers,
+			CachedAt:   time.Now(),
+		}),
+	}
+
+	if element, exists := c.entries[key]; exists {
+		element.Value = entry
+		c.lru.MoveToFront(element)
+		return
+	}
+
+	c.entries[key] = c.lru.PushFront(entry)
+
+	for c.lru.Len() > c.maxEntries {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*responseCacheEntry).key)
+		c.evictions++
+	}
+}
+
+// Flush removes all cached responses and returns how many were removed
+func (c *ResponseCache) Flush() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	flushed := c.lru.Len()
+	c.entries = make(map[string]*list.Element)
+	c.lru.Init()
+	return flushed
+}
+
+// Stats returns cache statistics
+func (c *ResponseCache) Stats() map[string]interface{} {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return map[string]interface{}{
+		"hits":      c.hits,
+		"misses":    c.misses,
+		"evictions": c.evictions,
+		"entries":   c.lru.Len(),
+	}
+}
+
+// copyCachedResponse returns a copy of response that can be modified
+// without affecting the cached response
+func copyCachedResponse(response *CachedResponse) *CachedResponse {
+	copied := *response
+	copied.Body = append([]byte(nil), response.Body...)
+	if response.Headers != nil {
+		copied.Headers = response.Headers.Clone()
+	}
+	return &copied
+}
@@ -462,3 +462,103 @@ func TestAggregatorMiddleware_GetStats(t *testing.T) {
 		}
 	}
 }
+
+func TestAggregatorMiddleware_FallbackCache(t *testing.T) {
+	failing := false
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failing {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id": 1}`))
+	}))
+	defer upstream.Close()
+
+	cfg := &config.AggregatorConfig{
+		Enabled:        true,
+		DefaultTimeout: 5 * time.Second,
+		CircuitBreaker: config.CircuitBreakerConfig{
+			Enabled:                true,
+			FailureThreshold:       1,
+			RequestVolumeThreshold: 1,
+			RecoveryTimeout:        time.Minute,
+		},
+		Routes: []config.AggregateRoute{
+			{
+				ID:   "profile",
+				Path: "/aggregated/profile",
+				UpstreamRequests: []config.UpstreamRequest{
+					{
+						Name:             "user",
+						URL:              upstream.URL + "/user",
+						Method:           "GET",
+						Required:         true,
+						FallbackCacheKey: "user-profile",
+					},
+				},
+			},
+		},
+	}
+	m := NewAggregatorMiddleware(cfg)
+	handler := m.Handler()(http.NotFoundHandler())
+
+	get := func() (int, map[string]interface{}) {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", "/aggregated/profile", nil))
+		var body map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &body)
+		return w.Code, body
+	}
+
+	code, body := get()
+	if code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", code)
+	}
+	if _, exists := body["_meta"]; exists {
+		t.Errorf("expected no _meta block for fresh response, got %v", body["_meta"])
+	}
+
+	// The failing upstream trips the breaker and is served from the cache
+	failing = true
+	for _, reason := range []string{staleReasonUpstreamError, staleReasonCircuitOpen} {
+		code, body = get()
+		if code != http.StatusOK {
+			t.Fatalf("expected stale status 200, got %d", code)
+		}
+		user, _ := body["user"].(map[string]interface{})
+		if user["id"] != float64(1) {
+			t.Errorf("expected cached user body, got %v", body["user"])
+		}
+		meta, _ := body["_meta"].(map[string]interface{})
+		part, _ := meta["user"].(map[string]interface{})
+		if part["stale"] != true || part["reason"] != reason {
+			t.Errorf("expected stale part with reason %s, got %v", reason, meta)
+		}
+	}
+
+	// Without a cached body the required upstream fails the aggregate
+	if flushed := m.FlushCache(); flushed != 1 {
+		t.Errorf("expected 1 flushed entry, got %d", flushed)
+	}
+	if code, _ = get(); code != http.StatusBadGateway {
+		t.Errorf("expected status 502 after flush, got %d", code)
+	}
+}
+
+func TestResponseCache_MaxStale(t *testing.T) {
+	cache := NewResponseCache(1)
+	cache.Store("a", 200, []byte("a"), nil)
+
+	if _, found := cache.Lookup("a", time.Nanosecond); found {
+		t.Error("expected response older than max stale to be ignored")
+	}
+	if cached, found := cache.Lookup("a", time.Hour); !found || string(cached.Body) != "a" {
+		t.Errorf("expected cached body a, got %v", cached)
+	}
+
+	cache.Store("b", 200, []byte("b"), nil)
+	if _, found := cache.Lookup("a", 0); found {
+		t.Error("expected least recently used response to be evicted")
+	}
+}
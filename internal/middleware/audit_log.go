@@ -0,0 +1,70 @@
+package middleware
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/songzhibin97/stargate/internal/config"
+)
+
+// AuditLogger writes one JSON line per administrative action, such as
+// overriding the rate limits of a client
+type AuditLogger struct {
+	writer io.Writer
+	mu     sync.Mutex
+}
+
+// AuditEntry represents an administrative action
+type AuditEntry struct {
+	Timestamp string            `json:"timestamp"`
+	Action    string            `json:"action"`
+	Target    string            `json:"target,omitempty"`
+	Caller    string            `json:"caller"`
+	Details   map[string]string `json:"details,omitempty"`
+}
+
+// NewAuditLogger creates an audit logger writing to the configured output,
+// "stdout", "stderr" or a file path, or nil if the audit log is disabled
+func NewAuditLogger(cfg *config.AuditLogConfig) (*AuditLogger, error) {
+	if cfg == nil || !cfg.Enabled {
+		return nil, nil
+	}
+
+	var writer io.Writer
+	switch cfg.Output {
+	case "stdout", "":
+		writer = os.Stdout
+	case "stderr":
+		writer = os.Stderr
+	default:
+		file, err := os.OpenFile(cfg.Output, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open audit log file %s: %w", cfg.Output, err)
+		}
+		writer = file
+	}
+
+	return &AuditLogger{writer: writer}, nil
+}
+
+// Log writes an audit entry, stamping it with the current time. A nil logger
+// discards entries.
+func (l *AuditLogger) Log(entry AuditEntry) {
+	if l == nil {
+		return
+	}
+	entry.Timestamp = time.Now().UTC().Format(time.RFC3339)
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.writer.Write(append(data, '\n'))
+}
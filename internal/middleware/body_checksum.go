@@ -0,0 +1,244 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/songzhibin97/stargate/internal/config"
+)
+
+const defaultBodyChecksumMaxBodySize = 1024 * 1024
+
+// bodyChecksum is a checksum of the request body supplied by the client
+type bodyChecksum struct {
+	header    string // Header carrying the checksum
+	algorithm string
+	expected  []byte
+	hash      hash.Hash
+}
+
+// BodyChecksumMiddleware verifies request bodies against the checksum in
+// their Content-MD5 or Digest header, rejecting tampered bodies before they
+// are forwarded
+type BodyChecksumMiddleware struct {
+	config *config.BodyChecksumConfig
+	routes map[string]bool
+	mu     sync.RWMutex
+
+	// Statistics
+	validated  int64
+	mismatches int64
+	rejected   int64
+}
+
+// NewBodyChecksumMiddleware creates a new body checksum middleware
+func NewBodyChecksumMiddleware(cfg *config.BodyChecksumConfig) *BodyChecksumMiddleware {
+	m := &BodyChecksumMiddleware{}
+	m.UpdateConfig(cfg)
+	return m
+}
+
+// UpdateConfig updates the middleware configuration
+func (m *BodyChecksumMiddleware) UpdateConfig(cfg *config.BodyChecksumConfig) {
+	routes := make(map[string]bool, len(cfg.Routes))
+	for _, routeID := range cfg.Routes {
+		routes[routeID] = true
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.config = cfg
+	m.routes = routes
+}
+
+// settings returns the configuration with defaults applied
+func (m *BodyChecksumMiddleware) settings() config.BodyChecksumConfig {
+	m.mu.RLock()
+	cfg := *m.config
+	m.mu.RUnlock()
+
+	if cfg.MaxBodySize <= 0 {
+		cfg.MaxBodySize = defaultBodyChecksumMaxBodySize
+	}
+	return cfg
+}
+
+// Handler returns the HTTP middleware handler
+func (m *BodyChecksumMiddleware) Handler() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			cfg := m.settings()
+			if !cfg.Enabled || !m.applies(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			checksums, err := parseBodyChecksums(r.Header)
+			if err != nil {
+				m.reject(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			if len(checksums) == 0 {
+				if cfg.Required {
+					m.reject(w, http.StatusBadRequest, "Content-MD5 or Digest header required")
+					return
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if r.ContentLength > cfg.MaxBodySize {
+				m.reject(w, http.StatusRequestEntityTooLarge, "request body too large for checksum validation")
+				return
+			}
+
+			// Hash the body while buffering it for forwarding
+			writers := []io.Writer{}
+			for _, checksum := range checksums {
+				writers = append(writers, checksum.hash)
+			}
+			var body bytes.Buffer
+			writers = append(writers, &body)
+
+			n, err := io.Copy(io.MultiWriter(writers...), io.LimitReader(r.Body, cfg.MaxBodySize+1))
+			if err != nil {
+				m.reject(w, http.StatusBadRequest, "failed to read request body")
+				return
+			}
+			if n > cfg.MaxBodySize {
+				m.reject(w, http.StatusRequestEntityTooLarge, "request body too large for checksum validation")
+				return
+			}
+
+			for _, checksum := range checksums {
+				if subtle.ConstantTimeCompare(checksum.hash.Sum(nil), checksum.expected) != 1 {
+					m.mu.Lock()
+					m.mismatches++
+					m.mu.Unlock()
+					m.writeError(w, http.StatusBadRequest, fmt.Sprintf("request body does not match %s %s checksum", checksum.header, checksum.algorithm))
+					return
+				}
+			}
+
+			m.mu.Lock()
+			m.validated++
+			m.mu.Unlock()
+
+			r.Body = io.NopCloser(bytes.NewReader(body.Bytes()))
+			r.ContentLength = n
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// applies reports whether the request's route is validated
+func (m *BodyChecksumMiddleware) applies(r *http.Request) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if len(m.routes) == 0 && len(m.config.Paths) == 0 {
+		return true
+	}
+	if routeID, ok := r.Context().Value("route_id").(string); ok && m.routes[routeID] {
+		return true
+	}
+	for _, prefix := range m.config.Paths {
+		if strings.HasPrefix(r.URL.Path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseBodyChecksums parses the Content-MD5 header (RFC 1864) and the
+// SHA-256 and MD5 values of the Digest header (RFC 3230). Digest values of
+// other algorithms are ignored, but a Digest header with none supported is
+// an error.
+func parseBodyChecksums(header http.Header) ([]*bodyChecksum, error) {
+	var checksums []*bodyChecksum
+
+	if value := header.Get("Content-MD5"); value != "" {
+		expected, err := base64.StdEncoding.DecodeString(strings.TrimSpace(value))
+		if err != nil || len(expected) != md5.Size {
+			return nil, fmt.Errorf("invalid Content-MD5 header")
+		}
+		checksums = append(checksums, &bodyChecksum{header: "Content-MD5", algorithm: "MD5", expected: expected, hash: md5.New()})
+	}
+
+	digests := header.Values("Digest")
+	if len(digests) == 0 {
+		return checksums, nil
+	}
+
+	supported := 0
+	for _, digest := range digests {
+		for _, instance := range strings.Split(digest, ",") {
+			algorithm, value, found := strings.Cut(strings.TrimSpace(instance), "=")
+			if !found {
+				return nil, fmt.Errorf("invalid Digest header")
+			}
+
+			var h hash.Hash
+			var size int
+			switch strings.ToUpper(algorithm) {
+			case "SHA-256":
+				h, size = sha256.New(), sha256.Size
+			case "MD5":
+				h, size = md5.New(), md5.Size
+			default:
+				continue
+			}
+
+			expected, err := base64.StdEncoding.DecodeString(value)
+			if err != nil || len(expected) != size {
+				return nil, fmt.Errorf("invalid %s value in Digest header", strings.ToUpper(algorithm))
+			}
+			checksums = append(checksums, &bodyChecksum{header: "Digest", algorithm: strings.ToUpper(algorithm), expected: expected, hash: h})
+			supported++
+		}
+	}
+	if supported == 0 {
+		return nil, fmt.Errorf("no supported algorithm in Digest header, expected SHA-256 or MD5")
+	}
+
+	return checksums, nil
+}
+
+// reject counts a request rejected without validation and writes the error
+func (m *BodyChecksumMiddleware) reject(w http.ResponseWriter, statusCode int, message string) {
+	m.mu.Lock()
+	m.rejected++
+	m.mu.Unlock()
+	m.writeError(w, statusCode, message)
+}
+
+// writeError writes a JSON error response
+func (m *BodyChecksumMiddleware) writeError(w http.ResponseWriter, statusCode int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
+
+// GetStats returns middleware statistics
+func (m *BodyChecksumMiddleware) GetStats() map[string]interface{} {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return map[string]interface{}{
+		"enabled":    m.config.Enabled,
+		"validated":  m.validated,
+		"mismatches": m.mismatches,
+		"rejected":   m.rejected,
+	}
+}
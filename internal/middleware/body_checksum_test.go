@@ -0,0 +1,121 @@
+package middleware
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/songzhibin97/stargate/internal/config"
+)
+
+// echoBody echoes the forwarded request body
+func echoBody() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Write(body)
+	})
+}
+
+func sha256Digest(body string) string {
+	sum := sha256.Sum256([]byte(body))
+	return "SHA-256=" + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+func contentMD5(body string) string {
+	sum := md5.Sum([]byte(body))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+func TestBodyChecksumMiddleware(t *testing.T) {
+	body := `{"amount":100}`
+	md5Sum := md5.Sum([]byte(body))
+
+	tests := []struct {
+		name     string
+		header   string
+		value    string
+		body     string
+		expected int
+	}{
+		{"valid SHA-256 digest", "Digest", sha256Digest(body), body, http.StatusOK},
+		{"valid MD5 digest", "Digest", "md5=" + base64.StdEncoding.EncodeToString(md5Sum[:]), body, http.StatusOK},
+		{"valid Content-MD5", "Content-MD5", contentMD5(body), body, http.StatusOK},
+		{"unsupported algorithm ignored", "Digest", "UNIXsum=30637, " + sha256Digest(body), body, http.StatusOK},
+		{"tampered SHA-256 digest", "Digest", sha256Digest(body), `{"amount":999}`, http.StatusBadRequest},
+		{"tampered Content-MD5", "Content-MD5", contentMD5(body), `{"amount":999}`, http.StatusBadRequest},
+		{"only unsupported algorithms", "Digest", "UNIXsum=30637", body, http.StatusBadRequest},
+		{"malformed digest", "Digest", "SHA-256=not-base64!", body, http.StatusBadRequest},
+		{"no checksum", "", "", body, http.StatusOK},
+	}
+
+	middleware := NewBodyChecksumMiddleware(&config.BodyChecksumConfig{Enabled: true})
+	handler := middleware.Handler()(echoBody())
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/payments", strings.NewReader(tt.body))
+			if tt.header != "" {
+				req.Header.Set(tt.header, tt.value)
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if rr.Code != tt.expected {
+				t.Fatalf("Expected status %d, got %d: %s", tt.expected, rr.Code, rr.Body.String())
+			}
+			if rr.Code == http.StatusOK && rr.Body.String() != tt.body {
+				t.Errorf("Expected body to be forwarded intact, got %q", rr.Body.String())
+			}
+		})
+	}
+
+	stats := middleware.GetStats()
+	if stats["validated"] != int64(4) || stats["mismatches"] != int64(2) || stats["rejected"] != int64(2) {
+		t.Errorf("Unexpected stats: %v", stats)
+	}
+}
+
+func TestBodyChecksumMiddleware_Scope(t *testing.T) {
+	middleware := NewBodyChecksumMiddleware(&config.BodyChecksumConfig{
+		Enabled:     true,
+		Paths:       []string{"/payments"},
+		Required:    true,
+		MaxBodySize: 8,
+	})
+	handler := middleware.Handler()(echoBody())
+
+	send := func(path, body, digest string) int {
+		req := httptest.NewRequest("POST", path, strings.NewReader(body))
+		if digest != "" {
+			req.Header.Set("Digest", digest)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	if code := send("/orders", "anything goes", ""); code != http.StatusOK {
+		t.Errorf("Expected paths outside the scope not to be validated, got %d", code)
+	}
+	if code := send("/payments", "small", ""); code != http.StatusBadRequest {
+		t.Errorf("Expected missing checksum to be rejected when required, got %d", code)
+	}
+	if code := send("/payments", "small", sha256Digest("small")); code != http.StatusOK {
+		t.Errorf("Expected valid checksum to be accepted, got %d", code)
+	}
+
+	// Bodies over the limit are rejected, also without a Content-Length
+	req := httptest.NewRequest("POST", "/payments", io.MultiReader(strings.NewReader("too large body")))
+	req.ContentLength = -1
+	req.Header.Set("Digest", sha256Digest("too large body"))
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected body over the limit to be rejected with 413, got %d", rr.Code)
+	}
+}
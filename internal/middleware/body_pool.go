@@ -0,0 +1,124 @@
+package middleware
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+)
+
+// maxPooledBodySize caps the buffers kept for reuse, so one large body
+// doesn't pin its memory in the pool
+const maxPooledBodySize = 1 << 20
+
+// bodyBufferPool holds the buffers bodies are read into
+var bodyBufferPool = sync.Pool{
+	New: func() interface{} {
+		return new(bytes.Buffer)
+	},
+}
+
+// errBodyReleased is returned by readers of a body whose buffer was released
+var errBodyReleased = errors.New("body buffer released")
+
+// pooledBody is a body read into a buffer of the pool. The buffer belongs to
+// the request reading it and must be released when the request is done;
+// readers of the body fail once it is.
+type pooledBody struct {
+	mu  sync.Mutex
+	buf *bytes.Buffer
+}
+
+// readPooledBody reads a body into a buffer of the pool. Callers bound the
+// body with io.LimitReader where needed.
+func readPooledBody(body io.Reader) (*pooledBody, error) {
+	buf := bodyBufferPool.Get().(*bytes.Buffer)
+	if _, err := buf.ReadFrom(body); err != nil {
+		putBodyBuffer(buf)
+		return nil, err
+	}
+	return &pooledBody{buf: buf}, nil
+}
+
+// readBodyString reads a body needed as a string for the rest of its
+// request, such as the bodies passed to WASM plugins and serverless
+// functions. It is read straight into the string, as the string would copy a
+// pooled buffer anyway.
+func readBodyString(body io.Reader) (string, error) {
+	var b strings.Builder
+	if _, err := io.Copy(&b, body); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// Bytes returns the body. The bytes are only valid until the body is released.
+func (b *pooledBody) Bytes() []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.buf == nil {
+		return nil
+	}
+	return b.buf.Bytes()
+}
+
+// Len returns the size of the body
+func (b *pooledBody) Len() int {
+	return len(b.Bytes())
+}
+
+// Reader returns a reader of the body from its start, to restore a request
+// body for the next handlers
+func (b *pooledBody) Reader() io.ReadCloser {
+	return &pooledBodyReader{body: b}
+}
+
+// Release zeroes the buffer, so no data leaks to the next request using it,
+// and returns it to the pool
+func (b *pooledBody) Release() {
+	b.mu.Lock()
+	buf := b.buf
+	b.buf = nil
+	b.mu.Unlock()
+
+	if buf != nil {
+		putBodyBuffer(buf)
+	}
+}
+
+// putBodyBuffer zeroes a buffer and returns it to the pool, unless too large
+func putBodyBuffer(buf *bytes.Buffer) {
+	if buf.Cap() > maxPooledBodySize {
+		return
+	}
+	clear(buf.Bytes())
+	buf.Reset()
+	bodyBufferPool.Put(buf)
+}
+
+// pooledBodyReader reads a pooled body until it is released
+type pooledBodyReader struct {
+	body   *pooledBody
+	offset int
+}
+
+func (r *pooledBodyReader) Read(p []byte) (int, error) {
+	r.body.mu.Lock()
+	defer r.body.mu.Unlock()
+
+	if r.body.buf == nil {
+		return 0, errBodyReleased
+	}
+	data := r.body.buf.Bytes()
+	if r.offset >= len(data) {
+		return 0, io.EOF
+	}
+	n := copy(p, data[r.offset:])
+	r.offset += n
+	return n, nil
+}
+
+func (r *pooledBodyReader) Close() error {
+	return nil
+}
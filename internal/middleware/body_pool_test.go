@@ -0,0 +1,143 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/songzhibin97/stargate/internal/config"
+)
+
+func TestPooledBody(t *testing.T) {
+	body, err := readPooledBody(strings.NewReader("secret payload"))
+	if err != nil {
+		t.Fatalf("Failed to read body: %v", err)
+	}
+	if string(body.Bytes()) != "secret payload" {
+		t.Fatalf("Expected the body, got %q", body.Bytes())
+	}
+
+	// Readers restore the body from its start, each with its own offset
+	for i := 0; i < 2; i++ {
+		data, err := io.ReadAll(body.Reader())
+		if err != nil || string(data) != "secret payload" {
+			t.Fatalf("Expected the restored body, got %q, %v", data, err)
+		}
+	}
+
+	reader := body.Reader()
+	buf := body.buf
+	data := buf.Bytes()[:buf.Len()]
+	body.Release()
+
+	// Buffers are zeroed on release and never readable past it
+	if !bytes.Equal(data, make([]byte, len(data))) {
+		t.Errorf("Expected the released buffer to be zeroed, got %q", data)
+	}
+	if _, err := reader.Read(make([]byte, 8)); err != errBodyReleased {
+		t.Errorf("Expected reading a released body to fail, got %v", err)
+	}
+	if body.Bytes() != nil {
+		t.Error("Expected no bytes from a released body")
+	}
+	body.Release()
+}
+
+func TestMockResponseMiddleware_BodyConditionRestoresBody(t *testing.T) {
+	middleware, err := NewMockResponseMiddleware(&config.MockResponseConfig{
+		Enabled: true,
+		PerRoute: map[string]config.MockRouteConfig{
+			"default": {
+				Enabled: true,
+				Rules: []config.MockRule{
+					{ID: "other", Enabled: true, Conditions: config.MockConditions{Body: "other"}, Response: config.MockResponse{StatusCode: 418}},
+					{ID: "ping", Enabled: true, Conditions: config.MockConditions{Body: "ping"}, Response: config.MockResponse{StatusCode: 200, Body: "pong"}},
+				},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create middleware: %v", err)
+	}
+
+	var forwarded string
+	handler := middleware.Handler()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		forwarded = string(data)
+	}))
+
+	for _, tt := range []struct {
+		body      string
+		status    int
+		forwarded string
+	}{
+		{"ping", http.StatusOK, ""},
+		{"order payload", http.StatusOK, "order payload"},
+	} {
+		forwarded = ""
+		req := httptest.NewRequest("POST", "/orders", strings.NewReader(tt.body))
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		if rr.Code != tt.status || forwarded != tt.forwarded {
+			t.Errorf("Body %q: expected status %d forwarding %q, got %d forwarding %q", tt.body, tt.status, tt.forwarded, rr.Code, forwarded)
+		}
+	}
+}
+
+// benchmarkBody is a typical JSON request body
+var benchmarkBody = strings.Repeat(`{"id":12345,"name":"order","items":[1,2,3]}`, 100)
+
+func BenchmarkReadBody_ReadAll(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		data, _ := io.ReadAll(strings.NewReader(benchmarkBody))
+		_ = data
+	}
+}
+
+func BenchmarkReadBody_Pooled(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		body, _ := readPooledBody(strings.NewReader(benchmarkBody))
+		body.Release()
+	}
+}
+
+func TestServerlessMiddleware_BodyOutlivesPooledBuffers(t *testing.T) {
+	var received string
+	functions := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request FunctionRequest
+		json.NewDecoder(r.Body).Decode(&request)
+		received = request.Body
+		w.Write([]byte("{}"))
+	}))
+	defer functions.Close()
+
+	middleware := NewServerlessMiddleware(&config.ServerlessConfig{Enabled: true, DefaultTimeout: 5 * time.Second})
+	rule := &ServerlessRule{
+		ID:         "audit",
+		PreProcess: []ServerlessFunction{{ID: "audit", Name: "audit", URL: functions.URL}},
+	}
+
+	modifiedReq, err := middleware.executePreProcessFunctions(httptest.NewRequest("POST", "/api", strings.NewReader("original body")), rule)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if received != "original body" {
+		t.Errorf("Expected the function to receive the body, got %q", received)
+	}
+
+	// Buffers reused by other requests don't change the forwarded body
+	for i := 0; i < 10; i++ {
+		body, _ := readPooledBody(strings.NewReader("other request"))
+		body.Release()
+	}
+	if data, _ := io.ReadAll(modifiedReq.Body); string(data) != "original body" {
+		t.Errorf("Expected the forwarded body intact, got %q", data)
+	}
+}
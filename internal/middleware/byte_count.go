@@ -0,0 +1,64 @@
+package middleware
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync/atomic"
+)
+
+// requestBodyCounterKey is the context key of the request's body counter
+type requestBodyCounterKey struct{}
+
+// RequestBodyCounter counts the bytes read from a request body. Chunked
+// requests have no Content-Length, so counting is the only way to size them.
+type RequestBodyCounter struct {
+	body io.ReadCloser
+	read int64 // Updated atomically, the transport may read in its own goroutine
+}
+
+// Read reads from the body and counts the bytes read
+func (c *RequestBodyCounter) Read(p []byte) (int, error) {
+	n, err := c.body.Read(p)
+	atomic.AddInt64(&c.read, int64(n))
+	return n, err
+}
+
+// Close closes the body
+func (c *RequestBodyCounter) Close() error {
+	return c.body.Close()
+}
+
+// BytesRead returns the number of bytes read from the body so far
+func (c *RequestBodyCounter) BytesRead() int64 {
+	return atomic.LoadInt64(&c.read)
+}
+
+// CountRequestBody wraps the request body in a counter. Requests already
+// counted by an outer middleware keep their counter, so every middleware
+// sees the same count. Requests without a body get a nil counter.
+func CountRequestBody(r *http.Request) (*http.Request, *RequestBodyCounter) {
+	if counter, ok := r.Context().Value(requestBodyCounterKey{}).(*RequestBodyCounter); ok {
+		return r, counter
+	}
+	if r.Body == nil || r.Body == http.NoBody {
+		return r, nil
+	}
+
+	counter := &RequestBodyCounter{body: r.Body}
+	r = r.WithContext(context.WithValue(r.Context(), requestBodyCounterKey{}, counter))
+	r.Body = counter
+	return r, counter
+}
+
+// requestSize returns the size of a request body: the bytes read from it, or
+// its Content-Length if the body wasn't read
+func requestSize(r *http.Request, counter *RequestBodyCounter) int64 {
+	if counter != nil && counter.BytesRead() > 0 {
+		return counter.BytesRead()
+	}
+	if r.ContentLength > 0 {
+		return r.ContentLength
+	}
+	return 0
+}
@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCountRequestBody(t *testing.T) {
+	// Chunked request bodies are sized by the bytes read
+	req := httptest.NewRequest("POST", "/upload", io.MultiReader(strings.NewReader("hello "), strings.NewReader("world")))
+	req, counter := CountRequestBody(req)
+	if counter == nil {
+		t.Fatal("Expected a counter for a request with a body")
+	}
+	if size := requestSize(req, counter); size != 0 {
+		t.Errorf("Expected size 0 before the body is read, got %d", size)
+	}
+
+	// Nested middlewares share the counter
+	nested, nestedCounter := CountRequestBody(req)
+	if nestedCounter != counter {
+		t.Error("Expected nested middlewares to share the counter")
+	}
+	if _, err := io.ReadAll(nested.Body); err != nil {
+		t.Fatalf("Failed to read body: %v", err)
+	}
+	if size := requestSize(req, counter); size != 11 {
+		t.Errorf("Expected size 11, got %d", size)
+	}
+
+	// Bodies that aren't read fall back to Content-Length
+	req, counter = CountRequestBody(httptest.NewRequest("POST", "/upload", strings.NewReader("unread")))
+	if size := requestSize(req, counter); size != 6 {
+		t.Errorf("Expected size 6 from Content-Length, got %d", size)
+	}
+
+	// Requests without a body aren't wrapped
+	req, counter = CountRequestBody(httptest.NewRequest("GET", "/", nil))
+	if counter != nil || req.Body != http.NoBody {
+		t.Error("Expected requests without a body to be left as is")
+	}
+	if size := requestSize(req, counter); size != 0 {
+		t.Errorf("Expected size 0, got %d", size)
+	}
+}
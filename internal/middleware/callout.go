@@ -0,0 +1,284 @@
+package middleware
+
+// Guarded outbound HTTP calls for WASM plugins and serverless functions.
+//
+// Callouts are synchronous: the request being processed waits for the
+// callout to complete, so every callout adds its full latency to the request.
+// Keep callouts off the hot path where possible and cache their results, for
+// example in the WASM key-value store with a TTL.
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/songzhibin97/stargate/internal/config"
+	"github.com/songzhibin97/stargate/pkg/metrics"
+)
+
+const (
+	defaultCalloutTimeout         = time.Second
+	defaultCalloutMaxConcurrent   = 10
+	defaultCalloutMaxResponseSize = 1 << 20
+)
+
+var (
+	// ErrCalloutDisabled is returned when callouts are not enabled
+	ErrCalloutDisabled = errors.New("callouts are disabled")
+
+	// ErrCalloutNotAllowed is returned when the destination is not in the allowlist
+	ErrCalloutNotAllowed = errors.New("callout destination not allowed")
+
+	// ErrCalloutLimitExceeded is returned when too many callouts are in flight
+	ErrCalloutLimitExceeded = errors.New("too many concurrent callouts")
+)
+
+// CalloutRequest describes an outbound HTTP call
+type CalloutRequest struct {
+	Method  string            `json:"method"`
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    string            `json:"body,omitempty"`
+}
+
+// CalloutResponse is the result of an outbound HTTP call
+type CalloutResponse struct {
+	Status  int               `json:"status,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    string            `json:"body,omitempty"`
+	Error   string            `json:"error,omitempty"`
+}
+
+// calloutClient executes callouts with a timeout, a destination allowlist
+// and a concurrency limit
+type calloutClient struct {
+	config          config.CalloutConfig
+	client          *http.Client
+	timeout         time.Duration
+	maxResponseSize int64
+	slots           chan struct{}
+
+	// Statistics
+	mutex        sync.Mutex
+	requests     int64
+	errors       int64
+	rejected     int64
+	totalLatency time.Duration
+
+	// Metrics by source, nil unless set
+	source   string
+	calls    metrics.CounterVec
+	duration metrics.HistogramVec
+}
+
+// newCalloutClient creates a callout client from configuration
+func newCalloutClient(cfg config.CalloutConfig) *calloutClient {
+	c := &calloutClient{
+		config:          cfg,
+		timeout:         cfg.Timeout,
+		maxResponseSize: cfg.MaxResponseSize,
+	}
+	if c.timeout <= 0 {
+		c.timeout = defaultCalloutTimeout
+	}
+	if c.maxResponseSize <= 0 {
+		c.maxResponseSize = defaultCalloutMaxResponseSize
+	}
+
+	maxConcurrent := cfg.MaxConcurrent
+	if maxConcurrent <= 0 {
+		maxConcurrent = defaultCalloutMaxConcurrent
+	}
+	c.slots = make(chan struct{}, maxConcurrent)
+
+	c.client = &http.Client{
+		Timeout: c.timeout,
+		Transport: &http.Transport{
+			MaxIdleConns:        100,
+			MaxIdleConnsPerHost: 10,
+			IdleConnTimeout:     30 * time.Second,
+		},
+		// Redirects could lead to destinations outside the allowlist
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	return c
+}
+
+// allowed reports whether the URL's host is in the allowlist
+func (c *calloutClient) allowed(u *url.URL) bool {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return false
+	}
+
+	host := strings.ToLower(u.Hostname())
+	hostPort := host
+	if port := u.Port(); port != "" {
+		hostPort = net.JoinHostPort(host, port)
+	}
+
+	for _, pattern := range c.config.AllowedHosts {
+		pattern = strings.ToLower(pattern)
+		target := host
+		if _, _, err := net.SplitHostPort(pattern); err == nil {
+			target = hostPort
+		}
+
+		if strings.HasPrefix(pattern, "*.") {
+			if strings.HasSuffix(target, pattern[1:]) {
+				return true
+			}
+		} else if target == pattern {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Call executes a callout. Rejected and failed callouts return an error;
+// HTTP error statuses are returned as a normal response. Calling a nil
+// client reports callouts as disabled.
+func (c *calloutClient) Call(ctx context.Context, request *CalloutRequest) (*CalloutResponse, error) {
+	if c == nil || !c.config.Enabled {
+		return nil, ErrCalloutDisabled
+	}
+
+	u, err := url.Parse(request.URL)
+	if err != nil {
+		c.recordRejected()
+		return nil, fmt.Errorf("invalid callout URL: %w", err)
+	}
+	if !c.allowed(u) {
+		c.recordRejected()
+		return nil, fmt.Errorf("%w: %s", ErrCalloutNotAllowed, u.Host)
+	}
+
+	select {
+	case c.slots <- struct{}{}:
+		defer func() { <-c.slots }()
+	default:
+		c.recordRejected()
+		return nil, ErrCalloutLimitExceeded
+	}
+
+	startTime := time.Now()
+	response, err := c.do(ctx, request, u)
+	c.recordCall(time.Since(startTime), err)
+
+	return response, err
+}
+
+// do performs the HTTP request of a callout
+func (c *calloutClient) do(ctx context.Context, request *CalloutRequest, u *url.URL) (*CalloutResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	method := request.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), strings.NewReader(request.Body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create callout request: %w", err)
+	}
+	for key, value := range request.Headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("callout failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var body bytes.Buffer
+	n, err := io.Copy(&body, io.LimitReader(resp.Body, c.maxResponseSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read callout response: %w", err)
+	}
+	if n > c.maxResponseSize {
+		return nil, fmt.Errorf("callout response exceeds %d bytes", c.maxResponseSize)
+	}
+
+	headers := make(map[string]string, len(resp.Header))
+	for key, values := range resp.Header {
+		if len(values) > 0 {
+			headers[key] = values[0]
+		}
+	}
+
+	return &CalloutResponse{
+		Status:  resp.StatusCode,
+		Headers: headers,
+		Body:    body.String(),
+	}, nil
+}
+
+// setMetrics sets the counter of callouts by source and result and the
+// latency histogram of executed callouts by source, labeling them with the
+// source of the callouts
+func (c *calloutClient) setMetrics(source string, calls metrics.CounterVec, duration metrics.HistogramVec) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.source = source
+	c.calls = calls
+	c.duration = duration
+}
+
+func (c *calloutClient) recordRejected() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.rejected++
+	if c.calls != nil {
+		c.calls.WithLabelValues(c.source, "rejected").Inc()
+	}
+}
+
+func (c *calloutClient) recordCall(latency time.Duration, err error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.requests++
+	c.totalLatency += latency
+	result := "success"
+	if err != nil {
+		c.errors++
+		result = "error"
+	}
+	if c.calls != nil {
+		c.calls.WithLabelValues(c.source, result).Inc()
+	}
+	if c.duration != nil {
+		c.duration.WithLabelValues(c.source).Observe(latency.Seconds())
+	}
+}
+
+// GetStats returns callout statistics
+func (c *calloutClient) GetStats() map[string]interface{} {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	var avgLatency float64
+	if c.requests > 0 {
+		avgLatency = float64(c.totalLatency) / float64(time.Millisecond) / float64(c.requests)
+	}
+
+	return map[string]interface{}{
+		"requests":       c.requests,
+		"errors":         c.errors,
+		"rejected":       c.rejected,
+		"in_flight":      len(c.slots),
+		"avg_latency_ms": avgLatency,
+	}
+}
@@ -0,0 +1,226 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/songzhibin97/stargate/internal/config"
+	"github.com/songzhibin97/stargate/internal/metrics/driver/memory"
+	"github.com/songzhibin97/stargate/pkg/metrics"
+)
+
+func TestCalloutClient_Allowlist(t *testing.T) {
+	client := newCalloutClient(config.CalloutConfig{
+		Enabled:      true,
+		AllowedHosts: []string{"authz.internal", "*.example.com", "localhost:8443"},
+	})
+
+	tests := []struct {
+		url     string
+		allowed bool
+	}{
+		{"http://authz.internal/check", true},
+		{"https://AUTHZ.internal:9000/check", true},
+		{"https://api.example.com/v1", true},
+		{"https://example.com/v1", false},
+		{"https://evil.com/?x=.example.com", false},
+		{"http://localhost:8443/", true},
+		{"http://localhost:8080/", false},
+		{"http://169.254.169.254/latest/meta-data", false},
+		{"file:///etc/passwd", false},
+	}
+
+	for _, tt := range tests {
+		u, err := url.Parse(tt.url)
+		if err != nil {
+			t.Fatalf("failed to parse %s: %v", tt.url, err)
+		}
+		if got := client.allowed(u); got != tt.allowed {
+			t.Errorf("Expected allowed(%s) = %v, got %v", tt.url, tt.allowed, got)
+		}
+	}
+}
+
+func TestCalloutClient_Call(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/slow":
+			<-release
+		case "/redirect":
+			http.Redirect(w, r, "http://169.254.169.254/", http.StatusFound)
+			return
+		}
+		w.Header().Set("X-Authz", "allow")
+		w.Write([]byte(r.Method + " " + r.Header.Get("X-Token")))
+	}))
+	defer server.Close()
+	defer close(release)
+
+	serverURL, _ := url.Parse(server.URL)
+	client := newCalloutClient(config.CalloutConfig{
+		Enabled:       true,
+		AllowedHosts:  []string{serverURL.Host},
+		Timeout:       time.Second,
+		MaxConcurrent: 1,
+	})
+	ctx := context.Background()
+
+	response, err := client.Call(ctx, &CalloutRequest{
+		Method:  "POST",
+		URL:     server.URL + "/check",
+		Headers: map[string]string{"X-Token": "abc"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if response.Status != http.StatusOK || response.Body != "POST abc" || response.Headers["X-Authz"] != "allow" {
+		t.Errorf("unexpected response: %+v", response)
+	}
+
+	// Redirects are returned, not followed
+	response, err = client.Call(ctx, &CalloutRequest{URL: server.URL + "/redirect"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if response.Status != http.StatusFound {
+		t.Errorf("Expected redirect status, got %d", response.Status)
+	}
+
+	if _, err := client.Call(ctx, &CalloutRequest{URL: "http://other.host/"}); !errors.Is(err, ErrCalloutNotAllowed) {
+		t.Errorf("Expected ErrCalloutNotAllowed, got %v", err)
+	}
+
+	// A second callout while one is in flight exceeds the concurrency limit
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		client.Call(ctx, &CalloutRequest{URL: server.URL + "/slow"})
+	}()
+	deadline := time.Now().Add(time.Second)
+	for len(client.slots) == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if _, err := client.Call(ctx, &CalloutRequest{URL: server.URL + "/check"}); !errors.Is(err, ErrCalloutLimitExceeded) {
+		t.Errorf("Expected ErrCalloutLimitExceeded, got %v", err)
+	}
+	release <- struct{}{}
+	<-done
+
+	stats := client.GetStats()
+	if stats["requests"].(int64) != 3 || stats["rejected"].(int64) != 2 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+
+	disabled := newCalloutClient(config.CalloutConfig{AllowedHosts: []string{serverURL.Host}})
+	if _, err := disabled.Call(ctx, &CalloutRequest{URL: server.URL}); !errors.Is(err, ErrCalloutDisabled) {
+		t.Errorf("Expected ErrCalloutDisabled, got %v", err)
+	}
+}
+
+func TestCalloutClient_Timeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	serverURL, _ := url.Parse(server.URL)
+	client := newCalloutClient(config.CalloutConfig{
+		Enabled:      true,
+		AllowedHosts: []string{serverURL.Host},
+		Timeout:      20 * time.Millisecond,
+	})
+
+	if _, err := client.Call(context.Background(), &CalloutRequest{URL: server.URL}); err == nil {
+		t.Error("Expected timeout error")
+	}
+	if stats := client.GetStats(); stats["errors"].(int64) != 1 {
+		t.Errorf("Expected 1 error, got %v", stats["errors"])
+	}
+}
+
+func TestServerlessMiddleware_PreProcessCallouts(t *testing.T) {
+	var authzCalls int32
+	authz := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&authzCalls, 1)
+		w.Write([]byte("allowed"))
+	}))
+	defer authz.Close()
+
+	functions := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request FunctionRequest
+		json.NewDecoder(r.Body).Decode(&request)
+
+		var response FunctionResponse
+		switch r.URL.Path {
+		case "/first":
+			// Ask the gateway to call the authz service
+			response.Callouts = []CalloutRequest{{Method: "GET", URL: authz.URL + "/check"}}
+		case "/second":
+			if len(request.Callouts) == 1 {
+				response.Headers = map[string]string{"X-Authz": request.Callouts[0].Body}
+			}
+		}
+		json.NewEncoder(w).Encode(response)
+	}))
+	defer functions.Close()
+
+	authzURL, _ := url.Parse(authz.URL)
+	middleware := NewServerlessMiddleware(&config.ServerlessConfig{
+		Enabled:        true,
+		DefaultTimeout: 5 * time.Second,
+		Callout: config.CalloutConfig{
+			Enabled:      true,
+			AllowedHosts: []string{authzURL.Host},
+		},
+	})
+
+	provider := memory.NewProvider(memory.Options{})
+	calls, _ := provider.NewCounterVec(metrics.MetricOptions{Name: "callouts_total", Labels: []string{"source", "result"}})
+	duration, _ := provider.NewHistogramVec(metrics.MetricOptions{Name: "callout_duration_seconds", Labels: []string{"source"}})
+	middleware.SetCalloutMetrics(calls, duration)
+
+	rule := &ServerlessRule{
+		ID: "authz",
+		PreProcess: []ServerlessFunction{
+			{ID: "first", Name: "first", URL: functions.URL + "/first"},
+			{ID: "second", Name: "second", URL: functions.URL + "/second"},
+		},
+	}
+
+	req := httptest.NewRequest("POST", "/api", strings.NewReader("{}"))
+	modifiedReq, err := middleware.executePreProcessFunctions(req, rule)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := modifiedReq.Header.Get("X-Authz"); got != "allowed" {
+		t.Errorf("Expected callout result to reach the next function, got %q", got)
+	}
+	if got := provider.GetCounterValue("callouts_total", map[string]string{"source": "serverless", "result": "success"}); got != 1 {
+		t.Errorf("Expected 1 successful callout, got %v", got)
+	}
+	if got := provider.GetHistogramCount("callout_duration_seconds", map[string]string{"source": "serverless"}); got != 1 {
+		t.Errorf("Expected 1 callout latency observation, got %d", got)
+	}
+
+	// Callouts of the last function have no function to receive their
+	// results and are rejected without being executed
+	rule.PreProcess = rule.PreProcess[:1]
+	if _, err := middleware.executePreProcessFunctions(httptest.NewRequest("POST", "/api", strings.NewReader("{}")), rule); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := atomic.LoadInt32(&authzCalls); got != 1 {
+		t.Errorf("Expected the last function's callout not to be executed, got %d calls", got)
+	}
+	if got := provider.GetCounterValue("callouts_total", map[string]string{"source": "serverless", "result": "rejected"}); got != 1 {
+		t.Errorf("Expected 1 rejected callout, got %v", got)
+	}
+}
@@ -0,0 +1,304 @@
+package middleware
+
+import (
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/songzhibin97/stargate/internal/auth"
+	"github.com/songzhibin97/stargate/internal/clientip"
+	"github.com/songzhibin97/stargate/internal/config"
+)
+
+// Experiment assignment keys
+const (
+	ExperimentKeyIP     = "ip"
+	ExperimentKeyUser   = "user"
+	ExperimentKeyHeader = "header"
+	ExperimentKeyCookie = "cookie"
+)
+
+const (
+	// experimentHeaderPrefix prefixes the header carrying a variant upstream
+	experimentHeaderPrefix = "X-Experiment-"
+
+	defaultExperimentCookiePrefix = "stargate_exp_"
+	defaultExperimentCookieMaxAge = 30 * 24 * time.Hour
+)
+
+// ExperimentMiddleware assigns requests to A/B experiment variants and tells
+// the upstream the variant of each experiment in a header
+type ExperimentMiddleware struct {
+	config      *config.ExperimentsConfig
+	experiments []*experiment
+	mu          sync.RWMutex
+
+	// Statistics
+	requestsProcessed int64
+	assignments       map[string]map[string]int64 // experiment -> variant -> assignments
+}
+
+// experiment is a compiled experiment
+type experiment struct {
+	config      config.Experiment
+	header      string
+	routes      map[string]bool
+	variants    map[string]bool
+	totalWeight uint32
+	cookieName  string
+}
+
+// NewExperimentMiddleware creates a new experiment middleware
+func NewExperimentMiddleware(cfg *config.ExperimentsConfig) (*ExperimentMiddleware, error) {
+	m := &ExperimentMiddleware{}
+	if err := m.UpdateConfig(cfg); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// UpdateConfig validates and applies a new configuration. Assignments are
+// unchanged for experiments whose variants and weights are unchanged.
+func (m *ExperimentMiddleware) UpdateConfig(cfg *config.ExperimentsConfig) error {
+	experiments := make([]*experiment, 0, len(cfg.Experiments))
+	seen := make(map[string]bool, len(cfg.Experiments))
+	for _, ec := range cfg.Experiments {
+		exp, err := compileExperiment(ec)
+		if err != nil {
+			return err
+		}
+		if seen[exp.header] {
+			return fmt.Errorf("duplicate experiment %q", ec.Name)
+		}
+		seen[exp.header] = true
+		experiments = append(experiments, exp)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.config = cfg
+	m.experiments = experiments
+	if m.assignments == nil {
+		m.assignments = make(map[string]map[string]int64)
+	}
+	return nil
+}
+
+// compileExperiment validates an experiment and applies defaults
+func compileExperiment(ec config.Experiment) (*experiment, error) {
+	if ec.Name == "" {
+		return nil, fmt.Errorf("experiment name cannot be empty")
+	}
+	if strings.ContainsAny(ec.Name, " \t\r\n:") {
+		return nil, fmt.Errorf("experiment %q: name must be usable in a header name", ec.Name)
+	}
+
+	switch ec.Key {
+	case "":
+		ec.Key = ExperimentKeyIP
+	case ExperimentKeyIP, ExperimentKeyUser:
+	case ExperimentKeyHeader, ExperimentKeyCookie:
+		if ec.KeyName == "" {
+			return nil, fmt.Errorf("experiment %q: key_name is required for the %s key", ec.Name, ec.Key)
+		}
+	default:
+		return nil, fmt.Errorf("experiment %q: unknown key %q", ec.Name, ec.Key)
+	}
+
+	exp := &experiment{
+		header:   http.CanonicalHeaderKey(experimentHeaderPrefix + ec.Name),
+		routes:   make(map[string]bool, len(ec.Routes)),
+		variants: make(map[string]bool, len(ec.Variants)),
+	}
+	for _, routeID := range ec.Routes {
+		exp.routes[routeID] = true
+	}
+	for _, variant := range ec.Variants {
+		if variant.Name == "" {
+			return nil, fmt.Errorf("experiment %q: variant name cannot be empty", ec.Name)
+		}
+		if exp.variants[variant.Name] {
+			return nil, fmt.Errorf("experiment %q: duplicate variant %q", ec.Name, variant.Name)
+		}
+		if variant.Weight < 0 {
+			return nil, fmt.Errorf("experiment %q: variant %q weight cannot be negative", ec.Name, variant.Name)
+		}
+		exp.variants[variant.Name] = true
+		exp.totalWeight += uint32(variant.Weight)
+	}
+	if exp.totalWeight == 0 {
+		return nil, fmt.Errorf("experiment %q: at least one variant needs a positive weight", ec.Name)
+	}
+
+	if ec.Cookie.Enabled {
+		exp.cookieName = ec.Cookie.Name
+		if exp.cookieName == "" {
+			exp.cookieName = defaultExperimentCookiePrefix + ec.Name
+		}
+		if ec.Cookie.Path == "" {
+			ec.Cookie.Path = "/"
+		}
+		if ec.Cookie.MaxAge <= 0 {
+			ec.Cookie.MaxAge = defaultExperimentCookieMaxAge
+		}
+	}
+
+	exp.config = ec
+	return exp, nil
+}
+
+// Handler returns the HTTP middleware handler
+func (m *ExperimentMiddleware) Handler() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			m.mu.RLock()
+			enabled := m.config.Enabled
+			experiments := m.experiments
+			m.mu.RUnlock()
+
+			// Skip if middleware is disabled
+			if !enabled || len(experiments) == 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			assigned := make(map[string]string, len(experiments))
+			for _, exp := range experiments {
+				// Clients can't choose their own variant
+				r.Header.Del(exp.header)
+
+				if !exp.matches(r) {
+					continue
+				}
+
+				variant, sticky := exp.assign(r)
+				r.Header.Set(exp.header, variant)
+				assigned[exp.config.Name] = variant
+
+				if exp.cookieName != "" && !sticky {
+					http.SetCookie(w, &http.Cookie{
+						Name:     exp.cookieName,
+						Value:    variant,
+						Path:     exp.config.Cookie.Path,
+						MaxAge:   int(exp.config.Cookie.MaxAge.Seconds()),
+						Secure:   exp.config.Cookie.Secure,
+						HttpOnly: exp.config.Cookie.HTTPOnly,
+						SameSite: http.SameSiteLaxMode,
+					})
+				}
+			}
+
+			m.mu.Lock()
+			m.requestsProcessed++
+			for name, variant := range assigned {
+				if m.assignments[name] == nil {
+					m.assignments[name] = make(map[string]int64)
+				}
+				m.assignments[name][variant]++
+			}
+			m.mu.Unlock()
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// matches reports whether the request is in the experiment
+func (e *experiment) matches(r *http.Request) bool {
+	if len(e.routes) == 0 && len(e.config.Paths) == 0 {
+		return true
+	}
+	if routeID, ok := r.Context().Value("route_id").(string); ok && e.routes[routeID] {
+		return true
+	}
+	for _, prefix := range e.config.Paths {
+		if strings.HasPrefix(r.URL.Path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// assign returns the request's variant and whether it came from a valid
+// sticky cookie
+func (e *experiment) assign(r *http.Request) (string, bool) {
+	if e.cookieName != "" {
+		if cookie, err := r.Cookie(e.cookieName); err == nil && e.variants[cookie.Value] {
+			return cookie.Value, true
+		}
+	}
+	return e.bucket(e.key(r)), false
+}
+
+// key returns the assignment key of the request, falling back to the client
+// IP if the configured key is missing
+func (e *experiment) key(r *http.Request) string {
+	var key string
+	switch e.config.Key {
+	case ExperimentKeyUser:
+		if user, ok := auth.GetUserFromContext(r.Context()); ok && user != nil {
+			key = user.ID
+		} else if consumer, ok := auth.GetConsumerFromContext(r.Context()); ok && consumer != nil {
+			key = consumer.ID
+		}
+	case ExperimentKeyHeader:
+		key = r.Header.Get(e.config.KeyName)
+	case ExperimentKeyCookie:
+		if cookie, err := r.Cookie(e.config.KeyName); err == nil {
+			key = cookie.Value
+		}
+	}
+	if key == "" {
+		key = clientip.FromRequest(r)
+	}
+	return key
+}
+
+// bucket deterministically maps a key to a variant by weight. The
+// experiment name is hashed along with the key so a user's buckets are
+// independent across experiments.
+func (e *experiment) bucket(key string) string {
+	hash := fnv.New32a()
+	hash.Write([]byte(e.config.Name))
+	hash.Write([]byte{0})
+	hash.Write([]byte(key))
+	point := hash.Sum32() % e.totalWeight
+
+	var cumulative uint32
+	for _, variant := range e.config.Variants {
+		if variant.Weight <= 0 {
+			continue
+		}
+		cumulative += uint32(variant.Weight)
+		if point < cumulative {
+			return variant.Name
+		}
+	}
+	return e.config.Variants[len(e.config.Variants)-1].Name
+}
+
+// GetStats returns middleware statistics
+func (m *ExperimentMiddleware) GetStats() map[string]interface{} {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	assignments := make(map[string]map[string]int64, len(m.assignments))
+	for name, variants := range m.assignments {
+		counts := make(map[string]int64, len(variants))
+		for variant, count := range variants {
+			counts[variant] = count
+		}
+		assignments[name] = counts
+	}
+
+	return map[string]interface{}{
+		"enabled":            m.config.Enabled,
+		"experiments":        len(m.experiments),
+		"requests_processed": m.requestsProcessed,
+		"assignments":        assignments,
+	}
+}
@@ -0,0 +1,172 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/songzhibin97/stargate/internal/auth"
+	"github.com/songzhibin97/stargate/internal/config"
+)
+
+// variantUpstream echoes the experiment headers it receives
+func variantUpstream() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get("X-Experiment-Checkout") + "|" + r.Header.Get("X-Experiment-Search")))
+	})
+}
+
+func newExperimentHandler(t *testing.T, experiments ...config.Experiment) (*ExperimentMiddleware, http.Handler) {
+	t.Helper()
+	middleware, err := NewExperimentMiddleware(&config.ExperimentsConfig{Enabled: true, Experiments: experiments})
+	if err != nil {
+		t.Fatalf("Failed to create experiment middleware: %v", err)
+	}
+	return middleware, middleware.Handler()(variantUpstream())
+}
+
+func TestExperimentMiddleware_StableWeightedAssignment(t *testing.T) {
+	middleware, handler := newExperimentHandler(t,
+		config.Experiment{
+			Name:     "checkout",
+			Key:      ExperimentKeyHeader,
+			KeyName:  "X-User-ID",
+			Variants: []config.ExperimentVariant{{Name: "control", Weight: 80}, {Name: "treatment", Weight: 20}},
+		},
+		config.Experiment{
+			Name:     "search",
+			Key:      ExperimentKeyHeader,
+			KeyName:  "X-User-ID",
+			Variants: []config.ExperimentVariant{{Name: "a", Weight: 1}, {Name: "b", Weight: 1}},
+		},
+	)
+
+	send := func(userID string) string {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("X-User-ID", userID)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr.Body.String()
+	}
+
+	treatment := 0
+	for i := 0; i < 1000; i++ {
+		userID := fmt.Sprintf("user-%d", i)
+		first := send(userID)
+		if again := send(userID); again != first {
+			t.Fatalf("Expected a stable assignment for %s, got %q then %q", userID, first, again)
+		}
+		if strings.HasPrefix(first, "treatment|") {
+			treatment++
+		}
+	}
+
+	// 20% of users expected in treatment
+	if treatment < 150 || treatment > 250 {
+		t.Errorf("Expected about 200 users in treatment, got %d", treatment)
+	}
+
+	stats := middleware.GetStats()
+	assignments := stats["assignments"].(map[string]map[string]int64)
+	if assignments["search"]["a"]+assignments["search"]["b"] != 2000 {
+		t.Errorf("Expected 2000 search assignments, got %v", assignments["search"])
+	}
+}
+
+func TestExperimentMiddleware_ClientHeaderIgnored(t *testing.T) {
+	_, handler := newExperimentHandler(t, config.Experiment{
+		Name:     "checkout",
+		Variants: []config.ExperimentVariant{{Name: "control", Weight: 1}, {Name: "treatment", Weight: 0}},
+	})
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Experiment-Checkout", "treatment")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if body := rr.Body.String(); body != "control|" {
+		t.Errorf("Expected the client's variant to be replaced, got %q", body)
+	}
+}
+
+func TestExperimentMiddleware_StickyCookie(t *testing.T) {
+	_, handler := newExperimentHandler(t, config.Experiment{
+		Name:     "checkout",
+		Key:      ExperimentKeyUser,
+		Variants: []config.ExperimentVariant{{Name: "control", Weight: 1}, {Name: "treatment", Weight: 1}},
+		Cookie:   config.ExperimentCookie{Enabled: true, HTTPOnly: true},
+	})
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req = req.WithContext(auth.SetUserInContext(req.Context(), &auth.UserInfo{ID: "alice"}))
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	cookies := rr.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != "stargate_exp_checkout" {
+		t.Fatalf("Expected the sticky cookie to be set, got %v", cookies)
+	}
+	variant := strings.TrimSuffix(rr.Body.String(), "|")
+	if cookies[0].Value != variant || !cookies[0].HttpOnly || cookies[0].MaxAge != 30*24*3600 {
+		t.Errorf("Expected a cookie recording variant %q, got %+v", variant, cookies[0])
+	}
+
+	// The cookie takes precedence over the key and isn't set again
+	other := "control"
+	if variant == "control" {
+		other = "treatment"
+	}
+	req = httptest.NewRequest("GET", "/", nil)
+	req.AddCookie(&http.Cookie{Name: "stargate_exp_checkout", Value: other})
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if body := rr.Body.String(); body != other+"|" {
+		t.Errorf("Expected the cookie's variant %q, got %q", other, body)
+	}
+	if len(rr.Result().Cookies()) != 0 {
+		t.Error("Expected no cookie for a request with a valid sticky cookie")
+	}
+
+	// Unknown variants in the cookie are reassigned
+	req = httptest.NewRequest("GET", "/", nil)
+	req.AddCookie(&http.Cookie{Name: "stargate_exp_checkout", Value: "retired"})
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if body := rr.Body.String(); body == "retired|" || len(rr.Result().Cookies()) != 1 {
+		t.Errorf("Expected an unknown cookie variant to be reassigned, got %q", body)
+	}
+}
+
+func TestExperimentMiddleware_Paths(t *testing.T) {
+	_, handler := newExperimentHandler(t, config.Experiment{
+		Name:     "checkout",
+		Paths:    []string{"/checkout"},
+		Variants: []config.ExperimentVariant{{Name: "control", Weight: 1}},
+	})
+
+	for path, expected := range map[string]string{"/checkout/cart": "control|", "/search": "|"} {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest("GET", path, nil))
+		if body := rr.Body.String(); body != expected {
+			t.Errorf("Expected %q for %s, got %q", expected, path, body)
+		}
+	}
+}
+
+func TestNewExperimentMiddleware_InvalidConfig(t *testing.T) {
+	tests := map[string]config.Experiment{
+		"empty name":   {Variants: []config.ExperimentVariant{{Name: "a", Weight: 1}}},
+		"no weight":    {Name: "x", Variants: []config.ExperimentVariant{{Name: "a", Weight: 0}}},
+		"negative":     {Name: "x", Variants: []config.ExperimentVariant{{Name: "a", Weight: 2}, {Name: "b", Weight: -1}}},
+		"duplicate":    {Name: "x", Variants: []config.ExperimentVariant{{Name: "a", Weight: 1}, {Name: "a", Weight: 1}}},
+		"unknown key":  {Name: "x", Key: "session", Variants: []config.ExperimentVariant{{Name: "a", Weight: 1}}},
+		"missing name": {Name: "x", Key: ExperimentKeyCookie, Variants: []config.ExperimentVariant{{Name: "a", Weight: 1}}},
+	}
+	for name, experiment := range tests {
+		if _, err := NewExperimentMiddleware(&config.ExperimentsConfig{Enabled: true, Experiments: []config.Experiment{experiment}}); err == nil {
+			t.Errorf("Expected an error for %s", name)
+		}
+	}
+}
@@ -0,0 +1,471 @@
+package middleware
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/songzhibin97/stargate/internal/auth"
+	"github.com/songzhibin97/stargate/internal/config"
+	kvmemory "github.com/songzhibin97/stargate/internal/store/driver/memory"
+	kvredis "github.com/songzhibin97/stargate/internal/store/driver/redis"
+	"github.com/songzhibin97/stargate/pkg/store"
+)
+
+const (
+	defaultIdempotencyHeader          = "Idempotency-Key"
+	defaultIdempotencyTTL             = 24 * time.Hour
+	defaultIdempotencyLockTimeout     = 30 * time.Second
+	defaultIdempotencyMaxBodySize     = 1024 * 1024
+	defaultIdempotencyMaxResponseSize = 1024 * 1024
+
+	// idempotencyMaxKeyLength is the longest idempotency key accepted
+	idempotencyMaxKeyLength = 255
+
+	// idempotencyPollInterval is how often a waiting retry checks whether
+	// the original request completed
+	idempotencyPollInterval = 50 * time.Millisecond
+
+	// idempotencyReplayedHeader marks replayed responses
+	idempotencyReplayedHeader = "Idempotent-Replayed"
+)
+
+// defaultIdempotencyMethods are the unsafe methods deduplicated by default
+var defaultIdempotencyMethods = []string{"POST", "PUT", "PATCH", "DELETE"}
+
+// idempotencyRecord is the stored state of an idempotency key
+type idempotencyRecord struct {
+	Fingerprint string      `json:"fingerprint"` // Hash of the method, path and body
+	Completed   bool        `json:"completed"`
+	StatusCode  int         `json:"status_code,omitempty"`
+	Header      http.Header `json:"header,omitempty"`
+	Body        []byte      `json:"body,omitempty"`
+}
+
+// IdempotencyMiddleware deduplicates retried requests carrying an
+// idempotency key by replaying the stored response of the first request
+type IdempotencyMiddleware struct {
+	config  *config.IdempotencyConfig
+	backend store.AtomicStore
+	claims  store.ExclusiveSetter
+	mu      sync.RWMutex
+
+	// Compiled configuration
+	routes  map[string]bool
+	methods map[string]bool
+
+	// Statistics
+	requestsProcessed int64
+	responsesStored   int64
+	replays           int64
+	conflicts         int64
+	mismatches        int64
+	storeErrors       int64
+}
+
+// NewIdempotencyMiddleware creates a new idempotency middleware
+func NewIdempotencyMiddleware(cfg *config.IdempotencyConfig) (*IdempotencyMiddleware, error) {
+	storeConfig := &store.Config{
+		Type:      cfg.Storage,
+		Address:   cfg.Redis.Address,
+		Database:  cfg.Redis.DB,
+		Password:  cfg.Redis.Password,
+		Timeout:   5 * time.Second,
+		KeyPrefix: "idempotency",
+	}
+
+	var backend store.AtomicStore
+	var err error
+
+	switch cfg.Storage {
+	case "", "memory":
+		backend, err = kvmemory.New(storeConfig)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create memory store: %w", err)
+		}
+	case "redis":
+		backend, err = kvredis.New(storeConfig)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create Redis store: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported storage type: %s", cfg.Storage)
+	}
+
+	claims, ok := backend.(store.ExclusiveSetter)
+	if !ok {
+		backend.Close()
+		return nil, fmt.Errorf("storage type %s does not support exclusive writes", cfg.Storage)
+	}
+
+	m := &IdempotencyMiddleware{
+		backend: backend,
+		claims:  claims,
+	}
+	m.UpdateConfig(cfg)
+	return m, nil
+}
+
+// UpdateConfig updates the middleware configuration. The storage isn't
+// changed by updates.
+func (m *IdempotencyMiddleware) UpdateConfig(cfg *config.IdempotencyConfig) {
+	routes := make(map[string]bool, len(cfg.Routes))
+	for _, routeID := range cfg.Routes {
+		routes[routeID] = true
+	}
+
+	methodList := cfg.Methods
+	if len(methodList) == 0 {
+		methodList = defaultIdempotencyMethods
+	}
+	methods := make(map[string]bool, len(methodList))
+	for _, method := range methodList {
+		methods[strings.ToUpper(method)] = true
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.config = cfg
+	m.routes = routes
+	m.methods = methods
+}
+
+// settings returns the configuration with defaults applied
+func (m *IdempotencyMiddleware) settings() config.IdempotencyConfig {
+	m.mu.RLock()
+	cfg := *m.config
+	m.mu.RUnlock()
+
+	if cfg.Header == "" {
+		cfg.Header = defaultIdempotencyHeader
+	}
+	if cfg.TTL <= 0 {
+		cfg.TTL = defaultIdempotencyTTL
+	}
+	if cfg.LockTimeout <= 0 {
+		cfg.LockTimeout = defaultIdempotencyLockTimeout
+	}
+	if cfg.MaxBodySize <= 0 {
+		cfg.MaxBodySize = defaultIdempotencyMaxBodySize
+	}
+	if cfg.MaxResponseSize <= 0 {
+		cfg.MaxResponseSize = defaultIdempotencyMaxResponseSize
+	}
+	return cfg
+}
+
+// Handler returns the HTTP middleware handler
+func (m *IdempotencyMiddleware) Handler() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			cfg := m.settings()
+
+			key := r.Header.Get(cfg.Header)
+			if !cfg.Enabled || key == "" || !m.applies(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if len(key) > idempotencyMaxKeyLength {
+				m.writeError(w, http.StatusBadRequest, fmt.Sprintf("%s must be at most %d characters", cfg.Header, idempotencyMaxKeyLength))
+				return
+			}
+
+			body, err := io.ReadAll(io.LimitReader(r.Body, cfg.MaxBodySize+1))
+			if err != nil {
+				m.writeError(w, http.StatusBadRequest, "failed to read request body")
+				return
+			}
+			if int64(len(body)) > cfg.MaxBodySize {
+				m.writeError(w, http.StatusRequestEntityTooLarge, "request body too large for idempotent processing")
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			m.mu.Lock()
+			m.requestsProcessed++
+			m.mu.Unlock()
+
+			m.serve(next, w, r, cfg, m.storeKey(r, key), fingerprint(r, body))
+		})
+	}
+}
+
+// applies reports whether the request's method and route are deduplicated
+func (m *IdempotencyMiddleware) applies(r *http.Request) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if !m.methods[r.Method] {
+		return false
+	}
+	if len(m.routes) == 0 && len(m.config.Paths) == 0 {
+		return true
+	}
+	if routeID, ok := r.Context().Value("route_id").(string); ok && m.routes[routeID] {
+		return true
+	}
+	for _, prefix := range m.config.Paths {
+		if strings.HasPrefix(r.URL.Path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// storeKey returns the storage key of an idempotency key. Keys are scoped to
+// the authenticated consumer so different clients can't collide.
+func (m *IdempotencyMiddleware) storeKey(r *http.Request, key string) string {
+	scope := ""
+	if consumer, ok := auth.GetConsumerFromContext(r.Context()); ok && consumer != nil {
+		scope = consumer.ID
+	}
+	sum := sha256.Sum256([]byte(scope + "\x00" + key))
+	return hex.EncodeToString(sum[:])
+}
+
+// fingerprint identifies a request so key reuse with a different request
+// can be detected
+func fingerprint(r *http.Request, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(r.Method + " " + r.URL.RequestURI() + "\x00"))
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// serve claims the key and forwards the request, or replays, waits for or
+// rejects it if the key is already taken
+func (m *IdempotencyMiddleware) serve(next http.Handler, w http.ResponseWriter, r *http.Request, cfg config.IdempotencyConfig, key, print string) {
+	ctx := r.Context()
+	deadline := time.Now().Add(cfg.WaitTimeout)
+
+	for {
+		claim, _ := json.Marshal(&idempotencyRecord{Fingerprint: print})
+		claimed, err := m.claims.SetNX(ctx, key, claim, cfg.LockTimeout)
+		if err != nil {
+			// Fail open, deduplication is best effort if the store is down
+			m.recordStoreError(err)
+			next.ServeHTTP(w, r)
+			return
+		}
+		if claimed {
+			m.forward(next, w, r, cfg, key, print)
+			return
+		}
+
+		record, err := m.load(ctx, key)
+		if err != nil {
+			m.recordStoreError(err)
+			next.ServeHTTP(w, r)
+			return
+		}
+		if record == nil {
+			// Released or expired since the claim attempt
+			continue
+		}
+
+		if record.Fingerprint != print {
+			m.mu.Lock()
+			m.mismatches++
+			m.mu.Unlock()
+			m.writeError(w, http.StatusUnprocessableEntity, fmt.Sprintf("%s was already used for a different request", cfg.Header))
+			return
+		}
+
+		if record.Completed {
+			m.replay(w, record)
+			return
+		}
+
+		if time.Now().After(deadline) {
+			m.mu.Lock()
+			m.conflicts++
+			m.mu.Unlock()
+			m.writeError(w, http.StatusConflict, "a request with this idempotency key is already in progress")
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(idempotencyPollInterval):
+		}
+	}
+}
+
+// forward sends a claimed request upstream and stores its response.
+// Server errors and responses too large to store release the key instead,
+// so the request can be retried.
+func (m *IdempotencyMiddleware) forward(next http.Handler, w http.ResponseWriter, r *http.Request, cfg config.IdempotencyConfig, key, print string) {
+	recorder := &idempotencyResponseWriter{
+		ResponseWriter: w,
+		statusCode:     http.StatusOK,
+		maxSize:        cfg.MaxResponseSize,
+	}
+
+	// Release the key if the handler panics
+	stored := false
+	defer func() {
+		if !stored {
+			m.backend.Delete(context.Background(), key)
+		}
+	}()
+
+	next.ServeHTTP(recorder, r)
+
+	if recorder.statusCode >= http.StatusInternalServerError || recorder.overflow || recorder.hijacked {
+		return
+	}
+
+	data, err := json.Marshal(&idempotencyRecord{
+		Fingerprint: print,
+		Completed:   true,
+		StatusCode:  recorder.statusCode,
+		Header:      w.Header().Clone(),
+		Body:        recorder.body.Bytes(),
+	})
+	if err != nil {
+		return
+	}
+
+	// Store even if the client went away, its retry should be replayed
+	if err := m.backend.Set(context.Background(), key, data, cfg.TTL); err != nil {
+		m.recordStoreError(err)
+		return
+	}
+	stored = true
+
+	m.mu.Lock()
+	m.responsesStored++
+	m.mu.Unlock()
+}
+
+// load returns the record stored for a key, or nil if there is none
+func (m *IdempotencyMiddleware) load(ctx context.Context, key string) (*idempotencyRecord, error) {
+	data, err := m.backend.Get(ctx, key)
+	if err != nil || data == nil {
+		return nil, err
+	}
+
+	var record idempotencyRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, fmt.Errorf("invalid idempotency record: %w", err)
+	}
+	return &record, nil
+}
+
+// replay writes a stored response
+func (m *IdempotencyMiddleware) replay(w http.ResponseWriter, record *idempotencyRecord) {
+	m.mu.Lock()
+	m.replays++
+	m.mu.Unlock()
+
+	for key, values := range record.Header {
+		w.Header()[key] = values
+	}
+	w.Header().Set(idempotencyReplayedHeader, "true")
+	w.WriteHeader(record.StatusCode)
+	w.Write(record.Body)
+}
+
+// recordStoreError counts and logs a storage failure
+func (m *IdempotencyMiddleware) recordStoreError(err error) {
+	m.mu.Lock()
+	m.storeErrors++
+	m.mu.Unlock()
+	log.Printf("Idempotency store error: %v", err)
+}
+
+// writeError writes a JSON error response
+func (m *IdempotencyMiddleware) writeError(w http.ResponseWriter, statusCode int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
+
+// Close closes the storage
+func (m *IdempotencyMiddleware) Close() error {
+	return m.backend.Close()
+}
+
+// GetStats returns middleware statistics
+func (m *IdempotencyMiddleware) GetStats() map[string]interface{} {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return map[string]interface{}{
+		"enabled":            m.config.Enabled,
+		"storage":            m.config.Storage,
+		"requests_processed": m.requestsProcessed,
+		"responses_stored":   m.responsesStored,
+		"replays":            m.replays,
+		"conflicts":          m.conflicts,
+		"mismatches":         m.mismatches,
+		"store_errors":       m.storeErrors,
+	}
+}
+
+// idempotencyResponseWriter passes the response through while keeping a
+// copy to store
+type idempotencyResponseWriter struct {
+	http.ResponseWriter
+	statusCode  int
+	wroteHeader bool
+	body        bytes.Buffer
+	maxSize     int64
+	overflow    bool
+	hijacked    bool
+}
+
+// WriteHeader captures the status code
+func (w *idempotencyResponseWriter) WriteHeader(statusCode int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		w.statusCode = statusCode
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+// Write passes the body through and keeps a copy up to the size limit
+func (w *idempotencyResponseWriter) Write(data []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if !w.overflow {
+		if int64(w.body.Len()+len(data)) > w.maxSize {
+			w.overflow = true
+			w.body.Reset()
+		} else {
+			w.body.Write(data)
+		}
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+// Flush implements http.Flusher
+func (w *idempotencyResponseWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack implements http.Hijacker. Hijacked connections aren't stored.
+func (w *idempotencyResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if hijacker, ok := w.ResponseWriter.(http.Hijacker); ok {
+		w.hijacked = true
+		return hijacker.Hijack()
+	}
+	return nil, nil, fmt.Errorf("response writer does not support hijacking")
+}
@@ -0,0 +1,162 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/songzhibin97/stargate/internal/config"
+)
+
+// countingUpstream counts requests and echoes the request count and body
+func countingUpstream(calls *int64, status int, delay time.Duration) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt64(calls, 1)
+		time.Sleep(delay)
+		w.Header().Set("X-Call", fmt.Sprintf("%d", n))
+		w.WriteHeader(status)
+		w.Write([]byte(fmt.Sprintf("created %d", n)))
+	})
+}
+
+func newIdempotencyMiddleware(t *testing.T, cfg *config.IdempotencyConfig) *IdempotencyMiddleware {
+	t.Helper()
+	cfg.Enabled = true
+	middleware, err := NewIdempotencyMiddleware(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create idempotency middleware: %v", err)
+	}
+	t.Cleanup(func() { middleware.Close() })
+	return middleware
+}
+
+func sendIdempotent(handler http.Handler, method, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, "/orders", strings.NewReader(body))
+	if key != "" {
+		req.Header.Set("Idempotency-Key", key)
+	}
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestIdempotencyMiddleware_Replay(t *testing.T) {
+	var calls int64
+	middleware := newIdempotencyMiddleware(t, &config.IdempotencyConfig{})
+	handler := middleware.Handler()(countingUpstream(&calls, http.StatusCreated, 0))
+
+	first := sendIdempotent(handler, "POST", "order-1", `{"item":"book"}`)
+	if first.Code != http.StatusCreated || first.Header().Get("Idempotent-Replayed") != "" {
+		t.Fatalf("Expected the first request to be forwarded, got %d", first.Code)
+	}
+
+	retry := sendIdempotent(handler, "POST", "order-1", `{"item":"book"}`)
+	if retry.Code != http.StatusCreated || retry.Body.String() != "created 1" || retry.Header().Get("X-Call") != "1" {
+		t.Errorf("Expected the stored response to be replayed, got %d %q", retry.Code, retry.Body.String())
+	}
+	if retry.Header().Get("Idempotent-Replayed") != "true" {
+		t.Error("Expected the replayed response to be marked")
+	}
+	if calls != 1 {
+		t.Errorf("Expected 1 upstream call, got %d", calls)
+	}
+
+	// Requests without a key and safe methods are never deduplicated
+	sendIdempotent(handler, "POST", "", `{"item":"book"}`)
+	sendIdempotent(handler, "GET", "order-1", "")
+	if calls != 3 {
+		t.Errorf("Expected 3 upstream calls, got %d", calls)
+	}
+}
+
+func TestIdempotencyMiddleware_KeyReuseWithDifferentBody(t *testing.T) {
+	var calls int64
+	middleware := newIdempotencyMiddleware(t, &config.IdempotencyConfig{})
+	handler := middleware.Handler()(countingUpstream(&calls, http.StatusCreated, 0))
+
+	sendIdempotent(handler, "POST", "order-1", `{"item":"book"}`)
+	rr := sendIdempotent(handler, "POST", "order-1", `{"item":"pen"}`)
+	if rr.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected status 422, got %d", rr.Code)
+	}
+	if calls != 1 {
+		t.Errorf("Expected 1 upstream call, got %d", calls)
+	}
+}
+
+func TestIdempotencyMiddleware_ServerErrorsNotStored(t *testing.T) {
+	var calls int64
+	middleware := newIdempotencyMiddleware(t, &config.IdempotencyConfig{})
+	handler := middleware.Handler()(countingUpstream(&calls, http.StatusBadGateway, 0))
+
+	sendIdempotent(handler, "POST", "order-1", "{}")
+	sendIdempotent(handler, "POST", "order-1", "{}")
+	if calls != 2 {
+		t.Errorf("Expected server errors to be retried, got %d upstream calls", calls)
+	}
+}
+
+func TestIdempotencyMiddleware_ConcurrentRequests(t *testing.T) {
+	var calls int64
+	middleware := newIdempotencyMiddleware(t, &config.IdempotencyConfig{})
+	handler := middleware.Handler()(countingUpstream(&calls, http.StatusCreated, 200*time.Millisecond))
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		sendIdempotent(handler, "POST", "order-1", "{}")
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	// Without waiting, a concurrent request is rejected
+	if rr := sendIdempotent(handler, "POST", "order-1", "{}"); rr.Code != http.StatusConflict {
+		t.Errorf("Expected status 409, got %d", rr.Code)
+	}
+	wg.Wait()
+
+	// With waiting, a concurrent request gets the original's response
+	middleware.UpdateConfig(&config.IdempotencyConfig{Enabled: true, WaitTimeout: time.Second})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		sendIdempotent(handler, "POST", "order-2", "{}")
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	rr := sendIdempotent(handler, "POST", "order-2", "{}")
+	wg.Wait()
+	if rr.Code != http.StatusCreated || rr.Header().Get("Idempotent-Replayed") != "true" {
+		t.Errorf("Expected the waiting request to be replayed, got %d", rr.Code)
+	}
+	if calls != 2 {
+		t.Errorf("Expected 2 upstream calls, got %d", calls)
+	}
+
+	stats := middleware.GetStats()
+	if stats["conflicts"] != int64(1) || stats["replays"] != int64(1) {
+		t.Errorf("Expected 1 conflict and 1 replay, got %v", stats)
+	}
+}
+
+func TestIdempotencyMiddleware_Limits(t *testing.T) {
+	var calls int64
+	middleware := newIdempotencyMiddleware(t, &config.IdempotencyConfig{MaxBodySize: 8, MaxResponseSize: 4})
+	handler := middleware.Handler()(countingUpstream(&calls, http.StatusCreated, 0))
+
+	if rr := sendIdempotent(handler, "POST", "order-1", "0123456789"); rr.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected status 413, got %d", rr.Code)
+	}
+
+	// Responses over the limit aren't stored, so retries are forwarded
+	sendIdempotent(handler, "POST", "order-2", "{}")
+	sendIdempotent(handler, "POST", "order-2", "{}")
+	if calls != 2 {
+		t.Errorf("Expected 2 upstream calls, got %d", calls)
+	}
+}
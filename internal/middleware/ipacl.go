@@ -10,7 +10,9 @@ import (
 	"sync"
 	"time"
 
+	"github.com/songzhibin97/stargate/internal/clientip"
 	"github.com/songzhibin97/stargate/internal/config"
+	"github.com/songzhibin97/stargate/internal/decision"
 )
 
 // IPACLMiddleware handles IP-based access control (whitelist/blacklist)
@@ -172,41 +174,9 @@ func (m *IPACLMiddleware) Handler() func(http.Handler) http.Handler {
 	}
 }
 
-// getClientIP extracts the real client IP from the request
+// getClientIP returns the client IP resolved for the request
 func (m *IPACLMiddleware) getClientIP(r *http.Request) string {
-	// Check X-Forwarded-For header (most common)
-	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
-		// X-Forwarded-For can contain multiple IPs, take the first one
-		ips := strings.Split(xff, ",")
-		clientIP := strings.TrimSpace(ips[0])
-		if clientIP != "" {
-			return clientIP
-		}
-	}
-	
-	// Check X-Real-IP header
-	if xri := r.Header.Get("X-Real-IP"); xri != "" {
-		return strings.TrimSpace(xri)
-	}
-	
-	// Check CF-Connecting-IP header (Cloudflare)
-	if cfIP := r.Header.Get("CF-Connecting-IP"); cfIP != "" {
-		return strings.TrimSpace(cfIP)
-	}
-	
-	// Check X-Client-IP header
-	if xci := r.Header.Get("X-Client-IP"); xci != "" {
-		return strings.TrimSpace(xci)
-	}
-	
-	// Fall back to RemoteAddr
-	ip, _, err := net.SplitHostPort(r.RemoteAddr)
-	if err != nil {
-		// If SplitHostPort fails, RemoteAddr might not have a port
-		return r.RemoteAddr
-	}
-	
-	return ip
+	return clientip.FromRequest(r)
 }
 
 // checkIPAccess checks if the IP is allowed based on whitelist/blacklist rules
@@ -292,6 +262,13 @@ func (m *IPACLMiddleware) handleBlockedRequest(w http.ResponseWriter, r *http.Re
 	log.Printf("IP ACL blocked request from %s: %s (matched rule: %s)", 
 		result.ClientIP, result.Reason, result.MatchedRule)
 	
+	decision.Record(r.Context(), decision.Decision{
+		Middleware: "ipacl",
+		Outcome:    decision.OutcomeIPDenied,
+		Reason:     result.Reason,
+		Fields:     map[string]string{"matched_rule": result.MatchedRule},
+	})
+	
 	// Set response headers
 	w.Header().Set("Content-Type", "application/json")
 	w.Header().Set("X-Blocked-By", "IP-ACL")
@@ -7,6 +7,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/songzhibin97/stargate/internal/clientip"
 	"github.com/songzhibin97/stargate/internal/config"
 )
 
@@ -204,6 +205,10 @@ func TestIPACLMiddleware_GetClientIP(t *testing.T) {
 	if err != nil {
 		t.Fatalf("Failed to create middleware: %v", err)
 	}
+	resolver, err := clientip.NewResolver([]string{"127.0.0.1", "10.0.0.0/8"})
+	if err != nil {
+		t.Fatalf("Failed to create resolver: %v", err)
+	}
 
 	tests := []struct {
 		name       string
@@ -231,24 +236,44 @@ func TestIPACLMiddleware_GetClientIP(t *testing.T) {
 			expectedIP: "192.168.1.100",
 		},
 		{
-			name: "CF-Connecting-IP header",
+			name: "Spoofed X-Forwarded-For from untrusted source",
 			setupReq: func() *http.Request {
 				req := httptest.NewRequest("GET", "/test", nil)
-				req.Header.Set("CF-Connecting-IP", "192.168.1.100")
+				req.Header.Set("X-Forwarded-For", "192.168.1.100")
+				req.RemoteAddr = "203.0.113.5:12345"
+				return req
+			},
+			expectedIP: "203.0.113.5",
+		},
+		{
+			name: "Spoofed X-Real-IP from untrusted source",
+			setupReq: func() *http.Request {
+				req := httptest.NewRequest("GET", "/test", nil)
+				req.Header.Set("X-Real-IP", "192.168.1.100")
+				req.RemoteAddr = "203.0.113.5:12345"
+				return req
+			},
+			expectedIP: "203.0.113.5",
+		},
+		{
+			name: "Spoofed hop prepended to X-Forwarded-For",
+			setupReq: func() *http.Request {
+				req := httptest.NewRequest("GET", "/test", nil)
+				req.Header.Set("X-Forwarded-For", "192.168.1.100, 198.51.100.7, 10.0.0.1")
 				req.RemoteAddr = "127.0.0.1:12345"
 				return req
 			},
-			expectedIP: "192.168.1.100",
+			expectedIP: "198.51.100.7",
 		},
 		{
-			name: "X-Client-IP header",
+			name: "CF-Connecting-IP header is not trusted",
 			setupReq: func() *http.Request {
 				req := httptest.NewRequest("GET", "/test", nil)
-				req.Header.Set("X-Client-IP", "192.168.1.100")
+				req.Header.Set("CF-Connecting-IP", "192.168.1.100")
 				req.RemoteAddr = "127.0.0.1:12345"
 				return req
 			},
-			expectedIP: "192.168.1.100",
+			expectedIP: "127.0.0.1",
 		},
 		{
 			name: "RemoteAddr fallback",
@@ -272,7 +297,7 @@ func TestIPACLMiddleware_GetClientIP(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			req := tt.setupReq()
+			req := resolver.WithClientIP(tt.setupReq())
 			ip := middleware.getClientIP(req)
 
 			if ip != tt.expectedIP {
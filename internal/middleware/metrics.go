@@ -1,8 +1,10 @@
 package middleware
 
 import (
+	"bufio"
 	"context"
 	"fmt"
+	"net"
 	"net/http"
 	"strconv"
 	"strings"
@@ -23,11 +25,23 @@ type MetricsConfig struct {
 	EnabledMetrics    map[string]bool         `yaml:"enabled_metrics" json:"enabled_metrics"`       // Enable/disable specific metrics
 	CustomLabels      map[string]string       `yaml:"custom_labels" json:"custom_labels"`           // Custom labels to add
 	SampleRate        float64                 `yaml:"sample_rate" json:"sample_rate"`               // Sampling rate for high traffic
-	LabelExtractors   map[string]string       `yaml:"label_extractors" json:"label_extractors"`     // Custom label extraction rules
+	LabelExtractors   map[string]string       `yaml:"label_extractors" json:"label_extractors"`     // Label name to registered label extractor name
 	SensitiveLabels   []string                `yaml:"sensitive_labels" json:"sensitive_labels"`     // Labels to filter out
+	HashedLabels      []string                `yaml:"hashed_labels" json:"hashed_labels"`           // Labels whose values are hashed
 	MaxLabelLength    int                     `yaml:"max_label_length" json:"max_label_length"`     // Maximum label value length
 	AsyncUpdates      bool                    `yaml:"async_updates" json:"async_updates"`           // Enable async metric updates
 	BufferSize        int                     `yaml:"buffer_size" json:"buffer_size"`               // Buffer size for async updates
+	RouteGroups       map[string]MetricsRouteGroup `yaml:"route_groups" json:"route_groups"`   // Route groups with request metrics of their own, by group name
+}
+
+// MetricsRouteGroup records the request metrics of a set of routes apart from
+// the others. Its metrics are named with the subsystem before the metric name,
+// such as <subsystem>_http_requests_total, and carry its labels as constant
+// labels.
+type MetricsRouteGroup struct {
+	Routes    []string          `yaml:"routes" json:"routes"`
+	Subsystem string            `yaml:"subsystem" json:"subsystem"`
+	Labels    map[string]string `yaml:"labels" json:"labels"`
 }
 
 // DefaultMetricsConfig returns default configuration
@@ -57,20 +71,24 @@ type MetricsMiddleware struct {
 	config   *MetricsConfig
 	provider metrics.Provider
 	
+	// sanitized wraps provider so label values are sanitized before recording
+	sanitized metrics.Provider
+	
 	// HTTP request metrics
-	requestsTotal    metrics.CounterVec
-	requestDuration  metrics.HistogramVec
-	requestSize      metrics.HistogramVec
-	responseSize     metrics.HistogramVec
+	requestMetrics
+	
+	// routeGroups holds the request metrics of the route groups by route ID
+	routeGroups map[string]*requestMetrics
 	
 	// Connection metrics
 	activeConnections metrics.Gauge
 	
-	// Error metrics
-	errorsTotal metrics.CounterVec
+	// Label extractors referenced by configuration, adding a label each to
+	// the HTTP request metrics
+	labelExtractors []configuredLabelExtractor
 	
-	// Label cache for performance
-	labelCache sync.Map
+	// routeMatcher returns the route matching a request for label extractors
+	routeMatcher func(r *http.Request) *MetricsRoute
 	
 	// Async processing
 	metricsChan chan *metricUpdate
@@ -79,6 +97,15 @@ type MetricsMiddleware struct {
 	cancel      context.CancelFunc
 }
 
+// requestMetrics are the HTTP request metrics of the node or a route group
+type requestMetrics struct {
+	requestsTotal   metrics.CounterVec
+	requestDuration metrics.HistogramVec
+	requestSize     metrics.HistogramVec
+	responseSize    metrics.HistogramVec
+	errorsTotal     metrics.CounterVec
+}
+
 // metricUpdate represents an async metric update
 type metricUpdate struct {
 	metricType string
@@ -98,13 +125,24 @@ func NewMetricsMiddleware(config *MetricsConfig, provider metrics.Provider) (*Me
 			fmt.Errorf("metrics provider is required"))
 	}
 	
+	labelExtractors, err := resolveLabelExtractors(config.LabelExtractors)
+	if err != nil {
+		return nil, err
+	}
+	
 	ctx, cancel := context.WithCancel(context.Background())
 	
 	m := &MetricsMiddleware{
 		config:   config,
 		provider: provider,
-		ctx:      ctx,
-		cancel:   cancel,
+		labelExtractors: labelExtractors,
+		sanitized: metrics.NewSanitizingProvider(provider, metrics.SanitizeOptions{
+			SensitiveLabels: config.SensitiveLabels,
+			HashedLabels:    config.HashedLabels,
+			MaxLabelLength:  config.MaxLabelLength,
+		}),
+		ctx:    ctx,
+		cancel: cancel,
 	}
 	
 	// Initialize async processing if enabled
@@ -126,13 +164,53 @@ func NewMetricsMiddleware(config *MetricsConfig, provider metrics.Provider) (*Me
 func (m *MetricsMiddleware) initMetrics() error {
 	var err error
 	
+	// HTTP request metrics of the routes outside route groups
+	if err := m.initRequestMetrics(&m.requestMetrics, "", m.config.ConstLabels); err != nil {
+		return err
+	}
+	
+	// HTTP request metrics of each route group, under its subsystem and labels
+	if len(m.config.RouteGroups) > 0 {
+		m.routeGroups = make(map[string]*requestMetrics)
+	}
+	for name, group := range m.config.RouteGroups {
+		groupMetrics := &requestMetrics{}
+		constLabels := metrics.MergeLabelMaps(m.config.ConstLabels, group.Labels)
+		if err := m.initRequestMetrics(groupMetrics, group.Subsystem+"_", constLabels); err != nil {
+			return fmt.Errorf("route group %s: %w", name, err)
+		}
+		for _, routeID := range group.Routes {
+			m.routeGroups[m.normalizeLabelValue(routeID)] = groupMetrics
+		}
+	}
+	
+	// Active connections gauge
+	if m.isMetricEnabled("active_connections") {
+		m.activeConnections, err = m.sanitized.NewGauge(metrics.MetricOptions{
+			Name:        "http_active_connections",
+			Help:        "Number of active HTTP connections",
+			ConstLabels: m.config.ConstLabels,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create active connections gauge: %w", err)
+		}
+	}
+	
+	return nil
+}
+
+// initRequestMetrics creates the enabled HTTP request metrics, naming them
+// with prefix before the metric name
+func (m *MetricsMiddleware) initRequestMetrics(rm *requestMetrics, prefix string, constLabels map[string]string) error {
+	var err error
+	
 	// HTTP request total counter
 	if m.isMetricEnabled("requests_total") {
-		m.requestsTotal, err = m.provider.NewCounterVec(metrics.MetricOptions{
-			Name:        "http_requests_total",
+		rm.requestsTotal, err = m.sanitized.NewCounterVec(metrics.MetricOptions{
+			Name:        prefix + "http_requests_total",
 			Help:        "Total number of HTTP requests processed",
-			Labels:      []string{"method", "route", "status_code", "consumer_id"},
-			ConstLabels: m.config.ConstLabels,
+			Labels:      m.labelNames("method", "route", "status_code", "consumer_id"),
+			ConstLabels: constLabels,
 		})
 		if err != nil {
 			return fmt.Errorf("failed to create requests counter: %w", err)
@@ -141,12 +219,12 @@ func (m *MetricsMiddleware) initMetrics() error {
 
 	// HTTP request duration histogram
 	if m.isMetricEnabled("request_duration") {
-		m.requestDuration, err = m.provider.NewHistogramVec(metrics.MetricOptions{
-			Name:        "http_request_duration_seconds",
+		rm.requestDuration, err = m.sanitized.NewHistogramVec(metrics.MetricOptions{
+			Name:        prefix + "http_request_duration_seconds",
 			Help:        "HTTP request duration in seconds",
-			Labels:      []string{"method", "route", "status_code", "consumer_id"},
+			Labels:      m.labelNames("method", "route", "status_code", "consumer_id"),
 			Buckets:     metrics.GetDefaultBuckets("duration"),
-			ConstLabels: m.config.ConstLabels,
+			ConstLabels: constLabels,
 		})
 		if err != nil {
 			return fmt.Errorf("failed to create request duration histogram: %w", err)
@@ -155,12 +233,12 @@ func (m *MetricsMiddleware) initMetrics() error {
 	
 	// HTTP request size histogram
 	if m.isMetricEnabled("request_size") {
-		m.requestSize, err = m.provider.NewHistogramVec(metrics.MetricOptions{
-			Name:        "http_request_size_bytes",
+		rm.requestSize, err = m.sanitized.NewHistogramVec(metrics.MetricOptions{
+			Name:        prefix + "http_request_size_bytes",
 			Help:        "HTTP request size in bytes",
-			Labels:      []string{"method", "route", "consumer_id"},
+			Labels:      m.labelNames("method", "route", "consumer_id"),
 			Buckets:     metrics.GetDefaultBuckets("size"),
-			ConstLabels: m.config.ConstLabels,
+			ConstLabels: constLabels,
 		})
 		if err != nil {
 			return fmt.Errorf("failed to create request size histogram: %w", err)
@@ -169,37 +247,25 @@ func (m *MetricsMiddleware) initMetrics() error {
 
 	// HTTP response size histogram
 	if m.isMetricEnabled("response_size") {
-		m.responseSize, err = m.provider.NewHistogramVec(metrics.MetricOptions{
-			Name:        "http_response_size_bytes",
+		rm.responseSize, err = m.sanitized.NewHistogramVec(metrics.MetricOptions{
+			Name:        prefix + "http_response_size_bytes",
 			Help:        "HTTP response size in bytes",
-			Labels:      []string{"method", "route", "status_code", "consumer_id"},
+			Labels:      m.labelNames("method", "route", "status_code", "consumer_id"),
 			Buckets:     metrics.GetDefaultBuckets("size"),
-			ConstLabels: m.config.ConstLabels,
+			ConstLabels: constLabels,
 		})
 		if err != nil {
 			return fmt.Errorf("failed to create response size histogram: %w", err)
 		}
 	}
 	
-	// Active connections gauge
-	if m.isMetricEnabled("active_connections") {
-		m.activeConnections, err = m.provider.NewGauge(metrics.MetricOptions{
-			Name:        "http_active_connections",
-			Help:        "Number of active HTTP connections",
-			ConstLabels: m.config.ConstLabels,
-		})
-		if err != nil {
-			return fmt.Errorf("failed to create active connections gauge: %w", err)
-		}
-	}
-	
 	// Error counter
 	if m.isMetricEnabled("errors_total") {
-		m.errorsTotal, err = m.provider.NewCounterVec(metrics.MetricOptions{
-			Name:        "http_errors_total",
+		rm.errorsTotal, err = m.sanitized.NewCounterVec(metrics.MetricOptions{
+			Name:        prefix + "http_errors_total",
 			Help:        "Total number of HTTP errors",
-			Labels:      []string{"method", "route", "status_code", "error_type", "consumer_id"},
-			ConstLabels: m.config.ConstLabels,
+			Labels:      m.labelNames("method", "route", "status_code", "error_type", "consumer_id"),
+			ConstLabels: constLabels,
 		})
 		if err != nil {
 			return fmt.Errorf("failed to create errors counter: %w", err)
@@ -209,6 +275,24 @@ func (m *MetricsMiddleware) initMetrics() error {
 	return nil
 }
 
+// labelNames returns the label names of a request metric followed by the
+// labels of the configured label extractors
+func (m *MetricsMiddleware) labelNames(names ...string) []string {
+	for _, extractor := range m.labelExtractors {
+		names = append(names, extractor.label)
+	}
+	return names
+}
+
+// labelValues returns the values of a request metric's labels followed by
+// the values of the configured label extractors' labels
+func (m *MetricsMiddleware) labelValues(labels map[string]string, values ...string) []string {
+	for _, extractor := range m.labelExtractors {
+		values = append(values, labels[extractor.label])
+	}
+	return values
+}
+
 // isMetricEnabled checks if a specific metric is enabled
 func (m *MetricsMiddleware) isMetricEnabled(metricName string) bool {
 	if enabled, exists := m.config.EnabledMetrics[metricName]; exists {
@@ -279,17 +363,22 @@ func (m *MetricsMiddleware) Handler() func(http.Handler) http.Handler {
 				defer m.activeConnections.Dec()
 			}
 
-			// Wrap response writer to capture response details
+			// Wrap response writer and request body to capture their sizes
 			wrapper := &metricsResponseWrapper{
 				ResponseWriter: w,
 				statusCode:     http.StatusOK,
 			}
+			r, requestBody := CountRequestBody(r)
 
 			// Process request
 			next.ServeHTTP(wrapper, r)
 
 			// Calculate duration
 			duration := time.Since(start)
+			wrapper.requestSize = requestSize(r, requestBody)
+
+			// Make the response status available to label extractors
+			r = r.WithContext(context.WithValue(r.Context(), metricsStatusKey{}, wrapper.statusCode))
 
 			// Extract and normalize labels
 			labels := m.extractLabels(r, wrapper)
@@ -306,12 +395,17 @@ func (m *MetricsMiddleware) shouldSample() bool {
 	return true // For now, always sample
 }
 
+// SetRouteMatcher sets the function returning the route matching a request,
+// used for the route label and passed to label extractors
+func (m *MetricsMiddleware) SetRouteMatcher(matcher func(r *http.Request) *MetricsRoute) {
+	m.routeMatcher = matcher
+}
+
 // extractLabels extracts and normalizes labels from request and response
 func (m *MetricsMiddleware) extractLabels(r *http.Request, wrapper *metricsResponseWrapper) map[string]string {
-	// Check cache first
-	cacheKey := m.buildCacheKey(r, wrapper)
-	if cached, ok := m.labelCache.Load(cacheKey); ok {
-		return cached.(map[string]string)
+	var route *MetricsRoute
+	if m.routeMatcher != nil {
+		route = m.routeMatcher(r)
 	}
 
 	labels := make(map[string]string)
@@ -319,6 +413,9 @@ func (m *MetricsMiddleware) extractLabels(r *http.Request, wrapper *metricsRespo
 	// Basic labels
 	labels["method"] = r.Method
 	labels["route"] = m.getRouteID(r)
+	if route != nil && route.ID != "" {
+		labels["route"] = route.ID
+	}
 	labels["status_code"] = strconv.Itoa(wrapper.statusCode)
 
 	// Extract consumer_id from authentication context
@@ -334,40 +431,13 @@ func (m *MetricsMiddleware) extractLabels(r *http.Request, wrapper *metricsRespo
 		labels[key] = value
 	}
 
-	// Apply custom label extractors
-	for labelName, extractor := range m.config.LabelExtractors {
-		if value := m.applyLabelExtractor(extractor, r, wrapper); value != "" {
-			labels[labelName] = value
-		}
+	// Apply configured label extractors
+	for _, extractor := range m.labelExtractors {
+		labels[extractor.label] = extractor.value(r, route)
 	}
 
 	// Normalize and filter labels
-	labels = m.normalizeLabels(labels)
-
-	// Cache the result
-	m.labelCache.Store(cacheKey, labels)
-
-	return labels
-}
-
-// buildCacheKey builds a cache key for label extraction
-func (m *MetricsMiddleware) buildCacheKey(r *http.Request, wrapper *metricsResponseWrapper) string {
-	return r.Method + "|" + r.URL.Path + "|" + strconv.Itoa(wrapper.statusCode)
-}
-
-// applyLabelExtractor applies a custom label extractor
-func (m *MetricsMiddleware) applyLabelExtractor(extractor string, r *http.Request, wrapper *metricsResponseWrapper) string {
-	// Simple implementation - in production, you might want a more sophisticated system
-	switch extractor {
-	case "user_agent":
-		return r.Header.Get("User-Agent")
-	case "remote_addr":
-		return r.RemoteAddr
-	case "host":
-		return r.Host
-	default:
-		return ""
-	}
+	return m.normalizeLabels(labels)
 }
 
 // normalizeLabels normalizes and filters label values
@@ -434,30 +504,36 @@ func (m *MetricsMiddleware) recordMetricsSync(r *http.Request, wrapper *metricsR
 	statusCode := labels["status_code"]
 	consumerID := labels["consumer_id"]
 
+	// Routes of a route group are recorded in the metrics of the group
+	rm := &m.requestMetrics
+	if groupMetrics, ok := m.routeGroups[route]; ok {
+		rm = groupMetrics
+	}
+
 	// Record request count
-	if m.requestsTotal != nil {
-		m.requestsTotal.WithLabelValues(method, route, statusCode, consumerID).Inc()
+	if rm.requestsTotal != nil {
+		rm.requestsTotal.WithLabelValues(m.labelValues(labels, method, route, statusCode, consumerID)...).Inc()
 	}
 
 	// Record request duration
-	if m.requestDuration != nil {
-		m.requestDuration.WithLabelValues(method, route, statusCode, consumerID).Observe(duration.Seconds())
+	if rm.requestDuration != nil {
+		rm.requestDuration.WithLabelValues(m.labelValues(labels, method, route, statusCode, consumerID)...).Observe(duration.Seconds())
 	}
 
-	// Record request size if available
-	if m.requestSize != nil && r.ContentLength > 0 {
-		m.requestSize.WithLabelValues(method, route, consumerID).Observe(float64(r.ContentLength))
+	// Record request size, counted for chunked requests without Content-Length
+	if rm.requestSize != nil && wrapper.requestSize > 0 {
+		rm.requestSize.WithLabelValues(m.labelValues(labels, method, route, consumerID)...).Observe(float64(wrapper.requestSize))
 	}
 
 	// Record response size
-	if m.responseSize != nil && wrapper.responseSize > 0 {
-		m.responseSize.WithLabelValues(method, route, statusCode, consumerID).Observe(float64(wrapper.responseSize))
+	if rm.responseSize != nil && wrapper.responseSize > 0 {
+		rm.responseSize.WithLabelValues(m.labelValues(labels, method, route, statusCode, consumerID)...).Observe(float64(wrapper.responseSize))
 	}
 
 	// Record errors for 4xx and 5xx status codes
-	if m.errorsTotal != nil && wrapper.statusCode >= 400 {
+	if rm.errorsTotal != nil && wrapper.statusCode >= 400 {
 		errorType := m.getErrorType(wrapper.statusCode)
-		m.errorsTotal.WithLabelValues(method, route, statusCode, errorType, consumerID).Inc()
+		rm.errorsTotal.WithLabelValues(m.labelValues(labels, method, route, statusCode, errorType, consumerID)...).Inc()
 	}
 }
 
@@ -526,6 +602,38 @@ func (m *MetricsMiddleware) Close() error {
 	return nil
 }
 
+// DeleteRouteSeries deletes every series recorded for the given route so that
+// removed routes do not keep growing the metric cardinality. It returns the
+// number of series deleted.
+func (m *MetricsMiddleware) DeleteRouteSeries(routeID string) int {
+	route := m.normalizeLabelValue(routeID)
+	match := map[string]string{"route": route}
+
+	rm := &m.requestMetrics
+	if groupMetrics, ok := m.routeGroups[route]; ok {
+		rm = groupMetrics
+	}
+
+	deleted := 0
+	if rm.requestsTotal != nil {
+		deleted += rm.requestsTotal.DeletePartialMatch(match)
+	}
+	if rm.requestDuration != nil {
+		deleted += rm.requestDuration.DeletePartialMatch(match)
+	}
+	if rm.requestSize != nil {
+		deleted += rm.requestSize.DeletePartialMatch(match)
+	}
+	if rm.responseSize != nil {
+		deleted += rm.responseSize.DeletePartialMatch(match)
+	}
+	if rm.errorsTotal != nil {
+		deleted += rm.errorsTotal.DeletePartialMatch(match)
+	}
+
+	return deleted
+}
+
 // GetMetrics returns current metric values (for debugging/monitoring)
 func (m *MetricsMiddleware) GetMetrics() map[string]interface{} {
 	return map[string]interface{}{
@@ -548,6 +656,7 @@ func (m *MetricsMiddleware) GetProvider() metrics.Provider {
 type metricsResponseWrapper struct {
 	http.ResponseWriter
 	statusCode   int
+	requestSize  int64 // Set once the request is served
 	responseSize int64
 	wroteHeader  bool
 }
@@ -568,3 +677,22 @@ func (rw *metricsResponseWrapper) Write(b []byte) (int, error) {
 	rw.responseSize += int64(n)
 	return n, err
 }
+
+// Flush implements http.Flusher, so streamed responses reach the client as
+// they are written
+func (rw *metricsResponseWrapper) Flush() {
+	if !rw.wroteHeader {
+		rw.WriteHeader(http.StatusOK)
+	}
+	if flusher, ok := rw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack implements http.Hijacker. Bytes of hijacked connections aren't counted.
+func (rw *metricsResponseWrapper) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if hijacker, ok := rw.ResponseWriter.(http.Hijacker); ok {
+		return hijacker.Hijack()
+	}
+	return nil, nil, fmt.Errorf("response writer does not support hijacking")
+}
@@ -194,6 +194,28 @@ func ValidateMetricsConfig(cfg *MetricsConfig) error {
 		}
 	}
 
+	// Validate label extractors, which add labels to the request metrics
+	for name := range cfg.LabelExtractors {
+		if err := metrics.ValidateLabelName(name); err != nil {
+			return fmt.Errorf("invalid label extractor label name %s: %w", name, err)
+		}
+	}
+	if _, err := resolveLabelExtractors(cfg.LabelExtractors); err != nil {
+		return err
+	}
+
+	// Validate route groups, whose metrics are named after their subsystem
+	for name, group := range cfg.RouteGroups {
+		if err := metrics.ValidateMetricName(group.Subsystem); err != nil {
+			return fmt.Errorf("invalid subsystem of route group %s: %w", name, err)
+		}
+		for label := range group.Labels {
+			if err := metrics.ValidateLabelName(label); err != nil {
+				return fmt.Errorf("invalid label name %s of route group %s: %w", label, name, err)
+			}
+		}
+	}
+
 	return nil
 }
 
@@ -0,0 +1,183 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/songzhibin97/stargate/internal/auth"
+)
+
+// MetricsRoute describes the route matching a request for label extractors
+type MetricsRoute struct {
+	ID         string
+	Paths      []string // Path templates of the route
+	UpstreamID string
+}
+
+// LabelExtractor extracts metric labels from a request and the route it
+// matched. The route is nil when no route matched. Extractors run after the
+// response, whose status is available with MetricsStatusFromContext.
+type LabelExtractor interface {
+	Extract(r *http.Request, route *MetricsRoute) map[string]string
+}
+
+// LabelExtractorFunc adapts a function to the LabelExtractor interface
+type LabelExtractorFunc func(r *http.Request, route *MetricsRoute) map[string]string
+
+// Extract calls f(r, route)
+func (f LabelExtractorFunc) Extract(r *http.Request, route *MetricsRoute) map[string]string {
+	return f(r, route)
+}
+
+// Global registry for label extractors
+var (
+	labelExtractorRegistry = make(map[string]LabelExtractor)
+	labelExtractorMutex    sync.RWMutex
+)
+
+func init() {
+	RegisterLabelExtractor("method", LabelExtractorFunc(func(r *http.Request, route *MetricsRoute) map[string]string {
+		return map[string]string{"method": r.Method}
+	}))
+	RegisterLabelExtractor("status", LabelExtractorFunc(func(r *http.Request, route *MetricsRoute) map[string]string {
+		return map[string]string{"status_code": strconv.Itoa(MetricsStatusFromContext(r.Context()))}
+	}))
+	RegisterLabelExtractor("route_template", LabelExtractorFunc(func(r *http.Request, route *MetricsRoute) map[string]string {
+		return map[string]string{"route_template": routeTemplate(r, route)}
+	}))
+	RegisterLabelExtractor("consumer", LabelExtractorFunc(func(r *http.Request, route *MetricsRoute) map[string]string {
+		if consumer, ok := auth.GetConsumerFromContext(r.Context()); ok && consumer != nil {
+			return map[string]string{"consumer_id": consumer.ID}
+		}
+		return map[string]string{"consumer_id": "anonymous"}
+	}))
+	RegisterLabelExtractor("user_agent", LabelExtractorFunc(func(r *http.Request, route *MetricsRoute) map[string]string {
+		return map[string]string{"user_agent": r.Header.Get("User-Agent")}
+	}))
+	RegisterLabelExtractor("remote_addr", LabelExtractorFunc(func(r *http.Request, route *MetricsRoute) map[string]string {
+		return map[string]string{"remote_addr": r.RemoteAddr}
+	}))
+	RegisterLabelExtractor("host", LabelExtractorFunc(func(r *http.Request, route *MetricsRoute) map[string]string {
+		return map[string]string{"host": r.Host}
+	}))
+}
+
+// RegisterLabelExtractor registers a label extractor that configurations
+// reference by name in label_extractors
+func RegisterLabelExtractor(name string, extractor LabelExtractor) error {
+	labelExtractorMutex.Lock()
+	defer labelExtractorMutex.Unlock()
+
+	if _, exists := labelExtractorRegistry[name]; exists {
+		return fmt.Errorf("label extractor %s already registered", name)
+	}
+
+	labelExtractorRegistry[name] = extractor
+	return nil
+}
+
+// GetLabelExtractor retrieves a registered label extractor by name
+func GetLabelExtractor(name string) (LabelExtractor, error) {
+	labelExtractorMutex.RLock()
+	defer labelExtractorMutex.RUnlock()
+
+	extractor, exists := labelExtractorRegistry[name]
+	if !exists {
+		return nil, fmt.Errorf("label extractor %s not found", name)
+	}
+
+	return extractor, nil
+}
+
+// ListLabelExtractors returns all registered label extractor names
+func ListLabelExtractors() []string {
+	labelExtractorMutex.RLock()
+	defer labelExtractorMutex.RUnlock()
+
+	names := make([]string, 0, len(labelExtractorRegistry))
+	for name := range labelExtractorRegistry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// configuredLabelExtractor is a label extractor referenced by configuration,
+// recorded under the configured label name
+type configuredLabelExtractor struct {
+	label     string
+	extractor LabelExtractor
+}
+
+// resolveLabelExtractors looks up the extractors referenced by the label
+// extractors configuration, sorted by label name
+func resolveLabelExtractors(config map[string]string) ([]configuredLabelExtractor, error) {
+	labels := make([]string, 0, len(config))
+	for label := range config {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+
+	extractors := make([]configuredLabelExtractor, 0, len(labels))
+	for _, label := range labels {
+		switch label {
+		case "method", "route", "status_code", "error_type", "consumer_id":
+			return nil, fmt.Errorf("label extractor label name %s is reserved", label)
+		}
+		extractor, err := GetLabelExtractor(config[label])
+		if err != nil {
+			return nil, fmt.Errorf("invalid label extractor for label %s: %w", label, err)
+		}
+		extractors = append(extractors, configuredLabelExtractor{label: label, extractor: extractor})
+	}
+	return extractors, nil
+}
+
+// value returns the label value extracted from the request. An extractor
+// returning a single label provides its value under the configured name,
+// otherwise the label with the configured name is used.
+func (c configuredLabelExtractor) value(r *http.Request, route *MetricsRoute) string {
+	labels := c.extractor.Extract(r, route)
+	if value, ok := labels[c.label]; ok {
+		return value
+	}
+	if len(labels) == 1 {
+		for _, value := range labels {
+			return value
+		}
+	}
+	return ""
+}
+
+// metricsStatusKey is the context key of the response status
+type metricsStatusKey struct{}
+
+// MetricsStatusFromContext returns the response status recorded for label
+// extractors, or 0 before the response
+func MetricsStatusFromContext(ctx context.Context) int {
+	status, _ := ctx.Value(metricsStatusKey{}).(int)
+	return status
+}
+
+// routeTemplate returns the path template of the route matching the
+// request: the first template matching the request path, or the first
+// template, or the request path without a route
+func routeTemplate(r *http.Request, route *MetricsRoute) string {
+	if route == nil || len(route.Paths) == 0 {
+		return r.URL.Path
+	}
+	for _, template := range route.Paths {
+		if template == r.URL.Path {
+			return template
+		}
+		if prefix := strings.TrimSuffix(template, "*"); prefix != template && strings.HasPrefix(r.URL.Path, prefix) {
+			return template
+		}
+	}
+	return route.Paths[0]
+}
@@ -0,0 +1,93 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/songzhibin97/stargate/internal/metrics/driver/memory"
+)
+
+func TestLabelExtractorRegistry(t *testing.T) {
+	for _, name := range []string{"method", "status", "route_template", "consumer"} {
+		if _, err := GetLabelExtractor(name); err != nil {
+			t.Errorf("Expected built-in label extractor %s: %v", name, err)
+		}
+	}
+
+	if err := RegisterLabelExtractor("method", LabelExtractorFunc(func(r *http.Request, route *MetricsRoute) map[string]string {
+		return nil
+	})); err == nil {
+		t.Error("Expected error registering a duplicate label extractor")
+	}
+
+	if _, err := NewMetricsMiddleware(&MetricsConfig{
+		Enabled:         true,
+		LabelExtractors: map[string]string{"tenant": "missing"},
+	}, memory.NewProvider(memory.Options{})); err == nil {
+		t.Error("Expected error for an unknown label extractor")
+	}
+	if _, err := NewMetricsMiddleware(&MetricsConfig{
+		Enabled:         true,
+		LabelExtractors: map[string]string{"route": "route_template"},
+	}, memory.NewProvider(memory.Options{})); err == nil {
+		t.Error("Expected error for a reserved label name")
+	}
+}
+
+func TestMetricsMiddlewareLabelExtractors(t *testing.T) {
+	// Custom extractors register once per test binary
+	RegisterLabelExtractor("test_tenant", LabelExtractorFunc(func(r *http.Request, route *MetricsRoute) map[string]string {
+		return map[string]string{"tenant": r.Header.Get("X-Tenant"), "ignored": "value"}
+	}))
+	RegisterLabelExtractor("test_api_version", LabelExtractorFunc(func(r *http.Request, route *MetricsRoute) map[string]string {
+		return map[string]string{"version": strings.SplitN(strings.TrimPrefix(r.URL.Path, "/"), "/", 2)[0]}
+	}))
+
+	provider := memory.NewProvider(memory.Options{Namespace: "test"})
+	config := DefaultMetricsConfig()
+	config.LabelExtractors = map[string]string{
+		"tenant":      "test_tenant",
+		"api_version": "test_api_version",
+		"template":    "route_template",
+		"status":      "status",
+		"agent":       "user_agent",
+	}
+	config.SensitiveLabels = []string{"agent"}
+	config.MaxLabelLength = 10
+
+	middleware, err := NewMetricsMiddleware(config, provider)
+	if err != nil {
+		t.Fatalf("Failed to create middleware: %v", err)
+	}
+	defer middleware.Close()
+
+	middleware.SetRouteMatcher(func(r *http.Request) *MetricsRoute {
+		return &MetricsRoute{ID: "orders", Paths: []string{"/v1/orders/*", "/v2/orders/*"}}
+	})
+	handler := middleware.Handler()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	req := httptest.NewRequest("POST", "/v2/orders/42", nil)
+	req.Header.Set("X-Tenant", "acme-corporation")
+	req.Header.Set("User-Agent", "test-agent")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	// Values are truncated to MaxLabelLength and sensitive labels are blank
+	labels := map[string]string{
+		"method":      "POST",
+		"route":       "orders",
+		"status_code": "201",
+		"consumer_id": "anonymous",
+		"agent":       "",
+		"api_version": "v2",
+		"status":      "201",
+		"template":    "/v2/orders",
+		"tenant":      "acme-corpo",
+	}
+	if got := provider.GetCounterValue("http_requests_total", labels); got != 1 {
+		t.Errorf("Expected 1 request with extracted labels, got %f", got)
+	}
+}
@@ -1,12 +1,14 @@
 package middleware
 
 import (
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
 
 	"github.com/songzhibin97/stargate/internal/config"
+	"github.com/songzhibin97/stargate/internal/metrics/driver/memory"
 	"github.com/songzhibin97/stargate/internal/metrics/driver/prometheus"
 	"github.com/songzhibin97/stargate/pkg/metrics"
 )
@@ -128,6 +130,141 @@ func TestMetricsMiddlewareHTTPHandler(t *testing.T) {
 	}
 }
 
+func TestMetricsMiddlewareDeleteRouteSeries(t *testing.T) {
+	provider, err := prometheus.NewProvider(prometheus.Options{
+		Namespace: "test",
+		Subsystem: "routes",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+
+	middleware, err := NewMetricsMiddleware(DefaultMetricsConfig(), provider)
+	if err != nil {
+		t.Fatalf("Failed to create middleware: %v", err)
+	}
+	defer middleware.Close()
+
+	handler := middleware.Handler()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	for _, path := range []string{"/removed", "/kept"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+
+	if deleted := middleware.DeleteRouteSeries("/removed"); deleted == 0 {
+		t.Error("Expected series of removed route to be deleted")
+	}
+
+	metricsW := httptest.NewRecorder()
+	provider.Handler().ServeHTTP(metricsW, httptest.NewRequest("GET", "/metrics", nil))
+	body := metricsW.Body.String()
+
+	if strings.Contains(body, `route="/removed"`) {
+		t.Error("Expected removed route to disappear from metrics output")
+	}
+	if !strings.Contains(body, `route="/kept"`) {
+		t.Error("Expected other routes to remain in metrics output")
+	}
+}
+
+func TestMetricsMiddlewareRecordsRequests(t *testing.T) {
+	provider := memory.NewProvider(memory.Options{
+		Namespace: "test",
+	})
+
+	middleware, err := NewMetricsMiddleware(DefaultMetricsConfig(), provider)
+	if err != nil {
+		t.Fatalf("Failed to create middleware: %v", err)
+	}
+	defer middleware.Close()
+
+	handler := middleware.Handler()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	for _, path := range []string{"/ok", "/ok", "/missing"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+
+	okLabels := map[string]string{"method": "GET", "route": "/ok", "status_code": "200", "consumer_id": "anonymous"}
+	if got := provider.GetCounterValue("http_requests_total", okLabels); got != 2 {
+		t.Errorf("Expected 2 requests for /ok, got %f", got)
+	}
+	if got := provider.GetHistogramCount("http_request_duration_seconds", okLabels); got != 2 {
+		t.Errorf("Expected 2 duration observations for /ok, got %d", got)
+	}
+
+	errorLabels := map[string]string{"method": "GET", "route": "/missing", "status_code": "404", "error_type": "client_error", "consumer_id": "anonymous"}
+	if got := provider.GetCounterValue("http_errors_total", errorLabels); got != 1 {
+		t.Errorf("Expected 1 client error for /missing, got %f", got)
+	}
+}
+
+func TestMetricsMiddlewareRouteGroups(t *testing.T) {
+	provider, err := prometheus.NewProvider(prometheus.Options{
+		Namespace: "test",
+		Subsystem: "groups",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+
+	config := DefaultMetricsConfig()
+	config.EnabledMetrics["request_size"] = false
+	config.RouteGroups = map[string]MetricsRouteGroup{
+		"internal": {
+			Routes:    []string{"reports"},
+			Subsystem: "internal",
+			Labels:    map[string]string{"audience": "internal"},
+		},
+	}
+	middleware, err := NewMetricsMiddleware(config, provider)
+	if err != nil {
+		t.Fatalf("Failed to create middleware: %v", err)
+	}
+	defer middleware.Close()
+
+	middleware.SetRouteMatcher(func(r *http.Request) *MetricsRoute {
+		return &MetricsRoute{ID: strings.TrimPrefix(r.URL.Path, "/")}
+	})
+	handler := middleware.Handler()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	for _, path := range []string{"/reports", "/reports", "/orders"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", path, strings.NewReader("body")))
+	}
+
+	metricsW := httptest.NewRecorder()
+	provider.Handler().ServeHTTP(metricsW, httptest.NewRequest("GET", "/metrics", nil))
+	body := metricsW.Body.String()
+
+	// Routes of the group are recorded under its subsystem and labels only
+	grouped := `test_groups_internal_http_requests_total{audience="internal",consumer_id="anonymous",method="POST",route="reports",status_code="200"} 2`
+	if !strings.Contains(body, grouped) {
+		t.Errorf("Expected the group series %s in metrics output", grouped)
+	}
+	if strings.Contains(body, `test_groups_http_requests_total{consumer_id="anonymous",method="POST",route="reports"`) {
+		t.Error("Expected routes of the group to be missing from the node metrics")
+	}
+	ungrouped := `test_groups_http_requests_total{consumer_id="anonymous",method="POST",route="orders",status_code="200"} 1`
+	if !strings.Contains(body, ungrouped) {
+		t.Errorf("Expected the node series %s in metrics output", ungrouped)
+	}
+
+	// Disabled metrics are disabled for the groups too
+	if strings.Contains(body, "test_groups_internal_http_request_size_bytes") {
+		t.Error("Expected no request size metric of the group when disabled")
+	}
+
+	if deleted := middleware.DeleteRouteSeries("reports"); deleted == 0 {
+		t.Error("Expected series of the group's route to be deleted")
+	}
+}
+
 func TestPrometheusMiddlewareAdapter(t *testing.T) {
 	// Test backward compatibility adapter
 	prometheusConfig := &config.PrometheusConfig{
@@ -306,3 +443,44 @@ func BenchmarkMetricsMiddleware(b *testing.B) {
 		}
 	})
 }
+
+func TestMetricsMiddlewareCountsStreamedBytes(t *testing.T) {
+	provider := memory.NewProvider(memory.Options{
+		Namespace: "test",
+	})
+
+	middleware, err := NewMetricsMiddleware(DefaultMetricsConfig(), provider)
+	if err != nil {
+		t.Fatalf("Failed to create middleware: %v", err)
+	}
+	defer middleware.Close()
+
+	// A chunked upload answered with a streamed response, neither has a Content-Length
+	handler := middleware.Handler()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		for _, chunk := range []string{"data: 1\n\n", "data: 2\n\n", "data: 3\n\n"} {
+			w.Write([]byte(chunk))
+			w.(http.Flusher).Flush()
+		}
+	}))
+
+	req := httptest.NewRequest("POST", "/events", io.MultiReader(strings.NewReader("hello "), strings.NewReader("world")))
+	if req.ContentLength != -1 {
+		t.Fatalf("Expected a request of unknown length, got %d", req.ContentLength)
+	}
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if !rr.Flushed {
+		t.Error("Expected flushes to reach the client")
+	}
+
+	requestLabels := map[string]string{"method": "POST", "route": "/events", "consumer_id": "anonymous"}
+	if got := provider.GetHistogramSum("http_request_size_bytes", requestLabels); got != 11 {
+		t.Errorf("Expected a request size of 11 bytes, got %f", got)
+	}
+	responseLabels := map[string]string{"method": "POST", "route": "/events", "status_code": "200", "consumer_id": "anonymous"}
+	if got := provider.GetHistogramSum("http_response_size_bytes", responseLabels); got != 27 {
+		t.Errorf("Expected a response size of 27 bytes, got %f", got)
+	}
+}
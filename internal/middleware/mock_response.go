@@ -1,7 +1,6 @@
 package middleware
 
 import (
-	"io"
 	"net/http"
 	"os"
 	"regexp"
@@ -79,8 +78,28 @@ func (m *MockResponseMiddleware) Handler() func(http.Handler) http.Handler {
 			// Get route ID from context
 			routeID := m.getRouteID(r)
 
+			// The body is read once, when a rule matches on it, into a
+			// pooled buffer released once the request is done
+			var body *pooledBody
+			defer func() {
+				if body != nil {
+					body.Release()
+				}
+			}()
+			readBody := func() ([]byte, error) {
+				if body == nil {
+					var err error
+					if body, err = readPooledBody(r.Body); err != nil {
+						return nil, err
+					}
+					// Restore body for potential future use
+					r.Body = body.Reader()
+				}
+				return body.Bytes(), nil
+			}
+
 			// Try to match mock rules
-			result := m.matchMockRules(r, routeID)
+			result := m.matchMockRules(r, routeID, readBody)
 			if result.Matched {
 				// Serve mock response
 				m.serveMockResponse(w, r, result)
@@ -138,13 +157,13 @@ func (m *MockResponseMiddleware) compileRulesUnsafe() error {
 }
 
 // matchMockRules tries to match request against mock rules
-func (m *MockResponseMiddleware) matchMockRules(r *http.Request, routeID string) *MockMatchResult {
+func (m *MockResponseMiddleware) matchMockRules(r *http.Request, routeID string, readBody func() ([]byte, error)) *MockMatchResult {
 	m.mu.RLock()
 
 	// Check per-route rules first
 	if routeConfig, exists := m.config.PerRoute[routeID]; exists && routeConfig.Enabled {
 		for _, rule := range routeConfig.Rules {
-			if rule.Enabled && m.matchRule(r, &rule) {
+			if rule.Enabled && m.matchRule(r, &rule, readBody) {
 				m.mu.RUnlock()
 				m.updateMatchedStats(rule.ID)
 				return &MockMatchResult{
@@ -177,8 +196,9 @@ func (m *MockResponseMiddleware) matchMockRules(r *http.Request, routeID string)
 	return &MockMatchResult{Matched: false}
 }
 
-// matchRule checks if a request matches a mock rule
-func (m *MockResponseMiddleware) matchRule(r *http.Request, rule *config.MockRule) bool {
+// matchRule checks if a request matches a mock rule, reading the body with
+// readBody if the rule matches on it
+func (m *MockResponseMiddleware) matchRule(r *http.Request, rule *config.MockRule, readBody func() ([]byte, error)) bool {
 	// Check HTTP methods
 	if len(rule.Conditions.Methods) > 0 {
 		methodMatched := false
@@ -225,13 +245,10 @@ func (m *MockResponseMiddleware) matchRule(r *http.Request, rule *config.MockRul
 
 	// Check request body if specified
 	if rule.Conditions.Body != "" {
-		body, err := io.ReadAll(r.Body)
+		body, err := readBody()
 		if err != nil {
 			return false
 		}
-		// Restore body for potential future use
-		r.Body = io.NopCloser(strings.NewReader(string(body)))
-		
 		if string(body) != rule.Conditions.Body {
 			return false
 		}
@@ -0,0 +1,354 @@
+package middleware
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/songzhibin97/stargate/internal/config"
+	"github.com/songzhibin97/stargate/internal/jsonschema"
+)
+
+const defaultSchemaValidationMaxBodySize = 1024 * 1024
+
+// routeSchemas are the compiled schemas of a route
+type routeSchemas struct {
+	request  *jsonschema.Schema
+	response *jsonschema.Schema
+}
+
+// SchemaValidationMiddleware validates the JSON bodies of requests and
+// responses of configured routes against JSON Schemas
+type SchemaValidationMiddleware struct {
+	config *config.SchemaValidationConfig
+	routes map[string]*routeSchemas
+	mu     sync.RWMutex
+
+	// routeMatcher returns the ID of the route matching a request
+	routeMatcher func(r *http.Request) string
+
+	// Statistics
+	validated        int64
+	requestFailures  int64
+	responseFailures int64
+	rejected         int64
+}
+
+// NewSchemaValidationMiddleware creates a new schema validation middleware
+func NewSchemaValidationMiddleware(cfg *config.SchemaValidationConfig) (*SchemaValidationMiddleware, error) {
+	m := &SchemaValidationMiddleware{}
+	if err := m.UpdateConfig(cfg); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// UpdateConfig compiles the schemas of the configuration and applies them.
+// On error the previous schemas stay in use.
+func (m *SchemaValidationMiddleware) UpdateConfig(cfg *config.SchemaValidationConfig) error {
+	apply, err := m.PrepareConfig(cfg)
+	if err != nil {
+		return err
+	}
+	apply()
+	return nil
+}
+
+// PrepareConfig compiles the schemas of the configuration and returns a
+// function applying them
+func (m *SchemaValidationMiddleware) PrepareConfig(cfg *config.SchemaValidationConfig) (func(), error) {
+	routes := make(map[string]*routeSchemas, len(cfg.Routes))
+	for routeID, route := range cfg.Routes {
+		request, response, err := config.CompileRouteSchemas(route)
+		if err != nil {
+			return nil, fmt.Errorf("invalid schema of route %s: %w", routeID, err)
+		}
+		routes[routeID] = &routeSchemas{request: request, response: response}
+	}
+
+	return func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+
+		m.config = cfg
+		m.routes = routes
+	}, nil
+}
+
+// SetRouteMatcher sets the function returning the ID of the route matching a
+// request, which selects the route's schemas. Without a matcher the route ID
+// is taken from the request context.
+func (m *SchemaValidationMiddleware) SetRouteMatcher(matcher func(r *http.Request) string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.routeMatcher = matcher
+}
+
+// schemas returns the schemas of the request's route and the body size limit
+func (m *SchemaValidationMiddleware) schemas(r *http.Request) (*routeSchemas, int64) {
+	m.mu.RLock()
+	matcher := m.routeMatcher
+	routes := m.routes
+	enabled := m.config.Enabled
+	maxBodySize := m.config.MaxBodySize
+	m.mu.RUnlock()
+
+	if !enabled || len(routes) == 0 {
+		return nil, 0
+	}
+	if maxBodySize <= 0 {
+		maxBodySize = defaultSchemaValidationMaxBodySize
+	}
+
+	var routeID string
+	if matcher != nil {
+		routeID = matcher(r)
+	} else {
+		routeID, _ = r.Context().Value("route_id").(string)
+	}
+	return routes[routeID], maxBodySize
+}
+
+// Handler returns the HTTP middleware handler
+func (m *SchemaValidationMiddleware) Handler() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			schemas, maxBodySize := m.schemas(r)
+			if schemas == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if schemas.request != nil && !m.validateRequest(w, r, schemas.request, maxBodySize) {
+				return
+			}
+
+			if schemas.response == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			wrapper := &schemaResponseWriter{ResponseWriter: w, maxBodySize: maxBodySize}
+			next.ServeHTTP(wrapper, r)
+			m.validateResponse(wrapper, schemas.response)
+		})
+	}
+}
+
+// validateRequest validates the request body, buffering it for forwarding.
+// Requests without a body aren't validated. It writes the error response and
+// returns false if the request is rejected.
+func (m *SchemaValidationMiddleware) validateRequest(w http.ResponseWriter, r *http.Request, schema *jsonschema.Schema, maxBodySize int64) bool {
+	if r.Body == nil || r.Body == http.NoBody || r.ContentLength == 0 {
+		return true
+	}
+	if !isJSONContentType(r.Header.Get("Content-Type")) {
+		m.reject(w, http.StatusUnsupportedMediaType, "request body must be JSON")
+		return false
+	}
+	if r.ContentLength > maxBodySize {
+		m.reject(w, http.StatusRequestEntityTooLarge, "request body too large for schema validation")
+		return false
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxBodySize+1))
+	if err != nil {
+		m.reject(w, http.StatusBadRequest, "failed to read request body")
+		return false
+	}
+	if int64(len(body)) > maxBodySize {
+		m.reject(w, http.StatusRequestEntityTooLarge, "request body too large for schema validation")
+		return false
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	r.ContentLength = int64(len(body))
+	if len(body) == 0 {
+		return true
+	}
+
+	errs, err := schema.ValidateJSON(body)
+	if err != nil {
+		m.reject(w, http.StatusBadRequest, "request body is not valid JSON")
+		return false
+	}
+	if len(errs) > 0 {
+		m.mu.Lock()
+		m.requestFailures++
+		m.mu.Unlock()
+		writeSchemaErrors(w, http.StatusBadRequest, "request body does not match schema", errs)
+		return false
+	}
+
+	m.mu.Lock()
+	m.validated++
+	m.mu.Unlock()
+	return true
+}
+
+// validateResponse validates a buffered response and writes it, or a 502
+// if it doesn't match the schema
+func (m *SchemaValidationMiddleware) validateResponse(w *schemaResponseWriter, schema *jsonschema.Schema) {
+	if w.passthrough || w.statusCode == 0 {
+		return
+	}
+
+	body := w.body.Bytes()
+	if len(body) > 0 {
+		errs, err := schema.ValidateJSON(body)
+		if err != nil || len(errs) > 0 {
+			m.mu.Lock()
+			m.responseFailures++
+			m.mu.Unlock()
+
+			header := w.ResponseWriter.Header()
+			header.Del("Content-Length")
+			header.Del("Content-Encoding")
+			header.Del("ETag")
+			if err != nil {
+				writeSchemaErrors(w.ResponseWriter, http.StatusBadGateway, "upstream response is not valid JSON", nil)
+			} else {
+				writeSchemaErrors(w.ResponseWriter, http.StatusBadGateway, "upstream response does not match schema", errs)
+			}
+			return
+		}
+
+		m.mu.Lock()
+		m.validated++
+		m.mu.Unlock()
+	}
+
+	w.ResponseWriter.WriteHeader(w.statusCode)
+	w.ResponseWriter.Write(body)
+}
+
+// reject counts a request rejected without validation and writes the error
+func (m *SchemaValidationMiddleware) reject(w http.ResponseWriter, statusCode int, message string) {
+	m.mu.Lock()
+	m.rejected++
+	m.mu.Unlock()
+	writeSchemaErrors(w, statusCode, message, nil)
+}
+
+// writeSchemaErrors writes a JSON error response listing validation errors
+func writeSchemaErrors(w http.ResponseWriter, statusCode int, message string, errs []jsonschema.ValidationError) {
+	response := map[string]interface{}{"error": message}
+	if len(errs) > 0 {
+		response["errors"] = errs
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(response)
+}
+
+// isJSONContentType reports whether a Content-Type is JSON, including
+// structured syntax types such as application/problem+json
+func isJSONContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// GetStats returns middleware statistics
+func (m *SchemaValidationMiddleware) GetStats() map[string]interface{} {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return map[string]interface{}{
+		"enabled":           m.config.Enabled,
+		"routes":            len(m.routes),
+		"validated":         m.validated,
+		"request_failures":  m.requestFailures,
+		"response_failures": m.responseFailures,
+		"rejected":          m.rejected,
+	}
+}
+
+// schemaResponseWriter buffers a response for validation. Responses that
+// aren't successful JSON, are compressed or outgrow the body size limit are
+// written through unvalidated.
+type schemaResponseWriter struct {
+	http.ResponseWriter
+	maxBodySize int64
+	statusCode  int
+	body        bytes.Buffer
+	passthrough bool // The response isn't validated and is written through
+}
+
+// WriteHeader decides whether the response is validated
+func (w *schemaResponseWriter) WriteHeader(statusCode int) {
+	if w.statusCode != 0 {
+		return
+	}
+	w.statusCode = statusCode
+	if !w.validates() {
+		w.passthrough = true
+		w.ResponseWriter.WriteHeader(statusCode)
+	}
+}
+
+// validates reports whether the response is buffered for validation
+func (w *schemaResponseWriter) validates() bool {
+	if w.statusCode < 200 || w.statusCode > 299 || w.statusCode == http.StatusNoContent {
+		return false
+	}
+	header := w.ResponseWriter.Header()
+	if !isJSONContentType(header.Get("Content-Type")) {
+		return false
+	}
+	if encoding := header.Get("Content-Encoding"); encoding != "" && encoding != "identity" {
+		return false
+	}
+	if length, err := strconv.ParseInt(header.Get("Content-Length"), 10, 64); err == nil && length > w.maxBodySize {
+		return false
+	}
+	return true
+}
+
+// Write buffers the body, writing it through once it outgrows the limit
+func (w *schemaResponseWriter) Write(p []byte) (int, error) {
+	if w.statusCode == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.passthrough {
+		return w.ResponseWriter.Write(p)
+	}
+	if int64(w.body.Len()+len(p)) > w.maxBodySize {
+		w.passthrough = true
+		w.ResponseWriter.WriteHeader(w.statusCode)
+		if _, err := w.ResponseWriter.Write(w.body.Bytes()); err != nil {
+			return 0, err
+		}
+		w.body.Reset()
+		return w.ResponseWriter.Write(p)
+	}
+	return w.body.Write(p)
+}
+
+// Flush implements http.Flusher for responses written through
+func (w *schemaResponseWriter) Flush() {
+	if !w.passthrough {
+		return
+	}
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack implements http.Hijacker
+func (w *schemaResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if hijacker, ok := w.ResponseWriter.(http.Hijacker); ok {
+		w.passthrough = true
+		return hijacker.Hijack()
+	}
+	return nil, nil, fmt.Errorf("response writer does not support hijacking")
+}
@@ -0,0 +1,172 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/songzhibin97/stargate/internal/config"
+)
+
+func newSchemaValidationMiddleware(t *testing.T, route config.RouteSchemaConfig) *SchemaValidationMiddleware {
+	t.Helper()
+	m, err := NewSchemaValidationMiddleware(&config.SchemaValidationConfig{
+		Enabled:     true,
+		Routes:      map[string]config.RouteSchemaConfig{"orders": route},
+		MaxBodySize: 64,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create middleware: %v", err)
+	}
+	m.SetRouteMatcher(func(r *http.Request) string {
+		if strings.HasPrefix(r.URL.Path, "/orders") {
+			return "orders"
+		}
+		return ""
+	})
+	return m
+}
+
+func TestSchemaValidationMiddleware_Request(t *testing.T) {
+	m := newSchemaValidationMiddleware(t, config.RouteSchemaConfig{
+		Request: map[string]interface{}{
+			"type":     "object",
+			"required": []interface{}{"quantity"},
+			"properties": map[string]interface{}{
+				"quantity": map[string]interface{}{"type": "integer", "minimum": 1},
+			},
+		},
+	})
+	handler := m.Handler()(echoBody())
+
+	tests := []struct {
+		name        string
+		path        string
+		contentType string
+		body        string
+		expected    int
+	}{
+		{"valid", "/orders", "application/json", `{"quantity":2}`, http.StatusOK},
+		{"invalid", "/orders", "application/json", `{"quantity":0}`, http.StatusBadRequest},
+		{"malformed", "/orders", "application/json", `{"quantity":`, http.StatusBadRequest},
+		{"not JSON", "/orders", "text/plain", `quantity=2`, http.StatusUnsupportedMediaType},
+		{"too large", "/orders", "application/json", `{"quantity":2,"note":"` + strings.Repeat("x", 64) + `"}`, http.StatusRequestEntityTooLarge},
+		{"no body", "/orders", "", ``, http.StatusOK},
+		{"other route", "/users", "application/json", `{"quantity":0}`, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", tt.path, strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if rr.Code != tt.expected {
+				t.Fatalf("Expected status %d, got %d: %s", tt.expected, rr.Code, rr.Body.String())
+			}
+			if tt.expected == http.StatusOK && rr.Body.String() != tt.body {
+				t.Errorf("Expected the body to be forwarded, got %q", rr.Body.String())
+			}
+		})
+	}
+
+	// Validation errors are listed with the path of the invalid value
+	req := httptest.NewRequest("POST", "/orders", strings.NewReader(`{"quantity":"two"}`))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	var response struct {
+		Error  string `json:"error"`
+		Errors []struct {
+			Path    string `json:"path"`
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to decode error response: %v", err)
+	}
+	if len(response.Errors) != 1 || response.Errors[0].Path != "/quantity" {
+		t.Errorf("Expected an error at /quantity, got %+v", response)
+	}
+
+	stats := m.GetStats()
+	if stats["validated"] != int64(1) || stats["request_failures"] != int64(2) || stats["rejected"] != int64(3) {
+		t.Errorf("Unexpected stats: %v", stats)
+	}
+}
+
+func TestSchemaValidationMiddleware_Response(t *testing.T) {
+	m := newSchemaValidationMiddleware(t, config.RouteSchemaConfig{
+		Response: map[string]interface{}{
+			"type":     "object",
+			"required": []interface{}{"id"},
+		},
+	})
+
+	serve := func(status int, contentType, body string) *httptest.ResponseRecorder {
+		handler := m.Handler()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", contentType)
+			w.WriteHeader(status)
+			w.Write([]byte(body))
+		}))
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest("GET", "/orders/1", nil))
+		return rr
+	}
+
+	rr := serve(http.StatusOK, "application/json", `{"id":"1"}`)
+	if rr.Code != http.StatusOK || rr.Body.String() != `{"id":"1"}` {
+		t.Errorf("Expected a valid response to pass, got %d %q", rr.Code, rr.Body.String())
+	}
+
+	rr = serve(http.StatusOK, "application/json", `{"name":"order"}`)
+	if rr.Code != http.StatusBadGateway || !strings.Contains(rr.Body.String(), "upstream response does not match schema") {
+		t.Errorf("Expected 502 for an invalid response, got %d %q", rr.Code, rr.Body.String())
+	}
+
+	// Errors, non-JSON and oversized responses aren't validated
+	rr = serve(http.StatusNotFound, "application/json", `{"error":"not found"}`)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected error responses to pass unvalidated, got %d", rr.Code)
+	}
+	rr = serve(http.StatusOK, "text/plain", `order`)
+	if rr.Code != http.StatusOK || rr.Body.String() != "order" {
+		t.Errorf("Expected non-JSON responses to pass unvalidated, got %d %q", rr.Code, rr.Body.String())
+	}
+	large := `{"name":"` + strings.Repeat("x", 64) + `"}`
+	rr = serve(http.StatusOK, "application/json", large)
+	if rr.Code != http.StatusOK || rr.Body.String() != large {
+		t.Errorf("Expected oversized responses to pass unvalidated, got %d", rr.Code)
+	}
+}
+
+func TestSchemaValidationMiddleware_UpdateConfig(t *testing.T) {
+	m := newSchemaValidationMiddleware(t, config.RouteSchemaConfig{
+		Request: map[string]interface{}{"type": "object"},
+	})
+
+	err := m.UpdateConfig(&config.SchemaValidationConfig{
+		Enabled: true,
+		Routes: map[string]config.RouteSchemaConfig{
+			"orders": {Request: map[string]interface{}{"type": "decimal"}},
+		},
+	})
+	if err == nil {
+		t.Fatal("Expected an invalid schema to be rejected")
+	}
+
+	// The previous schema stays in use
+	req := httptest.NewRequest("POST", "/orders", strings.NewReader(`[]`))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	m.Handler()(echoBody()).ServeHTTP(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected the previous schema to reject the request, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,319 @@
+package middleware
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/songzhibin97/stargate/internal/config"
+)
+
+const (
+	// defaultHSTSMaxAge is the default Strict-Transport-Security max-age
+	defaultHSTSMaxAge = 365 * 24 * time.Hour
+
+	defaultContentTypeOptions = "nosniff"
+	defaultFrameOptions       = "DENY"
+	defaultReferrerPolicy     = "strict-origin-when-cross-origin"
+)
+
+// defaultRemovedHeaders are upstream headers leaking server implementation details
+var defaultRemovedHeaders = []string{
+	"Server",
+	"X-Powered-By",
+	"X-AspNet-Version",
+	"X-AspNetMvc-Version",
+	"X-Runtime",
+}
+
+// hopByHopHeaders are connection-specific headers that must not be forwarded
+var hopByHopHeaders = []string{
+	"Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Connection",
+	"Te",
+	"Trailer",
+	"Upgrade",
+}
+
+// SecurityHeadersMiddleware injects security headers into responses and
+// strips hop-by-hop and sensitive upstream headers
+type SecurityHeadersMiddleware struct {
+	config *config.SecurityHeadersConfig
+	mu     sync.RWMutex
+
+	// Compiled header policy
+	headers map[string]string   // headers injected into every response
+	remove  []string            // headers stripped from every response
+	hsts    string              // Strict-Transport-Security value, empty if disabled
+	routes  map[string]map[string]string
+
+	// Statistics
+	requestsProcessed int64
+	headersInjected   int64
+	headersRemoved    int64
+}
+
+// NewSecurityHeadersMiddleware creates a new security headers middleware
+func NewSecurityHeadersMiddleware(cfg *config.SecurityHeadersConfig) *SecurityHeadersMiddleware {
+	m := &SecurityHeadersMiddleware{}
+	m.UpdateConfig(cfg)
+	return m
+}
+
+// UpdateConfig updates the middleware configuration
+func (m *SecurityHeadersMiddleware) UpdateConfig(cfg *config.SecurityHeadersConfig) {
+	headers := map[string]string{
+		"X-Content-Type-Options": valueOrDefault(cfg.ContentTypeOptions, defaultContentTypeOptions),
+		"X-Frame-Options":        valueOrDefault(cfg.FrameOptions, defaultFrameOptions),
+		"Referrer-Policy":        valueOrDefault(cfg.ReferrerPolicy, defaultReferrerPolicy),
+	}
+	if cfg.ContentSecurityPolicy != "" {
+		headers["Content-Security-Policy"] = cfg.ContentSecurityPolicy
+	}
+	if cfg.PermissionsPolicy != "" {
+		headers["Permissions-Policy"] = cfg.PermissionsPolicy
+	}
+	for key, value := range cfg.CustomHeaders {
+		headers[http.CanonicalHeaderKey(key)] = value
+	}
+
+	// Headers to strip, except those explicitly kept or injected
+	removed := cfg.RemoveHeaders
+	if len(removed) == 0 {
+		removed = defaultRemovedHeaders
+	}
+	keep := make(map[string]bool, len(cfg.KeepHeaders))
+	for _, key := range cfg.KeepHeaders {
+		keep[http.CanonicalHeaderKey(key)] = true
+	}
+	remove := make([]string, 0, len(hopByHopHeaders)+len(removed))
+	for _, key := range append(append([]string{}, hopByHopHeaders...), removed...) {
+		key = http.CanonicalHeaderKey(key)
+		if _, injected := headers[key]; !keep[key] && !injected {
+			remove = append(remove, key)
+		}
+	}
+
+	routes := make(map[string]map[string]string, len(cfg.PerRoute))
+	for routeID, route := range cfg.PerRoute {
+		if route.Disabled {
+			routes[routeID] = nil
+			continue
+		}
+		routeHeaders := make(map[string]string, len(headers)+len(route.Headers))
+		for key, value := range headers {
+			routeHeaders[key] = value
+		}
+		for key, value := range route.Headers {
+			key = http.CanonicalHeaderKey(key)
+			if value == "" {
+				delete(routeHeaders, key)
+				continue
+			}
+			routeHeaders[key] = value
+		}
+		routes[routeID] = routeHeaders
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.config = cfg
+	m.headers = headers
+	m.remove = remove
+	m.hsts = buildHSTSValue(cfg.HSTS)
+	m.routes = routes
+}
+
+// buildHSTSValue builds the Strict-Transport-Security header value
+func buildHSTSValue(hsts config.HSTSConfig) string {
+	if !hsts.Enabled {
+		return ""
+	}
+
+	maxAge := hsts.MaxAge
+	if maxAge <= 0 {
+		maxAge = defaultHSTSMaxAge
+	}
+
+	value := fmt.Sprintf("max-age=%d", int64(maxAge.Seconds()))
+	if hsts.IncludeSubDomains {
+		value += "; includeSubDomains"
+	}
+	if hsts.Preload {
+		value += "; preload"
+	}
+	return value
+}
+
+// valueOrDefault returns value, or fallback if value is empty
+func valueOrDefault(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
+
+// Handler returns the HTTP middleware handler
+func (m *SecurityHeadersMiddleware) Handler() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			m.mu.RLock()
+			enabled := m.config.Enabled
+			m.mu.RUnlock()
+
+			// Skip if middleware is disabled
+			if !enabled {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			headers, skip := m.headersForRoute(r)
+			if skip {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			writer := &securityHeadersWriter{
+				ResponseWriter: w,
+				middleware:     m,
+				headers:        headers,
+				tls:            r.TLS != nil,
+			}
+
+			m.mu.Lock()
+			m.requestsProcessed++
+			m.mu.Unlock()
+
+			next.ServeHTTP(writer, r)
+		})
+	}
+}
+
+// headersForRoute returns the headers injected for the request's route and
+// whether the route has security headers disabled
+func (m *SecurityHeadersMiddleware) headersForRoute(r *http.Request) (map[string]string, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if routeID, ok := r.Context().Value("route_id").(string); ok {
+		if headers, exists := m.routes[routeID]; exists {
+			return headers, headers == nil
+		}
+	}
+	return m.headers, false
+}
+
+// applyHeaders strips and injects headers on a response about to be sent
+func (m *SecurityHeadersMiddleware) applyHeaders(header http.Header, headers map[string]string, tls bool, statusCode int) {
+	m.mu.RLock()
+	remove := m.remove
+	hsts := m.hsts
+	m.mu.RUnlock()
+
+	removed := 0
+	// Protocol switches need their Connection and Upgrade headers
+	if statusCode != http.StatusSwitchingProtocols {
+		// Headers listed in Connection are hop-by-hop as well
+		for _, value := range header.Values("Connection") {
+			for _, key := range strings.Split(value, ",") {
+				if key = http.CanonicalHeaderKey(strings.TrimSpace(key)); key != "" {
+					if _, exists := header[key]; exists {
+						header.Del(key)
+						removed++
+					}
+				}
+			}
+		}
+		for _, key := range remove {
+			if _, exists := header[key]; exists {
+				header.Del(key)
+				removed++
+			}
+		}
+	}
+
+	injected := 0
+	for key, value := range headers {
+		header.Set(key, value)
+		injected++
+	}
+
+	// HSTS is only meaningful over TLS, browsers ignore it on plain HTTP
+	if hsts != "" && tls {
+		if _, overridden := headers["Strict-Transport-Security"]; !overridden {
+			header.Set("Strict-Transport-Security", hsts)
+			injected++
+		}
+	}
+
+	m.mu.Lock()
+	m.headersRemoved += int64(removed)
+	m.headersInjected += int64(injected)
+	m.mu.Unlock()
+}
+
+// GetStats returns middleware statistics
+func (m *SecurityHeadersMiddleware) GetStats() map[string]interface{} {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return map[string]interface{}{
+		"enabled":            m.config.Enabled,
+		"requests_processed": m.requestsProcessed,
+		"headers_injected":   m.headersInjected,
+		"headers_removed":    m.headersRemoved,
+		"hsts_enabled":       m.hsts != "",
+		"per_route_rules":    len(m.routes),
+	}
+}
+
+// securityHeadersWriter applies security headers when the response headers are written
+type securityHeadersWriter struct {
+	http.ResponseWriter
+	middleware  *SecurityHeadersMiddleware
+	headers     map[string]string
+	tls         bool
+	wroteHeader bool
+}
+
+// WriteHeader applies security headers before writing the status code
+func (w *securityHeadersWriter) WriteHeader(statusCode int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		w.middleware.applyHeaders(w.ResponseWriter.Header(), w.headers, w.tls, statusCode)
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+// Write ensures security headers are applied before writing the body
+func (w *securityHeadersWriter) Write(data []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+// Flush implements http.Flusher
+func (w *securityHeadersWriter) Flush() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack implements http.Hijacker
+func (w *securityHeadersWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if hijacker, ok := w.ResponseWriter.(http.Hijacker); ok {
+		return hijacker.Hijack()
+	}
+	return nil, nil, fmt.Errorf("response writer does not support hijacking")
+}
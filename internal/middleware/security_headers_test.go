@@ -0,0 +1,206 @@
+package middleware
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/songzhibin97/stargate/internal/config"
+)
+
+// leakyUpstream responds like an upstream exposing implementation details
+func leakyUpstream() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Server", "Apache/2.4.1")
+		w.Header().Set("X-Powered-By", "PHP/7.4")
+		w.Header().Set("X-AspNet-Version", "4.0.30319")
+		w.Header().Set("Keep-Alive", "timeout=5")
+		w.Header().Set("Connection", "X-Internal-Hop")
+		w.Header().Set("X-Internal-Hop", "secret")
+		w.Header().Set("Content-Type", "text/plain")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("ok"))
+	})
+}
+
+func TestSecurityHeadersMiddleware_Defaults(t *testing.T) {
+	middleware := NewSecurityHeadersMiddleware(&config.SecurityHeadersConfig{Enabled: true})
+	handler := middleware.Handler()(leakyUpstream())
+
+	req := httptest.NewRequest("GET", "/test", nil)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	expectedHeaders := map[string]string{
+		"X-Content-Type-Options": "nosniff",
+		"X-Frame-Options":        "DENY",
+		"Referrer-Policy":        "strict-origin-when-cross-origin",
+		"Content-Type":           "text/plain",
+	}
+	for key, expected := range expectedHeaders {
+		if actual := rr.Header().Get(key); actual != expected {
+			t.Errorf("Expected header %s to be %q, got %q", key, expected, actual)
+		}
+	}
+
+	for _, key := range []string{"Server", "X-Powered-By", "X-AspNet-Version", "Keep-Alive", "Connection", "X-Internal-Hop"} {
+		if actual := rr.Header().Get(key); actual != "" {
+			t.Errorf("Expected upstream header %s to be stripped, got %q", key, actual)
+		}
+	}
+
+	// HSTS is opt-in
+	if actual := rr.Header().Get("Strict-Transport-Security"); actual != "" {
+		t.Errorf("Expected no HSTS header by default, got %q", actual)
+	}
+
+	if rr.Body.String() != "ok" {
+		t.Errorf("Expected body ok, got %q", rr.Body.String())
+	}
+}
+
+func TestSecurityHeadersMiddleware_CustomPolicy(t *testing.T) {
+	middleware := NewSecurityHeadersMiddleware(&config.SecurityHeadersConfig{
+		Enabled:               true,
+		FrameOptions:          "SAMEORIGIN",
+		ContentSecurityPolicy: "default-src 'self'",
+		PermissionsPolicy:     "geolocation=()",
+		CustomHeaders:         map[string]string{"x-gateway": "stargate"},
+		RemoveHeaders:         []string{"X-Powered-By"},
+		KeepHeaders:           []string{"Keep-Alive"},
+	})
+	handler := middleware.Handler()(leakyUpstream())
+
+	req := httptest.NewRequest("GET", "/test", nil)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	expectedHeaders := map[string]string{
+		"X-Frame-Options":         "SAMEORIGIN",
+		"Content-Security-Policy": "default-src 'self'",
+		"Permissions-Policy":      "geolocation=()",
+		"X-Gateway":               "stargate",
+		"Keep-Alive":              "timeout=5",
+		"Server":                  "Apache/2.4.1",
+	}
+	for key, expected := range expectedHeaders {
+		if actual := rr.Header().Get(key); actual != expected {
+			t.Errorf("Expected header %s to be %q, got %q", key, expected, actual)
+		}
+	}
+
+	if actual := rr.Header().Get("X-Powered-By"); actual != "" {
+		t.Errorf("Expected X-Powered-By to be stripped, got %q", actual)
+	}
+}
+
+func TestSecurityHeadersMiddleware_HSTS(t *testing.T) {
+	middleware := NewSecurityHeadersMiddleware(&config.SecurityHeadersConfig{
+		Enabled: true,
+		HSTS: config.HSTSConfig{
+			Enabled:           true,
+			MaxAge:            24 * time.Hour,
+			IncludeSubDomains: true,
+		},
+	})
+	handler := middleware.Handler()(leakyUpstream())
+
+	// Plain HTTP request
+	req := httptest.NewRequest("GET", "/test", nil)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if actual := rr.Header().Get("Strict-Transport-Security"); actual != "" {
+		t.Errorf("Expected no HSTS header without TLS, got %q", actual)
+	}
+
+	// TLS request
+	req = httptest.NewRequest("GET", "https://example.com/test", nil)
+	req.TLS = &tls.ConnectionState{}
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	expected := "max-age=86400; includeSubDomains"
+	if actual := rr.Header().Get("Strict-Transport-Security"); actual != expected {
+		t.Errorf("Expected HSTS header %q, got %q", expected, actual)
+	}
+}
+
+func TestSecurityHeadersMiddleware_PerRoute(t *testing.T) {
+	middleware := NewSecurityHeadersMiddleware(&config.SecurityHeadersConfig{
+		Enabled:               true,
+		ContentSecurityPolicy: "default-src 'self'",
+		PerRoute: map[string]config.SecurityHeadersRoute{
+			"docs": {
+				Headers: map[string]string{
+					"Content-Security-Policy": "",
+					"X-Frame-Options":         "SAMEORIGIN",
+				},
+			},
+			"legacy": {Disabled: true},
+		},
+	})
+	handler := middleware.Handler()(leakyUpstream())
+
+	serve := func(routeID string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/test", nil)
+		req = req.WithContext(context.WithValue(req.Context(), "route_id", routeID))
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	// Route overriding headers
+	rr := serve("docs")
+	if actual := rr.Header().Get("Content-Security-Policy"); actual != "" {
+		t.Errorf("Expected CSP to be skipped for docs route, got %q", actual)
+	}
+	if actual := rr.Header().Get("X-Frame-Options"); actual != "SAMEORIGIN" {
+		t.Errorf("Expected X-Frame-Options SAMEORIGIN for docs route, got %q", actual)
+	}
+	if actual := rr.Header().Get("X-Content-Type-Options"); actual != "nosniff" {
+		t.Errorf("Expected global X-Content-Type-Options for docs route, got %q", actual)
+	}
+	if actual := rr.Header().Get("Server"); actual != "" {
+		t.Errorf("Expected Server header to be stripped for docs route, got %q", actual)
+	}
+
+	// Route with security headers disabled
+	rr = serve("legacy")
+	if actual := rr.Header().Get("X-Frame-Options"); actual != "" {
+		t.Errorf("Expected no security headers for legacy route, got X-Frame-Options %q", actual)
+	}
+	if actual := rr.Header().Get("Server"); actual != "Apache/2.4.1" {
+		t.Errorf("Expected Server header to pass through for legacy route, got %q", actual)
+	}
+
+	// Other routes use the global policy
+	rr = serve("api")
+	if actual := rr.Header().Get("Content-Security-Policy"); actual != "default-src 'self'" {
+		t.Errorf("Expected global CSP for api route, got %q", actual)
+	}
+}
+
+func TestSecurityHeadersMiddleware_Disabled(t *testing.T) {
+	middleware := NewSecurityHeadersMiddleware(&config.SecurityHeadersConfig{Enabled: false})
+	handler := middleware.Handler()(leakyUpstream())
+
+	req := httptest.NewRequest("GET", "/test", nil)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if actual := rr.Header().Get("X-Frame-Options"); actual != "" {
+		t.Errorf("Expected no security headers when disabled, got X-Frame-Options %q", actual)
+	}
+	if actual := rr.Header().Get("Server"); actual != "Apache/2.4.1" {
+		t.Errorf("Expected Server header to pass through when disabled, got %q", actual)
+	}
+
+	stats := middleware.GetStats()
+	if stats["requests_processed"].(int64) != 0 {
+		t.Errorf("Expected 0 processed requests, got %v", stats["requests_processed"])
+	}
+}
@@ -4,15 +4,18 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/songzhibin97/stargate/internal/config"
+	"github.com/songzhibin97/stargate/pkg/metrics"
 )
 
 // ServerlessMiddleware represents the serverless function integration middleware
@@ -21,6 +24,12 @@ type ServerlessMiddleware struct {
 	client *http.Client
 	mutex  sync.RWMutex
 
+	// Guarded outbound HTTP calls requested by pre-process functions
+	callout *calloutClient
+
+	// Cached function results
+	cache *functionCache
+
 	// Statistics
 	totalRequests       int64
 	preProcessRequests  int64
@@ -38,6 +47,10 @@ type ServerlessFunction struct {
 	Timeout     time.Duration     `yaml:"timeout,omitempty" json:"timeout,omitempty"`
 	RetryCount  int               `yaml:"retry_count,omitempty" json:"retry_count,omitempty"`
 	OnError     string            `yaml:"on_error,omitempty" json:"on_error,omitempty"` // continue, abort
+	Phase       string            `yaml:"phase,omitempty" json:"phase,omitempty"`
+	Priority    int               `yaml:"priority,omitempty" json:"priority,omitempty"`
+	Cache       *config.ServerlessCache `yaml:"cache,omitempty" json:"cache,omitempty"`
+	OmitBody    bool              `yaml:"omit_body,omitempty" json:"omit_body,omitempty"`
 }
 
 // ServerlessRule represents a rule for when to execute serverless functions
@@ -48,8 +61,12 @@ type ServerlessRule struct {
 	Headers     map[string]string    `yaml:"headers,omitempty" json:"headers,omitempty"`
 	PreProcess  []ServerlessFunction `yaml:"pre_process,omitempty" json:"pre_process,omitempty"`
 	PostProcess []ServerlessFunction `yaml:"post_process,omitempty" json:"post_process,omitempty"`
+	MaxBodyBytes int64               `yaml:"max_body_bytes,omitempty" json:"max_body_bytes,omitempty"`
 }
 
+// ErrServerlessBodyTooLarge is returned when a request body exceeds the rule's MaxBodyBytes
+var ErrServerlessBodyTooLarge = errors.New("request body too large")
+
 // FunctionRequest represents the request payload sent to serverless function
 type FunctionRequest struct {
 	Method  string            `json:"method"`
@@ -57,6 +74,9 @@ type FunctionRequest struct {
 	Headers map[string]string `json:"headers"`
 	Body    string            `json:"body"`
 	Query   map[string]string `json:"query"`
+
+	// Callouts holds the results of callouts requested by earlier pre-process functions
+	Callouts []*CalloutResponse `json:"callouts,omitempty"`
 }
 
 // FunctionResponse represents the response from serverless function
@@ -65,6 +85,16 @@ type FunctionResponse struct {
 	Body    string            `json:"body,omitempty"`
 	Status  int               `json:"status,omitempty"`
 	Error   string            `json:"error,omitempty"`
+
+	// Callouts requested by a pre-process function. The gateway executes them
+	// through the guarded callout client and passes the results to the next
+	// pre-process function. Callouts requested by the last pre-process
+	// function of a rule have no function to receive their results and are
+	// rejected without being executed.
+	Callouts []CalloutRequest `json:"callouts,omitempty"`
+
+	// noCache is set when the function opted out of caching this response
+	noCache bool
 }
 
 // NewServerlessMiddleware creates a new serverless middleware
@@ -79,13 +109,23 @@ func NewServerlessMiddleware(cfg *config.ServerlessConfig) *ServerlessMiddleware
 	}
 
 	return &ServerlessMiddleware{
-		config: cfg,
-		client: client,
+		config:  cfg,
+		client:  client,
+		callout: newCalloutClient(cfg.Callout),
+		cache:   newFunctionCache(cfg.CacheSize),
 	}
 }
 
-// Handler returns the HTTP middleware handler
+// Handler returns the HTTP middleware handler running request phase
+// pre-process functions and post-process functions
 func (m *ServerlessMiddleware) Handler() func(http.Handler) http.Handler {
+	return m.PhaseHandler(config.PluginPhaseRequest)
+}
+
+// PhaseHandler returns the HTTP middleware handler running the pre-process
+// functions of the given phase. Post-process functions form the response
+// phase and run in the request phase handler, after the upstream responded.
+func (m *ServerlessMiddleware) PhaseHandler(phase string) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			// Skip if middleware is disabled
@@ -99,6 +139,9 @@ func (m *ServerlessMiddleware) Handler() func(http.Handler) http.Handler {
 
 			// Find matching rule
 			rule := m.matchRule(r)
+			if rule != nil {
+				rule = rule.forPhase(phase)
+			}
 			if rule == nil {
 				// No matching rule, continue to next handler
 				next.ServeHTTP(w, r)
@@ -107,6 +150,11 @@ func (m *ServerlessMiddleware) Handler() func(http.Handler) http.Handler {
 
 			// Execute pre-process functions
 			modifiedRequest, err := m.executePreProcessFunctions(r, rule)
+			if errors.Is(err, ErrServerlessBodyTooLarge) {
+				m.updateFailedRequests()
+				m.handleError(w, r, http.StatusRequestEntityTooLarge, err.Error())
+				return
+			}
 			if err != nil {
 				m.updateFailedRequests()
 				m.handleError(w, r, http.StatusInternalServerError, fmt.Sprintf("Pre-process function failed: %v", err))
@@ -118,6 +166,8 @@ func (m *ServerlessMiddleware) Handler() func(http.Handler) http.Handler {
 				ResponseWriter: w,
 				statusCode:     http.StatusOK,
 				body:          &bytes.Buffer{},
+				maxBody:        rule.MaxBodyBytes,
+				skipBody:       !needsBody(rule.PostProcess),
 			}
 
 			// Continue to next handler with modified request
@@ -154,6 +204,7 @@ func (m *ServerlessMiddleware) matchRule(r *http.Request) *ServerlessRule {
 				Path:        rule.Path,
 				Method:      rule.Method,
 				Headers:     rule.Headers,
+				MaxBodyBytes: rule.MaxBodyBytes,
 				PreProcess:  make([]ServerlessFunction, len(rule.PreProcess)),
 				PostProcess: make([]ServerlessFunction, len(rule.PostProcess)),
 			}
@@ -169,6 +220,10 @@ func (m *ServerlessMiddleware) matchRule(r *http.Request) *ServerlessRule {
 					Timeout:    fn.Timeout,
 					RetryCount: fn.RetryCount,
 					OnError:    fn.OnError,
+					Phase:      fn.Phase,
+					Priority:   fn.Priority,
+					Cache:      fn.Cache,
+					OmitBody:   fn.OmitBody,
 				}
 			}
 			
@@ -183,6 +238,10 @@ func (m *ServerlessMiddleware) matchRule(r *http.Request) *ServerlessRule {
 					Timeout:    fn.Timeout,
 					RetryCount: fn.RetryCount,
 					OnError:    fn.OnError,
+					Phase:      fn.Phase,
+					Priority:   fn.Priority,
+					Cache:      fn.Cache,
+					OmitBody:   fn.OmitBody,
 				}
 			}
 			
@@ -193,6 +252,58 @@ func (m *ServerlessMiddleware) matchRule(r *http.Request) *ServerlessRule {
 	return nil
 }
 
+// forPhase returns a copy of the rule holding only the functions that run in
+// the given phase, ordered by priority, or nil if there are none. Post-process
+// functions are kept for the request phase.
+func (rule *ServerlessRule) forPhase(phase string) *ServerlessRule {
+	phaseRule := *rule
+	phaseRule.PreProcess = orderFunctions(rule.PreProcess, phase, config.PluginPhaseRequest)
+	if phase == config.PluginPhaseRequest {
+		phaseRule.PostProcess = orderFunctions(rule.PostProcess, config.PluginPhaseResponse, config.PluginPhaseResponse)
+	} else {
+		phaseRule.PostProcess = nil
+	}
+
+	if len(phaseRule.PreProcess) == 0 && len(phaseRule.PostProcess) == 0 {
+		return nil
+	}
+	return &phaseRule
+}
+
+// orderFunctions returns the functions of the given phase, higher priority
+// first and equal priorities in their configured order. Functions without a
+// phase belong to defaultPhase.
+func orderFunctions(functions []ServerlessFunction, phase, defaultPhase string) []ServerlessFunction {
+	var ordered []ServerlessFunction
+	for _, fn := range functions {
+		fnPhase := fn.Phase
+		if fnPhase == "" {
+			fnPhase = defaultPhase
+		}
+		if fnPhase == phase {
+			ordered = append(ordered, fn)
+		}
+	}
+
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return ordered[i].Priority > ordered[j].Priority
+	})
+
+	return ordered
+}
+
+// HasPhase reports whether any rule has pre-process functions in the given phase
+func (m *ServerlessMiddleware) HasPhase(phase string) bool {
+	for _, rule := range m.config.Rules {
+		for _, fn := range rule.PreProcess {
+			if fn.Phase == phase || (fn.Phase == "" && phase == config.PluginPhaseRequest) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 // matchPath checks if the request path matches the rule path
 func (m *ServerlessMiddleware) matchPath(requestPath, rulePath string) bool {
 	// Simple exact match for now, can be extended to support patterns
@@ -226,20 +337,35 @@ func (m *ServerlessMiddleware) executePreProcessFunctions(r *http.Request, rule
 
 	m.updatePreProcessRequests()
 
-	// Read original request body
-	var originalBody []byte
-	if r.Body != nil {
-		var err error
-		originalBody, err = io.ReadAll(r.Body)
+	// Read original request body, unless no function needs it
+	var originalBody string
+	bodyRead := needsBody(rule.PreProcess)
+	if bodyRead && r.Body != nil {
+		if rule.MaxBodyBytes > 0 && r.ContentLength > rule.MaxBodyBytes {
+			return nil, ErrServerlessBodyTooLarge
+		}
+
+		var reader io.Reader = r.Body
+		if rule.MaxBodyBytes > 0 {
+			reader = io.LimitReader(r.Body, rule.MaxBodyBytes+1)
+		}
+
+		// Read as a string, kept by the function requests
+		body, err := readBodyString(reader)
 		if err != nil {
 			return nil, fmt.Errorf("failed to read request body: %w", err)
 		}
+		if rule.MaxBodyBytes > 0 && int64(len(body)) > rule.MaxBodyBytes {
+			return nil, ErrServerlessBodyTooLarge
+		}
+		originalBody = body
 		// Restore body for further processing
-		r.Body = io.NopCloser(bytes.NewReader(originalBody))
+		r.Body = io.NopCloser(strings.NewReader(originalBody))
 	}
 
 	// Execute each pre-process function in sequence
-	currentBody := string(originalBody)
+	currentBody := originalBody
+	bodyModified := false
 	currentHeaders := make(map[string]string)
 	for key, values := range r.Header {
 		if len(values) > 0 {
@@ -247,8 +373,14 @@ func (m *ServerlessMiddleware) executePreProcessFunctions(r *http.Request, rule
 		}
 	}
 
-	for _, function := range rule.PreProcess {
-		response, err := m.callServerlessFunction(r, function, currentBody)
+	var callouts []*CalloutResponse
+	for i, function := range rule.PreProcess {
+		functionBody := currentBody
+		if function.OmitBody {
+			functionBody = ""
+		}
+
+		response, err := m.callServerlessFunction(r, function, functionBody, callouts)
 		if err != nil {
 			if function.OnError == "continue" {
 				log.Printf("Pre-process function %s failed but continuing: %v", function.Name, err)
@@ -260,18 +392,28 @@ func (m *ServerlessMiddleware) executePreProcessFunctions(r *http.Request, rule
 		// Apply function response to modify request
 		if response.Body != "" {
 			currentBody = response.Body
+			bodyModified = true
 		}
 		
 		// Apply header modifications
 		for key, value := range response.Headers {
 			currentHeaders[key] = value
 		}
+
+		// Execute requested callouts for the next function
+		if i == len(rule.PreProcess)-1 {
+			m.rejectCallouts(function.Name, response.Callouts)
+			continue
+		}
+		callouts = m.executeCallouts(r.Context(), response.Callouts)
 	}
 
-	// Create modified request
+	// Create modified request, an unread body is streamed through unchanged
 	modifiedRequest := r.Clone(r.Context())
-	modifiedRequest.Body = io.NopCloser(strings.NewReader(currentBody))
-	modifiedRequest.ContentLength = int64(len(currentBody))
+	if bodyRead || bodyModified {
+		modifiedRequest.Body = io.NopCloser(strings.NewReader(currentBody))
+		modifiedRequest.ContentLength = int64(len(currentBody))
+	}
 
 	// Apply modified headers
 	for key, value := range currentHeaders {
@@ -291,12 +433,21 @@ func (m *ServerlessMiddleware) executePostProcessFunctions(r *http.Request, wrap
 
 	// Execute each post-process function
 	for _, function := range rule.PostProcess {
+		body := ""
+		if !function.OmitBody {
+			if wrapper.overflow {
+				log.Printf("Skipping post-process function %s: response body exceeds %d bytes", function.Name, rule.MaxBodyBytes)
+				continue
+			}
+			body = wrapper.body.String()
+		}
+
 		// Create function request with response data
 		functionReq := &FunctionRequest{
 			Method:  r.Method,
 			Path:    r.URL.Path,
 			Headers: make(map[string]string),
-			Body:    wrapper.body.String(),
+			Body:    body,
 			Query:   make(map[string]string),
 		}
 
@@ -314,7 +465,7 @@ func (m *ServerlessMiddleware) executePostProcessFunctions(r *http.Request, wrap
 			}
 		}
 
-		_, err := m.callServerlessFunction(r, function, functionReq.Body)
+		_, err := m.callServerlessFunction(r, function, functionReq.Body, nil)
 		if err != nil {
 			if function.OnError == "continue" {
 				log.Printf("Post-process function %s failed but continuing: %v", function.Name, err)
@@ -327,15 +478,26 @@ func (m *ServerlessMiddleware) executePostProcessFunctions(r *http.Request, wrap
 	return nil
 }
 
-// callServerlessFunction calls a serverless function with retry logic
-func (m *ServerlessMiddleware) callServerlessFunction(r *http.Request, function ServerlessFunction, body string) (*FunctionResponse, error) {
+// callServerlessFunction calls a serverless function with retry logic. If the
+// function has caching configured, successful results are served from and
+// stored in the cache; calls that receive callout results are never cached.
+func (m *ServerlessMiddleware) callServerlessFunction(r *http.Request, function ServerlessFunction, body string, callouts []*CalloutResponse) (*FunctionResponse, error) {
+	var cacheKey string
+	if m.cache != nil && function.Cache != nil && function.Cache.TTL > 0 && len(callouts) == 0 {
+		cacheKey = functionCacheKey(r, function, body)
+		if response, hit := m.cache.get(cacheKey); hit {
+			return response, nil
+		}
+	}
+
 	// Prepare function request
 	functionReq := &FunctionRequest{
-		Method:  r.Method,
-		Path:    r.URL.Path,
-		Headers: make(map[string]string),
-		Body:    body,
-		Query:   make(map[string]string),
+		Method:   r.Method,
+		Path:     r.URL.Path,
+		Headers:  make(map[string]string),
+		Body:     body,
+		Query:    make(map[string]string),
+		Callouts: callouts,
 	}
 
 	// Add request headers
@@ -366,8 +528,11 @@ func (m *ServerlessMiddleware) callServerlessFunction(r *http.Request, function
 
 	var lastErr error
 	for attempt := 0; attempt < maxRetries; attempt++ {
-		response, err := m.executeFunctionCall(function, reqBody)
+		response, err := m.executeFunctionCall(r.Context(), function, reqBody)
 		if err == nil {
+			if cacheKey != "" && !response.noCache {
+				m.cache.set(cacheKey, response, function.Cache.TTL)
+			}
 			return response, nil
 		}
 		lastErr = err
@@ -381,15 +546,71 @@ func (m *ServerlessMiddleware) callServerlessFunction(r *http.Request, function
 	return nil, fmt.Errorf("function call failed after %d attempts: %w", maxRetries, lastErr)
 }
 
-// executeFunctionCall executes a single function call
-func (m *ServerlessMiddleware) executeFunctionCall(function ServerlessFunction, reqBody []byte) (*FunctionResponse, error) {
+// needsBody reports whether any of the functions is sent the body
+func needsBody(functions []ServerlessFunction) bool {
+	for _, fn := range functions {
+		if !fn.OmitBody {
+			return true
+		}
+	}
+	return false
+}
+
+// rejectCallouts rejects the callouts requested by the last pre-process
+// function, whose results no function would receive
+func (m *ServerlessMiddleware) rejectCallouts(functionName string, requests []CalloutRequest) {
+	if len(requests) == 0 || m.callout == nil {
+		return
+	}
+	log.Printf("Rejected %d callouts of pre-process function %s: no function follows to receive their results", len(requests), functionName)
+	for range requests {
+		m.callout.recordRejected()
+	}
+}
+
+// SetCalloutMetrics sets the counter of callouts by source and result and
+// the latency histogram of executed callouts by source
+func (m *ServerlessMiddleware) SetCalloutMetrics(calls metrics.CounterVec, duration metrics.HistogramVec) {
+	if m.callout != nil {
+		m.callout.setMetrics("serverless", calls, duration)
+	}
+}
+
+// executeCallouts executes the callouts requested by a function. Rejected
+// and failed callouts are reported through the Error field of their result.
+func (m *ServerlessMiddleware) executeCallouts(ctx context.Context, requests []CalloutRequest) []*CalloutResponse {
+	if len(requests) == 0 {
+		return nil
+	}
+
+	results := make([]*CalloutResponse, len(requests))
+	for i := range requests {
+		response, err := m.callout.Call(ctx, &requests[i])
+		if err != nil {
+			log.Printf("Serverless callout to %s failed: %v", requests[i].URL, err)
+			response = &CalloutResponse{Error: err.Error()}
+		}
+		results[i] = response
+	}
+
+	return results
+}
+
+// executeFunctionCall executes a single function call. The call is cancelled
+// when the function timeout expires or the client request is cancelled.
+func (m *ServerlessMiddleware) executeFunctionCall(ctx context.Context, function ServerlessFunction, reqBody []byte) (*FunctionResponse, error) {
 	// Set timeout
 	timeout := function.Timeout
 	if timeout == 0 {
 		timeout = m.config.DefaultTimeout
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	var cancel context.CancelFunc
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+	}
 	defer cancel()
 
 	// Create HTTP request
@@ -416,11 +637,13 @@ func (m *ServerlessMiddleware) executeFunctionCall(function ServerlessFunction,
 	}
 	defer resp.Body.Close()
 
-	// Read response
-	respBody, err := io.ReadAll(resp.Body)
+	// Read response into a pooled buffer, released once parsed
+	body, err := readPooledBody(resp.Body)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read response body: %w", err)
 	}
+	defer body.Release()
+	respBody := body.Bytes()
 
 	// Check for HTTP errors
 	if resp.StatusCode >= 400 {
@@ -434,6 +657,7 @@ func (m *ServerlessMiddleware) executeFunctionCall(function ServerlessFunction,
 		functionResp.Body = string(respBody)
 		functionResp.Status = resp.StatusCode
 	}
+	functionResp.noCache = !cacheable(resp.Header)
 
 	return &functionResp, nil
 }
@@ -478,18 +702,34 @@ func (m *ServerlessMiddleware) updateFailedRequests() {
 	m.failedRequests++
 }
 
+// FlushCache removes all cached function results and returns how many were removed
+func (m *ServerlessMiddleware) FlushCache() int {
+	if m.cache == nil {
+		return 0
+	}
+	return m.cache.flush()
+}
+
 // GetStats returns middleware statistics
 func (m *ServerlessMiddleware) GetStats() map[string]interface{} {
 	m.mutex.RLock()
 	defer m.mutex.RUnlock()
 
-	return map[string]interface{}{
+	stats := map[string]interface{}{
 		"total_requests":        m.totalRequests,
 		"pre_process_requests":  m.preProcessRequests,
 		"post_process_requests": m.postProcessRequests,
 		"failed_requests":       m.failedRequests,
 		"success_rate":          float64(m.totalRequests-m.failedRequests) / float64(m.totalRequests) * 100,
 	}
+	if m.callout != nil {
+		stats["callouts"] = m.callout.GetStats()
+	}
+	if m.cache != nil {
+		stats["cache"] = m.cache.stats()
+	}
+
+	return stats
 }
 
 // serverlessResponseWrapper wraps http.ResponseWriter to capture response data
@@ -497,6 +737,9 @@ type serverlessResponseWrapper struct {
 	http.ResponseWriter
 	statusCode int
 	body       *bytes.Buffer
+	maxBody    int64 // Stop capturing beyond this many bytes, zero for no limit
+	skipBody   bool  // Don't capture the body at all
+	overflow   bool  // The body exceeded maxBody and was discarded
 }
 
 func (w *serverlessResponseWrapper) WriteHeader(code int) {
@@ -506,6 +749,13 @@ func (w *serverlessResponseWrapper) WriteHeader(code int) {
 
 func (w *serverlessResponseWrapper) Write(data []byte) (int, error) {
 	// Write to both the original response and our buffer
-	w.body.Write(data)
+	if !w.skipBody && !w.overflow {
+		if w.maxBody > 0 && int64(w.body.Len()+len(data)) > w.maxBody {
+			w.overflow = true
+			w.body.Reset()
+		} else {
+			w.body.Write(data)
+		}
+	}
 	return w.ResponseWriter.Write(data)
 }
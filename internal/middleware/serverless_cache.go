@@ -0,0 +1,193 @@
+package middleware
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultServerlessCacheSize is the default maximum number of cached function results
+const defaultServerlessCacheSize = 10000
+
+// functionCache is a size-bounded LRU cache of serverless function results
+type functionCache struct {
+	maxEntries int
+
+	mutex   sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List
+
+	// Statistics
+	hits      int64
+	misses    int64
+	evictions int64
+}
+
+// functionCacheEntry is a cached function result
+type functionCacheEntry struct {
+	key       string
+	response  *FunctionResponse
+	expiresAt time.Time
+}
+
+// newFunctionCache creates a function cache holding at most maxEntries results
+func newFunctionCache(maxEntries int) *functionCache {
+	if maxEntries <= 0 {
+		maxEntries = defaultServerlessCacheSize
+	}
+	return &functionCache{
+		maxEntries: maxEntries,
+		entries:    make(map[string]*list.Element),
+		lru:        list.New(),
+	}
+}
+
+// get returns a copy of the cached result for key, if present and not expired
+func (c *functionCache) get(key string) (*FunctionResponse, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	element, exists := c.entries[key]
+	if !exists {
+		c.misses++
+		return nil, false
+	}
+
+	entry := element.Value.(*functionCacheEntry)
+	if time.Now().After(entry.expiresAt) {
+		c.lru.Remove(element)
+		delete(c.entries, key)
+		c.misses++
+		return nil, false
+	}
+
+	c.lru.MoveToFront(element)
+	c.hits++
+	return copyFunctionResponse(entry.response), true
+}
+
+// set caches a copy of response under key for ttl, evicting the least
+// recently used result when the cache is full
+func (c *functionCache) set(key string, response *FunctionResponse, ttl time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	entry := &functionCacheEntry{
+		key:       key,
+		response:  copyFunctionResponse(response),
+		expiresAt: time.Now().Add(ttl),
+	}
+
+	if element, exists := c.entries[key]; exists {
+		element.Value = entry
+		c.lru.MoveToFront(element)
+		return
+	}
+
+	c.entries[key] = c.lru.PushFront(entry)
+
+	for c.lru.Len() > c.maxEntries {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*functionCacheEntry).key)
+		c.evictions++
+	}
+}
+
+// flush removes all cached results and returns how many were removed
+func (c *functionCache) flush() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	flushed := c.lru.Len()
+	c.entries = make(map[string]*list.Element)
+	c.lru.Init()
+	return flushed
+}
+
+// stats returns cache statistics
+func (c *functionCache) stats() map[string]interface{} {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return map[string]interface{}{
+		"hits":      c.hits,
+		"misses":    c.misses,
+		"evictions": c.evictions,
+		"entries":   c.lru.Len(),
+	}
+}
+
+// functionCacheKey builds the cache key of a function call from the function,
+// the request method and path, and the attributes selected by the function's
+// cache configuration. The client IP is taken from the connection rather than
+// forwarding headers, so clients cannot claim another client's cached result.
+func functionCacheKey(r *http.Request, function ServerlessFunction, body string) string {
+	cache := function.Cache
+
+	var key strings.Builder
+	key.WriteString(function.ID)
+	key.WriteString("\x00")
+	key.WriteString(r.Method)
+	key.WriteString("\x00")
+	key.WriteString(r.URL.Path)
+
+	for _, name := range cache.KeyHeaders {
+		key.WriteString("\x00h:")
+		key.WriteString(strings.Join(r.Header.Values(name), ","))
+	}
+	query := r.URL.Query()
+	for _, name := range cache.KeyQuery {
+		key.WriteString("\x00q:")
+		key.WriteString(strings.Join(query[name], ","))
+	}
+	if cache.KeyClientIP {
+		ip, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			ip = r.RemoteAddr
+		}
+		key.WriteString("\x00ip:")
+		key.WriteString(ip)
+	}
+	if cache.KeyBody {
+		key.WriteString("\x00b:")
+		key.WriteString(body)
+	}
+
+	sum := sha256.Sum256([]byte(key.String()))
+	return hex.EncodeToString(sum[:])
+}
+
+// copyFunctionResponse returns a copy of response that can be modified
+// without affecting the cached result
+func copyFunctionResponse(response *FunctionResponse) *FunctionResponse {
+	copied := *response
+	if response.Headers != nil {
+		copied.Headers = make(map[string]string, len(response.Headers))
+		for key, value := range response.Headers {
+			copied.Headers[key] = value
+		}
+	}
+	if response.Callouts != nil {
+		copied.Callouts = append([]CalloutRequest(nil), response.Callouts...)
+	}
+	return &copied
+}
+
+// cacheable reports whether the function's response allows caching
+func cacheable(header http.Header) bool {
+	for _, value := range header.Values("Cache-Control") {
+		for _, directive := range strings.Split(value, ",") {
+			switch strings.ToLower(strings.TrimSpace(directive)) {
+			case "no-store", "no-cache":
+				return false
+			}
+		}
+	}
+	return true
+}
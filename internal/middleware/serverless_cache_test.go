@@ -0,0 +1,129 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/songzhibin97/stargate/internal/config"
+)
+
+func TestServerlessMiddleware_FunctionCache(t *testing.T) {
+	var calls int64
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&calls, 1)
+		switch r.URL.Path {
+		case "/no-store":
+			w.Header().Set("Cache-Control", "no-store")
+		case "/fail":
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(FunctionResponse{Headers: map[string]string{"X-Authz": "allow"}})
+	}))
+	defer testServer.Close()
+
+	middleware := NewServerlessMiddleware(&config.ServerlessConfig{
+		Enabled:        true,
+		DefaultTimeout: 5 * time.Second,
+	})
+
+	cache := &config.ServerlessCache{TTL: time.Minute, KeyHeaders: []string{"Authorization"}}
+	authz := ServerlessFunction{ID: "authz", Name: "authz", URL: testServer.URL + "/authz", Cache: cache}
+
+	call := func(function ServerlessFunction, token string) {
+		req := httptest.NewRequest("GET", "/api", nil)
+		req.Header.Set("Authorization", token)
+		response, err := middleware.callServerlessFunction(req, function, "", nil)
+		if function.URL == testServer.URL+"/fail" {
+			if err == nil {
+				t.Error("expected error from failing function")
+			}
+			return
+		}
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if response.Headers["X-Authz"] != "allow" {
+			t.Errorf("unexpected response: %+v", response)
+		}
+		// Modifying a cached result must not affect the cache
+		response.Headers["X-Authz"] = "modified"
+	}
+
+	call(authz, "token-a")
+	call(authz, "token-a")
+	if got := atomic.LoadInt64(&calls); got != 1 {
+		t.Errorf("Expected repeated request to be served from cache, got %d calls", got)
+	}
+
+	call(authz, "token-b")
+	if got := atomic.LoadInt64(&calls); got != 2 {
+		t.Errorf("Expected different key attributes to miss the cache, got %d calls", got)
+	}
+
+	// Functions can opt out of caching per response
+	noStore := ServerlessFunction{ID: "no-store", Name: "no-store", URL: testServer.URL + "/no-store", Cache: cache}
+	call(noStore, "token-a")
+	call(noStore, "token-a")
+	if got := atomic.LoadInt64(&calls); got != 4 {
+		t.Errorf("Expected no-store responses not to be cached, got %d calls", got)
+	}
+
+	// Failures are not cached
+	fail := ServerlessFunction{ID: "fail", Name: "fail", URL: testServer.URL + "/fail", Cache: cache}
+	call(fail, "token-a")
+	call(fail, "token-a")
+	if got := atomic.LoadInt64(&calls); got != 6 {
+		t.Errorf("Expected failed results not to be cached, got %d calls", got)
+	}
+
+	stats := middleware.GetStats()["cache"].(map[string]interface{})
+	if stats["hits"].(int64) != 1 || stats["entries"].(int) != 2 {
+		t.Errorf("unexpected cache stats: %+v", stats)
+	}
+}
+
+func TestFunctionCache_EvictionAndExpiry(t *testing.T) {
+	cache := newFunctionCache(2)
+
+	cache.set("a", &FunctionResponse{Body: "a"}, time.Minute)
+	cache.set("b", &FunctionResponse{Body: "b"}, time.Minute)
+	cache.get("a")
+	cache.set("c", &FunctionResponse{Body: "c"}, time.Minute)
+
+	if _, hit := cache.get("b"); hit {
+		t.Error("Expected least recently used entry to be evicted")
+	}
+	if response, hit := cache.get("a"); !hit || response.Body != "a" {
+		t.Error("Expected recently used entry to be kept")
+	}
+
+	cache.set("d", &FunctionResponse{Body: "d"}, time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	if _, hit := cache.get("d"); hit {
+		t.Error("Expected expired entry to miss")
+	}
+}
+
+func TestFunctionCache_Flush(t *testing.T) {
+	cache := newFunctionCache(10)
+	cache.set("a", &FunctionResponse{Body: "a"}, time.Minute)
+	cache.set("b", &FunctionResponse{Body: "b"}, time.Minute)
+
+	if flushed := cache.flush(); flushed != 2 {
+		t.Errorf("Expected 2 entries flushed, got %d", flushed)
+	}
+	if _, hit := cache.get("a"); hit {
+		t.Error("Expected flushed entry to miss")
+	}
+
+	// The cache remains usable after a flush
+	cache.set("c", &FunctionResponse{Body: "c"}, time.Minute)
+	if response, hit := cache.get("c"); !hit || response.Body != "c" {
+		t.Error("Expected entry set after flush to hit")
+	}
+}
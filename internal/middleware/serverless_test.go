@@ -3,6 +3,7 @@ package middleware
 import (
 	"bytes"
 	"encoding/json"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -313,7 +314,7 @@ func TestServerlessMiddleware_callServerlessFunction(t *testing.T) {
 			req := httptest.NewRequest("POST", "/test", strings.NewReader(`{"test": "data"}`))
 			req.Header.Set("Content-Type", "application/json")
 
-			response, err := middleware.callServerlessFunction(req, tt.function, `{"test": "data"}`)
+			response, err := middleware.callServerlessFunction(req, tt.function, `{"test": "data"}`, nil)
 
 			if tt.expectError && err == nil {
 				t.Error("expected error, but got none")
@@ -389,6 +390,44 @@ func TestServerlessMiddleware_executePreProcessFunctions(t *testing.T) {
 	}
 }
 
+func TestServerlessRule_forPhase(t *testing.T) {
+	rule := &ServerlessRule{
+		ID: "rule",
+		PreProcess: []ServerlessFunction{
+			{ID: "enrich"},
+			{ID: "authz", Phase: "auth"},
+			{ID: "validate", Priority: 10},
+			{ID: "authn", Phase: "auth", Priority: 5},
+		},
+		PostProcess: []ServerlessFunction{
+			{ID: "audit"},
+			{ID: "sign", Priority: 1},
+		},
+	}
+
+	ids := func(functions []ServerlessFunction) string {
+		var result []string
+		for _, fn := range functions {
+			result = append(result, fn.ID)
+		}
+		return strings.Join(result, ",")
+	}
+
+	auth := rule.forPhase("auth")
+	if auth == nil || ids(auth.PreProcess) != "authn,authz" || len(auth.PostProcess) != 0 {
+		t.Errorf("unexpected auth phase rule: %+v", auth)
+	}
+
+	request := rule.forPhase("request")
+	if request == nil || ids(request.PreProcess) != "validate,enrich" || ids(request.PostProcess) != "sign,audit" {
+		t.Errorf("unexpected request phase rule: %+v", request)
+	}
+
+	if (&ServerlessRule{PreProcess: []ServerlessFunction{{ID: "enrich"}}}).forPhase("auth") != nil {
+		t.Error("expected no auth phase rule without auth functions")
+	}
+}
+
 func TestServerlessMiddleware_GetStats(t *testing.T) {
 	config := &config.ServerlessConfig{
 		Enabled: true,
@@ -459,3 +498,112 @@ func TestServerlessResponseWrapper(t *testing.T) {
 		t.Errorf("expected recorder to contain %s, got %s", string(testData), recorder.Body.String())
 	}
 }
+
+func TestServerlessMiddleware_BodyLimits(t *testing.T) {
+	var receivedBody string
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request FunctionRequest
+		json.NewDecoder(r.Body).Decode(&request)
+		receivedBody = request.Body
+		json.NewEncoder(w).Encode(FunctionResponse{Headers: map[string]string{"X-Checked": "true"}})
+	}))
+	defer testServer.Close()
+
+	middleware := NewServerlessMiddleware(&config.ServerlessConfig{
+		Enabled:        true,
+		DefaultTimeout: 5 * time.Second,
+		Rules: []config.ServerlessRule{
+			{
+				ID:           "capped",
+				Path:         "/api/upload",
+				Method:       "POST",
+				MaxBodyBytes: 16,
+				PreProcess:   []config.ServerlessFunction{{ID: "inspect", Name: "inspect", URL: testServer.URL}},
+			},
+			{
+				ID:           "headers-only",
+				Path:         "/api/stream",
+				Method:       "POST",
+				MaxBodyBytes: 16,
+				PreProcess:   []config.ServerlessFunction{{ID: "authz", Name: "authz", URL: testServer.URL, OmitBody: true}},
+			},
+		},
+	})
+
+	var upstreamBody string
+	handler := middleware.Handler()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		upstreamBody = string(body)
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	// Bodies within the cap are passed to the function
+	receivedBody = ""
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("POST", "/api/upload", strings.NewReader("small")))
+	if recorder.Code != http.StatusOK || receivedBody != "small" {
+		t.Errorf("expected small body to reach the function, got status %d and body %q", recorder.Code, receivedBody)
+	}
+
+	// Bodies over the cap are rejected before calling the function
+	receivedBody = "untouched"
+	recorder = httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/api/upload", strings.NewReader(strings.Repeat("x", 17)))
+	handler.ServeHTTP(recorder, req)
+	if recorder.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected status 413, got %d", recorder.Code)
+	}
+	if receivedBody != "untouched" {
+		t.Error("expected function not to be called for oversized body")
+	}
+
+	// Chunked bodies without a content length are capped while reading
+	recorder = httptest.NewRecorder()
+	req = httptest.NewRequest("POST", "/api/upload", io.MultiReader(strings.NewReader(strings.Repeat("x", 10)), strings.NewReader(strings.Repeat("y", 10))))
+	req.ContentLength = -1
+	handler.ServeHTTP(recorder, req)
+	if recorder.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected status 413 for chunked body, got %d", recorder.Code)
+	}
+
+	// Functions that omit the body never see it, and the body is streamed
+	// to the upstream unchanged regardless of the cap
+	largeBody := strings.Repeat("z", 64)
+	receivedBody = "untouched"
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("POST", "/api/stream", strings.NewReader(largeBody)))
+	if recorder.Code != http.StatusOK {
+		t.Errorf("expected status 200, got %d", recorder.Code)
+	}
+	if receivedBody != "" {
+		t.Errorf("expected function to receive no body, got %q", receivedBody)
+	}
+	if upstreamBody != largeBody {
+		t.Errorf("expected upstream to receive the original body, got %d bytes", len(upstreamBody))
+	}
+}
+
+func TestServerlessMiddleware_FunctionTimeout(t *testing.T) {
+	release := make(chan struct{})
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer testServer.Close()
+	defer close(release)
+
+	middleware := NewServerlessMiddleware(&config.ServerlessConfig{Enabled: true})
+
+	function := ServerlessFunction{ID: "slow", Name: "slow", URL: testServer.URL, Timeout: 50 * time.Millisecond}
+	req := httptest.NewRequest("POST", "/api", nil)
+
+	start := time.Now()
+	if _, err := middleware.callServerlessFunction(req, function, "", nil); err == nil {
+		t.Error("expected timeout error")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected call to be cancelled after the timeout, took %v", elapsed)
+	}
+}
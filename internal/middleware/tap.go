@@ -0,0 +1,487 @@
+package middleware
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/songzhibin97/stargate/internal/clientip"
+	"github.com/songzhibin97/stargate/internal/config"
+)
+
+const (
+	defaultTapMaxBodySize = 64 * 1024
+	defaultTapBufferSize  = 100
+	defaultTapPath        = "/_stargate/tap"
+
+	// tapRedacted replaces the values of redacted headers
+	tapRedacted = "[REDACTED]"
+)
+
+// defaultTapRedactedHeaders are always redacted unless redaction is disabled
+var defaultTapRedactedHeaders = []string{
+	"Authorization",
+	"Cookie",
+	"Set-Cookie",
+	"Proxy-Authorization",
+}
+
+// TapCapture is a captured request and response pair
+type TapCapture struct {
+	ID        int64         `json:"id"`
+	Timestamp time.Time     `json:"timestamp"`
+	Duration  time.Duration `json:"duration"`
+	RouteID   string        `json:"route_id,omitempty"`
+	ClientIP  string        `json:"client_ip"`
+	Request   TapRequest    `json:"request"`
+	Response  TapResponse   `json:"response"`
+}
+
+// TapRequest is a captured request, with enough detail to replay it
+type TapRequest struct {
+	Method        string      `json:"method"`
+	URL           string      `json:"url"`
+	Host          string      `json:"host"`
+	Proto         string      `json:"proto"`
+	Headers       http.Header `json:"headers"`
+	Body          []byte      `json:"body,omitempty"`
+	BodyTruncated bool        `json:"body_truncated,omitempty"`
+}
+
+// TapResponse is a captured response
+type TapResponse struct {
+	StatusCode    int         `json:"status_code"`
+	Headers       http.Header `json:"headers"`
+	Body          []byte      `json:"body,omitempty"`
+	BodyTruncated bool        `json:"body_truncated,omitempty"`
+}
+
+// TapMiddleware captures a sample of request and response pairs for debugging
+type TapMiddleware struct {
+	config *config.TapConfig
+	mu     sync.RWMutex
+
+	// Compiled settings
+	routes      map[string]bool
+	methods     map[string]bool
+	redact      map[string]bool
+	maxBodySize int64
+
+	// Ring buffer of captures, next is the slot written next
+	buffer []*TapCapture
+	next   int
+	count  int
+	lastID int64
+
+	file *os.File
+
+	// Statistics
+	requestsSeen     int64
+	requestsCaptured int64
+}
+
+// NewTapMiddleware creates a new tap middleware
+func NewTapMiddleware(cfg *config.TapConfig) (*TapMiddleware, error) {
+	m := &TapMiddleware{}
+	if err := m.UpdateConfig(cfg); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// UpdateConfig updates the middleware configuration. Existing captures are
+// kept if the buffer size is unchanged.
+func (m *TapMiddleware) UpdateConfig(cfg *config.TapConfig) error {
+	var file *os.File
+	if cfg.Enabled && cfg.File != "" {
+		var err error
+		file, err = os.OpenFile(cfg.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+		if err != nil {
+			return fmt.Errorf("failed to open tap file: %w", err)
+		}
+	}
+
+	routes := make(map[string]bool, len(cfg.Routes))
+	for _, routeID := range cfg.Routes {
+		routes[routeID] = true
+	}
+	methods := make(map[string]bool, len(cfg.Methods))
+	for _, method := range cfg.Methods {
+		methods[strings.ToUpper(method)] = true
+	}
+	redact := tapRedaction(cfg.RedactHeaders, cfg.DisableRedaction)
+
+	maxBodySize := cfg.MaxBodySize
+	if maxBodySize <= 0 {
+		maxBodySize = defaultTapMaxBodySize
+	}
+	bufferSize := cfg.BufferSize
+	if bufferSize <= 0 {
+		bufferSize = defaultTapBufferSize
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.file != nil {
+		m.file.Close()
+	}
+
+	m.config = cfg
+	m.routes = routes
+	m.methods = methods
+	m.redact = redact
+	m.maxBodySize = maxBodySize
+	m.file = file
+	if len(m.buffer) != bufferSize {
+		m.buffer = make([]*TapCapture, bufferSize)
+		m.next = 0
+		m.count = 0
+	}
+	return nil
+}
+
+// Path returns the path of the admin endpoint serving captures
+func (m *TapMiddleware) Path() string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return valueOrDefault(m.config.Path, defaultTapPath)
+}
+
+// Handler returns the HTTP middleware handler
+func (m *TapMiddleware) Handler() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Requests not captured pass through without any buffering
+			if !m.shouldCapture(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			m.serveCaptured(next, w, r)
+		})
+	}
+}
+
+// shouldCapture reports whether a request matches and is sampled
+func (m *TapMiddleware) shouldCapture(r *http.Request) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.config.Enabled {
+		return false
+	}
+	m.requestsSeen++
+
+	if len(m.methods) > 0 && !m.methods[r.Method] {
+		return false
+	}
+	if !m.matchesRoute(r) {
+		return false
+	}
+
+	sampleRate := m.config.SampleRate
+	if sampleRate <= 0 {
+		return false
+	}
+	return sampleRate >= 1.0 || rand.Float64() < sampleRate
+}
+
+// matchesRoute reports whether the request matches the configured routes or
+// path prefixes, the caller must hold m.mu
+func (m *TapMiddleware) matchesRoute(r *http.Request) bool {
+	if len(m.routes) == 0 && len(m.config.Paths) == 0 {
+		return true
+	}
+	if routeID, ok := r.Context().Value("route_id").(string); ok && m.routes[routeID] {
+		return true
+	}
+	for _, prefix := range m.config.Paths {
+		if strings.HasPrefix(r.URL.Path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// serveCaptured serves a request while capturing it and its response
+func (m *TapMiddleware) serveCaptured(next http.Handler, w http.ResponseWriter, r *http.Request) {
+	m.mu.RLock()
+	maxBodySize := m.maxBodySize
+	redact := m.redact
+	m.mu.RUnlock()
+
+	m.record(captureExchange(next, w, r, maxBodySize, redact))
+}
+
+// tapRedaction returns the headers redacted from captures, the default ones
+// and extra, or none if redaction is disabled
+func tapRedaction(extra []string, disabled bool) map[string]bool {
+	redact := make(map[string]bool)
+	if !disabled {
+		for _, key := range append(append([]string{}, defaultTapRedactedHeaders...), extra...) {
+			redact[http.CanonicalHeaderKey(key)] = true
+		}
+	}
+	return redact
+}
+
+// captureExchange serves a request with next while capturing it and its
+// response, up to maxBodySize bytes of each body, redacting the headers of
+// redact. Bodies are streamed past the cap unbuffered.
+func captureExchange(next http.Handler, w http.ResponseWriter, r *http.Request, maxBodySize int64, redact map[string]bool) *TapCapture {
+	start := time.Now()
+	capture := &TapCapture{
+		Timestamp: start,
+		ClientIP:  clientip.FromRequest(r),
+		Request: TapRequest{
+			Method:  r.Method,
+			URL:     r.URL.String(),
+			Host:    r.Host,
+			Proto:   r.Proto,
+			Headers: redactTapHeaders(r.Header, redact),
+		},
+	}
+
+	// Capture the start of the request body, the rest is streamed unbuffered
+	if r.Body != nil && r.Body != http.NoBody {
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxBodySize+1))
+		if err != nil {
+			log.Printf("Tap failed to read request body: %v", err)
+		}
+		remaining := io.Reader(r.Body)
+		if int64(len(body)) > maxBodySize {
+			capture.Request.Body = body[:maxBodySize]
+			capture.Request.BodyTruncated = true
+		} else {
+			capture.Request.Body = body
+			remaining = http.NoBody
+		}
+		r.Body = &tapBody{Reader: io.MultiReader(bytes.NewReader(body), remaining), closer: r.Body}
+	}
+
+	writer := &tapResponseWriter{
+		ResponseWriter: w,
+		statusCode:     http.StatusOK,
+		maxBodySize:    maxBodySize,
+	}
+	next.ServeHTTP(writer, r)
+
+	if routeID, ok := r.Context().Value("route_id").(string); ok {
+		capture.RouteID = routeID
+	}
+	capture.Duration = time.Since(start)
+	capture.Response = TapResponse{
+		StatusCode:    writer.statusCode,
+		Headers:       redactTapHeaders(w.Header(), redact),
+		Body:          writer.body.Bytes(),
+		BodyTruncated: writer.truncated,
+	}
+	return capture
+}
+
+// redactTapHeaders copies headers, replacing the values of redacted ones
+func redactTapHeaders(header http.Header, redact map[string]bool) http.Header {
+	copied := header.Clone()
+	for key := range copied {
+		if redact[key] {
+			copied[key] = []string{tapRedacted}
+		}
+	}
+	return copied
+}
+
+// record stores a capture in the ring buffer and the tap file
+func (m *TapMiddleware) record(capture *TapCapture) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.lastID++
+	capture.ID = m.lastID
+	m.requestsCaptured++
+
+	m.buffer[m.next] = capture
+	m.next = (m.next + 1) % len(m.buffer)
+	if m.count < len(m.buffer) {
+		m.count++
+	}
+
+	if m.file != nil {
+		line, err := json.Marshal(capture)
+		if err == nil {
+			_, err = m.file.Write(append(line, '\n'))
+		}
+		if err != nil {
+			log.Printf("Tap failed to write capture %d to file: %v", capture.ID, err)
+		}
+	}
+}
+
+// Captures returns up to limit captures, newest first. A limit of zero or
+// less returns all captures.
+func (m *TapMiddleware) Captures(limit int) []*TapCapture {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if limit <= 0 || limit > m.count {
+		limit = m.count
+	}
+
+	captures := make([]*TapCapture, 0, limit)
+	for i := 1; i <= limit; i++ {
+		index := (m.next - i + len(m.buffer)) % len(m.buffer)
+		captures = append(captures, m.buffer[index])
+	}
+	return captures
+}
+
+// Clear removes all captures and returns how many were removed
+func (m *TapMiddleware) Clear() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	cleared := m.count
+	m.buffer = make([]*TapCapture, len(m.buffer))
+	m.next = 0
+	m.count = 0
+	return cleared
+}
+
+// AdminHandler serves captures: GET lists them newest first (optional
+// ?limit=N) and DELETE clears them
+func (m *TapMiddleware) AdminHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		switch r.Method {
+		case http.MethodGet:
+			limit := 0
+			if value := r.URL.Query().Get("limit"); value != "" {
+				parsed, err := strconv.Atoi(value)
+				if err != nil || parsed < 0 {
+					w.WriteHeader(http.StatusBadRequest)
+					json.NewEncoder(w).Encode(map[string]string{"error": "invalid limit"})
+					return
+				}
+				limit = parsed
+			}
+			captures := m.Captures(limit)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"captures": captures,
+				"count":    len(captures),
+			})
+		case http.MethodDelete:
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"cleared": m.Clear(),
+			})
+		default:
+			w.Header().Set("Allow", "GET, DELETE")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			json.NewEncoder(w).Encode(map[string]string{"error": "method not allowed"})
+		}
+	})
+}
+
+// Close closes the tap file
+func (m *TapMiddleware) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.file != nil {
+		err := m.file.Close()
+		m.file = nil
+		return err
+	}
+	return nil
+}
+
+// GetStats returns middleware statistics
+func (m *TapMiddleware) GetStats() map[string]interface{} {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return map[string]interface{}{
+		"enabled":           m.config.Enabled,
+		"requests_seen":     m.requestsSeen,
+		"requests_captured": m.requestsCaptured,
+		"buffered":          m.count,
+		"buffer_size":       len(m.buffer),
+		"sample_rate":       m.config.SampleRate,
+	}
+}
+
+// tapBody replays the captured start of a request body followed by the rest
+type tapBody struct {
+	io.Reader
+	closer io.Closer
+}
+
+// Close closes the original request body
+func (b *tapBody) Close() error {
+	return b.closer.Close()
+}
+
+// tapResponseWriter captures the status code and the start of the response body
+type tapResponseWriter struct {
+	http.ResponseWriter
+	statusCode  int
+	wroteHeader bool
+	body        bytes.Buffer
+	maxBodySize int64
+	truncated   bool
+}
+
+// WriteHeader captures the status code
+func (w *tapResponseWriter) WriteHeader(statusCode int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		w.statusCode = statusCode
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+// Write captures the body up to the size cap
+func (w *tapResponseWriter) Write(data []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	if remaining := w.maxBodySize - int64(w.body.Len()); remaining > 0 {
+		if int64(len(data)) > remaining {
+			w.body.Write(data[:remaining])
+			w.truncated = true
+		} else {
+			w.body.Write(data)
+		}
+	} else if len(data) > 0 {
+		w.truncated = true
+	}
+
+	return w.ResponseWriter.Write(data)
+}
+
+// Flush implements http.Flusher
+func (w *tapResponseWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack implements http.Hijacker
+func (w *tapResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if hijacker, ok := w.ResponseWriter.(http.Hijacker); ok {
+		return hijacker.Hijack()
+	}
+	return nil, nil, fmt.Errorf("response writer does not support hijacking")
+}
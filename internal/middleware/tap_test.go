@@ -0,0 +1,304 @@
+package middleware
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/songzhibin97/stargate/internal/config"
+)
+
+// echoUpstream echoes the request body with a session cookie
+func echoUpstream() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Set-Cookie", "session=abc")
+		w.Header().Set("Content-Type", "text/plain")
+		w.WriteHeader(http.StatusCreated)
+		w.Write(body)
+	})
+}
+
+func newTestTap(t *testing.T, cfg *config.TapConfig) *TapMiddleware {
+	t.Helper()
+	tap, err := NewTapMiddleware(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create tap middleware: %v", err)
+	}
+	t.Cleanup(func() { tap.Close() })
+	return tap
+}
+
+func TestTapMiddleware_CapturesAndRedacts(t *testing.T) {
+	tap := newTestTap(t, &config.TapConfig{
+		Enabled:       true,
+		SampleRate:    1.0,
+		RedactHeaders: []string{"X-Api-Key"},
+	})
+	handler := tap.Handler()(echoUpstream())
+
+	req := httptest.NewRequest("POST", "/orders?id=1", strings.NewReader("hello"))
+	req.Header.Set("Authorization", "Bearer secret")
+	req.Header.Set("Cookie", "session=abc")
+	req.Header.Set("X-Api-Key", "key")
+	req.Header.Set("X-Request-Id", "req-1")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Body.String() != "hello" {
+		t.Errorf("Expected upstream to receive the full body, got %q", rr.Body.String())
+	}
+
+	captures := tap.Captures(0)
+	if len(captures) != 1 {
+		t.Fatalf("Expected 1 capture, got %d", len(captures))
+	}
+	capture := captures[0]
+
+	if capture.Request.Method != "POST" || capture.Request.URL != "/orders?id=1" {
+		t.Errorf("Expected POST /orders?id=1, got %s %s", capture.Request.Method, capture.Request.URL)
+	}
+	if string(capture.Request.Body) != "hello" {
+		t.Errorf("Expected request body hello, got %q", capture.Request.Body)
+	}
+	for _, header := range []string{"Authorization", "Cookie", "X-Api-Key"} {
+		if value := capture.Request.Headers.Get(header); value != tapRedacted {
+			t.Errorf("Expected %s to be redacted, got %q", header, value)
+		}
+	}
+	if value := capture.Request.Headers.Get("X-Request-Id"); value != "req-1" {
+		t.Errorf("Expected X-Request-Id req-1, got %q", value)
+	}
+	if capture.Response.StatusCode != http.StatusCreated {
+		t.Errorf("Expected status 201, got %d", capture.Response.StatusCode)
+	}
+	if value := capture.Response.Headers.Get("Set-Cookie"); value != tapRedacted {
+		t.Errorf("Expected Set-Cookie to be redacted, got %q", value)
+	}
+	if string(capture.Response.Body) != "hello" {
+		t.Errorf("Expected response body hello, got %q", capture.Response.Body)
+	}
+
+	// Redaction must not alter the headers sent upstream or to the client
+	if rr.Header().Get("Set-Cookie") != "session=abc" {
+		t.Errorf("Expected client to receive Set-Cookie, got %q", rr.Header().Get("Set-Cookie"))
+	}
+}
+
+func TestTapMiddleware_DisableRedaction(t *testing.T) {
+	tap := newTestTap(t, &config.TapConfig{Enabled: true, SampleRate: 1.0, DisableRedaction: true})
+	handler := tap.Handler()(echoUpstream())
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	captures := tap.Captures(0)
+	if len(captures) != 1 {
+		t.Fatalf("Expected 1 capture, got %d", len(captures))
+	}
+	if value := captures[0].Request.Headers.Get("Authorization"); value != "Bearer secret" {
+		t.Errorf("Expected Authorization to be kept, got %q", value)
+	}
+}
+
+func TestTapMiddleware_TruncatesBodies(t *testing.T) {
+	tap := newTestTap(t, &config.TapConfig{Enabled: true, SampleRate: 1.0, MaxBodySize: 4})
+	handler := tap.Handler()(echoUpstream())
+
+	body := "0123456789"
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("POST", "/", strings.NewReader(body)))
+
+	if rr.Body.String() != body {
+		t.Errorf("Expected upstream to receive the full body, got %q", rr.Body.String())
+	}
+
+	capture := tap.Captures(0)[0]
+	if string(capture.Request.Body) != "0123" || !capture.Request.BodyTruncated {
+		t.Errorf("Expected truncated request body 0123, got %q (truncated %v)", capture.Request.Body, capture.Request.BodyTruncated)
+	}
+	if string(capture.Response.Body) != "0123" || !capture.Response.BodyTruncated {
+		t.Errorf("Expected truncated response body 0123, got %q (truncated %v)", capture.Response.Body, capture.Response.BodyTruncated)
+	}
+}
+
+func TestTapMiddleware_Matching(t *testing.T) {
+	tap := newTestTap(t, &config.TapConfig{
+		Enabled:    true,
+		SampleRate: 1.0,
+		Routes:     []string{"orders"},
+		Paths:      []string{"/debug/"},
+		Methods:    []string{"get", "POST"},
+	})
+
+	var sawBody io.ReadCloser
+	handler := tap.Handler()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sawBody = r.Body
+	}))
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		routeID  string
+		captured bool
+	}{
+		{"matching route", "GET", "/orders", "orders", true},
+		{"matching path", "POST", "/debug/x", "", true},
+		{"other route", "GET", "/users", "users", false},
+		{"other method", "DELETE", "/debug/x", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tap.Clear()
+			body := io.NopCloser(strings.NewReader("body"))
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			req.Body = body
+			if tt.routeID != "" {
+				req = req.WithContext(context.WithValue(req.Context(), "route_id", tt.routeID))
+			}
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			if captured := len(tap.Captures(0)) == 1; captured != tt.captured {
+				t.Errorf("Expected captured %v, got %v", tt.captured, captured)
+			}
+			// Requests not captured must reach the handler untouched
+			if !tt.captured && sawBody != body {
+				t.Error("Expected body of a request not captured to be passed through unwrapped")
+			}
+		})
+	}
+}
+
+func TestTapMiddleware_InactiveDoesNotBuffer(t *testing.T) {
+	for name, cfg := range map[string]*config.TapConfig{
+		"disabled":    {Enabled: false, SampleRate: 1.0},
+		"not sampled": {Enabled: true, SampleRate: 0},
+	} {
+		t.Run(name, func(t *testing.T) {
+			tap := newTestTap(t, cfg)
+
+			var sawWriter http.ResponseWriter
+			handler := tap.Handler()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				sawWriter = w
+			}))
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, httptest.NewRequest("POST", "/", strings.NewReader("body")))
+
+			if sawWriter != rr {
+				t.Error("Expected response writer to be passed through unwrapped")
+			}
+			if len(tap.Captures(0)) != 0 {
+				t.Error("Expected no captures")
+			}
+		})
+	}
+}
+
+func TestTapMiddleware_RingBuffer(t *testing.T) {
+	tap := newTestTap(t, &config.TapConfig{Enabled: true, SampleRate: 1.0, BufferSize: 3})
+	handler := tap.Handler()(echoUpstream())
+
+	for _, path := range []string{"/1", "/2", "/3", "/4", "/5"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+
+	captures := tap.Captures(0)
+	if len(captures) != 3 {
+		t.Fatalf("Expected 3 captures, got %d", len(captures))
+	}
+	for i, expected := range []string{"/5", "/4", "/3"} {
+		if captures[i].Request.URL != expected {
+			t.Errorf("Expected capture %d to be %s, got %s", i, expected, captures[i].Request.URL)
+		}
+	}
+
+	if limited := tap.Captures(2); len(limited) != 2 || limited[0].Request.URL != "/5" {
+		t.Errorf("Expected 2 newest captures, got %d", len(limited))
+	}
+}
+
+func TestTapMiddleware_AdminHandler(t *testing.T) {
+	tap := newTestTap(t, &config.TapConfig{Enabled: true, SampleRate: 1.0})
+	handler := tap.Handler()(echoUpstream())
+	for i := 0; i < 3; i++ {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	}
+
+	admin := tap.AdminHandler()
+
+	rr := httptest.NewRecorder()
+	admin.ServeHTTP(rr, httptest.NewRequest("GET", "/_stargate/tap?limit=2", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rr.Code)
+	}
+	var listed struct {
+		Captures []TapCapture `json:"captures"`
+		Count    int          `json:"count"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &listed); err != nil {
+		t.Fatalf("Failed to decode captures: %v", err)
+	}
+	if listed.Count != 2 || listed.Captures[0].ID != 3 {
+		t.Errorf("Expected 2 captures starting with ID 3, got %+v", listed)
+	}
+
+	rr = httptest.NewRecorder()
+	admin.ServeHTTP(rr, httptest.NewRequest("GET", "/_stargate/tap?limit=x", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for invalid limit, got %d", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	admin.ServeHTTP(rr, httptest.NewRequest("DELETE", "/_stargate/tap", nil))
+	if rr.Code != http.StatusOK || len(tap.Captures(0)) != 0 {
+		t.Errorf("Expected captures to be cleared, got status %d and %d captures", rr.Code, len(tap.Captures(0)))
+	}
+
+	rr = httptest.NewRecorder()
+	admin.ServeHTTP(rr, httptest.NewRequest("POST", "/_stargate/tap", nil))
+	if rr.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status 405, got %d", rr.Code)
+	}
+}
+
+func TestTapMiddleware_File(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "tap.jsonl")
+	tap := newTestTap(t, &config.TapConfig{Enabled: true, SampleRate: 1.0, File: file})
+	handler := tap.Handler()(echoUpstream())
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/a", nil))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/b", nil))
+	if err := tap.Close(); err != nil {
+		t.Fatalf("Failed to close tap: %v", err)
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		t.Fatalf("Failed to open tap file: %v", err)
+	}
+	defer f.Close()
+
+	var urls []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var capture TapCapture
+		if err := json.Unmarshal(scanner.Bytes(), &capture); err != nil {
+			t.Fatalf("Failed to decode capture line: %v", err)
+		}
+		urls = append(urls, capture.Request.URL)
+	}
+	if strings.Join(urls, ",") != "/a,/b" {
+		t.Errorf("Expected captures /a,/b in file, got %v", urls)
+	}
+}
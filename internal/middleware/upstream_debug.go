@@ -0,0 +1,192 @@
+package middleware
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/songzhibin97/stargate/internal/config"
+)
+
+const (
+	defaultUpstreamDebugDuration    = 10 * time.Minute
+	defaultUpstreamDebugMaxDuration = time.Hour
+	defaultUpstreamDebugMaxBodySize = 4 * 1024
+)
+
+// UpstreamDebugEntry is a logged request and response of an upstream
+type UpstreamDebugEntry struct {
+	UpstreamID string `json:"upstream_id"`
+	TapCapture
+}
+
+// UpstreamDebug is the debug logging of an upstream in effect
+type UpstreamDebug struct {
+	UpstreamID string    `json:"upstream_id"`
+	EnabledBy  string    `json:"enabled_by,omitempty"`
+	EnabledAt  time.Time `json:"enabled_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+// UpstreamDebugLogger logs full requests and responses proxied to upstreams
+// with debug logging turned on, one JSON line per exchange. Debug logging is
+// turned on per upstream for a limited time and turns off on its own, so
+// verbose logging isn't left on. Headers are redacted and bodies capped like
+// tap captures.
+type UpstreamDebugLogger struct {
+	mu        sync.RWMutex
+	upstreams map[string]*UpstreamDebug
+
+	defaultDuration time.Duration
+	maxDuration     time.Duration
+	maxBodySize     int64
+	redact          map[string]bool
+
+	writeMu sync.Mutex
+	writer  io.Writer
+	closer  io.Closer
+}
+
+// NewUpstreamDebugLogger creates the debug logger of upstreams, writing to
+// the configured output
+func NewUpstreamDebugLogger(cfg *config.UpstreamDebugLogConfig) (*UpstreamDebugLogger, error) {
+	l := &UpstreamDebugLogger{
+		upstreams:       make(map[string]*UpstreamDebug),
+		defaultDuration: cfg.DefaultDuration,
+		maxDuration:     cfg.MaxDuration,
+		maxBodySize:     cfg.MaxBodySize,
+		redact:          tapRedaction(cfg.RedactHeaders, false),
+	}
+	if l.defaultDuration <= 0 {
+		l.defaultDuration = defaultUpstreamDebugDuration
+	}
+	if l.maxDuration <= 0 {
+		l.maxDuration = defaultUpstreamDebugMaxDuration
+	}
+	if l.maxBodySize <= 0 {
+		l.maxBodySize = defaultUpstreamDebugMaxBodySize
+	}
+
+	switch cfg.Output {
+	case "stdout", "":
+		l.writer = os.Stdout
+	case "stderr":
+		l.writer = os.Stderr
+	default:
+		file, err := os.OpenFile(cfg.Output, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open upstream debug log file %s: %w", cfg.Output, err)
+		}
+		l.writer = file
+		l.closer = file
+	}
+	return l, nil
+}
+
+// Enable turns debug logging of an upstream on for duration, the default
+// duration if zero, replacing an earlier expiration
+func (l *UpstreamDebugLogger) Enable(upstreamID string, duration time.Duration, enabledBy string) (*UpstreamDebug, error) {
+	if upstreamID == "" {
+		return nil, fmt.Errorf("upstream ID cannot be empty")
+	}
+	if duration == 0 {
+		duration = l.defaultDuration
+	}
+	if duration < 0 || duration > l.maxDuration {
+		return nil, fmt.Errorf("duration must be positive and at most %v", l.maxDuration)
+	}
+
+	now := time.Now()
+	debug := &UpstreamDebug{
+		UpstreamID: upstreamID,
+		EnabledBy:  enabledBy,
+		EnabledAt:  now,
+		ExpiresAt:  now.Add(duration),
+	}
+
+	l.mu.Lock()
+	l.upstreams[upstreamID] = debug
+	l.mu.Unlock()
+	return debug, nil
+}
+
+// Disable turns debug logging of an upstream off, reporting whether it was on
+func (l *UpstreamDebugLogger) Disable(upstreamID string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	debug, ok := l.upstreams[upstreamID]
+	delete(l.upstreams, upstreamID)
+	return ok && time.Now().Before(debug.ExpiresAt)
+}
+
+// Active returns the upstreams with debug logging on, sorted by upstream ID,
+// dropping expired ones
+func (l *UpstreamDebugLogger) Active() []UpstreamDebug {
+	if l == nil {
+		return nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	active := make([]UpstreamDebug, 0, len(l.upstreams))
+	for upstreamID, debug := range l.upstreams {
+		if !now.Before(debug.ExpiresAt) {
+			delete(l.upstreams, upstreamID)
+			log.Printf("Debug logging of upstream %s expired", upstreamID)
+			continue
+		}
+		active = append(active, *debug)
+	}
+	sort.Slice(active, func(i, j int) bool {
+		return active[i].UpstreamID < active[j].UpstreamID
+	})
+	return active
+}
+
+// enabled reports whether debug logging of an upstream is on
+func (l *UpstreamDebugLogger) enabled(upstreamID string) bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	debug, ok := l.upstreams[upstreamID]
+	return ok && time.Now().Before(debug.ExpiresAt)
+}
+
+// Serve serves a request proxied to an upstream with next, logging the
+// request and response if debug logging of the upstream is on. A nil logger
+// only serves the request.
+func (l *UpstreamDebugLogger) Serve(next http.Handler, w http.ResponseWriter, r *http.Request, upstreamID string) {
+	if l == nil || !l.enabled(upstreamID) {
+		next.ServeHTTP(w, r)
+		return
+	}
+
+	capture := captureExchange(next, w, r, l.maxBodySize, l.redact)
+	data, err := json.Marshal(UpstreamDebugEntry{UpstreamID: upstreamID, TapCapture: *capture})
+	if err != nil {
+		return
+	}
+
+	l.writeMu.Lock()
+	defer l.writeMu.Unlock()
+	if _, err := l.writer.Write(append(data, '\n')); err != nil {
+		log.Printf("Failed to write debug log of upstream %s: %v", upstreamID, err)
+	}
+}
+
+// Close closes the debug log file
+func (l *UpstreamDebugLogger) Close() error {
+	if l == nil || l.closer == nil {
+		return nil
+	}
+	return l.closer.Close()
+}
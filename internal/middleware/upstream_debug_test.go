@@ -0,0 +1,95 @@
+package middleware
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/songzhibin97/stargate/internal/config"
+)
+
+func TestUpstreamDebugLogger(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "debug.log")
+	logger, err := NewUpstreamDebugLogger(&config.UpstreamDebugLogConfig{
+		MaxDuration:   time.Hour,
+		MaxBodySize:   8,
+		RedactHeaders: []string{"X-Secret"},
+		Output:        output,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+	defer logger.Close()
+
+	if _, err := logger.Enable("orders", 2*time.Hour, ""); err == nil {
+		t.Error("Expected durations over the max to be rejected")
+	}
+	debug, err := logger.Enable("orders", 0, "10.0.0.1")
+	if err != nil {
+		t.Fatalf("Failed to enable debug logging: %v", err)
+	}
+	if time.Until(debug.ExpiresAt) < 9*time.Minute {
+		t.Errorf("Expected the default duration of 10m, got expiry %v", debug.ExpiresAt)
+	}
+	logger.Enable("payments", 20*time.Millisecond, "")
+
+	upstream := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Set-Cookie", "session=abc")
+		w.Write([]byte("echo " + string(body)))
+	})
+	send := func(upstreamID string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/orders", strings.NewReader("order payload"))
+		req.Header.Set("Authorization", "Bearer token")
+		req.Header.Set("X-Secret", "secret")
+		rr := httptest.NewRecorder()
+		logger.Serve(upstream, rr, req, upstreamID)
+		return rr
+	}
+
+	// Bodies reach the upstream and the client in full
+	if rr := send("orders"); rr.Body.String() != "echo order payload" {
+		t.Errorf("Expected the full response, got %q", rr.Body.String())
+	}
+	send("inventory")
+	time.Sleep(30 * time.Millisecond)
+	send("payments")
+
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatalf("Failed to read debug log: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("Expected only the exchange of the upstream with debug logging on, got %q", data)
+	}
+
+	var entry UpstreamDebugEntry
+	if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+		t.Fatalf("Failed to decode entry: %v", err)
+	}
+	if entry.UpstreamID != "orders" || entry.Request.Method != "POST" || entry.Response.StatusCode != http.StatusOK {
+		t.Errorf("Unexpected entry %+v", entry)
+	}
+	if entry.Request.Headers.Get("Authorization") != tapRedacted || entry.Request.Headers.Get("X-Secret") != tapRedacted || entry.Response.Headers.Get("Set-Cookie") != tapRedacted {
+		t.Errorf("Expected sensitive headers redacted, got %v and %v", entry.Request.Headers, entry.Response.Headers)
+	}
+	if string(entry.Request.Body) != "order pa" || !entry.Request.BodyTruncated || string(entry.Response.Body) != "echo ord" || !entry.Response.BodyTruncated {
+		t.Errorf("Expected bodies capped at 8 bytes, got %q and %q", entry.Request.Body, entry.Response.Body)
+	}
+
+	// Expired debug logging is dropped
+	active := logger.Active()
+	if len(active) != 1 || active[0].UpstreamID != "orders" || active[0].EnabledBy != "10.0.0.1" {
+		t.Errorf("Expected only the unexpired upstream active, got %+v", active)
+	}
+	if !logger.Disable("orders") || logger.Disable("orders") {
+		t.Error("Expected disabling to report whether debug logging was on")
+	}
+}
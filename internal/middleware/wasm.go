@@ -2,17 +2,21 @@ package middleware
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"os"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/songzhibin97/stargate/internal/config"
+	"github.com/songzhibin97/stargate/pkg/metrics"
 	"github.com/tetratelabs/wazero"
 	"github.com/tetratelabs/wazero/api"
 	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
@@ -30,6 +34,17 @@ type WASMMiddleware struct {
 	pluginRequests  int64
 	failedRequests  int64
 	pluginLoadTime  time.Duration
+
+	// Hot reload
+	reloadCancel context.CancelFunc
+	reloadWg     sync.WaitGroup
+
+	// Key-value store shared with plugins, namespaced by plugin ID
+	kv            *wasmKVStore
+	modulePlugins map[string]string // module name -> plugin ID
+
+	// Guarded outbound HTTP calls
+	callout *calloutClient
 }
 
 // WASMPlugin represents a loaded WASM plugin
@@ -38,19 +53,32 @@ type WASMPlugin struct {
 	Name        string
 	Path        string
 	Module      api.Module
+	Hash        string // SHA-256 of the module binary, used as the plugin version
 	LoadedAt    time.Time
 	LastUsed    time.Time
 	CallCount   int64
 	ErrorCount  int64
+
+	// Hot reload state
+	ReloadedAt      time.Time
+	ReloadCount     int64
+	LastReloadError string
+
+	config   config.WASMPlugin
+	compiled wazero.CompiledModule
+	inflight sync.WaitGroup
 }
 
-// PluginRequest represents the request data passed to WASM plugin
+// PluginRequest represents the request data passed to WASM plugin. In the
+// response phase, Headers, Body and Status describe the upstream response.
 type PluginRequest struct {
 	Method  string            `json:"method"`
 	Path    string            `json:"path"`
 	Headers map[string]string `json:"headers"`
 	Body    string            `json:"body"`
 	Query   map[string]string `json:"query"`
+	Phase   string            `json:"phase,omitempty"`
+	Status  int               `json:"status,omitempty"`
 }
 
 // PluginResponse represents the response from WASM plugin
@@ -74,20 +102,96 @@ func NewWASMMiddleware(cfg *config.WASMConfig) (*WASMMiddleware, error) {
 		return nil, fmt.Errorf("failed to instantiate WASI: %w", err)
 	}
 
+	kv, err := newWASMKVStore(cfg.KV)
+	if err != nil {
+		runtime.Close(ctx)
+		return nil, fmt.Errorf("failed to create plugin kv store: %w", err)
+	}
+
 	middleware := &WASMMiddleware{
-		config:  cfg,
-		runtime: runtime,
-		plugins: make(map[string]*WASMPlugin),
+		config:        cfg,
+		runtime:       runtime,
+		plugins:       make(map[string]*WASMPlugin),
+		kv:            kv,
+		modulePlugins: make(map[string]string),
+		callout:       newCalloutClient(cfg.Callout),
+	}
+
+	// Instantiate host functions shared by all plugins
+	if err := middleware.instantiateHostModule(ctx); err != nil {
+		middleware.Close(ctx)
+		return nil, err
 	}
 
 	// Load plugins
 	if err := middleware.loadPlugins(ctx); err != nil {
+		middleware.Close(ctx)
 		return nil, fmt.Errorf("failed to load plugins: %w", err)
 	}
 
+	// Watch plugin files for changes
+	if cfg.HotReload {
+		middleware.startReloadWatcher()
+	}
+
 	return middleware, nil
 }
 
+// instantiateHostModule registers the host functions that plugins can call
+func (m *WASMMiddleware) instantiateHostModule(ctx context.Context) error {
+	hostModule := m.runtime.NewHostModuleBuilder("env")
+
+	// Add host functions that plugins can call
+	hostModule.NewFunctionBuilder().
+		WithName("log").
+		WithParameterNames("ptr", "len").
+		WithFunc(m.hostLog).
+		Export("log")
+
+	hostModule.NewFunctionBuilder().
+		WithName("get_header").
+		WithParameterNames("key_ptr", "key_len", "value_ptr", "value_len").
+		WithFunc(m.hostGetHeader).
+		Export("get_header")
+
+	hostModule.NewFunctionBuilder().
+		WithName("set_header").
+		WithParameterNames("key_ptr", "key_len", "value_ptr", "value_len").
+		WithFunc(m.hostSetHeader).
+		Export("set_header")
+
+	hostModule.NewFunctionBuilder().
+		WithName("kv_get").
+		WithParameterNames("key_ptr", "key_len", "value_ptr", "value_cap").
+		WithFunc(m.hostKVGet).
+		Export("kv_get")
+
+	hostModule.NewFunctionBuilder().
+		WithName("kv_set").
+		WithParameterNames("key_ptr", "key_len", "value_ptr", "value_len", "ttl_ms").
+		WithFunc(m.hostKVSet).
+		Export("kv_set")
+
+	hostModule.NewFunctionBuilder().
+		WithName("kv_incr").
+		WithParameterNames("key_ptr", "key_len", "delta", "result_ptr").
+		WithFunc(m.hostKVIncr).
+		Export("kv_incr")
+
+	hostModule.NewFunctionBuilder().
+		WithName("http_call").
+		WithParameterNames("request_ptr", "request_len", "response_ptr", "response_cap").
+		WithFunc(m.hostHTTPCall).
+		Export("http_call")
+
+	// Instantiate host module
+	if _, err := hostModule.Instantiate(ctx); err != nil {
+		return fmt.Errorf("failed to instantiate host module: %w", err)
+	}
+
+	return nil
+}
+
 // loadPlugins loads all configured WASM plugins
 func (m *WASMMiddleware) loadPlugins(ctx context.Context) error {
 	startTime := time.Now()
@@ -109,73 +213,77 @@ func (m *WASMMiddleware) loadPlugins(ctx context.Context) error {
 
 // loadPlugin loads a single WASM plugin
 func (m *WASMMiddleware) loadPlugin(ctx context.Context, pluginConfig config.WASMPlugin) error {
+	plugin, err := m.compilePlugin(ctx, pluginConfig, pluginConfig.Name)
+	if err != nil {
+		return err
+	}
+
+	m.mutex.Lock()
+	m.plugins[pluginConfig.ID] = plugin
+	m.mutex.Unlock()
+
+	log.Printf("Successfully loaded WASM plugin: %s", pluginConfig.Name)
+	return nil
+}
+
+// compilePlugin reads, compiles and instantiates a plugin under the given module name
+func (m *WASMMiddleware) compilePlugin(ctx context.Context, pluginConfig config.WASMPlugin, moduleName string) (*WASMPlugin, error) {
 	// Read WASM file
 	wasmBytes, err := os.ReadFile(pluginConfig.Path)
 	if err != nil {
-		return fmt.Errorf("failed to read WASM file %s: %w", pluginConfig.Path, err)
+		return nil, fmt.Errorf("failed to read WASM file %s: %w", pluginConfig.Path, err)
 	}
 
 	// Compile module
 	compiledModule, err := m.runtime.CompileModule(ctx, wasmBytes)
 	if err != nil {
-		return fmt.Errorf("failed to compile WASM module: %w", err)
+		return nil, fmt.Errorf("failed to compile WASM module: %w", err)
 	}
 
-	// Create host functions for the plugin
-	hostModule := m.runtime.NewHostModuleBuilder("env")
-	
-	// Add host functions that plugins can call
-	hostModule.NewFunctionBuilder().
-		WithName("log").
-		WithParameterNames("ptr", "len").
-		WithFunc(m.hostLog).
-		Export("log")
-
-	hostModule.NewFunctionBuilder().
-		WithName("get_header").
-		WithParameterNames("key_ptr", "key_len", "value_ptr", "value_len").
-		WithFunc(m.hostGetHeader).
-		Export("get_header")
-
-	hostModule.NewFunctionBuilder().
-		WithName("set_header").
-		WithParameterNames("key_ptr", "key_len", "value_ptr", "value_len").
-		WithFunc(m.hostSetHeader).
-		Export("set_header")
-
-	// Instantiate host module
-	_, err = hostModule.Instantiate(ctx)
-	if err != nil {
-		return fmt.Errorf("failed to instantiate host module: %w", err)
-	}
+	// Register the module before instantiation, start functions may already use the kv store
+	m.mutex.Lock()
+	m.modulePlugins[moduleName] = pluginConfig.ID
+	m.mutex.Unlock()
 
 	// Instantiate the plugin module
-	moduleConfig := wazero.NewModuleConfig().WithName(pluginConfig.Name)
+	moduleConfig := wazero.NewModuleConfig().WithName(moduleName)
 	module, err := m.runtime.InstantiateModule(ctx, compiledModule, moduleConfig)
 	if err != nil {
-		return fmt.Errorf("failed to instantiate module: %w", err)
+		m.mutex.Lock()
+		delete(m.modulePlugins, moduleName)
+		m.mutex.Unlock()
+		compiledModule.Close(ctx)
+		return nil, fmt.Errorf("failed to instantiate module: %w", err)
 	}
 
+	sum := sha256.Sum256(wasmBytes)
+
 	// Create plugin instance
-	plugin := &WASMPlugin{
+	return &WASMPlugin{
 		ID:       pluginConfig.ID,
 		Name:     pluginConfig.Name,
 		Path:     pluginConfig.Path,
 		Module:   module,
+		Hash:     hex.EncodeToString(sum[:]),
 		LoadedAt: time.Now(),
 		LastUsed: time.Now(),
-	}
-
-	m.mutex.Lock()
-	m.plugins[pluginConfig.ID] = plugin
-	m.mutex.Unlock()
-
-	log.Printf("Successfully loaded WASM plugin: %s", pluginConfig.Name)
-	return nil
+		config:   pluginConfig,
+		compiled: compiledModule,
+	}, nil
 }
 
-// Handler returns the HTTP middleware handler
+// Handler returns the HTTP middleware handler for request phase plugins
 func (m *WASMMiddleware) Handler() func(http.Handler) http.Handler {
+	return m.PhaseHandler(config.PluginPhaseRequest)
+}
+
+// PhaseHandler returns the HTTP middleware handler running the plugins of
+// the given phase
+func (m *WASMMiddleware) PhaseHandler(phase string) func(http.Handler) http.Handler {
+	if phase == config.PluginPhaseResponse {
+		return m.responseHandler()
+	}
+
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			// Skip if middleware is disabled
@@ -188,7 +296,7 @@ func (m *WASMMiddleware) Handler() func(http.Handler) http.Handler {
 			m.updateTotalRequests()
 
 			// Find matching plugins for this request
-			matchingPlugins := m.findMatchingPlugins(r)
+			matchingPlugins := m.findMatchingPlugins(r, phase)
 			if len(matchingPlugins) == 0 {
 				// No matching plugins, continue to next handler
 				next.ServeHTTP(w, r)
@@ -197,6 +305,7 @@ func (m *WASMMiddleware) Handler() func(http.Handler) http.Handler {
 
 			// Execute plugins
 			modifiedRequest, shouldContinue, err := m.executePlugins(r, matchingPlugins)
+			releasePlugins(matchingPlugins)
 			if err != nil {
 				m.updateFailedRequests()
 				m.handleError(w, r, http.StatusInternalServerError, fmt.Sprintf("Plugin execution failed: %v", err))
@@ -214,8 +323,10 @@ func (m *WASMMiddleware) Handler() func(http.Handler) http.Handler {
 	}
 }
 
-// findMatchingPlugins finds plugins that match the current request
-func (m *WASMMiddleware) findMatchingPlugins(r *http.Request) []*WASMPlugin {
+// findMatchingPlugins finds plugins of the given phase that match the current
+// request, ordered by priority. Each returned plugin is marked in flight until
+// released with releasePlugins, so a reload can drain it before closing its module.
+func (m *WASMMiddleware) findMatchingPlugins(r *http.Request, phase string) []*WASMPlugin {
 	var matchingPlugins []*WASMPlugin
 
 	m.mutex.RLock()
@@ -224,16 +335,47 @@ func (m *WASMMiddleware) findMatchingPlugins(r *http.Request) []*WASMPlugin {
 	for _, rule := range m.config.Rules {
 		if m.matchRule(r, rule) {
 			for _, pluginID := range rule.Plugins {
-				if plugin, exists := m.plugins[pluginID]; exists {
+				if plugin, exists := m.plugins[pluginID]; exists && pluginPhase(plugin.config.Phase) == phase {
+					plugin.inflight.Add(1)
 					matchingPlugins = append(matchingPlugins, plugin)
 				}
 			}
 		}
 	}
 
+	// Higher priority first, equal priorities keep their configured order
+	sort.SliceStable(matchingPlugins, func(i, j int) bool {
+		return matchingPlugins[i].config.Priority > matchingPlugins[j].config.Priority
+	})
+
 	return matchingPlugins
 }
 
+// pluginPhase returns the configured phase, defaulting to the request phase
+func pluginPhase(phase string) string {
+	if phase == "" {
+		return config.PluginPhaseRequest
+	}
+	return phase
+}
+
+// HasPhase reports whether any configured plugin runs in the given phase
+func (m *WASMMiddleware) HasPhase(phase string) bool {
+	for _, plugin := range m.config.Plugins {
+		if pluginPhase(plugin.Phase) == phase {
+			return true
+		}
+	}
+	return false
+}
+
+// releasePlugins marks plugins returned by findMatchingPlugins as no longer in flight
+func releasePlugins(plugins []*WASMPlugin) {
+	for _, plugin := range plugins {
+		plugin.inflight.Done()
+	}
+}
+
 // matchRule checks if a request matches a plugin rule
 func (m *WASMMiddleware) matchRule(r *http.Request, rule config.WASMRule) bool {
 	// Match path
@@ -266,16 +408,16 @@ func (m *WASMMiddleware) matchPath(requestPath, rulePath string) bool {
 func (m *WASMMiddleware) executePlugins(r *http.Request, plugins []*WASMPlugin) (*http.Request, bool, error) {
 	m.updatePluginRequests()
 
-	// Read request body
-	var requestBody []byte
+	// Read request body, kept as a string by the plugin request
+	var requestBody string
 	if r.Body != nil {
-		var err error
-		requestBody, err = io.ReadAll(r.Body)
+		body, err := readBodyString(r.Body)
 		if err != nil {
 			return nil, false, fmt.Errorf("failed to read request body: %w", err)
 		}
+		requestBody = body
 		// Restore body for further processing
-		r.Body = io.NopCloser(strings.NewReader(string(requestBody)))
+		r.Body = io.NopCloser(strings.NewReader(requestBody))
 	}
 
 	// Prepare plugin request
@@ -283,7 +425,7 @@ func (m *WASMMiddleware) executePlugins(r *http.Request, plugins []*WASMPlugin)
 		Method:  r.Method,
 		Path:    r.URL.Path,
 		Headers: make(map[string]string),
-		Body:    string(requestBody),
+		Body:    requestBody,
 		Query:   make(map[string]string),
 	}
 
@@ -428,6 +570,40 @@ func (m *WASMMiddleware) hostGetHeader(ctx context.Context, mod api.Module, keyP
 	return 0
 }
 
+// hostHTTPCall performs a guarded outbound HTTP call. The request is a JSON
+// CalloutRequest; the JSON CalloutResponse is written to the response buffer
+// if it fits. It returns the response length, or -1 if the request could not
+// be read. Rejected and failed callouts still produce a response with Error
+// set. If the response is longer than response_cap nothing is copied and the
+// plugin can retry with a larger buffer.
+func (m *WASMMiddleware) hostHTTPCall(ctx context.Context, mod api.Module, requestPtr, requestLen, responsePtr, responseCap uint32) int32 {
+	data, ok := mod.Memory().Read(requestPtr, requestLen)
+	if !ok {
+		return -1
+	}
+
+	var request CalloutRequest
+	if err := json.Unmarshal(data, &request); err != nil {
+		return -1
+	}
+
+	response, err := m.callout.Call(ctx, &request)
+	if err != nil {
+		log.Printf("WASM plugin %s: http_call to %s failed: %v", mod.Name(), request.URL, err)
+		response = &CalloutResponse{Error: err.Error()}
+	}
+
+	responseJSON, err := json.Marshal(response)
+	if err != nil {
+		return -1
+	}
+
+	if uint32(len(responseJSON)) <= responseCap && !mod.Memory().Write(responsePtr, responseJSON) {
+		return -1
+	}
+	return int32(len(responseJSON))
+}
+
 // hostSetHeader allows plugins to set response headers
 func (m *WASMMiddleware) hostSetHeader(ctx context.Context, mod api.Module, keyPtr, keyLen, valuePtr, valueLen uint32) {
 	// This is a simplified implementation
@@ -468,6 +644,14 @@ func (m *WASMMiddleware) updateFailedRequests() {
 	m.failedRequests++
 }
 
+// SetCalloutMetrics sets the counter of callouts by source and result and
+// the latency histogram of executed callouts by source
+func (m *WASMMiddleware) SetCalloutMetrics(calls metrics.CounterVec, duration metrics.HistogramVec) {
+	if m.callout != nil {
+		m.callout.setMetrics("wasm", calls, duration)
+	}
+}
+
 // GetStats returns middleware statistics
 func (m *WASMMiddleware) GetStats() map[string]interface{} {
 	m.mutex.RLock()
@@ -481,10 +665,14 @@ func (m *WASMMiddleware) GetStats() map[string]interface{} {
 			"last_used":   plugin.LastUsed,
 			"call_count":  plugin.CallCount,
 			"error_count": plugin.ErrorCount,
+			"hash":        plugin.Hash,
+			"reloaded_at": plugin.ReloadedAt,
+			"reload_count": plugin.ReloadCount,
+			"last_reload_error": plugin.LastReloadError,
 		}
 	}
 
-	return map[string]interface{}{
+	stats := map[string]interface{}{
 		"total_requests":    m.totalRequests,
 		"plugin_requests":   m.pluginRequests,
 		"failed_requests":   m.failedRequests,
@@ -493,9 +681,18 @@ func (m *WASMMiddleware) GetStats() map[string]interface{} {
 		"loaded_plugins":    len(m.plugins),
 		"plugin_stats":      pluginStats,
 	}
+	if m.callout != nil {
+		stats["callouts"] = m.callout.GetStats()
+	}
+
+	return stats
 }
 
 // Close closes the WASM runtime and cleans up resources
 func (m *WASMMiddleware) Close(ctx context.Context) error {
+	m.stopReloadWatcher()
+	if m.kv != nil {
+		m.kv.Close()
+	}
 	return m.runtime.Close(ctx)
 }
@@ -0,0 +1,358 @@
+package middleware
+
+// Shared key-value store for WASM plugins.
+//
+// Plugins reach the store through the kv_get, kv_set and kv_incr host
+// functions. Every plugin gets its own namespace derived from its plugin ID,
+// so plugins cannot read or overwrite each other's keys, and the namespace
+// survives hot reloads of the plugin.
+//
+// Consistency model:
+//
+//   - storage "memory" (default) is node-local. Each gateway node keeps its
+//     own independent copy of the data, which is lost on restart. Operations
+//     are linearizable within a node.
+//   - storage "redis" is shared by all nodes pointing at the same Redis.
+//     kv_incr is atomic across the cluster; kv_set is last-write-wins.
+//
+// Quotas (keys, total bytes and value size) are enforced per plugin by each
+// node for the keys that node has written. With Redis the cluster-wide usage
+// of a plugin can therefore exceed its quota by up to a factor of the number
+// of nodes.
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/songzhibin97/stargate/internal/config"
+	kvmemory "github.com/songzhibin97/stargate/internal/store/driver/memory"
+	kvredis "github.com/songzhibin97/stargate/internal/store/driver/redis"
+	"github.com/songzhibin97/stargate/pkg/store"
+	"github.com/tetratelabs/wazero/api"
+)
+
+const (
+	defaultWASMKVMaxKeys      = 1000
+	defaultWASMKVMaxBytes     = 1 << 20
+	defaultWASMKVMaxValueSize = 64 << 10
+
+	// wasmKVMaxKeyLength is the longest key a plugin may use
+	wasmKVMaxKeyLength = 256
+
+	// wasmKVCounterSize is the quota charged for a counter value, the
+	// longest decimal representation of an int64
+	wasmKVCounterSize = 20
+)
+
+// Status codes returned by the kv host functions. Non-negative results of
+// kv_get are value lengths.
+const (
+	wasmKVOK          int32 = 0
+	wasmKVNotFound    int32 = -1
+	wasmKVErrQuota    int32 = -2
+	wasmKVErrTooLarge int32 = -3
+	wasmKVErrInvalid  int32 = -4
+	wasmKVErrStore    int32 = -5
+)
+
+var (
+	// ErrWASMKVQuotaExceeded is returned when a write would exceed the plugin's quota
+	ErrWASMKVQuotaExceeded = errors.New("wasm kv quota exceeded")
+
+	// ErrWASMKVTooLarge is returned when a key or value exceeds the size limits
+	ErrWASMKVTooLarge = errors.New("wasm kv key or value too large")
+)
+
+// wasmKVStore is the plugin key-value store with per-plugin namespaces and quotas
+type wasmKVStore struct {
+	backend      store.AtomicStore
+	maxKeys      int
+	maxBytes     int
+	maxValueSize int
+
+	mu    sync.Mutex
+	usage map[string]*wasmKVUsage
+}
+
+// wasmKVUsage tracks the quota usage of a single namespace
+type wasmKVUsage struct {
+	entries map[string]wasmKVEntry
+	bytes   int
+}
+
+// wasmKVEntry is the accounted size and expiry of a single key
+type wasmKVEntry struct {
+	size      int
+	expiresAt time.Time
+}
+
+// newWASMKVStore creates the plugin key-value store from configuration
+func newWASMKVStore(cfg config.WASMKVConfig) (*wasmKVStore, error) {
+	storeConfig := &store.Config{
+		Type:      cfg.Storage,
+		Address:   cfg.Redis.Address,
+		Database:  cfg.Redis.DB,
+		Password:  cfg.Redis.Password,
+		Timeout:   5 * time.Second,
+		KeyPrefix: "wasm_kv",
+	}
+
+	var backend store.AtomicStore
+	var err error
+
+	switch cfg.Storage {
+	case "", "memory":
+		backend, err = kvmemory.New(storeConfig)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create memory store: %w", err)
+		}
+	case "redis":
+		backend, err = kvredis.New(storeConfig)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create Redis store: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported storage type: %s", cfg.Storage)
+	}
+
+	kv := &wasmKVStore{
+		backend:      backend,
+		maxKeys:      cfg.MaxKeys,
+		maxBytes:     cfg.MaxBytes,
+		maxValueSize: cfg.MaxValueSize,
+		usage:        make(map[string]*wasmKVUsage),
+	}
+	if kv.maxKeys <= 0 {
+		kv.maxKeys = defaultWASMKVMaxKeys
+	}
+	if kv.maxBytes <= 0 {
+		kv.maxBytes = defaultWASMKVMaxBytes
+	}
+	if kv.maxValueSize <= 0 {
+		kv.maxValueSize = defaultWASMKVMaxValueSize
+	}
+
+	return kv, nil
+}
+
+// key returns the backend key of a plugin key
+func (kv *wasmKVStore) key(namespace, key string) string {
+	return namespace + ":" + key
+}
+
+// Get returns the value of key, or nil if it does not exist
+func (kv *wasmKVStore) Get(ctx context.Context, namespace, key string) ([]byte, error) {
+	if len(key) == 0 || len(key) > wasmKVMaxKeyLength {
+		return nil, ErrWASMKVTooLarge
+	}
+	return kv.backend.Get(ctx, kv.key(namespace, key))
+}
+
+// Set stores value under key. A zero ttl keeps the value until it is overwritten.
+func (kv *wasmKVStore) Set(ctx context.Context, namespace, key string, value []byte, ttl time.Duration) error {
+	if len(key) == 0 || len(key) > wasmKVMaxKeyLength || len(value) > kv.maxValueSize {
+		return ErrWASMKVTooLarge
+	}
+
+	var expiresAt time.Time
+	if ttl > 0 {
+		expiresAt = time.Now().Add(ttl)
+	}
+	if err := kv.reserve(namespace, key, len(key)+len(value), expiresAt, false); err != nil {
+		return err
+	}
+
+	if err := kv.backend.Set(ctx, kv.key(namespace, key), value, ttl); err != nil {
+		kv.release(namespace, key)
+		return err
+	}
+	return nil
+}
+
+// Incr atomically adds delta to the counter stored under key and returns the
+// new value. Missing keys start at zero and never expire.
+func (kv *wasmKVStore) Incr(ctx context.Context, namespace, key string, delta int64) (int64, error) {
+	if len(key) == 0 || len(key) > wasmKVMaxKeyLength {
+		return 0, ErrWASMKVTooLarge
+	}
+
+	if err := kv.reserve(namespace, key, len(key)+wasmKVCounterSize, time.Time{}, true); err != nil {
+		return 0, err
+	}
+
+	return kv.backend.IncrBy(ctx, kv.key(namespace, key), delta)
+}
+
+// reserve accounts size bytes for key in namespace, failing if the write
+// would exceed the quota. If keepExpiry is set, an existing key keeps its expiry.
+func (kv *wasmKVStore) reserve(namespace, key string, size int, expiresAt time.Time, keepExpiry bool) error {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+
+	usage, exists := kv.usage[namespace]
+	if !exists {
+		usage = &wasmKVUsage{entries: make(map[string]wasmKVEntry)}
+		kv.usage[namespace] = usage
+	}
+	usage.pruneExpired(time.Now())
+
+	keys := len(usage.entries)
+	bytes := usage.bytes
+	previous, tracked := usage.entries[key]
+	if tracked {
+		bytes -= previous.size
+		if keepExpiry {
+			expiresAt = previous.expiresAt
+		}
+	} else {
+		keys++
+	}
+	bytes += size
+
+	if keys > kv.maxKeys || bytes > kv.maxBytes {
+		return ErrWASMKVQuotaExceeded
+	}
+
+	usage.entries[key] = wasmKVEntry{size: size, expiresAt: expiresAt}
+	usage.bytes = bytes
+	return nil
+}
+
+// release drops the accounting for key in namespace
+func (kv *wasmKVStore) release(namespace, key string) {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+
+	if usage, exists := kv.usage[namespace]; exists {
+		if entry, tracked := usage.entries[key]; tracked {
+			usage.bytes -= entry.size
+			delete(usage.entries, key)
+		}
+	}
+}
+
+// Usage returns the number of keys and bytes accounted to namespace
+func (kv *wasmKVStore) Usage(namespace string) (int, int) {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+
+	usage, exists := kv.usage[namespace]
+	if !exists {
+		return 0, 0
+	}
+	usage.pruneExpired(time.Now())
+	return len(usage.entries), usage.bytes
+}
+
+// Close closes the backend store
+func (kv *wasmKVStore) Close() error {
+	return kv.backend.Close()
+}
+
+// pruneExpired drops the accounting of expired keys
+func (u *wasmKVUsage) pruneExpired(now time.Time) {
+	for key, entry := range u.entries {
+		if !entry.expiresAt.IsZero() && now.After(entry.expiresAt) {
+			u.bytes -= entry.size
+			delete(u.entries, key)
+		}
+	}
+}
+
+// kvNamespace returns the key-value namespace of the calling module, which is
+// the ID of the plugin it was instantiated for
+func (m *WASMMiddleware) kvNamespace(mod api.Module) string {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	if pluginID, exists := m.modulePlugins[mod.Name()]; exists {
+		return pluginID
+	}
+	return mod.Name()
+}
+
+// kvStatus maps a store error to a host function status code
+func kvStatus(err error) int32 {
+	switch {
+	case errors.Is(err, ErrWASMKVQuotaExceeded):
+		return wasmKVErrQuota
+	case errors.Is(err, ErrWASMKVTooLarge):
+		return wasmKVErrTooLarge
+	default:
+		return wasmKVErrStore
+	}
+}
+
+// hostKVGet copies the value of a key into the plugin buffer. It returns the
+// value length, or a negative status code. If the value is longer than
+// value_cap nothing is copied and the plugin can retry with a larger buffer.
+func (m *WASMMiddleware) hostKVGet(ctx context.Context, mod api.Module, keyPtr, keyLen, valuePtr, valueCap uint32) int32 {
+	key, ok := mod.Memory().Read(keyPtr, keyLen)
+	if !ok {
+		return wasmKVErrInvalid
+	}
+
+	value, err := m.kv.Get(ctx, m.kvNamespace(mod), string(key))
+	if err != nil {
+		return kvStatus(err)
+	}
+	if value == nil {
+		return wasmKVNotFound
+	}
+
+	if uint32(len(value)) <= valueCap && !mod.Memory().Write(valuePtr, value) {
+		return wasmKVErrInvalid
+	}
+	return int32(len(value))
+}
+
+// hostKVSet stores a value for the calling plugin. A ttl_ms of zero keeps the
+// value until it is overwritten.
+func (m *WASMMiddleware) hostKVSet(ctx context.Context, mod api.Module, keyPtr, keyLen, valuePtr, valueLen uint32, ttlMs int64) int32 {
+	key, ok := mod.Memory().Read(keyPtr, keyLen)
+	if !ok {
+		return wasmKVErrInvalid
+	}
+	value, ok := mod.Memory().Read(valuePtr, valueLen)
+	if !ok {
+		return wasmKVErrInvalid
+	}
+	if ttlMs < 0 {
+		return wasmKVErrInvalid
+	}
+
+	namespace := m.kvNamespace(mod)
+	if err := m.kv.Set(ctx, namespace, string(key), value, time.Duration(ttlMs)*time.Millisecond); err != nil {
+		log.Printf("WASM plugin %s: kv_set failed: %v", namespace, err)
+		return kvStatus(err)
+	}
+	return wasmKVOK
+}
+
+// hostKVIncr atomically adds delta to a counter and writes the new value as
+// a little-endian int64 to result_ptr
+func (m *WASMMiddleware) hostKVIncr(ctx context.Context, mod api.Module, keyPtr, keyLen uint32, delta int64, resultPtr uint32) int32 {
+	key, ok := mod.Memory().Read(keyPtr, keyLen)
+	if !ok {
+		return wasmKVErrInvalid
+	}
+
+	namespace := m.kvNamespace(mod)
+	value, err := m.kv.Incr(ctx, namespace, string(key), delta)
+	if err != nil {
+		log.Printf("WASM plugin %s: kv_incr failed: %v", namespace, err)
+		return kvStatus(err)
+	}
+
+	var result [8]byte
+	binary.LittleEndian.PutUint64(result[:], uint64(value))
+	if !mod.Memory().Write(resultPtr, result[:]) {
+		return wasmKVErrInvalid
+	}
+	return wasmKVOK
+}
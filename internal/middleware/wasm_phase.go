@@ -0,0 +1,139 @@
+package middleware
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/songzhibin97/stargate/internal/config"
+)
+
+// responseHandler returns the HTTP middleware handler running response phase
+// plugins. The downstream response is buffered so plugins can rewrite its
+// status, headers and body before it is sent, which means responses of
+// matching requests are not streamed to the client.
+func (m *WASMMiddleware) responseHandler() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Skip if middleware is disabled
+			if !m.config.Enabled {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			matchingPlugins := m.findMatchingPlugins(r, config.PluginPhaseResponse)
+			if len(matchingPlugins) == 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+			defer releasePlugins(matchingPlugins)
+
+			buffer := &wasmResponseBuffer{
+				header:     make(http.Header),
+				statusCode: http.StatusOK,
+			}
+			next.ServeHTTP(buffer, r)
+
+			if err := m.executeResponsePlugins(r, buffer, matchingPlugins); err != nil {
+				m.updateFailedRequests()
+				m.handleError(w, r, http.StatusInternalServerError, fmt.Sprintf("Plugin execution failed: %v", err))
+				return
+			}
+
+			buffer.writeTo(w)
+		})
+	}
+}
+
+// executeResponsePlugins runs response phase plugins on a buffered response
+// and applies their modifications to it
+func (m *WASMMiddleware) executeResponsePlugins(r *http.Request, buffer *wasmResponseBuffer, plugins []*WASMPlugin) error {
+	m.updatePluginRequests()
+
+	pluginReq := &PluginRequest{
+		Method:  r.Method,
+		Path:    r.URL.Path,
+		Headers: make(map[string]string),
+		Body:    buffer.body.String(),
+		Query:   make(map[string]string),
+		Phase:   config.PluginPhaseResponse,
+		Status:  buffer.statusCode,
+	}
+
+	for key, values := range r.URL.Query() {
+		if len(values) > 0 {
+			pluginReq.Query[key] = values[0]
+		}
+	}
+
+	for _, plugin := range plugins {
+		for key, values := range buffer.header {
+			if len(values) > 0 {
+				pluginReq.Headers[key] = values[0]
+			}
+		}
+
+		response, err := m.executePlugin(plugin, pluginReq)
+		if err != nil {
+			plugin.ErrorCount++
+			return fmt.Errorf("plugin %s execution failed: %w", plugin.Name, err)
+		}
+
+		plugin.CallCount++
+		plugin.LastUsed = time.Now()
+
+		// Apply plugin modifications
+		for key, value := range response.Headers {
+			buffer.header.Set(key, value)
+		}
+		if response.Body != "" {
+			buffer.body.Reset()
+			buffer.body.WriteString(response.Body)
+			pluginReq.Body = response.Body
+		}
+		if response.Status != 0 {
+			buffer.statusCode = response.Status
+			pluginReq.Status = response.Status
+		}
+
+		// Check if plugin wants to skip the remaining response plugins
+		if !response.Continue {
+			log.Printf("WASM plugin %s stopped response processing", plugin.Name)
+			break
+		}
+	}
+
+	return nil
+}
+
+// wasmResponseBuffer captures a response for response phase plugins
+type wasmResponseBuffer struct {
+	header     http.Header
+	statusCode int
+	body       bytes.Buffer
+}
+
+func (b *wasmResponseBuffer) Header() http.Header {
+	return b.header
+}
+
+func (b *wasmResponseBuffer) WriteHeader(code int) {
+	b.statusCode = code
+}
+
+func (b *wasmResponseBuffer) Write(data []byte) (int, error) {
+	return b.body.Write(data)
+}
+
+// writeTo sends the buffered response
+func (b *wasmResponseBuffer) writeTo(w http.ResponseWriter) {
+	for key, values := range b.header {
+		w.Header()[key] = values
+	}
+	w.Header().Set("Content-Length", strconv.Itoa(b.body.Len()))
+	w.WriteHeader(b.statusCode)
+	w.Write(b.body.Bytes())
+}
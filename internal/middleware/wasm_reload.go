@@ -0,0 +1,198 @@
+package middleware
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/songzhibin97/stargate/internal/config"
+)
+
+// defaultWASMReloadInterval is how often plugin files are checked for changes
+const defaultWASMReloadInterval = 2 * time.Second
+
+// wasmModuleGeneration makes module names of reloaded plugins unique, since
+// the runtime rejects a name that is still held by a draining module
+var wasmModuleGeneration int64
+
+// PluginInfo describes the version and reload state of a loaded WASM plugin
+type PluginInfo struct {
+	ID              string    `json:"id"`
+	Name            string    `json:"name"`
+	Path            string    `json:"path"`
+	Hash            string    `json:"hash"`
+	LoadedAt        time.Time `json:"loaded_at"`
+	ReloadedAt      time.Time `json:"reloaded_at,omitempty"`
+	ReloadCount     int64     `json:"reload_count"`
+	LastReloadError string    `json:"last_reload_error,omitempty"`
+}
+
+// pluginFileState is the last observed state of a plugin file
+type pluginFileState struct {
+	modTime time.Time
+	size    int64
+}
+
+// startReloadWatcher polls plugin files and reloads plugins whose file changed
+func (m *WASMMiddleware) startReloadWatcher() {
+	interval := m.config.ReloadInterval
+	if interval <= 0 {
+		interval = defaultWASMReloadInterval
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	m.reloadCancel = cancel
+
+	states := make(map[string]pluginFileState, len(m.config.Plugins))
+	for _, pluginConfig := range m.config.Plugins {
+		if stat, err := os.Stat(pluginConfig.Path); err == nil {
+			states[pluginConfig.ID] = pluginFileState{modTime: stat.ModTime(), size: stat.Size()}
+		}
+	}
+
+	m.reloadWg.Add(1)
+	go func() {
+		defer m.reloadWg.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				m.checkPluginFiles(ctx, states)
+			}
+		}
+	}()
+}
+
+// stopReloadWatcher stops the plugin file watcher if it is running
+func (m *WASMMiddleware) stopReloadWatcher() {
+	if m.reloadCancel != nil {
+		m.reloadCancel()
+		m.reloadWg.Wait()
+	}
+}
+
+// checkPluginFiles reloads every plugin whose file changed since the last check
+func (m *WASMMiddleware) checkPluginFiles(ctx context.Context, states map[string]pluginFileState) {
+	for _, pluginConfig := range m.config.Plugins {
+		stat, err := os.Stat(pluginConfig.Path)
+		if err != nil {
+			// File might be in the middle of being replaced
+			continue
+		}
+
+		current := pluginFileState{modTime: stat.ModTime(), size: stat.Size()}
+		if previous, exists := states[pluginConfig.ID]; exists && previous == current {
+			continue
+		}
+		states[pluginConfig.ID] = current
+
+		// Errors are logged and recorded on the plugin by ReloadPlugin
+		_ = m.ReloadPlugin(ctx, pluginConfig.ID)
+	}
+}
+
+// ReloadPlugin recompiles the plugin from its file and atomically swaps it in.
+// Requests already executing the old module finish before it is closed. If
+// the new module fails to load, the old one stays active and the error is
+// recorded on it. Reloading an unchanged file is a no-op.
+func (m *WASMMiddleware) ReloadPlugin(ctx context.Context, pluginID string) error {
+	var cfg *config.WASMPlugin
+	for i := range m.config.Plugins {
+		if m.config.Plugins[i].ID == pluginID {
+			cfg = &m.config.Plugins[i]
+			break
+		}
+	}
+	if cfg == nil {
+		return fmt.Errorf("plugin %s is not configured", pluginID)
+	}
+
+	m.mutex.RLock()
+	old := m.plugins[pluginID]
+	m.mutex.RUnlock()
+
+	if old != nil {
+		if wasmBytes, err := os.ReadFile(cfg.Path); err == nil {
+			sum := sha256.Sum256(wasmBytes)
+			if hex.EncodeToString(sum[:]) == old.Hash {
+				return nil
+			}
+		}
+	}
+
+	moduleName := fmt.Sprintf("%s@%d", cfg.Name, atomic.AddInt64(&wasmModuleGeneration, 1))
+	plugin, err := m.compilePlugin(ctx, *cfg, moduleName)
+	if err != nil {
+		if old != nil {
+			m.mutex.Lock()
+			old.LastReloadError = err.Error()
+			m.mutex.Unlock()
+		}
+		log.Printf("Failed to reload WASM plugin %s, keeping previous version: %v", cfg.Name, err)
+		return fmt.Errorf("failed to reload plugin %s: %w", cfg.Name, err)
+	}
+
+	plugin.ReloadedAt = time.Now()
+
+	m.mutex.Lock()
+	if old != nil {
+		plugin.CallCount = old.CallCount
+		plugin.ErrorCount = old.ErrorCount
+		plugin.ReloadCount = old.ReloadCount
+	}
+	plugin.ReloadCount++
+	m.plugins[pluginID] = plugin
+	m.mutex.Unlock()
+
+	if old != nil {
+		// Drain requests still using the old module before closing it
+		go func() {
+			old.inflight.Wait()
+			m.mutex.Lock()
+			delete(m.modulePlugins, old.Module.Name())
+			m.mutex.Unlock()
+			old.Module.Close(context.Background())
+			old.compiled.Close(context.Background())
+		}()
+	}
+
+	log.Printf("Successfully reloaded WASM plugin: %s (hash %s)", cfg.Name, plugin.Hash)
+	return nil
+}
+
+// PluginInfos returns version and reload information for all loaded plugins
+func (m *WASMMiddleware) PluginInfos() []PluginInfo {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	infos := make([]PluginInfo, 0, len(m.plugins))
+	for id, plugin := range m.plugins {
+		infos = append(infos, PluginInfo{
+			ID:              id,
+			Name:            plugin.Name,
+			Path:            plugin.Path,
+			Hash:            plugin.Hash,
+			LoadedAt:        plugin.LoadedAt,
+			ReloadedAt:      plugin.ReloadedAt,
+			ReloadCount:     plugin.ReloadCount,
+			LastReloadError: plugin.LastReloadError,
+		})
+	}
+
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].ID < infos[j].ID
+	})
+
+	return infos
+}
@@ -1,8 +1,11 @@
 package middleware
 
 import (
+	"context"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
@@ -295,7 +298,7 @@ func TestWASMMiddleware_findMatchingPlugins(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			req := httptest.NewRequest(tt.requestMethod, tt.requestPath, nil)
-			matchingPlugins := middleware.findMatchingPlugins(req)
+			matchingPlugins := middleware.findMatchingPlugins(req, "request")
 
 			if len(matchingPlugins) != len(tt.expectedPlugins) {
 				t.Errorf("expected %d plugins, got %d", len(tt.expectedPlugins), len(matchingPlugins))
@@ -317,6 +320,60 @@ func TestWASMMiddleware_findMatchingPlugins(t *testing.T) {
 	}
 }
 
+func TestWASMMiddleware_findMatchingPluginsPhaseOrder(t *testing.T) {
+	newPlugin := func(id, phase string, priority int) *WASMPlugin {
+		return &WASMPlugin{
+			ID:     id,
+			Name:   id,
+			config: config.WASMPlugin{ID: id, Phase: phase, Priority: priority},
+		}
+	}
+
+	middleware := &WASMMiddleware{
+		config: &config.WASMConfig{
+			Enabled: true,
+			Plugins: []config.WASMPlugin{{ID: "authz", Phase: config.PluginPhaseAuth}},
+			Rules: []config.WASMRule{
+				{ID: "rule", Path: "/api", Plugins: []string{"low", "authz", "high", "default", "response"}},
+			},
+		},
+		plugins: map[string]*WASMPlugin{
+			"low":      newPlugin("low", config.PluginPhaseRequest, -10),
+			"authz":    newPlugin("authz", config.PluginPhaseAuth, 0),
+			"high":     newPlugin("high", config.PluginPhaseRequest, 10),
+			"default":  newPlugin("default", "", 0),
+			"response": newPlugin("response", config.PluginPhaseResponse, 0),
+		},
+	}
+
+	tests := []struct {
+		phase    string
+		expected []string
+	}{
+		{config.PluginPhaseAuth, []string{"authz"}},
+		{config.PluginPhaseRequest, []string{"high", "default", "low"}},
+		{config.PluginPhaseResponse, []string{"response"}},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/api", nil)
+		plugins := middleware.findMatchingPlugins(req, tt.phase)
+		releasePlugins(plugins)
+
+		var ids []string
+		for _, plugin := range plugins {
+			ids = append(ids, plugin.ID)
+		}
+		if strings.Join(ids, ",") != strings.Join(tt.expected, ",") {
+			t.Errorf("Expected %s plugins %v, got %v", tt.phase, tt.expected, ids)
+		}
+	}
+
+	if !middleware.HasPhase(config.PluginPhaseAuth) || middleware.HasPhase(config.PluginPhaseResponse) {
+		t.Error("Expected HasPhase to reflect configured plugin phases")
+	}
+}
+
 func TestWASMMiddleware_GetStats(t *testing.T) {
 	plugin1 := &WASMPlugin{
 		ID:         "plugin1",
@@ -376,6 +433,192 @@ func TestWASMMiddleware_GetStats(t *testing.T) {
 	}
 }
 
+func TestWASMMiddleware_HotReload(t *testing.T) {
+	// Minimal valid modules; v2 only adds a type section so its hash differs
+	emptyModule := []byte("\x00asm\x01\x00\x00\x00")
+	moduleV2 := append(append([]byte{}, emptyModule...), 0x01, 0x04, 0x01, 0x60, 0x00, 0x00)
+
+	path := filepath.Join(t.TempDir(), "plugin.wasm")
+	if err := os.WriteFile(path, emptyModule, 0644); err != nil {
+		t.Fatalf("failed to write plugin: %v", err)
+	}
+
+	middleware, err := NewWASMMiddleware(&config.WASMConfig{
+		Enabled: true,
+		Plugins: []config.WASMPlugin{
+			{ID: "reloadable", Name: "reloadable", Path: path, Required: true},
+		},
+		HotReload:      true,
+		ReloadInterval: 10 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("failed to create WASM middleware: %v", err)
+	}
+	defer middleware.Close(context.Background())
+
+	waitFor := func(condition func(PluginInfo) bool) PluginInfo {
+		deadline := time.Now().Add(2 * time.Second)
+		for {
+			info := middleware.PluginInfos()[0]
+			if condition(info) || time.Now().After(deadline) {
+				return info
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	initial := middleware.PluginInfos()[0]
+	if initial.Hash == "" || initial.ReloadCount != 0 {
+		t.Fatalf("expected loaded plugin with hash and no reloads, got %+v", initial)
+	}
+
+	if err := os.WriteFile(path, moduleV2, 0644); err != nil {
+		t.Fatalf("failed to update plugin: %v", err)
+	}
+	reloaded := waitFor(func(info PluginInfo) bool { return info.ReloadCount == 1 })
+	if reloaded.ReloadCount != 1 || reloaded.Hash == initial.Hash || reloaded.ReloadedAt.IsZero() {
+		t.Fatalf("expected plugin to be reloaded with a new hash, got %+v", reloaded)
+	}
+
+	// A module that fails to compile keeps the previous version active
+	if err := os.WriteFile(path, []byte("not a wasm module"), 0644); err != nil {
+		t.Fatalf("failed to corrupt plugin: %v", err)
+	}
+	failed := waitFor(func(info PluginInfo) bool { return info.LastReloadError != "" })
+	if failed.LastReloadError == "" {
+		t.Fatal("expected reload error to be recorded")
+	}
+	if failed.Hash != reloaded.Hash || failed.ReloadCount != 1 {
+		t.Errorf("expected previous version to stay active, got %+v", failed)
+	}
+
+	if err := middleware.ReloadPlugin(context.Background(), "unknown"); err == nil {
+		t.Error("expected error reloading unknown plugin")
+	}
+}
+
+func TestWASMMiddleware_KVCounter(t *testing.T) {
+	// Sample plugin exporting count(), which calls kv_incr("hits", 1) and
+	// returns the new counter value
+	counterModule := []byte{
+		0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00,
+		// types: (i32, i32, i64, i32) -> i32 and () -> i64
+		0x01, 0x0d, 0x02, 0x60, 0x04, 0x7f, 0x7f, 0x7e, 0x7f, 0x01, 0x7f, 0x60, 0x00, 0x01, 0x7e,
+		// import env.kv_incr
+		0x02, 0x0f, 0x01, 0x03, 'e', 'n', 'v', 0x07, 'k', 'v', '_', 'i', 'n', 'c', 'r', 0x00, 0x00,
+		// one function of type 1
+		0x03, 0x02, 0x01, 0x01,
+		// one page of memory
+		0x05, 0x03, 0x01, 0x00, 0x01,
+		// export count
+		0x07, 0x09, 0x01, 0x05, 'c', 'o', 'u', 'n', 't', 0x00, 0x01,
+		// kv_incr(0, 4, 1, 16); drop; i64.load 16
+		0x0a, 0x14, 0x01, 0x12, 0x00, 0x41, 0x00, 0x41, 0x04, 0x42, 0x01, 0x41, 0x10, 0x10, 0x00, 0x1a,
+		0x41, 0x10, 0x29, 0x03, 0x00, 0x0b,
+		// data: "hits" at offset 0
+		0x0b, 0x0a, 0x01, 0x00, 0x41, 0x00, 0x0b, 0x04, 'h', 'i', 't', 's',
+	}
+
+	path := filepath.Join(t.TempDir(), "counter.wasm")
+	if err := os.WriteFile(path, counterModule, 0644); err != nil {
+		t.Fatalf("failed to write plugin: %v", err)
+	}
+
+	middleware, err := NewWASMMiddleware(&config.WASMConfig{
+		Enabled: true,
+		Plugins: []config.WASMPlugin{
+			{ID: "counter-a", Name: "counter-a", Path: path, Required: true},
+			{ID: "counter-b", Name: "counter-b", Path: path, Required: true},
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to create WASM middleware: %v", err)
+	}
+	defer middleware.Close(context.Background())
+
+	count := func(pluginID string) uint64 {
+		results, err := middleware.plugins[pluginID].Module.ExportedFunction("count").Call(context.Background())
+		if err != nil {
+			t.Fatalf("count failed: %v", err)
+		}
+		return results[0]
+	}
+
+	// The counter persists across calls
+	for i := uint64(1); i <= 3; i++ {
+		if got := count("counter-a"); got != i {
+			t.Errorf("Expected counter %d, got %d", i, got)
+		}
+	}
+
+	// Each plugin has its own namespace
+	if got := count("counter-b"); got != 1 {
+		t.Errorf("Expected separate counter for second plugin, got %d", got)
+	}
+
+	if keys, _ := middleware.kv.Usage("counter-a"); keys != 1 {
+		t.Errorf("Expected 1 key accounted to plugin, got %d", keys)
+	}
+}
+
+func TestWASMKVStore_Quota(t *testing.T) {
+	kv, err := newWASMKVStore(config.WASMKVConfig{MaxKeys: 2, MaxBytes: 64, MaxValueSize: 16})
+	if err != nil {
+		t.Fatalf("failed to create kv store: %v", err)
+	}
+	defer kv.Close()
+
+	ctx := context.Background()
+
+	if err := kv.Set(ctx, "p1", "a", []byte("1"), 0); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := kv.Set(ctx, "p1", "b", []byte("2"), 0); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := kv.Set(ctx, "p1", "c", []byte("3"), 0); err != ErrWASMKVQuotaExceeded {
+		t.Errorf("Expected key quota error, got %v", err)
+	}
+
+	// Overwriting an existing key does not count as a new key
+	if err := kv.Set(ctx, "p1", "a", []byte("updated"), 0); err != nil {
+		t.Errorf("Expected overwrite to succeed, got %v", err)
+	}
+
+	// Quotas are per plugin
+	if err := kv.Set(ctx, "p2", "c", []byte("3"), 0); err != nil {
+		t.Errorf("Expected other plugin to have its own quota, got %v", err)
+	}
+	if value, _ := kv.Get(ctx, "p2", "a"); value != nil {
+		t.Errorf("Expected plugins not to see each other's keys, got %q", value)
+	}
+
+	if err := kv.Set(ctx, "p2", "big", make([]byte, 17), 0); err != ErrWASMKVTooLarge {
+		t.Errorf("Expected value size error, got %v", err)
+	}
+	if err := kv.Set(ctx, "p3", "big", make([]byte, 16), 0); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if _, err := kv.Incr(ctx, "p3", strings.Repeat("k", 40), 1); err != ErrWASMKVQuotaExceeded {
+		t.Errorf("Expected byte quota error, got %v", err)
+	}
+
+	// Expired keys no longer count against the quota
+	if err := kv.Set(ctx, "p4", "a", []byte("1"), 0); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := kv.Set(ctx, "p4", "b", []byte("2"), 20*time.Millisecond); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	time.Sleep(30 * time.Millisecond)
+	if value, _ := kv.Get(ctx, "p4", "b"); value != nil {
+		t.Errorf("Expected expired key to be gone, got %q", value)
+	}
+	if err := kv.Set(ctx, "p4", "c", []byte("3"), 0); err != nil {
+		t.Errorf("Expected expired key to free quota, got %v", err)
+	}
+}
+
 func TestWASMMiddleware_matchPath(t *testing.T) {
 	middleware := &WASMMiddleware{}
 
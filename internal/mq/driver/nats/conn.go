@@ -0,0 +1,314 @@
+package nats
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/songzhibin97/stargate/pkg/mq"
+)
+
+const (
+	// defaultServer is the server connected to when no broker is configured
+	defaultServer = "nats://127.0.0.1:4222"
+
+	// defaultPort is the port of brokers configured without one
+	defaultPort = "4222"
+
+	// defaultTimeout bounds connecting and requests when no timeout is
+	// configured
+	defaultTimeout = 5 * time.Second
+)
+
+// reconnectWait is the delay between attempts to reconnect to the servers
+// after the connection is lost
+var reconnectWait = 2 * time.Second
+
+// options are the connection settings of a producer or consumer
+type options struct {
+	servers   []*url.URL // Without credentials, which are passed apart
+	name      string
+	user      string
+	pass      string
+	token     string
+	tls       *tls.Config // nil unless TLS is configured, servers may still require it
+	timeout   time.Duration
+	jetStream bool
+}
+
+// newOptions returns the connection settings of a configuration
+func newOptions(brokers []string, clientID string, security mq.SecurityConfig, extra map[string]interface{}, timeout time.Duration) (*options, error) {
+	opts := &options{name: clientID, timeout: timeout}
+	if opts.timeout <= 0 {
+		opts.timeout = defaultTimeout
+	}
+
+	if len(brokers) == 0 {
+		brokers = []string{defaultServer}
+	}
+	for _, broker := range brokers {
+		if !strings.Contains(broker, "://") {
+			broker = "nats://" + broker
+		}
+		u, err := url.Parse(broker)
+		if err != nil || u.Host == "" || (u.Scheme != "nats" && u.Scheme != "tls") {
+			return nil, configurationError(fmt.Sprintf("invalid NATS broker address: %s", broker), mq.ErrInvalidBroker)
+		}
+		if u.Port() == "" {
+			u.Host = net.JoinHostPort(u.Hostname(), defaultPort)
+		}
+		if u.User != nil && opts.user == "" && opts.token == "" {
+			if pass, ok := u.User.Password(); ok {
+				opts.user, opts.pass = u.User.Username(), pass
+			} else {
+				opts.token = u.User.Username()
+			}
+		}
+		u.User = nil
+		opts.servers = append(opts.servers, u)
+	}
+
+	if security.SASL.Enabled {
+		opts.user, opts.pass, opts.token = security.SASL.Username, security.SASL.Password, ""
+	}
+	if token, ok := extra["token"].(string); ok && token != "" {
+		opts.user, opts.pass, opts.token = "", "", token
+	}
+
+	jetStream, err := boolOption(extra, "jetstream")
+	if err != nil {
+		return nil, err
+	}
+	opts.jetStream = jetStream
+
+	if security.TLS.Enabled {
+		tlsConfig, err := newTLSConfig(security.TLS)
+		if err != nil {
+			return nil, err
+		}
+		opts.tls = tlsConfig
+	}
+
+	return opts, nil
+}
+
+// boolOption returns a boolean driver-specific option, false if unset
+func boolOption(extra map[string]interface{}, name string) (bool, error) {
+	switch value := extra[name].(type) {
+	case nil:
+		return false, nil
+	case bool:
+		return value, nil
+	case string:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return false, configurationError(fmt.Sprintf("invalid %s option: %s", name, value), mq.ErrInvalidConfig)
+		}
+		return b, nil
+	default:
+		return false, configurationError(fmt.Sprintf("invalid %s option: %v", name, value), mq.ErrInvalidConfig)
+	}
+}
+
+// newTLSConfig returns the client TLS configuration of the TLS settings
+func newTLSConfig(config mq.TLSConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: config.InsecureSkipVerify}
+	if config.CertFile != "" || config.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(config.CertFile, config.KeyFile)
+		if err != nil {
+			return nil, configurationError(fmt.Sprintf("failed to load client certificate: %v", err), mq.ErrInvalidConfig)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	if config.CAFile != "" {
+		ca, err := os.ReadFile(config.CAFile)
+		if err != nil {
+			return nil, configurationError(fmt.Sprintf("failed to read CA certificate: %v", err), mq.ErrInvalidConfig)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, configurationError("no CA certificate found in "+config.CAFile, mq.ErrInvalidConfig)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	return tlsConfig, nil
+}
+
+// conn is a connection to NATS, with its JetStream context. The client
+// reconnects to the configured servers in turn when the connection is lost,
+// subscribing again, until it is closed.
+type conn struct {
+	nc   *nats.Conn
+	js   jetstream.JetStream
+	opts *options
+}
+
+// connect connects to the first reachable server
+func connect(opts *options) (*conn, error) {
+	servers := make([]string, len(opts.servers))
+	for i, server := range opts.servers {
+		servers[i] = server.String()
+	}
+
+	natsOpts := []nats.Option{
+		nats.Name(opts.name),
+		nats.Timeout(opts.timeout),
+		nats.DontRandomize(),
+		nats.MaxReconnects(-1),
+		nats.ReconnectWait(reconnectWait),
+		// Publishing fails while disconnected instead of buffering messages
+		// that are lost if the connection isn't re-established
+		nats.ReconnectBufSize(-1),
+	}
+	if opts.user != "" {
+		natsOpts = append(natsOpts, nats.UserInfo(opts.user, opts.pass))
+	}
+	if opts.token != "" {
+		natsOpts = append(natsOpts, nats.Token(opts.token))
+	}
+	if opts.tls != nil {
+		natsOpts = append(natsOpts, nats.Secure(opts.tls))
+	}
+
+	nc, err := nats.Connect(strings.Join(servers, ","), natsOpts...)
+	if err != nil {
+		return nil, connectionError("CONNECT_FAILED", "failed to connect to NATS", err)
+	}
+	js, err := jetstream.New(nc, jetstream.WithDefaultTimeout(opts.timeout))
+	if err != nil {
+		nc.Close()
+		return nil, connectionError("CONNECT_FAILED", "failed to create JetStream context", err)
+	}
+	return &conn{nc: nc, js: js, opts: opts}, nil
+}
+
+// flush waits until the server processed everything sent before
+func (c *conn) flush(ctx context.Context) error {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+	if err := c.nc.FlushWithContext(ctx); err != nil {
+		if ctx.Err() != nil {
+			return timeoutError("FLUSH_TIMEOUT", "NATS flush timed out", err)
+		}
+		return natsError(err)
+	}
+	return nil
+}
+
+// withTimeout bounds a context without a deadline by the timeout
+func (c *conn) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, c.opts.timeout)
+}
+
+// connected reports whether the connection is established
+func (c *conn) connected() bool {
+	return c.nc.IsConnected()
+}
+
+// close closes the connection after sending buffered data
+func (c *conn) close() error {
+	if c.nc.IsClosed() {
+		return nil
+	}
+	c.nc.Flush()
+	c.nc.Close()
+	return nil
+}
+
+// health returns the health status of the connection, measuring the round
+// trip time to the server
+func (c *conn) health(ctx context.Context, component string, details map[string]interface{}) mq.HealthStatus {
+	health := mq.HealthStatus{
+		Status:    "healthy",
+		Message:   fmt.Sprintf("NATS %s is connected", component),
+		Timestamp: time.Now(),
+		Details:   details,
+	}
+	health.Details["type"] = "nats"
+	health.Details["connected"] = c.connected()
+	health.Details["jetstream"] = c.opts.jetStream
+
+	if !c.connected() {
+		health.Status = "unhealthy"
+		health.Message = fmt.Sprintf("NATS %s is disconnected", component)
+		if err := c.nc.LastError(); err != nil {
+			health.Details["error"] = err.Error()
+		}
+		return health
+	}
+
+	health.Details["server"] = c.nc.ConnectedUrlRedacted()
+	health.Details["server_id"] = c.nc.ConnectedServerId()
+	health.Details["server_version"] = c.nc.ConnectedServerVersion()
+	start := time.Now()
+	if err := c.flush(ctx); err != nil {
+		health.Status = "unhealthy"
+		health.Message = fmt.Sprintf("NATS round trip failed: %v", err)
+		health.Details["error"] = err.Error()
+		return health
+	}
+	health.Details["rtt"] = time.Since(start).String()
+	return health
+}
+
+// natsError returns the mq error of an error of the NATS client
+func natsError(err error) error {
+	switch {
+	case errors.Is(err, nats.ErrMaxPayload):
+		e := mq.NewProducerError("MESSAGE_TOO_LARGE", err.Error(), false)
+		e.Cause = mq.ErrMessageTooLarge
+		return e
+	case errors.Is(err, nats.ErrBadSubject):
+		e := mq.NewProducerError("INVALID_TOPIC", err.Error(), false)
+		e.Cause = mq.ErrInvalidTopic
+		return e
+	case errors.Is(err, nats.ErrHeadersNotSupported):
+		return mq.NewProducerError("HEADERS_NOT_SUPPORTED", "NATS server doesn't support message headers", false)
+	case errors.Is(err, nats.ErrConnectionClosed):
+		return connectionError("CONNECTION_CLOSED", "connection to NATS is closed", mq.ErrConnectionClosed)
+	case errors.Is(err, nats.ErrReconnectBufExceeded), errors.Is(err, nats.ErrConnectionReconnecting), errors.Is(err, nats.ErrDisconnected):
+		return connectionError("NOT_CONNECTED", "not connected to NATS", err)
+	case errors.Is(err, nats.ErrTimeout), errors.Is(err, context.DeadlineExceeded):
+		return timeoutError("REQUEST_TIMEOUT", "NATS request timed out", err)
+	case errors.Is(err, nats.ErrNoResponders), errors.Is(err, jetstream.ErrJetStreamNotEnabled), errors.Is(err, jetstream.ErrJetStreamNotEnabledForAccount):
+		return connectionError("JETSTREAM_UNAVAILABLE", "JetStream is not enabled on the NATS server", err)
+	}
+	return err
+}
+
+// connectionError returns a retryable connection error
+func connectionError(code, message string, cause error) error {
+	err := mq.NewConnectionError(code, message, true)
+	err.Cause = cause
+	if cause != nil {
+		err.Details = cause.Error()
+	}
+	return err
+}
+
+// timeoutError returns a timeout error
+func timeoutError(code, message string, cause error) error {
+	err := mq.NewTimeoutError(code, message)
+	err.Cause = cause
+	return err
+}
+
+// configurationError returns a configuration error
+func configurationError(message string, cause error) error {
+	err := mq.NewConfigurationError("INVALID_CONFIG", message)
+	err.Cause = cause
+	return err
+}
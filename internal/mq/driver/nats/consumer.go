@@ -0,0 +1,734 @@
+package nats
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/songzhibin97/stargate/pkg/mq"
+)
+
+const (
+	// maxQueued is the number of messages a core subscription queues for
+	// its worker, the next ones wait in the pending buffer of the client
+	maxQueued = 1024
+
+	// pendingMsgsLimit and pendingBytesLimit bound the pending buffer of
+	// core subscriptions. The client drops the messages beyond, reporting
+	// the subscription as a slow consumer.
+	pendingMsgsLimit  = 64 * 1024
+	pendingBytesLimit = 64 * 1024 * 1024
+)
+
+// Consumer implements mq.Consumer on NATS. Subscriptions are core NATS
+// subscriptions unless the "jetstream" option is set or their options need
+// a stream, see the package documentation.
+type Consumer struct {
+	conn      *conn
+	groupID   string
+	jetStream bool
+	ackWait   time.Duration
+	stats     stats
+
+	duplicates int64 // Messages skipped by deduplication
+
+	mu     sync.Mutex
+	subs   map[string]*consumerSub // By topic
+	closed bool
+
+	compressorsMu       sync.Mutex
+	compressors         map[mq.CompressionType]mq.Compressor // Created on first use
+	maxDecompressedSize int64
+}
+
+// consumerSub is the subscription of a consumer to a topic. Its messages
+// are queued by the client and handled in order by a worker.
+type consumerSub struct {
+	topic   string
+	handler mq.MessageHandler
+	opts    mq.SubscribeOptions
+	dedup   *mq.Deduplicator // Nil without deduplication
+
+	sub *nats.Subscription // Core subscription, nil for JetStream
+
+	// JetStream consumer, empty for core subscriptions
+	js        jetstream.Consumer
+	config    jetstream.ConsumerConfig // To create the ephemeral consumer again
+	consume   jetstream.ConsumeContext // Drained while paused
+	stream    string
+	consumer  string
+	ephemeral bool
+	pending   int64  // Messages left for the consumer, from the last delivery
+	received  uint64 // Stream sequence of the last message received
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{} // Closed when the worker exits
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	queue   []*delivery
+	paused  bool
+	resumed chan struct{} // Closed when a paused subscription resumes
+	stopped bool
+}
+
+// delivery is a message received by a subscription
+type delivery struct {
+	subject string
+	header  nats.Header
+	data    []byte
+	js      jetstream.Msg // Nil for core NATS messages
+}
+
+// NewConsumer creates a consumer connected to the configured brokers
+func NewConsumer(config *mq.ConsumerConfig) (mq.Consumer, error) {
+	if config == nil {
+		return nil, configurationError("consumer config cannot be nil", mq.ErrMissingConfig)
+	}
+
+	opts, err := newOptions(config.Brokers, config.ClientID, config.Security, config.Options, 0)
+	if err != nil {
+		return nil, err
+	}
+	c, err := connect(opts)
+	if err != nil {
+		return nil, err
+	}
+	return &Consumer{
+		conn:        c,
+		groupID:     config.GroupID,
+		jetStream:   opts.jetStream,
+		ackWait:     config.SessionTimeout,
+		subs:        make(map[string]*consumerSub),
+		compressors: make(map[mq.CompressionType]mq.Compressor),
+
+		maxDecompressedSize: config.MaxDecompressedSize,
+	}, nil
+}
+
+// Subscribe subscribes to a topic with a message handler
+func (c *Consumer) Subscribe(ctx context.Context, topic string, handler mq.MessageHandler) error {
+	return c.SubscribeWithOptions(ctx, topic, handler, nil)
+}
+
+// SubscribeWithOptions subscribes to a topic with options. The context
+// bounds setting up the subscription, which lasts until it is unsubscribed.
+func (c *Consumer) SubscribeWithOptions(ctx context.Context, topic string, handler mq.MessageHandler, opts *mq.SubscribeOptions) error {
+	if topic == "" {
+		err := mq.NewConsumerError("INVALID_TOPIC", "topic cannot be empty", false)
+		err.Cause = mq.ErrInvalidTopic
+		return err
+	}
+	if handler == nil {
+		return mq.NewConsumerError("INVALID_HANDLER", "handler cannot be nil", false)
+	}
+
+	s := &consumerSub{topic: topic, handler: handler, done: make(chan struct{})}
+	if opts != nil {
+		s.opts = *opts
+	}
+	if s.opts.StartFromOffset != nil && *s.opts.StartFromOffset < 0 {
+		return mq.NewConsumerError("INVALID_OFFSET", "offset cannot be negative", false)
+	}
+	s.dedup = mq.NewSubscribeDeduplicator(&s.opts)
+	s.cond = sync.NewCond(&s.mu)
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return consumerClosedError()
+	}
+	if _, ok := c.subs[topic]; ok {
+		return mq.NewConsumerError("ALREADY_SUBSCRIBED", fmt.Sprintf("already subscribed to topic %s", topic), false)
+	}
+
+	var err error
+	if c.jetStream || needsJetStream(&s.opts) {
+		err = c.subscribeJetStream(ctx, s)
+	} else {
+		err = c.subscribeCore(ctx, s)
+	}
+	if err != nil {
+		s.cancel()
+		return err
+	}
+
+	c.subs[topic] = s
+	go c.work(s)
+	return nil
+}
+
+// needsJetStream reports whether subscribe options need a JetStream
+// consumer, to replay stored messages or to redeliver failed ones
+func needsJetStream(opts *mq.SubscribeOptions) bool {
+	return opts.StartFromBeginning || opts.StartFromTimestamp != nil || opts.StartFromOffset != nil ||
+		opts.DeadLetterTopic != "" || opts.MaxRetries > 0
+}
+
+// subscribeCore subscribes to a topic with core NATS, in the queue group
+// of the consumer group
+func (c *Consumer) subscribeCore(ctx context.Context, s *consumerSub) error {
+	sub, err := c.conn.nc.QueueSubscribe(s.topic, c.groupID, func(m *nats.Msg) {
+		s.enqueue(&delivery{subject: m.Subject, header: m.Header, data: m.Data})
+	})
+	if err != nil {
+		return natsError(err)
+	}
+	if err := sub.SetPendingLimits(pendingMsgsLimit, pendingBytesLimit); err != nil {
+		sub.Unsubscribe()
+		return natsError(err)
+	}
+	// Wait for the server to register the subscription, so messages
+	// published once Subscribe returns are received
+	if err := c.conn.flush(ctx); err != nil {
+		sub.Unsubscribe()
+		return err
+	}
+	s.sub = sub
+	return nil
+}
+
+// subscribeJetStream subscribes to a topic through a pull consumer on the
+// stream capturing it. Consumers of a group share a durable consumer
+// delivering each message to one of them, others get an ephemeral one.
+func (c *Consumer) subscribeJetStream(ctx context.Context, s *consumerSub) error {
+	stream, err := c.conn.streamForSubject(ctx, s.topic)
+	if err != nil {
+		return err
+	}
+
+	config := jetstream.ConsumerConfig{
+		DeliverPolicy: jetstream.DeliverNewPolicy,
+		AckPolicy:     jetstream.AckExplicitPolicy,
+		AckWait:       c.ackWait,
+		FilterSubject: s.topic,
+	}
+	switch {
+	case s.opts.StartFromBeginning:
+		config.DeliverPolicy = jetstream.DeliverAllPolicy
+	case s.opts.StartFromTimestamp != nil:
+		config.DeliverPolicy, config.OptStartTime = jetstream.DeliverByStartTimePolicy, s.opts.StartFromTimestamp
+	case s.opts.StartFromOffset != nil && *s.opts.StartFromOffset > 0:
+		config.DeliverPolicy, config.OptStartSeq = jetstream.DeliverByStartSequencePolicy, uint64(*s.opts.StartFromOffset)
+	case s.opts.StartFromOffset != nil:
+		config.DeliverPolicy = jetstream.DeliverAllPolicy
+	}
+	// Without a dead letter topic the server stops redelivering, with one
+	// the consumer terminates the message once it is dead-lettered
+	if s.opts.MaxRetries > 0 && s.opts.DeadLetterTopic == "" {
+		config.MaxDeliver = s.opts.MaxRetries + 1
+	}
+	if c.groupID != "" {
+		config.Durable = durableName(c.groupID, s.topic)
+	}
+
+	var consumer jetstream.Consumer
+	if config.Durable != "" {
+		// Another member of the group may have created the consumer, which
+		// resumes where the group left off
+		consumer, err = c.conn.js.Consumer(ctx, stream, config.Durable)
+		if errors.Is(err, jetstream.ErrConsumerNotFound) {
+			consumer, err = c.conn.js.CreateConsumer(ctx, stream, config)
+		}
+	} else {
+		consumer, err = c.conn.js.CreateConsumer(ctx, stream, config)
+	}
+	if err != nil {
+		return natsError(err)
+	}
+	info := consumer.CachedInfo()
+
+	s.js, s.config, s.stream, s.consumer, s.ephemeral = consumer, config, stream, info.Name, config.Durable == ""
+	s.received = info.Delivered.Stream
+	if err := c.consumeJetStream(s); err != nil {
+		if s.ephemeral {
+			c.conn.js.DeleteConsumer(ctx, stream, info.Name)
+		}
+		return err
+	}
+	return nil
+}
+
+// consumeJetStream starts pulling the messages of a JetStream subscription
+func (c *Consumer) consumeJetStream(s *consumerSub) error {
+	consume, err := s.js.Consume(func(m jetstream.Msg) {
+		if meta, err := m.Metadata(); err == nil {
+			atomic.StoreUint64(&s.received, meta.Sequence.Stream)
+		}
+		s.enqueue(&delivery{subject: m.Subject(), header: m.Headers(), data: m.Data(), js: m})
+	}, jetstream.ConsumeErrHandler(func(_ jetstream.ConsumeContext, err error) {
+		c.stats.setError(err)
+	}))
+	if err != nil {
+		return natsError(err)
+	}
+	s.consume = consume
+	return nil
+}
+
+// resumeJetStream pulls the messages of a paused JetStream subscription
+// again. The server removes inactive ephemeral consumers, which are then
+// created again after the last message received.
+func (c *Consumer) resumeJetStream(s *consumerSub) error {
+	if s.ephemeral {
+		ctx, cancel := c.conn.withTimeout(context.Background())
+		defer cancel()
+		_, err := s.js.Info(ctx)
+		if errors.Is(err, jetstream.ErrConsumerNotFound) {
+			config := s.config
+			config.DeliverPolicy, config.OptStartTime = jetstream.DeliverByStartSequencePolicy, nil
+			config.OptStartSeq = atomic.LoadUint64(&s.received) + 1
+			var consumer jetstream.Consumer
+			if consumer, err = c.conn.js.CreateConsumer(ctx, s.stream, config); err == nil {
+				s.js, s.consumer = consumer, consumer.CachedInfo().Name
+			}
+		}
+		if err != nil {
+			return natsError(err)
+		}
+	}
+	return c.consumeJetStream(s)
+}
+
+// durableName returns the name of the durable consumer of a group for a
+// topic, which can't contain dots, wildcards or whitespace
+func durableName(groupID, topic string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '.', '*', '>', ' ', '\t', '\r', '\n':
+			return '_'
+		}
+		return r
+	}, groupID+"-"+topic)
+}
+
+// enqueue queues a message for the worker. Core NATS messages wait while
+// the queue is full, JetStream ones are bounded by the pulls.
+func (s *consumerSub) enqueue(d *delivery) {
+	s.mu.Lock()
+	for d.js == nil && len(s.queue) >= maxQueued && !s.stopped {
+		s.cond.Wait()
+	}
+	if !s.stopped {
+		s.queue = append(s.queue, d)
+		s.cond.Broadcast()
+	}
+	s.mu.Unlock()
+}
+
+// next returns the next message to handle, or nil once stopped
+func (s *consumerSub) next() *delivery {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for (len(s.queue) == 0 || s.paused) && !s.stopped {
+		s.cond.Wait()
+	}
+	if s.stopped {
+		return nil
+	}
+	m := s.queue[0]
+	s.queue[0] = nil
+	s.queue = s.queue[1:]
+	s.cond.Broadcast()
+	return m
+}
+
+// setPaused pauses or resumes handling messages, returning the channel
+// closed when it resumes if it wasn't paused before
+func (s *consumerSub) setPaused(paused bool) <-chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if paused == s.paused {
+		return nil
+	}
+	s.paused = paused
+	s.cond.Broadcast()
+	if !paused {
+		close(s.resumed)
+		s.resumed = nil
+		return nil
+	}
+	s.resumed = make(chan struct{})
+	return s.resumed
+}
+
+// isPaused reports whether handling messages is paused
+func (s *consumerSub) isPaused() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.paused
+}
+
+// queuedJetStream returns the queued JetStream messages
+func (s *consumerSub) queuedJetStream() []jetstream.Msg {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	msgs := make([]jetstream.Msg, 0, len(s.queue))
+	for _, d := range s.queue {
+		if d.js != nil {
+			msgs = append(msgs, d.js)
+		}
+	}
+	return msgs
+}
+
+// stop stops the worker, waiting for the message being handled
+func (s *consumerSub) stop() {
+	s.mu.Lock()
+	s.stopped = true
+	s.queue = nil
+	s.cond.Broadcast()
+	s.mu.Unlock()
+	s.cancel()
+	<-s.done
+}
+
+// work handles the messages of a subscription until it is stopped
+func (c *Consumer) work(s *consumerSub) {
+	defer close(s.done)
+	for d := s.next(); d != nil; d = s.next() {
+		c.handle(s, d)
+	}
+}
+
+// handle passes a message to the handler, acknowledging it for JetStream
+func (c *Consumer) handle(s *consumerSub, d *delivery) {
+	message := decodeMessage(d.subject, d.header, d.data)
+	var meta *jetstream.MsgMetadata
+	if d.js != nil {
+		meta, _ = d.js.Metadata()
+	}
+	if meta != nil {
+		message.RetryCount = int(meta.NumDelivered) - 1
+		message.MaxRetries = s.opts.MaxRetries
+		if message.Timestamp.IsZero() {
+			message.Timestamp = meta.Timestamp
+		}
+		atomic.StoreInt64(&s.pending, int64(meta.NumPending))
+	}
+
+	start := time.Now()
+	err := c.decompress(message, d.header.Get(headerCompression))
+	if err == nil && s.dedup != nil && s.dedup.IsDuplicate(message) {
+		// Skipped, but still acknowledged
+		atomic.AddInt64(&c.duplicates, 1)
+	} else {
+		if err == nil {
+			err = s.handler(s.ctx, message)
+		}
+		if err == nil && s.dedup != nil {
+			s.dedup.MarkProcessed(message)
+		}
+		c.stats.record(1, len(d.data), time.Since(start), err)
+	}
+	if d.js == nil {
+		return
+	}
+
+	var ackErr error
+	switch {
+	case err == nil:
+		ackErr = d.js.Ack()
+	case s.opts.DeadLetterTopic != "" && meta != nil && int(meta.NumDelivered) > s.opts.MaxRetries:
+		if dlErr := c.deadLetter(s, d, err); dlErr != nil {
+			c.stats.setError(dlErr)
+			ackErr = nak(d.js, s.opts.RetryDelay)
+		} else {
+			ackErr = d.js.Term()
+		}
+	default:
+		ackErr = nak(d.js, s.opts.RetryDelay)
+	}
+	if ackErr != nil {
+		c.stats.setError(ackErr)
+	}
+}
+
+// decompress decompresses the payload of a message published compressed,
+// failing it like a handler error when the compression is unknown
+func (c *Consumer) decompress(message *mq.Message, compression string) error {
+	if compression == "" {
+		return nil
+	}
+
+	c.compressorsMu.Lock()
+	compressor, ok := c.compressors[mq.CompressionType(compression)]
+	if !ok {
+		var err error
+		if compressor, err = mq.NewCompressorWithLimit(mq.CompressionType(compression), 0, c.maxDecompressedSize); err != nil {
+			c.compressorsMu.Unlock()
+			return err
+		}
+		c.compressors[compressor.Type()] = compressor
+	}
+	c.compressorsMu.Unlock()
+
+	payload, err := compressor.Decompress(message.Payload)
+	if err != nil {
+		return err
+	}
+	message.Payload = payload
+	return nil
+}
+
+// deadLetter publishes a message that failed processing to the dead letter
+// topic, with its original topic and the error
+func (c *Consumer) deadLetter(s *consumerSub, d *delivery, cause error) error {
+	header := make(nats.Header, len(d.header)+2)
+	for name, values := range d.header {
+		header[name] = append([]string(nil), values...)
+	}
+	header.Set(headerOriginalTopic, d.subject)
+	header.Set(headerError, strings.NewReplacer("\r", " ", "\n", " ").Replace(cause.Error()))
+
+	return c.conn.jsPublish(context.Background(), &nats.Msg{Subject: s.opts.DeadLetterTopic, Header: header, Data: d.data})
+}
+
+// SubscribeMultiple subscribes to topics with the same handler, none of
+// them if one fails
+func (c *Consumer) SubscribeMultiple(ctx context.Context, topics []string, handler mq.MessageHandler) error {
+	for i, topic := range topics {
+		if err := c.Subscribe(ctx, topic, handler); err != nil {
+			for _, subscribed := range topics[:i] {
+				c.Unsubscribe(subscribed)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// Unsubscribe unsubscribes from a topic, waiting for the message being
+// handled
+func (c *Consumer) Unsubscribe(topic string) error {
+	c.mu.Lock()
+	s, ok := c.subs[topic]
+	delete(c.subs, topic)
+	c.mu.Unlock()
+	if !ok {
+		return mq.NewConsumerError("NOT_SUBSCRIBED", fmt.Sprintf("not subscribed to topic %s", topic), false)
+	}
+	return c.unsubscribe(s)
+}
+
+// unsubscribe stops a subscription, deleting its consumer if ephemeral
+func (c *Consumer) unsubscribe(s *consumerSub) error {
+	var err error
+	if s.sub != nil {
+		if err = s.sub.Unsubscribe(); err != nil && !errors.Is(err, nats.ErrConnectionClosed) {
+			err = natsError(err)
+		} else {
+			err = nil
+		}
+	}
+	if s.consume != nil {
+		s.consume.Stop()
+	}
+	s.stop()
+	if s.ephemeral {
+		ctx, cancel := c.conn.withTimeout(context.Background())
+		defer cancel()
+		// The server deletes it after a while anyway
+		c.conn.js.DeleteConsumer(ctx, s.stream, s.consumer)
+	}
+	return err
+}
+
+// UnsubscribeAll unsubscribes from all topics
+func (c *Consumer) UnsubscribeAll() error {
+	c.mu.Lock()
+	subs := c.subs
+	c.subs = make(map[string]*consumerSub)
+	c.mu.Unlock()
+
+	var firstErr error
+	for _, s := range subs {
+		if err := c.unsubscribe(s); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// Commit does nothing: JetStream messages are acknowledged once handled
+// and core NATS messages aren't acknowledged
+func (c *Consumer) Commit(ctx context.Context) error {
+	return nil
+}
+
+// CommitMessage does nothing, see Commit
+func (c *Consumer) CommitMessage(ctx context.Context, message *mq.Message) error {
+	return nil
+}
+
+// Seek isn't supported: subscribe with a start option instead
+func (c *Consumer) Seek(ctx context.Context, topic string, partition int32, offset int64) error {
+	err := mq.NewConsumerError("UNSUPPORTED_OPERATION", "NATS consumer doesn't support seeking, subscribe with StartFromOffset instead", false)
+	err.Cause = mq.ErrOperationNotSupported
+	return err
+}
+
+// Pause stops handling messages of topics, all subscribed topics when none
+// are given. Subscriptions are kept, so core NATS messages keep being
+// received, in their share of the queue group, and are handled once
+// resumed. Beyond the queue and the pending limits of the subscription they
+// are dropped as a slow consumer. JetStream subscriptions stop pulling and
+// their consumer keeps the messages left, those already received being
+// reported in progress every half acknowledgement wait, so the server
+// doesn't redeliver them.
+func (c *Consumer) Pause(topics ...string) error {
+	return c.setPaused(topics, true)
+}
+
+// Resume resumes handling messages of paused topics, all subscribed topics
+// when none are given
+func (c *Consumer) Resume(topics ...string) error {
+	return c.setPaused(topics, false)
+}
+
+// setPaused pauses or resumes topics, none of them if one isn't subscribed.
+// A JetStream topic that can't pull again stays paused.
+func (c *Consumer) setPaused(topics []string, paused bool) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(topics) == 0 {
+		for topic := range c.subs {
+			topics = append(topics, topic)
+		}
+	}
+	for _, topic := range topics {
+		if _, ok := c.subs[topic]; !ok {
+			return mq.NewConsumerError("NOT_SUBSCRIBED", fmt.Sprintf("not subscribed to topic %s", topic), false)
+		}
+	}
+	for _, topic := range topics {
+		s := c.subs[topic]
+		if s.stream == "" {
+			s.setPaused(paused)
+			continue
+		}
+		if s.isPaused() == paused {
+			continue
+		}
+		if paused {
+			// Messages already pulled are still queued
+			resumed := s.setPaused(true)
+			s.consume.Drain()
+			go c.keepInProgress(s, resumed)
+			continue
+		}
+		if err := c.resumeJetStream(s); err != nil {
+			return err
+		}
+		s.setPaused(false)
+	}
+	return nil
+}
+
+// keepInProgress reports the queued messages of a paused JetStream
+// subscription in progress until it resumes or stops
+func (c *Consumer) keepInProgress(s *consumerSub, resumed <-chan struct{}) {
+	ackWait := c.ackWait
+	if ackWait <= 0 {
+		ackWait = defaultAckWait
+	}
+	ticker := time.NewTicker(ackWait / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-resumed:
+			return
+		case <-s.ctx.Done():
+			return
+		}
+		for _, m := range s.queuedJetStream() {
+			if err := m.InProgress(); err != nil {
+				c.stats.setError(err)
+				break
+			}
+		}
+	}
+}
+
+// Close unsubscribes from all topics and closes the connection
+func (c *Consumer) Close() error {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil
+	}
+	c.closed = true
+	c.mu.Unlock()
+
+	c.UnsubscribeAll()
+	return c.conn.close()
+}
+
+// Health returns the health status of the consumer
+func (c *Consumer) Health(ctx context.Context) mq.HealthStatus {
+	return c.conn.health(ctx, "consumer", map[string]interface{}{
+		"subscriptions": len(c.topics()),
+	})
+}
+
+// GetMetrics returns consumer metrics. The lag counts messages received
+// but not handled yet, and for JetStream the messages left in the stream.
+func (c *Consumer) GetMetrics() mq.ConsumerMetrics {
+	consumed, bytes, errors, latency, lastErr := c.stats.snapshot()
+
+	var lag int64
+	paused := make(map[string]bool)
+	c.mu.Lock()
+	for topic, s := range c.subs {
+		s.mu.Lock()
+		lag += int64(len(s.queue))
+		paused[topic] = s.paused
+		s.mu.Unlock()
+		lag += atomic.LoadInt64(&s.pending)
+	}
+	c.mu.Unlock()
+
+	return mq.ConsumerMetrics{
+		MessagesConsumed:     consumed,
+		BytesConsumed:        bytes,
+		ProcessingErrors:     errors,
+		DuplicatesSkipped:    atomic.LoadInt64(&c.duplicates),
+		AvgProcessingLatency: latency,
+		Lag:                  lag,
+		Connected:            c.conn.connected(),
+		SubscribedTopics:     c.topics(),
+		Paused:               paused,
+		LastError:            lastErr,
+		LastUpdated:          time.Now(),
+	}
+}
+
+// topics returns the subscribed topics in order
+func (c *Consumer) topics() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	topics := make([]string, 0, len(c.subs))
+	for topic := range c.subs {
+		topics = append(topics, topic)
+	}
+	sort.Strings(topics)
+	return topics
+}
+
+// consumerClosedError returns the error of subscribing on a closed consumer
+func consumerClosedError() error {
+	err := mq.NewConsumerError("CONSUMER_CLOSED", "consumer is closed", false)
+	err.Cause = mq.ErrConsumerClosed
+	return err
+}
@@ -0,0 +1,73 @@
+// Package nats implements mq.Producer and mq.Consumer on NATS, with the
+// nats.go client and its jetstream package.
+//
+// # Configuration
+//
+// Brokers are server addresses such as "nats://host:4222", "tls://host:4222"
+// or "host:4222", by default nats://127.0.0.1:4222. The connection is to the
+// first reachable one, and when it is lost the client reconnects to them in
+// turn and subscribes again. Messages published while disconnected fail
+// with a connection error rather than being buffered. User and password or
+// a token are taken from the broker URL, from Security.SASL, or from the
+// "token" option. Security.TLS configures TLS, which is also used when the
+// server requires it. ClientID names the connection, ProducerConfig.Timeout bounds
+// connecting and requests, 5 seconds by default, and ConsumerConfig.
+// SessionTimeout is the acknowledgement wait of JetStream consumers.
+//
+// # Core NATS and JetStream
+//
+// By default messages are published and received with core NATS, which
+// delivers at most once to the subscribers present at the time. Setting the
+// "jetstream" option to true publishes to JetStream streams instead,
+// waiting for the server to store each message, and subscribes through
+// JetStream consumers. Streams are managed outside the driver: a topic must
+// be captured by a stream, or publishing fails with mq.ErrTopicNotFound.
+//
+// Subscriptions with StartFromBeginning, StartFromTimestamp,
+// StartFromOffset, MaxRetries or DeadLetterTopic always use a JetStream
+// pull consumer filtered on the topic, StartFromOffset being a stream
+// sequence. A message is acknowledged when the handler succeeds. When it
+// fails the message is redelivered after RetryDelay, up to MaxRetries
+// times, and then published to DeadLetterTopic, which a stream must also
+// capture, with the Stargate-Original-Topic and Stargate-Error headers.
+//
+// # Consumer Groups
+//
+// GroupID is a queue group, delivering each message to one consumer of the
+// group. With JetStream the consumers of a group share a durable consumer
+// per topic, which resumes where the group left off: start options only
+// apply when it is first created. Consumers without a group get an
+// ephemeral consumer, deleted when they unsubscribe, which the server
+// also removes after it isn't pulled from for a few seconds.
+//
+// Pause keeps the subscriptions, and with them the place of the consumer
+// in its queue group. Core NATS messages received meanwhile wait in
+// memory, up to 1024 per subscription and then up to its pending limits of
+// 65536 messages or 64 MiB, beyond which the client drops them as a slow
+// consumer. JetStream subscriptions stop pulling, their consumer keeping
+// the messages left for Resume, and the messages already pulled are
+// reported in progress every half SessionTimeout so the server doesn't
+// redeliver them. An ephemeral consumer removed by the server while paused
+// is created again on Resume, after the last message received.
+//
+// # Messages
+//
+// Message ID, Key and Timestamp travel in the Nats-Msg-Id, Stargate-Key
+// and Stargate-Timestamp headers, next to the message headers. JetStream
+// uses Nats-Msg-Id to discard duplicates, so PublishOptions.DeduplicationID
+// replaces it. Payloads are compressed as ProducerConfig.Compression selects,
+// at the level of the "compression_level" option, and the Stargate-Compression
+// header tells consumers to decompress them, up to
+// ConsumerConfig.MaxDecompressedSize. DelaySeconds isn't supported, and Seek
+// returns mq.ErrOperationNotSupported.
+//
+// NATS has no delayed delivery, so PublishWithDelay keeps messages in the
+// producer until they are due, up to ProducerConfig.MaxDelay. They are
+// lost when the producer is closed before, and can be overtaken by the
+// messages published directly.
+//
+// With SubscribeOptions.DeduplicationWindow, a subscription remembers the
+// keys of the messages its handler processed, and acknowledges the
+// messages with a key seen within the window without handling them.
+// Failed messages aren't remembered, so their redeliveries are handled.
+package nats
@@ -0,0 +1,82 @@
+package nats
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/songzhibin97/stargate/pkg/mq"
+)
+
+// defaultAckWait is the acknowledgement wait of JetStream consumers when
+// ConsumerConfig.SessionTimeout isn't set, the server default
+const defaultAckWait = 30 * time.Second
+
+// streamForSubject returns the name of the stream capturing a subject
+func (c *conn) streamForSubject(ctx context.Context, subject string) (string, error) {
+	stream, err := c.js.StreamNameBySubject(ctx, subject)
+	if errors.Is(err, jetstream.ErrStreamNotFound) {
+		err := mq.NewConsumerError("TOPIC_NOT_FOUND", fmt.Sprintf("no JetStream stream captures topic %s", subject), false)
+		err.Cause = mq.ErrTopicNotFound
+		return "", err
+	}
+	if err != nil {
+		return "", natsError(err)
+	}
+	return stream, nil
+}
+
+// jsPublish publishes messages to streams, waiting for the server to store
+// them. The acknowledgements are awaited together.
+func (c *conn) jsPublish(ctx context.Context, msgs ...*nats.Msg) error {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	acks := make([]jetstream.PubAckFuture, len(msgs))
+	for i, m := range msgs {
+		ack, err := c.js.PublishMsgAsync(m)
+		if err != nil {
+			return jsPublishError(m.Subject, err)
+		}
+		acks[i] = ack
+	}
+
+	for i, ack := range acks {
+		select {
+		case <-ack.Ok():
+		case err := <-ack.Err():
+			return jsPublishError(msgs[i].Subject, err)
+		case <-ctx.Done():
+			return timeoutError("PUBLISH_TIMEOUT", "timed out waiting for the JetStream publish acknowledgement", ctx.Err())
+		}
+	}
+	return nil
+}
+
+// jsPublishError returns the mq error of a message failing to be stored
+func jsPublishError(subject string, err error) error {
+	if errors.Is(err, jetstream.ErrNoStreamResponse) {
+		err := mq.NewProducerError("TOPIC_NOT_FOUND", fmt.Sprintf("no JetStream stream captures topic %s", subject), false)
+		err.Cause = mq.ErrTopicNotFound
+		return err
+	}
+	var apiErr *jetstream.APIError
+	if errors.As(err, &apiErr) {
+		e := mq.NewProducerError("PUBLISH_FAILED", apiErr.Description, true)
+		e.Cause = err
+		return e
+	}
+	return natsError(err)
+}
+
+// nak negatively acknowledges a message, redelivering it after a delay or
+// as soon as possible for no delay
+func nak(m jetstream.Msg, delay time.Duration) error {
+	if delay <= 0 {
+		return m.Nak()
+	}
+	return m.NakWithDelay(delay)
+}
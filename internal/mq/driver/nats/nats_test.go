@@ -0,0 +1,797 @@
+package nats
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/songzhibin97/stargate/pkg/mq"
+)
+
+// newTestServer starts an embedded NATS server, with JetStream if enabled
+func newTestServer(t *testing.T, jetStream bool) *server.Server {
+	return startTestServer(t, server.RANDOM_PORT, jetStream)
+}
+
+// startTestServer starts an embedded NATS server listening on a port,
+// accepting payloads up to 1 KiB
+func startTestServer(t *testing.T, port int, jetStream bool) *server.Server {
+	t.Helper()
+	s, err := server.NewServer(&server.Options{
+		Host:       "127.0.0.1",
+		Port:       port,
+		JetStream:  jetStream,
+		StoreDir:   t.TempDir(),
+		MaxPayload: 1024,
+		NoLog:      true,
+		NoSigs:     true,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	go s.Start()
+	if !s.ReadyForConnections(5 * time.Second) {
+		t.Fatal("Server not ready")
+	}
+	t.Cleanup(s.Shutdown)
+	return s
+}
+
+// newTestJetStream returns a JetStream context on a server, creating
+// streams capturing subjects by name
+func newTestJetStream(t *testing.T, s *server.Server, streams map[string]string) jetstream.JetStream {
+	t.Helper()
+	nc, err := nats.Connect(s.ClientURL())
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	t.Cleanup(nc.Close)
+	js, err := jetstream.New(nc)
+	if err != nil {
+		t.Fatalf("Failed to create JetStream context: %v", err)
+	}
+	for name, subjects := range streams {
+		if _, err := js.CreateStream(context.Background(), jetstream.StreamConfig{Name: name, Subjects: []string{subjects}}); err != nil {
+			t.Fatalf("Failed to create stream %s: %v", name, err)
+		}
+	}
+	return js
+}
+
+// consumerInfo returns the info of a JetStream consumer
+func consumerInfo(t *testing.T, js jetstream.JetStream, stream, name string) *jetstream.ConsumerInfo {
+	t.Helper()
+	consumer, err := js.Consumer(context.Background(), stream, name)
+	if err != nil {
+		t.Fatalf("Failed to get consumer %s: %v", name, err)
+	}
+	info, err := consumer.Info(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to get consumer %s: %v", name, err)
+	}
+	return info
+}
+
+// streamConsumers returns the number of consumers of a stream
+func streamConsumers(t *testing.T, js jetstream.JetStream, name string) int {
+	t.Helper()
+	stream, err := js.Stream(context.Background(), name)
+	if err != nil {
+		t.Fatalf("Failed to get stream %s: %v", name, err)
+	}
+	info, err := stream.Info(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to get stream %s: %v", name, err)
+	}
+	return info.State.Consumers
+}
+
+// newTestProducer creates a producer connected to a server
+func newTestProducer(t *testing.T, s *server.Server, options map[string]interface{}) *Producer {
+	t.Helper()
+	p, err := NewProducer(&mq.ProducerConfig{Brokers: []string{s.ClientURL()}, ClientID: "test-producer", Timeout: time.Second, Options: options})
+	if err != nil {
+		t.Fatalf("Failed to create producer: %v", err)
+	}
+	t.Cleanup(func() { p.Close() })
+	return p.(*Producer)
+}
+
+// newTestConsumer creates a consumer of a group connected to a server
+func newTestConsumer(t *testing.T, s *server.Server, groupID string) *Consumer {
+	t.Helper()
+	c, err := NewConsumer(&mq.ConsumerConfig{Brokers: []string{s.ClientURL()}, ClientID: "test-consumer", GroupID: groupID})
+	if err != nil {
+		t.Fatalf("Failed to create consumer: %v", err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c.(*Consumer)
+}
+
+// collector collects the messages received by a handler
+type collector struct {
+	mu       sync.Mutex
+	messages []*mq.Message
+}
+
+func (c *collector) handle(ctx context.Context, message *mq.Message) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.messages = append(c.messages, message)
+	return nil
+}
+
+func (c *collector) received() []*mq.Message {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]*mq.Message(nil), c.messages...)
+}
+
+// waitFor waits until a condition holds
+func waitFor(t *testing.T, what string, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestProducerConsumer(t *testing.T) {
+	s := newTestServer(t, false)
+	p := newTestProducer(t, s, nil)
+	c := newTestConsumer(t, s, "")
+	ctx := context.Background()
+
+	var received collector
+	if err := c.Subscribe(ctx, "orders.created", received.handle); err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	if err := c.Subscribe(ctx, "orders.created", received.handle); !mq.IsConsumerError(err) {
+		t.Errorf("Expected consumer error subscribing twice, got %v", err)
+	}
+
+	timestamp := time.Date(2024, 1, 2, 3, 4, 5, 6, time.UTC)
+	message := &mq.Message{
+		ID:        "msg-1",
+		Key:       "customer-1",
+		Payload:   []byte(`{"order":1}`),
+		Headers:   map[string]string{"Trace-Id": "trace-1"},
+		Timestamp: timestamp,
+	}
+	if err := p.PublishWithOptions(ctx, "orders.created", message, &mq.PublishOptions{Sync: true}); err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+	batch := []*mq.Message{{Payload: []byte("2")}, {Payload: []byte("3")}}
+	if err := p.PublishBatch(ctx, "orders.created", batch); err != nil {
+		t.Fatalf("PublishBatch failed: %v", err)
+	}
+	published := make(chan error, 1)
+	err := p.PublishAsync(ctx, "orders.created", &mq.Message{Payload: []byte("4")}, func(message *mq.Message, err error) {
+		published <- err
+	})
+	if err != nil {
+		t.Fatalf("PublishAsync failed: %v", err)
+	}
+	if err := <-published; err != nil {
+		t.Errorf("Asynchronous publish failed: %v", err)
+	}
+	if err := p.Flush(ctx); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+
+	waitFor(t, "messages", func() bool { return len(received.received()) == 4 })
+	got := received.received()[0]
+	if got.ID != "msg-1" || got.Key != "customer-1" || got.Topic != "orders.created" || string(got.Payload) != `{"order":1}` {
+		t.Errorf("Unexpected message: %+v", got)
+	}
+	if !got.Timestamp.Equal(timestamp) {
+		t.Errorf("Expected timestamp %v, got %v", timestamp, got.Timestamp)
+	}
+	if len(got.Headers) != 1 || got.Headers["Trace-Id"] != "trace-1" {
+		t.Errorf("Unexpected headers: %v", got.Headers)
+	}
+
+	producerMetrics := p.GetMetrics()
+	if producerMetrics.MessagesPublished != 4 || producerMetrics.PublishErrors != 0 || !producerMetrics.Connected {
+		t.Errorf("Unexpected producer metrics: %+v", producerMetrics)
+	}
+	waitFor(t, "consumer metrics", func() bool { return c.GetMetrics().MessagesConsumed == 4 })
+	consumerMetrics := c.GetMetrics()
+	if consumerMetrics.BytesConsumed != int64(len(`{"order":1}`)+3) || !consumerMetrics.Connected {
+		t.Errorf("Unexpected consumer metrics: %+v", consumerMetrics)
+	}
+	if len(consumerMetrics.SubscribedTopics) != 1 || consumerMetrics.SubscribedTopics[0] != "orders.created" {
+		t.Errorf("Unexpected subscribed topics: %v", consumerMetrics.SubscribedTopics)
+	}
+
+	health := p.Health(ctx)
+	if health.Status != "healthy" || health.Details["server_id"] != s.ID() {
+		t.Errorf("Unexpected producer health: %+v", health)
+	}
+	if health := c.Health(ctx); health.Status != "healthy" || health.Details["subscriptions"] != 1 {
+		t.Errorf("Unexpected consumer health: %+v", health)
+	}
+
+	if err := c.Unsubscribe("orders.created"); err != nil {
+		t.Fatalf("Unsubscribe failed: %v", err)
+	}
+	if err := c.Unsubscribe("orders.created"); !mq.IsConsumerError(err) {
+		t.Errorf("Expected consumer error unsubscribing twice, got %v", err)
+	}
+}
+
+func TestProducer_PublishWithDelay(t *testing.T) {
+	s := newTestServer(t, false)
+	p, err := NewProducer(&mq.ProducerConfig{Brokers: []string{s.ClientURL()}, MaxDelay: time.Second})
+	if err != nil {
+		t.Fatalf("Failed to create producer: %v", err)
+	}
+	defer p.Close()
+	c := newTestConsumer(t, s, "")
+	ctx := context.Background()
+
+	var received collector
+	if err := c.Subscribe(ctx, "retries", received.handle); err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+
+	start := time.Now()
+	if err := p.PublishWithDelay(ctx, "retries", &mq.Message{ID: "delayed"}, 100*time.Millisecond); err != nil {
+		t.Fatalf("PublishWithDelay failed: %v", err)
+	}
+	if err := p.Publish(ctx, "retries", &mq.Message{ID: "direct"}); err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+	if metrics := p.GetMetrics(); metrics.PendingMessages != 1 {
+		t.Errorf("Expected the delayed message pending, got %d", metrics.PendingMessages)
+	}
+
+	waitFor(t, "messages", func() bool { return len(received.received()) == 2 })
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("Delayed message received after %s", elapsed)
+	}
+	if messages := received.received(); messages[0].ID != "direct" || messages[1].ID != "delayed" {
+		t.Errorf("Expected the direct message first, got %s and %s", messages[0].ID, messages[1].ID)
+	}
+
+	if err := p.PublishWithDelay(ctx, "retries", &mq.Message{}, 2*time.Second); !mq.IsConfigurationError(err) {
+		t.Errorf("Expected configuration error beyond the maximum delay, got %v", err)
+	}
+}
+
+func TestProducer_Errors(t *testing.T) {
+	s := newTestServer(t, false)
+	p := newTestProducer(t, s, nil)
+	ctx := context.Background()
+
+	err := p.Publish(ctx, "orders.created", &mq.Message{Payload: make([]byte, 2048)})
+	if !errors.Is(err, mq.ErrMessageTooLarge) {
+		t.Errorf("Expected ErrMessageTooLarge, got %v", err)
+	}
+	if err := p.Publish(ctx, "", &mq.Message{}); !errors.Is(err, mq.ErrInvalidTopic) {
+		t.Errorf("Expected ErrInvalidTopic, got %v", err)
+	}
+	err = p.PublishWithOptions(ctx, "orders.created", &mq.Message{}, &mq.PublishOptions{DelaySeconds: 10})
+	if !errors.Is(err, mq.ErrOperationNotSupported) {
+		t.Errorf("Expected ErrOperationNotSupported, got %v", err)
+	}
+	if metrics := p.GetMetrics(); metrics.PublishErrors != 1 || metrics.LastError == "" {
+		t.Errorf("Unexpected metrics: %+v", metrics)
+	}
+
+	p.Close()
+	if err := p.Publish(ctx, "orders.created", &mq.Message{}); !errors.Is(err, mq.ErrProducerClosed) {
+		t.Errorf("Expected ErrProducerClosed, got %v", err)
+	}
+
+	_, err = NewProducer(&mq.ProducerConfig{Brokers: []string{"127.0.0.1:1"}, Timeout: time.Second})
+	if !mq.IsConnectionError(err) {
+		t.Errorf("Expected connection error for an unreachable server, got %v", err)
+	}
+	_, err = NewProducer(&mq.ProducerConfig{Brokers: []string{"http://127.0.0.1:4222"}})
+	if !mq.IsConfigurationError(err) {
+		t.Errorf("Expected configuration error for an invalid broker, got %v", err)
+	}
+}
+
+func TestProducerConsumer_Compression(t *testing.T) {
+	s := newTestServer(t, false)
+	p, err := NewProducer(&mq.ProducerConfig{
+		Brokers:     []string{s.ClientURL()},
+		Compression: mq.CompressionZstd,
+		Options:     map[string]interface{}{"compression_level": 19},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create producer: %v", err)
+	}
+	defer p.Close()
+	c := newTestConsumer(t, s, "")
+	ctx := context.Background()
+
+	var received collector
+	if err := c.Subscribe(ctx, "events", received.handle); err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+
+	// Beyond the maximum payload of the server unless compressed
+	payload := []byte(strings.Repeat(`{"path":"/api/v1/orders","status":200}`, 100))
+	if err := p.Publish(ctx, "events", &mq.Message{Payload: payload}); err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+	opts := &mq.PublishOptions{Compression: mq.CompressionGzip}
+	if err := p.PublishWithOptions(ctx, "events", &mq.Message{Payload: payload}, opts); err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+
+	waitFor(t, "messages", func() bool { return len(received.received()) == 2 })
+	for _, message := range received.received() {
+		if string(message.Payload) != string(payload) || len(message.Headers) != 0 {
+			t.Errorf("Unexpected message: %+v", message)
+		}
+	}
+	if metrics := p.GetMetrics(); metrics.BytesPublished >= int64(len(payload)) {
+		t.Errorf("Expected compressed bytes published, got %d", metrics.BytesPublished)
+	}
+}
+
+func TestConsumer_QueueGroup(t *testing.T) {
+	s := newTestServer(t, false)
+	p := newTestProducer(t, s, nil)
+	ctx := context.Background()
+
+	var first, second collector
+	if err := newTestConsumer(t, s, "workers").Subscribe(ctx, "jobs", first.handle); err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	if err := newTestConsumer(t, s, "workers").Subscribe(ctx, "jobs", second.handle); err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+
+	for i := 0; i < 10; i++ {
+		if err := p.Publish(ctx, "jobs", &mq.Message{Payload: []byte("job")}); err != nil {
+			t.Fatalf("Publish failed: %v", err)
+		}
+	}
+	waitFor(t, "jobs", func() bool { return len(first.received())+len(second.received()) == 10 })
+	if len(first.received()) == 0 || len(second.received()) == 0 {
+		t.Errorf("Expected jobs spread over the group, got %d and %d", len(first.received()), len(second.received()))
+	}
+}
+
+func TestConsumer_Pause(t *testing.T) {
+	s := newTestServer(t, false)
+	p := newTestProducer(t, s, nil)
+	c := newTestConsumer(t, s, "workers")
+	ctx := context.Background()
+
+	var received, other collector
+	if err := c.Subscribe(ctx, "events", received.handle); err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	if err := c.Subscribe(ctx, "audit", other.handle); err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	if err := c.Pause("events", "unknown"); !mq.IsConsumerError(err) {
+		t.Errorf("Expected consumer error pausing an unknown topic, got %v", err)
+	}
+	if metrics := c.GetMetrics(); metrics.Paused["events"] {
+		t.Errorf("Expected no topic paused after a failed pause, got %v", metrics.Paused)
+	}
+	if err := c.Pause("events"); err != nil {
+		t.Fatalf("Pause failed: %v", err)
+	}
+	if metrics := c.GetMetrics(); !metrics.Paused["events"] || metrics.Paused["audit"] {
+		t.Errorf("Expected only events paused, got %v", metrics.Paused)
+	}
+
+	for i := 0; i < 5; i++ {
+		if err := p.PublishWithOptions(ctx, "events", &mq.Message{Payload: []byte("1")}, &mq.PublishOptions{Sync: true}); err != nil {
+			t.Fatalf("Publish failed: %v", err)
+		}
+	}
+	if err := p.Publish(ctx, "audit", &mq.Message{Payload: []byte("1")}); err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+	waitFor(t, "lag", func() bool { return c.GetMetrics().Lag == 5 })
+	waitFor(t, "other topic", func() bool { return len(other.received()) == 1 })
+	time.Sleep(50 * time.Millisecond)
+	if n := len(received.received()); n != 0 {
+		t.Errorf("Expected no message handled while paused, got %d", n)
+	}
+
+	if err := c.Resume(); err != nil {
+		t.Fatalf("Resume failed: %v", err)
+	}
+	waitFor(t, "messages", func() bool { return len(received.received()) == 5 })
+	if metrics := c.GetMetrics(); metrics.Paused["events"] || metrics.Lag != 0 {
+		t.Errorf("Expected events resumed with no lag, got %+v", metrics)
+	}
+
+	// Pausing all topics, twice, and resuming them
+	if err := c.Pause(); err != nil {
+		t.Fatalf("Pause failed: %v", err)
+	}
+	if err := c.Pause(); err != nil {
+		t.Fatalf("Pause failed: %v", err)
+	}
+	if metrics := c.GetMetrics(); !metrics.Paused["events"] || !metrics.Paused["audit"] {
+		t.Errorf("Expected all topics paused, got %v", metrics.Paused)
+	}
+	if err := c.Resume("events", "audit"); err != nil {
+		t.Fatalf("Resume failed: %v", err)
+	}
+
+	if err := c.Seek(ctx, "events", 0, 1); !errors.Is(err, mq.ErrOperationNotSupported) {
+		t.Errorf("Expected ErrOperationNotSupported, got %v", err)
+	}
+}
+
+func TestConsumer_PauseBoundsQueue(t *testing.T) {
+	s := newTestServer(t, false)
+	p := newTestProducer(t, s, nil)
+	c := newTestConsumer(t, s, "")
+	ctx := context.Background()
+
+	var received collector
+	if err := c.Subscribe(ctx, "events", received.handle); err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	if err := c.Pause(); err != nil {
+		t.Fatalf("Pause failed: %v", err)
+	}
+
+	// Messages beyond the queue wait in the pending buffer of the client
+	total := maxQueued + 100
+	for i := 0; i < total; i++ {
+		if err := p.Publish(ctx, "events", &mq.Message{Payload: []byte("1")}); err != nil {
+			t.Fatalf("Publish failed: %v", err)
+		}
+	}
+	waitFor(t, "full queue", func() bool { return c.GetMetrics().Lag == maxQueued })
+	time.Sleep(50 * time.Millisecond)
+	if lag := c.GetMetrics().Lag; lag != maxQueued {
+		t.Errorf("Expected the queue bounded to %d messages, got %d", maxQueued, lag)
+	}
+	if msgs, _, err := c.subs["events"].sub.PendingLimits(); err != nil || msgs != pendingMsgsLimit {
+		t.Errorf("Expected a pending limit of %d messages, got %d (%v)", pendingMsgsLimit, msgs, err)
+	}
+
+	if err := c.Resume(); err != nil {
+		t.Fatalf("Resume failed: %v", err)
+	}
+	waitFor(t, "messages", func() bool { return len(received.received()) == total })
+}
+
+func TestJetStream_Pause(t *testing.T) {
+	s := newTestServer(t, true)
+	js := newTestJetStream(t, s, map[string]string{"ORDERS": "orders.>"})
+	p := newTestProducer(t, s, map[string]interface{}{"jetstream": true})
+	consumer, err := NewConsumer(&mq.ConsumerConfig{
+		Brokers:        []string{s.ClientURL()},
+		Options:        map[string]interface{}{"jetstream": true},
+		SessionTimeout: 200 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create consumer: %v", err)
+	}
+	defer consumer.Close()
+	c := consumer.(*Consumer)
+	ctx := context.Background()
+
+	// The handler holds the first message until the subscription is paused
+	var received collector
+	release := make(chan struct{})
+	handler := func(ctx context.Context, message *mq.Message) error {
+		if len(received.received()) == 0 {
+			<-release
+		}
+		return received.handle(ctx, message)
+	}
+	if err := c.Subscribe(ctx, "orders.created", handler); err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := p.Publish(ctx, "orders.created", &mq.Message{Payload: []byte("order")}); err != nil {
+			t.Fatalf("Publish failed: %v", err)
+		}
+	}
+	waitFor(t, "pulled messages", func() bool { return c.GetMetrics().Lag == 2 })
+	if err := c.Pause(); err != nil {
+		t.Fatalf("Pause failed: %v", err)
+	}
+	close(release)
+
+	// Messages published while paused stay with the consumer, and the
+	// pulled ones are kept from redelivery, for several acknowledgement waits
+	for i := 0; i < 2; i++ {
+		if err := p.Publish(ctx, "orders.created", &mq.Message{Payload: []byte("order")}); err != nil {
+			t.Fatalf("Publish failed: %v", err)
+		}
+	}
+	time.Sleep(time.Second)
+	if n := len(received.received()); n != 1 {
+		t.Errorf("Expected only the message being handled when paused, got %d", n)
+	}
+	name := c.subs["orders.created"].consumer
+	info := consumerInfo(t, js, "ORDERS", name)
+	if info.NumRedelivered != 0 || info.NumPending != 2 || info.NumAckPending != 2 {
+		t.Errorf("Expected 2 messages pending, 2 pulled and no redelivery while paused, got %+v", info)
+	}
+
+	if err := c.Resume(); err != nil {
+		t.Fatalf("Resume failed: %v", err)
+	}
+	waitFor(t, "messages", func() bool { return len(received.received()) == 5 })
+	for _, message := range received.received() {
+		if message.RetryCount != 0 {
+			t.Errorf("Expected messages delivered once, got %+v", message)
+		}
+	}
+	waitFor(t, "acknowledgements", func() bool { return consumerInfo(t, js, "ORDERS", name).NumAckPending == 0 })
+}
+
+func TestJetStream_PauseRemovedEphemeral(t *testing.T) {
+	s := newTestServer(t, true)
+	js := newTestJetStream(t, s, map[string]string{"ORDERS": "orders.>"})
+	p := newTestProducer(t, s, map[string]interface{}{"jetstream": true})
+	c := newTestConsumer(t, s, "")
+	ctx := context.Background()
+
+	var received collector
+	if err := c.SubscribeWithOptions(ctx, "orders.created", received.handle, &mq.SubscribeOptions{StartFromBeginning: true}); err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	if err := p.Publish(ctx, "orders.created", &mq.Message{Payload: []byte("before")}); err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+	waitFor(t, "first message", func() bool { return len(received.received()) == 1 })
+	if err := c.Pause(); err != nil {
+		t.Fatalf("Pause failed: %v", err)
+	}
+
+	// The server removes the ephemeral consumer while it isn't pulled from
+	name := c.subs["orders.created"].consumer
+	if err := js.DeleteConsumer(ctx, "ORDERS", name); err != nil {
+		t.Fatalf("Failed to delete consumer: %v", err)
+	}
+	if err := p.Publish(ctx, "orders.created", &mq.Message{Payload: []byte("paused")}); err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+
+	if err := c.Resume(); err != nil {
+		t.Fatalf("Resume failed: %v", err)
+	}
+	waitFor(t, "messages", func() bool { return len(received.received()) == 2 })
+	time.Sleep(50 * time.Millisecond)
+	messages := received.received()
+	if len(messages) != 2 || string(messages[1].Payload) != "paused" {
+		t.Errorf("Expected the message published while paused once, got %d messages", len(messages))
+	}
+	if c.subs["orders.created"].consumer == name {
+		t.Errorf("Expected a new ephemeral consumer, got %s", name)
+	}
+}
+
+func TestReconnect(t *testing.T) {
+	defer func(wait time.Duration) { reconnectWait = wait }(reconnectWait)
+	reconnectWait = 20 * time.Millisecond
+
+	s := newTestServer(t, false)
+	port := s.Addr().(*net.TCPAddr).Port
+	p := newTestProducer(t, s, nil)
+	c := newTestConsumer(t, s, "")
+	ctx := context.Background()
+
+	var received collector
+	if err := c.Subscribe(ctx, "events", received.handle); err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+
+	s.Shutdown()
+	waitFor(t, "disconnect", func() bool { return !p.GetMetrics().Connected })
+	if health := p.Health(ctx); health.Status != "unhealthy" {
+		t.Errorf("Expected unhealthy producer, got %+v", health)
+	}
+	if err := p.Publish(ctx, "events", &mq.Message{}); !mq.IsConnectionError(err) {
+		t.Errorf("Expected connection error while disconnected, got %v", err)
+	}
+
+	startTestServer(t, port, false)
+	waitFor(t, "reconnect", func() bool { return p.GetMetrics().Connected && c.GetMetrics().Connected })
+	if err := p.PublishWithOptions(ctx, "events", &mq.Message{Payload: []byte("1")}, &mq.PublishOptions{Sync: true}); err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+	waitFor(t, "message after reconnecting", func() bool { return len(received.received()) == 1 })
+}
+
+func TestJetStream_StartFromBeginning(t *testing.T) {
+	s := newTestServer(t, true)
+	js := newTestJetStream(t, s, map[string]string{"ORDERS": "orders.>"})
+	p := newTestProducer(t, s, map[string]interface{}{"jetstream": true})
+	c := newTestConsumer(t, s, "")
+	ctx := context.Background()
+
+	for _, payload := range []string{"1", "2"} {
+		if err := p.Publish(ctx, "orders.created", &mq.Message{ID: "msg-" + payload, Payload: []byte(payload)}); err != nil {
+			t.Fatalf("Publish failed: %v", err)
+		}
+	}
+	err := p.Publish(ctx, "payments.created", &mq.Message{Payload: []byte("1")})
+	if !errors.Is(err, mq.ErrTopicNotFound) {
+		t.Errorf("Expected ErrTopicNotFound publishing outside streams, got %v", err)
+	}
+
+	var received collector
+	err = c.SubscribeWithOptions(ctx, "orders.created", received.handle, &mq.SubscribeOptions{StartFromBeginning: true})
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	if err := p.Publish(ctx, "orders.created", &mq.Message{ID: "msg-3", Payload: []byte("3")}); err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+
+	waitFor(t, "messages", func() bool { return len(received.received()) == 3 })
+	for i, message := range received.received() {
+		if message.ID != "msg-"+string(message.Payload) || message.RetryCount != 0 || message.Timestamp.IsZero() {
+			t.Errorf("Unexpected message %d: %+v", i, message)
+		}
+	}
+	name := c.subs["orders.created"].consumer
+	waitFor(t, "acknowledgements", func() bool {
+		info := consumerInfo(t, js, "ORDERS", name)
+		return info.NumAckPending == 0 && info.AckFloor.Stream == 3
+	})
+
+	var late collector
+	if err := newTestConsumer(t, s, "").SubscribeWithOptions(ctx, "orders.created", late.handle, &mq.SubscribeOptions{StartFromOffset: new(int64)}); err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	waitFor(t, "replayed messages", func() bool { return len(late.received()) == 3 })
+
+	if err := c.Unsubscribe("orders.created"); err != nil {
+		t.Fatalf("Unsubscribe failed: %v", err)
+	}
+	if consumers := streamConsumers(t, js, "ORDERS"); consumers != 1 {
+		t.Errorf("Expected the ephemeral consumer deleted, got %d consumers", consumers)
+	}
+}
+
+func TestJetStream_DurableGroup(t *testing.T) {
+	s := newTestServer(t, true)
+	js := newTestJetStream(t, s, map[string]string{"ORDERS": "orders.>"})
+	p := newTestProducer(t, s, map[string]interface{}{"jetstream": "true"})
+	ctx := context.Background()
+
+	var first, second collector
+	if err := newTestConsumer(t, s, "billing").SubscribeWithOptions(ctx, "orders.created", first.handle, &mq.SubscribeOptions{StartFromBeginning: true}); err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	if err := newTestConsumer(t, s, "billing").SubscribeWithOptions(ctx, "orders.created", second.handle, &mq.SubscribeOptions{StartFromBeginning: true}); err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	if _, err := js.Consumer(ctx, "ORDERS", "billing-orders_created"); err != nil {
+		t.Fatalf("Expected the durable consumer of the group: %v", err)
+	}
+	if consumers := streamConsumers(t, js, "ORDERS"); consumers != 1 {
+		t.Fatalf("Expected one shared durable consumer, got %d", consumers)
+	}
+
+	for i := 0; i < 10; i++ {
+		if err := p.Publish(ctx, "orders.created", &mq.Message{Payload: []byte("order")}); err != nil {
+			t.Fatalf("Publish failed: %v", err)
+		}
+	}
+	waitFor(t, "orders", func() bool { return len(first.received())+len(second.received()) == 10 })
+}
+
+func TestJetStream_DeadLetter(t *testing.T) {
+	s := newTestServer(t, true)
+	js := newTestJetStream(t, s, map[string]string{"ORDERS": "orders.>", "DEAD": "dead.>"})
+	p := newTestProducer(t, s, map[string]interface{}{"jetstream": true})
+	c := newTestConsumer(t, s, "")
+	ctx := context.Background()
+
+	var mu sync.Mutex
+	var retries []int
+	failing := func(ctx context.Context, message *mq.Message) error {
+		mu.Lock()
+		defer mu.Unlock()
+		retries = append(retries, message.RetryCount)
+		return errors.New("payment\r\ndeclined")
+	}
+	opts := &mq.SubscribeOptions{MaxRetries: 2, DeadLetterTopic: "dead.orders"}
+	if err := c.SubscribeWithOptions(ctx, "orders.created", failing, opts); err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	var dead collector
+	if err := c.Subscribe(ctx, "dead.orders", dead.handle); err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+
+	if err := p.Publish(ctx, "orders.created", &mq.Message{ID: "order-1", Payload: []byte("1")}); err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+
+	waitFor(t, "dead letter", func() bool { return len(dead.received()) == 1 })
+	letter := dead.received()[0]
+	if letter.ID != "order-1" || string(letter.Payload) != "1" {
+		t.Errorf("Unexpected dead letter: %+v", letter)
+	}
+	if letter.Headers[headerOriginalTopic] != "orders.created" || letter.Headers[headerError] != "payment  declined" {
+		t.Errorf("Unexpected dead letter headers: %v", letter.Headers)
+	}
+
+	mu.Lock()
+	if len(retries) != 3 || retries[0] != 0 || retries[2] != 2 {
+		t.Errorf("Expected 3 deliveries, got retry counts %v", retries)
+	}
+	mu.Unlock()
+	name := c.subs["orders.created"].consumer
+	waitFor(t, "termination", func() bool {
+		info := consumerInfo(t, js, "ORDERS", name)
+		return info.NumAckPending == 0 && info.AckFloor.Stream == 1
+	})
+	if metrics := c.GetMetrics(); metrics.ProcessingErrors != 3 {
+		t.Errorf("Expected 3 processing errors, got %+v", metrics)
+	}
+}
+
+func TestJetStream_Deduplication(t *testing.T) {
+	s := newTestServer(t, true)
+	js := newTestJetStream(t, s, map[string]string{"USAGE": "usage.>"})
+	p := newTestProducer(t, s, map[string]interface{}{"jetstream": true})
+	c := newTestConsumer(t, s, "")
+	ctx := context.Background()
+
+	// The first delivery fails, so its redelivery isn't a duplicate
+	var mu sync.Mutex
+	var handled []string
+	failed := false
+	handler := func(ctx context.Context, message *mq.Message) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if !failed {
+			failed = true
+			return errors.New("unavailable")
+		}
+		handled = append(handled, message.Key)
+		return nil
+	}
+	opts := &mq.SubscribeOptions{MaxRetries: 1, DeduplicationWindow: time.Minute}
+	if err := c.SubscribeWithOptions(ctx, "usage.api", handler, opts); err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+
+	// Distinct IDs, so JetStream stores them all
+	for i, key := range []string{"req-1", "req-1", "req-2", "req-1"} {
+		message := &mq.Message{ID: fmt.Sprintf("event-%d", i), Key: key, Payload: []byte(key)}
+		if err := p.Publish(ctx, "usage.api", message); err != nil {
+			t.Fatalf("Publish failed: %v", err)
+		}
+	}
+
+	name := c.subs["usage.api"].consumer
+	waitFor(t, "acknowledgements", func() bool {
+		info := consumerInfo(t, js, "USAGE", name)
+		return info.NumAckPending == 0 && info.AckFloor.Stream == 4
+	})
+	mu.Lock()
+	if len(handled) != 2 || handled[0] == handled[1] {
+		t.Errorf("Expected each key handled once, got %v", handled)
+	}
+	mu.Unlock()
+	if metrics := c.GetMetrics(); metrics.DuplicatesSkipped != 2 || metrics.MessagesConsumed != 2 {
+		t.Errorf("Expected 2 messages consumed and 2 duplicates skipped, got %+v", metrics)
+	}
+}
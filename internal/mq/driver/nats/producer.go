@@ -0,0 +1,385 @@
+package nats
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/songzhibin97/stargate/pkg/mq"
+)
+
+// Message headers carrying mq.Message fields
+const (
+	headerMsgID     = "Nats-Msg-Id"
+	headerKey       = "Stargate-Key"
+	headerTimestamp = "Stargate-Timestamp"
+
+	// headerCompression names the compression of compressed payloads
+	headerCompression = "Stargate-Compression"
+
+	// Headers added to dead letters
+	headerOriginalTopic = "Stargate-Original-Topic"
+	headerError         = "Stargate-Error"
+)
+
+// stats counts the messages of a producer or consumer
+type stats struct {
+	mu       sync.Mutex
+	messages int64
+	bytes    int64
+	errors   int64
+	ops      int64
+	latency  time.Duration // Total latency of successful operations
+	lastErr  string
+}
+
+// record records an operation on messages
+func (s *stats) record(messages, bytes int, latency time.Duration, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err != nil {
+		s.errors += int64(messages)
+		s.lastErr = err.Error()
+		return
+	}
+	s.messages += int64(messages)
+	s.bytes += int64(bytes)
+	s.ops++
+	s.latency += latency
+}
+
+// setError records an error that isn't counted against messages
+func (s *stats) setError(err error) {
+	s.mu.Lock()
+	s.lastErr = err.Error()
+	s.mu.Unlock()
+}
+
+// snapshot returns the counts and the average latency in milliseconds
+func (s *stats) snapshot() (messages, bytes, errors int64, avgLatency float64, lastErr string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ops > 0 {
+		avgLatency = float64(s.latency) / float64(s.ops) / float64(time.Millisecond)
+	}
+	return s.messages, s.bytes, s.errors, avgLatency, s.lastErr
+}
+
+// encodeMessage returns the NATS message of a message published to a
+// topic, compressing its payload
+func encodeMessage(topic string, message *mq.Message, opts *mq.PublishOptions, compressor mq.Compressor) (*nats.Msg, error) {
+	if message == nil {
+		err := mq.NewProducerError("INVALID_MESSAGE", "message cannot be nil", false)
+		err.Cause = mq.ErrInvalidMessage
+		return nil, err
+	}
+
+	header := make(nats.Header, len(message.Headers)+3)
+	for name, value := range message.Headers {
+		header.Set(name, value)
+	}
+	id := message.ID
+	if opts != nil {
+		for name, value := range opts.Headers {
+			header.Set(name, value)
+		}
+		if opts.DeduplicationID != "" {
+			id = opts.DeduplicationID
+		}
+	}
+	if id != "" {
+		header.Set(headerMsgID, id)
+	}
+	if message.Key != "" {
+		header.Set(headerKey, message.Key)
+	}
+	if !message.Timestamp.IsZero() {
+		header.Set(headerTimestamp, message.Timestamp.UTC().Format(time.RFC3339Nano))
+	}
+
+	data := message.Payload
+	if compressor.Type() != mq.CompressionNone {
+		var err error
+		if data, err = compressor.Compress(data); err != nil {
+			return nil, err
+		}
+		header.Set(headerCompression, string(compressor.Type()))
+	}
+	return &nats.Msg{Subject: topic, Header: header, Data: data}, nil
+}
+
+// decodeMessage returns the message of a received NATS message
+func decodeMessage(subject string, header nats.Header, data []byte) *mq.Message {
+	message := &mq.Message{
+		ID:      header.Get(headerMsgID),
+		Topic:   subject,
+		Payload: data,
+		Key:     header.Get(headerKey),
+	}
+	if t, err := time.Parse(time.RFC3339Nano, header.Get(headerTimestamp)); err == nil {
+		message.Timestamp = t
+	}
+	for name, values := range header {
+		switch name {
+		case headerMsgID, headerKey, headerTimestamp, headerCompression:
+			continue
+		}
+		if len(values) == 0 {
+			continue
+		}
+		if message.Headers == nil {
+			message.Headers = make(map[string]string, len(header))
+		}
+		message.Headers[name] = values[0]
+	}
+	return message
+}
+
+// Producer implements mq.Producer on NATS, publishing to JetStream streams
+// when the "jetstream" option is set
+type Producer struct {
+	conn       *conn
+	jetStream  bool
+	compressor mq.Compressor
+	scheduler  *mq.DelayScheduler // Of delayed messages
+	stats      stats
+
+	async   sync.WaitGroup
+	pending int64 // Asynchronous publishes in flight
+	closed  int32
+}
+
+// NewProducer creates a producer connected to the configured brokers
+func NewProducer(config *mq.ProducerConfig) (mq.Producer, error) {
+	if config == nil {
+		return nil, configurationError("producer config cannot be nil", mq.ErrMissingConfig)
+	}
+	compressor, err := mq.NewProducerCompressor(config)
+	if err != nil {
+		return nil, err
+	}
+
+	opts, err := newOptions(config.Brokers, config.ClientID, config.Security, config.Options, config.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	c, err := connect(opts)
+	if err != nil {
+		return nil, err
+	}
+	p := &Producer{conn: c, jetStream: opts.jetStream, compressor: compressor}
+	p.scheduler = mq.NewDelayScheduler(p.Publish, config.MaxDelay)
+	return p, nil
+}
+
+// Publish publishes a message to a topic
+func (p *Producer) Publish(ctx context.Context, topic string, message *mq.Message) error {
+	return p.PublishWithOptions(ctx, topic, message, nil)
+}
+
+// PublishWithOptions publishes a message to a topic with options
+func (p *Producer) PublishWithOptions(ctx context.Context, topic string, message *mq.Message, opts *mq.PublishOptions) error {
+	return p.publish(ctx, topic, []*mq.Message{message}, opts)
+}
+
+// PublishBatch publishes messages to a topic in a single write
+func (p *Producer) PublishBatch(ctx context.Context, topic string, messages []*mq.Message) error {
+	return p.PublishBatchWithOptions(ctx, topic, messages, nil)
+}
+
+// PublishBatchWithOptions publishes messages to a topic in a single write
+// with options. With JetStream, the acknowledgements are awaited together.
+func (p *Producer) PublishBatchWithOptions(ctx context.Context, topic string, messages []*mq.Message, opts *mq.PublishOptions) error {
+	if len(messages) == 0 {
+		return nil
+	}
+	return p.publish(ctx, topic, messages, opts)
+}
+
+// PublishAsync publishes a message in the background, calling the callback
+// with the result
+func (p *Producer) PublishAsync(ctx context.Context, topic string, message *mq.Message, callback mq.PublishCallback) error {
+	return p.PublishAsyncWithOptions(ctx, topic, message, nil, callback)
+}
+
+// PublishAsyncWithOptions publishes a message with options in the
+// background, calling the callback with the result
+func (p *Producer) PublishAsyncWithOptions(ctx context.Context, topic string, message *mq.Message, opts *mq.PublishOptions, callback mq.PublishCallback) error {
+	if atomic.LoadInt32(&p.closed) == 1 {
+		return producerClosedError()
+	}
+
+	atomic.AddInt64(&p.pending, 1)
+	p.async.Add(1)
+	go func() {
+		defer p.async.Done()
+		err := p.PublishWithOptions(ctx, topic, message, opts)
+		atomic.AddInt64(&p.pending, -1)
+		if callback != nil {
+			callback(message, err)
+		}
+	}()
+	return nil
+}
+
+// PublishWithDelay publishes a message to a topic after a delay. NATS has
+// no delayed delivery, so the message waits in the producer, see
+// mq.DelayScheduler for the ordering caveats.
+func (p *Producer) PublishWithDelay(ctx context.Context, topic string, message *mq.Message, delay time.Duration) error {
+	if atomic.LoadInt32(&p.closed) == 1 {
+		return producerClosedError()
+	}
+	return p.scheduler.Schedule(ctx, topic, message, delay)
+}
+
+// SetDelayErrorHandler sets the handler called with the delayed messages
+// that fail to publish
+func (p *Producer) SetDelayErrorHandler(handler mq.DelayErrorHandler) {
+	p.scheduler.SetErrorHandler(handler)
+}
+
+// publish publishes messages to a topic
+func (p *Producer) publish(ctx context.Context, topic string, messages []*mq.Message, opts *mq.PublishOptions) error {
+	if atomic.LoadInt32(&p.closed) == 1 {
+		return producerClosedError()
+	}
+	if topic == "" {
+		err := mq.NewProducerError("INVALID_TOPIC", "topic cannot be empty", false)
+		err.Cause = mq.ErrInvalidTopic
+		return err
+	}
+	if strings.ContainsAny(topic, " \t\r\n") {
+		err := mq.NewProducerError("INVALID_TOPIC", fmt.Sprintf("invalid NATS subject: %q", topic), false)
+		err.Cause = mq.ErrInvalidTopic
+		return err
+	}
+	compressor := p.compressor
+	if opts != nil {
+		if opts.Compression != "" && opts.Compression != compressor.Type() {
+			var err error
+			if compressor, err = mq.NewCompressor(opts.Compression, 0); err != nil {
+				return err
+			}
+		}
+		if opts.DelaySeconds > 0 {
+			err := mq.NewProducerError("UNSUPPORTED_OPTION", "NATS producer doesn't support DelaySeconds, use PublishWithDelay", false)
+			err.Cause = mq.ErrOperationNotSupported
+			return err
+		}
+		if opts.Timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+			defer cancel()
+		}
+	}
+
+	// All messages or none are sent when one is too large
+	maxPayload := p.conn.nc.MaxPayload()
+	msgs := make([]*nats.Msg, len(messages))
+	bytes := 0
+	for i, message := range messages {
+		m, err := encodeMessage(topic, message, opts, compressor)
+		if err != nil {
+			return err
+		}
+		if maxPayload > 0 && int64(len(m.Data)) > maxPayload {
+			err := mq.NewProducerError("MESSAGE_TOO_LARGE", fmt.Sprintf("message of %d bytes exceeds the maximum payload of %d bytes", len(m.Data), maxPayload), false)
+			err.Cause = mq.ErrMessageTooLarge
+			p.stats.record(len(messages), 0, 0, err)
+			return err
+		}
+		msgs[i] = m
+		bytes += len(m.Data)
+	}
+
+	start := time.Now()
+	var err error
+	if p.jetStream {
+		err = p.conn.jsPublish(ctx, msgs...)
+	} else {
+		for _, m := range msgs {
+			if err = p.conn.nc.PublishMsg(m); err != nil {
+				err = natsError(err)
+				break
+			}
+		}
+		if err == nil && opts != nil && opts.Sync {
+			err = p.conn.flush(ctx)
+		}
+	}
+	p.stats.record(len(msgs), bytes, time.Since(start), err)
+	return err
+}
+
+// Flush waits for asynchronous publishes to complete and for the server to
+// process everything published before
+func (p *Producer) Flush(ctx context.Context) error {
+	if atomic.LoadInt32(&p.closed) == 1 {
+		return producerClosedError()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		p.async.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return timeoutError("FLUSH_TIMEOUT", "timed out waiting for asynchronous publishes", ctx.Err())
+	}
+	return p.conn.flush(ctx)
+}
+
+// Close waits for asynchronous publishes to complete and closes the
+// connection, dropping the delayed messages that aren't due yet
+func (p *Producer) Close() error {
+	if !atomic.CompareAndSwapInt32(&p.closed, 0, 1) {
+		return nil
+	}
+	dropped := p.scheduler.Close()
+	p.async.Wait()
+	if err := p.conn.close(); err != nil {
+		return err
+	}
+	if dropped > 0 {
+		return mq.NewProducerError("DELAYED_MESSAGES_DROPPED", fmt.Sprintf("%d delayed messages dropped on close", dropped), false)
+	}
+	return nil
+}
+
+// Health returns the health status of the producer
+func (p *Producer) Health(ctx context.Context) mq.HealthStatus {
+	return p.conn.health(ctx, "producer", map[string]interface{}{
+		"pending_messages": atomic.LoadInt64(&p.pending),
+		"delayed_messages": p.scheduler.Len(),
+	})
+}
+
+// GetMetrics returns producer metrics
+func (p *Producer) GetMetrics() mq.ProducerMetrics {
+	published, bytes, errors, latency, lastErr := p.stats.snapshot()
+	return mq.ProducerMetrics{
+		MessagesPublished: published,
+		BytesPublished:    bytes,
+		PublishErrors:     errors,
+		AvgPublishLatency: latency,
+		PendingMessages:   atomic.LoadInt64(&p.pending) + int64(p.scheduler.Len()),
+		Connected:         p.conn.connected(),
+		LastError:         lastErr,
+		LastUpdated:       time.Now(),
+
+		DelayedPublishErrors: p.scheduler.Failed(),
+	}
+}
+
+// producerClosedError returns the error of publishing on a closed producer
+func producerClosedError() error {
+	err := mq.NewProducerError("PRODUCER_CLOSED", "producer is closed", false)
+	err.Cause = mq.ErrProducerClosed
+	return err
+}
@@ -54,19 +54,26 @@ type CreateApplicationRequest struct {
 type UpdateApplicationRequest struct {
 	Name        string `json:"name,omitempty"`
 	Description string `json:"description,omitempty"`
+
+	// Version the update is based on, rejected once the application has
+	// been modified since; the current version is used when omitted
+	Version int64 `json:"version,omitempty"`
 }
 
 // ApplicationResponse represents an application response
 type ApplicationResponse struct {
-	ID          string    `json:"id"`
-	Name        string    `json:"name"`
-	Description string    `json:"description"`
-	UserID      string    `json:"user_id"`
-	APIKey      string    `json:"api_key"`
-	Status      string    `json:"status"`
-	RateLimit   int64     `json:"rate_limit"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID          string     `json:"id"`
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	UserID      string     `json:"user_id"`
+	APIKey      string     `json:"api_key"`
+	Status      string     `json:"status"`
+	RateLimit   int64      `json:"rate_limit"`
+	Scopes      []string   `json:"scopes"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	LastUsedAt  *time.Time `json:"last_used_at,omitempty"`
+	Version     int64      `json:"version"`
 }
 
 // ApplicationListResponse represents a paginated list of applications
@@ -76,6 +83,9 @@ type ApplicationListResponse struct {
 	Offset       int                    `json:"offset"`
 	Limit        int                    `json:"limit"`
 	HasMore      bool                   `json:"has_more"`
+
+	TotalEstimated bool   `json:"total_estimated"`       // Total is an estimate, see ?estimate_total=true
+	NextCursor     string `json:"next_cursor,omitempty"` // Cursor of the next page, see ?page_size
 }
 
 // HandleCreateApplication handles POST /api/applications
@@ -247,11 +257,26 @@ func (ah *ApplicationHandler) HandleListApplications(w http.ResponseWriter, r *h
 		Limit:  limit,
 		SortBy: "created_at",
 		SortOrder: "desc",
+		EstimateTotal: r.URL.Query().Get("estimate_total") == "true",
+		Cursor: r.URL.Query().Get("cursor"),
+	}
+
+	// Page with a cursor instead of an offset when ?page_size or ?cursor is
+	// given: the first page is requested with ?page_size and the following
+	// ones with the next_cursor of the previous page
+	if pageSize, err := strconv.Atoi(r.URL.Query().Get("page_size")); err == nil && pageSize > 0 && pageSize <= 100 {
+		filter.PageSize = pageSize
+	} else if filter.Cursor != "" {
+		filter.PageSize = limit
 	}
 
 	// Get applications
 	result, err := ah.appRepo.ListApplications(ctx, filter)
 	if err != nil {
+		if portal.IsValidationError(err) {
+			ah.writeError(w, http.StatusBadRequest, "INVALID_CURSOR", "Invalid pagination cursor")
+			return
+		}
 		ah.writeError(w, http.StatusInternalServerError, "DATABASE_ERROR", "Failed to retrieve applications")
 		return
 	}
@@ -268,6 +293,8 @@ func (ah *ApplicationHandler) HandleListApplications(w http.ResponseWriter, r *h
 		Offset:       result.Offset,
 		Limit:        result.Limit,
 		HasMore:      result.HasMore,
+		TotalEstimated: result.TotalEstimated,
+		NextCursor: result.NextCursor,
 	}
 
 	ah.writeJSON(w, http.StatusOK, response)
@@ -327,6 +354,9 @@ func (ah *ApplicationHandler) HandleUpdateApplication(w http.ResponseWriter, r *
 	if req.Description != "" {
 		app.Description = req.Description
 	}
+	if req.Version != 0 {
+		app.Version = req.Version
+	}
 	app.UpdatedAt = time.Now()
 
 	// Validate updated application
@@ -337,7 +367,11 @@ func (ah *ApplicationHandler) HandleUpdateApplication(w http.ResponseWriter, r *
 
 	// Update application
 	if err := ah.appRepo.UpdateApplication(ctx, app); err != nil {
-		ah.writeError(w, http.StatusInternalServerError, "UPDATE_ERROR", "Failed to update application")
+		if portal.IsConflictError(err) {
+			ah.writeError(w, http.StatusConflict, "STALE_VERSION", "Application has been modified, reload it and retry")
+		} else {
+			ah.writeError(w, http.StatusInternalServerError, "UPDATE_ERROR", "Failed to update application")
+		}
 		return
 	}
 
@@ -538,8 +572,11 @@ func (ah *ApplicationHandler) toApplicationResponse(app *portal.Application) *Ap
 		APIKey:      app.APIKey,
 		Status:      string(app.Status),
 		RateLimit:   app.RateLimit,
+		Scopes:      app.Scopes,
 		CreatedAt:   app.CreatedAt,
 		UpdatedAt:   app.UpdatedAt,
+		LastUsedAt:  app.LastUsedAt,
+		Version:     app.Version,
 	}
 }
 
@@ -223,6 +223,10 @@ func (ph *PortalHandler) HandleLogin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Record the login for dormant account detection; failing to record it
+	// doesn't fail the login
+	_ = ph.userRepo.UpdateLastLogin(ctx, user.ID)
+
 	// Prepare response
 	response := AuthResponse{
 		Token: token,
@@ -0,0 +1,116 @@
+// Package instrument provides metrics instrumentation shared by the portal
+// repository implementations.
+package instrument
+
+import (
+	"errors"
+	"time"
+
+	"github.com/songzhibin97/stargate/pkg/metrics"
+	"github.com/songzhibin97/stargate/pkg/portal"
+)
+
+// Repository names used as the "repository" label value
+const (
+	UserRepository        = "user"
+	ApplicationRepository = "application"
+)
+
+// Error type label values
+const (
+	ErrorTypeNotFound   = "notfound"
+	ErrorTypeConflict   = "conflict"
+	ErrorTypeValidation = "validation"
+	ErrorTypeDatabase   = "database"
+	ErrorTypeOther      = "other"
+)
+
+// noopDone is returned by Track on a nil Recorder so disabled instrumentation
+// neither reads the clock nor allocates
+var noopDone = func(*error) {}
+
+// Recorder records per-method call counts, latencies and errors for portal repositories
+type Recorder struct {
+	calls    metrics.CounterVec
+	duration metrics.HistogramVec
+	errors   metrics.CounterVec
+}
+
+// NewRecorder creates a new recorder registering its metrics with the given provider
+func NewRecorder(provider metrics.Provider) (*Recorder, error) {
+	calls, err := provider.NewCounterVec(metrics.MetricOptions{
+		Name:   "portal_repository_calls_total",
+		Help:   "Total number of portal repository method calls",
+		Labels: []string{"repository", "method"},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	duration, err := provider.NewHistogramVec(metrics.MetricOptions{
+		Name:    "portal_repository_call_duration_seconds",
+		Help:    "Portal repository method call latency in seconds",
+		Labels:  []string{"repository", "method"},
+		Buckets: metrics.GetDefaultBuckets("latency"),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	errorsTotal, err := provider.NewCounterVec(metrics.MetricOptions{
+		Name:   "portal_repository_errors_total",
+		Help:   "Total number of portal repository method errors",
+		Labels: []string{"repository", "method", "error_type"},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &Recorder{
+		calls:    calls,
+		duration: duration,
+		errors:   errorsTotal,
+	}, nil
+}
+
+// Track starts measuring a repository method call. The returned function must
+// be deferred with a pointer to the method's error result:
+//
+//	defer ar.repo.metrics.Track(instrument.ApplicationRepository, "GetApplication")(&err)
+//
+// A nil Recorder is valid and records nothing.
+func (r *Recorder) Track(repository, method string) func(*error) {
+	if r == nil {
+		return noopDone
+	}
+
+	start := time.Now()
+	return func(errp *error) {
+		r.calls.WithLabelValues(repository, method).Inc()
+		r.duration.WithLabelValues(repository, method).Observe(time.Since(start).Seconds())
+		if errp != nil && *errp != nil {
+			r.errors.WithLabelValues(repository, method, ErrorType(*errp)).Inc()
+		}
+	}
+}
+
+// ErrorType maps a repository error to its error type label value
+func ErrorType(err error) string {
+	var portalErr *portal.PortalError
+	if !errors.As(err, &portalErr) {
+		return ErrorTypeOther
+	}
+
+	switch portalErr.Type {
+	case portal.ErrorTypeNotFound:
+		return ErrorTypeNotFound
+	case portal.ErrorTypeConflict:
+		return ErrorTypeConflict
+	case portal.ErrorTypeValidation:
+		return ErrorTypeValidation
+	case portal.ErrorTypeDatabase:
+		return ErrorTypeDatabase
+	default:
+		return ErrorTypeOther
+	}
+}
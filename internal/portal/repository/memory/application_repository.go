@@ -8,6 +8,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/songzhibin97/stargate/internal/portal/repository/instrument"
 	"github.com/songzhibin97/stargate/pkg/portal"
 )
 
@@ -25,13 +26,21 @@ func NewApplicationRepository(repo *Repository) *ApplicationRepository {
 }
 
 // CreateApplication creates a new application
-func (ar *ApplicationRepository) CreateApplication(ctx context.Context, app *portal.Application) error {
+func (ar *ApplicationRepository) CreateApplication(ctx context.Context, app *portal.Application) (err error) {
+	defer ar.repo.metrics.Track(instrument.ApplicationRepository, "CreateApplication")(&err)
+
 	if ar.tx != nil {
-		if err := ar.tx.isActive(); err != nil {
+		if err := ar.tx.isWritable(); err != nil {
 			return err
 		}
 	}
 
+	// Hash the secret before locking, hashing is slow on purpose
+	secretHash, err := ar.hashAPISecret(app)
+	if err != nil {
+		return err
+	}
+
 	ar.repo.mu.Lock()
 	defer ar.repo.mu.Unlock()
 
@@ -64,17 +73,22 @@ func (ar *ApplicationRepository) CreateApplication(ctx context.Context, app *por
 		app.CreatedAt = now
 	}
 	app.UpdatedAt = now
+	app.Version = 1
 
 	// Create a copy to avoid external modifications
-	appCopy := *app
-	ar.repo.applications[app.ID] = &appCopy
-	ar.repo.addApplicationToIndex(&appCopy)
+	app.Scopes = portal.NormalizeScopes(app.Scopes)
+	appCopy := copyApplication(app)
+	appCopy.APISecret = secretHash
+	ar.repo.applications[app.ID] = appCopy
+	ar.repo.addApplicationToIndex(appCopy)
 
 	return nil
 }
 
 // GetApplication retrieves an application by ID
-func (ar *ApplicationRepository) GetApplication(ctx context.Context, appID string) (*portal.Application, error) {
+func (ar *ApplicationRepository) GetApplication(ctx context.Context, appID string) (_ *portal.Application, err error) {
+	defer ar.repo.metrics.Track(instrument.ApplicationRepository, "GetApplication")(&err)
+
 	if ar.tx != nil {
 		if err := ar.tx.isActive(); err != nil {
 			return nil, err
@@ -92,18 +106,19 @@ func (ar *ApplicationRepository) GetApplication(ctx context.Context, appID strin
 		return nil, portal.NewValidationError("INVALID_APPLICATION_ID", "application ID cannot be empty")
 	}
 
-	app, exists := ar.repo.applications[appID]
+	app, exists := ar.liveApplication(appID)
 	if !exists {
 		return nil, portal.NewNotFoundError("APPLICATION_NOT_FOUND", "application not found")
 	}
 
 	// Return a copy to avoid external modifications
-	appCopy := *app
-	return &appCopy, nil
+	return exportApplication(app), nil
 }
 
 // GetApplicationByAPIKey retrieves an application by API key
-func (ar *ApplicationRepository) GetApplicationByAPIKey(ctx context.Context, apiKey string) (*portal.Application, error) {
+func (ar *ApplicationRepository) GetApplicationByAPIKey(ctx context.Context, apiKey string) (_ *portal.Application, err error) {
+	defer ar.repo.metrics.Track(instrument.ApplicationRepository, "GetApplicationByAPIKey")(&err)
+
 	if ar.tx != nil {
 		if err := ar.tx.isActive(); err != nil {
 			return nil, err
@@ -127,12 +142,13 @@ func (ar *ApplicationRepository) GetApplicationByAPIKey(ctx context.Context, api
 	}
 
 	// Return a copy to avoid external modifications
-	appCopy := *app
-	return &appCopy, nil
+	return exportApplication(app), nil
 }
 
 // GetApplicationsByUser retrieves all applications for a specific user
-func (ar *ApplicationRepository) GetApplicationsByUser(ctx context.Context, userID string) ([]*portal.Application, error) {
+func (ar *ApplicationRepository) GetApplicationsByUser(ctx context.Context, userID string) (_ []*portal.Application, err error) {
+	defer ar.repo.metrics.Track(instrument.ApplicationRepository, "GetApplicationsByUser")(&err)
+
 	if ar.tx != nil {
 		if err := ar.tx.isActive(); err != nil {
 			return nil, err
@@ -158,21 +174,27 @@ func (ar *ApplicationRepository) GetApplicationsByUser(ctx context.Context, user
 	// Return copies to avoid external modifications
 	result := make([]*portal.Application, len(apps))
 	for i, app := range apps {
-		appCopy := *app
-		result[i] = &appCopy
+		result[i] = exportApplication(app)
 	}
 
 	return result, nil
 }
 
 // UpdateApplication updates an existing application
-func (ar *ApplicationRepository) UpdateApplication(ctx context.Context, app *portal.Application) error {
+func (ar *ApplicationRepository) UpdateApplication(ctx context.Context, app *portal.Application) (err error) {
+	defer ar.repo.metrics.Track(instrument.ApplicationRepository, "UpdateApplication")(&err)
+
 	if ar.tx != nil {
-		if err := ar.tx.isActive(); err != nil {
+		if err := ar.tx.isWritable(); err != nil {
 			return err
 		}
 	}
 
+	secretHash, err := ar.hashAPISecret(app)
+	if err != nil {
+		return err
+	}
+
 	ar.repo.mu.Lock()
 	defer ar.repo.mu.Unlock()
 
@@ -185,11 +207,16 @@ func (ar *ApplicationRepository) UpdateApplication(ctx context.Context, app *por
 	}
 
 	// Check if application exists
-	existingApp, exists := ar.repo.applications[app.ID]
+	existingApp, exists := ar.liveApplication(app.ID)
 	if !exists {
 		return portal.NewNotFoundError("APPLICATION_NOT_FOUND", "application not found")
 	}
 
+	// Reject updates of an application modified since it was read
+	if app.Version != existingApp.Version {
+		return portal.NewConflictError("STALE_VERSION", "application has been modified since it was read")
+	}
+
 	// Check if API key is being changed and if new API key already exists
 	if existingApp.APIKey != app.APIKey {
 		if _, apiKeyExists := ar.repo.appsByAPIKey[app.APIKey]; apiKeyExists {
@@ -206,20 +233,30 @@ func (ar *ApplicationRepository) UpdateApplication(ctx context.Context, app *por
 
 	// Update timestamps
 	app.CreatedAt = existingApp.CreatedAt // Preserve original creation time
+	app.LastUsedAt = existingApp.LastUsedAt
+	app.DeletedAt = existingApp.DeletedAt
 	app.UpdatedAt = time.Now()
+	app.Version = existingApp.Version + 1
 
-	// Create a copy and update
-	appCopy := *app
-	ar.repo.applications[app.ID] = &appCopy
-	ar.repo.addApplicationToIndex(&appCopy)
+	// Create a copy and update, keeping the secret unless a new one is set
+	app.Scopes = portal.NormalizeScopes(app.Scopes)
+	appCopy := copyApplication(app)
+	appCopy.APISecret = secretHash
+	if secretHash == "" {
+		appCopy.APISecret = existingApp.APISecret
+	}
+	ar.repo.applications[app.ID] = appCopy
+	ar.repo.addApplicationToIndex(appCopy)
 
 	return nil
 }
 
 // DeleteApplication deletes an application by ID
-func (ar *ApplicationRepository) DeleteApplication(ctx context.Context, appID string) error {
+func (ar *ApplicationRepository) DeleteApplication(ctx context.Context, appID string) (err error) {
+	defer ar.repo.metrics.Track(instrument.ApplicationRepository, "DeleteApplication")(&err)
+
 	if ar.tx != nil {
-		if err := ar.tx.isActive(); err != nil {
+		if err := ar.tx.isWritable(); err != nil {
 			return err
 		}
 	}
@@ -240,15 +277,101 @@ func (ar *ApplicationRepository) DeleteApplication(ctx context.Context, appID st
 		return portal.NewNotFoundError("APPLICATION_NOT_FOUND", "application not found")
 	}
 
-	// Remove from indexes
-	ar.repo.removeApplicationFromIndex(app)
+	// Remove from indexes, which soft-deleted applications already left
+	if app.DeletedAt == nil {
+		ar.repo.removeApplicationFromIndex(app)
+	}
 	delete(ar.repo.applications, appID)
+	delete(ar.repo.usage, appID)
+
+	return nil
+}
+
+// SoftDeleteApplication marks an application deleted, releasing its API key
+func (ar *ApplicationRepository) SoftDeleteApplication(ctx context.Context, appID string) (err error) {
+	defer ar.repo.metrics.Track(instrument.ApplicationRepository, "SoftDeleteApplication")(&err)
+
+	if ar.tx != nil {
+		if err := ar.tx.isWritable(); err != nil {
+			return err
+		}
+	}
+
+	ar.repo.mu.Lock()
+	defer ar.repo.mu.Unlock()
+
+	if ar.repo.closed {
+		return portal.NewDatabaseError("REPO_CLOSED", "repository is closed", nil)
+	}
+
+	if appID == "" {
+		return portal.NewValidationError("INVALID_APPLICATION_ID", "application ID cannot be empty")
+	}
+
+	app, exists := ar.liveApplication(appID)
+	if !exists {
+		return portal.NewNotFoundError("APPLICATION_NOT_FOUND", "application not found")
+	}
+
+	// Keep the application but drop it from the indexes, so lookups skip it
+	// and its API key can be reused
+	now := time.Now()
+	ar.repo.removeApplicationFromIndex(app)
+	app.DeletedAt = &now
+	app.UpdatedAt = now
+	app.Version++
+
+	return nil
+}
+
+// RestoreApplication clears the deletion mark of a soft-deleted application
+func (ar *ApplicationRepository) RestoreApplication(ctx context.Context, appID string) (err error) {
+	defer ar.repo.metrics.Track(instrument.ApplicationRepository, "RestoreApplication")(&err)
+
+	if ar.tx != nil {
+		if err := ar.tx.isWritable(); err != nil {
+			return err
+		}
+	}
+
+	ar.repo.mu.Lock()
+	defer ar.repo.mu.Unlock()
+
+	if ar.repo.closed {
+		return portal.NewDatabaseError("REPO_CLOSED", "repository is closed", nil)
+	}
+
+	if appID == "" {
+		return portal.NewValidationError("INVALID_APPLICATION_ID", "application ID cannot be empty")
+	}
+
+	app, exists := ar.repo.applications[appID]
+	if !exists || app.DeletedAt == nil {
+		return portal.NewNotFoundError("APPLICATION_NOT_FOUND", "deleted application not found")
+	}
+
+	// The API key may have been reused since the application was deleted
+	if _, exists := ar.repo.appsByAPIKey[app.APIKey]; exists {
+		return portal.NewConflictError("APPLICATION_API_KEY_EXISTS", "application with this API key already exists")
+	}
+
+	// Verify user exists
+	if _, exists := ar.repo.users[app.UserID]; !exists {
+		return portal.NewNotFoundError("USER_NOT_FOUND", "user not found")
+	}
+
+	app.DeletedAt = nil
+	app.UpdatedAt = time.Now()
+	app.Version++
+	ar.repo.addApplicationToIndex(app)
 
 	return nil
 }
 
 // ExistsApplication checks if an application exists by ID
-func (ar *ApplicationRepository) ExistsApplication(ctx context.Context, appID string) (bool, error) {
+func (ar *ApplicationRepository) ExistsApplication(ctx context.Context, appID string) (_ bool, err error) {
+	defer ar.repo.metrics.Track(instrument.ApplicationRepository, "ExistsApplication")(&err)
+
 	if ar.tx != nil {
 		if err := ar.tx.isActive(); err != nil {
 			return false, err
@@ -266,12 +389,14 @@ func (ar *ApplicationRepository) ExistsApplication(ctx context.Context, appID st
 		return false, portal.NewValidationError("INVALID_APPLICATION_ID", "application ID cannot be empty")
 	}
 
-	_, exists := ar.repo.applications[appID]
+	_, exists := ar.liveApplication(appID)
 	return exists, nil
 }
 
 // ExistsApplicationByAPIKey checks if an application exists by API key
-func (ar *ApplicationRepository) ExistsApplicationByAPIKey(ctx context.Context, apiKey string) (bool, error) {
+func (ar *ApplicationRepository) ExistsApplicationByAPIKey(ctx context.Context, apiKey string) (_ bool, err error) {
+	defer ar.repo.metrics.Track(instrument.ApplicationRepository, "ExistsApplicationByAPIKey")(&err)
+
 	if ar.tx != nil {
 		if err := ar.tx.isActive(); err != nil {
 			return false, err
@@ -294,9 +419,11 @@ func (ar *ApplicationRepository) ExistsApplicationByAPIKey(ctx context.Context,
 }
 
 // UpdateApplicationStatus updates the status of an application
-func (ar *ApplicationRepository) UpdateApplicationStatus(ctx context.Context, appID string, status portal.ApplicationStatus) error {
+func (ar *ApplicationRepository) UpdateApplicationStatus(ctx context.Context, appID string, status portal.ApplicationStatus) (err error) {
+	defer ar.repo.metrics.Track(instrument.ApplicationRepository, "UpdateApplicationStatus")(&err)
+
 	if ar.tx != nil {
-		if err := ar.tx.isActive(); err != nil {
+		if err := ar.tx.isWritable(); err != nil {
 			return err
 		}
 	}
@@ -312,7 +439,7 @@ func (ar *ApplicationRepository) UpdateApplicationStatus(ctx context.Context, ap
 		return portal.NewValidationError("INVALID_APPLICATION_ID", "application ID cannot be empty")
 	}
 
-	app, exists := ar.repo.applications[appID]
+	app, exists := ar.liveApplication(appID)
 	if !exists {
 		return portal.NewNotFoundError("APPLICATION_NOT_FOUND", "application not found")
 	}
@@ -320,14 +447,17 @@ func (ar *ApplicationRepository) UpdateApplicationStatus(ctx context.Context, ap
 	// Update status
 	app.Status = status
 	app.UpdatedAt = time.Now()
+	app.Version++
 
 	return nil
 }
 
 // UpdateApplicationRateLimit updates the rate limit of an application
-func (ar *ApplicationRepository) UpdateApplicationRateLimit(ctx context.Context, appID string, rateLimit int64) error {
+func (ar *ApplicationRepository) UpdateApplicationRateLimit(ctx context.Context, appID string, rateLimit int64) (err error) {
+	defer ar.repo.metrics.Track(instrument.ApplicationRepository, "UpdateApplicationRateLimit")(&err)
+
 	if ar.tx != nil {
-		if err := ar.tx.isActive(); err != nil {
+		if err := ar.tx.isWritable(); err != nil {
 			return err
 		}
 	}
@@ -347,7 +477,7 @@ func (ar *ApplicationRepository) UpdateApplicationRateLimit(ctx context.Context,
 		return portal.NewValidationError("INVALID_RATE_LIMIT", "rate limit cannot be negative")
 	}
 
-	app, exists := ar.repo.applications[appID]
+	app, exists := ar.liveApplication(appID)
 	if !exists {
 		return portal.NewNotFoundError("APPLICATION_NOT_FOUND", "application not found")
 	}
@@ -355,14 +485,352 @@ func (ar *ApplicationRepository) UpdateApplicationRateLimit(ctx context.Context,
 	// Update rate limit
 	app.RateLimit = rateLimit
 	app.UpdatedAt = time.Now()
+	app.Version++
 
 	return nil
 }
 
-// RegenerateAPIKey generates a new API key for an application
-func (ar *ApplicationRepository) RegenerateAPIKey(ctx context.Context, appID string) (string, error) {
+// GetApplicationScopes retrieves the scopes granted to an application
+func (ar *ApplicationRepository) GetApplicationScopes(ctx context.Context, appID string) (_ []string, err error) {
+	defer ar.repo.metrics.Track(instrument.ApplicationRepository, "GetApplicationScopes")(&err)
+
+	app, err := ar.GetApplication(ctx, appID)
+	if err != nil {
+		return nil, err
+	}
+	return app.Scopes, nil
+}
+
+// SetApplicationScopes replaces the scopes granted to an application
+func (ar *ApplicationRepository) SetApplicationScopes(ctx context.Context, appID string, scopes []string) (err error) {
+	defer ar.repo.metrics.Track(instrument.ApplicationRepository, "SetApplicationScopes")(&err)
+
+	if err := portal.ValidateScopes(scopes, ar.repo.scopes); err != nil {
+		return err
+	}
+	return ar.updateScopes(appID, func([]string) []string {
+		return scopes
+	})
+}
+
+// AddApplicationScopes grants additional scopes to an application
+func (ar *ApplicationRepository) AddApplicationScopes(ctx context.Context, appID string, scopes []string) (err error) {
+	defer ar.repo.metrics.Track(instrument.ApplicationRepository, "AddApplicationScopes")(&err)
+
+	if err := portal.ValidateScopes(scopes, ar.repo.scopes); err != nil {
+		return err
+	}
+	return ar.updateScopes(appID, func(current []string) []string {
+		return append(append([]string(nil), current...), scopes...)
+	})
+}
+
+// RemoveApplicationScopes revokes scopes from an application
+func (ar *ApplicationRepository) RemoveApplicationScopes(ctx context.Context, appID string, scopes []string) (err error) {
+	defer ar.repo.metrics.Track(instrument.ApplicationRepository, "RemoveApplicationScopes")(&err)
+
+	removed := make(map[string]bool, len(scopes))
+	for _, scope := range scopes {
+		removed[scope] = true
+	}
+	return ar.updateScopes(appID, func(current []string) []string {
+		var kept []string
+		for _, scope := range current {
+			if !removed[scope] {
+				kept = append(kept, scope)
+			}
+		}
+		return kept
+	})
+}
+
+// updateScopes replaces the scopes of an application with the result of
+// update applied to its current scopes
+func (ar *ApplicationRepository) updateScopes(appID string, update func(current []string) []string) error {
+	if ar.tx != nil {
+		if err := ar.tx.isWritable(); err != nil {
+			return err
+		}
+	}
+
+	ar.repo.mu.Lock()
+	defer ar.repo.mu.Unlock()
+
+	if ar.repo.closed {
+		return portal.NewDatabaseError("REPO_CLOSED", "repository is closed", nil)
+	}
+
+	if appID == "" {
+		return portal.NewValidationError("INVALID_APPLICATION_ID", "application ID cannot be empty")
+	}
+
+	app, exists := ar.liveApplication(appID)
+	if !exists {
+		return portal.NewNotFoundError("APPLICATION_NOT_FOUND", "application not found")
+	}
+
+	// Update scopes, never modifying the slice in place
+	app.Scopes = portal.NormalizeScopes(update(app.Scopes))
+	app.UpdatedAt = time.Now()
+	app.Version++
+
+	return nil
+}
+
+// RecordApplicationsLastUsed records when applications were last used
+func (ar *ApplicationRepository) RecordApplicationsLastUsed(ctx context.Context, lastUsed map[string]time.Time) (err error) {
+	defer ar.repo.metrics.Track(instrument.ApplicationRepository, "RecordApplicationsLastUsed")(&err)
+
+	if ar.tx != nil {
+		if err := ar.tx.isWritable(); err != nil {
+			return err
+		}
+	}
+
+	ar.repo.mu.Lock()
+	defer ar.repo.mu.Unlock()
+
+	if ar.repo.closed {
+		return portal.NewDatabaseError("REPO_CLOSED", "repository is closed", nil)
+	}
+
+	for appID, usedAt := range lastUsed {
+		app, exists := ar.liveApplication(appID)
+		if !exists {
+			continue
+		}
+		if app.LastUsedAt == nil || app.LastUsedAt.Before(usedAt) {
+			usedAt := usedAt
+			app.LastUsedAt = &usedAt
+		}
+	}
+
+	return nil
+}
+
+// ListDormantApplications retrieves the active applications not used since the given time
+func (ar *ApplicationRepository) ListDormantApplications(ctx context.Context, unusedSince time.Time) (_ []*portal.Application, err error) {
+	defer ar.repo.metrics.Track(instrument.ApplicationRepository, "ListDormantApplications")(&err)
+
 	if ar.tx != nil {
 		if err := ar.tx.isActive(); err != nil {
+			return nil, err
+		}
+	}
+
+	ar.repo.mu.RLock()
+	defer ar.repo.mu.RUnlock()
+
+	if ar.repo.closed {
+		return nil, portal.NewDatabaseError("REPO_CLOSED", "repository is closed", nil)
+	}
+
+	var dormant []*portal.Application
+	for _, app := range ar.dormantApplications(unusedSince) {
+		dormant = append(dormant, exportApplication(app))
+	}
+	return dormant, nil
+}
+
+// SuspendDormantApplications suspends the active applications not used since the given time
+func (ar *ApplicationRepository) SuspendDormantApplications(ctx context.Context, unusedSince time.Time) (_ []string, err error) {
+	defer ar.repo.metrics.Track(instrument.ApplicationRepository, "SuspendDormantApplications")(&err)
+
+	if ar.tx != nil {
+		if err := ar.tx.isWritable(); err != nil {
+			return nil, err
+		}
+	}
+
+	ar.repo.mu.Lock()
+	defer ar.repo.mu.Unlock()
+
+	if ar.repo.closed {
+		return nil, portal.NewDatabaseError("REPO_CLOSED", "repository is closed", nil)
+	}
+
+	now := time.Now()
+	var suspended []string
+	for _, app := range ar.dormantApplications(unusedSince) {
+		app.Status = portal.ApplicationStatusSuspended
+		app.UpdatedAt = now
+		app.Version++
+		suspended = append(suspended, app.ID)
+	}
+	sort.Strings(suspended)
+	return suspended, nil
+}
+
+// dormantApplications returns the stored active applications not used since
+// the given time, least recently active first. The caller must hold the lock.
+func (ar *ApplicationRepository) dormantApplications(unusedSince time.Time) []*portal.Application {
+	var dormant []*portal.Application
+	for _, app := range ar.repo.applications {
+		if app.DeletedAt == nil && app.Status == portal.ApplicationStatusActive && app.LastActivity().Before(unusedSince) {
+			dormant = append(dormant, app)
+		}
+	}
+	sort.Slice(dormant, func(i, j int) bool {
+		if !dormant[i].LastActivity().Equal(dormant[j].LastActivity()) {
+			return dormant[i].LastActivity().Before(dormant[j].LastActivity())
+		}
+		return dormant[i].ID < dormant[j].ID
+	})
+	return dormant
+}
+
+// TransferApplications moves applications from one user to another atomically
+func (ar *ApplicationRepository) TransferApplications(ctx context.Context, fromUserID, toUserID string, appIDs []string) (err error) {
+	defer ar.repo.metrics.Track(instrument.ApplicationRepository, "TransferApplications")(&err)
+
+	if ar.tx != nil {
+		if err := ar.tx.isWritable(); err != nil {
+			return err
+		}
+	}
+
+	events, err := ar.transferApplications(fromUserID, toUserID, appIDs)
+	if err != nil {
+		return err
+	}
+
+	if ar.tx != nil {
+		ar.tx.addEvents(events)
+	} else {
+		ar.repo.publishEvents(events)
+	}
+	return nil
+}
+
+// transferApplications checks every application before moving any, so a
+// failed transfer leaves all of them with their owner
+func (ar *ApplicationRepository) transferApplications(fromUserID, toUserID string, appIDs []string) ([]portal.ApplicationEvent, error) {
+	if fromUserID == "" || toUserID == "" {
+		return nil, portal.NewValidationError("INVALID_USER_ID", "user ID cannot be empty")
+	}
+	if fromUserID == toUserID {
+		return nil, portal.NewValidationError("INVALID_TRANSFER", "cannot transfer applications to their owner")
+	}
+
+	ar.repo.mu.Lock()
+	defer ar.repo.mu.Unlock()
+
+	if ar.repo.closed {
+		return nil, portal.NewDatabaseError("REPO_CLOSED", "repository is closed", nil)
+	}
+
+	if _, exists := ar.repo.users[toUserID]; !exists {
+		return nil, portal.NewNotFoundError("USER_NOT_FOUND", "user with ID "+toUserID+" not found")
+	}
+
+	var apps []*portal.Application
+	if len(appIDs) == 0 {
+		apps = append(apps, ar.repo.appsByUser[fromUserID]...)
+	} else {
+		seen := make(map[string]bool, len(appIDs))
+		for _, appID := range appIDs {
+			if appID == "" {
+				return nil, portal.NewValidationError("INVALID_APPLICATION_ID", "application ID cannot be empty")
+			}
+			if seen[appID] {
+				continue
+			}
+			seen[appID] = true
+
+			app, exists := ar.liveApplication(appID)
+			if !exists || app.UserID != fromUserID {
+				return nil, portal.NewNotFoundError("APPLICATION_NOT_FOUND", "application with ID "+appID+" not found for user "+fromUserID)
+			}
+			apps = append(apps, app)
+		}
+	}
+
+	now := time.Now()
+	events := make([]portal.ApplicationEvent, 0, len(apps))
+	for _, app := range apps {
+		events = append(events, ar.moveApplication(app, toUserID, now))
+	}
+	return events, nil
+}
+
+// TransferApplication moves an application to another user
+func (ar *ApplicationRepository) TransferApplication(ctx context.Context, appID, newUserID string) (err error) {
+	defer ar.repo.metrics.Track(instrument.ApplicationRepository, "TransferApplication")(&err)
+
+	if ar.tx != nil {
+		if err := ar.tx.isWritable(); err != nil {
+			return err
+		}
+	}
+
+	event, err := ar.transferApplication(appID, newUserID)
+	if err != nil {
+		return err
+	}
+
+	if ar.tx != nil {
+		ar.tx.addEvents([]portal.ApplicationEvent{event})
+	} else {
+		ar.repo.publishEvents([]portal.ApplicationEvent{event})
+	}
+	return nil
+}
+
+// transferApplication moves an application to another user under the lock
+func (ar *ApplicationRepository) transferApplication(appID, newUserID string) (portal.ApplicationEvent, error) {
+	if appID == "" {
+		return portal.ApplicationEvent{}, portal.NewValidationError("INVALID_APPLICATION_ID", "application ID cannot be empty")
+	}
+	if newUserID == "" {
+		return portal.ApplicationEvent{}, portal.NewValidationError("INVALID_USER_ID", "user ID cannot be empty")
+	}
+
+	ar.repo.mu.Lock()
+	defer ar.repo.mu.Unlock()
+
+	if ar.repo.closed {
+		return portal.ApplicationEvent{}, portal.NewDatabaseError("REPO_CLOSED", "repository is closed", nil)
+	}
+
+	app, exists := ar.liveApplication(appID)
+	if !exists {
+		return portal.ApplicationEvent{}, portal.NewNotFoundError("APPLICATION_NOT_FOUND", "application not found")
+	}
+	if _, exists := ar.repo.users[newUserID]; !exists {
+		return portal.ApplicationEvent{}, portal.NewNotFoundError("USER_NOT_FOUND", "user with ID "+newUserID+" not found")
+	}
+	if app.UserID == newUserID {
+		return portal.ApplicationEvent{}, portal.NewValidationError("INVALID_TRANSFER", "cannot transfer an application to its owner")
+	}
+
+	return ar.moveApplication(app, newUserID, time.Now()), nil
+}
+
+// moveApplication moves a stored application to another user, updating the
+// indexes, and returns the transfer event. The caller must hold the lock.
+func (ar *ApplicationRepository) moveApplication(app *portal.Application, toUserID string, now time.Time) portal.ApplicationEvent {
+	fromUserID := app.UserID
+	ar.repo.removeApplicationFromIndex(app)
+	app.UserID = toUserID
+	app.UpdatedAt = now
+	app.Version++
+	ar.repo.addApplicationToIndex(app)
+
+	return portal.ApplicationEvent{
+		Type:           portal.ApplicationEventTransferred,
+		ApplicationID:  app.ID,
+		UserID:         toUserID,
+		PreviousUserID: fromUserID,
+		Time:           now,
+	}
+}
+
+// RegenerateAPIKey generates a new API key for an application
+func (ar *ApplicationRepository) RegenerateAPIKey(ctx context.Context, appID string) (_ string, err error) {
+	defer ar.repo.metrics.Track(instrument.ApplicationRepository, "RegenerateAPIKey")(&err)
+
+	if ar.tx != nil {
+		if err := ar.tx.isWritable(); err != nil {
 			return "", err
 		}
 	}
@@ -378,7 +846,7 @@ func (ar *ApplicationRepository) RegenerateAPIKey(ctx context.Context, appID str
 		return "", portal.NewValidationError("INVALID_APPLICATION_ID", "application ID cannot be empty")
 	}
 
-	app, exists := ar.repo.applications[appID]
+	app, exists := ar.liveApplication(appID)
 	if !exists {
 		return "", portal.NewNotFoundError("APPLICATION_NOT_FOUND", "application not found")
 	}
@@ -395,6 +863,7 @@ func (ar *ApplicationRepository) RegenerateAPIKey(ctx context.Context, appID str
 	// Update application
 	app.APIKey = newAPIKey
 	app.UpdatedAt = time.Now()
+	app.Version++
 
 	// Add new API key to index
 	ar.repo.appsByAPIKey[newAPIKey] = app
@@ -403,13 +872,25 @@ func (ar *ApplicationRepository) RegenerateAPIKey(ctx context.Context, appID str
 }
 
 // RegenerateAPISecret generates a new API secret for an application
-func (ar *ApplicationRepository) RegenerateAPISecret(ctx context.Context, appID string) (string, error) {
+func (ar *ApplicationRepository) RegenerateAPISecret(ctx context.Context, appID string) (_ string, err error) {
+	defer ar.repo.metrics.Track(instrument.ApplicationRepository, "RegenerateAPISecret")(&err)
+
 	if ar.tx != nil {
-		if err := ar.tx.isActive(); err != nil {
+		if err := ar.tx.isWritable(); err != nil {
 			return "", err
 		}
 	}
 
+	// Generate new API secret, returned once and stored hashed
+	newAPISecret, err := ar.generateAPISecret()
+	if err != nil {
+		return "", portal.NewInternalError("API_SECRET_GENERATION_FAILED", "failed to generate API secret", err)
+	}
+	secretHash, err := ar.repo.secrets.Hash(newAPISecret)
+	if err != nil {
+		return "", portal.NewInternalError("API_SECRET_HASH_FAILED", "failed to hash API secret", err)
+	}
+
 	ar.repo.mu.Lock()
 	defer ar.repo.mu.Unlock()
 
@@ -421,26 +902,83 @@ func (ar *ApplicationRepository) RegenerateAPISecret(ctx context.Context, appID
 		return "", portal.NewValidationError("INVALID_APPLICATION_ID", "application ID cannot be empty")
 	}
 
-	app, exists := ar.repo.applications[appID]
+	app, exists := ar.liveApplication(appID)
 	if !exists {
 		return "", portal.NewNotFoundError("APPLICATION_NOT_FOUND", "application not found")
 	}
 
-	// Generate new API secret
-	newAPISecret, err := ar.generateAPISecret()
-	if err != nil {
-		return "", portal.NewInternalError("API_SECRET_GENERATION_FAILED", "failed to generate API secret", err)
-	}
-
 	// Update application
-	app.APISecret = newAPISecret
+	app.APISecret = secretHash
 	app.UpdatedAt = time.Now()
+	app.Version++
 
 	return newAPISecret, nil
 }
 
+// VerifyAPISecret reports whether a secret matches the API secret of an
+// application, rehashing a secret stored in plaintext or with another
+// algorithm once it matches
+func (ar *ApplicationRepository) VerifyAPISecret(ctx context.Context, appID, presentedSecret string) (_ bool, err error) {
+	defer ar.repo.metrics.Track(instrument.ApplicationRepository, "VerifyAPISecret")(&err)
+
+	if ar.tx != nil {
+		if err := ar.tx.isActive(); err != nil {
+			return false, err
+		}
+	}
+
+	if appID == "" {
+		return false, portal.NewValidationError("INVALID_APPLICATION_ID", "application ID cannot be empty")
+	}
+
+	stored, err := ar.storedAPISecret(appID)
+	if err != nil {
+		return false, err
+	}
+
+	// Verify without holding the lock, hashing is slow on purpose
+	match, rehash := ar.repo.secrets.Verify(stored, presentedSecret)
+	// Rehashing is a write, left to a verification outside read-only
+	// transactions
+	if !rehash || (ar.tx != nil && ar.tx.readOnly) {
+		return match, nil
+	}
+
+	secretHash, err := ar.repo.secrets.Hash(presentedSecret)
+	if err != nil {
+		return false, portal.NewInternalError("API_SECRET_HASH_FAILED", "failed to hash API secret", err)
+	}
+
+	ar.repo.mu.Lock()
+	defer ar.repo.mu.Unlock()
+
+	// Keep a secret regenerated meanwhile
+	if app, exists := ar.liveApplication(appID); exists && app.APISecret == stored {
+		app.APISecret = secretHash
+	}
+	return true, nil
+}
+
+// storedAPISecret returns the stored API secret of an application
+func (ar *ApplicationRepository) storedAPISecret(appID string) (string, error) {
+	ar.repo.mu.RLock()
+	defer ar.repo.mu.RUnlock()
+
+	if ar.repo.closed {
+		return "", portal.NewDatabaseError("REPO_CLOSED", "repository is closed", nil)
+	}
+
+	app, exists := ar.liveApplication(appID)
+	if !exists {
+		return "", portal.NewNotFoundError("APPLICATION_NOT_FOUND", "application not found")
+	}
+	return app.APISecret, nil
+}
+
 // CountApplicationsByUser returns the count of applications for a specific user
-func (ar *ApplicationRepository) CountApplicationsByUser(ctx context.Context, userID string) (int64, error) {
+func (ar *ApplicationRepository) CountApplicationsByUser(ctx context.Context, userID string) (_ int64, err error) {
+	defer ar.repo.metrics.Track(instrument.ApplicationRepository, "CountApplicationsByUser")(&err)
+
 	if ar.tx != nil {
 		if err := ar.tx.isActive(); err != nil {
 			return 0, err
@@ -467,7 +1005,9 @@ func (ar *ApplicationRepository) CountApplicationsByUser(ctx context.Context, us
 }
 
 // ListApplications retrieves applications based on filter criteria
-func (ar *ApplicationRepository) ListApplications(ctx context.Context, filter *portal.ApplicationFilter) (*portal.PaginatedApplications, error) {
+func (ar *ApplicationRepository) ListApplications(ctx context.Context, filter *portal.ApplicationFilter) (_ *portal.PaginatedApplications, err error) {
+	defer ar.repo.metrics.Track(instrument.ApplicationRepository, "ListApplications")(&err)
+
 	if ar.tx != nil {
 		if err := ar.tx.isActive(); err != nil {
 			return nil, err
@@ -485,6 +1025,10 @@ func (ar *ApplicationRepository) ListApplications(ctx context.Context, filter *p
 		filter = &portal.ApplicationFilter{}
 	}
 
+	if err := filter.Validate(); err != nil {
+		return nil, err
+	}
+
 	// Set default values
 	if filter.Limit <= 0 {
 		filter.Limit = 50
@@ -503,16 +1047,36 @@ func (ar *ApplicationRepository) ListApplications(ctx context.Context, filter *p
 	var filteredApps []*portal.Application
 	for _, app := range ar.repo.applications {
 		if ar.matchesApplicationFilter(app, filter) {
-			appCopy := *app
-			filteredApps = append(filteredApps, &appCopy)
+			filteredApps = append(filteredApps, exportApplication(app))
 		}
 	}
 
+	total := int64(len(filteredApps))
+
+	// Page after the cursor instead of an offset in cursor mode
+	if filter.CursorMode() {
+		if filter.PageSize <= 0 {
+			filter.PageSize = 50
+		}
+		page, nextCursor, err := cursorPage(filteredApps, func(app *portal.Application) (time.Time, string) {
+			return app.CreatedAt, app.ID
+		}, filter.Cursor, filter.PageSize, filter.SortOrder)
+		if err != nil {
+			return nil, err
+		}
+		return &portal.PaginatedApplications{
+			Applications: page,
+			Total:        total,
+			Limit:        filter.PageSize,
+			HasMore:      nextCursor != "",
+			NextCursor:   nextCursor,
+		}, nil
+	}
+
 	// Sort applications
 	ar.sortApplications(filteredApps, filter.SortBy, filter.SortOrder)
 
 	// Calculate pagination
-	total := int64(len(filteredApps))
 	start := filter.Offset
 	end := start + filter.Limit
 
@@ -536,7 +1100,9 @@ func (ar *ApplicationRepository) ListApplications(ctx context.Context, filter *p
 }
 
 // CountApplications returns the total count of applications matching the filter
-func (ar *ApplicationRepository) CountApplications(ctx context.Context, filter *portal.ApplicationFilter) (int64, error) {
+func (ar *ApplicationRepository) CountApplications(ctx context.Context, filter *portal.ApplicationFilter) (_ int64, err error) {
+	defer ar.repo.metrics.Track(instrument.ApplicationRepository, "CountApplications")(&err)
+
 	if ar.tx != nil {
 		if err := ar.tx.isActive(); err != nil {
 			return 0, err
@@ -551,7 +1117,11 @@ func (ar *ApplicationRepository) CountApplications(ctx context.Context, filter *
 	}
 
 	if filter == nil {
-		return int64(len(ar.repo.applications)), nil
+		filter = &portal.ApplicationFilter{}
+	}
+
+	if err := filter.Validate(); err != nil {
+		return 0, err
 	}
 
 	count := int64(0)
@@ -564,14 +1134,53 @@ func (ar *ApplicationRepository) CountApplications(ctx context.Context, filter *
 	return count, nil
 }
 
-// BatchCreateApplications creates multiple applications in a single operation
-func (ar *ApplicationRepository) BatchCreateApplications(ctx context.Context, apps []*portal.Application) error {
+// BatchGetApplications retrieves multiple applications by IDs, leaving out
+// the ones that don't exist
+func (ar *ApplicationRepository) BatchGetApplications(ctx context.Context, appIDs []string) (_ map[string]*portal.Application, err error) {
+	defer ar.repo.metrics.Track(instrument.ApplicationRepository, "BatchGetApplications")(&err)
+
 	if ar.tx != nil {
 		if err := ar.tx.isActive(); err != nil {
+			return nil, err
+		}
+	}
+
+	ar.repo.mu.RLock()
+	defer ar.repo.mu.RUnlock()
+
+	if ar.repo.closed {
+		return nil, portal.NewDatabaseError("REPO_CLOSED", "repository is closed", nil)
+	}
+
+	apps := make(map[string]*portal.Application, len(appIDs))
+	for _, appID := range appIDs {
+		if appID == "" {
+			return nil, portal.NewValidationError("INVALID_APPLICATION_ID", "application ID cannot be empty")
+		}
+
+		if app, exists := ar.liveApplication(appID); exists {
+			apps[appID] = exportApplication(app)
+		}
+	}
+
+	return apps, nil
+}
+
+// BatchCreateApplications creates multiple applications in a single operation
+func (ar *ApplicationRepository) BatchCreateApplications(ctx context.Context, apps []*portal.Application) (err error) {
+	defer ar.repo.metrics.Track(instrument.ApplicationRepository, "BatchCreateApplications")(&err)
+
+	if ar.tx != nil {
+		if err := ar.tx.isWritable(); err != nil {
 			return err
 		}
 	}
 
+	secretHashes, err := ar.hashAPISecrets(apps)
+	if err != nil {
+		return err
+	}
+
 	ar.repo.mu.Lock()
 	defer ar.repo.mu.Unlock()
 
@@ -607,30 +1216,40 @@ func (ar *ApplicationRepository) BatchCreateApplications(ctx context.Context, ap
 
 	// Create all applications
 	now := time.Now()
-	for _, app := range apps {
+	for i, app := range apps {
 		// Set timestamps
 		if app.CreatedAt.IsZero() {
 			app.CreatedAt = now
 		}
 		app.UpdatedAt = now
+		app.Version = 1
 
 		// Create a copy to avoid external modifications
-		appCopy := *app
-		ar.repo.applications[app.ID] = &appCopy
-		ar.repo.addApplicationToIndex(&appCopy)
+		app.Scopes = portal.NormalizeScopes(app.Scopes)
+		appCopy := copyApplication(app)
+		appCopy.APISecret = secretHashes[i]
+		ar.repo.applications[app.ID] = appCopy
+		ar.repo.addApplicationToIndex(appCopy)
 	}
 
 	return nil
 }
 
 // BatchUpdateApplications updates multiple applications in a single operation
-func (ar *ApplicationRepository) BatchUpdateApplications(ctx context.Context, apps []*portal.Application) error {
+func (ar *ApplicationRepository) BatchUpdateApplications(ctx context.Context, apps []*portal.Application) (err error) {
+	defer ar.repo.metrics.Track(instrument.ApplicationRepository, "BatchUpdateApplications")(&err)
+
 	if ar.tx != nil {
-		if err := ar.tx.isActive(); err != nil {
+		if err := ar.tx.isWritable(); err != nil {
 			return err
 		}
 	}
 
+	secretHashes, err := ar.hashAPISecrets(apps)
+	if err != nil {
+		return err
+	}
+
 	ar.repo.mu.Lock()
 	defer ar.repo.mu.Unlock()
 
@@ -649,11 +1268,16 @@ func (ar *ApplicationRepository) BatchUpdateApplications(ctx context.Context, ap
 		}
 
 		// Check if application exists
-		existingApp, exists := ar.repo.applications[app.ID]
+		existingApp, exists := ar.liveApplication(app.ID)
 		if !exists {
 			return portal.NewNotFoundError("APPLICATION_NOT_FOUND", "application with ID "+app.ID+" not found")
 		}
 
+		// Reject updates of an application modified since it was read
+		if app.Version != existingApp.Version {
+			return portal.NewConflictError("STALE_VERSION", "application with ID "+app.ID+" has been modified since it was read")
+		}
+
 		// Check if API key is being changed and if new API key already exists
 		if existingApp.APIKey != app.APIKey {
 			if _, apiKeyExists := ar.repo.appsByAPIKey[app.APIKey]; apiKeyExists {
@@ -669,7 +1293,7 @@ func (ar *ApplicationRepository) BatchUpdateApplications(ctx context.Context, ap
 
 	// Update all applications
 	now := time.Now()
-	for _, app := range apps {
+	for i, app := range apps {
 		existingApp := ar.repo.applications[app.ID]
 
 		// Remove old API key from index if changed
@@ -679,21 +1303,31 @@ func (ar *ApplicationRepository) BatchUpdateApplications(ctx context.Context, ap
 
 		// Update timestamps
 		app.CreatedAt = existingApp.CreatedAt // Preserve original creation time
+		app.LastUsedAt = existingApp.LastUsedAt
+		app.DeletedAt = existingApp.DeletedAt
 		app.UpdatedAt = now
-
-		// Create a copy and update
-		appCopy := *app
-		ar.repo.applications[app.ID] = &appCopy
-		ar.repo.addApplicationToIndex(&appCopy)
+		app.Version = existingApp.Version + 1
+
+		// Create a copy and update, keeping the secret unless a new one is set
+		app.Scopes = portal.NormalizeScopes(app.Scopes)
+		appCopy := copyApplication(app)
+		appCopy.APISecret = secretHashes[i]
+		if secretHashes[i] == "" {
+			appCopy.APISecret = existingApp.APISecret
+		}
+		ar.repo.applications[app.ID] = appCopy
+		ar.repo.addApplicationToIndex(appCopy)
 	}
 
 	return nil
 }
 
 // BatchDeleteApplications deletes multiple applications by IDs
-func (ar *ApplicationRepository) BatchDeleteApplications(ctx context.Context, appIDs []string) error {
+func (ar *ApplicationRepository) BatchDeleteApplications(ctx context.Context, appIDs []string) (err error) {
+	defer ar.repo.metrics.Track(instrument.ApplicationRepository, "BatchDeleteApplications")(&err)
+
 	if ar.tx != nil {
-		if err := ar.tx.isActive(); err != nil {
+		if err := ar.tx.isWritable(); err != nil {
 			return err
 		}
 	}
@@ -715,7 +1349,7 @@ func (ar *ApplicationRepository) BatchDeleteApplications(ctx context.Context, ap
 			return portal.NewValidationError("INVALID_APPLICATION_ID", "application ID cannot be empty")
 		}
 
-		if _, exists := ar.repo.applications[appID]; !exists {
+		if _, exists := ar.liveApplication(appID); !exists {
 			return portal.NewNotFoundError("APPLICATION_NOT_FOUND", "application with ID "+appID+" not found")
 		}
 	}
@@ -725,11 +1359,49 @@ func (ar *ApplicationRepository) BatchDeleteApplications(ctx context.Context, ap
 		app := ar.repo.applications[appID]
 		ar.repo.removeApplicationFromIndex(app)
 		delete(ar.repo.applications, appID)
+		delete(ar.repo.usage, appID)
 	}
 
 	return nil
 }
 
+// liveApplication returns the stored application with the given ID unless it
+// was soft-deleted. The caller must hold the lock.
+func (ar *ApplicationRepository) liveApplication(appID string) (*portal.Application, bool) {
+	app, exists := ar.repo.applications[appID]
+	if !exists || app.DeletedAt != nil {
+		return nil, false
+	}
+	return app, true
+}
+
+// hashAPISecret returns the hash of an application's API secret to store, or
+// "" if it has none
+func (ar *ApplicationRepository) hashAPISecret(app *portal.Application) (string, error) {
+	if app == nil {
+		return "", nil
+	}
+	secretHash, err := ar.repo.secrets.Hash(app.APISecret)
+	if err != nil {
+		return "", portal.NewInternalError("API_SECRET_HASH_FAILED", "failed to hash API secret", err)
+	}
+	return secretHash, nil
+}
+
+// hashAPISecrets returns the hashes of the API secrets of applications to
+// store, in order
+func (ar *ApplicationRepository) hashAPISecrets(apps []*portal.Application) ([]string, error) {
+	hashes := make([]string, len(apps))
+	for i, app := range apps {
+		secretHash, err := ar.hashAPISecret(app)
+		if err != nil {
+			return nil, err
+		}
+		hashes[i] = secretHash
+	}
+	return hashes, nil
+}
+
 // generateAPIKey generates a new API key
 func (ar *ApplicationRepository) generateAPIKey() (string, error) {
 	bytes := make([]byte, 16)
@@ -750,6 +1422,11 @@ func (ar *ApplicationRepository) generateAPISecret() (string, error) {
 
 // matchesApplicationFilter checks if an application matches the given filter criteria
 func (ar *ApplicationRepository) matchesApplicationFilter(app *portal.Application, filter *portal.ApplicationFilter) bool {
+	// Skip soft-deleted applications unless included
+	if app.DeletedAt != nil && !filter.IncludeDeleted {
+		return false
+	}
+
 	// Filter by user ID
 	if filter.UserID != "" && app.UserID != filter.UserID {
 		return false
@@ -765,6 +1442,14 @@ func (ar *ApplicationRepository) matchesApplicationFilter(app *portal.Applicatio
 		return false
 	}
 
+	// Filter by granted scope, scopes are kept sorted
+	if filter.HasScope != "" {
+		i := sort.SearchStrings(app.Scopes, filter.HasScope)
+		if i == len(app.Scopes) || app.Scopes[i] != filter.HasScope {
+			return false
+		}
+	}
+
 	// Filter by search (searches in name and description)
 	if filter.Search != "" {
 		searchLower := strings.ToLower(filter.Search)
@@ -2,8 +2,12 @@ package memory
 
 import (
 	"context"
+	"fmt"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/songzhibin97/stargate/internal/portal/repository/secret"
 	"github.com/songzhibin97/stargate/pkg/portal"
 )
 
@@ -304,6 +308,66 @@ func TestApplicationRepository_UpdateApplication(t *testing.T) {
 	}
 }
 
+func TestApplicationRepository_UpdateApplicationVersion(t *testing.T) {
+	repo := NewRepository()
+	userRepo := NewUserRepository(repo)
+	appRepo := NewApplicationRepository(repo)
+	ctx := context.Background()
+
+	userRepo.CreateUser(ctx, createTestUser("user1", "test@example.com"))
+	appRepo.CreateApplication(ctx, createTestApplication("app1", "user1", "ak_test123"))
+
+	// Test applications are created at version 1
+	first, _ := appRepo.GetApplication(ctx, "app1")
+	second, _ := appRepo.GetApplication(ctx, "app1")
+	if first.Version != 1 {
+		t.Errorf("Expected version 1, got %d", first.Version)
+	}
+
+	// Test the first of two concurrent updates wins
+	first.Name = "First Edit"
+	if err := appRepo.UpdateApplication(ctx, first); err != nil {
+		t.Fatalf("UpdateApplication() returned error: %v", err)
+	}
+	if first.Version != 2 {
+		t.Errorf("Expected the update to advance the version to 2, got %d", first.Version)
+	}
+
+	second.Name = "Second Edit"
+	err := appRepo.UpdateApplication(ctx, second)
+	if !portal.IsConflictError(err) {
+		t.Fatalf("Expected conflict error for a stale version, got: %v", err)
+	}
+	if perr, ok := err.(*portal.PortalError); !ok || perr.Code != "STALE_VERSION" {
+		t.Errorf("Expected STALE_VERSION error, got: %v", err)
+	}
+	if app, _ := appRepo.GetApplication(ctx, "app1"); app.Name != "First Edit" || app.Version != 2 {
+		t.Errorf("Expected the first edit at version 2 kept, got %q at version %d", app.Name, app.Version)
+	}
+
+	// Test other changes advance the version too
+	if err := appRepo.UpdateApplicationStatus(ctx, "app1", portal.ApplicationStatusInactive); err != nil {
+		t.Fatalf("UpdateApplicationStatus() returned error: %v", err)
+	}
+	if err := appRepo.UpdateApplication(ctx, first); !portal.IsConflictError(err) {
+		t.Errorf("Expected conflict error after a status change, got: %v", err)
+	}
+
+	// Test batch updates check versions
+	current, _ := appRepo.GetApplication(ctx, "app1")
+	stale := *current
+	stale.Version--
+	if err := appRepo.BatchUpdateApplications(ctx, []*portal.Application{&stale}); !portal.IsConflictError(err) {
+		t.Errorf("Expected conflict error for a stale batch update, got: %v", err)
+	}
+	if err := appRepo.BatchUpdateApplications(ctx, []*portal.Application{current}); err != nil {
+		t.Errorf("BatchUpdateApplications() returned error: %v", err)
+	}
+	if app, _ := appRepo.GetApplication(ctx, "app1"); app.Version != 4 {
+		t.Errorf("Expected version 4, got %d", app.Version)
+	}
+}
+
 func TestApplicationRepository_DeleteApplication(t *testing.T) {
 	repo := NewRepository()
 	userRepo := NewUserRepository(repo)
@@ -362,6 +426,119 @@ func TestApplicationRepository_DeleteApplication(t *testing.T) {
 	}
 }
 
+func TestApplicationRepository_SoftDeleteApplication(t *testing.T) {
+	repo := NewRepository()
+	userRepo := NewUserRepository(repo)
+	appRepo := NewApplicationRepository(repo)
+	ctx := context.Background()
+
+	userRepo.CreateUser(ctx, createTestUser("user1", "test@example.com"))
+	appRepo.CreateApplication(ctx, createTestApplication("app1", "user1", "ak_test123"))
+	appRepo.CreateApplication(ctx, createTestApplication("app2", "user1", "ak_test456"))
+
+	if err := appRepo.SoftDeleteApplication(ctx, "app1"); err != nil {
+		t.Fatalf("SoftDeleteApplication() returned error: %v", err)
+	}
+
+	// Lookups skip the soft-deleted application
+	if _, err := appRepo.GetApplication(ctx, "app1"); !portal.IsNotFoundError(err) {
+		t.Errorf("Expected not found error, got: %v", err)
+	}
+	if _, err := appRepo.GetApplicationByAPIKey(ctx, "ak_test123"); !portal.IsNotFoundError(err) {
+		t.Errorf("Expected not found error by API key, got: %v", err)
+	}
+	if exists, _ := appRepo.ExistsApplication(ctx, "app1"); exists {
+		t.Error("Soft-deleted application should not exist")
+	}
+	if apps, _ := appRepo.GetApplicationsByUser(ctx, "user1"); len(apps) != 1 || apps[0].ID != "app2" {
+		t.Errorf("Expected only app2 for the user, got %d applications", len(apps))
+	}
+	if err := appRepo.SoftDeleteApplication(ctx, "app1"); !portal.IsNotFoundError(err) {
+		t.Errorf("Expected not found error deleting twice, got: %v", err)
+	}
+
+	// Listings include it only when asked to
+	if count, _ := appRepo.CountApplications(ctx, nil); count != 1 {
+		t.Errorf("Expected 1 application counted, got %d", count)
+	}
+	list, _ := appRepo.ListApplications(ctx, &portal.ApplicationFilter{IncludeDeleted: true, SortBy: "id", SortOrder: "asc"})
+	if list.Total != 2 || list.Applications[0].DeletedAt == nil || list.Applications[1].DeletedAt != nil {
+		t.Errorf("Expected the soft-deleted application listed with its deletion time, got %+v", list.Applications)
+	}
+
+	// Its API key is released for reuse, which blocks restoring it
+	if err := appRepo.CreateApplication(ctx, createTestApplication("app3", "user1", "ak_test123")); err != nil {
+		t.Fatalf("Expected the API key reusable, got: %v", err)
+	}
+	if err := appRepo.RestoreApplication(ctx, "app1"); !portal.IsConflictError(err) {
+		t.Errorf("Expected conflict error restoring with a reused API key, got: %v", err)
+	}
+	appRepo.DeleteApplication(ctx, "app3")
+
+	// Restoring brings it back
+	if err := appRepo.RestoreApplication(ctx, "app1"); err != nil {
+		t.Fatalf("RestoreApplication() returned error: %v", err)
+	}
+	if app, err := appRepo.GetApplicationByAPIKey(ctx, "ak_test123"); err != nil || app.ID != "app1" || app.DeletedAt != nil {
+		t.Errorf("Expected app1 restored, got %+v (%v)", app, err)
+	}
+	if err := appRepo.RestoreApplication(ctx, "app2"); !portal.IsNotFoundError(err) {
+		t.Errorf("Expected not found error restoring an application that isn't deleted, got: %v", err)
+	}
+
+	// Hard deletion purges soft-deleted applications too
+	appRepo.SoftDeleteApplication(ctx, "app1")
+	if err := appRepo.DeleteApplication(ctx, "app1"); err != nil {
+		t.Errorf("DeleteApplication() returned error: %v", err)
+	}
+	if _, exists := repo.applications["app1"]; exists {
+		t.Error("Application should be purged")
+	}
+}
+
+func TestApplicationRepository_BatchGetApplications(t *testing.T) {
+	repo := NewRepository()
+	userRepo := NewUserRepository(repo)
+	appRepo := NewApplicationRepository(repo)
+	ctx := context.Background()
+
+	userRepo.CreateUser(ctx, createTestUser("user1", "test@example.com"))
+	appRepo.CreateApplication(ctx, createTestApplication("app1", "user1", "ak_test1"))
+	appRepo.CreateApplication(ctx, createTestApplication("app2", "user1", "ak_test2"))
+	appRepo.CreateApplication(ctx, createTestApplication("app3", "user1", "ak_test3"))
+	appRepo.SoftDeleteApplication(ctx, "app3")
+
+	// Test missing and soft-deleted applications are left out
+	apps, err := appRepo.BatchGetApplications(ctx, []string{"app1", "app2", "app3", "nonexistent"})
+	if err != nil {
+		t.Fatalf("BatchGetApplications() returned error: %v", err)
+	}
+	if len(apps) != 2 || apps["app1"] == nil || apps["app2"] == nil {
+		t.Fatalf("Expected app1 and app2, got %v", apps)
+	}
+	if apps["app1"].Name != "Test App app1" || apps["app1"].APISecret != "" {
+		t.Errorf("Expected app1 without its secret, got %+v", apps["app1"])
+	}
+
+	// Test returned applications are copies
+	apps["app1"].Name = "Modified"
+	if app, _ := appRepo.GetApplication(ctx, "app1"); app.Name != "Test App app1" {
+		t.Error("Expected the stored application unaffected by changes to the result")
+	}
+
+	// Test no IDs
+	apps, err = appRepo.BatchGetApplications(ctx, nil)
+	if err != nil || len(apps) != 0 {
+		t.Errorf("Expected no applications, got %v (%v)", apps, err)
+	}
+
+	// Test empty ID
+	_, err = appRepo.BatchGetApplications(ctx, []string{"app1", ""})
+	if !portal.IsValidationError(err) {
+		t.Errorf("Expected validation error for empty ID, got: %v", err)
+	}
+}
+
 func TestApplicationRepository_ExistsApplication(t *testing.T) {
 	repo := NewRepository()
 	userRepo := NewUserRepository(repo)
@@ -608,10 +785,15 @@ func TestApplicationRepository_RegenerateAPISecret(t *testing.T) {
 		t.Error("New API secret should be different from old one")
 	}
 
-	// Verify API secret was updated in application
-	updatedApp, _ := appRepo.GetApplication(ctx, "app1")
-	if updatedApp.APISecret != newAPISecret {
-		t.Errorf("Expected API secret %s, got %s", newAPISecret, updatedApp.APISecret)
+	// Verify API secret was updated in application, only stored hashed
+	if ok, _ := appRepo.VerifyAPISecret(ctx, "app1", newAPISecret); !ok {
+		t.Error("Expected the new API secret to verify")
+	}
+	if ok, _ := appRepo.VerifyAPISecret(ctx, "app1", oldAPISecret); ok {
+		t.Error("Expected the old API secret to be rejected")
+	}
+	if stored := repo.applications["app1"].APISecret; stored == newAPISecret || !secret.IsHashed(stored) {
+		t.Errorf("Expected the API secret stored hashed, got %s", stored)
 	}
 
 	// Test non-existent application
@@ -624,6 +806,70 @@ func TestApplicationRepository_RegenerateAPISecret(t *testing.T) {
 	}
 }
 
+func TestApplicationRepository_VerifyAPISecret(t *testing.T) {
+	argon2id, err := secret.NewHasher(secret.AlgorithmArgon2id)
+	if err != nil {
+		t.Fatalf("NewHasher() returned error: %v", err)
+	}
+	repo := NewRepository(WithSecretHasher(argon2id))
+	userRepo := NewUserRepository(repo)
+	appRepo := NewApplicationRepository(repo)
+	ctx := context.Background()
+
+	userRepo.CreateUser(ctx, createTestUser("user1", "test@example.com"))
+	app := createTestApplication("app1", "user1", "ak_test123")
+	if err := appRepo.CreateApplication(ctx, app); err != nil {
+		t.Fatalf("CreateApplication() returned error: %v", err)
+	}
+
+	// Lookups never return the secret
+	storedApp, _ := appRepo.GetApplication(ctx, "app1")
+	if storedApp.APISecret != "" {
+		t.Errorf("Expected no API secret from GetApplication, got %s", storedApp.APISecret)
+	}
+	list, _ := appRepo.ListApplications(ctx, nil)
+	if list.Applications[0].APISecret != "" {
+		t.Errorf("Expected no API secret from ListApplications, got %s", list.Applications[0].APISecret)
+	}
+	if ok, err := appRepo.VerifyAPISecret(ctx, "app1", app.APISecret); !ok || err != nil {
+		t.Errorf("Expected the API secret to verify, got %v (%v)", ok, err)
+	}
+	if ok, _ := appRepo.VerifyAPISecret(ctx, "app1", "as_wrong"); ok {
+		t.Error("Expected a wrong API secret to be rejected")
+	}
+
+	// Updating without a secret keeps it
+	storedApp.Name = "Renamed"
+	if err := appRepo.UpdateApplication(ctx, storedApp); err != nil {
+		t.Fatalf("UpdateApplication() returned error: %v", err)
+	}
+	if ok, _ := appRepo.VerifyAPISecret(ctx, "app1", app.APISecret); !ok {
+		t.Error("Expected the API secret kept by an update without one")
+	}
+
+	// A secret stored in plaintext before hashing verifies and is rehashed
+	repo.applications["app1"].APISecret = "as_legacy"
+	if ok, _ := appRepo.VerifyAPISecret(ctx, "app1", "as_wrong"); ok {
+		t.Error("Expected a wrong API secret to be rejected")
+	}
+	if repo.applications["app1"].APISecret != "as_legacy" {
+		t.Error("Expected a plaintext secret kept until verified")
+	}
+	if ok, _ := appRepo.VerifyAPISecret(ctx, "app1", "as_legacy"); !ok {
+		t.Error("Expected the plaintext API secret to verify")
+	}
+	if stored := repo.applications["app1"].APISecret; !strings.HasPrefix(stored, "$argon2id$") {
+		t.Errorf("Expected the API secret rehashed with argon2id, got %s", stored)
+	}
+	if ok, _ := appRepo.VerifyAPISecret(ctx, "app1", "as_legacy"); !ok {
+		t.Error("Expected the rehashed API secret to verify")
+	}
+
+	if _, err := appRepo.VerifyAPISecret(ctx, "nonexistent", "as_legacy"); !portal.IsNotFoundError(err) {
+		t.Errorf("Expected not found error, got: %v", err)
+	}
+}
+
 func TestApplicationRepository_CountApplicationsByUser(t *testing.T) {
 	repo := NewRepository()
 	userRepo := NewUserRepository(repo)
@@ -679,3 +925,441 @@ func TestApplicationRepository_CountApplicationsByUser(t *testing.T) {
 		t.Errorf("Expected validation error, got: %v", err)
 	}
 }
+
+func TestApplicationRepository_ListApplicationsFilterValidation(t *testing.T) {
+	repo := NewRepository()
+	userRepo := NewUserRepository(repo)
+	appRepo := NewApplicationRepository(repo)
+	ctx := context.Background()
+
+	user := createTestUser("user1", "test@example.com")
+	userRepo.CreateUser(ctx, user)
+	appRepo.CreateApplication(ctx, createTestApplication("app1", "user1", "ak_test123"))
+
+	now := time.Now()
+	earlier := now.Add(-time.Hour)
+
+	// Test inverted created-at range
+	_, err := appRepo.ListApplications(ctx, &portal.ApplicationFilter{CreatedAfter: &now, CreatedBefore: &earlier})
+	if !portal.IsValidationError(err) {
+		t.Errorf("Expected validation error for inverted range, got: %v", err)
+	}
+	_, err = appRepo.CountApplications(ctx, &portal.ApplicationFilter{CreatedAfter: &now, CreatedBefore: &earlier})
+	if !portal.IsValidationError(err) {
+		t.Errorf("Expected validation error for inverted range, got: %v", err)
+	}
+
+	// Test unknown sort field in strict mode
+	_, err = appRepo.ListApplications(ctx, &portal.ApplicationFilter{SortBy: "api_secret", Strict: true})
+	if !portal.IsValidationError(err) {
+		t.Errorf("Expected validation error for unknown sort field, got: %v", err)
+	}
+
+	// Test invalid sort order in strict mode
+	_, err = appRepo.ListApplications(ctx, &portal.ApplicationFilter{SortOrder: "sideways", Strict: true})
+	if !portal.IsValidationError(err) {
+		t.Errorf("Expected validation error for invalid sort order, got: %v", err)
+	}
+
+	// Test lenient mode still coerces invalid sort criteria
+	result, err := appRepo.ListApplications(ctx, &portal.ApplicationFilter{SortBy: "api_secret", SortOrder: "sideways"})
+	if err != nil {
+		t.Errorf("ListApplications() returned error in lenient mode: %v", err)
+	}
+	if result == nil || result.Total != 1 {
+		t.Errorf("Expected 1 application in lenient mode, got: %+v", result)
+	}
+
+	// Test valid strict filter
+	_, err = appRepo.ListApplications(ctx, &portal.ApplicationFilter{SortBy: "name", SortOrder: "asc", CreatedAfter: &earlier, CreatedBefore: &now, Strict: true})
+	if err != nil {
+		t.Errorf("ListApplications() returned error for valid strict filter: %v", err)
+	}
+}
+
+func TestApplicationRepository_ListApplicationsCursor(t *testing.T) {
+	repo := NewRepository()
+	userRepo := NewUserRepository(repo)
+	appRepo := NewApplicationRepository(repo)
+	ctx := context.Background()
+
+	userRepo.CreateUser(ctx, createTestUser("user1", "test@example.com"))
+
+	// app2 and app3 share a creation time, which their IDs order
+	base := time.Now().Add(-time.Hour)
+	createdAt := map[string]time.Time{
+		"app1": base,
+		"app2": base.Add(time.Minute),
+		"app3": base.Add(time.Minute),
+		"app4": base.Add(2 * time.Minute),
+		"app5": base.Add(3 * time.Minute),
+	}
+	for _, id := range []string{"app3", "app1", "app5", "app2", "app4"} {
+		app := createTestApplication(id, "user1", "ak_"+id)
+		app.CreatedAt = createdAt[id]
+		if err := appRepo.CreateApplication(ctx, app); err != nil {
+			t.Fatalf("CreateApplication() returned error: %v", err)
+		}
+	}
+
+	listAll := func(filter *portal.ApplicationFilter) []string {
+		var ids []string
+		for page := 0; ; page++ {
+			result, err := appRepo.ListApplications(ctx, filter)
+			if err != nil {
+				t.Fatalf("ListApplications() returned error: %v", err)
+			}
+			if result.Limit != 2 {
+				t.Errorf("Expected limit 2, got %d", result.Limit)
+			}
+			for _, app := range result.Applications {
+				ids = append(ids, app.ID)
+			}
+			if result.HasMore != (result.NextCursor != "") {
+				t.Errorf("Expected has_more to match the next cursor, got %v and %q", result.HasMore, result.NextCursor)
+			}
+			if result.NextCursor == "" {
+				return ids
+			}
+			if page == 0 {
+				// Applications created while paging don't shift the pages
+				newer := createTestApplication("app6", "user1", "ak_app6")
+				if err := appRepo.CreateApplication(ctx, newer); err != nil {
+					t.Fatalf("CreateApplication() returned error: %v", err)
+				}
+				defer appRepo.DeleteApplication(ctx, "app6")
+			}
+			filter.Cursor = result.NextCursor
+		}
+	}
+
+	// Test pages in descending order by default
+	ids := listAll(&portal.ApplicationFilter{PageSize: 2})
+	if strings.Join(ids, ",") != "app5,app4,app3,app2,app1" {
+		t.Errorf("Expected app5,app4,app3,app2,app1, got %v", ids)
+	}
+
+	// Test pages in ascending order
+	ids = listAll(&portal.ApplicationFilter{PageSize: 2, SortOrder: "asc"})
+	if strings.Join(ids, ",") != "app1,app2,app3,app4,app5,app6" {
+		t.Errorf("Expected app1,app2,app3,app4,app5,app6, got %v", ids)
+	}
+
+	// Test offset pagination still applies without a cursor or page size
+	result, err := appRepo.ListApplications(ctx, &portal.ApplicationFilter{Offset: 4, Limit: 2})
+	if err != nil {
+		t.Fatalf("ListApplications() returned error: %v", err)
+	}
+	if len(result.Applications) != 1 || result.NextCursor != "" {
+		t.Errorf("Expected the last application without a cursor, got %+v", result)
+	}
+
+	// Test malformed cursor
+	_, err = appRepo.ListApplications(ctx, &portal.ApplicationFilter{Cursor: "not-a-cursor"})
+	if !portal.IsValidationError(err) {
+		t.Errorf("Expected validation error for malformed cursor, got: %v", err)
+	}
+
+	// Test other sort fields in strict mode
+	_, err = appRepo.ListApplications(ctx, &portal.ApplicationFilter{PageSize: 2, SortBy: "name", Strict: true})
+	if !portal.IsValidationError(err) {
+		t.Errorf("Expected validation error for cursor sorted by name, got: %v", err)
+	}
+}
+
+func TestApplicationRepository_Scopes(t *testing.T) {
+	repo := NewRepository(WithAllowedScopes([]string{"orders:read", "orders:write", "billing:read"}))
+	userRepo := NewUserRepository(repo)
+	appRepo := NewApplicationRepository(repo)
+	ctx := context.Background()
+
+	userRepo.CreateUser(ctx, createTestUser("user1", "test@example.com"))
+
+	// Test scopes are validated and normalized on create
+	app := createTestApplication("app1", "user1", "ak_test123")
+	app.Scopes = []string{"orders:write", "admin"}
+	if err := appRepo.CreateApplication(ctx, app); !portal.IsValidationError(err) {
+		t.Errorf("Expected validation error for a scope not allowed, got: %v", err)
+	}
+	app.Scopes = []string{"orders:write", "orders:read", "orders:write"}
+	if err := appRepo.CreateApplication(ctx, app); err != nil {
+		t.Fatalf("CreateApplication() returned error: %v", err)
+	}
+
+	scopes, err := appRepo.GetApplicationScopes(ctx, "app1")
+	if err != nil || len(scopes) != 2 || scopes[0] != "orders:read" || scopes[1] != "orders:write" {
+		t.Errorf("Expected sorted scopes without duplicates, got %v (%v)", scopes, err)
+	}
+
+	// Test returned scopes don't alias the stored ones
+	scopes[0] = "billing:read"
+	if stored, _ := appRepo.GetApplication(ctx, "app1"); stored.Scopes[0] != "orders:read" {
+		t.Errorf("Expected stored scopes to be unaffected, got %v", stored.Scopes)
+	}
+
+	// Test add, remove and set
+	if err := appRepo.AddApplicationScopes(ctx, "app1", []string{"billing:read", "orders:read"}); err != nil {
+		t.Errorf("AddApplicationScopes() returned error: %v", err)
+	}
+	if err := appRepo.RemoveApplicationScopes(ctx, "app1", []string{"orders:write", "unknown"}); err != nil {
+		t.Errorf("RemoveApplicationScopes() returned error: %v", err)
+	}
+	scopes, _ = appRepo.GetApplicationScopes(ctx, "app1")
+	if len(scopes) != 2 || scopes[0] != "billing:read" || scopes[1] != "orders:read" {
+		t.Errorf("Expected [billing:read orders:read], got %v", scopes)
+	}
+
+	// Test filtering by granted scope
+	other := createTestApplication("app2", "user1", "ak_test456")
+	other.Scopes = []string{"orders:write"}
+	if err := appRepo.CreateApplication(ctx, other); err != nil {
+		t.Fatalf("CreateApplication() returned error: %v", err)
+	}
+	for scope, want := range map[string]string{"billing:read": "app1", "orders:write": "app2", "orders": ""} {
+		result, err := appRepo.ListApplications(ctx, &portal.ApplicationFilter{HasScope: scope})
+		if err != nil {
+			t.Fatalf("ListApplications() returned error: %v", err)
+		}
+		var got string
+		for _, app := range result.Applications {
+			got += app.ID
+		}
+		if got != want {
+			t.Errorf("Expected applications granted %s to be %q, got %q", scope, want, got)
+		}
+	}
+
+	if err := appRepo.AddApplicationScopes(ctx, "app1", []string{"orders delete"}); !portal.IsValidationError(err) {
+		t.Errorf("Expected validation error for a malformed scope, got: %v", err)
+	}
+	if err := appRepo.SetApplicationScopes(ctx, "app1", []string{"admin"}); !portal.IsValidationError(err) {
+		t.Errorf("Expected validation error for a scope not allowed, got: %v", err)
+	}
+	if err := appRepo.SetApplicationScopes(ctx, "app1", nil); err != nil {
+		t.Errorf("SetApplicationScopes() returned error: %v", err)
+	}
+	if scopes, _ := appRepo.GetApplicationScopes(ctx, "app1"); scopes == nil || len(scopes) != 0 {
+		t.Errorf("Expected no scopes, got %v", scopes)
+	}
+
+	// Test non-existent application
+	if err := appRepo.AddApplicationScopes(ctx, "nonexistent", []string{"orders:read"}); !portal.IsNotFoundError(err) {
+		t.Errorf("Expected not found error, got: %v", err)
+	}
+}
+
+func TestApplicationRepository_DormantApplications(t *testing.T) {
+	repo := NewRepository()
+	userRepo := NewUserRepository(repo)
+	appRepo := NewApplicationRepository(repo)
+	ctx := context.Background()
+
+	userRepo.CreateUser(ctx, createTestUser("user1", "test@example.com"))
+
+	now := time.Now()
+	for i, createdAt := range []time.Time{now.Add(-72 * time.Hour), now.Add(-48 * time.Hour), now.Add(-96 * time.Hour), now} {
+		app := createTestApplication(fmt.Sprintf("app%d", i+1), "user1", fmt.Sprintf("ak_test%d", i+1))
+		app.CreatedAt = createdAt
+		if err := appRepo.CreateApplication(ctx, app); err != nil {
+			t.Fatalf("CreateApplication() returned error: %v", err)
+		}
+	}
+	appRepo.UpdateApplicationStatus(ctx, "app3", portal.ApplicationStatusInactive)
+
+	// Test last use only moves forward and unknown applications are ignored
+	err := appRepo.RecordApplicationsLastUsed(ctx, map[string]time.Time{"app2": now.Add(-time.Hour), "unknown": now})
+	if err != nil {
+		t.Fatalf("RecordApplicationsLastUsed() returned error: %v", err)
+	}
+	appRepo.RecordApplicationsLastUsed(ctx, map[string]time.Time{"app2": now.Add(-2 * time.Hour)})
+	if app, _ := appRepo.GetApplication(ctx, "app2"); app.LastUsedAt == nil || !app.LastUsedAt.Equal(now.Add(-time.Hour)) {
+		t.Errorf("Expected last use %v, got %v", now.Add(-time.Hour), app.LastUsedAt)
+	}
+
+	// Test updates preserve the last use
+	app, _ := appRepo.GetApplication(ctx, "app2")
+	app.LastUsedAt = nil
+	appRepo.UpdateApplication(ctx, app)
+	if app, _ := appRepo.GetApplication(ctx, "app2"); app.LastUsedAt == nil {
+		t.Error("Expected UpdateApplication to preserve the last use")
+	}
+
+	// Only app1 is active and unused for a day; app2 was used and app3 is inactive
+	dormant, err := appRepo.ListDormantApplications(ctx, now.Add(-24*time.Hour))
+	if err != nil {
+		t.Fatalf("ListDormantApplications() returned error: %v", err)
+	}
+	if len(dormant) != 1 || dormant[0].ID != "app1" {
+		t.Errorf("Expected [app1] dormant, got %d applications", len(dormant))
+	}
+
+	dormant, _ = appRepo.ListDormantApplications(ctx, now.Add(time.Minute))
+	if len(dormant) != 3 || dormant[0].ID != "app1" || dormant[1].ID != "app2" || dormant[2].ID != "app4" {
+		t.Errorf("Expected app1, app2 and app4 dormant least recently active first, got %d applications", len(dormant))
+	}
+
+	suspended, err := appRepo.SuspendDormantApplications(ctx, now.Add(-24*time.Hour))
+	if err != nil {
+		t.Fatalf("SuspendDormantApplications() returned error: %v", err)
+	}
+	if len(suspended) != 1 || suspended[0] != "app1" {
+		t.Errorf("Expected [app1] suspended, got %v", suspended)
+	}
+	if app, _ := appRepo.GetApplication(ctx, "app1"); app.Status != portal.ApplicationStatusSuspended {
+		t.Errorf("Expected app1 to be suspended, got %s", app.Status)
+	}
+	if dormant, _ := appRepo.ListDormantApplications(ctx, now.Add(-24*time.Hour)); len(dormant) != 0 {
+		t.Errorf("Expected no dormant applications after suspending, got %d", len(dormant))
+	}
+}
+
+func TestApplicationRepository_TransferApplications(t *testing.T) {
+	var events []portal.ApplicationEvent
+	repo := NewRepository(WithApplicationEvents(func(event portal.ApplicationEvent) {
+		events = append(events, event)
+	}))
+	userRepo := NewUserRepository(repo)
+	appRepo := NewApplicationRepository(repo)
+	ctx := context.Background()
+
+	userRepo.CreateUser(ctx, createTestUser("user1", "user1@example.com"))
+	userRepo.CreateUser(ctx, createTestUser("user2", "user2@example.com"))
+	userRepo.CreateUser(ctx, createTestUser("team", "team@example.com"))
+	appRepo.CreateApplication(ctx, createTestApplication("app1", "user1", "ak_test1"))
+	appRepo.CreateApplication(ctx, createTestApplication("app2", "user1", "ak_test2"))
+	appRepo.CreateApplication(ctx, createTestApplication("app3", "user1", "ak_test3"))
+	appRepo.CreateApplication(ctx, createTestApplication("app4", "user2", "ak_test4"))
+
+	owners := func() string {
+		var owners []string
+		for _, appID := range []string{"app1", "app2", "app3", "app4"} {
+			app, _ := appRepo.GetApplication(ctx, appID)
+			owners = append(owners, app.UserID)
+		}
+		return strings.Join(owners, ",")
+	}
+
+	// Test failures transfer nothing
+	if err := appRepo.TransferApplications(ctx, "user1", "nobody", nil); !portal.IsNotFoundError(err) {
+		t.Errorf("Expected not found error for an unknown target user, got: %v", err)
+	}
+	if err := appRepo.TransferApplications(ctx, "user1", "team", []string{"app1", "app4"}); !portal.IsNotFoundError(err) {
+		t.Errorf("Expected not found error for another user's application, got: %v", err)
+	}
+	if err := appRepo.TransferApplications(ctx, "user1", "team", []string{"app1", "missing"}); !portal.IsNotFoundError(err) {
+		t.Errorf("Expected not found error for an unknown application, got: %v", err)
+	}
+	if err := appRepo.TransferApplications(ctx, "user1", "user1", nil); !portal.IsValidationError(err) {
+		t.Errorf("Expected validation error for a transfer to the owner, got: %v", err)
+	}
+	if got := owners(); got != "user1,user1,user1,user2" || len(events) != 0 {
+		t.Fatalf("Expected failed transfers to change nothing, got owners %s and %d events", got, len(events))
+	}
+
+	// Test transferring selected applications
+	if err := appRepo.TransferApplications(ctx, "user1", "team", []string{"app2"}); err != nil {
+		t.Fatalf("TransferApplications() returned error: %v", err)
+	}
+	if got := owners(); got != "user1,team,user1,user2" {
+		t.Errorf("Expected app2 transferred, got owners %s", got)
+	}
+	if len(events) != 1 || events[0].Type != portal.ApplicationEventTransferred || events[0].ApplicationID != "app2" ||
+		events[0].UserID != "team" || events[0].PreviousUserID != "user1" {
+		t.Errorf("Expected a transferred event for app2, got %+v", events)
+	}
+
+	// Test transferring all remaining applications in a transaction reports
+	// events on commit
+	events = nil
+	tx, _ := repo.BeginTx(ctx)
+	if err := tx.ApplicationRepository().TransferApplications(ctx, "user1", "team", nil); err != nil {
+		t.Fatalf("TransferApplications() returned error: %v", err)
+	}
+	if len(events) != 0 {
+		t.Errorf("Expected no events before commit, got %d", len(events))
+	}
+	tx.Commit(ctx)
+	if got := owners(); got != "team,team,team,user2" {
+		t.Errorf("Expected all of user1's applications transferred, got owners %s", got)
+	}
+	if len(events) != 2 {
+		t.Errorf("Expected 2 transferred events on commit, got %d", len(events))
+	}
+	if count, _ := appRepo.CountApplicationsByUser(ctx, "team"); count != 3 {
+		t.Errorf("Expected team to own 3 applications, got %d", count)
+	}
+	if apps, _ := appRepo.GetApplicationsByUser(ctx, "user1"); len(apps) != 0 {
+		t.Errorf("Expected user1 to own no applications, got %d", len(apps))
+	}
+}
+
+func TestApplicationRepository_TransferApplication(t *testing.T) {
+	var events []portal.ApplicationEvent
+	repo := NewRepository(WithApplicationEvents(func(event portal.ApplicationEvent) {
+		events = append(events, event)
+	}))
+	userRepo := NewUserRepository(repo)
+	appRepo := NewApplicationRepository(repo)
+	ctx := context.Background()
+
+	userRepo.CreateUser(ctx, createTestUser("user1", "user1@example.com"))
+	userRepo.CreateUser(ctx, createTestUser("user2", "user2@example.com"))
+	appRepo.CreateApplication(ctx, createTestApplication("app1", "user1", "ak_test1"))
+	appRepo.CreateApplication(ctx, createTestApplication("app2", "user1", "ak_test2"))
+
+	// Test failures transfer nothing
+	if err := appRepo.TransferApplication(ctx, "missing", "user2"); !portal.IsNotFoundError(err) {
+		t.Errorf("Expected not found error for an unknown application, got: %v", err)
+	}
+	if err := appRepo.TransferApplication(ctx, "app1", "nobody"); !portal.IsNotFoundError(err) {
+		t.Errorf("Expected not found error for an unknown target user, got: %v", err)
+	}
+	if err := appRepo.TransferApplication(ctx, "app1", "user1"); !portal.IsValidationError(err) {
+		t.Errorf("Expected validation error for a transfer to the owner, got: %v", err)
+	}
+	if len(events) != 0 {
+		t.Fatalf("Expected failed transfers to report nothing, got %d events", len(events))
+	}
+
+	// Test the application moves between the users' indexes
+	before, _ := appRepo.GetApplication(ctx, "app1")
+	if err := appRepo.TransferApplication(ctx, "app1", "user2"); err != nil {
+		t.Fatalf("TransferApplication() returned error: %v", err)
+	}
+	app, _ := appRepo.GetApplication(ctx, "app1")
+	if app.UserID != "user2" || !app.UpdatedAt.After(before.UpdatedAt) || app.Version != before.Version+1 {
+		t.Errorf("Expected app1 owned by user2 with a new update time and version, got %+v", app)
+	}
+	if apps, _ := appRepo.GetApplicationsByUser(ctx, "user1"); len(apps) != 1 || apps[0].ID != "app2" {
+		t.Errorf("Expected user1 to own only app2, got %v", apps)
+	}
+	if apps, _ := appRepo.GetApplicationsByUser(ctx, "user2"); len(apps) != 1 || apps[0].ID != "app1" {
+		t.Errorf("Expected user2 to own only app1, got %v", apps)
+	}
+	if len(events) != 1 || events[0].Type != portal.ApplicationEventTransferred || events[0].ApplicationID != "app1" ||
+		events[0].UserID != "user2" || events[0].PreviousUserID != "user1" {
+		t.Errorf("Expected a transferred event for app1, got %+v", events)
+	}
+
+	// Test a transfer in a transaction reports its event on commit
+	events = nil
+	tx, _ := repo.BeginTx(ctx)
+	if err := tx.ApplicationRepository().TransferApplication(ctx, "app2", "user2"); err != nil {
+		t.Fatalf("TransferApplication() returned error: %v", err)
+	}
+	if len(events) != 0 {
+		t.Errorf("Expected no events before commit, got %d", len(events))
+	}
+	tx.Commit(ctx)
+	if len(events) != 1 || events[0].ApplicationID != "app2" {
+		t.Errorf("Expected a transferred event for app2 on commit, got %+v", events)
+	}
+	if count, _ := appRepo.CountApplicationsByUser(ctx, "user2"); count != 2 {
+		t.Errorf("Expected user2 to own 2 applications, got %d", count)
+	}
+
+	// Test a committed transaction can't be used for transfers
+	if err := tx.ApplicationRepository().TransferApplication(ctx, "app2", "user1"); err == nil {
+		t.Error("Expected error transferring in a committed transaction")
+	}
+}
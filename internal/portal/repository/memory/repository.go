@@ -2,9 +2,12 @@ package memory
 
 import (
 	"context"
+	"sort"
 	"sync"
 	"time"
 
+	"github.com/songzhibin97/stargate/internal/portal/repository/instrument"
+	"github.com/songzhibin97/stargate/internal/portal/repository/secret"
 	"github.com/songzhibin97/stargate/pkg/portal"
 )
 
@@ -16,18 +19,61 @@ type Repository struct {
 	usersByEmail map[string]*portal.User
 	appsByAPIKey map[string]*portal.Application
 	appsByUser   map[string][]*portal.Application
+	usage        map[string]*applicationUsage
 	closed       bool
+	metrics      *instrument.Recorder
+	scopes       []string // Scopes applications may be granted, any when empty
+	events       portal.ApplicationEventHandler
+	secrets      *secret.Hasher // Hashes API secrets at rest
+}
+
+// Option configures an in-memory repository
+type Option func(*Repository)
+
+// WithMetrics enables per-method call, latency and error instrumentation
+func WithMetrics(recorder *instrument.Recorder) Option {
+	return func(r *Repository) {
+		r.metrics = recorder
+	}
+}
+
+// WithAllowedScopes restricts the scopes applications may be granted
+func WithAllowedScopes(scopes []string) Option {
+	return func(r *Repository) {
+		r.scopes = scopes
+	}
+}
+
+// WithApplicationEvents reports committed application changes to the handler
+func WithApplicationEvents(handler portal.ApplicationEventHandler) Option {
+	return func(r *Repository) {
+		r.events = handler
+	}
+}
+
+// WithSecretHasher hashes API secrets at rest with the hasher instead of
+// bcrypt
+func WithSecretHasher(hasher *secret.Hasher) Option {
+	return func(r *Repository) {
+		r.secrets = hasher
+	}
 }
 
 // NewRepository creates a new in-memory repository
-func NewRepository() *Repository {
-	return &Repository{
+func NewRepository(opts ...Option) *Repository {
+	r := &Repository{
 		users:        make(map[string]*portal.User),
 		applications: make(map[string]*portal.Application),
 		usersByEmail: make(map[string]*portal.User),
 		appsByAPIKey: make(map[string]*portal.Application),
 		appsByUser:   make(map[string][]*portal.Application),
+		usage:        make(map[string]*applicationUsage),
+		secrets:      secret.Default(),
 	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
 }
 
 // Health returns the health status of the repository
@@ -56,6 +102,27 @@ func (r *Repository) Health(ctx context.Context) portal.HealthStatus {
 	}
 }
 
+// Stats returns the size of the repository, which has no connections
+func (r *Repository) Stats(ctx context.Context) (*portal.RepositoryStats, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.closed {
+		return nil, portal.NewDatabaseError("REPO_CLOSED", "repository is closed", nil)
+	}
+
+	stats := &portal.RepositoryStats{
+		Users:     int64(len(r.users)),
+		Timestamp: time.Now(),
+	}
+	for _, app := range r.applications {
+		if app.DeletedAt == nil {
+			stats.Applications++
+		}
+	}
+	return stats, nil
+}
+
 // Close closes the repository connection and releases resources
 func (r *Repository) Close() error {
 	r.mu.Lock()
@@ -71,13 +138,21 @@ func (r *Repository) Close() error {
 	r.usersByEmail = nil
 	r.appsByAPIKey = nil
 	r.appsByUser = nil
+	r.usage = nil
 	r.closed = true
 
 	return nil
 }
 
-// BeginTx begins a transaction
+// BeginTx begins a transaction with the default options
 func (r *Repository) BeginTx(ctx context.Context) (portal.Transaction, error) {
+	return r.BeginTxWithOptions(ctx, nil)
+}
+
+// BeginTxWithOptions begins a transaction with the options, the default
+// options if nil. The isolation level is ignored, as changes are applied
+// immediately.
+func (r *Repository) BeginTxWithOptions(ctx context.Context, opts *portal.TxOptions) (portal.Transaction, error) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
@@ -85,7 +160,9 @@ func (r *Repository) BeginTx(ctx context.Context) (portal.Transaction, error) {
 		return nil, portal.NewDatabaseError("REPO_CLOSED", "repository is closed", nil)
 	}
 
-	return NewTransaction(r), nil
+	tx := NewTransaction(r)
+	tx.readOnly = opts != nil && opts.ReadOnly
+	return tx, nil
 }
 
 // isValidUser validates user data
@@ -131,17 +208,53 @@ func (r *Repository) isValidApplication(app *portal.Application) error {
 	if app.Status == "" {
 		return portal.NewValidationError("INVALID_APPLICATION_STATUS", "application status cannot be empty")
 	}
-	return nil
+	return portal.ValidateScopes(app.Scopes, r.scopes)
+}
+
+// copyApplication returns a copy of the application sharing no memory with it
+func copyApplication(app *portal.Application) *portal.Application {
+	appCopy := *app
+	appCopy.Scopes = make([]string, len(app.Scopes))
+	copy(appCopy.Scopes, app.Scopes)
+	if app.LastUsedAt != nil {
+		lastUsedAt := *app.LastUsedAt
+		appCopy.LastUsedAt = &lastUsedAt
+	}
+	if app.DeletedAt != nil {
+		deletedAt := *app.DeletedAt
+		appCopy.DeletedAt = &deletedAt
+	}
+	return &appCopy
+}
+
+// exportApplication returns a copy of a stored application for callers,
+// without the hash of its API secret
+func exportApplication(app *portal.Application) *portal.Application {
+	appCopy := copyApplication(app)
+	appCopy.APISecret = ""
+	return appCopy
+}
+
+// publishEvents reports application changes to the event handler, if any.
+// It must be called without holding the lock.
+func (r *Repository) publishEvents(events []portal.ApplicationEvent) {
+	if r.events == nil {
+		return
+	}
+	for _, event := range events {
+		r.events(event)
+	}
 }
 
-// addUserToIndex adds user to internal indexes
+// addUserToIndex adds user to internal indexes. Users are indexed by
+// normalized email so that lookups ignore case.
 func (r *Repository) addUserToIndex(user *portal.User) {
-	r.usersByEmail[user.Email] = user
+	r.usersByEmail[portal.NormalizeEmail(user.Email)] = user
 }
 
 // removeUserFromIndex removes user from internal indexes
 func (r *Repository) removeUserFromIndex(user *portal.User) {
-	delete(r.usersByEmail, user.Email)
+	delete(r.usersByEmail, portal.NormalizeEmail(user.Email))
 }
 
 // addApplicationToIndex adds application to internal indexes
@@ -168,3 +281,40 @@ func (r *Repository) removeApplicationFromIndex(app *portal.Application) {
 		}
 	}
 }
+
+// cursorPage orders rows by created_at and then id, in sortOrder, and returns
+// the page of up to pageSize rows after the cursor, or from the first row
+// when the cursor is empty, along with the cursor of the next page, which is
+// empty when no more rows follow
+func cursorPage[T any](rows []T, key func(T) (time.Time, string), cursor string, pageSize int, sortOrder string) ([]T, string, error) {
+	desc := sortOrder != "asc"
+	sort.Slice(rows, func(i, j int) bool {
+		createdAt, id := key(rows[j])
+		c := portal.Cursor{CreatedAt: createdAt, ID: id}
+		if desc {
+			return c.After(key(rows[i]))
+		}
+		return c.Before(key(rows[i]))
+	})
+
+	start := 0
+	if cursor != "" {
+		c, err := portal.DecodeCursor(cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		start = sort.Search(len(rows), func(i int) bool {
+			if desc {
+				return c.Before(key(rows[i]))
+			}
+			return c.After(key(rows[i]))
+		})
+	}
+	rows = rows[start:]
+
+	if len(rows) <= pageSize {
+		return rows, "", nil
+	}
+	rows = rows[:pageSize]
+	return rows, portal.EncodeCursor(key(rows[pageSize-1])), nil
+}
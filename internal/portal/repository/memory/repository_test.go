@@ -2,9 +2,13 @@ package memory
 
 import (
 	"context"
+	"database/sql"
 	"testing"
 	"time"
 
+	"github.com/songzhibin97/stargate/internal/metrics/driver/prometheus"
+	"github.com/songzhibin97/stargate/internal/portal/repository/instrument"
+	"github.com/songzhibin97/stargate/pkg/metrics"
 	"github.com/songzhibin97/stargate/pkg/portal"
 )
 
@@ -52,6 +56,37 @@ func TestRepository_Health(t *testing.T) {
 	}
 }
 
+func TestRepository_Stats(t *testing.T) {
+	repo := NewRepository()
+	userRepo := NewUserRepository(repo)
+	appRepo := NewApplicationRepository(repo)
+	ctx := context.Background()
+
+	userRepo.CreateUser(ctx, createTestUser("user1", "user1@example.com"))
+	userRepo.CreateUser(ctx, createTestUser("user2", "user2@example.com"))
+	appRepo.CreateApplication(ctx, createTestApplication("app1", "user1", "ak_test1"))
+	appRepo.CreateApplication(ctx, createTestApplication("app2", "user1", "ak_test2"))
+	appRepo.SoftDeleteApplication(ctx, "app2")
+
+	// Test sizes, leaving out soft-deleted applications, and no connections
+	stats, err := repo.Stats(ctx)
+	if err != nil {
+		t.Fatalf("Stats() returned error: %v", err)
+	}
+	if stats.Users != 2 || stats.Applications != 1 {
+		t.Errorf("Expected 2 users and 1 application, got %d and %d", stats.Users, stats.Applications)
+	}
+	if stats.OpenConnections != 0 || stats.MaxOpenConnections != 0 || stats.Timestamp.IsZero() {
+		t.Errorf("Expected no connections and a timestamp, got %+v", stats)
+	}
+
+	// Test closed repository
+	repo.Close()
+	if _, err := repo.Stats(ctx); !portal.IsDatabaseError(err) {
+		t.Errorf("Expected database error for closed repo, got: %v", err)
+	}
+}
+
 func TestRepository_Close(t *testing.T) {
 	repo := NewRepository()
 
@@ -107,6 +142,69 @@ func TestRepository_BeginTx(t *testing.T) {
 	}
 }
 
+func TestRepository_BeginTxWithOptions(t *testing.T) {
+	repo := NewRepository()
+	ctx := context.Background()
+
+	repo.users["user1"] = createTestUser("user1", "test@example.com")
+	repo.addUserToIndex(repo.users["user1"])
+
+	// Test reads are allowed in a read-only transaction
+	tx, err := repo.BeginTxWithOptions(ctx, &portal.TxOptions{Isolation: sql.LevelSerializable, ReadOnly: true})
+	if err != nil {
+		t.Fatalf("BeginTxWithOptions() returned error: %v", err)
+	}
+	if _, err := tx.UserRepository().GetUser(ctx, "user1"); err != nil {
+		t.Errorf("GetUser() in read-only transaction returned error: %v", err)
+	}
+	if _, err := tx.ApplicationRepository().ListApplications(ctx, nil); err != nil {
+		t.Errorf("ListApplications() in read-only transaction returned error: %v", err)
+	}
+
+	// Test writes are rejected in a read-only transaction
+	err = tx.UserRepository().CreateUser(ctx, createTestUser("user2", "test2@example.com"))
+	if !portal.IsValidationError(err) {
+		t.Errorf("Expected validation error for write in read-only transaction, got: %v", err)
+	}
+	err = tx.UserRepository().UpdateUserRole(ctx, "user1", portal.UserRoleAdmin)
+	if !portal.IsValidationError(err) {
+		t.Errorf("Expected validation error for write in read-only transaction, got: %v", err)
+	}
+	err = tx.ApplicationRepository().CreateApplication(ctx, createTestApplication("app1", "user1", "key1"))
+	if !portal.IsValidationError(err) {
+		t.Errorf("Expected validation error for write in read-only transaction, got: %v", err)
+	}
+	if _, exists := repo.users["user2"]; exists {
+		t.Error("User should not be created in read-only transaction")
+	}
+	if repo.users["user1"].Role == portal.UserRoleAdmin {
+		t.Error("User should not be updated in read-only transaction")
+	}
+	if err := tx.Commit(ctx); err != nil {
+		t.Errorf("Commit() returned error: %v", err)
+	}
+
+	// Test writes are allowed without read-only
+	tx, err = repo.BeginTxWithOptions(ctx, &portal.TxOptions{Isolation: sql.LevelSerializable})
+	if err != nil {
+		t.Fatalf("BeginTxWithOptions() returned error: %v", err)
+	}
+	if err := tx.UserRepository().UpdateUserRole(ctx, "user1", portal.UserRoleAdmin); err != nil {
+		t.Errorf("UpdateUserRole() returned error: %v", err)
+	}
+	tx.Commit(ctx)
+
+	// Test nil options are the defaults
+	tx, err = repo.BeginTxWithOptions(ctx, nil)
+	if err != nil {
+		t.Fatalf("BeginTxWithOptions() returned error: %v", err)
+	}
+	if err := tx.UserRepository().UpdateUserRole(ctx, "user1", portal.UserRoleViewer); err != nil {
+		t.Errorf("UpdateUserRole() returned error: %v", err)
+	}
+	tx.Commit(ctx)
+}
+
 func TestRepository_Validation(t *testing.T) {
 	repo := NewRepository()
 
@@ -235,6 +333,65 @@ func TestRepository_IndexManagement(t *testing.T) {
 	}
 }
 
+func TestRepository_Metrics(t *testing.T) {
+	provider, err := prometheus.NewProvider(prometheus.Options{})
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+	recorder, err := instrument.NewRecorder(provider)
+	if err != nil {
+		t.Fatalf("Failed to create recorder: %v", err)
+	}
+
+	repo := NewRepository(WithMetrics(recorder))
+	userRepo := NewUserRepository(repo)
+	appRepo := NewApplicationRepository(repo)
+	ctx := context.Background()
+
+	userRepo.CreateUser(ctx, createTestUser("user1", "test@example.com"))
+	userRepo.CreateUser(ctx, createTestUser("user1", "test@example.com"))
+	appRepo.GetApplication(ctx, "missing")
+	appRepo.GetApplication(ctx, "")
+
+	families, err := provider.Gather()
+	if err != nil {
+		t.Fatalf("Failed to gather metrics: %v", err)
+	}
+
+	values := make(map[string]float64)
+	for _, family := range families {
+		for _, m := range family.Metrics {
+			labels := metrics.LabelPairsToLabels(m.Labels)
+			key := family.Name + "/" + labels["repository"] + "/" + labels["method"] + "/" + labels["error_type"]
+			if family.Type == metrics.HistogramType {
+				values[key] = float64(m.Count)
+			} else {
+				values[key] = m.Value
+			}
+		}
+	}
+
+	expected := map[string]float64{
+		"portal_repository_calls_total/user/CreateUser/":                       2,
+		"portal_repository_call_duration_seconds/user/CreateUser/":             2,
+		"portal_repository_errors_total/user/CreateUser/conflict":              1,
+		"portal_repository_calls_total/application/GetApplication/":            2,
+		"portal_repository_errors_total/application/GetApplication/notfound":   1,
+		"portal_repository_errors_total/application/GetApplication/validation": 1,
+	}
+	for key, want := range expected {
+		if got := values[key]; got != want {
+			t.Errorf("Expected %s = %v, got %v", key, want, got)
+		}
+	}
+
+	// A repository without metrics must still work
+	plain := NewUserRepository(NewRepository())
+	if err := plain.CreateUser(ctx, createTestUser("user2", "other@example.com")); err != nil {
+		t.Errorf("CreateUser() without metrics returned error: %v", err)
+	}
+}
+
 func createTestUser(id, email string) *portal.User {
 	return &portal.User{
 		ID:        id,
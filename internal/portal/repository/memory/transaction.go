@@ -12,8 +12,10 @@ type Transaction struct {
 	repo      *Repository
 	userRepo  *UserRepository
 	appRepo   *ApplicationRepository
+	readOnly  bool // Writes are rejected
 	committed bool
 	rolledBack bool
+	events    []portal.ApplicationEvent // Reported on commit
 	mu        sync.Mutex
 }
 
@@ -38,20 +40,34 @@ func NewTransaction(repo *Repository) *Transaction {
 
 // Commit commits the transaction
 func (tx *Transaction) Commit(ctx context.Context) error {
+	events, err := tx.commit()
+	if err != nil {
+		return err
+	}
+
+	// Report changes outside the lock so handlers may use the repository
+	tx.repo.publishEvents(events)
+	return nil
+}
+
+// commit marks the transaction committed and returns its queued events
+func (tx *Transaction) commit() ([]portal.ApplicationEvent, error) {
 	tx.mu.Lock()
 	defer tx.mu.Unlock()
 
 	if tx.committed {
-		return portal.NewDatabaseError("TX_ALREADY_COMMITTED", "transaction already committed", nil)
+		return nil, portal.NewDatabaseError("TX_ALREADY_COMMITTED", "transaction already committed", nil)
 	}
 	if tx.rolledBack {
-		return portal.NewDatabaseError("TX_ALREADY_ROLLED_BACK", "transaction already rolled back", nil)
+		return nil, portal.NewDatabaseError("TX_ALREADY_ROLLED_BACK", "transaction already rolled back", nil)
 	}
 
 	// For in-memory implementation, operations are applied immediately
 	// so commit is just a state change
 	tx.committed = true
-	return nil
+	events := tx.events
+	tx.events = nil
+	return events, nil
 }
 
 // Rollback rolls back the transaction
@@ -69,6 +85,7 @@ func (tx *Transaction) Rollback(ctx context.Context) error {
 	// For in-memory implementation, we can't really rollback changes
 	// In a real database implementation, this would undo all changes
 	tx.rolledBack = true
+	tx.events = nil
 	return nil
 }
 
@@ -82,6 +99,13 @@ func (tx *Transaction) ApplicationRepository() portal.ApplicationRepository {
 	return tx.appRepo
 }
 
+// addEvents queues application changes to report on commit
+func (tx *Transaction) addEvents(events []portal.ApplicationEvent) {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	tx.events = append(tx.events, events...)
+}
+
 // isActive checks if the transaction is still active
 func (tx *Transaction) isActive() error {
 	tx.mu.Lock()
@@ -95,3 +119,14 @@ func (tx *Transaction) isActive() error {
 	}
 	return nil
 }
+
+// isWritable checks if the transaction is still active and allows writes
+func (tx *Transaction) isWritable() error {
+	if err := tx.isActive(); err != nil {
+		return err
+	}
+	if tx.readOnly {
+		return portal.NewValidationError("TX_READ_ONLY", "transaction is read-only")
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/songzhibin97/stargate/pkg/portal"
+)
+
+// Ring buffer sizes per granularity. Minute buckets are kept for 24 hours,
+// hour buckets for 30 days and day buckets for a year; older buckets are
+// overwritten and read back as zero.
+const (
+	minuteUsageSlots = 24 * 60
+	hourUsageSlots   = 30 * 24
+	dayUsageSlots    = 365
+)
+
+// usageRing holds fixed-width usage buckets indexed by bucket start time
+type usageRing struct {
+	step  time.Duration
+	slots []portal.UsageBucket
+}
+
+func newUsageRing(step time.Duration, size int) *usageRing {
+	return &usageRing{
+		step:  step,
+		slots: make([]portal.UsageBucket, size),
+	}
+}
+
+// slot returns the slot for the bucket starting at start
+func (r *usageRing) slot(start time.Time) *portal.UsageBucket {
+	size := int64(len(r.slots))
+	// Buckets before 1970 have negative indexes
+	idx := (start.Unix()/int64(r.step/time.Second)%size + size) % size
+	return &r.slots[idx]
+}
+
+// add adds the delta to the bucket containing ts, recycling stale slots
+func (r *usageRing) add(ts time.Time, delta *portal.UsageDelta) {
+	start := ts.UTC().Truncate(r.step)
+	slot := r.slot(start)
+	if slot.Start.After(start) {
+		// The delta is older than the retention window
+		return
+	}
+	if !slot.Start.Equal(start) {
+		*slot = portal.UsageBucket{Start: start}
+	}
+	slot.Requests += delta.Requests
+	slot.Errors += delta.Errors
+	slot.Bytes += delta.Bytes
+}
+
+// fill copies retained counters into the given zero-filled buckets
+func (r *usageRing) fill(buckets []portal.UsageBucket) {
+	for i := range buckets {
+		slot := r.slot(buckets[i].Start)
+		if slot.Start.Equal(buckets[i].Start) {
+			buckets[i] = *slot
+		}
+	}
+}
+
+// applicationUsage holds the usage rings of a single application
+type applicationUsage struct {
+	rings map[string]*usageRing
+}
+
+func newApplicationUsage() *applicationUsage {
+	return &applicationUsage{
+		rings: map[string]*usageRing{
+			portal.UsageGranularityMinute: newUsageRing(time.Minute, minuteUsageSlots),
+			portal.UsageGranularityHour:   newUsageRing(time.Hour, hourUsageSlots),
+			portal.UsageGranularityDay:    newUsageRing(24*time.Hour, dayUsageSlots),
+		},
+	}
+}
+
+// UsageRepository implements the portal.UsageRepository interface using in-memory ring buffers
+type UsageRepository struct {
+	repo *Repository
+}
+
+// NewUsageRepository creates a new in-memory usage repository
+func NewUsageRepository(repo *Repository) *UsageRepository {
+	return &UsageRepository{
+		repo: repo,
+	}
+}
+
+// RecordUsage adds the delta to the application's usage counters
+func (ur *UsageRepository) RecordUsage(ctx context.Context, delta *portal.UsageDelta) error {
+	if err := portal.ValidateUsageDelta(delta); err != nil {
+		return err
+	}
+
+	ur.repo.mu.Lock()
+	defer ur.repo.mu.Unlock()
+
+	if ur.repo.closed {
+		return portal.NewDatabaseError("REPO_CLOSED", "repository is closed", nil)
+	}
+
+	if _, exists := ur.repo.applications[delta.ApplicationID]; !exists {
+		return portal.NewNotFoundError("APPLICATION_NOT_FOUND", "application not found")
+	}
+
+	ts := delta.Timestamp
+	if ts.IsZero() {
+		ts = time.Now()
+	}
+
+	usage, exists := ur.repo.usage[delta.ApplicationID]
+	if !exists {
+		usage = newApplicationUsage()
+		ur.repo.usage[delta.ApplicationID] = usage
+	}
+	for _, ring := range usage.rings {
+		ring.add(ts, delta)
+	}
+
+	return nil
+}
+
+// GetUsage returns time-bucketed usage for an application
+func (ur *UsageRepository) GetUsage(ctx context.Context, appID string, from, to time.Time, granularity string) ([]portal.UsageBucket, error) {
+	buckets, err := portal.NewUsageBuckets(appID, from, to, granularity)
+	if err != nil {
+		return nil, err
+	}
+
+	ur.repo.mu.RLock()
+	defer ur.repo.mu.RUnlock()
+
+	if ur.repo.closed {
+		return nil, portal.NewDatabaseError("REPO_CLOSED", "repository is closed", nil)
+	}
+
+	if usage, exists := ur.repo.usage[appID]; exists {
+		usage.rings[granularity].fill(buckets)
+	}
+
+	return buckets, nil
+}
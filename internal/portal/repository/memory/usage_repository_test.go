@@ -0,0 +1,143 @@
+package memory
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/songzhibin97/stargate/pkg/portal"
+)
+
+func TestUsageRepository_RecordAndGetUsage(t *testing.T) {
+	repo := NewRepository()
+	userRepo := NewUserRepository(repo)
+	appRepo := NewApplicationRepository(repo)
+	usageRepo := NewUsageRepository(repo)
+	ctx := context.Background()
+
+	userRepo.CreateUser(ctx, createTestUser("user1", "test@example.com"))
+	appRepo.CreateApplication(ctx, createTestApplication("app1", "user1", "ak_test123"))
+
+	base := time.Now().UTC().Truncate(time.Hour)
+	deltas := []*portal.UsageDelta{
+		{ApplicationID: "app1", Timestamp: base.Add(1 * time.Minute), Requests: 10, Errors: 1, Bytes: 100},
+		{ApplicationID: "app1", Timestamp: base.Add(1*time.Minute + 30*time.Second), Requests: 5, Bytes: 50},
+		{ApplicationID: "app1", Timestamp: base.Add(3 * time.Minute), Requests: 2, Errors: 2, Bytes: 20},
+	}
+	for _, delta := range deltas {
+		if err := usageRepo.RecordUsage(ctx, delta); err != nil {
+			t.Fatalf("RecordUsage() returned error: %v", err)
+		}
+	}
+
+	// Test minute buckets with zero-filled gaps
+	buckets, err := usageRepo.GetUsage(ctx, "app1", base, base.Add(5*time.Minute), portal.UsageGranularityMinute)
+	if err != nil {
+		t.Fatalf("GetUsage() returned error: %v", err)
+	}
+	if len(buckets) != 5 {
+		t.Fatalf("Expected 5 buckets, got %d", len(buckets))
+	}
+	expectedRequests := []int64{0, 15, 0, 2, 0}
+	for i, bucket := range buckets {
+		if !bucket.Start.Equal(base.Add(time.Duration(i) * time.Minute)) {
+			t.Errorf("Bucket %d: expected start %v, got %v", i, base.Add(time.Duration(i)*time.Minute), bucket.Start)
+		}
+		if bucket.Requests != expectedRequests[i] {
+			t.Errorf("Bucket %d: expected %d requests, got %d", i, expectedRequests[i], bucket.Requests)
+		}
+	}
+	if buckets[1].Errors != 1 || buckets[1].Bytes != 150 {
+		t.Errorf("Expected 1 error and 150 bytes in bucket 1, got %+v", buckets[1])
+	}
+
+	// Test hour aggregation
+	buckets, err = usageRepo.GetUsage(ctx, "app1", base, base.Add(2*time.Hour), portal.UsageGranularityHour)
+	if err != nil {
+		t.Fatalf("GetUsage() returned error: %v", err)
+	}
+	if len(buckets) != 2 {
+		t.Fatalf("Expected 2 buckets, got %d", len(buckets))
+	}
+	if buckets[0].Requests != 17 || buckets[0].Errors != 3 || buckets[0].Bytes != 170 {
+		t.Errorf("Unexpected hour bucket: %+v", buckets[0])
+	}
+	if buckets[1].Requests != 0 {
+		t.Errorf("Expected empty second hour bucket, got %+v", buckets[1])
+	}
+
+	// Test application without usage
+	userRepo.CreateUser(ctx, createTestUser("user2", "other@example.com"))
+	appRepo.CreateApplication(ctx, createTestApplication("app2", "user2", "ak_test456"))
+	day := base.Truncate(24 * time.Hour)
+	buckets, err = usageRepo.GetUsage(ctx, "app2", day, day.Add(24*time.Hour), portal.UsageGranularityDay)
+	if err != nil {
+		t.Fatalf("GetUsage() returned error: %v", err)
+	}
+	if len(buckets) != 1 || buckets[0].Requests != 0 {
+		t.Errorf("Expected one empty day bucket, got %+v", buckets)
+	}
+}
+
+func TestUsageRepository_PreEpochUsage(t *testing.T) {
+	repo := NewRepository()
+	userRepo := NewUserRepository(repo)
+	appRepo := NewApplicationRepository(repo)
+	usageRepo := NewUsageRepository(repo)
+	ctx := context.Background()
+
+	userRepo.CreateUser(ctx, createTestUser("user1", "test@example.com"))
+	appRepo.CreateApplication(ctx, createTestApplication("app1", "user1", "ak_test123"))
+
+	// Buckets before 1970 have negative Unix times
+	base := time.Date(1969, 12, 31, 23, 55, 0, 0, time.UTC)
+	err := usageRepo.RecordUsage(ctx, &portal.UsageDelta{ApplicationID: "app1", Timestamp: base.Add(90 * time.Second), Requests: 3})
+	if err != nil {
+		t.Fatalf("RecordUsage() returned error: %v", err)
+	}
+
+	buckets, err := usageRepo.GetUsage(ctx, "app1", base, base.Add(3*time.Minute), portal.UsageGranularityMinute)
+	if err != nil {
+		t.Fatalf("GetUsage() returned error: %v", err)
+	}
+	if len(buckets) != 3 || buckets[0].Requests != 0 || buckets[1].Requests != 3 || buckets[2].Requests != 0 {
+		t.Errorf("Expected 3 requests in the second bucket, got %+v", buckets)
+	}
+}
+
+func TestUsageRepository_Validation(t *testing.T) {
+	repo := NewRepository()
+	usageRepo := NewUsageRepository(repo)
+	ctx := context.Background()
+	now := time.Now()
+
+	// Test unknown application
+	err := usageRepo.RecordUsage(ctx, &portal.UsageDelta{ApplicationID: "missing", Requests: 1})
+	if !portal.IsNotFoundError(err) {
+		t.Errorf("Expected not found error, got: %v", err)
+	}
+
+	// Test negative counters
+	err = usageRepo.RecordUsage(ctx, &portal.UsageDelta{ApplicationID: "app1", Requests: -1})
+	if !portal.IsValidationError(err) {
+		t.Errorf("Expected validation error, got: %v", err)
+	}
+
+	// Test invalid granularity
+	_, err = usageRepo.GetUsage(ctx, "app1", now.Add(-time.Hour), now, "week")
+	if !portal.IsValidationError(err) {
+		t.Errorf("Expected validation error for invalid granularity, got: %v", err)
+	}
+
+	// Test inverted range
+	_, err = usageRepo.GetUsage(ctx, "app1", now, now.Add(-time.Hour), portal.UsageGranularityMinute)
+	if !portal.IsValidationError(err) {
+		t.Errorf("Expected validation error for inverted range, got: %v", err)
+	}
+
+	// Test range cap
+	_, err = usageRepo.GetUsage(ctx, "app1", now.Add(-48*time.Hour), now, portal.UsageGranularityMinute)
+	if !portal.IsValidationError(err) {
+		t.Errorf("Expected validation error for oversized range, got: %v", err)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/songzhibin97/stargate/internal/portal/repository/instrument"
 	"github.com/songzhibin97/stargate/pkg/portal"
 )
 
@@ -23,9 +24,11 @@ func NewUserRepository(repo *Repository) *UserRepository {
 }
 
 // CreateUser creates a new user
-func (ur *UserRepository) CreateUser(ctx context.Context, user *portal.User) error {
+func (ur *UserRepository) CreateUser(ctx context.Context, user *portal.User) (err error) {
+	defer ur.repo.metrics.Track(instrument.UserRepository, "CreateUser")(&err)
+
 	if ur.tx != nil {
-		if err := ur.tx.isActive(); err != nil {
+		if err := ur.tx.isWritable(); err != nil {
 			return err
 		}
 	}
@@ -40,6 +43,7 @@ func (ur *UserRepository) CreateUser(ctx context.Context, user *portal.User) err
 	if err := ur.repo.isValidUser(user); err != nil {
 		return err
 	}
+	user.Email = portal.NormalizeEmail(user.Email)
 
 	// Check if user already exists
 	if _, exists := ur.repo.users[user.ID]; exists {
@@ -67,7 +71,9 @@ func (ur *UserRepository) CreateUser(ctx context.Context, user *portal.User) err
 }
 
 // GetUser retrieves a user by ID
-func (ur *UserRepository) GetUser(ctx context.Context, userID string) (*portal.User, error) {
+func (ur *UserRepository) GetUser(ctx context.Context, userID string) (_ *portal.User, err error) {
+	defer ur.repo.metrics.Track(instrument.UserRepository, "GetUser")(&err)
+
 	if ur.tx != nil {
 		if err := ur.tx.isActive(); err != nil {
 			return nil, err
@@ -96,7 +102,9 @@ func (ur *UserRepository) GetUser(ctx context.Context, userID string) (*portal.U
 }
 
 // GetUserByEmail retrieves a user by email address
-func (ur *UserRepository) GetUserByEmail(ctx context.Context, email string) (*portal.User, error) {
+func (ur *UserRepository) GetUserByEmail(ctx context.Context, email string) (_ *portal.User, err error) {
+	defer ur.repo.metrics.Track(instrument.UserRepository, "GetUserByEmail")(&err)
+
 	if ur.tx != nil {
 		if err := ur.tx.isActive(); err != nil {
 			return nil, err
@@ -114,7 +122,7 @@ func (ur *UserRepository) GetUserByEmail(ctx context.Context, email string) (*po
 		return nil, portal.NewValidationError("INVALID_EMAIL", "email cannot be empty")
 	}
 
-	user, exists := ur.repo.usersByEmail[email]
+	user, exists := ur.repo.usersByEmail[portal.NormalizeEmail(email)]
 	if !exists {
 		return nil, portal.NewNotFoundError("USER_NOT_FOUND", "user not found")
 	}
@@ -125,9 +133,11 @@ func (ur *UserRepository) GetUserByEmail(ctx context.Context, email string) (*po
 }
 
 // UpdateUser updates an existing user
-func (ur *UserRepository) UpdateUser(ctx context.Context, user *portal.User) error {
+func (ur *UserRepository) UpdateUser(ctx context.Context, user *portal.User) (err error) {
+	defer ur.repo.metrics.Track(instrument.UserRepository, "UpdateUser")(&err)
+
 	if ur.tx != nil {
-		if err := ur.tx.isActive(); err != nil {
+		if err := ur.tx.isWritable(); err != nil {
 			return err
 		}
 	}
@@ -142,6 +152,7 @@ func (ur *UserRepository) UpdateUser(ctx context.Context, user *portal.User) err
 	if err := ur.repo.isValidUser(user); err != nil {
 		return err
 	}
+	user.Email = portal.NormalizeEmail(user.Email)
 
 	// Check if user exists
 	existingUser, exists := ur.repo.users[user.ID]
@@ -171,9 +182,11 @@ func (ur *UserRepository) UpdateUser(ctx context.Context, user *portal.User) err
 }
 
 // DeleteUser deletes a user by ID
-func (ur *UserRepository) DeleteUser(ctx context.Context, userID string) error {
+func (ur *UserRepository) DeleteUser(ctx context.Context, userID string) (err error) {
+	defer ur.repo.metrics.Track(instrument.UserRepository, "DeleteUser")(&err)
+
 	if ur.tx != nil {
-		if err := ur.tx.isActive(); err != nil {
+		if err := ur.tx.isWritable(); err != nil {
 			return err
 		}
 	}
@@ -202,7 +215,9 @@ func (ur *UserRepository) DeleteUser(ctx context.Context, userID string) error {
 }
 
 // ExistsUser checks if a user exists by ID
-func (ur *UserRepository) ExistsUser(ctx context.Context, userID string) (bool, error) {
+func (ur *UserRepository) ExistsUser(ctx context.Context, userID string) (_ bool, err error) {
+	defer ur.repo.metrics.Track(instrument.UserRepository, "ExistsUser")(&err)
+
 	if ur.tx != nil {
 		if err := ur.tx.isActive(); err != nil {
 			return false, err
@@ -225,7 +240,9 @@ func (ur *UserRepository) ExistsUser(ctx context.Context, userID string) (bool,
 }
 
 // ExistsUserByEmail checks if a user exists by email
-func (ur *UserRepository) ExistsUserByEmail(ctx context.Context, email string) (bool, error) {
+func (ur *UserRepository) ExistsUserByEmail(ctx context.Context, email string) (_ bool, err error) {
+	defer ur.repo.metrics.Track(instrument.UserRepository, "ExistsUserByEmail")(&err)
+
 	if ur.tx != nil {
 		if err := ur.tx.isActive(); err != nil {
 			return false, err
@@ -243,14 +260,16 @@ func (ur *UserRepository) ExistsUserByEmail(ctx context.Context, email string) (
 		return false, portal.NewValidationError("INVALID_EMAIL", "email cannot be empty")
 	}
 
-	_, exists := ur.repo.usersByEmail[email]
+	_, exists := ur.repo.usersByEmail[portal.NormalizeEmail(email)]
 	return exists, nil
 }
 
 // UpdateUserStatus updates the status of a user
-func (ur *UserRepository) UpdateUserStatus(ctx context.Context, userID string, status portal.UserStatus) error {
+func (ur *UserRepository) UpdateUserStatus(ctx context.Context, userID string, status portal.UserStatus) (err error) {
+	defer ur.repo.metrics.Track(instrument.UserRepository, "UpdateUserStatus")(&err)
+
 	if ur.tx != nil {
-		if err := ur.tx.isActive(); err != nil {
+		if err := ur.tx.isWritable(); err != nil {
 			return err
 		}
 	}
@@ -279,9 +298,11 @@ func (ur *UserRepository) UpdateUserStatus(ctx context.Context, userID string, s
 }
 
 // UpdateUserRole updates the role of a user
-func (ur *UserRepository) UpdateUserRole(ctx context.Context, userID string, role portal.UserRole) error {
+func (ur *UserRepository) UpdateUserRole(ctx context.Context, userID string, role portal.UserRole) (err error) {
+	defer ur.repo.metrics.Track(instrument.UserRepository, "UpdateUserRole")(&err)
+
 	if ur.tx != nil {
-		if err := ur.tx.isActive(); err != nil {
+		if err := ur.tx.isWritable(); err != nil {
 			return err
 		}
 	}
@@ -309,8 +330,42 @@ func (ur *UserRepository) UpdateUserRole(ctx context.Context, userID string, rol
 	return nil
 }
 
+// UpdateLastLogin records that a user logged in now
+func (ur *UserRepository) UpdateLastLogin(ctx context.Context, userID string) (err error) {
+	defer ur.repo.metrics.Track(instrument.UserRepository, "UpdateLastLogin")(&err)
+
+	if ur.tx != nil {
+		if err := ur.tx.isWritable(); err != nil {
+			return err
+		}
+	}
+
+	ur.repo.mu.Lock()
+	defer ur.repo.mu.Unlock()
+
+	if ur.repo.closed {
+		return portal.NewDatabaseError("REPO_CLOSED", "repository is closed", nil)
+	}
+
+	if userID == "" {
+		return portal.NewValidationError("INVALID_USER_ID", "user ID cannot be empty")
+	}
+
+	user, exists := ur.repo.users[userID]
+	if !exists {
+		return portal.NewNotFoundError("USER_NOT_FOUND", "user not found")
+	}
+
+	now := time.Now()
+	user.LastLoginAt = &now
+
+	return nil
+}
+
 // ListUsers retrieves users based on filter criteria
-func (ur *UserRepository) ListUsers(ctx context.Context, filter *portal.UserFilter) (*portal.PaginatedUsers, error) {
+func (ur *UserRepository) ListUsers(ctx context.Context, filter *portal.UserFilter) (_ *portal.PaginatedUsers, err error) {
+	defer ur.repo.metrics.Track(instrument.UserRepository, "ListUsers")(&err)
+
 	if ur.tx != nil {
 		if err := ur.tx.isActive(); err != nil {
 			return nil, err
@@ -351,11 +406,32 @@ func (ur *UserRepository) ListUsers(ctx context.Context, filter *portal.UserFilt
 		}
 	}
 
+	total := int64(len(filteredUsers))
+
+	// Page after the cursor instead of an offset in cursor mode
+	if filter.CursorMode() {
+		if filter.PageSize <= 0 {
+			filter.PageSize = 50
+		}
+		page, nextCursor, err := cursorPage(filteredUsers, func(user *portal.User) (time.Time, string) {
+			return user.CreatedAt, user.ID
+		}, filter.Cursor, filter.PageSize, filter.SortOrder)
+		if err != nil {
+			return nil, err
+		}
+		return &portal.PaginatedUsers{
+			Users:      page,
+			Total:      total,
+			Limit:      filter.PageSize,
+			HasMore:    nextCursor != "",
+			NextCursor: nextCursor,
+		}, nil
+	}
+
 	// Sort users
 	ur.sortUsers(filteredUsers, filter.SortBy, filter.SortOrder)
 
 	// Calculate pagination
-	total := int64(len(filteredUsers))
 	start := filter.Offset
 	end := start + filter.Limit
 
@@ -379,7 +455,9 @@ func (ur *UserRepository) ListUsers(ctx context.Context, filter *portal.UserFilt
 }
 
 // CountUsers returns the total count of users matching the filter
-func (ur *UserRepository) CountUsers(ctx context.Context, filter *portal.UserFilter) (int64, error) {
+func (ur *UserRepository) CountUsers(ctx context.Context, filter *portal.UserFilter) (_ int64, err error) {
+	defer ur.repo.metrics.Track(instrument.UserRepository, "CountUsers")(&err)
+
 	if ur.tx != nil {
 		if err := ur.tx.isActive(); err != nil {
 			return 0, err
@@ -408,9 +486,11 @@ func (ur *UserRepository) CountUsers(ctx context.Context, filter *portal.UserFil
 }
 
 // BatchCreateUsers creates multiple users in a single operation
-func (ur *UserRepository) BatchCreateUsers(ctx context.Context, users []*portal.User) error {
+func (ur *UserRepository) BatchCreateUsers(ctx context.Context, users []*portal.User) (err error) {
+	defer ur.repo.metrics.Track(instrument.UserRepository, "BatchCreateUsers")(&err)
+
 	if ur.tx != nil {
-		if err := ur.tx.isActive(); err != nil {
+		if err := ur.tx.isWritable(); err != nil {
 			return err
 		}
 	}
@@ -431,6 +511,7 @@ func (ur *UserRepository) BatchCreateUsers(ctx context.Context, users []*portal.
 		if err := ur.repo.isValidUser(user); err != nil {
 			return err
 		}
+		user.Email = portal.NormalizeEmail(user.Email)
 
 		// Check if user already exists
 		if _, exists := ur.repo.users[user.ID]; exists {
@@ -462,9 +543,11 @@ func (ur *UserRepository) BatchCreateUsers(ctx context.Context, users []*portal.
 }
 
 // BatchUpdateUsers updates multiple users in a single operation
-func (ur *UserRepository) BatchUpdateUsers(ctx context.Context, users []*portal.User) error {
+func (ur *UserRepository) BatchUpdateUsers(ctx context.Context, users []*portal.User) (err error) {
+	defer ur.repo.metrics.Track(instrument.UserRepository, "BatchUpdateUsers")(&err)
+
 	if ur.tx != nil {
-		if err := ur.tx.isActive(); err != nil {
+		if err := ur.tx.isWritable(); err != nil {
 			return err
 		}
 	}
@@ -485,6 +568,7 @@ func (ur *UserRepository) BatchUpdateUsers(ctx context.Context, users []*portal.
 		if err := ur.repo.isValidUser(user); err != nil {
 			return err
 		}
+		user.Email = portal.NormalizeEmail(user.Email)
 
 		// Check if user exists
 		existingUser, exists := ur.repo.users[user.ID]
@@ -524,9 +608,11 @@ func (ur *UserRepository) BatchUpdateUsers(ctx context.Context, users []*portal.
 }
 
 // BatchDeleteUsers deletes multiple users by IDs
-func (ur *UserRepository) BatchDeleteUsers(ctx context.Context, userIDs []string) error {
+func (ur *UserRepository) BatchDeleteUsers(ctx context.Context, userIDs []string) (err error) {
+	defer ur.repo.metrics.Track(instrument.UserRepository, "BatchDeleteUsers")(&err)
+
 	if ur.tx != nil {
-		if err := ur.tx.isActive(); err != nil {
+		if err := ur.tx.isWritable(); err != nil {
 			return err
 		}
 	}
@@ -566,7 +652,7 @@ func (ur *UserRepository) BatchDeleteUsers(ctx context.Context, userIDs []string
 // matchesUserFilter checks if a user matches the given filter criteria
 func (ur *UserRepository) matchesUserFilter(user *portal.User, filter *portal.UserFilter) bool {
 	// Filter by email
-	if filter.Email != "" && user.Email != filter.Email {
+	if filter.Email != "" && user.Email != portal.NormalizeEmail(filter.Email) {
 		return false
 	}
 
@@ -602,6 +688,11 @@ func (ur *UserRepository) matchesUserFilter(user *portal.User, filter *portal.Us
 		return false
 	}
 
+	// Filter by last login, users who never logged in included
+	if filter.LastLoginBefore != nil && user.LastLoginAt != nil && !user.LastLoginAt.Before(*filter.LastLoginBefore) {
+		return false
+	}
+
 	return true
 }
 
@@ -3,6 +3,7 @@ package memory
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/songzhibin97/stargate/pkg/portal"
 )
@@ -49,6 +50,32 @@ func TestUserRepository_CreateUser(t *testing.T) {
 		t.Errorf("Expected conflict error, got: %v", err)
 	}
 
+	// Test duplicate email differing only in case
+	mixedCaseUser := createTestUser("user2", "Test@Example.com")
+	err = userRepo.CreateUser(ctx, mixedCaseUser)
+	if !portal.IsConflictError(err) {
+		t.Errorf("Expected conflict error for mixed-case duplicate email, got: %v", err)
+	}
+	if _, exists := repo.users["user2"]; exists {
+		t.Error("Mixed-case duplicate email user should not be stored")
+	}
+
+	// Test emails are stored in lowercase
+	upperUser := createTestUser("user4", "Upper@Example.com")
+	if err := userRepo.CreateUser(ctx, upperUser); err != nil {
+		t.Errorf("CreateUser() with mixed-case email returned error: %v", err)
+	}
+	if stored := repo.users["user4"]; stored == nil || stored.Email != "upper@example.com" {
+		t.Errorf("Expected email stored as upper@example.com, got %+v", stored)
+	}
+
+	// Test updating to an email differing only in case from another user's
+	upperUser.Email = "TEST@example.com"
+	err = userRepo.UpdateUser(ctx, upperUser)
+	if !portal.IsConflictError(err) {
+		t.Errorf("Expected conflict error for mixed-case email update, got: %v", err)
+	}
+
 	// Test closed repository
 	repo.Close()
 	err = userRepo.CreateUser(ctx, createTestUser("user3", "test3@example.com"))
@@ -126,6 +153,17 @@ func TestUserRepository_GetUserByEmail(t *testing.T) {
 		t.Errorf("Expected ID %s, got %s", user.ID, retrievedUser.ID)
 	}
 
+	// Test lookup ignores case
+	retrievedUser, err = userRepo.GetUserByEmail(ctx, "TEST@example.COM")
+	if err != nil {
+		t.Errorf("GetUserByEmail() with mixed-case email returned error: %v", err)
+	} else if retrievedUser.ID != user.ID {
+		t.Errorf("Expected ID %s, got %s", user.ID, retrievedUser.ID)
+	}
+	if exists, _ := userRepo.ExistsUserByEmail(ctx, "Test@Example.com"); !exists {
+		t.Error("Expected user to exist by mixed-case email")
+	}
+
 	// Test non-existent email
 	_, err = userRepo.GetUserByEmail(ctx, "nonexistent@example.com")
 	if err == nil {
@@ -373,6 +411,57 @@ func TestUserRepository_UpdateUserRole(t *testing.T) {
 	}
 }
 
+func TestUserRepository_UpdateLastLogin(t *testing.T) {
+	repo := NewRepository()
+	userRepo := NewUserRepository(repo)
+	ctx := context.Background()
+
+	userRepo.CreateUser(ctx, createTestUser("user1", "user1@example.com"))
+	userRepo.CreateUser(ctx, createTestUser("user2", "user2@example.com"))
+
+	// Test users start without a login
+	user, _ := userRepo.GetUser(ctx, "user1")
+	if user.LastLoginAt != nil {
+		t.Errorf("Expected no last login, got %v", user.LastLoginAt)
+	}
+
+	// Test successful login update
+	before := time.Now()
+	if err := userRepo.UpdateLastLogin(ctx, "user1"); err != nil {
+		t.Errorf("UpdateLastLogin() returned error: %v", err)
+	}
+	user, _ = userRepo.GetUser(ctx, "user1")
+	if user.LastLoginAt == nil || user.LastLoginAt.Before(before) {
+		t.Errorf("Expected last login after %v, got %v", before, user.LastLoginAt)
+	}
+
+	// Test users who never logged in match a last login filter
+	result, err := userRepo.ListUsers(ctx, &portal.UserFilter{LastLoginBefore: &before})
+	if err != nil {
+		t.Errorf("ListUsers() returned error: %v", err)
+	}
+	if len(result.Users) != 1 || result.Users[0].ID != "user2" {
+		t.Errorf("Expected only user2 to have logged in before %v, got %+v", before, result.Users)
+	}
+	after := time.Now().Add(time.Second)
+	result, _ = userRepo.ListUsers(ctx, &portal.UserFilter{LastLoginBefore: &after})
+	if len(result.Users) != 2 {
+		t.Errorf("Expected 2 users to have logged in before %v, got %d", after, len(result.Users))
+	}
+
+	// Test non-existent user
+	err = userRepo.UpdateLastLogin(ctx, "nonexistent")
+	if !portal.IsNotFoundError(err) {
+		t.Errorf("Expected not found error, got: %v", err)
+	}
+
+	// Test empty ID
+	err = userRepo.UpdateLastLogin(ctx, "")
+	if !portal.IsValidationError(err) {
+		t.Errorf("Expected validation error, got: %v", err)
+	}
+}
+
 func TestUserRepository_ListUsers(t *testing.T) {
 	repo := NewRepository()
 	userRepo := NewUserRepository(repo)
@@ -439,6 +528,16 @@ func TestUserRepository_ListUsers(t *testing.T) {
 		t.Error("Should have more users")
 	}
 
+	// Test estimated totals are exact in memory
+	filter = &portal.UserFilter{Limit: 2, EstimateTotal: true}
+	result, err = userRepo.ListUsers(ctx, filter)
+	if err != nil {
+		t.Errorf("ListUsers() with estimated total returned error: %v", err)
+	}
+	if result.Total != 3 || result.TotalEstimated {
+		t.Errorf("Expected exact total 3, got %d (estimated: %v)", result.Total, result.TotalEstimated)
+	}
+
 	// Test search
 	filter = &portal.UserFilter{Search: "user1"}
 	result, err = userRepo.ListUsers(ctx, filter)
@@ -448,6 +547,32 @@ func TestUserRepository_ListUsers(t *testing.T) {
 	if len(result.Users) != 1 {
 		t.Errorf("Expected 1 user matching search, got %d", len(result.Users))
 	}
+
+	// Test cursor pagination, ordered by creation time and then ID
+	var ids []string
+	filter = &portal.UserFilter{PageSize: 2, SortOrder: "asc"}
+	for {
+		result, err = userRepo.ListUsers(ctx, filter)
+		if err != nil {
+			t.Fatalf("ListUsers() with cursor returned error: %v", err)
+		}
+		for _, user := range result.Users {
+			ids = append(ids, user.ID)
+		}
+		if !result.HasMore {
+			break
+		}
+		filter.Cursor = result.NextCursor
+	}
+	if len(ids) != 3 || ids[0] != "user1" || ids[1] != "user2" || ids[2] != "user3" {
+		t.Errorf("Expected user1, user2, user3 over the pages, got %v", ids)
+	}
+
+	// Test malformed cursor
+	_, err = userRepo.ListUsers(ctx, &portal.UserFilter{Cursor: "not-a-cursor"})
+	if !portal.IsValidationError(err) {
+		t.Errorf("Expected validation error for malformed cursor, got: %v", err)
+	}
 }
 
 func TestUserRepository_CountUsers(t *testing.T) {
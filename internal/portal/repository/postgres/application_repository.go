@@ -6,9 +6,12 @@ import (
 	"database/sql"
 	"encoding/hex"
 	"fmt"
+	"sort"
 	"strings"
 	"time"
 
+	"github.com/lib/pq"
+	"github.com/songzhibin97/stargate/internal/portal/repository/instrument"
 	"github.com/songzhibin97/stargate/pkg/portal"
 )
 
@@ -26,11 +29,18 @@ func NewApplicationRepository(repo *Repository) *ApplicationRepository {
 }
 
 // CreateApplication creates a new application
-func (ar *ApplicationRepository) CreateApplication(ctx context.Context, app *portal.Application) error {
+func (ar *ApplicationRepository) CreateApplication(ctx context.Context, app *portal.Application) (err error) {
+	defer ar.repo.metrics.Track(instrument.ApplicationRepository, "CreateApplication")(&err)
+
 	if err := ar.validateApplication(app); err != nil {
 		return err
 	}
 
+	secretHash, err := ar.hashAPISecret(app.APISecret)
+	if err != nil {
+		return err
+	}
+
 	// Check if user exists
 	userExists, err := ar.checkUserExists(ctx, app.UserID)
 	if err != nil {
@@ -41,20 +51,22 @@ func (ar *ApplicationRepository) CreateApplication(ctx context.Context, app *por
 	}
 
 	query := `
-		INSERT INTO applications (id, name, description, user_id, api_key, api_secret, status, rate_limit, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`
+		INSERT INTO applications (id, name, description, user_id, api_key, api_secret, status, rate_limit, scopes, created_at, updated_at, last_used_at, version)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)`
 
 	now := time.Now()
 	if app.CreatedAt.IsZero() {
 		app.CreatedAt = now
 	}
 	app.UpdatedAt = now
+	app.Version = 1
+	app.Scopes = portal.NormalizeScopes(app.Scopes)
 
 	var execErr error
 	if ar.tx != nil {
-		_, execErr = ar.tx.execCommand(ctx, query, app.ID, app.Name, app.Description, app.UserID, app.APIKey, app.APISecret, app.Status, app.RateLimit, app.CreatedAt, app.UpdatedAt)
+		_, execErr = ar.tx.execCommand(ctx, query, app.ID, app.Name, app.Description, app.UserID, app.APIKey, secretHash, app.Status, app.RateLimit, pq.Array(app.Scopes), app.CreatedAt, app.UpdatedAt, app.LastUsedAt, app.Version)
 	} else {
-		_, execErr = ar.repo.execCommand(ctx, query, app.ID, app.Name, app.Description, app.UserID, app.APIKey, app.APISecret, app.Status, app.RateLimit, app.CreatedAt, app.UpdatedAt)
+		_, execErr = ar.repo.execCommand(ctx, query, app.ID, app.Name, app.Description, app.UserID, app.APIKey, secretHash, app.Status, app.RateLimit, pq.Array(app.Scopes), app.CreatedAt, app.UpdatedAt, app.LastUsedAt, app.Version)
 	}
 
 	if execErr != nil {
@@ -76,15 +88,17 @@ func (ar *ApplicationRepository) CreateApplication(ctx context.Context, app *por
 }
 
 // GetApplication retrieves an application by ID
-func (ar *ApplicationRepository) GetApplication(ctx context.Context, appID string) (*portal.Application, error) {
+func (ar *ApplicationRepository) GetApplication(ctx context.Context, appID string) (_ *portal.Application, err error) {
+	defer ar.repo.metrics.Track(instrument.ApplicationRepository, "GetApplication")(&err)
+
 	if appID == "" {
 		return nil, portal.NewValidationError("INVALID_APPLICATION_ID", "application ID cannot be empty")
 	}
 
 	query := `
-		SELECT id, name, description, user_id, api_key, api_secret, status, rate_limit, created_at, updated_at
+		SELECT id, name, description, user_id, api_key, status, rate_limit, scopes, created_at, updated_at, last_used_at, deleted_at, version
 		FROM applications
-		WHERE id = $1`
+		WHERE id = $1 AND deleted_at IS NULL`
 
 	var row *sql.Row
 	if ar.tx != nil {
@@ -94,7 +108,7 @@ func (ar *ApplicationRepository) GetApplication(ctx context.Context, appID strin
 	}
 
 	app := &portal.Application{}
-	err := row.Scan(&app.ID, &app.Name, &app.Description, &app.UserID, &app.APIKey, &app.APISecret, &app.Status, &app.RateLimit, &app.CreatedAt, &app.UpdatedAt)
+	err = row.Scan(&app.ID, &app.Name, &app.Description, &app.UserID, &app.APIKey, &app.Status, &app.RateLimit, pq.Array(&app.Scopes), &app.CreatedAt, &app.UpdatedAt, &app.LastUsedAt, &app.DeletedAt, &app.Version)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, portal.NewNotFoundError("APPLICATION_NOT_FOUND", "application not found")
@@ -106,15 +120,17 @@ func (ar *ApplicationRepository) GetApplication(ctx context.Context, appID strin
 }
 
 // GetApplicationByAPIKey retrieves an application by API key
-func (ar *ApplicationRepository) GetApplicationByAPIKey(ctx context.Context, apiKey string) (*portal.Application, error) {
+func (ar *ApplicationRepository) GetApplicationByAPIKey(ctx context.Context, apiKey string) (_ *portal.Application, err error) {
+	defer ar.repo.metrics.Track(instrument.ApplicationRepository, "GetApplicationByAPIKey")(&err)
+
 	if apiKey == "" {
 		return nil, portal.NewValidationError("INVALID_API_KEY", "API key cannot be empty")
 	}
 
 	query := `
-		SELECT id, name, description, user_id, api_key, api_secret, status, rate_limit, created_at, updated_at
+		SELECT id, name, description, user_id, api_key, status, rate_limit, scopes, created_at, updated_at, last_used_at, deleted_at, version
 		FROM applications
-		WHERE api_key = $1`
+		WHERE api_key = $1 AND deleted_at IS NULL`
 
 	var row *sql.Row
 	if ar.tx != nil {
@@ -124,7 +140,7 @@ func (ar *ApplicationRepository) GetApplicationByAPIKey(ctx context.Context, api
 	}
 
 	app := &portal.Application{}
-	err := row.Scan(&app.ID, &app.Name, &app.Description, &app.UserID, &app.APIKey, &app.APISecret, &app.Status, &app.RateLimit, &app.CreatedAt, &app.UpdatedAt)
+	err = row.Scan(&app.ID, &app.Name, &app.Description, &app.UserID, &app.APIKey, &app.Status, &app.RateLimit, pq.Array(&app.Scopes), &app.CreatedAt, &app.UpdatedAt, &app.LastUsedAt, &app.DeletedAt, &app.Version)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, portal.NewNotFoundError("APPLICATION_NOT_FOUND", "application not found")
@@ -136,19 +152,20 @@ func (ar *ApplicationRepository) GetApplicationByAPIKey(ctx context.Context, api
 }
 
 // GetApplicationsByUser retrieves all applications for a specific user
-func (ar *ApplicationRepository) GetApplicationsByUser(ctx context.Context, userID string) ([]*portal.Application, error) {
+func (ar *ApplicationRepository) GetApplicationsByUser(ctx context.Context, userID string) (_ []*portal.Application, err error) {
+	defer ar.repo.metrics.Track(instrument.ApplicationRepository, "GetApplicationsByUser")(&err)
+
 	if userID == "" {
 		return nil, portal.NewValidationError("INVALID_USER_ID", "user ID cannot be empty")
 	}
 
 	query := `
-		SELECT id, name, description, user_id, api_key, api_secret, status, rate_limit, created_at, updated_at
+		SELECT id, name, description, user_id, api_key, status, rate_limit, scopes, created_at, updated_at, last_used_at, deleted_at, version
 		FROM applications
-		WHERE user_id = $1
+		WHERE user_id = $1 AND deleted_at IS NULL
 		ORDER BY created_at DESC`
 
 	var rows *sql.Rows
-	var err error
 	if ar.tx != nil {
 		rows, err = ar.tx.execQuery(ctx, query, userID)
 	} else {
@@ -163,7 +180,7 @@ func (ar *ApplicationRepository) GetApplicationsByUser(ctx context.Context, user
 	var applications []*portal.Application
 	for rows.Next() {
 		app := &portal.Application{}
-		err := rows.Scan(&app.ID, &app.Name, &app.Description, &app.UserID, &app.APIKey, &app.APISecret, &app.Status, &app.RateLimit, &app.CreatedAt, &app.UpdatedAt)
+		err := rows.Scan(&app.ID, &app.Name, &app.Description, &app.UserID, &app.APIKey, &app.Status, &app.RateLimit, pq.Array(&app.Scopes), &app.CreatedAt, &app.UpdatedAt, &app.LastUsedAt, &app.DeletedAt, &app.Version)
 		if err != nil {
 			return nil, portal.NewDatabaseError("SCAN_FAILED", "failed to scan application", err)
 		}
@@ -178,11 +195,19 @@ func (ar *ApplicationRepository) GetApplicationsByUser(ctx context.Context, user
 }
 
 // UpdateApplication updates an existing application
-func (ar *ApplicationRepository) UpdateApplication(ctx context.Context, app *portal.Application) error {
+func (ar *ApplicationRepository) UpdateApplication(ctx context.Context, app *portal.Application) (err error) {
+	defer ar.repo.metrics.Track(instrument.ApplicationRepository, "UpdateApplication")(&err)
+
 	if err := ar.validateApplication(app); err != nil {
 		return err
 	}
 
+	// Keep the secret unless a new one is set
+	secretHash, err := ar.hashAPISecret(app.APISecret)
+	if err != nil {
+		return err
+	}
+
 	// Check if application exists
 	existingApp, err := ar.GetApplication(ctx, app.ID)
 	if err != nil {
@@ -200,17 +225,19 @@ func (ar *ApplicationRepository) UpdateApplication(ctx context.Context, app *por
 
 	query := `
 		UPDATE applications 
-		SET name = $2, description = $3, user_id = $4, api_key = $5, api_secret = $6, status = $7, rate_limit = $8, updated_at = $9
-		WHERE id = $1`
+		SET name = $2, description = $3, user_id = $4, api_key = $5, api_secret = COALESCE(NULLIF($6, ''), api_secret), status = $7, rate_limit = $8, scopes = $9, updated_at = $10, version = version + 1
+		WHERE id = $1 AND version = $11 AND deleted_at IS NULL`
 
 	app.CreatedAt = existingApp.CreatedAt // Preserve original creation time
 	app.UpdatedAt = time.Now()
+	app.Scopes = portal.NormalizeScopes(app.Scopes)
 
+	var result sql.Result
 	var execErr error
 	if ar.tx != nil {
-		_, execErr = ar.tx.execCommand(ctx, query, app.ID, app.Name, app.Description, app.UserID, app.APIKey, app.APISecret, app.Status, app.RateLimit, app.UpdatedAt)
+		result, execErr = ar.tx.execCommand(ctx, query, app.ID, app.Name, app.Description, app.UserID, app.APIKey, secretHash, app.Status, app.RateLimit, pq.Array(app.Scopes), app.UpdatedAt, app.Version)
 	} else {
-		_, execErr = ar.repo.execCommand(ctx, query, app.ID, app.Name, app.Description, app.UserID, app.APIKey, app.APISecret, app.Status, app.RateLimit, app.UpdatedAt)
+		result, execErr = ar.repo.execCommand(ctx, query, app.ID, app.Name, app.Description, app.UserID, app.APIKey, secretHash, app.Status, app.RateLimit, pq.Array(app.Scopes), app.UpdatedAt, app.Version)
 	}
 
 	if execErr != nil {
@@ -223,28 +250,69 @@ func (ar *ApplicationRepository) UpdateApplication(ctx context.Context, app *por
 		return execErr
 	}
 
+	// The application exists, so no row was updated because it was modified
+	// since it was read
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return portal.NewDatabaseError("ROWS_AFFECTED_FAILED", "failed to get rows affected", err)
+	}
+	if rowsAffected == 0 {
+		return portal.NewConflictError("STALE_VERSION", "application has been modified since it was read")
+	}
+	app.Version++
+
 	return nil
 }
 
 // DeleteApplication deletes an application by ID
-func (ar *ApplicationRepository) DeleteApplication(ctx context.Context, appID string) error {
+func (ar *ApplicationRepository) DeleteApplication(ctx context.Context, appID string) (err error) {
+	defer ar.repo.metrics.Track(instrument.ApplicationRepository, "DeleteApplication")(&err)
+
 	if appID == "" {
 		return portal.NewValidationError("INVALID_APPLICATION_ID", "application ID cannot be empty")
 	}
 
-	// Check if application exists
-	_, err := ar.GetApplication(ctx, appID)
+	// Soft-deleted applications are deleted for good too
+	query := `DELETE FROM applications WHERE id = $1`
+
+	var result sql.Result
+	if ar.tx != nil {
+		result, err = ar.tx.execCommand(ctx, query, appID)
+	} else {
+		result, err = ar.repo.execCommand(ctx, query, appID)
+	}
+
 	if err != nil {
 		return err
 	}
 
-	query := `DELETE FROM applications WHERE id = $1`
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return portal.NewDatabaseError("ROWS_AFFECTED_FAILED", "failed to get rows affected", err)
+	}
+
+	if rowsAffected == 0 {
+		return portal.NewNotFoundError("APPLICATION_NOT_FOUND", "application not found")
+	}
+
+	return nil
+}
+
+// SoftDeleteApplication marks an application deleted, releasing its API key
+func (ar *ApplicationRepository) SoftDeleteApplication(ctx context.Context, appID string) (err error) {
+	defer ar.repo.metrics.Track(instrument.ApplicationRepository, "SoftDeleteApplication")(&err)
+
+	if appID == "" {
+		return portal.NewValidationError("INVALID_APPLICATION_ID", "application ID cannot be empty")
+	}
+
+	query := `UPDATE applications SET deleted_at = $2, updated_at = $2, version = version + 1 WHERE id = $1 AND deleted_at IS NULL`
 
 	var result sql.Result
 	if ar.tx != nil {
-		result, err = ar.tx.execCommand(ctx, query, appID)
+		result, err = ar.tx.execCommand(ctx, query, appID, time.Now())
 	} else {
-		result, err = ar.repo.execCommand(ctx, query, appID)
+		result, err = ar.repo.execCommand(ctx, query, appID, time.Now())
 	}
 
 	if err != nil {
@@ -263,13 +331,52 @@ func (ar *ApplicationRepository) DeleteApplication(ctx context.Context, appID st
 	return nil
 }
 
+// RestoreApplication clears the deletion mark of a soft-deleted application
+func (ar *ApplicationRepository) RestoreApplication(ctx context.Context, appID string) (err error) {
+	defer ar.repo.metrics.Track(instrument.ApplicationRepository, "RestoreApplication")(&err)
+
+	if appID == "" {
+		return portal.NewValidationError("INVALID_APPLICATION_ID", "application ID cannot be empty")
+	}
+
+	query := `UPDATE applications SET deleted_at = NULL, updated_at = $2, version = version + 1 WHERE id = $1 AND deleted_at IS NOT NULL`
+
+	var result sql.Result
+	if ar.tx != nil {
+		result, err = ar.tx.execCommand(ctx, query, appID, time.Now())
+	} else {
+		result, err = ar.repo.execCommand(ctx, query, appID, time.Now())
+	}
+
+	if err != nil {
+		// The API key may have been reused since the application was deleted
+		if isUniqueViolation(err) && strings.Contains(err.Error(), "applications_api_key_key") {
+			return portal.NewConflictError("APPLICATION_API_KEY_EXISTS", "application with this API key already exists")
+		}
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return portal.NewDatabaseError("ROWS_AFFECTED_FAILED", "failed to get rows affected", err)
+	}
+
+	if rowsAffected == 0 {
+		return portal.NewNotFoundError("APPLICATION_NOT_FOUND", "deleted application not found")
+	}
+
+	return nil
+}
+
 // ExistsApplication checks if an application exists by ID
-func (ar *ApplicationRepository) ExistsApplication(ctx context.Context, appID string) (bool, error) {
+func (ar *ApplicationRepository) ExistsApplication(ctx context.Context, appID string) (_ bool, err error) {
+	defer ar.repo.metrics.Track(instrument.ApplicationRepository, "ExistsApplication")(&err)
+
 	if appID == "" {
 		return false, portal.NewValidationError("INVALID_APPLICATION_ID", "application ID cannot be empty")
 	}
 
-	query := `SELECT 1 FROM applications WHERE id = $1 LIMIT 1`
+	query := `SELECT 1 FROM applications WHERE id = $1 AND deleted_at IS NULL LIMIT 1`
 
 	var exists int
 	var row *sql.Row
@@ -279,7 +386,7 @@ func (ar *ApplicationRepository) ExistsApplication(ctx context.Context, appID st
 		row = ar.repo.execQueryRow(ctx, query, appID)
 	}
 
-	err := row.Scan(&exists)
+	err = row.Scan(&exists)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return false, nil
@@ -291,12 +398,14 @@ func (ar *ApplicationRepository) ExistsApplication(ctx context.Context, appID st
 }
 
 // ExistsApplicationByAPIKey checks if an application exists by API key
-func (ar *ApplicationRepository) ExistsApplicationByAPIKey(ctx context.Context, apiKey string) (bool, error) {
+func (ar *ApplicationRepository) ExistsApplicationByAPIKey(ctx context.Context, apiKey string) (_ bool, err error) {
+	defer ar.repo.metrics.Track(instrument.ApplicationRepository, "ExistsApplicationByAPIKey")(&err)
+
 	if apiKey == "" {
 		return false, portal.NewValidationError("INVALID_API_KEY", "API key cannot be empty")
 	}
 
-	query := `SELECT 1 FROM applications WHERE api_key = $1 LIMIT 1`
+	query := `SELECT 1 FROM applications WHERE api_key = $1 AND deleted_at IS NULL LIMIT 1`
 
 	var exists int
 	var row *sql.Row
@@ -306,7 +415,7 @@ func (ar *ApplicationRepository) ExistsApplicationByAPIKey(ctx context.Context,
 		row = ar.repo.execQueryRow(ctx, query, apiKey)
 	}
 
-	err := row.Scan(&exists)
+	err = row.Scan(&exists)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return false, nil
@@ -318,15 +427,16 @@ func (ar *ApplicationRepository) ExistsApplicationByAPIKey(ctx context.Context,
 }
 
 // UpdateApplicationStatus updates the status of an application
-func (ar *ApplicationRepository) UpdateApplicationStatus(ctx context.Context, appID string, status portal.ApplicationStatus) error {
+func (ar *ApplicationRepository) UpdateApplicationStatus(ctx context.Context, appID string, status portal.ApplicationStatus) (err error) {
+	defer ar.repo.metrics.Track(instrument.ApplicationRepository, "UpdateApplicationStatus")(&err)
+
 	if appID == "" {
 		return portal.NewValidationError("INVALID_APPLICATION_ID", "application ID cannot be empty")
 	}
 
-	query := `UPDATE applications SET status = $2, updated_at = $3 WHERE id = $1`
+	query := `UPDATE applications SET status = $2, updated_at = $3, version = version + 1 WHERE id = $1 AND deleted_at IS NULL`
 
 	var result sql.Result
-	var err error
 	if ar.tx != nil {
 		result, err = ar.tx.execCommand(ctx, query, appID, status, time.Now())
 	} else {
@@ -350,7 +460,9 @@ func (ar *ApplicationRepository) UpdateApplicationStatus(ctx context.Context, ap
 }
 
 // UpdateApplicationRateLimit updates the rate limit of an application
-func (ar *ApplicationRepository) UpdateApplicationRateLimit(ctx context.Context, appID string, rateLimit int64) error {
+func (ar *ApplicationRepository) UpdateApplicationRateLimit(ctx context.Context, appID string, rateLimit int64) (err error) {
+	defer ar.repo.metrics.Track(instrument.ApplicationRepository, "UpdateApplicationRateLimit")(&err)
+
 	if appID == "" {
 		return portal.NewValidationError("INVALID_APPLICATION_ID", "application ID cannot be empty")
 	}
@@ -359,10 +471,9 @@ func (ar *ApplicationRepository) UpdateApplicationRateLimit(ctx context.Context,
 		return portal.NewValidationError("INVALID_RATE_LIMIT", "rate limit cannot be negative")
 	}
 
-	query := `UPDATE applications SET rate_limit = $2, updated_at = $3 WHERE id = $1`
+	query := `UPDATE applications SET rate_limit = $2, updated_at = $3, version = version + 1 WHERE id = $1 AND deleted_at IS NULL`
 
 	var result sql.Result
-	var err error
 	if ar.tx != nil {
 		result, err = ar.tx.execCommand(ctx, query, appID, rateLimit, time.Now())
 	} else {
@@ -385,14 +496,381 @@ func (ar *ApplicationRepository) UpdateApplicationRateLimit(ctx context.Context,
 	return nil
 }
 
+// GetApplicationScopes retrieves the scopes granted to an application
+func (ar *ApplicationRepository) GetApplicationScopes(ctx context.Context, appID string) (_ []string, err error) {
+	defer ar.repo.metrics.Track(instrument.ApplicationRepository, "GetApplicationScopes")(&err)
+
+	if appID == "" {
+		return nil, portal.NewValidationError("INVALID_APPLICATION_ID", "application ID cannot be empty")
+	}
+
+	query := `SELECT scopes FROM applications WHERE id = $1 AND deleted_at IS NULL`
+
+	var row *sql.Row
+	if ar.tx != nil {
+		row = ar.tx.execQueryRow(ctx, query, appID)
+	} else {
+		row = ar.repo.execQueryRow(ctx, query, appID)
+	}
+
+	var scopes []string
+	if err := row.Scan(pq.Array(&scopes)); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, portal.NewNotFoundError("APPLICATION_NOT_FOUND", "application not found")
+		}
+		return nil, portal.NewDatabaseError("SCAN_FAILED", "failed to scan application scopes", err)
+	}
+
+	return scopes, nil
+}
+
+// SetApplicationScopes replaces the scopes granted to an application
+func (ar *ApplicationRepository) SetApplicationScopes(ctx context.Context, appID string, scopes []string) (err error) {
+	defer ar.repo.metrics.Track(instrument.ApplicationRepository, "SetApplicationScopes")(&err)
+
+	if err := portal.ValidateScopes(scopes, ar.repo.scopes); err != nil {
+		return err
+	}
+
+	query := `UPDATE applications SET scopes = $2, updated_at = $3, version = version + 1 WHERE id = $1 AND deleted_at IS NULL`
+	return ar.updateScopes(ctx, query, appID, portal.NormalizeScopes(scopes))
+}
+
+// AddApplicationScopes grants additional scopes to an application
+func (ar *ApplicationRepository) AddApplicationScopes(ctx context.Context, appID string, scopes []string) (err error) {
+	defer ar.repo.metrics.Track(instrument.ApplicationRepository, "AddApplicationScopes")(&err)
+
+	if err := portal.ValidateScopes(scopes, ar.repo.scopes); err != nil {
+		return err
+	}
+
+	// Merge in a single statement so concurrent changes aren't lost
+	query := `
+		UPDATE applications
+		SET scopes = ARRAY(SELECT DISTINCT s FROM unnest(scopes || $2::text[]) AS s ORDER BY s COLLATE "C"), updated_at = $3, version = version + 1
+		WHERE id = $1 AND deleted_at IS NULL`
+	return ar.updateScopes(ctx, query, appID, portal.NormalizeScopes(scopes))
+}
+
+// RemoveApplicationScopes revokes scopes from an application
+func (ar *ApplicationRepository) RemoveApplicationScopes(ctx context.Context, appID string, scopes []string) (err error) {
+	defer ar.repo.metrics.Track(instrument.ApplicationRepository, "RemoveApplicationScopes")(&err)
+
+	query := `
+		UPDATE applications
+		SET scopes = ARRAY(SELECT s FROM unnest(scopes) AS s WHERE s <> ALL($2::text[]) ORDER BY s COLLATE "C"), updated_at = $3, version = version + 1
+		WHERE id = $1 AND deleted_at IS NULL`
+	return ar.updateScopes(ctx, query, appID, portal.NormalizeScopes(scopes))
+}
+
+// updateScopes runs a scope update query taking the application ID, the
+// scopes and the update time
+func (ar *ApplicationRepository) updateScopes(ctx context.Context, query, appID string, scopes []string) error {
+	if appID == "" {
+		return portal.NewValidationError("INVALID_APPLICATION_ID", "application ID cannot be empty")
+	}
+
+	var result sql.Result
+	var err error
+	if ar.tx != nil {
+		result, err = ar.tx.execCommand(ctx, query, appID, pq.Array(scopes), time.Now())
+	} else {
+		result, err = ar.repo.execCommand(ctx, query, appID, pq.Array(scopes), time.Now())
+	}
+
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return portal.NewDatabaseError("ROWS_AFFECTED_FAILED", "failed to get rows affected", err)
+	}
+
+	if rowsAffected == 0 {
+		return portal.NewNotFoundError("APPLICATION_NOT_FOUND", "application not found")
+	}
+
+	return nil
+}
+
+// RecordApplicationsLastUsed records when applications were last used
+func (ar *ApplicationRepository) RecordApplicationsLastUsed(ctx context.Context, lastUsed map[string]time.Time) (err error) {
+	defer ar.repo.metrics.Track(instrument.ApplicationRepository, "RecordApplicationsLastUsed")(&err)
+
+	if len(lastUsed) == 0 {
+		return nil
+	}
+
+	appIDs := make([]string, 0, len(lastUsed))
+	usedAts := make([]string, 0, len(lastUsed))
+	for appID, usedAt := range lastUsed {
+		appIDs = append(appIDs, appID)
+		usedAts = append(usedAts, usedAt.Format(time.RFC3339Nano))
+	}
+
+	// Only move last_used_at forward, so late batches don't overwrite newer uses
+	query := `
+		UPDATE applications AS a
+		SET last_used_at = u.used_at
+		FROM unnest($1::text[], $2::timestamptz[]) AS u(id, used_at)
+		WHERE a.id = u.id AND a.deleted_at IS NULL AND (a.last_used_at IS NULL OR a.last_used_at < u.used_at)`
+
+	if ar.tx != nil {
+		_, err = ar.tx.execCommand(ctx, query, pq.Array(appIDs), pq.Array(usedAts))
+	} else {
+		_, err = ar.repo.execCommand(ctx, query, pq.Array(appIDs), pq.Array(usedAts))
+	}
+	return err
+}
+
+// ListDormantApplications retrieves the active applications not used since the given time
+func (ar *ApplicationRepository) ListDormantApplications(ctx context.Context, unusedSince time.Time) (_ []*portal.Application, err error) {
+	defer ar.repo.metrics.Track(instrument.ApplicationRepository, "ListDormantApplications")(&err)
+
+	query := `
+		SELECT id, name, description, user_id, api_key, status, rate_limit, scopes, created_at, updated_at, last_used_at, deleted_at, version
+		FROM applications
+		WHERE deleted_at IS NULL AND status = 'active' AND COALESCE(last_used_at, created_at) < $1
+		ORDER BY COALESCE(last_used_at, created_at), id`
+
+	var rows *sql.Rows
+	if ar.tx != nil {
+		rows, err = ar.tx.execQuery(ctx, query, unusedSince)
+	} else {
+		rows, err = ar.repo.execQuery(ctx, query, unusedSince)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var applications []*portal.Application
+	for rows.Next() {
+		app := &portal.Application{}
+		err := rows.Scan(&app.ID, &app.Name, &app.Description, &app.UserID, &app.APIKey, &app.Status, &app.RateLimit, pq.Array(&app.Scopes), &app.CreatedAt, &app.UpdatedAt, &app.LastUsedAt, &app.DeletedAt, &app.Version)
+		if err != nil {
+			return nil, portal.NewDatabaseError("SCAN_FAILED", "failed to scan application", err)
+		}
+		applications = append(applications, app)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, portal.NewDatabaseError("ROWS_ERROR", "error iterating rows", err)
+	}
+
+	return applications, nil
+}
+
+// SuspendDormantApplications suspends the active applications not used since the given time
+func (ar *ApplicationRepository) SuspendDormantApplications(ctx context.Context, unusedSince time.Time) (_ []string, err error) {
+	defer ar.repo.metrics.Track(instrument.ApplicationRepository, "SuspendDormantApplications")(&err)
+
+	query := `
+		UPDATE applications
+		SET status = 'suspended', updated_at = $2, version = version + 1
+		WHERE deleted_at IS NULL AND status = 'active' AND COALESCE(last_used_at, created_at) < $1
+		RETURNING id`
+
+	var rows *sql.Rows
+	if ar.tx != nil {
+		rows, err = ar.tx.execQuery(ctx, query, unusedSince, time.Now())
+	} else {
+		rows, err = ar.repo.execQuery(ctx, query, unusedSince, time.Now())
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var suspended []string
+	for rows.Next() {
+		var appID string
+		if err := rows.Scan(&appID); err != nil {
+			return nil, portal.NewDatabaseError("SCAN_FAILED", "failed to scan application ID", err)
+		}
+		suspended = append(suspended, appID)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, portal.NewDatabaseError("ROWS_ERROR", "error iterating rows", err)
+	}
+
+	sort.Strings(suspended)
+	return suspended, nil
+}
+
+// TransferApplications moves applications from one user to another atomically
+func (ar *ApplicationRepository) TransferApplications(ctx context.Context, fromUserID, toUserID string, appIDs []string) (err error) {
+	defer ar.repo.metrics.Track(instrument.ApplicationRepository, "TransferApplications")(&err)
+
+	if fromUserID == "" || toUserID == "" {
+		return portal.NewValidationError("INVALID_USER_ID", "user ID cannot be empty")
+	}
+	if fromUserID == toUserID {
+		return portal.NewValidationError("INVALID_TRANSFER", "cannot transfer applications to their owner")
+	}
+	for _, appID := range appIDs {
+		if appID == "" {
+			return portal.NewValidationError("INVALID_APPLICATION_ID", "application ID cannot be empty")
+		}
+	}
+
+	// Use a transaction if not already in one
+	if ar.tx == nil {
+		tx, err := ar.repo.BeginTx(ctx)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback(ctx)
+
+		txAppRepo := tx.ApplicationRepository().(*ApplicationRepository)
+		if err := txAppRepo.TransferApplications(ctx, fromUserID, toUserID, appIDs); err != nil {
+			return err
+		}
+
+		return tx.Commit(ctx)
+	}
+
+	userExists, err := ar.checkUserExists(ctx, toUserID)
+	if err != nil {
+		return err
+	}
+	if !userExists {
+		return portal.NewNotFoundError("USER_NOT_FOUND", fmt.Sprintf("user with ID %s not found", toUserID))
+	}
+
+	now := time.Now()
+	query := `UPDATE applications SET user_id = $2, updated_at = $3, version = version + 1 WHERE user_id = $1 AND deleted_at IS NULL RETURNING id`
+	args := []interface{}{fromUserID, toUserID, now}
+	if len(appIDs) > 0 {
+		query = `UPDATE applications SET user_id = $2, updated_at = $3, version = version + 1 WHERE user_id = $1 AND id = ANY($4) AND deleted_at IS NULL RETURNING id`
+		args = append(args, pq.Array(appIDs))
+	}
+
+	rows, err := ar.tx.execQuery(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	transferred := make(map[string]bool)
+	for rows.Next() {
+		var appID string
+		if err := rows.Scan(&appID); err != nil {
+			return portal.NewDatabaseError("SCAN_FAILED", "failed to scan application ID", err)
+		}
+		transferred[appID] = true
+	}
+
+	if err := rows.Err(); err != nil {
+		return portal.NewDatabaseError("ROWS_ERROR", "error iterating rows", err)
+	}
+
+	// Any application left out doesn't exist or belongs to another user,
+	// which fails the transfer so the transaction is rolled back
+	for _, appID := range appIDs {
+		if !transferred[appID] {
+			return portal.NewNotFoundError("APPLICATION_NOT_FOUND", fmt.Sprintf("application with ID %s not found for user %s", appID, fromUserID))
+		}
+	}
+
+	events := make([]portal.ApplicationEvent, 0, len(transferred))
+	for appID := range transferred {
+		events = append(events, portal.ApplicationEvent{
+			Type:           portal.ApplicationEventTransferred,
+			ApplicationID:  appID,
+			UserID:         toUserID,
+			PreviousUserID: fromUserID,
+			Time:           now,
+		})
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i].ApplicationID < events[j].ApplicationID })
+	ar.tx.addEvents(events)
+
+	return nil
+}
+
+// TransferApplication moves an application to another user
+func (ar *ApplicationRepository) TransferApplication(ctx context.Context, appID, newUserID string) (err error) {
+	defer ar.repo.metrics.Track(instrument.ApplicationRepository, "TransferApplication")(&err)
+
+	if appID == "" {
+		return portal.NewValidationError("INVALID_APPLICATION_ID", "application ID cannot be empty")
+	}
+	if newUserID == "" {
+		return portal.NewValidationError("INVALID_USER_ID", "user ID cannot be empty")
+	}
+
+	// Use a transaction if not already in one
+	if ar.tx == nil {
+		tx, err := ar.repo.BeginTx(ctx)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback(ctx)
+
+		txAppRepo := tx.ApplicationRepository().(*ApplicationRepository)
+		if err := txAppRepo.TransferApplication(ctx, appID, newUserID); err != nil {
+			return err
+		}
+
+		return tx.Commit(ctx)
+	}
+
+	userExists, err := ar.checkUserExists(ctx, newUserID)
+	if err != nil {
+		return err
+	}
+	if !userExists {
+		return portal.NewNotFoundError("USER_NOT_FOUND", fmt.Sprintf("user with ID %s not found", newUserID))
+	}
+
+	// Lock the application so the owner it is transferred from stays current
+	var previousUserID string
+	row := ar.tx.execQueryRow(ctx, `SELECT user_id FROM applications WHERE id = $1 AND deleted_at IS NULL FOR UPDATE`, appID)
+	if err := row.Scan(&previousUserID); err != nil {
+		if err == sql.ErrNoRows {
+			return portal.NewNotFoundError("APPLICATION_NOT_FOUND", "application not found")
+		}
+		return portal.NewDatabaseError("SCAN_FAILED", "failed to scan application owner", err)
+	}
+	if previousUserID == newUserID {
+		return portal.NewValidationError("INVALID_TRANSFER", "cannot transfer an application to its owner")
+	}
+
+	now := time.Now()
+	query := `UPDATE applications SET user_id = $2, updated_at = $3, version = version + 1 WHERE id = $1`
+	if _, err := ar.tx.execCommand(ctx, query, appID, newUserID, now); err != nil {
+		if isForeignKeyViolation(err) {
+			return portal.NewNotFoundError("USER_NOT_FOUND", fmt.Sprintf("user with ID %s not found", newUserID))
+		}
+		return err
+	}
+
+	ar.tx.addEvents([]portal.ApplicationEvent{{
+		Type:           portal.ApplicationEventTransferred,
+		ApplicationID:  appID,
+		UserID:         newUserID,
+		PreviousUserID: previousUserID,
+		Time:           now,
+	}})
+
+	return nil
+}
+
 // RegenerateAPIKey generates a new API key for an application
-func (ar *ApplicationRepository) RegenerateAPIKey(ctx context.Context, appID string) (string, error) {
+func (ar *ApplicationRepository) RegenerateAPIKey(ctx context.Context, appID string) (_ string, err error) {
+	defer ar.repo.metrics.Track(instrument.ApplicationRepository, "RegenerateAPIKey")(&err)
+
 	if appID == "" {
 		return "", portal.NewValidationError("INVALID_APPLICATION_ID", "application ID cannot be empty")
 	}
 
 	// Check if application exists
-	_, err := ar.GetApplication(ctx, appID)
+	_, err = ar.GetApplication(ctx, appID)
 	if err != nil {
 		return "", err
 	}
@@ -403,7 +881,7 @@ func (ar *ApplicationRepository) RegenerateAPIKey(ctx context.Context, appID str
 		return "", portal.NewInternalError("API_KEY_GENERATION_FAILED", "failed to generate API key", err)
 	}
 
-	query := `UPDATE applications SET api_key = $2, updated_at = $3 WHERE id = $1`
+	query := `UPDATE applications SET api_key = $2, updated_at = $3, version = version + 1 WHERE id = $1 AND deleted_at IS NULL`
 
 	var result sql.Result
 	if ar.tx != nil {
@@ -433,30 +911,36 @@ func (ar *ApplicationRepository) RegenerateAPIKey(ctx context.Context, appID str
 }
 
 // RegenerateAPISecret generates a new API secret for an application
-func (ar *ApplicationRepository) RegenerateAPISecret(ctx context.Context, appID string) (string, error) {
+func (ar *ApplicationRepository) RegenerateAPISecret(ctx context.Context, appID string) (_ string, err error) {
+	defer ar.repo.metrics.Track(instrument.ApplicationRepository, "RegenerateAPISecret")(&err)
+
 	if appID == "" {
 		return "", portal.NewValidationError("INVALID_APPLICATION_ID", "application ID cannot be empty")
 	}
 
 	// Check if application exists
-	_, err := ar.GetApplication(ctx, appID)
+	_, err = ar.GetApplication(ctx, appID)
 	if err != nil {
 		return "", err
 	}
 
-	// Generate new API secret
+	// Generate new API secret, returned once and stored hashed
 	newAPISecret, err := ar.generateAPISecret()
 	if err != nil {
 		return "", portal.NewInternalError("API_SECRET_GENERATION_FAILED", "failed to generate API secret", err)
 	}
+	secretHash, err := ar.hashAPISecret(newAPISecret)
+	if err != nil {
+		return "", err
+	}
 
-	query := `UPDATE applications SET api_secret = $2, updated_at = $3 WHERE id = $1`
+	query := `UPDATE applications SET api_secret = $2, updated_at = $3, version = version + 1 WHERE id = $1 AND deleted_at IS NULL`
 
 	var result sql.Result
 	if ar.tx != nil {
-		result, err = ar.tx.execCommand(ctx, query, appID, newAPISecret, time.Now())
+		result, err = ar.tx.execCommand(ctx, query, appID, secretHash, time.Now())
 	} else {
-		result, err = ar.repo.execCommand(ctx, query, appID, newAPISecret, time.Now())
+		result, err = ar.repo.execCommand(ctx, query, appID, secretHash, time.Now())
 	}
 
 	if err != nil {
@@ -475,13 +959,68 @@ func (ar *ApplicationRepository) RegenerateAPISecret(ctx context.Context, appID
 	return newAPISecret, nil
 }
 
+// VerifyAPISecret reports whether a secret matches the API secret of an
+// application, rehashing a secret stored in plaintext or with another
+// algorithm once it matches
+func (ar *ApplicationRepository) VerifyAPISecret(ctx context.Context, appID, presentedSecret string) (_ bool, err error) {
+	defer ar.repo.metrics.Track(instrument.ApplicationRepository, "VerifyAPISecret")(&err)
+
+	if appID == "" {
+		return false, portal.NewValidationError("INVALID_APPLICATION_ID", "application ID cannot be empty")
+	}
+
+	query := `SELECT api_secret FROM applications WHERE id = $1 AND deleted_at IS NULL`
+
+	var row *sql.Row
+	if ar.tx != nil {
+		row = ar.tx.execQueryRow(ctx, query, appID)
+	} else {
+		row = ar.repo.execQueryRow(ctx, query, appID)
+	}
+
+	var stored string
+	if err := row.Scan(&stored); err != nil {
+		if err == sql.ErrNoRows {
+			return false, portal.NewNotFoundError("APPLICATION_NOT_FOUND", "application not found")
+		}
+		return false, portal.NewDatabaseError("SCAN_FAILED", "failed to scan application secret", err)
+	}
+
+	match, rehash := ar.repo.secrets.Verify(stored, presentedSecret)
+	// Rehashing is a write, left to a verification outside read-only
+	// transactions
+	if !rehash || (ar.tx != nil && ar.tx.readOnly) {
+		return match, nil
+	}
+
+	secretHash, err := ar.hashAPISecret(presentedSecret)
+	if err != nil {
+		return false, err
+	}
+
+	// Keep a secret regenerated meanwhile
+	query = `UPDATE applications SET api_secret = $2 WHERE id = $1 AND api_secret = $3`
+	if ar.tx != nil {
+		_, err = ar.tx.execCommand(ctx, query, appID, secretHash, stored)
+	} else {
+		_, err = ar.repo.execCommand(ctx, query, appID, secretHash, stored)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
 // CountApplicationsByUser returns the count of applications for a specific user
-func (ar *ApplicationRepository) CountApplicationsByUser(ctx context.Context, userID string) (int64, error) {
+func (ar *ApplicationRepository) CountApplicationsByUser(ctx context.Context, userID string) (_ int64, err error) {
+	defer ar.repo.metrics.Track(instrument.ApplicationRepository, "CountApplicationsByUser")(&err)
+
 	if userID == "" {
 		return 0, portal.NewValidationError("INVALID_USER_ID", "user ID cannot be empty")
 	}
 
-	query := `SELECT COUNT(*) FROM applications WHERE user_id = $1`
+	query := `SELECT COUNT(*) FROM applications WHERE user_id = $1 AND deleted_at IS NULL`
 
 	var count int64
 	var row *sql.Row
@@ -499,11 +1038,17 @@ func (ar *ApplicationRepository) CountApplicationsByUser(ctx context.Context, us
 }
 
 // ListApplications retrieves applications based on filter criteria
-func (ar *ApplicationRepository) ListApplications(ctx context.Context, filter *portal.ApplicationFilter) (*portal.PaginatedApplications, error) {
+func (ar *ApplicationRepository) ListApplications(ctx context.Context, filter *portal.ApplicationFilter) (_ *portal.PaginatedApplications, err error) {
+	defer ar.repo.metrics.Track(instrument.ApplicationRepository, "ListApplications")(&err)
+
 	if filter == nil {
 		filter = &portal.ApplicationFilter{}
 	}
 
+	if err := filter.Validate(); err != nil {
+		return nil, err
+	}
+
 	// Set default values
 	if filter.Limit <= 0 {
 		filter.Limit = 50
@@ -517,6 +1062,10 @@ func (ar *ApplicationRepository) ListApplications(ctx context.Context, filter *p
 	if filter.SortOrder == "" {
 		filter.SortOrder = "desc"
 	}
+	cursorMode := filter.CursorMode()
+	if cursorMode && filter.PageSize <= 0 {
+		filter.PageSize = 50
+	}
 
 	// Build WHERE clause
 	whereClause, args := ar.buildWhereClause(filter)
@@ -524,31 +1073,55 @@ func (ar *ApplicationRepository) ListApplications(ctx context.Context, filter *p
 	// Build ORDER BY clause
 	orderBy := ar.buildOrderByClause(filter.SortBy, filter.SortOrder)
 
-	// Count total records
-	countQuery := fmt.Sprintf("SELECT COUNT(*) FROM applications %s", whereClause)
-	var total int64
-	var row *sql.Row
+	// Page after the cursor instead of an offset in cursor mode, keeping
+	// the unpaged WHERE clause for the count
+	pageWhereClause, pageArgs := whereClause, args
+	if cursorMode {
+		pageWhereClause, pageArgs, orderBy, err = keysetClause(whereClause, args, filter.Cursor, filter.SortOrder)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	queryRow := ar.repo.execQueryRow
 	if ar.tx != nil {
-		row = ar.tx.execQueryRow(ctx, countQuery, args...)
-	} else {
-		row = ar.repo.execQueryRow(ctx, countQuery, args...)
+		queryRow = ar.tx.execQueryRow
 	}
 
-	if err := row.Scan(&total); err != nil {
-		return nil, portal.NewDatabaseError("COUNT_FAILED", "failed to count applications", err)
+	// Count total records, estimating the count when allowed
+	var total int64
+	var estimated bool
+	if filter.EstimateTotal {
+		total, estimated, err = estimateCount(ctx, queryRow, "applications", whereClause, args)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if !estimated {
+		countQuery := fmt.Sprintf("SELECT COUNT(*) FROM applications %s", whereClause)
+		if err := queryRow(ctx, countQuery, args...).Scan(&total); err != nil {
+			return nil, portal.NewDatabaseError("COUNT_FAILED", "failed to count applications", err)
+		}
 	}
 
-	// Query applications with pagination
+	// Query applications with pagination, fetching one more row to tell
+	// whether more follow when the total is estimated or paging with a cursor
+	limit, offset := filter.Limit, filter.Offset
+	if cursorMode {
+		limit, offset = filter.PageSize, 0
+	}
+	if estimated || cursorMode {
+		limit++
+	}
 	query := fmt.Sprintf(`
-		SELECT id, name, description, user_id, api_key, api_secret, status, rate_limit, created_at, updated_at
+		SELECT id, name, description, user_id, api_key, status, rate_limit, scopes, created_at, updated_at, last_used_at, deleted_at, version
 		FROM applications %s %s
 		LIMIT $%d OFFSET $%d`,
-		whereClause, orderBy, len(args)+1, len(args)+2)
+		pageWhereClause, orderBy, len(pageArgs)+1, len(pageArgs)+2)
 
-	args = append(args, filter.Limit, filter.Offset)
+	args = append(pageArgs, limit, offset)
 
 	var rows *sql.Rows
-	var err error
 	if ar.tx != nil {
 		rows, err = ar.tx.execQuery(ctx, query, args...)
 	} else {
@@ -563,7 +1136,7 @@ func (ar *ApplicationRepository) ListApplications(ctx context.Context, filter *p
 	var applications []*portal.Application
 	for rows.Next() {
 		app := &portal.Application{}
-		err := rows.Scan(&app.ID, &app.Name, &app.Description, &app.UserID, &app.APIKey, &app.APISecret, &app.Status, &app.RateLimit, &app.CreatedAt, &app.UpdatedAt)
+		err := rows.Scan(&app.ID, &app.Name, &app.Description, &app.UserID, &app.APIKey, &app.Status, &app.RateLimit, pq.Array(&app.Scopes), &app.CreatedAt, &app.UpdatedAt, &app.LastUsedAt, &app.DeletedAt, &app.Version)
 		if err != nil {
 			return nil, portal.NewDatabaseError("SCAN_FAILED", "failed to scan application", err)
 		}
@@ -574,23 +1147,57 @@ func (ar *ApplicationRepository) ListApplications(ctx context.Context, filter *p
 		return nil, portal.NewDatabaseError("ROWS_ERROR", "error iterating rows", err)
 	}
 
+	if cursorMode {
+		var nextCursor string
+		if len(applications) > filter.PageSize {
+			applications = applications[:filter.PageSize]
+			last := applications[len(applications)-1]
+			nextCursor = portal.EncodeCursor(last.CreatedAt, last.ID)
+		}
+		if estimated && total < int64(len(applications)) {
+			total = int64(len(applications))
+		}
+		return &portal.PaginatedApplications{
+			Applications:   applications,
+			Total:          total,
+			Limit:          filter.PageSize,
+			HasMore:        nextCursor != "",
+			TotalEstimated: estimated,
+			NextCursor:     nextCursor,
+		}, nil
+	}
+
 	hasMore := int64(filter.Offset)+int64(len(applications)) < total
+	if estimated {
+		hasMore = len(applications) > filter.Limit
+		if hasMore {
+			applications = applications[:filter.Limit]
+		}
+		total = paginatedTotal(total, filter.Offset, len(applications), hasMore)
+	}
 
 	return &portal.PaginatedApplications{
-		Applications: applications,
-		Total:        total,
-		Offset:       filter.Offset,
-		Limit:        filter.Limit,
-		HasMore:      hasMore,
+		Applications:   applications,
+		Total:          total,
+		Offset:         filter.Offset,
+		Limit:          filter.Limit,
+		HasMore:        hasMore,
+		TotalEstimated: estimated,
 	}, nil
 }
 
 // CountApplications returns the total count of applications matching the filter
-func (ar *ApplicationRepository) CountApplications(ctx context.Context, filter *portal.ApplicationFilter) (int64, error) {
+func (ar *ApplicationRepository) CountApplications(ctx context.Context, filter *portal.ApplicationFilter) (_ int64, err error) {
+	defer ar.repo.metrics.Track(instrument.ApplicationRepository, "CountApplications")(&err)
+
 	if filter == nil {
 		filter = &portal.ApplicationFilter{}
 	}
 
+	if err := filter.Validate(); err != nil {
+		return 0, err
+	}
+
 	whereClause, args := ar.buildWhereClause(filter)
 	query := fmt.Sprintf("SELECT COUNT(*) FROM applications %s", whereClause)
 
@@ -609,8 +1216,65 @@ func (ar *ApplicationRepository) CountApplications(ctx context.Context, filter *
 	return count, nil
 }
 
+// BatchGetApplications retrieves multiple applications by IDs in a single
+// query, leaving out the ones that don't exist
+func (ar *ApplicationRepository) BatchGetApplications(ctx context.Context, appIDs []string) (_ map[string]*portal.Application, err error) {
+	defer ar.repo.metrics.Track(instrument.ApplicationRepository, "BatchGetApplications")(&err)
+
+	apps := make(map[string]*portal.Application, len(appIDs))
+	if len(appIDs) == 0 {
+		return apps, nil
+	}
+
+	// Query applications in batch using IN clause
+	placeholders := make([]string, len(appIDs))
+	args := make([]interface{}, len(appIDs))
+	for i, appID := range appIDs {
+		if appID == "" {
+			return nil, portal.NewValidationError("INVALID_APPLICATION_ID", "application ID cannot be empty")
+		}
+		placeholders[i] = fmt.Sprintf("$%d", i+1)
+		args[i] = appID
+	}
+
+	query := fmt.Sprintf(`
+		SELECT id, name, description, user_id, api_key, status, rate_limit, scopes, created_at, updated_at, last_used_at, deleted_at, version
+		FROM applications
+		WHERE id IN (%s) AND deleted_at IS NULL`,
+		strings.Join(placeholders, ","))
+
+	var rows *sql.Rows
+	if ar.tx != nil {
+		rows, err = ar.tx.execQuery(ctx, query, args...)
+	} else {
+		rows, err = ar.repo.execQuery(ctx, query, args...)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		app := &portal.Application{}
+		err := rows.Scan(&app.ID, &app.Name, &app.Description, &app.UserID, &app.APIKey, &app.Status, &app.RateLimit, pq.Array(&app.Scopes), &app.CreatedAt, &app.UpdatedAt, &app.LastUsedAt, &app.DeletedAt, &app.Version)
+		if err != nil {
+			return nil, portal.NewDatabaseError("SCAN_FAILED", "failed to scan application", err)
+		}
+		apps[app.ID] = app
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, portal.NewDatabaseError("ROWS_ERROR", "error iterating rows", err)
+	}
+
+	return apps, nil
+}
+
 // BatchCreateApplications creates multiple applications in a single transaction
-func (ar *ApplicationRepository) BatchCreateApplications(ctx context.Context, apps []*portal.Application) error {
+func (ar *ApplicationRepository) BatchCreateApplications(ctx context.Context, apps []*portal.Application) (err error) {
+	defer ar.repo.metrics.Track(instrument.ApplicationRepository, "BatchCreateApplications")(&err)
+
 	if len(apps) == 0 {
 		return nil
 	}
@@ -640,8 +1304,8 @@ func (ar *ApplicationRepository) BatchCreateApplications(ctx context.Context, ap
 
 	// Insert applications in batch
 	query := `
-		INSERT INTO applications (id, name, description, user_id, api_key, api_secret, status, rate_limit, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`
+		INSERT INTO applications (id, name, description, user_id, api_key, api_secret, status, rate_limit, scopes, created_at, updated_at, last_used_at, version)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)`
 
 	now := time.Now()
 	for _, app := range apps {
@@ -658,8 +1322,15 @@ func (ar *ApplicationRepository) BatchCreateApplications(ctx context.Context, ap
 			app.CreatedAt = now
 		}
 		app.UpdatedAt = now
+		app.Version = 1
+		app.Scopes = portal.NormalizeScopes(app.Scopes)
 
-		_, err = ar.tx.execCommand(ctx, query, app.ID, app.Name, app.Description, app.UserID, app.APIKey, app.APISecret, app.Status, app.RateLimit, app.CreatedAt, app.UpdatedAt)
+		secretHash, err := ar.hashAPISecret(app.APISecret)
+		if err != nil {
+			return err
+		}
+
+		_, err = ar.tx.execCommand(ctx, query, app.ID, app.Name, app.Description, app.UserID, app.APIKey, secretHash, app.Status, app.RateLimit, pq.Array(app.Scopes), app.CreatedAt, app.UpdatedAt, app.LastUsedAt, app.Version)
 		if err != nil {
 			if isUniqueViolation(err) {
 				if strings.Contains(err.Error(), "applications_pkey") {
@@ -680,7 +1351,9 @@ func (ar *ApplicationRepository) BatchCreateApplications(ctx context.Context, ap
 }
 
 // BatchUpdateApplications updates multiple applications in a single transaction
-func (ar *ApplicationRepository) BatchUpdateApplications(ctx context.Context, apps []*portal.Application) error {
+func (ar *ApplicationRepository) BatchUpdateApplications(ctx context.Context, apps []*portal.Application) (err error) {
+	defer ar.repo.metrics.Track(instrument.ApplicationRepository, "BatchUpdateApplications")(&err)
+
 	if len(apps) == 0 {
 		return nil
 	}
@@ -711,8 +1384,8 @@ func (ar *ApplicationRepository) BatchUpdateApplications(ctx context.Context, ap
 	// Update applications in batch
 	query := `
 		UPDATE applications
-		SET name = $2, description = $3, user_id = $4, api_key = $5, api_secret = $6, status = $7, rate_limit = $8, updated_at = $9
-		WHERE id = $1`
+		SET name = $2, description = $3, user_id = $4, api_key = $5, api_secret = COALESCE(NULLIF($6, ''), api_secret), status = $7, rate_limit = $8, scopes = $9, updated_at = $10, version = version + 1
+		WHERE id = $1 AND version = $11 AND deleted_at IS NULL`
 
 	now := time.Now()
 	for _, app := range apps {
@@ -733,8 +1406,14 @@ func (ar *ApplicationRepository) BatchUpdateApplications(ctx context.Context, ap
 
 		app.CreatedAt = existingApp.CreatedAt // Preserve original creation time
 		app.UpdatedAt = now
+		app.Scopes = portal.NormalizeScopes(app.Scopes)
 
-		_, err = ar.tx.execCommand(ctx, query, app.ID, app.Name, app.Description, app.UserID, app.APIKey, app.APISecret, app.Status, app.RateLimit, app.UpdatedAt)
+		secretHash, err := ar.hashAPISecret(app.APISecret)
+		if err != nil {
+			return err
+		}
+
+		result, err := ar.tx.execCommand(ctx, query, app.ID, app.Name, app.Description, app.UserID, app.APIKey, secretHash, app.Status, app.RateLimit, pq.Array(app.Scopes), app.UpdatedAt, app.Version)
 		if err != nil {
 			if isUniqueViolation(err) && strings.Contains(err.Error(), "applications_api_key_key") {
 				return portal.NewConflictError("APPLICATION_API_KEY_EXISTS", fmt.Sprintf("application with API key %s already exists", app.APIKey))
@@ -744,13 +1423,24 @@ func (ar *ApplicationRepository) BatchUpdateApplications(ctx context.Context, ap
 			}
 			return err
 		}
+
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return portal.NewDatabaseError("ROWS_AFFECTED_FAILED", "failed to get rows affected", err)
+		}
+		if rowsAffected == 0 {
+			return portal.NewConflictError("STALE_VERSION", fmt.Sprintf("application with ID %s has been modified since it was read", app.ID))
+		}
+		app.Version++
 	}
 
 	return nil
 }
 
 // BatchDeleteApplications deletes multiple applications by IDs
-func (ar *ApplicationRepository) BatchDeleteApplications(ctx context.Context, appIDs []string) error {
+func (ar *ApplicationRepository) BatchDeleteApplications(ctx context.Context, appIDs []string) (err error) {
+	defer ar.repo.metrics.Track(instrument.ApplicationRepository, "BatchDeleteApplications")(&err)
+
 	if len(appIDs) == 0 {
 		return nil
 	}
@@ -833,7 +1523,7 @@ func (ar *ApplicationRepository) validateApplication(app *portal.Application) er
 	if app.Status == "" {
 		return portal.NewValidationError("INVALID_APPLICATION_STATUS", "application status cannot be empty")
 	}
-	return nil
+	return portal.ValidateScopes(app.Scopes, ar.repo.scopes)
 }
 
 // checkUserExists checks if a user exists
@@ -859,6 +1549,16 @@ func (ar *ApplicationRepository) checkUserExists(ctx context.Context, userID str
 	return true, nil
 }
 
+// hashAPISecret returns the hash of an API secret to store, or "" for an
+// empty secret
+func (ar *ApplicationRepository) hashAPISecret(apiSecret string) (string, error) {
+	secretHash, err := ar.repo.secrets.Hash(apiSecret)
+	if err != nil {
+		return "", portal.NewInternalError("API_SECRET_HASH_FAILED", "failed to hash API secret", err)
+	}
+	return secretHash, nil
+}
+
 // generateAPIKey generates a new API key
 func (ar *ApplicationRepository) generateAPIKey() (string, error) {
 	bytes := make([]byte, 16)
@@ -901,6 +1601,12 @@ func (ar *ApplicationRepository) buildWhereClause(filter *portal.ApplicationFilt
 		argIndex++
 	}
 
+	if filter.HasScope != "" {
+		conditions = append(conditions, fmt.Sprintf("scopes @> ARRAY[$%d]::text[]", argIndex))
+		args = append(args, filter.HasScope)
+		argIndex++
+	}
+
 	if filter.Search != "" {
 		conditions = append(conditions, fmt.Sprintf("(name ILIKE $%d OR description ILIKE $%d)", argIndex, argIndex))
 		args = append(args, "%"+filter.Search+"%")
@@ -919,6 +1625,10 @@ func (ar *ApplicationRepository) buildWhereClause(filter *portal.ApplicationFilt
 		argIndex++
 	}
 
+	if !filter.IncludeDeleted {
+		conditions = append(conditions, "deleted_at IS NULL")
+	}
+
 	whereClause := ""
 	if len(conditions) > 0 {
 		whereClause = "WHERE " + strings.Join(conditions, " AND ")
@@ -929,17 +1639,7 @@ func (ar *ApplicationRepository) buildWhereClause(filter *portal.ApplicationFilt
 
 // buildOrderByClause builds the ORDER BY clause
 func (ar *ApplicationRepository) buildOrderByClause(sortBy, sortOrder string) string {
-	validSortFields := map[string]bool{
-		"id":          true,
-		"name":        true,
-		"user_id":     true,
-		"status":      true,
-		"rate_limit":  true,
-		"created_at":  true,
-		"updated_at":  true,
-	}
-
-	if !validSortFields[sortBy] {
+	if !portal.IsApplicationSortField(sortBy) {
 		sortBy = "created_at"
 	}
 
@@ -0,0 +1,5 @@
+-- Migration: Drop application usage table
+-- Version: 000003
+-- Description: Drop minute-resolution application usage counters
+
+DROP TABLE IF EXISTS application_usage;
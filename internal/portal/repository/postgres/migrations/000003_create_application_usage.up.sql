@@ -0,0 +1,19 @@
+-- Migration: Create application usage table
+-- Version: 000003
+-- Description: Create minute-resolution application usage counters
+
+CREATE TABLE application_usage (
+    application_id VARCHAR(255) NOT NULL REFERENCES applications(id) ON DELETE CASCADE,
+    bucket_start TIMESTAMP WITH TIME ZONE NOT NULL,
+    requests BIGINT NOT NULL DEFAULT 0,
+    errors BIGINT NOT NULL DEFAULT 0,
+    bytes BIGINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (application_id, bucket_start)
+);
+
+-- Create indexes for application_usage table
+CREATE INDEX idx_application_usage_bucket_start ON application_usage(bucket_start);
+
+-- Comments for documentation
+COMMENT ON TABLE application_usage IS 'Per-minute application usage counters, aggregated on read';
+COMMENT ON COLUMN application_usage.bucket_start IS 'UTC minute the counters belong to';
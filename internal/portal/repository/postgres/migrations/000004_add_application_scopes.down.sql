@@ -0,0 +1,5 @@
+-- Migration: Drop application scopes
+-- Version: 000004
+-- Description: Drop the scopes granted to each application
+
+ALTER TABLE applications DROP COLUMN IF EXISTS scopes;
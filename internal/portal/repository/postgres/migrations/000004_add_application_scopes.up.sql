@@ -0,0 +1,8 @@
+-- Migration: Add application scopes
+-- Version: 000004
+-- Description: Store the scopes granted to each application
+
+ALTER TABLE applications ADD COLUMN scopes TEXT[] NOT NULL DEFAULT '{}';
+
+-- Comments for documentation
+COMMENT ON COLUMN applications.scopes IS 'Scopes granted to the application, sorted and without duplicates';
@@ -0,0 +1,7 @@
+-- Migration: Drop application last used time
+-- Version: 000005
+-- Description: Drop the time each application's API key was last used
+
+DROP INDEX IF EXISTS idx_applications_last_activity;
+
+ALTER TABLE applications DROP COLUMN IF EXISTS last_used_at;
@@ -0,0 +1,11 @@
+-- Migration: Add application last used time
+-- Version: 000005
+-- Description: Track when each application's API key was last used
+
+ALTER TABLE applications ADD COLUMN last_used_at TIMESTAMP WITH TIME ZONE;
+
+-- Index for finding dormant applications
+CREATE INDEX IF NOT EXISTS idx_applications_last_activity ON applications(COALESCE(last_used_at, created_at)) WHERE status = 'active';
+
+-- Comments for documentation
+COMMENT ON COLUMN applications.last_used_at IS 'Approximate time the application''s API key was last used, NULL if never used';
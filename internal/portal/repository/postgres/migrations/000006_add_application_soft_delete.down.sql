@@ -0,0 +1,13 @@
+-- Migration: Drop application soft delete
+-- Version: 000006
+-- Description: Purge soft-deleted applications and drop their deletion time
+
+DELETE FROM applications WHERE deleted_at IS NOT NULL;
+
+DROP INDEX IF EXISTS idx_applications_last_activity;
+CREATE INDEX IF NOT EXISTS idx_applications_last_activity ON applications(COALESCE(last_used_at, created_at)) WHERE status = 'active';
+
+DROP INDEX IF EXISTS applications_api_key_key;
+ALTER TABLE applications ADD CONSTRAINT applications_api_key_key UNIQUE (api_key);
+
+ALTER TABLE applications DROP COLUMN IF EXISTS deleted_at;
@@ -0,0 +1,18 @@
+-- Migration: Add application soft delete
+-- Version: 000006
+-- Description: Keep deleted applications for auditing, releasing their API key
+
+ALTER TABLE applications ADD COLUMN deleted_at TIMESTAMP WITH TIME ZONE;
+
+-- Only applications that aren't deleted need unique API keys, so the key of
+-- a soft-deleted application can be reused. The index keeps the name of the
+-- constraint it replaces, which conflicts are recognized by.
+ALTER TABLE applications DROP CONSTRAINT IF EXISTS applications_api_key_key;
+CREATE UNIQUE INDEX IF NOT EXISTS applications_api_key_key ON applications(api_key) WHERE deleted_at IS NULL;
+
+-- Recreate the dormant application index without soft-deleted applications
+DROP INDEX IF EXISTS idx_applications_last_activity;
+CREATE INDEX IF NOT EXISTS idx_applications_last_activity ON applications(COALESCE(last_used_at, created_at)) WHERE status = 'active' AND deleted_at IS NULL;
+
+-- Comments for documentation
+COMMENT ON COLUMN applications.deleted_at IS 'Time the application was soft-deleted, NULL unless deleted';
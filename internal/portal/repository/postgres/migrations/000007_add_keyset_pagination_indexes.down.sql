@@ -0,0 +1,9 @@
+-- Migration: Drop keyset pagination indexes
+-- Version: 000007
+-- Description: Restore the created_at indexes of users and applications
+
+DROP INDEX IF EXISTS idx_applications_created_at_id;
+CREATE INDEX IF NOT EXISTS idx_applications_created_at ON applications(created_at);
+
+DROP INDEX IF EXISTS idx_users_created_at_id;
+CREATE INDEX IF NOT EXISTS idx_users_created_at ON users(created_at);
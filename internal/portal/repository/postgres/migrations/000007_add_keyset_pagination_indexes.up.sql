@@ -0,0 +1,11 @@
+-- Migration: Add keyset pagination indexes
+-- Version: 000007
+-- Description: Index users and applications by (created_at, id) for cursor pagination
+
+-- Cursor pagination orders by created_at and then id, which the composite
+-- indexes serve in both directions. They supersede the created_at indexes.
+DROP INDEX IF EXISTS idx_users_created_at;
+CREATE INDEX IF NOT EXISTS idx_users_created_at_id ON users(created_at, id);
+
+DROP INDEX IF EXISTS idx_applications_created_at;
+CREATE INDEX IF NOT EXISTS idx_applications_created_at_id ON applications(created_at, id);
@@ -0,0 +1,5 @@
+-- Migration: Drop application version
+-- Version: 000008
+-- Description: Drop the optimistic concurrency control version of applications
+
+ALTER TABLE applications DROP COLUMN IF EXISTS version;
@@ -0,0 +1,10 @@
+-- Migration: Add application version
+-- Version: 000008
+-- Description: Version applications for optimistic concurrency control
+
+-- Every change increments the version, and updates only apply to the version
+-- they were based on, so concurrent edits can't silently overwrite each other
+ALTER TABLE applications ADD COLUMN version BIGINT NOT NULL DEFAULT 1;
+
+-- Comments for documentation
+COMMENT ON COLUMN applications.version IS 'Incremented by every change, updates based on an older version are rejected';
@@ -0,0 +1,5 @@
+-- Migration: Drop application scopes index
+-- Version: 000009
+-- Description: Drop the index of the scopes granted to applications
+
+DROP INDEX IF EXISTS idx_applications_scopes;
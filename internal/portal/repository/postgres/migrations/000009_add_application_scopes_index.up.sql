@@ -0,0 +1,5 @@
+-- Migration: Add application scopes index
+-- Version: 000009
+-- Description: Index the scopes granted to applications to filter by scope
+
+CREATE INDEX IF NOT EXISTS idx_applications_scopes ON applications USING GIN (scopes);
@@ -0,0 +1,5 @@
+-- Migration: Drop case-insensitive user email index
+-- Version: 000010
+-- Description: Drop the index making user emails unique ignoring case
+
+DROP INDEX IF EXISTS users_email_lower_key;
@@ -0,0 +1,8 @@
+-- Migration: Add case-insensitive user email index
+-- Version: 000010
+-- Description: Make user emails unique ignoring case, storing them in lowercase
+
+-- Users are stored and looked up by lowercase email. This fails if two users
+-- have emails differing only in case, which must be merged by hand first.
+UPDATE users SET email = lower(email) WHERE email <> lower(email);
+CREATE UNIQUE INDEX IF NOT EXISTS users_email_lower_key ON users(lower(email));
@@ -0,0 +1,6 @@
+-- Migration: Drop user last login time
+-- Version: 000011
+-- Description: Stop tracking when each user last logged in
+
+DROP INDEX IF EXISTS idx_users_last_login_at;
+ALTER TABLE users DROP COLUMN IF EXISTS last_login_at;
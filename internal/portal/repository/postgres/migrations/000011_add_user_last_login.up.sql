@@ -0,0 +1,11 @@
+-- Migration: Add user last login time
+-- Version: 000011
+-- Description: Track when each user last logged in to find dormant accounts
+
+ALTER TABLE users ADD COLUMN last_login_at TIMESTAMP WITH TIME ZONE;
+
+-- Index for finding dormant users
+CREATE INDEX IF NOT EXISTS idx_users_last_login_at ON users(last_login_at);
+
+-- Comments for documentation
+COMMENT ON COLUMN users.last_login_at IS 'Time the user last logged in, NULL if never logged in';
@@ -3,13 +3,17 @@ package postgres
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
 
 	"github.com/golang-migrate/migrate/v4"
 	"github.com/golang-migrate/migrate/v4/database/postgres"
 	_ "github.com/golang-migrate/migrate/v4/source/file"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
+	"github.com/songzhibin97/stargate/internal/portal/repository/instrument"
+	"github.com/songzhibin97/stargate/internal/portal/repository/secret"
 	"github.com/songzhibin97/stargate/pkg/portal"
 )
 
@@ -21,6 +25,43 @@ type Repository struct {
 	maxIdleConns   int
 	connMaxLifetime time.Duration
 	migrationPath  string
+	metrics        *instrument.Recorder
+	scopes         []string // Scopes applications may be granted, any when empty
+	events         portal.ApplicationEventHandler
+	secrets        *secret.Hasher // Hashes API secrets at rest
+}
+
+// Option configures a PostgreSQL repository
+type Option func(*Repository)
+
+// WithMetrics enables per-method call, latency and error instrumentation
+func WithMetrics(recorder *instrument.Recorder) Option {
+	return func(r *Repository) {
+		r.metrics = recorder
+	}
+}
+
+// WithAllowedScopes restricts the scopes applications may be granted
+func WithAllowedScopes(scopes []string) Option {
+	return func(r *Repository) {
+		r.scopes = scopes
+	}
+}
+
+// WithApplicationEvents reports committed application changes to the handler
+func WithApplicationEvents(handler portal.ApplicationEventHandler) Option {
+	return func(r *Repository) {
+		r.events = handler
+	}
+}
+
+// WithSecretHasher hashes API secrets at rest with the hasher instead of
+// bcrypt. Secrets stored in plaintext or with another algorithm are rehashed
+// once verified.
+func WithSecretHasher(hasher *secret.Hasher) Option {
+	return func(r *Repository) {
+		r.secrets = hasher
+	}
 }
 
 // Config holds the configuration for PostgreSQL repository
@@ -44,7 +85,7 @@ func DefaultConfig() *Config {
 }
 
 // NewRepository creates a new PostgreSQL repository
-func NewRepository(config *Config) (*Repository, error) {
+func NewRepository(config *Config, opts ...Option) (*Repository, error) {
 	if config == nil {
 		config = DefaultConfig()
 	}
@@ -76,6 +117,10 @@ func NewRepository(config *Config) (*Repository, error) {
 		maxIdleConns:    config.MaxIdleConns,
 		connMaxLifetime: config.ConnMaxLifetime,
 		migrationPath:   config.MigrationPath,
+		secrets:         secret.Default(),
+	}
+	for _, opt := range opts {
+		opt(repo)
 	}
 
 	return repo, nil
@@ -141,6 +186,28 @@ func (r *Repository) Health(ctx context.Context) portal.HealthStatus {
 	}
 }
 
+// Stats returns the connection pool usage of the repository and the number
+// of users and applications
+func (r *Repository) Stats(ctx context.Context) (*portal.RepositoryStats, error) {
+	dbStats := r.db.Stats()
+	stats := &portal.RepositoryStats{
+		OpenConnections:    dbStats.OpenConnections,
+		InUseConnections:   dbStats.InUse,
+		IdleConnections:    dbStats.Idle,
+		MaxOpenConnections: dbStats.MaxOpenConnections,
+		WaitCount:          dbStats.WaitCount,
+		WaitDuration:       dbStats.WaitDuration,
+		Timestamp:          time.Now(),
+	}
+
+	query := `SELECT (SELECT COUNT(*) FROM users), (SELECT COUNT(*) FROM applications WHERE deleted_at IS NULL)`
+	if err := r.execQueryRow(ctx, query).Scan(&stats.Users, &stats.Applications); err != nil {
+		return nil, portal.NewDatabaseError("STATS_FAILED", "failed to count users and applications", err)
+	}
+
+	return stats, nil
+}
+
 // Close closes the repository connection and releases resources
 func (r *Repository) Close() error {
 	if r.db != nil {
@@ -149,14 +216,27 @@ func (r *Repository) Close() error {
 	return nil
 }
 
-// BeginTx begins a transaction
+// BeginTx begins a transaction with the default options
 func (r *Repository) BeginTx(ctx context.Context) (portal.Transaction, error) {
-	tx, err := r.db.BeginTx(ctx, nil)
+	return r.BeginTxWithOptions(ctx, nil)
+}
+
+// BeginTxWithOptions begins a transaction with the options, the default
+// options if nil
+func (r *Repository) BeginTxWithOptions(ctx context.Context, opts *portal.TxOptions) (portal.Transaction, error) {
+	var txOpts *sql.TxOptions
+	if opts != nil {
+		txOpts = &sql.TxOptions{Isolation: opts.Isolation, ReadOnly: opts.ReadOnly}
+	}
+
+	tx, err := r.db.BeginTx(ctx, txOpts)
 	if err != nil {
 		return nil, portal.NewDatabaseError("TX_BEGIN_FAILED", "failed to begin transaction", err)
 	}
 
-	return NewTransaction(r, tx), nil
+	transaction := NewTransaction(r, tx)
+	transaction.readOnly = txOpts != nil && txOpts.ReadOnly
+	return transaction, nil
 }
 
 // DB returns the underlying database connection (for internal use)
@@ -213,12 +293,103 @@ func (r *Repository) execCommand(ctx context.Context, query string, args ...inte
 	return result, nil
 }
 
+// queryRowFunc executes a query that returns a single row, in or out of a
+// transaction
+type queryRowFunc func(ctx context.Context, query string, args ...interface{}) *sql.Row
+
+// estimateCount returns the planner's estimate of the rows of the table
+// matching the WHERE clause, or false when the table has no statistics yet
+func estimateCount(ctx context.Context, queryRow queryRowFunc, table, whereClause string, args []interface{}) (int64, bool, error) {
+	if whereClause == "" {
+		// reltuples is -1 until the table is first vacuumed or analyzed
+		var reltuples float64
+		row := queryRow(ctx, "SELECT reltuples FROM pg_class WHERE oid = $1::regclass", table)
+		if err := row.Scan(&reltuples); err != nil {
+			return 0, false, portal.NewDatabaseError("COUNT_FAILED", fmt.Sprintf("failed to estimate %s count", table), err)
+		}
+		if reltuples < 0 {
+			return 0, false, nil
+		}
+		return int64(reltuples), true, nil
+	}
+
+	var plan string
+	row := queryRow(ctx, fmt.Sprintf("EXPLAIN (FORMAT JSON) SELECT 1 FROM %s %s", table, whereClause), args...)
+	if err := row.Scan(&plan); err != nil {
+		return 0, false, portal.NewDatabaseError("COUNT_FAILED", fmt.Sprintf("failed to estimate %s count", table), err)
+	}
+
+	var explained []struct {
+		Plan struct {
+			Rows float64 `json:"Plan Rows"`
+		} `json:"Plan"`
+	}
+	if err := json.Unmarshal([]byte(plan), &explained); err != nil || len(explained) == 0 {
+		return 0, false, portal.NewDatabaseError("COUNT_FAILED", fmt.Sprintf("failed to parse %s count estimate", table), err)
+	}
+	return int64(explained[0].Plan.Rows), true, nil
+}
+
+// paginatedTotal keeps an estimated total consistent with the page: never
+// below the rows paged through, and beyond them while more rows follow
+func paginatedTotal(total int64, offset, count int, hasMore bool) int64 {
+	seen := int64(offset) + int64(count)
+	if hasMore {
+		seen++
+	}
+	if total < seen {
+		return seen
+	}
+	return total
+}
+
+// keysetClause extends a WHERE clause with the keyset condition of cursor
+// pagination, selecting the rows after the cursor, and returns it with its
+// arguments and the ORDER BY clause ordering rows by created_at and then id
+func keysetClause(whereClause string, args []interface{}, cursor, sortOrder string) (string, []interface{}, string, error) {
+	direction, comparison := "DESC", "<"
+	if sortOrder == "asc" {
+		direction, comparison = "ASC", ">"
+	}
+	orderBy := fmt.Sprintf("ORDER BY created_at %s, id %s", direction, direction)
+	if cursor == "" {
+		return whereClause, args, orderBy, nil
+	}
+
+	c, err := portal.DecodeCursor(cursor)
+	if err != nil {
+		return "", nil, "", err
+	}
+	condition := fmt.Sprintf("(created_at, id) %s ($%d, $%d)", comparison, len(args)+1, len(args)+2)
+	if whereClause == "" {
+		whereClause = "WHERE " + condition
+	} else {
+		whereClause += " AND " + condition
+	}
+	keysetArgs := append(append([]interface{}{}, args...), c.CreatedAt, c.ID)
+	return whereClause, keysetArgs, orderBy, nil
+}
+
+// publishEvents reports application changes to the event handler, if any
+func (r *Repository) publishEvents(events []portal.ApplicationEvent) {
+	if r.events == nil {
+		return
+	}
+	for _, event := range events {
+		r.events(event)
+	}
+}
+
 // isUniqueViolation checks if the error is a unique constraint violation
 func isUniqueViolation(err error) bool {
 	if err == nil {
 		return false
 	}
 	// PostgreSQL unique violation error code is 23505
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Code == "23505"
+	}
 	return err.Error() == "pq: duplicate key value violates unique constraint" ||
 		   err.Error() == "ERROR: duplicate key value violates unique constraint"
 }
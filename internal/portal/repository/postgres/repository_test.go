@@ -2,12 +2,15 @@ package postgres
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
 	_ "github.com/lib/pq"
+	"github.com/songzhibin97/stargate/internal/portal/repository/secret"
 	"github.com/songzhibin97/stargate/pkg/portal"
 )
 
@@ -112,6 +115,41 @@ func TestRepository_Health(t *testing.T) {
 	}
 }
 
+func TestRepository_Stats(t *testing.T) {
+	if testRepo == nil {
+		t.Skip("Test database not available")
+	}
+
+	defer cleanupTestData(t)
+
+	ctx := context.Background()
+	userRepo := NewUserRepository(testRepo)
+	appRepo := NewApplicationRepository(testRepo)
+
+	user := &portal.User{ID: "stats-user", Email: "stats@example.com", Name: "Stats", Role: portal.UserRoleDeveloper, Status: portal.UserStatusActive}
+	if err := userRepo.CreateUser(ctx, user); err != nil {
+		t.Fatalf("CreateUser() returned error: %v", err)
+	}
+	for _, appID := range []string{"stats-app-1", "stats-app-2"} {
+		app := &portal.Application{ID: appID, Name: appID, UserID: "stats-user", APIKey: "ak_" + appID, APISecret: "as_" + appID, Status: portal.ApplicationStatusActive, RateLimit: 1000}
+		if err := appRepo.CreateApplication(ctx, app); err != nil {
+			t.Fatalf("CreateApplication() returned error: %v", err)
+		}
+	}
+	appRepo.SoftDeleteApplication(ctx, "stats-app-2")
+
+	stats, err := testRepo.Stats(ctx)
+	if err != nil {
+		t.Fatalf("Stats() returned error: %v", err)
+	}
+	if stats.Applications != 1 || stats.Users < 1 {
+		t.Errorf("Expected 1 application and at least 1 user, got %d and %d", stats.Applications, stats.Users)
+	}
+	if stats.OpenConnections < 1 || stats.MaxOpenConnections != testRepo.maxOpenConns {
+		t.Errorf("Expected an open connection and max %d, got %+v", testRepo.maxOpenConns, stats)
+	}
+}
+
 func TestRepository_BeginTx(t *testing.T) {
 	if testRepo == nil {
 		t.Skip("Test database not available")
@@ -146,6 +184,43 @@ func TestRepository_BeginTx(t *testing.T) {
 	}
 }
 
+func TestRepository_BeginTxWithOptions(t *testing.T) {
+	if testRepo == nil {
+		t.Skip("Test database not available")
+	}
+	defer cleanupTestData(t)
+
+	ctx := context.Background()
+
+	// Test the isolation level is applied
+	tx, err := testRepo.BeginTxWithOptions(ctx, &portal.TxOptions{Isolation: sql.LevelSerializable})
+	if err != nil {
+		t.Fatalf("BeginTxWithOptions() returned error: %v", err)
+	}
+	var isolation string
+	if err := tx.(*Transaction).tx.QueryRowContext(ctx, "SHOW transaction_isolation").Scan(&isolation); err != nil {
+		t.Errorf("Failed to query isolation level: %v", err)
+	}
+	if isolation != "serializable" {
+		t.Errorf("Expected serializable isolation, got %s", isolation)
+	}
+	tx.Rollback(ctx)
+
+	// Test writes fail in a read-only transaction
+	tx, err = testRepo.BeginTxWithOptions(ctx, &portal.TxOptions{ReadOnly: true})
+	if err != nil {
+		t.Fatalf("BeginTxWithOptions() returned error: %v", err)
+	}
+	if _, err := tx.UserRepository().ListUsers(ctx, nil); err != nil {
+		t.Errorf("ListUsers() in read-only transaction returned error: %v", err)
+	}
+	err = tx.UserRepository().CreateUser(ctx, &portal.User{ID: "read-only-user", Email: "read-only@example.com", Name: "Read Only", Role: portal.UserRoleDeveloper, Status: portal.UserStatusActive})
+	if err == nil {
+		t.Error("Expected error for write in read-only transaction")
+	}
+	tx.Rollback(ctx)
+}
+
 func TestRepository_UserRepository(t *testing.T) {
 	if testRepo == nil {
 		t.Skip("Test database not available")
@@ -188,6 +263,18 @@ func TestRepository_UserRepository(t *testing.T) {
 		t.Errorf("Expected ID %s, got %s", user.ID, userByEmail.ID)
 	}
 
+	// Test emails are unique and looked up ignoring case
+	userByEmail, err = userRepo.GetUserByEmail(ctx, "Test1@Example.com")
+	if err != nil {
+		t.Errorf("GetUserByEmail() with mixed-case email returned error: %v", err)
+	} else if userByEmail.ID != user.ID {
+		t.Errorf("Expected ID %s, got %s", user.ID, userByEmail.ID)
+	}
+	err = userRepo.CreateUser(ctx, &portal.User{ID: "test-user-mixed-case", Email: "TEST1@example.com", Name: "Mixed Case", Role: portal.UserRoleDeveloper, Status: portal.UserStatusActive})
+	if !portal.IsConflictError(err) {
+		t.Errorf("Expected conflict error for mixed-case duplicate email, got: %v", err)
+	}
+
 	// Test UpdateUser
 	user.Name = "Updated Test User 1"
 	err = userRepo.UpdateUser(ctx, user)
@@ -209,6 +296,38 @@ func TestRepository_UserRepository(t *testing.T) {
 		t.Error("User should exist")
 	}
 
+	// Test UpdateLastLogin and finding users by last login
+	loginCutoff := time.Now()
+	dormant, err := userRepo.ListUsers(ctx, &portal.UserFilter{Email: "test1@example.com", LastLoginBefore: &loginCutoff})
+	if err != nil {
+		t.Errorf("ListUsers() with last login filter returned error: %v", err)
+	} else if len(dormant.Users) != 1 {
+		t.Errorf("Expected a user who never logged in to match, got %d users", len(dormant.Users))
+	}
+	if err := userRepo.UpdateLastLogin(ctx, "test-user-1"); err != nil {
+		t.Errorf("UpdateLastLogin() returned error: %v", err)
+	}
+	loggedIn, _ := userRepo.GetUser(ctx, "test-user-1")
+	if loggedIn == nil || loggedIn.LastLoginAt == nil {
+		t.Error("Expected last login to be recorded")
+	}
+	dormant, _ = userRepo.ListUsers(ctx, &portal.UserFilter{Email: "test1@example.com", LastLoginBefore: &loginCutoff})
+	if dormant != nil && len(dormant.Users) != 0 {
+		t.Errorf("Expected no users to have logged in before the cutoff, got %d", len(dormant.Users))
+	}
+	if err := userRepo.UpdateLastLogin(ctx, "nonexistent"); !portal.IsNotFoundError(err) {
+		t.Errorf("Expected not found error, got: %v", err)
+	}
+
+	// Test estimated totals stay consistent with the page
+	page, err := userRepo.ListUsers(ctx, &portal.UserFilter{Email: "test1@example.com", EstimateTotal: true})
+	if err != nil {
+		t.Errorf("ListUsers() with estimated total returned error: %v", err)
+	}
+	if len(page.Users) != 1 || page.HasMore || page.Total < 1 {
+		t.Errorf("Expected 1 user and a total of at least 1, got %d users, total %d", len(page.Users), page.Total)
+	}
+
 	// Test DeleteUser
 	err = userRepo.DeleteUser(ctx, "test-user-1")
 	if err != nil {
@@ -274,6 +393,30 @@ func TestRepository_ApplicationRepository(t *testing.T) {
 	if retrievedApp.Name != app.Name {
 		t.Errorf("Expected name %s, got %s", app.Name, retrievedApp.Name)
 	}
+	if retrievedApp.APISecret != "" {
+		t.Errorf("Expected no API secret from GetApplication, got %s", retrievedApp.APISecret)
+	}
+
+	// Test VerifyAPISecret against the stored hash
+	if ok, err := appRepo.VerifyAPISecret(ctx, "test-app-1", "as_secret123456789"); !ok || err != nil {
+		t.Errorf("Expected the API secret to verify, got %v (%v)", ok, err)
+	}
+	if ok, _ := appRepo.VerifyAPISecret(ctx, "test-app-1", "as_wrong"); ok {
+		t.Error("Expected a wrong API secret to be rejected")
+	}
+
+	// Test a plaintext secret is rehashed once verified
+	if _, err := testRepo.db.ExecContext(ctx, `UPDATE applications SET api_secret = 'as_legacy' WHERE id = 'test-app-1'`); err != nil {
+		t.Fatalf("Failed to store a plaintext secret: %v", err)
+	}
+	if ok, _ := appRepo.VerifyAPISecret(ctx, "test-app-1", "as_legacy"); !ok {
+		t.Error("Expected the plaintext API secret to verify")
+	}
+	var stored string
+	testRepo.db.QueryRowContext(ctx, `SELECT api_secret FROM applications WHERE id = 'test-app-1'`).Scan(&stored)
+	if !secret.IsHashed(stored) {
+		t.Errorf("Expected the API secret rehashed, got %s", stored)
+	}
 
 	// Test GetApplicationByAPIKey
 	appByAPIKey, err := appRepo.GetApplicationByAPIKey(ctx, "ak_test123456789")
@@ -304,6 +447,70 @@ func TestRepository_ApplicationRepository(t *testing.T) {
 	if updatedApp.Name != "Updated Test App 1" {
 		t.Errorf("Expected name 'Updated Test App 1', got '%s'", updatedApp.Name)
 	}
+	if updatedApp.Version != 2 || app.Version != 2 {
+		t.Errorf("Expected the update to advance the version to 2, got %d (stored %d)", app.Version, updatedApp.Version)
+	}
+
+	// Test an update based on a stale version is rejected
+	stale := *updatedApp
+	stale.Version = 1
+	stale.Name = "Stale Test App 1"
+	if err := appRepo.UpdateApplication(ctx, &stale); !portal.IsConflictError(err) {
+		t.Errorf("Expected conflict error for a stale version, got: %v", err)
+	}
+	if current, _ := appRepo.GetApplication(ctx, "test-app-1"); current.Name != "Updated Test App 1" || current.Version != 2 {
+		t.Errorf("Expected the stale update rejected, got %q at version %d", current.Name, current.Version)
+	}
+
+	// Test BatchGetApplications
+	batch, err := appRepo.BatchGetApplications(ctx, []string{"test-app-1", "nonexistent"})
+	if err != nil {
+		t.Errorf("BatchGetApplications() returned error: %v", err)
+	}
+	if len(batch) != 1 || batch["test-app-1"] == nil || batch["test-app-1"].Name != "Updated Test App 1" {
+		t.Errorf("Expected only test-app-1, got %v", batch)
+	}
+
+	// Test application scopes
+	if err := appRepo.SetApplicationScopes(ctx, "test-app-1", []string{"orders:write", "orders:read"}); err != nil {
+		t.Errorf("SetApplicationScopes() returned error: %v", err)
+	}
+	if err := appRepo.AddApplicationScopes(ctx, "test-app-1", []string{"billing:read", "orders:read"}); err != nil {
+		t.Errorf("AddApplicationScopes() returned error: %v", err)
+	}
+	if err := appRepo.RemoveApplicationScopes(ctx, "test-app-1", []string{"orders:write"}); err != nil {
+		t.Errorf("RemoveApplicationScopes() returned error: %v", err)
+	}
+	scopes, err := appRepo.GetApplicationScopes(ctx, "test-app-1")
+	if err != nil || len(scopes) != 2 || scopes[0] != "billing:read" || scopes[1] != "orders:read" {
+		t.Errorf("Expected [billing:read orders:read], got %v (%v)", scopes, err)
+	}
+	if err := appRepo.AddApplicationScopes(ctx, "test-app-1", []string{"bad scope"}); !portal.IsValidationError(err) {
+		t.Errorf("Expected validation error for a malformed scope, got: %v", err)
+	}
+	byScope, err := appRepo.ListApplications(ctx, &portal.ApplicationFilter{HasScope: "billing:read"})
+	if err != nil || byScope.Total != 1 || byScope.Applications[0].ID != "test-app-1" {
+		t.Errorf("Expected test-app-1 granted billing:read, got %+v (%v)", byScope, err)
+	}
+	if count, _ := appRepo.CountApplications(ctx, &portal.ApplicationFilter{HasScope: "orders:write"}); count != 0 {
+		t.Errorf("Expected no application granted orders:write, got %d", count)
+	}
+
+	// Test last use and dormant applications
+	usedAt := time.Now().Add(-time.Hour).Truncate(time.Microsecond)
+	if err := appRepo.RecordApplicationsLastUsed(ctx, map[string]time.Time{"test-app-1": usedAt}); err != nil {
+		t.Errorf("RecordApplicationsLastUsed() returned error: %v", err)
+	}
+	appRepo.RecordApplicationsLastUsed(ctx, map[string]time.Time{"test-app-1": usedAt.Add(-time.Hour)})
+	if usedApp, _ := appRepo.GetApplication(ctx, "test-app-1"); usedApp.LastUsedAt == nil || !usedApp.LastUsedAt.Equal(usedAt) {
+		t.Errorf("Expected last use %v, got %v", usedAt, usedApp.LastUsedAt)
+	}
+	if dormant, err := appRepo.ListDormantApplications(ctx, usedAt); err != nil || len(dormant) != 0 {
+		t.Errorf("Expected no dormant applications, got %d (%v)", len(dormant), err)
+	}
+	if dormant, _ := appRepo.ListDormantApplications(ctx, usedAt.Add(time.Second)); len(dormant) != 1 || dormant[0].ID != "test-app-1" {
+		t.Errorf("Expected test-app-1 dormant, got %d applications", len(dormant))
+	}
 
 	// Test RegenerateAPIKey
 	newAPIKey, err := appRepo.RegenerateAPIKey(ctx, "test-app-1")
@@ -330,6 +537,197 @@ func TestRepository_ApplicationRepository(t *testing.T) {
 	}
 }
 
+func TestRepository_TransferApplications(t *testing.T) {
+	if testRepo == nil {
+		t.Skip("Test database not available")
+	}
+
+	defer cleanupTestData(t)
+
+	var events []portal.ApplicationEvent
+	testRepo.events = func(event portal.ApplicationEvent) {
+		events = append(events, event)
+	}
+	defer func() { testRepo.events = nil }()
+
+	ctx := context.Background()
+	userRepo := NewUserRepository(testRepo)
+	appRepo := NewApplicationRepository(testRepo)
+
+	for _, userID := range []string{"transfer-from", "transfer-to"} {
+		user := &portal.User{ID: userID, Email: userID + "@example.com", Name: userID, Role: portal.UserRoleDeveloper, Status: portal.UserStatusActive}
+		if err := userRepo.CreateUser(ctx, user); err != nil {
+			t.Fatalf("CreateUser() returned error: %v", err)
+		}
+	}
+	for _, appID := range []string{"transfer-app-1", "transfer-app-2"} {
+		app := &portal.Application{ID: appID, Name: appID, UserID: "transfer-from", APIKey: "ak_" + appID, APISecret: "as_" + appID, Status: portal.ApplicationStatusActive, RateLimit: 1000}
+		if err := appRepo.CreateApplication(ctx, app); err != nil {
+			t.Fatalf("CreateApplication() returned error: %v", err)
+		}
+	}
+
+	// Test a partial failure rolls back the applications already moved
+	err := appRepo.TransferApplications(ctx, "transfer-from", "transfer-to", []string{"transfer-app-1", "missing-app"})
+	if !portal.IsNotFoundError(err) {
+		t.Errorf("Expected not found error, got: %v", err)
+	}
+	if count, _ := appRepo.CountApplicationsByUser(ctx, "transfer-from"); count != 2 || len(events) != 0 {
+		t.Errorf("Expected the failed transfer to be rolled back, got %d applications left and %d events", count, len(events))
+	}
+
+	// Test transferring all applications
+	if err := appRepo.TransferApplications(ctx, "transfer-from", "transfer-to", nil); err != nil {
+		t.Fatalf("TransferApplications() returned error: %v", err)
+	}
+	if count, _ := appRepo.CountApplicationsByUser(ctx, "transfer-to"); count != 2 {
+		t.Errorf("Expected 2 applications transferred, got %d", count)
+	}
+	if len(events) != 2 || events[0].ApplicationID != "transfer-app-1" || events[0].PreviousUserID != "transfer-from" {
+		t.Errorf("Expected 2 transferred events, got %+v", events)
+	}
+
+	// Test transferring a single application back, rolled back with the
+	// transaction it is part of
+	events = nil
+	if err := appRepo.TransferApplication(ctx, "transfer-app-1", "missing-user"); !portal.IsNotFoundError(err) {
+		t.Errorf("Expected not found error for an unknown user, got: %v", err)
+	}
+	if err := appRepo.TransferApplication(ctx, "missing-app", "transfer-from"); !portal.IsNotFoundError(err) {
+		t.Errorf("Expected not found error for an unknown application, got: %v", err)
+	}
+	tx, err := testRepo.BeginTx(ctx)
+	if err != nil {
+		t.Fatalf("BeginTx() returned error: %v", err)
+	}
+	if err := tx.ApplicationRepository().TransferApplication(ctx, "transfer-app-1", "transfer-from"); err != nil {
+		t.Fatalf("TransferApplication() returned error: %v", err)
+	}
+	tx.Rollback(ctx)
+	if app, _ := appRepo.GetApplication(ctx, "transfer-app-1"); app.UserID != "transfer-to" || len(events) != 0 {
+		t.Errorf("Expected the rolled back transfer to change nothing, got owner %s and %d events", app.UserID, len(events))
+	}
+
+	if err := appRepo.TransferApplication(ctx, "transfer-app-1", "transfer-from"); err != nil {
+		t.Fatalf("TransferApplication() returned error: %v", err)
+	}
+	if app, _ := appRepo.GetApplication(ctx, "transfer-app-1"); app.UserID != "transfer-from" {
+		t.Errorf("Expected transfer-app-1 owned by transfer-from, got %s", app.UserID)
+	}
+	if len(events) != 1 || events[0].PreviousUserID != "transfer-to" || events[0].UserID != "transfer-from" {
+		t.Errorf("Expected a transferred event, got %+v", events)
+	}
+}
+
+func TestRepository_SoftDeleteApplication(t *testing.T) {
+	if testRepo == nil {
+		t.Skip("Test database not available")
+	}
+
+	defer cleanupTestData(t)
+
+	ctx := context.Background()
+	userRepo := NewUserRepository(testRepo)
+	appRepo := NewApplicationRepository(testRepo)
+
+	user := &portal.User{ID: "soft-delete-user", Email: "soft-delete@example.com", Name: "Soft Delete", Role: portal.UserRoleDeveloper, Status: portal.UserStatusActive}
+	if err := userRepo.CreateUser(ctx, user); err != nil {
+		t.Fatalf("CreateUser() returned error: %v", err)
+	}
+	app := &portal.Application{ID: "soft-delete-app", Name: "soft-delete-app", UserID: "soft-delete-user", APIKey: "ak_soft_delete", APISecret: "as_soft_delete", Status: portal.ApplicationStatusActive, RateLimit: 1000}
+	if err := appRepo.CreateApplication(ctx, app); err != nil {
+		t.Fatalf("CreateApplication() returned error: %v", err)
+	}
+
+	if err := appRepo.SoftDeleteApplication(ctx, "soft-delete-app"); err != nil {
+		t.Fatalf("SoftDeleteApplication() returned error: %v", err)
+	}
+	if _, err := appRepo.GetApplication(ctx, "soft-delete-app"); !portal.IsNotFoundError(err) {
+		t.Errorf("Expected not found error, got: %v", err)
+	}
+	if _, err := appRepo.GetApplicationByAPIKey(ctx, "ak_soft_delete"); !portal.IsNotFoundError(err) {
+		t.Errorf("Expected not found error by API key, got: %v", err)
+	}
+	list, err := appRepo.ListApplications(ctx, &portal.ApplicationFilter{UserID: "soft-delete-user", IncludeDeleted: true})
+	if err != nil || list.Total != 1 || list.Applications[0].DeletedAt == nil {
+		t.Errorf("Expected the soft-deleted application listed, got %+v (%v)", list, err)
+	}
+	if count, _ := appRepo.CountApplications(ctx, &portal.ApplicationFilter{UserID: "soft-delete-user"}); count != 0 {
+		t.Errorf("Expected no applications counted, got %d", count)
+	}
+
+	// The partial unique index lets the API key be reused, blocking restore
+	reuse := &portal.Application{ID: "soft-delete-reuse", Name: "soft-delete-reuse", UserID: "soft-delete-user", APIKey: "ak_soft_delete", APISecret: "as_soft_delete", Status: portal.ApplicationStatusActive, RateLimit: 1000}
+	if err := appRepo.CreateApplication(ctx, reuse); err != nil {
+		t.Fatalf("Expected the API key reusable, got: %v", err)
+	}
+	if err := appRepo.RestoreApplication(ctx, "soft-delete-app"); !portal.IsConflictError(err) {
+		t.Errorf("Expected conflict error restoring with a reused API key, got: %v", err)
+	}
+	appRepo.DeleteApplication(ctx, "soft-delete-reuse")
+
+	if err := appRepo.RestoreApplication(ctx, "soft-delete-app"); err != nil {
+		t.Fatalf("RestoreApplication() returned error: %v", err)
+	}
+	if restored, err := appRepo.GetApplicationByAPIKey(ctx, "ak_soft_delete"); err != nil || restored.DeletedAt != nil {
+		t.Errorf("Expected the application restored, got %+v (%v)", restored, err)
+	}
+}
+
+func TestRepository_ListApplicationsCursor(t *testing.T) {
+	if testRepo == nil {
+		t.Skip("Test database not available")
+	}
+
+	defer cleanupTestData(t)
+
+	ctx := context.Background()
+	userRepo := NewUserRepository(testRepo)
+	appRepo := NewApplicationRepository(testRepo)
+
+	user := &portal.User{ID: "cursor-user", Email: "cursor@example.com", Name: "Cursor", Role: portal.UserRoleDeveloper, Status: portal.UserStatusActive}
+	if err := userRepo.CreateUser(ctx, user); err != nil {
+		t.Fatalf("CreateUser() returned error: %v", err)
+	}
+
+	// cursor-app-2 and cursor-app-3 share a creation time, which their IDs order
+	base := time.Now().Add(-time.Hour).Truncate(time.Microsecond)
+	offsets := []time.Duration{0, time.Minute, time.Minute, 2 * time.Minute, 3 * time.Minute}
+	for i, offset := range offsets {
+		id := fmt.Sprintf("cursor-app-%d", i+1)
+		app := &portal.Application{ID: id, Name: id, UserID: "cursor-user", APIKey: "ak_" + id, APISecret: "as_" + id, Status: portal.ApplicationStatusActive, RateLimit: 1000, CreatedAt: base.Add(offset)}
+		if err := appRepo.CreateApplication(ctx, app); err != nil {
+			t.Fatalf("CreateApplication() returned error: %v", err)
+		}
+	}
+
+	var ids []string
+	filter := &portal.ApplicationFilter{UserID: "cursor-user", PageSize: 2}
+	for {
+		result, err := appRepo.ListApplications(ctx, filter)
+		if err != nil {
+			t.Fatalf("ListApplications() returned error: %v", err)
+		}
+		if result.Total != 5 {
+			t.Errorf("Expected total 5, got %d", result.Total)
+		}
+		for _, app := range result.Applications {
+			ids = append(ids, app.ID)
+		}
+		if !result.HasMore {
+			break
+		}
+		filter.Cursor = result.NextCursor
+	}
+	if got := strings.Join(ids, ","); got != "cursor-app-5,cursor-app-4,cursor-app-3,cursor-app-2,cursor-app-1" {
+		t.Errorf("Expected applications newest first, got %s", got)
+	}
+
+	if _, err := appRepo.ListApplications(ctx, &portal.ApplicationFilter{Cursor: "not-a-cursor"}); !portal.IsValidationError(err) {
+		t.Errorf("Expected validation error for malformed cursor, got: %v", err)
+	}
+}
+
 func TestRepository_Transaction(t *testing.T) {
 	if testRepo == nil {
 		t.Skip("Test database not available")
@@ -407,3 +805,22 @@ func TestRepository_Transaction(t *testing.T) {
 		t.Errorf("Expected not found error, got: %v", err)
 	}
 }
+
+func TestApplicationRepository_BuildOrderByClause(t *testing.T) {
+	ar := &ApplicationRepository{}
+
+	// Fields accepted by strict validation are sorted by
+	for _, field := range []string{"id", "name", "user_id", "status", "rate_limit", "created_at", "updated_at"} {
+		if !portal.IsApplicationSortField(field) {
+			t.Fatalf("Expected %s to be a sort field", field)
+		}
+		if clause := ar.buildOrderByClause(field, "asc"); clause != "ORDER BY "+field+" ASC" {
+			t.Errorf("Expected ORDER BY %s ASC, got %s", field, clause)
+		}
+	}
+
+	// Unknown fields fall back to the creation time
+	if clause := ar.buildOrderByClause("api_secret", "sideways"); clause != "ORDER BY created_at DESC" {
+		t.Errorf("Expected ORDER BY created_at DESC, got %s", clause)
+	}
+}
@@ -13,14 +13,17 @@ CREATE TABLE users (
     role VARCHAR(50) NOT NULL CHECK (role IN ('admin', 'developer', 'viewer')),
     status VARCHAR(50) NOT NULL CHECK (status IN ('active', 'inactive', 'suspended')),
     created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
-    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    last_login_at TIMESTAMP WITH TIME ZONE
 );
 
 -- Create indexes for users table
 CREATE INDEX idx_users_email ON users(email);
+CREATE UNIQUE INDEX users_email_lower_key ON users(lower(email));
 CREATE INDEX idx_users_role ON users(role);
 CREATE INDEX idx_users_status ON users(status);
-CREATE INDEX idx_users_created_at ON users(created_at);
+CREATE INDEX idx_users_created_at_id ON users(created_at, id);
+CREATE INDEX idx_users_last_login_at ON users(last_login_at);
 
 -- Applications table
 CREATE TABLE applications (
@@ -28,20 +31,28 @@ CREATE TABLE applications (
     name VARCHAR(255) NOT NULL,
     description TEXT,
     user_id VARCHAR(255) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
-    api_key VARCHAR(255) NOT NULL UNIQUE,
+    api_key VARCHAR(255) NOT NULL,
     api_secret VARCHAR(255) NOT NULL,
     status VARCHAR(50) NOT NULL CHECK (status IN ('active', 'inactive', 'suspended')),
     rate_limit BIGINT NOT NULL DEFAULT 1000,
+    scopes TEXT[] NOT NULL DEFAULT '{}',
     created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
-    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    last_used_at TIMESTAMP WITH TIME ZONE,
+    deleted_at TIMESTAMP WITH TIME ZONE,
+    version BIGINT NOT NULL DEFAULT 1
 );
 
 -- Create indexes for applications table
 CREATE INDEX idx_applications_user_id ON applications(user_id);
 CREATE INDEX idx_applications_api_key ON applications(api_key);
 CREATE INDEX idx_applications_status ON applications(status);
-CREATE INDEX idx_applications_created_at ON applications(created_at);
+CREATE INDEX idx_applications_created_at_id ON applications(created_at, id);
 CREATE INDEX idx_applications_name ON applications(name);
+CREATE INDEX idx_applications_scopes ON applications USING GIN (scopes);
+CREATE INDEX idx_applications_last_activity ON applications(COALESCE(last_used_at, created_at)) WHERE status = 'active' AND deleted_at IS NULL;
+-- API keys are unique among applications that aren't soft-deleted
+CREATE UNIQUE INDEX applications_api_key_key ON applications(api_key) WHERE deleted_at IS NULL;
 
 -- Credentials table (for future extensibility)
 CREATE TABLE credentials (
@@ -85,6 +96,19 @@ CREATE INDEX idx_api_usage_logs_created_at ON api_usage_logs(created_at);
 CREATE INDEX idx_api_usage_logs_status_code ON api_usage_logs(status_code);
 CREATE INDEX idx_api_usage_logs_method ON api_usage_logs(method);
 
+-- Application usage table (minute-resolution counters, aggregated on read)
+CREATE TABLE application_usage (
+    application_id VARCHAR(255) NOT NULL REFERENCES applications(id) ON DELETE CASCADE,
+    bucket_start TIMESTAMP WITH TIME ZONE NOT NULL,
+    requests BIGINT NOT NULL DEFAULT 0,
+    errors BIGINT NOT NULL DEFAULT 0,
+    bytes BIGINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (application_id, bucket_start)
+);
+
+-- Create indexes for application_usage table
+CREATE INDEX idx_application_usage_bucket_start ON application_usage(bucket_start);
+
 -- Partitioning for api_usage_logs (monthly partitions)
 -- This helps with performance for large datasets
 CREATE TABLE api_usage_logs_template (LIKE api_usage_logs INCLUDING ALL);
@@ -159,8 +183,15 @@ COMMENT ON TABLE users IS 'Portal users with role-based access control';
 COMMENT ON TABLE applications IS 'Developer applications with API credentials';
 COMMENT ON TABLE credentials IS 'Application credentials for various authentication methods';
 COMMENT ON TABLE api_usage_logs IS 'API usage tracking for analytics and monitoring';
+COMMENT ON TABLE application_usage IS 'Per-minute application usage counters, aggregated on read';
 
 COMMENT ON COLUMN users.role IS 'User role: admin, developer, or viewer';
 COMMENT ON COLUMN users.status IS 'User status: active, inactive, or suspended';
+COMMENT ON COLUMN users.last_login_at IS 'Time the user last logged in, NULL if never logged in';
 COMMENT ON COLUMN applications.rate_limit IS 'API rate limit per hour for this application';
+COMMENT ON COLUMN applications.scopes IS 'Scopes granted to the application, sorted and without duplicates';
+COMMENT ON COLUMN applications.last_used_at IS 'Approximate time the application''s API key was last used, NULL if never used';
+COMMENT ON COLUMN applications.deleted_at IS 'Time the application was soft-deleted, NULL unless deleted';
+COMMENT ON COLUMN applications.version IS 'Incremented by every change, updates based on an older version are rejected';
+COMMENT ON COLUMN application_usage.bucket_start IS 'UTC minute the counters belong to';
 COMMENT ON COLUMN credentials.credential_type IS 'Type of credential: api_key, oauth2, or jwt';
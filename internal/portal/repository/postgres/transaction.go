@@ -14,8 +14,10 @@ type Transaction struct {
 	tx         *sql.Tx
 	userRepo   *UserRepository
 	appRepo    *ApplicationRepository
+	readOnly   bool // Writes fail, reported by the database
 	committed  bool
 	rolledBack bool
+	events     []portal.ApplicationEvent // Reported on commit
 	mu         sync.Mutex
 }
 
@@ -41,22 +43,36 @@ func NewTransaction(repo *Repository, tx *sql.Tx) *Transaction {
 
 // Commit commits the transaction
 func (t *Transaction) Commit(ctx context.Context) error {
+	events, err := t.commit()
+	if err != nil {
+		return err
+	}
+
+	// Report changes outside the lock so handlers may use the repository
+	t.repo.publishEvents(events)
+	return nil
+}
+
+// commit commits the database transaction and returns its queued events
+func (t *Transaction) commit() ([]portal.ApplicationEvent, error) {
 	t.mu.Lock()
 	defer t.mu.Unlock()
 
 	if t.committed {
-		return portal.NewDatabaseError("TX_ALREADY_COMMITTED", "transaction already committed", nil)
+		return nil, portal.NewDatabaseError("TX_ALREADY_COMMITTED", "transaction already committed", nil)
 	}
 	if t.rolledBack {
-		return portal.NewDatabaseError("TX_ALREADY_ROLLED_BACK", "transaction already rolled back", nil)
+		return nil, portal.NewDatabaseError("TX_ALREADY_ROLLED_BACK", "transaction already rolled back", nil)
 	}
 
 	if err := t.tx.Commit(); err != nil {
-		return portal.NewDatabaseError("TX_COMMIT_FAILED", "failed to commit transaction", err)
+		return nil, portal.NewDatabaseError("TX_COMMIT_FAILED", "failed to commit transaction", err)
 	}
 
 	t.committed = true
-	return nil
+	events := t.events
+	t.events = nil
+	return events, nil
 }
 
 // Rollback rolls back the transaction
@@ -76,6 +92,7 @@ func (t *Transaction) Rollback(ctx context.Context) error {
 	}
 
 	t.rolledBack = true
+	t.events = nil
 	return nil
 }
 
@@ -89,6 +106,13 @@ func (t *Transaction) ApplicationRepository() portal.ApplicationRepository {
 	return t.appRepo
 }
 
+// addEvents queues application changes to report on commit
+func (t *Transaction) addEvents(events []portal.ApplicationEvent) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.events = append(t.events, events...)
+}
+
 // isActive checks if the transaction is still active
 func (t *Transaction) isActive() error {
 	t.mu.Lock()
@@ -0,0 +1,98 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/songzhibin97/stargate/pkg/portal"
+)
+
+// UsageRepository implements the portal.UsageRepository interface using PostgreSQL.
+// Counters are stored per UTC minute and are retained until deleted, either
+// explicitly or together with their application.
+type UsageRepository struct {
+	repo *Repository
+}
+
+// NewUsageRepository creates a new PostgreSQL usage repository
+func NewUsageRepository(repo *Repository) *UsageRepository {
+	return &UsageRepository{
+		repo: repo,
+	}
+}
+
+// RecordUsage adds the delta to the application's usage counters
+func (ur *UsageRepository) RecordUsage(ctx context.Context, delta *portal.UsageDelta) error {
+	if err := portal.ValidateUsageDelta(delta); err != nil {
+		return err
+	}
+
+	ts := delta.Timestamp
+	if ts.IsZero() {
+		ts = time.Now()
+	}
+
+	query := `
+		INSERT INTO application_usage (application_id, bucket_start, requests, errors, bytes)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (application_id, bucket_start) DO UPDATE SET
+			requests = application_usage.requests + EXCLUDED.requests,
+			errors = application_usage.errors + EXCLUDED.errors,
+			bytes = application_usage.bytes + EXCLUDED.bytes`
+
+	_, err := ur.repo.execCommand(ctx, query, delta.ApplicationID, ts.UTC().Truncate(time.Minute), delta.Requests, delta.Errors, delta.Bytes)
+	if err != nil {
+		if isForeignKeyViolation(err) {
+			return portal.NewNotFoundError("APPLICATION_NOT_FOUND", "application not found")
+		}
+		return err
+	}
+
+	return nil
+}
+
+// GetUsage returns time-bucketed usage for an application
+func (ur *UsageRepository) GetUsage(ctx context.Context, appID string, from, to time.Time, granularity string) ([]portal.UsageBucket, error) {
+	buckets, err := portal.NewUsageBuckets(appID, from, to, granularity)
+	if err != nil {
+		return nil, err
+	}
+
+	// granularity has been validated above, so it is safe to pass to date_trunc
+	query := `
+		SELECT date_trunc($1, bucket_start AT TIME ZONE 'UTC') AS bucket,
+			SUM(requests), SUM(errors), SUM(bytes)
+		FROM application_usage
+		WHERE application_id = $2 AND bucket_start >= $3 AND bucket_start < $4
+		GROUP BY bucket`
+
+	var rows *sql.Rows
+	rows, err = ur.repo.execQuery(ctx, query, granularity, appID, buckets[0].Start, to.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	index := make(map[int64]int, len(buckets))
+	for i, bucket := range buckets {
+		index[bucket.Start.Unix()] = i
+	}
+
+	for rows.Next() {
+		var bucket portal.UsageBucket
+		if err := rows.Scan(&bucket.Start, &bucket.Requests, &bucket.Errors, &bucket.Bytes); err != nil {
+			return nil, portal.NewDatabaseError("SCAN_FAILED", "failed to scan usage bucket", err)
+		}
+		if i, exists := index[bucket.Start.Unix()]; exists {
+			bucket.Start = buckets[i].Start
+			buckets[i] = bucket
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, portal.NewDatabaseError("ROWS_ERROR", "error iterating rows", err)
+	}
+
+	return buckets, nil
+}
@@ -7,6 +7,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/songzhibin97/stargate/internal/portal/repository/instrument"
 	"github.com/songzhibin97/stargate/pkg/portal"
 )
 
@@ -24,14 +25,17 @@ func NewUserRepository(repo *Repository) *UserRepository {
 }
 
 // CreateUser creates a new user
-func (ur *UserRepository) CreateUser(ctx context.Context, user *portal.User) error {
+func (ur *UserRepository) CreateUser(ctx context.Context, user *portal.User) (err error) {
+	defer ur.repo.metrics.Track(instrument.UserRepository, "CreateUser")(&err)
+
 	if err := ur.validateUser(user); err != nil {
 		return err
 	}
+	user.Email = portal.NormalizeEmail(user.Email)
 
 	query := `
-		INSERT INTO users (id, email, name, password, role, status, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+		INSERT INTO users (id, email, name, password, role, status, created_at, updated_at, last_login_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`
 
 	now := time.Now()
 	if user.CreatedAt.IsZero() {
@@ -39,11 +43,10 @@ func (ur *UserRepository) CreateUser(ctx context.Context, user *portal.User) err
 	}
 	user.UpdatedAt = now
 
-	var err error
 	if ur.tx != nil {
-		_, err = ur.tx.execCommand(ctx, query, user.ID, user.Email, user.Name, user.Password, user.Role, user.Status, user.CreatedAt, user.UpdatedAt)
+		_, err = ur.tx.execCommand(ctx, query, user.ID, user.Email, user.Name, user.Password, user.Role, user.Status, user.CreatedAt, user.UpdatedAt, user.LastLoginAt)
 	} else {
-		_, err = ur.repo.execCommand(ctx, query, user.ID, user.Email, user.Name, user.Password, user.Role, user.Status, user.CreatedAt, user.UpdatedAt)
+		_, err = ur.repo.execCommand(ctx, query, user.ID, user.Email, user.Name, user.Password, user.Role, user.Status, user.CreatedAt, user.UpdatedAt, user.LastLoginAt)
 	}
 
 	if err != nil {
@@ -51,7 +54,7 @@ func (ur *UserRepository) CreateUser(ctx context.Context, user *portal.User) err
 			if strings.Contains(err.Error(), "users_pkey") {
 				return portal.NewConflictError("USER_ALREADY_EXISTS", "user with this ID already exists")
 			}
-			if strings.Contains(err.Error(), "users_email_key") {
+			if isEmailViolation(err) {
 				return portal.NewConflictError("USER_EMAIL_EXISTS", "user with this email already exists")
 			}
 		}
@@ -62,13 +65,15 @@ func (ur *UserRepository) CreateUser(ctx context.Context, user *portal.User) err
 }
 
 // GetUser retrieves a user by ID
-func (ur *UserRepository) GetUser(ctx context.Context, userID string) (*portal.User, error) {
+func (ur *UserRepository) GetUser(ctx context.Context, userID string) (_ *portal.User, err error) {
+	defer ur.repo.metrics.Track(instrument.UserRepository, "GetUser")(&err)
+
 	if userID == "" {
 		return nil, portal.NewValidationError("INVALID_USER_ID", "user ID cannot be empty")
 	}
 
 	query := `
-		SELECT id, email, name, password, role, status, created_at, updated_at
+		SELECT id, email, name, password, role, status, created_at, updated_at, last_login_at
 		FROM users
 		WHERE id = $1`
 
@@ -80,7 +85,7 @@ func (ur *UserRepository) GetUser(ctx context.Context, userID string) (*portal.U
 	}
 
 	user := &portal.User{}
-	err := row.Scan(&user.ID, &user.Email, &user.Name, &user.Password, &user.Role, &user.Status, &user.CreatedAt, &user.UpdatedAt)
+	err = row.Scan(&user.ID, &user.Email, &user.Name, &user.Password, &user.Role, &user.Status, &user.CreatedAt, &user.UpdatedAt, &user.LastLoginAt)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, portal.NewNotFoundError("USER_NOT_FOUND", "user not found")
@@ -92,25 +97,27 @@ func (ur *UserRepository) GetUser(ctx context.Context, userID string) (*portal.U
 }
 
 // GetUserByEmail retrieves a user by email address
-func (ur *UserRepository) GetUserByEmail(ctx context.Context, email string) (*portal.User, error) {
+func (ur *UserRepository) GetUserByEmail(ctx context.Context, email string) (_ *portal.User, err error) {
+	defer ur.repo.metrics.Track(instrument.UserRepository, "GetUserByEmail")(&err)
+
 	if email == "" {
 		return nil, portal.NewValidationError("INVALID_EMAIL", "email cannot be empty")
 	}
 
 	query := `
-		SELECT id, email, name, password, role, status, created_at, updated_at
+		SELECT id, email, name, password, role, status, created_at, updated_at, last_login_at
 		FROM users
-		WHERE email = $1`
+		WHERE lower(email) = $1`
 
 	var row *sql.Row
 	if ur.tx != nil {
-		row = ur.tx.execQueryRow(ctx, query, email)
+		row = ur.tx.execQueryRow(ctx, query, portal.NormalizeEmail(email))
 	} else {
-		row = ur.repo.execQueryRow(ctx, query, email)
+		row = ur.repo.execQueryRow(ctx, query, portal.NormalizeEmail(email))
 	}
 
 	user := &portal.User{}
-	err := row.Scan(&user.ID, &user.Email, &user.Name, &user.Password, &user.Role, &user.Status, &user.CreatedAt, &user.UpdatedAt)
+	err = row.Scan(&user.ID, &user.Email, &user.Name, &user.Password, &user.Role, &user.Status, &user.CreatedAt, &user.UpdatedAt, &user.LastLoginAt)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, portal.NewNotFoundError("USER_NOT_FOUND", "user not found")
@@ -122,10 +129,13 @@ func (ur *UserRepository) GetUserByEmail(ctx context.Context, email string) (*po
 }
 
 // UpdateUser updates an existing user
-func (ur *UserRepository) UpdateUser(ctx context.Context, user *portal.User) error {
+func (ur *UserRepository) UpdateUser(ctx context.Context, user *portal.User) (err error) {
+	defer ur.repo.metrics.Track(instrument.UserRepository, "UpdateUser")(&err)
+
 	if err := ur.validateUser(user); err != nil {
 		return err
 	}
+	user.Email = portal.NormalizeEmail(user.Email)
 
 	// Check if user exists
 	existingUser, err := ur.GetUser(ctx, user.ID)
@@ -148,7 +158,7 @@ func (ur *UserRepository) UpdateUser(ctx context.Context, user *portal.User) err
 	}
 
 	if err != nil {
-		if isUniqueViolation(err) && strings.Contains(err.Error(), "users_email_key") {
+		if isUniqueViolation(err) && isEmailViolation(err) {
 			return portal.NewConflictError("USER_EMAIL_EXISTS", "user with this email already exists")
 		}
 		return err
@@ -158,13 +168,15 @@ func (ur *UserRepository) UpdateUser(ctx context.Context, user *portal.User) err
 }
 
 // DeleteUser deletes a user by ID
-func (ur *UserRepository) DeleteUser(ctx context.Context, userID string) error {
+func (ur *UserRepository) DeleteUser(ctx context.Context, userID string) (err error) {
+	defer ur.repo.metrics.Track(instrument.UserRepository, "DeleteUser")(&err)
+
 	if userID == "" {
 		return portal.NewValidationError("INVALID_USER_ID", "user ID cannot be empty")
 	}
 
 	// Check if user exists
-	_, err := ur.GetUser(ctx, userID)
+	_, err = ur.GetUser(ctx, userID)
 	if err != nil {
 		return err
 	}
@@ -195,7 +207,9 @@ func (ur *UserRepository) DeleteUser(ctx context.Context, userID string) error {
 }
 
 // ExistsUser checks if a user exists by ID
-func (ur *UserRepository) ExistsUser(ctx context.Context, userID string) (bool, error) {
+func (ur *UserRepository) ExistsUser(ctx context.Context, userID string) (_ bool, err error) {
+	defer ur.repo.metrics.Track(instrument.UserRepository, "ExistsUser")(&err)
+
 	if userID == "" {
 		return false, portal.NewValidationError("INVALID_USER_ID", "user ID cannot be empty")
 	}
@@ -210,7 +224,7 @@ func (ur *UserRepository) ExistsUser(ctx context.Context, userID string) (bool,
 		row = ur.repo.execQueryRow(ctx, query, userID)
 	}
 
-	err := row.Scan(&exists)
+	err = row.Scan(&exists)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return false, nil
@@ -222,22 +236,24 @@ func (ur *UserRepository) ExistsUser(ctx context.Context, userID string) (bool,
 }
 
 // ExistsUserByEmail checks if a user exists by email
-func (ur *UserRepository) ExistsUserByEmail(ctx context.Context, email string) (bool, error) {
+func (ur *UserRepository) ExistsUserByEmail(ctx context.Context, email string) (_ bool, err error) {
+	defer ur.repo.metrics.Track(instrument.UserRepository, "ExistsUserByEmail")(&err)
+
 	if email == "" {
 		return false, portal.NewValidationError("INVALID_EMAIL", "email cannot be empty")
 	}
 
-	query := `SELECT 1 FROM users WHERE email = $1 LIMIT 1`
+	query := `SELECT 1 FROM users WHERE lower(email) = $1 LIMIT 1`
 
 	var exists int
 	var row *sql.Row
 	if ur.tx != nil {
-		row = ur.tx.execQueryRow(ctx, query, email)
+		row = ur.tx.execQueryRow(ctx, query, portal.NormalizeEmail(email))
 	} else {
-		row = ur.repo.execQueryRow(ctx, query, email)
+		row = ur.repo.execQueryRow(ctx, query, portal.NormalizeEmail(email))
 	}
 
-	err := row.Scan(&exists)
+	err = row.Scan(&exists)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return false, nil
@@ -249,7 +265,9 @@ func (ur *UserRepository) ExistsUserByEmail(ctx context.Context, email string) (
 }
 
 // UpdateUserStatus updates the status of a user
-func (ur *UserRepository) UpdateUserStatus(ctx context.Context, userID string, status portal.UserStatus) error {
+func (ur *UserRepository) UpdateUserStatus(ctx context.Context, userID string, status portal.UserStatus) (err error) {
+	defer ur.repo.metrics.Track(instrument.UserRepository, "UpdateUserStatus")(&err)
+
 	if userID == "" {
 		return portal.NewValidationError("INVALID_USER_ID", "user ID cannot be empty")
 	}
@@ -257,7 +275,6 @@ func (ur *UserRepository) UpdateUserStatus(ctx context.Context, userID string, s
 	query := `UPDATE users SET status = $2, updated_at = $3 WHERE id = $1`
 
 	var result sql.Result
-	var err error
 	if ur.tx != nil {
 		result, err = ur.tx.execCommand(ctx, query, userID, status, time.Now())
 	} else {
@@ -281,7 +298,9 @@ func (ur *UserRepository) UpdateUserStatus(ctx context.Context, userID string, s
 }
 
 // UpdateUserRole updates the role of a user
-func (ur *UserRepository) UpdateUserRole(ctx context.Context, userID string, role portal.UserRole) error {
+func (ur *UserRepository) UpdateUserRole(ctx context.Context, userID string, role portal.UserRole) (err error) {
+	defer ur.repo.metrics.Track(instrument.UserRepository, "UpdateUserRole")(&err)
+
 	if userID == "" {
 		return portal.NewValidationError("INVALID_USER_ID", "user ID cannot be empty")
 	}
@@ -289,7 +308,6 @@ func (ur *UserRepository) UpdateUserRole(ctx context.Context, userID string, rol
 	query := `UPDATE users SET role = $2, updated_at = $3 WHERE id = $1`
 
 	var result sql.Result
-	var err error
 	if ur.tx != nil {
 		result, err = ur.tx.execCommand(ctx, query, userID, role, time.Now())
 	} else {
@@ -312,6 +330,39 @@ func (ur *UserRepository) UpdateUserRole(ctx context.Context, userID string, rol
 	return nil
 }
 
+// UpdateLastLogin records that a user logged in now
+func (ur *UserRepository) UpdateLastLogin(ctx context.Context, userID string) (err error) {
+	defer ur.repo.metrics.Track(instrument.UserRepository, "UpdateLastLogin")(&err)
+
+	if userID == "" {
+		return portal.NewValidationError("INVALID_USER_ID", "user ID cannot be empty")
+	}
+
+	query := `UPDATE users SET last_login_at = $2 WHERE id = $1`
+
+	var result sql.Result
+	if ur.tx != nil {
+		result, err = ur.tx.execCommand(ctx, query, userID, time.Now())
+	} else {
+		result, err = ur.repo.execCommand(ctx, query, userID, time.Now())
+	}
+
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return portal.NewDatabaseError("ROWS_AFFECTED_FAILED", "failed to get rows affected", err)
+	}
+
+	if rowsAffected == 0 {
+		return portal.NewNotFoundError("USER_NOT_FOUND", "user not found")
+	}
+
+	return nil
+}
+
 // validateUser validates user data
 func (ur *UserRepository) validateUser(user *portal.User) error {
 	if user == nil {
@@ -335,8 +386,16 @@ func (ur *UserRepository) validateUser(user *portal.User) error {
 	return nil
 }
 
+// isEmailViolation checks if a unique violation is on the user email, which
+// is unique ignoring case
+func isEmailViolation(err error) bool {
+	return strings.Contains(err.Error(), "users_email_key") || strings.Contains(err.Error(), "users_email_lower_key")
+}
+
 // ListUsers retrieves users based on filter criteria
-func (ur *UserRepository) ListUsers(ctx context.Context, filter *portal.UserFilter) (*portal.PaginatedUsers, error) {
+func (ur *UserRepository) ListUsers(ctx context.Context, filter *portal.UserFilter) (_ *portal.PaginatedUsers, err error) {
+	defer ur.repo.metrics.Track(instrument.UserRepository, "ListUsers")(&err)
+
 	if filter == nil {
 		filter = &portal.UserFilter{}
 	}
@@ -354,6 +413,10 @@ func (ur *UserRepository) ListUsers(ctx context.Context, filter *portal.UserFilt
 	if filter.SortOrder == "" {
 		filter.SortOrder = "desc"
 	}
+	cursorMode := filter.CursorMode()
+	if cursorMode && filter.PageSize <= 0 {
+		filter.PageSize = 50
+	}
 
 	// Build WHERE clause
 	whereClause, args := ur.buildWhereClause(filter)
@@ -361,31 +424,55 @@ func (ur *UserRepository) ListUsers(ctx context.Context, filter *portal.UserFilt
 	// Build ORDER BY clause
 	orderBy := ur.buildOrderByClause(filter.SortBy, filter.SortOrder)
 
-	// Count total records
-	countQuery := fmt.Sprintf("SELECT COUNT(*) FROM users %s", whereClause)
-	var total int64
-	var row *sql.Row
+	// Page after the cursor instead of an offset in cursor mode, keeping
+	// the unpaged WHERE clause for the count
+	pageWhereClause, pageArgs := whereClause, args
+	if cursorMode {
+		pageWhereClause, pageArgs, orderBy, err = keysetClause(whereClause, args, filter.Cursor, filter.SortOrder)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	queryRow := ur.repo.execQueryRow
 	if ur.tx != nil {
-		row = ur.tx.execQueryRow(ctx, countQuery, args...)
-	} else {
-		row = ur.repo.execQueryRow(ctx, countQuery, args...)
+		queryRow = ur.tx.execQueryRow
 	}
 
-	if err := row.Scan(&total); err != nil {
-		return nil, portal.NewDatabaseError("COUNT_FAILED", "failed to count users", err)
+	// Count total records, estimating the count when allowed
+	var total int64
+	var estimated bool
+	if filter.EstimateTotal {
+		total, estimated, err = estimateCount(ctx, queryRow, "users", whereClause, args)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if !estimated {
+		countQuery := fmt.Sprintf("SELECT COUNT(*) FROM users %s", whereClause)
+		if err := queryRow(ctx, countQuery, args...).Scan(&total); err != nil {
+			return nil, portal.NewDatabaseError("COUNT_FAILED", "failed to count users", err)
+		}
 	}
 
-	// Query users with pagination
+	// Query users with pagination, fetching one more row to tell whether
+	// more follow when the total is estimated or paging with a cursor
+	limit, offset := filter.Limit, filter.Offset
+	if cursorMode {
+		limit, offset = filter.PageSize, 0
+	}
+	if estimated || cursorMode {
+		limit++
+	}
 	query := fmt.Sprintf(`
-		SELECT id, email, name, role, status, created_at, updated_at
+		SELECT id, email, name, role, status, created_at, updated_at, last_login_at
 		FROM users %s %s
 		LIMIT $%d OFFSET $%d`,
-		whereClause, orderBy, len(args)+1, len(args)+2)
+		pageWhereClause, orderBy, len(pageArgs)+1, len(pageArgs)+2)
 
-	args = append(args, filter.Limit, filter.Offset)
+	args = append(pageArgs, limit, offset)
 
 	var rows *sql.Rows
-	var err error
 	if ur.tx != nil {
 		rows, err = ur.tx.execQuery(ctx, query, args...)
 	} else {
@@ -400,7 +487,7 @@ func (ur *UserRepository) ListUsers(ctx context.Context, filter *portal.UserFilt
 	var users []*portal.User
 	for rows.Next() {
 		user := &portal.User{}
-		err := rows.Scan(&user.ID, &user.Email, &user.Name, &user.Role, &user.Status, &user.CreatedAt, &user.UpdatedAt)
+		err := rows.Scan(&user.ID, &user.Email, &user.Name, &user.Role, &user.Status, &user.CreatedAt, &user.UpdatedAt, &user.LastLoginAt)
 		if err != nil {
 			return nil, portal.NewDatabaseError("SCAN_FAILED", "failed to scan user", err)
 		}
@@ -411,19 +498,49 @@ func (ur *UserRepository) ListUsers(ctx context.Context, filter *portal.UserFilt
 		return nil, portal.NewDatabaseError("ROWS_ERROR", "error iterating rows", err)
 	}
 
+	if cursorMode {
+		var nextCursor string
+		if len(users) > filter.PageSize {
+			users = users[:filter.PageSize]
+			last := users[len(users)-1]
+			nextCursor = portal.EncodeCursor(last.CreatedAt, last.ID)
+		}
+		if estimated && total < int64(len(users)) {
+			total = int64(len(users))
+		}
+		return &portal.PaginatedUsers{
+			Users:          users,
+			Total:          total,
+			Limit:          filter.PageSize,
+			HasMore:        nextCursor != "",
+			TotalEstimated: estimated,
+			NextCursor:     nextCursor,
+		}, nil
+	}
+
 	hasMore := int64(filter.Offset)+int64(len(users)) < total
+	if estimated {
+		hasMore = len(users) > filter.Limit
+		if hasMore {
+			users = users[:filter.Limit]
+		}
+		total = paginatedTotal(total, filter.Offset, len(users), hasMore)
+	}
 
 	return &portal.PaginatedUsers{
-		Users:   users,
-		Total:   total,
-		Offset:  filter.Offset,
-		Limit:   filter.Limit,
-		HasMore: hasMore,
+		Users:          users,
+		Total:          total,
+		Offset:         filter.Offset,
+		Limit:          filter.Limit,
+		HasMore:        hasMore,
+		TotalEstimated: estimated,
 	}, nil
 }
 
 // CountUsers returns the total count of users matching the filter
-func (ur *UserRepository) CountUsers(ctx context.Context, filter *portal.UserFilter) (int64, error) {
+func (ur *UserRepository) CountUsers(ctx context.Context, filter *portal.UserFilter) (_ int64, err error) {
+	defer ur.repo.metrics.Track(instrument.UserRepository, "CountUsers")(&err)
+
 	if filter == nil {
 		filter = &portal.UserFilter{}
 	}
@@ -447,7 +564,9 @@ func (ur *UserRepository) CountUsers(ctx context.Context, filter *portal.UserFil
 }
 
 // BatchCreateUsers creates multiple users in a single transaction
-func (ur *UserRepository) BatchCreateUsers(ctx context.Context, users []*portal.User) error {
+func (ur *UserRepository) BatchCreateUsers(ctx context.Context, users []*portal.User) (err error) {
+	defer ur.repo.metrics.Track(instrument.UserRepository, "BatchCreateUsers")(&err)
+
 	if len(users) == 0 {
 		return nil
 	}
@@ -457,6 +576,7 @@ func (ur *UserRepository) BatchCreateUsers(ctx context.Context, users []*portal.
 		if err := ur.validateUser(user); err != nil {
 			return err
 		}
+		user.Email = portal.NormalizeEmail(user.Email)
 	}
 
 	// Use a transaction if not already in one
@@ -493,7 +613,7 @@ func (ur *UserRepository) BatchCreateUsers(ctx context.Context, users []*portal.
 				if strings.Contains(err.Error(), "users_pkey") {
 					return portal.NewConflictError("USER_ALREADY_EXISTS", fmt.Sprintf("user with ID %s already exists", user.ID))
 				}
-				if strings.Contains(err.Error(), "users_email_key") {
+				if isEmailViolation(err) {
 					return portal.NewConflictError("USER_EMAIL_EXISTS", fmt.Sprintf("user with email %s already exists", user.Email))
 				}
 			}
@@ -505,7 +625,9 @@ func (ur *UserRepository) BatchCreateUsers(ctx context.Context, users []*portal.
 }
 
 // BatchUpdateUsers updates multiple users in a single transaction
-func (ur *UserRepository) BatchUpdateUsers(ctx context.Context, users []*portal.User) error {
+func (ur *UserRepository) BatchUpdateUsers(ctx context.Context, users []*portal.User) (err error) {
+	defer ur.repo.metrics.Track(instrument.UserRepository, "BatchUpdateUsers")(&err)
+
 	if len(users) == 0 {
 		return nil
 	}
@@ -515,6 +637,7 @@ func (ur *UserRepository) BatchUpdateUsers(ctx context.Context, users []*portal.
 		if err := ur.validateUser(user); err != nil {
 			return err
 		}
+		user.Email = portal.NormalizeEmail(user.Email)
 	}
 
 	// Use a transaction if not already in one
@@ -552,7 +675,7 @@ func (ur *UserRepository) BatchUpdateUsers(ctx context.Context, users []*portal.
 
 		_, err = ur.tx.execCommand(ctx, query, user.ID, user.Email, user.Name, user.Role, user.Status, user.UpdatedAt)
 		if err != nil {
-			if isUniqueViolation(err) && strings.Contains(err.Error(), "users_email_key") {
+			if isUniqueViolation(err) && isEmailViolation(err) {
 				return portal.NewConflictError("USER_EMAIL_EXISTS", fmt.Sprintf("user with email %s already exists", user.Email))
 			}
 			return err
@@ -563,7 +686,9 @@ func (ur *UserRepository) BatchUpdateUsers(ctx context.Context, users []*portal.
 }
 
 // BatchDeleteUsers deletes multiple users by IDs
-func (ur *UserRepository) BatchDeleteUsers(ctx context.Context, userIDs []string) error {
+func (ur *UserRepository) BatchDeleteUsers(ctx context.Context, userIDs []string) (err error) {
+	defer ur.repo.metrics.Track(instrument.UserRepository, "BatchDeleteUsers")(&err)
+
 	if len(userIDs) == 0 {
 		return nil
 	}
@@ -633,8 +758,8 @@ func (ur *UserRepository) buildWhereClause(filter *portal.UserFilter) (string, [
 	argIndex := 1
 
 	if filter.Email != "" {
-		conditions = append(conditions, fmt.Sprintf("email = $%d", argIndex))
-		args = append(args, filter.Email)
+		conditions = append(conditions, fmt.Sprintf("lower(email) = $%d", argIndex))
+		args = append(args, portal.NormalizeEmail(filter.Email))
 		argIndex++
 	}
 
@@ -674,6 +799,13 @@ func (ur *UserRepository) buildWhereClause(filter *portal.UserFilter) (string, [
 		argIndex++
 	}
 
+	// Users who never logged in are dormant too
+	if filter.LastLoginBefore != nil {
+		conditions = append(conditions, fmt.Sprintf("(last_login_at IS NULL OR last_login_at < $%d)", argIndex))
+		args = append(args, *filter.LastLoginBefore)
+		argIndex++
+	}
+
 	whereClause := ""
 	if len(conditions) > 0 {
 		whereClause = "WHERE " + strings.Join(conditions, " AND ")
@@ -0,0 +1,153 @@
+// Package secret hashes application API secrets at rest for the portal
+// repository implementations.
+package secret
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Hashing algorithms
+const (
+	AlgorithmBcrypt   = "bcrypt"
+	AlgorithmArgon2id = "argon2id"
+)
+
+// Argon2id parameters, following the second recommended option of RFC 9106
+const (
+	argon2Time    = 3
+	argon2Memory  = 64 * 1024
+	argon2Threads = 4
+	argon2KeyLen  = 32
+	argon2SaltLen = 16
+)
+
+// Hasher hashes API secrets with a configured algorithm and verifies them
+// against hashes of any supported algorithm, as well as against secrets
+// stored in plaintext before hashing was introduced
+type Hasher struct {
+	algorithm  string
+	bcryptCost int
+}
+
+// NewHasher creates a hasher for the algorithm, bcrypt when empty
+func NewHasher(algorithm string) (*Hasher, error) {
+	switch algorithm {
+	case "":
+		algorithm = AlgorithmBcrypt
+	case AlgorithmBcrypt, AlgorithmArgon2id:
+	default:
+		return nil, fmt.Errorf("unsupported secret hash algorithm: %s", algorithm)
+	}
+	return &Hasher{algorithm: algorithm, bcryptCost: bcrypt.DefaultCost}, nil
+}
+
+// Default returns a bcrypt hasher
+func Default() *Hasher {
+	return &Hasher{algorithm: AlgorithmBcrypt, bcryptCost: bcrypt.DefaultCost}
+}
+
+// Hash returns the hash of a secret to store, or "" for an empty secret
+func (h *Hasher) Hash(secret string) (string, error) {
+	if secret == "" {
+		return "", nil
+	}
+
+	switch h.algorithm {
+	case AlgorithmArgon2id:
+		salt := make([]byte, argon2SaltLen)
+		if _, err := rand.Read(salt); err != nil {
+			return "", err
+		}
+		key := argon2.IDKey([]byte(secret), salt, argon2Time, argon2Memory, argon2Threads, argon2KeyLen)
+		return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, argon2Memory, argon2Time, argon2Threads,
+			base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+	default:
+		hash, err := bcrypt.GenerateFromPassword(bcryptInput(secret), h.bcryptCost)
+		if err != nil {
+			return "", err
+		}
+		return string(hash), nil
+	}
+}
+
+// Verify reports whether a presented secret matches a stored one, and
+// whether the stored secret should be replaced with a hash by this hasher
+// because it is in plaintext or hashed with another algorithm
+func (h *Hasher) Verify(stored, presented string) (match, rehash bool) {
+	if stored == "" || presented == "" {
+		return false, false
+	}
+
+	switch algorithmOf(stored) {
+	case AlgorithmBcrypt:
+		match = bcrypt.CompareHashAndPassword([]byte(stored), bcryptInput(presented)) == nil
+	case AlgorithmArgon2id:
+		match = verifyArgon2id(stored, presented)
+	default:
+		match = subtle.ConstantTimeCompare([]byte(stored), []byte(presented)) == 1
+	}
+	return match, match && algorithmOf(stored) != h.algorithm
+}
+
+// IsHashed reports whether a stored secret is hashed rather than plaintext
+func IsHashed(stored string) bool {
+	return algorithmOf(stored) != ""
+}
+
+// algorithmOf returns the algorithm a stored secret is hashed with, or "" if
+// it is in plaintext. Generated secrets never start with "$".
+func algorithmOf(stored string) string {
+	switch {
+	case strings.HasPrefix(stored, "$2a$"), strings.HasPrefix(stored, "$2b$"), strings.HasPrefix(stored, "$2y$"):
+		return AlgorithmBcrypt
+	case strings.HasPrefix(stored, "$argon2id$"):
+		return AlgorithmArgon2id
+	default:
+		return ""
+	}
+}
+
+// bcryptInput returns the SHA-256 of a secret in hex, since bcrypt only takes
+// inputs of up to 72 bytes
+func bcryptInput(secret string) []byte {
+	sum := sha256.Sum256([]byte(secret))
+	return []byte(hex.EncodeToString(sum[:]))
+}
+
+// verifyArgon2id verifies a secret against an encoded argon2id hash
+func verifyArgon2id(stored, presented string) bool {
+	// $argon2id$v=19$m=65536,t=3,p=4$<salt>$<key>
+	parts := strings.Split(stored, "$")
+	if len(parts) != 6 {
+		return false
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return false
+	}
+	var memory, time uint32
+	var threads uint8
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &time, &threads); err != nil {
+		return false
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return false
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return false
+	}
+
+	presentedKey := argon2.IDKey([]byte(presented), salt, time, memory, threads, uint32(len(key)))
+	return subtle.ConstantTimeCompare(key, presentedKey) == 1
+}
@@ -0,0 +1,64 @@
+package secret
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHasher(t *testing.T) {
+	if _, err := NewHasher("md5"); err == nil {
+		t.Error("Expected an unsupported algorithm to be rejected")
+	}
+
+	bcryptHasher, _ := NewHasher("")
+	argon2Hasher, _ := NewHasher(AlgorithmArgon2id)
+	// Longer than the 72 bytes bcrypt takes
+	apiSecret := "as_" + strings.Repeat("0123456789abcdef", 6)
+
+	for _, tc := range []struct {
+		name   string
+		hasher *Hasher
+		prefix string
+		other  *Hasher
+	}{
+		{"bcrypt", bcryptHasher, "$2a$", argon2Hasher},
+		{"argon2id", argon2Hasher, "$argon2id$", bcryptHasher},
+	} {
+		hash, err := tc.hasher.Hash(apiSecret)
+		if err != nil {
+			t.Fatalf("%s: Hash() returned error: %v", tc.name, err)
+		}
+		if !strings.HasPrefix(hash, tc.prefix) || !IsHashed(hash) {
+			t.Errorf("%s: unexpected hash %s", tc.name, hash)
+		}
+		if match, rehash := tc.hasher.Verify(hash, apiSecret); !match || rehash {
+			t.Errorf("%s: expected a match without rehash, got %v %v", tc.name, match, rehash)
+		}
+		if match, _ := tc.hasher.Verify(hash, apiSecret[:len(apiSecret)-1]); match {
+			t.Errorf("%s: expected a different secret to be rejected", tc.name)
+		}
+		// Hashes of the other algorithm verify and are rehashed
+		if match, rehash := tc.other.Verify(hash, apiSecret); !match || !rehash {
+			t.Errorf("%s: expected a match with rehash by the other algorithm, got %v %v", tc.name, match, rehash)
+		}
+	}
+
+	// Plaintext secrets verify and are rehashed
+	if IsHashed("as_plain") {
+		t.Error("Expected a plaintext secret not to be hashed")
+	}
+	if match, rehash := bcryptHasher.Verify("as_plain", "as_plain"); !match || !rehash {
+		t.Errorf("Expected a plaintext match with rehash, got %v %v", match, rehash)
+	}
+	if match, rehash := bcryptHasher.Verify("as_plain", "as_other"); match || rehash {
+		t.Errorf("Expected a plaintext mismatch, got %v %v", match, rehash)
+	}
+
+	// Empty secrets are stored empty and never match
+	if hash, _ := bcryptHasher.Hash(""); hash != "" {
+		t.Errorf("Expected an empty hash for an empty secret, got %s", hash)
+	}
+	if match, _ := bcryptHasher.Verify("", ""); match {
+		t.Error("Expected an empty secret never to match")
+	}
+}
@@ -0,0 +1,85 @@
+package proxy
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/songzhibin97/stargate/internal/tls"
+)
+
+// ACMERenewResponse is the response of the certificate renewal endpoint
+type ACMERenewResponse struct {
+	Domain   string    `json:"domain"`
+	Renewed  bool      `json:"renewed"`
+	NotAfter time.Time `json:"not_after,omitempty"` // Expiry of the new certificate
+	Error    string    `json:"error,omitempty"`
+}
+
+// SetACMEManager sets the ACME manager whose certificates the renewal
+// endpoint renews
+func (p *Pipeline) SetACMEManager(manager *tls.ACMEManager) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.acmeManager = manager
+}
+
+// acmeRenewPath returns the path of the certificate renewal endpoint, or ""
+// if the REST Admin API is disabled
+func (p *Pipeline) acmeRenewPath() string {
+	return p.nodeAdminPath("/tls/acme/renew")
+}
+
+// handleACMERenew renews the certificate of the domain query parameter
+// immediately and reports its new expiry
+func (p *Pipeline) handleACMERenew(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(map[string]string{"error": "method not allowed"})
+		return
+	}
+
+	p.mu.RLock()
+	manager := p.acmeManager
+	p.mu.RUnlock()
+	if manager == nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "ACME is not enabled"})
+		return
+	}
+
+	domain := r.URL.Query().Get("domain")
+	if domain == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "domain is required"})
+		return
+	}
+
+	response := ACMERenewResponse{Domain: domain}
+	notAfter, err := manager.ForceRenew(r.Context(), domain)
+	var rateLimited *tls.RenewalRateLimitError
+	switch {
+	case err == nil:
+		response.Renewed = true
+		response.NotAfter = notAfter
+		log.Printf("Certificate for %s renewed on request from %s", domain, r.RemoteAddr)
+	case errors.Is(err, tls.ErrUnknownDomain):
+		response.Error = err.Error()
+		w.WriteHeader(http.StatusBadRequest)
+	case errors.As(err, &rateLimited):
+		response.Error = err.Error()
+		retryAfter := int(time.Until(rateLimited.RetryAt).Seconds()) + 1
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+		w.WriteHeader(http.StatusTooManyRequests)
+	default:
+		response.Error = err.Error()
+		w.WriteHeader(http.StatusBadGateway)
+	}
+	json.NewEncoder(w).Encode(response)
+}
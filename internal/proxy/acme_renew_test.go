@@ -0,0 +1,76 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/songzhibin97/stargate/internal/config"
+	"github.com/songzhibin97/stargate/internal/tls"
+)
+
+func TestPipeline_ACMERenew(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.AdminAPI.REST = config.RESTConfig{Enabled: true, Prefix: "/api/v1"}
+	cfg.AdminAPI.Auth = config.AuthConfig{
+		Enabled: true,
+		APIKey:  config.APIKeyConfig{Header: "X-Admin-Key", Keys: []string{"admin-key"}},
+	}
+
+	pipeline, err := NewPipeline(cfg, nil)
+	if err != nil {
+		t.Fatalf("Failed to create pipeline: %v", err)
+	}
+	defer pipeline.Stop()
+
+	request := func(method, target string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, nil)
+		req.Header.Set("X-Admin-Key", "admin-key")
+		rr := httptest.NewRecorder()
+		pipeline.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := httptest.NewRecorder()
+	pipeline.ServeHTTP(rr, httptest.NewRequest("POST", "/_stargate/admin/tls/acme/renew?domain=example.com", nil))
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 without credentials, got %d", rr.Code)
+	}
+
+	if rr := request("POST", "/_stargate/admin/tls/acme/renew?domain=example.com"); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 without ACME, got %d", rr.Code)
+	}
+
+	manager, err := tls.NewACMEManager(&config.ACMEConfig{
+		Enabled:   true,
+		Domains:   []string{"example.com"},
+		Email:     "ops@example.com",
+		CacheDir:  t.TempDir(),
+		AcceptTOS: true,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create ACME manager: %v", err)
+	}
+	pipeline.SetACMEManager(manager)
+
+	if rr := request("GET", "/_stargate/admin/tls/acme/renew?domain=example.com"); rr.Code != http.StatusMethodNotAllowed || rr.Header().Get("Allow") != http.MethodPost {
+		t.Errorf("Expected status 405 allowing POST, got %d", rr.Code)
+	}
+	if rr := request("POST", "/_stargate/admin/tls/acme/renew"); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 without a domain, got %d", rr.Code)
+	}
+
+	rr = request("POST", "/_stargate/admin/tls/acme/renew?domain=other.example.com")
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status 400 for an unmanaged domain, got %d", rr.Code)
+	}
+	var response ACMERenewResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if response.Renewed || response.Domain != "other.example.com" || !strings.Contains(response.Error, "not managed") {
+		t.Errorf("Unexpected response: %+v", response)
+	}
+}
@@ -0,0 +1,126 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// Cache flush scopes
+const (
+	CacheScopeResponse      = "response"      // Cached serverless function results and aggregator fallback responses
+	CacheScopeIntrospection = "introspection" // Cached OAuth 2.0 introspection results
+	CacheScopeRateLimit     = "ratelimit"     // Rate limit counters
+	CacheScopeAll           = "all"
+)
+
+// cacheFlushScopes lists the scopes flushed by CacheScopeAll, in order
+var cacheFlushScopes = []string{CacheScopeResponse, CacheScopeIntrospection, CacheScopeRateLimit}
+
+// CacheFlushResponse is the response of the cache flush endpoint
+type CacheFlushResponse struct {
+	Flushed map[string]int64  `json:"flushed"`          // Entries cleared per scope
+	Total   int64             `json:"total"`            // Entries cleared across scopes
+	Errors  map[string]string `json:"errors,omitempty"` // Scopes that failed to flush
+}
+
+// cacheFlushPath returns the path of the cache flush endpoint, or "" if the
+// REST Admin API is disabled
+func (p *Pipeline) cacheFlushPath() string {
+	return p.nodeAdminPath("/cache/flush")
+}
+
+// handleCacheFlush clears the caches selected by the scope query parameter,
+// a comma separated list of scopes or "all", and reports the entries cleared.
+// Rate limit counters in Redis are flushed under the gateway's key prefix
+// only, so other data in the same database is left alone.
+func (p *Pipeline) handleCacheFlush(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(map[string]string{"error": "method not allowed"})
+		return
+	}
+
+	scopes, err := parseCacheFlushScopes(r.URL.Query().Get("scope"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	response := CacheFlushResponse{Flushed: make(map[string]int64, len(scopes))}
+	for _, scope := range scopes {
+		flushed, err := p.flushCache(r, scope)
+		response.Flushed[scope] = flushed
+		response.Total += flushed
+		if err != nil {
+			if response.Errors == nil {
+				response.Errors = make(map[string]string)
+			}
+			response.Errors[scope] = err.Error()
+		}
+	}
+
+	log.Printf("Flushed caches %s from %s: %d entries cleared", strings.Join(scopes, ","), r.RemoteAddr, response.Total)
+
+	if len(response.Errors) > 0 {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+// flushCache clears one cache scope and returns the entries cleared
+func (p *Pipeline) flushCache(r *http.Request, scope string) (int64, error) {
+	switch scope {
+	case CacheScopeResponse:
+		var flushed int64
+		if p.serverlessMiddleware != nil {
+			flushed += int64(p.serverlessMiddleware.FlushCache())
+		}
+		if p.aggregatorMiddleware != nil {
+			flushed += int64(p.aggregatorMiddleware.FlushCache())
+		}
+		return flushed, nil
+	case CacheScopeIntrospection:
+		if p.authMiddleware == nil {
+			return 0, nil
+		}
+		return int64(p.authMiddleware.FlushIntrospectionCache()), nil
+	case CacheScopeRateLimit:
+		if p.rateLimitMiddleware == nil {
+			return 0, nil
+		}
+		return p.rateLimitMiddleware.Flush(r.Context())
+	}
+	return 0, fmt.Errorf("unknown cache scope %q", scope)
+}
+
+// parseCacheFlushScopes parses and validates the scope query parameter
+func parseCacheFlushScopes(value string) ([]string, error) {
+	if value == "" {
+		return nil, fmt.Errorf("scope is required, one or more of %s or %s", strings.Join(cacheFlushScopes, ", "), CacheScopeAll)
+	}
+
+	var scopes []string
+	seen := make(map[string]bool)
+	for _, scope := range strings.Split(value, ",") {
+		scope = strings.TrimSpace(scope)
+		switch scope {
+		case CacheScopeAll:
+			return cacheFlushScopes, nil
+		case CacheScopeResponse, CacheScopeIntrospection, CacheScopeRateLimit:
+			if !seen[scope] {
+				seen[scope] = true
+				scopes = append(scopes, scope)
+			}
+		default:
+			return nil, fmt.Errorf("unknown cache scope %q", scope)
+		}
+	}
+	return scopes, nil
+}
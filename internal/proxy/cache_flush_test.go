@@ -0,0 +1,93 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/songzhibin97/stargate/internal/config"
+	"github.com/songzhibin97/stargate/internal/ratelimit"
+)
+
+func TestPipeline_CacheFlush(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.AdminAPI.REST = config.RESTConfig{Enabled: true, Prefix: "/api/v1"}
+	cfg.AdminAPI.Auth = config.AuthConfig{
+		Enabled: true,
+		APIKey:  config.APIKeyConfig{Header: "X-Admin-Key", Keys: []string{"secret"}},
+	}
+
+	pipeline, err := NewPipeline(cfg, nil)
+	if err != nil {
+		t.Fatalf("Failed to create pipeline: %v", err)
+	}
+	defer pipeline.Stop()
+
+	pipeline.rateLimitMiddleware, err = ratelimit.NewMiddleware(&ratelimit.Config{
+		Enabled:            true,
+		Strategy:           ratelimit.StrategyFixedWindow,
+		IdentifierStrategy: ratelimit.IdentifierIP,
+		WindowSize:         time.Minute,
+		MaxRequests:        1,
+		CleanupInterval:    time.Minute,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create rate limit middleware: %v", err)
+	}
+
+	// Record a counter for one client
+	limited := pipeline.rateLimitMiddleware.Handler()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	limited.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+
+	flush := func(method, target, key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, nil)
+		if key != "" {
+			req.Header.Set("X-Admin-Key", key)
+		}
+		rr := httptest.NewRecorder()
+		pipeline.ServeHTTP(rr, req)
+		return rr
+	}
+
+	// The Admin API prefix belongs to the proxied APIs, so the counter
+	// checked below stays
+	if rr := flush("POST", "/api/v1/cache/flush?scope=ratelimit", "secret"); rr.Code == http.StatusOK {
+		t.Error("Expected the Admin API prefix to be proxied")
+	}
+	if rr := flush("POST", "/_stargate/admin/cache/flush?scope=ratelimit", ""); rr.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 without credentials, got %d", rr.Code)
+	}
+	if rr := flush("GET", "/_stargate/admin/cache/flush?scope=ratelimit", "secret"); rr.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status 405 for GET, got %d", rr.Code)
+	}
+	if rr := flush("POST", "/_stargate/admin/cache/flush?scope=everything", "secret"); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for unknown scope, got %d", rr.Code)
+	}
+	if rr := flush("POST", "/_stargate/admin/cache/flush", "secret"); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 without scope, got %d", rr.Code)
+	}
+
+	rr := flush("POST", "/_stargate/admin/cache/flush?scope=ratelimit", "secret")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var response CacheFlushResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if response.Flushed[CacheScopeRateLimit] != 1 || response.Total != 1 {
+		t.Errorf("Expected 1 rate limit entry flushed, got %+v", response)
+	}
+	if _, ok := response.Flushed[CacheScopeResponse]; ok {
+		t.Error("Expected only the requested scope to be flushed")
+	}
+
+	rr = flush("POST", "/_stargate/admin/cache/flush?scope=all", "secret")
+	response = CacheFlushResponse{}
+	json.Unmarshal(rr.Body.Bytes(), &response)
+	if len(response.Flushed) != len(cacheFlushScopes) || response.Total != 0 {
+		t.Errorf("Expected all scopes flushed with nothing left, got %+v", response)
+	}
+}
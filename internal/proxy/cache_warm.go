@@ -0,0 +1,184 @@
+package proxy
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/songzhibin97/stargate/internal/router"
+)
+
+// cacheWarmRequest is a startup request priming the caches of a route
+type cacheWarmRequest struct {
+	routeID string
+	url     string
+	headers map[string]string
+}
+
+// cacheWarmRequests returns the configured warm requests, ordered by route
+func (p *Pipeline) cacheWarmRequests() []cacheWarmRequest {
+	cfg := p.config.Proxy.CacheWarm
+	routeIDs := make([]string, 0, len(cfg.PerRoute))
+	for routeID := range cfg.PerRoute {
+		routeIDs = append(routeIDs, routeID)
+	}
+	sort.Strings(routeIDs)
+
+	var requests []cacheWarmRequest
+	for _, routeID := range routeIDs {
+		route := cfg.PerRoute[routeID]
+		for _, url := range route.URLs {
+			requests = append(requests, cacheWarmRequest{routeID: routeID, url: url, headers: route.Headers})
+		}
+	}
+	return requests
+}
+
+// startCacheWarm starts requesting the configured warm URLs through the
+// pipeline. Readiness waits until they complete or the warm deadline passes,
+// when unfinished requests are abandoned.
+func (p *Pipeline) startCacheWarm() {
+	if p.config == nil || !p.config.Proxy.CacheWarm.Enabled {
+		return
+	}
+	requests := p.cacheWarmRequests()
+	if len(requests) == 0 {
+		return
+	}
+
+	cfg := p.config.Proxy.CacheWarm
+	done := make(chan struct{})
+	deadline := time.Now().Add(cfg.Deadline)
+
+	p.cacheWarmMu.Lock()
+	p.cacheWarmDone = done
+	p.cacheWarmDeadline = deadline
+	p.cacheWarmMu.Unlock()
+
+	go func() {
+		defer close(done)
+		ctx, cancel := context.WithDeadline(context.Background(), deadline)
+		defer cancel()
+		p.warmCaches(ctx, requests)
+	}()
+}
+
+// warmCaches sends the warm requests with at most the configured number in
+// flight and logs how many succeeded
+func (p *Pipeline) warmCaches(ctx context.Context, requests []cacheWarmRequest) {
+	cfg := p.config.Proxy.CacheWarm
+	start := time.Now()
+	log.Printf("Warming caches with %d requests", len(requests))
+
+	var succeeded atomic.Int64
+	sem := make(chan struct{}, cfg.Concurrency)
+	var wg sync.WaitGroup
+	for _, warm := range requests {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		go func(warm cacheWarmRequest) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			if p.warmCache(ctx, warm, cfg.Timeout) {
+				succeeded.Add(1)
+			}
+		}(warm)
+	}
+	wg.Wait()
+
+	if ctx.Err() != nil {
+		log.Printf("Cache warming stopped at its deadline: %d of %d requests succeeded in %v", succeeded.Load(), len(requests), time.Since(start))
+		return
+	}
+	log.Printf("Cache warming completed: %d of %d requests succeeded in %v", succeeded.Load(), len(requests), time.Since(start))
+}
+
+// warmCache sends one warm request through the pipeline, so its response is
+// cached by the middlewares as for a client, and reports whether it succeeded
+func (p *Pipeline) warmCache(ctx context.Context, warm cacheWarmRequest, timeout time.Duration) bool {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, warm.url, nil)
+	if err != nil {
+		log.Printf("Cache warm request %s of route %s failed: %v", warm.url, warm.routeID, err)
+		return false
+	}
+	req.RemoteAddr = "127.0.0.1:0"
+	req.RequestURI = req.URL.RequestURI()
+	for name, value := range warm.headers {
+		req.Header.Set(name, value)
+	}
+
+	// Requests that would warm another route's caches point at a stale
+	// configuration
+	match := req
+	if p.config.Tenancy.Enabled {
+		match = req.WithContext(router.WithTenant(ctx, p.config.Tenancy.TenantForHost(req.Host)))
+	}
+	if route, err := p.router.Match(match); err != nil || route.ID != warm.routeID {
+		log.Printf("Cache warm request %s doesn't match route %s, skipped", warm.url, warm.routeID)
+		return false
+	}
+
+	w := &cacheWarmResponseWriter{header: make(http.Header)}
+	p.ServeHTTP(w, req)
+	if w.status >= http.StatusBadRequest {
+		log.Printf("Cache warm request %s of route %s failed: status %d", warm.url, warm.routeID, w.status)
+		return false
+	}
+	return true
+}
+
+// cachesWarmedUp reports whether startup cache warming has completed or run
+// past its deadline
+func (p *Pipeline) cachesWarmedUp() bool {
+	p.cacheWarmMu.Lock()
+	done, deadline := p.cacheWarmDone, p.cacheWarmDeadline
+	p.cacheWarmMu.Unlock()
+	if done == nil {
+		return true
+	}
+
+	select {
+	case <-done:
+		return true
+	default:
+		return !time.Now().Before(deadline)
+	}
+}
+
+// cacheWarmResponseWriter discards the response of a warm request, keeping
+// its status
+type cacheWarmResponseWriter struct {
+	header http.Header
+	status int
+}
+
+func (w *cacheWarmResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *cacheWarmResponseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+func (w *cacheWarmResponseWriter) Write(b []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	return len(b), nil
+}
+
+// Flush implements http.Flusher for streamed responses
+func (w *cacheWarmResponseWriter) Flush() {}
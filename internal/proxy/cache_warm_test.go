@@ -0,0 +1,151 @@
+package proxy
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/songzhibin97/stargate/internal/config"
+	"github.com/songzhibin97/stargate/internal/loadbalancer"
+	"github.com/songzhibin97/stargate/internal/types"
+)
+
+func TestPipeline_CacheWarm(t *testing.T) {
+	// The upstream holds warm requests until released
+	release := make(chan struct{})
+	var mu sync.Mutex
+	var warmed []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/health" {
+			return
+		}
+		<-release
+		mu.Lock()
+		warmed = append(warmed, r.Method+" "+r.URL.RequestURI()+" "+r.Header.Get("Accept"))
+		mu.Unlock()
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	newPipeline := func(warm config.CacheWarmConfig) *Pipeline {
+		cfg := &config.Config{}
+		cfg.Proxy.CacheWarm = warm
+		pipeline, err := NewPipeline(cfg, nil)
+		if err != nil {
+			t.Fatalf("Failed to create pipeline: %v", err)
+		}
+		t.Cleanup(func() { pipeline.Stop() })
+
+		host, portStr, _ := net.SplitHostPort(strings.TrimPrefix(server.URL, "http://"))
+		port, _ := strconv.Atoi(portStr)
+		lb := loadbalancer.NewRoundRobinBalancer(cfg)
+		if err := lb.UpdateUpstream(&types.Upstream{
+			ID:        "default-upstream",
+			Name:      "default-upstream",
+			Algorithm: "round_robin",
+			Targets:   []*types.Target{{Host: host, Port: port, Weight: 100, Healthy: true}},
+		}); err != nil {
+			t.Fatalf("Failed to add upstream: %v", err)
+		}
+		pipeline.loadBalancer = lb
+
+		// The mock router matches every request to route "default"
+		pipeline.router = &MockRouter{}
+		return pipeline
+	}
+
+	pipeline := newPipeline(config.CacheWarmConfig{
+		Enabled:     true,
+		Concurrency: 2,
+		Timeout:     5 * time.Second,
+		Deadline:    5 * time.Second,
+		PerRoute: map[string]config.CacheWarmRoute{
+			"default": {
+				URLs:    []string{"http://example.com/catalog?page=1", "http://example.com/missing"},
+				Headers: map[string]string{"Accept": "application/json"},
+			},
+			// Matches route "default" instead, so it's skipped
+			"other": {URLs: []string{"http://example.com/other"}},
+		},
+	})
+	pipeline.startCacheWarm()
+
+	if pipeline.Ready() {
+		t.Fatal("Expected the pipeline not to be ready while warming caches")
+	}
+	close(release)
+
+	waitFor := func(cond func() bool) bool {
+		for deadline := time.Now().Add(3 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+			if cond() {
+				return true
+			}
+		}
+		return false
+	}
+	if !waitFor(pipeline.Ready) {
+		t.Fatal("Expected the pipeline to be ready once caches are warmed, failed requests included")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	got := strings.Join(warmed, ",")
+	for _, want := range []string{"GET /catalog?page=1 application/json", "GET /missing application/json"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Expected warm request %q, got %q", want, got)
+		}
+	}
+	if len(warmed) != 2 {
+		t.Errorf("Expected the request of the unmatched route to be skipped, got %q", got)
+	}
+}
+
+func TestPipeline_CacheWarmDeadline(t *testing.T) {
+	// The upstream answers after the warm deadline
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(300 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{}
+	cfg.Proxy.CacheWarm = config.CacheWarmConfig{
+		Enabled:     true,
+		Concurrency: 1,
+		Timeout:     5 * time.Second,
+		Deadline:    100 * time.Millisecond,
+		PerRoute:    map[string]config.CacheWarmRoute{"default": {URLs: []string{"http://example.com/slow"}}},
+	}
+	pipeline, err := NewPipeline(cfg, nil)
+	if err != nil {
+		t.Fatalf("Failed to create pipeline: %v", err)
+	}
+	defer pipeline.Stop()
+
+	host, portStr, _ := net.SplitHostPort(strings.TrimPrefix(server.URL, "http://"))
+	port, _ := strconv.Atoi(portStr)
+	lb := loadbalancer.NewRoundRobinBalancer(cfg)
+	lb.UpdateUpstream(&types.Upstream{
+		ID:        "default-upstream",
+		Name:      "default-upstream",
+		Algorithm: "round_robin",
+		Targets:   []*types.Target{{Host: host, Port: port, Weight: 100, Healthy: true}},
+	})
+	pipeline.loadBalancer = lb
+	pipeline.router = &MockRouter{}
+
+	pipeline.startCacheWarm()
+	if pipeline.Ready() {
+		t.Fatal("Expected the pipeline not to be ready while warming caches")
+	}
+	time.Sleep(150 * time.Millisecond)
+	if !pipeline.Ready() {
+		t.Error("Expected readiness not to wait for warming past its deadline")
+	}
+}
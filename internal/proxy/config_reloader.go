@@ -88,6 +88,9 @@ func (cr *ConfigReloader) Stop() {
 	cr.store.Unwatch("routes/")
 	cr.store.Unwatch("upstreams/")
 	cr.store.Unwatch("plugins/")
+	if cr.config.Tenancy.Enabled {
+		cr.store.Unwatch(store.TenantsPrefix)
+	}
 
 	cr.wg.Wait()
 	log.Println("Configuration reloader stopped")
@@ -110,9 +113,41 @@ func (cr *ConfigReloader) startWatching() error {
 		return fmt.Errorf("failed to watch plugins: %w", err)
 	}
 
+	// Watch the routes and upstreams of tenants
+	if cr.config.Tenancy.Enabled {
+		if err := cr.store.Watch(store.TenantsPrefix, cr.onTenantChange); err != nil {
+			return fmt.Errorf("failed to watch tenants: %w", err)
+		}
+	}
+
 	return nil
 }
 
+// onTenantChange handles changes of tenants' routes and upstreams
+func (cr *ConfigReloader) onTenantChange(key string, value []byte, eventType store.EventType) {
+	_, kind, _, ok := store.ParseTenantKey(key)
+	if !ok {
+		return
+	}
+	switch kind {
+	case "routes":
+		cr.onRouteChange(key, value, eventType)
+	case "upstreams":
+		cr.onUpstreamChange(key, value, eventType)
+	}
+}
+
+// scopeRoute qualifies the IDs of a tenant's route and of the upstreams it
+// references, so they can't collide with other tenants'
+func scopeRoute(route *router.RouteRule, tenant string) {
+	route.Tenant = tenant
+	route.ID = router.QualifiedID(tenant, route.ID)
+	route.UpstreamID = router.QualifiedID(tenant, route.UpstreamID)
+	for i, fallback := range route.FallbackUpstreams {
+		route.FallbackUpstreams[i] = router.QualifiedID(tenant, fallback)
+	}
+}
+
 // onRouteChange handles route configuration changes
 func (cr *ConfigReloader) onRouteChange(key string, value []byte, eventType store.EventType) {
 	cr.mu.Lock()
@@ -124,6 +159,7 @@ func (cr *ConfigReloader) onRouteChange(key string, value []byte, eventType stor
 
 	log.Printf("Route configuration changed: key=%s, type=%v", key, eventType)
 
+	tenant, _, routeID, _ := store.ParseTenantKey(key)
 	switch eventType {
 	case store.EventTypePut:
 		var route router.RouteRule
@@ -131,10 +167,10 @@ func (cr *ConfigReloader) onRouteChange(key string, value []byte, eventType stor
 			log.Printf("Failed to unmarshal route: %v", err)
 			return
 		}
+		scopeRoute(&route, tenant)
 		cr.updateRoute(&route)
 	case store.EventTypeDelete:
-		routeID := cr.extractIDFromKey(key, "routes/")
-		cr.deleteRoute(routeID)
+		cr.deleteRoute(router.QualifiedID(tenant, routeID))
 	}
 
 	cr.lastUpdate = time.Now()
@@ -151,6 +187,7 @@ func (cr *ConfigReloader) onUpstreamChange(key string, value []byte, eventType s
 
 	log.Printf("Upstream configuration changed: key=%s, type=%v", key, eventType)
 
+	tenant, _, upstreamID, _ := store.ParseTenantKey(key)
 	switch eventType {
 	case store.EventTypePut:
 		var upstream router.Upstream
@@ -158,10 +195,10 @@ func (cr *ConfigReloader) onUpstreamChange(key string, value []byte, eventType s
 			log.Printf("Failed to unmarshal upstream: %v", err)
 			return
 		}
+		upstream.ID = router.QualifiedID(tenant, upstream.ID)
 		cr.updateUpstream(&upstream)
 	case store.EventTypeDelete:
-		upstreamID := cr.extractIDFromKey(key, "upstreams/")
-		cr.deleteUpstream(upstreamID)
+		cr.deleteUpstream(router.QualifiedID(tenant, upstreamID))
 	}
 
 	cr.lastUpdate = time.Now()
@@ -317,18 +354,20 @@ func (cr *ConfigReloader) performFullReload() {
 
 // reloadRoutes reloads all route configurations
 func (cr *ConfigReloader) reloadRoutes(ctx context.Context) error {
-	routesData, err := cr.store.List(ctx, "routes/")
+	routesData, err := cr.listTenantKeys(ctx, "routes")
 	if err != nil {
 		return fmt.Errorf("failed to list routes: %w", err)
 	}
 
 	var routes []router.RouteRule
-	for _, data := range routesData {
+	for key, data := range routesData {
 		var route router.RouteRule
 		if err := json.Unmarshal(data, &route); err != nil {
 			log.Printf("Failed to unmarshal route: %v", err)
 			continue
 		}
+		tenant, _, _, _ := store.ParseTenantKey(key)
+		scopeRoute(&route, tenant)
 		routes = append(routes, route)
 	}
 
@@ -343,18 +382,20 @@ func (cr *ConfigReloader) reloadRoutes(ctx context.Context) error {
 
 // reloadUpstreams reloads all upstream configurations
 func (cr *ConfigReloader) reloadUpstreams(ctx context.Context) error {
-	upstreamsData, err := cr.store.List(ctx, "upstreams/")
+	upstreamsData, err := cr.listTenantKeys(ctx, "upstreams")
 	if err != nil {
 		return fmt.Errorf("failed to list upstreams: %w", err)
 	}
 
 	var upstreams []router.Upstream
-	for _, data := range upstreamsData {
+	for key, data := range upstreamsData {
 		var upstream router.Upstream
 		if err := json.Unmarshal(data, &upstream); err != nil {
 			log.Printf("Failed to unmarshal upstream: %v", err)
 			continue
 		}
+		tenant, _, _, _ := store.ParseTenantKey(key)
+		upstream.ID = router.QualifiedID(tenant, upstream.ID)
 		upstreams = append(upstreams, upstream)
 	}
 
@@ -383,12 +424,27 @@ func (cr *ConfigReloader) reloadPlugins(ctx context.Context) error {
 	return nil
 }
 
-// extractIDFromKey extracts the ID from a store key
-func (cr *ConfigReloader) extractIDFromKey(key, prefix string) string {
-	if len(key) > len(prefix) {
-		return key[len(prefix):]
+// listTenantKeys lists the keys of a kind such as routes, including the
+// keys of every tenant when tenancy is enabled
+func (cr *ConfigReloader) listTenantKeys(ctx context.Context, kind string) (map[string][]byte, error) {
+	data, err := cr.store.List(ctx, store.TenantPrefix("", kind))
+	if err != nil {
+		return nil, err
+	}
+	if !cr.config.Tenancy.Enabled {
+		return data, nil
+	}
+
+	scoped, err := cr.store.List(ctx, store.TenantsPrefix)
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range scoped {
+		if _, keyKind, _, ok := store.ParseTenantKey(key); ok && keyKind == kind {
+			data[key] = value
+		}
 	}
-	return ""
+	return data, nil
 }
 
 // GetStatus returns the status of the configuration reloader
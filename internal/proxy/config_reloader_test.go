@@ -445,3 +445,55 @@ func TestConfigReloader_Status(t *testing.T) {
 		t.Error("Reloader should be running after start")
 	}
 }
+
+func TestConfigReloader_TenantKeys(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Tenancy.Enabled = true
+	mockStore := NewMockStore()
+	mockPipeline := NewMockPipeline()
+
+	reloader := NewConfigReloader(cfg, mockStore, mockPipeline)
+	if err := reloader.Start(); err != nil {
+		t.Fatalf("Failed to start config reloader: %v", err)
+	}
+	defer reloader.Stop()
+
+	ctx := context.Background()
+	route := router.RouteRule{ID: "api", Name: "API", UpstreamID: "backend", FallbackUpstreams: []string{"backup"}}
+	routeData, _ := json.Marshal(route)
+	upstream := router.Upstream{ID: "backend", Name: "Backend"}
+	upstreamData, _ := json.Marshal(upstream)
+
+	mockStore.Put(ctx, "tenants/acme/routes/api", routeData)
+	mockStore.Put(ctx, "tenants/acme/upstreams/backend", upstreamData)
+	time.Sleep(100 * time.Millisecond)
+
+	got, exists := mockPipeline.GetRoutes()["acme/api"]
+	if !exists {
+		t.Fatal("Tenant route was not updated in pipeline under its qualified ID")
+	}
+	if got.Tenant != "acme" || got.UpstreamID != "acme/backend" || got.FallbackUpstreams[0] != "acme/backup" {
+		t.Errorf("Tenant route not scoped: tenant=%q upstream=%q fallbacks=%v", got.Tenant, got.UpstreamID, got.FallbackUpstreams)
+	}
+	if _, exists := mockPipeline.GetUpstreams()["acme/backend"]; !exists {
+		t.Error("Tenant upstream was not updated in pipeline under its qualified ID")
+	}
+
+	// A full reload keeps default tenant and tenant routes apart
+	mockStore.Put(ctx, "routes/api", routeData)
+	time.Sleep(100 * time.Millisecond)
+	reloader.performFullReload()
+	routes := mockPipeline.GetRoutes()
+	if len(routes) != 2 || routes["api"] == nil || routes["acme/api"] == nil {
+		t.Errorf("Expected routes api and acme/api after full reload, got %v", routes)
+	}
+
+	mockStore.Delete(ctx, "tenants/acme/routes/api")
+	time.Sleep(100 * time.Millisecond)
+	if _, exists := mockPipeline.GetRoutes()["acme/api"]; exists {
+		t.Error("Tenant route was not deleted from pipeline")
+	}
+	if _, exists := mockPipeline.GetRoutes()["api"]; !exists {
+		t.Error("Deleting a tenant route removed the default tenant's route")
+	}
+}
@@ -0,0 +1,25 @@
+package proxy
+
+import (
+	pkgconfig "github.com/songzhibin97/stargate/pkg/config"
+)
+
+// SetConfigSource sets the config source whose state is reported in health
+func (p *Pipeline) SetConfigSource(source pkgconfig.HealthReporter) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.configSource = source
+}
+
+// configSourceDegraded reports whether the config source can't be reached,
+// in which case the last known good configuration is served
+func (p *Pipeline) configSourceDegraded() bool {
+	p.mu.RLock()
+	source := p.configSource
+	p.mu.RUnlock()
+
+	if source == nil {
+		return false
+	}
+	return source.Health()["status"] == "degraded"
+}
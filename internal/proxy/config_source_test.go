@@ -0,0 +1,48 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/songzhibin97/stargate/internal/config"
+)
+
+// stubConfigSource reports a fixed config source status
+type stubConfigSource struct {
+	status string
+}
+
+func (s *stubConfigSource) Health() map[string]interface{} {
+	return map[string]interface{}{"status": s.status}
+}
+
+func TestPipeline_ConfigSourceHealth(t *testing.T) {
+	p := &Pipeline{config: &config.Config{Server: config.ServerConfig{HealthPath: "/health"}}}
+
+	health := func() *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		p.ServeHTTP(rr, httptest.NewRequest("GET", "/health", nil))
+		return rr
+	}
+
+	if rr := health(); rr.Body.String() != `{"status": "healthy"}` {
+		t.Errorf("Expected plain health without a config source, got %q", rr.Body.String())
+	}
+
+	source := &stubConfigSource{status: "healthy"}
+	p.SetConfigSource(source)
+	if rr := health(); rr.Body.String() != `{"status": "healthy"}` {
+		t.Errorf("Expected plain health with a healthy config source, got %q", rr.Body.String())
+	}
+
+	// A degraded config source is reported without affecting readiness
+	source.status = "degraded"
+	rr := health()
+	if rr.Code != http.StatusOK || rr.Body.String() != `{"status": "healthy", "config_source": "degraded"}` {
+		t.Errorf("Expected 200 reporting the degraded config source, got %d %q", rr.Code, rr.Body.String())
+	}
+	if got := p.Health()["config_source"].(map[string]interface{})["status"]; got != "degraded" {
+		t.Errorf("Expected pipeline health to include the config source, got %v", got)
+	}
+}
@@ -0,0 +1,84 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/songzhibin97/stargate/internal/clientip"
+	"github.com/songzhibin97/stargate/internal/config"
+	"github.com/songzhibin97/stargate/internal/ratelimit"
+)
+
+// websocketListener is the listener label of rejected WebSocket upgrades
+const websocketListener = "websocket"
+
+// connectionReleaseKey is the context key of the function releasing the
+// connection limit reservation of a WebSocket upgrade
+type connectionReleaseKey struct{}
+
+// newWebSocketLimiters creates the connection limiters of WebSocket upgrades,
+// keyed by route ID with the global limiter under ""
+func newWebSocketLimiters(cfg config.ConnectionLimitConfig) (map[string]*ratelimit.ConnectionLimiter, error) {
+	limiters := make(map[string]*ratelimit.ConnectionLimiter, len(cfg.PerRoute)+1)
+
+	limiter, err := ratelimit.NewConnectionLimiter(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("invalid connection limit: %w", err)
+	}
+	limiters[""] = limiter
+
+	for routeID, routeCfg := range cfg.PerRoute {
+		limiter, err := ratelimit.NewConnectionLimiter(routeCfg)
+		if err != nil {
+			return nil, fmt.Errorf("invalid connection limit for route %s: %w", routeID, err)
+		}
+		limiters[routeID] = limiter
+	}
+
+	return limiters, nil
+}
+
+// acquireWebSocketConnection reserves a connection for a WebSocket upgrade
+// under the limits of the matched route, or the global limits. Rejected
+// upgrades are answered with 429 before the upstream is contacted. The
+// returned request carries the release function for the WebSocket proxy.
+func (p *Pipeline) acquireWebSocketConnection(w http.ResponseWriter, r *http.Request) (*http.Request, func(), bool) {
+	limiter := p.websocketLimiters[""]
+	if route, err := p.router.Match(r); err == nil {
+		if routeLimiter, exists := p.websocketLimiters[route.ID]; exists {
+			limiter = routeLimiter
+		}
+	}
+
+	release, reason, allowed := limiter.Acquire(clientip.FromRequest(r))
+	if !allowed {
+		p.recordRejectedConnection(websocketListener, reason)
+		log.Printf("Rejected WebSocket upgrade from %s: %s", clientip.FromRequest(r), reason)
+		p.handleError(w, r, http.StatusTooManyRequests, "too many connections")
+		return r, nil, false
+	}
+
+	return r.WithContext(context.WithValue(r.Context(), connectionReleaseKey{}, release)), release, true
+}
+
+// connectionRelease returns the connection limit release function of the
+// request, or a no-op if the request holds no reservation
+func connectionRelease(r *http.Request) func() {
+	if release, ok := r.Context().Value(connectionReleaseKey{}).(func()); ok {
+		return release
+	}
+	return func() {}
+}
+
+// recordRejectedConnection counts a connection rejected by connection limits
+func (p *Pipeline) recordRejectedConnection(listener, reason string) {
+	p.mu.Lock()
+	p.rejectedConnections++
+	p.mu.Unlock()
+
+	if p.connectionRejectCounter != nil {
+		p.connectionRejectCounter.WithLabelValues(listener, reason).Inc()
+	}
+}
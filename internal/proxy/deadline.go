@@ -0,0 +1,215 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/songzhibin97/stargate/internal/clientip"
+	"github.com/songzhibin97/stargate/internal/config"
+)
+
+// maxGRPCTimeoutValue is the largest value of a grpc-timeout header, which
+// allows at most 8 digits
+const maxGRPCTimeoutValue = 99999999
+
+// requestDeadlineKey is the context key of the header passing the time left
+// to upstreams, set on requests bounded by a deadline
+type requestDeadlineKey struct{}
+
+// requestDeadline bounds requests by an overall deadline from configuration,
+// optionally shortened by a header from a trusted source
+type requestDeadline struct {
+	timeout  time.Duration
+	perRoute map[string]time.Duration
+	header   string
+	trusted  []*net.IPNet
+}
+
+// newRequestDeadline creates the request deadline from configuration, or
+// returns nil if deadlines are disabled
+func newRequestDeadline(cfg config.RequestDeadlineConfig) (*requestDeadline, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+
+	trusted, err := clientip.ParseNetworks(cfg.TrustedSources)
+	if err != nil {
+		return nil, fmt.Errorf("invalid request deadline trusted sources: %w", err)
+	}
+
+	d := &requestDeadline{
+		timeout:  cfg.Timeout,
+		perRoute: cfg.PerRoute,
+		header:   cfg.Header,
+		trusted:  trusted,
+	}
+	if d.header == "" {
+		d.header = "X-Request-Timeout"
+	}
+	return d, nil
+}
+
+// timeoutFor returns the timeout of a request matching the route, or 0 if it
+// has none. A timeout requested by a trusted source can only shorten the
+// configured one. The header is removed so only the time left reaches the
+// upstream.
+func (d *requestDeadline) timeoutFor(r *http.Request, routeID string) time.Duration {
+	timeout := d.timeout
+	if routeTimeout, ok := d.perRoute[routeID]; ok {
+		timeout = routeTimeout
+	}
+
+	requested := strings.TrimSpace(r.Header.Get(d.header))
+	r.Header.Del(d.header)
+	if requested == "" || !d.trustedSource(r) {
+		return timeout
+	}
+	if t, err := parseRequestTimeout(requested); err == nil && t > 0 && (timeout == 0 || t < timeout) {
+		timeout = t
+	}
+	return timeout
+}
+
+// trustedSource reports whether the connection comes from a source allowed
+// to request a timeout. The peer address is used rather than the resolved
+// client IP, the header is set by the layer directly in front of the gateway.
+func (d *requestDeadline) trustedSource(r *http.Request) bool {
+	ip := net.ParseIP(clientip.RemoteIP(r))
+	if ip == nil {
+		return false
+	}
+	for _, network := range d.trusted {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseRequestTimeout parses a requested timeout, either in milliseconds or
+// as a duration such as 1.5s
+func parseRequestTimeout(value string) (time.Duration, error) {
+	if ms, err := strconv.ParseInt(value, 10, 64); err == nil {
+		if ms > math.MaxInt64/int64(time.Millisecond) {
+			return 0, fmt.Errorf("timeout out of range: %s", value)
+		}
+		return time.Duration(ms) * time.Millisecond, nil
+	}
+	return time.ParseDuration(value)
+}
+
+// withRequestDeadline bounds the request by its deadline, counted from its
+// arrival so time spent before the upstream is accounted for. The returned
+// function releases the deadline's resources.
+func (p *Pipeline) withRequestDeadline(r *http.Request, arrival time.Time) (*http.Request, context.CancelFunc) {
+	p.mu.RLock()
+	d := p.requestDeadline
+	p.mu.RUnlock()
+	if d == nil {
+		return r, func() {}
+	}
+
+	var routeID string
+	if len(d.perRoute) > 0 {
+		routeID = p.matchedRouteID(r)
+	}
+	timeout := d.timeoutFor(r, routeID)
+	if timeout <= 0 {
+		return r, func() {}
+	}
+
+	ctx, cancel := context.WithDeadline(r.Context(), arrival.Add(timeout))
+	ctx = context.WithValue(ctx, requestDeadlineKey{}, d.header)
+	return r.WithContext(ctx), cancel
+}
+
+// deadlineExceeded reports whether the request's deadline has passed,
+// answering it with 504 if so
+func (p *Pipeline) deadlineExceeded(w http.ResponseWriter, r *http.Request) bool {
+	if r.Context().Err() != context.DeadlineExceeded {
+		return false
+	}
+	p.handleError(w, r, http.StatusGatewayTimeout, "request deadline exceeded")
+	return true
+}
+
+// propagateDeadline passes the time left before the request's deadline to
+// the upstream in the deadline header, and as grpc-timeout to gRPC upstreams
+// unless the client asked for less
+func propagateDeadline(req *http.Request) {
+	header, ok := req.Context().Value(requestDeadlineKey{}).(string)
+	if !ok {
+		return
+	}
+	deadline, ok := req.Context().Deadline()
+	if !ok {
+		return
+	}
+	remaining := time.Until(deadline)
+	if remaining <= 0 {
+		return
+	}
+
+	req.Header.Set(header, strconv.FormatInt(int64(math.Ceil(float64(remaining)/float64(time.Millisecond))), 10))
+
+	if strings.HasPrefix(req.Header.Get("Content-Type"), "application/grpc") {
+		if requested, err := parseGRPCTimeout(req.Header.Get("Grpc-Timeout")); err == nil && requested < remaining {
+			return
+		}
+		req.Header.Set("Grpc-Timeout", formatGRPCTimeout(remaining))
+	}
+}
+
+// grpcTimeoutUnits maps grpc-timeout units to durations
+var grpcTimeoutUnits = map[byte]time.Duration{
+	'H': time.Hour,
+	'M': time.Minute,
+	'S': time.Second,
+	'm': time.Millisecond,
+	'u': time.Microsecond,
+	'n': time.Nanosecond,
+}
+
+// parseGRPCTimeout parses a grpc-timeout header value
+func parseGRPCTimeout(value string) (time.Duration, error) {
+	if len(value) < 2 || len(value) > 9 {
+		return 0, fmt.Errorf("invalid grpc-timeout: %q", value)
+	}
+	unit, ok := grpcTimeoutUnits[value[len(value)-1]]
+	if !ok {
+		return 0, fmt.Errorf("invalid grpc-timeout unit: %q", value)
+	}
+	amount, err := strconv.ParseInt(value[:len(value)-1], 10, 64)
+	if err != nil || amount < 0 {
+		return 0, fmt.Errorf("invalid grpc-timeout: %q", value)
+	}
+	if amount > math.MaxInt64/int64(unit) {
+		return math.MaxInt64, nil
+	}
+	return time.Duration(amount) * unit, nil
+}
+
+// formatGRPCTimeout formats a duration as a grpc-timeout header value,
+// rounding up to the finest unit that fits in 8 digits
+func formatGRPCTimeout(timeout time.Duration) string {
+	for _, unit := range []struct {
+		suffix   string
+		duration time.Duration
+	}{
+		{"m", time.Millisecond},
+		{"S", time.Second},
+		{"M", time.Minute},
+	} {
+		amount := int64(math.Ceil(float64(timeout) / float64(unit.duration)))
+		if amount <= maxGRPCTimeoutValue {
+			return strconv.FormatInt(amount, 10) + unit.suffix
+		}
+	}
+	return strconv.FormatInt(int64(math.Ceil(float64(timeout)/float64(time.Hour))), 10) + "H"
+}
@@ -0,0 +1,245 @@
+package proxy
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/songzhibin97/stargate/internal/config"
+	"github.com/songzhibin97/stargate/internal/loadbalancer"
+	"github.com/songzhibin97/stargate/internal/types"
+)
+
+// newDeadlinePipeline creates a pipeline with request deadlines routing
+// everything to default-upstream, with the given upstreams
+func newDeadlinePipeline(t *testing.T, deadline config.RequestDeadlineConfig, upstreams map[string]*httptest.Server) *Pipeline {
+	t.Helper()
+
+	cfg := &config.Config{}
+	cfg.Proxy.DynamicRouting = config.DynamicRoutingConfig{Enabled: true}
+	cfg.Proxy.Deadline = deadline
+
+	pipeline, err := NewPipeline(cfg, nil)
+	if err != nil {
+		t.Fatalf("Failed to create pipeline: %v", err)
+	}
+	t.Cleanup(func() { pipeline.Stop() })
+
+	lb := loadbalancer.NewRoundRobinBalancer(cfg)
+	for id, server := range upstreams {
+		host, portStr, _ := net.SplitHostPort(strings.TrimPrefix(server.URL, "http://"))
+		port, _ := strconv.Atoi(portStr)
+		upstream := &types.Upstream{
+			ID:        id,
+			Name:      id,
+			Algorithm: "round_robin",
+			Targets:   []*types.Target{{Host: host, Port: port, Weight: 100, Healthy: true}},
+		}
+		if err := lb.UpdateUpstream(upstream); err != nil {
+			t.Fatalf("Failed to add upstream: %v", err)
+		}
+	}
+	pipeline.loadBalancer = lb
+	pipeline.router = &MockRouter{}
+	pipeline.middlewares = nil
+
+	return pipeline
+}
+
+func TestPipeline_DeadlineExceededDuringReroute(t *testing.T) {
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		w.Header().Set("X-Route-To", "slow")
+		w.Write([]byte("primary"))
+	}))
+	defer primary.Close()
+
+	timeouts := make(chan string, 1)
+	release := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/health" {
+			return
+		}
+		timeouts <- r.Header.Get("X-Request-Timeout")
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+		w.Write([]byte("slow"))
+	}))
+	defer slow.Close()
+	defer close(release)
+
+	pipeline := newDeadlinePipeline(t, config.RequestDeadlineConfig{
+		Enabled: true,
+		Timeout: 200 * time.Millisecond,
+	}, map[string]*httptest.Server{"default-upstream": primary, "slow": slow})
+
+	start := time.Now()
+	rr := httptest.NewRecorder()
+	pipeline.ServeHTTP(rr, httptest.NewRequest("POST", "/orders", strings.NewReader("order-1")))
+	elapsed := time.Since(start)
+
+	if rr.Code != http.StatusGatewayTimeout {
+		t.Errorf("Expected status 504 when the deadline fires during the re-route, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if elapsed > time.Second {
+		t.Errorf("Expected the deadline to bound the whole request, took %v", elapsed)
+	}
+
+	select {
+	case value := <-timeouts:
+		remaining, err := strconv.Atoi(value)
+		if err != nil {
+			t.Fatalf("Expected the time left in milliseconds, got %q", value)
+		}
+		if remaining <= 0 || remaining > 200 {
+			t.Errorf("Expected the re-routed upstream to get the time left, got %dms", remaining)
+		}
+	default:
+		t.Fatal("Expected the request re-routed to the slow upstream")
+	}
+}
+
+func TestPipeline_DeadlineCountsMiddlewareTime(t *testing.T) {
+	called := false
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Active health checks of the upstream don't count
+		if r.URL.Path != "/health" {
+			called = true
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer upstream.Close()
+
+	pipeline := newDeadlinePipeline(t, config.RequestDeadlineConfig{
+		Enabled: true,
+		Timeout: 50 * time.Millisecond,
+	}, map[string]*httptest.Server{"default-upstream": upstream})
+
+	pipeline.middlewares = []namedMiddleware{{
+		name: "slow",
+		handler: func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				time.Sleep(100 * time.Millisecond)
+				next.ServeHTTP(w, r)
+			})
+		},
+	}}
+
+	rr := httptest.NewRecorder()
+	pipeline.ServeHTTP(rr, httptest.NewRequest("GET", "/", nil))
+
+	if rr.Code != http.StatusGatewayTimeout {
+		t.Errorf("Expected status 504 after middlewares used up the deadline, got %d", rr.Code)
+	}
+	if called {
+		t.Error("Expected the upstream not to be called after the deadline")
+	}
+}
+
+func TestRequestDeadline_TimeoutFor(t *testing.T) {
+	d, err := newRequestDeadline(config.RequestDeadlineConfig{
+		Enabled:        true,
+		Timeout:        10 * time.Second,
+		PerRoute:       map[string]time.Duration{"reports": time.Minute},
+		TrustedSources: []string{"10.0.0.0/8"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create request deadline: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		routeID    string
+		remoteAddr string
+		header     string
+		expected   time.Duration
+	}{
+		{"default timeout", "orders", "10.0.0.1:1234", "", 10 * time.Second},
+		{"per-route timeout", "reports", "10.0.0.1:1234", "", time.Minute},
+		{"trusted milliseconds", "orders", "10.0.0.1:1234", "1500", 1500 * time.Millisecond},
+		{"trusted duration", "reports", "10.0.0.1:1234", "2s", 2 * time.Second},
+		{"trusted cannot extend", "orders", "10.0.0.1:1234", "30s", 10 * time.Second},
+		{"untrusted ignored", "orders", "192.168.1.1:1234", "1500", 10 * time.Second},
+		{"invalid ignored", "orders", "10.0.0.1:1234", "soon", 10 * time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			if tt.header != "" {
+				req.Header.Set("X-Request-Timeout", tt.header)
+			}
+
+			if timeout := d.timeoutFor(req, tt.routeID); timeout != tt.expected {
+				t.Errorf("Expected timeout %v, got %v", tt.expected, timeout)
+			}
+			if req.Header.Get("X-Request-Timeout") != "" {
+				t.Error("Expected the client's timeout header to be removed")
+			}
+		})
+	}
+
+	disabled, err := newRequestDeadline(config.RequestDeadlineConfig{Timeout: time.Second})
+	if err != nil || disabled != nil {
+		t.Errorf("Expected no request deadline when disabled, got %v, %v", disabled, err)
+	}
+}
+
+func TestPropagateDeadline(t *testing.T) {
+	newRequest := func(timeout time.Duration) (*http.Request, context.CancelFunc) {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		ctx = context.WithValue(ctx, requestDeadlineKey{}, "X-Request-Timeout")
+		req := httptest.NewRequest("POST", "/", nil).WithContext(ctx)
+		req.Header.Set("Content-Type", "application/grpc+proto")
+		return req, cancel
+	}
+
+	req, cancel := newRequest(5 * time.Second)
+	defer cancel()
+	propagateDeadline(req)
+	grpcTimeout, err := parseGRPCTimeout(req.Header.Get("Grpc-Timeout"))
+	if err != nil || grpcTimeout <= 4*time.Second || grpcTimeout > 5*time.Second {
+		t.Errorf("Expected a grpc-timeout of the time left, got %q", req.Header.Get("Grpc-Timeout"))
+	}
+	if ms, err := strconv.Atoi(req.Header.Get("X-Request-Timeout")); err != nil || ms <= 4000 || ms > 5000 {
+		t.Errorf("Expected the time left in milliseconds, got %q", req.Header.Get("X-Request-Timeout"))
+	}
+
+	// A shorter grpc-timeout from the client is kept
+	req, cancel = newRequest(5 * time.Second)
+	defer cancel()
+	req.Header.Set("Grpc-Timeout", "100m")
+	propagateDeadline(req)
+	if value := req.Header.Get("Grpc-Timeout"); value != "100m" {
+		t.Errorf("Expected the client's shorter grpc-timeout kept, got %q", value)
+	}
+
+	// A longer one is lowered to the time left
+	req, cancel = newRequest(time.Second)
+	defer cancel()
+	req.Header.Set("Grpc-Timeout", "1H")
+	propagateDeadline(req)
+	if grpcTimeout, err := parseGRPCTimeout(req.Header.Get("Grpc-Timeout")); err != nil || grpcTimeout > time.Second {
+		t.Errorf("Expected the client's longer grpc-timeout lowered, got %q", req.Header.Get("Grpc-Timeout"))
+	}
+
+	// Requests without a deadline are left alone
+	req = httptest.NewRequest("GET", "/", nil)
+	propagateDeadline(req)
+	if req.Header.Get("X-Request-Timeout") != "" || req.Header.Get("Grpc-Timeout") != "" {
+		t.Error("Expected no deadline headers without a request deadline")
+	}
+
+	if value := formatGRPCTimeout(200 * time.Hour); value != "720000S" {
+		t.Errorf("Expected a long timeout in seconds, got %q", value)
+	}
+}
@@ -0,0 +1,241 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/songzhibin97/stargate/internal/types"
+)
+
+// Upstream diagnostics deadlines
+const (
+	defaultDiagnosticsTimeout = 10 * time.Second
+	maxDiagnosticsTimeout     = 60 * time.Second
+)
+
+// UpstreamDiagnostic is the result of checking a single upstream
+type UpstreamDiagnostic struct {
+	UpstreamID string  `json:"upstream_id"`
+	Target     string  `json:"target,omitempty"`      // Target that was checked
+	CheckType  string  `json:"check_type"`            // "http" or "tcp"
+	Reachable  bool    `json:"reachable"`             // Target accepted the connection and passed the check
+	LatencyMs  float64 `json:"latency_ms"`            // Duration of the check
+	StatusCode int     `json:"status_code,omitempty"` // Status of the HTTP health check request
+	Error      string  `json:"error,omitempty"`
+}
+
+// UpstreamDiagnosticsResponse is the response of the upstream diagnostics endpoint
+type UpstreamDiagnosticsResponse struct {
+	Upstreams   []UpstreamDiagnostic `json:"upstreams"`
+	Total       int                  `json:"total"`
+	Reachable   int                  `json:"reachable"`
+	Unreachable int                  `json:"unreachable"`
+	DurationMs  float64              `json:"duration_ms"`
+}
+
+// diagnosticsPath returns the path of the upstream diagnostics endpoint, or
+// "" if the REST Admin API is disabled
+func (p *Pipeline) diagnosticsPath() string {
+	return p.nodeAdminPath("/diagnostics/upstreams")
+}
+
+// handleUpstreamDiagnostics dials one target of every upstream and issues its
+// health check request, independently of the background health checkers. The
+// checks run concurrently and are bounded by the timeout query parameter, a
+// duration such as "5s". The response is 503 if any upstream is unreachable.
+func (p *Pipeline) handleUpstreamDiagnostics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(map[string]string{"error": "method not allowed"})
+		return
+	}
+
+	timeout, err := parseDiagnosticsTimeout(r.URL.Query().Get("timeout"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	defer cancel()
+
+	start := time.Now()
+	upstreams := p.listUpstreams()
+	response := UpstreamDiagnosticsResponse{
+		Upstreams: make([]UpstreamDiagnostic, len(upstreams)),
+		Total:     len(upstreams),
+	}
+
+	var wg sync.WaitGroup
+	for i, upstream := range upstreams {
+		wg.Add(1)
+		go func(i int, upstream *types.Upstream) {
+			defer wg.Done()
+			response.Upstreams[i] = p.diagnoseUpstream(ctx, upstream)
+		}(i, upstream)
+	}
+	wg.Wait()
+
+	for _, result := range response.Upstreams {
+		if result.Reachable {
+			response.Reachable++
+		} else {
+			response.Unreachable++
+		}
+	}
+	response.DurationMs = float64(time.Since(start).Microseconds()) / 1000
+
+	log.Printf("Upstream diagnostics from %s: %d/%d upstreams reachable", r.RemoteAddr, response.Reachable, response.Total)
+
+	if response.Unreachable > 0 {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+// listUpstreams returns the upstreams of the load balancer sorted by ID
+func (p *Pipeline) listUpstreams() []*types.Upstream {
+	lister, ok := p.loadBalancer.(interface{ ListUpstreams() []*types.Upstream })
+	if !ok {
+		return nil
+	}
+
+	upstreams := lister.ListUpstreams()
+	sort.Slice(upstreams, func(i, j int) bool { return upstreams[i].ID < upstreams[j].ID })
+	return upstreams
+}
+
+// diagnoseUpstream checks the first healthy target of the upstream, or its
+// first target if none is healthy, with the upstream's health check
+func (p *Pipeline) diagnoseUpstream(ctx context.Context, upstream *types.Upstream) UpstreamDiagnostic {
+	check := diagnosticsHealthCheck(upstream)
+	result := UpstreamDiagnostic{UpstreamID: upstream.ID, CheckType: check.Type}
+
+	target := diagnosticsTarget(upstream)
+	if target == nil {
+		result.Error = "upstream has no targets"
+		return result
+	}
+	result.Target = net.JoinHostPort(target.Host, strconv.Itoa(target.Port))
+
+	if check.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(check.Timeout)*time.Second)
+		defer cancel()
+	}
+
+	var err error
+	start := time.Now()
+	if check.Type == "tcp" {
+		err = dialTarget(ctx, result.Target)
+	} else {
+		result.StatusCode, err = p.checkTargetHTTP(ctx, upstream.ID, target, check.Path)
+	}
+	result.LatencyMs = float64(time.Since(start).Microseconds()) / 1000
+
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.Reachable = true
+	return result
+}
+
+// dialTarget opens and closes a TCP connection to the address
+func dialTarget(ctx context.Context, address string) error {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// checkTargetHTTP issues the health check request to the target over the
+// upstream's transport. 2xx and 3xx responses pass the check.
+func (p *Pipeline) checkTargetHTTP(ctx context.Context, upstreamID string, target *types.Target, path string) (int, error) {
+	scheme := "http"
+	transport := http.DefaultTransport.(*http.Transport)
+	if p.reverseProxy != nil {
+		transport = p.reverseProxy.upstreamTransport(upstreamID)
+		if target.Port == 443 || p.reverseProxy.upstreamTLSEnabled(upstreamID) {
+			scheme = "https"
+		}
+	}
+
+	url := fmt.Sprintf("%s://%s%s", scheme, net.JoinHostPort(target.Host, strconv.Itoa(target.Port)), path)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 400 {
+		return resp.StatusCode, fmt.Errorf("health check returned status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// diagnosticsHealthCheck returns the health check of the upstream, or the
+// defaults of the active health checker
+func diagnosticsHealthCheck(upstream *types.Upstream) types.HealthCheck {
+	check := types.HealthCheck{Type: "http", Path: "/health", Timeout: 5}
+	if upstream.HealthCheck != nil {
+		check = *upstream.HealthCheck
+		if check.Type == "" {
+			check.Type = "http"
+		}
+		if check.Path == "" {
+			check.Path = "/health"
+		}
+	}
+	return check
+}
+
+// diagnosticsTarget returns the first healthy target of the upstream, or its
+// first target if none is healthy
+func diagnosticsTarget(upstream *types.Upstream) *types.Target {
+	for _, target := range upstream.Targets {
+		if target.Healthy {
+			return target
+		}
+	}
+	if len(upstream.Targets) > 0 {
+		return upstream.Targets[0]
+	}
+	return nil
+}
+
+// parseDiagnosticsTimeout parses the timeout query parameter
+func parseDiagnosticsTimeout(value string) (time.Duration, error) {
+	if value == "" {
+		return defaultDiagnosticsTimeout, nil
+	}
+	timeout, err := time.ParseDuration(value)
+	if err != nil || timeout <= 0 {
+		return 0, fmt.Errorf("invalid timeout %q", value)
+	}
+	if timeout > maxDiagnosticsTimeout {
+		timeout = maxDiagnosticsTimeout
+	}
+	return timeout, nil
+}
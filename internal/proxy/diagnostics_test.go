@@ -0,0 +1,126 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/songzhibin97/stargate/internal/config"
+	"github.com/songzhibin97/stargate/internal/types"
+)
+
+func TestPipeline_UpstreamDiagnostics(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.AdminAPI.REST = config.RESTConfig{Enabled: true, Prefix: "/api/v1"}
+	cfg.AdminAPI.Auth = config.AuthConfig{
+		Enabled: true,
+		APIKey:  config.APIKeyConfig{Header: "X-Admin-Key", Keys: []string{"secret"}},
+	}
+
+	pipeline, err := NewPipeline(cfg, nil)
+	if err != nil {
+		t.Fatalf("Failed to create pipeline: %v", err)
+	}
+	defer pipeline.Stop()
+
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/health":
+			w.WriteHeader(http.StatusOK)
+		case "/slow":
+			time.Sleep(2 * time.Second)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer backend.Close()
+	// Each upstream gets its own targets, the active health checker updates them
+	healthy := func() *types.Target { return targetOf(t, backend.Listener.Addr()) }
+
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	down := func() *types.Target {
+		target := targetOf(t, closed.Addr())
+		target.Healthy = false
+		return target
+	}
+	closed.Close()
+
+	upstreams := []*types.Upstream{
+		{ID: "api", Name: "api", Targets: []*types.Target{down(), healthy()}},
+		{ID: "broken", Name: "broken", Targets: []*types.Target{healthy()}, HealthCheck: &types.HealthCheck{Type: "http", Path: "/status", Interval: 30}},
+		{ID: "down", Name: "down", Targets: []*types.Target{down()}, HealthCheck: &types.HealthCheck{Type: "tcp", Interval: 30}},
+		{ID: "raw", Name: "raw", Targets: []*types.Target{healthy()}, HealthCheck: &types.HealthCheck{Type: "tcp", Interval: 30}},
+		{ID: "slow", Name: "slow", Targets: []*types.Target{healthy()}, HealthCheck: &types.HealthCheck{Type: "http", Path: "/slow", Interval: 30}},
+	}
+	for _, upstream := range upstreams {
+		if err := pipeline.AddUpstream(upstream); err != nil {
+			t.Fatalf("Failed to add upstream: %v", err)
+		}
+	}
+
+	diagnose := func(method, target, key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, nil)
+		if key != "" {
+			req.Header.Set("X-Admin-Key", key)
+		}
+		rr := httptest.NewRecorder()
+		pipeline.ServeHTTP(rr, req)
+		return rr
+	}
+
+	if rr := diagnose("POST", "/_stargate/admin/diagnostics/upstreams", ""); rr.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 without credentials, got %d", rr.Code)
+	}
+	if rr := diagnose("GET", "/_stargate/admin/diagnostics/upstreams", "secret"); rr.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status 405 for GET, got %d", rr.Code)
+	}
+	if rr := diagnose("POST", "/_stargate/admin/diagnostics/upstreams?timeout=soon", "secret"); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an invalid timeout, got %d", rr.Code)
+	}
+
+	start := time.Now()
+	rr := diagnose("POST", "/_stargate/admin/diagnostics/upstreams?timeout=500ms", "secret")
+	if elapsed := time.Since(start); elapsed > 1500*time.Millisecond {
+		t.Errorf("Expected diagnostics to be bounded by the timeout, took %v", elapsed)
+	}
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503 with unreachable upstreams, got %d", rr.Code)
+	}
+
+	var response UpstreamDiagnosticsResponse
+	if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if response.Total != 5 || response.Reachable != 2 || response.Unreachable != 3 {
+		t.Fatalf("Unexpected summary: %+v", response)
+	}
+
+	expected := map[string]struct {
+		reachable  bool
+		statusCode int
+	}{
+		"api":    {true, http.StatusOK},
+		"broken": {false, http.StatusInternalServerError},
+		"down":   {false, 0},
+		"raw":    {true, 0},
+		"slow":   {false, 0},
+	}
+	for _, result := range response.Upstreams {
+		want := expected[result.UpstreamID]
+		if result.Reachable != want.reachable || result.StatusCode != want.statusCode {
+			t.Errorf("Unexpected result for %s: %+v", result.UpstreamID, result)
+		}
+		if !result.Reachable && result.Error == "" {
+			t.Errorf("Expected an error for unreachable upstream %s", result.UpstreamID)
+		}
+	}
+	if response.Upstreams[0].UpstreamID != "api" || response.Upstreams[0].Target != backend.Listener.Addr().String() {
+		t.Errorf("Expected the healthy target of api to be checked, got %+v", response.Upstreams[0])
+	}
+}
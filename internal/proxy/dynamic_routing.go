@@ -0,0 +1,130 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/songzhibin97/stargate/internal/config"
+	"github.com/songzhibin97/stargate/internal/types"
+)
+
+// UpstreamResolver selects a target of the named upstream for a re-routed
+// request, failing if the upstream is unknown
+type UpstreamResolver func(r *http.Request, upstreamID string) (*types.Target, error)
+
+// SetUpstreamResolver sets the resolver used for dynamic routing. Responses
+// are never re-routed without a resolver.
+func (rp *ReverseProxy) SetUpstreamResolver(resolve UpstreamResolver) {
+	rp.upstreamMu.Lock()
+	defer rp.upstreamMu.Unlock()
+	rp.resolveUpstream = resolve
+}
+
+// dynamicRouting returns the dynamic routing configuration with defaults
+// applied and the upstream resolver, or false if re-routing is off
+func (rp *ReverseProxy) dynamicRouting() (config.DynamicRoutingConfig, UpstreamResolver, bool) {
+	rp.upstreamMu.RLock()
+	cfg := rp.config.Proxy.DynamicRouting
+	resolve := rp.resolveUpstream
+	rp.upstreamMu.RUnlock()
+
+	if !cfg.Enabled || resolve == nil {
+		return cfg, nil, false
+	}
+	if cfg.Header == "" {
+		cfg.Header = "X-Route-To"
+	}
+	if cfg.MaxHops <= 0 {
+		cfg.MaxHops = 1
+	}
+	if cfg.MaxHops > config.MaxDynamicRoutingHops {
+		cfg.MaxHops = config.MaxDynamicRoutingHops
+	}
+	if cfg.MaxBodySize <= 0 {
+		cfg.MaxBodySize = 1024 * 1024
+	}
+	return cfg, resolve, true
+}
+
+// bufferForReroute buffers the request body so the request can be sent
+// again. Bodies larger than maxBodySize are streamed through unbuffered and
+// the request is not re-routed.
+func bufferForReroute(r *http.Request, maxBodySize int64) {
+	if r.Body == nil || r.Body == http.NoBody || r.GetBody != nil {
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxBodySize+1))
+	if err != nil || int64(len(body)) > maxBodySize {
+		r.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), r.Body))
+		return
+	}
+
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	r.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+}
+
+// reroute follows the routing header of an upstream response: if it names a
+// known upstream other than the current one, the response is discarded and
+// the request is sent to that upstream instead, up to MaxHops times. The
+// header is never passed on to the client.
+func (rp *ReverseProxy) reroute(req *http.Request, resp *http.Response) (*http.Response, error) {
+	cfg, resolve, ok := rp.dynamicRouting()
+	if !ok {
+		return resp, nil
+	}
+
+	for hops := 0; ; hops++ {
+		to := strings.TrimSpace(resp.Header.Get(cfg.Header))
+		if to == "" {
+			return resp, nil
+		}
+		resp.Header.Del(cfg.Header)
+
+		from := upstreamID(req)
+		if hops >= cfg.MaxHops || to == from || resp.StatusCode == http.StatusSwitchingProtocols {
+			return resp, nil
+		}
+
+		// Requests whose body couldn't be buffered can't be sent again
+		body := req.Body
+		if body != nil && body != http.NoBody {
+			if req.GetBody == nil {
+				return resp, nil
+			}
+			var err error
+			if body, err = req.GetBody(); err != nil {
+				return resp, nil
+			}
+		}
+
+		target, err := resolve(req, to)
+		if err != nil {
+			log.Printf("Not re-routing request from upstream %s to %s: %v", from, to, err)
+			return resp, nil
+		}
+
+		// The discarded response is closed rather than drained, its body
+		// may be arbitrarily large
+		resp.Body.Close()
+
+		ctx := context.WithValue(req.Context(), "upstream_id", to)
+		ctx = context.WithValue(ctx, "target", target)
+		next := req.Clone(ctx)
+		next.Body = body
+		rp.setTargetURL(next, target)
+		propagateDeadline(next)
+
+		resp, err = rp.transportFor(next).RoundTrip(next)
+		if err != nil {
+			return nil, err
+		}
+		req = next
+	}
+}
@@ -0,0 +1,119 @@
+package proxy
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/songzhibin97/stargate/internal/config"
+	"github.com/songzhibin97/stargate/internal/loadbalancer"
+	"github.com/songzhibin97/stargate/internal/types"
+)
+
+func TestPipeline_DynamicRouting(t *testing.T) {
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		w.Header().Set("X-Route-To", r.URL.Query().Get("route_to"))
+		w.Write([]byte("primary"))
+	}))
+	defer primary.Close()
+
+	special := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		// Routing back is ignored, re-routes are limited to one hop
+		w.Header().Set("X-Route-To", "default-upstream")
+		w.Write([]byte("special:" + string(body)))
+	}))
+	defer special.Close()
+
+	cfg := &config.Config{}
+	cfg.Proxy.DynamicRouting = config.DynamicRoutingConfig{Enabled: true}
+	cfg.Metrics = config.MetricsConfig{Enabled: true, Provider: "prometheus", Path: "/metrics"}
+
+	pipeline, err := NewPipeline(cfg, nil)
+	if err != nil {
+		t.Fatalf("Failed to create pipeline: %v", err)
+	}
+	defer pipeline.Stop()
+
+	lb := loadbalancer.NewRoundRobinBalancer(cfg)
+	for id, server := range map[string]*httptest.Server{"default-upstream": primary, "special": special} {
+		host, portStr, _ := net.SplitHostPort(strings.TrimPrefix(server.URL, "http://"))
+		port, _ := strconv.Atoi(portStr)
+		upstream := &types.Upstream{
+			ID:        id,
+			Name:      id,
+			Algorithm: "round_robin",
+			Targets:   []*types.Target{{Host: host, Port: port, Weight: 100, Healthy: true}},
+		}
+		if err := lb.UpdateUpstream(upstream); err != nil {
+			t.Fatalf("Failed to add upstream: %v", err)
+		}
+	}
+	pipeline.loadBalancer = lb
+
+	// The mock router routes everything to default-upstream
+	pipeline.router = &MockRouter{}
+	handler := pipeline.createHandler()
+
+	send := func(routeTo string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/orders?route_to="+routeTo, strings.NewReader("order-1"))
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := send("special")
+	if body := rr.Body.String(); body != "special:order-1" {
+		t.Errorf("Expected the request re-proxied to special with its body, got %q", body)
+	}
+	if value := rr.Header().Get("X-Route-To"); value != "" {
+		t.Errorf("Expected the routing header to be removed, got %q", value)
+	}
+
+	// Unknown upstreams and the current upstream are not followed
+	for _, routeTo := range []string{"unknown", "default-upstream"} {
+		rr = send(routeTo)
+		if body := rr.Body.String(); body != "primary" {
+			t.Errorf("Expected the response of primary for %s, got %q", routeTo, body)
+		}
+	}
+
+	if count := pipeline.Metrics()["reroute_count"]; count != int64(1) {
+		t.Errorf("Expected 1 re-route, got %v", count)
+	}
+
+	metricsRR := httptest.NewRecorder()
+	pipeline.getMetricsProvider().Handler().ServeHTTP(metricsRR, httptest.NewRequest("GET", "/metrics", nil))
+	expected := `stargate_node_upstream_reroutes_total{from_upstream="default-upstream",to_upstream="special"} 1`
+	if !strings.Contains(metricsRR.Body.String(), expected) {
+		t.Errorf("Expected metrics output to contain %q", expected)
+	}
+}
+
+func TestBufferForReroute(t *testing.T) {
+	req := httptest.NewRequest("POST", "/", strings.NewReader("0123456789"))
+	bufferForReroute(req, 4)
+	if req.GetBody != nil {
+		t.Error("Expected a body over the limit not to be replayable")
+	}
+	body, _ := io.ReadAll(req.Body)
+	if string(body) != "0123456789" {
+		t.Errorf("Expected the full body to be streamed, got %q", body)
+	}
+
+	req = httptest.NewRequest("POST", "/", strings.NewReader("0123"))
+	bufferForReroute(req, 4)
+	if req.GetBody == nil {
+		t.Fatal("Expected a body within the limit to be replayable")
+	}
+	replay, _ := req.GetBody()
+	body, _ = io.ReadAll(replay)
+	if string(body) != "0123" {
+		t.Errorf("Expected the replayed body %q, got %q", "0123", body)
+	}
+}
@@ -0,0 +1,52 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/songzhibin97/stargate/internal/config"
+)
+
+// effectiveConfigPath returns the path of the effective configuration
+// endpoint, or "" if the REST Admin API is disabled
+func (p *Pipeline) effectiveConfigPath() string {
+	return p.nodeAdminPath("/config/effective")
+}
+
+// EffectiveConfig summarizes the features enabled by the configuration and
+// the middlewares in the request chain, with secrets redacted
+func (p *Pipeline) EffectiveConfig() config.EffectiveConfig {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	effective := config.Effective(p.config)
+	effective.Middlewares = make([]string, 0, len(p.middlewares))
+	for _, middleware := range p.middlewares {
+		effective.Middlewares = append(effective.Middlewares, middleware.name)
+	}
+	return effective
+}
+
+// logEffectiveConfig logs the effective configuration as a single JSON line
+func (p *Pipeline) logEffectiveConfig() {
+	encoded, err := json.Marshal(p.EffectiveConfig())
+	if err != nil {
+		p.logger.Printf("Failed to encode effective configuration: %v", err)
+		return
+	}
+	p.logger.Printf("Effective configuration: %s", encoded)
+}
+
+// handleEffectiveConfig returns the effective configuration
+func (p *Pipeline) handleEffectiveConfig(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(map[string]string{"error": "method not allowed"})
+		return
+	}
+
+	json.NewEncoder(w).Encode(p.EffectiveConfig())
+}
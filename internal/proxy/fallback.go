@@ -0,0 +1,65 @@
+package proxy
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/songzhibin97/stargate/internal/types"
+)
+
+// Results of fallback upstream attempts
+const (
+	fallbackSelected    = "selected"    // The fallback upstream serves the request
+	fallbackUnavailable = "unavailable" // The fallback upstream has no available target
+	fallbackNotFound    = "not_found"   // The fallback upstream doesn't exist
+	fallbackSkipped     = "skipped"     // The fallback upstream was already tried
+)
+
+// selectFallbackTarget tries the route's fallback upstreams in order after
+// the primary upstream had no available target, returning the first one with
+// a target. Each upstream is tried at most once, so fallbacks repeating the
+// primary or each other can't loop.
+func (p *Pipeline) selectFallbackTarget(w http.ResponseWriter, r *http.Request, route *Route, primaryID string) (*types.Upstream, *types.Target, bool) {
+	tried := map[string]bool{primaryID: true}
+
+	for _, upstreamID := range route.FallbackUpstreams {
+		if tried[upstreamID] {
+			p.recordFallback(route.ID, primaryID, upstreamID, fallbackSkipped)
+			continue
+		}
+		tried[upstreamID] = true
+
+		upstream := p.getUpstream(upstreamID)
+		if upstream == nil {
+			p.recordFallback(route.ID, primaryID, upstreamID, fallbackNotFound)
+			continue
+		}
+
+		target, err := p.selectAffinityTarget(w, r, upstream)
+		if err != nil {
+			p.recordFallback(route.ID, primaryID, upstreamID, fallbackUnavailable)
+			continue
+		}
+
+		p.recordFallback(route.ID, primaryID, upstreamID, fallbackSelected)
+		return upstream, target, true
+	}
+
+	return nil, nil, false
+}
+
+// recordFallback records an attempt to fail over from the route's primary
+// upstream to a fallback upstream
+func (p *Pipeline) recordFallback(routeID, primaryID, upstreamID, result string) {
+	log.Printf("Route %s failing over from upstream %s to %s: %s", routeID, primaryID, upstreamID, result)
+
+	if result == fallbackSelected {
+		p.mu.Lock()
+		p.fallbackCount++
+		p.mu.Unlock()
+	}
+
+	if p.fallbackCounter != nil {
+		p.fallbackCounter.WithLabelValues(routeID, primaryID, upstreamID, result).Inc()
+	}
+}
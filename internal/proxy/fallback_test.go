@@ -0,0 +1,110 @@
+package proxy
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/songzhibin97/stargate/internal/config"
+	"github.com/songzhibin97/stargate/internal/loadbalancer"
+	"github.com/songzhibin97/stargate/internal/types"
+)
+
+// staticRouter routes every request to one route
+type staticRouter struct {
+	MockRouter
+	route *Route
+}
+
+func (sr *staticRouter) Match(r *http.Request) (*Route, error) {
+	return sr.route, nil
+}
+
+func TestPipeline_FallbackUpstreams(t *testing.T) {
+	secondary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("secondary"))
+	}))
+	defer secondary.Close()
+
+	cfg := &config.Config{}
+	cfg.Metrics = config.MetricsConfig{Enabled: true, Provider: "prometheus", Path: "/metrics"}
+
+	pipeline, err := NewPipeline(cfg, nil)
+	if err != nil {
+		t.Fatalf("Failed to create pipeline: %v", err)
+	}
+	defer pipeline.Stop()
+
+	host, portStr, _ := net.SplitHostPort(strings.TrimPrefix(secondary.URL, "http://"))
+	port, _ := strconv.Atoi(portStr)
+
+	lb := loadbalancer.NewRoundRobinBalancer(cfg)
+	upstreams := map[string]bool{"primary": false, "tertiary": false, "secondary": true}
+	for id, healthy := range upstreams {
+		upstream := &types.Upstream{
+			ID:        id,
+			Name:      id,
+			Algorithm: "round_robin",
+			Targets:   []*types.Target{{Host: host, Port: port, Weight: 100, Healthy: healthy}},
+		}
+		if err := lb.UpdateUpstream(upstream); err != nil {
+			t.Fatalf("Failed to add upstream: %v", err)
+		}
+	}
+	pipeline.loadBalancer = lb
+
+	// Fallbacks repeating the primary or unknown upstreams are skipped
+	route := &Route{
+		ID:                "orders",
+		UpstreamID:        "primary",
+		FallbackUpstreams: []string{"primary", "missing", "tertiary", "secondary"},
+	}
+	pipeline.router = &staticRouter{route: route}
+	handler := pipeline.createHandler()
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/orders", nil))
+	if rr.Code != http.StatusOK || rr.Body.String() != "secondary" {
+		t.Fatalf("Expected the request to fail over to secondary, got %d %q", rr.Code, rr.Body.String())
+	}
+
+	if len(rr.Result().Cookies()) != 0 {
+		t.Errorf("Expected no affinity cookie without session affinity")
+	}
+
+	if count := pipeline.Metrics()["fallback_count"]; count != int64(1) {
+		t.Errorf("Expected 1 fallback, got %v", count)
+	}
+
+	metricsRR := httptest.NewRecorder()
+	pipeline.getMetricsProvider().Handler().ServeHTTP(metricsRR, httptest.NewRequest("GET", "/metrics", nil))
+	for _, expected := range []string{
+		`fallback_upstream="primary",primary_upstream="primary",result="skipped",route="orders"} 1`,
+		`fallback_upstream="missing",primary_upstream="primary",result="not_found",route="orders"} 1`,
+		`fallback_upstream="tertiary",primary_upstream="primary",result="unavailable",route="orders"} 1`,
+		`fallback_upstream="secondary",primary_upstream="primary",result="selected",route="orders"} 1`,
+	} {
+		if !strings.Contains(metricsRR.Body.String(), expected) {
+			t.Errorf("Expected metrics output to contain %q", expected)
+		}
+	}
+
+	// Requests falling back to an upstream with affinity are pinned
+	pipeline.sessionAffinities = newSessionAffinities(map[string]config.SessionAffinityConfig{"secondary": {}}, []byte("secret"))
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/orders", nil))
+	if cookies := rr.Result().Cookies(); len(cookies) != 1 || cookies[0].Name != "stargate_affinity_secondary" {
+		t.Errorf("Expected the fallback request pinned, got cookies %v", cookies)
+	}
+
+	// Without an available fallback the request fails
+	route.FallbackUpstreams = []string{"tertiary"}
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/orders", nil))
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 when every fallback is down, got %d", rr.Code)
+	}
+}
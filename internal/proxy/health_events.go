@@ -0,0 +1,338 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/songzhibin97/stargate/internal/governance/circuitbreaker"
+	"github.com/songzhibin97/stargate/internal/health"
+)
+
+// Health event stream event types
+const (
+	healthEventSnapshot       = "snapshot"
+	healthEventTargetHealth   = "target_health"
+	healthEventCircuitBreaker = "circuit_breaker"
+)
+
+const (
+	// maxPendingHealthEvents bounds the events queued for a subscriber. A
+	// subscriber falling further behind is sent a fresh snapshot instead.
+	maxPendingHealthEvents = 1000
+
+	// healthEventKeepAlive is the interval of keep-alive comments on idle streams
+	healthEventKeepAlive = 15 * time.Second
+)
+
+// HealthEvent is a target health transition or circuit breaker state
+// change streamed to subscribers
+type HealthEvent struct {
+	Type       string    `json:"type"`
+	Timestamp  time.Time `json:"timestamp"`
+	UpstreamID string    `json:"upstream_id,omitempty"`
+	Target     string    `json:"target,omitempty"`
+	Breaker    string    `json:"breaker,omitempty"`
+	OldStatus  string    `json:"old_status"`
+	NewStatus  string    `json:"new_status"`
+	Source     string    `json:"source,omitempty"` // "active" or "passive" for target health
+
+	// Why a passive health check ejected or recovered the target
+	Reason      string `json:"reason,omitempty"`
+	Count       int    `json:"count,omitempty"`
+	IsolationMs int64  `json:"isolation_ms,omitempty"`
+}
+
+// key identifies the target or breaker the event is about. Pending events of
+// the same key are coalesced, keeping the latest state.
+func (e *HealthEvent) key() string {
+	if e.Type == healthEventCircuitBreaker {
+		return "breaker/" + e.Breaker
+	}
+	return "target/" + e.UpstreamID + "/" + e.Target
+}
+
+// HealthSnapshot is the first event of a stream: the health of every target
+// and the state of every circuit breaker
+type HealthSnapshot struct {
+	Type            string                 `json:"type"`
+	Timestamp       time.Time              `json:"timestamp"`
+	Upstreams       []RoutingTableUpstream `json:"upstreams"`
+	CircuitBreakers []HealthBreakerState   `json:"circuit_breakers"`
+}
+
+// HealthBreakerState is the state of a circuit breaker
+type HealthBreakerState struct {
+	Name  string `json:"name"`
+	State string `json:"state"`
+}
+
+// healthSubscriber is a stream's queue of events. Publishing never blocks:
+// events about the same target or breaker are coalesced, and a subscriber
+// too far behind drops its queue and is sent a new snapshot.
+type healthSubscriber struct {
+	mu      sync.Mutex
+	pending map[string]*HealthEvent
+	order   []string // Keys of pending events in arrival order
+	resync  bool     // Events were dropped, a snapshot must be sent
+	notify  chan struct{}
+	done    chan struct{}
+}
+
+// push queues an event for the subscriber
+func (s *healthSubscriber) push(event *HealthEvent) {
+	s.mu.Lock()
+	// The snapshot sent on resync covers the event
+	if !s.resync {
+		key := event.key()
+		if _, queued := s.pending[key]; queued {
+			s.pending[key] = event
+		} else if len(s.order) < maxPendingHealthEvents {
+			s.pending[key] = event
+			s.order = append(s.order, key)
+		} else {
+			s.pending = make(map[string]*HealthEvent)
+			s.order = nil
+			s.resync = true
+		}
+	}
+	s.mu.Unlock()
+
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+}
+
+// take returns the queued events and whether a snapshot must be sent first
+func (s *healthSubscriber) take() ([]*HealthEvent, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	events := make([]*HealthEvent, 0, len(s.order))
+	for _, key := range s.order {
+		events = append(events, s.pending[key])
+	}
+	resync := s.resync
+	s.pending = make(map[string]*HealthEvent)
+	s.order = nil
+	s.resync = false
+	return events, resync
+}
+
+// healthEventBroker fans health events out to the subscribed streams
+type healthEventBroker struct {
+	mu          sync.Mutex
+	subscribers map[*healthSubscriber]struct{}
+	closed      bool
+}
+
+// newHealthEventBroker creates a new health event broker
+func newHealthEventBroker() *healthEventBroker {
+	return &healthEventBroker{subscribers: make(map[*healthSubscriber]struct{})}
+}
+
+// subscribe registers a new stream. The subscriber's done channel is closed
+// when the broker is closed.
+func (b *healthEventBroker) subscribe() *healthSubscriber {
+	s := &healthSubscriber{
+		pending: make(map[string]*HealthEvent),
+		notify:  make(chan struct{}, 1),
+		done:    make(chan struct{}),
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		close(s.done)
+		return s
+	}
+	b.subscribers[s] = struct{}{}
+	return s
+}
+
+// unsubscribe removes a stream
+func (b *healthEventBroker) unsubscribe(s *healthSubscriber) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.subscribers, s)
+}
+
+// publish queues an event for every stream without blocking
+func (b *healthEventBroker) publish(event *HealthEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for s := range b.subscribers {
+		s.push(event)
+	}
+}
+
+// subscriberCount returns the number of open streams
+func (b *healthEventBroker) subscriberCount() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.subscribers)
+}
+
+// close ends every stream, so they don't hold up draining
+func (b *healthEventBroker) close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return
+	}
+	b.closed = true
+	for s := range b.subscribers {
+		close(s.done)
+	}
+	b.subscribers = nil
+}
+
+// publishTargetHealth publishes a target health transition
+func (p *Pipeline) publishTargetHealth(event *health.HealthStatusEvent) {
+	if p.healthEvents == nil {
+		return
+	}
+	p.healthEvents.publish(&HealthEvent{
+		Type:        healthEventTargetHealth,
+		Timestamp:   event.Timestamp,
+		UpstreamID:  event.UpstreamID,
+		Target:      event.Target,
+		OldStatus:   event.OldStatus,
+		NewStatus:   event.NewStatus,
+		Source:      event.Source,
+		Reason:      event.Reason,
+		Count:       event.Count,
+		IsolationMs: event.IsolationMs,
+	})
+}
+
+// publishBreakerState publishes a circuit breaker state change
+func (p *Pipeline) publishBreakerState(name string, from, to circuitbreaker.State) {
+	if p.healthEvents == nil {
+		return
+	}
+	p.healthEvents.publish(&HealthEvent{
+		Type:      healthEventCircuitBreaker,
+		Timestamp: time.Now().UTC(),
+		Breaker:   name,
+		OldStatus: from.String(),
+		NewStatus: to.String(),
+	})
+}
+
+// healthSnapshot returns the health of every target and the state of every
+// circuit breaker, sorted by ID
+func (p *Pipeline) healthSnapshot() *HealthSnapshot {
+	snapshot := &HealthSnapshot{
+		Type:            healthEventSnapshot,
+		Timestamp:       time.Now().UTC(),
+		Upstreams:       []RoutingTableUpstream{},
+		CircuitBreakers: []HealthBreakerState{},
+	}
+	for _, upstream := range p.listUpstreams() {
+		snapshot.Upstreams = append(snapshot.Upstreams, p.routingTableUpstream(upstream))
+	}
+
+	if p.circuitBreakerMiddleware != nil {
+		for name, cb := range p.circuitBreakerMiddleware.GetAllCircuitBreakers() {
+			snapshot.CircuitBreakers = append(snapshot.CircuitBreakers, HealthBreakerState{Name: name, State: cb.GetState().String()})
+		}
+		sort.Slice(snapshot.CircuitBreakers, func(i, j int) bool {
+			return snapshot.CircuitBreakers[i].Name < snapshot.CircuitBreakers[j].Name
+		})
+	}
+	return snapshot
+}
+
+// healthEventsPath returns the path of the health event stream, or "" if the
+// REST Admin API is disabled
+func (p *Pipeline) healthEventsPath() string {
+	return p.nodeAdminPath("/health/events")
+}
+
+// handleHealthEvents streams target health transitions and circuit breaker
+// state changes as Server-Sent Events. The stream starts with a snapshot,
+// followed by an event per change. A client that can't keep up has its
+// pending changes coalesced and, once too far behind, is sent a new
+// snapshot, so slow clients never block health checking.
+func (p *Pipeline) handleHealthEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Allow", http.MethodGet)
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(map[string]string{"error": "method not allowed"})
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "streaming not supported"})
+		return
+	}
+
+	// The stream outlives the server's write timeout
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	// Subscribe before taking the snapshot so no change is missed
+	subscriber := p.healthEvents.subscribe()
+	defer p.healthEvents.unsubscribe(subscriber)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	log.Printf("Health event stream opened by %s", r.RemoteAddr)
+	defer log.Printf("Health event stream closed by %s", r.RemoteAddr)
+
+	if err := writeServerSentEvent(w, healthEventSnapshot, p.healthSnapshot()); err != nil {
+		return
+	}
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(healthEventKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-subscriber.done:
+			return
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+		case <-subscriber.notify:
+			events, resync := subscriber.take()
+			if resync {
+				if err := writeServerSentEvent(w, healthEventSnapshot, p.healthSnapshot()); err != nil {
+					return
+				}
+			}
+			for _, event := range events {
+				if err := writeServerSentEvent(w, event.Type, event); err != nil {
+					return
+				}
+			}
+		}
+		flusher.Flush()
+	}
+}
+
+// writeServerSentEvent writes an event with a JSON payload
+func writeServerSentEvent(w http.ResponseWriter, eventType string, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", eventType, data)
+	return err
+}
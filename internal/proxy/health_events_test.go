@@ -0,0 +1,192 @@
+package proxy
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/songzhibin97/stargate/internal/config"
+	"github.com/songzhibin97/stargate/internal/health"
+	"github.com/songzhibin97/stargate/internal/loadbalancer"
+	"github.com/songzhibin97/stargate/internal/types"
+)
+
+// readServerSentEvent reads the next event of a stream, skipping comments
+func readServerSentEvent(t *testing.T, reader *bufio.Reader) (string, []byte) {
+	t.Helper()
+
+	var eventType, data string
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("Failed to read event: %v", err)
+		}
+		line = strings.TrimRight(line, "\n")
+		switch {
+		case line == "" && eventType != "":
+			return eventType, []byte(data)
+		case strings.HasPrefix(line, "event: "):
+			eventType = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+}
+
+func TestPipeline_HealthEvents(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.AdminAPI.REST = config.RESTConfig{Enabled: true, Prefix: "/api/v1"}
+	cfg.AdminAPI.Auth = config.AuthConfig{
+		Enabled: true,
+		APIKey:  config.APIKeyConfig{Header: "X-Admin-Key", Keys: []string{"admin-key"}},
+	}
+	cfg.CircuitBreaker = config.CircuitBreakerConfig{Enabled: true, FailureThreshold: 1}
+
+	pipeline, err := NewPipeline(cfg, nil)
+	if err != nil {
+		t.Fatalf("Failed to create pipeline: %v", err)
+	}
+	defer pipeline.Stop()
+
+	lb := loadbalancer.NewRoundRobinBalancer(cfg)
+	if err := lb.UpdateUpstream(&types.Upstream{
+		ID:        "orders",
+		Name:      "orders",
+		Algorithm: "round_robin",
+		Targets:   []*types.Target{{Host: "127.0.0.1", Port: 8081, Weight: 100, Healthy: true}},
+	}); err != nil {
+		t.Fatalf("Failed to add upstream: %v", err)
+	}
+	pipeline.loadBalancer = lb
+
+	server := httptest.NewServer(pipeline)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/_stargate/admin/health/events")
+	if err != nil {
+		t.Fatalf("Failed to request stream: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("Expected status 401 without credentials, got %d", resp.StatusCode)
+	}
+
+	req, _ := http.NewRequest("GET", server.URL+"/_stargate/admin/health/events", nil)
+	req.Header.Set("X-Admin-Key", "admin-key")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to open stream: %v", err)
+	}
+	defer resp.Body.Close()
+	if contentType := resp.Header.Get("Content-Type"); contentType != "text/event-stream" {
+		t.Fatalf("Expected an event stream, got %d %q", resp.StatusCode, contentType)
+	}
+	reader := bufio.NewReader(resp.Body)
+
+	// The stream starts with a snapshot
+	eventType, data := readServerSentEvent(t, reader)
+	var snapshot HealthSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil || eventType != "snapshot" {
+		t.Fatalf("Expected a snapshot, got %s %s", eventType, data)
+	}
+	if len(snapshot.Upstreams) != 1 || snapshot.Upstreams[0].Targets[0].Address != "127.0.0.1:8081" || !snapshot.Upstreams[0].Targets[0].Healthy {
+		t.Errorf("Expected the healthy target in the snapshot, got %+v", snapshot.Upstreams)
+	}
+	if len(snapshot.CircuitBreakers) != 1 || snapshot.CircuitBreakers[0] != (HealthBreakerState{Name: "default", State: "CLOSED"}) {
+		t.Errorf("Expected the closed default breaker in the snapshot, got %+v", snapshot.CircuitBreakers)
+	}
+
+	// Passive and active health transitions follow as they happen
+	pipeline.onHealthStatusChange("orders", "orders:127.0.0.1:8081", health.HealthChange{
+		Reason:            health.ReasonConsecutive5xx,
+		Count:             3,
+		IsolationDuration: 30 * time.Second,
+	})
+	eventType, data = readServerSentEvent(t, reader)
+	var event HealthEvent
+	if err := json.Unmarshal(data, &event); err != nil || eventType != "target_health" {
+		t.Fatalf("Expected a target health event, got %s %s", eventType, data)
+	}
+	if event.UpstreamID != "orders" || event.Target != "127.0.0.1:8081" || event.NewStatus != "unhealthy" || event.Source != "passive" || event.Timestamp.IsZero() {
+		t.Errorf("Unexpected passive health event: %+v", event)
+	}
+	if event.Reason != "consecutive_5xx" || event.Count != 3 || event.IsolationMs != 30000 {
+		t.Errorf("Expected the ejection reason in the passive health event, got %+v", event)
+	}
+
+	pipeline.onActiveHealthChange("orders", &types.Target{Host: "127.0.0.1", Port: 8081}, true)
+	_, data = readServerSentEvent(t, reader)
+	event = HealthEvent{}
+	json.Unmarshal(data, &event)
+	if event.NewStatus != "healthy" || event.OldStatus != "unhealthy" || event.Source != "active" {
+		t.Errorf("Unexpected active health event: %+v", event)
+	}
+
+	// Circuit breaker state changes are streamed too
+	pipeline.circuitBreakerMiddleware.GetCircuitBreaker("default").RecordFailure()
+	eventType, data = readServerSentEvent(t, reader)
+	event = HealthEvent{}
+	json.Unmarshal(data, &event)
+	if eventType != "circuit_breaker" || event.Breaker != "default" || event.OldStatus != "CLOSED" || event.NewStatus != "OPEN" {
+		t.Errorf("Unexpected circuit breaker event: %s %+v", eventType, event)
+	}
+
+	if streams := pipeline.Metrics()["health_event_streams"]; streams != 1 {
+		t.Errorf("Expected 1 open stream, got %v", streams)
+	}
+
+	// Shutting down ends the stream instead of waiting for it to drain
+	done := make(chan error, 1)
+	go func() { done <- pipeline.Stop() }()
+	if _, err := reader.ReadString('\n'); err == nil {
+		t.Error("Expected the stream to end on shutdown")
+	}
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected shutdown not to wait for the stream")
+	}
+}
+
+func TestHealthSubscriber_SlowConsumer(t *testing.T) {
+	broker := newHealthEventBroker()
+	subscriber := broker.subscribe()
+
+	targetEvent := func(target, status string) *HealthEvent {
+		return &HealthEvent{Type: healthEventTargetHealth, UpstreamID: "orders", Target: target, NewStatus: status}
+	}
+
+	// Changes of the same target are coalesced, keeping the latest
+	broker.publish(targetEvent("10.0.0.1:80", "unhealthy"))
+	broker.publish(targetEvent("10.0.0.2:80", "unhealthy"))
+	broker.publish(targetEvent("10.0.0.1:80", "healthy"))
+
+	events, resync := subscriber.take()
+	if resync || len(events) != 2 {
+		t.Fatalf("Expected 2 coalesced events, got %d (resync %v)", len(events), resync)
+	}
+	if events[0].Target != "10.0.0.1:80" || events[0].NewStatus != "healthy" || events[1].Target != "10.0.0.2:80" {
+		t.Errorf("Expected the latest state of each target in arrival order, got %+v %+v", events[0], events[1])
+	}
+
+	// A subscriber too far behind is resynced with a snapshot
+	for i := 0; i <= maxPendingHealthEvents; i++ {
+		broker.publish(targetEvent(fmt.Sprintf("10.0.%d.%d:80", i/256, i%256), "unhealthy"))
+	}
+	events, resync = subscriber.take()
+	if !resync || len(events) != 0 {
+		t.Errorf("Expected a resync without queued events, got %d events (resync %v)", len(events), resync)
+	}
+
+	broker.close()
+	select {
+	case <-subscriber.done:
+	default:
+		t.Error("Expected closing the broker to end the subscriber")
+	}
+}
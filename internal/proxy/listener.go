@@ -0,0 +1,81 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/songzhibin97/stargate/internal/config"
+)
+
+// listenerKey is the request context key holding the name of the listener
+// that accepted the request
+type listenerKey struct{}
+
+// listenerProfile selects the middlewares applied and the routes served on a
+// listener. A nil profile applies every middleware and serves every route.
+type listenerProfile struct {
+	middlewares []string
+	routes      []string
+}
+
+// ListenerHandler returns the handler of requests accepted by the named
+// listener, which applies the middlewares and serves the routes selected by
+// the listener's profile
+func (p *Pipeline) ListenerHandler(name string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), listenerKey{}, name)))
+	})
+}
+
+// listenerProfile returns the profile of the listener that accepted the
+// request, or nil if the listener has none
+func (p *Pipeline) listenerProfile(r *http.Request) *listenerProfile {
+	name, _ := r.Context().Value(listenerKey{}).(string)
+	if name == "" {
+		return nil
+	}
+
+	p.mu.RLock()
+	cfg := p.config
+	p.mu.RUnlock()
+	if cfg == nil {
+		return nil
+	}
+
+	for _, listener := range cfg.Server.Listeners {
+		if listener.Name != name || listener.Profile == "" {
+			continue
+		}
+		profile, exists := cfg.Server.Profiles[listener.Profile]
+		if !exists {
+			return nil
+		}
+		return newListenerProfile(profile)
+	}
+	return nil
+}
+
+// newListenerProfile creates a listener profile from its configuration
+func newListenerProfile(cfg config.ListenerProfileConfig) *listenerProfile {
+	return &listenerProfile{middlewares: cfg.Middlewares, routes: cfg.Routes}
+}
+
+// appliesMiddleware reports whether the named middleware applies on the listener
+func (lp *listenerProfile) appliesMiddleware(name string) bool {
+	return lp == nil || len(lp.middlewares) == 0 || containsName(lp.middlewares, name)
+}
+
+// servesRoute reports whether the route is served on the listener
+func (lp *listenerProfile) servesRoute(routeID string) bool {
+	return lp == nil || len(lp.routes) == 0 || containsName(lp.routes, routeID)
+}
+
+// containsName reports whether names contains name
+func containsName(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
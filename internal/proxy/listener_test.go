@@ -0,0 +1,133 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/songzhibin97/stargate/internal/config"
+)
+
+func TestPipeline_ListenerProfiles(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Server.Listeners = []config.ListenerConfig{
+		{Name: "public", Address: ":8443"},
+		{Name: "internal", Address: ":9080", Profile: "internal"},
+		{Name: "admin", Address: ":9090", Profile: "admin"},
+	}
+	cfg.Server.Profiles = map[string]config.ListenerProfileConfig{
+		"internal": {Middlewares: []string{"metrics"}},
+		"admin":    {Routes: []string{"admin-api"}},
+	}
+
+	pipeline, err := NewPipeline(cfg, nil)
+	if err != nil {
+		t.Fatalf("Failed to create pipeline: %v", err)
+	}
+	defer pipeline.Stop()
+
+	// Replace the chain with middlewares that mark the response
+	pipeline.middlewares = nil
+	for _, name := range []string{"auth", "metrics"} {
+		name := name
+		pipeline.use(name, func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Add("X-Applied", name)
+				next.ServeHTTP(w, r)
+			})
+		})
+	}
+
+	serve := func(listener string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		pipeline.ListenerHandler(listener).ServeHTTP(rr, httptest.NewRequest("GET", "/orders", nil))
+		return rr
+	}
+
+	// Without a profile every middleware applies and every route is served;
+	// the default route has no upstream in this test
+	rr := serve("public")
+	if applied := rr.Header().Values("X-Applied"); len(applied) != 2 {
+		t.Errorf("Expected all middlewares on public listener, got %v", applied)
+	}
+	if rr.Code != http.StatusBadGateway {
+		t.Errorf("Expected route to be served on public listener, got status %d", rr.Code)
+	}
+
+	rr = serve("internal")
+	if applied := rr.Header().Values("X-Applied"); len(applied) != 1 || applied[0] != "metrics" {
+		t.Errorf("Expected only the metrics middleware on internal listener, got %v", applied)
+	}
+
+	if rr := serve("admin"); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected route outside the admin profile to be 404, got %d", rr.Code)
+	}
+
+	// Requests that didn't come through a listener use the whole pipeline
+	rr = httptest.NewRecorder()
+	pipeline.ServeHTTP(rr, httptest.NewRequest("GET", "/orders", nil))
+	if applied := rr.Header().Values("X-Applied"); len(applied) != 2 {
+		t.Errorf("Expected all middlewares without a listener, got %v", applied)
+	}
+}
+
+func TestServer_MultipleListeners(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Server.HealthPath = "/health"
+	cfg.Server.Listeners = []config.ListenerConfig{
+		{Name: "public", Address: "127.0.0.1:0"},
+		{Name: "internal", Address: "127.0.0.1:0", Profile: "internal"},
+	}
+	cfg.Server.Profiles = map[string]config.ListenerProfileConfig{
+		"internal": {Routes: []string{"internal-api"}},
+	}
+
+	server, err := NewServer(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+
+	errs := make(chan error, 1)
+	go func() { errs <- server.Start() }()
+
+	// Wait for both listeners to be bound
+	deadline := time.Now().Add(5 * time.Second)
+	for server.Addr("public") == nil || server.Addr("internal") == nil {
+		if time.Now().After(deadline) {
+			t.Fatal("Listeners were not bound")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	get := func(listener, path string) int {
+		resp, err := http.Get("http://" + server.Addr(listener).String() + path)
+		if err != nil {
+			t.Fatalf("Request to %s listener failed: %v", listener, err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	for _, listener := range []string{"public", "internal"} {
+		if code := get(listener, "/health"); code != http.StatusOK {
+			t.Errorf("Expected health on %s listener to be 200, got %d", listener, code)
+		}
+	}
+	if code := get("internal", "/orders"); code != http.StatusNotFound {
+		t.Errorf("Expected route outside the internal profile to be 404, got %d", code)
+	}
+	if code := get("public", "/orders"); code == http.StatusNotFound {
+		t.Errorf("Expected route to be served on the public listener")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		t.Errorf("Expected clean shutdown, got %v", err)
+	}
+	if err := <-errs; err != http.ErrServerClosed {
+		t.Errorf("Expected Start to return http.ErrServerClosed, got %v", err)
+	}
+}
@@ -0,0 +1,57 @@
+package proxy
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/songzhibin97/stargate/internal/config"
+)
+
+// noRouteID is the route ID of requests sent to the catch-all upstream
+const noRouteID = "no_route"
+
+// handleNoRoute answers a request matching no route as configured by
+// proxy.no_route. For the upstream action it returns a catch-all route to
+// the upstream, which the request is proxied through like any other route;
+// otherwise it writes the response and returns nil.
+func (p *Pipeline) handleNoRoute(w http.ResponseWriter, r *http.Request) *Route {
+	cfg := p.config.Proxy.NoRoute
+	mode, target, err := config.ParseNoRouteAction(cfg.Action)
+	if err != nil {
+		log.Printf("Invalid no-route action, answering with an error: %v", err)
+		mode = "error"
+	}
+
+	switch mode {
+	case "upstream":
+		return &Route{ID: noRouteID, Name: "No route", UpstreamID: target}
+
+	case "redirect":
+		status := cfg.StatusCode
+		if status == 0 {
+			status = http.StatusFound
+		}
+		http.Redirect(w, r, target, status)
+
+	case "static":
+		p.mu.Lock()
+		p.errorCount++
+		p.mu.Unlock()
+
+		status := cfg.StatusCode
+		if status == 0 {
+			status = http.StatusNotFound
+		}
+		contentType := cfg.ContentType
+		if contentType == "" {
+			contentType = "text/html; charset=utf-8"
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.WriteHeader(status)
+		w.Write([]byte(cfg.Body))
+
+	default:
+		p.handleError(w, r, http.StatusNotFound, "route not found")
+	}
+	return nil
+}
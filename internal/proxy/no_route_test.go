@@ -0,0 +1,130 @@
+package proxy
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/songzhibin97/stargate/internal/config"
+	"github.com/songzhibin97/stargate/internal/loadbalancer"
+	"github.com/songzhibin97/stargate/internal/types"
+)
+
+// missRouter matches no request
+type missRouter struct {
+	MockRouter
+}
+
+func (mr *missRouter) Match(r *http.Request) (*Route, error) {
+	return nil, errors.New("no route matched")
+}
+
+func TestPipeline_NoRoute(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("catch-all " + r.URL.Path))
+	}))
+	defer backend.Close()
+
+	host, portStr, _ := net.SplitHostPort(strings.TrimPrefix(backend.URL, "http://"))
+	port, _ := strconv.Atoi(portStr)
+
+	newHandler := func(t *testing.T, noRoute config.NoRouteConfig) http.Handler {
+		cfg := &config.Config{}
+		cfg.Proxy.NoRoute = noRoute
+
+		pipeline, err := NewPipeline(cfg, nil)
+		if err != nil {
+			t.Fatalf("Failed to create pipeline: %v", err)
+		}
+		t.Cleanup(func() { pipeline.Stop() })
+
+		lb := loadbalancer.NewRoundRobinBalancer(cfg)
+		for id, healthy := range map[string]bool{"catch-all": true, "down": false} {
+			if err := lb.UpdateUpstream(&types.Upstream{
+				ID:        id,
+				Name:      id,
+				Algorithm: "round_robin",
+				Targets:   []*types.Target{{Host: host, Port: port, Weight: 100, Healthy: healthy}},
+			}); err != nil {
+				t.Fatalf("Failed to add upstream: %v", err)
+			}
+		}
+		pipeline.loadBalancer = lb
+		pipeline.router = &missRouter{}
+		return pipeline.createHandler()
+	}
+
+	serve := func(handler http.Handler) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest("GET", "/unknown", nil))
+		return rr
+	}
+
+	t.Run("error by default", func(t *testing.T) {
+		rr := serve(newHandler(t, config.NoRouteConfig{}))
+		if rr.Code != http.StatusNotFound || rr.Body.String() != "route not found" {
+			t.Errorf("Expected 404 route not found, got %d %q", rr.Code, rr.Body.String())
+		}
+	})
+
+	t.Run("invalid action falls back to error", func(t *testing.T) {
+		rr := serve(newHandler(t, config.NoRouteConfig{Action: "teapot"}))
+		if rr.Code != http.StatusNotFound || rr.Body.String() != "route not found" {
+			t.Errorf("Expected 404 route not found, got %d %q", rr.Code, rr.Body.String())
+		}
+	})
+
+	t.Run("redirect", func(t *testing.T) {
+		rr := serve(newHandler(t, config.NoRouteConfig{Action: "redirect:https://example.com/home"}))
+		if rr.Code != http.StatusFound || rr.Header().Get("Location") != "https://example.com/home" {
+			t.Errorf("Expected 302 to https://example.com/home, got %d %q", rr.Code, rr.Header().Get("Location"))
+		}
+
+		rr = serve(newHandler(t, config.NoRouteConfig{Action: "redirect:/home", StatusCode: http.StatusPermanentRedirect}))
+		if rr.Code != http.StatusPermanentRedirect || rr.Header().Get("Location") != "/home" {
+			t.Errorf("Expected 308 to /home, got %d %q", rr.Code, rr.Header().Get("Location"))
+		}
+	})
+
+	t.Run("upstream", func(t *testing.T) {
+		rr := serve(newHandler(t, config.NoRouteConfig{Action: "upstream:catch-all"}))
+		if rr.Code != http.StatusOK || rr.Body.String() != "catch-all /unknown" {
+			t.Errorf("Expected the catch-all upstream to serve the request, got %d %q", rr.Code, rr.Body.String())
+		}
+
+		// The catch-all upstream goes through load balancing and health
+		rr = serve(newHandler(t, config.NoRouteConfig{Action: "upstream:down"}))
+		if rr.Code != http.StatusServiceUnavailable {
+			t.Errorf("Expected 503 without a healthy catch-all target, got %d", rr.Code)
+		}
+
+		rr = serve(newHandler(t, config.NoRouteConfig{Action: "upstream:missing"}))
+		if rr.Code != http.StatusBadGateway {
+			t.Errorf("Expected 502 for an unknown catch-all upstream, got %d", rr.Code)
+		}
+	})
+
+	t.Run("static", func(t *testing.T) {
+		rr := serve(newHandler(t, config.NoRouteConfig{Action: "static", Body: "<h1>Nothing here</h1>"}))
+		if rr.Code != http.StatusNotFound || rr.Body.String() != "<h1>Nothing here</h1>" {
+			t.Errorf("Expected the static page with 404, got %d %q", rr.Code, rr.Body.String())
+		}
+		if contentType := rr.Header().Get("Content-Type"); contentType != "text/html; charset=utf-8" {
+			t.Errorf("Expected HTML content type, got %q", contentType)
+		}
+
+		rr = serve(newHandler(t, config.NoRouteConfig{
+			Action:      "static",
+			StatusCode:  http.StatusOK,
+			Body:        `{"message":"see https://docs.example.com"}`,
+			ContentType: "application/json",
+		}))
+		if rr.Code != http.StatusOK || rr.Header().Get("Content-Type") != "application/json" {
+			t.Errorf("Expected a 200 JSON page, got %d %q", rr.Code, rr.Header().Get("Content-Type"))
+		}
+	})
+}
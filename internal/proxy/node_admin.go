@@ -0,0 +1,39 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/songzhibin97/stargate/internal/controller/api"
+)
+
+// nodeAdminPrefix prefixes the Admin endpoints of the node. They are served
+// on the proxy listeners, under a prefix reserved by the gateway so they
+// never shadow the paths of proxied APIs.
+const nodeAdminPrefix = "/_stargate/admin"
+
+// nodeAdminPath returns the path of a node Admin endpoint, or "" if the REST
+// Admin API is disabled
+func (p *Pipeline) nodeAdminPath(suffix string) string {
+	if !p.config.AdminAPI.REST.Enabled {
+		return ""
+	}
+	return nodeAdminPrefix + suffix
+}
+
+// nodeAdminHandler protects a node Admin endpoint with the Admin API
+// authentication. Anyone reaching the gateway reaches the proxy listeners,
+// so requests are refused unless the authentication is enabled with
+// credentials, instead of being let through like the Admin API does.
+func (p *Pipeline) nodeAdminHandler(next http.Handler) http.Handler {
+	authenticated := api.NewAuthMiddleware(p.config).Middleware(next)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !p.config.AdminAuthConfigured() {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusForbidden)
+			json.NewEncoder(w).Encode(map[string]string{"error": "node admin endpoints require admin_api.auth to be enabled with credentials"})
+			return
+		}
+		authenticated.ServeHTTP(w, r)
+	})
+}
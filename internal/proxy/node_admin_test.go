@@ -0,0 +1,57 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/songzhibin97/stargate/internal/config"
+)
+
+func TestPipeline_NodeAdminFailsClosed(t *testing.T) {
+	tests := []struct {
+		name string
+		auth config.AuthConfig
+	}{
+		{name: "auth disabled", auth: config.AuthConfig{APIKey: config.APIKeyConfig{Header: "X-Admin-Key", Keys: []string{"secret"}}}},
+		{name: "no credentials", auth: config.AuthConfig{Enabled: true, APIKey: config.APIKeyConfig{Header: "X-Admin-Key"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{}
+			cfg.AdminAPI.REST = config.RESTConfig{Enabled: true, Prefix: "/api/v1"}
+			cfg.AdminAPI.Auth = tt.auth
+			cfg.Tap = config.TapConfig{Enabled: true, SampleRate: 1, Path: "/_stargate/tap"}
+
+			pipeline, err := NewPipeline(cfg, nil)
+			if err != nil {
+				t.Fatalf("Failed to create pipeline: %v", err)
+			}
+			defer pipeline.Stop()
+
+			targets := []string{
+				"/_stargate/admin/cache/flush?scope=all",
+				"/_stargate/admin/diagnostics/upstreams",
+				"/_stargate/admin/debug/routing",
+				"/_stargate/admin/config/effective",
+				"/_stargate/admin/tls/acme/renew?domain=example.com",
+				"/_stargate/admin/health/events",
+				"/_stargate/admin/routes:test",
+				"/_stargate/admin/ratelimit/overrides",
+				"/_stargate/admin/debug/upstreams/web",
+				"/_stargate/admin/wasm/plugins",
+				"/_stargate/tap",
+			}
+			for _, target := range targets {
+				req := httptest.NewRequest("POST", target, nil)
+				req.Header.Set("X-Admin-Key", "secret")
+				rr := httptest.NewRecorder()
+				pipeline.ServeHTTP(rr, req)
+				if rr.Code != http.StatusForbidden {
+					t.Errorf("%s: expected status 403, got %d", target, rr.Code)
+				}
+			}
+		})
+	}
+}
@@ -229,8 +229,8 @@ func TestPassiveHealthCheckRecovery(t *testing.T) {
 	}
 
 	var recoveryCallbackCalled bool
-	callback := func(upstreamID, targetKey string, healthy bool) {
-		if healthy {
+	callback := func(upstreamID, targetKey string, change health.HealthChange) {
+		if change.Healthy {
 			recoveryCallbackCalled = true
 		}
 	}
@@ -353,3 +353,37 @@ func getPortFromURL(urlStr string) int {
 
 	return port
 }
+
+func TestConvertToPassiveHealthConfig_PerUpstream(t *testing.T) {
+	timeoutAsFailure := false
+	cfg := &config.Config{}
+	cfg.Upstreams.Defaults.HealthCheck.Passive = config.PassiveHealthCheckConfig{
+		Enabled:              true,
+		ConsecutiveFailures:  3,
+		IsolationDuration:    30 * time.Second,
+		RecoveryInterval:     10 * time.Second,
+		ConsecutiveSuccesses: 2,
+		FailureStatusCodes:   []int{500, 502, 503},
+		TimeoutAsFailure:     true,
+	}
+	cfg.Upstreams.Passive = map[string]config.PassiveHealthOverrideConfig{
+		"flaky": {ConsecutiveFailures: 10, FailureStatusCodes: []int{502}, TimeoutAsFailure: &timeoutAsFailure},
+	}
+
+	pipeline := &Pipeline{config: cfg}
+	passiveConfig := pipeline.convertToPassiveHealthConfig()
+
+	flaky := passiveConfig.Upstreams["flaky"]
+	if flaky == nil {
+		t.Fatal("Expected passive health config of the flaky upstream")
+	}
+	if flaky.ConsecutiveFailures != 10 || len(flaky.FailureStatusCodes) != 1 || flaky.TimeoutAsFailure {
+		t.Errorf("Expected overridden thresholds, got %+v", flaky)
+	}
+	if flaky.IsolationDuration != 30*time.Second || flaky.ConsecutiveSuccesses != 2 {
+		t.Errorf("Expected unset thresholds to use the defaults, got %+v", flaky)
+	}
+	if passiveConfig.ConsecutiveFailures != 3 || !passiveConfig.TimeoutAsFailure {
+		t.Errorf("Expected the defaults to be unchanged, got %+v", passiveConfig)
+	}
+}
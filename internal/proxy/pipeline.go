@@ -2,6 +2,7 @@ package proxy
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"hash/fnv"
 	"log"
@@ -13,6 +14,7 @@ import (
 
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/songzhibin97/stargate/internal/auth"
+	"github.com/songzhibin97/stargate/internal/clientip"
 	"github.com/songzhibin97/stargate/internal/config"
 	"github.com/songzhibin97/stargate/internal/governance/circuitbreaker"
 	"github.com/songzhibin97/stargate/internal/governance/trafficmirror"
@@ -21,7 +23,10 @@ import (
 	"github.com/songzhibin97/stargate/internal/middleware"
 	"github.com/songzhibin97/stargate/internal/ratelimit"
 	"github.com/songzhibin97/stargate/internal/router"
+	"github.com/songzhibin97/stargate/internal/tls"
 	"github.com/songzhibin97/stargate/internal/types"
+	"github.com/songzhibin97/stargate/internal/webhook"
+	pkgconfig "github.com/songzhibin97/stargate/pkg/config"
 	"github.com/songzhibin97/stargate/pkg/metrics"
 )
 
@@ -33,7 +38,7 @@ type Pipeline struct {
 	logger    *log.Logger
 
 	// Middleware chain
-	middlewares []Middleware
+	middlewares []namedMiddleware
 	middlewareManager *middleware.Manager
 
 	// Core components
@@ -42,11 +47,22 @@ type Pipeline struct {
 	loadBalancerManager      *loadbalancer.Manager
 	reverseProxy             *ReverseProxy
 	websocketProxy           *WebSocketProxy
+	websocketLimiters        map[string]*ratelimit.ConnectionLimiter
+	upstreamLimiters         map[string]*upstreamLimiter // Concurrency limits keyed by upstream ID
+	upstreamPriority         *upstreamPriority           // Tier priority of queued requests, nil for arrival order
+	sessionAffinities        map[string]*sessionAffinity // Cookie-based session affinity keyed by upstream ID
+	randomAffinitySecret     []byte                      // Signs affinity cookies without a configured secret
 	passiveHealthChecker     *health.PassiveHealthChecker
+	healthWebhook            *webhook.Sender
+	clientIPResolver         *clientip.Resolver
+	upstreamOverride         *upstreamOverride
+	requestDeadline          *requestDeadline
+	rootPage                 *rootPage
 	authMiddleware           *auth.Middleware
 	ipaclMiddleware          *middleware.IPACLMiddleware
 	corsMiddleware           *middleware.CORSMiddleware
 	headerTransformMiddleware *middleware.HeaderTransformMiddleware
+	securityHeadersMiddleware *middleware.SecurityHeadersMiddleware
 	mockResponseMiddleware   *middleware.MockResponseMiddleware
 	grpcWebMiddleware        *middleware.GRPCWebMiddleware
 	rateLimitMiddleware      *ratelimit.Middleware
@@ -54,6 +70,27 @@ type Pipeline struct {
 	trafficMirrorMiddleware  *trafficmirror.Middleware
 	accessLogMiddleware      *middleware.AccessLogMiddleware
 	metricsMiddleware        *middleware.MetricsMiddleware
+	tapMiddleware            *middleware.TapMiddleware
+	tapHandler               http.Handler
+	experimentMiddleware     *middleware.ExperimentMiddleware
+	idempotencyMiddleware    *middleware.IdempotencyMiddleware
+	bodyChecksumMiddleware   *middleware.BodyChecksumMiddleware
+	schemaValidationMiddleware *middleware.SchemaValidationMiddleware
+	cacheFlushHandler        http.Handler
+	diagnosticsHandler       http.Handler
+	routingTableHandler      http.Handler
+	effectiveConfigHandler   http.Handler
+	acmeRenewHandler         http.Handler
+	healthEventsHandler      http.Handler
+	routeReplayHandler       http.Handler
+	rateLimitOverridesHandler http.Handler
+	upstreamDebugHandler     http.Handler
+	wasmPluginsHandler       http.Handler
+	upstreamDebug            *middleware.UpstreamDebugLogger
+	auditLogger              *middleware.AuditLogger
+	healthEvents             *healthEventBroker
+	acmeManager              *tls.ACMEManager // Set by the server when a listener uses ACME
+	configSource             pkgconfig.HealthReporter // Set by the node when its config source reports health
 	tracingMiddleware        *middleware.TracingMiddleware
 	aggregatorMiddleware     *middleware.AggregatorMiddleware
 	serverlessMiddleware     *middleware.ServerlessMiddleware
@@ -63,11 +100,59 @@ type Pipeline struct {
 	requestCount  int64
 	responseCount int64
 	errorCount    int64
+	rerouteCount  int64
+	fallbackCount int64
+	rejectedConnections int64
+	queueRejectCount    int64
+	tlsFailureCount     int64
+
+	// Dynamic routing re-routes by source and destination upstream
+	rerouteCounter metrics.CounterVec
+
+	// Fallback upstream attempts by route, upstreams and result
+	fallbackCounter metrics.CounterVec
+
+	// Connections rejected by connection limits by listener and reason
+	connectionRejectCounter metrics.CounterVec
+
+	// Upstream queues: depth and wait time by upstream, rejections by
+	// upstream and reason
+	upstreamQueueDepth         metrics.GaugeVec
+	upstreamQueueWait          metrics.HistogramVec
+	upstreamQueueRejectCounter metrics.CounterVec
+	upstreamTierCounter        metrics.CounterVec
+	upstreamTLSFailureCounter  metrics.CounterVec
+
+	// Passive health transitions by upstream, transition and reason
+	passiveHealthCounter metrics.CounterVec
+
+	// Targets per-target metrics have series for, by upstream
+	seriesMu      sync.Mutex
+	seriesTargets map[string]map[string]bool
+
+	// Startup cache warming, readiness waits for it until the deadline
+	cacheWarmMu       sync.Mutex
+	cacheWarmDone     chan struct{}
+	cacheWarmDeadline time.Time
+
+	// Shutdown state
+	notReady bool  // the health endpoint reports not ready, set on lame duck
+	draining bool  // new requests are rejected while draining
+	inFlight int64 // requests currently being served
+	targetInFlight map[string]int64 // requests currently proxied per upstream target
+	stopOnce sync.Once
 }
 
 // Middleware represents a middleware function
 type Middleware func(http.Handler) http.Handler
 
+// namedMiddleware is a middleware of the chain, named after its configuration
+// section so listener profiles can select it
+type namedMiddleware struct {
+	name    string
+	handler Middleware
+}
+
 // Router interface for route matching
 type Router interface {
 	Match(r *http.Request) (*Route, error)
@@ -89,6 +174,7 @@ type Route struct {
 	Paths      []string          `json:"paths"`
 	Methods    []string          `json:"methods"`
 	UpstreamID string            `json:"upstream_id"`
+	FallbackUpstreams []string   `json:"fallback_upstreams,omitempty"` // Tried in order when UpstreamID has no healthy target
 	Metadata   map[string]string `json:"metadata"`
 	CreatedAt  int64             `json:"created_at"`
 	UpdatedAt  int64             `json:"updated_at"`
@@ -164,6 +250,9 @@ func NewPipeline(cfg *config.Config, logger *log.Logger) (*Pipeline, error) {
 		return nil, fmt.Errorf("failed to build middleware chain: %w", err)
 	}
 
+	// Surface the features actually enabled by the merged configuration
+	p.logEffectiveConfig()
+
 	return p, nil
 }
 
@@ -190,7 +279,16 @@ func (p *Pipeline) DeleteRoute(routeID string) error {
 	}
 
 	// Remove the route from the router
-	return p.router.DeleteRoute(routeID)
+	if err := p.router.DeleteRoute(routeID); err != nil {
+		return err
+	}
+
+	// Drop the route's series so removed routes don't accumulate
+	if p.metricsMiddleware != nil {
+		p.metricsMiddleware.DeleteRouteSeries(routeID)
+	}
+
+	return nil
 }
 
 // UpdateUpstream updates a single upstream in the pipeline
@@ -203,7 +301,13 @@ func (p *Pipeline) UpdateUpstream(upstream *router.Upstream) error {
 	}
 
 	// Update the upstream in the load balancer manager
-	return p.loadBalancerManager.UpdateUpstream(upstream)
+	if err := p.loadBalancerManager.UpdateUpstream(upstream); err != nil {
+		return err
+	}
+
+	// Drop the series of removed targets
+	p.pruneTargetSeries(upstream.ID, routerTargetAddrs(upstream.Targets))
+	return nil
 }
 
 // DeleteUpstream removes an upstream from the pipeline
@@ -216,7 +320,13 @@ func (p *Pipeline) DeleteUpstream(upstreamID string) error {
 	}
 
 	// Remove the upstream from the load balancer manager
-	return p.loadBalancerManager.DeleteUpstream(upstreamID)
+	if err := p.loadBalancerManager.DeleteUpstream(upstreamID); err != nil {
+		return err
+	}
+
+	// Drop the upstream's series so removed upstreams don't accumulate
+	p.deleteUpstreamSeries(upstreamID)
+	return nil
 }
 
 // RebuildMiddleware rebuilds the entire middleware chain
@@ -258,6 +368,7 @@ func (p *Pipeline) ReloadRoutes(routes []router.RouteRule) error {
 			Paths:      convertPathRulesToStrings(route.Rules.Paths),
 			Methods:    route.Rules.Methods,
 			UpstreamID: route.UpstreamID,
+			FallbackUpstreams: route.FallbackUpstreams,
 			Metadata:   route.Metadata,
 			CreatedAt:  route.CreatedAt,
 			UpdatedAt:  route.UpdatedAt,
@@ -292,15 +403,51 @@ func (p *Pipeline) ReloadUpstreams(upstreams []router.Upstream) error {
 	}
 
 	// Reload all upstreams using the manager
-	return p.loadBalancerManager.ReloadUpstreams(upstreams)
+	err := p.loadBalancerManager.ReloadUpstreams(upstreams)
+
+	// Drop the series of removed upstreams and targets
+	reloaded := make(map[string]bool, len(upstreams))
+	for _, upstream := range upstreams {
+		reloaded[upstream.ID] = true
+		p.pruneTargetSeries(upstream.ID, routerTargetAddrs(upstream.Targets))
+	}
+	for _, upstreamID := range p.upstreamsWithSeries() {
+		if !reloaded[upstreamID] {
+			p.deleteUpstreamSeries(upstreamID)
+		}
+	}
+
+	return err
 }
 
 // ServeHTTP implements http.Handler interface
 func (p *Pipeline) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Request deadlines count from arrival, including time spent in middlewares
+	arrival := time.Now()
+
+	// Handle health endpoint, answered even while draining
+	if p.config != nil && p.config.Server.HealthPath != "" && r.URL.Path == p.config.Server.HealthPath {
+		p.handleHealth(w, r)
+		return
+	}
+
 	p.mu.Lock()
 	p.requestCount++
+	if p.draining {
+		p.mu.Unlock()
+		w.Header().Set("Connection", "close")
+		p.handleError(w, r, http.StatusServiceUnavailable, "Gateway is shutting down")
+		return
+	}
+	p.inFlight++
 	p.mu.Unlock()
 
+	defer func() {
+		p.mu.Lock()
+		p.inFlight--
+		p.mu.Unlock()
+	}()
+
 	// Handle metrics endpoint
 	if p.config.Metrics.Enabled && r.URL.Path == p.config.Metrics.Path {
 		if p.metricsMiddleware != nil {
@@ -315,13 +462,106 @@ func (p *Pipeline) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Resolve the client IP once for all middlewares
+	p.mu.RLock()
+	resolver := p.clientIPResolver
+	p.mu.RUnlock()
+	r = resolver.WithClientIP(r)
+
+	// Match the request against the routes of the tenant serving its host
+	if p.config.Tenancy.Enabled {
+		r = r.WithContext(router.WithTenant(r.Context(), p.config.Tenancy.TenantForHost(r.Host)))
+	}
+
+	// Select the middlewares and routes of the accepting listener
+	profile := p.listenerProfile(r)
+
+	// Handle tap endpoint, protected by the node Admin authentication
+	if p.tapMiddleware != nil && r.URL.Path == p.tapMiddleware.Path() {
+		p.tapHandler.ServeHTTP(w, r)
+		return
+	}
+
+	// Handle cache flush endpoint, protected by the node Admin authentication
+	if path := p.cacheFlushPath(); path != "" && r.URL.Path == path {
+		p.cacheFlushHandler.ServeHTTP(w, r)
+		return
+	}
+
+	// Handle upstream diagnostics endpoint, protected by the node Admin authentication
+	if path := p.diagnosticsPath(); path != "" && r.URL.Path == path {
+		p.diagnosticsHandler.ServeHTTP(w, r)
+		return
+	}
+
+	// Handle routing table endpoint, protected by the node Admin authentication
+	if path := p.routingTablePath(); path != "" && r.URL.Path == path {
+		p.routingTableHandler.ServeHTTP(w, r)
+		return
+	}
+
+	// Handle certificate renewal endpoint, protected by the node Admin authentication
+	if path := p.acmeRenewPath(); path != "" && r.URL.Path == path {
+		p.acmeRenewHandler.ServeHTTP(w, r)
+		return
+	}
+
+	// Handle route replay endpoint, protected by the node Admin authentication
+	if path := p.routeReplayPath(); path != "" && r.URL.Path == path {
+		p.routeReplayHandler.ServeHTTP(w, r)
+		return
+	}
+
+	// Handle rate limit overrides endpoint, protected by the node Admin authentication
+	if path := p.rateLimitOverridesPath(); path != "" && (r.URL.Path == path || strings.HasPrefix(r.URL.Path, path+"/")) {
+		p.rateLimitOverridesHandler.ServeHTTP(w, r)
+		return
+	}
+
+	// Handle upstream debug logging endpoint, protected by the node Admin authentication
+	if path := p.upstreamDebugPath(); path != "" && (r.URL.Path == path || strings.HasPrefix(r.URL.Path, path+"/")) {
+		p.upstreamDebugHandler.ServeHTTP(w, r)
+		return
+	}
+
+	// Handle health event stream, protected by the node Admin authentication
+	if path := p.healthEventsPath(); path != "" && r.URL.Path == path {
+		p.healthEventsHandler.ServeHTTP(w, r)
+		return
+	}
+
+	// Handle WASM plugins endpoint, protected by the node Admin authentication
+	if path := p.wasmPluginsPath(); path != "" && r.URL.Path == path {
+		p.wasmPluginsHandler.ServeHTTP(w, r)
+		return
+	}
+
+	// Handle effective configuration endpoint, protected by the node Admin authentication
+	if path := p.effectiveConfigPath(); path != "" && r.URL.Path == path {
+		p.effectiveConfigHandler.ServeHTTP(w, r)
+		return
+	}
+
 	// Log protocol information for debugging
 	p.logProtocolInfo(r)
 
 	// Check if this is a WebSocket upgrade request
 	if p.websocketProxy.IsWebSocketUpgrade(r) {
+		// Only upgrade routes served on this listener
+		if route, err := p.router.Match(r); err == nil && !profile.servesRoute(route.ID) {
+			p.handleError(w, r, http.StatusNotFound, "route not found")
+			return
+		}
+
+		// Enforce connection limits before contacting the upstream
+		r, release, allowed := p.acquireWebSocketConnection(w, r)
+		if !allowed {
+			return
+		}
+
 		// Handle WebSocket upgrade
 		if err := p.websocketProxy.HandleWebSocketUpgrade(w, r); err != nil {
+			release()
 			p.handleError(w, r, http.StatusBadRequest, fmt.Sprintf("WebSocket upgrade failed: %v", err))
 			return
 		}
@@ -329,12 +569,18 @@ func (p *Pipeline) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Bound the request by its deadline, if any
+	r, cancel := p.withRequestDeadline(r, arrival)
+	defer cancel()
+
 	// Create handler chain for regular HTTP requests
 	handler := p.createHandler()
 
 	// Execute middleware chain
 	for i := len(p.middlewares) - 1; i >= 0; i-- {
-		handler = p.middlewares[i](handler)
+		if profile.appliesMiddleware(p.middlewares[i].name) {
+			handler = p.middlewares[i].handler(handler)
+		}
 	}
 
 	// Serve request
@@ -386,44 +632,284 @@ func (p *Pipeline) Start() error {
 		}
 	}
 
+	// Prime the response caches before reporting ready
+	p.startCacheWarm()
+
 	return nil
 }
 
-// Stop stops the pipeline
+// defaultShutdownTimeout bounds Stop, which has no caller supplied deadline
+const defaultShutdownTimeout = 30 * time.Second
+
+// drainPollInterval is how often in-flight requests are checked while draining
+const drainPollInterval = 10 * time.Millisecond
+
+// shutdownStepGrace is how long a step started after the deadline may run
+// before it is reported as timed out, so quick cleanup still completes
+const shutdownStepGrace = 100 * time.Millisecond
+
+// shutdownStep is a named step of the pipeline shutdown sequence
+type shutdownStep struct {
+	name string
+	run  func(ctx context.Context) error
+}
+
+// Stop stops the pipeline, see Shutdown
 func (p *Pipeline) Stop() error {
-	// Stop WebSocket proxy
-	if p.websocketProxy != nil {
-		if err := p.websocketProxy.Close(); err != nil {
-			log.Printf("Failed to close WebSocket proxy: %v", err)
+	ctx, cancel := context.WithTimeout(context.Background(), defaultShutdownTimeout)
+	defer cancel()
+	return p.Shutdown(ctx)
+}
+
+// Shutdown stops the pipeline components in order: stop accepting new
+// requests, drain in-flight requests, close the proxies, close the
+// authenticators, stop health checks, stop the rate limiter, close the load
+// balancer and router, flush metrics, close the tap file, the upstream debug
+// log and the idempotency store. Health checks are stopped only after
+// draining so targets don't flap while the last requests complete.
+//
+// All steps share the deadline of ctx. A step that fails or runs past the
+// deadline doesn't prevent the remaining steps from running; the returned
+// error describes every step that didn't complete cleanly. Only the first
+// call shuts the pipeline down, later calls return nil.
+func (p *Pipeline) Shutdown(ctx context.Context) error {
+	var err error
+	p.stopOnce.Do(func() {
+		err = p.shutdown(ctx)
+	})
+	return err
+}
+
+// shutdown runs the shutdown steps and aggregates their errors
+func (p *Pipeline) shutdown(ctx context.Context) error {
+	steps := []shutdownStep{
+		{"stop accepting requests", p.stopAccepting},
+		{"drain in-flight requests", p.drain},
+		{"close proxies", p.closeProxies},
+		{"close authenticators", p.closeAuth},
+		{"stop health checks", p.stopHealthChecks},
+		{"stop rate limiter", p.stopRateLimiter},
+		{"close load balancer and router", p.closeRouting},
+		{"flush metrics", p.flushMetrics},
+		{"close tap", p.closeTap},
+		{"close upstream debug log", p.closeUpstreamDebug},
+		{"close idempotency store", p.closeIdempotencyStore},
+	}
+
+	var errs []error
+	for _, step := range steps {
+		if err := runShutdownStep(ctx, step); err != nil {
+			log.Printf("Pipeline shutdown step %q failed: %v", step.name, err)
+			errs = append(errs, fmt.Errorf("%s: %w", step.name, err))
 		}
 	}
 
-	// Stop load balancer
-	if stopper, ok := p.loadBalancer.(interface{ Stop() error }); ok {
-		if err := stopper.Stop(); err != nil {
-			return fmt.Errorf("failed to stop load balancer: %w", err)
+	if len(errs) > 0 {
+		return fmt.Errorf("pipeline shutdown incomplete: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// runShutdownStep runs a step, giving up when the shared deadline expires.
+// A step still running past the deadline finishes in the background.
+func runShutdownStep(ctx context.Context, step shutdownStep) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- step.run(ctx)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+	}
+
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(shutdownStepGrace):
+		return ctx.Err()
+	}
+}
+
+// EnterLameDuck marks the pipeline not ready while it keeps serving, so load
+// balancers polling the health endpoint stop routing to it before shutdown
+func (p *Pipeline) EnterLameDuck() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.notReady = true
+}
+
+// Ready reports whether the pipeline accepts traffic and hasn't begun shutting
+// down. With wait_for_first_check, it isn't ready until every target has
+// been health checked once, and with cache warming until the caches are
+// warmed or the warm deadline passes.
+func (p *Pipeline) Ready() bool {
+	p.mu.RLock()
+	ready := !p.notReady && !p.draining
+	p.mu.RUnlock()
+	return ready && p.healthChecksWarmedUp() && p.cachesWarmedUp()
+}
+
+// healthChecksWarmedUp reports whether the load balancer's startup health
+// checks have completed
+func (p *Pipeline) healthChecksWarmedUp() bool {
+	if reporter, ok := p.loadBalancer.(interface{ HealthChecksWarmedUp() bool }); ok {
+		return reporter.HealthChecksWarmedUp()
+	}
+	return true
+}
+
+// handleHealth answers the health endpoint with 200 while ready and 503
+// during startup health checks or once the lame-duck period or shutdown
+// has begun
+func (p *Pipeline) handleHealth(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+
+	if !p.Ready() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte(`{"status": "not_ready"}`))
+		return
+	}
+	// A degraded config source doesn't affect readiness as the last known
+	// good configuration keeps being served
+	if p.configSourceDegraded() {
+		w.Write([]byte(`{"status": "healthy", "config_source": "degraded"}`))
+		return
+	}
+	w.Write([]byte(`{"status": "healthy"}`))
+}
+
+// stopAccepting rejects new requests with 503
+func (p *Pipeline) stopAccepting(ctx context.Context) error {
+	p.mu.Lock()
+	p.draining = true
+	p.mu.Unlock()
+
+	// End health event streams so they don't hold up draining
+	if p.healthEvents != nil {
+		p.healthEvents.close()
+	}
+	return nil
+}
+
+// drain waits for in-flight requests to complete
+func (p *Pipeline) drain(ctx context.Context) error {
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+
+	for {
+		p.mu.RLock()
+		inFlight := p.inFlight
+		p.mu.RUnlock()
+
+		if inFlight == 0 {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%d requests still in flight: %w", inFlight, ctx.Err())
+		case <-ticker.C:
 		}
 	}
+}
 
-	// Stop router
-	if stopper, ok := p.router.(interface{ Stop() error }); ok {
-		if err := stopper.Stop(); err != nil {
-			return fmt.Errorf("failed to stop router: %w", err)
+// closeProxies closes WebSocket and upstream connections
+func (p *Pipeline) closeProxies(ctx context.Context) error {
+	var errs []error
+	if p.websocketProxy != nil {
+		if err := p.websocketProxy.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("websocket proxy: %w", err))
+		}
+	}
+	if p.reverseProxy != nil {
+		if err := p.reverseProxy.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("reverse proxy: %w", err))
 		}
 	}
+	return errors.Join(errs...)
+}
 
-	// Stop passive health checker
+// stopHealthChecks stops passive and active health checking and the health webhook
+func (p *Pipeline) stopHealthChecks(ctx context.Context) error {
+	var errs []error
 	if p.passiveHealthChecker != nil {
 		if err := p.passiveHealthChecker.Stop(); err != nil {
-			log.Printf("Failed to stop passive health checker: %v", err)
+			errs = append(errs, fmt.Errorf("passive health checker: %w", err))
+		}
+	}
+	if stopper, ok := p.loadBalancer.(interface{ StopHealthChecks() error }); ok {
+		if err := stopper.StopHealthChecks(); err != nil {
+			errs = append(errs, fmt.Errorf("active health checker: %w", err))
 		}
 	}
+	if p.healthWebhook != nil {
+		p.healthWebhook.Close()
+	}
+	return errors.Join(errs...)
+}
 
-	// Stop rate limit middleware
+// stopRateLimiter stops the rate limiter's background cleanup
+func (p *Pipeline) stopRateLimiter(ctx context.Context) error {
 	if p.rateLimitMiddleware != nil {
 		p.rateLimitMiddleware.Stop()
 	}
+	return nil
+}
+
+// closeRouting stops the load balancer and router
+func (p *Pipeline) closeRouting(ctx context.Context) error {
+	var errs []error
+	if stopper, ok := p.loadBalancer.(interface{ Stop() error }); ok {
+		if err := stopper.Stop(); err != nil {
+			errs = append(errs, fmt.Errorf("load balancer: %w", err))
+		}
+	}
+	if stopper, ok := p.router.(interface{ Stop() error }); ok {
+		if err := stopper.Stop(); err != nil {
+			errs = append(errs, fmt.Errorf("router: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// flushMetrics flushes buffered metric updates
+func (p *Pipeline) flushMetrics(ctx context.Context) error {
+	if p.metricsMiddleware != nil {
+		return p.metricsMiddleware.Close()
+	}
+	return nil
+}
+
+// closeAuth waits for shadow auth evaluations and releases the resources of
+// the authenticators
+func (p *Pipeline) closeAuth(ctx context.Context) error {
+	if p.authMiddleware != nil {
+		p.authMiddleware.Close()
+	}
+	return nil
+}
 
+// closeTap closes the tap capture file
+func (p *Pipeline) closeTap(ctx context.Context) error {
+	if p.tapMiddleware != nil {
+		return p.tapMiddleware.Close()
+	}
+	return nil
+}
+
+// closeUpstreamDebug closes the upstream debug log file
+func (p *Pipeline) closeUpstreamDebug(ctx context.Context) error {
+	return p.upstreamDebug.Close()
+}
+
+// closeIdempotencyStore closes the idempotency key storage
+func (p *Pipeline) closeIdempotencyStore(ctx context.Context) error {
+	if p.idempotencyMiddleware != nil {
+		return p.idempotencyMiddleware.Close()
+	}
 	return nil
 }
 
@@ -432,8 +918,13 @@ func (p *Pipeline) Health() map[string]interface{} {
 	p.mu.RLock()
 	defer p.mu.RUnlock()
 
+	status := "healthy"
+	if p.notReady || p.draining || !p.healthChecksWarmedUp() || !p.cachesWarmedUp() {
+		status = "not_ready"
+	}
+
 	health := map[string]interface{}{
-		"status":         "healthy",
+		"status":         status,
 		"uptime":         time.Since(p.startTime).Seconds(),
 		"request_count":  p.requestCount,
 		"response_count": p.responseCount,
@@ -445,6 +936,21 @@ func (p *Pipeline) Health() map[string]interface{} {
 		health["load_balancer"] = p.loadBalancer.Health()
 	}
 
+	// Add WASM plugin versions
+	if p.wasmMiddleware != nil {
+		health["wasm_plugins"] = p.wasmMiddleware.PluginInfos()
+	}
+
+	// Add upstreams with debug logging on
+	if active := p.upstreamDebug.Active(); len(active) > 0 {
+		health["upstream_debug_logging"] = active
+	}
+
+	// Add the state of the config source
+	if p.configSource != nil {
+		health["config_source"] = p.configSource.Health()
+	}
+
 	return health
 }
 
@@ -453,20 +959,113 @@ func (p *Pipeline) Metrics() map[string]interface{} {
 	p.mu.RLock()
 	defer p.mu.RUnlock()
 
-	return map[string]interface{}{
+	stats := map[string]interface{}{
 		"uptime":         time.Since(p.startTime).Seconds(),
 		"request_count":  p.requestCount,
 		"response_count": p.responseCount,
 		"error_count":    p.errorCount,
+		"reroute_count":  p.rerouteCount,
+		"fallback_count": p.fallbackCount,
+		"rejected_connections": p.rejectedConnections,
+		"queue_rejected":       p.queueRejectCount,
+		"tls_failures":         p.tlsFailureCount,
+	}
+	if len(p.upstreamLimiters) > 0 {
+		queues := make(map[string]interface{}, len(p.upstreamLimiters))
+		for upstreamID, limiter := range p.upstreamLimiters {
+			queues[upstreamID] = limiter.stats()
+		}
+		stats["upstream_queues"] = queues
+	}
+	if p.healthEvents != nil {
+		stats["health_event_streams"] = p.healthEvents.subscriberCount()
 	}
+	if p.authMiddleware != nil {
+		if jwtStats := p.authMiddleware.JWTStats(); jwtStats != nil {
+			stats["jwt"] = jwtStats
+		}
+	}
+	return stats
 }
 
-// Reload reloads the pipeline configuration
+// Reload reloads the pipeline configuration. Every component of the new
+// configuration is built first and they are applied together, so an invalid
+// configuration leaves the running one untouched.
 func (p *Pipeline) Reload(cfg *config.Config) error {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
+	// Build trusted proxies
+	resolver, err := clientip.NewResolver(cfg.Server.TrustedProxies)
+	if err != nil {
+		return fmt.Errorf("failed to create client IP resolver: %w", err)
+	}
+
+	// Build upstream override trust
+	override, err := newUpstreamOverride(cfg.Proxy.UpstreamOverride)
+	if err != nil {
+		return err
+	}
+
+	// Build request deadlines
+	deadline, err := newRequestDeadline(cfg.Proxy.Deadline)
+	if err != nil {
+		return err
+	}
+
+	// Build root page
+	root, err := newRootPage(cfg.Proxy.Root)
+	if err != nil {
+		return err
+	}
+
+	// Build upstream priority tiers
+	priority, err := newUpstreamPriority(cfg.Upstreams.Priority)
+	if err != nil {
+		return err
+	}
+
+	// Build transports of upstreams whose connection settings changed
+	applyTransports := func() {}
+	if p.reverseProxy != nil {
+		if applyTransports, err = p.reverseProxy.PrepareConfig(cfg); err != nil {
+			return fmt.Errorf("failed to update upstream transports: %w", err)
+		}
+	}
+
+	// Compile JSON schemas
+	applySchemas := func() {}
+	if p.schemaValidationMiddleware != nil {
+		if applySchemas, err = p.schemaValidationMiddleware.PrepareConfig(&cfg.SchemaValidation); err != nil {
+			return fmt.Errorf("failed to update JSON schemas: %w", err)
+		}
+	}
+
+	// Load revoked JWT IDs
+	applyRevocations := func() {}
+	if p.authMiddleware != nil {
+		if applyRevocations, err = p.authMiddleware.PrepareJWTRevocations(&cfg.Auth.JWT.Revocation); err != nil {
+			return fmt.Errorf("failed to update JWT revocations: %w", err)
+		}
+	}
+
+	// Apply the configuration and its components together
 	p.config = cfg
+	p.clientIPResolver = resolver
+	p.upstreamOverride = override
+	p.requestDeadline = deadline
+	p.rootPage = root
+	p.upstreamPriority = priority
+	p.updateUpstreamLimiters(cfg.Upstreams.Concurrency)
+	p.sessionAffinities = newSessionAffinities(cfg.Upstreams.Affinity, p.sessionAffinitySecret(cfg.Upstreams.AffinitySecret))
+	applyTransports()
+	applySchemas()
+	applyRevocations()
+
+	// Update body checksum routes
+	if p.bodyChecksumMiddleware != nil {
+		p.bodyChecksumMiddleware.UpdateConfig(&cfg.BodyChecksum)
+	}
 
 	// Rebuild middleware chain
 	return p.buildMiddlewareChain()
@@ -480,15 +1079,68 @@ func (p *Pipeline) initializeComponents() error {
 	// Initialize load balancer based on configuration
 	p.loadBalancer = p.createLoadBalancer()
 
-	// Initialize reverse proxy
+	// Stream active health transitions to health event subscribers
+	p.healthEvents = newHealthEventBroker()
+	if notifier, ok := p.loadBalancer.(interface {
+		AddHealthChangeCallback(callback health.HealthChangeCallback)
+	}); ok {
+		notifier.AddHealthChangeCallback(p.onActiveHealthChange)
+	}
+
+	// Initialize client IP resolver
 	var err error
+	p.clientIPResolver, err = clientip.NewResolver(p.config.Server.TrustedProxies)
+	if err != nil {
+		return fmt.Errorf("failed to create client IP resolver: %w", err)
+	}
+
+	// Initialize upstream override
+	p.upstreamOverride, err = newUpstreamOverride(p.config.Proxy.UpstreamOverride)
+	if err != nil {
+		return err
+	}
+
+	// Initialize request deadlines
+	p.requestDeadline, err = newRequestDeadline(p.config.Proxy.Deadline)
+	if err != nil {
+		return err
+	}
+
+	// Initialize root page
+	p.rootPage, err = newRootPage(p.config.Proxy.Root)
+	if err != nil {
+		return err
+	}
+
+	// Initialize reverse proxy
 	p.reverseProxy, err = NewReverseProxy(p.config)
 	if err != nil {
 		return fmt.Errorf("failed to create reverse proxy: %w", err)
 	}
 
+	p.reverseProxy.SetUpstreamResolver(p.resolveReroute)
+
 	// Initialize WebSocket proxy
 	p.websocketProxy = NewWebSocketProxy(p.config)
+	p.websocketLimiters, err = newWebSocketLimiters(p.config.RateLimit.Connections)
+	if err != nil {
+		return err
+	}
+
+	// Initialize upstream concurrency limits
+	p.upstreamPriority, err = newUpstreamPriority(p.config.Upstreams.Priority)
+	if err != nil {
+		return err
+	}
+	p.updateUpstreamLimiters(p.config.Upstreams.Concurrency)
+
+	// Initialize session affinity
+	p.sessionAffinities = newSessionAffinities(p.config.Upstreams.Affinity, p.sessionAffinitySecret(p.config.Upstreams.AffinitySecret))
+
+	// Initialize health status webhook for passive health transitions
+	if p.config.Webhooks.HealthStatus.Enabled {
+		p.healthWebhook = webhook.NewSender(&p.config.Webhooks.HealthStatus)
+	}
 
 	// Initialize passive health checker
 	passiveConfig := p.convertToPassiveHealthConfig()
@@ -500,6 +1152,7 @@ func (p *Pipeline) initializeComponents() error {
 	// Initialize authentication middleware
 	if p.config.Auth.Enabled {
 		p.authMiddleware = auth.NewMiddleware(&p.config.Auth)
+		p.authMiddleware.SetRouteMatcher(p.matchedRouteID)
 	}
 
 	// Initialize IP ACL middleware
@@ -521,6 +1174,11 @@ func (p *Pipeline) initializeComponents() error {
 		p.headerTransformMiddleware = middleware.NewHeaderTransformMiddleware(&p.config.HeaderTransform)
 	}
 
+	// Initialize security headers middleware
+	if p.config.SecurityHeaders.Enabled {
+		p.securityHeadersMiddleware = middleware.NewSecurityHeadersMiddleware(&p.config.SecurityHeaders)
+	}
+
 	// Initialize mock response middleware
 	if p.config.MockResponse.Enabled {
 		p.mockResponseMiddleware, err = middleware.NewMockResponseMiddleware(&p.config.MockResponse)
@@ -544,6 +1202,7 @@ func (p *Pipeline) initializeComponents() error {
 		if err != nil {
 			return fmt.Errorf("failed to create rate limit middleware: %w", err)
 		}
+		p.rateLimitMiddleware.SetRouteMatcher(p.matchedRouteID)
 	}
 
 	// Initialize circuit breaker middleware
@@ -552,6 +1211,7 @@ func (p *Pipeline) initializeComponents() error {
 		if err != nil {
 			return fmt.Errorf("failed to create circuit breaker middleware: %w", err)
 		}
+		p.circuitBreakerMiddleware.SetStateChangeListener(p.publishBreakerState)
 	}
 
 	// Initialize traffic mirror middleware
@@ -568,6 +1228,13 @@ func (p *Pipeline) initializeComponents() error {
 		if err != nil {
 			return fmt.Errorf("failed to create access log middleware: %w", err)
 		}
+		p.accessLogMiddleware.SetRouteMatcher(p.matchedRouteID)
+	}
+
+	// Initialize audit log of administrative actions
+	p.auditLogger, err = middleware.NewAuditLogger(&p.config.Logging.AuditLog)
+	if err != nil {
+		return fmt.Errorf("failed to create audit logger: %w", err)
 	}
 
 	// Initialize metrics middleware
@@ -586,10 +1253,21 @@ func (p *Pipeline) initializeComponents() error {
 				SampleRate:      p.config.Metrics.SampleRate,
 				LabelExtractors: p.config.Metrics.LabelExtractors,
 				SensitiveLabels: p.config.Metrics.SensitiveLabels,
+				HashedLabels:    p.config.Metrics.HashedLabels,
 				MaxLabelLength:  p.config.Metrics.MaxLabelLength,
 				AsyncUpdates:    p.config.Metrics.AsyncUpdates,
 				BufferSize:      p.config.Metrics.BufferSize,
 			}
+			if len(p.config.Metrics.RouteGroups) > 0 {
+				metricsConfig.RouteGroups = make(map[string]middleware.MetricsRouteGroup, len(p.config.Metrics.RouteGroups))
+				for name, group := range p.config.Metrics.RouteGroups {
+					metricsConfig.RouteGroups[name] = middleware.MetricsRouteGroup{
+						Routes:    group.Routes,
+						Subsystem: group.Subsystem,
+						Labels:    group.Labels,
+					}
+				}
+			}
 
 			// Set defaults if not specified
 			if metricsConfig.Provider == "" {
@@ -632,8 +1310,236 @@ func (p *Pipeline) initializeComponents() error {
 				return fmt.Errorf("failed to create metrics middleware from Prometheus config: %w", err)
 			}
 		}
+		if p.metricsMiddleware != nil {
+			p.metricsMiddleware.SetRouteMatcher(p.matchedMetricsRoute)
+		}
 	}
 
+	// Initialize dynamic routing metrics
+	if provider := p.getMetricsProvider(); provider != nil {
+		p.rerouteCounter, err = provider.NewCounterVec(metrics.MetricOptions{
+			Name:   "upstream_reroutes_total",
+			Help:   "Total number of requests re-routed by an upstream routing header",
+			Labels: []string{"from_upstream", "to_upstream"},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create re-route counter: %w", err)
+		}
+
+		p.fallbackCounter, err = provider.NewCounterVec(metrics.MetricOptions{
+			Name:   "upstream_fallback_attempts_total",
+			Help:   "Total number of attempts to fail over to a route's fallback upstream by result",
+			Labels: []string{"route", "primary_upstream", "fallback_upstream", "result"},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create fallback counter: %w", err)
+		}
+
+		p.connectionRejectCounter, err = provider.NewCounterVec(metrics.MetricOptions{
+			Name:   "connections_rejected_total",
+			Help:   "Total number of WebSocket and stream connections rejected by connection limits",
+			Labels: []string{"listener", "reason"},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create rejected connection counter: %w", err)
+		}
+
+		p.upstreamQueueDepth, err = provider.NewGaugeVec(metrics.MetricOptions{
+			Name:   "upstream_queue_depth",
+			Help:   "Number of requests waiting for a slot of a saturated upstream",
+			Labels: []string{"upstream"},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create upstream queue depth gauge: %w", err)
+		}
+
+		p.upstreamQueueWait, err = provider.NewHistogramVec(metrics.MetricOptions{
+			Name:    "upstream_queue_wait_seconds",
+			Help:    "Time requests waited for a slot of a saturated upstream by result",
+			Labels:  []string{"upstream", "result"},
+			Buckets: metrics.GetDefaultBuckets("duration"),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create upstream queue wait histogram: %w", err)
+		}
+
+		p.upstreamQueueRejectCounter, err = provider.NewCounterVec(metrics.MetricOptions{
+			Name:   "upstream_queue_rejected_total",
+			Help:   "Total number of requests rejected by upstream concurrency limits by reason",
+			Labels: []string{"upstream", "reason"},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create upstream queue rejection counter: %w", err)
+		}
+
+		p.upstreamTierCounter, err = provider.NewCounterVec(metrics.MetricOptions{
+			Name:   "upstream_tier_requests_total",
+			Help:   "Total number of requests admitted or rejected by upstream concurrency limits by consumer tier",
+			Labels: []string{"upstream", "tier", "result"},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create upstream tier counter: %w", err)
+		}
+
+		p.upstreamTLSFailureCounter, err = provider.NewCounterVec(metrics.MetricOptions{
+			Name:   "upstream_tls_handshake_failures_total",
+			Help:   "Total number of failed TLS handshakes with upstream targets by reason",
+			Labels: []string{"upstream", "target", "reason"},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create upstream TLS handshake failure counter: %w", err)
+		}
+
+		p.passiveHealthCounter, err = provider.NewCounterVec(metrics.MetricOptions{
+			Name:   "upstream_passive_health_transitions_total",
+			Help:   "Total number of targets ejected or recovered by passive health checks by reason",
+			Labels: []string{"upstream", "transition", "reason"},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create passive health transition counter: %w", err)
+		}
+
+		if p.rateLimitMiddleware != nil {
+			storageFallbacks, err := provider.NewCounterVec(metrics.MetricOptions{
+				Name:   "ratelimit_storage_fallbacks_total",
+				Help:   "Total number of rate limit storage operations served from memory because the storage backend failed",
+				Labels: []string{"storage"},
+			})
+			if err != nil {
+				return fmt.Errorf("failed to create rate limit storage fallback counter: %w", err)
+			}
+			p.rateLimitMiddleware.SetStorageMetrics(storageFallbacks)
+		}
+
+		if p.authMiddleware != nil {
+			jwtCacheRequests, err := provider.NewCounterVec(metrics.MetricOptions{
+				Name:   "jwt_cache_requests_total",
+				Help:   "Total number of verified JWT cache lookups by result",
+				Labels: []string{"result"},
+			})
+			if err != nil {
+				return fmt.Errorf("failed to create JWT cache counter: %w", err)
+			}
+			jwtRevoked, err := provider.NewCounter(metrics.MetricOptions{
+				Name: "jwt_revoked_tokens_total",
+				Help: "Total number of requests rejected for carrying a revoked JWT",
+			})
+			if err != nil {
+				return fmt.Errorf("failed to create revoked JWT counter: %w", err)
+			}
+			p.authMiddleware.SetJWTMetrics(jwtCacheRequests, jwtRevoked)
+
+			introspectionAttempts, err := provider.NewCounterVec(metrics.MetricOptions{
+				Name:   "oauth2_introspection_attempts_total",
+				Help:   "Total number of OAuth 2.0 introspection requests by result",
+				Labels: []string{"result"},
+			})
+			if err != nil {
+				return fmt.Errorf("failed to create introspection attempt counter: %w", err)
+			}
+			introspectionDuration, err := provider.NewHistogramVec(metrics.MetricOptions{
+				Name:    "oauth2_introspection_duration_seconds",
+				Help:    "Latency of OAuth 2.0 introspection requests by result",
+				Labels:  []string{"result"},
+				Buckets: metrics.GetDefaultBuckets("duration"),
+			})
+			if err != nil {
+				return fmt.Errorf("failed to create introspection latency histogram: %w", err)
+			}
+			introspectionErrors, err := provider.NewCounterVec(metrics.MetricOptions{
+				Name:   "oauth2_introspection_errors_total",
+				Help:   "Total number of failed OAuth 2.0 introspections by reason",
+				Labels: []string{"reason"},
+			})
+			if err != nil {
+				return fmt.Errorf("failed to create introspection error counter: %w", err)
+			}
+			p.authMiddleware.SetOAuth2Metrics(introspectionAttempts, introspectionDuration, introspectionErrors)
+
+			if p.config.Auth.Shadow.Enabled {
+				shadowDecisions, err := provider.NewCounterVec(metrics.MetricOptions{
+					Name:   "auth_shadow_decisions_total",
+					Help:   "Total number of shadow auth decisions by active and shadow outcome and shadow failure reason",
+					Labels: []string{"active", "shadow", "reason"},
+				})
+				if err != nil {
+					return fmt.Errorf("failed to create shadow auth decision counter: %w", err)
+				}
+				p.authMiddleware.SetShadowMetrics(shadowDecisions)
+			}
+		}
+	}
+
+	// Initialize tap middleware
+	if p.config.Tap.Enabled {
+		p.tapMiddleware, err = middleware.NewTapMiddleware(&p.config.Tap)
+		if err != nil {
+			return fmt.Errorf("failed to create tap middleware: %w", err)
+		}
+		p.tapHandler = p.nodeAdminHandler(p.tapMiddleware.AdminHandler())
+	}
+
+	// Initialize experiment middleware
+	if p.config.Experiments.Enabled {
+		p.experimentMiddleware, err = middleware.NewExperimentMiddleware(&p.config.Experiments)
+		if err != nil {
+			return fmt.Errorf("failed to create experiment middleware: %w", err)
+		}
+	}
+
+	// Initialize idempotency middleware
+	if p.config.Idempotency.Enabled {
+		p.idempotencyMiddleware, err = middleware.NewIdempotencyMiddleware(&p.config.Idempotency)
+		if err != nil {
+			return fmt.Errorf("failed to create idempotency middleware: %w", err)
+		}
+	}
+
+	// Initialize body checksum middleware
+	if p.config.BodyChecksum.Enabled {
+		p.bodyChecksumMiddleware = middleware.NewBodyChecksumMiddleware(&p.config.BodyChecksum)
+	}
+
+	// Initialize schema validation middleware
+	if p.config.SchemaValidation.Enabled {
+		p.schemaValidationMiddleware, err = middleware.NewSchemaValidationMiddleware(&p.config.SchemaValidation)
+		if err != nil {
+			return fmt.Errorf("failed to create schema validation middleware: %w", err)
+		}
+		p.schemaValidationMiddleware.SetRouteMatcher(p.matchedRouteID)
+	}
+
+	// Initialize cache flush endpoint
+	p.cacheFlushHandler = p.nodeAdminHandler(http.HandlerFunc(p.handleCacheFlush))
+
+	// Initialize upstream diagnostics endpoint
+	p.diagnosticsHandler = p.nodeAdminHandler(http.HandlerFunc(p.handleUpstreamDiagnostics))
+
+	// Initialize routing table endpoint
+	p.routingTableHandler = p.nodeAdminHandler(http.HandlerFunc(p.handleRoutingTable))
+
+	// Initialize effective configuration endpoint
+	p.effectiveConfigHandler = p.nodeAdminHandler(http.HandlerFunc(p.handleEffectiveConfig))
+
+	// Initialize certificate renewal endpoint
+	p.acmeRenewHandler = p.nodeAdminHandler(http.HandlerFunc(p.handleACMERenew))
+
+	// Initialize route replay endpoint
+	p.routeReplayHandler = p.nodeAdminHandler(http.HandlerFunc(p.handleRouteReplay))
+
+	// Initialize rate limit overrides endpoint
+	p.rateLimitOverridesHandler = p.nodeAdminHandler(http.HandlerFunc(p.handleRateLimitOverrides))
+
+	// Initialize upstream debug logging and its endpoint
+	p.upstreamDebug, err = middleware.NewUpstreamDebugLogger(&p.config.Upstreams.DebugLog)
+	if err != nil {
+		return fmt.Errorf("failed to create upstream debug logger: %w", err)
+	}
+	p.upstreamDebugHandler = p.nodeAdminHandler(http.HandlerFunc(p.handleUpstreamDebug))
+
+	// Initialize health event stream
+	p.healthEventsHandler = p.nodeAdminHandler(http.HandlerFunc(p.handleHealthEvents))
+
 	// Initialize tracing middleware
 	if p.config.Tracing.Enabled {
 		p.tracingMiddleware, err = middleware.NewTracingMiddleware(&p.config.Tracing)
@@ -661,13 +1567,43 @@ func (p *Pipeline) initializeComponents() error {
 		}
 	}
 
+	// Report callouts of serverless functions and WASM plugins
+	if provider := p.getMetricsProvider(); provider != nil && (p.serverlessMiddleware != nil || p.wasmMiddleware != nil) {
+		calloutCalls, err := provider.NewCounterVec(metrics.MetricOptions{
+			Name:   "callouts_total",
+			Help:   "Total number of callouts of serverless functions and WASM plugins by source and result",
+			Labels: []string{"source", "result"},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create callout counter: %w", err)
+		}
+		calloutDuration, err := provider.NewHistogramVec(metrics.MetricOptions{
+			Name:    "callout_duration_seconds",
+			Help:    "Latency of executed callouts of serverless functions and WASM plugins by source",
+			Labels:  []string{"source"},
+			Buckets: metrics.GetDefaultBuckets("duration"),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create callout latency histogram: %w", err)
+		}
+		if p.serverlessMiddleware != nil {
+			p.serverlessMiddleware.SetCalloutMetrics(calloutCalls, calloutDuration)
+		}
+		if p.wasmMiddleware != nil {
+			p.wasmMiddleware.SetCalloutMetrics(calloutCalls, calloutDuration)
+		}
+	}
+
+	// Initialize WASM plugins endpoint
+	p.wasmPluginsHandler = p.nodeAdminHandler(http.HandlerFunc(p.handleWASMPlugins))
+
 	return nil
 }
 
 // convertToPassiveHealthConfig converts config to passive health config
 func (p *Pipeline) convertToPassiveHealthConfig() *health.PassiveHealthConfig {
 	if p.config.Upstreams.Defaults.HealthCheck.Passive.Enabled {
-		return &health.PassiveHealthConfig{
+		passiveConfig := &health.PassiveHealthConfig{
 			Enabled:              p.config.Upstreams.Defaults.HealthCheck.Passive.Enabled,
 			ConsecutiveFailures:  p.config.Upstreams.Defaults.HealthCheck.Passive.ConsecutiveFailures,
 			IsolationDuration:    p.config.Upstreams.Defaults.HealthCheck.Passive.IsolationDuration,
@@ -676,6 +1612,32 @@ func (p *Pipeline) convertToPassiveHealthConfig() *health.PassiveHealthConfig {
 			FailureStatusCodes:   p.config.Upstreams.Defaults.HealthCheck.Passive.FailureStatusCodes,
 			TimeoutAsFailure:     p.config.Upstreams.Defaults.HealthCheck.Passive.TimeoutAsFailure,
 		}
+
+		// Per-upstream thresholds override the defaults field by field
+		if len(p.config.Upstreams.Passive) > 0 {
+			passiveConfig.Upstreams = make(map[string]*health.PassiveHealthConfig, len(p.config.Upstreams.Passive))
+		}
+		for upstreamID, override := range p.config.Upstreams.Passive {
+			upstreamConfig := *passiveConfig
+			upstreamConfig.Upstreams = nil
+			if override.ConsecutiveFailures > 0 {
+				upstreamConfig.ConsecutiveFailures = override.ConsecutiveFailures
+			}
+			if override.IsolationDuration > 0 {
+				upstreamConfig.IsolationDuration = override.IsolationDuration
+			}
+			if override.ConsecutiveSuccesses > 0 {
+				upstreamConfig.ConsecutiveSuccesses = override.ConsecutiveSuccesses
+			}
+			if len(override.FailureStatusCodes) > 0 {
+				upstreamConfig.FailureStatusCodes = override.FailureStatusCodes
+			}
+			if override.TimeoutAsFailure != nil {
+				upstreamConfig.TimeoutAsFailure = *override.TimeoutAsFailure
+			}
+			passiveConfig.Upstreams[upstreamID] = &upstreamConfig
+		}
+		return passiveConfig
 	}
 	return nil
 }
@@ -704,8 +1666,11 @@ func (p *Pipeline) convertToRateLimitConfig() *ratelimit.Config {
 		identifierStrategy = ratelimit.IdentifierAPIKey
 	case "combined":
 		identifierStrategy = ratelimit.IdentifierCombined
-	default:
+	case "", "ip":
 		identifierStrategy = ratelimit.IdentifierIP
+	default:
+		// Composite identifier such as api_key+route_id
+		identifierStrategy = ratelimit.IdentifierStrategy(p.config.RateLimit.IdentifierStrategy)
 	}
 
 	return &ratelimit.Config{
@@ -720,12 +1685,26 @@ func (p *Pipeline) convertToRateLimitConfig() *ratelimit.Config {
 		SkipSuccessfulRequests: p.config.RateLimit.SkipSuccessful,
 		SkipFailedRequests:     p.config.RateLimit.SkipFailed,
 		CustomHeaders:          p.config.RateLimit.CustomHeaders,
+		Storage:                p.config.RateLimit.Storage,
+		RedisAddress:           p.config.RateLimit.Redis.Address,
+		RedisPassword:          p.config.RateLimit.Redis.Password,
+		RedisDB:                p.config.RateLimit.Redis.DB,
+		DynamoDB: ratelimit.DynamoDBConfig{
+			Table:           p.config.RateLimit.DynamoDB.Table,
+			Region:          p.config.RateLimit.DynamoDB.Region,
+			Endpoint:        p.config.RateLimit.DynamoDB.Endpoint,
+			AccessKeyID:     p.config.RateLimit.DynamoDB.AccessKeyID,
+			SecretAccessKey: p.config.RateLimit.DynamoDB.SecretAccessKey,
+			SessionToken:    p.config.RateLimit.DynamoDB.SessionToken,
+		},
 	}
 }
 
 // onHealthStatusChange handles health status changes from passive health checker
-func (p *Pipeline) onHealthStatusChange(upstreamID, targetKey string, healthy bool) {
-	log.Printf("Health status changed for %s in upstream %s: healthy=%v", targetKey, upstreamID, healthy)
+func (p *Pipeline) onHealthStatusChange(upstreamID, targetKey string, change health.HealthChange) {
+	healthy := change.Healthy
+	log.Printf("Health status changed for %s in upstream %s: healthy=%v reason=%s count=%d isolation=%v",
+		targetKey, upstreamID, healthy, change.Reason, change.Count, change.IsolationDuration)
 
 	// Extract host and port from targetKey (format: upstreamID:host:port)
 	parts := strings.Split(targetKey, ":")
@@ -738,93 +1717,164 @@ func (p *Pipeline) onHealthStatusChange(upstreamID, targetKey string, healthy bo
 
 		// Update load balancer
 		if err := p.UpdateTargetHealth(upstreamID, host, port, healthy); err != nil {
-			log.Printf("Failed to update target health in load balancer: %v", err)
+			log.Printf("Failed to update target health in load balancer after %s: %v", change.Reason, err)
+		}
+
+		if p.passiveHealthCounter != nil {
+			transition := "ejected"
+			if healthy {
+				transition = "recovered"
+			}
+			p.passiveHealthCounter.WithLabelValues(upstreamID, transition, change.Reason).Inc()
+		}
+
+		event := health.NewPassiveHealthStatusEvent(upstreamID, fmt.Sprintf("%s:%d", host, port), change)
+		p.publishTargetHealth(event)
+
+		// Notify health status webhook, delivery happens in the background
+		if p.healthWebhook != nil {
+			if err := p.healthWebhook.Send(health.HealthStatusEventType, event); err != nil {
+				log.Printf("Failed to queue health status webhook for %s: %v", targetKey, err)
+			}
 		}
 	}
 }
 
+// onActiveHealthChange publishes health transitions found by active health checks
+func (p *Pipeline) onActiveHealthChange(upstreamID string, target *types.Target, healthy bool) {
+	p.publishTargetHealth(health.NewHealthStatusEvent(upstreamID, fmt.Sprintf("%s:%d", target.Host, target.Port), healthy, "active"))
+}
+
 // buildMiddlewareChain builds the middleware chain
 func (p *Pipeline) buildMiddlewareChain() error {
-	p.middlewares = []Middleware{}
+	p.middlewares = []namedMiddleware{}
 
 	// Add tracing middleware (first to capture all requests in traces)
 	if p.config.Tracing.Enabled && p.tracingMiddleware != nil {
-		p.middlewares = append(p.middlewares, p.tracingMiddleware.Handler())
+		p.use("tracing", p.tracingMiddleware.Handler())
 	}
 
 	// Add access log middleware (second to log all requests)
 	if p.config.Logging.AccessLog.Enabled && p.accessLogMiddleware != nil {
-		p.middlewares = append(p.middlewares, p.accessLogMiddleware.Handler())
+		p.use("access_log", p.accessLogMiddleware.Handler())
 	}
 
 	// Add metrics middleware (early to capture all metrics)
 	if p.config.Metrics.Enabled && p.metricsMiddleware != nil {
-		p.middlewares = append(p.middlewares, p.metricsMiddleware.Handler())
+		p.use("metrics", p.metricsMiddleware.Handler())
+	}
+
+	// Add tap middleware (outside response-generating middlewares so their responses are captured too)
+	if p.config.Tap.Enabled && p.tapMiddleware != nil {
+		p.use("tap", p.tapMiddleware.Handler())
+	}
+
+	// Add security headers middleware (before response-generating middlewares so all responses get the headers)
+	if p.config.SecurityHeaders.Enabled && p.securityHeadersMiddleware != nil {
+		p.use("security_headers", p.securityHeadersMiddleware.Handler())
 	}
 
 	// Add CORS middleware (first in chain to handle preflight requests early)
 	if p.config.CORS.Enabled && p.corsMiddleware != nil {
-		p.middlewares = append(p.middlewares, p.corsMiddleware.Handler())
+		p.use("cors", p.corsMiddleware.Handler())
 	}
 
 	// Add header transform middleware (early in chain to transform headers before other processing)
 	if p.config.HeaderTransform.Enabled && p.headerTransformMiddleware != nil {
-		p.middlewares = append(p.middlewares, p.headerTransformMiddleware.Handler())
+		p.use("header_transform", p.headerTransformMiddleware.Handler())
 	}
 
 	// Add mock response middleware (early in chain to return mock responses before backend processing)
 	if p.config.MockResponse.Enabled && p.mockResponseMiddleware != nil {
-		p.middlewares = append(p.middlewares, p.mockResponseMiddleware.Handler())
+		p.use("mock_response", p.mockResponseMiddleware.Handler())
 	}
 
 	// Add gRPC-Web middleware (early in chain to handle gRPC-Web protocol conversion)
 	if p.config.GRPCWeb.Enabled && p.grpcWebMiddleware != nil {
-		p.middlewares = append(p.middlewares, p.grpcWebMiddleware.Handler())
+		p.use("grpc_web", p.grpcWebMiddleware.Handler())
 	}
 
 	// Add IP ACL middleware (after CORS for early IP-based rejection)
 	if p.config.IPACL.Enabled && p.ipaclMiddleware != nil {
-		p.middlewares = append(p.middlewares, p.ipaclMiddleware.Handler())
+		p.use("ip_acl", p.ipaclMiddleware.Handler())
 	}
 
 	// Add rate limiting middleware (after IP ACL, before auth to limit unauthenticated requests)
 	if p.config.RateLimit.Enabled && p.rateLimitMiddleware != nil {
-		p.middlewares = append(p.middlewares, p.rateLimitMiddleware.Handler())
+		p.use("rate_limit", p.rateLimitMiddleware.Handler())
 	}
 
 	// Add auth middleware (after rate limiting)
 	if p.config.Auth.Enabled && p.authMiddleware != nil {
-		p.middlewares = append(p.middlewares, p.authMiddleware.Handler())
+		p.use("auth", p.authMiddleware.Handler())
+	}
+
+	// Add auth phase plugins (directly after auth so they can make access decisions early)
+	if p.config.Serverless.Enabled && p.serverlessMiddleware != nil && p.serverlessMiddleware.HasPhase(config.PluginPhaseAuth) {
+		p.use("serverless", p.serverlessMiddleware.PhaseHandler(config.PluginPhaseAuth))
+	}
+	if p.config.WASM.Enabled && p.wasmMiddleware != nil && p.wasmMiddleware.HasPhase(config.PluginPhaseAuth) {
+		p.use("wasm", p.wasmMiddleware.PhaseHandler(config.PluginPhaseAuth))
+	}
+
+	// Add body checksum middleware (after auth so unauthenticated bodies aren't buffered)
+	if p.config.BodyChecksum.Enabled && p.bodyChecksumMiddleware != nil {
+		p.use("body_checksum", p.bodyChecksumMiddleware.Handler())
+	}
+
+	// Add schema validation middleware (after body checksums so bodies are verified before parsing)
+	if p.config.SchemaValidation.Enabled && p.schemaValidationMiddleware != nil {
+		p.use("schema_validation", p.schemaValidationMiddleware.Handler())
+	}
+
+	// Add experiment middleware (after auth so users can be bucketed by ID)
+	if p.config.Experiments.Enabled && p.experimentMiddleware != nil {
+		p.use("experiments", p.experimentMiddleware.Handler())
+	}
+
+	// Add idempotency middleware (after auth so keys are scoped to the consumer)
+	if p.config.Idempotency.Enabled && p.idempotencyMiddleware != nil {
+		p.use("idempotency", p.idempotencyMiddleware.Handler())
 	}
 
 	// Add aggregator middleware (after auth, before circuit breaker to handle aggregate requests)
 	if p.config.Aggregator.Enabled && p.aggregatorMiddleware != nil {
-		p.middlewares = append(p.middlewares, p.aggregatorMiddleware.Handler())
+		p.use("aggregator", p.aggregatorMiddleware.Handler())
 	}
 
 	// Add serverless middleware (after aggregator, before circuit breaker for request/response processing)
 	if p.config.Serverless.Enabled && p.serverlessMiddleware != nil {
-		p.middlewares = append(p.middlewares, p.serverlessMiddleware.Handler())
+		p.use("serverless", p.serverlessMiddleware.Handler())
 	}
 
 	// Add WASM middleware (after serverless, before circuit breaker for plugin processing)
 	if p.config.WASM.Enabled && p.wasmMiddleware != nil {
-		p.middlewares = append(p.middlewares, p.wasmMiddleware.Handler())
+		p.use("wasm", p.wasmMiddleware.Handler())
+	}
+
+	// Add response phase WASM plugins (after request phase plugins, wrapping the upstream response)
+	if p.config.WASM.Enabled && p.wasmMiddleware != nil && p.wasmMiddleware.HasPhase(config.PluginPhaseResponse) {
+		p.use("wasm", p.wasmMiddleware.PhaseHandler(config.PluginPhaseResponse))
 	}
 
 	// Add circuit breaker middleware (after auth, before actual request processing)
 	if p.config.CircuitBreaker.Enabled && p.circuitBreakerMiddleware != nil {
-		p.middlewares = append(p.middlewares, p.circuitBreakerMiddleware.Handler())
+		p.use("circuit_breaker", p.circuitBreakerMiddleware.Handler())
 	}
 
 	// Add traffic mirror middleware (last in chain, after all processing)
 	if p.config.TrafficMirror.Enabled && p.trafficMirrorMiddleware != nil {
-		p.middlewares = append(p.middlewares, p.trafficMirrorMiddleware.Handler())
+		p.use("traffic_mirror", p.trafficMirrorMiddleware.Handler())
 	}
 
 	return nil
 }
 
+// use appends a middleware to the chain
+func (p *Pipeline) use(name string, handler Middleware) {
+	p.middlewares = append(p.middlewares, namedMiddleware{name: name, handler: handler})
+}
+
 // getMetricsProvider returns the metrics provider from the middleware
 func (p *Pipeline) getMetricsProvider() metrics.Provider {
 	if p.metricsMiddleware == nil {
@@ -849,6 +1899,8 @@ func (p *Pipeline) createLoadBalancer() types.LoadBalancer {
 		return loadbalancer.NewCanaryBalancer(p.config)
 	case "weighted_round_robin":
 		return loadbalancer.NewWeightedRoundRobinBalancer(p.config)
+	case "weighted_random":
+		return loadbalancer.NewWeightedRandomBalancer(p.config)
 	case "ip_hash":
 		return loadbalancer.NewIPHashBalancer(p.config)
 	case "round_robin":
@@ -872,8 +1924,11 @@ func (p *Pipeline) selectTarget(upstream *types.Upstream, r *http.Request) (*typ
 // selectTargetWithIPHash 使用IP Hash负载均衡器选择目标
 func (p *Pipeline) selectTargetWithIPHash(lb *loadbalancer.IPHashBalancer, upstream *types.Upstream, r *http.Request) (*types.Target, error) {
 	// 提取客户端IP
-	clientIP := loadbalancer.ExtractClientIP(r)
+	return p.selectTargetByClientIP(upstream, loadbalancer.ExtractClientIP(r))
+}
 
+// selectTargetByClientIP 使用客户端IP哈希从健康的目标实例中选择目标
+func (p *Pipeline) selectTargetByClientIP(upstream *types.Upstream, clientIP string) (*types.Target, error) {
 	// 获取健康的目标实例
 	healthyTargets := make([]*types.Target, 0)
 	for _, target := range upstream.Targets {
@@ -935,6 +1990,15 @@ func (p *Pipeline) getUpstream(upstreamID string) *types.Upstream {
 		return upstream
 	}
 
+	// 尝试 WeightedRandomBalancer
+	if lb, ok := p.loadBalancer.(*loadbalancer.WeightedRandomBalancer); ok {
+		upstream, err := lb.GetUpstream(upstreamID)
+		if err != nil {
+			return nil
+		}
+		return upstream
+	}
+
 	// 尝试 IPHashBalancer
 	if lb, ok := p.loadBalancer.(*loadbalancer.IPHashBalancer); ok {
 		upstream, err := lb.GetUpstream(upstreamID)
@@ -947,14 +2011,30 @@ func (p *Pipeline) getUpstream(upstreamID string) *types.Upstream {
 	return nil
 }
 
-// AddUpstream adds an upstream to the load balancer
+// AddUpstream adds an upstream to the load balancer, or updates it and
+// drops the series of its removed targets
 func (p *Pipeline) AddUpstream(upstream *types.Upstream) error {
-	return p.loadBalancer.UpdateUpstream(upstream)
+	if err := p.loadBalancer.UpdateUpstream(upstream); err != nil {
+		return err
+	}
+
+	addrs := make([]string, len(upstream.Targets))
+	for i, target := range upstream.Targets {
+		addrs[i] = targetAddr(target)
+	}
+	p.pruneTargetSeries(upstream.ID, addrs)
+	return nil
 }
 
-// RemoveUpstream removes an upstream from the load balancer
+// RemoveUpstream removes an upstream from the load balancer and drops its
+// series
 func (p *Pipeline) RemoveUpstream(upstreamID string) error {
-	return p.loadBalancer.RemoveUpstream(upstreamID)
+	if err := p.loadBalancer.RemoveUpstream(upstreamID); err != nil {
+		return err
+	}
+
+	p.deleteUpstreamSeries(upstreamID)
+	return nil
 }
 
 // UpdateTargetHealth updates the health status of a target
@@ -974,6 +2054,11 @@ func (p *Pipeline) UpdateTargetHealth(upstreamID, targetHost string, targetPort
 		return lb.UpdateTargetHealth(upstreamID, targetHost, targetPort, healthy)
 	}
 
+	// 尝试 WeightedRandomBalancer
+	if lb, ok := p.loadBalancer.(*loadbalancer.WeightedRandomBalancer); ok {
+		return lb.UpdateTargetHealth(upstreamID, targetHost, targetPort, healthy)
+	}
+
 	// 尝试 IPHashBalancer
 	if lb, ok := p.loadBalancer.(*loadbalancer.IPHashBalancer); ok {
 		return lb.UpdateTargetHealth(upstreamID, targetHost, targetPort, healthy)
@@ -982,64 +2067,103 @@ func (p *Pipeline) UpdateTargetHealth(upstreamID, targetHost string, targetPort
 	return fmt.Errorf("load balancer does not support health updates")
 }
 
+// matchedRouteID returns the ID of the route matching the request, or ""
+func (p *Pipeline) matchedRouteID(r *http.Request) string {
+	route, err := p.router.Match(r)
+	if err != nil {
+		return ""
+	}
+	return route.ID
+}
+
+// matchedMetricsRoute returns the route matching the request for metrics
+// label extractors, or nil
+func (p *Pipeline) matchedMetricsRoute(r *http.Request) *middleware.MetricsRoute {
+	route, err := p.router.Match(r)
+	if err != nil || route == nil {
+		return nil
+	}
+	return &middleware.MetricsRoute{ID: route.ID, Paths: route.Paths, UpstreamID: route.UpstreamID}
+}
+
 // createHandler creates the core request handler
 func (p *Pipeline) createHandler() http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		startTime := time.Now()
 
+		// Middlewares may have used up the request's time
+		if p.deadlineExceeded(w, r) {
+			return
+		}
+
 		// Route matching
 		route, err := p.router.Match(r)
-		if err != nil {
-			p.handleError(w, r, http.StatusNotFound, "route not found")
-			return
+		if err != nil || !p.listenerProfile(r).servesRoute(route.ID) {
+			if p.serveRootPage(w, r) {
+				return
+			}
+			if route = p.handleNoRoute(w, r); route == nil {
+				return
+			}
 		}
 
 		// Add route ID to request context for circuit breaker
 		ctx := context.WithValue(r.Context(), "route_id", route.ID)
 		r = r.WithContext(ctx)
 
-		// Get upstream for the matched route
-		upstream := p.getUpstream(route.UpstreamID)
+		// Get upstream for the matched route, unless overridden by a trusted source
+		upstream := p.getUpstream(p.upstreamIDFor(r, route.UpstreamID))
 		if upstream == nil {
 			p.handleError(w, r, http.StatusBadGateway, "upstream not found")
 			return
 		}
 
-		// Load balancing - select target from upstream
-		target, err := p.selectTarget(upstream, r)
+		// Load balancing - select target from upstream, failing over to the
+		// route's fallback upstreams if it has no available target
+		target, err := p.selectAffinityTarget(w, r, upstream)
+		if err != nil && len(route.FallbackUpstreams) > 0 {
+			if fallback, fallbackTarget, ok := p.selectFallbackTarget(w, r, route, upstream.ID); ok {
+				upstream, target, err = fallback, fallbackTarget, nil
+			}
+		}
 		if err != nil {
 			p.handleError(w, r, http.StatusServiceUnavailable, fmt.Sprintf("load balancer error: %v", err))
 			return
 		}
 
-		// Set target in request context for reverse proxy
+		// Set target and upstream ID in request context for reverse proxy
 		r = SetTarget(r, target)
+		r = r.WithContext(context.WithValue(r.Context(), "upstream_id", upstream.ID))
 
 		// Wrap response writer to capture status code
 		wrapper := NewResponseWrapper(w)
 
+		// Wait for a slot if the upstream is at its concurrency limit
+		release, ok := p.acquireUpstreamSlot(w, r, upstream.ID)
+		if !ok {
+			return
+		}
+
 		// Reverse proxy
-		p.reverseProxy.ServeHTTP(wrapper, r)
+		r, failure := withProxyFailure(r)
+		done := p.trackTargetInFlight(upstream.ID, target)
+		p.upstreamDebug.Serve(p.reverseProxy, wrapper, r, upstream.ID)
+		done()
+		release()
+
+		if failure.tls != nil {
+			p.recordUpstreamTLSFailure(upstream.ID, target, failure.tls)
+		}
 
 		// Record request result for passive health checking
 		if p.passiveHealthChecker != nil {
-			// Check if there was a proxy error
-			var proxyError error
-			var isTimeout bool
-			if err, ok := r.Context().Value("proxy_error").(error); ok {
-				proxyError = err
-			}
-			if timeout, ok := r.Context().Value("proxy_timeout").(bool); ok {
-				isTimeout = timeout
-			}
-
 			result := &health.RequestResult{
 				UpstreamID: upstream.ID,
 				Target:     target,
 				StatusCode: wrapper.StatusCode(),
-				Error:      proxyError,
+				Error:      failure.err,
 				Duration:   wrapper.Duration(),
-				IsTimeout:  isTimeout,
+				IsTimeout:  failure.isTimeout,
 				Timestamp:  startTime,
 			}
 			p.passiveHealthChecker.RecordRequest(result)
@@ -1047,6 +2171,31 @@ func (p *Pipeline) createHandler() http.Handler {
 	})
 }
 
+// resolveReroute selects a target of the upstream named by a dynamic routing
+// header, honoring session affinity pins, and records the re-route
+func (p *Pipeline) resolveReroute(r *http.Request, upstreamID string) (*types.Target, error) {
+	upstream := p.getUpstream(upstreamID)
+	if upstream == nil {
+		return nil, fmt.Errorf("upstream %s not found", upstreamID)
+	}
+
+	target, err := p.selectPinnedTarget(r, upstream)
+	if err != nil {
+		return nil, fmt.Errorf("load balancer error: %w", err)
+	}
+
+	p.mu.Lock()
+	p.rerouteCount++
+	p.mu.Unlock()
+
+	if p.rerouteCounter != nil {
+		from, _ := r.Context().Value("upstream_id").(string)
+		p.rerouteCounter.WithLabelValues(from, upstreamID).Inc()
+	}
+
+	return target, nil
+}
+
 // handleError handles errors
 func (p *Pipeline) handleError(w http.ResponseWriter, r *http.Request, status int, message string) {
 	p.mu.Lock()
@@ -0,0 +1,54 @@
+package proxy
+
+import (
+	"testing"
+
+	"github.com/songzhibin97/stargate/internal/config"
+)
+
+func TestPipeline_ReloadKeepsRunningConfigOnError(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.SchemaValidation.Enabled = true
+
+	pipeline, err := NewPipeline(cfg, nil)
+	if err != nil {
+		t.Fatalf("Failed to create pipeline: %v", err)
+	}
+	defer pipeline.Stop()
+
+	resolver := pipeline.clientIPResolver
+
+	invalidProxies := &config.Config{}
+	invalidProxies.SchemaValidation.Enabled = true
+	invalidProxies.Server.TrustedProxies = []string{"not-a-cidr"}
+
+	// The trusted proxies are valid, but a later component fails
+	invalidSchema := &config.Config{}
+	invalidSchema.SchemaValidation.Enabled = true
+	invalidSchema.Server.TrustedProxies = []string{"10.0.0.0/8"}
+	invalidSchema.SchemaValidation.Routes = map[string]config.RouteSchemaConfig{
+		"orders": {Request: map[string]interface{}{"$ref": "#"}},
+	}
+
+	for _, reloaded := range []*config.Config{invalidProxies, invalidSchema} {
+		if err := pipeline.Reload(reloaded); err == nil {
+			t.Fatal("Expected reload to fail")
+		}
+		if pipeline.config != cfg {
+			t.Error("Expected the running configuration to stay in use")
+		}
+		if pipeline.clientIPResolver != resolver {
+			t.Error("Expected the running client IP resolver to stay in use")
+		}
+	}
+
+	valid := &config.Config{}
+	valid.SchemaValidation.Enabled = true
+	valid.Server.TrustedProxies = []string{"10.0.0.0/8"}
+	if err := pipeline.Reload(valid); err != nil {
+		t.Fatalf("Failed to reload: %v", err)
+	}
+	if pipeline.config != valid || pipeline.clientIPResolver == resolver {
+		t.Error("Expected the reloaded configuration to be applied")
+	}
+}
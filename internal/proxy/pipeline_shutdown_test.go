@@ -0,0 +1,105 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/songzhibin97/stargate/internal/config"
+)
+
+func TestPipeline_ShutdownWaitsForInFlightRequests(t *testing.T) {
+	p := &Pipeline{}
+	p.inFlight = 1
+
+	done := make(chan error, 1)
+	go func() {
+		done <- p.Shutdown(context.Background())
+	}()
+
+	// New requests are rejected while draining
+	time.Sleep(50 * time.Millisecond)
+	rr := httptest.NewRecorder()
+	p.ServeHTTP(rr, httptest.NewRequest("GET", "/test", nil))
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503 while draining, got %d", rr.Code)
+	}
+	if rr.Header().Get("Connection") != "close" {
+		t.Errorf("Expected Connection: close while draining, got %q", rr.Header().Get("Connection"))
+	}
+
+	select {
+	case <-done:
+		t.Fatal("Expected shutdown to wait for the in-flight request")
+	default:
+	}
+
+	// Complete the in-flight request
+	p.mu.Lock()
+	p.inFlight--
+	p.mu.Unlock()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Expected clean shutdown, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for shutdown")
+	}
+
+	// Later calls are no-ops
+	if err := p.Shutdown(context.Background()); err != nil {
+		t.Errorf("Expected repeated shutdown to return nil, got %v", err)
+	}
+}
+
+func TestPipeline_ShutdownDeadline(t *testing.T) {
+	p := &Pipeline{}
+	p.inFlight = 2
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	err := p.Shutdown(ctx)
+	if err == nil {
+		t.Fatal("Expected error when requests are still in flight at the deadline")
+	}
+	if !strings.Contains(err.Error(), "drain in-flight requests") || !strings.Contains(err.Error(), "2 requests still in flight") {
+		t.Errorf("Expected error to describe the drain step, got %v", err)
+	}
+}
+
+func TestPipeline_LameDuckHealth(t *testing.T) {
+	p := &Pipeline{config: &config.Config{Server: config.ServerConfig{HealthPath: "/health"}}}
+
+	health := func() int {
+		rr := httptest.NewRecorder()
+		p.ServeHTTP(rr, httptest.NewRequest("GET", "/health", nil))
+		return rr.Code
+	}
+
+	if code := health(); code != http.StatusOK {
+		t.Errorf("Expected status 200 while ready, got %d", code)
+	}
+
+	// Lame duck flips readiness while requests are still served
+	p.EnterLameDuck()
+	if code := health(); code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503 during lame duck, got %d", code)
+	}
+	if p.draining {
+		t.Error("Expected lame duck not to start draining")
+	}
+
+	// Health stays not ready, rather than rejected, while draining
+	if err := p.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Expected clean shutdown, got %v", err)
+	}
+	if code := health(); code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503 while draining, got %d", code)
+	}
+}
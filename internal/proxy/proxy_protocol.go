@@ -0,0 +1,277 @@
+package proxy
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/songzhibin97/stargate/internal/clientip"
+	"github.com/songzhibin97/stargate/internal/config"
+)
+
+// defaultProxyProtocolHeaderTimeout is the default time allowed for reading a PROXY protocol header
+const defaultProxyProtocolHeaderTimeout = 5 * time.Second
+
+var (
+	// proxyProtocolV1Prefix starts a PROXY protocol v1 header
+	proxyProtocolV1Prefix = []byte("PROXY ")
+	// proxyProtocolV2Signature starts a PROXY protocol v2 header
+	proxyProtocolV2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+	// ErrInvalidProxyProtocolHeader is returned for malformed PROXY protocol headers
+	ErrInvalidProxyProtocolHeader = errors.New("invalid PROXY protocol header")
+)
+
+const (
+	// proxyProtocolV1MaxLength is the maximum length of a v1 header including CRLF
+	proxyProtocolV1MaxLength = 107
+	// proxyProtocolV2HeaderLength is the length of the fixed v2 header
+	proxyProtocolV2HeaderLength = 16
+)
+
+// proxyProtocolListener accepts connections carrying a PROXY protocol header
+// and reports the client address from the header as the connection's remote
+// address. Headers are only parsed on connections from trusted sources;
+// connections from other sources are passed through unchanged.
+type proxyProtocolListener struct {
+	net.Listener
+	trusted       []*net.IPNet
+	headerTimeout time.Duration
+}
+
+// newProxyProtocolListener wraps a listener to accept the PROXY protocol
+func newProxyProtocolListener(listener net.Listener, cfg config.ProxyProtocolConfig) (net.Listener, error) {
+	trusted, err := clientip.ParseNetworks(cfg.TrustedSources)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse trusted sources: %w", err)
+	}
+
+	headerTimeout := cfg.HeaderTimeout
+	if headerTimeout <= 0 {
+		headerTimeout = defaultProxyProtocolHeaderTimeout
+	}
+
+	return &proxyProtocolListener{
+		Listener:      listener,
+		trusted:       trusted,
+		headerTimeout: headerTimeout,
+	}, nil
+}
+
+// Accept waits for and returns the next connection
+func (l *proxyProtocolListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+
+	if !l.isTrusted(conn.RemoteAddr()) {
+		return conn, nil
+	}
+
+	return &proxyProtocolConn{
+		Conn:          conn,
+		reader:        bufio.NewReader(conn),
+		headerTimeout: l.headerTimeout,
+	}, nil
+}
+
+// isTrusted reports whether addr may send a PROXY protocol header
+func (l *proxyProtocolListener) isTrusted(addr net.Addr) bool {
+	tcpAddr, ok := addr.(*net.TCPAddr)
+	if !ok {
+		return false
+	}
+
+	for _, network := range l.trusted {
+		if network.Contains(tcpAddr.IP) {
+			return true
+		}
+	}
+	return false
+}
+
+// proxyProtocolConn is a connection from a trusted source. The header is read
+// on first use, which happens in the connection's own goroutine rather than in
+// the accept loop, so a slow sender can't block other connections.
+type proxyProtocolConn struct {
+	net.Conn
+	reader        *bufio.Reader
+	headerTimeout time.Duration
+
+	once       sync.Once
+	remoteAddr net.Addr
+	localAddr  net.Addr
+	err        error
+}
+
+// Read reads data following the PROXY protocol header
+func (c *proxyProtocolConn) Read(b []byte) (int, error) {
+	c.once.Do(c.readHeader)
+	if c.err != nil {
+		return 0, c.err
+	}
+	return c.reader.Read(b)
+}
+
+// RemoteAddr returns the client address from the header, or the connection's
+// address when the header carries none
+func (c *proxyProtocolConn) RemoteAddr() net.Addr {
+	c.once.Do(c.readHeader)
+	if c.remoteAddr != nil {
+		return c.remoteAddr
+	}
+	return c.Conn.RemoteAddr()
+}
+
+// LocalAddr returns the destination address from the header, or the
+// connection's address when the header carries none
+func (c *proxyProtocolConn) LocalAddr() net.Addr {
+	c.once.Do(c.readHeader)
+	if c.localAddr != nil {
+		return c.localAddr
+	}
+	return c.Conn.LocalAddr()
+}
+
+// readHeader reads the PROXY protocol header if the connection starts with one
+func (c *proxyProtocolConn) readHeader() {
+	c.Conn.SetReadDeadline(time.Now().Add(c.headerTimeout))
+	defer c.Conn.SetReadDeadline(time.Time{})
+
+	// Look at the start of the connection without consuming it
+	prefix, err := c.reader.Peek(len(proxyProtocolV1Prefix))
+	if err != nil {
+		if err != io.EOF {
+			c.err = err
+		}
+		return
+	}
+
+	if bytes.Equal(prefix, proxyProtocolV1Prefix) {
+		c.remoteAddr, c.localAddr, c.err = readProxyProtocolV1(c.reader)
+		return
+	}
+
+	prefix, err = c.reader.Peek(len(proxyProtocolV2Signature))
+	if err == nil && bytes.Equal(prefix, proxyProtocolV2Signature) {
+		c.remoteAddr, c.localAddr, c.err = readProxyProtocolV2(c.reader)
+	}
+}
+
+// readProxyProtocolV1 reads a text v1 header such as
+// "PROXY TCP4 192.0.2.1 198.51.100.1 56324 443\r\n"
+func readProxyProtocolV1(reader *bufio.Reader) (net.Addr, net.Addr, error) {
+	var line []byte
+	for len(line) < proxyProtocolV1MaxLength {
+		b, err := reader.ReadByte()
+		if err != nil {
+			return nil, nil, err
+		}
+		line = append(line, b)
+		if b == '\n' {
+			break
+		}
+	}
+	if !bytes.HasSuffix(line, []byte("\r\n")) {
+		return nil, nil, ErrInvalidProxyProtocolHeader
+	}
+
+	fields := strings.Fields(string(line[:len(line)-2]))
+	if len(fields) < 2 {
+		return nil, nil, ErrInvalidProxyProtocolHeader
+	}
+
+	switch fields[1] {
+	case "UNKNOWN":
+		// Sender couldn't determine the addresses, use the connection's
+		return nil, nil, nil
+	case "TCP4", "TCP6":
+	default:
+		return nil, nil, ErrInvalidProxyProtocolHeader
+	}
+
+	if len(fields) != 6 {
+		return nil, nil, ErrInvalidProxyProtocolHeader
+	}
+
+	source, err := parseProxyProtocolV1Addr(fields[2], fields[4])
+	if err != nil {
+		return nil, nil, err
+	}
+	destination, err := parseProxyProtocolV1Addr(fields[3], fields[5])
+	if err != nil {
+		return nil, nil, err
+	}
+	return source, destination, nil
+}
+
+// parseProxyProtocolV1Addr parses an address and port of a v1 header
+func parseProxyProtocolV1Addr(host, port string) (*net.TCPAddr, error) {
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return nil, ErrInvalidProxyProtocolHeader
+	}
+	portNumber, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return nil, ErrInvalidProxyProtocolHeader
+	}
+	return &net.TCPAddr{IP: ip, Port: int(portNumber)}, nil
+}
+
+// readProxyProtocolV2 reads a binary v2 header
+func readProxyProtocolV2(reader *bufio.Reader) (net.Addr, net.Addr, error) {
+	header := make([]byte, proxyProtocolV2HeaderLength)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		return nil, nil, err
+	}
+
+	versionCommand := header[12]
+	if versionCommand>>4 != 2 {
+		return nil, nil, ErrInvalidProxyProtocolHeader
+	}
+
+	payload := make([]byte, binary.BigEndian.Uint16(header[14:16]))
+	if _, err := io.ReadFull(reader, payload); err != nil {
+		return nil, nil, err
+	}
+
+	switch versionCommand & 0x0F {
+	case 0x0:
+		// LOCAL command, e.g. load balancer health checks
+		return nil, nil, nil
+	case 0x1:
+		// PROXY command
+	default:
+		return nil, nil, ErrInvalidProxyProtocolHeader
+	}
+
+	// Address family and transport protocol, TLVs after the addresses are ignored
+	switch header[13] {
+	case 0x11: // TCP over IPv4
+		if len(payload) < 12 {
+			return nil, nil, ErrInvalidProxyProtocolHeader
+		}
+		return &net.TCPAddr{IP: net.IP(payload[0:4]), Port: int(binary.BigEndian.Uint16(payload[8:10]))},
+			&net.TCPAddr{IP: net.IP(payload[4:8]), Port: int(binary.BigEndian.Uint16(payload[10:12]))},
+			nil
+	case 0x21: // TCP over IPv6
+		if len(payload) < 36 {
+			return nil, nil, ErrInvalidProxyProtocolHeader
+		}
+		return &net.TCPAddr{IP: net.IP(payload[0:16]), Port: int(binary.BigEndian.Uint16(payload[32:34]))},
+			&net.TCPAddr{IP: net.IP(payload[16:32]), Port: int(binary.BigEndian.Uint16(payload[34:36]))},
+			nil
+	default:
+		// Unsupported family, use the connection's addresses
+		return nil, nil, nil
+	}
+}
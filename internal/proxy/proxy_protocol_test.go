@@ -0,0 +1,164 @@
+package proxy
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/songzhibin97/stargate/internal/config"
+)
+
+// startProxyProtocolServer serves the remote address of each request on a
+// listener accepting the PROXY protocol from trustedSources
+func startProxyProtocolServer(t *testing.T, trustedSources []string) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+
+	proxyListener, err := newProxyProtocolListener(listener, config.ProxyProtocolConfig{
+		Enabled:        true,
+		TrustedSources: trustedSources,
+		HeaderTimeout:  time.Second,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create proxy protocol listener: %v", err)
+	}
+
+	server := &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, r.RemoteAddr)
+		}),
+	}
+	go server.Serve(proxyListener)
+	t.Cleanup(func() { server.Close() })
+
+	return listener.Addr().String()
+}
+
+// requestWithHeader sends a request preceded by header and returns the
+// response status and the remote address the server saw
+func requestWithHeader(t *testing.T, address string, header []byte) (int, string, error) {
+	conn, err := net.Dial("tcp", address)
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	conn.Write(header)
+	io.WriteString(conn, "GET / HTTP/1.1\r\nHost: test\r\nConnection: close\r\n\r\n")
+
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(body), err
+}
+
+// proxyProtocolV2Header builds a v2 PROXY header for TCP over IPv4
+func proxyProtocolV2Header(source, destination net.IP, sourcePort, destinationPort uint16) []byte {
+	header := append([]byte{}, proxyProtocolV2Signature...)
+	header = append(header, 0x21, 0x11, 0, 12)
+	header = append(header, source.To4()...)
+	header = append(header, destination.To4()...)
+	ports := make([]byte, 4)
+	binary.BigEndian.PutUint16(ports[0:2], sourcePort)
+	binary.BigEndian.PutUint16(ports[2:4], destinationPort)
+	return append(header, ports...)
+}
+
+func TestProxyProtocolListener_TrustedSource(t *testing.T) {
+	address := startProxyProtocolServer(t, []string{"127.0.0.0/8"})
+
+	tests := []struct {
+		name     string
+		header   []byte
+		expected string
+	}{
+		{
+			name:     "v1 TCP4",
+			header:   []byte("PROXY TCP4 203.0.113.7 10.0.0.1 51234 443\r\n"),
+			expected: "203.0.113.7:51234",
+		},
+		{
+			name:     "v1 TCP6",
+			header:   []byte("PROXY TCP6 2001:db8::1 2001:db8::2 51234 443\r\n"),
+			expected: "[2001:db8::1]:51234",
+		},
+		{
+			name:     "v2 TCP4",
+			header:   proxyProtocolV2Header(net.ParseIP("198.51.100.9"), net.ParseIP("10.0.0.1"), 40000, 443),
+			expected: "198.51.100.9:40000",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, remoteAddr, err := requestWithHeader(t, address, tt.header)
+			if err != nil {
+				t.Fatalf("Request failed: %v", err)
+			}
+			if remoteAddr != tt.expected {
+				t.Errorf("Expected remote address %s, got %s", tt.expected, remoteAddr)
+			}
+		})
+	}
+
+	// Connections without a header keep their own address
+	_, remoteAddr, err := requestWithHeader(t, address, nil)
+	if err != nil {
+		t.Fatalf("Request without header failed: %v", err)
+	}
+	if host, _, _ := net.SplitHostPort(remoteAddr); host != "127.0.0.1" {
+		t.Errorf("Expected connection address without header, got %s", remoteAddr)
+	}
+
+	// Malformed headers are rejected
+	status, _, err := requestWithHeader(t, address, []byte("PROXY TCP4 not-an-ip 10.0.0.1 1 2\r\n"))
+	if err == nil && status == http.StatusOK {
+		t.Error("Expected malformed header to be rejected")
+	}
+}
+
+func TestProxyProtocolListener_UntrustedSource(t *testing.T) {
+	address := startProxyProtocolServer(t, []string{"10.0.0.1"})
+
+	// Headers from untrusted sources are not parsed, so a spoofed header
+	// makes the request invalid instead of changing the client address
+	_, remoteAddr, err := requestWithHeader(t, address, []byte("PROXY TCP4 203.0.113.7 10.0.0.1 51234 443\r\n"))
+	if err == nil && remoteAddr == "203.0.113.7:51234" {
+		t.Error("Expected spoofed header from untrusted source to be ignored")
+	}
+
+	_, remoteAddr, err = requestWithHeader(t, address, nil)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	if host, _, _ := net.SplitHostPort(remoteAddr); host != "127.0.0.1" {
+		t.Errorf("Expected connection address, got %s", remoteAddr)
+	}
+}
+
+func TestNewProxyProtocolListener_InvalidTrustedSource(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer listener.Close()
+
+	_, err = newProxyProtocolListener(listener, config.ProxyProtocolConfig{
+		Enabled:        true,
+		TrustedSources: []string{"not-a-cidr"},
+	})
+	if err == nil {
+		t.Error("Expected error for invalid trusted source")
+	}
+}
@@ -197,8 +197,12 @@ func TestPipeline_RateLimitDisabled(t *testing.T) {
 }
 
 func TestPipeline_RateLimitMultipleClients(t *testing.T) {
-	// Create configuration with rate limiting enabled
+	// Create configuration with rate limiting enabled, trusting the test
+	// client as a proxy so X-Forwarded-For identifies the clients
 	cfg := &config.Config{
+		Server: config.ServerConfig{
+			TrustedProxies: []string{"127.0.0.1"},
+		},
 		Proxy: config.ProxyConfig{
 			BufferSize:            32768,
 			ConnectTimeout:        5 * time.Second,
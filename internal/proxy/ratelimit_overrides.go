@@ -0,0 +1,213 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/songzhibin97/stargate/internal/middleware"
+	"github.com/songzhibin97/stargate/internal/ratelimit"
+)
+
+// maxRateLimitOverrideTTL caps how long a rate limit override lasts
+const maxRateLimitOverrideTTL = 7 * 24 * time.Hour
+
+// RateLimitOverrideRequest sets a temporary rate limit override of a client
+type RateLimitOverrideRequest struct {
+	Identifier  string `json:"identifier"`             // ip:<address>, api_key:<key> or user:<id>
+	Action      string `json:"action"`                 // block or limit
+	Status      int    `json:"status,omitempty"`       // Status of blocked requests, 403 (default) or 429
+	MaxRequests int    `json:"max_requests,omitempty"` // Requests allowed per window by limit overrides
+	Window      string `json:"window,omitempty"`       // Window of limit overrides, such as "1m"
+	TTL         string `json:"ttl"`                    // How long the override lasts, such as "30m"
+	Reason      string `json:"reason,omitempty"`       // Returned to the client with rejected requests
+}
+
+// RateLimitOverride is a rate limit override in effect
+type RateLimitOverride struct {
+	Identifier  string    `json:"identifier"`
+	Action      string    `json:"action"`
+	Status      int       `json:"status,omitempty"`
+	MaxRequests int       `json:"max_requests,omitempty"`
+	Window      string    `json:"window,omitempty"`
+	Reason      string    `json:"reason,omitempty"`
+	CreatedBy   string    `json:"created_by,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+// RateLimitOverridesResponse is the response listing the rate limit overrides
+type RateLimitOverridesResponse struct {
+	Overrides []RateLimitOverride `json:"overrides"`
+	Total     int                 `json:"total"`
+}
+
+// rateLimitOverridesPath returns the path of the rate limit overrides
+// endpoint, or "" if the REST Admin API is disabled
+func (p *Pipeline) rateLimitOverridesPath() string {
+	return p.nodeAdminPath("/ratelimit/overrides")
+}
+
+// handleRateLimitOverrides lists (GET) and sets (POST) the rate limit
+// overrides of clients, and deletes one with DELETE on
+// <path>/<identifier>. Overrides are kept in the rate limit backend, so they
+// apply on every node sharing it, and are recorded in the audit log.
+func (p *Pipeline) handleRateLimitOverrides(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if p.rateLimitMiddleware == nil {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]string{"error": "rate limiting is not enabled"})
+		return
+	}
+	overrides := p.rateLimitMiddleware.Overrides()
+
+	identifier := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, p.rateLimitOverridesPath()), "/")
+	if identifier != "" {
+		if r.Method != http.MethodDelete {
+			w.Header().Set("Allow", http.MethodDelete)
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			json.NewEncoder(w).Encode(map[string]string{"error": "method not allowed"})
+			return
+		}
+
+		deleted, err := overrides.Delete(r.Context(), identifier)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+		if !deleted {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]string{"error": "override not found"})
+			return
+		}
+
+		p.auditLogger.Log(middleware.AuditEntry{
+			Action: "ratelimit.override.delete",
+			Target: ratelimit.MaskIdentifier(identifier),
+			Caller: r.RemoteAddr,
+		})
+		log.Printf("Rate limit override of %s deleted by %s", ratelimit.MaskIdentifier(identifier), r.RemoteAddr)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		list, err := overrides.List(r.Context())
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+		response := RateLimitOverridesResponse{Overrides: make([]RateLimitOverride, 0, len(list)), Total: len(list)}
+		for _, override := range list {
+			response.Overrides = append(response.Overrides, newRateLimitOverride(override))
+		}
+		json.NewEncoder(w).Encode(response)
+	case http.MethodPost:
+		var request RateLimitOverrideRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&request); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": fmt.Sprintf("invalid request body: %v", err)})
+			return
+		}
+		override, err := parseRateLimitOverride(request)
+		if err == nil {
+			override.CreatedBy = r.RemoteAddr
+			err = override.Validate()
+		}
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+		if err := overrides.Set(r.Context(), override); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+
+		details := map[string]string{
+			"ttl":        request.TTL,
+			"expires_at": override.ExpiresAt.UTC().Format(time.RFC3339),
+		}
+		if override.Action == ratelimit.OverrideBlock {
+			details["status"] = strconv.Itoa(override.Status)
+		} else {
+			details["max_requests"] = strconv.Itoa(override.MaxRequests)
+			details["window"] = override.Window.String()
+		}
+		if override.Reason != "" {
+			details["reason"] = override.Reason
+		}
+		p.auditLogger.Log(middleware.AuditEntry{
+			Action:  "ratelimit.override." + override.Action,
+			Target:  ratelimit.MaskIdentifier(override.Identifier),
+			Caller:  r.RemoteAddr,
+			Details: details,
+		})
+		log.Printf("Rate limit override %s of %s set by %s until %s", override.Action, ratelimit.MaskIdentifier(override.Identifier), r.RemoteAddr, override.ExpiresAt.Format(time.RFC3339))
+
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(newRateLimitOverride(override))
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(map[string]string{"error": "method not allowed"})
+	}
+}
+
+// parseRateLimitOverride builds the override of a request, expiring after
+// its TTL
+func parseRateLimitOverride(request RateLimitOverrideRequest) (*ratelimit.Override, error) {
+	ttl, err := time.ParseDuration(request.TTL)
+	if err != nil || ttl <= 0 {
+		return nil, fmt.Errorf("ttl must be a positive duration, such as 30m")
+	}
+	if ttl > maxRateLimitOverrideTTL {
+		return nil, fmt.Errorf("ttl must be at most %v", maxRateLimitOverrideTTL)
+	}
+
+	var window time.Duration
+	if request.Window != "" {
+		if window, err = time.ParseDuration(request.Window); err != nil {
+			return nil, fmt.Errorf("invalid window: %v", err)
+		}
+	}
+
+	now := time.Now()
+	return &ratelimit.Override{
+		Identifier:  request.Identifier,
+		Action:      request.Action,
+		Status:      request.Status,
+		MaxRequests: request.MaxRequests,
+		Window:      window,
+		Reason:      request.Reason,
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(ttl),
+	}, nil
+}
+
+// newRateLimitOverride returns the Admin API view of an override
+func newRateLimitOverride(override *ratelimit.Override) RateLimitOverride {
+	view := RateLimitOverride{
+		Identifier:  override.Identifier,
+		Action:      override.Action,
+		Status:      override.Status,
+		MaxRequests: override.MaxRequests,
+		Reason:      override.Reason,
+		CreatedBy:   override.CreatedBy,
+		CreatedAt:   override.CreatedAt,
+		ExpiresAt:   override.ExpiresAt,
+	}
+	if override.Window > 0 {
+		view.Window = override.Window.String()
+	}
+	return view
+}
@@ -0,0 +1,127 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/songzhibin97/stargate/internal/config"
+	"github.com/songzhibin97/stargate/internal/middleware"
+	"github.com/songzhibin97/stargate/internal/ratelimit"
+)
+
+func TestPipeline_RateLimitOverrides(t *testing.T) {
+	auditPath := filepath.Join(t.TempDir(), "audit.log")
+
+	cfg := &config.Config{}
+	cfg.AdminAPI.REST = config.RESTConfig{Enabled: true, Prefix: "/api/v1"}
+	cfg.AdminAPI.Auth = config.AuthConfig{
+		Enabled: true,
+		APIKey:  config.APIKeyConfig{Header: "X-Admin-Key", Keys: []string{"secret"}},
+	}
+
+	pipeline, err := NewPipeline(cfg, nil)
+	if err != nil {
+		t.Fatalf("Failed to create pipeline: %v", err)
+	}
+	defer pipeline.Stop()
+
+	pipeline.auditLogger, err = middleware.NewAuditLogger(&config.AuditLogConfig{Enabled: true, Output: auditPath})
+	if err != nil {
+		t.Fatalf("Failed to create audit logger: %v", err)
+	}
+
+	send := func(method, target, key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		if key != "" {
+			req.Header.Set("X-Admin-Key", key)
+		}
+		rr := httptest.NewRecorder()
+		pipeline.ServeHTTP(rr, req)
+		return rr
+	}
+
+	if rr := send("GET", "/_stargate/admin/ratelimit/overrides", "secret", ""); rr.Code != http.StatusConflict {
+		t.Errorf("Expected status 409 without rate limiting, got %d", rr.Code)
+	}
+
+	pipeline.rateLimitMiddleware, err = ratelimit.NewMiddleware(&ratelimit.Config{
+		Enabled:            true,
+		Strategy:           ratelimit.StrategyFixedWindow,
+		IdentifierStrategy: ratelimit.IdentifierIP,
+		WindowSize:         time.Minute,
+		MaxRequests:        100,
+		CleanupInterval:    time.Minute,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create rate limit middleware: %v", err)
+	}
+
+	block := `{"identifier":"api_key:abcdef123","action":"block","ttl":"30m","reason":"abuse"}`
+	if rr := send("POST", "/_stargate/admin/ratelimit/overrides", "", block); rr.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 without credentials, got %d", rr.Code)
+	}
+	for _, body := range []string{
+		`{"identifier":"api_key:abcdef123","action":"block"}`,
+		`{"identifier":"api_key:abcdef123","action":"block","ttl":"720h"}`,
+		`{"identifier":"host:example.com","action":"block","ttl":"30m"}`,
+		`{"identifier":"ip:10.0.0.1","action":"limit","ttl":"30m"}`,
+	} {
+		if rr := send("POST", "/_stargate/admin/ratelimit/overrides", "secret", body); rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected status 400 for %s, got %d", body, rr.Code)
+		}
+	}
+
+	rr := send("POST", "/_stargate/admin/ratelimit/overrides", "secret", block)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var created RateLimitOverride
+	json.NewDecoder(rr.Body).Decode(&created)
+	if created.Status != http.StatusForbidden || time.Until(created.ExpiresAt) < 29*time.Minute {
+		t.Errorf("Expected a 403 block expiring in 30m, got %+v", created)
+	}
+
+	limit := `{"identifier":"ip:10.0.0.1","action":"limit","max_requests":10,"window":"1m","ttl":"1h"}`
+	if rr := send("POST", "/_stargate/admin/ratelimit/overrides", "secret", limit); rr.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	rr = send("GET", "/_stargate/admin/ratelimit/overrides", "secret", "")
+	var listed RateLimitOverridesResponse
+	json.NewDecoder(rr.Body).Decode(&listed)
+	if listed.Total != 2 || listed.Overrides[0].Identifier != "api_key:abcdef123" || listed.Overrides[1].Window != "1m0s" {
+		t.Errorf("Expected both overrides listed, got %+v", listed)
+	}
+
+	if rr := send("DELETE", "/_stargate/admin/ratelimit/overrides/ip:10.0.0.1", "secret", ""); rr.Code != http.StatusNoContent {
+		t.Errorf("Expected status 204, got %d", rr.Code)
+	}
+	if rr := send("DELETE", "/_stargate/admin/ratelimit/overrides/ip:10.0.0.1", "secret", ""); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for a deleted override, got %d", rr.Code)
+	}
+
+	// The audit log records every change, masking API keys
+	data, err := os.ReadFile(auditPath)
+	if err != nil {
+		t.Fatalf("Failed to read audit log: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("Expected 3 audit entries, got %q", data)
+	}
+	var entry middleware.AuditEntry
+	json.Unmarshal([]byte(lines[0]), &entry)
+	if entry.Action != "ratelimit.override.block" || entry.Target != "api_key:abcd***" || entry.Details["reason"] != "abuse" || entry.Caller == "" {
+		t.Errorf("Unexpected audit entry %+v", entry)
+	}
+	json.Unmarshal([]byte(lines[2]), &entry)
+	if entry.Action != "ratelimit.override.delete" || entry.Target != "ip:10.0.0.1" {
+		t.Errorf("Unexpected audit entry %+v", entry)
+	}
+}
@@ -0,0 +1,164 @@
+package proxy
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/songzhibin97/stargate/internal/config"
+)
+
+// defaultRetryMethods are the idempotent methods retried without a route
+// policy
+var defaultRetryMethods = []string{
+	http.MethodGet, http.MethodHead, http.MethodOptions,
+	http.MethodPut, http.MethodDelete, http.MethodTrace,
+}
+
+// defaultRetryConditions are the failures retried when a policy names none
+var defaultRetryConditions = []string{config.RetryConditionConnectError, "502", "503", "504"}
+
+// retryPolicy decides which requests are retried after which failures
+type retryPolicy struct {
+	anyMethod    bool
+	methods      map[string]bool
+	connectError bool
+	statuses     map[int]bool
+}
+
+// newRetryPolicy builds a retry policy, skipping invalid entries which
+// configuration validation rejects
+func newRetryPolicy(retryOn config.RetryOnConfig) *retryPolicy {
+	policy := &retryPolicy{
+		methods:  make(map[string]bool),
+		statuses: make(map[int]bool),
+	}
+	for _, method := range retryOn.Methods {
+		if method == "*" {
+			policy.anyMethod = true
+		}
+		policy.methods[strings.ToUpper(method)] = true
+	}
+
+	conditions := retryOn.Conditions
+	if len(conditions) == 0 {
+		conditions = defaultRetryConditions
+	}
+	for _, condition := range conditions {
+		connectError, status, err := config.ParseRetryCondition(condition)
+		if err != nil {
+			continue
+		}
+		if connectError {
+			policy.connectError = true
+		} else {
+			policy.statuses[status] = true
+		}
+	}
+	return policy
+}
+
+// allowsMethod reports whether requests with the method may be retried
+func (rp *retryPolicy) allowsMethod(method string) bool {
+	return rp.anyMethod || rp.methods[method]
+}
+
+// retryable reports whether the outcome of an attempt is a failure the
+// policy retries
+func (rp *retryPolicy) retryable(resp *http.Response, err error) bool {
+	if err != nil {
+		return rp.connectError && isConnectError(err)
+	}
+	return rp.statuses[resp.StatusCode]
+}
+
+// isConnectError reports whether the request failed to connect to its
+// target, so the target never saw it
+func isConnectError(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// retryConfig returns the retry configuration with defaults applied, or
+// false if retries are off
+func (rp *ReverseProxy) retryConfig() (config.RetryConfig, bool) {
+	rp.upstreamMu.RLock()
+	cfg := rp.config.Proxy.Retry
+	rp.upstreamMu.RUnlock()
+
+	if !cfg.Enabled || cfg.Attempts <= 0 {
+		return cfg, false
+	}
+	if cfg.Attempts > config.MaxRetryAttempts {
+		cfg.Attempts = config.MaxRetryAttempts
+	}
+	if cfg.MaxBodySize <= 0 {
+		cfg.MaxBodySize = 1024 * 1024
+	}
+	return cfg, true
+}
+
+// retryPolicyFor returns the retry policy of the request's route, or the
+// default method based policy
+func retryPolicyFor(cfg config.RetryConfig, r *http.Request) *retryPolicy {
+	routeID, _ := r.Context().Value("route_id").(string)
+	if retryOn, ok := cfg.PerRoute[routeID]; ok {
+		return newRetryPolicy(retryOn)
+	}
+	return newRetryPolicy(config.RetryOnConfig{Methods: defaultRetryMethods})
+}
+
+// retry sends the request to its target again while the previous attempt
+// failed in a way the route's policy retries, up to Attempts times. The
+// failed attempt's response is discarded, the last one is returned as is.
+func (rp *ReverseProxy) retry(req *http.Request, resp *http.Response, err error) (*http.Response, error) {
+	cfg, ok := rp.retryConfig()
+	if !ok {
+		return resp, err
+	}
+	policy := retryPolicyFor(cfg, req)
+	if !policy.allowsMethod(req.Method) {
+		return resp, err
+	}
+
+	for attempt := 0; attempt < cfg.Attempts && policy.retryable(resp, err); attempt++ {
+		if req.Context().Err() != nil {
+			break
+		}
+
+		// Requests whose body couldn't be buffered can't be sent again
+		body := req.Body
+		if body != nil && body != http.NoBody {
+			if req.GetBody == nil {
+				break
+			}
+			var bodyErr error
+			if body, bodyErr = req.GetBody(); bodyErr != nil {
+				break
+			}
+		}
+
+		if resp != nil {
+			resp.Body.Close()
+			resp = nil
+		}
+
+		if cfg.Backoff > 0 {
+			timer := time.NewTimer(cfg.Backoff)
+			select {
+			case <-timer.C:
+			case <-req.Context().Done():
+				timer.Stop()
+				return nil, req.Context().Err()
+			}
+		}
+
+		next := req.Clone(req.Context())
+		next.Body = body
+		propagateDeadline(next)
+		resp, err = rp.transportFor(next).RoundTrip(next)
+	}
+	return resp, err
+}
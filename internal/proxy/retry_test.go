@@ -0,0 +1,150 @@
+package proxy
+
+import (
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/songzhibin97/stargate/internal/config"
+	"github.com/songzhibin97/stargate/internal/loadbalancer"
+	"github.com/songzhibin97/stargate/internal/types"
+)
+
+func TestPipeline_RetryOn(t *testing.T) {
+	// The upstream fails every other attempt with 503
+	var attempts atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/health" {
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		if attempts.Add(1)%2 == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(r.Method + ":" + string(body)))
+	}))
+	defer server.Close()
+
+	newHandler := func(retry config.RetryConfig) http.Handler {
+		cfg := &config.Config{}
+		cfg.Proxy.Retry = retry
+		pipeline, err := NewPipeline(cfg, nil)
+		if err != nil {
+			t.Fatalf("Failed to create pipeline: %v", err)
+		}
+		t.Cleanup(func() { pipeline.Stop() })
+
+		host, portStr, _ := net.SplitHostPort(strings.TrimPrefix(server.URL, "http://"))
+		port, _ := strconv.Atoi(portStr)
+		lb := loadbalancer.NewRoundRobinBalancer(cfg)
+		if err := lb.UpdateUpstream(&types.Upstream{
+			ID:        "default-upstream",
+			Name:      "default-upstream",
+			Algorithm: "round_robin",
+			Targets:   []*types.Target{{Host: host, Port: port, Weight: 100, Healthy: true}},
+		}); err != nil {
+			t.Fatalf("Failed to add upstream: %v", err)
+		}
+		pipeline.loadBalancer = lb
+
+		// The mock router matches every request to route "default"
+		pipeline.router = &MockRouter{}
+		return pipeline.createHandler()
+	}
+
+	send := func(handler http.Handler, method string) (*httptest.ResponseRecorder, int64) {
+		attempts.Store(0)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest(method, "/orders", strings.NewReader("order-1")))
+		return rr, attempts.Load()
+	}
+
+	// The default policy retries GET but not POST
+	handler := newHandler(config.RetryConfig{Enabled: true, Attempts: 2})
+	if rr, n := send(handler, http.MethodPost); rr.Code != http.StatusServiceUnavailable || n != 1 {
+		t.Errorf("Expected POST not to be retried by default, got status %d after %d attempts", rr.Code, n)
+	}
+	if rr, n := send(handler, http.MethodGet); rr.Code != http.StatusOK || n != 2 {
+		t.Errorf("Expected GET to be retried by default, got status %d after %d attempts", rr.Code, n)
+	}
+
+	// A route marking POST retryable overrides the default policy, and
+	// replays the request body
+	handler = newHandler(config.RetryConfig{
+		Enabled:  true,
+		Attempts: 2,
+		PerRoute: map[string]config.RetryOnConfig{
+			"default": {Methods: []string{"POST"}, Conditions: []string{"503"}},
+		},
+	})
+	rr, n := send(handler, http.MethodPost)
+	if rr.Code != http.StatusOK || n != 2 {
+		t.Fatalf("Expected POST to be retried, got status %d after %d attempts", rr.Code, n)
+	}
+	if body := rr.Body.String(); body != "POST:order-1" {
+		t.Errorf("Expected the retry to carry the request body, got %q", body)
+	}
+	if rr, n := send(handler, http.MethodGet); rr.Code != http.StatusServiceUnavailable || n != 1 {
+		t.Errorf("Expected GET not to be retried by the route policy, got status %d after %d attempts", rr.Code, n)
+	}
+
+	// Conditions the route doesn't name aren't retried
+	handler = newHandler(config.RetryConfig{
+		Enabled:  true,
+		Attempts: 2,
+		PerRoute: map[string]config.RetryOnConfig{
+			"default": {Methods: []string{"*"}, Conditions: []string{"502"}},
+		},
+	})
+	if rr, n := send(handler, http.MethodPost); rr.Code != http.StatusServiceUnavailable || n != 1 {
+		t.Errorf("Expected 503 not to be retried on 502 only, got status %d after %d attempts", rr.Code, n)
+	}
+}
+
+func TestRetryPolicy_Retryable(t *testing.T) {
+	dialErr := &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+	readErr := &net.OpError{Op: "read", Net: "tcp", Err: errors.New("connection reset by peer")}
+
+	defaults := newRetryPolicy(config.RetryOnConfig{Methods: defaultRetryMethods})
+	statusOnly := newRetryPolicy(config.RetryOnConfig{Methods: []string{"post"}, Conditions: []string{"429"}})
+
+	tests := []struct {
+		name   string
+		policy *retryPolicy
+		status int
+		err    error
+		want   bool
+	}{
+		{"connect error", defaults, 0, dialErr, true},
+		{"error after connecting", defaults, 0, readErr, false},
+		{"502", defaults, http.StatusBadGateway, nil, true},
+		{"500", defaults, http.StatusInternalServerError, nil, false},
+		{"named status", statusOnly, http.StatusTooManyRequests, nil, true},
+		{"connect error not named", statusOnly, 0, dialErr, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var resp *http.Response
+			if tt.err == nil {
+				resp = &http.Response{StatusCode: tt.status}
+			}
+			if got := tt.policy.retryable(resp, tt.err); got != tt.want {
+				t.Errorf("retryable() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if !statusOnly.allowsMethod(http.MethodPost) || statusOnly.allowsMethod(http.MethodGet) {
+		t.Error("Expected the route policy to allow only POST")
+	}
+	if defaults.allowsMethod(http.MethodPost) || !defaults.allowsMethod(http.MethodDelete) {
+		t.Error("Expected the default policy to allow only idempotent methods")
+	}
+}
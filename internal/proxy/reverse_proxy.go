@@ -2,17 +2,18 @@ package proxy
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
-	"strings"
-	"time"
+	"sync"
 
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/propagation"
+	"github.com/songzhibin97/stargate/internal/clientip"
 	"github.com/songzhibin97/stargate/internal/config"
 	"github.com/songzhibin97/stargate/internal/types"
 )
@@ -22,33 +23,40 @@ type ReverseProxy struct {
 	config    *config.Config
 	transport *http.Transport
 	proxy     *httputil.ReverseProxy
+
+	// Per-upstream transports keyed by upstream ID
+	upstreams  map[string]*upstreamTransport
+	upstreamMu sync.RWMutex
+
+	// Selects targets for dynamic routing
+	resolveUpstream UpstreamResolver
+
+	// Upstream TLS certificate reloading
+	stopReload chan struct{}
+	reloadWg   sync.WaitGroup
+	closeOnce  sync.Once
 }
 
 // NewReverseProxy creates a new reverse proxy
 func NewReverseProxy(cfg *config.Config) (*ReverseProxy, error) {
 	// Create custom transport
-	transport := &http.Transport{
-		DialContext: (&net.Dialer{
-			Timeout:   cfg.Proxy.ConnectTimeout,
-			KeepAlive: cfg.Proxy.KeepAliveTimeout,
-		}).DialContext,
-		ResponseHeaderTimeout: cfg.Proxy.ResponseHeaderTimeout,
-		MaxIdleConns:          cfg.Proxy.MaxIdleConns,
-		MaxIdleConnsPerHost:   cfg.Proxy.MaxIdleConnsPerHost,
-		IdleConnTimeout:       cfg.Proxy.KeepAliveTimeout,
-		TLSHandshakeTimeout:   10 * time.Second,
-		ExpectContinueTimeout: 1 * time.Second,
-	}
+	transport := newTransport(cfg, config.UpstreamProxyConfig{}, nil)
 
 	rp := &ReverseProxy{
 		config:    cfg,
 		transport: transport,
+		upstreams: make(map[string]*upstreamTransport),
+	}
+
+	// Create per-upstream transports
+	if err := rp.UpdateUpstreams(cfg.Proxy.Upstreams); err != nil {
+		return nil, fmt.Errorf("failed to configure upstream transports: %w", err)
 	}
 
 	// Create httputil.ReverseProxy with custom director
 	rp.proxy = &httputil.ReverseProxy{
 		Director:       rp.director,
-		Transport:      transport,
+		Transport:      roundTripperFunc(rp.roundTrip),
 		ModifyResponse: rp.modifyResponse,
 		ErrorHandler:   rp.errorHandler,
 		BufferPool:     &bufferPool{size: cfg.Proxy.BufferSize},
@@ -59,9 +67,27 @@ func NewReverseProxy(cfg *config.Config) (*ReverseProxy, error) {
 
 // ServeHTTP implements http.Handler interface
 func (rp *ReverseProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if cfg, _, ok := rp.dynamicRouting(); ok {
+		bufferForReroute(r, cfg.MaxBodySize)
+	}
+	if cfg, ok := rp.retryConfig(); ok && retryPolicyFor(cfg, r).allowsMethod(r.Method) {
+		bufferForReroute(r, cfg.MaxBodySize)
+	}
 	rp.proxy.ServeHTTP(w, r)
 }
 
+// roundTrip sends the request to its upstream, retrying failed attempts and
+// following dynamic routing headers in the response
+func (rp *ReverseProxy) roundTrip(req *http.Request) (*http.Response, error) {
+	propagateDeadline(req)
+	resp, err := rp.transportFor(req).RoundTrip(req)
+	resp, err = rp.retry(req, resp, err)
+	if err != nil {
+		return nil, err
+	}
+	return rp.reroute(req, resp)
+}
+
 // director modifies the request before forwarding
 func (rp *ReverseProxy) director(req *http.Request) {
 	// Get target from context (set by load balancer)
@@ -75,24 +101,15 @@ func (rp *ReverseProxy) director(req *http.Request) {
 	}
 
 	// Set target URL
-	req.URL.Scheme = "http"
-	if target.Port == 443 {
-		req.URL.Scheme = "https"
-	}
-	req.URL.Host = fmt.Sprintf("%s:%d", target.Host, target.Port)
+	rp.setTargetURL(req, target)
 
 	// Preserve original host header if needed
 	if req.Header.Get("X-Forwarded-Host") == "" {
 		req.Header.Set("X-Forwarded-Host", req.Host)
 	}
 
-	// Set forwarded headers
-	if req.Header.Get("X-Forwarded-For") == "" {
-		req.Header.Set("X-Forwarded-For", getClientIP(req))
-	} else {
-		req.Header.Set("X-Forwarded-For", req.Header.Get("X-Forwarded-For")+", "+getClientIP(req))
-	}
-
+	// Set forwarded headers. httputil.ReverseProxy appends the peer address
+	// to X-Forwarded-For after the director runs.
 	if req.Header.Get("X-Forwarded-Proto") == "" {
 		if req.TLS != nil {
 			req.Header.Set("X-Forwarded-Proto", "https")
@@ -101,10 +118,8 @@ func (rp *ReverseProxy) director(req *http.Request) {
 		}
 	}
 
-	// Set real IP
-	if req.Header.Get("X-Real-IP") == "" {
-		req.Header.Set("X-Real-IP", getClientIP(req))
-	}
+	// Set real IP, replacing any value not resolved through a trusted proxy
+	req.Header.Set("X-Real-IP", getClientIP(req))
 
 	// Remove hop-by-hop headers
 	removeHopByHopHeaders(req.Header)
@@ -121,6 +136,15 @@ func (rp *ReverseProxy) director(req *http.Request) {
 	}
 }
 
+// setTargetURL points the request URL at the target
+func (rp *ReverseProxy) setTargetURL(req *http.Request, target *types.Target) {
+	req.URL.Scheme = "http"
+	if target.Port == 443 || rp.upstreamTLSEnabled(upstreamID(req)) {
+		req.URL.Scheme = "https"
+	}
+	req.URL.Host = fmt.Sprintf("%s:%d", target.Host, target.Port)
+}
+
 // modifyResponse modifies the response before returning to client
 func (rp *ReverseProxy) modifyResponse(resp *http.Response) error {
 	// Remove hop-by-hop headers
@@ -148,21 +172,29 @@ func (rp *ReverseProxy) errorHandler(w http.ResponseWriter, r *http.Request, err
 	status := http.StatusBadGateway
 	message := "Bad Gateway"
 	isTimeout := false
+	tlsFailure := classifyUpstreamTLSError(err, rp.serverName(r))
 
-	if err == context.DeadlineExceeded {
+	// The transport reports an expired request deadline in various ways,
+	// so the request's context is checked as well
+	if errors.Is(err, context.DeadlineExceeded) || r.Context().Err() == context.DeadlineExceeded {
 		status = http.StatusGatewayTimeout
 		message = "Gateway Timeout"
 		isTimeout = true
+	} else if tlsFailure != nil {
+		message = "Upstream TLS handshake failed"
+		rp.upstreamMu.RLock()
+		detail := rp.config.Proxy.TLSErrorDetail
+		rp.upstreamMu.RUnlock()
+		if detail {
+			message += ": " + tlsFailure.description()
+		}
 	} else if _, ok := err.(net.Error); ok {
 		status = http.StatusServiceUnavailable
 		message = "Service Unavailable"
 	}
 
-	// Store error information in request context for passive health checking
-	ctx := context.WithValue(r.Context(), "proxy_error", err)
-	ctx = context.WithValue(ctx, "proxy_timeout", isTimeout)
-	ctx = context.WithValue(ctx, "proxy_status", status)
-	*r = *r.WithContext(ctx)
+	// Record the error for passive health checking
+	recordProxyFailure(r, err, isTimeout, tlsFailure)
 
 	// Write error response
 	w.Header().Set("Content-Type", "application/json")
@@ -177,27 +209,9 @@ func (rp *ReverseProxy) shouldAddCORS(r *http.Request) bool {
 	return origin != ""
 }
 
-// getClientIP extracts the client IP address
+// getClientIP returns the client IP resolved for the request
 func getClientIP(r *http.Request) string {
-	// Check X-Forwarded-For header
-	xff := r.Header.Get("X-Forwarded-For")
-	if xff != "" {
-		ips := strings.Split(xff, ",")
-		return strings.TrimSpace(ips[0])
-	}
-
-	// Check X-Real-IP header
-	xri := r.Header.Get("X-Real-IP")
-	if xri != "" {
-		return strings.TrimSpace(xri)
-	}
-
-	// Fall back to remote address
-	ip, _, err := net.SplitHostPort(r.RemoteAddr)
-	if err != nil {
-		return r.RemoteAddr
-	}
-	return ip
+	return clientip.FromRequest(r)
 }
 
 // removeHopByHopHeaders removes hop-by-hop headers
@@ -240,9 +254,25 @@ func (rp *ReverseProxy) Health() map[string]interface{} {
 
 // Close closes the reverse proxy and cleans up resources
 func (rp *ReverseProxy) Close() error {
+	rp.closeOnce.Do(func() {
+		rp.upstreamMu.Lock()
+		stopReload := rp.stopReload
+		rp.upstreamMu.Unlock()
+		if stopReload != nil {
+			close(stopReload)
+			rp.reloadWg.Wait()
+		}
+	})
+
 	if rp.transport != nil {
 		rp.transport.CloseIdleConnections()
 	}
+
+	rp.upstreamMu.RLock()
+	for _, ut := range rp.upstreams {
+		ut.transport.CloseIdleConnections()
+	}
+	rp.upstreamMu.RUnlock()
 	return nil
 }
 
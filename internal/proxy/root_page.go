@@ -0,0 +1,91 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/songzhibin97/stargate/internal/config"
+)
+
+// rootPage answers requests for the gateway root matching no route with a
+// page or a redirect, configured by proxy.root
+type rootPage struct {
+	paths       map[string]bool
+	redirect    string
+	body        []byte
+	contentType string
+	status      int
+}
+
+// newRootPage creates the root page from configuration, reading the page
+// file, or returns nil if the root page is disabled
+func newRootPage(cfg config.RootPageConfig) (*rootPage, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+
+	page := &rootPage{
+		paths:       map[string]bool{"/": true},
+		redirect:    cfg.Redirect,
+		body:        []byte(cfg.Body),
+		contentType: cfg.ContentType,
+		status:      cfg.StatusCode,
+	}
+	for _, path := range cfg.Paths {
+		page.paths[path] = true
+	}
+	if cfg.File != "" {
+		body, err := os.ReadFile(cfg.File)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read root page file: %w", err)
+		}
+		page.body = body
+	}
+	if page.contentType == "" {
+		page.contentType = "text/html; charset=utf-8"
+	}
+	if page.status == 0 {
+		page.status = http.StatusOK
+		if page.redirect != "" {
+			page.status = http.StatusFound
+		}
+	}
+	return page, nil
+}
+
+// serveRootPage answers a request matching no route with the root page,
+// reporting whether it did
+func (p *Pipeline) serveRootPage(w http.ResponseWriter, r *http.Request) bool {
+	p.mu.RLock()
+	root := p.rootPage
+	p.mu.RUnlock()
+
+	if !root.serves(r) {
+		return false
+	}
+	root.serve(w, r)
+	return true
+}
+
+// serves reports whether the page answers the request
+func (rp *rootPage) serves(r *http.Request) bool {
+	if rp == nil || (r.Method != http.MethodGet && r.Method != http.MethodHead) {
+		return false
+	}
+	return rp.paths[r.URL.Path]
+}
+
+// serve writes the page or the redirect
+func (rp *rootPage) serve(w http.ResponseWriter, r *http.Request) {
+	if rp.redirect != "" {
+		http.Redirect(w, r, rp.redirect, rp.status)
+		return
+	}
+
+	w.Header().Set("Content-Type", rp.contentType)
+	w.WriteHeader(rp.status)
+	if r.Method != http.MethodHead {
+		w.Write(rp.body)
+	}
+}
@@ -0,0 +1,131 @@
+package proxy
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/songzhibin97/stargate/internal/config"
+	"github.com/songzhibin97/stargate/internal/loadbalancer"
+	"github.com/songzhibin97/stargate/internal/types"
+)
+
+// pathRouter routes requests by exact path
+type pathRouter struct {
+	MockRouter
+	routes map[string]*Route
+}
+
+func (pr *pathRouter) Match(r *http.Request) (*Route, error) {
+	if route, ok := pr.routes[r.URL.Path]; ok {
+		return route, nil
+	}
+	return nil, errors.New("no route matched")
+}
+
+func TestPipeline_RootPage(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("backend " + r.URL.Path))
+	}))
+	defer backend.Close()
+
+	host, portStr, _ := net.SplitHostPort(strings.TrimPrefix(backend.URL, "http://"))
+	port, _ := strconv.Atoi(portStr)
+
+	newHandler := func(t *testing.T, root config.RootPageConfig, routes map[string]*Route) http.Handler {
+		cfg := &config.Config{}
+		cfg.Proxy.Root = root
+
+		pipeline, err := NewPipeline(cfg, nil)
+		if err != nil {
+			t.Fatalf("Failed to create pipeline: %v", err)
+		}
+		t.Cleanup(func() { pipeline.Stop() })
+
+		lb := loadbalancer.NewRoundRobinBalancer(cfg)
+		if err := lb.UpdateUpstream(&types.Upstream{
+			ID:        "web",
+			Name:      "web",
+			Algorithm: "round_robin",
+			Targets:   []*types.Target{{Host: host, Port: port, Weight: 100, Healthy: true}},
+		}); err != nil {
+			t.Fatalf("Failed to add upstream: %v", err)
+		}
+		pipeline.loadBalancer = lb
+		pipeline.router = &pathRouter{routes: routes}
+		return pipeline.createHandler()
+	}
+	serve := func(handler http.Handler, method, path string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest(method, path, nil))
+		return rr
+	}
+
+	landing := config.RootPageConfig{
+		Enabled:     true,
+		Paths:       []string{"/status"},
+		Body:        `{"status":"ok"}`,
+		ContentType: "application/json",
+	}
+
+	t.Run("disabled by default", func(t *testing.T) {
+		rr := serve(newHandler(t, config.RootPageConfig{}, nil), "GET", "/")
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("Expected 404 without a root page, got %d", rr.Code)
+		}
+	})
+
+	t.Run("page", func(t *testing.T) {
+		handler := newHandler(t, landing, nil)
+		for _, path := range []string{"/", "/status"} {
+			rr := serve(handler, "GET", path)
+			if rr.Code != http.StatusOK || rr.Body.String() != `{"status":"ok"}` || rr.Header().Get("Content-Type") != "application/json" {
+				t.Errorf("Expected the page on %s, got %d %q %q", path, rr.Code, rr.Header().Get("Content-Type"), rr.Body.String())
+			}
+		}
+
+		// Other paths and methods are answered by the no-route action
+		if rr := serve(handler, "GET", "/unknown"); rr.Code != http.StatusNotFound {
+			t.Errorf("Expected 404 for other paths, got %d", rr.Code)
+		}
+		if rr := serve(handler, "POST", "/"); rr.Code != http.StatusNotFound {
+			t.Errorf("Expected 404 for POST /, got %d", rr.Code)
+		}
+	})
+
+	t.Run("does not shadow a route on /", func(t *testing.T) {
+		handler := newHandler(t, landing, map[string]*Route{
+			"/": {ID: "home", UpstreamID: "web"},
+		})
+		rr := serve(handler, "GET", "/")
+		if rr.Code != http.StatusOK || rr.Body.String() != "backend /" {
+			t.Errorf("Expected the route to serve /, got %d %q", rr.Code, rr.Body.String())
+		}
+		if rr := serve(handler, "GET", "/status"); rr.Body.String() != `{"status":"ok"}` {
+			t.Errorf("Expected the page on unrouted paths, got %q", rr.Body.String())
+		}
+	})
+
+	t.Run("file", func(t *testing.T) {
+		file := filepath.Join(t.TempDir(), "index.html")
+		os.WriteFile(file, []byte("<h1>Stargate</h1>"), 0644)
+
+		rr := serve(newHandler(t, config.RootPageConfig{Enabled: true, File: file}, nil), "GET", "/")
+		if rr.Code != http.StatusOK || rr.Body.String() != "<h1>Stargate</h1>" || rr.Header().Get("Content-Type") != "text/html; charset=utf-8" {
+			t.Errorf("Expected the HTML page from the file, got %d %q %q", rr.Code, rr.Header().Get("Content-Type"), rr.Body.String())
+		}
+	})
+
+	t.Run("redirect", func(t *testing.T) {
+		rr := serve(newHandler(t, config.RootPageConfig{Enabled: true, Redirect: "https://docs.example.com"}, nil), "GET", "/")
+		if rr.Code != http.StatusFound || rr.Header().Get("Location") != "https://docs.example.com" {
+			t.Errorf("Expected 302 to the docs, got %d %q", rr.Code, rr.Header().Get("Location"))
+		}
+	})
+}
@@ -0,0 +1,191 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/songzhibin97/stargate/internal/config"
+)
+
+// Route replay limits
+const (
+	maxRouteReplayRequests = 1000
+	maxRouteReplayBodySize = 1 << 20
+)
+
+// RouteReplayRequest describes a synthetic request to match against the
+// live router
+type RouteReplayRequest struct {
+	Method   string            `json:"method"` // default: GET
+	Host     string            `json:"host"`
+	Path     string            `json:"path"` // May include a query string
+	Headers  map[string]string `json:"headers,omitempty"`
+	Listener string            `json:"listener,omitempty"` // Listener accepting the request, whose profile selects routes and middlewares
+}
+
+// RouteReplayResult is the routing decision for a synthetic request
+type RouteReplayResult struct {
+	Method        string   `json:"method"`
+	Host          string   `json:"host"`
+	Path          string   `json:"path"`
+	Matched       bool     `json:"matched"`
+	RouteID       string   `json:"route_id,omitempty"`
+	UpstreamID    string   `json:"upstream_id,omitempty"`
+	UpstreamFound bool     `json:"upstream_found"`     // The load balancer has the upstream
+	NoRoute       string   `json:"no_route,omitempty"` // proxy.no_route action answering an unmatched request, or root for the root page
+	Middlewares   []string `json:"middlewares"`        // Middlewares of the chain the request passes, in order
+}
+
+// RouteReplayResponse is the response of the route replay endpoint
+type RouteReplayResponse struct {
+	Results []RouteReplayResult `json:"results"`
+	Total   int                 `json:"total"`
+	Matched int                 `json:"matched"`
+}
+
+// routeReplayPath returns the path of the route replay endpoint, or "" if
+// the REST Admin API is disabled
+func (p *Pipeline) routeReplayPath() string {
+	return p.nodeAdminPath("/routes:test")
+}
+
+// handleRouteReplay matches a batch of synthetic requests against the live
+// router and reports the route, upstream and middlewares of each, so CI can
+// assert routing behavior. Nothing is proxied and no upstream is contacted.
+func (p *Pipeline) handleRouteReplay(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(map[string]string{"error": "method not allowed"})
+		return
+	}
+
+	var body struct {
+		Requests []RouteReplayRequest `json:"requests"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRouteReplayBodySize)).Decode(&body); err != nil {
+		status := http.StatusBadRequest
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			status = http.StatusRequestEntityTooLarge
+		}
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string]string{"error": fmt.Sprintf("invalid request body: %v", err)})
+		return
+	}
+	if len(body.Requests) > maxRouteReplayRequests {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": fmt.Sprintf("at most %d requests can be tested at once", maxRouteReplayRequests)})
+		return
+	}
+
+	response := RouteReplayResponse{
+		Results: make([]RouteReplayResult, 0, len(body.Requests)),
+		Total:   len(body.Requests),
+	}
+	for i, descriptor := range body.Requests {
+		result, err := p.replayRoute(r.Context(), descriptor)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": fmt.Sprintf("request %d: %v", i, err)})
+			return
+		}
+		if result.Matched {
+			response.Matched++
+		}
+		response.Results = append(response.Results, result)
+	}
+
+	log.Printf("Route replay by %s: %d of %d requests matched", r.RemoteAddr, response.Matched, response.Total)
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// replayRoute matches a synthetic request the way createHandler does,
+// without proxying it
+func (p *Pipeline) replayRoute(ctx context.Context, descriptor RouteReplayRequest) (RouteReplayResult, error) {
+	if descriptor.Method == "" {
+		descriptor.Method = http.MethodGet
+	}
+	descriptor.Method = strings.ToUpper(descriptor.Method)
+	if !strings.HasPrefix(descriptor.Path, "/") {
+		return RouteReplayResult{}, fmt.Errorf("path must start with /: %q", descriptor.Path)
+	}
+	if descriptor.Listener != "" && !p.hasListener(descriptor.Listener) {
+		return RouteReplayResult{}, fmt.Errorf("unknown listener %q", descriptor.Listener)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, descriptor.Method, "http://replay"+descriptor.Path, nil)
+	if err != nil {
+		return RouteReplayResult{}, err
+	}
+	req.Host = descriptor.Host
+	for name, value := range descriptor.Headers {
+		req.Header.Set(name, value)
+	}
+	if descriptor.Listener != "" {
+		req = req.WithContext(context.WithValue(req.Context(), listenerKey{}, descriptor.Listener))
+	}
+
+	result := RouteReplayResult{
+		Method:      descriptor.Method,
+		Host:        descriptor.Host,
+		Path:        descriptor.Path,
+		Middlewares: []string{},
+	}
+
+	profile := p.listenerProfile(req)
+	p.mu.RLock()
+	for _, middleware := range p.middlewares {
+		if profile.appliesMiddleware(middleware.name) {
+			result.Middlewares = append(result.Middlewares, middleware.name)
+		}
+	}
+	noRoute := p.config.Proxy.NoRoute.Action
+	root := p.rootPage
+	p.mu.RUnlock()
+
+	route, err := p.router.Match(req)
+	if err == nil && profile.servesRoute(route.ID) {
+		result.Matched = true
+		result.RouteID = route.ID
+		result.UpstreamID = route.UpstreamID
+	} else if root.serves(req) {
+		// An unmatched request for the root is answered by the root page
+		result.NoRoute = "root"
+	} else {
+		// An unmatched request is answered by the no-route action
+		mode, target, err := config.ParseNoRouteAction(noRoute)
+		if err != nil {
+			mode = "error"
+		}
+		result.NoRoute = mode
+		if mode == "upstream" {
+			result.RouteID = noRouteID
+			result.UpstreamID = target
+		}
+	}
+	if result.UpstreamID != "" {
+		result.UpstreamFound = p.getUpstream(result.UpstreamID) != nil
+	}
+	return result, nil
+}
+
+// hasListener reports whether a listener of the name is configured
+func (p *Pipeline) hasListener(name string) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	for _, listener := range p.config.Server.Listeners {
+		if listener.Name == name {
+			return true
+		}
+	}
+	return false
+}
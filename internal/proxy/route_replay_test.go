@@ -0,0 +1,139 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/songzhibin97/stargate/internal/config"
+	"github.com/songzhibin97/stargate/internal/loadbalancer"
+	"github.com/songzhibin97/stargate/internal/types"
+)
+
+func TestPipeline_RouteReplay(t *testing.T) {
+	var proxied int64
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/health" {
+			atomic.AddInt64(&proxied, 1)
+		}
+	}))
+	defer backend.Close()
+	host, portStr, _ := net.SplitHostPort(strings.TrimPrefix(backend.URL, "http://"))
+	port, _ := strconv.Atoi(portStr)
+
+	cfg := &config.Config{}
+	cfg.AdminAPI.REST = config.RESTConfig{Enabled: true, Prefix: "/api/v1"}
+	cfg.AdminAPI.Auth = config.AuthConfig{
+		Enabled: true,
+		APIKey:  config.APIKeyConfig{Header: "X-Admin-Key", Keys: []string{"admin-key"}},
+	}
+	cfg.Proxy.NoRoute = config.NoRouteConfig{Action: "upstream:catch-all"}
+	cfg.Server.Listeners = []config.ListenerConfig{
+		{Name: "public", Address: ":8443"},
+		{Name: "admin", Address: ":9090", Profile: "admin"},
+	}
+	cfg.Server.Profiles = map[string]config.ListenerProfileConfig{
+		"admin": {Middlewares: []string{"metrics"}, Routes: []string{"admin-api"}},
+	}
+
+	pipeline, err := NewPipeline(cfg, nil)
+	if err != nil {
+		t.Fatalf("Failed to create pipeline: %v", err)
+	}
+	defer pipeline.Stop()
+
+	router := NewRouterAdapter()
+	for _, route := range []*Route{
+		{ID: "orders", Hosts: []string{"api.example.com"}, Paths: []string{"/orders/"}, Methods: []string{"GET", "POST"}, UpstreamID: "orders-svc"},
+		{ID: "admin-api", Paths: []string{"/admin/"}, UpstreamID: "admin-svc"},
+	} {
+		if err := router.AddRoute(route); err != nil {
+			t.Fatalf("Failed to add route: %v", err)
+		}
+	}
+	pipeline.router = router
+
+	lb := loadbalancer.NewRoundRobinBalancer(cfg)
+	if err := lb.UpdateUpstream(&types.Upstream{
+		ID:        "orders-svc",
+		Name:      "orders-svc",
+		Algorithm: "round_robin",
+		Targets:   []*types.Target{{Host: host, Port: port, Weight: 100, Healthy: true}},
+	}); err != nil {
+		t.Fatalf("Failed to add upstream: %v", err)
+	}
+	pipeline.loadBalancer = lb
+
+	pipeline.middlewares = nil
+	for _, name := range []string{"auth", "metrics"} {
+		pipeline.use(name, func(next http.Handler) http.Handler { return next })
+	}
+
+	request := func(method, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/_stargate/admin/routes:test", strings.NewReader(body))
+		req.Header.Set("X-Admin-Key", "admin-key")
+		rr := httptest.NewRecorder()
+		pipeline.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := httptest.NewRecorder()
+	pipeline.ServeHTTP(rr, httptest.NewRequest("POST", "/_stargate/admin/routes:test", strings.NewReader(`{"requests":[]}`)))
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 without credentials, got %d", rr.Code)
+	}
+	if rr := request("GET", ""); rr.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status 405 for GET, got %d", rr.Code)
+	}
+
+	rr = request("POST", `{"requests":[
+		{"method":"GET","host":"api.example.com","path":"/orders/1"},
+		{"method":"delete","host":"api.example.com","path":"/orders/1"},
+		{"path":"/admin/users","listener":"admin"},
+		{"host":"api.example.com","path":"/orders/1?expand=items","listener":"admin"}
+	]}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var response RouteReplayResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if response.Total != 4 || response.Matched != 2 {
+		t.Errorf("Expected 2 of 4 requests matched, got %d of %d", response.Matched, response.Total)
+	}
+
+	expected := []RouteReplayResult{
+		{Method: "GET", Host: "api.example.com", Path: "/orders/1", Matched: true, RouteID: "orders", UpstreamID: "orders-svc", UpstreamFound: true, Middlewares: []string{"auth", "metrics"}},
+		{Method: "DELETE", Host: "api.example.com", Path: "/orders/1", RouteID: "no_route", UpstreamID: "catch-all", NoRoute: "upstream", Middlewares: []string{"auth", "metrics"}},
+		{Method: "GET", Path: "/admin/users", Matched: true, RouteID: "admin-api", UpstreamID: "admin-svc", Middlewares: []string{"metrics"}},
+		{Method: "GET", Host: "api.example.com", Path: "/orders/1?expand=items", RouteID: "no_route", UpstreamID: "catch-all", NoRoute: "upstream", Middlewares: []string{"metrics"}},
+	}
+	for i, result := range response.Results {
+		if !reflect.DeepEqual(result, expected[i]) {
+			t.Errorf("Request %d: expected %+v, got %+v", i, expected[i], result)
+		}
+	}
+
+	for body, message := range map[string]string{
+		`{"requests":[{"path":"orders"}]}`:                  "request 0: path must start with /",
+		`{"requests":[{"path":"/","listener":"partners"}]}`: "request 0: unknown listener",
+		`{"requests":[{"path":"/"}`:                         "invalid request body",
+	} {
+		rr := request("POST", body)
+		if rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), message) {
+			t.Errorf("Expected 400 with %q for %s, got %d %s", message, body, rr.Code, rr.Body.String())
+		}
+	}
+
+	if count := atomic.LoadInt64(&proxied); count != 0 {
+		t.Errorf("Expected no upstream calls, got %d", count)
+	}
+}
@@ -35,6 +35,7 @@ func (ra *RouterAdapter) Match(r *http.Request) (*Route, error) {
 		Paths:      ra.convertPathRulesToStrings(result.Route.Rules.Paths),
 		Methods:    result.Route.Rules.Methods,
 		UpstreamID: result.Route.UpstreamID,
+		FallbackUpstreams: result.Route.FallbackUpstreams,
 		Metadata:   result.Route.Metadata,
 		CreatedAt:  result.Route.CreatedAt,
 		UpdatedAt:  result.Route.UpdatedAt,
@@ -55,6 +56,7 @@ func (ra *RouterAdapter) AddRoute(route *Route) error {
 			Methods: route.Methods,
 		},
 		UpstreamID: route.UpstreamID,
+		FallbackUpstreams: route.FallbackUpstreams,
 		Priority:   100, // Default priority
 		Metadata:   route.Metadata,
 		CreatedAt:  route.CreatedAt,
@@ -85,6 +87,7 @@ func (ra *RouterAdapter) ListRoutes() []*Route {
 			Paths:      ra.convertPathRulesToStrings(enhancedRoute.Rules.Paths),
 			Methods:    enhancedRoute.Rules.Methods,
 			UpstreamID: enhancedRoute.UpstreamID,
+			FallbackUpstreams: enhancedRoute.FallbackUpstreams,
 			Metadata:   enhancedRoute.Metadata,
 			CreatedAt:  enhancedRoute.CreatedAt,
 			UpdatedAt:  enhancedRoute.UpdatedAt,
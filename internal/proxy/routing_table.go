@@ -0,0 +1,229 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/songzhibin97/stargate/internal/types"
+)
+
+// Routing table export limits
+const (
+	defaultRoutingTableLimit = 1000
+	maxRoutingTableLimit     = 10000
+	maxRoutingTableBytes     = 4 << 20
+)
+
+// RoutingTableRoute is a route of the live router
+type RoutingTableRoute struct {
+	ID                string   `json:"id"`
+	Name              string   `json:"name"`
+	Hosts             []string `json:"hosts"`
+	Paths             []string `json:"paths"`
+	Methods           []string `json:"methods"`
+	UpstreamID        string   `json:"upstream_id"`
+	FallbackUpstreams []string `json:"fallback_upstreams,omitempty"`
+	UpstreamFound     bool     `json:"upstream_found"` // The load balancer has the route's upstream
+}
+
+// RoutingTableTarget is a target of an upstream of the live load balancer
+type RoutingTableTarget struct {
+	Address  string `json:"address"`
+	Weight   int    `json:"weight"`
+	Healthy  bool   `json:"healthy"`
+	InFlight int64  `json:"in_flight"` // Requests currently proxied to the target
+}
+
+// RoutingTableUpstream is an upstream of the live load balancer
+type RoutingTableUpstream struct {
+	ID             string               `json:"id"`
+	Name           string               `json:"name"`
+	Algorithm      string               `json:"algorithm"`
+	Targets        []RoutingTableTarget `json:"targets"`
+	HealthyTargets int                  `json:"healthy_targets"`
+}
+
+// RoutingTableResponse is the response of the routing table endpoint
+type RoutingTableResponse struct {
+	Routes         []RoutingTableRoute    `json:"routes"`
+	Upstreams      []RoutingTableUpstream `json:"upstreams"`
+	TotalRoutes    int                    `json:"total_routes"`
+	TotalUpstreams int                    `json:"total_upstreams"`
+	Truncated      bool                   `json:"truncated"` // Routes or upstreams were left out by the limit
+	GeneratedAt    time.Time              `json:"generated_at"`
+}
+
+// routingTablePath returns the path of the routing table endpoint, or "" if
+// the REST Admin API is disabled
+func (p *Pipeline) routingTablePath() string {
+	return p.nodeAdminPath("/debug/routing")
+}
+
+// handleRoutingTable returns the routes of the live router and the upstreams
+// and targets of the live load balancer, rather than the configuration, so
+// the node's state can be diffed against the intended configuration. The
+// limit query parameter bounds the routes and upstreams returned, and the
+// limit is lowered further while the response exceeds maxRoutingTableBytes.
+func (p *Pipeline) handleRoutingTable(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(map[string]string{"error": "method not allowed"})
+		return
+	}
+
+	limit, err := parseRoutingTableLimit(r.URL.Query().Get("limit"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	response := p.routingTable()
+	var body bytes.Buffer
+	for {
+		truncated := truncateRoutingTable(response, limit)
+		body.Reset()
+		if err := json.NewEncoder(&body).Encode(truncated); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+		if body.Len() <= maxRoutingTableBytes || limit == 0 {
+			break
+		}
+		limit /= 2
+	}
+
+	log.Printf("Routing table exported to %s: %d routes, %d upstreams", r.RemoteAddr, response.TotalRoutes, response.TotalUpstreams)
+
+	w.Write(body.Bytes())
+}
+
+// routingTable snapshots the routes of the router and the upstreams of the
+// load balancer, sorted by ID
+func (p *Pipeline) routingTable() RoutingTableResponse {
+	upstreams := p.listUpstreams()
+	known := make(map[string]bool, len(upstreams))
+	response := RoutingTableResponse{
+		Upstreams:      make([]RoutingTableUpstream, 0, len(upstreams)),
+		TotalUpstreams: len(upstreams),
+		GeneratedAt:    time.Now().UTC(),
+	}
+	for _, upstream := range upstreams {
+		known[upstream.ID] = true
+		response.Upstreams = append(response.Upstreams, p.routingTableUpstream(upstream))
+	}
+
+	var routes []*Route
+	if p.router != nil {
+		routes = p.router.ListRoutes()
+	}
+	sort.Slice(routes, func(i, j int) bool { return routes[i].ID < routes[j].ID })
+	response.Routes = make([]RoutingTableRoute, 0, len(routes))
+	response.TotalRoutes = len(routes)
+	for _, route := range routes {
+		response.Routes = append(response.Routes, RoutingTableRoute{
+			ID:                route.ID,
+			Name:              route.Name,
+			Hosts:             route.Hosts,
+			Paths:             route.Paths,
+			Methods:           route.Methods,
+			UpstreamID:        route.UpstreamID,
+			FallbackUpstreams: route.FallbackUpstreams,
+			UpstreamFound:     known[route.UpstreamID],
+		})
+	}
+
+	return response
+}
+
+// routingTableUpstream describes the upstream with the health, weight and
+// in-flight requests of its targets
+func (p *Pipeline) routingTableUpstream(upstream *types.Upstream) RoutingTableUpstream {
+	result := RoutingTableUpstream{
+		ID:        upstream.ID,
+		Name:      upstream.Name,
+		Algorithm: upstream.Algorithm,
+		Targets:   make([]RoutingTableTarget, 0, len(upstream.Targets)),
+	}
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	for _, target := range upstream.Targets {
+		result.Targets = append(result.Targets, RoutingTableTarget{
+			Address:  net.JoinHostPort(target.Host, strconv.Itoa(target.Port)),
+			Weight:   target.Weight,
+			Healthy:  target.Healthy,
+			InFlight: p.targetInFlight[targetInFlightKey(upstream.ID, target)],
+		})
+		if target.Healthy {
+			result.HealthyTargets++
+		}
+	}
+	return result
+}
+
+// truncateRoutingTable returns the routing table with at most limit routes
+// and limit upstreams
+func truncateRoutingTable(response RoutingTableResponse, limit int) RoutingTableResponse {
+	if len(response.Routes) > limit {
+		response.Routes = response.Routes[:limit]
+		response.Truncated = true
+	}
+	if len(response.Upstreams) > limit {
+		response.Upstreams = response.Upstreams[:limit]
+		response.Truncated = true
+	}
+	return response
+}
+
+// parseRoutingTableLimit parses the limit query parameter
+func parseRoutingTableLimit(value string) (int, error) {
+	if value == "" {
+		return defaultRoutingTableLimit, nil
+	}
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit <= 0 {
+		return 0, fmt.Errorf("invalid limit %q", value)
+	}
+	if limit > maxRoutingTableLimit {
+		limit = maxRoutingTableLimit
+	}
+	return limit, nil
+}
+
+// trackTargetInFlight counts a request proxied to the target until the
+// returned function is called
+func (p *Pipeline) trackTargetInFlight(upstreamID string, target *types.Target) func() {
+	key := targetInFlightKey(upstreamID, target)
+
+	p.mu.Lock()
+	if p.targetInFlight == nil {
+		p.targetInFlight = make(map[string]int64)
+	}
+	p.targetInFlight[key]++
+	p.mu.Unlock()
+
+	return func() {
+		p.mu.Lock()
+		if p.targetInFlight[key]--; p.targetInFlight[key] <= 0 {
+			delete(p.targetInFlight, key)
+		}
+		p.mu.Unlock()
+	}
+}
+
+// targetInFlightKey identifies a target of an upstream
+func targetInFlightKey(upstreamID string, target *types.Target) string {
+	return upstreamID + "/" + net.JoinHostPort(target.Host, strconv.Itoa(target.Port))
+}
@@ -0,0 +1,174 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/songzhibin97/stargate/internal/config"
+	"github.com/songzhibin97/stargate/internal/types"
+)
+
+// listRouter lists a fixed set of routes
+type listRouter struct {
+	MockRouter
+	routes []*Route
+}
+
+func (lr *listRouter) ListRoutes() []*Route {
+	return lr.routes
+}
+
+func TestPipeline_RoutingTable(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.AdminAPI.REST = config.RESTConfig{Enabled: true, Prefix: "/api/v1"}
+	cfg.AdminAPI.Auth = config.AuthConfig{
+		Enabled: true,
+		APIKey:  config.APIKeyConfig{Header: "X-Admin-Key", Keys: []string{"secret"}},
+	}
+
+	pipeline, err := NewPipeline(cfg, nil)
+	if err != nil {
+		t.Fatalf("Failed to create pipeline: %v", err)
+	}
+	defer pipeline.Stop()
+
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer backend.Close()
+
+	healthy := targetOf(t, backend.Listener.Addr())
+	healthy.Weight = 3
+	down := targetOf(t, backend.Listener.Addr())
+	down.Host = "127.0.0.2"
+	down.Healthy = false
+	upstream := &types.Upstream{
+		ID:          "api",
+		Name:        "api",
+		Algorithm:   "round_robin",
+		Targets:     []*types.Target{healthy, down},
+		HealthCheck: &types.HealthCheck{Type: "http", Path: "/", Interval: 30},
+	}
+	if err := pipeline.AddUpstream(upstream); err != nil {
+		t.Fatalf("Failed to add upstream: %v", err)
+	}
+
+	// The orders route points at an upstream the load balancer does not have
+	pipeline.router = &listRouter{routes: []*Route{
+		{ID: "orders", Paths: []string{"/orders"}, UpstreamID: "orders", FallbackUpstreams: []string{"api"}},
+		{ID: "api", Hosts: []string{"api.example.com"}, Paths: []string{"/"}, Methods: []string{"GET"}, UpstreamID: "api"},
+	}}
+
+	done := pipeline.trackTargetInFlight("api", healthy)
+	defer done()
+
+	export := func(method, target, key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, nil)
+		if key != "" {
+			req.Header.Set("X-Admin-Key", key)
+		}
+		rr := httptest.NewRecorder()
+		pipeline.ServeHTTP(rr, req)
+		return rr
+	}
+
+	if rr := export("GET", "/_stargate/admin/debug/routing", ""); rr.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 without credentials, got %d", rr.Code)
+	}
+	if rr := export("POST", "/_stargate/admin/debug/routing", "secret"); rr.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status 405 for POST, got %d", rr.Code)
+	}
+	if rr := export("GET", "/_stargate/admin/debug/routing?limit=0", "secret"); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an invalid limit, got %d", rr.Code)
+	}
+
+	rr := export("GET", "/_stargate/admin/debug/routing", "secret")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var response RoutingTableResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	if response.TotalRoutes != 2 || len(response.Routes) != 2 || response.Truncated {
+		t.Fatalf("Expected 2 routes without truncation, got %+v", response)
+	}
+	if route := response.Routes[0]; route.ID != "api" || !route.UpstreamFound || route.Hosts[0] != "api.example.com" {
+		t.Errorf("Unexpected api route: %+v", route)
+	}
+	if route := response.Routes[1]; route.ID != "orders" || route.UpstreamFound || route.FallbackUpstreams[0] != "api" {
+		t.Errorf("Expected the orders route to report its missing upstream, got %+v", route)
+	}
+
+	if len(response.Upstreams) != 1 {
+		t.Fatalf("Expected 1 upstream, got %d", len(response.Upstreams))
+	}
+	api := response.Upstreams[0]
+	if api.ID != "api" || api.HealthyTargets != 1 || len(api.Targets) != 2 {
+		t.Fatalf("Unexpected api upstream: %+v", api)
+	}
+	expected := RoutingTableTarget{Address: backend.Listener.Addr().String(), Weight: 3, Healthy: true, InFlight: 1}
+	if api.Targets[0] != expected {
+		t.Errorf("Expected target %+v, got %+v", expected, api.Targets[0])
+	}
+	if target := api.Targets[1]; target.Healthy || target.InFlight != 0 {
+		t.Errorf("Expected an idle unhealthy target, got %+v", target)
+	}
+
+	// The limit bounds the routes and upstreams returned
+	rr = export("GET", "/_stargate/admin/debug/routing?limit=1", "secret")
+	response = RoutingTableResponse{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(response.Routes) != 1 || response.TotalRoutes != 2 || !response.Truncated {
+		t.Errorf("Expected 1 of 2 routes and truncation, got %d of %d", len(response.Routes), response.TotalRoutes)
+	}
+}
+
+func TestPipeline_RoutingTableSizeCap(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.AdminAPI.REST = config.RESTConfig{Enabled: true, Prefix: "/api/v1"}
+	cfg.AdminAPI.Auth = config.AuthConfig{
+		Enabled: true,
+		APIKey:  config.APIKeyConfig{Header: "X-Admin-Key", Keys: []string{"secret"}},
+	}
+
+	pipeline, err := NewPipeline(cfg, nil)
+	if err != nil {
+		t.Fatalf("Failed to create pipeline: %v", err)
+	}
+	defer pipeline.Stop()
+
+	// Routes with long paths exceed the size cap at the maximum limit
+	paths := make([]string, 100)
+	for i := range paths {
+		paths[i] = fmt.Sprintf("/%0100d", i)
+	}
+	routes := make([]*Route, 2000)
+	for i := range routes {
+		routes[i] = &Route{ID: fmt.Sprintf("route-%04d", i), Paths: paths, UpstreamID: "api"}
+	}
+	pipeline.router = &listRouter{routes: routes}
+
+	req := httptest.NewRequest("GET", "/_stargate/admin/debug/routing?limit=10000", nil)
+	req.Header.Set("X-Admin-Key", "secret")
+	rr := httptest.NewRecorder()
+	pipeline.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rr.Code)
+	}
+	if rr.Body.Len() > maxRoutingTableBytes {
+		t.Errorf("Expected at most %d bytes, got %d", maxRoutingTableBytes, rr.Body.Len())
+	}
+
+	var response RoutingTableResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if !response.Truncated || len(response.Routes) == 0 || response.TotalRoutes != len(routes) {
+		t.Errorf("Expected a truncated table of %d routes, got %d routes", len(routes), len(response.Routes))
+	}
+}
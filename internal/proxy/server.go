@@ -2,22 +2,27 @@ package proxy
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
+	"sync"
 	"time"
 
 	"golang.org/x/net/http2"
 	"github.com/songzhibin97/stargate/internal/config"
 	"github.com/songzhibin97/stargate/internal/tls"
 	"github.com/songzhibin97/stargate/internal/tracing"
+	pkgconfig "github.com/songzhibin97/stargate/pkg/config"
 )
 
 // Server represents the proxy server
 type Server struct {
 	config         *config.Config
-	httpServer     *http.Server
+	listeners      []*httpListener
 	pipeline       *Pipeline
+	streamProxy    *StreamProxy
 	acmeManager    *tls.ACMEManager
 	tracerProvider *tracing.TracerProvider
 }
@@ -36,46 +41,44 @@ func NewServer(cfg *config.Config) (*Server, error) {
 		return nil, fmt.Errorf("failed to create pipeline: %w", err)
 	}
 
-	// Create ACME manager if enabled
+	listenerConfigs := cfg.Server.HTTPListeners()
+
+	// Create ACME manager if enabled, shared by the listeners using ACME
 	var acmeManager *tls.ACMEManager
-	if cfg.Server.TLS.Enabled && cfg.Server.TLS.ACME.Enabled {
-		acmeManager, err = tls.NewACMEManager(&cfg.Server.TLS.ACME)
-		if err != nil {
-			return nil, fmt.Errorf("failed to create ACME manager: %w", err)
+	for _, listenerConfig := range listenerConfigs {
+		if listenerConfig.TLS.Enabled && listenerConfig.TLS.ACME.Enabled {
+			acmeManager, err = tls.NewACMEManager(&listenerConfig.TLS.ACME)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create ACME manager: %w", err)
+			}
+			break
 		}
 	}
 
-	// Create HTTP server
-	httpServer := &http.Server{
-		Addr:           cfg.Server.Address,
-		Handler:        pipeline,
-		ReadTimeout:    cfg.Server.ReadTimeout,
-		WriteTimeout:   cfg.Server.WriteTimeout,
-		IdleTimeout:    cfg.Server.IdleTimeout,
-		MaxHeaderBytes: cfg.Server.MaxHeaderBytes,
+	if acmeManager != nil {
+		pipeline.SetACMEManager(acmeManager)
 	}
 
-	// Configure TLS if enabled
-	if cfg.Server.TLS.Enabled {
-		if acmeManager != nil {
-			// Use ACME-managed certificates
-			httpServer.TLSConfig = acmeManager.GetTLSConfig()
-			// Wrap handler to handle ACME challenges
-			httpServer.Handler = acmeManager.GetHTTPHandler(pipeline)
-		}
+	// Create an HTTP server per listener
+	listeners := make([]*httpListener, 0, len(listenerConfigs))
+	for _, listenerConfig := range listenerConfigs {
+		listeners = append(listeners, newHTTPListener(cfg, listenerConfig, pipeline, acmeManager))
+	}
 
-		// Configure HTTP/2 support for TLS connections
-		if err := http2.ConfigureServer(httpServer, &http2.Server{}); err != nil {
-			log.Printf("Failed to configure HTTP/2 for proxy server: %v", err)
-		} else {
-			log.Println("HTTP/2 support enabled for proxy server TLS connections")
+	// Create L4 stream proxy if any stream listeners are configured
+	var streamProxy *StreamProxy
+	if len(cfg.Stream.Listeners) > 0 {
+		streamProxy, err = NewStreamProxy(&cfg.Stream, cfg.RateLimit.Connections, pipeline)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create stream proxy: %w", err)
 		}
 	}
 
 	return &Server{
 		config:         cfg,
-		httpServer:     httpServer,
+		listeners:      listeners,
 		pipeline:       pipeline,
+		streamProxy:    streamProxy,
 		acmeManager:    acmeManager,
 		tracerProvider: tracerProvider,
 	}, nil
@@ -96,56 +99,162 @@ func (s *Server) Start() error {
 		log.Printf("ACME manager started for domains: %v", s.acmeManager.GetDomains())
 	}
 
-	// Start HTTP server
-	if s.config.Server.TLS.Enabled {
-		if s.acmeManager != nil {
-			// Use ACME-managed certificates
-			return s.httpServer.ListenAndServeTLS("", "")
-		} else {
-			// Use static certificates
-			return s.httpServer.ListenAndServeTLS(
-				s.config.Server.TLS.CertFile,
-				s.config.Server.TLS.KeyFile,
-			)
+	// Start stream listeners
+	if s.streamProxy != nil {
+		if err := s.streamProxy.Start(); err != nil {
+			return fmt.Errorf("failed to start stream proxy: %w", err)
+		}
+	}
+
+	// Bind every listener before serving, accepting the PROXY protocol if enabled
+	netListeners := make([]net.Listener, 0, len(s.listeners))
+	for _, listener := range s.listeners {
+		netListener, err := s.listen(listener.server.Addr)
+		if err != nil {
+			for _, bound := range netListeners {
+				bound.Close()
+			}
+			return err
 		}
+		listener.setAddr(netListener.Addr())
+		netListeners = append(netListeners, netListener)
+	}
+
+	// Serve every listener and return the first error. After Shutdown every
+	// listener returns http.ErrServerClosed.
+	errs := make(chan error, len(s.listeners))
+	for i, listener := range s.listeners {
+		log.Printf("Listener %s serving on %s (tls=%v, profile=%q)", listener.config.Name, netListeners[i].Addr(), listener.config.TLS.Enabled, listener.config.Profile)
+		go func(listener *httpListener, netListener net.Listener) {
+			errs <- listener.serve(netListener, s.acmeManager != nil)
+		}(listener, netListeners[i])
 	}
+	return <-errs
+}
 
-	return s.httpServer.ListenAndServe()
+// Addr returns the bound address of the named HTTP listener, or nil if the
+// listener doesn't exist or isn't bound yet
+func (s *Server) Addr(name string) net.Addr {
+	for _, listener := range s.listeners {
+		if listener.config.Name == name {
+			return listener.getAddr()
+		}
+	}
+	return nil
 }
 
-// Shutdown gracefully shuts down the server
+// listen creates a server listener
+func (s *Server) listen(address string) (net.Listener, error) {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", address, err)
+	}
+
+	if !s.config.Server.ProxyProtocol.Enabled {
+		return listener, nil
+	}
+
+	proxyListener, err := newProxyProtocolListener(listener, s.config.Server.ProxyProtocol)
+	if err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("failed to configure proxy protocol: %w", err)
+	}
+	log.Printf("PROXY protocol enabled for trusted sources: %v", s.config.Server.ProxyProtocol.TrustedSources)
+	return proxyListener, nil
+}
+
+// LameDuck marks the server not ready and keeps serving for the configured
+// lame-duck duration, or until ctx is done, so load balancers that remove
+// endpoints asynchronously stop routing here before Shutdown stops accepting
+func (s *Server) LameDuck(ctx context.Context) {
+	s.pipeline.EnterLameDuck()
+
+	duration := s.config.Server.LameDuckDuration
+	if duration <= 0 {
+		return
+	}
+
+	log.Printf("Entering lame-duck period: reporting not ready and serving for %s", duration)
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		log.Println("Lame-duck period complete")
+	case <-ctx.Done():
+		log.Println("Lame-duck period cut short")
+	}
+}
+
+// Shutdown gracefully shuts down the server. The listener is closed and
+// in-flight connections are drained before the pipeline components are
+// stopped, all within the deadline of ctx.
 func (s *Server) Shutdown(ctx context.Context) error {
-	// Stop ACME manager first
-	if s.acmeManager != nil {
-		if err := s.acmeManager.Stop(); err != nil {
-			log.Printf("Failed to stop ACME manager: %v", err)
+	var errs []error
+
+	// Stop accepting connections and drain in-flight requests on all listeners
+	var wg sync.WaitGroup
+	listenerErrs := make([]error, len(s.listeners))
+	for i, listener := range s.listeners {
+		wg.Add(1)
+		go func(i int, listener *httpListener) {
+			defer wg.Done()
+			if err := listener.server.Shutdown(ctx); err != nil {
+				listenerErrs[i] = fmt.Errorf("http server %s: %w", listener.config.Name, err)
+			}
+		}(i, listener)
+	}
+	wg.Wait()
+	errs = append(errs, listenerErrs...)
+
+	// Stop stream listeners and drain open stream connections
+	if s.streamProxy != nil {
+		if err := s.streamProxy.Shutdown(ctx); err != nil {
+			errs = append(errs, err)
 		}
 	}
 
 	// Stop the pipeline
-	if err := s.pipeline.Stop(); err != nil {
-		return fmt.Errorf("failed to stop pipeline: %w", err)
+	if err := s.pipeline.Shutdown(ctx); err != nil {
+		errs = append(errs, err)
 	}
 
-	// Shutdown tracing
+	// Stop ACME manager
+	if s.acmeManager != nil {
+		if err := s.acmeManager.Stop(); err != nil {
+			errs = append(errs, fmt.Errorf("acme manager: %w", err))
+		}
+	}
+
+	// Shutdown tracing last so spans of drained requests are exported
 	if s.tracerProvider != nil {
 		if err := tracing.ShutdownGlobalTracer(ctx, s.tracerProvider); err != nil {
-			log.Printf("Failed to shutdown tracer: %v", err)
+			errs = append(errs, fmt.Errorf("tracer: %w", err))
 		}
 	}
 
-	// Shutdown HTTP server
-	return s.httpServer.Shutdown(ctx)
+	return errors.Join(errs...)
+}
+
+// SetConfigSource sets the config source whose state is reported in health
+func (s *Server) SetConfigSource(source pkgconfig.HealthReporter) {
+	s.pipeline.SetConfigSource(source)
 }
 
 // Health returns the health status of the server
 func (s *Server) Health() map[string]interface{} {
+	status := "healthy"
+	if !s.pipeline.Ready() {
+		status = "not_ready"
+	}
+
 	health := map[string]interface{}{
-		"status":    "healthy",
+		"status":    status,
 		"timestamp": time.Now().Unix(),
 		"server": map[string]interface{}{
-			"address": s.config.Server.Address,
-			"uptime":  time.Since(s.pipeline.startTime).Seconds(),
+			"address":   s.config.Server.Address,
+			"listeners": s.listenerStatus(),
+			"uptime":    time.Since(s.pipeline.startTime).Seconds(),
 		},
 	}
 
@@ -154,6 +263,15 @@ func (s *Server) Health() map[string]interface{} {
 		health["pipeline"] = pipelineHealth
 	}
 
+	if s.streamProxy != nil {
+		health["stream"] = s.streamProxy.Stats()
+	}
+
+	// Add certificate renewal state per ACME domain
+	if s.acmeManager != nil {
+		health["acme"] = s.acmeManager.Renewals(context.Background())
+	}
+
 	return health
 }
 
@@ -161,8 +279,9 @@ func (s *Server) Health() map[string]interface{} {
 func (s *Server) Metrics() map[string]interface{} {
 	metrics := map[string]interface{}{
 		"server": map[string]interface{}{
-			"address": s.config.Server.Address,
-			"uptime":  time.Since(s.pipeline.startTime).Seconds(),
+			"address":   s.config.Server.Address,
+			"listeners": s.listenerStatus(),
+			"uptime":    time.Since(s.pipeline.startTime).Seconds(),
 		},
 	}
 
@@ -171,9 +290,30 @@ func (s *Server) Metrics() map[string]interface{} {
 		metrics["pipeline"] = pipelineMetrics
 	}
 
+	if s.streamProxy != nil {
+		metrics["stream"] = s.streamProxy.Stats()
+	}
+
 	return metrics
 }
 
+// listenerStatus returns the address, TLS and profile of each HTTP listener
+func (s *Server) listenerStatus() map[string]interface{} {
+	status := make(map[string]interface{}, len(s.listeners))
+	for _, listener := range s.listeners {
+		address := listener.config.Address
+		if addr := listener.getAddr(); addr != nil {
+			address = addr.String()
+		}
+		status[listener.config.Name] = map[string]interface{}{
+			"address": address,
+			"tls":     listener.config.TLS.Enabled,
+			"profile": listener.config.Profile,
+		}
+	}
+	return status
+}
+
 // Reload reloads the server configuration
 func (s *Server) Reload(cfg *config.Config) error {
 	// Update configuration
@@ -182,3 +322,76 @@ func (s *Server) Reload(cfg *config.Config) error {
 	// Reload pipeline
 	return s.pipeline.Reload(cfg)
 }
+
+// httpListener is the HTTP server of a single configured listener
+type httpListener struct {
+	config config.ListenerConfig
+	server *http.Server
+
+	mu   sync.RWMutex
+	addr net.Addr
+}
+
+// newHTTPListener creates the HTTP server of a listener. Requests are tagged
+// with the listener name so the pipeline applies the listener's profile.
+func newHTTPListener(cfg *config.Config, listenerConfig config.ListenerConfig, pipeline *Pipeline, acmeManager *tls.ACMEManager) *httpListener {
+	server := &http.Server{
+		Addr:           listenerConfig.Address,
+		Handler:        pipeline.ListenerHandler(listenerConfig.Name),
+		ReadTimeout:    cfg.Server.ReadTimeout,
+		WriteTimeout:   cfg.Server.WriteTimeout,
+		IdleTimeout:    cfg.Server.IdleTimeout,
+		MaxHeaderBytes: cfg.Server.MaxHeaderBytes,
+	}
+
+	// Configure TLS if enabled
+	if listenerConfig.TLS.Enabled {
+		if listenerConfig.TLS.ACME.Enabled && acmeManager != nil {
+			// Use ACME-managed certificates
+			server.TLSConfig = acmeManager.GetTLSConfig()
+			// Wrap handler to handle ACME challenges
+			server.Handler = acmeManager.GetHTTPHandler(server.Handler)
+		}
+
+		// Configure HTTP/2 support for TLS connections
+		h2 := &http2.Server{
+			MaxConcurrentStreams: cfg.Server.HTTP2.MaxConcurrentStreams,
+			MaxReadFrameSize:     cfg.Server.HTTP2.MaxReadFrameSize,
+			IdleTimeout:          cfg.Server.HTTP2.IdleTimeout,
+		}
+		if err := http2.ConfigureServer(server, h2); err != nil {
+			log.Printf("Failed to configure HTTP/2 for listener %s: %v", listenerConfig.Name, err)
+		} else {
+			log.Printf("HTTP/2 support enabled for listener %s TLS connections", listenerConfig.Name)
+		}
+	}
+
+	return &httpListener{config: listenerConfig, server: server}
+}
+
+// serve serves HTTP or HTTPS on the bound listener
+func (l *httpListener) serve(listener net.Listener, acme bool) error {
+	if !l.config.TLS.Enabled {
+		return l.server.Serve(listener)
+	}
+	if l.config.TLS.ACME.Enabled && acme {
+		// Use ACME-managed certificates
+		return l.server.ServeTLS(listener, "", "")
+	}
+	// Use static certificates
+	return l.server.ServeTLS(listener, l.config.TLS.CertFile, l.config.TLS.KeyFile)
+}
+
+// setAddr records the bound address of the listener
+func (l *httpListener) setAddr(addr net.Addr) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.addr = addr
+}
+
+// getAddr returns the bound address of the listener, or nil if not bound
+func (l *httpListener) getAddr() net.Addr {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.addr
+}
@@ -0,0 +1,192 @@
+package proxy
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/songzhibin97/stargate/internal/config"
+	"github.com/songzhibin97/stargate/internal/types"
+)
+
+// defaultSessionAffinityTTL is how long a client stays pinned to a target
+// when no TTL is configured
+const defaultSessionAffinityTTL = time.Hour
+
+// sessionAffinity pins the clients of an upstream to a target with a cookie.
+// The cookie holds an opaque key of the target, so target addresses aren't
+// revealed, and its expiry, enforced by the gateway as well as the client.
+// Both are signed with HMAC-SHA256, so clients can't pick a target or
+// extend their pin by forging the cookie.
+type sessionAffinity struct {
+	upstreamID string
+	secret     []byte
+	cookieName string
+	path       string
+	ttl        time.Duration
+	secure     bool
+	httpOnly   bool
+	sameSite   http.SameSite
+
+	now func() time.Time
+}
+
+// newSessionAffinities creates the session affinities of the configured
+// upstreams, keyed by upstream ID, signing cookies with a secret
+func newSessionAffinities(cfg map[string]config.SessionAffinityConfig, secret []byte) map[string]*sessionAffinity {
+	affinities := make(map[string]*sessionAffinity, len(cfg))
+	for upstreamID, affinity := range cfg {
+		a := &sessionAffinity{
+			upstreamID: upstreamID,
+			secret:     secret,
+			cookieName: affinity.CookieName,
+			path:       affinity.Path,
+			ttl:        affinity.TTL,
+			secure:     affinity.Secure,
+			httpOnly:   affinity.HTTPOnly,
+			sameSite:   http.SameSiteLaxMode,
+			now:        time.Now,
+		}
+		if a.cookieName == "" {
+			a.cookieName = "stargate_affinity_" + upstreamID
+		}
+		if a.path == "" {
+			a.path = "/"
+		}
+		if a.ttl <= 0 {
+			a.ttl = defaultSessionAffinityTTL
+		}
+		switch strings.ToLower(affinity.SameSite) {
+		case "strict":
+			a.sameSite = http.SameSiteStrictMode
+		case "none":
+			a.sameSite = http.SameSiteNoneMode
+		}
+		affinities[upstreamID] = a
+	}
+	return affinities
+}
+
+// targetKey returns the opaque key of a target in the cookie
+func (a *sessionAffinity) targetKey(target *types.Target) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s|%s:%d", a.upstreamID, target.Host, target.Port)))
+	return hex.EncodeToString(sum[:8])
+}
+
+// signature returns the signature of a target key and expiry
+func (a *sessionAffinity) signature(key, expiry string) string {
+	mac := hmac.New(sha256.New, a.secret)
+	mac.Write([]byte(a.upstreamID + "|" + key + "|" + expiry))
+	return hex.EncodeToString(mac.Sum(nil)[:16])
+}
+
+// pinned returns the healthy target the request's cookie pins it to, or nil
+// if it has no unexpired cookie with a valid signature or the target is gone
+// or unhealthy
+func (a *sessionAffinity) pinned(r *http.Request, upstream *types.Upstream) *types.Target {
+	cookie, err := r.Cookie(a.cookieName)
+	if err != nil {
+		return nil
+	}
+	parts := strings.Split(cookie.Value, ".")
+	if len(parts) != 3 {
+		return nil
+	}
+	key, expiry, signature := parts[0], parts[1], parts[2]
+	if !hmac.Equal([]byte(signature), []byte(a.signature(key, expiry))) {
+		return nil
+	}
+	expiresAt, err := strconv.ParseInt(expiry, 10, 64)
+	if err != nil || !a.now().Before(time.Unix(expiresAt, 0)) {
+		return nil
+	}
+
+	for _, target := range upstream.Targets {
+		if a.targetKey(target) == key {
+			if !target.Healthy {
+				return nil
+			}
+			return target
+		}
+	}
+	return nil
+}
+
+// pin sets the cookie pinning the client to the target
+func (a *sessionAffinity) pin(w http.ResponseWriter, target *types.Target) {
+	key := a.targetKey(target)
+	expiry := strconv.FormatInt(a.now().Add(a.ttl).Unix(), 10)
+	http.SetCookie(w, &http.Cookie{
+		Name:     a.cookieName,
+		Value:    key + "." + expiry + "." + a.signature(key, expiry),
+		Path:     a.path,
+		MaxAge:   int(a.ttl.Seconds()),
+		Secure:   a.secure,
+		HttpOnly: a.httpOnly,
+		SameSite: a.sameSite,
+	})
+}
+
+// sessionAffinitySecret returns the secret signing affinity cookies: the
+// configured one, shared by the gateway nodes, or else a random one
+// generated once per pipeline, so pins don't survive restarts and don't
+// hold across nodes. Called with the pipeline lock held.
+func (p *Pipeline) sessionAffinitySecret(secret string) []byte {
+	if secret != "" {
+		return []byte(secret)
+	}
+	if p.randomAffinitySecret == nil {
+		p.randomAffinitySecret = make([]byte, 32)
+		rand.Read(p.randomAffinitySecret)
+	}
+	return p.randomAffinitySecret
+}
+
+// sessionAffinity returns the session affinity of an upstream, or nil
+func (p *Pipeline) sessionAffinity(upstreamID string) *sessionAffinity {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.sessionAffinities[upstreamID]
+}
+
+// selectAffinityTarget selects the target of a request routed to an
+// upstream, directly or as a fallback. Clients of an upstream with session
+// affinity stay on the target their cookie pins them to while it is
+// healthy; otherwise the load balancer picks a target and the client is
+// pinned to it.
+func (p *Pipeline) selectAffinityTarget(w http.ResponseWriter, r *http.Request, upstream *types.Upstream) (*types.Target, error) {
+	affinity := p.sessionAffinity(upstream.ID)
+	if affinity == nil {
+		return p.selectTarget(upstream, r)
+	}
+
+	if target := affinity.pinned(r, upstream); target != nil {
+		return target, nil
+	}
+
+	target, err := p.selectTarget(upstream, r)
+	if err != nil {
+		return nil, err
+	}
+	affinity.pin(w, target)
+	return target, nil
+}
+
+// selectPinnedTarget selects the target of a request re-routed to an
+// upstream by a response. The request stays on the target its cookie pins
+// it to, but isn't pinned otherwise: re-routes are decided per response,
+// and the response setting the cookie is the one of the re-routed request.
+func (p *Pipeline) selectPinnedTarget(r *http.Request, upstream *types.Upstream) (*types.Target, error) {
+	if affinity := p.sessionAffinity(upstream.ID); affinity != nil {
+		if target := affinity.pinned(r, upstream); target != nil {
+			return target, nil
+		}
+	}
+	return p.selectTarget(upstream, r)
+}
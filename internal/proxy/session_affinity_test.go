@@ -0,0 +1,176 @@
+package proxy
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/songzhibin97/stargate/internal/config"
+	"github.com/songzhibin97/stargate/internal/loadbalancer"
+	"github.com/songzhibin97/stargate/internal/types"
+)
+
+func TestPipeline_SessionAffinity(t *testing.T) {
+	var targets []*types.Target
+	for _, name := range []string{"a", "b", "c"} {
+		name := name
+		backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(name))
+		}))
+		defer backend.Close()
+		host, portStr, _ := net.SplitHostPort(strings.TrimPrefix(backend.URL, "http://"))
+		port, _ := strconv.Atoi(portStr)
+		targets = append(targets, &types.Target{Host: host, Port: port, Weight: 100, Healthy: true})
+	}
+
+	cfg := &config.Config{}
+	cfg.Upstreams.Affinity = map[string]config.SessionAffinityConfig{
+		"orders": {TTL: time.Minute, HTTPOnly: true, SameSite: "strict"},
+	}
+	pipeline, err := NewPipeline(cfg, nil)
+	if err != nil {
+		t.Fatalf("Failed to create pipeline: %v", err)
+	}
+	defer pipeline.Stop()
+
+	now := time.Now()
+	pipeline.sessionAffinities["orders"].now = func() time.Time { return now }
+
+	lb := loadbalancer.NewRoundRobinBalancer(cfg)
+	if err := lb.UpdateUpstream(&types.Upstream{
+		ID:        "orders",
+		Name:      "orders",
+		Algorithm: "round_robin",
+		Targets:   targets,
+	}); err != nil {
+		t.Fatalf("Failed to add upstream: %v", err)
+	}
+	pipeline.loadBalancer = lb
+	pipeline.router = &staticRouter{route: &Route{ID: "orders", UpstreamID: "orders"}}
+	handler := pipeline.createHandler()
+
+	serve := func(cookie *http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/orders", nil)
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d", rr.Code)
+		}
+		return rr
+	}
+	affinityCookie := func(rr *httptest.ResponseRecorder) *http.Cookie {
+		for _, cookie := range rr.Result().Cookies() {
+			if cookie.Name == "stargate_affinity_orders" {
+				return cookie
+			}
+		}
+		return nil
+	}
+
+	// The first request is pinned to the target the load balancer picked
+	rr := serve(nil)
+	pinnedTo := rr.Body.String()
+	cookie := affinityCookie(rr)
+	if cookie == nil {
+		t.Fatal("Expected an affinity cookie")
+	}
+	if cookie.Path != "/" || cookie.MaxAge != 60 || !cookie.HttpOnly || cookie.SameSite != http.SameSiteStrictMode {
+		t.Errorf("Unexpected cookie attributes: %+v", cookie)
+	}
+	if strings.Contains(cookie.Value, targets[0].Host) {
+		t.Errorf("Expected an opaque target in the cookie, got %s", cookie.Value)
+	}
+
+	// Pinned requests stay on the target despite round robin
+	for i := 0; i < 5; i++ {
+		rr := serve(cookie)
+		if rr.Body.String() != pinnedTo {
+			t.Fatalf("Expected request pinned to %s, got %s", pinnedTo, rr.Body.String())
+		}
+		if affinityCookie(rr) != nil {
+			t.Error("Expected no new cookie for a pinned request")
+		}
+	}
+
+	// A request pinned to an unhealthy target fails over and is re-pinned
+	pinnedTarget := targets[strings.Index("abc", pinnedTo)]
+	lb.UpdateTargetHealth("orders", pinnedTarget.Host, pinnedTarget.Port, false)
+	rr = serve(cookie)
+	if rr.Body.String() == pinnedTo {
+		t.Fatalf("Expected failover from unhealthy target %s", pinnedTo)
+	}
+	failedOverTo := rr.Body.String()
+	if cookie = affinityCookie(rr); cookie == nil {
+		t.Fatal("Expected the client re-pinned after failover")
+	}
+	lb.UpdateTargetHealth("orders", pinnedTarget.Host, pinnedTarget.Port, true)
+	for i := 0; i < 3; i++ {
+		if rr := serve(cookie); rr.Body.String() != failedOverTo {
+			t.Fatalf("Expected request pinned to %s after failover, got %s", failedOverTo, rr.Body.String())
+		}
+	}
+
+	// An expired pin is ignored even if the client still sends it
+	now = now.Add(time.Minute)
+	if cookie = affinityCookie(serve(cookie)); cookie == nil {
+		t.Fatal("Expected the client re-pinned after expiry")
+	}
+
+	// Forged cookies are ignored: pinning to another target, extending the
+	// expiry or leaving out the signature
+	affinity := pipeline.sessionAffinities["orders"]
+	parts := strings.Split(cookie.Value, ".")
+	if len(parts) != 3 {
+		t.Fatalf("Expected a signed cookie, got %s", cookie.Value)
+	}
+	for _, forged := range []string{
+		affinity.targetKey(targets[0]) + "." + parts[1] + "." + parts[2],
+		parts[0] + "." + strconv.FormatInt(now.Add(time.Hour).Unix(), 10) + "." + parts[2],
+		parts[0] + "." + parts[1],
+	} {
+		if affinityCookie(serve(&http.Cookie{Name: cookie.Name, Value: forged})) == nil {
+			t.Errorf("Expected forged cookie %s to be ignored", forged)
+		}
+	}
+
+	// Upstreams without affinity aren't pinned
+	delete(pipeline.sessionAffinities, "orders")
+	if affinityCookie(serve(nil)) != nil {
+		t.Error("Expected no affinity cookie without session affinity")
+	}
+}
+
+func TestSessionAffinity_Secret(t *testing.T) {
+	target := &types.Target{Host: "10.0.0.1", Port: 8080, Weight: 100, Healthy: true}
+	upstream := &types.Upstream{ID: "orders", Targets: []*types.Target{target}}
+	cfg := map[string]config.SessionAffinityConfig{"orders": {}}
+
+	rr := httptest.NewRecorder()
+	newSessionAffinities(cfg, []byte("secret"))["orders"].pin(rr, target)
+	req := httptest.NewRequest("GET", "/orders", nil)
+	req.AddCookie(rr.Result().Cookies()[0])
+
+	// Nodes sharing the secret honor each other's cookies
+	if newSessionAffinities(cfg, []byte("secret"))["orders"].pinned(req, upstream) != target {
+		t.Error("Expected the cookie to pin the request with the same secret")
+	}
+	if newSessionAffinities(cfg, []byte("other"))["orders"].pinned(req, upstream) != nil {
+		t.Error("Expected the cookie to be rejected with another secret")
+	}
+
+	// Without a configured secret a random one is used
+	pipeline := &Pipeline{}
+	if key := pipeline.sessionAffinitySecret(""); len(key) != 32 || string(pipeline.sessionAffinitySecret("")) != string(key) {
+		t.Errorf("Expected a stable random secret, got %x", key)
+	}
+	if key := pipeline.sessionAffinitySecret("configured"); string(key) != "configured" {
+		t.Errorf("Expected the configured secret, got %q", key)
+	}
+}
@@ -0,0 +1,519 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/songzhibin97/stargate/internal/config"
+	"github.com/songzhibin97/stargate/internal/health"
+	"github.com/songzhibin97/stargate/internal/loadbalancer"
+	"github.com/songzhibin97/stargate/internal/ratelimit"
+	"github.com/songzhibin97/stargate/internal/types"
+)
+
+const (
+	// defaultStreamConnectTimeout is the default timeout dialing a stream target
+	defaultStreamConnectTimeout = 5 * time.Second
+
+	// defaultStreamTCPIdleTimeout is the default idle time before a TCP connection is closed
+	defaultStreamTCPIdleTimeout = 10 * time.Minute
+
+	// defaultStreamUDPIdleTimeout is the default idle time before a UDP session is closed
+	defaultStreamUDPIdleTimeout = time.Minute
+
+	// streamBufferSize is the size of the buffers used to copy stream data
+	streamBufferSize = 32 * 1024
+
+	// udpMaxDatagramSize is the largest UDP payload read from clients and targets
+	udpMaxDatagramSize = 64 * 1024
+)
+
+// streamTargetSelector selects targets of stream listener upstreams and
+// receives the outcome of connecting to them and of connection limiting
+type streamTargetSelector interface {
+	selectStreamTarget(upstreamID, clientIP string) (*types.Target, error)
+	recordStreamResult(upstreamID string, target *types.Target, err error, duration time.Duration)
+	recordRejectedConnection(listener, reason string)
+}
+
+// StreamProxy serves the configured TCP and UDP stream listeners, piping
+// bytes between clients and targets of the listener's upstream. Targets are
+// selected by the HTTP pipeline's load balancer, so stream upstreams share
+// its health checking, but no HTTP middleware is applied.
+type StreamProxy struct {
+	listeners []config.StreamListenerConfig
+	selector  streamTargetSelector
+	limiters  map[string]*ratelimit.ConnectionLimiter // by listener name, nil if unlimited
+
+	mu          sync.Mutex
+	closed      bool
+	addrs       map[string]net.Addr
+	tcpListener []net.Listener
+	udpConns    []net.PacketConn
+	conns       map[io.Closer]struct{} // client and target connections, closed on forced shutdown
+	wg          sync.WaitGroup
+
+	// Statistics
+	activeConnections   int64
+	totalConnections    int64
+	failedConnections   int64
+	rejectedConnections int64
+	bytesReceived       int64 // client to target
+	bytesSent           int64 // target to client
+}
+
+// NewStreamProxy creates a stream proxy for the configured listeners. Each
+// listener is limited by its own connection limits if set, or by limits.
+func NewStreamProxy(cfg *config.StreamConfig, limits config.ConnectionLimitConfig, selector streamTargetSelector) (*StreamProxy, error) {
+	limiters := make(map[string]*ratelimit.ConnectionLimiter, len(cfg.Listeners))
+	for _, listenerCfg := range cfg.Listeners {
+		listenerLimits := limits
+		if listenerCfg.ConnectionLimit != nil {
+			listenerLimits = *listenerCfg.ConnectionLimit
+		}
+		limiter, err := ratelimit.NewConnectionLimiter(listenerLimits)
+		if err != nil {
+			return nil, fmt.Errorf("stream listener %s: invalid connection limit: %w", listenerCfg.Name, err)
+		}
+		limiters[listenerCfg.Name] = limiter
+	}
+
+	return &StreamProxy{
+		listeners: cfg.Listeners,
+		selector:  selector,
+		limiters:  limiters,
+		addrs:     make(map[string]net.Addr),
+		conns:     make(map[io.Closer]struct{}),
+	}, nil
+}
+
+// Start binds all stream listeners and starts serving them. If any listener
+// fails to bind, the listeners already bound are closed.
+func (s *StreamProxy) Start() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, listenerCfg := range s.listeners {
+		switch listenerCfg.Protocol {
+		case config.StreamProtocolUDP:
+			conn, err := net.ListenPacket("udp", listenerCfg.Address)
+			if err != nil {
+				s.closeListeners()
+				return fmt.Errorf("stream listener %s: failed to listen on udp %s: %w", listenerCfg.Name, listenerCfg.Address, err)
+			}
+			s.udpConns = append(s.udpConns, conn)
+			s.addrs[listenerCfg.Name] = conn.LocalAddr()
+
+			s.wg.Add(1)
+			go s.serveUDP(conn, listenerCfg)
+		default:
+			listener, err := net.Listen("tcp", listenerCfg.Address)
+			if err != nil {
+				s.closeListeners()
+				return fmt.Errorf("stream listener %s: failed to listen on tcp %s: %w", listenerCfg.Name, listenerCfg.Address, err)
+			}
+			s.tcpListener = append(s.tcpListener, listener)
+			s.addrs[listenerCfg.Name] = listener.Addr()
+
+			s.wg.Add(1)
+			go s.serveTCP(listener, listenerCfg)
+		}
+
+		log.Printf("Stream listener %s proxying %s %s to upstream %s",
+			listenerCfg.Name, protocolOf(listenerCfg), s.addrs[listenerCfg.Name], listenerCfg.UpstreamID)
+	}
+
+	return nil
+}
+
+// Addr returns the bound address of the named listener, or nil if it is not listening
+func (s *StreamProxy) Addr(name string) net.Addr {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.addrs[name]
+}
+
+// Shutdown stops accepting connections and waits for open TCP connections to
+// finish. Connections still open when ctx is done are closed. UDP sessions
+// have no end of stream and are closed immediately.
+func (s *StreamProxy) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	s.closed = true
+	s.closeListeners()
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+	}
+
+	s.mu.Lock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+
+	<-done
+	return fmt.Errorf("stream proxy: %w", ctx.Err())
+}
+
+// closeListeners closes all bound listeners. The caller must hold s.mu.
+func (s *StreamProxy) closeListeners() {
+	for _, listener := range s.tcpListener {
+		listener.Close()
+	}
+	for _, conn := range s.udpConns {
+		conn.Close()
+	}
+	s.tcpListener = nil
+	s.udpConns = nil
+}
+
+// Stats returns stream proxy statistics
+func (s *StreamProxy) Stats() map[string]interface{} {
+	return map[string]interface{}{
+		"listeners":            len(s.listeners),
+		"active_connections":   atomic.LoadInt64(&s.activeConnections),
+		"total_connections":    atomic.LoadInt64(&s.totalConnections),
+		"failed_connections":   atomic.LoadInt64(&s.failedConnections),
+		"rejected_connections": atomic.LoadInt64(&s.rejectedConnections),
+		"bytes_received":       atomic.LoadInt64(&s.bytesReceived),
+		"bytes_sent":           atomic.LoadInt64(&s.bytesSent),
+	}
+}
+
+// serveTCP accepts TCP connections until the listener is closed
+func (s *StreamProxy) serveTCP(listener net.Listener, cfg config.StreamListenerConfig) {
+	defer s.wg.Done()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if !s.isClosed() {
+				log.Printf("Stream listener %s stopped accepting: %v", cfg.Name, err)
+			}
+			return
+		}
+
+		s.wg.Add(1)
+		go s.handleTCP(conn, cfg)
+	}
+}
+
+// handleTCP connects a client to a target of the listener's upstream and
+// copies data in both directions until both sides are done or idle
+func (s *StreamProxy) handleTCP(client net.Conn, cfg config.StreamListenerConfig) {
+	defer s.wg.Done()
+	defer client.Close()
+
+	atomic.AddInt64(&s.totalConnections, 1)
+	if !s.track(client) {
+		return
+	}
+	defer s.untrack(client)
+
+	release, allowed := s.acquire(cfg, client.RemoteAddr())
+	if !allowed {
+		return
+	}
+	defer release()
+
+	target, upstream, err := s.dial(cfg, "tcp", client.RemoteAddr())
+	if err != nil {
+		atomic.AddInt64(&s.failedConnections, 1)
+		log.Printf("Stream listener %s: %v", cfg.Name, err)
+		return
+	}
+	defer upstream.Close()
+	if !s.track(upstream) {
+		return
+	}
+	defer s.untrack(upstream)
+
+	atomic.AddInt64(&s.activeConnections, 1)
+	defer atomic.AddInt64(&s.activeConnections, -1)
+
+	idleTimeout := cfg.IdleTimeout
+	if idleTimeout <= 0 {
+		idleTimeout = defaultStreamTCPIdleTimeout
+	}
+
+	var lastActivity atomic.Int64
+	lastActivity.Store(time.Now().UnixNano())
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		s.pipe(upstream, client, idleTimeout, &lastActivity, &s.bytesReceived)
+	}()
+	go func() {
+		defer wg.Done()
+		s.pipe(client, upstream, idleTimeout, &lastActivity, &s.bytesSent)
+	}()
+	wg.Wait()
+
+	log.Printf("Stream listener %s closed connection from %s to %s:%d", cfg.Name, client.RemoteAddr(), target.Host, target.Port)
+}
+
+// pipe copies src to dst until src is done. End of stream is forwarded as a
+// half-close so the other direction can finish; errors and idle timeouts
+// close both connections. The connection is idle when neither direction has
+// carried data for idleTimeout.
+func (s *StreamProxy) pipe(dst, src net.Conn, idleTimeout time.Duration, lastActivity *atomic.Int64, counter *int64) {
+	buf := make([]byte, streamBufferSize)
+	for {
+		src.SetReadDeadline(time.Now().Add(idleTimeout))
+		n, err := src.Read(buf)
+		if n > 0 {
+			lastActivity.Store(time.Now().UnixNano())
+			if _, werr := dst.Write(buf[:n]); werr != nil {
+				src.Close()
+				return
+			}
+			atomic.AddInt64(counter, int64(n))
+		}
+		if err == nil {
+			continue
+		}
+
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() &&
+			time.Since(time.Unix(0, lastActivity.Load())) < idleTimeout {
+			// The other direction is still active
+			continue
+		}
+
+		if errors.Is(err, io.EOF) {
+			if tcp, ok := dst.(*net.TCPConn); ok {
+				tcp.CloseWrite()
+				return
+			}
+		}
+		dst.Close()
+		src.Close()
+		return
+	}
+}
+
+// serveUDP relays datagrams until the listener is closed. Each client
+// address gets a session with its own connection to a selected target,
+// which is closed after the session has been idle for the idle timeout.
+func (s *StreamProxy) serveUDP(conn net.PacketConn, cfg config.StreamListenerConfig) {
+	defer s.wg.Done()
+
+	idleTimeout := cfg.IdleTimeout
+	if idleTimeout <= 0 {
+		idleTimeout = defaultStreamUDPIdleTimeout
+	}
+
+	var mu sync.Mutex
+	sessions := make(map[string]net.Conn)
+	var sessionsWG sync.WaitGroup
+	defer func() {
+		mu.Lock()
+		for _, upstream := range sessions {
+			upstream.Close()
+		}
+		mu.Unlock()
+		sessionsWG.Wait()
+	}()
+
+	buf := make([]byte, udpMaxDatagramSize)
+	for {
+		n, clientAddr, err := conn.ReadFrom(buf)
+		if err != nil {
+			if !s.isClosed() {
+				log.Printf("Stream listener %s stopped reading: %v", cfg.Name, err)
+			}
+			return
+		}
+
+		key := clientAddr.String()
+		mu.Lock()
+		upstream, exists := sessions[key]
+		mu.Unlock()
+
+		if !exists {
+			atomic.AddInt64(&s.totalConnections, 1)
+			release, allowed := s.acquire(cfg, clientAddr)
+			if !allowed {
+				continue
+			}
+
+			_, upstream, err = s.dial(cfg, "udp", clientAddr)
+			if err != nil {
+				release()
+				atomic.AddInt64(&s.failedConnections, 1)
+				log.Printf("Stream listener %s: %v", cfg.Name, err)
+				continue
+			}
+
+			mu.Lock()
+			sessions[key] = upstream
+			mu.Unlock()
+			atomic.AddInt64(&s.activeConnections, 1)
+
+			sessionsWG.Add(1)
+			go func() {
+				defer sessionsWG.Done()
+				defer release()
+				defer atomic.AddInt64(&s.activeConnections, -1)
+				s.relayUDPReplies(conn, clientAddr, upstream, idleTimeout)
+
+				mu.Lock()
+				delete(sessions, key)
+				mu.Unlock()
+			}()
+		}
+
+		upstream.SetWriteDeadline(time.Now().Add(idleTimeout))
+		if written, err := upstream.Write(buf[:n]); err == nil {
+			atomic.AddInt64(&s.bytesReceived, int64(written))
+		}
+	}
+}
+
+// relayUDPReplies sends datagrams from a session's target back to the client
+// until the session is idle or closed
+func (s *StreamProxy) relayUDPReplies(conn net.PacketConn, clientAddr net.Addr, upstream net.Conn, idleTimeout time.Duration) {
+	defer upstream.Close()
+
+	buf := make([]byte, udpMaxDatagramSize)
+	for {
+		upstream.SetReadDeadline(time.Now().Add(idleTimeout))
+		n, err := upstream.Read(buf)
+		if err != nil {
+			return
+		}
+		if written, err := conn.WriteTo(buf[:n], clientAddr); err == nil {
+			atomic.AddInt64(&s.bytesSent, int64(written))
+		}
+	}
+}
+
+// acquire reserves a connection for the client under the listener's
+// connection limits, recording rejected connections
+func (s *StreamProxy) acquire(cfg config.StreamListenerConfig, clientAddr net.Addr) (func(), bool) {
+	release, reason, allowed := s.limiters[cfg.Name].Acquire(hostOf(clientAddr))
+	if !allowed {
+		atomic.AddInt64(&s.rejectedConnections, 1)
+		s.selector.recordRejectedConnection(cfg.Name, reason)
+		log.Printf("Stream listener %s rejected connection from %s: %s", cfg.Name, clientAddr, reason)
+	}
+	return release, allowed
+}
+
+// dial selects a target of the listener's upstream for the client and
+// connects to it, reporting the outcome for passive health checking
+func (s *StreamProxy) dial(cfg config.StreamListenerConfig, network string, clientAddr net.Addr) (*types.Target, net.Conn, error) {
+	target, err := s.selector.selectStreamTarget(cfg.UpstreamID, hostOf(clientAddr))
+	if err != nil {
+		return nil, nil, fmt.Errorf("no target for %s: %w", clientAddr, err)
+	}
+
+	connectTimeout := cfg.ConnectTimeout
+	if connectTimeout <= 0 {
+		connectTimeout = defaultStreamConnectTimeout
+	}
+
+	start := time.Now()
+	address := net.JoinHostPort(target.Host, strconv.Itoa(target.Port))
+	conn, err := net.DialTimeout(network, address, connectTimeout)
+	s.selector.recordStreamResult(cfg.UpstreamID, target, err, time.Since(start))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to connect %s to %s: %w", clientAddr, address, err)
+	}
+	return target, conn, nil
+}
+
+// track registers a connection to be closed on forced shutdown, returning
+// false if the proxy is already shut down
+func (s *StreamProxy) track(conn io.Closer) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return false
+	}
+	s.conns[conn] = struct{}{}
+	return true
+}
+
+// untrack removes a connection registered by track
+func (s *StreamProxy) untrack(conn io.Closer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.conns, conn)
+}
+
+// isClosed reports whether the proxy has been shut down
+func (s *StreamProxy) isClosed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closed
+}
+
+// hostOf returns the IP of a client address
+func hostOf(addr net.Addr) string {
+	if host, _, err := net.SplitHostPort(addr.String()); err == nil {
+		return host
+	}
+	return addr.String()
+}
+
+// protocolOf returns the protocol of a listener, defaulting to TCP
+func protocolOf(cfg config.StreamListenerConfig) string {
+	if cfg.Protocol == "" {
+		return config.StreamProtocolTCP
+	}
+	return cfg.Protocol
+}
+
+// selectStreamTarget selects a target of the upstream for a stream client
+// using the pipeline's load balancer. Session affinity is cookie-based and
+// doesn't apply to stream clients, which the IP hash balancer keeps on a
+// target instead.
+func (p *Pipeline) selectStreamTarget(upstreamID, clientIP string) (*types.Target, error) {
+	upstream := p.getUpstream(upstreamID)
+	if upstream == nil {
+		return nil, fmt.Errorf("upstream %s not found", upstreamID)
+	}
+
+	if _, ok := p.loadBalancer.(*loadbalancer.IPHashBalancer); ok {
+		return p.selectTargetByClientIP(upstream, clientIP)
+	}
+	return p.loadBalancer.Select(upstream)
+}
+
+// recordStreamResult reports the outcome of connecting to a stream target
+// to the passive health checker
+func (p *Pipeline) recordStreamResult(upstreamID string, target *types.Target, err error, duration time.Duration) {
+	if p.passiveHealthChecker == nil {
+		return
+	}
+
+	var netErr net.Error
+	p.passiveHealthChecker.RecordRequest(&health.RequestResult{
+		UpstreamID: upstreamID,
+		Target:     target,
+		Error:      err,
+		Duration:   duration,
+		IsTimeout:  errors.As(err, &netErr) && netErr.Timeout(),
+		Timestamp:  time.Now().Add(-duration),
+	})
+}
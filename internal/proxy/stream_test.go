@@ -0,0 +1,251 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/songzhibin97/stargate/internal/config"
+	"github.com/songzhibin97/stargate/internal/types"
+)
+
+// fakeStreamSelector selects a fixed target and records dial results
+type fakeStreamSelector struct {
+	target *types.Target
+
+	mu       sync.Mutex
+	results  []error
+	rejected []string
+}
+
+func (f *fakeStreamSelector) selectStreamTarget(upstreamID, clientIP string) (*types.Target, error) {
+	if f.target == nil {
+		return nil, fmt.Errorf("upstream %s not found", upstreamID)
+	}
+	return f.target, nil
+}
+
+func (f *fakeStreamSelector) recordStreamResult(upstreamID string, target *types.Target, err error, duration time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.results = append(f.results, err)
+}
+
+func (f *fakeStreamSelector) recordRejectedConnection(listener, reason string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.rejected = append(f.rejected, listener+":"+reason)
+}
+
+// targetOf returns the target of a listener address
+func targetOf(t *testing.T, addr net.Addr) *types.Target {
+	host, port, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		t.Fatalf("Failed to parse address: %v", err)
+	}
+	portNum, _ := strconv.Atoi(port)
+	return &types.Target{Host: host, Port: portNum, Healthy: true}
+}
+
+// startStreamProxy starts a stream proxy with a single listener
+func startStreamProxy(t *testing.T, protocol string, selector streamTargetSelector) *StreamProxy {
+	return startLimitedStreamProxy(t, protocol, nil, selector)
+}
+
+// startLimitedStreamProxy starts a stream proxy with a single listener
+// limited by limit
+func startLimitedStreamProxy(t *testing.T, protocol string, limit *config.ConnectionLimitConfig, selector streamTargetSelector) *StreamProxy {
+	proxy, err := NewStreamProxy(&config.StreamConfig{
+		Listeners: []config.StreamListenerConfig{
+			{Name: "test", Address: "127.0.0.1:0", Protocol: protocol, UpstreamID: "backend", ConnectionLimit: limit},
+		},
+	}, config.ConnectionLimitConfig{}, selector)
+	if err != nil {
+		t.Fatalf("Failed to create stream proxy: %v", err)
+	}
+	if err := proxy.Start(); err != nil {
+		t.Fatalf("Failed to start stream proxy: %v", err)
+	}
+	t.Cleanup(func() { proxy.Shutdown(context.Background()) })
+	return proxy
+}
+
+func TestStreamProxy_TCP(t *testing.T) {
+	backend, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer backend.Close()
+	go func() {
+		for {
+			conn, err := backend.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				io.Copy(conn, conn)
+			}()
+		}
+	}()
+
+	selector := &fakeStreamSelector{target: targetOf(t, backend.Addr())}
+	proxy := startStreamProxy(t, config.StreamProtocolTCP, selector)
+
+	conn, err := net.Dial("tcp", proxy.Addr("test").String())
+	if err != nil {
+		t.Fatalf("Failed to dial proxy: %v", err)
+	}
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	io.WriteString(conn, "ping")
+	conn.(*net.TCPConn).CloseWrite()
+
+	reply, err := io.ReadAll(conn)
+	conn.Close()
+	if err != nil {
+		t.Fatalf("Failed to read reply: %v", err)
+	}
+	if string(reply) != "ping" {
+		t.Errorf("Expected echoed ping, got %q", reply)
+	}
+
+	if err := proxy.Shutdown(context.Background()); err != nil {
+		t.Errorf("Expected clean shutdown, got %v", err)
+	}
+
+	stats := proxy.Stats()
+	if stats["total_connections"] != int64(1) || stats["bytes_received"] != int64(4) || stats["bytes_sent"] != int64(4) {
+		t.Errorf("Unexpected stats: %v", stats)
+	}
+	if len(selector.results) != 1 || selector.results[0] != nil {
+		t.Errorf("Expected one successful dial result, got %v", selector.results)
+	}
+}
+
+func TestStreamProxy_TCPNoTarget(t *testing.T) {
+	proxy := startStreamProxy(t, config.StreamProtocolTCP, &fakeStreamSelector{})
+
+	conn, err := net.Dial("tcp", proxy.Addr("test").String())
+	if err != nil {
+		t.Fatalf("Failed to dial proxy: %v", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	if _, err := conn.Read(make([]byte, 1)); err != io.EOF {
+		t.Errorf("Expected connection to be closed, got %v", err)
+	}
+
+	proxy.Shutdown(context.Background())
+	if failed := proxy.Stats()["failed_connections"]; failed != int64(1) {
+		t.Errorf("Expected 1 failed connection, got %v", failed)
+	}
+}
+
+func TestStreamProxy_UDP(t *testing.T) {
+	backend, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer backend.Close()
+	go func() {
+		buf := make([]byte, 1024)
+		for {
+			n, addr, err := backend.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			backend.WriteTo(buf[:n], addr)
+		}
+	}()
+
+	proxy := startStreamProxy(t, config.StreamProtocolUDP, &fakeStreamSelector{target: targetOf(t, backend.LocalAddr())})
+
+	conn, err := net.Dial("udp", proxy.Addr("test").String())
+	if err != nil {
+		t.Fatalf("Failed to dial proxy: %v", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	buf := make([]byte, 1024)
+	for _, message := range []string{"one", "two"} {
+		io.WriteString(conn, message)
+		n, err := conn.Read(buf)
+		if err != nil {
+			t.Fatalf("Failed to read reply: %v", err)
+		}
+		if string(buf[:n]) != message {
+			t.Errorf("Expected echoed %s, got %q", message, buf[:n])
+		}
+	}
+
+	if sessions := proxy.Stats()["total_connections"]; sessions != int64(1) {
+		t.Errorf("Expected datagrams of one client to share a session, got %v sessions", sessions)
+	}
+}
+
+func TestStreamProxy_ConnectionLimit(t *testing.T) {
+	backend, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer backend.Close()
+	go func() {
+		for {
+			conn, err := backend.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				io.Copy(conn, conn)
+			}()
+		}
+	}()
+
+	selector := &fakeStreamSelector{target: targetOf(t, backend.Addr())}
+	proxy := startLimitedStreamProxy(t, config.StreamProtocolTCP, &config.ConnectionLimitConfig{
+		Enabled:  true,
+		MaxPerIP: 1,
+	}, selector)
+
+	first, err := net.Dial("tcp", proxy.Addr("test").String())
+	if err != nil {
+		t.Fatalf("Failed to dial proxy: %v", err)
+	}
+	defer first.Close()
+	first.SetDeadline(time.Now().Add(5 * time.Second))
+
+	// Wait for the first connection to be proxied before opening the second
+	io.WriteString(first, "a")
+	if _, err := first.Read(make([]byte, 1)); err != nil {
+		t.Fatalf("Failed to read reply: %v", err)
+	}
+
+	second, err := net.Dial("tcp", proxy.Addr("test").String())
+	if err != nil {
+		t.Fatalf("Failed to dial proxy: %v", err)
+	}
+	defer second.Close()
+	second.SetDeadline(time.Now().Add(5 * time.Second))
+
+	if _, err := second.Read(make([]byte, 1)); err != io.EOF {
+		t.Errorf("Expected second connection to be rejected, got %v", err)
+	}
+
+	selector.mu.Lock()
+	defer selector.mu.Unlock()
+	if len(selector.rejected) != 1 || selector.rejected[0] != "test:max_per_ip" {
+		t.Errorf("Expected one max_per_ip rejection, got %v", selector.rejected)
+	}
+	if len(selector.results) != 1 {
+		t.Errorf("Expected rejected connection not to dial the target, got %d dials", len(selector.results))
+	}
+}
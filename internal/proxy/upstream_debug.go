@@ -0,0 +1,111 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/songzhibin97/stargate/internal/middleware"
+)
+
+// UpstreamDebugRequest turns on the debug logging of an upstream
+type UpstreamDebugRequest struct {
+	Duration string `json:"duration,omitempty"` // How long debug logging stays on, such as "15m"
+}
+
+// UpstreamDebugResponse is the response listing the upstreams with debug
+// logging on
+type UpstreamDebugResponse struct {
+	Upstreams []middleware.UpstreamDebug `json:"upstreams"`
+	Total     int                        `json:"total"`
+}
+
+// upstreamDebugPath returns the path of the upstream debug logging endpoint,
+// or "" if the REST Admin API is disabled
+func (p *Pipeline) upstreamDebugPath() string {
+	return p.nodeAdminPath("/debug/upstreams")
+}
+
+// handleUpstreamDebug lists the upstreams with debug logging on (GET), and
+// turns debug logging of one on with PUT on <path>/<upstream ID> or off with
+// DELETE. Debug logging turns off on its own once its duration passes.
+// Changes are recorded in the audit log.
+func (p *Pipeline) handleUpstreamDebug(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	upstreamID := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, p.upstreamDebugPath()), "/")
+	if upstreamID == "" {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			json.NewEncoder(w).Encode(map[string]string{"error": "method not allowed"})
+			return
+		}
+		active := p.upstreamDebug.Active()
+		json.NewEncoder(w).Encode(UpstreamDebugResponse{Upstreams: active, Total: len(active)})
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		if p.getUpstream(upstreamID) == nil {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]string{"error": "upstream not found"})
+			return
+		}
+
+		var request UpstreamDebugRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&request); err != nil && err != io.EOF {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": fmt.Sprintf("invalid request body: %v", err)})
+			return
+		}
+		var duration time.Duration
+		if request.Duration != "" {
+			var err error
+			if duration, err = time.ParseDuration(request.Duration); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(map[string]string{"error": fmt.Sprintf("invalid duration: %v", err)})
+				return
+			}
+		}
+
+		debug, err := p.upstreamDebug.Enable(upstreamID, duration, r.RemoteAddr)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+
+		p.auditLogger.Log(middleware.AuditEntry{
+			Action:  "upstream.debug.enable",
+			Target:  upstreamID,
+			Caller:  r.RemoteAddr,
+			Details: map[string]string{"expires_at": debug.ExpiresAt.UTC().Format(time.RFC3339)},
+		})
+		log.Printf("Debug logging of upstream %s turned on by %s until %s", upstreamID, r.RemoteAddr, debug.ExpiresAt.Format(time.RFC3339))
+		json.NewEncoder(w).Encode(debug)
+	case http.MethodDelete:
+		if !p.upstreamDebug.Disable(upstreamID) {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]string{"error": "debug logging of the upstream is not on"})
+			return
+		}
+
+		p.auditLogger.Log(middleware.AuditEntry{
+			Action: "upstream.debug.disable",
+			Target: upstreamID,
+			Caller: r.RemoteAddr,
+		})
+		log.Printf("Debug logging of upstream %s turned off by %s", upstreamID, r.RemoteAddr)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.Header().Set("Allow", "PUT, DELETE")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(map[string]string{"error": "method not allowed"})
+	}
+}
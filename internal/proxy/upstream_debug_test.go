@@ -0,0 +1,137 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/songzhibin97/stargate/internal/config"
+	"github.com/songzhibin97/stargate/internal/loadbalancer"
+	"github.com/songzhibin97/stargate/internal/middleware"
+	"github.com/songzhibin97/stargate/internal/types"
+)
+
+func TestPipeline_UpstreamDebug(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("backend " + r.URL.Path))
+	}))
+	defer backend.Close()
+
+	host, portStr, _ := net.SplitHostPort(strings.TrimPrefix(backend.URL, "http://"))
+	port, _ := strconv.Atoi(portStr)
+
+	dir := t.TempDir()
+	debugPath := filepath.Join(dir, "debug.log")
+	auditPath := filepath.Join(dir, "audit.log")
+
+	cfg := &config.Config{}
+	cfg.AdminAPI.REST = config.RESTConfig{Enabled: true, Prefix: "/api/v1"}
+	cfg.AdminAPI.Auth = config.AuthConfig{
+		Enabled: true,
+		APIKey:  config.APIKeyConfig{Header: "X-Admin-Key", Keys: []string{"secret"}},
+	}
+	cfg.Upstreams.DebugLog.Output = debugPath
+
+	pipeline, err := NewPipeline(cfg, nil)
+	if err != nil {
+		t.Fatalf("Failed to create pipeline: %v", err)
+	}
+	defer pipeline.Stop()
+
+	pipeline.auditLogger, err = middleware.NewAuditLogger(&config.AuditLogConfig{Enabled: true, Output: auditPath})
+	if err != nil {
+		t.Fatalf("Failed to create audit logger: %v", err)
+	}
+
+	lb := loadbalancer.NewRoundRobinBalancer(cfg)
+	if err := lb.UpdateUpstream(&types.Upstream{
+		ID:        "web",
+		Name:      "web",
+		Algorithm: "round_robin",
+		Targets:   []*types.Target{{Host: host, Port: port, Weight: 100, Healthy: true}},
+	}); err != nil {
+		t.Fatalf("Failed to add upstream: %v", err)
+	}
+	pipeline.loadBalancer = lb
+	pipeline.router = &pathRouter{routes: map[string]*Route{
+		"/orders": {ID: "orders", UpstreamID: "web"},
+	}}
+	handler := pipeline.createHandler()
+
+	send := func(method, target, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("X-Admin-Key", "secret")
+		rr := httptest.NewRecorder()
+		pipeline.ServeHTTP(rr, req)
+		return rr
+	}
+	proxy := func() {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest("GET", "/orders", nil))
+		if rr.Body.String() != "backend /orders" {
+			t.Fatalf("Expected the backend response, got %d %q", rr.Code, rr.Body.String())
+		}
+	}
+
+	// Nothing is logged until debug logging is turned on
+	proxy()
+	if rr := send("PUT", "/_stargate/admin/debug/upstreams/unknown", ""); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for an unknown upstream, got %d", rr.Code)
+	}
+	if rr := send("PUT", "/_stargate/admin/debug/upstreams/web", `{"duration":"2h"}`); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for a duration over the max, got %d", rr.Code)
+	}
+	rr := send("PUT", "/_stargate/admin/debug/upstreams/web", `{"duration":"15m"}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	proxy()
+	data, err := os.ReadFile(debugPath)
+	if err != nil {
+		t.Fatalf("Failed to read debug log: %v", err)
+	}
+	var entry middleware.UpstreamDebugEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		t.Fatalf("Expected one debug entry, got %q: %v", data, err)
+	}
+	if entry.UpstreamID != "web" || !strings.HasSuffix(entry.Request.URL, "/orders") || string(entry.Response.Body) != "backend /orders" {
+		t.Errorf("Unexpected debug entry %+v", entry)
+	}
+
+	rr = send("GET", "/_stargate/admin/debug/upstreams", "")
+	var listed UpstreamDebugResponse
+	json.NewDecoder(rr.Body).Decode(&listed)
+	if listed.Total != 1 || listed.Upstreams[0].UpstreamID != "web" {
+		t.Errorf("Expected the upstream listed, got %+v", listed)
+	}
+	if _, ok := pipeline.Health()["upstream_debug_logging"]; !ok {
+		t.Error("Expected health to report debug logging")
+	}
+
+	if rr := send("DELETE", "/_stargate/admin/debug/upstreams/web", ""); rr.Code != http.StatusNoContent {
+		t.Errorf("Expected status 204, got %d", rr.Code)
+	}
+	if rr := send("DELETE", "/_stargate/admin/debug/upstreams/web", ""); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 once turned off, got %d", rr.Code)
+	}
+	if _, ok := pipeline.Health()["upstream_debug_logging"]; ok {
+		t.Error("Expected health to stop reporting debug logging")
+	}
+
+	// The audit log records turning debug logging on and off
+	data, err = os.ReadFile(auditPath)
+	if err != nil {
+		t.Fatalf("Failed to read audit log: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[0], "upstream.debug.enable") || !strings.Contains(lines[1], "upstream.debug.disable") {
+		t.Errorf("Expected enable and disable audit entries, got %q", data)
+	}
+}
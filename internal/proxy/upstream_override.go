@@ -0,0 +1,121 @@
+package proxy
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/songzhibin97/stargate/internal/clientip"
+	"github.com/songzhibin97/stargate/internal/config"
+)
+
+// upstreamOverride selects the upstream named by a header from a trusted
+// source instead of the matched route's upstream
+type upstreamOverride struct {
+	header       string
+	secretHeader string
+	secret       string
+	trusted      []*net.IPNet
+}
+
+// newUpstreamOverride creates the upstream override from configuration, or
+// returns nil if overrides are disabled
+func newUpstreamOverride(cfg config.UpstreamOverrideConfig) (*upstreamOverride, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	if len(cfg.TrustedSources) == 0 && cfg.Secret == "" {
+		return nil, fmt.Errorf("upstream override requires trusted sources or a secret")
+	}
+
+	trusted, err := clientip.ParseNetworks(cfg.TrustedSources)
+	if err != nil {
+		return nil, fmt.Errorf("invalid upstream override trusted sources: %w", err)
+	}
+
+	o := &upstreamOverride{
+		header:       cfg.Header,
+		secretHeader: cfg.SecretHeader,
+		secret:       cfg.Secret,
+		trusted:      trusted,
+	}
+	if o.header == "" {
+		o.header = "X-Stargate-Upstream"
+	}
+	if o.secretHeader == "" {
+		o.secretHeader = "X-Stargate-Upstream-Secret"
+	}
+	return o, nil
+}
+
+// take returns the requested upstream ID and whether the request is trusted
+// to override. The override headers are removed so they never reach the
+// upstream.
+func (o *upstreamOverride) take(r *http.Request) (string, bool) {
+	upstreamID := strings.TrimSpace(r.Header.Get(o.header))
+	secret := r.Header.Get(o.secretHeader)
+	r.Header.Del(o.header)
+	r.Header.Del(o.secretHeader)
+
+	if upstreamID == "" {
+		return "", false
+	}
+	return upstreamID, o.trustedSource(r) && o.validSecret(secret)
+}
+
+// trustedSource reports whether the connection comes from a trusted source.
+// The peer address is used rather than the resolved client IP, the override
+// is set by the layer directly in front of the gateway.
+func (o *upstreamOverride) trustedSource(r *http.Request) bool {
+	if len(o.trusted) == 0 {
+		return true
+	}
+	ip := net.ParseIP(clientip.RemoteIP(r))
+	if ip == nil {
+		return false
+	}
+	for _, network := range o.trusted {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// validSecret reports whether the request carries the configured secret
+func (o *upstreamOverride) validSecret(secret string) bool {
+	if o.secret == "" {
+		return true
+	}
+	return subtle.ConstantTimeCompare([]byte(secret), []byte(o.secret)) == 1
+}
+
+// upstreamIDFor returns the upstream ID for a request matching a route with
+// routeUpstreamID, applying a trusted override naming a known upstream.
+// Other overrides are ignored and logged.
+func (p *Pipeline) upstreamIDFor(r *http.Request, routeUpstreamID string) string {
+	p.mu.RLock()
+	override := p.upstreamOverride
+	p.mu.RUnlock()
+
+	if override == nil {
+		return routeUpstreamID
+	}
+
+	upstreamID, trusted := override.take(r)
+	if upstreamID == "" || upstreamID == routeUpstreamID {
+		return routeUpstreamID
+	}
+	if !trusted {
+		log.Printf("Ignoring upstream override %q from untrusted source %s", upstreamID, clientip.RemoteIP(r))
+		return routeUpstreamID
+	}
+	if p.getUpstream(upstreamID) == nil {
+		log.Printf("Ignoring override to unknown upstream %q from %s", upstreamID, clientip.RemoteIP(r))
+		return routeUpstreamID
+	}
+	return upstreamID
+}
@@ -0,0 +1,134 @@
+package proxy
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/songzhibin97/stargate/internal/config"
+	"github.com/songzhibin97/stargate/internal/loadbalancer"
+	"github.com/songzhibin97/stargate/internal/types"
+)
+
+// newOverridePipeline creates a pipeline routing to default-upstream with a
+// tenant-b upstream available for overrides
+func newOverridePipeline(t *testing.T, override config.UpstreamOverrideConfig) http.Handler {
+	t.Helper()
+
+	servers := map[string]*httptest.Server{}
+	for _, id := range []string{"default-upstream", "tenant-b"} {
+		name := id
+		servers[id] = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// The override headers must not reach the upstream
+			body := name
+			if r.Header.Get("X-Stargate-Upstream") != "" || r.Header.Get("X-Stargate-Upstream-Secret") != "" {
+				body += " with override headers"
+			}
+			w.Write([]byte(body))
+		}))
+		t.Cleanup(servers[id].Close)
+	}
+
+	cfg := &config.Config{}
+	cfg.Proxy.UpstreamOverride = override
+
+	pipeline, err := NewPipeline(cfg, nil)
+	if err != nil {
+		t.Fatalf("Failed to create pipeline: %v", err)
+	}
+	t.Cleanup(func() { pipeline.Stop() })
+
+	lb := loadbalancer.NewRoundRobinBalancer(cfg)
+	for id, server := range servers {
+		host, portStr, _ := net.SplitHostPort(strings.TrimPrefix(server.URL, "http://"))
+		port, _ := strconv.Atoi(portStr)
+		upstream := &types.Upstream{
+			ID:        id,
+			Name:      id,
+			Algorithm: "round_robin",
+			Targets:   []*types.Target{{Host: host, Port: port, Weight: 100, Healthy: true}},
+		}
+		if err := lb.UpdateUpstream(upstream); err != nil {
+			t.Fatalf("Failed to add upstream: %v", err)
+		}
+	}
+	pipeline.loadBalancer = lb
+
+	// The mock router routes everything to default-upstream
+	pipeline.router = &MockRouter{}
+	return pipeline.createHandler()
+}
+
+func sendOverride(handler http.Handler, remoteAddr string, headers map[string]string) string {
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = remoteAddr
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	return rr.Body.String()
+}
+
+func TestPipeline_UpstreamOverride_TrustedSource(t *testing.T) {
+	handler := newOverridePipeline(t, config.UpstreamOverrideConfig{
+		Enabled:        true,
+		TrustedSources: []string{"10.0.0.0/8"},
+	})
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		upstream   string
+		expected   string
+	}{
+		{"trusted", "10.1.2.3:4000", "tenant-b", "tenant-b"},
+		{"untrusted", "192.0.2.1:4000", "tenant-b", "default-upstream"},
+		{"unknown upstream", "10.1.2.3:4000", "tenant-z", "default-upstream"},
+		{"no override", "10.1.2.3:4000", "", "default-upstream"},
+	}
+	for _, tt := range tests {
+		headers := map[string]string{}
+		if tt.upstream != "" {
+			headers["X-Stargate-Upstream"] = tt.upstream
+		}
+		if body := sendOverride(handler, tt.remoteAddr, headers); body != tt.expected {
+			t.Errorf("%s: expected response from %q, got %q", tt.name, tt.expected, body)
+		}
+	}
+}
+
+func TestPipeline_UpstreamOverride_Secret(t *testing.T) {
+	handler := newOverridePipeline(t, config.UpstreamOverrideConfig{
+		Enabled: true,
+		Secret:  "s3cret",
+	})
+
+	trusted := sendOverride(handler, "192.0.2.1:4000", map[string]string{
+		"X-Stargate-Upstream":        "tenant-b",
+		"X-Stargate-Upstream-Secret": "s3cret",
+	})
+	if trusted != "tenant-b" {
+		t.Errorf("Expected the override with the secret to be honored, got %q", trusted)
+	}
+
+	untrusted := sendOverride(handler, "192.0.2.1:4000", map[string]string{
+		"X-Stargate-Upstream":        "tenant-b",
+		"X-Stargate-Upstream-Secret": "guess",
+	})
+	if untrusted != "default-upstream" {
+		t.Errorf("Expected the override with a wrong secret to be ignored, got %q", untrusted)
+	}
+}
+
+func TestPipeline_UpstreamOverride_Disabled(t *testing.T) {
+	handler := newOverridePipeline(t, config.UpstreamOverrideConfig{})
+
+	body := sendOverride(handler, "10.1.2.3:4000", map[string]string{"X-Stargate-Upstream": "tenant-b"})
+	if body != "default-upstream with override headers" {
+		t.Errorf("Expected the header to be passed through untouched when disabled, got %q", body)
+	}
+}
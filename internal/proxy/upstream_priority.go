@@ -0,0 +1,151 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/songzhibin97/stargate/internal/auth"
+	"github.com/songzhibin97/stargate/internal/config"
+)
+
+// defaultTierKey is the consumer metadata key, JWT claim or header holding
+// the tier when none is configured
+const defaultTierKey = "tier"
+
+// TierResolver resolves the tier of a request for the priority of upstream
+// concurrency queues. It returns "" when the request has no tier.
+type TierResolver interface {
+	ResolveTier(r *http.Request, key string) string
+}
+
+// TierResolverFunc adapts a function to the TierResolver interface
+type TierResolverFunc func(r *http.Request, key string) string
+
+// ResolveTier calls f(r, key)
+func (f TierResolverFunc) ResolveTier(r *http.Request, key string) string {
+	return f(r, key)
+}
+
+// Global registry for tier resolvers
+var (
+	tierResolverRegistry = make(map[string]TierResolver)
+	tierResolverMutex    sync.RWMutex
+)
+
+func init() {
+	// The tier of the authenticated consumer: its metadata, then the claim of
+	// its JWT
+	RegisterTierResolver("auth", TierResolverFunc(func(r *http.Request, key string) string {
+		if consumer, ok := auth.GetConsumerFromContext(r.Context()); ok && consumer != nil {
+			if tier := consumer.Metadata[key]; tier != "" {
+				return tier
+			}
+		}
+		if claims, ok := auth.GetClaimsFromContext(r.Context()); ok {
+			if tier, ok := claims[key].(string); ok {
+				return tier
+			}
+		}
+		return ""
+	}))
+	// The tier in a request header, only meaningful when the header is set by
+	// a trusted hop in front of the gateway
+	RegisterTierResolver("header", TierResolverFunc(func(r *http.Request, key string) string {
+		return r.Header.Get(key)
+	}))
+}
+
+// RegisterTierResolver registers a tier resolver that configurations
+// reference by name in upstreams.priority.resolver
+func RegisterTierResolver(name string, resolver TierResolver) error {
+	tierResolverMutex.Lock()
+	defer tierResolverMutex.Unlock()
+
+	if _, exists := tierResolverRegistry[name]; exists {
+		return fmt.Errorf("tier resolver %s already registered", name)
+	}
+
+	tierResolverRegistry[name] = resolver
+	return nil
+}
+
+// GetTierResolver retrieves a registered tier resolver by name
+func GetTierResolver(name string) (TierResolver, error) {
+	tierResolverMutex.RLock()
+	defer tierResolverMutex.RUnlock()
+
+	resolver, exists := tierResolverRegistry[name]
+	if !exists {
+		return nil, fmt.Errorf("tier resolver %s not found", name)
+	}
+
+	return resolver, nil
+}
+
+// ListTierResolvers returns all registered tier resolver names
+func ListTierResolvers() []string {
+	tierResolverMutex.RLock()
+	defer tierResolverMutex.RUnlock()
+
+	names := make([]string, 0, len(tierResolverRegistry))
+	for name := range tierResolverRegistry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// upstreamPriority ranks the requests queued by upstream concurrency limits
+// by the tier of their consumer
+type upstreamPriority struct {
+	resolver    TierResolver
+	key         string
+	ranks       map[string]int // Tier names by rank, 0 being the highest
+	defaultTier string
+}
+
+// newUpstreamPriority creates the upstream priority from configuration, or
+// returns nil if no tiers are configured
+func newUpstreamPriority(cfg config.UpstreamPriorityConfig) (*upstreamPriority, error) {
+	if len(cfg.Tiers) == 0 {
+		return nil, nil
+	}
+
+	name := cfg.Resolver
+	if name == "" {
+		name = "auth"
+	}
+	resolver, err := GetTierResolver(name)
+	if err != nil {
+		return nil, fmt.Errorf("invalid upstream priority resolver: %w", err)
+	}
+
+	p := &upstreamPriority{
+		resolver:    resolver,
+		key:         cfg.Key,
+		ranks:       make(map[string]int, len(cfg.Tiers)),
+		defaultTier: cfg.DefaultTier,
+	}
+	if p.key == "" {
+		p.key = defaultTierKey
+	}
+	for rank, tier := range cfg.Tiers {
+		p.ranks[tier] = rank
+	}
+	if p.defaultTier == "" {
+		p.defaultTier = cfg.Tiers[len(cfg.Tiers)-1]
+	}
+	return p, nil
+}
+
+// tierOf returns the tier of a request and its rank, placing requests
+// without a configured tier in the default tier
+func (p *upstreamPriority) tierOf(r *http.Request) (string, int) {
+	tier := p.resolver.ResolveTier(r, p.key)
+	if rank, ok := p.ranks[tier]; ok {
+		return tier, rank
+	}
+	return p.defaultTier, p.ranks[p.defaultTier]
+}
@@ -0,0 +1,232 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/songzhibin97/stargate/internal/auth"
+	"github.com/songzhibin97/stargate/internal/config"
+	"github.com/songzhibin97/stargate/internal/loadbalancer"
+	"github.com/songzhibin97/stargate/internal/types"
+)
+
+func TestUpstreamLimiter_Priority(t *testing.T) {
+	limiter := newUpstreamLimiter(config.UpstreamConcurrencyConfig{MaxConcurrent: 1, QueueSize: 2, QueueTimeout: time.Second})
+
+	type result struct {
+		name    string
+		release func()
+		err     error
+	}
+	results := make(chan result, 4)
+	acquire := func(name string, priority int) {
+		go func() {
+			release, _, err := limiter.acquire(context.Background(), priority)
+			results <- result{name, release, err}
+		}()
+	}
+	waitQueued := func(depth int) {
+		deadline := time.Now().Add(time.Second)
+		for limiter.stats()["queued"] != depth {
+			if time.Now().After(deadline) {
+				t.Fatalf("Expected %d queued requests", depth)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	// A free tier request holds the only slot and two more queue behind it
+	release, _, err := limiter.acquire(context.Background(), 2)
+	if err != nil {
+		t.Fatalf("Failed to acquire a free slot: %v", err)
+	}
+	acquire("free-1", 2)
+	waitQueued(1)
+	acquire("free-2", 2)
+	waitQueued(2)
+
+	// A premium request sheds the last free request from the full queue
+	acquire("premium", 0)
+	if shed := <-results; shed.name != "free-2" || !errors.Is(shed.err, errUpstreamQueueShed) {
+		t.Fatalf("Expected the last free request shed, got %s: %v", shed.name, shed.err)
+	}
+	waitQueued(2)
+
+	// Another free request can't shed a request of the same tier
+	if _, _, err := limiter.acquire(context.Background(), 2); !errors.Is(err, errUpstreamQueueFull) {
+		t.Errorf("Expected a full queue for the free tier, got %v", err)
+	}
+
+	// The premium request gets the freed slot despite arriving last
+	release()
+	admitted := <-results
+	if admitted.name != "premium" || admitted.err != nil {
+		t.Fatalf("Expected the premium request admitted first, got %s: %v", admitted.name, admitted.err)
+	}
+	admitted.release()
+	if admitted = <-results; admitted.name != "free-1" || admitted.err != nil {
+		t.Fatalf("Expected the free request admitted next, got %s: %v", admitted.name, admitted.err)
+	}
+	admitted.release()
+
+	if stats := limiter.stats(); stats["in_flight"] != 0 || stats["queued"] != 0 {
+		t.Errorf("Expected the limiter drained, got %v", stats)
+	}
+}
+
+func TestUpstreamPriority_TierOf(t *testing.T) {
+	if priority, err := newUpstreamPriority(config.UpstreamPriorityConfig{}); priority != nil || err != nil {
+		t.Errorf("Expected no priority without tiers, got %v %v", priority, err)
+	}
+	if _, err := newUpstreamPriority(config.UpstreamPriorityConfig{Tiers: []string{"premium"}, Resolver: "unknown"}); err == nil {
+		t.Error("Expected an unknown resolver to be rejected")
+	}
+
+	priority, err := newUpstreamPriority(config.UpstreamPriorityConfig{Tiers: []string{"premium", "standard", "free"}})
+	if err != nil {
+		t.Fatalf("Failed to create priority: %v", err)
+	}
+
+	consumer := httptest.NewRequest("GET", "/", nil)
+	consumer = consumer.WithContext(auth.SetConsumerInContext(consumer.Context(), &auth.Consumer{ID: "acme", Metadata: map[string]string{"tier": "premium"}}))
+	claims := httptest.NewRequest("GET", "/", nil)
+	claims = claims.WithContext(auth.SetClaimsInContext(claims.Context(), map[string]interface{}{"tier": "standard"}))
+	unknown := httptest.NewRequest("GET", "/", nil)
+	unknown = unknown.WithContext(auth.SetClaimsInContext(unknown.Context(), map[string]interface{}{"tier": "gold"}))
+
+	for _, tc := range []struct {
+		name string
+		req  *http.Request
+		tier string
+		rank int
+	}{
+		{"consumer metadata", consumer, "premium", 0},
+		{"jwt claim", claims, "standard", 1},
+		{"unknown tier", unknown, "free", 2},
+		{"anonymous", httptest.NewRequest("GET", "/", nil), "free", 2},
+	} {
+		if tier, rank := priority.tierOf(tc.req); tier != tc.tier || rank != tc.rank {
+			t.Errorf("%s: expected tier %s (%d), got %s (%d)", tc.name, tc.tier, tc.rank, tier, rank)
+		}
+	}
+
+	// Custom resolvers are registered by name
+	RegisterTierResolver("test_plan", TierResolverFunc(func(r *http.Request, key string) string {
+		return strings.TrimPrefix(r.URL.Path, "/")
+	}))
+	priority, err = newUpstreamPriority(config.UpstreamPriorityConfig{Tiers: []string{"premium", "free"}, Resolver: "test_plan", DefaultTier: "premium"})
+	if err != nil {
+		t.Fatalf("Failed to create priority: %v", err)
+	}
+	if tier, _ := priority.tierOf(httptest.NewRequest("GET", "/free", nil)); tier != "free" {
+		t.Errorf("Expected the custom resolver's tier, got %s", tier)
+	}
+	if tier, _ := priority.tierOf(httptest.NewRequest("GET", "/", nil)); tier != "premium" {
+		t.Errorf("Expected the configured default tier, got %s", tier)
+	}
+}
+
+func TestPipeline_UpstreamPriority(t *testing.T) {
+	started := make(chan struct{}, 10)
+	release := make(chan struct{})
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/health" {
+			return
+		}
+		started <- struct{}{}
+		<-release
+		w.Write([]byte(r.Header.Get("X-Tier")))
+	}))
+	defer backend.Close()
+	defer close(release)
+
+	cfg := &config.Config{}
+	cfg.Metrics = config.MetricsConfig{Enabled: true, Provider: "prometheus", Path: "/metrics"}
+	cfg.Upstreams.Concurrency = map[string]config.UpstreamConcurrencyConfig{
+		"orders": {MaxConcurrent: 1, QueueSize: 1, QueueTimeout: time.Second},
+	}
+	cfg.Upstreams.Priority = config.UpstreamPriorityConfig{Tiers: []string{"premium", "free"}, Resolver: "header", Key: "X-Tier"}
+
+	pipeline, err := NewPipeline(cfg, nil)
+	if err != nil {
+		t.Fatalf("Failed to create pipeline: %v", err)
+	}
+	defer pipeline.Stop()
+
+	host, portStr, _ := net.SplitHostPort(strings.TrimPrefix(backend.URL, "http://"))
+	port, _ := strconv.Atoi(portStr)
+	lb := loadbalancer.NewRoundRobinBalancer(cfg)
+	if err := lb.UpdateUpstream(&types.Upstream{
+		ID:        "orders",
+		Name:      "orders",
+		Algorithm: "round_robin",
+		Targets:   []*types.Target{{Host: host, Port: port, Weight: 100, Healthy: true}},
+	}); err != nil {
+		t.Fatalf("Failed to add upstream: %v", err)
+	}
+	pipeline.loadBalancer = lb
+	pipeline.router = &staticRouter{route: &Route{ID: "orders", UpstreamID: "orders"}}
+	handler := pipeline.createHandler()
+
+	serve := func(tier string) <-chan *httptest.ResponseRecorder {
+		done := make(chan *httptest.ResponseRecorder, 1)
+		go func() {
+			req := httptest.NewRequest("GET", "/orders", nil)
+			req.Header.Set("X-Tier", tier)
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+			done <- rr
+		}()
+		return done
+	}
+	waitQueued := func() {
+		deadline := time.Now().Add(time.Second)
+		for pipeline.upstreamLimiters["orders"].stats()["queued"] != 1 {
+			if time.Now().After(deadline) {
+				t.Fatal("Expected a queued request")
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+
+	// A free request holds the slot and another one queues
+	first := serve("free")
+	<-started
+	queued := serve("free")
+	waitQueued()
+
+	// A premium request sheds the queued free request under saturation
+	premium := serve("premium")
+	if rr := <-queued; rr.Code != http.StatusServiceUnavailable || rr.Header().Get("Retry-After") == "" {
+		t.Fatalf("Expected the free request shed with 503, got %d", rr.Code)
+	}
+	waitQueued()
+
+	release <- struct{}{}
+	<-first
+	<-started
+	release <- struct{}{}
+	if rr := <-premium; rr.Code != http.StatusOK || rr.Body.String() != "premium" {
+		t.Fatalf("Expected the premium request proxied, got %d %q", rr.Code, rr.Body.String())
+	}
+
+	metricsRR := httptest.NewRecorder()
+	pipeline.getMetricsProvider().Handler().ServeHTTP(metricsRR, httptest.NewRequest("GET", "/metrics", nil))
+	for _, expected := range []string{
+		`upstream_tier_requests_total{result="admitted",tier="free",upstream="orders"} 1`,
+		`upstream_tier_requests_total{result="rejected",tier="free",upstream="orders"} 1`,
+		`upstream_tier_requests_total{result="admitted",tier="premium",upstream="orders"} 1`,
+		`upstream_queue_rejected_total{reason="shed",upstream="orders"} 1`,
+	} {
+		if !strings.Contains(metricsRR.Body.String(), expected) {
+			t.Errorf("Expected metrics output to contain %q", expected)
+		}
+	}
+}
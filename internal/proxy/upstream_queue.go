@@ -0,0 +1,282 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/songzhibin97/stargate/internal/config"
+)
+
+// defaultUpstreamQueueTimeout is the longest wait for a slot of a saturated
+// upstream when no queue timeout is configured
+const defaultUpstreamQueueTimeout = time.Second
+
+var (
+	// errUpstreamQueueFull is returned when an upstream is at capacity and
+	// its queue has no room
+	errUpstreamQueueFull = errors.New("upstream queue full")
+
+	// errUpstreamQueueTimeout is returned when no slot of an upstream freed
+	// up within the queue timeout
+	errUpstreamQueueTimeout = errors.New("upstream queue timeout")
+
+	// errUpstreamQueueShed is returned to a queued request dropped from a
+	// full queue to make room for a request of a higher priority
+	errUpstreamQueueShed = errors.New("upstream queue shed")
+)
+
+// upstreamLimiter limits the requests proxied to an upstream at once. Requests
+// over the limit wait for a slot in a bounded queue, by priority and then in
+// arrival order.
+type upstreamLimiter struct {
+	config       config.UpstreamConcurrencyConfig
+	queueTimeout time.Duration
+
+	mu       sync.Mutex
+	inFlight int
+	waiters  []*upstreamWaiter // Queued requests, highest priority first
+
+	// onQueueChange is called with the queue depth whenever it changes
+	onQueueChange func(depth int)
+}
+
+// upstreamWaiter is a request queued for a slot
+type upstreamWaiter struct {
+	priority int        // Lower values are served first
+	ready    chan error // Receives nil when handed a slot, or why it was shed
+}
+
+// newUpstreamLimiter creates an upstream limiter from configuration
+func newUpstreamLimiter(cfg config.UpstreamConcurrencyConfig) *upstreamLimiter {
+	queueTimeout := cfg.QueueTimeout
+	if queueTimeout <= 0 {
+		queueTimeout = defaultUpstreamQueueTimeout
+	}
+	return &upstreamLimiter{
+		config:       cfg,
+		queueTimeout: queueTimeout,
+	}
+}
+
+// acquire reserves a slot, waiting in the queue if the upstream is at
+// capacity. Lower priority values are served first. It returns the function
+// releasing the slot and the time spent queued.
+func (l *upstreamLimiter) acquire(ctx context.Context, priority int) (func(), time.Duration, error) {
+	waiter, err := l.enqueue(priority)
+	if err != nil {
+		return nil, 0, err
+	}
+	if waiter == nil {
+		return l.release, 0, nil
+	}
+
+	start := time.Now()
+	timer := time.NewTimer(l.queueTimeout)
+	defer timer.Stop()
+
+	select {
+	case err := <-waiter.ready:
+		if err != nil {
+			return nil, time.Since(start), err
+		}
+		return l.release, time.Since(start), nil
+	case <-timer.C:
+		err = errUpstreamQueueTimeout
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	// The waiter may have been handed a slot or shed while giving up
+	if !l.dequeue(waiter) {
+		if <-waiter.ready == nil {
+			l.release()
+		}
+	}
+	return nil, time.Since(start), err
+}
+
+// enqueue takes a slot if one is free, returning a nil waiter, or takes a
+// place in the queue. A full queue sheds its last request of a lower
+// priority to make room, and rejects the request if it has none.
+func (l *upstreamLimiter) enqueue(priority int) (*upstreamWaiter, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.inFlight < l.config.MaxConcurrent {
+		l.inFlight++
+		return nil, nil
+	}
+
+	if len(l.waiters) >= l.config.QueueSize {
+		if len(l.waiters) == 0 || l.waiters[len(l.waiters)-1].priority <= priority {
+			return nil, errUpstreamQueueFull
+		}
+		shed := l.waiters[len(l.waiters)-1]
+		l.waiters = l.waiters[:len(l.waiters)-1]
+		shed.ready <- errUpstreamQueueShed
+	}
+
+	// Queue behind the requests of the same or a higher priority
+	waiter := &upstreamWaiter{priority: priority, ready: make(chan error, 1)}
+	i := sort.Search(len(l.waiters), func(i int) bool { return l.waiters[i].priority > priority })
+	l.waiters = append(l.waiters, nil)
+	copy(l.waiters[i+1:], l.waiters[i:])
+	l.waiters[i] = waiter
+
+	l.notifyQueueChange()
+	return waiter, nil
+}
+
+// dequeue removes a waiter giving up from the queue, reporting false if it
+// was no longer queued
+func (l *upstreamLimiter) dequeue(waiter *upstreamWaiter) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for i, queued := range l.waiters {
+		if queued == waiter {
+			l.waiters = append(l.waiters[:i], l.waiters[i+1:]...)
+			l.notifyQueueChange()
+			return true
+		}
+	}
+	return false
+}
+
+// release frees a slot, handing it to the first queued request if any
+func (l *upstreamLimiter) release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if len(l.waiters) == 0 {
+		l.inFlight--
+		return
+	}
+	waiter := l.waiters[0]
+	l.waiters = l.waiters[1:]
+	waiter.ready <- nil
+	l.notifyQueueChange()
+}
+
+// notifyQueueChange reports the queue depth, called with the lock held
+func (l *upstreamLimiter) notifyQueueChange() {
+	if l.onQueueChange != nil {
+		l.onQueueChange(len(l.waiters))
+	}
+}
+
+// stats returns the limiter's current usage
+func (l *upstreamLimiter) stats() map[string]interface{} {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return map[string]interface{}{
+		"in_flight":      l.inFlight,
+		"max_concurrent": l.config.MaxConcurrent,
+		"queued":         len(l.waiters),
+		"queue_size":     l.config.QueueSize,
+	}
+}
+
+// retryAfter returns the Retry-After seconds of rejected requests: by the
+// time a queued request would have given up, a slot is likely to be free
+func (l *upstreamLimiter) retryAfter() string {
+	return strconv.Itoa(int(math.Ceil(l.queueTimeout.Seconds())))
+}
+
+// updateUpstreamLimiters creates the limiters of the configured upstreams,
+// keeping the limiters of upstreams whose limits didn't change so requests
+// holding their slots stay counted
+func (p *Pipeline) updateUpstreamLimiters(cfg map[string]config.UpstreamConcurrencyConfig) {
+	limiters := make(map[string]*upstreamLimiter, len(cfg))
+	for upstreamID, concurrency := range cfg {
+		if limiter, exists := p.upstreamLimiters[upstreamID]; exists && limiter.config == concurrency {
+			limiters[upstreamID] = limiter
+			continue
+		}
+
+		upstreamID := upstreamID
+		limiter := newUpstreamLimiter(concurrency)
+		limiter.onQueueChange = func(depth int) {
+			if p.upstreamQueueDepth != nil {
+				p.upstreamQueueDepth.WithLabelValues(upstreamID).Set(float64(depth))
+			}
+		}
+		limiters[upstreamID] = limiter
+	}
+	p.upstreamLimiters = limiters
+}
+
+// acquireUpstreamSlot reserves a slot of the upstream's concurrency limit,
+// queuing the request while the upstream is saturated. With priority tiers,
+// queued requests of higher tiers get slots first and lower tiers are shed
+// from a full queue. Requests that can't get a slot are answered with 503
+// and Retry-After. The returned function releases the slot.
+func (p *Pipeline) acquireUpstreamSlot(w http.ResponseWriter, r *http.Request, upstreamID string) (func(), bool) {
+	p.mu.RLock()
+	limiter := p.upstreamLimiters[upstreamID]
+	priority := p.upstreamPriority
+	p.mu.RUnlock()
+	if limiter == nil {
+		return func() {}, true
+	}
+
+	var tier string
+	var rank int
+	if priority != nil {
+		tier, rank = priority.tierOf(r)
+	}
+
+	release, waited, err := limiter.acquire(r.Context(), rank)
+	result := "acquired"
+	switch {
+	case errors.Is(err, errUpstreamQueueFull):
+		result = "queue_full"
+	case errors.Is(err, errUpstreamQueueShed):
+		result = "shed"
+	case errors.Is(err, errUpstreamQueueTimeout):
+		result = "timeout"
+	case errors.Is(err, context.DeadlineExceeded):
+		result = "deadline"
+	case err != nil:
+		result = "canceled"
+	}
+
+	// Only requests that queued waited
+	if p.upstreamQueueWait != nil && waited > 0 {
+		p.upstreamQueueWait.WithLabelValues(upstreamID, result).Observe(waited.Seconds())
+	}
+	if priority != nil && p.upstreamTierCounter != nil {
+		admission := "admitted"
+		if err != nil {
+			admission = "rejected"
+		}
+		p.upstreamTierCounter.WithLabelValues(upstreamID, tier, admission).Inc()
+	}
+	if err == nil {
+		return release, true
+	}
+
+	p.mu.Lock()
+	p.queueRejectCount++
+	p.mu.Unlock()
+	if p.upstreamQueueRejectCounter != nil {
+		p.upstreamQueueRejectCounter.WithLabelValues(upstreamID, result).Inc()
+	}
+
+	// The request ran out of time while queued, retrying won't help
+	if result == "deadline" {
+		p.handleError(w, r, http.StatusGatewayTimeout, "request deadline exceeded")
+		return nil, false
+	}
+
+	w.Header().Set("Retry-After", limiter.retryAfter())
+	p.handleError(w, r, http.StatusServiceUnavailable, "upstream at capacity")
+	return nil, false
+}
@@ -0,0 +1,133 @@
+package proxy
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/songzhibin97/stargate/internal/config"
+	"github.com/songzhibin97/stargate/internal/loadbalancer"
+	"github.com/songzhibin97/stargate/internal/types"
+)
+
+func TestPipeline_UpstreamQueue(t *testing.T) {
+	started := make(chan struct{}, 10)
+	release := make(chan struct{})
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Active health checks of the balancer aren't queued
+		if r.URL.Path == "/health" {
+			return
+		}
+		started <- struct{}{}
+		<-release
+		w.Write([]byte("ok"))
+	}))
+	defer backend.Close()
+	defer close(release)
+
+	cfg := &config.Config{}
+	cfg.Metrics = config.MetricsConfig{Enabled: true, Provider: "prometheus", Path: "/metrics"}
+	cfg.Upstreams.Concurrency = map[string]config.UpstreamConcurrencyConfig{
+		"orders": {MaxConcurrent: 1, QueueSize: 1, QueueTimeout: 200 * time.Millisecond},
+	}
+
+	pipeline, err := NewPipeline(cfg, nil)
+	if err != nil {
+		t.Fatalf("Failed to create pipeline: %v", err)
+	}
+	defer pipeline.Stop()
+
+	host, portStr, _ := net.SplitHostPort(strings.TrimPrefix(backend.URL, "http://"))
+	port, _ := strconv.Atoi(portStr)
+
+	lb := loadbalancer.NewRoundRobinBalancer(cfg)
+	if err := lb.UpdateUpstream(&types.Upstream{
+		ID:        "orders",
+		Name:      "orders",
+		Algorithm: "round_robin",
+		Targets:   []*types.Target{{Host: host, Port: port, Weight: 100, Healthy: true}},
+	}); err != nil {
+		t.Fatalf("Failed to add upstream: %v", err)
+	}
+	pipeline.loadBalancer = lb
+	pipeline.router = &staticRouter{route: &Route{ID: "orders", UpstreamID: "orders"}}
+	handler := pipeline.createHandler()
+
+	serve := func() <-chan *httptest.ResponseRecorder {
+		done := make(chan *httptest.ResponseRecorder, 1)
+		go func() {
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, httptest.NewRequest("GET", "/orders", nil))
+			done <- rr
+		}()
+		return done
+	}
+	waitQueued := func(depth int) {
+		deadline := time.Now().Add(time.Second)
+		for pipeline.upstreamLimiters["orders"].stats()["queued"] != depth {
+			if time.Now().After(deadline) {
+				t.Fatalf("Expected %d queued requests", depth)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+
+	// The first request takes the only slot, the second queues
+	first := serve()
+	<-started
+	second := serve()
+	waitQueued(1)
+
+	// The queue is full, the third request is rejected without waiting
+	rr := <-serve()
+	if rr.Code != http.StatusServiceUnavailable || rr.Header().Get("Retry-After") != "1" {
+		t.Fatalf("Expected 503 with Retry-After when the queue is full, got %d %q", rr.Code, rr.Header().Get("Retry-After"))
+	}
+
+	// Finishing the first request hands its slot to the queued one
+	release <- struct{}{}
+	if rr := <-first; rr.Code != http.StatusOK {
+		t.Fatalf("Expected the first request to succeed, got %d", rr.Code)
+	}
+	<-started
+	release <- struct{}{}
+	if rr := <-second; rr.Code != http.StatusOK {
+		t.Fatalf("Expected the queued request to succeed, got %d", rr.Code)
+	}
+
+	// A queued request gives up after the queue timeout
+	third := serve()
+	<-started
+	start := time.Now()
+	rr = <-serve()
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Fatalf("Expected 503 after the queue timeout, got %d", rr.Code)
+	}
+	if waited := time.Since(start); waited < 200*time.Millisecond {
+		t.Errorf("Expected the request to wait for the queue timeout, waited %v", waited)
+	}
+	release <- struct{}{}
+	<-third
+
+	if count := pipeline.Metrics()["queue_rejected"]; count != int64(2) {
+		t.Errorf("Expected 2 rejected requests, got %v", count)
+	}
+
+	metricsRR := httptest.NewRecorder()
+	pipeline.getMetricsProvider().Handler().ServeHTTP(metricsRR, httptest.NewRequest("GET", "/metrics", nil))
+	for _, expected := range []string{
+		`upstream_queue_rejected_total{reason="queue_full",upstream="orders"} 1`,
+		`upstream_queue_rejected_total{reason="timeout",upstream="orders"} 1`,
+		`upstream_queue_wait_seconds_count{result="acquired",upstream="orders"} 1`,
+		`upstream_queue_wait_seconds_count{result="timeout",upstream="orders"} 1`,
+		`upstream_queue_depth{upstream="orders"} 0`,
+	} {
+		if !strings.Contains(metricsRR.Body.String(), expected) {
+			t.Errorf("Expected metrics output to contain %q", expected)
+		}
+	}
+}
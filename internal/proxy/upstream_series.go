@@ -0,0 +1,148 @@
+package proxy
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+
+	"github.com/songzhibin97/stargate/internal/router"
+	"github.com/songzhibin97/stargate/internal/types"
+)
+
+// targetAddr returns the address of a target in metric labels
+func targetAddr(target *types.Target) string {
+	return fmt.Sprintf("%s:%d", target.Host, target.Port)
+}
+
+// routerTargetAddr returns the address of a configured target URL in metric
+// labels, the URL itself if it has no host
+func routerTargetAddr(target router.Target) string {
+	u, err := url.Parse(target.URL)
+	if err != nil || u.Host == "" {
+		return target.URL
+	}
+	if u.Port() != "" {
+		return u.Host
+	}
+	port := "80"
+	if u.Scheme == "https" {
+		port = "443"
+	}
+	return net.JoinHostPort(u.Hostname(), port)
+}
+
+// routerTargetAddrs returns the addresses of configured targets in metric
+// labels
+func routerTargetAddrs(targets []router.Target) []string {
+	addrs := make([]string, len(targets))
+	for i, target := range targets {
+		addrs[i] = routerTargetAddr(target)
+	}
+	return addrs
+}
+
+// trackTargetSeries records that per-target metrics have series for a target
+// of an upstream, so they can be deleted once the target is removed
+func (p *Pipeline) trackTargetSeries(upstreamID, addr string) {
+	p.seriesMu.Lock()
+	defer p.seriesMu.Unlock()
+
+	if p.seriesTargets == nil {
+		p.seriesTargets = make(map[string]map[string]bool)
+	}
+	if p.seriesTargets[upstreamID] == nil {
+		p.seriesTargets[upstreamID] = make(map[string]bool)
+	}
+	p.seriesTargets[upstreamID][addr] = true
+}
+
+// deleteTargetSeries deletes the series of every per-target metric recorded
+// for a target of an upstream
+func (p *Pipeline) deleteTargetSeries(upstreamID, addr string) {
+	p.seriesMu.Lock()
+	delete(p.seriesTargets[upstreamID], addr)
+	p.seriesMu.Unlock()
+
+	match := map[string]string{"upstream": upstreamID, "target": addr}
+	if p.upstreamTLSFailureCounter != nil {
+		p.upstreamTLSFailureCounter.DeletePartialMatch(match)
+	}
+}
+
+// pruneTargetSeries deletes the per-target series of the targets of an
+// upstream that aren't among its current targets, and tracks the upstream
+// so its series are deleted once it is removed by a reload
+func (p *Pipeline) pruneTargetSeries(upstreamID string, addrs []string) {
+	current := make(map[string]bool, len(addrs))
+	for _, addr := range addrs {
+		current[addr] = true
+	}
+
+	var removed []string
+	p.seriesMu.Lock()
+	if p.seriesTargets == nil {
+		p.seriesTargets = make(map[string]map[string]bool)
+	}
+	if p.seriesTargets[upstreamID] == nil {
+		p.seriesTargets[upstreamID] = make(map[string]bool)
+	}
+	for addr := range p.seriesTargets[upstreamID] {
+		if !current[addr] {
+			removed = append(removed, addr)
+		}
+	}
+	p.seriesMu.Unlock()
+
+	for _, addr := range removed {
+		p.deleteTargetSeries(upstreamID, addr)
+	}
+}
+
+// deleteUpstreamSeries deletes the series of every metric recorded for an
+// upstream and its targets, so removed upstreams don't accumulate
+func (p *Pipeline) deleteUpstreamSeries(upstreamID string) {
+	p.seriesMu.Lock()
+	delete(p.seriesTargets, upstreamID)
+	p.seriesMu.Unlock()
+
+	// Matching the upstream alone covers the series of all its targets
+	match := map[string]string{"upstream": upstreamID}
+	if p.upstreamQueueDepth != nil {
+		p.upstreamQueueDepth.DeletePartialMatch(match)
+	}
+	if p.upstreamQueueWait != nil {
+		p.upstreamQueueWait.DeletePartialMatch(match)
+	}
+	if p.upstreamQueueRejectCounter != nil {
+		p.upstreamQueueRejectCounter.DeletePartialMatch(match)
+	}
+	if p.upstreamTierCounter != nil {
+		p.upstreamTierCounter.DeletePartialMatch(match)
+	}
+	if p.upstreamTLSFailureCounter != nil {
+		p.upstreamTLSFailureCounter.DeletePartialMatch(match)
+	}
+	if p.passiveHealthCounter != nil {
+		p.passiveHealthCounter.DeletePartialMatch(match)
+	}
+	if p.rerouteCounter != nil {
+		p.rerouteCounter.DeletePartialMatch(map[string]string{"from_upstream": upstreamID})
+		p.rerouteCounter.DeletePartialMatch(map[string]string{"to_upstream": upstreamID})
+	}
+	if p.fallbackCounter != nil {
+		p.fallbackCounter.DeletePartialMatch(map[string]string{"primary_upstream": upstreamID})
+		p.fallbackCounter.DeletePartialMatch(map[string]string{"fallback_upstream": upstreamID})
+	}
+}
+
+// upstreamsWithSeries returns the upstreams whose series are tracked
+func (p *Pipeline) upstreamsWithSeries() []string {
+	p.seriesMu.Lock()
+	defer p.seriesMu.Unlock()
+
+	upstreamIDs := make([]string, 0, len(p.seriesTargets))
+	for upstreamID := range p.seriesTargets {
+		upstreamIDs = append(upstreamIDs, upstreamID)
+	}
+	return upstreamIDs
+}
@@ -0,0 +1,83 @@
+package proxy
+
+import (
+	"testing"
+
+	"github.com/songzhibin97/stargate/internal/config"
+	"github.com/songzhibin97/stargate/internal/router"
+	"github.com/songzhibin97/stargate/internal/types"
+)
+
+func TestPipeline_DeleteTargetSeries(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Metrics = config.MetricsConfig{Enabled: true, Provider: "prometheus", Path: "/metrics"}
+
+	pipeline, err := NewPipeline(cfg, nil)
+	if err != nil {
+		t.Fatalf("Failed to create pipeline: %v", err)
+	}
+	defer pipeline.Stop()
+
+	kept := &types.Target{Host: "10.0.0.1", Port: 443, Weight: 100, Healthy: true}
+	removed := &types.Target{Host: "10.0.0.2", Port: 443, Weight: 100, Healthy: true}
+	if err := pipeline.AddUpstream(&types.Upstream{ID: "orders", Name: "orders", Targets: []*types.Target{kept, removed}}); err != nil {
+		t.Fatalf("Failed to add upstream: %v", err)
+	}
+	for _, target := range []*types.Target{kept, removed} {
+		pipeline.recordUpstreamTLSFailure("orders", target, &upstreamTLSFailure{reason: tlsFailureExpired})
+	}
+
+	// targetSeries returns the targets of orders with TLS failure series
+	targetSeries := func() map[string]bool {
+		families, err := pipeline.getMetricsProvider().Gather()
+		if err != nil {
+			t.Fatalf("Failed to gather metrics: %v", err)
+		}
+		targets := make(map[string]bool)
+		for _, family := range families {
+			for _, metric := range family.Metrics {
+				labels := make(map[string]string)
+				for _, label := range metric.Labels {
+					labels[label.Name] = label.Value
+				}
+				if labels["upstream"] == "orders" && labels["target"] != "" {
+					targets[labels["target"]] = true
+				}
+			}
+		}
+		return targets
+	}
+
+	if series := targetSeries(); !series["10.0.0.1:443"] || !series["10.0.0.2:443"] {
+		t.Fatalf("Expected series for both targets, got %v", series)
+	}
+
+	// Removing a target drops its series only
+	if err := pipeline.AddUpstream(&types.Upstream{ID: "orders", Name: "orders", Targets: []*types.Target{kept}}); err != nil {
+		t.Fatalf("Failed to update upstream: %v", err)
+	}
+	if series := targetSeries(); !series["10.0.0.1:443"] || series["10.0.0.2:443"] {
+		t.Errorf("Expected only the series of the kept target, got %v", series)
+	}
+
+	// Removing the upstream drops the series of its targets
+	if err := pipeline.RemoveUpstream("orders"); err != nil {
+		t.Fatalf("Failed to remove upstream: %v", err)
+	}
+	if series := targetSeries(); len(series) != 0 {
+		t.Errorf("Expected no series after removing the upstream, got %v", series)
+	}
+}
+
+func TestRouterTargetAddr(t *testing.T) {
+	for url, expected := range map[string]string{
+		"http://10.0.0.1:8080/api": "10.0.0.1:8080",
+		"http://backend":           "backend:80",
+		"https://backend":          "backend:443",
+		"10.0.0.1:8080":            "10.0.0.1:8080",
+	} {
+		if addr := routerTargetAddr(router.Target{URL: url}); addr != expected {
+			t.Errorf("Expected %s for %s, got %s", expected, url, addr)
+		}
+	}
+}
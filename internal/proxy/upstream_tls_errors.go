@@ -0,0 +1,171 @@
+package proxy
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/songzhibin97/stargate/internal/types"
+)
+
+// Reasons of failed TLS handshakes with upstreams
+const (
+	tlsFailureExpired          = "certificate_expired"
+	tlsFailureNotYetValid      = "certificate_not_yet_valid"
+	tlsFailureInvalid          = "invalid_certificate"
+	tlsFailureUnknownAuthority = "unknown_authority"
+	tlsFailureHostnameMismatch = "hostname_mismatch"
+	tlsFailureNotTLS           = "not_tls"
+	tlsFailureRemoteAlert      = "remote_alert"
+)
+
+// upstreamTLSFailure describes a failed TLS handshake with an upstream target
+type upstreamTLSFailure struct {
+	reason     string
+	serverName string    // SNI sent to the target
+	subject    string    // Subject of the certificate the target presented, if any
+	notAfter   time.Time // Expiry of the certificate the target presented, if any
+	err        error
+}
+
+// classifyUpstreamTLSError returns the TLS handshake failure behind a proxy
+// error, or nil if the error isn't one
+func classifyUpstreamTLSError(err error, serverName string) *upstreamTLSFailure {
+	var invalidErr x509.CertificateInvalidError
+	var authorityErr x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var recordErr tls.RecordHeaderError
+	var opErr *net.OpError
+
+	failure := &upstreamTLSFailure{serverName: serverName, err: err}
+	var cert *x509.Certificate
+	switch {
+	case errors.As(err, &invalidErr):
+		cert = invalidErr.Cert
+		failure.reason = tlsFailureInvalid
+		if invalidErr.Reason == x509.Expired {
+			failure.reason = tlsFailureExpired
+			if cert != nil && time.Now().Before(cert.NotBefore) {
+				failure.reason = tlsFailureNotYetValid
+			}
+		}
+	case errors.As(err, &authorityErr):
+		cert = authorityErr.Cert
+		failure.reason = tlsFailureUnknownAuthority
+	case errors.As(err, &hostnameErr):
+		cert = hostnameErr.Certificate
+		failure.reason = tlsFailureHostnameMismatch
+	case errors.As(err, &recordErr):
+		failure.reason = tlsFailureNotTLS
+	case errors.As(err, &opErr) && opErr.Op == "remote error":
+		failure.reason = tlsFailureRemoteAlert
+	default:
+		// Other verification failures, such as a custom verifier's
+		var verifyErr *tls.CertificateVerificationError
+		if !errors.As(err, &verifyErr) {
+			return nil
+		}
+		failure.reason = tlsFailureInvalid
+		if len(verifyErr.UnverifiedCertificates) > 0 {
+			cert = verifyErr.UnverifiedCertificates[0]
+		}
+	}
+
+	if cert != nil {
+		failure.subject = cert.Subject.String()
+		failure.notAfter = cert.NotAfter
+	}
+	return failure
+}
+
+// description returns the failure in words for clients
+func (f *upstreamTLSFailure) description() string {
+	switch f.reason {
+	case tlsFailureExpired:
+		return "upstream certificate expired"
+	case tlsFailureNotYetValid:
+		return "upstream certificate not yet valid"
+	case tlsFailureUnknownAuthority:
+		return "upstream certificate signed by an unknown authority"
+	case tlsFailureHostnameMismatch:
+		return "upstream certificate doesn't match the server name"
+	case tlsFailureNotTLS:
+		return "upstream doesn't speak TLS"
+	case tlsFailureRemoteAlert:
+		return "upstream rejected the handshake"
+	default:
+		return "upstream certificate invalid"
+	}
+}
+
+// String returns the failure with its certificate and SNI details for logs
+func (f *upstreamTLSFailure) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "reason=%s sni=%s", f.reason, f.serverName)
+	if f.subject != "" {
+		fmt.Fprintf(&b, " subject=%q not_after=%s", f.subject, f.notAfter.UTC().Format(time.RFC3339))
+	}
+	fmt.Fprintf(&b, " error=%q", f.err.Error())
+	return b.String()
+}
+
+// serverName returns the SNI the request's upstream transport sends to the
+// target
+func (rp *ReverseProxy) serverName(req *http.Request) string {
+	if tlsConfig := rp.transportFor(req).TLSClientConfig; tlsConfig != nil && tlsConfig.ServerName != "" {
+		return tlsConfig.ServerName
+	}
+	return req.URL.Hostname()
+}
+
+// proxyFailureKey is the context key of the proxyFailure recording why a
+// request couldn't be proxied
+type proxyFailureKey struct{}
+
+// proxyFailure records why the reverse proxy failed a request. The error
+// handler only sees a clone of the request, so it is shared through the
+// request context.
+type proxyFailure struct {
+	err       error
+	isTimeout bool
+	tls       *upstreamTLSFailure
+}
+
+// withProxyFailure returns the request with a proxyFailure the reverse
+// proxy's error handler fills in
+func withProxyFailure(r *http.Request) (*http.Request, *proxyFailure) {
+	failure := &proxyFailure{}
+	return r.WithContext(context.WithValue(r.Context(), proxyFailureKey{}, failure)), failure
+}
+
+// recordProxyFailure records a proxy error in the request's proxyFailure,
+// if it has one
+func recordProxyFailure(r *http.Request, err error, isTimeout bool, tlsFailure *upstreamTLSFailure) {
+	if failure, ok := r.Context().Value(proxyFailureKey{}).(*proxyFailure); ok {
+		failure.err = err
+		failure.isTimeout = isTimeout
+		failure.tls = tlsFailure
+	}
+}
+
+// recordUpstreamTLSFailure logs and counts a failed TLS handshake with an
+// upstream target
+func (p *Pipeline) recordUpstreamTLSFailure(upstreamID string, target *types.Target, failure *upstreamTLSFailure) {
+	addr := targetAddr(target)
+	log.Printf("TLS handshake with target %s of upstream %s failed: %s", addr, upstreamID, failure)
+
+	p.mu.Lock()
+	p.tlsFailureCount++
+	p.mu.Unlock()
+	if p.upstreamTLSFailureCounter != nil {
+		p.upstreamTLSFailureCounter.WithLabelValues(upstreamID, addr, failure.reason).Inc()
+		p.trackTargetSeries(upstreamID, addr)
+	}
+}
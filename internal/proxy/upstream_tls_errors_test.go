@@ -0,0 +1,186 @@
+package proxy
+
+import (
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/songzhibin97/stargate/internal/config"
+	"github.com/songzhibin97/stargate/internal/health"
+	"github.com/songzhibin97/stargate/internal/loadbalancer"
+	"github.com/songzhibin97/stargate/internal/types"
+)
+
+// newTLSBackend starts a backend presenting a certificate for
+// backend.internal signed by ca, valid between notBefore and notAfter
+func newTLSBackend(t *testing.T, ca *testCA, notBefore, notAfter time.Time) *httptest.Server {
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(10),
+		Subject:      pkix.Name{CommonName: "backend.internal"},
+		NotBefore:    notBefore,
+		NotAfter:     notAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		DNSNames:     []string{"backend.internal"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &ca.key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	server.TLS = &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: ca.key}}}
+	server.StartTLS()
+	return server
+}
+
+func TestPipeline_UpstreamTLSErrors(t *testing.T) {
+	ca := newTestCA(t)
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	writeTestFile(t, caFile, ca.pem)
+
+	expired := newTLSBackend(t, ca, time.Now().Add(-2*time.Hour), time.Now().Add(-time.Hour))
+	defer expired.Close()
+	valid := newTLSBackend(t, ca, time.Now().Add(-time.Hour), time.Now().Add(time.Hour))
+	defer valid.Close()
+	untrusted := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer untrusted.Close()
+	plain := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer plain.Close()
+
+	upstreamTLS := func(serverName string) config.UpstreamProxyConfig {
+		return config.UpstreamProxyConfig{TLS: config.UpstreamTLSConfig{Enabled: true, CAFile: caFile, ServerName: serverName}}
+	}
+	cfg := &config.Config{}
+	cfg.Metrics = config.MetricsConfig{Enabled: true, Provider: "prometheus", Path: "/metrics"}
+	cfg.Proxy = config.ProxyConfig{
+		ConnectTimeout:        5 * time.Second,
+		ResponseHeaderTimeout: 5 * time.Second,
+		BufferSize:            32 * 1024,
+		TLSErrorDetail:        true,
+		Upstreams: map[string]config.UpstreamProxyConfig{
+			"expired":   upstreamTLS("backend.internal"),
+			"valid":     upstreamTLS("backend.internal"),
+			"untrusted": upstreamTLS(""),
+			"mismatch":  upstreamTLS("other.internal"),
+			"plain":     upstreamTLS(""),
+		},
+	}
+	cfg.Upstreams.Defaults.HealthCheck.Passive = config.PassiveHealthCheckConfig{
+		Enabled:              true,
+		ConsecutiveFailures:  5,
+		IsolationDuration:    time.Minute,
+		RecoveryInterval:     time.Minute,
+		ConsecutiveSuccesses: 1,
+		FailureStatusCodes:   []int{502, 503},
+	}
+
+	pipeline, err := NewPipeline(cfg, nil)
+	if err != nil {
+		t.Fatalf("Failed to create pipeline: %v", err)
+	}
+	defer pipeline.Stop()
+
+	lb := loadbalancer.NewRoundRobinBalancer(cfg)
+	targets := make(map[string]*types.Target)
+	for upstreamID, backend := range map[string]*httptest.Server{
+		"expired":   expired,
+		"valid":     valid,
+		"untrusted": untrusted,
+		"mismatch":  valid,
+		"plain":     plain,
+	} {
+		host, portStr, _ := net.SplitHostPort(backend.Listener.Addr().String())
+		port, _ := strconv.Atoi(portStr)
+		targets[upstreamID] = &types.Target{Host: host, Port: port, Weight: 100, Healthy: true}
+		if err := lb.UpdateUpstream(&types.Upstream{
+			ID:        upstreamID,
+			Name:      upstreamID,
+			Algorithm: "round_robin",
+			Targets:   []*types.Target{targets[upstreamID]},
+		}); err != nil {
+			t.Fatalf("Failed to add upstream: %v", err)
+		}
+	}
+	pipeline.loadBalancer = lb
+	pipeline.router = &pathRouter{routes: map[string]*Route{
+		"/expired":   {ID: "expired", UpstreamID: "expired"},
+		"/valid":     {ID: "valid", UpstreamID: "valid"},
+		"/untrusted": {ID: "untrusted", UpstreamID: "untrusted"},
+		"/mismatch":  {ID: "mismatch", UpstreamID: "mismatch"},
+		"/plain":     {ID: "plain", UpstreamID: "plain"},
+	}}
+	handler := pipeline.createHandler()
+
+	tests := []struct {
+		upstream string
+		reason   string
+		message  string
+	}{
+		{"expired", tlsFailureExpired, "Upstream TLS handshake failed: upstream certificate expired"},
+		{"untrusted", tlsFailureUnknownAuthority, "Upstream TLS handshake failed: upstream certificate signed by an unknown authority"},
+		{"mismatch", tlsFailureHostnameMismatch, "Upstream TLS handshake failed: upstream certificate doesn't match the server name"},
+		{"plain", tlsFailureNotTLS, "Upstream TLS handshake failed: upstream doesn't speak TLS"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.upstream, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, httptest.NewRequest("GET", "/"+tt.upstream, nil))
+			if rr.Code != http.StatusBadGateway {
+				t.Fatalf("Expected status 502, got %d: %s", rr.Code, rr.Body.String())
+			}
+			var body map[string]string
+			json.NewDecoder(rr.Body).Decode(&body)
+			if body["message"] != tt.message {
+				t.Errorf("Expected message %q, got %q", tt.message, body["message"])
+			}
+
+			// The passive health checker records the failure's reason
+			stats := pipeline.passiveHealthChecker.GetTargetStats(tt.upstream, targets[tt.upstream])
+			if stats["last_failure_reason"] != health.ReasonTLSHandshake {
+				t.Errorf("Expected the TLS handshake failure recorded, got %v", stats["last_failure_reason"])
+			}
+		})
+	}
+
+	// A valid certificate is proxied
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/valid", nil))
+	if rr.Code != http.StatusOK || rr.Body.String() != "ok" {
+		t.Fatalf("Expected the valid upstream proxied, got %d %q", rr.Code, rr.Body.String())
+	}
+
+	if count := pipeline.Metrics()["tls_failures"]; count != int64(len(tests)) {
+		t.Errorf("Expected %d TLS failures, got %v", len(tests), count)
+	}
+	metricsRR := httptest.NewRecorder()
+	pipeline.getMetricsProvider().Handler().ServeHTTP(metricsRR, httptest.NewRequest("GET", "/metrics", nil))
+	for _, tt := range tests {
+		expected := `upstream_tls_handshake_failures_total{reason="` + tt.reason + `",target="` + targets[tt.upstream].Host + ":" + strconv.Itoa(targets[tt.upstream].Port) + `",upstream="` + tt.upstream + `"} 1`
+		if !strings.Contains(metricsRR.Body.String(), expected) {
+			t.Errorf("Expected metrics output to contain %q", expected)
+		}
+	}
+
+	// Without detail, clients only learn the handshake failed
+	pipeline.reverseProxy.config.Proxy.TLSErrorDetail = false
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/expired", nil))
+	if !strings.Contains(rr.Body.String(), `"message": "Upstream TLS handshake failed"`) {
+		t.Errorf("Expected the failure without detail, got %s", rr.Body.String())
+	}
+}
@@ -0,0 +1,355 @@
+package proxy
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"reflect"
+	"time"
+
+	"github.com/songzhibin97/stargate/internal/config"
+)
+
+// defaultCertReloadInterval is how often upstream TLS files are checked for changes
+const defaultCertReloadInterval = 30 * time.Second
+
+// upstreamTransport is the transport used for a single upstream
+type upstreamTransport struct {
+	settings  config.UpstreamProxyConfig
+	transport *http.Transport
+	files     map[string]time.Time // TLS file modification times when the transport was built
+}
+
+// newTransport creates an upstream transport from the proxy configuration
+// and the upstream's connection settings
+func newTransport(cfg *config.Config, settings config.UpstreamProxyConfig, tlsConfig *tls.Config) *http.Transport {
+	return &http.Transport{
+		DialContext:           newDialContext(cfg, settings),
+		ResponseHeaderTimeout: cfg.Proxy.ResponseHeaderTimeout,
+		MaxIdleConns:          cfg.Proxy.MaxIdleConns,
+		MaxIdleConnsPerHost:   cfg.Proxy.MaxIdleConnsPerHost,
+		IdleConnTimeout:       cfg.Proxy.KeepAliveTimeout,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+		TLSClientConfig:       tlsConfig,
+	}
+}
+
+// newDialer creates the dialer for an upstream, falling back to the global
+// proxy settings for values the upstream doesn't override
+func newDialer(cfg *config.Config, settings config.UpstreamProxyConfig) *net.Dialer {
+	dialer := &net.Dialer{
+		Timeout:   cfg.Proxy.ConnectTimeout,
+		KeepAlive: cfg.Proxy.KeepAliveTimeout,
+	}
+
+	if settings.ConnectTimeout > 0 {
+		dialer.Timeout = settings.ConnectTimeout
+	}
+	if settings.KeepAlive != 0 {
+		dialer.KeepAlive = settings.KeepAlive
+	}
+	if settings.FallbackDelay > 0 {
+		dialer.FallbackDelay = settings.FallbackDelay
+	}
+	if settings.DualStack != nil && !*settings.DualStack {
+		// A negative delay disables racing IPv4 and IPv6 attempts
+		dialer.FallbackDelay = -1
+	}
+
+	return dialer
+}
+
+// newDialContext returns the dial function for an upstream, applying TCP
+// options the dialer doesn't expose
+func newDialContext(cfg *config.Config, settings config.UpstreamProxyConfig) func(ctx context.Context, network, address string) (net.Conn, error) {
+	dialer := newDialer(cfg, settings)
+	if settings.TCPNoDelay == nil || *settings.TCPNoDelay {
+		// Go enables TCP no-delay by default
+		return dialer.DialContext
+	}
+
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		conn, err := dialer.DialContext(ctx, network, address)
+		if err != nil {
+			return nil, err
+		}
+		if tcpConn, ok := conn.(*net.TCPConn); ok {
+			if err := tcpConn.SetNoDelay(false); err != nil {
+				conn.Close()
+				return nil, err
+			}
+		}
+		return conn, nil
+	}
+}
+
+// newUpstreamTransport builds the transport for an upstream's settings
+func newUpstreamTransport(cfg *config.Config, settings config.UpstreamProxyConfig) (*upstreamTransport, error) {
+	var tlsConfig *tls.Config
+	if settings.TLS.Enabled {
+		var err error
+		tlsConfig, err = createUpstreamTLSConfig(settings.TLS)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &upstreamTransport{
+		settings:  settings,
+		transport: newTransport(cfg, settings, tlsConfig),
+		files:     tlsFileModTimes(settings.TLS),
+	}, nil
+}
+
+// createUpstreamTLSConfig creates the client TLS configuration for an upstream
+func createUpstreamTLSConfig(tlsConfig config.UpstreamTLSConfig) (*tls.Config, error) {
+	clientConfig := &tls.Config{
+		InsecureSkipVerify: tlsConfig.InsecureSkipVerify,
+		ServerName:         tlsConfig.ServerName,
+	}
+
+	// Load client certificate for mTLS
+	if tlsConfig.CertFile != "" || tlsConfig.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(tlsConfig.CertFile, tlsConfig.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		clientConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	// Load CA bundle, the system roots are used otherwise
+	if tlsConfig.CAFile != "" {
+		caPEM, err := os.ReadFile(tlsConfig.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("no certificates found in CA file %s", tlsConfig.CAFile)
+		}
+		clientConfig.RootCAs = pool
+	}
+
+	return clientConfig, nil
+}
+
+// tlsFileModTimes returns the modification times of the configured TLS files
+func tlsFileModTimes(tlsConfig config.UpstreamTLSConfig) map[string]time.Time {
+	files := make(map[string]time.Time)
+	if !tlsConfig.Enabled {
+		return files
+	}
+
+	for _, path := range []string{tlsConfig.CertFile, tlsConfig.KeyFile, tlsConfig.CAFile} {
+		if path == "" {
+			continue
+		}
+		if stat, err := os.Stat(path); err == nil {
+			files[path] = stat.ModTime()
+		}
+	}
+	return files
+}
+
+// filesChanged reports whether any TLS file changed since the transport was built
+func (ut *upstreamTransport) filesChanged() bool {
+	current := tlsFileModTimes(ut.settings.TLS)
+	if len(current) != len(ut.files) {
+		return true
+	}
+	for path, modTime := range current {
+		if !ut.files[path].Equal(modTime) {
+			return true
+		}
+	}
+	return false
+}
+
+// upstreamID returns the upstream ID the pipeline stored in the request context
+func upstreamID(req *http.Request) string {
+	id, _ := req.Context().Value("upstream_id").(string)
+	return id
+}
+
+// transportFor returns the transport for the request's upstream
+func (rp *ReverseProxy) transportFor(req *http.Request) *http.Transport {
+	return rp.upstreamTransport(upstreamID(req))
+}
+
+// upstreamTransport returns the transport of the upstream, or the default
+// transport if the upstream has no connection settings of its own
+func (rp *ReverseProxy) upstreamTransport(id string) *http.Transport {
+	rp.upstreamMu.RLock()
+	defer rp.upstreamMu.RUnlock()
+
+	if ut, exists := rp.upstreams[id]; exists {
+		return ut.transport
+	}
+	return rp.transport
+}
+
+// upstreamTLSEnabled reports whether connections to the upstream use TLS
+func (rp *ReverseProxy) upstreamTLSEnabled(id string) bool {
+	rp.upstreamMu.RLock()
+	defer rp.upstreamMu.RUnlock()
+
+	ut, exists := rp.upstreams[id]
+	return exists && ut.settings.TLS.Enabled
+}
+
+// UpdateUpstreams replaces the per-upstream connection settings. Transports
+// of upstreams whose settings changed are rebuilt and the idle connections of
+// the replaced transports are closed; in-flight requests finish on the old
+// transport.
+func (rp *ReverseProxy) UpdateUpstreams(settings map[string]config.UpstreamProxyConfig) error {
+	rp.upstreamMu.Lock()
+	defer rp.upstreamMu.Unlock()
+
+	upstreams, err := rp.buildUpstreams(rp.config, settings)
+	if err != nil {
+		return err
+	}
+	rp.swapUpstreams(upstreams)
+	return nil
+}
+
+// buildUpstreams builds the transports of the per-upstream connection
+// settings, reusing the transports of upstreams whose settings didn't change.
+// The caller must hold upstreamMu.
+func (rp *ReverseProxy) buildUpstreams(cfg *config.Config, settings map[string]config.UpstreamProxyConfig) (map[string]*upstreamTransport, error) {
+	upstreams := make(map[string]*upstreamTransport, len(settings))
+	for id, upstreamSettings := range settings {
+		if existing, exists := rp.upstreams[id]; exists && settingsEqual(existing.settings, upstreamSettings) && !existing.filesChanged() {
+			upstreams[id] = existing
+			continue
+		}
+
+		ut, err := newUpstreamTransport(cfg, upstreamSettings)
+		if err != nil {
+			return nil, fmt.Errorf("upstream %s: %w", id, err)
+		}
+		upstreams[id] = ut
+	}
+	return upstreams, nil
+}
+
+// swapUpstreams replaces the upstream transports, closing the idle
+// connections of the replaced ones. The caller must hold upstreamMu.
+func (rp *ReverseProxy) swapUpstreams(upstreams map[string]*upstreamTransport) {
+	for id, existing := range rp.upstreams {
+		if upstreams[id] != existing {
+			existing.transport.CloseIdleConnections()
+		}
+	}
+	rp.upstreams = upstreams
+
+	// Watch TLS files once an upstream uses TLS
+	if rp.stopReload == nil {
+		for _, ut := range upstreams {
+			if ut.settings.TLS.Enabled {
+				rp.startCertReloader()
+				break
+			}
+		}
+	}
+}
+
+// PrepareConfig builds the transports of a reloaded configuration's
+// per-upstream connection settings and returns a function applying them, so
+// an invalid configuration leaves the current transports in use
+func (rp *ReverseProxy) PrepareConfig(cfg *config.Config) (func(), error) {
+	rp.upstreamMu.RLock()
+	upstreams, err := rp.buildUpstreams(cfg, cfg.Proxy.Upstreams)
+	rp.upstreamMu.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+
+	return func() {
+		rp.upstreamMu.Lock()
+		defer rp.upstreamMu.Unlock()
+
+		rp.config = cfg
+		rp.swapUpstreams(upstreams)
+	}, nil
+}
+
+// UpdateConfig applies a reloaded configuration's per-upstream connection settings
+func (rp *ReverseProxy) UpdateConfig(cfg *config.Config) error {
+	apply, err := rp.PrepareConfig(cfg)
+	if err != nil {
+		return err
+	}
+	apply()
+	return nil
+}
+
+// settingsEqual reports whether two upstream settings are identical
+func settingsEqual(a, b config.UpstreamProxyConfig) bool {
+	return reflect.DeepEqual(a, b)
+}
+
+// reloadUpstreamCertificates rebuilds the transports of upstreams whose TLS
+// files changed. If the new files can't be loaded, the previous transport
+// stays in use.
+func (rp *ReverseProxy) reloadUpstreamCertificates() {
+	rp.upstreamMu.Lock()
+	defer rp.upstreamMu.Unlock()
+
+	for id, existing := range rp.upstreams {
+		if !existing.settings.TLS.Enabled || !existing.filesChanged() {
+			continue
+		}
+
+		ut, err := newUpstreamTransport(rp.config, existing.settings)
+		if err != nil {
+			log.Printf("Failed to reload TLS certificates for upstream %s, keeping previous ones: %v", id, err)
+			continue
+		}
+
+		rp.upstreams[id] = ut
+		existing.transport.CloseIdleConnections()
+		log.Printf("Reloaded TLS certificates for upstream %s", id)
+	}
+}
+
+// startCertReloader polls upstream TLS files and reloads changed certificates.
+// The caller must hold upstreamMu.
+func (rp *ReverseProxy) startCertReloader() {
+	interval := rp.config.Proxy.CertReloadInterval
+	if interval <= 0 {
+		interval = defaultCertReloadInterval
+	}
+
+	stopReload := make(chan struct{})
+	rp.stopReload = stopReload
+	rp.reloadWg.Add(1)
+	go func() {
+		defer rp.reloadWg.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stopReload:
+				return
+			case <-ticker.C:
+				rp.reloadUpstreamCertificates()
+			}
+		}
+	}()
+}
+
+// roundTripperFunc adapts a function to http.RoundTripper
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
@@ -0,0 +1,342 @@
+package proxy
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/songzhibin97/stargate/internal/config"
+	"github.com/songzhibin97/stargate/internal/types"
+)
+
+// testCA is a certificate authority issuing test certificates
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate CA key: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "stargate test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create CA certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("Failed to parse CA certificate: %v", err)
+	}
+
+	return &testCA{
+		cert: cert,
+		key:  key,
+		pem:  pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+	}
+}
+
+// issue creates a certificate signed by the CA and returns it PEM encoded
+func (ca *testCA) issue(t *testing.T, serial int64, commonName string, usage x509.ExtKeyUsage) ([]byte, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		DNSNames:     []string{"backend.internal"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("Failed to marshal key: %v", err)
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+func writeTestFile(t *testing.T, path string, data []byte) {
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatalf("Failed to write %s: %v", path, err)
+	}
+}
+
+// newMTLSBackend starts a backend requiring client certificates issued by ca
+// and reports the common name of the client certificate it received
+func newMTLSBackend(t *testing.T, ca *testCA) *httptest.Server {
+	certPEM, keyPEM := ca.issue(t, 2, "backend", x509.ExtKeyUsageServerAuth)
+	serverCert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		t.Fatalf("Failed to load server certificate: %v", err)
+	}
+
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(ca.cert)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Client-CN", r.TLS.PeerCertificates[0].Subject.CommonName)
+		w.WriteHeader(http.StatusOK)
+	}))
+	server.TLS = &tls.Config{
+		Certificates: []tls.Certificate{serverCert},
+		ClientCAs:    clientCAs,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	}
+	server.StartTLS()
+	return server
+}
+
+// proxyToUpstream sends a request through the proxy to the backend as upstreamID
+func proxyToUpstream(rp *ReverseProxy, backend *httptest.Server, upstreamID string) *httptest.ResponseRecorder {
+	host, portStr, _ := net.SplitHostPort(backend.Listener.Addr().String())
+	port, _ := strconv.Atoi(portStr)
+
+	req := httptest.NewRequest("GET", "/test", nil)
+	req = SetTarget(req, &types.Target{Host: host, Port: port})
+	req = req.WithContext(context.WithValue(req.Context(), "upstream_id", upstreamID))
+
+	rec := httptest.NewRecorder()
+	rp.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestReverseProxy_UpstreamMTLS(t *testing.T) {
+	ca := newTestCA(t)
+	backend := newMTLSBackend(t, ca)
+	defer backend.Close()
+
+	dir := t.TempDir()
+	caFile := filepath.Join(dir, "ca.pem")
+	certFile := filepath.Join(dir, "client.pem")
+	keyFile := filepath.Join(dir, "client-key.pem")
+	writeTestFile(t, caFile, ca.pem)
+	certPEM, keyPEM := ca.issue(t, 3, "gateway", x509.ExtKeyUsageClientAuth)
+	writeTestFile(t, certFile, certPEM)
+	writeTestFile(t, keyFile, keyPEM)
+
+	cfg := &config.Config{
+		Proxy: config.ProxyConfig{
+			ConnectTimeout:        5 * time.Second,
+			ResponseHeaderTimeout: 5 * time.Second,
+			KeepAliveTimeout:      30 * time.Second,
+			MaxIdleConns:          10,
+			MaxIdleConnsPerHost:   10,
+			BufferSize:            32 * 1024,
+			Upstreams: map[string]config.UpstreamProxyConfig{
+				"mtls-upstream": {
+					TLS: config.UpstreamTLSConfig{
+						Enabled:    true,
+						CertFile:   certFile,
+						KeyFile:    keyFile,
+						CAFile:     caFile,
+						ServerName: "backend.internal",
+					},
+				},
+				"no-cert-upstream": {
+					TLS: config.UpstreamTLSConfig{
+						Enabled: true,
+						CAFile:  caFile,
+					},
+				},
+			},
+		},
+	}
+
+	rp, err := NewReverseProxy(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create reverse proxy: %v", err)
+	}
+	defer rp.Close()
+
+	// Upstream with a client certificate is accepted
+	rec := proxyToUpstream(rp, backend, "mtls-upstream")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rec.Code)
+	}
+	if cn := rec.Header().Get("X-Client-CN"); cn != "gateway" {
+		t.Errorf("Expected client CN gateway, got %s", cn)
+	}
+
+	// Upstream without a client certificate is rejected by the backend
+	rec = proxyToUpstream(rp, backend, "no-cert-upstream")
+	if rec.Code == http.StatusOK {
+		t.Errorf("Expected request without client certificate to fail, got %d", rec.Code)
+	}
+
+	// Rotated client certificate is picked up without a restart
+	certPEM, keyPEM = ca.issue(t, 4, "gateway-rotated", x509.ExtKeyUsageClientAuth)
+	writeTestFile(t, certFile, certPEM)
+	writeTestFile(t, keyFile, keyPEM)
+	future := time.Now().Add(time.Minute)
+	os.Chtimes(certFile, future, future)
+	os.Chtimes(keyFile, future, future)
+	rp.reloadUpstreamCertificates()
+
+	rec = proxyToUpstream(rp, backend, "mtls-upstream")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 after reload, got %d", rec.Code)
+	}
+	if cn := rec.Header().Get("X-Client-CN"); cn != "gateway-rotated" {
+		t.Errorf("Expected client CN gateway-rotated after reload, got %s", cn)
+	}
+
+	// Broken files keep the previous certificate in use
+	writeTestFile(t, certFile, []byte("invalid"))
+	os.Chtimes(certFile, future.Add(time.Minute), future.Add(time.Minute))
+	rp.reloadUpstreamCertificates()
+
+	rec = proxyToUpstream(rp, backend, "mtls-upstream")
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected status 200 with previous certificate, got %d", rec.Code)
+	}
+}
+
+func TestNewReverseProxy_InvalidUpstreamTLS(t *testing.T) {
+	cfg := &config.Config{
+		Proxy: config.ProxyConfig{
+			Upstreams: map[string]config.UpstreamProxyConfig{
+				"broken": {
+					TLS: config.UpstreamTLSConfig{
+						Enabled:  true,
+						CertFile: "/nonexistent/client.pem",
+						KeyFile:  "/nonexistent/client-key.pem",
+					},
+				},
+			},
+		},
+	}
+
+	if _, err := NewReverseProxy(cfg); err == nil {
+		t.Error("Expected error for missing client certificate files")
+	}
+}
+
+func TestNewDialer_UpstreamOverrides(t *testing.T) {
+	cfg := &config.Config{
+		Proxy: config.ProxyConfig{
+			ConnectTimeout:   5 * time.Second,
+			KeepAliveTimeout: 30 * time.Second,
+		},
+	}
+
+	dialer := newDialer(cfg, config.UpstreamProxyConfig{})
+	if dialer.Timeout != 5*time.Second {
+		t.Errorf("Expected global connect timeout 5s, got %v", dialer.Timeout)
+	}
+	if dialer.KeepAlive != 30*time.Second {
+		t.Errorf("Expected global keep-alive 30s, got %v", dialer.KeepAlive)
+	}
+
+	dualStack := false
+	dialer = newDialer(cfg, config.UpstreamProxyConfig{
+		ConnectTimeout: 20 * time.Second,
+		KeepAlive:      -1,
+		DualStack:      &dualStack,
+	})
+	if dialer.Timeout != 20*time.Second {
+		t.Errorf("Expected upstream connect timeout 20s, got %v", dialer.Timeout)
+	}
+	if dialer.KeepAlive >= 0 {
+		t.Errorf("Expected keep-alive disabled, got %v", dialer.KeepAlive)
+	}
+	if dialer.FallbackDelay >= 0 {
+		t.Errorf("Expected dual-stack fallback disabled, got %v", dialer.FallbackDelay)
+	}
+}
+
+func TestReverseProxy_UpdateUpstreams(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer backend.Close()
+
+	noDelay := false
+	cfg := &config.Config{
+		Proxy: config.ProxyConfig{
+			ConnectTimeout:   5 * time.Second,
+			KeepAliveTimeout: 30 * time.Second,
+			BufferSize:       32 * 1024,
+			Upstreams: map[string]config.UpstreamProxyConfig{
+				"slow-link": {ConnectTimeout: 10 * time.Second, TCPNoDelay: &noDelay},
+				"local":     {KeepAlive: 15 * time.Second},
+			},
+		},
+	}
+
+	rp, err := NewReverseProxy(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create reverse proxy: %v", err)
+	}
+	defer rp.Close()
+
+	// Upstream with TCP no-delay turned off can still be proxied to
+	if rec := proxyToUpstream(rp, backend, "slow-link"); rec.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", rec.Code)
+	}
+
+	if rp.upstreams["slow-link"].transport == rp.upstreams["local"].transport {
+		t.Fatal("Expected each upstream to have its own transport")
+	}
+	slowLink := rp.upstreams["slow-link"].transport
+	local := rp.upstreams["local"].transport
+
+	// Only the changed upstream gets a new transport on config reload
+	reloaded := *cfg
+	reloaded.Proxy.Upstreams = map[string]config.UpstreamProxyConfig{
+		"slow-link": {ConnectTimeout: 10 * time.Second, TCPNoDelay: &noDelay},
+		"local":     {KeepAlive: 60 * time.Second},
+	}
+	if err := rp.UpdateConfig(&reloaded); err != nil {
+		t.Fatalf("Failed to update upstreams: %v", err)
+	}
+	if rp.upstreams["slow-link"].transport != slowLink {
+		t.Error("Expected unchanged upstream to keep its transport")
+	}
+	if rp.upstreams["local"].transport == local {
+		t.Error("Expected changed upstream to get a new transport")
+	}
+
+	// Removed upstreams fall back to the global transport
+	if err := rp.UpdateUpstreams(nil); err != nil {
+		t.Fatalf("Failed to update upstreams: %v", err)
+	}
+	if rec := proxyToUpstream(rp, backend, "slow-link"); rec.Code != http.StatusOK {
+		t.Errorf("Expected status 200 on global transport, got %d", rec.Code)
+	}
+}
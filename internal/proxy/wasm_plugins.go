@@ -0,0 +1,40 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/songzhibin97/stargate/internal/middleware"
+)
+
+// wasmPluginsPath returns the path of the WASM plugins endpoint, or "" if
+// the REST Admin API is disabled
+func (p *Pipeline) wasmPluginsPath() string {
+	return p.nodeAdminPath("/wasm/plugins")
+}
+
+// WASMPluginsResponse is the response of the WASM plugins endpoint
+type WASMPluginsResponse struct {
+	Enabled bool                    `json:"enabled"` // Whether the WASM middleware is enabled
+	Plugins []middleware.PluginInfo `json:"plugins"` // Loaded plugins by ID
+}
+
+// handleWASMPlugins returns the loaded WASM plugins with the hash of their
+// module, which identifies their version, and their reload state
+func (p *Pipeline) handleWASMPlugins(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(map[string]string{"error": "method not allowed"})
+		return
+	}
+
+	response := WASMPluginsResponse{Plugins: []middleware.PluginInfo{}}
+	if p.wasmMiddleware != nil {
+		response.Enabled = true
+		response.Plugins = p.wasmMiddleware.PluginInfos()
+	}
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,73 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/songzhibin97/stargate/internal/config"
+	"github.com/songzhibin97/stargate/internal/middleware"
+)
+
+func TestPipeline_WASMPlugins(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.AdminAPI.REST = config.RESTConfig{Enabled: true, Prefix: "/api/v1"}
+	cfg.AdminAPI.Auth = config.AuthConfig{
+		Enabled: true,
+		APIKey:  config.APIKeyConfig{Header: "X-Admin-Key", Keys: []string{"secret"}},
+	}
+
+	pipeline, err := NewPipeline(cfg, nil)
+	if err != nil {
+		t.Fatalf("Failed to create pipeline: %v", err)
+	}
+	defer pipeline.Stop()
+
+	list := func(method, key string) (*httptest.ResponseRecorder, WASMPluginsResponse) {
+		req := httptest.NewRequest(method, "/_stargate/admin/wasm/plugins", nil)
+		if key != "" {
+			req.Header.Set("X-Admin-Key", key)
+		}
+		rr := httptest.NewRecorder()
+		pipeline.ServeHTTP(rr, req)
+		var response WASMPluginsResponse
+		json.Unmarshal(rr.Body.Bytes(), &response)
+		return rr, response
+	}
+
+	if rr, _ := list("GET", ""); rr.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 without credentials, got %d", rr.Code)
+	}
+	if rr, _ := list("POST", "secret"); rr.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status 405 for POST, got %d", rr.Code)
+	}
+	if rr, response := list("GET", "secret"); rr.Code != http.StatusOK || response.Enabled || len(response.Plugins) != 0 {
+		t.Errorf("Expected no plugins with WASM disabled, got %d %+v", rr.Code, response)
+	}
+
+	// A minimal valid module
+	path := filepath.Join(t.TempDir(), "plugin.wasm")
+	if err := os.WriteFile(path, []byte("\x00asm\x01\x00\x00\x00"), 0644); err != nil {
+		t.Fatalf("Failed to write plugin: %v", err)
+	}
+	pipeline.wasmMiddleware, err = middleware.NewWASMMiddleware(&config.WASMConfig{
+		Enabled: true,
+		Plugins: []config.WASMPlugin{{ID: "headers", Name: "headers", Path: path, Required: true}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create WASM middleware: %v", err)
+	}
+	defer pipeline.wasmMiddleware.Close(context.Background())
+
+	rr, response := list("GET", "secret")
+	if rr.Code != http.StatusOK || !response.Enabled || len(response.Plugins) != 1 {
+		t.Fatalf("Expected 1 plugin, got %d %s", rr.Code, rr.Body.String())
+	}
+	if plugin := response.Plugins[0]; plugin.ID != "headers" || plugin.Hash == "" || plugin.LoadedAt.IsZero() {
+		t.Errorf("Unexpected plugin: %+v", plugin)
+	}
+}
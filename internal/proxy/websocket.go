@@ -9,10 +9,12 @@ import (
 	"io"
 	"net"
 	"net/http"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/songzhibin97/stargate/internal/clientip"
 	"github.com/songzhibin97/stargate/internal/config"
 	"github.com/songzhibin97/stargate/internal/types"
 )
@@ -42,6 +44,7 @@ type websocketConnection struct {
 	ctx        context.Context
 	cancel     context.CancelFunc
 	startTime  time.Time
+	release    func() // releases the connection limit reservation
 }
 
 // NewWebSocketProxy creates a new WebSocket proxy
@@ -134,6 +137,7 @@ func (wp *WebSocketProxy) HandleWebSocketUpgrade(w http.ResponseWriter, r *http.
 		ctx:          ctx,
 		cancel:       cancel,
 		startTime:    time.Now(),
+		release:      connectionRelease(r),
 	}
 
 	// Register connection
@@ -149,7 +153,7 @@ func (wp *WebSocketProxy) HandleWebSocketUpgrade(w http.ResponseWriter, r *http.
 
 // connectToUpstream establishes connection to upstream WebSocket server
 func (wp *WebSocketProxy) connectToUpstream(target *types.Target, r *http.Request) (net.Conn, error) {
-	address := fmt.Sprintf("%s:%d", target.Host, target.Port)
+	address := net.JoinHostPort(target.Host, strconv.Itoa(target.Port))
 	
 	// Use configured connect timeout
 	dialer := &net.Dialer{
@@ -178,7 +182,11 @@ func (wp *WebSocketProxy) sendUpgradeRequest(conn net.Conn, r *http.Request) err
 	}
 	
 	// Add forwarded headers
-	req += fmt.Sprintf("X-Forwarded-For: %s\r\n", getClientIP(r))
+	forwardedFor := clientip.RemoteIP(r)
+	if prior := strings.Join(r.Header.Values("X-Forwarded-For"), ", "); prior != "" {
+		forwardedFor = prior + ", " + forwardedFor
+	}
+	req += fmt.Sprintf("X-Forwarded-For: %s\r\n", forwardedFor)
 	req += fmt.Sprintf("X-Forwarded-Proto: %s\r\n", getProto(r))
 	req += fmt.Sprintf("X-Real-IP: %s\r\n", getClientIP(r))
 	
@@ -313,6 +321,10 @@ func (wp *WebSocketProxy) cleanupConnection(conn *websocketConnection) {
 	wp.mu.Lock()
 	delete(wp.activeConns, conn.id)
 	wp.mu.Unlock()
+
+	if conn.release != nil {
+		conn.release()
+	}
 	
 	// Log connection closure
 	duration := time.Since(conn.startTime)
@@ -0,0 +1,187 @@
+package ratelimit
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"github.com/songzhibin97/stargate/internal/clientip"
+	"github.com/songzhibin97/stargate/internal/config"
+)
+
+// Reasons a connection is rejected by the connection limiter
+const (
+	ConnectionRejectMaxPerIP     = "max_per_ip"
+	ConnectionRejectNewPerSecond = "new_per_second"
+)
+
+// connectionSweepInterval is how often idle client state is removed
+const connectionSweepInterval = time.Minute
+
+// ConnectionLimiter limits the concurrent and new connections of each client
+// IP. Each accepted connection holds a slot until it is released, and new
+// connections draw from a per client token bucket.
+type ConnectionLimiter struct {
+	maxPerIP int
+	rate     float64
+	burst    float64
+	exempt   []*net.IPNet
+
+	mu        sync.Mutex
+	clients   map[string]*connectionClient
+	lastSweep time.Time
+
+	// Statistics
+	accepted int64
+	rejected map[string]int64 // by reason
+}
+
+// connectionClient is the connection state of a single client IP
+type connectionClient struct {
+	active     int
+	tokens     float64
+	lastRefill time.Time
+}
+
+// NewConnectionLimiter creates a connection limiter from the configuration.
+// It returns nil if the limits are disabled, which allows every connection.
+func NewConnectionLimiter(cfg config.ConnectionLimitConfig) (*ConnectionLimiter, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+
+	exempt, err := clientip.ParseNetworks(cfg.ExemptCIDRs)
+	if err != nil {
+		return nil, err
+	}
+
+	burst := float64(cfg.Burst)
+	if burst <= 0 {
+		burst = cfg.NewPerSecond
+		if burst < 1 {
+			burst = 1
+		}
+	}
+
+	return &ConnectionLimiter{
+		maxPerIP:  cfg.MaxPerIP,
+		rate:      cfg.NewPerSecond,
+		burst:     burst,
+		exempt:    exempt,
+		clients:   make(map[string]*connectionClient),
+		lastSweep: time.Now(),
+		rejected:  make(map[string]int64),
+	}, nil
+}
+
+// Acquire reserves a connection for the client IP. If the connection is
+// allowed it returns a function releasing the reservation, which must be
+// called once when the connection closes. Otherwise it returns the reason
+// the connection was rejected. A nil limiter allows every connection.
+func (l *ConnectionLimiter) Acquire(ip string) (release func(), reason string, allowed bool) {
+	if l == nil || l.isExempt(ip) {
+		return func() {}, "", true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.sweep(now)
+
+	client, exists := l.clients[ip]
+	if !exists {
+		client = &connectionClient{tokens: l.burst, lastRefill: now}
+		l.clients[ip] = client
+	}
+
+	if l.maxPerIP > 0 && client.active >= l.maxPerIP {
+		l.rejected[ConnectionRejectMaxPerIP]++
+		return nil, ConnectionRejectMaxPerIP, false
+	}
+
+	if l.rate > 0 {
+		client.tokens += now.Sub(client.lastRefill).Seconds() * l.rate
+		if client.tokens > l.burst {
+			client.tokens = l.burst
+		}
+		client.lastRefill = now
+
+		if client.tokens < 1 {
+			l.rejected[ConnectionRejectNewPerSecond]++
+			return nil, ConnectionRejectNewPerSecond, false
+		}
+		client.tokens--
+	}
+
+	client.active++
+	l.accepted++
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+			client.active--
+		})
+	}, "", true
+}
+
+// sweep removes clients without active connections whose token bucket has
+// refilled, at most once per sweep interval. The caller must hold l.mu.
+func (l *ConnectionLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < connectionSweepInterval {
+		return
+	}
+	l.lastSweep = now
+
+	for ip, client := range l.clients {
+		if client.active > 0 {
+			continue
+		}
+		if l.rate > 0 && client.tokens+now.Sub(client.lastRefill).Seconds()*l.rate < l.burst {
+			continue
+		}
+		delete(l.clients, ip)
+	}
+}
+
+// isExempt reports whether the client IP is exempt from the limits
+func (l *ConnectionLimiter) isExempt(ip string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, network := range l.exempt {
+		if network.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// Stats returns connection limiter statistics
+func (l *ConnectionLimiter) Stats() map[string]interface{} {
+	if l == nil {
+		return nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	active := 0
+	for _, client := range l.clients {
+		active += client.active
+	}
+	rejected := make(map[string]int64, len(l.rejected))
+	for reason, count := range l.rejected {
+		rejected[reason] = count
+	}
+
+	return map[string]interface{}{
+		"active_connections": active,
+		"accepted":           l.accepted,
+		"rejected":           rejected,
+		"clients":            len(l.clients),
+	}
+}
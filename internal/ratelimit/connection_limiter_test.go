@@ -0,0 +1,88 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/songzhibin97/stargate/internal/config"
+)
+
+func TestConnectionLimiter_MaxPerIP(t *testing.T) {
+	limiter, err := NewConnectionLimiter(config.ConnectionLimitConfig{Enabled: true, MaxPerIP: 2})
+	if err != nil {
+		t.Fatalf("Failed to create limiter: %v", err)
+	}
+
+	release1, _, ok1 := limiter.Acquire("10.0.0.1")
+	_, _, ok2 := limiter.Acquire("10.0.0.1")
+	_, reason, ok3 := limiter.Acquire("10.0.0.1")
+	if !ok1 || !ok2 || ok3 {
+		t.Fatalf("Expected two connections allowed and the third rejected, got %v %v %v", ok1, ok2, ok3)
+	}
+	if reason != ConnectionRejectMaxPerIP {
+		t.Errorf("Expected reason %s, got %s", ConnectionRejectMaxPerIP, reason)
+	}
+
+	if _, _, ok := limiter.Acquire("10.0.0.2"); !ok {
+		t.Error("Expected other client IP to be allowed")
+	}
+
+	// Releasing twice frees a single slot
+	release1()
+	release1()
+	if _, _, ok := limiter.Acquire("10.0.0.1"); !ok {
+		t.Error("Expected connection to be allowed after release")
+	}
+	if _, _, ok := limiter.Acquire("10.0.0.1"); ok {
+		t.Error("Expected double release to free only one slot")
+	}
+
+	rejected := limiter.Stats()["rejected"].(map[string]int64)
+	if rejected[ConnectionRejectMaxPerIP] != 2 {
+		t.Errorf("Expected 2 rejections, got %v", rejected)
+	}
+}
+
+func TestConnectionLimiter_NewPerSecond(t *testing.T) {
+	limiter, err := NewConnectionLimiter(config.ConnectionLimitConfig{
+		Enabled:      true,
+		NewPerSecond: 20,
+		Burst:        2,
+		ExemptCIDRs:  []string{"192.168.0.0/16"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create limiter: %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		release, _, ok := limiter.Acquire("10.0.0.1")
+		if !ok {
+			t.Fatalf("Expected connection %d within burst to be allowed", i)
+		}
+		release()
+	}
+	if _, reason, ok := limiter.Acquire("10.0.0.1"); ok || reason != ConnectionRejectNewPerSecond {
+		t.Errorf("Expected connection beyond burst to be rejected, got %v %s", ok, reason)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if _, _, ok := limiter.Acquire("10.0.0.1"); !ok {
+		t.Error("Expected connection to be allowed after refill")
+	}
+
+	for i := 0; i < 10; i++ {
+		if _, _, ok := limiter.Acquire("192.168.1.1"); !ok {
+			t.Fatal("Expected exempt client to be allowed")
+		}
+	}
+}
+
+func TestConnectionLimiter_Disabled(t *testing.T) {
+	limiter, err := NewConnectionLimiter(config.ConnectionLimitConfig{MaxPerIP: 1})
+	if err != nil || limiter != nil {
+		t.Fatalf("Expected nil limiter when disabled, got %v %v", limiter, err)
+	}
+	if _, _, ok := limiter.Acquire("10.0.0.1"); !ok {
+		t.Error("Expected nil limiter to allow connections")
+	}
+}
@@ -79,29 +79,34 @@ func (drl *DistributedRateLimiter) isAllowedFixedWindow(ctx context.Context, ide
 	windowStart := drl.getWindowStart(now)
 	windowKey := fmt.Sprintf("%s%s:fw:%d", drl.keyPrefix, identifier, windowStart.Unix())
 
-	// Check if key exists, if not, set it with TTL and increment
-	exists, err := drl.store.Exists(ctx, windowKey)
+	// Increment the window's counter, creating it with the window's TTL
+	count, err := incrByWithTTL(ctx, drl.store, windowKey, 1, drl.config.WindowSize)
 	if err != nil {
 		// On error, allow the request (fail open)
 		return true
 	}
 
-	if !exists {
-		// Set initial value with TTL
-		err = drl.store.Set(ctx, windowKey, []byte("0"), drl.config.WindowSize)
-		if err != nil {
-			return true
-		}
+	return count <= int64(drl.config.MaxRequests)
+}
+
+// incrByWithTTL increments a counter, setting ttl on it when created. Stores
+// that can't do both in one atomic operation get the key created first,
+// which can reset a counter another node increments concurrently.
+func incrByWithTTL(ctx context.Context, s store.AtomicStore, key string, value int64, ttl time.Duration) (int64, error) {
+	if incrementer, ok := s.(store.ExpiringIncrementer); ok {
+		return incrementer.IncrByWithTTL(ctx, key, value, ttl)
 	}
 
-	// Increment counter
-	count, err := drl.store.IncrBy(ctx, windowKey, 1)
+	exists, err := s.Exists(ctx, key)
 	if err != nil {
-		// On error, allow the request (fail open)
-		return true
+		return 0, err
 	}
-
-	return count <= int64(drl.config.MaxRequests)
+	if !exists {
+		if err := s.Set(ctx, key, []byte("0"), ttl); err != nil {
+			return 0, err
+		}
+	}
+	return s.IncrBy(ctx, key, value)
 }
 
 // isAllowedTokenBucket implements token bucket algorithm using distributed storage
@@ -318,6 +323,17 @@ func (drl *DistributedRateLimiter) GetStats() *RateLimiterStats {
 	}
 }
 
+// Flush deletes the counters of all identifiers and returns how many keys
+// were removed. Only keys under the limiter's key prefix are deleted, so other
+// data in a shared store is left alone.
+func (drl *DistributedRateLimiter) Flush(ctx context.Context) (int64, error) {
+	deleter, ok := drl.store.(store.PrefixDeleter)
+	if !ok {
+		return 0, fmt.Errorf("rate limit store does not support flushing")
+	}
+	return deleter.DeletePrefix(ctx, drl.keyPrefix)
+}
+
 // Stop stops the rate limiter and cleans up resources
 func (drl *DistributedRateLimiter) Stop() {
 	if drl.store != nil {
@@ -8,6 +8,9 @@ import (
 	"testing"
 	"time"
 
+	memorymetrics "github.com/songzhibin97/stargate/internal/metrics/driver/memory"
+	"github.com/songzhibin97/stargate/internal/store/driver/memory"
+	"github.com/songzhibin97/stargate/pkg/metrics"
 	"github.com/songzhibin97/stargate/pkg/store"
 )
 
@@ -247,6 +250,48 @@ func TestDistributedRateLimiter_FixedWindow(t *testing.T) {
 	}
 }
 
+func TestDistributedRateLimiter_Flush(t *testing.T) {
+	ctx := context.Background()
+	storage, err := memory.New(&store.Config{KeyPrefix: "gateway"})
+	if err != nil {
+		t.Fatalf("Failed to create memory store: %v", err)
+	}
+
+	limiter := NewDistributedRateLimiter(storage, &DistributedConfig{
+		Strategy:    StrategyFixedWindow,
+		WindowSize:  time.Minute,
+		MaxRequests: 1,
+		KeyPrefix:   "ratelimit:",
+	})
+	defer limiter.Stop()
+
+	// Unrelated data sharing the store must survive the flush
+	if err := storage.Set(ctx, "session:abc", []byte("data"), 0); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+
+	limiter.IsAllowed("client-a")
+	limiter.IsAllowed("client-b")
+	if limiter.IsAllowed("client-a") {
+		t.Fatal("Expected client-a to be rate limited before flush")
+	}
+
+	flushed, err := limiter.Flush(ctx)
+	if err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	if flushed != 2 {
+		t.Errorf("Expected 2 keys flushed, got %d", flushed)
+	}
+
+	if !limiter.IsAllowed("client-a") {
+		t.Error("Expected client-a to be allowed after flush")
+	}
+	if exists, _ := storage.Exists(ctx, "session:abc"); !exists {
+		t.Error("Expected unrelated key to be kept")
+	}
+}
+
 func TestDistributedRateLimiter_TokenBucket(t *testing.T) {
 	storage := NewMockAtomicStore()
 	defer storage.Close()
@@ -350,3 +395,63 @@ func TestDistributedRateLimiter_ConcurrentAccess(t *testing.T) {
 	t.Logf("Concurrent test: %d requests allowed out of %d total", 
 		totalAllowed, numLimiters*requestsPerLimiter)
 }
+
+func TestDistributedRateLimiter_StorageFallback(t *testing.T) {
+	primary := NewMockAtomicStore()
+	fallback, err := memory.New(&store.Config{KeyPrefix: "ratelimit"})
+	if err != nil {
+		t.Fatalf("Failed to create memory store: %v", err)
+	}
+	fs := newFallbackStore("redis", primary, fallback)
+
+	fallbacks, err := memorymetrics.NewProvider(memorymetrics.Options{}).NewCounterVec(metrics.MetricOptions{
+		Name:   "ratelimit_storage_fallbacks_total",
+		Labels: []string{"storage"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create counter: %v", err)
+	}
+	fs.setMetrics(fallbacks)
+
+	limiter := NewDistributedRateLimiter(fs, &DistributedConfig{
+		Strategy:    StrategyFixedWindow,
+		WindowSize:  time.Minute,
+		MaxRequests: 2,
+		KeyPrefix:   "ratelimit:",
+	})
+	defer limiter.Stop()
+
+	// The backend fails: limits keep applying in memory instead of failing open
+	primary.mu.Lock()
+	primary.closed = true
+	primary.mu.Unlock()
+
+	if !limiter.IsAllowed("client") || !limiter.IsAllowed("client") {
+		t.Fatal("Expected requests within the limit to be allowed from memory")
+	}
+	if limiter.IsAllowed("client") {
+		t.Error("Expected the limit to apply while the backend fails")
+	}
+	served := fallbacks.WithLabelValues("redis").Get()
+	if served == 0 {
+		t.Error("Expected operations served from memory to be counted")
+	}
+	if active := fs.Health(context.Background()).Details["fallback_active"]; active != true {
+		t.Errorf("Expected the fallback to be reported active, got %v", active)
+	}
+
+	// The backend recovers and is used again once the retry interval passed
+	primary.mu.Lock()
+	primary.closed = false
+	primary.mu.Unlock()
+	fs.mu.Lock()
+	fs.bypassUntil = time.Time{}
+	fs.mu.Unlock()
+
+	if !limiter.IsAllowed("client") {
+		t.Error("Expected the recovered backend's own count to apply")
+	}
+	if count := fallbacks.WithLabelValues("redis").Get(); count != served {
+		t.Errorf("Expected no more fallbacks after recovery, got %v more", count-served)
+	}
+}
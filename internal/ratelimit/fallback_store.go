@@ -0,0 +1,175 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/songzhibin97/stargate/pkg/metrics"
+	"github.com/songzhibin97/stargate/pkg/store"
+)
+
+// fallbackRetryInterval is how long a failing storage backend is bypassed
+// before it's tried again, so requests don't each wait for it to time out
+const fallbackRetryInterval = 5 * time.Second
+
+// fallbackStore serves rate limit counters from node-local memory while its
+// storage backend fails, so limits keep applying per node instead of every
+// request being allowed. Counts made in memory aren't copied to the backend,
+// which resumes its own counts once it recovers.
+type fallbackStore struct {
+	storage  string // Backend name, such as redis or dynamodb
+	primary  store.AtomicStore
+	fallback store.AtomicStore
+
+	mu          sync.Mutex
+	bypassUntil time.Time
+	fallbacks   metrics.CounterVec // Operations served from memory by storage
+}
+
+// newFallbackStore wraps the storage backend with a memory fallback
+func newFallbackStore(storage string, primary, fallback store.AtomicStore) *fallbackStore {
+	return &fallbackStore{
+		storage:  storage,
+		primary:  primary,
+		fallback: fallback,
+	}
+}
+
+// setMetrics sets the counter of operations served from memory
+func (fs *fallbackStore) setMetrics(fallbacks metrics.CounterVec) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	fs.fallbacks = fallbacks
+}
+
+// bypassed reports whether the backend is bypassed after failing
+func (fs *fallbackStore) bypassed() bool {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	return time.Now().Before(fs.bypassUntil)
+}
+
+// primaryFailed bypasses the backend for fallbackRetryInterval
+func (fs *fallbackStore) primaryFailed(err error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	if time.Now().After(fs.bypassUntil) {
+		log.Printf("Rate limit storage %s failed, counting in memory for %v: %v", fs.storage, fallbackRetryInterval, err)
+	}
+	fs.bypassUntil = time.Now().Add(fallbackRetryInterval)
+}
+
+// recordFallback counts an operation served from memory
+func (fs *fallbackStore) recordFallback() {
+	fs.mu.Lock()
+	fallbacks := fs.fallbacks
+	fs.mu.Unlock()
+	if fallbacks != nil {
+		fallbacks.WithLabelValues(fs.storage).Inc()
+	}
+}
+
+// withFallback runs op on the backend, or on the memory store if the backend
+// is bypassed or fails
+func withFallback[T any](fs *fallbackStore, op func(s store.AtomicStore) (T, error)) (T, error) {
+	if !fs.bypassed() {
+		result, err := op(fs.primary)
+		// A request abandoned by its caller says nothing about the backend
+		if err == nil || errors.Is(err, context.Canceled) {
+			return result, err
+		}
+		fs.primaryFailed(err)
+	}
+	fs.recordFallback()
+	return op(fs.fallback)
+}
+
+// IncrBy atomically increments the value of a key by the given amount
+func (fs *fallbackStore) IncrBy(ctx context.Context, key string, value int64) (int64, error) {
+	return withFallback(fs, func(s store.AtomicStore) (int64, error) {
+		return s.IncrBy(ctx, key, value)
+	})
+}
+
+// IncrByWithTTL atomically increments the value of a key by the given amount
+// and sets ttl on it if it has no expiration
+func (fs *fallbackStore) IncrByWithTTL(ctx context.Context, key string, value int64, ttl time.Duration) (int64, error) {
+	return withFallback(fs, func(s store.AtomicStore) (int64, error) {
+		return incrByWithTTL(ctx, s, key, value, ttl)
+	})
+}
+
+// Set stores a value by key with optional TTL
+func (fs *fallbackStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	_, err := withFallback(fs, func(s store.AtomicStore) (struct{}, error) {
+		return struct{}{}, s.Set(ctx, key, value, ttl)
+	})
+	return err
+}
+
+// Get retrieves a value by key
+func (fs *fallbackStore) Get(ctx context.Context, key string) ([]byte, error) {
+	return withFallback(fs, func(s store.AtomicStore) ([]byte, error) {
+		return s.Get(ctx, key)
+	})
+}
+
+// Delete removes a key from storage
+func (fs *fallbackStore) Delete(ctx context.Context, key string) error {
+	_, err := withFallback(fs, func(s store.AtomicStore) (struct{}, error) {
+		return struct{}{}, s.Delete(ctx, key)
+	})
+	return err
+}
+
+// Exists checks if a key exists in storage
+func (fs *fallbackStore) Exists(ctx context.Context, key string) (bool, error) {
+	return withFallback(fs, func(s store.AtomicStore) (bool, error) {
+		return s.Exists(ctx, key)
+	})
+}
+
+// TTL returns the remaining time to live for a key
+func (fs *fallbackStore) TTL(ctx context.Context, key string) (time.Duration, error) {
+	return withFallback(fs, func(s store.AtomicStore) (time.Duration, error) {
+		return s.TTL(ctx, key)
+	})
+}
+
+// DeletePrefix removes all keys starting with prefix from both the backend
+// and the memory store
+func (fs *fallbackStore) DeletePrefix(ctx context.Context, prefix string) (int64, error) {
+	var deleted int64
+	var errs []error
+	for _, s := range []store.AtomicStore{fs.primary, fs.fallback} {
+		deleter, ok := s.(store.PrefixDeleter)
+		if !ok {
+			continue
+		}
+		n, err := deleter.DeletePrefix(ctx, prefix)
+		deleted += n
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return deleted, errors.Join(errs...)
+}
+
+// Close closes the backend and the memory store
+func (fs *fallbackStore) Close() error {
+	return errors.Join(fs.primary.Close(), fs.fallback.Close())
+}
+
+// Health returns the health status of the backend, noting whether counts
+// are currently kept in memory
+func (fs *fallbackStore) Health(ctx context.Context) store.HealthStatus {
+	health := fs.primary.Health(ctx)
+	if health.Details == nil {
+		health.Details = make(map[string]interface{})
+	}
+	health.Details["fallback_active"] = fs.bypassed()
+	return health
+}
@@ -1,10 +1,13 @@
 package ratelimit
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"sync"
 	"time"
+
+	"github.com/songzhibin97/stargate/internal/clientip"
 )
 
 // FixedWindowRateLimiter implements a fixed window rate limiting algorithm
@@ -181,6 +184,16 @@ func (fw *FixedWindowRateLimiter) Stop() {
 	close(fw.stopCh)
 }
 
+// Flush resets the counters of all identifiers and returns how many were removed
+func (fw *FixedWindowRateLimiter) Flush(ctx context.Context) (int64, error) {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+
+	flushed := int64(len(fw.windows))
+	fw.windows = make(map[string]*windowData)
+	return flushed, nil
+}
+
 // GetStats returns statistics about the rate limiter
 func (fw *FixedWindowRateLimiter) GetStats() *RateLimiterStats {
 	fw.mu.RLock()
@@ -256,56 +269,17 @@ func ExtractIdentifier(r *http.Request, strategy string) string {
 		}
 		return "ip:" + ip
 	default:
-		// Default to IP-based identification
+		// Composite identifiers, or IP-based identification if invalid
+		if components, err := ParseIdentifier(strategy); err == nil {
+			return compositeIdentifier(r, components)
+		}
 		return extractClientIP(r)
 	}
 }
 
-// extractClientIP extracts the client IP address from the request
+// extractClientIP returns the client IP resolved for the request
 func extractClientIP(r *http.Request) string {
-	// Check X-Forwarded-For header (most common for proxied requests)
-	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
-		// X-Forwarded-For can contain multiple IPs, take the first one
-		if idx := len(xff); idx > 0 {
-			if commaIdx := 0; commaIdx < idx {
-				for i, c := range xff {
-					if c == ',' {
-						commaIdx = i
-						break
-					}
-				}
-				if commaIdx > 0 {
-					return xff[:commaIdx]
-				}
-			}
-			return xff
-		}
-	}
-
-	// Check X-Real-IP header
-	if xri := r.Header.Get("X-Real-IP"); xri != "" {
-		return xri
-	}
-
-	// Check X-Forwarded header
-	if xf := r.Header.Get("X-Forwarded"); xf != "" {
-		return xf
-	}
-
-	// Fall back to RemoteAddr
-	if r.RemoteAddr != "" {
-		// RemoteAddr is in format "IP:port", extract just the IP
-		if colonIdx := len(r.RemoteAddr) - 1; colonIdx >= 0 {
-			for i := colonIdx; i >= 0; i-- {
-				if r.RemoteAddr[i] == ':' {
-					return r.RemoteAddr[:i]
-				}
-			}
-		}
-		return r.RemoteAddr
-	}
-
-	return "unknown"
+	return clientip.FromRequest(r)
 }
 
 // IsRateLimited checks if the response indicates rate limiting
@@ -4,6 +4,8 @@ import (
 	"net/http"
 	"testing"
 	"time"
+
+	"github.com/songzhibin97/stargate/internal/clientip"
 )
 
 func TestFixedWindowRateLimiter_NewFixedWindowRateLimiter(t *testing.T) {
@@ -236,6 +238,11 @@ func TestFixedWindowRateLimiter_GetStats(t *testing.T) {
 }
 
 func TestExtractIdentifier(t *testing.T) {
+	resolver, err := clientip.NewResolver([]string{"192.168.1.0/24"})
+	if err != nil {
+		t.Fatalf("Failed to create resolver: %v", err)
+	}
+
 	tests := []struct {
 		name     string
 		strategy string
@@ -257,6 +264,13 @@ func TestExtractIdentifier(t *testing.T) {
 			remoteAddr: "192.168.1.1:12345",
 			expected: "203.0.113.1",
 		},
+		{
+			name:     "IP strategy with X-Forwarded-For from untrusted source",
+			strategy: "ip",
+			headers:  map[string]string{"X-Forwarded-For": "203.0.113.1"},
+			remoteAddr: "198.51.100.1:12345",
+			expected: "198.51.100.1",
+		},
 		{
 			name:     "User strategy with user ID",
 			strategy: "user",
@@ -295,6 +309,7 @@ func TestExtractIdentifier(t *testing.T) {
 			for key, value := range tt.headers {
 				req.Header.Set(key, value)
 			}
+			req = resolver.WithClientIP(req)
 
 			result := ExtractIdentifier(req, tt.strategy)
 			if result != tt.expected {
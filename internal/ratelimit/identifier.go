@@ -0,0 +1,109 @@
+package ratelimit
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Composite identifiers combine components joined with "+", such as
+// "api_key+route_id" to limit per API key per route, or
+// "ip+header:X-Tenant-ID". The components are:
+//
+//	ip             client IP
+//	user           X-User-ID header, or the client IP if missing
+//	api_key        X-API-Key header, or the client IP if missing
+//	route_id       ID of the route matching the request, empty if none
+//	header:<name>  value of the request header, empty if missing
+//
+// The key lists each component as name:value in the configured order,
+// joined by "|", so requests differing in any component are counted
+// separately. User and API key components without a value are listed as
+// ip:<client IP>. The strategies ip, user, api_key and combined keep their
+// single identifier keys.
+
+// Identifier components
+const (
+	ComponentIP      = "ip"
+	ComponentUser    = "user"
+	ComponentAPIKey  = "api_key"
+	ComponentRouteID = "route_id"
+
+	// componentHeaderPrefix prefixes header components, header:<name>
+	componentHeaderPrefix = "header:"
+
+	// identifierSeparator joins the components of a composite identifier
+	identifierSeparator = "+"
+)
+
+// routeIDKey is the request context key of the route ID, shared with the
+// pipeline and other middlewares
+const routeIDKey = "route_id"
+
+// keyValueEscaper escapes the characters separating key segments in values
+var keyValueEscaper = strings.NewReplacer("%", "%25", "|", "%7C")
+
+// ParseIdentifier parses a composite identifier strategy into its components
+func ParseIdentifier(strategy string) ([]string, error) {
+	if strategy == "" {
+		return nil, fmt.Errorf("identifier strategy is empty")
+	}
+
+	var components []string
+	seen := make(map[string]bool)
+	for _, component := range strings.Split(strategy, identifierSeparator) {
+		component = strings.TrimSpace(component)
+		switch {
+		case component == ComponentIP, component == ComponentUser,
+			component == ComponentAPIKey, component == ComponentRouteID:
+		case strings.HasPrefix(component, componentHeaderPrefix):
+			name := strings.TrimPrefix(component, componentHeaderPrefix)
+			if name == "" {
+				return nil, fmt.Errorf("header identifier component needs a header name")
+			}
+			component = componentHeaderPrefix + http.CanonicalHeaderKey(name)
+		default:
+			return nil, fmt.Errorf("unknown identifier component %q", component)
+		}
+
+		if seen[component] {
+			return nil, fmt.Errorf("identifier component %q is repeated", component)
+		}
+		seen[component] = true
+		components = append(components, component)
+	}
+	return components, nil
+}
+
+// compositeIdentifier builds the key of a request from identifier components
+func compositeIdentifier(r *http.Request, components []string) string {
+	segments := make([]string, len(components))
+	for i, component := range components {
+		segments[i] = componentSegment(r, component)
+	}
+	return strings.Join(segments, "|")
+}
+
+// componentSegment returns the name:value key segment of an identifier
+// component of a request. User and API key components without a value fall
+// back to an ip segment.
+func componentSegment(r *http.Request, component string) string {
+	var value string
+	switch component {
+	case ComponentIP:
+		value = extractClientIP(r)
+	case ComponentUser:
+		value = r.Header.Get("X-User-ID")
+	case ComponentAPIKey:
+		value = r.Header.Get("X-API-Key")
+	case ComponentRouteID:
+		value, _ = r.Context().Value(routeIDKey).(string)
+	default:
+		value = r.Header.Get(strings.TrimPrefix(component, componentHeaderPrefix))
+	}
+
+	if value == "" && (component == ComponentUser || component == ComponentAPIKey) {
+		component, value = ComponentIP, extractClientIP(r)
+	}
+	return component + ":" + keyValueEscaper.Replace(value)
+}
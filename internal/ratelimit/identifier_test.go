@@ -0,0 +1,116 @@
+package ratelimit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/songzhibin97/stargate/internal/clientip"
+)
+
+func TestParseIdentifier(t *testing.T) {
+	components, err := ParseIdentifier("api_key+route_id+header:x-tenant-id")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := []string{"api_key", "route_id", "header:X-Tenant-Id"}
+	if strings.Join(components, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected components %v, got %v", expected, components)
+	}
+
+	for _, invalid := range []string{"", "api_key+", "api_key+session", "header:", "ip+ip"} {
+		if _, err := ParseIdentifier(invalid); err == nil {
+			t.Errorf("Expected error for identifier %q", invalid)
+		}
+	}
+}
+
+func TestExtractIdentifier_Composite(t *testing.T) {
+	resolver, err := clientip.NewResolver(nil)
+	if err != nil {
+		t.Fatalf("Failed to create resolver: %v", err)
+	}
+
+	newRequest := func(headers map[string]string) *http.Request {
+		req := httptest.NewRequest("GET", "/orders", nil)
+		req.RemoteAddr = "192.168.1.1:12345"
+		for key, value := range headers {
+			req.Header.Set(key, value)
+		}
+		req = req.WithContext(context.WithValue(req.Context(), routeIDKey, "orders"))
+		return resolver.WithClientIP(req)
+	}
+
+	tests := []struct {
+		name     string
+		strategy string
+		headers  map[string]string
+		expected string
+	}{
+		{"API key per route", "api_key+route_id", map[string]string{"X-API-Key": "abc123"}, "api_key:abc123|route_id:orders"},
+		{"missing API key falls back to IP", "api_key+route_id", nil, "ip:192.168.1.1|route_id:orders"},
+		{"header values are escaped", "ip+header:X-Tenant-ID", map[string]string{"X-Tenant-ID": "a|b"}, "ip:192.168.1.1|header:X-Tenant-Id:a%7Cb"},
+		{"missing header", "user+header:X-Tenant-ID", map[string]string{"X-User-ID": "user123"}, "user:user123|header:X-Tenant-Id:"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := ExtractIdentifier(newRequest(tt.headers), tt.strategy); result != tt.expected {
+				t.Errorf("Expected identifier %s, got %s", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestMiddleware_CompositeIdentifierPerRoute(t *testing.T) {
+	middleware, err := NewMiddleware(&Config{
+		Strategy:           StrategyFixedWindow,
+		IdentifierStrategy: "api_key+route_id",
+		WindowSize:         time.Minute,
+		MaxRequests:        1,
+		CleanupInterval:    5 * time.Minute,
+		Enabled:            true,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create middleware: %v", err)
+	}
+	defer middleware.Stop()
+
+	// Routes are named after the first path segment
+	middleware.SetRouteMatcher(func(r *http.Request) string {
+		return strings.Split(strings.TrimPrefix(r.URL.Path, "/"), "/")[0]
+	})
+	handler := middleware.Handler()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	send := func(path, apiKey string) int {
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("X-API-Key", apiKey)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	if code := send("/orders/1", "key-1"); code != http.StatusOK {
+		t.Fatalf("Expected first request to be allowed, got %d", code)
+	}
+	if code := send("/orders/2", "key-1"); code != http.StatusTooManyRequests {
+		t.Errorf("Expected second request of the key on the route to be limited, got %d", code)
+	}
+
+	// The same key on another route and another key on the route have their own buckets
+	if code := send("/payments/1", "key-1"); code != http.StatusOK {
+		t.Errorf("Expected the key on another route to be allowed, got %d", code)
+	}
+	if code := send("/orders/1", "key-2"); code != http.StatusOK {
+		t.Errorf("Expected another key on the route to be allowed, got %d", code)
+	}
+}
+
+func TestMaskIdentifier_Composite(t *testing.T) {
+	if masked := MaskIdentifier("api_key:abc123|route_id:orders"); masked != "api_key:abc1***|route_id:orders" {
+		t.Errorf("Expected the API key segment to be masked, got %s", masked)
+	}
+}
@@ -1,11 +1,15 @@
 package ratelimit
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"time"
 
+	"github.com/songzhibin97/stargate/pkg/metrics"
 	"github.com/songzhibin97/stargate/pkg/store"
+	"github.com/songzhibin97/stargate/internal/store/driver/dynamodb"
 	"github.com/songzhibin97/stargate/internal/store/driver/memory"
 	"github.com/songzhibin97/stargate/internal/store/driver/redis"
 )
@@ -25,11 +29,19 @@ type RateLimiter interface {
 	Stop()
 }
 
+// Flusher is implemented by rate limiters whose counters can be reset
+type Flusher interface {
+	// Flush resets the counters of all identifiers and returns the number of
+	// entries removed
+	Flush(ctx context.Context) (int64, error)
+}
+
 // RateLimitResult represents the result of a rate limit check
 type RateLimitResult struct {
 	Allowed   bool          // whether the request is allowed
 	Quota     *QuotaInfo    // quota information
 	RetryAfter time.Duration // how long to wait before retrying (if not allowed)
+	Identifier string        // identifier the request was counted under
 }
 
 // RateLimitStrategy defines different rate limiting strategies
@@ -101,7 +113,8 @@ type Config struct {
 	// CustomHeaders allows setting custom rate limit headers
 	CustomHeaders map[string]string `yaml:"custom_headers" json:"custom_headers"`
 
-	// Storage defines the storage backend type ("memory" or "redis")
+	// Storage defines the storage backend type ("memory", "redis" or "dynamodb").
+	// Redis and DynamoDB fall back to memory while they fail.
 	Storage string `yaml:"storage" json:"storage"`
 
 	// Redis configuration fields (for backward compatibility)
@@ -111,6 +124,9 @@ type Config struct {
 
 	// RedisConfig contains Redis-specific configuration (deprecated, use individual fields)
 	RedisConfig *RedisConfig `yaml:"redis_config" json:"redis_config"`
+
+	// DynamoDB contains the DynamoDB configuration, used with the dynamodb storage
+	DynamoDB DynamoDBConfig `yaml:"dynamodb" json:"dynamodb"`
 }
 
 // RedisConfig represents Redis configuration for rate limiting
@@ -120,6 +136,17 @@ type RedisConfig struct {
 	DB       int    `yaml:"db" json:"db"`
 }
 
+// DynamoDBConfig represents DynamoDB configuration for rate limiting.
+// Credentials and region default to the standard AWS environment variables.
+type DynamoDBConfig struct {
+	Table           string `yaml:"table" json:"table"`
+	Region          string `yaml:"region" json:"region"`
+	Endpoint        string `yaml:"endpoint" json:"endpoint"`
+	AccessKeyID     string `yaml:"access_key_id" json:"access_key_id"`
+	SecretAccessKey string `yaml:"secret_access_key" json:"-"`
+	SessionToken    string `yaml:"session_token" json:"-"`
+}
+
 // DefaultConfig returns a default rate limiter configuration
 func DefaultConfig() *Config {
 	return &Config{
@@ -169,7 +196,7 @@ func (m *Manager) CreateLimiter(name string, config *Config) (RateLimiter, error
 	var err error
 
 	// Check if distributed storage is requested
-	if config.Storage == "redis" {
+	if config.Storage == "redis" || config.Storage == "dynamodb" {
 		return m.createDistributedLimiter(name, config)
 	}
 
@@ -226,6 +253,19 @@ func (m *Manager) createDistributedLimiter(name string, config *Config) (RateLim
 		if err != nil {
 			return nil, fmt.Errorf("failed to create Redis store: %w", err)
 		}
+	case "dynamodb":
+		storeConfig.Address = config.DynamoDB.Endpoint
+		storeConfig.Username = config.DynamoDB.AccessKeyID
+		storeConfig.Password = config.DynamoDB.SecretAccessKey
+		storeConfig.Options = map[string]interface{}{
+			"table":         config.DynamoDB.Table,
+			"region":        config.DynamoDB.Region,
+			"session_token": config.DynamoDB.SessionToken,
+		}
+		atomicStore, err = dynamodb.New(storeConfig)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create DynamoDB store: %w", err)
+		}
 	case "memory":
 		atomicStore, err = memory.New(storeConfig)
 		if err != nil {
@@ -235,6 +275,16 @@ func (m *Manager) createDistributedLimiter(name string, config *Config) (RateLim
 		return nil, fmt.Errorf("unsupported storage type: %s", config.Storage)
 	}
 
+	// Keep limiting in memory while a remote backend fails
+	if config.Storage != "memory" {
+		fallback, err := memory.New(&store.Config{KeyPrefix: storeConfig.KeyPrefix})
+		if err != nil {
+			atomicStore.Close()
+			return nil, fmt.Errorf("failed to create fallback memory store: %w", err)
+		}
+		atomicStore = newFallbackStore(config.Storage, atomicStore, fallback)
+	}
+
 	// Create distributed config
 	distributedConfig := &DistributedConfig{
 		Strategy:           config.Strategy,
@@ -298,6 +348,7 @@ func (m *Manager) CheckRequest(limiterName string, r *http.Request) *RateLimitRe
 		Allowed:    allowed,
 		Quota:      quota,
 		RetryAfter: retryAfter,
+		Identifier: identifier,
 	}
 }
 
@@ -309,6 +360,37 @@ func (m *Manager) Stop() {
 	m.limiters = make(map[string]RateLimiter)
 }
 
+// Flush resets the counters of all rate limiters and returns the number of
+// entries removed
+func (m *Manager) Flush(ctx context.Context) (int64, error) {
+	var flushed int64
+	var errs []error
+	for name, limiter := range m.limiters {
+		flusher, ok := limiter.(Flusher)
+		if !ok {
+			continue
+		}
+		n, err := flusher.Flush(ctx)
+		flushed += n
+		if err != nil {
+			errs = append(errs, fmt.Errorf("limiter %s: %w", name, err))
+		}
+	}
+	return flushed, errors.Join(errs...)
+}
+
+// SetStorageMetrics sets the counter of storage operations served from
+// memory because the storage backend failed, labeled by storage
+func (m *Manager) SetStorageMetrics(fallbacks metrics.CounterVec) {
+	for _, limiter := range m.limiters {
+		if distributed, ok := limiter.(*DistributedRateLimiter); ok {
+			if fs, ok := distributed.store.(*fallbackStore); ok {
+				fs.setMetrics(fallbacks)
+			}
+		}
+	}
+}
+
 // GetAllStats returns statistics for all rate limiters
 func (m *Manager) GetAllStats() map[string]*RateLimiterStats {
 	stats := make(map[string]*RateLimiterStats)
@@ -1,11 +1,19 @@
 package ratelimit
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
+
+	"github.com/songzhibin97/stargate/internal/decision"
+	"github.com/songzhibin97/stargate/internal/store/driver/memory"
+	"github.com/songzhibin97/stargate/pkg/metrics"
+	"github.com/songzhibin97/stargate/pkg/store"
 )
 
 // Middleware represents the rate limiting middleware
@@ -13,6 +21,15 @@ type Middleware struct {
 	manager    *Manager
 	config     *Config
 	limiterName string
+
+	// routeMatcher returns the ID of the route matching a request, for
+	// identifiers with a route_id component
+	routeMatcher func(r *http.Request) string
+
+	// overrides holds the overrides of clients, in the backend of the
+	// limiter or in overrideStore for in-memory limiters
+	overrides     *OverrideStore
+	overrideStore store.AtomicStore
 }
 
 // NewMiddleware creates a new rate limiting middleware
@@ -30,11 +47,43 @@ func NewMiddleware(config *Config) (*Middleware, error) {
 		return nil, fmt.Errorf("failed to create rate limiter: %w", err)
 	}
 
-	return &Middleware{
+	m := &Middleware{
 		manager:     manager,
 		config:      config,
 		limiterName: limiterName,
-	}, nil
+	}
+	if err := m.setupOverrides(); err != nil {
+		manager.Stop()
+		return nil, err
+	}
+	return m, nil
+}
+
+// setupOverrides keeps the overrides in the backend of a distributed limiter,
+// shared with the other nodes, or else in memory. Overrides in memory are
+// kept across configuration updates.
+func (m *Middleware) setupOverrides() error {
+	if limiter, ok := m.manager.GetLimiter(m.limiterName); ok {
+		if distributed, ok := limiter.(*DistributedRateLimiter); ok {
+			if m.overrideStore != nil {
+				m.overrideStore.Close()
+				m.overrideStore = nil
+			}
+			m.overrides = NewOverrideStore(distributed.store, distributed.keyPrefix)
+			return nil
+		}
+	}
+
+	if m.overrideStore != nil {
+		return nil
+	}
+	memoryStore, err := memory.New(&store.Config{KeyPrefix: "ratelimit"})
+	if err != nil {
+		return fmt.Errorf("failed to create override store: %w", err)
+	}
+	m.overrideStore = memoryStore
+	m.overrides = NewOverrideStore(memoryStore, "ratelimit:")
+	return nil
 }
 
 // Handler returns an HTTP middleware handler function
@@ -47,8 +96,16 @@ func (m *Middleware) Handler() func(http.Handler) http.Handler {
 				return
 			}
 
+			// Overrides of the client apply instead of the configured limits
+			if override := m.overrides.Match(r); override != nil {
+				if m.handleOverride(w, r, override) {
+					next.ServeHTTP(w, r)
+				}
+				return
+			}
+
 			// Check rate limit
-			result := m.manager.CheckRequest(m.limiterName, r)
+			result := m.manager.CheckRequest(m.limiterName, m.withRouteID(r))
 			
 			// Set rate limit headers
 			if result.Quota != nil {
@@ -67,6 +124,32 @@ func (m *Middleware) Handler() func(http.Handler) http.Handler {
 	}
 }
 
+// SetRouteMatcher sets the function returning the ID of the route matching a
+// request, used by identifiers with a route_id component. Without a matcher
+// the route ID is taken from the request context.
+func (m *Middleware) SetRouteMatcher(matcher func(r *http.Request) string) {
+	m.routeMatcher = matcher
+}
+
+// SetStorageMetrics sets the counter of storage operations served from
+// memory because the rate limit storage backend failed, labeled by storage
+func (m *Middleware) SetStorageMetrics(fallbacks metrics.CounterVec) {
+	m.manager.SetStorageMetrics(fallbacks)
+}
+
+// withRouteID returns the request to identify, carrying the matched route ID
+// if the identifier has a route_id component
+func (m *Middleware) withRouteID(r *http.Request) *http.Request {
+	if m.routeMatcher == nil || !strings.Contains(string(m.config.IdentifierStrategy), ComponentRouteID) {
+		return r
+	}
+	routeID := m.routeMatcher(r)
+	if routeID == "" {
+		return r
+	}
+	return r.WithContext(context.WithValue(r.Context(), routeIDKey, routeID))
+}
+
 // handleRateLimited handles rate limited requests
 func (m *Middleware) handleRateLimited(w http.ResponseWriter, r *http.Request, result *RateLimitResult) {
 	// Set Retry-After header
@@ -83,6 +166,8 @@ func (m *Middleware) handleRateLimited(w http.ResponseWriter, r *http.Request, r
 		w.Header().Set(key, value)
 	}
 
+	m.recordDecision(r, result)
+
 	// Set content type
 	w.Header().Set("Content-Type", "application/json")
 	
@@ -114,6 +199,105 @@ func (m *Middleware) handleRateLimited(w http.ResponseWriter, r *http.Request, r
 	}
 }
 
+// handleOverride applies the override of a client and reports whether the
+// request is allowed. Blocked clients get the status of the override, limited
+// clients 429 once over its quota, with the reason of the override.
+func (m *Middleware) handleOverride(w http.ResponseWriter, r *http.Request, override *Override) bool {
+	status := override.Status
+	errorResponse := RateLimitErrorResponse{
+		Message: "Requests from this client are blocked.",
+		Reason:  override.Reason,
+	}
+	reason := "override_block"
+
+	if override.Action == OverrideLimit {
+		allowed, quota := m.overrides.Allow(r.Context(), override)
+		SetRateLimitHeaders(w, quota)
+		if allowed {
+			return true
+		}
+
+		retryAfter := int(time.Until(quota.ResetTime).Seconds())
+		if retryAfter < 1 {
+			retryAfter = 1
+		}
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+
+		status = http.StatusTooManyRequests
+		errorResponse.Message = "Rate limit exceeded. Please try again later."
+		errorResponse.Limit = quota.Limit
+		errorResponse.ResetTime = quota.ResetTime.Unix()
+		errorResponse.RetryAfter = retryAfter
+		reason = "override_limit"
+	}
+
+	fields := map[string]string{
+		"limiter":    m.limiterName,
+		"identifier": MaskIdentifier(override.Identifier),
+	}
+	if override.Reason != "" {
+		fields["override_reason"] = override.Reason
+	}
+	decision.Record(r.Context(), decision.Decision{
+		Middleware: "ratelimit",
+		Outcome:    decision.OutcomeRateLimited,
+		Reason:     reason,
+		Fields:     fields,
+	})
+
+	errorResponse.Error = http.StatusText(status)
+	errorResponse.Code = status
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(errorResponse); err != nil {
+		log.Printf("Failed to encode rate limit override response: %v", err)
+	}
+	return false
+}
+
+// Overrides returns the store of the rate limit overrides of clients
+func (m *Middleware) Overrides() *OverrideStore {
+	return m.overrides
+}
+
+// recordDecision records the rejection for the access log
+func (m *Middleware) recordDecision(r *http.Request, result *RateLimitResult) {
+	fields := map[string]string{
+		"limiter":    m.limiterName,
+		"strategy":   string(m.config.Strategy),
+		"identifier": MaskIdentifier(result.Identifier),
+	}
+	if result.Quota != nil {
+		fields["limit"] = strconv.Itoa(result.Quota.Limit)
+		fields["remaining"] = strconv.Itoa(result.Quota.Remaining)
+	}
+
+	decision.Record(r.Context(), decision.Decision{
+		Middleware: "ratelimit",
+		Outcome:    decision.OutcomeRateLimited,
+		Reason:     "quota_exceeded",
+		Fields:     fields,
+	})
+}
+
+// MaskIdentifier hides all but the first characters of API keys, which are
+// credentials, in identifiers and the segments of composite identifiers
+func MaskIdentifier(identifier string) string {
+	const prefix = "api_key:"
+	segments := strings.Split(identifier, "|")
+	for i, segment := range segments {
+		if !strings.HasPrefix(segment, prefix) {
+			continue
+		}
+		key := strings.TrimPrefix(segment, prefix)
+		if len(key) > 4 {
+			key = key[:4]
+		}
+		segments[i] = prefix + key + "***"
+	}
+	return strings.Join(segments, "|")
+}
+
 // RateLimitErrorResponse represents the error response for rate limited requests
 type RateLimitErrorResponse struct {
 	Error      string `json:"error"`
@@ -123,6 +307,7 @@ type RateLimitErrorResponse struct {
 	Remaining  int    `json:"remaining,omitempty"`
 	ResetTime  int64  `json:"reset_time,omitempty"`
 	RetryAfter int    `json:"retry_after,omitempty"`
+	Reason     string `json:"reason,omitempty"` // Reason of the override blocking or limiting the client
 }
 
 // UpdateConfig updates the middleware configuration
@@ -144,7 +329,7 @@ func (m *Middleware) UpdateConfig(config *Config) error {
 		return fmt.Errorf("failed to recreate rate limiter: %w", err)
 	}
 
-	return nil
+	return m.setupOverrides()
 }
 
 // GetStats returns statistics about the rate limiter
@@ -152,6 +337,11 @@ func (m *Middleware) GetStats() map[string]*RateLimiterStats {
 	return m.manager.GetAllStats()
 }
 
+// Flush resets all rate limit counters and returns the number of entries removed
+func (m *Middleware) Flush(ctx context.Context) (int64, error) {
+	return m.manager.Flush(ctx)
+}
+
 // Health returns the health status of the middleware
 func (m *Middleware) Health() map[string]interface{} {
 	health := m.manager.Health()
@@ -164,6 +354,9 @@ func (m *Middleware) Stop() {
 	if m.manager != nil {
 		m.manager.Stop()
 	}
+	if m.overrideStore != nil {
+		m.overrideStore.Close()
+	}
 }
 
 // ConditionalMiddleware creates a middleware that applies rate limiting conditionally
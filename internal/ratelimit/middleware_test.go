@@ -1,11 +1,14 @@
 package ratelimit
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 	"time"
+
+	"github.com/songzhibin97/stargate/internal/decision"
 )
 
 func TestMiddleware_NewMiddleware(t *testing.T) {
@@ -203,6 +206,89 @@ func TestMiddleware_Handler_RateLimited(t *testing.T) {
 	}
 }
 
+func TestMiddleware_Handler_RecordsDecision(t *testing.T) {
+	middleware, err := NewMiddleware(&Config{
+		Strategy:           StrategyFixedWindow,
+		IdentifierStrategy: IdentifierAPIKey,
+		WindowSize:         time.Minute,
+		MaxRequests:        1,
+		CleanupInterval:    5 * time.Minute,
+		Enabled:            true,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create middleware: %v", err)
+	}
+	defer middleware.Stop()
+
+	handler := middleware.Handler()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	var recorder *decision.Recorder
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest("GET", "/test", nil)
+		req.Header.Set("X-API-Key", "secret-key-123")
+		ctx, r := decision.WithRecorder(req.Context())
+		recorder = r
+		handler.ServeHTTP(httptest.NewRecorder(), req.WithContext(ctx))
+	}
+
+	d, ok := recorder.Last()
+	if !ok {
+		t.Fatal("Expected the rejection to be recorded")
+	}
+	if d.Outcome != decision.OutcomeRateLimited || d.Fields["limiter"] != "default" || d.Fields["remaining"] != "0" {
+		t.Errorf("Unexpected decision: %+v", d)
+	}
+	if d.Fields["identifier"] != "api_key:secr***" {
+		t.Errorf("Expected the API key to be masked, got %s", d.Fields["identifier"])
+	}
+}
+
+func TestMiddleware_Flush(t *testing.T) {
+	config := &Config{
+		Strategy:           StrategyFixedWindow,
+		IdentifierStrategy: IdentifierIP,
+		WindowSize:         time.Minute,
+		MaxRequests:        1,
+		CleanupInterval:    5 * time.Minute,
+		Enabled:            true,
+	}
+
+	middleware, err := NewMiddleware(config)
+	if err != nil {
+		t.Fatalf("Failed to create middleware: %v", err)
+	}
+	defer middleware.Stop()
+
+	handler := middleware.Handler()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	request := func() int {
+		req := httptest.NewRequest("GET", "/test", nil)
+		req.RemoteAddr = "192.168.1.1:12345"
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	request()
+	if code := request(); code != http.StatusTooManyRequests {
+		t.Fatalf("Expected status 429 before flush, got %d", code)
+	}
+
+	flushed, err := middleware.Flush(context.Background())
+	if err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	if flushed != 1 {
+		t.Errorf("Expected 1 entry flushed, got %d", flushed)
+	}
+
+	if code := request(); code != http.StatusOK {
+		t.Errorf("Expected status 200 after flush, got %d", code)
+	}
+}
+
 func TestMiddleware_Handler_CustomHeaders(t *testing.T) {
 	config := &Config{
 		Strategy:           StrategyFixedWindow,
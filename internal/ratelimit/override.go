@@ -0,0 +1,311 @@
+package ratelimit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/songzhibin97/stargate/pkg/store"
+)
+
+// Override actions
+const (
+	OverrideBlock = "block" // Reject every request of the identifier
+	OverrideLimit = "limit" // Limit the identifier to its own quota
+)
+
+const (
+	// overridesKey is the backend key of the overrides document, outside the
+	// counter key prefix so flushing counters keeps the overrides
+	overridesKey = "overrides"
+
+	// overrideRefreshInterval is how often the overrides are reloaded from
+	// the backend, picking up overrides set through other nodes
+	overrideRefreshInterval = time.Second
+)
+
+// Override is a temporary rate limit override of one client, consulted ahead
+// of the configured limits. Identifiers are ip:<client IP>, api_key:<key> or
+// user:<X-User-ID>.
+type Override struct {
+	Identifier  string        `json:"identifier"`
+	Action      string        `json:"action"`
+	Status      int           `json:"status,omitempty"`       // Status of blocked requests, 403 or 429
+	MaxRequests int           `json:"max_requests,omitempty"` // Requests allowed per window by limit overrides
+	Window      time.Duration `json:"window,omitempty"`
+	Reason      string        `json:"reason,omitempty"`
+	CreatedBy   string        `json:"created_by,omitempty"`
+	CreatedAt   time.Time     `json:"created_at"`
+	ExpiresAt   time.Time     `json:"expires_at"`
+}
+
+// Validate checks the override and defaults the status of blocks to 403
+func (o *Override) Validate() error {
+	kind, value, _ := strings.Cut(o.Identifier, ":")
+	switch kind {
+	case ComponentIP, ComponentAPIKey, ComponentUser:
+	default:
+		return fmt.Errorf("identifier must be ip:<address>, api_key:<key> or user:<id>")
+	}
+	if value == "" {
+		return fmt.Errorf("identifier %s has no value", kind)
+	}
+
+	switch o.Action {
+	case OverrideBlock:
+		if o.Status == 0 {
+			o.Status = http.StatusForbidden
+		}
+		if o.Status != http.StatusForbidden && o.Status != http.StatusTooManyRequests {
+			return fmt.Errorf("status of blocked requests must be 403 or 429")
+		}
+	case OverrideLimit:
+		if o.MaxRequests <= 0 {
+			return fmt.Errorf("max_requests must be positive")
+		}
+		if o.Window <= 0 {
+			return fmt.Errorf("window must be positive")
+		}
+	default:
+		return fmt.Errorf("action must be %s or %s", OverrideBlock, OverrideLimit)
+	}
+
+	if o.ExpiresAt.IsZero() {
+		return fmt.Errorf("override needs an expiration")
+	}
+	return nil
+}
+
+// expired reports whether the override has expired at now
+func (o *Override) expired(now time.Time) bool {
+	return !now.Before(o.ExpiresAt)
+}
+
+// OverrideStore keeps the rate limit overrides in the rate limit backend,
+// shared by the nodes using it. Each node caches the overrides and reloads
+// them every second, so overrides set through another node apply within a
+// second.
+type OverrideStore struct {
+	store     store.AtomicStore
+	keyPrefix string
+
+	// writeMu serializes the updates of the overrides document on this node
+	writeMu sync.Mutex
+
+	mu         sync.RWMutex
+	overrides  map[string]*Override
+	loadedAt   time.Time
+	refreshing atomic.Bool
+}
+
+// NewOverrideStore creates an override store in the rate limit backend.
+// Counters of limit overrides are kept under keyPrefix.
+func NewOverrideStore(atomicStore store.AtomicStore, keyPrefix string) *OverrideStore {
+	return &OverrideStore{
+		store:     atomicStore,
+		keyPrefix: keyPrefix,
+	}
+}
+
+// Set adds or replaces the override of its identifier
+func (s *OverrideStore) Set(ctx context.Context, override *Override) error {
+	if err := override.Validate(); err != nil {
+		return err
+	}
+
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	overrides, err := s.load(ctx)
+	if err != nil {
+		return err
+	}
+	overrides[override.Identifier] = override
+	return s.save(ctx, overrides)
+}
+
+// Delete removes the override of an identifier and reports whether it existed
+func (s *OverrideStore) Delete(ctx context.Context, identifier string) (bool, error) {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	overrides, err := s.load(ctx)
+	if err != nil {
+		return false, err
+	}
+	if _, exists := overrides[identifier]; !exists {
+		return false, nil
+	}
+	delete(overrides, identifier)
+	return true, s.save(ctx, overrides)
+}
+
+// List returns the overrides in effect, ordered by identifier
+func (s *OverrideStore) List(ctx context.Context) ([]*Override, error) {
+	overrides, err := s.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	s.cache(overrides)
+
+	list := make([]*Override, 0, len(overrides))
+	for _, override := range overrides {
+		list = append(list, override)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Identifier < list[j].Identifier })
+	return list, nil
+}
+
+// Match returns the override applying to a request, or nil. Blocks take
+// precedence over limits, then the IP, API key and user overrides apply in
+// that order.
+func (s *OverrideStore) Match(r *http.Request) *Override {
+	overrides := s.cached(r.Context())
+	if len(overrides) == 0 {
+		return nil
+	}
+
+	identifiers := []string{ComponentIP + ":" + extractClientIP(r)}
+	if apiKey := r.Header.Get("X-API-Key"); apiKey != "" {
+		identifiers = append(identifiers, ComponentAPIKey+":"+apiKey)
+	}
+	if userID := r.Header.Get("X-User-ID"); userID != "" {
+		identifiers = append(identifiers, ComponentUser+":"+userID)
+	}
+
+	now := time.Now()
+	var match *Override
+	for _, identifier := range identifiers {
+		override, exists := overrides[identifier]
+		if !exists || override.expired(now) {
+			continue
+		}
+		if override.Action == OverrideBlock {
+			return override
+		}
+		if match == nil {
+			match = override
+		}
+	}
+	return match
+}
+
+// Allow counts a request against a limit override and reports whether it is
+// allowed, with the quota of the current window. Requests are allowed if the
+// backend fails.
+func (s *OverrideStore) Allow(ctx context.Context, override *Override) (bool, *QuotaInfo) {
+	windowNanos := override.Window.Nanoseconds()
+	windowStart := time.Unix(0, time.Now().UnixNano()/windowNanos*windowNanos)
+	quota := &QuotaInfo{
+		Limit:       override.MaxRequests,
+		Remaining:   override.MaxRequests,
+		ResetTime:   windowStart.Add(override.Window),
+		WindowStart: windowStart,
+	}
+
+	key := fmt.Sprintf("%soverride:%s:%d", s.keyPrefix, keyValueEscaper.Replace(override.Identifier), windowStart.Unix())
+	count, err := incrByWithTTL(ctx, s.store, key, 1, override.Window)
+	if err != nil {
+		// On error, allow the request (fail open)
+		return true, quota
+	}
+
+	quota.Remaining = override.MaxRequests - int(count)
+	if quota.Remaining < 0 {
+		quota.Remaining = 0
+	}
+	return count <= int64(override.MaxRequests), quota
+}
+
+// cached returns the cached overrides, reloading them from the backend if
+// they are older than the refresh interval. Concurrent requests keep using
+// the cached overrides while one reloads them.
+func (s *OverrideStore) cached(ctx context.Context) map[string]*Override {
+	s.mu.RLock()
+	overrides, loadedAt := s.overrides, s.loadedAt
+	s.mu.RUnlock()
+
+	if time.Since(loadedAt) < overrideRefreshInterval || !s.refreshing.CompareAndSwap(false, true) {
+		return overrides
+	}
+	defer s.refreshing.Store(false)
+
+	loaded, err := s.load(ctx)
+	if err != nil {
+		log.Printf("Failed to load rate limit overrides: %v", err)
+		// Retry after the refresh interval rather than on every request
+		s.mu.Lock()
+		s.loadedAt = time.Now()
+		s.mu.Unlock()
+		return overrides
+	}
+	s.cache(loaded)
+	return loaded
+}
+
+// cache replaces the cached overrides
+func (s *OverrideStore) cache(overrides map[string]*Override) {
+	s.mu.Lock()
+	s.overrides = overrides
+	s.loadedAt = time.Now()
+	s.mu.Unlock()
+}
+
+// load reads the overrides in effect from the backend
+func (s *OverrideStore) load(ctx context.Context) (map[string]*Override, error) {
+	data, err := s.store.Get(ctx, overridesKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rate limit overrides: %w", err)
+	}
+
+	overrides := make(map[string]*Override)
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &overrides); err != nil {
+			return nil, fmt.Errorf("failed to decode rate limit overrides: %w", err)
+		}
+	}
+
+	now := time.Now()
+	for identifier, override := range overrides {
+		if override.expired(now) {
+			delete(overrides, identifier)
+		}
+	}
+	return overrides, nil
+}
+
+// save writes the overrides to the backend, expiring the document with the
+// last override, and caches them
+func (s *OverrideStore) save(ctx context.Context, overrides map[string]*Override) error {
+	if len(overrides) == 0 {
+		if err := s.store.Delete(ctx, overridesKey); err != nil {
+			return fmt.Errorf("failed to delete rate limit overrides: %w", err)
+		}
+		s.cache(overrides)
+		return nil
+	}
+
+	var expiresAt time.Time
+	for _, override := range overrides {
+		if override.ExpiresAt.After(expiresAt) {
+			expiresAt = override.ExpiresAt
+		}
+	}
+
+	data, err := json.Marshal(overrides)
+	if err != nil {
+		return fmt.Errorf("failed to encode rate limit overrides: %w", err)
+	}
+	if err := s.store.Set(ctx, overridesKey, data, time.Until(expiresAt)); err != nil {
+		return fmt.Errorf("failed to write rate limit overrides: %w", err)
+	}
+	s.cache(overrides)
+	return nil
+}
@@ -0,0 +1,156 @@
+package ratelimit
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/songzhibin97/stargate/internal/store/driver/memory"
+	"github.com/songzhibin97/stargate/pkg/store"
+)
+
+func TestOverride_Validate(t *testing.T) {
+	expiresAt := time.Now().Add(time.Minute)
+	tests := []struct {
+		name     string
+		override Override
+		valid    bool
+	}{
+		{"block ip", Override{Identifier: "ip:10.0.0.1", Action: OverrideBlock, ExpiresAt: expiresAt}, true},
+		{"block with 429", Override{Identifier: "user:42", Action: OverrideBlock, Status: 429, ExpiresAt: expiresAt}, true},
+		{"block with 500", Override{Identifier: "user:42", Action: OverrideBlock, Status: 500, ExpiresAt: expiresAt}, false},
+		{"limit api key", Override{Identifier: "api_key:abc", Action: OverrideLimit, MaxRequests: 5, Window: time.Minute, ExpiresAt: expiresAt}, true},
+		{"limit without window", Override{Identifier: "api_key:abc", Action: OverrideLimit, MaxRequests: 5, ExpiresAt: expiresAt}, false},
+		{"limit without quota", Override{Identifier: "api_key:abc", Action: OverrideLimit, Window: time.Minute, ExpiresAt: expiresAt}, false},
+		{"unknown kind", Override{Identifier: "host:example.com", Action: OverrideBlock, ExpiresAt: expiresAt}, false},
+		{"empty value", Override{Identifier: "ip:", Action: OverrideBlock, ExpiresAt: expiresAt}, false},
+		{"unknown action", Override{Identifier: "ip:10.0.0.1", Action: "allow", ExpiresAt: expiresAt}, false},
+		{"no expiration", Override{Identifier: "ip:10.0.0.1", Action: OverrideBlock}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.override.Validate()
+			if (err == nil) != tt.valid {
+				t.Errorf("Expected valid=%v, got error %v", tt.valid, err)
+			}
+		})
+	}
+
+	override := Override{Identifier: "ip:10.0.0.1", Action: OverrideBlock, ExpiresAt: expiresAt}
+	override.Validate()
+	if override.Status != http.StatusForbidden {
+		t.Errorf("Expected blocks to default to status 403, got %d", override.Status)
+	}
+}
+
+func TestOverrideStore_SharedBackend(t *testing.T) {
+	backend, err := memory.New(&store.Config{KeyPrefix: "ratelimit"})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer backend.Close()
+
+	// Two nodes sharing the backend
+	node1 := NewOverrideStore(backend, "ratelimit:")
+	node2 := NewOverrideStore(backend, "ratelimit:")
+	ctx := context.Background()
+
+	if err := node1.Set(ctx, &Override{Identifier: "ip:10.0.0.1", Action: OverrideBlock, Reason: "abuse", ExpiresAt: time.Now().Add(time.Hour)}); err != nil {
+		t.Fatalf("Failed to set override: %v", err)
+	}
+	if err := node1.Set(ctx, &Override{Identifier: "user:42", Action: OverrideBlock, ExpiresAt: time.Now().Add(50 * time.Millisecond)}); err != nil {
+		t.Fatalf("Failed to set override: %v", err)
+	}
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	if override := node2.Match(req); override == nil || override.Reason != "abuse" {
+		t.Fatalf("Expected the override set through another node, got %+v", override)
+	}
+
+	// Overrides expire automatically
+	time.Sleep(60 * time.Millisecond)
+	list, err := node2.List(ctx)
+	if err != nil {
+		t.Fatalf("Failed to list overrides: %v", err)
+	}
+	if len(list) != 1 || list[0].Identifier != "ip:10.0.0.1" {
+		t.Errorf("Expected only the unexpired override, got %+v", list)
+	}
+
+	deleted, err := node2.Delete(ctx, "ip:10.0.0.1")
+	if err != nil || !deleted {
+		t.Fatalf("Expected the override to be deleted, got %v, %v", deleted, err)
+	}
+	if deleted, _ := node2.Delete(ctx, "ip:10.0.0.1"); deleted {
+		t.Error("Expected deleting a missing override to report false")
+	}
+	if override := node2.Match(req); override != nil {
+		t.Errorf("Expected no override after deleting it, got %+v", override)
+	}
+}
+
+func TestMiddleware_Handler_Overrides(t *testing.T) {
+	middleware, err := NewMiddleware(&Config{
+		Strategy:           StrategyFixedWindow,
+		IdentifierStrategy: IdentifierIP,
+		WindowSize:         time.Minute,
+		MaxRequests:        100,
+		CleanupInterval:    5 * time.Minute,
+		Enabled:            true,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create middleware: %v", err)
+	}
+	defer middleware.Stop()
+
+	ctx := context.Background()
+	expiresAt := time.Now().Add(time.Hour)
+	middleware.Overrides().Set(ctx, &Override{Identifier: "ip:10.0.0.1", Action: OverrideBlock, Reason: "credential stuffing", ExpiresAt: expiresAt})
+	middleware.Overrides().Set(ctx, &Override{Identifier: "api_key:clamped-key", Action: OverrideLimit, MaxRequests: 2, Window: time.Minute, Reason: "scraping", ExpiresAt: expiresAt})
+
+	handler := middleware.Handler()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	send := func(ip, apiKey string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = ip + ":1234"
+		if apiKey != "" {
+			req.Header.Set("X-API-Key", apiKey)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	w := send("10.0.0.1", "")
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("Expected blocked client to get 403, got %d", w.Code)
+	}
+	var response RateLimitErrorResponse
+	json.NewDecoder(w.Body).Decode(&response)
+	if response.Reason != "credential stuffing" {
+		t.Errorf("Expected the reason of the block, got %+v", response)
+	}
+
+	for i := 0; i < 2; i++ {
+		if w := send("10.0.0.2", "clamped-key"); w.Code != http.StatusOK {
+			t.Fatalf("Request %d: expected status 200 within the override quota, got %d", i, w.Code)
+		}
+	}
+	w = send("10.0.0.3", "clamped-key")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected clamped client to get 429 over its quota, got %d", w.Code)
+	}
+	if w.Header().Get("Retry-After") == "" || w.Header().Get("X-RateLimit-Limit") != "2" {
+		t.Errorf("Expected rate limit headers of the override, got %v", w.Header())
+	}
+
+	if w := send("10.0.0.4", "other-key"); w.Code != http.StatusOK {
+		t.Errorf("Expected clients without overrides to be allowed, got %d", w.Code)
+	}
+}
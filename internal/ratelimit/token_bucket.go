@@ -1,6 +1,7 @@
 package ratelimit
 
 import (
+	"context"
 	"sync"
 	"time"
 )
@@ -214,6 +215,16 @@ func (tb *TokenBucketRateLimiter) GetStats() *RateLimiterStats {
 	}
 }
 
+// Flush refills the buckets of all identifiers and returns how many were removed
+func (tb *TokenBucketRateLimiter) Flush(ctx context.Context) (int64, error) {
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+
+	flushed := int64(len(tb.buckets))
+	tb.buckets = make(map[string]*bucketData)
+	return flushed, nil
+}
+
 // Stop stops the rate limiter and cleans up resources
 func (tb *TokenBucketRateLimiter) Stop() {
 	if tb.cleanupTicker != nil {
@@ -32,6 +32,7 @@ type Route struct {
 	Paths      []string          `json:"paths"`
 	Methods    []string          `json:"methods"`
 	UpstreamID string            `json:"upstream_id"`
+	FallbackUpstreams []string   `json:"fallback_upstreams,omitempty"`
 	Priority   int               `json:"priority"`
 	Metadata   map[string]string `json:"metadata"`
 	CreatedAt  int64             `json:"created_at"`
@@ -348,10 +348,12 @@ func (er *EnhancedRouter) RemoveRoute(routeID string) bool {
 	return false
 }
 
-// Match 匹配HTTP请求
+// Match 匹配HTTP请求，只考虑请求服务租户的路由
 func (er *EnhancedRouter) Match(req *http.Request) *EnhancedMatchResult {
+	tenant := TenantFromContext(req.Context())
 	for _, route := range er.routes {
-		if route.MatchRequest(req) {
+		// 禁用的路由视为不匹配，请求继续匹配其他路由
+		if route.Tenant == tenant && route.IsEnabled() && route.MatchRequest(req) {
 			result := NewEnhancedMatchResult(route, true)
 			
 			// 找到匹配的路径规则
@@ -372,9 +374,10 @@ func (er *EnhancedRouter) Match(req *http.Request) *EnhancedMatchResult {
 // MatchAll 匹配所有符合条件的路由
 func (er *EnhancedRouter) MatchAll(req *http.Request) []*EnhancedMatchResult {
 	results := make([]*EnhancedMatchResult, 0)
+	tenant := TenantFromContext(req.Context())
 	
 	for _, route := range er.routes {
-		if route.MatchRequest(req) {
+		if route.Tenant == tenant && route.IsEnabled() && route.MatchRequest(req) {
 			result := NewEnhancedMatchResult(route, true)
 			
 			// 找到匹配的路径规则
@@ -16,6 +16,7 @@ var (
 	ErrQueryNameEmpty      = errors.New("query parameter name cannot be empty")
 	ErrQueryValueRequired  = errors.New("query parameter value is required for value/regex match type")
 	ErrDuplicateRouteID    = errors.New("duplicate route ID")
+	ErrFallbackLoop        = errors.New("fallback upstreams must not repeat the route's upstream or each other")
 	
 	// 上游服务错误
 	ErrUpstreamNameEmpty    = errors.New("upstream name cannot be empty")
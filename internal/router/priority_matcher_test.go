@@ -317,3 +317,105 @@ func testSamePriorityAcceptance(t *testing.T) {
 
 	t.Logf(" 功能要求：相同优先级时正确选择了第一个匹配的路由")
 }
+
+// TestEnhancedRouter_DisabledRoute 验证禁用的路由视为不匹配，请求落到其他路由
+func TestEnhancedRouter_DisabledRoute(t *testing.T) {
+	disabled := false
+	router := NewEnhancedRouter()
+	routes := []RouteRule{
+		{
+			ID:         "flagged-route",
+			Rules:      Rule{Paths: []PathRule{{Type: MatchTypePrefix, Value: "/api/beta"}}},
+			UpstreamID: "beta-upstream",
+			Priority:   500,
+			Enabled:    &disabled,
+		},
+		{
+			ID:         "api-route",
+			Rules:      Rule{Paths: []PathRule{{Type: MatchTypePrefix, Value: "/api"}}},
+			UpstreamID: "api-upstream",
+			Priority:   100,
+		},
+	}
+	for i := range routes {
+		if err := router.AddRoute(&routes[i]); err != nil {
+			t.Fatalf("Failed to add route %s: %v", routes[i].ID, err)
+		}
+	}
+
+	req, _ := http.NewRequest("GET", "http://example.com/api/beta/items", nil)
+	result := router.Match(req)
+	if !result.Matched || result.Route.ID != "api-route" {
+		t.Fatalf("Expected disabled route to fall through to api-route, got %+v", result.Route)
+	}
+	if all := router.MatchAll(req); len(all) != 1 {
+		t.Errorf("Expected MatchAll to skip the disabled route, got %d matches", len(all))
+	}
+	if router.Size() != 2 {
+		t.Errorf("Expected the disabled route to be kept, got %d routes", router.Size())
+	}
+
+	// 重新启用后立即生效
+	enabled := true
+	routes[0].Enabled = &enabled
+	if result := router.Match(req); result.Route.ID != "flagged-route" {
+		t.Errorf("Expected re-enabled route to match, got %s", result.Route.ID)
+	}
+}
+
+func TestEnhancedRouter_TenantRoutes(t *testing.T) {
+	router := NewEnhancedRouter()
+	routes := []RouteRule{
+		{
+			ID:         "api",
+			Rules:      Rule{Paths: []PathRule{{Type: MatchTypePrefix, Value: "/api"}}},
+			UpstreamID: "default-upstream",
+			Priority:   100,
+		},
+		{
+			ID:         QualifiedID("acme", "api"),
+			Tenant:     "acme",
+			Rules:      Rule{Paths: []PathRule{{Type: MatchTypePrefix, Value: "/api"}}},
+			UpstreamID: QualifiedID("acme", "backend"),
+			Priority:   100,
+		},
+		{
+			ID:         QualifiedID("acme", "beta"),
+			Tenant:     "acme",
+			Rules:      Rule{Paths: []PathRule{{Type: MatchTypePrefix, Value: "/beta"}}},
+			UpstreamID: QualifiedID("acme", "beta"),
+			Priority:   100,
+		},
+	}
+	for i := range routes {
+		if err := router.AddRoute(&routes[i]); err != nil {
+			t.Fatalf("Failed to add route %s: %v", routes[i].ID, err)
+		}
+	}
+
+	tests := []struct {
+		tenant string
+		path   string
+		want   string
+	}{
+		{"", "/api/items", "api"},
+		{"acme", "/api/items", "acme/api"},
+		{"acme", "/beta", "acme/beta"},
+		{"", "/beta", ""},      // 默认租户看不到其他租户的路由
+		{"globex", "/api", ""}, // 没有路由的租户不会落到默认租户
+	}
+	for _, tt := range tests {
+		req, _ := http.NewRequest("GET", "http://example.com"+tt.path, nil)
+		req = req.WithContext(WithTenant(req.Context(), tt.tenant))
+		result := router.Match(req)
+		if tt.want == "" {
+			if result.Matched {
+				t.Errorf("tenant %q path %s: expected no match, got %s", tt.tenant, tt.path, result.Route.ID)
+			}
+			continue
+		}
+		if !result.Matched || result.Route.ID != tt.want {
+			t.Errorf("tenant %q path %s: expected %s, got %+v", tt.tenant, tt.path, tt.want, result.Route)
+		}
+	}
+}
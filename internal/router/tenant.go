@@ -0,0 +1,26 @@
+package router
+
+import "context"
+
+// tenantContextKey 请求上下文中服务租户的键
+type tenantContextKey struct{}
+
+// WithTenant 返回携带服务租户的上下文，请求只匹配该租户的路由
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantContextKey{}, tenant)
+}
+
+// TenantFromContext 返回上下文中的服务租户，未设置时为默认租户 ""
+func TenantFromContext(ctx context.Context) string {
+	tenant, _ := ctx.Value(tenantContextKey{}).(string)
+	return tenant
+}
+
+// QualifiedID 返回租户对象在数据面中的ID：默认租户保持原ID，
+// 其他租户加上 "<tenant>/" 前缀，避免不同租户的同名路由和上游服务冲突
+func QualifiedID(tenant, id string) string {
+	if tenant == "" || id == "" {
+		return id
+	}
+	return tenant + "/" + id
+}
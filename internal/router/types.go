@@ -90,8 +90,14 @@ type RouteRule struct {
 	Name       string            `yaml:"name" json:"name"`
 	Rules      Rule              `yaml:"rules" json:"rules"`
 	UpstreamID string            `yaml:"upstream_id" json:"upstream_id"`
+	// FallbackUpstreams are tried in order when UpstreamID has no healthy target
+	FallbackUpstreams []string `yaml:"fallback_upstreams,omitempty" json:"fallback_upstreams,omitempty"`
 	Priority   int               `yaml:"priority,omitempty" json:"priority,omitempty"`
 	Metadata   map[string]string `yaml:"metadata,omitempty" json:"metadata,omitempty"`
+	// Enabled 为false时保留路由定义但不参与匹配，未设置时视为启用
+	Enabled *bool `yaml:"enabled,omitempty" json:"enabled,omitempty"`
+	// Tenant 路由所属租户，由存储键决定而不从路由定义读取，默认租户为空
+	Tenant string `yaml:"-" json:"-"`
 	// Developer Portal fields
 	OpenAPISpec *OpenAPISpec      `yaml:"openapi_spec,omitempty" json:"openapi_spec,omitempty"`
 	CreatedAt   int64             `yaml:"created_at,omitempty" json:"created_at,omitempty"`
@@ -145,6 +151,18 @@ func (r *RouteRule) Validate() error {
 		return ErrUpstreamIDEmpty
 	}
 	
+	// 验证备用上游服务不形成循环
+	fallbacks := map[string]bool{r.UpstreamID: true}
+	for _, upstreamID := range r.FallbackUpstreams {
+		if upstreamID == "" {
+			return ErrUpstreamIDEmpty
+		}
+		if fallbacks[upstreamID] {
+			return ErrFallbackLoop
+		}
+		fallbacks[upstreamID] = true
+	}
+	
 	// 验证规则至少有一个匹配条件
 	if len(r.Rules.Hosts) == 0 && len(r.Rules.Paths) == 0 &&
 	   len(r.Rules.Methods) == 0 && len(r.Rules.Headers) == 0 &&
@@ -232,9 +250,10 @@ func (u *Upstream) Validate() error {
 	// 验证负载均衡算法
 	if u.Algorithm != "" {
 		validAlgorithms := map[string]bool{
-			"round_robin": true,
-			"weighted":    true,
-			"ip_hash":     true,
+			"round_robin":     true,
+			"weighted":        true,
+			"weighted_random": true,
+			"ip_hash":         true,
 		}
 		if !validAlgorithms[u.Algorithm] {
 			return ErrInvalidAlgorithm
@@ -279,6 +298,11 @@ func (rc *RoutingConfig) Validate() error {
 		if !upstreamIDs[route.UpstreamID] {
 			return ErrUpstreamNotFound
 		}
+		for _, upstreamID := range route.FallbackUpstreams {
+			if !upstreamIDs[upstreamID] {
+				return ErrUpstreamNotFound
+			}
+		}
 	}
 	
 	return nil
@@ -306,6 +330,7 @@ func (r *RouteRule) ToLegacyRoute() *Route {
 		Paths:      paths,
 		Methods:    methods,
 		UpstreamID: r.UpstreamID,
+		FallbackUpstreams: r.FallbackUpstreams,
 		Priority:   r.Priority,
 		Metadata:   r.Metadata,
 		CreatedAt:  r.CreatedAt,
@@ -313,6 +338,11 @@ func (r *RouteRule) ToLegacyRoute() *Route {
 	}
 }
 
+// IsEnabled 返回路由是否参与匹配
+func (r *RouteRule) IsEnabled() bool {
+	return r.Enabled == nil || *r.Enabled
+}
+
 // SetTimestamps 设置时间戳
 func (r *RouteRule) SetTimestamps() {
 	now := time.Now().Unix()
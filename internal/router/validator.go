@@ -78,6 +78,11 @@ func (v *Validator) ValidateRoutingConfig(config *RoutingConfig) error {
 		if !upstreamIDs[route.UpstreamID] {
 			return fmt.Errorf("route %s references non-existent upstream: %s", route.ID, route.UpstreamID)
 		}
+		for _, upstreamID := range route.FallbackUpstreams {
+			if !upstreamIDs[upstreamID] {
+				return fmt.Errorf("route %s references non-existent fallback upstream: %s", route.ID, upstreamID)
+			}
+		}
 	}
 	
 	return nil
@@ -233,6 +238,18 @@ func (v *Validator) validateBasicFields(route *RouteRule) error {
 		return fmt.Errorf("invalid upstream ID format: %s", route.UpstreamID)
 	}
 	
+	// 验证备用上游服务，同一上游只能出现一次以防止循环
+	seen := map[string]bool{route.UpstreamID: true}
+	for _, upstreamID := range route.FallbackUpstreams {
+		if !isValidID(upstreamID) {
+			return fmt.Errorf("invalid fallback upstream ID format: %s", upstreamID)
+		}
+		if seen[upstreamID] {
+			return fmt.Errorf("fallback upstream %s repeats the route's upstream or another fallback", upstreamID)
+		}
+		seen[upstreamID] = true
+	}
+	
 	return nil
 }
 
@@ -363,10 +380,11 @@ func (v *Validator) validateTarget(target *Target) error {
 // validateAlgorithm 验证负载均衡算法
 func (v *Validator) validateAlgorithm(algorithm string) error {
 	validAlgorithms := map[string]bool{
-		"round_robin": true,
-		"weighted":    true,
-		"ip_hash":     true,
-		"least_conn":  true,
+		"round_robin":     true,
+		"weighted":        true,
+		"weighted_random": true,
+		"ip_hash":         true,
+		"least_conn":      true,
 	}
 	
 	if !validAlgorithms[algorithm] {
@@ -65,6 +65,32 @@ func TestValidator_ValidateRouteRule(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "fallback upstreams",
+			route: &RouteRule{
+				ID:   "test-route",
+				Name: "Test Route",
+				Rules: Rule{
+					Hosts: []string{"example.com"},
+				},
+				UpstreamID:        "test-upstream",
+				FallbackUpstreams: []string{"backup-upstream", "dr-upstream"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "fallback upstream repeating the upstream",
+			route: &RouteRule{
+				ID:   "test-route",
+				Name: "Test Route",
+				Rules: Rule{
+					Hosts: []string{"example.com"},
+				},
+				UpstreamID:        "test-upstream",
+				FallbackUpstreams: []string{"backup-upstream", "test-upstream"},
+			},
+			wantErr: true,
+		},
 		{
 			name: "empty rules",
 			route: &RouteRule{
@@ -0,0 +1,55 @@
+// Package dynamodb implements store.AtomicStore on Amazon DynamoDB with the
+// AWS SDK for Go v2.
+//
+// # Table Layout
+//
+// Keys are items of a table whose partition key is the string attribute
+// "key". Integer values are stored as numbers in "value" so they can be
+// incremented, other values as binary. Keys with a TTL carry their
+// expiration in epoch seconds in "expires_at", which should be enabled as
+// the table's TTL attribute so DynamoDB deletes expired items:
+//
+//	aws dynamodb create-table --table-name stargate-ratelimit \
+//	    --attribute-definitions AttributeName=key,AttributeType=S \
+//	    --key-schema AttributeName=key,KeyType=HASH \
+//	    --billing-mode PAY_PER_REQUEST
+//	aws dynamodb update-time-to-live --table-name stargate-ratelimit \
+//	    --time-to-live-specification Enabled=true,AttributeName=expires_at
+//
+// # Configuration
+//
+// The store is configured with store.Config: Address is the endpoint, by
+// default the regional DynamoDB endpoint, Username and Password are the
+// access key ID and secret access key, and Options hold "table", "region"
+// and "session_token". Missing credentials and region are resolved by the
+// SDK's default chain: the AWS_* environment variables, shared config and
+// credentials files, web identity tokens (IRSA), ECS task roles and EC2
+// instance profiles. Temporary credentials are refreshed before they
+// expire, and throttled or failed requests are retried with backoff by the
+// SDK's standard retryer.
+//
+// # Consistency
+//
+// Each operation on a key is a single request, conditional where needed:
+// increments are atomic and never lost, and reads are strongly consistent.
+// The differences from the Redis driver, which increments and sets the TTL
+// in one Lua script run by Redis on a single thread, are:
+//
+//   - DynamoDB deletes expired items lazily, up to days late, so the store
+//     treats items past expires_at as missing itself. Incrementing such an
+//     item takes a second conditional request resetting it, and concurrent
+//     resets are settled by retrying, costing extra round trips under
+//     contention where Redis needs one.
+//   - TTLs have a resolution of one second and are rounded up, where Redis
+//     keeps milliseconds.
+//   - A key lives in one partition, so a single hot counter is limited by
+//     DynamoDB's per-partition throughput of about 1,000 writes per second,
+//     an order of magnitude below a Redis instance. Rate limit identifiers
+//     spread over many keys, so this only matters for very hot clients.
+//   - Every operation is an HTTPS round trip to a regional service, adding
+//     a few milliseconds per request compared to a Redis in the same
+//     network.
+//   - Global tables replicate asynchronously, so nodes in different regions
+//     count separately until replication catches up, and concurrent updates
+//     of the same key in two regions are resolved by last writer wins.
+package dynamodb
@@ -0,0 +1,432 @@
+package dynamodb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/songzhibin97/stargate/pkg/store"
+)
+
+// Item attributes
+const (
+	keyAttribute    = "key"
+	valueAttribute  = "value"
+	expiryAttribute = "expires_at"
+)
+
+// maxResetAttempts caps the attempts to reset an expired counter that other
+// nodes keep resetting concurrently
+const maxResetAttempts = 3
+
+// DynamoDBStore implements the store.AtomicStore interface using DynamoDB
+type DynamoDBStore struct {
+	client    *dynamodb.Client
+	endpoint  string // Configured endpoint, "" for the regional endpoint
+	table     string
+	region    string
+	keyPrefix string
+	config    *store.Config
+}
+
+// item is a DynamoDB item or key
+type item map[string]types.AttributeValue
+
+// New creates a new DynamoDB store instance. The table isn't checked and
+// credentials are resolved on the first request, so the store can be
+// created while DynamoDB or the credential source is unreachable.
+func New(config *store.Config) (store.AtomicStore, error) {
+	if config == nil {
+		config = store.DefaultConfig()
+	}
+
+	table := option(config, "table")
+	if table == "" {
+		return nil, fmt.Errorf("dynamodb table is required")
+	}
+
+	endpoint := config.Address
+	if endpoint != "" {
+		if u, err := url.Parse(endpoint); err != nil || u.Scheme == "" || u.Host == "" {
+			return nil, fmt.Errorf("invalid dynamodb endpoint: %s", endpoint)
+		}
+	}
+
+	timeout := config.Timeout
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+
+	// The default chain resolves the region and credentials from the
+	// environment, shared config files, web identity tokens (IRSA), ECS task
+	// roles and EC2 instance profiles
+	opts := []func(*awsconfig.LoadOptions) error{
+		awsconfig.WithHTTPClient(awshttp.NewBuildableClient().WithTimeout(timeout)),
+	}
+	if region := option(config, "region"); region != "" {
+		opts = append(opts, awsconfig.WithRegion(region))
+	}
+	if config.Username != "" || config.Password != "" {
+		opts = append(opts, awsconfig.WithCredentialsProvider(
+			credentials.NewStaticCredentialsProvider(config.Username, config.Password, option(config, "session_token"))))
+	}
+	awsCfg, err := awsconfig.LoadDefaultConfig(context.Background(), opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS configuration: %w", err)
+	}
+	if awsCfg.Region == "" {
+		return nil, fmt.Errorf("dynamodb region is required")
+	}
+
+	client := dynamodb.NewFromConfig(awsCfg, func(o *dynamodb.Options) {
+		if endpoint != "" {
+			o.BaseEndpoint = aws.String(endpoint)
+		}
+	})
+
+	return &DynamoDBStore{
+		client:    client,
+		endpoint:  endpoint,
+		table:     table,
+		region:    awsCfg.Region,
+		keyPrefix: config.KeyPrefix,
+		config:    config,
+	}, nil
+}
+
+// option returns a string option of the configuration
+func option(config *store.Config, name string) string {
+	value, _ := config.Options[name].(string)
+	return value
+}
+
+// getKey returns the full key with prefix
+func (ds *DynamoDBStore) getKey(key string) string {
+	if ds.keyPrefix == "" {
+		return key
+	}
+	return ds.keyPrefix + ":" + key
+}
+
+// itemKey returns the primary key of the item of a full key
+func itemKey(fullKey string) item {
+	return item{keyAttribute: stringValue(fullKey)}
+}
+
+// stringValue returns a string attribute value
+func stringValue(s string) types.AttributeValue {
+	return &types.AttributeValueMemberS{Value: s}
+}
+
+// numberValue returns a number attribute value
+func numberValue(n int64) types.AttributeValue {
+	return &types.AttributeValueMemberN{Value: strconv.FormatInt(n, 10)}
+}
+
+// numberOf returns the integer of a number attribute value
+func numberOf(value types.AttributeValue) (int64, bool) {
+	n, ok := value.(*types.AttributeValueMemberN)
+	if !ok {
+		return 0, false
+	}
+	i, err := strconv.ParseInt(n.Value, 10, 64)
+	return i, err == nil
+}
+
+// expiresAt returns the expiration of a TTL starting now in epoch seconds,
+// rounded up
+func expiresAt(now time.Time, ttl time.Duration) int64 {
+	t := now.Add(ttl)
+	if t.Nanosecond() > 0 {
+		return t.Unix() + 1
+	}
+	return t.Unix()
+}
+
+// expiry returns the expiration of an item in epoch seconds, if it has one
+func (it item) expiry() (int64, bool) {
+	return numberOf(it[expiryAttribute])
+}
+
+// expired reports whether an item has expired, DynamoDB may not have
+// deleted it yet
+func (it item) expired(now time.Time) bool {
+	expires, ok := it.expiry()
+	return ok && expires <= now.Unix()
+}
+
+// isConditionFailed reports whether err is a failed condition expression
+func isConditionFailed(err error) bool {
+	var conditionErr *types.ConditionalCheckFailedException
+	return errors.As(err, &conditionErr)
+}
+
+// IncrBy atomically increments the value of a key by the given amount
+func (ds *DynamoDBStore) IncrBy(ctx context.Context, key string, value int64) (int64, error) {
+	return ds.increment(ctx, key, value, 0)
+}
+
+// IncrByWithTTL atomically increments the value of a key by the given amount
+// and sets ttl on it if it has no expiration
+func (ds *DynamoDBStore) IncrByWithTTL(ctx context.Context, key string, value int64, ttl time.Duration) (int64, error) {
+	return ds.increment(ctx, key, value, ttl)
+}
+
+// increment adds value to a counter, setting ttl if positive and the counter
+// has no expiration. A live counter is updated in one conditional request;
+// an expired one DynamoDB hasn't deleted yet fails the condition and is
+// reset, unless another node reset it first, in which case the increment is
+// tried again.
+func (ds *DynamoDBStore) increment(ctx context.Context, key string, value int64, ttl time.Duration) (int64, error) {
+	fullKey := ds.getKey(key)
+
+	for attempt := 0; attempt < maxResetAttempts; attempt++ {
+		now := time.Now()
+		values := map[string]types.AttributeValue{
+			":inc": numberValue(value),
+			":now": numberValue(now.Unix()),
+		}
+		update := "ADD #v :inc"
+		reset := "SET #v = :inc REMOVE #e"
+		if ttl > 0 {
+			values[":exp"] = numberValue(expiresAt(now, ttl))
+			update = "ADD #v :inc SET #e = if_not_exists(#e, :exp)"
+			reset = "SET #v = :inc, #e = :exp"
+		}
+
+		input := &dynamodb.UpdateItemInput{
+			TableName:                 aws.String(ds.table),
+			Key:                       itemKey(fullKey),
+			UpdateExpression:          aws.String(update),
+			ConditionExpression:       aws.String("attribute_not_exists(#e) OR #e > :now"),
+			ExpressionAttributeNames:  map[string]string{"#v": valueAttribute, "#e": expiryAttribute},
+			ExpressionAttributeValues: values,
+			ReturnValues:              types.ReturnValueUpdatedNew,
+		}
+		result, err := ds.updateCounter(ctx, input)
+		if !isConditionFailed(err) {
+			if err != nil {
+				return 0, fmt.Errorf("failed to increment key %s: %w", key, err)
+			}
+			return result, nil
+		}
+
+		input.UpdateExpression = aws.String(reset)
+		input.ConditionExpression = aws.String("#e <= :now")
+		result, err = ds.updateCounter(ctx, input)
+		if !isConditionFailed(err) {
+			if err != nil {
+				return 0, fmt.Errorf("failed to reset expired key %s: %w", key, err)
+			}
+			return result, nil
+		}
+	}
+
+	return 0, fmt.Errorf("failed to increment key %s: too many concurrent resets", key)
+}
+
+// updateCounter runs an update of a counter and returns its new value
+func (ds *DynamoDBStore) updateCounter(ctx context.Context, input *dynamodb.UpdateItemInput) (int64, error) {
+	output, err := ds.client.UpdateItem(ctx, input)
+	if err != nil {
+		return 0, err
+	}
+
+	value, ok := numberOf(output.Attributes[valueAttribute])
+	if !ok {
+		return 0, fmt.Errorf("updated item has no numeric value")
+	}
+	return value, nil
+}
+
+// Set stores a value by key with optional TTL. Integer values are stored as
+// numbers so they can be incremented.
+func (ds *DynamoDBStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	it := itemKey(ds.getKey(key))
+	if n, err := strconv.ParseInt(string(value), 10, 64); err == nil {
+		it[valueAttribute] = numberValue(n)
+	} else {
+		it[valueAttribute] = &types.AttributeValueMemberB{Value: append([]byte{}, value...)}
+	}
+	if ttl > 0 {
+		it[expiryAttribute] = numberValue(expiresAt(time.Now(), ttl))
+	}
+
+	input := &dynamodb.PutItemInput{
+		TableName: aws.String(ds.table),
+		Item:      it,
+	}
+	if _, err := ds.client.PutItem(ctx, input); err != nil {
+		return fmt.Errorf("failed to set key %s: %w", key, err)
+	}
+
+	return nil
+}
+
+// getItem returns the item of a key, or nil if it doesn't exist or expired
+func (ds *DynamoDBStore) getItem(ctx context.Context, key string) (item, error) {
+	output, err := ds.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(ds.table),
+		Key:            itemKey(ds.getKey(key)),
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	it := item(output.Item)
+	if it == nil || it.expired(time.Now()) {
+		return nil, nil
+	}
+	return it, nil
+}
+
+// Get retrieves a value by key
+func (ds *DynamoDBStore) Get(ctx context.Context, key string) ([]byte, error) {
+	it, err := ds.getItem(ctx, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get key %s: %w", key, err)
+	}
+	if it == nil {
+		return nil, nil
+	}
+
+	switch value := it[valueAttribute].(type) {
+	case *types.AttributeValueMemberN:
+		return []byte(value.Value), nil
+	case *types.AttributeValueMemberS:
+		return []byte(value.Value), nil
+	case *types.AttributeValueMemberB:
+		return value.Value, nil
+	default:
+		return []byte{}, nil
+	}
+}
+
+// Delete removes a key from storage
+func (ds *DynamoDBStore) Delete(ctx context.Context, key string) error {
+	input := &dynamodb.DeleteItemInput{
+		TableName: aws.String(ds.table),
+		Key:       itemKey(ds.getKey(key)),
+	}
+	if _, err := ds.client.DeleteItem(ctx, input); err != nil {
+		return fmt.Errorf("failed to delete key %s: %w", key, err)
+	}
+
+	return nil
+}
+
+// DeletePrefix removes all keys starting with prefix within the store's key
+// prefix. Keys are found by scanning the table, which reads every item, so
+// it's meant for occasional flushes. An empty prefix on a store without a
+// key prefix is rejected rather than wiping the table.
+func (ds *DynamoDBStore) DeletePrefix(ctx context.Context, prefix string) (int64, error) {
+	if ds.keyPrefix == "" && prefix == "" {
+		return 0, fmt.Errorf("refusing to delete all keys without a key prefix")
+	}
+	fullPrefix := ds.getKey(prefix)
+
+	var deleted int64
+	paginator := dynamodb.NewScanPaginator(ds.client, &dynamodb.ScanInput{
+		TableName:                 aws.String(ds.table),
+		FilterExpression:          aws.String("begins_with(#k, :prefix)"),
+		ProjectionExpression:      aws.String("#k"),
+		ExpressionAttributeNames:  map[string]string{"#k": keyAttribute},
+		ExpressionAttributeValues: map[string]types.AttributeValue{":prefix": stringValue(fullPrefix)},
+	})
+	for paginator.HasMorePages() {
+		output, err := paginator.NextPage(ctx)
+		if err != nil {
+			return deleted, fmt.Errorf("failed to scan keys with prefix %s: %w", prefix, err)
+		}
+
+		for _, it := range output.Items {
+			deleteInput := &dynamodb.DeleteItemInput{
+				TableName: aws.String(ds.table),
+				Key:       item{keyAttribute: it[keyAttribute]},
+			}
+			if _, err := ds.client.DeleteItem(ctx, deleteInput); err != nil {
+				return deleted, fmt.Errorf("failed to delete keys with prefix %s: %w", prefix, err)
+			}
+			deleted++
+		}
+	}
+
+	return deleted, nil
+}
+
+// Exists checks if a key exists in storage
+func (ds *DynamoDBStore) Exists(ctx context.Context, key string) (bool, error) {
+	it, err := ds.getItem(ctx, key)
+	if err != nil {
+		return false, fmt.Errorf("failed to check existence of key %s: %w", key, err)
+	}
+
+	return it != nil, nil
+}
+
+// TTL returns the remaining time to live for a key
+func (ds *DynamoDBStore) TTL(ctx context.Context, key string) (time.Duration, error) {
+	it, err := ds.getItem(ctx, key)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get TTL for key %s: %w", key, err)
+	}
+	if it == nil {
+		return -2 * time.Second, nil // Key doesn't exist
+	}
+
+	expires, ok := it.expiry()
+	if !ok {
+		return -1 * time.Second, nil // Key has no expiration
+	}
+	return time.Until(time.Unix(expires, 0)), nil
+}
+
+// Close closes the store connection and releases resources
+func (ds *DynamoDBStore) Close() error {
+	return nil
+}
+
+// Health returns the health status of the store
+func (ds *DynamoDBStore) Health(ctx context.Context) store.HealthStatus {
+	health := store.HealthStatus{
+		Status:    "healthy",
+		Message:   "DynamoDB store is operational",
+		Timestamp: time.Now(),
+		Details: map[string]interface{}{
+			"type":   "dynamodb",
+			"region": ds.region,
+			"table":  ds.table,
+		},
+	}
+	if ds.endpoint != "" {
+		health.Details["endpoint"] = ds.endpoint
+	}
+
+	output, err := ds.client.DescribeTable(ctx, &dynamodb.DescribeTableInput{TableName: aws.String(ds.table)})
+	if err != nil {
+		health.Status = "unhealthy"
+		health.Message = fmt.Sprintf("DynamoDB request failed: %v", err)
+		health.Details["error"] = err.Error()
+		return health
+	}
+
+	status := output.Table.TableStatus
+	health.Details["table_status"] = string(status)
+	health.Details["item_count"] = aws.ToInt64(output.Table.ItemCount)
+	if status != types.TableStatusActive && status != types.TableStatusUpdating {
+		health.Status = "unhealthy"
+		health.Message = fmt.Sprintf("DynamoDB table is %s", status)
+	}
+
+	return health
+}
@@ -0,0 +1,342 @@
+package dynamodb
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/songzhibin97/stargate/pkg/store"
+)
+
+// getDynamoDBConfig returns a DynamoDB Local configuration for testing,
+// skipping the test unless DYNAMODB_ENDPOINT points at DynamoDB Local, e.g.
+//
+//	docker run -p 8000:8000 amazon/dynamodb-local
+//	DYNAMODB_ENDPOINT=http://localhost:8000 go test ./internal/store/driver/dynamodb
+func getDynamoDBConfig(t *testing.T) *store.Config {
+	t.Helper()
+
+	endpoint := os.Getenv("DYNAMODB_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("DYNAMODB_ENDPOINT not set, DynamoDB Local is not available")
+	}
+
+	return &store.Config{
+		Type:      "dynamodb",
+		Address:   endpoint,
+		Username:  "local",
+		Password:  "local",
+		Timeout:   5 * time.Second,
+		KeyPrefix: "test",
+		Options: map[string]interface{}{
+			"table":  fmt.Sprintf("stargate-test-%d", time.Now().UnixNano()),
+			"region": "us-east-1",
+		},
+	}
+}
+
+// newTestStore creates a store on a new table, deleted when the test ends
+func newTestStore(t *testing.T) *DynamoDBStore {
+	t.Helper()
+
+	s, err := New(getDynamoDBConfig(t))
+	if err != nil {
+		t.Fatalf("Failed to create DynamoDB store: %v", err)
+	}
+	ds := s.(*DynamoDBStore)
+
+	ctx := context.Background()
+	_, err = ds.client.CreateTable(ctx, &dynamodb.CreateTableInput{
+		TableName:            aws.String(ds.table),
+		AttributeDefinitions: []types.AttributeDefinition{{AttributeName: aws.String(keyAttribute), AttributeType: types.ScalarAttributeTypeS}},
+		KeySchema:            []types.KeySchemaElement{{AttributeName: aws.String(keyAttribute), KeyType: types.KeyTypeHash}},
+		BillingMode:          types.BillingModePayPerRequest,
+	})
+	if err != nil {
+		t.Skipf("Failed to create table (DynamoDB Local may not be available): %v", err)
+	}
+	t.Cleanup(func() {
+		ds.client.DeleteTable(context.Background(), &dynamodb.DeleteTableInput{TableName: aws.String(ds.table)})
+		ds.Close()
+	})
+
+	return ds
+}
+
+func TestNew_Config(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "")
+	t.Setenv("AWS_REGION", "")
+	t.Setenv("AWS_DEFAULT_REGION", "")
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(t.TempDir(), "config"))
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(t.TempDir(), "credentials"))
+
+	tests := []struct {
+		name   string
+		config *store.Config
+		valid  bool
+	}{
+		{"complete", &store.Config{Username: "id", Password: "secret", Options: map[string]interface{}{"table": "t", "region": "eu-west-1"}}, true},
+		{"missing table", &store.Config{Username: "id", Password: "secret", Options: map[string]interface{}{"region": "eu-west-1"}}, false},
+		{"missing region", &store.Config{Username: "id", Password: "secret", Options: map[string]interface{}{"table": "t"}}, false},
+		{"default credentials", &store.Config{Options: map[string]interface{}{"table": "t", "region": "eu-west-1"}}, true},
+		{"invalid endpoint", &store.Config{Address: "localhost:8000", Username: "id", Password: "secret", Options: map[string]interface{}{"table": "t", "region": "eu-west-1"}}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(tt.config)
+			if tt.valid && err != nil {
+				t.Errorf("Expected the configuration to be valid, got %v", err)
+			}
+			if !tt.valid && err == nil {
+				t.Error("Expected the configuration to be rejected")
+			}
+		})
+	}
+
+	t.Setenv("AWS_ACCESS_KEY_ID", "env-id")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "env-secret")
+	t.Setenv("AWS_REGION", "ap-south-1")
+	s, err := New(&store.Config{Options: map[string]interface{}{"table": "t"}})
+	if err != nil {
+		t.Fatalf("Expected credentials and region from the environment, got %v", err)
+	}
+	ds := s.(*DynamoDBStore)
+	creds, err := ds.client.Options().Credentials.Retrieve(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to retrieve credentials: %v", err)
+	}
+	if creds.AccessKeyID != "env-id" || ds.region != "ap-south-1" {
+		t.Errorf("Expected the environment credentials and region, got %s and %s", creds.AccessKeyID, ds.region)
+	}
+
+	// Configured credentials take precedence over the default chain
+	s, err = New(&store.Config{Username: "id", Password: "secret", Options: map[string]interface{}{"table": "t", "session_token": "token"}})
+	if err != nil {
+		t.Fatalf("Failed to create DynamoDB store: %v", err)
+	}
+	creds, err = s.(*DynamoDBStore).client.Options().Credentials.Retrieve(context.Background())
+	if err != nil || creds.AccessKeyID != "id" || creds.SessionToken != "token" {
+		t.Errorf("Expected the configured credentials, got %+v, %v", creds, err)
+	}
+}
+
+func TestDynamoDBStore_IncrementResetsExpiredItem(t *testing.T) {
+	// An expired item DynamoDB hasn't deleted yet fails the increment's
+	// condition and is reset
+	var mu sync.Mutex
+	var updates []map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if target := r.Header.Get("X-Amz-Target"); target != "DynamoDB_20120810.UpdateItem" {
+			t.Errorf("Unexpected operation %s", target)
+		}
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=id/") {
+			t.Errorf("Expected a signed request, got %q", r.Header.Get("Authorization"))
+		}
+
+		body, _ := io.ReadAll(r.Body)
+		var input map[string]interface{}
+		json.Unmarshal(body, &input)
+
+		mu.Lock()
+		updates = append(updates, input)
+		first := len(updates) == 1
+		mu.Unlock()
+
+		if first {
+			w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"__type":"com.amazonaws.dynamodb.v20120810#ConditionalCheckFailedException","message":"The conditional request failed"}`))
+			return
+		}
+		w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+		w.Write([]byte(`{"Attributes":{"value":{"N":"1"},"expires_at":{"N":"1700000000"}}}`))
+	}))
+	defer server.Close()
+
+	s, err := New(&store.Config{
+		Address:   server.URL,
+		Username:  "id",
+		Password:  "secret",
+		KeyPrefix: "rl",
+		Options:   map[string]interface{}{"table": "counters", "region": "us-east-1"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create DynamoDB store: %v", err)
+	}
+
+	count, err := s.(store.ExpiringIncrementer).IncrByWithTTL(context.Background(), "window", 1, time.Minute)
+	if err != nil {
+		t.Fatalf("IncrByWithTTL failed: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("Expected count 1 after the reset, got %d", count)
+	}
+
+	if len(updates) != 2 {
+		t.Fatalf("Expected an increment and a reset, got %d updates", len(updates))
+	}
+	if key := fmt.Sprint(updates[0]["Key"]); key != "map[key:map[S:rl:window]]" {
+		t.Errorf("Expected the prefixed key, got %s", key)
+	}
+	if updates[1]["UpdateExpression"] != "SET #v = :inc, #e = :exp" || updates[1]["ConditionExpression"] != "#e <= :now" {
+		t.Errorf("Expected the expired item reset, got %q if %q", updates[1]["UpdateExpression"], updates[1]["ConditionExpression"])
+	}
+}
+
+func TestDynamoDBStore_IncrBy(t *testing.T) {
+	ctx := context.Background()
+	ds := newTestStore(t)
+
+	result, err := ds.IncrBy(ctx, "counter", 5)
+	if err != nil {
+		t.Fatalf("IncrBy failed: %v", err)
+	}
+	if result != 5 {
+		t.Errorf("Expected 5, got %d", result)
+	}
+
+	result, err = ds.IncrBy(ctx, "counter", 3)
+	if err != nil {
+		t.Fatalf("IncrBy failed: %v", err)
+	}
+	if result != 8 {
+		t.Errorf("Expected 8, got %d", result)
+	}
+
+	if ttl, _ := ds.TTL(ctx, "counter"); ttl != -1*time.Second {
+		t.Errorf("Expected no expiration, got %v", ttl)
+	}
+
+	// Counters set by Set can be incremented
+	if err := ds.Set(ctx, "window", []byte("0"), time.Minute); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if result, err = ds.IncrBy(ctx, "window", 1); err != nil || result != 1 {
+		t.Errorf("Expected 1 after incrementing a set counter, got %d, %v", result, err)
+	}
+}
+
+func TestDynamoDBStore_IncrByWithTTLConcurrent(t *testing.T) {
+	ctx := context.Background()
+	ds := newTestStore(t)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				if _, err := ds.IncrByWithTTL(ctx, "window", 1, time.Minute); err != nil {
+					t.Errorf("IncrByWithTTL failed: %v", err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	value, err := ds.Get(ctx, "window")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if string(value) != "100" {
+		t.Errorf("Expected 100 increments counted, got %s", value)
+	}
+
+	ttl, err := ds.TTL(ctx, "window")
+	if err != nil {
+		t.Fatalf("TTL failed: %v", err)
+	}
+	if ttl <= 0 || ttl > time.Minute+time.Second {
+		t.Errorf("Expected a TTL of about a minute, got %v", ttl)
+	}
+}
+
+func TestDynamoDBStore_Expiration(t *testing.T) {
+	ctx := context.Background()
+	ds := newTestStore(t)
+
+	if err := ds.Set(ctx, "session", []byte("data"), time.Second); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if _, err := ds.IncrByWithTTL(ctx, "window", 5, time.Second); err != nil {
+		t.Fatalf("IncrByWithTTL failed: %v", err)
+	}
+
+	value, err := ds.Get(ctx, "session")
+	if err != nil || string(value) != "data" {
+		t.Fatalf("Expected the value before expiration, got %q, %v", value, err)
+	}
+
+	time.Sleep(2100 * time.Millisecond)
+
+	// DynamoDB Local doesn't delete expired items, the store ignores them
+	if exists, err := ds.Exists(ctx, "session"); err != nil || exists {
+		t.Errorf("Expected the expired key to be missing, got %v, %v", exists, err)
+	}
+	if ttl, _ := ds.TTL(ctx, "session"); ttl != -2*time.Second {
+		t.Errorf("Expected TTL -2s for an expired key, got %v", ttl)
+	}
+
+	count, err := ds.IncrByWithTTL(ctx, "window", 1, time.Minute)
+	if err != nil {
+		t.Fatalf("IncrByWithTTL failed: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("Expected the expired counter to restart at 1, got %d", count)
+	}
+}
+
+func TestDynamoDBStore_DeletePrefix(t *testing.T) {
+	ctx := context.Background()
+	ds := newTestStore(t)
+
+	for _, key := range []string{"ratelimit:a", "ratelimit:b", "other:c"} {
+		if err := ds.Set(ctx, key, []byte("1"), 0); err != nil {
+			t.Fatalf("Set failed: %v", err)
+		}
+	}
+
+	deleted, err := ds.DeletePrefix(ctx, "ratelimit:")
+	if err != nil {
+		t.Fatalf("DeletePrefix failed: %v", err)
+	}
+	if deleted != 2 {
+		t.Errorf("Expected 2 keys deleted, got %d", deleted)
+	}
+	if exists, _ := ds.Exists(ctx, "other:c"); !exists {
+		t.Error("Expected keys outside the prefix to be kept")
+	}
+
+	if err := ds.Delete(ctx, "other:c"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if value, _ := ds.Get(ctx, "other:c"); value != nil {
+		t.Errorf("Expected the deleted key to be missing, got %q", value)
+	}
+}
+
+func TestDynamoDBStore_Health(t *testing.T) {
+	ds := newTestStore(t)
+
+	health := ds.Health(context.Background())
+	if health.Status != "healthy" {
+		t.Errorf("Expected a healthy store, got %s: %s", health.Status, health.Message)
+	}
+	if health.Details["table"] != ds.table {
+		t.Errorf("Expected the table in the health details, got %v", health.Details["table"])
+	}
+}
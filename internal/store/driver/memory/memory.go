@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"strings"
 	"sync"
 	"time"
 
@@ -77,17 +78,35 @@ func (ms *MemoryStore) IncrBy(ctx context.Context, key string, value int64) (int
 	ms.mu.Lock()
 	defer ms.mu.Unlock()
 
-	fullKey := ms.getKey(key)
+	return ms.incrBy(ms.getKey(key), value, 0)
+}
+
+// IncrByWithTTL atomically increments the value of a key by the given amount
+// and sets ttl on it if it has no expiration
+func (ms *MemoryStore) IncrByWithTTL(ctx context.Context, key string, value int64, ttl time.Duration) (int64, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	return ms.incrBy(ms.getKey(key), value, ttl)
+}
+
+// incrBy increments the value of a key, setting ttl if positive and the key
+// has no expiration. The caller must hold the lock.
+func (ms *MemoryStore) incrBy(fullKey string, value int64, ttl time.Duration) (int64, error) {
 	existingEntry, exists := ms.data[fullKey]
 
 	// Check if entry exists and is not expired
 	if !exists || existingEntry.isExpired() {
 		// Create new entry with the increment value
 		newValue := value
-		ms.data[fullKey] = &entry{
+		newEntry := &entry{
 			value:     []byte(fmt.Sprintf("%d", newValue)),
-			hasExpiry: false,
+			hasExpiry: ttl > 0,
 		}
+		if ttl > 0 {
+			newEntry.expiresAt = time.Now().Add(ttl)
+		}
+		ms.data[fullKey] = newEntry
 		return newValue, nil
 	}
 
@@ -105,6 +124,10 @@ func (ms *MemoryStore) IncrBy(ctx context.Context, key string, value int64) (int
 	// Increment the value
 	newValue := currentValue + value
 	existingEntry.value = []byte(fmt.Sprintf("%d", newValue))
+	if ttl > 0 && !existingEntry.hasExpiry {
+		existingEntry.hasExpiry = true
+		existingEntry.expiresAt = time.Now().Add(ttl)
+	}
 
 	return newValue, nil
 }
@@ -130,6 +153,31 @@ func (ms *MemoryStore) Set(ctx context.Context, key string, value []byte, ttl ti
 	return nil
 }
 
+// SetNX stores a value by key with optional TTL if the key doesn't exist
+func (ms *MemoryStore) SetNX(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	fullKey := ms.getKey(key)
+	if existingEntry, exists := ms.data[fullKey]; exists && !existingEntry.isExpired() {
+		return false, nil
+	}
+
+	entry := &entry{
+		value:     make([]byte, len(value)),
+		hasExpiry: ttl > 0,
+	}
+
+	copy(entry.value, value)
+
+	if ttl > 0 {
+		entry.expiresAt = time.Now().Add(ttl)
+	}
+
+	ms.data[fullKey] = entry
+	return true, nil
+}
+
 // Get retrieves a value by key
 func (ms *MemoryStore) Get(ctx context.Context, key string) ([]byte, error) {
 	ms.mu.RLock()
@@ -158,6 +206,22 @@ func (ms *MemoryStore) Delete(ctx context.Context, key string) error {
 	return nil
 }
 
+// DeletePrefix removes all keys starting with prefix within the store's key prefix
+func (ms *MemoryStore) DeletePrefix(ctx context.Context, prefix string) (int64, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	fullPrefix := ms.getKey(prefix)
+	var deleted int64
+	for key := range ms.data {
+		if strings.HasPrefix(key, fullPrefix) {
+			delete(ms.data, key)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
 // Exists checks if a key exists in storage
 func (ms *MemoryStore) Exists(ctx context.Context, key string) (bool, error) {
 	ms.mu.RLock()
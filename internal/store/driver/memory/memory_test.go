@@ -44,6 +44,44 @@ func TestMemoryStore_IncrBy(t *testing.T) {
 	}
 }
 
+func TestMemoryStore_IncrByWithTTL(t *testing.T) {
+	ctx := context.Background()
+	ms, err := New(nil)
+	if err != nil {
+		t.Fatalf("Failed to create memory store: %v", err)
+	}
+	defer ms.Close()
+
+	incrementer := ms.(store.ExpiringIncrementer)
+
+	result, err := incrementer.IncrByWithTTL(ctx, "window", 1, 100*time.Millisecond)
+	if err != nil || result != 1 {
+		t.Fatalf("Expected 1, got %d, %v", result, err)
+	}
+
+	// The TTL of an existing counter is kept
+	time.Sleep(50 * time.Millisecond)
+	if result, _ = incrementer.IncrByWithTTL(ctx, "window", 1, time.Minute); result != 2 {
+		t.Errorf("Expected 2, got %d", result)
+	}
+	if ttl, _ := ms.TTL(ctx, "window"); ttl > 100*time.Millisecond {
+		t.Errorf("Expected the original TTL to be kept, got %v", ttl)
+	}
+
+	// An expired counter restarts with a new TTL
+	time.Sleep(100 * time.Millisecond)
+	if result, _ = incrementer.IncrByWithTTL(ctx, "window", 1, time.Minute); result != 1 {
+		t.Errorf("Expected the expired counter to restart at 1, got %d", result)
+	}
+
+	// A counter without expiration gets one
+	ms.IncrBy(ctx, "counter", 1)
+	incrementer.IncrByWithTTL(ctx, "counter", 1, time.Minute)
+	if ttl, _ := ms.TTL(ctx, "counter"); ttl <= 0 {
+		t.Errorf("Expected the counter to get a TTL, got %v", ttl)
+	}
+}
+
 func TestMemoryStore_SetGet(t *testing.T) {
 	ctx := context.Background()
 	ms, err := New(nil)
@@ -251,6 +289,36 @@ func TestMemoryStore_KeyPrefix(t *testing.T) {
 	}
 }
 
+func TestMemoryStore_DeletePrefix(t *testing.T) {
+	ctx := context.Background()
+	ms, err := New(&store.Config{KeyPrefix: "gateway"})
+	if err != nil {
+		t.Fatalf("Failed to create memory store: %v", err)
+	}
+	defer ms.Close()
+
+	for _, key := range []string{"ratelimit:a", "ratelimit:b", "other:c"} {
+		if err := ms.Set(ctx, key, []byte("1"), 0); err != nil {
+			t.Fatalf("Set failed: %v", err)
+		}
+	}
+
+	deleted, err := ms.(store.PrefixDeleter).DeletePrefix(ctx, "ratelimit:")
+	if err != nil {
+		t.Fatalf("DeletePrefix failed: %v", err)
+	}
+	if deleted != 2 {
+		t.Errorf("Expected 2 keys deleted, got %d", deleted)
+	}
+
+	if exists, _ := ms.Exists(ctx, "ratelimit:a"); exists {
+		t.Error("Expected ratelimit:a to be deleted")
+	}
+	if exists, _ := ms.Exists(ctx, "other:c"); !exists {
+		t.Error("Expected other:c to be kept")
+	}
+}
+
 func TestMemoryStore_ConcurrentAccess(t *testing.T) {
 	ctx := context.Background()
 	ms, err := New(nil)
@@ -294,3 +362,29 @@ func TestMemoryStore_ConcurrentAccess(t *testing.T) {
 		t.Errorf("Expected %d, got %s", expectedValue, string(result))
 	}
 }
+
+func TestMemoryStore_SetNX(t *testing.T) {
+	ctx := context.Background()
+	ms, err := New(&store.Config{})
+	if err != nil {
+		t.Fatalf("Failed to create memory store: %v", err)
+	}
+	defer ms.Close()
+
+	setter := ms.(store.ExclusiveSetter)
+	if stored, err := setter.SetNX(ctx, "claim", []byte("first"), 50*time.Millisecond); err != nil || !stored {
+		t.Fatalf("Expected the first SetNX to store, got %v, %v", stored, err)
+	}
+	if stored, _ := setter.SetNX(ctx, "claim", []byte("second"), 0); stored {
+		t.Error("Expected SetNX on an existing key not to store")
+	}
+	if value, _ := ms.Get(ctx, "claim"); string(value) != "first" {
+		t.Errorf("Expected value first, got %q", value)
+	}
+
+	// Expired keys can be claimed again
+	time.Sleep(60 * time.Millisecond)
+	if stored, _ := setter.SetNX(ctx, "claim", []byte("second"), 0); !stored {
+		t.Error("Expected SetNX on an expired key to store")
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/redis/go-redis/v9"
@@ -81,6 +82,32 @@ func (rs *RedisStore) IncrBy(ctx context.Context, key string, value int64) (int6
 	return result, nil
 }
 
+// incrByWithTTLScript increments a key and sets its TTL in milliseconds if it
+// has none, in one atomic step
+var incrByWithTTLScript = redis.NewScript(`
+local value = redis.call('INCRBY', KEYS[1], ARGV[1])
+if redis.call('PTTL', KEYS[1]) == -1 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return value
+`)
+
+// IncrByWithTTL atomically increments the value of a key by the given amount
+// and sets ttl on it if it has no expiration
+func (rs *RedisStore) IncrByWithTTL(ctx context.Context, key string, value int64, ttl time.Duration) (int64, error) {
+	if ttl <= 0 {
+		return rs.IncrBy(ctx, key, value)
+	}
+	fullKey := rs.getKey(key)
+
+	result, err := incrByWithTTLScript.Run(ctx, rs.client, []string{fullKey}, value, max(ttl.Milliseconds(), 1)).Int64()
+	if err != nil {
+		return 0, fmt.Errorf("failed to increment key %s: %w", key, err)
+	}
+
+	return result, nil
+}
+
 // Set stores a value by key with optional TTL
 func (rs *RedisStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
 	fullKey := rs.getKey(key)
@@ -99,6 +126,18 @@ func (rs *RedisStore) Set(ctx context.Context, key string, value []byte, ttl tim
 	return nil
 }
 
+// SetNX stores a value by key with optional TTL if the key doesn't exist
+func (rs *RedisStore) SetNX(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error) {
+	fullKey := rs.getKey(key)
+
+	stored, err := rs.client.SetNX(ctx, fullKey, value, ttl).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to set key %s: %w", key, err)
+	}
+
+	return stored, nil
+}
+
 // Get retrieves a value by key
 func (rs *RedisStore) Get(ctx context.Context, key string) ([]byte, error) {
 	fullKey := rs.getKey(key)
@@ -126,6 +165,55 @@ func (rs *RedisStore) Delete(ctx context.Context, key string) error {
 	return nil
 }
 
+// deletePrefixBatchSize is the number of keys scanned and deleted per round trip
+const deletePrefixBatchSize = 500
+
+// DeletePrefix removes all keys starting with prefix within the store's key
+// prefix. Keys are found with SCAN so Redis is never blocked. An empty prefix
+// on a store without a key prefix is rejected rather than wiping the database.
+func (rs *RedisStore) DeletePrefix(ctx context.Context, prefix string) (int64, error) {
+	fullPrefix := rs.getKey(prefix)
+	if rs.keyPrefix == "" && prefix == "" {
+		return 0, fmt.Errorf("refusing to delete all keys without a key prefix")
+	}
+
+	var deleted int64
+	var cursor uint64
+	pattern := escapePattern(fullPrefix) + "*"
+	for {
+		keys, next, err := rs.client.Scan(ctx, cursor, pattern, deletePrefixBatchSize).Result()
+		if err != nil {
+			return deleted, fmt.Errorf("failed to scan keys with prefix %s: %w", prefix, err)
+		}
+
+		if len(keys) > 0 {
+			n, err := rs.client.Del(ctx, keys...).Result()
+			if err != nil {
+				return deleted, fmt.Errorf("failed to delete keys with prefix %s: %w", prefix, err)
+			}
+			deleted += n
+		}
+
+		cursor = next
+		if cursor == 0 {
+			return deleted, nil
+		}
+	}
+}
+
+// escapePattern escapes the glob characters of a SCAN MATCH pattern
+func escapePattern(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch r {
+		case '*', '?', '[', ']', '\\':
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
 // Exists checks if a key exists in storage
 func (rs *RedisStore) Exists(ctx context.Context, key string) (bool, error) {
 	fullKey := rs.getKey(key)
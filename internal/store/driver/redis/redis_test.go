@@ -320,3 +320,77 @@ func TestRedisStore_KeyPrefix(t *testing.T) {
 		t.Errorf("Expected %s, got %s", string(value), string(result))
 	}
 }
+
+func TestRedisStore_DeletePrefix(t *testing.T) {
+	ctx := context.Background()
+	config := getRedisConfig()
+	config.KeyPrefix = "test-delete-prefix"
+
+	rs, err := New(config)
+	if err != nil {
+		t.Skipf("Failed to create Redis store (Redis may not be available): %v", err)
+	}
+	defer rs.Close()
+
+	skipIfRedisUnavailable(t, rs)
+
+	// A store sharing the database under another prefix must be left alone
+	otherConfig := getRedisConfig()
+	otherConfig.KeyPrefix = "test-delete-prefix-other"
+	other, err := New(otherConfig)
+	if err != nil {
+		t.Fatalf("Failed to create Redis store: %v", err)
+	}
+	defer other.Close()
+	defer other.Delete(ctx, "ratelimit:a")
+
+	for _, key := range []string{"ratelimit:a", "ratelimit:b", "other:c"} {
+		if err := rs.Set(ctx, key, []byte("1"), time.Minute); err != nil {
+			t.Fatalf("Set failed: %v", err)
+		}
+		defer rs.Delete(ctx, key)
+	}
+	if err := other.Set(ctx, "ratelimit:a", []byte("1"), time.Minute); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+
+	deleted, err := rs.(store.PrefixDeleter).DeletePrefix(ctx, "ratelimit:")
+	if err != nil {
+		t.Fatalf("DeletePrefix failed: %v", err)
+	}
+	if deleted != 2 {
+		t.Errorf("Expected 2 keys deleted, got %d", deleted)
+	}
+
+	if exists, _ := rs.Exists(ctx, "other:c"); !exists {
+		t.Error("Expected other:c to be kept")
+	}
+	if exists, _ := other.Exists(ctx, "ratelimit:a"); !exists {
+		t.Error("Expected key of the other store to be kept")
+	}
+}
+
+func TestRedisStore_SetNX(t *testing.T) {
+	ctx := context.Background()
+	config := getRedisConfig()
+
+	rs, err := New(config)
+	if err != nil {
+		t.Skipf("Failed to create Redis store (Redis may not be available): %v", err)
+	}
+	defer rs.Close()
+
+	skipIfRedisUnavailable(t, rs)
+	defer rs.Delete(ctx, "setnx:claim")
+
+	setter := rs.(store.ExclusiveSetter)
+	if stored, err := setter.SetNX(ctx, "setnx:claim", []byte("first"), time.Minute); err != nil || !stored {
+		t.Fatalf("Expected the first SetNX to store, got %v, %v", stored, err)
+	}
+	if stored, _ := setter.SetNX(ctx, "setnx:claim", []byte("second"), time.Minute); stored {
+		t.Error("Expected SetNX on an existing key not to store")
+	}
+	if value, _ := rs.Get(ctx, "setnx:claim"); string(value) != "first" {
+		t.Errorf("Expected value first, got %q", value)
+	}
+}
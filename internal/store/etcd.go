@@ -210,6 +210,12 @@ func (es *EtcdStore) Unwatch(key string) error {
 	return nil
 }
 
+// Client returns the etcd client of the store, for coordination such as
+// leader election on the same cluster
+func (es *EtcdStore) Client() *clientv3.Client {
+	return es.client
+}
+
 // Close closes the etcd store
 func (es *EtcdStore) Close() error {
 	// Stop all watchers
@@ -0,0 +1,38 @@
+package store
+
+import "strings"
+
+// TenantsPrefix is the prefix of the keys of tenants other than the default
+const TenantsPrefix = "tenants/"
+
+// TenantPrefix returns the prefix of a tenant's keys of a kind such as
+// routes or upstreams. The default tenant, named "", keeps the unscoped
+// keys of single-tenant deployments.
+func TenantPrefix(tenant, kind string) string {
+	if tenant == "" {
+		return kind + "/"
+	}
+	return TenantsPrefix + tenant + "/" + kind + "/"
+}
+
+// TenantKey returns the key of a tenant's object of a kind
+func TenantKey(tenant, kind, id string) string {
+	return TenantPrefix(tenant, kind) + id
+}
+
+// ParseTenantKey splits a key made by TenantKey into its tenant, kind and
+// object ID, failing for keys of other layouts
+func ParseTenantKey(key string) (tenant, kind, id string, ok bool) {
+	if rest, scoped := strings.CutPrefix(key, TenantsPrefix); scoped {
+		parts := strings.SplitN(rest, "/", 3)
+		if len(parts) != 3 || parts[0] == "" || parts[2] == "" {
+			return "", "", "", false
+		}
+		return parts[0], parts[1], parts[2], true
+	}
+	kind, id, found := strings.Cut(key, "/")
+	if !found || id == "" {
+		return "", "", "", false
+	}
+	return "", kind, id, true
+}
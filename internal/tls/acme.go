@@ -1,8 +1,11 @@
 package tls
 
 import (
+	"context"
 	"crypto/tls"
 	"crypto/x509"
+	"encoding/pem"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -15,6 +18,36 @@ import (
 	"github.com/songzhibin97/stargate/internal/config"
 )
 
+// renewBefore is how long before expiry certificates are renewed
+const renewBefore = 30 * 24 * time.Hour
+
+// DefaultForceRenewInterval is the minimum time between forced renewals of a
+// domain when not configured
+const DefaultForceRenewInterval = time.Hour
+
+// ErrUnknownDomain is returned for domains not managed by the ACME manager
+var ErrUnknownDomain = errors.New("domain is not managed by ACME")
+
+// RenewalRateLimitError is returned when a forced renewal of a domain comes
+// too soon after the previous one
+type RenewalRateLimitError struct {
+	Domain  string
+	RetryAt time.Time
+}
+
+func (e *RenewalRateLimitError) Error() string {
+	return fmt.Sprintf("certificate for %s was renewed recently, retry after %s", e.Domain, e.RetryAt.Format(time.RFC3339))
+}
+
+// CertificateRenewal is the renewal state of a domain's certificate
+type CertificateRenewal struct {
+	Domain      string     `json:"domain"`
+	NotAfter    *time.Time `json:"not_after,omitempty"`
+	LastRenewal *time.Time `json:"last_renewal,omitempty"` // Issuance of the cached certificate
+	NextRenewal *time.Time `json:"next_renewal,omitempty"` // Scheduled renewal, 30 days before expiry
+	Error       string     `json:"error,omitempty"`
+}
+
 // ACMEManager manages ACME certificates
 type ACMEManager struct {
 	config   *config.ACMEConfig
@@ -22,6 +55,14 @@ type ACMEManager struct {
 	mu       sync.RWMutex
 	started  bool
 	stopChan chan struct{}
+
+	// Forced renewals replace the autocert manager, whose in-memory
+	// certificates can't be evicted
+	cacheDir      string
+	httpChallenge bool                 // Answer http-01 challenges, set by GetHTTPHandler
+	renewMu       sync.Mutex           // Serializes forced renewals
+	renewals      map[string]time.Time // Last forced renewal attempt per domain
+	obtain        func(manager *autocert.Manager, domain string) (*tls.Certificate, error)
 }
 
 // NewACMEManager creates a new ACME certificate manager
@@ -54,23 +95,44 @@ func NewACMEManager(cfg *config.ACMEConfig) (*ACMEManager, error) {
 		return nil, fmt.Errorf("failed to create ACME cache directory: %w", err)
 	}
 
-	// Create autocert manager
+	am := &ACMEManager{
+		config:   cfg,
+		stopChan: make(chan struct{}),
+		cacheDir: cacheDir,
+		renewals: make(map[string]time.Time),
+		obtain:   obtainCertificate,
+	}
+	am.manager = am.newAutocertManager()
+
+	return am, nil
+}
+
+// newAutocertManager creates an autocert manager over the certificate cache
+func (am *ACMEManager) newAutocertManager() *autocert.Manager {
 	manager := &autocert.Manager{
-		Prompt:     autocert.AcceptTOS,
-		Cache:      autocert.DirCache(cacheDir),
-		HostPolicy: autocert.HostWhitelist(cfg.Domains...),
-		Email:      cfg.Email,
+		Prompt:      autocert.AcceptTOS,
+		Cache:       autocert.DirCache(am.cacheDir),
+		HostPolicy:  autocert.HostWhitelist(am.config.Domains...),
+		Email:       am.config.Email,
+		RenewBefore: renewBefore,
 	}
 
 	// Note: Custom directory URL configuration would require additional setup
 	// For staging environment, this would need to be configured differently
 	// in newer versions of autocert
 
-	return &ACMEManager{
-		config:   cfg,
-		manager:  manager,
-		stopChan: make(chan struct{}),
-	}, nil
+	if am.httpChallenge {
+		// Enables http-01 challenges on the new manager
+		manager.HTTPHandler(nil)
+	}
+	return manager
+}
+
+// currentManager returns the autocert manager in use
+func (am *ACMEManager) currentManager() *autocert.Manager {
+	am.mu.RLock()
+	defer am.mu.RUnlock()
+	return am.manager
 }
 
 // Start starts the ACME manager
@@ -110,15 +172,148 @@ func (am *ACMEManager) Stop() error {
 // GetTLSConfig returns a TLS configuration with ACME certificate management
 func (am *ACMEManager) GetTLSConfig() *tls.Config {
 	return &tls.Config{
-		GetCertificate: am.manager.GetCertificate,
-		NextProtos:     []string{"h2", "http/1.1"},
-		MinVersion:     tls.VersionTLS12,
+		GetCertificate: func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return am.currentManager().GetCertificate(hello)
+		},
+		NextProtos: []string{"h2", "http/1.1"},
+		MinVersion: tls.VersionTLS12,
 	}
 }
 
 // GetHTTPHandler returns an HTTP handler for ACME challenges
 func (am *ACMEManager) GetHTTPHandler(next http.Handler) http.Handler {
-	return am.manager.HTTPHandler(next)
+	am.mu.Lock()
+	am.httpChallenge = true
+	am.manager.HTTPHandler(nil)
+	am.mu.Unlock()
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		am.currentManager().HTTPHandler(next).ServeHTTP(w, r)
+	})
+}
+
+// ForceRenew obtains a new certificate for a domain immediately, outside the
+// renewal window, and returns its expiry. Forced renewals of a domain are
+// limited to one per force_renew_interval, failed attempts included, to stay
+// within the ACME provider's rate limits. The previous certificate keeps
+// being served if renewal fails.
+func (am *ACMEManager) ForceRenew(ctx context.Context, domain string) (time.Time, error) {
+	if !am.managesDomain(domain) {
+		return time.Time{}, ErrUnknownDomain
+	}
+
+	am.renewMu.Lock()
+	defer am.renewMu.Unlock()
+
+	interval := am.config.ForceRenewInterval
+	if interval <= 0 {
+		interval = DefaultForceRenewInterval
+	}
+	am.mu.Lock()
+	if last, ok := am.renewals[domain]; ok && time.Since(last) < interval {
+		am.mu.Unlock()
+		return time.Time{}, &RenewalRateLimitError{Domain: domain, RetryAt: last.Add(interval)}
+	}
+	am.renewals[domain] = time.Now()
+	am.mu.Unlock()
+
+	// Move the cached certificate aside so the new manager can't load it,
+	// and restore it if renewal fails
+	cache := autocert.DirCache(am.cacheDir)
+	saved := make(map[string][]byte)
+	for _, key := range []string{domain, domain + "+rsa"} {
+		if data, err := cache.Get(ctx, key); err == nil {
+			saved[key] = data
+			cache.Delete(ctx, key)
+		}
+	}
+
+	manager := am.newAutocertManager()
+	cert, err := am.obtain(manager, domain)
+	if err == nil && len(cert.Certificate) == 0 {
+		err = fmt.Errorf("empty certificate")
+	}
+	var leaf *x509.Certificate
+	if err == nil {
+		leaf, err = x509.ParseCertificate(cert.Certificate[0])
+	}
+	if err != nil {
+		for key, data := range saved {
+			cache.Put(ctx, key, data)
+		}
+		log.Printf("Forced certificate renewal failed for domain %s: %v", domain, err)
+		return time.Time{}, fmt.Errorf("failed to renew certificate for %s: %w", domain, err)
+	}
+
+	am.mu.Lock()
+	am.manager = manager
+	am.mu.Unlock()
+
+	log.Printf("Certificate renewed for domain %s by request, expires %s", domain, leaf.NotAfter.Format(time.RFC3339))
+	return leaf.NotAfter, nil
+}
+
+// obtainCertificate requests a certificate through the autocert manager,
+// preferring ECDSA like modern clients
+func obtainCertificate(manager *autocert.Manager, domain string) (*tls.Certificate, error) {
+	return manager.GetCertificate(&tls.ClientHelloInfo{
+		ServerName:       domain,
+		SupportedCurves:  []tls.CurveID{tls.CurveP256},
+		SignatureSchemes: []tls.SignatureScheme{tls.ECDSAWithP256AndSHA256},
+		CipherSuites:     []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256},
+	})
+}
+
+// managesDomain reports whether the domain is configured for ACME
+func (am *ACMEManager) managesDomain(domain string) bool {
+	for _, d := range am.config.Domains {
+		if d == domain {
+			return true
+		}
+	}
+	return false
+}
+
+// Renewals returns the renewal state of each domain from the certificate
+// cache, without requesting certificates that were never issued
+func (am *ACMEManager) Renewals(ctx context.Context) []CertificateRenewal {
+	cache := autocert.DirCache(am.cacheDir)
+	renewals := make([]CertificateRenewal, 0, len(am.config.Domains))
+	for _, domain := range am.config.Domains {
+		renewal := CertificateRenewal{Domain: domain}
+
+		leaf, err := cachedLeaf(ctx, cache, domain)
+		switch {
+		case err == autocert.ErrCacheMiss:
+			// Not issued yet
+		case err != nil:
+			renewal.Error = err.Error()
+		default:
+			notAfter, issued, next := leaf.NotAfter, leaf.NotBefore, leaf.NotAfter.Add(-renewBefore)
+			renewal.NotAfter, renewal.LastRenewal, renewal.NextRenewal = &notAfter, &issued, &next
+		}
+		renewals = append(renewals, renewal)
+	}
+	return renewals
+}
+
+// cachedLeaf parses the leaf certificate of a domain from the autocert
+// cache, which stores the private key followed by the certificate chain
+func cachedLeaf(ctx context.Context, cache autocert.Cache, domain string) (*x509.Certificate, error) {
+	data, err := cache.Get(ctx, domain)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return nil, fmt.Errorf("no certificate cached for %s", domain)
+		}
+		if block.Type == "CERTIFICATE" {
+			return x509.ParseCertificate(block.Bytes)
+		}
+	}
 }
 
 // renewalMonitor monitors certificate expiration and triggers renewal
@@ -148,7 +343,7 @@ func (am *ACMEManager) checkAndRenewCertificates() {
 // checkCertificate checks if a certificate needs renewal
 func (am *ACMEManager) checkCertificate(domain string) error {
 	// Get certificate from manager
-	cert, err := am.manager.GetCertificate(&tls.ClientHelloInfo{
+	cert, err := am.currentManager().GetCertificate(&tls.ClientHelloInfo{
 		ServerName: domain,
 	})
 	if err != nil {
@@ -165,11 +360,11 @@ func (am *ACMEManager) checkCertificate(domain string) error {
 		}
 
 		timeUntilExpiry := time.Until(x509Cert.NotAfter)
-		if timeUntilExpiry < 30*24*time.Hour { // Renew if expires within 30 days
+		if timeUntilExpiry < renewBefore { // Renew if expires within 30 days
 			log.Printf("Certificate for domain %s expires in %v, triggering renewal", domain, timeUntilExpiry)
 
 			// Trigger renewal by requesting a new certificate
-			_, err := am.manager.GetCertificate(&tls.ClientHelloInfo{
+			_, err := am.currentManager().GetCertificate(&tls.ClientHelloInfo{
 				ServerName: domain,
 			})
 			if err != nil {
@@ -273,6 +468,10 @@ func ValidateConfig(cfg *config.ACMEConfig) error {
 		return fmt.Errorf("ACME Terms of Service must be accepted")
 	}
 
+	if cfg.ForceRenewInterval < 0 {
+		return fmt.Errorf("ACME force renew interval cannot be negative")
+	}
+
 	// Validate cache directory
 	if cfg.CacheDir != "" {
 		if !filepath.IsAbs(cfg.CacheDir) {
@@ -1,12 +1,23 @@
 package tls
 
 import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
 	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"testing"
+	"time"
 
+	"golang.org/x/crypto/acme/autocert"
 	"github.com/songzhibin97/stargate/internal/config"
 )
 
@@ -364,3 +375,106 @@ func TestValidateConfig(t *testing.T) {
 		})
 	}
 }
+
+// selfSignedCertificate creates a certificate for a domain and its autocert
+// cache entry, the private key followed by the certificate
+func selfSignedCertificate(t *testing.T, domain string, notAfter time.Time) (*tls.Certificate, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: domain},
+		DNSNames:     []string{domain},
+		NotBefore:    notAfter.Add(-90 * 24 * time.Hour),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+	data := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	data = append(data, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})...)
+	return &tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, data
+}
+
+func TestACMEManager_ForceRenew(t *testing.T) {
+	ctx := context.Background()
+	tempDir := t.TempDir()
+	manager, err := NewACMEManager(&config.ACMEConfig{
+		Enabled:   true,
+		Domains:   []string{"example.com", "www.example.com"},
+		Email:     "test@example.com",
+		CacheDir:  tempDir,
+		AcceptTOS: true,
+	})
+	if err != nil {
+		t.Fatalf("NewACMEManager() failed: %v", err)
+	}
+
+	oldExpiry := time.Now().Add(60 * 24 * time.Hour).Truncate(time.Second)
+	_, oldData := selfSignedCertificate(t, "example.com", oldExpiry)
+	autocert.DirCache(tempDir).Put(ctx, "example.com", oldData)
+
+	// A failed renewal keeps the cached certificate
+	manager.obtain = func(m *autocert.Manager, domain string) (*tls.Certificate, error) {
+		if _, err := m.Cache.Get(ctx, domain); err != autocert.ErrCacheMiss {
+			t.Errorf("Expected the cached certificate to be moved aside during renewal, got %v", err)
+		}
+		return nil, errors.New("order failed")
+	}
+	if _, err := manager.ForceRenew(ctx, "example.com"); err == nil {
+		t.Fatal("Expected the renewal error")
+	}
+	if data, _ := autocert.DirCache(tempDir).Get(ctx, "example.com"); string(data) != string(oldData) {
+		t.Error("Expected the cached certificate to be restored after a failed renewal")
+	}
+
+	// Failed attempts count toward the rate limit
+	var rateLimited *RenewalRateLimitError
+	if _, err := manager.ForceRenew(ctx, "example.com"); !errors.As(err, &rateLimited) {
+		t.Fatalf("Expected a rate limit error, got %v", err)
+	}
+	if until := time.Until(rateLimited.RetryAt); until <= 0 || until > DefaultForceRenewInterval {
+		t.Errorf("Unexpected retry time %v", rateLimited.RetryAt)
+	}
+
+	if _, err := manager.ForceRenew(ctx, "other.example.com"); !errors.Is(err, ErrUnknownDomain) {
+		t.Errorf("Expected ErrUnknownDomain, got %v", err)
+	}
+
+	// A successful renewal serves the new certificate
+	newExpiry := time.Now().Add(90 * 24 * time.Hour).Truncate(time.Second)
+	newCert, newData := selfSignedCertificate(t, "www.example.com", newExpiry)
+	manager.obtain = func(m *autocert.Manager, domain string) (*tls.Certificate, error) {
+		m.Cache.Put(ctx, domain, newData)
+		return newCert, nil
+	}
+	notAfter, err := manager.ForceRenew(ctx, "www.example.com")
+	if err != nil {
+		t.Fatalf("ForceRenew() failed: %v", err)
+	}
+	if !notAfter.Equal(newExpiry) {
+		t.Errorf("Expected expiry %v, got %v", newExpiry, notAfter)
+	}
+
+	renewals := manager.Renewals(ctx)
+	if len(renewals) != 2 {
+		t.Fatalf("Expected 2 renewal states, got %d", len(renewals))
+	}
+	for _, renewal := range renewals {
+		expiry := oldExpiry
+		if renewal.Domain == "www.example.com" {
+			expiry = newExpiry
+		}
+		if renewal.NotAfter == nil || !renewal.NotAfter.Equal(expiry) {
+			t.Errorf("%s: expected expiry %v, got %v", renewal.Domain, expiry, renewal.NotAfter)
+			continue
+		}
+		if !renewal.NextRenewal.Equal(expiry.Add(-30*24*time.Hour)) || !renewal.LastRenewal.Equal(expiry.Add(-90*24*time.Hour)) {
+			t.Errorf("%s: unexpected renewal times %v %v", renewal.Domain, renewal.LastRenewal, renewal.NextRenewal)
+		}
+	}
+}
@@ -0,0 +1,276 @@
+// Package webhook delivers signed JSON event notifications to external
+// receivers.
+//
+// Events are queued and delivered by a background worker, so callers never
+// block on the receiver. Failed deliveries are retried with exponential
+// backoff up to the configured retry count. When a secret is configured the
+// request body is signed with HMAC-SHA256 and the signature is sent in the
+// X-Stargate-Signature header as "sha256=<hex>". Each attempt carries its
+// number in the X-Stargate-Delivery-Attempt header.
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/songzhibin97/stargate/internal/config"
+)
+
+const (
+	// HeaderEvent carries the event type
+	HeaderEvent = "X-Stargate-Event"
+	// HeaderDelivery carries the unique delivery ID, stable across retries
+	HeaderDelivery = "X-Stargate-Delivery"
+	// HeaderAttempt carries the delivery attempt number, starting at 1
+	HeaderAttempt = "X-Stargate-Delivery-Attempt"
+	// HeaderTimestamp carries the Unix time the event was queued
+	HeaderTimestamp = "X-Stargate-Timestamp"
+	// HeaderSignature carries the HMAC-SHA256 signature of the body
+	HeaderSignature = "X-Stargate-Signature"
+
+	// signaturePrefix prefixes the hex encoded signature
+	signaturePrefix = "sha256="
+
+	defaultTimeout      = 10 * time.Second
+	defaultQueueSize    = 100
+	defaultRetryBackoff = time.Second
+	maxRetryBackoff     = 30 * time.Second
+)
+
+// delivery is a queued webhook request
+type delivery struct {
+	id        string
+	event     string
+	body      []byte
+	timestamp time.Time
+}
+
+// Sender delivers webhook events asynchronously
+type Sender struct {
+	config *config.WebhookConfig
+	client *http.Client
+	queue  chan *delivery
+	stopCh chan struct{}
+	wg     sync.WaitGroup
+
+	closeOnce    sync.Once
+	retryBackoff time.Duration
+
+	mu        sync.RWMutex
+	delivered int64
+	failed    int64
+	dropped   int64
+	retries   int64
+}
+
+// NewSender creates a webhook sender and starts its delivery worker
+func NewSender(cfg *config.WebhookConfig) *Sender {
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+
+	s := &Sender{
+		config:       cfg,
+		client:       &http.Client{Timeout: timeout},
+		queue:        make(chan *delivery, defaultQueueSize),
+		stopCh:       make(chan struct{}),
+		retryBackoff: defaultRetryBackoff,
+	}
+
+	s.wg.Add(1)
+	go s.run()
+
+	return s
+}
+
+// Enabled reports whether events are delivered
+func (s *Sender) Enabled() bool {
+	return s != nil && s.config.Enabled && s.config.URL != ""
+}
+
+// Send queues an event for delivery. It never blocks: if the queue is full
+// the event is dropped and logged.
+func (s *Sender) Send(event string, payload interface{}) error {
+	if !s.Enabled() {
+		return nil
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal webhook payload: %w", err)
+	}
+
+	d := &delivery{
+		id:        newDeliveryID(),
+		event:     event,
+		body:      body,
+		timestamp: time.Now(),
+	}
+
+	select {
+	case <-s.stopCh:
+		return fmt.Errorf("webhook sender is closed")
+	default:
+	}
+
+	select {
+	case s.queue <- d:
+		return nil
+	default:
+		s.mu.Lock()
+		s.dropped++
+		s.mu.Unlock()
+		log.Printf("Webhook queue full, dropping %s event %s", event, d.id)
+		return fmt.Errorf("webhook queue is full")
+	}
+}
+
+// Close stops the delivery worker. Queued events not yet delivered are discarded.
+func (s *Sender) Close() {
+	s.closeOnce.Do(func() {
+		close(s.stopCh)
+		s.wg.Wait()
+	})
+}
+
+// GetStats returns delivery statistics
+func (s *Sender) GetStats() map[string]interface{} {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return map[string]interface{}{
+		"enabled":   s.Enabled(),
+		"delivered": s.delivered,
+		"failed":    s.failed,
+		"dropped":   s.dropped,
+		"retries":   s.retries,
+		"queued":    len(s.queue),
+	}
+}
+
+// run delivers queued events in order
+func (s *Sender) run() {
+	defer s.wg.Done()
+
+	for {
+		select {
+		case <-s.stopCh:
+			return
+		case d := <-s.queue:
+			s.deliver(d)
+		}
+	}
+}
+
+// deliver sends an event, retrying failed attempts with exponential backoff
+func (s *Sender) deliver(d *delivery) {
+	attempts := s.config.RetryCount + 1
+	if attempts < 1 {
+		attempts = 1
+	}
+	backoff := s.retryBackoff
+
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		var retryable bool
+		if retryable, err = s.post(d, attempt); err == nil {
+			s.mu.Lock()
+			s.delivered++
+			s.mu.Unlock()
+			return
+		}
+		if !retryable || attempt == attempts {
+			break
+		}
+
+		log.Printf("Webhook %s event %s attempt %d/%d failed: %v, retrying in %v",
+			d.event, d.id, attempt, attempts, err, backoff)
+
+		select {
+		case <-s.stopCh:
+			return
+		case <-time.After(backoff):
+		}
+
+		s.mu.Lock()
+		s.retries++
+		s.mu.Unlock()
+
+		backoff *= 2
+		if backoff > maxRetryBackoff {
+			backoff = maxRetryBackoff
+		}
+	}
+
+	s.mu.Lock()
+	s.failed++
+	s.mu.Unlock()
+	log.Printf("Webhook %s event %s delivery to %s failed: %v", d.event, d.id, s.config.URL, err)
+}
+
+// post makes a single delivery attempt and reports whether a failure is worth retrying
+func (s *Sender) post(d *delivery, attempt int) (bool, error) {
+	req, err := http.NewRequest(http.MethodPost, s.config.URL, bytes.NewReader(d.body))
+	if err != nil {
+		return false, fmt.Errorf("failed to create webhook request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Stargate-Webhook/1.0")
+	req.Header.Set(HeaderEvent, d.event)
+	req.Header.Set(HeaderDelivery, d.id)
+	req.Header.Set(HeaderAttempt, strconv.Itoa(attempt))
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(d.timestamp.Unix(), 10))
+	if s.config.Secret != "" {
+		req.Header.Set(HeaderSignature, Sign(s.config.Secret, d.body))
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return true, nil
+	}
+
+	// Client errors other than throttling won't succeed on retry
+	retryable := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests ||
+		resp.StatusCode == http.StatusRequestTimeout
+	return retryable, fmt.Errorf("receiver returned status %d", resp.StatusCode)
+}
+
+// Sign returns the signature header value of body for secret
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether signature is a valid signature of body for secret
+func Verify(secret string, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, body)), []byte(signature))
+}
+
+// newDeliveryID generates a random delivery ID
+func newDeliveryID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 16)
+	}
+	return hex.EncodeToString(b)
+}
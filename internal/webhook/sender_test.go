@@ -0,0 +1,211 @@
+package webhook
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/songzhibin97/stargate/internal/config"
+)
+
+// receivedRequest is a webhook request seen by the test receiver
+type receivedRequest struct {
+	header http.Header
+	body   []byte
+}
+
+// testReceiver records requests and answers with the given status codes in turn
+type testReceiver struct {
+	mu       sync.Mutex
+	requests []receivedRequest
+	statuses []int
+	received chan struct{}
+}
+
+func newTestReceiver(statuses ...int) (*testReceiver, *httptest.Server) {
+	receiver := &testReceiver{statuses: statuses, received: make(chan struct{}, 100)}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		receiver.mu.Lock()
+		status := http.StatusOK
+		if n := len(receiver.requests); n < len(receiver.statuses) {
+			status = receiver.statuses[n]
+		}
+		receiver.requests = append(receiver.requests, receivedRequest{header: r.Header.Clone(), body: body})
+		receiver.mu.Unlock()
+
+		w.WriteHeader(status)
+		receiver.received <- struct{}{}
+	}))
+	return receiver, server
+}
+
+// wait waits for n requests to arrive
+func (r *testReceiver) wait(t *testing.T, n int) []receivedRequest {
+	for i := 0; i < n; i++ {
+		select {
+		case <-r.received:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Timed out waiting for webhook request %d", i+1)
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]receivedRequest{}, r.requests...)
+}
+
+func TestSender_SignedDelivery(t *testing.T) {
+	receiver, server := newTestReceiver()
+	defer server.Close()
+
+	sender := NewSender(&config.WebhookConfig{
+		Enabled: true,
+		URL:     server.URL,
+		Timeout: time.Second,
+		Secret:  "s3cret",
+	})
+	defer sender.Close()
+
+	payload := map[string]string{"upstream_id": "api", "new_status": "unhealthy"}
+	if err := sender.Send("health_status", payload); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+
+	requests := receiver.wait(t, 1)
+	req := requests[0]
+
+	if event := req.header.Get(HeaderEvent); event != "health_status" {
+		t.Errorf("Expected event health_status, got %q", event)
+	}
+	if req.header.Get(HeaderDelivery) == "" {
+		t.Error("Expected delivery ID header")
+	}
+	if contentType := req.header.Get("Content-Type"); contentType != "application/json" {
+		t.Errorf("Expected JSON content type, got %q", contentType)
+	}
+	if !Verify("s3cret", req.body, req.header.Get(HeaderSignature)) {
+		t.Errorf("Expected valid signature, got %q", req.header.Get(HeaderSignature))
+	}
+	if Verify("other", req.body, req.header.Get(HeaderSignature)) {
+		t.Error("Expected signature to fail with a different secret")
+	}
+
+	var received map[string]string
+	if err := json.Unmarshal(req.body, &received); err != nil {
+		t.Fatalf("Failed to decode payload: %v", err)
+	}
+	if received["upstream_id"] != "api" {
+		t.Errorf("Expected upstream_id api, got %q", received["upstream_id"])
+	}
+}
+
+func TestSender_RetryWithBackoff(t *testing.T) {
+	receiver, server := newTestReceiver(http.StatusServiceUnavailable, http.StatusInternalServerError)
+	defer server.Close()
+
+	sender := NewSender(&config.WebhookConfig{
+		Enabled:    true,
+		URL:        server.URL,
+		RetryCount: 2,
+	})
+	sender.retryBackoff = 10 * time.Millisecond
+	defer sender.Close()
+
+	sender.Send("health_status", map[string]string{"target": "10.0.0.1:80"})
+
+	requests := receiver.wait(t, 3)
+	if requests[0].header.Get(HeaderDelivery) != requests[2].header.Get(HeaderDelivery) {
+		t.Error("Expected retries to keep the delivery ID")
+	}
+	for i, req := range requests {
+		if attempt := req.header.Get(HeaderAttempt); attempt != strconv.Itoa(i+1) {
+			t.Errorf("Expected delivery attempt %d, got %q", i+1, attempt)
+		}
+	}
+
+	// Wait for the worker to record the outcome
+	time.Sleep(50 * time.Millisecond)
+	stats := sender.GetStats()
+	if stats["delivered"].(int64) != 1 {
+		t.Errorf("Expected 1 delivered event, got %v", stats["delivered"])
+	}
+	if stats["retries"].(int64) != 2 {
+		t.Errorf("Expected 2 retries, got %v", stats["retries"])
+	}
+}
+
+func TestSender_GivesUpAfterRetryCount(t *testing.T) {
+	receiver, server := newTestReceiver(
+		http.StatusBadGateway, http.StatusBadGateway, http.StatusBadGateway, http.StatusBadGateway)
+	defer server.Close()
+
+	sender := NewSender(&config.WebhookConfig{
+		Enabled:    true,
+		URL:        server.URL,
+		RetryCount: 1,
+	})
+	sender.retryBackoff = 10 * time.Millisecond
+	defer sender.Close()
+
+	sender.Send("health_status", map[string]string{})
+	receiver.wait(t, 2)
+
+	// No further attempts after RetryCount retries
+	select {
+	case <-receiver.received:
+		t.Error("Expected no more than RetryCount retries")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	stats := sender.GetStats()
+	if stats["failed"].(int64) != 1 {
+		t.Errorf("Expected 1 failed event, got %v", stats["failed"])
+	}
+}
+
+func TestSender_ClientErrorNotRetried(t *testing.T) {
+	receiver, server := newTestReceiver(http.StatusBadRequest)
+	defer server.Close()
+
+	sender := NewSender(&config.WebhookConfig{
+		Enabled:    true,
+		URL:        server.URL,
+		RetryCount: 3,
+	})
+	sender.retryBackoff = 10 * time.Millisecond
+	defer sender.Close()
+
+	sender.Send("health_status", map[string]string{})
+	receiver.wait(t, 1)
+
+	select {
+	case <-receiver.received:
+		t.Error("Expected client errors not to be retried")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestSender_Disabled(t *testing.T) {
+	receiver, server := newTestReceiver()
+	defer server.Close()
+
+	sender := NewSender(&config.WebhookConfig{Enabled: false, URL: server.URL})
+	defer sender.Close()
+
+	if err := sender.Send("health_status", map[string]string{}); err != nil {
+		t.Errorf("Expected disabled sender to ignore events, got %v", err)
+	}
+
+	select {
+	case <-receiver.received:
+		t.Error("Expected no request from disabled sender")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
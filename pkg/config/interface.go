@@ -41,6 +41,14 @@ type Source interface {
 	Close() error
 }
 
+// HealthReporter is implemented by sources that can lose their connection,
+// such as etcd, to report their state in the node's health
+type HealthReporter interface {
+	// Health returns the state of the source, with "status" being
+	// "healthy" or "degraded"
+	Health() map[string]interface{}
+}
+
 
 
 // Loader defines the interface for configuration loading
@@ -108,6 +108,10 @@
 //	gauge := factory.MustGauge("active_users", "Active users")
 //	histogram := factory.MustHistogram("latency_seconds", "Request latency")
 //
+//	// Measure latency without handling start times manually
+//	timer := factory.Timer("operation_duration_seconds", "Operation duration")
+//	defer timer.Start()()
+//
 // # Common Metrics
 //
 // The package provides pre-defined common metrics:
@@ -131,6 +135,10 @@
 //		Namespace: "myapp",
 //	})
 //
+// Tests can use the "memory" provider from internal/metrics/driver/memory,
+// which keeps values in maps and exposes accessors such as GetCounterValue
+// for assertions.
+//
 // # HTTP Handler
 //
 // Expose metrics via HTTP:
@@ -162,6 +170,9 @@
 // 5. Use appropriate metric types for your use case
 // 6. Consider using the convenient factory for simpler code
 // 7. Handle errors appropriately, especially during initialization
+// 8. Wrap providers with NewSanitizingProvider when label values may carry
+//    credentials or personal data; sensitive labels are emptied, hashed labels
+//    are replaced with a digest and values are truncated before reaching the backend
 //
 // # Thread Safety
 //
@@ -173,6 +184,8 @@
 // - Metric creation is more expensive than metric updates
 // - Create metrics once during initialization when possible
 // - Be mindful of label cardinality - each unique combination creates a new time series
+// - Set MetricOptions.MaxSeries on vectors whose label values come from clients; label
+//   combinations beyond the cap are folded into a single "__overflow__" series
 // - Use histograms for latency measurements, not summaries (unless you need quantiles)
 // - Consider using sampling for high-frequency metrics in performance-critical paths
 //
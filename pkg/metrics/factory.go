@@ -3,6 +3,7 @@ package metrics
 import (
 	"fmt"
 	"sync"
+	"time"
 )
 
 // Global registry for metric factories
@@ -71,11 +72,17 @@ func MustNewProvider(factoryName string, opts ProviderOptions) Provider {
 // ConvenientFactory provides convenient methods for creating common metrics
 type ConvenientFactory struct {
 	provider Provider
+
+	timersMu sync.Mutex
+	timers   map[string]*HistogramTimer
 }
 
 // NewConvenientFactory creates a new convenient factory
 func NewConvenientFactory(provider Provider) *ConvenientFactory {
-	return &ConvenientFactory{provider: provider}
+	return &ConvenientFactory{
+		provider: provider,
+		timers:   make(map[string]*HistogramTimer),
+	}
 }
 
 // Counter creates a counter with the given name and help
@@ -287,6 +294,59 @@ func (f *ConvenientFactory) MustSummaryVec(name, help string, labels []string) S
 	return summaryVec
 }
 
+// HistogramTimer measures operation latency into a histogram in seconds
+type HistogramTimer struct {
+	histogram Histogram
+}
+
+// Start starts measuring and returns a function that records the elapsed
+// time when called, typically deferred:
+//
+//	defer timer.Start()()
+func (t *HistogramTimer) Start() func() {
+	start := time.Now()
+	return func() {
+		t.histogram.Observe(time.Since(start).Seconds())
+	}
+}
+
+// ObserveDuration records the given duration
+func (t *HistogramTimer) ObserveDuration(d time.Duration) {
+	t.histogram.Observe(d.Seconds())
+}
+
+// Histogram returns the underlying histogram
+func (t *HistogramTimer) Histogram() Histogram {
+	return t.histogram
+}
+
+// Timer returns a timer recording into a duration histogram with the given
+// name and help, creating it on first use. It panics if the histogram cannot
+// be created, like the other Must* methods.
+func (f *ConvenientFactory) Timer(name, help string) *HistogramTimer {
+	f.timersMu.Lock()
+	defer f.timersMu.Unlock()
+
+	if timer, exists := f.timers[name]; exists {
+		return timer
+	}
+
+	histogram, err := f.HistogramWithBuckets(name, help, GetDefaultBuckets("duration"))
+	if err != nil {
+		panic(fmt.Sprintf("failed to create timer %s: %v", name, err))
+	}
+
+	timer := &HistogramTimer{histogram: histogram}
+	f.timers[name] = timer
+	return timer
+}
+
+// ObserveDuration records a duration into the timer with the given name,
+// creating the timer with a generic help text if it does not exist yet
+func (f *ConvenientFactory) ObserveDuration(name string, d time.Duration) {
+	f.Timer(name, fmt.Sprintf("Duration of %s in seconds", name)).ObserveDuration(d)
+}
+
 // CommonMetrics provides commonly used metrics
 type CommonMetrics struct {
 	// HTTP metrics
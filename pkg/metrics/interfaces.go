@@ -91,6 +91,10 @@ type CounterVec interface {
 	// DeleteLabelValues deletes the metric where the variable labels have the given values
 	DeleteLabelValues(lvs ...string) bool
 	
+	// DeletePartialMatch deletes all metrics whose labels contain the given
+	// labels and returns the number of metrics deleted
+	DeletePartialMatch(labels map[string]string) int
+	
 	// Reset deletes all metrics in this vector
 	Reset()
 }
@@ -115,6 +119,10 @@ type GaugeVec interface {
 	// DeleteLabelValues deletes the metric where the variable labels have the given values
 	DeleteLabelValues(lvs ...string) bool
 	
+	// DeletePartialMatch deletes all metrics whose labels contain the given
+	// labels and returns the number of metrics deleted
+	DeletePartialMatch(labels map[string]string) int
+	
 	// Reset deletes all metrics in this vector
 	Reset()
 }
@@ -139,6 +147,10 @@ type HistogramVec interface {
 	// DeleteLabelValues deletes the metric where the variable labels have the given values
 	DeleteLabelValues(lvs ...string) bool
 	
+	// DeletePartialMatch deletes all metrics whose labels contain the given
+	// labels and returns the number of metrics deleted
+	DeletePartialMatch(labels map[string]string) int
+	
 	// Reset deletes all metrics in this vector
 	Reset()
 }
@@ -163,6 +175,10 @@ type SummaryVec interface {
 	// DeleteLabelValues deletes the metric where the variable labels have the given values
 	DeleteLabelValues(lvs ...string) bool
 	
+	// DeletePartialMatch deletes all metrics whose labels contain the given
+	// labels and returns the number of metrics deleted
+	DeletePartialMatch(labels map[string]string) int
+	
 	// Reset deletes all metrics in this vector
 	Reset()
 }
@@ -29,6 +29,10 @@ type Provider interface {
 	Register(collector Collector) error
 	Unregister(collector Collector) error
 	
+	// UnregisterMetric removes a metric created by this provider, identified by
+	// the name it was created with, together with all of its series
+	UnregisterMetric(name string) error
+	
 	// Metrics collection
 	Gather() ([]*MetricFamily, error)
 	GatherWithOptions(opts *GatherOptions) ([]*MetricFamily, error)
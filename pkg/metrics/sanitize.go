@@ -0,0 +1,286 @@
+package metrics
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"unicode/utf8"
+)
+
+// hashedLabelLength is the number of hex characters kept from a hashed label value
+const hashedLabelLength = 16
+
+// SanitizeOptions configures the label sanitization applied by NewSanitizingProvider
+type SanitizeOptions struct {
+	// SensitiveLabels are labels whose values are replaced with an empty string
+	SensitiveLabels []string
+
+	// HashedLabels are labels whose values are replaced with a truncated
+	// SHA-256 digest, keeping them distinguishable without exposing the raw value
+	HashedLabels []string
+
+	// MaxLabelLength truncates label values longer than this many bytes,
+	// without splitting a multi-byte character. Zero disables truncation.
+	MaxLabelLength int
+}
+
+// labelSanitizer rewrites label values according to SanitizeOptions
+type labelSanitizer struct {
+	sensitive map[string]bool
+	hashed    map[string]bool
+	maxLength int
+}
+
+func newLabelSanitizer(opts SanitizeOptions) *labelSanitizer {
+	s := &labelSanitizer{
+		sensitive: make(map[string]bool, len(opts.SensitiveLabels)),
+		hashed:    make(map[string]bool, len(opts.HashedLabels)),
+		maxLength: opts.MaxLabelLength,
+	}
+	for _, name := range opts.SensitiveLabels {
+		s.sensitive[name] = true
+	}
+	for _, name := range opts.HashedLabels {
+		s.hashed[name] = true
+	}
+	return s
+}
+
+// value returns the sanitized value of the named label
+func (s *labelSanitizer) value(name, value string) string {
+	if s.sensitive[name] {
+		return ""
+	}
+	if s.hashed[name] && value != "" {
+		sum := sha256.Sum256([]byte(value))
+		value = hex.EncodeToString(sum[:])[:hashedLabelLength]
+	}
+	if s.maxLength > 0 && len(value) > s.maxLength {
+		// Cut at a rune boundary, backends reject invalid UTF-8
+		n := s.maxLength
+		for n > 0 && !utf8.RuneStart(value[n]) {
+			n--
+		}
+		value = value[:n]
+	}
+	return value
+}
+
+// labelValues sanitizes positional label values. Values beyond the known
+// label names are passed through so the backend reports the count mismatch.
+func (s *labelSanitizer) labelValues(names []string, lvs []string) []string {
+	sanitized := make([]string, len(lvs))
+	for i, v := range lvs {
+		if i < len(names) {
+			sanitized[i] = s.value(names[i], v)
+		} else {
+			sanitized[i] = v
+		}
+	}
+	return sanitized
+}
+
+// labels sanitizes a label map
+func (s *labelSanitizer) labels(labels map[string]string) map[string]string {
+	sanitized := make(map[string]string, len(labels))
+	for name, v := range labels {
+		sanitized[name] = s.value(name, v)
+	}
+	return sanitized
+}
+
+// sanitizingProvider decorates a Provider so that every labelled metric it
+// creates sanitizes label values before they reach the backend
+type sanitizingProvider struct {
+	Provider
+	sanitizer *labelSanitizer
+}
+
+// NewSanitizingProvider wraps provider so that label values passed to any
+// vector it creates are sanitized according to opts: sensitive labels are
+// emptied, hashed labels are replaced with a digest and all values are
+// truncated to MaxLabelLength. Const labels are not rewritten. If opts
+// requests no sanitization, provider is returned unchanged.
+func NewSanitizingProvider(provider Provider, opts SanitizeOptions) Provider {
+	if provider == nil {
+		return nil
+	}
+	if len(opts.SensitiveLabels) == 0 && len(opts.HashedLabels) == 0 && opts.MaxLabelLength <= 0 {
+		return provider
+	}
+	return &sanitizingProvider{
+		Provider:  provider,
+		sanitizer: newLabelSanitizer(opts),
+	}
+}
+
+func (p *sanitizingProvider) NewCounterVec(opts MetricOptions) (CounterVec, error) {
+	vec, err := p.Provider.NewCounterVec(opts)
+	if err != nil {
+		return nil, err
+	}
+	return &sanitizingCounterVec{CounterVec: vec, names: opts.Labels, sanitizer: p.sanitizer}, nil
+}
+
+func (p *sanitizingProvider) NewGaugeVec(opts MetricOptions) (GaugeVec, error) {
+	vec, err := p.Provider.NewGaugeVec(opts)
+	if err != nil {
+		return nil, err
+	}
+	return &sanitizingGaugeVec{GaugeVec: vec, names: opts.Labels, sanitizer: p.sanitizer}, nil
+}
+
+func (p *sanitizingProvider) NewHistogramVec(opts MetricOptions) (HistogramVec, error) {
+	vec, err := p.Provider.NewHistogramVec(opts)
+	if err != nil {
+		return nil, err
+	}
+	return &sanitizingHistogramVec{HistogramVec: vec, names: opts.Labels, sanitizer: p.sanitizer}, nil
+}
+
+func (p *sanitizingProvider) NewSummaryVec(opts MetricOptions) (SummaryVec, error) {
+	vec, err := p.Provider.NewSummaryVec(opts)
+	if err != nil {
+		return nil, err
+	}
+	return &sanitizingSummaryVec{SummaryVec: vec, names: opts.Labels, sanitizer: p.sanitizer}, nil
+}
+
+// sanitizingCounterVec sanitizes label values of a CounterVec
+type sanitizingCounterVec struct {
+	CounterVec
+	names     []string
+	sanitizer *labelSanitizer
+}
+
+func (cv *sanitizingCounterVec) WithLabelValues(lvs ...string) Counter {
+	return cv.CounterVec.WithLabelValues(cv.sanitizer.labelValues(cv.names, lvs)...)
+}
+
+func (cv *sanitizingCounterVec) With(labels map[string]string) Counter {
+	return cv.CounterVec.With(cv.sanitizer.labels(labels))
+}
+
+func (cv *sanitizingCounterVec) GetMetricWithLabelValues(lvs ...string) (Counter, error) {
+	return cv.CounterVec.GetMetricWithLabelValues(cv.sanitizer.labelValues(cv.names, lvs)...)
+}
+
+func (cv *sanitizingCounterVec) GetMetricWith(labels map[string]string) (Counter, error) {
+	return cv.CounterVec.GetMetricWith(cv.sanitizer.labels(labels))
+}
+
+func (cv *sanitizingCounterVec) Delete(labels map[string]string) bool {
+	return cv.CounterVec.Delete(cv.sanitizer.labels(labels))
+}
+
+func (cv *sanitizingCounterVec) DeleteLabelValues(lvs ...string) bool {
+	return cv.CounterVec.DeleteLabelValues(cv.sanitizer.labelValues(cv.names, lvs)...)
+}
+
+func (cv *sanitizingCounterVec) DeletePartialMatch(labels map[string]string) int {
+	return cv.CounterVec.DeletePartialMatch(cv.sanitizer.labels(labels))
+}
+
+// sanitizingGaugeVec sanitizes label values of a GaugeVec
+type sanitizingGaugeVec struct {
+	GaugeVec
+	names     []string
+	sanitizer *labelSanitizer
+}
+
+func (gv *sanitizingGaugeVec) WithLabelValues(lvs ...string) Gauge {
+	return gv.GaugeVec.WithLabelValues(gv.sanitizer.labelValues(gv.names, lvs)...)
+}
+
+func (gv *sanitizingGaugeVec) With(labels map[string]string) Gauge {
+	return gv.GaugeVec.With(gv.sanitizer.labels(labels))
+}
+
+func (gv *sanitizingGaugeVec) GetMetricWithLabelValues(lvs ...string) (Gauge, error) {
+	return gv.GaugeVec.GetMetricWithLabelValues(gv.sanitizer.labelValues(gv.names, lvs)...)
+}
+
+func (gv *sanitizingGaugeVec) GetMetricWith(labels map[string]string) (Gauge, error) {
+	return gv.GaugeVec.GetMetricWith(gv.sanitizer.labels(labels))
+}
+
+func (gv *sanitizingGaugeVec) Delete(labels map[string]string) bool {
+	return gv.GaugeVec.Delete(gv.sanitizer.labels(labels))
+}
+
+func (gv *sanitizingGaugeVec) DeleteLabelValues(lvs ...string) bool {
+	return gv.GaugeVec.DeleteLabelValues(gv.sanitizer.labelValues(gv.names, lvs)...)
+}
+
+func (gv *sanitizingGaugeVec) DeletePartialMatch(labels map[string]string) int {
+	return gv.GaugeVec.DeletePartialMatch(gv.sanitizer.labels(labels))
+}
+
+// sanitizingHistogramVec sanitizes label values of a HistogramVec
+type sanitizingHistogramVec struct {
+	HistogramVec
+	names     []string
+	sanitizer *labelSanitizer
+}
+
+func (hv *sanitizingHistogramVec) WithLabelValues(lvs ...string) Histogram {
+	return hv.HistogramVec.WithLabelValues(hv.sanitizer.labelValues(hv.names, lvs)...)
+}
+
+func (hv *sanitizingHistogramVec) With(labels map[string]string) Histogram {
+	return hv.HistogramVec.With(hv.sanitizer.labels(labels))
+}
+
+func (hv *sanitizingHistogramVec) GetMetricWithLabelValues(lvs ...string) (Histogram, error) {
+	return hv.HistogramVec.GetMetricWithLabelValues(hv.sanitizer.labelValues(hv.names, lvs)...)
+}
+
+func (hv *sanitizingHistogramVec) GetMetricWith(labels map[string]string) (Histogram, error) {
+	return hv.HistogramVec.GetMetricWith(hv.sanitizer.labels(labels))
+}
+
+func (hv *sanitizingHistogramVec) Delete(labels map[string]string) bool {
+	return hv.HistogramVec.Delete(hv.sanitizer.labels(labels))
+}
+
+func (hv *sanitizingHistogramVec) DeleteLabelValues(lvs ...string) bool {
+	return hv.HistogramVec.DeleteLabelValues(hv.sanitizer.labelValues(hv.names, lvs)...)
+}
+
+func (hv *sanitizingHistogramVec) DeletePartialMatch(labels map[string]string) int {
+	return hv.HistogramVec.DeletePartialMatch(hv.sanitizer.labels(labels))
+}
+
+// sanitizingSummaryVec sanitizes label values of a SummaryVec
+type sanitizingSummaryVec struct {
+	SummaryVec
+	names     []string
+	sanitizer *labelSanitizer
+}
+
+func (sv *sanitizingSummaryVec) WithLabelValues(lvs ...string) Summary {
+	return sv.SummaryVec.WithLabelValues(sv.sanitizer.labelValues(sv.names, lvs)...)
+}
+
+func (sv *sanitizingSummaryVec) With(labels map[string]string) Summary {
+	return sv.SummaryVec.With(sv.sanitizer.labels(labels))
+}
+
+func (sv *sanitizingSummaryVec) GetMetricWithLabelValues(lvs ...string) (Summary, error) {
+	return sv.SummaryVec.GetMetricWithLabelValues(sv.sanitizer.labelValues(sv.names, lvs)...)
+}
+
+func (sv *sanitizingSummaryVec) GetMetricWith(labels map[string]string) (Summary, error) {
+	return sv.SummaryVec.GetMetricWith(sv.sanitizer.labels(labels))
+}
+
+func (sv *sanitizingSummaryVec) Delete(labels map[string]string) bool {
+	return sv.SummaryVec.Delete(sv.sanitizer.labels(labels))
+}
+
+func (sv *sanitizingSummaryVec) DeleteLabelValues(lvs ...string) bool {
+	return sv.SummaryVec.DeleteLabelValues(sv.sanitizer.labelValues(sv.names, lvs)...)
+}
+
+func (sv *sanitizingSummaryVec) DeletePartialMatch(labels map[string]string) int {
+	return sv.SummaryVec.DeletePartialMatch(sv.sanitizer.labels(labels))
+}
@@ -86,6 +86,7 @@ type MetricOptions struct {
 	MaxAge      time.Duration     `json:"max_age,omitempty"`     // For summaries
 	AgeBuckets  uint32            `json:"age_buckets,omitempty"` // For summaries
 	BufCap      uint32            `json:"buf_cap,omitempty"`     // For summaries
+	MaxSeries   int               `json:"max_series,omitempty"`  // For counter and gauge vectors, 0 means unlimited
 }
 
 // ProviderOptions represents options for creating a metrics provider
@@ -0,0 +1,206 @@
+package mq
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// DefaultMaxDecompressedSize bounds the size of decompressed payloads when
+// ConsumerConfig.MaxDecompressedSize isn't set
+const DefaultMaxDecompressedSize = 64 << 20
+
+// NewCompressor creates a compressor of a compression type at a level, 0
+// for the default level of the algorithm, decompressing payloads up to
+// DefaultMaxDecompressedSize. No compression returns a compressor passing
+// data through.
+func NewCompressor(compression CompressionType, level int) (Compressor, error) {
+	return NewCompressorWithLimit(compression, level, 0)
+}
+
+// NewCompressorWithLimit creates a compressor like NewCompressor, failing to
+// decompress payloads larger than maxDecompressedSize bytes,
+// DefaultMaxDecompressedSize when zero, so a small compressed message can't
+// exhaust the memory of the consumer
+func NewCompressorWithLimit(compression CompressionType, level int, maxDecompressedSize int64) (Compressor, error) {
+	if maxDecompressedSize <= 0 {
+		maxDecompressedSize = DefaultMaxDecompressedSize
+	}
+	switch compression {
+	case "", CompressionNone:
+		return noneCompressor{}, nil
+	case CompressionGzip:
+		return newGzipCompressor(level, maxDecompressedSize)
+	case CompressionZstd:
+		return newZstdCompressor(level, maxDecompressedSize)
+	default:
+		err := NewConfigurationError("UNSUPPORTED_COMPRESSION", fmt.Sprintf("unsupported compression: %s", compression))
+		err.Cause = ErrInvalidConfig
+		return nil, err
+	}
+}
+
+// NewProducerCompressor creates the compressor selected by a producer
+// configuration, at the level of the "compression_level" option
+func NewProducerCompressor(config *ProducerConfig) (Compressor, error) {
+	level := 0
+	switch value := config.Options["compression_level"].(type) {
+	case nil:
+	case int:
+		level = value
+	case float64:
+		level = int(value)
+	case string:
+		var err error
+		if level, err = strconv.Atoi(value); err != nil {
+			return nil, NewConfigurationError("INVALID_COMPRESSION_LEVEL", fmt.Sprintf("invalid compression level: %s", value))
+		}
+	default:
+		return nil, NewConfigurationError("INVALID_COMPRESSION_LEVEL", fmt.Sprintf("invalid compression level: %v", value))
+	}
+	return NewCompressor(config.Compression, level)
+}
+
+// noneCompressor passes data through
+type noneCompressor struct{}
+
+func (noneCompressor) Compress(data []byte) ([]byte, error)   { return data, nil }
+func (noneCompressor) Decompress(data []byte) ([]byte, error) { return data, nil }
+func (noneCompressor) Type() CompressionType                  { return CompressionNone }
+
+// GzipCompressor compresses messages with gzip
+type GzipCompressor struct {
+	level   int
+	maxSize int64 // Maximum decompressed size
+}
+
+// NewGzipCompressor creates a gzip compressor at a level from 1 (fastest)
+// to 9 (best compression), 0 for the default level
+func NewGzipCompressor(level int) (Compressor, error) {
+	return newGzipCompressor(level, DefaultMaxDecompressedSize)
+}
+
+func newGzipCompressor(level int, maxSize int64) (Compressor, error) {
+	if level == 0 {
+		level = gzip.DefaultCompression
+	} else if level < gzip.BestSpeed || level > gzip.BestCompression {
+		return nil, NewConfigurationError("INVALID_COMPRESSION_LEVEL", fmt.Sprintf("gzip compression level must be between %d and %d, got %d", gzip.BestSpeed, gzip.BestCompression, level))
+	}
+	return &GzipCompressor{level: level, maxSize: maxSize}, nil
+}
+
+// Compress compresses data with gzip
+func (c *GzipCompressor) Compress(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w, err := gzip.NewWriterLevel(&buf, c.level)
+	if err != nil {
+		return nil, NewSerializationError("COMPRESSION_FAILED", "gzip compression failed", ErrCompressionFailed)
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, NewSerializationError("COMPRESSION_FAILED", "gzip compression failed", ErrCompressionFailed)
+	}
+	if err := w.Close(); err != nil {
+		return nil, NewSerializationError("COMPRESSION_FAILED", "gzip compression failed", ErrCompressionFailed)
+	}
+	return buf.Bytes(), nil
+}
+
+// Decompress decompresses gzip data
+func (c *GzipCompressor) Decompress(data []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, NewSerializationError("DECOMPRESSION_FAILED", fmt.Sprintf("gzip decompression failed: %v", err), ErrDecompressionFailed)
+	}
+	defer r.Close()
+	decompressed, err := io.ReadAll(io.LimitReader(r, c.maxSize+1))
+	if err != nil {
+		return nil, NewSerializationError("DECOMPRESSION_FAILED", fmt.Sprintf("gzip decompression failed: %v", err), ErrDecompressionFailed)
+	}
+	if int64(len(decompressed)) > c.maxSize {
+		return nil, decompressedTooLarge(c.maxSize)
+	}
+	return decompressed, nil
+}
+
+// Type returns CompressionGzip
+func (c *GzipCompressor) Type() CompressionType {
+	return CompressionGzip
+}
+
+// ZstdCompressor compresses messages with Zstandard, which compresses JSON
+// payloads such as API usage events better and faster than gzip. It is safe
+// for concurrent use.
+type ZstdCompressor struct {
+	encoder *zstd.Encoder
+	decoder *zstd.Decoder
+	maxSize int64 // Maximum decompressed size
+}
+
+// NewZstdCompressor creates a zstd compressor at a level from 1 (fastest)
+// to 22 (best compression) following the zstd command line levels, 0 for
+// the default level 3. The levels map to the four speeds of the encoder, so
+// levels within the same speed compress alike.
+func NewZstdCompressor(level int) (Compressor, error) {
+	return newZstdCompressor(level, DefaultMaxDecompressedSize)
+}
+
+func newZstdCompressor(level int, maxSize int64) (Compressor, error) {
+	encoderLevel := zstd.SpeedDefault
+	if level != 0 {
+		if level < 1 || level > 22 {
+			return nil, NewConfigurationError("INVALID_COMPRESSION_LEVEL", fmt.Sprintf("zstd compression level must be between 1 and 22, got %d", level))
+		}
+		encoderLevel = zstd.EncoderLevelFromZstd(level)
+	}
+
+	encoder, err := zstd.NewWriter(nil, zstd.WithEncoderLevel(encoderLevel))
+	if err != nil {
+		return nil, NewInternalError("COMPRESSOR_INIT_FAILED", "failed to create zstd encoder", err)
+	}
+	// The window can't exceed the output, and is bounded by the decoder
+	window := maxSize
+	if window < zstd.MinWindowSize {
+		window = zstd.MinWindowSize
+	} else if window > zstd.MaxWindowSize {
+		window = zstd.MaxWindowSize
+	}
+	decoder, err := zstd.NewReader(nil, zstd.WithDecoderMaxMemory(uint64(maxSize)), zstd.WithDecoderMaxWindow(uint64(window)))
+	if err != nil {
+		encoder.Close()
+		return nil, NewInternalError("COMPRESSOR_INIT_FAILED", "failed to create zstd decoder", err)
+	}
+	return &ZstdCompressor{encoder: encoder, decoder: decoder, maxSize: maxSize}, nil
+}
+
+// Compress compresses data into a zstd frame
+func (c *ZstdCompressor) Compress(data []byte) ([]byte, error) {
+	return c.encoder.EncodeAll(data, make([]byte, 0, len(data)/2)), nil
+}
+
+// Decompress decompresses zstd frames
+func (c *ZstdCompressor) Decompress(data []byte) ([]byte, error) {
+	decompressed, err := c.decoder.DecodeAll(data, nil)
+	if errors.Is(err, zstd.ErrDecoderSizeExceeded) || errors.Is(err, zstd.ErrWindowSizeExceeded) {
+		return nil, decompressedTooLarge(c.maxSize)
+	}
+	if err != nil {
+		return nil, NewSerializationError("DECOMPRESSION_FAILED", fmt.Sprintf("zstd decompression failed: %v", err), ErrDecompressionFailed)
+	}
+	return decompressed, nil
+}
+
+// Type returns CompressionZstd
+func (c *ZstdCompressor) Type() CompressionType {
+	return CompressionZstd
+}
+
+// decompressedTooLarge returns the error of a payload decompressing beyond
+// the maximum size
+func decompressedTooLarge(maxSize int64) error {
+	return NewSerializationError("DECOMPRESSED_TOO_LARGE", fmt.Sprintf("decompressed payload exceeds %d bytes", maxSize), ErrDecompressionFailed)
+}
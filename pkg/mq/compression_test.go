@@ -0,0 +1,191 @@
+package mq
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// usageEvents returns the JSON of API usage events, the payloads zstd is
+// meant for
+func usageEvents(n int) []byte {
+	events := make([]*APIUsageEvent, n)
+	for i := range events {
+		events[i] = &APIUsageEvent{
+			RequestID:     fmt.Sprintf("req-%08d", i),
+			ApplicationID: fmt.Sprintf("app-%d", i%17),
+			UserID:        fmt.Sprintf("user-%d", i%101),
+			Method:        []string{"GET", "POST", "PUT", "DELETE"}[i%4],
+			Path:          fmt.Sprintf("/api/v1/orders/%d/items", i%1000),
+			StatusCode:    []int{200, 201, 404, 500}[i%4],
+			ResponseTime:  int64(i % 250),
+			RequestSize:   int64(i % 4096),
+			ResponseSize:  int64(i % 16384),
+			Timestamp:     time.Date(2024, 1, 1, 0, 0, i, 0, time.UTC),
+			ClientIP:      fmt.Sprintf("10.0.%d.%d", i%256, i%200),
+			UserAgent:     "stargate-client/1.0",
+		}
+	}
+	payload, _ := json.Marshal(events)
+	return payload
+}
+
+func TestZstdCompressor_RoundTrip(t *testing.T) {
+	message := NewMessageBuilder().WithTopic("api.usage").WithPayload(usageEvents(10000)).Build()
+
+	for _, level := range []int{0, 1, 3, 9, 22} {
+		t.Run(fmt.Sprintf("level %d", level), func(t *testing.T) {
+			c, err := NewZstdCompressor(level)
+			if err != nil {
+				t.Fatalf("NewZstdCompressor failed: %v", err)
+			}
+
+			compressed, err := c.Compress(message.Payload)
+			if err != nil {
+				t.Fatalf("Compress failed: %v", err)
+			}
+			if len(compressed) >= len(message.Payload)/5 {
+				t.Errorf("Expected a 5x smaller payload, got %d bytes from %d", len(compressed), len(message.Payload))
+			}
+
+			decompressed, err := c.Decompress(compressed)
+			if err != nil {
+				t.Fatalf("Decompress failed: %v", err)
+			}
+			if !bytes.Equal(decompressed, message.Payload) {
+				t.Errorf("Payload changed in the round trip")
+			}
+		})
+	}
+}
+
+func TestZstdCompressor_Errors(t *testing.T) {
+	for _, level := range []int{-1, 23} {
+		if _, err := NewZstdCompressor(level); !IsConfigurationError(err) {
+			t.Errorf("Expected configuration error for level %d, got %v", level, err)
+		}
+	}
+
+	c, err := NewZstdCompressor(0)
+	if err != nil {
+		t.Fatalf("NewZstdCompressor failed: %v", err)
+	}
+	if _, err := c.Decompress([]byte("not zstd")); !errors.Is(err, ErrDecompressionFailed) {
+		t.Errorf("Expected ErrDecompressionFailed, got %v", err)
+	}
+
+	empty, err := c.Compress(nil)
+	if err != nil {
+		t.Fatalf("Compress failed: %v", err)
+	}
+	if decompressed, err := c.Decompress(empty); err != nil || len(decompressed) != 0 {
+		t.Errorf("Expected an empty payload, got %q, %v", decompressed, err)
+	}
+}
+
+func TestCompressor_MaxDecompressedSize(t *testing.T) {
+	// A bomb: 8 MiB of zeros compress to a few KiB
+	bomb := make([]byte, 8<<20)
+
+	for _, compression := range []CompressionType{CompressionGzip, CompressionZstd} {
+		t.Run(string(compression), func(t *testing.T) {
+			c, err := NewCompressorWithLimit(compression, 0, 1<<20)
+			if err != nil {
+				t.Fatalf("NewCompressorWithLimit failed: %v", err)
+			}
+			compressed, err := c.Compress(bomb)
+			if err != nil {
+				t.Fatalf("Compress failed: %v", err)
+			}
+
+			_, err = c.Decompress(compressed)
+			if !IsSerializationError(err) || !errors.Is(err, ErrDecompressionFailed) {
+				t.Errorf("Expected decompression failure beyond the limit, got %v", err)
+			}
+
+			// Within the limit
+			compressed, _ = c.Compress(bomb[:1<<20])
+			if decompressed, err := c.Decompress(compressed); err != nil || len(decompressed) != 1<<20 {
+				t.Errorf("Expected %d bytes at the limit, got %d: %v", 1<<20, len(decompressed), err)
+			}
+		})
+	}
+}
+
+func TestNewProducerCompressor(t *testing.T) {
+	tests := []struct {
+		compression CompressionType
+		level       interface{}
+		want        CompressionType
+		wantErr     bool
+	}{
+		{compression: "", want: CompressionNone},
+		{compression: CompressionGzip, level: 9, want: CompressionGzip},
+		{compression: CompressionZstd, want: CompressionZstd},
+		{compression: CompressionZstd, level: "19", want: CompressionZstd},
+		{compression: CompressionZstd, level: float64(1), want: CompressionZstd},
+		{compression: CompressionZstd, level: 30, wantErr: true},
+		{compression: CompressionGzip, level: "best", wantErr: true},
+		{compression: CompressionLZ4, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		config := &ProducerConfig{Compression: tt.compression, Options: map[string]interface{}{}}
+		if tt.level != nil {
+			config.Options["compression_level"] = tt.level
+		}
+		c, err := NewProducerCompressor(config)
+		if tt.wantErr {
+			if !IsConfigurationError(err) {
+				t.Errorf("%s level %v: expected configuration error, got %v", tt.compression, tt.level, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s level %v: unexpected error: %v", tt.compression, tt.level, err)
+			continue
+		}
+		if c.Type() != tt.want {
+			t.Errorf("%s level %v: expected %s compressor, got %s", tt.compression, tt.level, tt.want, c.Type())
+		}
+	}
+}
+
+// BenchmarkCompress compares zstd with gzip on API usage events, reporting
+// the compressed size as a percentage of the original
+func BenchmarkCompress(b *testing.B) {
+	payload := usageEvents(10000)
+
+	benchmarks := []struct {
+		compression CompressionType
+		level       int
+	}{
+		{CompressionGzip, 1},
+		{CompressionGzip, 0},
+		{CompressionGzip, 9},
+		{CompressionZstd, 1},
+		{CompressionZstd, 0},
+		{CompressionZstd, 19},
+	}
+	for _, bm := range benchmarks {
+		b.Run(fmt.Sprintf("%s level %d", bm.compression, bm.level), func(b *testing.B) {
+			c, err := NewCompressor(bm.compression, bm.level)
+			if err != nil {
+				b.Fatalf("NewCompressor failed: %v", err)
+			}
+			b.SetBytes(int64(len(payload)))
+			b.ResetTimer()
+
+			var compressed []byte
+			for i := 0; i < b.N; i++ {
+				if compressed, err = c.Compress(payload); err != nil {
+					b.Fatalf("Compress failed: %v", err)
+				}
+			}
+			b.ReportMetric(100*float64(len(compressed))/float64(len(payload)), "%size")
+		})
+	}
+}
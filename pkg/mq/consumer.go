@@ -34,11 +34,13 @@ type Consumer interface {
 	// Seek seeks to a specific offset for a topic partition
 	Seek(ctx context.Context, topic string, partition int32, offset int64) error
 	
-	// Pause pauses consumption from specified topics
-	Pause(topics []string) error
+	// Pause stops delivering messages of the specified topics, all subscribed topics
+	// when none are, to handlers. Subscriptions and group assignments are kept.
+	Pause(topics ...string) error
 	
-	// Resume resumes consumption from specified topics
-	Resume(topics []string) error
+	// Resume resumes delivering messages of the specified topics, all subscribed
+	// topics when none are
+	Resume(topics ...string) error
 	
 	// Close closes the consumer and releases resources
 	Close() error
@@ -81,6 +83,15 @@ type SubscribeOptions struct {
 	
 	// BatchTimeout for batch processing
 	BatchTimeout time.Duration
+	
+	// DeduplicationWindow skips messages whose deduplication key was
+	// processed within the window, committing them without calling the
+	// handler. Zero disables deduplication.
+	DeduplicationWindow time.Duration
+	
+	// DeduplicationKey returns the deduplication key of a message, by
+	// default Message.Key. Messages with an empty key are never skipped.
+	DeduplicationKey func(*Message) string
 }
 
 // ConsumerMetrics contains metrics for a consumer
@@ -94,6 +105,9 @@ type ConsumerMetrics struct {
 	// Total processing errors
 	ProcessingErrors int64 `json:"processing_errors"`
 	
+	// Duplicate messages skipped by deduplication
+	DuplicatesSkipped int64 `json:"duplicates_skipped"`
+	
 	// Average processing latency in milliseconds
 	AvgProcessingLatency float64 `json:"avg_processing_latency"`
 	
@@ -106,6 +120,9 @@ type ConsumerMetrics struct {
 	// Subscribed topics
 	SubscribedTopics []string `json:"subscribed_topics"`
 	
+	// Paused reports whether each subscribed topic is paused
+	Paused map[string]bool `json:"paused"`
+	
 	// Last error
 	LastError string `json:"last_error,omitempty"`
 	
@@ -0,0 +1,124 @@
+package mq
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// DefaultDeduplicationCacheSize bounds the keys a Deduplicator remembers
+const DefaultDeduplicationCacheSize = 100000
+
+// Deduplicator remembers the keys of processed messages for a window, so
+// consumers can skip the duplicates redelivered after rebalances or
+// reconnections. When the cache is full the least recently processed keys
+// are forgotten first.
+type Deduplicator struct {
+	window time.Duration
+	key    func(*Message) string
+	size   int
+	now    func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List // Of *dedupEntry, most recently processed first
+}
+
+// dedupEntry is a key processed at a time
+type dedupEntry struct {
+	key       string
+	processed time.Time
+}
+
+// NewDeduplicator creates a deduplicator remembering keys for a window, of
+// the keys returned by a function, by default Message.Key, and up to size
+// keys, DefaultDeduplicationCacheSize when not positive
+func NewDeduplicator(window time.Duration, key func(*Message) string, size int) *Deduplicator {
+	if key == nil {
+		key = func(message *Message) string { return message.Key }
+	}
+	if size <= 0 {
+		size = DefaultDeduplicationCacheSize
+	}
+	return &Deduplicator{
+		window:  window,
+		key:     key,
+		size:    size,
+		now:     time.Now,
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+	}
+}
+
+// NewSubscribeDeduplicator creates the deduplicator of subscribe options,
+// nil when they don't enable deduplication
+func NewSubscribeDeduplicator(opts *SubscribeOptions) *Deduplicator {
+	if opts == nil || opts.DeduplicationWindow <= 0 {
+		return nil
+	}
+	return NewDeduplicator(opts.DeduplicationWindow, opts.DeduplicationKey, 0)
+}
+
+// IsDuplicate reports whether the key of a message was processed within
+// the window
+func (d *Deduplicator) IsDuplicate(message *Message) bool {
+	key := d.key(message)
+	if key == "" {
+		return false
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.expire()
+	_, ok := d.entries[key]
+	return ok
+}
+
+// MarkProcessed records the key of a processed message, starting its
+// window again
+func (d *Deduplicator) MarkProcessed(message *Message) {
+	key := d.key(message)
+	if key == "" {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	entry := &dedupEntry{key: key, processed: d.now()}
+	if element, ok := d.entries[key]; ok {
+		element.Value = entry
+		d.order.MoveToFront(element)
+	} else {
+		d.entries[key] = d.order.PushFront(entry)
+	}
+	for d.order.Len() > d.size {
+		d.remove(d.order.Back())
+	}
+	d.expire()
+}
+
+// Len returns the number of keys remembered
+func (d *Deduplicator) Len() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.expire()
+	return d.order.Len()
+}
+
+// expire forgets the keys processed before the window. Keys are ordered by
+// processing time, so they are the last ones.
+func (d *Deduplicator) expire() {
+	cutoff := d.now().Add(-d.window)
+	for element := d.order.Back(); element != nil; element = d.order.Back() {
+		if element.Value.(*dedupEntry).processed.After(cutoff) {
+			return
+		}
+		d.remove(element)
+	}
+}
+
+// remove forgets the key of a list element
+func (d *Deduplicator) remove(element *list.Element) {
+	d.order.Remove(element)
+	delete(d.entries, element.Value.(*dedupEntry).key)
+}
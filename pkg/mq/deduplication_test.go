@@ -0,0 +1,78 @@
+package mq
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestDeduplicator_Window(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	d := NewDeduplicator(time.Minute, nil, 0)
+	d.now = func() time.Time { return now }
+
+	message := NewMessageBuilder().WithKey("req-1").Build()
+	if d.IsDuplicate(message) {
+		t.Fatal("Expected an unprocessed message not to be a duplicate")
+	}
+	d.MarkProcessed(message)
+	if !d.IsDuplicate(message) {
+		t.Error("Expected a processed message to be a duplicate")
+	}
+	if d.IsDuplicate(NewMessageBuilder().WithKey("req-2").Build()) {
+		t.Error("Expected another key not to be a duplicate")
+	}
+	if d.IsDuplicate(NewMessageBuilder().Build()) {
+		t.Error("Expected a message without key not to be a duplicate")
+	}
+
+	now = now.Add(59 * time.Second)
+	if !d.IsDuplicate(message) {
+		t.Error("Expected a duplicate within the window")
+	}
+	now = now.Add(time.Second)
+	if d.IsDuplicate(message) {
+		t.Error("Expected no duplicate after the window")
+	}
+	if d.Len() != 0 {
+		t.Errorf("Expected expired keys to be forgotten, got %d", d.Len())
+	}
+}
+
+func TestDeduplicator_Size(t *testing.T) {
+	d := NewDeduplicator(time.Hour, nil, 3)
+	for i := 0; i < 5; i++ {
+		d.MarkProcessed(NewMessageBuilder().WithKey(fmt.Sprintf("req-%d", i)).Build())
+	}
+	// Processing a key again makes it the most recent
+	d.MarkProcessed(NewMessageBuilder().WithKey("req-2").Build())
+	d.MarkProcessed(NewMessageBuilder().WithKey("req-5").Build())
+
+	if d.Len() != 3 {
+		t.Errorf("Expected 3 keys, got %d", d.Len())
+	}
+	for i, want := range []bool{false, false, true, false, true, true} {
+		if got := d.IsDuplicate(NewMessageBuilder().WithKey(fmt.Sprintf("req-%d", i)).Build()); got != want {
+			t.Errorf("req-%d: expected duplicate %v, got %v", i, want, got)
+		}
+	}
+}
+
+func TestNewSubscribeDeduplicator(t *testing.T) {
+	if NewSubscribeDeduplicator(nil) != nil || NewSubscribeDeduplicator(&SubscribeOptions{}) != nil {
+		t.Error("Expected no deduplicator without a window")
+	}
+
+	d := NewSubscribeDeduplicator(&SubscribeOptions{
+		DeduplicationWindow: time.Minute,
+		DeduplicationKey:    func(message *Message) string { return message.Headers["request_id"] },
+	})
+	message := NewMessageBuilder().WithKey("app-1").WithHeader("request_id", "req-1").Build()
+	d.MarkProcessed(message)
+	if !d.IsDuplicate(NewMessageBuilder().WithKey("app-2").WithHeader("request_id", "req-1").Build()) {
+		t.Error("Expected the custom key to detect the duplicate")
+	}
+	if d.IsDuplicate(NewMessageBuilder().WithKey("app-1").WithHeader("request_id", "req-2").Build()) {
+		t.Error("Expected Message.Key not to be used with a custom key")
+	}
+}
@@ -0,0 +1,307 @@
+package mq
+
+import (
+	"container/heap"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DefaultMaxDelay bounds delayed publishing when ProducerConfig.MaxDelay
+// isn't set
+const DefaultMaxDelay = 24 * time.Hour
+
+// PublishFunc publishes a message to a topic, like Producer.Publish
+type PublishFunc func(ctx context.Context, topic string, message *Message) error
+
+// DelayErrorHandler is called with a delayed message that failed to publish
+// when due, and the error
+type DelayErrorHandler func(topic string, message *Message, err error)
+
+// DelayScheduler publishes messages after a delay for backends without
+// native delayed delivery. Messages wait in memory, in a queue served by a
+// goroutine, so:
+//   - they are lost when the scheduler is closed or the process exits
+//     before they are due
+//   - messages due at the same time are published in the order they were
+//     scheduled, but delayed messages are published independently from the
+//     ones published directly, which can overtake them
+//   - a message is published once it is due, later when the publishing of
+//     the previous ones takes time
+//   - a message failing to publish isn't retried; it is counted and passed
+//     to the error handler
+type DelayScheduler struct {
+	publish  PublishFunc
+	maxDelay time.Duration
+
+	mu           sync.Mutex
+	queue        delayQueue
+	seq          uint64
+	wake         chan struct{} // Signaled when the first message changes
+	closed       bool
+	failed       int64 // Messages that failed to publish when due
+	errorHandler DelayErrorHandler
+
+	ctx    context.Context // Canceled when the scheduler is closed
+	cancel context.CancelFunc
+	done   chan struct{} // Closed when the goroutine exits
+}
+
+// NewDelayScheduler creates a scheduler publishing messages with a
+// function, rejecting delays beyond maxDelay, DefaultMaxDelay when zero
+func NewDelayScheduler(publish PublishFunc, maxDelay time.Duration) *DelayScheduler {
+	if maxDelay <= 0 {
+		maxDelay = DefaultMaxDelay
+	}
+	s := &DelayScheduler{
+		publish:  publish,
+		maxDelay: maxDelay,
+		wake:     make(chan struct{}, 1),
+		done:     make(chan struct{}),
+	}
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	go s.run()
+	return s
+}
+
+// ValidateDelay returns a configuration error when a delay is negative or
+// exceeds the maximum delay
+func ValidateDelay(delay, maxDelay time.Duration) error {
+	if delay < 0 {
+		err := NewConfigurationError("INVALID_DELAY", fmt.Sprintf("delay cannot be negative: %s", delay))
+		err.Cause = ErrInvalidConfig
+		return err
+	}
+	if delay > maxDelay {
+		err := NewConfigurationError("DELAY_TOO_LONG", fmt.Sprintf("delay %s exceeds the maximum delay %s", delay, maxDelay))
+		err.Cause = ErrInvalidConfig
+		return err
+	}
+	return nil
+}
+
+// SetErrorHandler sets the handler called with the delayed messages that
+// fail to publish. It is called from the goroutine of the scheduler, so it
+// delays the following messages until it returns.
+func (s *DelayScheduler) SetErrorHandler(handler DelayErrorHandler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.errorHandler = handler
+}
+
+// Schedule publishes a message to a topic after a delay. A message without
+// delay is published before Schedule returns, with the context; delayed
+// ones are copied and published in the background, their errors counted by
+// Failed and passed to the error handler.
+func (s *DelayScheduler) Schedule(ctx context.Context, topic string, message *Message, delay time.Duration) error {
+	if err := ValidateDelay(delay, s.maxDelay); err != nil {
+		return err
+	}
+	if delay == 0 {
+		return s.publish(ctx, topic, message)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		err := NewProducerError("PRODUCER_CLOSED", "producer is closed", false)
+		err.Cause = ErrProducerClosed
+		return err
+	}
+	s.seq++
+	item := &delayedMessage{due: time.Now().Add(delay), seq: s.seq, topic: topic, message: cloneMessage(message)}
+	heap.Push(&s.queue, item)
+	if s.queue[0] == item {
+		select {
+		case s.wake <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// Len returns the number of messages waiting to be published
+func (s *DelayScheduler) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.queue)
+}
+
+// Failed returns the number of delayed messages that failed to publish
+func (s *DelayScheduler) Failed() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.failed
+}
+
+// Close stops the scheduler, canceling a publish in progress, and returns
+// the number of messages dropped because they weren't due yet
+func (s *DelayScheduler) Close() int {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return 0
+	}
+	s.closed = true
+	s.mu.Unlock()
+
+	s.cancel()
+	<-s.done
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	dropped := len(s.queue)
+	s.queue = nil
+	return dropped
+}
+
+// run publishes the messages as they become due
+func (s *DelayScheduler) run() {
+	defer close(s.done)
+	timer := time.NewTimer(time.Hour)
+	defer timer.Stop()
+
+	for {
+		s.mu.Lock()
+		var next *delayedMessage
+		wait := time.Hour
+		if len(s.queue) > 0 {
+			if wait = time.Until(s.queue[0].due); wait <= 0 {
+				next = heap.Pop(&s.queue).(*delayedMessage)
+			}
+		}
+		s.mu.Unlock()
+
+		if next != nil {
+			if err := s.publish(s.ctx, next.topic, next.message); err != nil {
+				s.mu.Lock()
+				s.failed++
+				handler := s.errorHandler
+				s.mu.Unlock()
+				if handler != nil {
+					handler(next.topic, next.message, err)
+				}
+			}
+			if s.ctx.Err() != nil {
+				return
+			}
+			continue
+		}
+
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		timer.Reset(wait)
+		select {
+		case <-timer.C:
+		case <-s.wake:
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
+
+// cloneMessage copies a message with its payload and headers, so the caller
+// can reuse them before the message is published
+func cloneMessage(message *Message) *Message {
+	if message == nil {
+		return nil
+	}
+	clone := *message
+	if message.Payload != nil {
+		clone.Payload = append([]byte(nil), message.Payload...)
+	}
+	if message.Headers != nil {
+		clone.Headers = make(map[string]string, len(message.Headers))
+		for k, v := range message.Headers {
+			clone.Headers[k] = v
+		}
+	}
+	return &clone
+}
+
+// delayedMessage is a message waiting to be published
+type delayedMessage struct {
+	due     time.Time
+	seq     uint64 // Orders messages due at the same time
+	topic   string
+	message *Message
+}
+
+// delayQueue is a heap of delayed messages, the first due first
+type delayQueue []*delayedMessage
+
+func (q delayQueue) Len() int { return len(q) }
+
+func (q delayQueue) Less(i, j int) bool {
+	if q[i].due.Equal(q[j].due) {
+		return q[i].seq < q[j].seq
+	}
+	return q[i].due.Before(q[j].due)
+}
+
+func (q delayQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+
+func (q *delayQueue) Push(x interface{}) { *q = append(*q, x.(*delayedMessage)) }
+
+func (q *delayQueue) Pop() interface{} {
+	old := *q
+	item := old[len(old)-1]
+	old[len(old)-1] = nil
+	*q = old[:len(old)-1]
+	return item
+}
+
+// DefaultProducer adds delayed publishing to a producer of a backend
+// without native delayed delivery, publishing delayed messages through a
+// DelayScheduler. See DelayScheduler for the ordering caveats.
+type DefaultProducer struct {
+	Producer
+	scheduler *DelayScheduler
+}
+
+// NewDefaultProducer wraps a producer, rejecting delays beyond maxDelay,
+// DefaultMaxDelay when zero
+func NewDefaultProducer(producer Producer, maxDelay time.Duration) *DefaultProducer {
+	return &DefaultProducer{
+		Producer:  producer,
+		scheduler: NewDelayScheduler(producer.Publish, maxDelay),
+	}
+}
+
+// PublishWithDelay publishes a message to a topic after a delay
+func (p *DefaultProducer) PublishWithDelay(ctx context.Context, topic string, message *Message, delay time.Duration) error {
+	return p.scheduler.Schedule(ctx, topic, message, delay)
+}
+
+// SetDelayErrorHandler sets the handler called with the delayed messages
+// that fail to publish
+func (p *DefaultProducer) SetDelayErrorHandler(handler DelayErrorHandler) {
+	p.scheduler.SetErrorHandler(handler)
+}
+
+// GetMetrics returns the metrics of the producer, counting the delayed
+// messages as pending
+func (p *DefaultProducer) GetMetrics() ProducerMetrics {
+	metrics := p.Producer.GetMetrics()
+	metrics.PendingMessages += int64(p.scheduler.Len())
+	metrics.DelayedPublishErrors = p.scheduler.Failed()
+	return metrics
+}
+
+// Close drops the delayed messages that aren't due yet and closes the
+// producer
+func (p *DefaultProducer) Close() error {
+	dropped := p.scheduler.Close()
+	if err := p.Producer.Close(); err != nil {
+		return err
+	}
+	if dropped > 0 {
+		return NewProducerError("DELAYED_MESSAGES_DROPPED", fmt.Sprintf("%d delayed messages dropped on close", dropped), false)
+	}
+	return nil
+}
@@ -0,0 +1,173 @@
+package mq
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingProducer records the messages published, for DefaultProducer
+type recordingProducer struct {
+	Producer
+
+	mu        sync.Mutex
+	published []*Message
+	times     []time.Time
+	closed    bool
+	err       error // Returned by Publish
+}
+
+func (p *recordingProducer) Publish(ctx context.Context, topic string, message *Message) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.err != nil {
+		return p.err
+	}
+	p.published = append(p.published, message)
+	p.times = append(p.times, time.Now())
+	return nil
+}
+
+func (p *recordingProducer) GetMetrics() ProducerMetrics { return ProducerMetrics{} }
+
+func (p *recordingProducer) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closed = true
+	return nil
+}
+
+func (p *recordingProducer) messages() ([]*Message, []time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]*Message(nil), p.published...), append([]time.Time(nil), p.times...)
+}
+
+func waitForPublished(t *testing.T, p *recordingProducer, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if published, _ := p.messages(); len(published) >= n {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for %d messages", n)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestDefaultProducer_PublishWithDelay(t *testing.T) {
+	backend := &recordingProducer{}
+	p := NewDefaultProducer(backend, time.Minute)
+	defer p.Close()
+	ctx := context.Background()
+
+	start := time.Now()
+	delays := map[string]time.Duration{"late": 150 * time.Millisecond, "early": 50 * time.Millisecond, "same": 50 * time.Millisecond}
+	for _, id := range []string{"late", "early", "same"} {
+		if err := p.PublishWithDelay(ctx, "retries", &Message{ID: id}, delays[id]); err != nil {
+			t.Fatalf("PublishWithDelay failed: %v", err)
+		}
+	}
+	if err := p.PublishWithDelay(ctx, "retries", &Message{ID: "now"}, 0); err != nil {
+		t.Fatalf("PublishWithDelay failed: %v", err)
+	}
+	if metrics := p.GetMetrics(); metrics.PendingMessages != 3 {
+		t.Errorf("Expected 3 pending messages, got %d", metrics.PendingMessages)
+	}
+
+	waitForPublished(t, backend, 4)
+	published, times := backend.messages()
+	for i, want := range []string{"now", "early", "same", "late"} {
+		if published[i].ID != want {
+			t.Fatalf("Expected message %d to be %s, got %s", i, want, published[i].ID)
+		}
+		if elapsed := times[i].Sub(start); elapsed < delays[want] {
+			t.Errorf("Message %s published after %s, before its delay", want, elapsed)
+		}
+	}
+}
+
+func TestDefaultProducer_CopiesMessage(t *testing.T) {
+	backend := &recordingProducer{}
+	p := NewDefaultProducer(backend, time.Minute)
+	defer p.Close()
+
+	payload := []byte("original")
+	message := &Message{ID: "1", Payload: payload, Headers: map[string]string{"k": "original"}}
+	if err := p.PublishWithDelay(context.Background(), "retries", message, 20*time.Millisecond); err != nil {
+		t.Fatalf("PublishWithDelay failed: %v", err)
+	}
+	copy(payload, "modified")
+	message.Headers["k"] = "modified"
+
+	waitForPublished(t, backend, 1)
+	published, _ := backend.messages()
+	if string(published[0].Payload) != "original" || published[0].Headers["k"] != "original" {
+		t.Errorf("Expected the message as scheduled, got %q %v", published[0].Payload, published[0].Headers)
+	}
+}
+
+func TestDefaultProducer_DelayedPublishError(t *testing.T) {
+	backend := &recordingProducer{err: errors.New("broker unavailable")}
+	p := NewDefaultProducer(backend, time.Minute)
+	defer p.Close()
+
+	failed := make(chan string, 1)
+	p.SetDelayErrorHandler(func(topic string, message *Message, err error) {
+		if err != backend.err {
+			t.Errorf("Unexpected error: %v", err)
+		}
+		failed <- message.ID
+	})
+	if err := p.PublishWithDelay(context.Background(), "retries", &Message{ID: "1"}, 10*time.Millisecond); err != nil {
+		t.Fatalf("PublishWithDelay failed: %v", err)
+	}
+
+	select {
+	case id := <-failed:
+		if id != "1" {
+			t.Errorf("Expected message 1 to fail, got %s", id)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the error handler")
+	}
+	if metrics := p.GetMetrics(); metrics.DelayedPublishErrors != 1 {
+		t.Errorf("Expected 1 delayed publish error, got %d", metrics.DelayedPublishErrors)
+	}
+}
+
+func TestDefaultProducer_InvalidDelay(t *testing.T) {
+	p := NewDefaultProducer(&recordingProducer{}, time.Minute)
+	defer p.Close()
+
+	for _, delay := range []time.Duration{-time.Second, time.Minute + time.Second} {
+		if err := p.PublishWithDelay(context.Background(), "retries", &Message{}, delay); !IsConfigurationError(err) {
+			t.Errorf("Expected configuration error for delay %s, got %v", delay, err)
+		}
+	}
+	if err := NewDefaultProducer(&recordingProducer{}, 0).PublishWithDelay(context.Background(), "retries", &Message{}, DefaultMaxDelay+time.Second); !IsConfigurationError(err) {
+		t.Errorf("Expected configuration error beyond the default maximum, got %v", err)
+	}
+}
+
+func TestDefaultProducer_Close(t *testing.T) {
+	backend := &recordingProducer{}
+	p := NewDefaultProducer(backend, 0)
+	if err := p.PublishWithDelay(context.Background(), "retries", &Message{}, time.Hour); err != nil {
+		t.Fatalf("PublishWithDelay failed: %v", err)
+	}
+
+	if err := p.Close(); err == nil {
+		t.Error("Expected an error for the dropped delayed message")
+	}
+	if !backend.closed {
+		t.Error("Expected the producer to be closed")
+	}
+	if err := p.PublishWithDelay(context.Background(), "retries", &Message{}, time.Second); !IsProducerError(err) {
+		t.Errorf("Expected producer error after close, got %v", err)
+	}
+}
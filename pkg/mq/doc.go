@@ -78,12 +78,17 @@
 //		WithUserAgent("MyApp/1.0").
 //		Build()
 //
-//	// Convert to message and publish
+//	// Convert to message and publish, serialized as the producer is
+//	// configured: mq.SerializationJSON or mq.SerializationProtobuf
+//	serializer, err := mq.NewProducerSerializer(config)
+//	if err != nil {
+//		return err
+//	}
 //	message, err := mq.NewAPIUsageEventBuilder().
 //		WithRequestID(event.RequestID).
 //		WithApplicationID(event.ApplicationID).
 //		// ... other fields
-//		ToMessage(jsonSerializer)
+//		ToMessage(serializer)
 //	if err != nil {
 //		return err
 //	}
@@ -125,6 +130,20 @@
 //		log.Error("Failed to start async publish:", err)
 //	}
 //
+// ## Delayed Publishing
+//
+//	// Process the retry event in 30 seconds; delays beyond
+//	// ProducerConfig.MaxDelay return a configuration error
+//	err = producer.PublishWithDelay(ctx, "retries", message, 30*time.Second)
+//	if err != nil {
+//		log.Error("Failed to schedule retry:", err)
+//	}
+//
+// Backends without native delayed delivery wait for the delay in memory,
+// with a DelayScheduler or by wrapping their producer in a DefaultProducer.
+// Such delayed messages are lost when the producer is closed first, and
+// messages published directly may overtake them.
+//
 // ## Basic Consumer Usage
 //
 //	// Create consumer configuration
@@ -166,7 +185,7 @@
 // ## API Usage Event Processing
 //
 //	// Create API usage event processor
-//	processor := mq.NewAPIUsageEventProcessor(jsonSerializer, func(ctx context.Context, event *mq.APIUsageEvent) error {
+//	processor := mq.NewAPIUsageEventProcessor(serializer, func(ctx context.Context, event *mq.APIUsageEvent) error {
 //		// Process the API usage event
 //		log.Info("Processing API usage:", event.RequestID, event.ApplicationID, event.Path)
 //		
@@ -0,0 +1,242 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.8
+// 	protoc        (unknown)
+// source: api_usage_event.proto
+
+package mqpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	structpb "google.golang.org/protobuf/types/known/structpb"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// APIUsageEvent is the protobuf form of mq.APIUsageEvent
+type APIUsageEvent struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	RequestId     string                 `protobuf:"bytes,1,opt,name=request_id,json=requestId,proto3" json:"request_id,omitempty"`
+	ApplicationId string                 `protobuf:"bytes,2,opt,name=application_id,json=applicationId,proto3" json:"application_id,omitempty"`
+	UserId        string                 `protobuf:"bytes,3,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	Method        string                 `protobuf:"bytes,4,opt,name=method,proto3" json:"method,omitempty"`
+	Path          string                 `protobuf:"bytes,5,opt,name=path,proto3" json:"path,omitempty"`
+	StatusCode    int32                  `protobuf:"varint,6,opt,name=status_code,json=statusCode,proto3" json:"status_code,omitempty"`
+	// Response time in milliseconds
+	ResponseTime  int64                  `protobuf:"varint,7,opt,name=response_time,json=responseTime,proto3" json:"response_time,omitempty"`
+	RequestSize   int64                  `protobuf:"varint,8,opt,name=request_size,json=requestSize,proto3" json:"request_size,omitempty"`
+	ResponseSize  int64                  `protobuf:"varint,9,opt,name=response_size,json=responseSize,proto3" json:"response_size,omitempty"`
+	Timestamp     *timestamppb.Timestamp `protobuf:"bytes,10,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	ClientIp      string                 `protobuf:"bytes,11,opt,name=client_ip,json=clientIp,proto3" json:"client_ip,omitempty"`
+	UserAgent     string                 `protobuf:"bytes,12,opt,name=user_agent,json=userAgent,proto3" json:"user_agent,omitempty"`
+	Metadata      *structpb.Struct       `protobuf:"bytes,13,opt,name=metadata,proto3" json:"metadata,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *APIUsageEvent) Reset() {
+	*x = APIUsageEvent{}
+	mi := &file_api_usage_event_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *APIUsageEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*APIUsageEvent) ProtoMessage() {}
+
+func (x *APIUsageEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_api_usage_event_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use APIUsageEvent.ProtoReflect.Descriptor instead.
+func (*APIUsageEvent) Descriptor() ([]byte, []int) {
+	return file_api_usage_event_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *APIUsageEvent) GetRequestId() string {
+	if x != nil {
+		return x.RequestId
+	}
+	return ""
+}
+
+func (x *APIUsageEvent) GetApplicationId() string {
+	if x != nil {
+		return x.ApplicationId
+	}
+	return ""
+}
+
+func (x *APIUsageEvent) GetUserId() string {
+	if x != nil {
+		return x.UserId
+	}
+	return ""
+}
+
+func (x *APIUsageEvent) GetMethod() string {
+	if x != nil {
+		return x.Method
+	}
+	return ""
+}
+
+func (x *APIUsageEvent) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+func (x *APIUsageEvent) GetStatusCode() int32 {
+	if x != nil {
+		return x.StatusCode
+	}
+	return 0
+}
+
+func (x *APIUsageEvent) GetResponseTime() int64 {
+	if x != nil {
+		return x.ResponseTime
+	}
+	return 0
+}
+
+func (x *APIUsageEvent) GetRequestSize() int64 {
+	if x != nil {
+		return x.RequestSize
+	}
+	return 0
+}
+
+func (x *APIUsageEvent) GetResponseSize() int64 {
+	if x != nil {
+		return x.ResponseSize
+	}
+	return 0
+}
+
+func (x *APIUsageEvent) GetTimestamp() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Timestamp
+	}
+	return nil
+}
+
+func (x *APIUsageEvent) GetClientIp() string {
+	if x != nil {
+		return x.ClientIp
+	}
+	return ""
+}
+
+func (x *APIUsageEvent) GetUserAgent() string {
+	if x != nil {
+		return x.UserAgent
+	}
+	return ""
+}
+
+func (x *APIUsageEvent) GetMetadata() *structpb.Struct {
+	if x != nil {
+		return x.Metadata
+	}
+	return nil
+}
+
+var File_api_usage_event_proto protoreflect.FileDescriptor
+
+const file_api_usage_event_proto_rawDesc = "" +
+	"\n" +
+	"\x15api_usage_event.proto\x12\x0estargate.mq.v1\x1a\x1cgoogle/protobuf/struct.proto\x1a\x1fgoogle/protobuf/timestamp.proto\"\xd3\x03\n" +
+	"\rAPIUsageEvent\x12\x1d\n" +
+	"\n" +
+	"request_id\x18\x01 \x01(\tR\trequestId\x12%\n" +
+	"\x0eapplication_id\x18\x02 \x01(\tR\rapplicationId\x12\x17\n" +
+	"\auser_id\x18\x03 \x01(\tR\x06userId\x12\x16\n" +
+	"\x06method\x18\x04 \x01(\tR\x06method\x12\x12\n" +
+	"\x04path\x18\x05 \x01(\tR\x04path\x12\x1f\n" +
+	"\vstatus_code\x18\x06 \x01(\x05R\n" +
+	"statusCode\x12#\n" +
+	"\rresponse_time\x18\a \x01(\x03R\fresponseTime\x12!\n" +
+	"\frequest_size\x18\b \x01(\x03R\vrequestSize\x12#\n" +
+	"\rresponse_size\x18\t \x01(\x03R\fresponseSize\x128\n" +
+	"\ttimestamp\x18\n" +
+	" \x01(\v2\x1a.google.protobuf.TimestampR\ttimestamp\x12\x1b\n" +
+	"\tclient_ip\x18\v \x01(\tR\bclientIp\x12\x1d\n" +
+	"\n" +
+	"user_agent\x18\f \x01(\tR\tuserAgent\x123\n" +
+	"\bmetadata\x18\r \x01(\v2\x17.google.protobuf.StructR\bmetadataB.Z,github.com/songzhibin97/stargate/pkg/mq/mqpbb\x06proto3"
+
+var (
+	file_api_usage_event_proto_rawDescOnce sync.Once
+	file_api_usage_event_proto_rawDescData []byte
+)
+
+func file_api_usage_event_proto_rawDescGZIP() []byte {
+	file_api_usage_event_proto_rawDescOnce.Do(func() {
+		file_api_usage_event_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_api_usage_event_proto_rawDesc), len(file_api_usage_event_proto_rawDesc)))
+	})
+	return file_api_usage_event_proto_rawDescData
+}
+
+var file_api_usage_event_proto_msgTypes = make([]protoimpl.MessageInfo, 1)
+var file_api_usage_event_proto_goTypes = []any{
+	(*APIUsageEvent)(nil),         // 0: stargate.mq.v1.APIUsageEvent
+	(*timestamppb.Timestamp)(nil), // 1: google.protobuf.Timestamp
+	(*structpb.Struct)(nil),       // 2: google.protobuf.Struct
+}
+var file_api_usage_event_proto_depIdxs = []int32{
+	1, // 0: stargate.mq.v1.APIUsageEvent.timestamp:type_name -> google.protobuf.Timestamp
+	2, // 1: stargate.mq.v1.APIUsageEvent.metadata:type_name -> google.protobuf.Struct
+	2, // [2:2] is the sub-list for method output_type
+	2, // [2:2] is the sub-list for method input_type
+	2, // [2:2] is the sub-list for extension type_name
+	2, // [2:2] is the sub-list for extension extendee
+	0, // [0:2] is the sub-list for field type_name
+}
+
+func init() { file_api_usage_event_proto_init() }
+func file_api_usage_event_proto_init() {
+	if File_api_usage_event_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_api_usage_event_proto_rawDesc), len(file_api_usage_event_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   1,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_api_usage_event_proto_goTypes,
+		DependencyIndexes: file_api_usage_event_proto_depIdxs,
+		MessageInfos:      file_api_usage_event_proto_msgTypes,
+	}.Build()
+	File_api_usage_event_proto = out.File
+	file_api_usage_event_proto_goTypes = nil
+	file_api_usage_event_proto_depIdxs = nil
+}
@@ -0,0 +1,5 @@
+// Package mqpb contains the protobuf messages of the mq package, used by
+// its protobuf serializer for the events defined as plain structs.
+package mqpb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative api_usage_event.proto
@@ -28,6 +28,10 @@ type Producer interface {
 	// PublishAsyncWithOptions publishes a message asynchronously with custom options
 	PublishAsyncWithOptions(ctx context.Context, topic string, message *Message, opts *PublishOptions, callback PublishCallback) error
 	
+	// PublishWithDelay publishes a message to be delivered after a delay, returning
+	// a configuration error when the delay exceeds ProducerConfig.MaxDelay
+	PublishWithDelay(ctx context.Context, topic string, message *Message, delay time.Duration) error
+	
 	// Flush flushes any pending messages
 	Flush(ctx context.Context) error
 	
@@ -58,6 +62,9 @@ type ProducerMetrics struct {
 	// Current pending messages
 	PendingMessages int64 `json:"pending_messages"`
 	
+	// Delayed messages that failed to publish when due
+	DelayedPublishErrors int64 `json:"delayed_publish_errors"`
+	
 	// Connection status
 	Connected bool `json:"connected"`
 	
@@ -278,7 +285,8 @@ func (b *APIUsageEventBuilder) Build() *APIUsageEvent {
 	return b.event
 }
 
-// ToMessage converts the API usage event to a message
+// ToMessage converts the API usage event to a message, serialized with a
+// JSONSerializer, a ProtobufSerializer or any other serializer
 func (b *APIUsageEventBuilder) ToMessage(serializer Serializer) (*Message, error) {
 	event := b.Build()
 	payload, err := serializer.Serialize(event)
@@ -293,6 +301,7 @@ func (b *APIUsageEventBuilder) ToMessage(serializer Serializer) (*Message, error
 		WithHeader("event_type", "api_usage").
 		WithHeader("application_id", event.ApplicationID).
 		WithHeader("user_id", event.UserID).
+		WithHeader("content_type", serializer.ContentType()).
 		WithTimestamp(event.Timestamp).
 		Build(), nil
 }
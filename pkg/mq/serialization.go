@@ -0,0 +1,169 @@
+package mq
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/songzhibin97/stargate/pkg/mq/mqpb"
+)
+
+// Content types of the serializers
+const (
+	ContentTypeJSON     = "application/json"
+	ContentTypeProtobuf = "application/x-protobuf"
+)
+
+// NewSerializer creates a serializer of a serialization format, JSON by
+// default
+func NewSerializer(serialization SerializationType) (Serializer, error) {
+	switch serialization {
+	case "", SerializationJSON:
+		return JSONSerializer{}, nil
+	case SerializationProtobuf:
+		return ProtobufSerializer{}, nil
+	default:
+		err := NewConfigurationError("UNSUPPORTED_SERIALIZATION", fmt.Sprintf("unsupported serialization: %s", serialization))
+		err.Cause = ErrInvalidConfig
+		return nil, err
+	}
+}
+
+// NewProducerSerializer creates the serializer selected by a producer
+// configuration
+func NewProducerSerializer(config *ProducerConfig) (Serializer, error) {
+	return NewSerializer(config.Serialization)
+}
+
+// JSONSerializer serializes messages with encoding/json
+type JSONSerializer struct{}
+
+// Serialize converts data to JSON
+func (JSONSerializer) Serialize(data interface{}) ([]byte, error) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return nil, NewSerializationError("SERIALIZE_FAILED", fmt.Sprintf("json serialization failed: %v", err), ErrSerializationFailed)
+	}
+	return payload, nil
+}
+
+// Deserialize converts JSON to data
+func (JSONSerializer) Deserialize(data []byte, target interface{}) error {
+	if err := json.Unmarshal(data, target); err != nil {
+		return NewSerializationError("DESERIALIZE_FAILED", fmt.Sprintf("json deserialization failed: %v", err), ErrDeserializationFailed)
+	}
+	return nil
+}
+
+// ContentType returns application/json
+func (JSONSerializer) ContentType() string {
+	return ContentTypeJSON
+}
+
+// ProtobufSerializer serializes messages implementing proto.Message, and
+// API usage events through their mqpb.APIUsageEvent form
+type ProtobufSerializer struct{}
+
+// Serialize converts a proto.Message or an API usage event to protobuf
+func (ProtobufSerializer) Serialize(data interface{}) ([]byte, error) {
+	var message proto.Message
+	switch value := data.(type) {
+	case proto.Message:
+		message = value
+	case *APIUsageEvent:
+		var err error
+		if message, err = apiUsageEventToProto(value); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, NewSerializationError("SERIALIZE_FAILED", fmt.Sprintf("protobuf serialization requires a proto.Message, got %T", data), ErrSerializationFailed)
+	}
+
+	payload, err := proto.Marshal(message)
+	if err != nil {
+		return nil, NewSerializationError("SERIALIZE_FAILED", fmt.Sprintf("protobuf serialization failed: %v", err), ErrSerializationFailed)
+	}
+	return payload, nil
+}
+
+// Deserialize converts protobuf to a proto.Message or an API usage event
+func (ProtobufSerializer) Deserialize(data []byte, target interface{}) error {
+	switch value := target.(type) {
+	case proto.Message:
+		if err := proto.Unmarshal(data, value); err != nil {
+			return NewSerializationError("DESERIALIZE_FAILED", fmt.Sprintf("protobuf deserialization failed: %v", err), ErrDeserializationFailed)
+		}
+		return nil
+	case *APIUsageEvent:
+		var message mqpb.APIUsageEvent
+		if err := proto.Unmarshal(data, &message); err != nil {
+			return NewSerializationError("DESERIALIZE_FAILED", fmt.Sprintf("protobuf deserialization failed: %v", err), ErrDeserializationFailed)
+		}
+		*value = *apiUsageEventFromProto(&message)
+		return nil
+	default:
+		return NewSerializationError("DESERIALIZE_FAILED", fmt.Sprintf("protobuf deserialization requires a proto.Message, got %T", target), ErrDeserializationFailed)
+	}
+}
+
+// ContentType returns application/x-protobuf
+func (ProtobufSerializer) ContentType() string {
+	return ContentTypeProtobuf
+}
+
+// apiUsageEventToProto returns the protobuf form of an API usage event.
+// Metadata values must be representable as a google.protobuf.Value.
+func apiUsageEventToProto(event *APIUsageEvent) (*mqpb.APIUsageEvent, error) {
+	message := &mqpb.APIUsageEvent{
+		RequestId:     event.RequestID,
+		ApplicationId: event.ApplicationID,
+		UserId:        event.UserID,
+		Method:        event.Method,
+		Path:          event.Path,
+		StatusCode:    int32(event.StatusCode),
+		ResponseTime:  event.ResponseTime,
+		RequestSize:   event.RequestSize,
+		ResponseSize:  event.ResponseSize,
+		ClientIp:      event.ClientIP,
+		UserAgent:     event.UserAgent,
+	}
+	if !event.Timestamp.IsZero() {
+		message.Timestamp = timestamppb.New(event.Timestamp)
+	}
+	if len(event.Metadata) > 0 {
+		metadata, err := structpb.NewStruct(event.Metadata)
+		if err != nil {
+			return nil, NewSerializationError("SERIALIZE_FAILED", fmt.Sprintf("invalid API usage event metadata: %v", err), ErrSerializationFailed)
+		}
+		message.Metadata = metadata
+	}
+	return message, nil
+}
+
+// apiUsageEventFromProto returns the API usage event of its protobuf form.
+// Like with JSON, metadata numbers become float64.
+func apiUsageEventFromProto(message *mqpb.APIUsageEvent) *APIUsageEvent {
+	event := &APIUsageEvent{
+		RequestID:     message.GetRequestId(),
+		ApplicationID: message.GetApplicationId(),
+		UserID:        message.GetUserId(),
+		Method:        message.GetMethod(),
+		Path:          message.GetPath(),
+		StatusCode:    int(message.GetStatusCode()),
+		ResponseTime:  message.GetResponseTime(),
+		RequestSize:   message.GetRequestSize(),
+		ResponseSize:  message.GetResponseSize(),
+		ClientIP:      message.GetClientIp(),
+		UserAgent:     message.GetUserAgent(),
+	}
+	if message.Timestamp != nil {
+		event.Timestamp = message.Timestamp.AsTime()
+	}
+	if message.Metadata != nil {
+		event.Metadata = message.Metadata.AsMap()
+	}
+	return event
+}
@@ -0,0 +1,138 @@
+package mq
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestNewProducerSerializer(t *testing.T) {
+	tests := []struct {
+		serialization SerializationType
+		contentType   string
+		wantErr       bool
+	}{
+		{serialization: "", contentType: ContentTypeJSON},
+		{serialization: SerializationJSON, contentType: ContentTypeJSON},
+		{serialization: SerializationProtobuf, contentType: ContentTypeProtobuf},
+		{serialization: SerializationAvro, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		s, err := NewProducerSerializer(&ProducerConfig{Serialization: tt.serialization})
+		if tt.wantErr {
+			if !IsConfigurationError(err) {
+				t.Errorf("%s: expected configuration error, got %v", tt.serialization, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.serialization, err)
+			continue
+		}
+		if s.ContentType() != tt.contentType {
+			t.Errorf("%s: expected content type %s, got %s", tt.serialization, tt.contentType, s.ContentType())
+		}
+	}
+}
+
+func TestAPIUsageEvent_RoundTrip(t *testing.T) {
+	for _, serialization := range []SerializationType{SerializationJSON, SerializationProtobuf} {
+		t.Run(string(serialization), func(t *testing.T) {
+			serializer, err := NewSerializer(serialization)
+			if err != nil {
+				t.Fatalf("NewSerializer failed: %v", err)
+			}
+
+			builder := NewAPIUsageEventBuilder().
+				WithRequestID("req-123").
+				WithApplicationID("app-456").
+				WithUserID("user-789").
+				WithMethod("POST").
+				WithPath("/api/v1/orders").
+				WithStatusCode(201).
+				WithResponseTime(150).
+				WithRequestSize(1024).
+				WithResponseSize(2048).
+				WithClientIP("192.168.1.100").
+				WithUserAgent("MyApp/1.0").
+				WithTimestamp(time.Date(2024, 1, 1, 12, 30, 0, 500, time.UTC)).
+				WithMetadata("region", "eu-west-1").
+				WithMetadata("retries", float64(2))
+			message, err := builder.ToMessage(serializer)
+			if err != nil {
+				t.Fatalf("ToMessage failed: %v", err)
+			}
+			if message.Headers["content_type"] != serializer.ContentType() {
+				t.Errorf("Expected content type %s, got %s", serializer.ContentType(), message.Headers["content_type"])
+			}
+
+			var got *APIUsageEvent
+			processor := NewAPIUsageEventProcessor(serializer, func(ctx context.Context, event *APIUsageEvent) error {
+				got = event
+				return nil
+			})
+			if err := processor.ProcessMessage(context.Background(), message); err != nil {
+				t.Fatalf("ProcessMessage failed: %v", err)
+			}
+
+			want := builder.Build()
+			if !got.Timestamp.Equal(want.Timestamp) {
+				t.Errorf("Expected timestamp %v, got %v", want.Timestamp, got.Timestamp)
+			}
+			got.Timestamp = want.Timestamp
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("Expected %+v, got %+v", want, got)
+			}
+		})
+	}
+}
+
+func TestProtobufSerializer_ProtoMessage(t *testing.T) {
+	s := ProtobufSerializer{}
+	message, err := structpb.NewStruct(map[string]interface{}{"topic": "api.usage", "partitions": float64(3)})
+	if err != nil {
+		t.Fatalf("NewStruct failed: %v", err)
+	}
+
+	payload, err := s.Serialize(message)
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+	var got structpb.Struct
+	if err := s.Deserialize(payload, &got); err != nil {
+		t.Fatalf("Deserialize failed: %v", err)
+	}
+	if !proto.Equal(&got, message) {
+		t.Errorf("Expected %v, got %v", message, &got)
+	}
+}
+
+func TestProtobufSerializer_Errors(t *testing.T) {
+	s := ProtobufSerializer{}
+
+	if _, err := s.Serialize(&MetricsMessage{}); !IsSerializationError(err) {
+		t.Errorf("Expected serialization error for a non-proto payload, got %v", err)
+	}
+	if _, err := s.Serialize(map[string]string{"key": "value"}); !IsSerializationError(err) {
+		t.Errorf("Expected serialization error for a map payload, got %v", err)
+	}
+
+	event := NewAPIUsageEventBuilder().WithMetadata("channel", make(chan int)).Build()
+	if _, err := s.Serialize(event); !IsSerializationError(err) {
+		t.Errorf("Expected serialization error for unsupported metadata, got %v", err)
+	}
+
+	var metrics MetricsMessage
+	if err := s.Deserialize([]byte{0x0a}, &metrics); !IsSerializationError(err) {
+		t.Errorf("Expected serialization error for a non-proto target, got %v", err)
+	}
+	var decoded APIUsageEvent
+	if err := s.Deserialize([]byte{0xff, 0xff}, &decoded); !IsSerializationError(err) {
+		t.Errorf("Expected serialization error for an invalid payload, got %v", err)
+	}
+}
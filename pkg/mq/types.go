@@ -104,6 +104,9 @@ type ProducerConfig struct {
 	// Serialization settings
 	Serialization SerializationType `yaml:"serialization" json:"serialization"`
 	
+	// MaxDelay bounds the delay of delayed publishing, DefaultMaxDelay when zero
+	MaxDelay time.Duration `yaml:"max_delay" json:"max_delay"`
+	
 	// Security settings
 	Security SecurityConfig `yaml:"security" json:"security"`
 	
@@ -137,6 +140,10 @@ type ConsumerConfig struct {
 	// SessionTimeout for consumer sessions
 	SessionTimeout time.Duration `yaml:"session_timeout" json:"session_timeout"`
 	
+	// MaxDecompressedSize bounds the size of decompressed payloads,
+	// DefaultMaxDecompressedSize when zero
+	MaxDecompressedSize int64 `yaml:"max_decompressed_size" json:"max_decompressed_size"`
+	
 	// Security settings
 	Security SecurityConfig `yaml:"security" json:"security"`
 	
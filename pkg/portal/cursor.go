@@ -0,0 +1,67 @@
+package portal
+
+import (
+	"encoding/base64"
+	"strings"
+	"time"
+)
+
+// Cursor is the position of a user or application in cursor pagination:
+// the sort key of the last row of a page, which the next page starts after.
+// Rows are ordered by creation time and then by ID, so rows created during
+// paging neither shift nor repeat the rows that follow.
+type Cursor struct {
+	CreatedAt time.Time
+	ID        string
+}
+
+// EncodeCursor returns the opaque cursor of a row
+func EncodeCursor(createdAt time.Time, id string) string {
+	key := createdAt.UTC().Format(time.RFC3339Nano) + "|" + id
+	return base64.RawURLEncoding.EncodeToString([]byte(key))
+}
+
+// DecodeCursor parses a cursor returned by EncodeCursor
+func DecodeCursor(cursor string) (Cursor, error) {
+	key, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return Cursor{}, NewValidationError("INVALID_CURSOR", "cursor is malformed")
+	}
+	createdAt, id, ok := strings.Cut(string(key), "|")
+	if !ok || id == "" {
+		return Cursor{}, NewValidationError("INVALID_CURSOR", "cursor is malformed")
+	}
+	t, err := time.Parse(time.RFC3339Nano, createdAt)
+	if err != nil {
+		return Cursor{}, NewValidationError("INVALID_CURSOR", "cursor is malformed")
+	}
+	return Cursor{CreatedAt: t, ID: id}, nil
+}
+
+// Before reports whether a row sorts before the cursor in ascending order
+func (c Cursor) Before(createdAt time.Time, id string) bool {
+	if !createdAt.Equal(c.CreatedAt) {
+		return createdAt.Before(c.CreatedAt)
+	}
+	return id < c.ID
+}
+
+// After reports whether a row sorts after the cursor in ascending order
+func (c Cursor) After(createdAt time.Time, id string) bool {
+	if !createdAt.Equal(c.CreatedAt) {
+		return createdAt.After(c.CreatedAt)
+	}
+	return id > c.ID
+}
+
+// CursorMode reports whether the filter pages with a cursor rather than an
+// offset, which is the case when a cursor or a page size is set
+func (f *UserFilter) CursorMode() bool {
+	return f.Cursor != "" || f.PageSize > 0
+}
+
+// CursorMode reports whether the filter pages with a cursor, see
+// UserFilter.CursorMode
+func (f *ApplicationFilter) CursorMode() bool {
+	return f.Cursor != "" || f.PageSize > 0
+}
@@ -11,6 +11,7 @@
 //   - Repository: Base interface for all repositories with health check and transaction support
 //   - UserRepository: Interface for user-related data operations
 //   - ApplicationRepository: Interface for application-related data operations
+//   - UsageRepository: Interface for recording and querying application usage
 //   - Transaction: Interface for transactional operations across multiple repositories
 //
 // # Error Handling
@@ -29,6 +30,53 @@
 //   - Application: Represents a developer application with API credentials
 //   - UserFilter/ApplicationFilter: Filter criteria for querying data
 //   - PaginatedUsers/PaginatedApplications: Paginated result sets
+//   - UsageDelta/UsageBucket: Application usage increments and aggregated buckets
+//
+// # Application Scopes
+//
+// Applications carry the scopes they are granted, kept sorted and without
+// duplicates. Repositories check scopes with ValidateScopes on every write
+// against the allowlist they are configured with, or only for well-formed
+// scope tokens when no allowlist is configured. Removing a scope is never
+// rejected, so scopes dropped from the allowlist can be revoked.
+//
+// # Dormant Applications
+//
+// LastUsedAt records the last successful authentication with an
+// application's API key. Callers on the request path batch uses and record
+// them with RecordApplicationsLastUsed off the request path, so the time is
+// approximate. An application never used counts as active since its
+// creation when finding and suspending dormant applications.
+//
+// # Estimated Totals
+//
+// Counting the rows matching a filter for the Total of a paginated result
+// scans them all, which gets expensive for large tables. Setting
+// EstimateTotal on a filter lets the repository report an estimate instead
+// and set TotalEstimated. The PostgreSQL implementation uses the planner's
+// statistics, which are only as fresh as the last ANALYZE and can be off by
+// a wide margin for selective filters, so estimates suit page counts and
+// progress indicators, not exact figures. It falls back to an exact count
+// while the table has no statistics. HasMore stays exact, and Total is never
+// below the number of rows already paged through. The in-memory
+// implementation always counts exactly.
+//
+// # Application Events
+//
+// Repositories configured with an ApplicationEventHandler report application
+// changes to it once committed, such as one ApplicationEventTransferred event
+// per application moved by TransferApplications. Changes made in a
+// transaction are reported when it commits and dropped when it rolls back.
+//
+// # Usage Aggregation
+//
+// UsageRepository records usage at minute resolution and aggregates it on read
+// into minute, hour or day buckets aligned to UTC. GetUsage returns one bucket
+// per interval in [from, to), zero-filled where nothing was recorded, and
+// rejects ranges spanning more than MaxUsageBuckets buckets. The in-memory
+// implementation keeps 24 hours of minute buckets, 30 days of hour buckets
+// and a year of day buckets; the PostgreSQL implementation keeps minute rows
+// until they are deleted.
 //
 // # Usage Examples
 //
@@ -76,6 +124,21 @@
 //		fmt.Printf("User: %s (%s)\n", user.Name, user.Email)
 //	}
 //
+//	// Page through users with a cursor, which stays consistent while users
+//	// are created, instead of an offset
+//	filter = &portal.UserFilter{PageSize: 100}
+//	for {
+//		result, err := userRepo.ListUsers(ctx, filter)
+//		if err != nil {
+//			return err
+//		}
+//		// ...
+//		if !result.HasMore {
+//			break
+//		}
+//		filter.Cursor = result.NextCursor
+//	}
+//
 // ## Basic Application Operations
 //
 //	// Create a new application
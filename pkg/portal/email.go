@@ -0,0 +1,10 @@
+package portal
+
+import "strings"
+
+// NormalizeEmail returns the form of an email address that users are stored
+// and looked up by. Addresses are compared case-insensitively, so
+// Dev@example.com and dev@example.com belong to the same user.
+func NormalizeEmail(email string) string {
+	return strings.ToLower(email)
+}
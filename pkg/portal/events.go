@@ -0,0 +1,25 @@
+package portal
+
+import "time"
+
+// ApplicationEventType identifies a change to an application
+type ApplicationEventType string
+
+// Application event types
+const (
+	// ApplicationEventTransferred reports an application moved to another user
+	ApplicationEventTransferred ApplicationEventType = "transferred"
+)
+
+// ApplicationEvent describes a change to an application
+type ApplicationEvent struct {
+	Type           ApplicationEventType `json:"type"`
+	ApplicationID  string               `json:"application_id"`
+	UserID         string               `json:"user_id"`                    // Owner after the change
+	PreviousUserID string               `json:"previous_user_id,omitempty"` // Owner before a transfer
+	Time           time.Time            `json:"time"`
+}
+
+// ApplicationEventHandler is called with application changes once they are
+// committed. It is called synchronously and must not block.
+type ApplicationEventHandler func(event ApplicationEvent)
@@ -2,6 +2,7 @@ package portal
 
 import (
 	"context"
+	"database/sql"
 	"time"
 )
 
@@ -13,8 +14,15 @@ type Repository interface {
 	// Close closes the repository connection and releases resources
 	Close() error
 	
-	// BeginTx begins a transaction
+	// BeginTx begins a transaction with the default options
 	BeginTx(ctx context.Context) (Transaction, error)
+	
+	// BeginTxWithOptions begins a transaction with the options, the default
+	// options if nil
+	BeginTxWithOptions(ctx context.Context, opts *TxOptions) (Transaction, error)
+	
+	// Stats returns the connection pool usage and size of the repository
+	Stats(ctx context.Context) (*RepositoryStats, error)
 }
 
 // Transaction defines the interface for database transactions
@@ -40,6 +48,32 @@ type HealthStatus struct {
 	Timestamp time.Time              `json:"timestamp"`
 }
 
+// RepositoryStats reports the connection pool usage and size of a repository.
+// Repositories without a connection pool report no connections.
+type RepositoryStats struct {
+	OpenConnections    int           `json:"open_connections"`     // Connections established, in use or idle
+	InUseConnections   int           `json:"in_use_connections"`   // Connections currently in use
+	IdleConnections    int           `json:"idle_connections"`     // Connections currently idle
+	MaxOpenConnections int           `json:"max_open_connections"` // Pool size limit, 0 if unlimited
+	WaitCount          int64         `json:"wait_count"`           // Total number of waits for a free connection
+	WaitDuration       time.Duration `json:"wait_duration"`        // Total time spent waiting for a free connection
+
+	Users        int64 `json:"users"`        // Total number of users
+	Applications int64 `json:"applications"` // Total number of applications, not counting soft-deleted ones
+
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// TxOptions holds the options of a transaction
+type TxOptions struct {
+	// Isolation is the isolation level, the default level of the database
+	// if zero. Repositories without isolation levels ignore it.
+	Isolation sql.IsolationLevel
+
+	// ReadOnly rejects writes within the transaction
+	ReadOnly bool
+}
+
 // UserRepository defines the interface for user data operations
 type UserRepository interface {
 	// CreateUser creates a new user
@@ -75,6 +109,9 @@ type UserRepository interface {
 	// UpdateUserRole updates the role of a user
 	UpdateUserRole(ctx context.Context, userID string, role UserRole) error
 	
+	// UpdateLastLogin records that a user logged in now
+	UpdateLastLogin(ctx context.Context, userID string) error
+	
 	// BatchCreateUsers creates multiple users in a single operation
 	BatchCreateUsers(ctx context.Context, users []*User) error
 	
@@ -87,7 +124,7 @@ type UserRepository interface {
 
 // ApplicationRepository defines the interface for application data operations
 type ApplicationRepository interface {
-	// CreateApplication creates a new application
+	// CreateApplication creates a new application at version 1
 	CreateApplication(ctx context.Context, app *Application) error
 	
 	// GetApplication retrieves an application by ID
@@ -99,12 +136,26 @@ type ApplicationRepository interface {
 	// GetApplicationsByUser retrieves all applications for a specific user
 	GetApplicationsByUser(ctx context.Context, userID string) ([]*Application, error)
 	
-	// UpdateApplication updates an existing application
+	// UpdateApplication updates an existing application if its Version is
+	// still the current version, and advances the version. Updates of an
+	// application modified since it was read fail with a STALE_VERSION
+	// conflict error.
 	UpdateApplication(ctx context.Context, app *Application) error
 	
 	// DeleteApplication deletes an application by ID
 	DeleteApplication(ctx context.Context, appID string) error
 	
+	// SoftDeleteApplication marks an application deleted, keeping it for
+	// auditing. Soft-deleted applications are hidden from lookups and from
+	// listings unless ApplicationFilter.IncludeDeleted is set, and their API
+	// key can be reused.
+	SoftDeleteApplication(ctx context.Context, appID string) error
+	
+	// RestoreApplication clears the deletion mark of a soft-deleted
+	// application. It fails with a conflict if its API key was reused
+	// meanwhile.
+	RestoreApplication(ctx context.Context, appID string) error
+	
 	// ListApplications retrieves applications based on filter criteria
 	ListApplications(ctx context.Context, filter *ApplicationFilter) (*PaginatedApplications, error)
 	
@@ -123,16 +174,64 @@ type ApplicationRepository interface {
 	// UpdateApplicationRateLimit updates the rate limit of an application
 	UpdateApplicationRateLimit(ctx context.Context, appID string, rateLimit int64) error
 	
+	// GetApplicationScopes retrieves the scopes granted to an application
+	GetApplicationScopes(ctx context.Context, appID string) ([]string, error)
+	
+	// SetApplicationScopes replaces the scopes granted to an application
+	SetApplicationScopes(ctx context.Context, appID string, scopes []string) error
+	
+	// AddApplicationScopes grants additional scopes to an application
+	AddApplicationScopes(ctx context.Context, appID string, scopes []string) error
+	
+	// RemoveApplicationScopes revokes scopes from an application. Scopes no
+	// longer allowed can still be removed.
+	RemoveApplicationScopes(ctx context.Context, appID string, scopes []string) error
+	
+	// RecordApplicationsLastUsed records when applications were last used,
+	// keyed by application ID. Recorded times never move backwards and
+	// unknown applications are ignored.
+	RecordApplicationsLastUsed(ctx context.Context, lastUsed map[string]time.Time) error
+	
+	// ListDormantApplications retrieves the active applications not used
+	// since the given time, or created before it if never used, least
+	// recently active first
+	ListDormantApplications(ctx context.Context, unusedSince time.Time) ([]*Application, error)
+	
+	// SuspendDormantApplications suspends the applications ListDormantApplications
+	// returns and returns their IDs
+	SuspendDormantApplications(ctx context.Context, unusedSince time.Time) ([]string, error)
+	
+	// TransferApplications moves applications of fromUserID to toUserID
+	// atomically, or all of fromUserID's applications when appIDs is empty.
+	// Nothing is transferred if toUserID or any of the applications of
+	// fromUserID doesn't exist.
+	TransferApplications(ctx context.Context, fromUserID, toUserID string, appIDs []string) error
+	
+	// TransferApplication moves an application to newUserID. It fails with a
+	// not found error if the application or newUserID doesn't exist.
+	TransferApplication(ctx context.Context, appID, newUserID string) error
+	
 	// RegenerateAPIKey generates a new API key for an application
 	RegenerateAPIKey(ctx context.Context, appID string) (string, error)
 	
-	// RegenerateAPISecret generates a new API secret for an application
+	// RegenerateAPISecret generates a new API secret for an application. The
+	// secret is only stored hashed, so this is the only time it is returned.
 	RegenerateAPISecret(ctx context.Context, appID string) (string, error)
 	
+	// VerifyAPISecret reports whether a presented secret matches the API
+	// secret of an application. API secrets are stored hashed and never
+	// returned by lookups.
+	VerifyAPISecret(ctx context.Context, appID, presentedSecret string) (bool, error)
+	
+	// BatchGetApplications retrieves multiple applications by IDs, keyed by
+	// ID. Applications that don't exist are absent from the result.
+	BatchGetApplications(ctx context.Context, appIDs []string) (map[string]*Application, error)
+	
 	// BatchCreateApplications creates multiple applications in a single operation
 	BatchCreateApplications(ctx context.Context, apps []*Application) error
 	
-	// BatchUpdateApplications updates multiple applications in a single operation
+	// BatchUpdateApplications updates multiple applications in a single
+	// operation, checking their versions like UpdateApplication
 	BatchUpdateApplications(ctx context.Context, apps []*Application) error
 	
 	// BatchDeleteApplications deletes multiple applications by IDs
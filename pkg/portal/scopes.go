@@ -0,0 +1,55 @@
+package portal
+
+import (
+	"fmt"
+	"sort"
+)
+
+// MaxScopeLength caps the length of a single scope
+const MaxScopeLength = 128
+
+// ValidateScopes checks that every scope is a well-formed scope token, as
+// defined for OAuth 2.0 scopes in RFC 6749 section 3.3, and, when allowed is
+// not empty, that it is one of the allowed scopes
+func ValidateScopes(scopes, allowed []string) error {
+	var allowlist map[string]bool
+	if len(allowed) > 0 {
+		allowlist = make(map[string]bool, len(allowed))
+		for _, scope := range allowed {
+			allowlist[scope] = true
+		}
+	}
+
+	for _, scope := range scopes {
+		if scope == "" {
+			return NewValidationError("INVALID_SCOPE", "scope cannot be empty")
+		}
+		if len(scope) > MaxScopeLength {
+			return NewValidationError("INVALID_SCOPE", fmt.Sprintf("scope exceeds %d characters: %s", MaxScopeLength, scope))
+		}
+		for i := 0; i < len(scope); i++ {
+			if c := scope[i]; c <= ' ' || c == '"' || c == '\\' || c >= 0x7f {
+				return NewValidationError("INVALID_SCOPE", fmt.Sprintf("scope contains an invalid character: %q", scope))
+			}
+		}
+		if allowlist != nil && !allowlist[scope] {
+			return NewValidationError("SCOPE_NOT_ALLOWED", fmt.Sprintf("scope is not allowed: %s", scope))
+		}
+	}
+	return nil
+}
+
+// NormalizeScopes returns the scopes sorted and without duplicates. The
+// result is never nil.
+func NormalizeScopes(scopes []string) []string {
+	normalized := make([]string, 0, len(scopes))
+	seen := make(map[string]bool, len(scopes))
+	for _, scope := range scopes {
+		if !seen[scope] {
+			seen[scope] = true
+			normalized = append(normalized, scope)
+		}
+	}
+	sort.Strings(normalized)
+	return normalized
+}
@@ -1,6 +1,7 @@
 package portal
 
 import (
+	"fmt"
 	"time"
 )
 
@@ -14,6 +15,7 @@ type User struct {
 	Status    UserStatus `json:"status" db:"status"`
 	CreatedAt time.Time `json:"created_at" db:"created_at"`
 	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+	LastLoginAt *time.Time `json:"last_login_at,omitempty" db:"last_login_at"` // Last successful login, nil if the user never logged in
 }
 
 // UserRole represents the role of a user
@@ -41,11 +43,24 @@ type Application struct {
 	Description string            `json:"description" db:"description"`
 	UserID      string            `json:"user_id" db:"user_id"`
 	APIKey      string            `json:"api_key" db:"api_key"`
-	APISecret   string            `json:"api_secret" db:"api_secret"`
+	APISecret   string            `json:"api_secret,omitempty" db:"api_secret"` // Only set when the secret is issued, stored hashed
 	Status      ApplicationStatus `json:"status" db:"status"`
 	RateLimit   int64             `json:"rate_limit" db:"rate_limit"`
+	Scopes      []string          `json:"scopes" db:"scopes"` // Permissions granted to the application, sorted
 	CreatedAt   time.Time         `json:"created_at" db:"created_at"`
 	UpdatedAt   time.Time         `json:"updated_at" db:"updated_at"`
+	LastUsedAt  *time.Time        `json:"last_used_at,omitempty" db:"last_used_at"` // Last successful authentication with the API key, nil if never used
+	DeletedAt   *time.Time        `json:"deleted_at,omitempty" db:"deleted_at"`     // Time the application was soft-deleted, nil unless deleted
+	Version     int64             `json:"version" db:"version"`                     // Incremented by every change, for optimistic concurrency control
+}
+
+// LastActivity returns when the application was last used, or its creation
+// time if it was never used
+func (a *Application) LastActivity() time.Time {
+	if a.LastUsedAt != nil {
+		return *a.LastUsedAt
+	}
+	return a.CreatedAt
 }
 
 // ApplicationStatus represents the status of an application
@@ -79,6 +94,21 @@ type UserFilter struct {
 	// Date range
 	CreatedAfter  *time.Time `json:"created_after,omitempty"`
 	CreatedBefore *time.Time `json:"created_before,omitempty"`
+
+	// LastLoginBefore matches users who last logged in before the time,
+	// including users who never logged in, to find dormant accounts
+	LastLoginBefore *time.Time `json:"last_login_before,omitempty"`
+
+	// EstimateTotal allows an estimated Total in the result, see
+	// PaginatedUsers.TotalEstimated
+	EstimateTotal bool `json:"estimate_total,omitempty"`
+
+	// Cursor pagination, an alternative to Offset and Limit. Pages are
+	// ordered by created_at and then id, in SortOrder, and start after
+	// Cursor, the NextCursor of the previous page, or at the first user
+	// when only PageSize is set.
+	Cursor   string `json:"cursor,omitempty"`
+	PageSize int    `json:"page_size,omitempty"`
 }
 
 // ApplicationFilter represents filter criteria for application queries
@@ -95,6 +125,9 @@ type ApplicationFilter struct {
 	UserID string            `json:"user_id,omitempty"`
 	Name   string            `json:"name,omitempty"`
 	Status ApplicationStatus `json:"status,omitempty"`
+
+	// HasScope filters applications granted the scope
+	HasScope string `json:"has_scope,omitempty"`
 	
 	// Search
 	Search string `json:"search,omitempty"`
@@ -102,6 +135,64 @@ type ApplicationFilter struct {
 	// Date range
 	CreatedAfter  *time.Time `json:"created_after,omitempty"`
 	CreatedBefore *time.Time `json:"created_before,omitempty"`
+
+	// Strict rejects unknown sort fields and sort orders instead of
+	// silently falling back to created_at/desc
+	Strict bool `json:"strict,omitempty"`
+
+	// EstimateTotal allows an estimated Total in the result, see
+	// PaginatedApplications.TotalEstimated
+	EstimateTotal bool `json:"estimate_total,omitempty"`
+
+	// IncludeDeleted includes soft-deleted applications
+	IncludeDeleted bool `json:"include_deleted,omitempty"`
+
+	// Cursor pagination, an alternative to Offset and Limit, see
+	// UserFilter.Cursor
+	Cursor   string `json:"cursor,omitempty"`
+	PageSize int    `json:"page_size,omitempty"`
+}
+
+// validApplicationSortFields lists the fields applications can be sorted by
+var validApplicationSortFields = map[string]bool{
+	"id":         true,
+	"name":       true,
+	"user_id":    true,
+	"status":     true,
+	"rate_limit": true,
+	"created_at": true,
+	"updated_at": true,
+}
+
+// IsApplicationSortField reports whether applications can be sorted by the
+// field. SQL repositories build their ORDER BY from it, so strict validation
+// and sorting accept the same fields.
+func IsApplicationSortField(field string) bool {
+	return validApplicationSortFields[field]
+}
+
+// Validate checks the filter for inconsistent criteria. The created-at range
+// is always checked; sort field and sort order are only checked in strict mode.
+func (f *ApplicationFilter) Validate() error {
+	if f.CreatedAfter != nil && f.CreatedBefore != nil && f.CreatedAfter.After(*f.CreatedBefore) {
+		return NewValidationError("INVALID_CREATED_RANGE", "created_after cannot be after created_before")
+	}
+
+	if !f.Strict {
+		return nil
+	}
+
+	if f.CursorMode() && f.SortBy != "" && f.SortBy != "created_at" {
+		return NewValidationError("INVALID_SORT_FIELD", "cursor pagination only sorts by created_at")
+	}
+	if f.SortBy != "" && !IsApplicationSortField(f.SortBy) {
+		return NewValidationError("INVALID_SORT_FIELD", fmt.Sprintf("unknown sort field: %s", f.SortBy))
+	}
+	if f.SortOrder != "" && f.SortOrder != "asc" && f.SortOrder != "desc" {
+		return NewValidationError("INVALID_SORT_ORDER", fmt.Sprintf("invalid sort order: %s", f.SortOrder))
+	}
+
+	return nil
 }
 
 // PaginatedUsers represents a paginated list of users
@@ -111,6 +202,14 @@ type PaginatedUsers struct {
 	Offset     int     `json:"offset"`
 	Limit      int     `json:"limit"`
 	HasMore    bool    `json:"has_more"`
+
+	// TotalEstimated reports that Total is an estimate, which is only the
+	// case when the filter asked for EstimateTotal. HasMore is always exact.
+	TotalEstimated bool `json:"total_estimated"`
+
+	// NextCursor is the cursor of the next page in cursor pagination, set
+	// while more follow
+	NextCursor string `json:"next_cursor,omitempty"`
 }
 
 // PaginatedApplications represents a paginated list of applications
@@ -120,4 +219,12 @@ type PaginatedApplications struct {
 	Offset       int            `json:"offset"`
 	Limit        int            `json:"limit"`
 	HasMore      bool           `json:"has_more"`
+
+	// TotalEstimated reports that Total is an estimate, which is only the
+	// case when the filter asked for EstimateTotal. HasMore is always exact.
+	TotalEstimated bool `json:"total_estimated"`
+
+	// NextCursor is the cursor of the next page in cursor pagination, set
+	// while more follow
+	NextCursor string `json:"next_cursor,omitempty"`
 }
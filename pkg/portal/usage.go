@@ -0,0 +1,107 @@
+package portal
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Usage granularities supported by GetUsage
+const (
+	UsageGranularityMinute = "minute"
+	UsageGranularityHour   = "hour"
+	UsageGranularityDay    = "day"
+)
+
+// MaxUsageBuckets caps the number of buckets a single usage query may return
+const MaxUsageBuckets = 1440
+
+// UsageDelta represents usage to be added to an application's counters
+type UsageDelta struct {
+	ApplicationID string    `json:"application_id"`
+	Timestamp     time.Time `json:"timestamp"`
+	Requests      int64     `json:"requests"`
+	Errors        int64     `json:"errors"`
+	Bytes         int64     `json:"bytes"`
+}
+
+// UsageBucket represents aggregated usage for one time bucket
+type UsageBucket struct {
+	Start    time.Time `json:"start"`
+	Requests int64     `json:"requests"`
+	Errors   int64     `json:"errors"`
+	Bytes    int64     `json:"bytes"`
+}
+
+// UsageRepository defines the interface for application usage data operations.
+//
+// Usage is recorded at minute resolution and aggregated by summing counters
+// into buckets aligned to UTC minute, hour or day boundaries. Queries cover
+// the half-open range [from, to) with from rounded down to the bucket boundary,
+// and return one bucket per interval with zero-filled gaps. Retention depends
+// on the implementation.
+type UsageRepository interface {
+	// RecordUsage adds the delta to the application's usage counters
+	RecordUsage(ctx context.Context, delta *UsageDelta) error
+
+	// GetUsage returns time-bucketed usage for an application
+	GetUsage(ctx context.Context, appID string, from, to time.Time, granularity string) ([]UsageBucket, error)
+}
+
+// UsageGranularityDuration returns the bucket width for a granularity
+func UsageGranularityDuration(granularity string) (time.Duration, error) {
+	switch granularity {
+	case UsageGranularityMinute:
+		return time.Minute, nil
+	case UsageGranularityHour:
+		return time.Hour, nil
+	case UsageGranularityDay:
+		return 24 * time.Hour, nil
+	default:
+		return 0, NewValidationError("INVALID_GRANULARITY", fmt.Sprintf("unsupported usage granularity: %s", granularity))
+	}
+}
+
+// ValidateUsageDelta validates a usage delta before it is recorded
+func ValidateUsageDelta(delta *UsageDelta) error {
+	if delta == nil {
+		return NewValidationError("INVALID_USAGE_DELTA", "usage delta cannot be nil")
+	}
+	if delta.ApplicationID == "" {
+		return NewValidationError("INVALID_APPLICATION_ID", "application ID cannot be empty")
+	}
+	if delta.Requests < 0 || delta.Errors < 0 || delta.Bytes < 0 {
+		return NewValidationError("INVALID_USAGE_DELTA", "usage counters cannot be negative")
+	}
+	return nil
+}
+
+// NewUsageBuckets validates a usage query and returns the zero-filled buckets
+// covering [from, to) at the given granularity
+func NewUsageBuckets(appID string, from, to time.Time, granularity string) ([]UsageBucket, error) {
+	if appID == "" {
+		return nil, NewValidationError("INVALID_APPLICATION_ID", "application ID cannot be empty")
+	}
+
+	step, err := UsageGranularityDuration(granularity)
+	if err != nil {
+		return nil, err
+	}
+
+	if !from.Before(to) {
+		return nil, NewValidationError("INVALID_USAGE_RANGE", "from must be before to")
+	}
+
+	start := from.UTC().Truncate(step)
+	count := int((to.Sub(start) + step - 1) / step)
+	if count > MaxUsageBuckets {
+		return nil, NewValidationError("USAGE_RANGE_TOO_LARGE",
+			fmt.Sprintf("usage range spans %d buckets, maximum is %d", count, MaxUsageBuckets))
+	}
+
+	buckets := make([]UsageBucket, count)
+	for i := range buckets {
+		buckets[i].Start = start.Add(time.Duration(i) * step)
+	}
+	return buckets, nil
+}
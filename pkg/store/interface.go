@@ -138,6 +138,35 @@ type AtomicStore interface {
 	Health(ctx context.Context) HealthStatus
 }
 
+// PrefixDeleter is implemented by atomic stores that can remove keys in bulk.
+// It's used to flush state such as rate limit counters without touching
+// unrelated keys sharing the same backend.
+type PrefixDeleter interface {
+	// DeletePrefix removes all keys starting with prefix, within the store's
+	// own key prefix, and returns the number of keys removed
+	DeletePrefix(ctx context.Context, prefix string) (int64, error)
+}
+
+// ExclusiveSetter is implemented by atomic stores that can set a key only if
+// it doesn't exist. It's used to claim work, such as an idempotency key, so
+// that only one node processes it.
+type ExclusiveSetter interface {
+	// SetNX stores value under key with optional TTL if the key doesn't
+	// exist, and reports whether it was stored
+	SetNX(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error)
+}
+
+// ExpiringIncrementer is implemented by atomic stores that can increment a
+// counter and set its TTL in one atomic operation. It's used for counters
+// such as rate limit windows, where creating the key, setting its TTL and
+// incrementing it separately lets concurrent nodes reset each other's counts.
+type ExpiringIncrementer interface {
+	// IncrByWithTTL atomically increments the value of a key by the given
+	// amount, creating it with the increment value if it doesn't exist, and
+	// sets ttl on it if it has no expiration. Returns the new value.
+	IncrByWithTTL(ctx context.Context, key string, value int64, ttl time.Duration) (int64, error)
+}
+
 // DistributedStore defines the interface for distributed storage operations
 type DistributedStore interface {
 	Store